@@ -0,0 +1,47 @@
+"""Allowed directories - the part of the file system the server may touch.
+
+A client can pass any path, so a server started with `--allow-dir` only
+works inside those directories: a root_path, project or file outside them
+is refused with PATH_NOT_ALLOWED. Paths are resolved before the check, so
+`../` segments and symlinks count by where they lead - a link inside an
+allowed directory pointing at /etc is outside. Without --allow-dir nothing
+is restricted.
+"""
+
+import os
+from typing import Any, Dict, Iterable, Union
+
+from xray.core.errors import PathNotAllowed
+
+PathLike = Union[str, os.PathLike]
+
+
+class AllowList:
+    """The directories paths must resolve into; empty means any path."""
+
+    def __init__(self, dirs: Iterable[PathLike] = ()):
+        self.dirs = sorted({os.path.realpath(os.path.expanduser(str(d))) for d in dirs})
+
+    @property
+    def restricted(self) -> bool:
+        return bool(self.dirs)
+
+    def allows(self, path: PathLike) -> bool:
+        """Whether a path, once symlinks and .. are resolved, lies in an allowed directory."""
+        if not self.dirs:
+            return True
+        real = os.path.realpath(os.path.expanduser(str(path)))
+        return any(real == d or real.startswith(d.rstrip(os.sep) + os.sep) for d in self.dirs)
+
+    def check(self, path: PathLike) -> str:
+        """The resolved path, or PathNotAllowed if it lies outside every allowed directory."""
+        real = os.path.realpath(os.path.expanduser(str(path)))
+        if not self.allows(real):
+            via = f" (resolves to '{real}')" if real != os.path.abspath(os.path.expanduser(str(path))) else ""
+            raise PathNotAllowed(
+                f"'{path}'{via} is outside the allowed directories ({', '.join(self.dirs)}) - "
+                f"see allowed_directories", str(path))
+        return real
+
+    def describe(self) -> Dict[str, Any]:
+        return {"restricted": self.restricted, "allowed_dirs": list(self.dirs)}
@@ -0,0 +1,182 @@
+"""Parses of historical file versions, kept by git blob SHA between calls and restarts.
+
+History tools parse the same old versions over and over: symbol_history
+walks a hot file's dozens of commits on every call, hotspots map every
+commit's hunks onto the symbols of the file at that commit, diff_symbols
+parses both sides of every changed file. A blob never changes, so what was
+extracted from one - a symbol table, the spans of its declarations - is
+kept here under (kind, parser version, blob SHA, path) and reused by every
+tool and every project of the server. The path is part of the key since
+the parser, and what it makes of the file (a _test.go file, a Python
+module's name), follows from it.
+
+The cache is bounded by entry count and by approximate bytes (see
+xray.core.memory), the least recently used entry evicted first. Entries
+are built outside the lock, so a slow parse never holds up a lookup; two
+threads missing the same blob both parse it, and the second result
+replaces the first. It is persisted under the cache root next to the
+project indexes, in the same checksummed format (see xray.core.cache),
+loaded on first use. Entries from another parser version are dropped when
+it is loaded, and never match a lookup, so a parser fix is seen at once;
+TABLE_VERSION covers the shape of the extracted tables themselves.
+"""
+
+import os
+import tempfile
+import threading
+from collections import OrderedDict
+from pathlib import Path
+from typing import Any, Callable, Dict, Optional, Tuple
+
+from xray.core.cache import cache_root, decode, encode
+from xray.core.memory import MEGABYTE, approximate_size
+from xray.core.parse_pool import parser_version
+
+# Bounds of the cache (--history-cache-entries / --history-cache-mb); 0 entries caches nothing
+DEFAULT_ENTRIES = 20000
+DEFAULT_MB = 256
+# The shape of what history tools extract from a parse; bump it when one changes
+TABLE_VERSION = 1
+# What a build returns for a version that does not parse (a merge conflict committed by
+# mistake): cached like any value, unlike None
+UNPARSEABLE = "unparseable"
+
+Key = Tuple[str, int, str, str]
+
+
+def cache_file() -> Path:
+    """Where the server persists its history cache."""
+    return cache_root() / "history" / "blobs.bin"
+
+
+class BlobCache:
+    """Values extracted from historical file versions, least recently used evicted first."""
+
+    def __init__(self, max_entries: int = DEFAULT_ENTRIES, max_bytes: int = DEFAULT_MB * MEGABYTE,
+                 path: Optional[Path] = None):
+        self.max_entries = max_entries
+        self.max_bytes = max_bytes
+        self.path = path
+        # key -> (value, approximate bytes)
+        self._entries: "OrderedDict[Key, Tuple[Any, int]]" = OrderedDict()
+        self._bytes = 0
+        self._lock = threading.Lock()
+        self._loaded = path is None
+        self._dirty = False
+        self.hits = 0
+        self.misses = 0
+        self.evictions = 0
+        self.load_error: Optional[str] = None
+
+    def get(self, kind: str, blob: str, path: str, build: Callable[[], Any]) -> Any:
+        """
+        The value of kind for one version of a file, from the cache or from
+        build(), called without the lock held. None from build - the blob
+        could not be read, and a partial clone may fetch it later - is
+        returned but not cached.
+        """
+        key = (kind, parser_version(path), blob, path)
+        with self._lock:
+            self._load()
+            entry = self._entries.get(key)
+            if entry is not None:
+                self._entries.move_to_end(key)
+                self.hits += 1
+                return entry[0]
+            self.misses += 1
+        value = build()
+        if value is not None and self.max_entries > 0:
+            size = approximate_size(value) + approximate_size(key)
+            with self._lock:
+                self._store(key, value, size)
+                self._dirty = True
+        return value
+
+    def _store(self, key: Key, value: Any, size: int):
+        previous = self._entries.pop(key, None)
+        if previous is not None:
+            self._bytes -= previous[1]
+        if size > self.max_bytes:
+            return
+        self._entries[key] = (value, size)
+        self._bytes += size
+        while len(self._entries) > self.max_entries or self._bytes > self.max_bytes:
+            _, (_, evicted) = self._entries.popitem(last=False)
+            self._bytes -= evicted
+            self.evictions += 1
+
+    def _load(self):
+        """Read the persisted entries once, dropping those of other parser versions; the caller holds the lock."""
+        if self._loaded:
+            return
+        self._loaded = True
+        try:
+            data = decode(self.path.read_bytes())
+            if not isinstance(data, dict) or data.get("table_version") != TABLE_VERSION:
+                raise ValueError("unexpected history cache payload")
+        except FileNotFoundError:
+            return
+        except Exception as e:
+            self.load_error = f"{self.path}: {e}"
+            return
+        for key, value, size in data.get("entries", []):
+            if key[1] == parser_version(key[3]) and key not in self._entries:
+                self._store(key, value, size)
+        # The bounds may have shrunk since the file was written
+        self._dirty = len(self._entries) < len(data.get("entries", []))
+
+    def save(self) -> bool:
+        """Persist the entries atomically if they changed; failures (read-only disk, etc.) are not fatal."""
+        if self.path is None:
+            return False
+        with self._lock:
+            if not self._dirty:
+                return False
+            data = {"table_version": TABLE_VERSION,
+                    "entries": [(key, value, size) for key, (value, size) in self._entries.items()]}
+            payload = encode(data)
+            self._dirty = False
+        try:
+            self.path.parent.mkdir(parents=True, exist_ok=True)
+            fd, tmp = tempfile.mkstemp(dir=self.path.parent, prefix=".blobs-")
+            with os.fdopen(fd, "wb") as f:
+                f.write(payload)
+            os.replace(tmp, self.path)
+            return True
+        except Exception:
+            with self._lock:
+                self._dirty = True
+            return False
+
+    def clear(self) -> int:
+        """Forget every entry, in memory and on disk; returns the bytes the file took."""
+        with self._lock:
+            self._entries.clear()
+            self._bytes = 0
+            self._loaded = True
+            self._dirty = False
+        freed = 0
+        if self.path is not None:
+            try:
+                freed = self.path.stat().st_size
+                self.path.unlink()
+            except OSError:
+                pass
+        return freed
+
+    def stats(self) -> Dict[str, Any]:
+        """Entries and approximate bytes held against the bounds, and how often lookups found theirs."""
+        with self._lock:
+            looked_up = self.hits + self.misses
+            result: Dict[str, Any] = {
+                "entries": len(self._entries), "approximate_bytes": self._bytes,
+                "max_entries": self.max_entries, "max_bytes": self.max_bytes,
+                "hits": self.hits, "misses": self.misses,
+                "hit_rate": round(self.hits / looked_up, 3) if looked_up else None,
+                "evictions": self.evictions,
+            }
+        if self.path is not None:
+            result["cache_file"] = str(self.path)
+        if self.load_error:
+            result["load_error"] = self.load_error
+        return result
@@ -0,0 +1,135 @@
+"""What an unsaved edit of one file does to the rest of the index.
+
+An editor buffer - the content of a file as an agent is about to write
+it - is parsed on its own and its declarations compared with those the
+index holds for the file:
+
+    added              declared by the buffer only
+    removed            declared by the indexed file only
+    signature_changed  declared by both, the signature differs beyond whitespace
+
+Declarations match by language, qualified name ("Type.Method",
+"Class.member") and kind. A removed or re-signatured declaration leaves
+dangling whatever the rest of the index has pointing at it:
+
+- Go: calls of, and references to, a function or method from other
+  files, as the call graph resolved them - unless another file of the
+  package (a build-constrained variant) still declares it;
+- TypeScript, JavaScript and Python: named imports of it in other files
+  (`import { X } from "./m"`, `from m import X`).
+
+Nothing is re-parsed or patched in the index: the buffer is only compared
+with it.
+"""
+
+import os
+import re
+from typing import Any, Dict, Iterable, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+
+_SPACE = re.compile(r"\s+")
+
+# Changes that leave references dangling
+BREAKING = ("removed", "signature_changed")
+
+
+def _normalized(signature: Optional[str]) -> str:
+    return _SPACE.sub(" ", signature or "").strip()
+
+
+def declaration_changes(old: List[Dict[str, Any]], new: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+    """
+    The added, removed and signature_changed declarations between two lists
+    of a file's declarations ({"id", "qualified", "kind", "language",
+    "signature", "line"}, as XRayIndexer._declarations lists them), in
+    order of the line they are (or were) declared on.
+    """
+    def keyed(declarations: List[Dict[str, Any]]) -> Dict[Tuple[str, str, str], Dict[str, Any]]:
+        # Overloads and platform variants in one file: the first declaration stands for them
+        table: Dict[Tuple[str, str, str], Dict[str, Any]] = {}
+        for declaration in declarations:
+            if not declaration["nested"]:
+                table.setdefault((declaration["language"], declaration["qualified"], declaration["kind"]), declaration)
+        return table
+
+    before, after = keyed(old), keyed(new)
+    changes = []
+    for key in before.keys() | after.keys():
+        was, now = before.get(key), after.get(key)
+        if was is None:
+            change = "added"
+        elif now is None:
+            change = "removed"
+        elif _normalized(was["signature"]) != _normalized(now["signature"]):
+            change = "signature_changed"
+        else:
+            continue
+        entry: Dict[str, Any] = {"name": key[1], "kind": key[2], "change": change}
+        if was is not None:
+            entry.update({"symbol_id": was["id"], "old_signature": was["signature"], "old_line": was["line"]})
+        if now is not None:
+            entry.update({"new_symbol_id": now["id"], "new_signature": now["signature"], "new_line": now["line"]})
+        changes.append(entry)
+    changes.sort(key=lambda c: (c.get("new_line") or c.get("old_line") or 0, c["name"]))
+    return changes
+
+
+def dangling_calls(graph: GoCallGraph, path: str, changes: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+    """Calls of and references to a Go function or method the buffer of path removed or re-signatured."""
+    pkg_dir = os.path.dirname(path)
+    broken = {(pkg_dir, c["name"]): c["change"] for c in changes
+              if c["change"] in BREAKING and c["kind"] in ("function", "method")}
+    if not broken:
+        return []
+    # Still declared by another file of the package: a removal there is no removal at all
+    for other in graph.project.packages.get(pkg_dir, {}).get("files", []):
+        if other == path:
+            continue
+        for symbol in graph.project.files[other]["symbols"]:
+            owner = (symbol.get("receiver") or {}).get("type") or symbol.get("container")
+            key = (pkg_dir, f"{owner}.{symbol['name']}" if owner else symbol["name"])
+            if symbol["type"] in ("function", "method") and broken.get(key) == "removed":
+                del broken[key]
+    dangling = []
+    for edge in graph.edges:
+        if edge["external"] or edge["callee"] not in broken or edge["path"] == path:
+            continue
+        dangling.append({
+            "symbol": edge["callee"][1],
+            "change": broken[edge["callee"]],
+            "from": edge["caller"][1],
+            "kind": edge["kind"],
+            "path": edge["path"],
+            "line": edge["line"],
+            "column": edge["column"],
+        })
+    return dangling
+
+
+def dangling_imports(path: str, changes: List[Dict[str, Any]],
+                     importers: Iterable[Tuple[str, List[Dict[str, Any]]]]) -> List[Dict[str, Any]]:
+    """
+    Named imports of a top-level declaration the buffer of path removed or
+    re-signatured, from importers: (file, its imports with "resolved"
+    files, per name too for Python) for every other file.
+    """
+    broken = {c["name"]: c["change"] for c in changes if c["change"] in BREAKING and "." not in c["name"]}
+    if not broken:
+        return []
+    dangling = []
+    for importer, imports in importers:
+        if importer == path:
+            continue
+        for imp in imports:
+            for name in imp.get("names") or ():
+                if name.get("resolved", imp.get("resolved")) != path or name["imported"] not in broken:
+                    continue
+                dangling.append({
+                    "symbol": name["imported"],
+                    "change": broken[name["imported"]],
+                    "kind": "import",
+                    "path": importer,
+                    "line": imp.get("line"),
+                })
+    return dangling
@@ -0,0 +1,216 @@
+"""On-disk persistence of the index between server restarts.
+
+Each project gets a directory under the user cache dir, keyed by a hash of
+its path, holding one index file per commit. Files are a zlib-compressed
+pickle behind a header with a format version and a SHA-256 of the payload,
+so truncated or corrupt files are detected and discarded instead of
+crashing the server. After each write the oldest commits' indexes are
+pruned, keeping the KEEP_INDEXES most recent within MAX_CACHE_BYTES
+(XRAY_CACHE_KEEP and XRAY_CACHE_MAX_MB override them). Entries inside are validated per file against mtime,
+size and content hash by the indexer, which is what makes seeding a new
+commit from the previous commit's index safe.
+"""
+
+import hashlib
+import os
+import pickle
+import shutil
+import sys
+import tempfile
+import zlib
+from pathlib import Path
+from typing import Dict, Optional, Any
+
+_MAGIC = b"XRAYIDX"
+# 2: the symbols of parse results carry their declaration ranges
+# 3: ranges and line counts break lines at line feeds only (see source_text.source_lines)
+# 4: parse results carry the renamed fields' new names (see schema.RENAMES)
+# 5: so do their transcodedFrom and buildConstraints
+_FORMAT_VERSION = 5
+_INDEX_FILE = "index.bin"
+# Commits' indexes kept per project, this commit's included, and the bytes they may take together
+KEEP_INDEXES = 5
+MAX_CACHE_BYTES = 512 * 1024 * 1024
+
+
+def cache_root() -> Path:
+    """The per-user cache directory for XRAY (XRAY_CACHE_DIR overrides it)."""
+    override = os.environ.get("XRAY_CACHE_DIR")
+    if override:
+        return Path(override)
+    if sys.platform == "darwin":
+        base = Path.home() / "Library" / "Caches"
+    elif os.name == "nt":
+        base = Path(os.environ.get("LOCALAPPDATA") or Path.home() / "AppData" / "Local")
+    else:
+        base = Path(os.environ.get("XDG_CACHE_HOME") or Path.home() / ".cache")
+    return base / "xray"
+
+
+def _limit(name: str, default: int, scale: int = 1) -> int:
+    """A positive integer setting from the environment, or default."""
+    try:
+        configured = int(os.environ.get(name, "0"))
+    except ValueError:
+        configured = 0
+    return configured * scale if configured > 0 else default
+
+
+def _dir_size(path: Path) -> int:
+    size = 0
+    for file in path.rglob("*"):
+        try:
+            if file.is_file():
+                size += file.stat().st_size
+        except OSError:
+            pass
+    return size
+
+
+def encode(data: Any) -> bytes:
+    """An object as the bytes of an index file: header, checksum, compressed pickle."""
+    payload = zlib.compress(pickle.dumps(data, protocol=pickle.HIGHEST_PROTOCOL), 1)
+    return _MAGIC + bytes([_FORMAT_VERSION]) + hashlib.sha256(payload).digest() + payload
+
+
+def decode(blob: bytes) -> Any:
+    """The object in index file bytes, raising ValueError if they are not a valid one."""
+    header = len(_MAGIC) + 1
+    if blob[:len(_MAGIC)] != _MAGIC:
+        raise ValueError("not an XRAY index file")
+    if blob[len(_MAGIC)] != _FORMAT_VERSION:
+        raise ValueError(f"unsupported index format {blob[len(_MAGIC)]}")
+    digest, payload = blob[header:header + 32], blob[header + 32:]
+    if hashlib.sha256(payload).digest() != digest:
+        raise ValueError("checksum mismatch")
+    return pickle.loads(zlib.decompress(payload))
+
+
+class IndexCache:
+    """The persisted index of one project at one commit."""
+
+    def __init__(self, project_path: Path, key: str):
+        self.project_path = Path(project_path)
+        self.key = key
+        self.project_dir = cache_root() / "projects" / hashlib.sha256(str(project_path).encode()).hexdigest()[:16]
+        self.path = self.project_dir / key / _INDEX_FILE
+        self.loaded_from: Optional[str] = None
+        self.load_error: Optional[str] = None
+
+    def _candidates(self):
+        yield self.path
+        # No index for this commit yet: start from the most recent one
+        try:
+            others = [p for p in self.project_dir.glob(f"*/{_INDEX_FILE}") if p != self.path]
+        except OSError:
+            return
+        yield from sorted(others, key=lambda p: p.stat().st_mtime, reverse=True)[:1]
+
+    def load(self) -> Dict[str, Any]:
+        """Return the persisted index, or an empty one if none is usable."""
+        for candidate in self._candidates():
+            if not candidate.is_file():
+                continue
+            try:
+                data = decode(candidate.read_bytes())
+                if not isinstance(data, dict):
+                    raise ValueError("unexpected index payload")
+            except Exception as e:
+                self.load_error = f"{candidate}: {e}"
+                try:
+                    candidate.unlink()
+                except OSError:
+                    pass
+                continue
+            self.loaded_from = str(candidate)
+            return data
+        return {}
+
+    def save(self, data: Dict[str, Any]) -> bool:
+        """Write the index atomically; failures (read-only disk, etc.) are not fatal."""
+        try:
+            self.path.parent.mkdir(parents=True, exist_ok=True)
+            (self.project_dir / "project").write_text(str(self.project_path))
+            fd, tmp = tempfile.mkstemp(dir=self.path.parent, prefix=".index-")
+            with os.fdopen(fd, "wb") as f:
+                f.write(encode(data))
+            os.replace(tmp, self.path)
+        except Exception:
+            return False
+        self.prune()
+        return True
+
+    def prune(self) -> int:
+        """
+        Delete the indexes of other commits beyond the most recent ones
+        kept, newest first while they fit the size cap with this commit's
+        (which is always kept); returns the bytes freed.
+        """
+        keep = _limit("XRAY_CACHE_KEEP", KEEP_INDEXES)
+        max_bytes = _limit("XRAY_CACHE_MAX_MB", MAX_CACHE_BYTES, 1024 * 1024)
+        try:
+            dirs = [d for d in self.project_dir.iterdir() if d.is_dir() and d != self.path.parent]
+            dirs.sort(key=lambda d: (d / _INDEX_FILE).stat().st_mtime if (d / _INDEX_FILE).is_file()
+                      else d.stat().st_mtime, reverse=True)
+        except OSError:
+            return 0
+        total = self.size()
+        kept = 1
+        freed = 0
+        for directory in dirs:
+            size = _dir_size(directory)
+            if kept < keep and total + size <= max_bytes:
+                kept += 1
+                total += size
+                continue
+            shutil.rmtree(directory, ignore_errors=True)
+            if not directory.exists():
+                freed += size
+        return freed
+
+    def verify(self) -> Dict[str, Any]:
+        """
+        Check the persisted index files of the project: this commit's and
+        the others kept. Each is {"path", "size_bytes", "valid", "error",
+        "files"} - files being the parse results it holds; "valid" is None
+        for this commit's when it was never saved.
+        """
+        found: Dict[str, Any] = {"path": str(self.path), "exists": self.path.is_file(), "size_bytes": 0,
+                                 "valid": None, "error": None, "load_error": self.load_error, "others": []}
+        try:
+            others = sorted(p for p in self.project_dir.glob(f"*/{_INDEX_FILE}") if p != self.path)
+        except OSError:
+            others = []
+        for candidate in ([self.path] if found["exists"] else []) + others:
+            entry: Dict[str, Any] = {"path": str(candidate), "size_bytes": 0, "valid": False, "error": None}
+            try:
+                blob = candidate.read_bytes()
+                entry["size_bytes"] = len(blob)
+                data = decode(blob)
+                if not isinstance(data, dict):
+                    raise ValueError("unexpected index payload")
+                entry["valid"] = True
+                entry["files"] = sum(len(v) for k, v in data.items() if "-index:" in k and isinstance(v, dict))
+            except Exception as e:
+                entry["error"] = str(e)
+            if candidate == self.path:
+                found.update({key: value for key, value in entry.items() if key != "path"})
+            else:
+                found["others"].append(entry)
+        return found
+
+    def size(self) -> int:
+        try:
+            return self.path.stat().st_size
+        except OSError:
+            return 0
+
+    def clear(self) -> int:
+        """Delete every persisted index of this project; returns the bytes freed."""
+        freed = 0
+        if self.project_dir.is_dir():
+            for path in self.project_dir.rglob("*"):
+                if path.is_file():
+                    freed += path.stat().st_size
+            shutil.rmtree(self.project_dir, ignore_errors=True)
+        return freed
@@ -0,0 +1,279 @@
+"""Source cut into chunks for embedding: whole declarations, never half a function.
+
+A file is laid out as units, in line order:
+
+    a top-level declaration   its doc comment, decorators or attributes, the
+                              declaration and its body; members and nested
+                              declarations stay inside their parent's unit
+    the code between them     package clauses, imports, statements at module
+                              level - blank lines trimmed
+
+Units then become chunks against a token budget:
+
+    coalesced   neighbouring units of the same file, each smaller than
+                target_tokens, joined while the chunk stays within it
+    alone       a unit between target_tokens and max_chunk_tokens
+    split       a unit over max_chunk_tokens, cut at statement boundaries - the
+                line after which brackets are shallowest (indentation in
+                Python), never mid-expression where there is a choice -
+                each part starting with the last overlap_tokens of the one
+                before
+
+Tokens are counted by an estimator (ESTIMATORS): any callable from text to
+a count. A chunk is a function of its file's content only, its chunk_id a
+hash of its file, lines and text, so an unchanged file yields the same
+chunks in every run.
+"""
+
+import hashlib
+import re
+from typing import Any, Callable, Dict, Iterator, List, Optional, Tuple
+
+from xray.core.source_text import source_lines
+
+CHUNK_COLUMNS = ("chunk_id", "file", "language", "package", "start_line", "end_line", "symbol_ids", "symbols",
+                 "part", "parts", "tokens", "file_hash", "text")
+
+DEFAULT_TARGET_TOKENS = 512
+DEFAULT_MAX_TOKENS = 1024
+DEFAULT_OVERLAP_TOKENS = 64
+
+# Kinds standing for the whole file, not a place in it
+_FILE_KINDS = {"module", "package"}
+_LINE_COMMENTS = {"python": ("#",), "shell": ("#",), "make": ("#",), "sql": ("--",)}
+_BLOCK_COMMENTS = {"go", "typescript", "javascript", "rust", "java", "proto", "sql"}
+# Decorators and attributes directly above a declaration belong to it
+_ATTRIBUTES = {"python": ("@",), "java": ("@",), "typescript": ("@",), "javascript": ("@",), "rust": ("#[",)}
+_OPEN, _CLOSE = "([{", ")]}"
+_WORD = re.compile(r"\w+|[^\w\s]")
+
+
+def _chars(text: str) -> int:
+    return len(text) // 4 + 1
+
+
+def _words(text: str) -> int:
+    return len(_WORD.findall(text))
+
+
+def _tiktoken() -> Callable[[str], int]:
+    try:
+        import tiktoken
+    except ImportError:
+        raise ValueError("tokenizer 'tiktoken' needs the tiktoken package installed") from None
+    encoding = tiktoken.get_encoding("cl100k_base")
+    return lambda text: len(encoding.encode(text, disallowed_special=()))
+
+
+# name -> factory of a text -> tokens estimator
+ESTIMATORS: Dict[str, Callable[[], Callable[[str], int]]] = {
+    # About four characters a token, as max_tokens budgets are estimated
+    "chars": lambda: _chars,
+    # Identifiers, numbers and punctuation marks, closer to code tokenizers
+    "words": lambda: _words,
+    # OpenAI's cl100k_base, when installed
+    "tiktoken": _tiktoken,
+}
+
+
+def estimator(name: str) -> Callable[[str], int]:
+    """The token estimator registered as name."""
+    if name not in ESTIMATORS:
+        raise ValueError(f"tokenizer must be one of {', '.join(ESTIMATORS)}")
+    return ESTIMATORS[name]()
+
+
+def leading_start(lines: List[str], start_line: int, language: str, floor: int = 1) -> int:
+    """
+    First line of the doc comment, decorators and attributes directly above
+    a declaration (start_line if none), not above floor.
+    """
+    comments = _LINE_COMMENTS.get(language, ("//",))
+    attributes = _ATTRIBUTES.get(language, ())
+    line = start_line - 1
+    while line >= floor:
+        text = lines[line - 1].strip()
+        if text.startswith(comments) or attributes and text.startswith(attributes):
+            line -= 1
+        elif language in _BLOCK_COMMENTS and text.endswith("*/"):
+            while line > floor and "/*" not in lines[line - 1]:
+                line -= 1
+            line -= 1
+        else:
+            break
+    return line + 1
+
+
+def boundary_scores(lines: List[str], language: str) -> List[int]:
+    """
+    How bad a cut after each line is: the bracket depth left open, with
+    Python's the indentation of the next line on top, and a line ending
+    inside a string or block comment worst of all.
+    """
+    comments = _LINE_COMMENTS.get(language, ("//",))
+    blocks = language in _BLOCK_COMMENTS
+    python = language == "python"
+    depth, quote, in_block = 0, None, False
+    open_after: List[int] = []
+    for text in lines:
+        i = 0
+        while i < len(text):
+            char = text[i]
+            if in_block:
+                if text.startswith("*/", i):
+                    in_block = False
+                    i += 1
+            elif quote:
+                if char == "\\":
+                    i += 1
+                elif text.startswith(quote, i):
+                    i += len(quote) - 1
+                    quote = None
+            elif text.startswith(comments, i):
+                break
+            elif blocks and text.startswith("/*", i):
+                in_block = True
+                i += 1
+            elif python and text.startswith(('"""', "'''"), i):
+                quote = text[i:i + 3]
+                i += 2
+            elif char in "\"`" or char == "'" and (language != "rust" or "'" in text[i + 1:i + 5]):
+                # A quote not closing within a few characters is a Rust lifetime, not a character
+                quote = char
+            elif char in _OPEN:
+                depth += 1
+            elif char in _CLOSE:
+                depth = max(0, depth - 1)
+            i += 1
+        # Single-quoted strings and characters end with their line
+        if quote in ("'", '"') and language != "shell":
+            quote = None
+        open_after.append(100000 if quote or in_block else depth * 100)
+    if python:
+        indents = [len(t) - len(t.lstrip()) if t.strip() else None for t in lines]
+        following: Optional[int] = 0
+        for k in range(len(lines) - 1, -1, -1):
+            open_after[k] += following or 0
+            following = indents[k] if indents[k] is not None else following
+    return open_after
+
+
+class Chunker:
+    """Cuts indexed files into chunks (see the module docstring)."""
+
+    def __init__(self, estimate: Callable[[str], int], target_tokens: int = DEFAULT_TARGET_TOKENS,
+                 max_tokens: int = DEFAULT_MAX_TOKENS, overlap_tokens: int = DEFAULT_OVERLAP_TOKENS):
+        if target_tokens < 1:
+            raise ValueError("target_tokens must be at least 1")
+        if max_tokens < target_tokens:
+            raise ValueError("max_chunk_tokens must be at least target_tokens")
+        if overlap_tokens < 0 or overlap_tokens >= max_tokens:
+            raise ValueError("overlap_tokens must be at least 0 and less than max_chunk_tokens")
+        self.estimate = estimate
+        self.target_tokens = target_tokens
+        self.max_tokens = max_tokens
+        self.overlap_tokens = overlap_tokens
+
+    def units(self, lines: List[str], symbols: List[Dict[str, Any]], language: str) -> List[Tuple[int, int]]:
+        """The line ranges of a file's units: top-level declarations and the code between them."""
+        spans: List[List[int]] = []
+        for symbol in sorted((s for s in symbols if not s.get("container") and not s.get("nested")
+                              and s["type"] not in _FILE_KINDS), key=lambda s: s["start_line"]):
+            end = min(max(symbol.get("end_line") or symbol["start_line"], symbol["start_line"]), len(lines))
+            if spans and symbol["start_line"] <= spans[-1][1]:
+                spans[-1][1] = max(spans[-1][1], end)
+                continue
+            floor = spans[-1][1] + 1 if spans else 1
+            spans.append([leading_start(lines, symbol["start_line"], language, floor), end])
+        units: List[Tuple[int, int]] = []
+        line = 1
+        for start, end in spans + [[len(lines) + 1, len(lines)]]:
+            gap = [k for k in range(line, start) if lines[k - 1].strip()]
+            if gap:
+                units.append((gap[0], gap[-1]))
+            if start <= end:
+                units.append((start, end))
+            line = end + 1
+        return units
+
+    def _text(self, lines: List[str], start: int, end: int) -> str:
+        return "\n".join(lines[start - 1:end])
+
+    def _split(self, lines: List[str], start: int, end: int, scores: List[int]) -> List[Tuple[int, int]]:
+        """A unit over max_tokens cut into parts, each after the first overlapping the one before."""
+        sizes = [self.estimate(lines[k - 1] + "\n") for k in range(start, end + 1)]
+        size = lambda a, b: sum(sizes[a - start:b - start + 1])
+        parts: List[Tuple[int, int]] = []
+        # first: the part's first line, overlap included; fresh: the first line no part has yet
+        first = fresh = start
+        while fresh < end and size(first, end) > self.max_tokens:
+            furthest = fresh
+            while furthest + 1 < end and size(first, furthest + 1) <= self.max_tokens:
+                furthest += 1
+            # The shallowest cut in the window past its first quarter, the latest of equals
+            low = min(furthest, max(fresh, first + (furthest - first) // 4))
+            cut = min(range(low, furthest + 1), key=lambda k: (scores[k - 1], -k))
+            parts.append((first, cut))
+            fresh = first = cut + 1
+            # The last lines of the part just cut come along, within overlap_tokens, from a boundary if one is
+            overlap = [k for k in range(cut, parts[-1][0], -1)
+                       if size(k, cut) <= self.overlap_tokens and lines[k - 1].strip()]
+            if overlap:
+                first = min(overlap, key=lambda k: (scores[k - 2], k))
+        parts.append((first, end))
+        return parts
+
+    def chunks(self, content: str, symbols: List[Dict[str, Any]], declarations: List[Dict[str, Any]],
+               file: str, language: str, package: str, file_hash: str) -> Iterator[Dict[str, Any]]:
+        """
+        The chunks of one file, in line order; symbols as the file was
+        parsed, declarations with their IDs as XRayIndexer._declarations
+        lists them.
+        """
+        lines = source_lines(content)
+        located = sorted((d for d in declarations if d["kind"] not in _FILE_KINDS), key=lambda d: d["line"])
+        scores: Optional[List[int]] = None
+
+        def chunk(start: int, end: int, part: Optional[int] = None, parts: Optional[int] = None,
+                  owner: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+            text = self._text(lines, start, end)
+            inside = [d for d in located if start <= d["line"] <= end and d is not owner]
+            if owner is not None:
+                inside.insert(0, owner)
+            key = f"{file}\0{start}\0{end}\0{text}"
+            return {
+                "chunk_id": hashlib.sha1(key.encode("utf-8")).hexdigest()[:16],
+                "file": file,
+                "language": language,
+                "package": package,
+                "start_line": start,
+                "end_line": end,
+                "symbol_ids": [d["id"] for d in inside],
+                "symbols": [d["qualified"] for d in inside],
+                "part": part,
+                "parts": parts,
+                "tokens": self.estimate(text),
+                "file_hash": file_hash,
+                "text": text,
+            }
+
+        group: Optional[Tuple[int, int]] = None
+        for start, end in self.units(lines, symbols, language):
+            tokens = self.estimate(self._text(lines, start, end))
+            if group is not None and (tokens > self.target_tokens
+                                      or self.estimate(self._text(lines, group[0], end)) > self.target_tokens):
+                yield chunk(*group)
+                group = None
+            if tokens <= self.target_tokens:
+                group = (group[0] if group else start, end)
+            elif tokens <= self.max_tokens:
+                yield chunk(start, end)
+            else:
+                if scores is None:
+                    scores = boundary_scores(lines, language)
+                pieces = self._split(lines, start, end, scores)
+                owner = next((d for d in located if start <= d["line"] <= end), None)
+                for k, (first, last) in enumerate(pieces, 1):
+                    yield chunk(first, last, k, len(pieces), owner)
+        if group is not None:
+            yield chunk(*group)
@@ -0,0 +1,294 @@
+"""Run XRAY tools from a shell or a CI job, without an MCP client.
+
+    git-project-xray-mcp <tool> [--project DIR] [--arg KEY=VALUE ...]
+                         [--format json|markdown|sarif] [--include GLOB ...]
+                         [--exclude GLOB ...] [--quiet] [--describe]
+    git-project-xray-mcp tools
+    git-project-xray-mcp schema [TOOL ...] [--check | --write]
+
+The tool is any tool the server registers, called with the arguments it
+takes over MCP: --project is its root_path (default: the current
+directory) and each --arg one more argument. A value is read as JSON when
+it parses as JSON (limit=50, include_tests=true, params=["string"]) and
+taken as a string otherwise (query=GetUser) - or when the parameter takes
+a string and not the JSON's type, so find_literals' value=100 is the
+string "100". --include and --exclude fill the tool's include and exclude
+globs. Listing tools are paged through to the end, so the whole result
+is printed at once. `tools` lists the tools, --describe prints one's
+documentation. `schema` prints the JSON Schemas of the tools'
+input and output (see xray.core.schema); --write freezes their shapes in
+the golden file and --check compares the tools against it, failing when a
+shape changed without a schemaVersion bump - the CI gate for the result
+format.
+
+The result goes to stdout: JSON by default, SARIF from the tools that
+write it (format="sarif"), or Markdown - tables of the result's lists -
+for reading. Progress goes to stderr unless --quiet.
+
+    0  done - and for an audit tool (AUDIT_TOOLS), nothing found
+    1  an audit tool found something, or schema --check a problem: a CI
+       job can gate on it
+    2  the call failed, or the command line was wrong
+"""
+
+import argparse
+import json
+import sys
+import typing
+from typing import Any, Awaitable, Callable, Dict, Iterable, List, Optional, TextIO, Tuple
+
+EXIT_OK = 0
+EXIT_FINDINGS = 1
+EXIT_ERROR = 2
+
+FORMATS = ("json", "markdown", "sarif")
+
+# Audit tools and the result field counting what they found (a number or a list)
+AUDIT_TOOLS = {
+    "audit_context": "missing_context",
+    "audit_errors": "total_count",
+    "audit_resources": "total_count",
+    "check_mocks": "total_count",
+    "deprecated_usage": "total_count",
+    "diagnostics": "issues",
+    "find_cycles": "total_count",
+    "find_stale_docs": "total_count",
+    "find_unused": "total_count",
+    "format_strings": "mismatch_count",
+    "run_rules": "total_count",
+    "scan_secrets": "total_count",
+    "stale_queries": "total_count",
+    "three_way_impact": "total_count",
+}
+
+# Parameters the command line sets itself, or that a single call has no use for
+_RESERVED = {"ctx", "root_path", "cursor", "include", "exclude"}
+
+
+def parse_command(argv: List[str], parents: Iterable[argparse.ArgumentParser] = ()) -> argparse.Namespace:
+    """
+    Read a tool command line (the tool name first), with the options of
+    parents too; exits with EXIT_ERROR on a bad one.
+    """
+    parser = argparse.ArgumentParser(
+        prog="git-project-xray-mcp", description="Run one XRAY tool and print its result.", parents=list(parents))
+    parser.add_argument("tool", help="the tool to run, or 'tools' to list them")
+    parser.add_argument("--project", metavar="DIR", default=".",
+                        help="the project to analyze, passed as root_path (default: the current directory)")
+    parser.add_argument("--arg", metavar="KEY=VALUE", action="append", default=[], dest="args",
+                        help="an argument of the tool; VALUE is JSON if it parses as JSON, else a string")
+    parser.add_argument("--format", choices=FORMATS, default="json",
+                        help="json (default), markdown, or sarif for the tools writing SARIF")
+    parser.add_argument("--include", metavar="GLOB", action="append", help="only paths matching GLOB")
+    parser.add_argument("--exclude", metavar="GLOB", action="append", help="leave out paths matching GLOB")
+    parser.add_argument("--quiet", action="store_true", help="no progress on stderr")
+    parser.add_argument("--describe", action="store_true", help="print the tool's documentation and exit")
+    try:
+        return parser.parse_args(argv)
+    except SystemExit as e:
+        # argparse exits 2 on errors too; keep 0 for --help
+        raise SystemExit(EXIT_OK if e.code == 0 else EXIT_ERROR)
+
+
+def parse_schema_command(argv: List[str]) -> argparse.Namespace:
+    """Read a `schema` command line (without the word schema); exits with EXIT_ERROR on a bad one."""
+    parser = argparse.ArgumentParser(
+        prog="git-project-xray-mcp schema", description="Print, check or freeze the JSON Schemas of the tools.")
+    parser.add_argument("tools", metavar="TOOL", nargs="*", help="the tools to print (default: all)")
+    mode = parser.add_mutually_exclusive_group()
+    mode.add_argument("--check", action="store_true",
+                      help="compare every tool with the golden file; exit 1 on a change the version does not allow")
+    mode.add_argument("--write", action="store_true", help="freeze every tool's shape in the golden file")
+    try:
+        command = parser.parse_args(argv)
+    except SystemExit as e:
+        raise SystemExit(EXIT_OK if e.code == 0 else EXIT_ERROR)
+    if command.tools and (command.check or command.write):
+        parser.print_usage(sys.stderr)
+        print("git-project-xray-mcp schema: --check and --write cover every tool, name none", file=sys.stderr)
+        raise SystemExit(EXIT_ERROR)
+    return command
+
+
+def _accepted(annotation: Any) -> Optional[Tuple[type, ...]]:
+    """The JSON-decoded types a parameter annotation takes, None for any."""
+    if annotation is Any or annotation is None:
+        return None
+    origin = typing.get_origin(annotation)
+    if origin is typing.Union:
+        types: Tuple[type, ...] = ()
+        for arg in typing.get_args(annotation):
+            accepted = _accepted(arg)
+            if accepted is None:
+                return None
+            types += accepted
+        return types
+    if origin is not None:
+        return (origin,)
+    if annotation is float:
+        return (float, int)
+    return (annotation,) if isinstance(annotation, type) else None
+
+
+def parse_arg(text: str, annotation: Any = None) -> Tuple[str, Any]:
+    """
+    One --arg KEY=VALUE: the key and the value, decoded from JSON when it is
+    JSON - and of a type the parameter's annotation takes, if it takes a
+    string: otherwise the string as given.
+    """
+    key, sep, value = text.partition("=")
+    key = key.strip()
+    if not sep or not key:
+        raise ValueError(f"--arg {text}: expected KEY=VALUE")
+    try:
+        decoded = json.loads(value)
+    except ValueError:
+        return key, value
+    accepted = _accepted(annotation)
+    if accepted is not None and str in accepted and not isinstance(decoded, accepted):
+        return key, value
+    return key, decoded
+
+
+def tool_arguments(tool: str, parameters: List[str], command: argparse.Namespace,
+                   root_path: str, annotations: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+    """The keyword arguments of a tool call, checked against the tool's parameters and read as their annotations."""
+    arguments: Dict[str, Any] = {}
+    annotations = annotations or {}
+    for text in command.args:
+        key, _, _ = text.partition("=")
+        key, value = parse_arg(text, annotations.get(key.strip()))
+        if key not in parameters or key in _RESERVED:
+            usable = ", ".join(p for p in parameters if p not in _RESERVED)
+            hint = "; use --project" if key == "root_path" else (
+                f"; use --{key}" if key in ("include", "exclude") else "")
+            raise ValueError(f"{tool} takes no argument '{key}'{hint} (arguments: {usable or 'none'})")
+        arguments[key] = value
+    if "root_path" in parameters and "project" not in arguments:
+        arguments["root_path"] = root_path
+    for option in ("include", "exclude"):
+        globs = getattr(command, option)
+        if globs:
+            if option not in parameters:
+                raise ValueError(f"{tool} takes no --{option} globs")
+            arguments[option] = globs
+    if command.format == "sarif":
+        if "format" not in parameters:
+            raise ValueError(f"{tool} has no SARIF output")
+        arguments["format"] = "sarif"
+    return arguments
+
+
+def is_sarif(result: Any) -> bool:
+    return isinstance(result, dict) and "$schema" in result and "runs" in result
+
+
+def findings(tool: str, result: Any) -> Optional[int]:
+    """How much an audit tool found, or None for other tools."""
+    if tool not in AUDIT_TOOLS:
+        return None
+    if is_sarif(result):
+        return sum(len(run.get("results") or []) for run in result["runs"])
+    value = result.get(AUDIT_TOOLS[tool]) if isinstance(result, dict) else None
+    if isinstance(value, list):
+        return len(value)
+    return value if isinstance(value, int) else 0
+
+
+def listing_field(page: Dict[str, Any]) -> Optional[str]:
+    """The list a page with a next_cursor holds part of: the one shorter than total_count."""
+    for key, value in page.items():
+        if isinstance(value, list) and len(value) < page.get("total_count", 0):
+            return key
+    return None
+
+
+async def all_pages(call: Callable[..., Awaitable[Any]], arguments: Dict[str, Any]) -> Any:
+    """Call a tool, following next_cursor to the last page and joining the pages' listings."""
+    result = await call(**arguments)
+    while isinstance(result, dict) and result.get("next_cursor"):
+        field = listing_field(result)
+        if field is None:
+            break
+        page = await call(**arguments, cursor=result["next_cursor"])
+        if not isinstance(page, dict) or "error" in page:
+            return page
+        result = {**page, field: result[field] + page.get(field, [])}
+    return result
+
+
+class ProgressPrinter:
+    """Stands in for the MCP context of a call: progress notifications become lines on stderr."""
+
+    session = None
+
+    def __init__(self, stream: TextIO = sys.stderr):
+        self.stream = stream
+
+    async def report_progress(self, progress: float, total: Optional[float] = None,
+                              message: Optional[str] = None):
+        print(f"xray: {message or progress}", file=self.stream, flush=True)
+
+
+def _cell(value: Any) -> str:
+    """A value inside a Markdown table cell."""
+    if isinstance(value, (dict, list)):
+        value = json.dumps(value, ensure_ascii=False, separators=(",", ":"))
+    elif value is None:
+        value = ""
+    return str(value).replace("|", "\\|").replace("\n", " ")
+
+
+def _scalar(value: Any) -> bool:
+    return not isinstance(value, (dict, list))
+
+
+def _markdown(lines: List[str], value: Dict[str, Any], level: int):
+    for key, item in value.items():
+        if _scalar(item):
+            lines.append(f"- **{key}**: {_cell(item)}")
+    if any(_scalar(item) for item in value.values()):
+        lines.append("")
+    for key, item in value.items():
+        if _scalar(item):
+            continue
+        lines.extend([f"{'#' * level} {key}", ""])
+        if isinstance(item, dict):
+            if level >= 4:
+                lines.extend(["```json", json.dumps(item, indent=2, ensure_ascii=False), "```", ""])
+            else:
+                _markdown(lines, item, level + 1)
+        elif not item:
+            lines.extend(["_none_", ""])
+        elif all(isinstance(entry, dict) for entry in item):
+            columns: List[str] = []
+            for entry in item:
+                columns.extend(column for column in entry if column not in columns)
+            lines.append("| " + " | ".join(columns) + " |")
+            lines.append("|" + " --- |" * len(columns))
+            lines.extend("| " + " | ".join(_cell(entry.get(column)) for column in columns) + " |"
+                         for entry in item)
+            lines.append("")
+        else:
+            lines.extend(f"- {_cell(entry)}" for entry in item)
+            lines.append("")
+
+
+def render_markdown(tool: str, result: Any) -> str:
+    """A result as Markdown: its values as a list, each of its lists as a table."""
+    if isinstance(result, str):
+        return result
+    if isinstance(result, dict) and isinstance(result.get("markdown"), str):
+        return result["markdown"]
+    lines = [f"# {tool}", ""]
+    _markdown(lines, result if isinstance(result, dict) else {"result": result}, 2)
+    return "\n".join(lines).rstrip() + "\n"
+
+
+def render(tool: str, result: Any, output_format: str) -> str:
+    """The text printed for a result."""
+    if output_format == "markdown":
+        return render_markdown(tool, result)
+    if isinstance(result, str):
+        return result if result.endswith("\n") else result + "\n"
+    return json.dumps(result, indent=2, ensure_ascii=False) + "\n"
@@ -0,0 +1,578 @@
+"""Links between the languages of a project, where Go embeds or calls out to the rest.
+
+Imports stop at a language's edge; these links go past it:
+
+    embed   a `//go:embed` directive and each file its patterns match, with
+            the symbols the index found in that file. The compiler resolves
+            these, so they are certain - unless a pattern matches nothing on
+            disk yet (a frontend build's output), listed in "unmatched_embeds".
+    exec    an exec.Command / exec.CommandContext call whose program - or,
+            run through bash/sh, whose script - is one of the project's
+            shell scripts. The program is a constant or a filepath.Join of
+            constants; matched by path from the project root, from the
+            calling file's directory, by path ending or by file name alone,
+            each less certain than the one before.
+    http    an HTTP call of the TypeScript/JavaScript code - fetch, axios (or
+            any client's) get/post/put/patch/delete, a config object's url
+            and method - whose path matches a route the Go code registers.
+            Path parameters match any segment (`${id}` against `{id}` or
+            `:id`; a literal against a parameter too), wildcards the rest of
+            the path, and a path built on a base URL (`${API}/users`) may
+            match the end of a route. Each call keeps its best matches only.
+
+Every link carries its evidence (the directive, literal or call text it
+was made from and how it matched) and a confidence between 0 and 1: exact
+compiler semantics score 1, string heuristics less, so false positives can
+be told apart and filtered with min_confidence.
+"""
+
+import glob
+import os
+import re
+from typing import Any, Callable, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+from xray.core.ts_parser import allows_jsx, source_language, tokenize, unquote
+
+LINK_KINDS = ("embed", "exec", "http")
+
+# Files listed for one embed pattern at most
+MAX_EMBEDDED_FILES = 100
+
+# Qualified name -> index of the program argument
+EXEC_FUNCS = {"os/exec.Command": 0, "os/exec.CommandContext": 1}
+# Programs running the script they are given
+_SHELLS = {"bash", "sh", "zsh", "dash", "ksh"}
+
+# HTTP client methods named after their verb
+_VERBS = {"get": "GET", "post": "POST", "put": "PUT", "patch": "PATCH", "delete": "DELETE",
+          "head": "HEAD", "options": "OPTIONS"}
+# Receivers that are HTTP clients for certain; others of a verb method are probably one
+_CLIENTS = {"axios", "http", "ky", "superagent", "request", "client", "api", "apiClient", "httpClient", "$http"}
+# Calls taking a config object ({url, method})
+_CONFIG_CALLS = {"axios", "request", "ajax"}
+
+_SCHEME_HOST = re.compile(r"^[a-z][a-z0-9+.-]*://[^/]*", re.IGNORECASE)
+_PARAM = "{}"
+_JOINS = {"filepath.Join", "path.Join"}
+
+
+def _literals(text: str) -> List[str]:
+    return [m.group(1) or m.group(2) for m in re.finditer(r'"((?:[^"\\]|\\.)*)"|`([^`]*)`', text)]
+
+
+def _template_text(literal: str) -> str:
+    """A template literal's text with each `${...}` replaced by a parameter marker."""
+    body = unquote(literal)
+    out, i, n = [], 0, len(body)
+    while i < n:
+        if body.startswith("${", i):
+            depth, i = 1, i + 2
+            while i < n and depth:
+                depth += {"{": 1, "}": -1}.get(body[i], 0)
+                i += 1
+            out.append(_PARAM)
+        elif body[i] == "\\" and i + 1 < n:
+            out.append(body[i + 1])
+            i += 2
+        else:
+            out.append(body[i])
+            i += 1
+    return "".join(out)
+
+
+def client_path(url: str) -> Tuple[List[str], bool]:
+    """
+    The path segments of a URL an HTTP call requests ("{}" for a dynamic
+    part), and whether something unknown goes before them (a base URL).
+    """
+    url = _SCHEME_HOST.sub("", url.strip())
+    prefixed = False
+    if url.startswith(_PARAM):
+        # `${API}/users` or API + "/users"
+        prefixed = True
+        url = url[url.find("/"):] if "/" in url else ""
+    url = re.split(r"[?#]", url, 1)[0]
+    if not url.startswith("/"):
+        prefixed = True
+    segments = [_PARAM if _PARAM in s else s for s in url.split("/") if s]
+    return segments, prefixed
+
+
+def route_path(route: str, framework: Optional[str]) -> List[Tuple[str, str]]:
+    """The segments of a Go route pattern as (kind, text): literal, param or wildcard."""
+    if not route.startswith("/") and "/" in route:
+        # net/http patterns may start with a host
+        route = route[route.find("/"):]
+    segments: List[Tuple[str, str]] = []
+    for part in route.split("/"):
+        if not part:
+            continue
+        if part.startswith("*") or part.endswith("...}"):
+            segments.append(("wildcard", part))
+        elif part.startswith(":") or (part.startswith("{") and part.endswith("}")):
+            segments.append(("param", part))
+        else:
+            segments.append(("literal", part))
+    if route.endswith("/") and framework in (None, "net/http") and (not segments or segments[-1][0] != "wildcard"):
+        # A ServeMux pattern ending in a slash matches the subtree below it
+        segments.append(("subtree", ""))
+    return segments
+
+
+def match_segments(client: List[str], route: List[Tuple[str, str]]) -> Optional[Tuple[float, str]]:
+    """
+    How well request segments fit route segments: (score, how) or None. At
+    least one literal segment must match, so `/{id}` alone matches nothing.
+    """
+    score, how, literals = 1.0, "exact", 0
+    for i, (kind, text) in enumerate(route):
+        if kind in ("wildcard", "subtree"):
+            if kind == "subtree" and i == len(client):
+                break
+            return (score * 0.8, "wildcard") if literals else None
+        if i >= len(client):
+            return None
+        segment = client[i]
+        if kind == "param":
+            how = "parameter"
+        elif segment == _PARAM:
+            score *= 0.7
+            how = "parameter"
+        elif segment != text:
+            return None
+        else:
+            literals += 1
+    if len(client) > len(route):
+        return None
+    if not literals and client:
+        return None
+    return score, how
+
+
+class CrossLanguageLinker:
+    """The embed, exec and http links of a project (see the module docstring)."""
+
+    def __init__(self, graph: GoCallGraph, routes: List[Dict[str, Any]], scripts: List[str],
+                 web_files: List[str], read: Callable[[str], Optional[str]],
+                 describe: Callable[[str], Tuple[Optional[str], List[Dict[str, Any]]]]):
+        """
+        Args:
+            graph: The Go call graph
+            routes: The Go routes (RouteExtractor.extract)
+            scripts: Paths of the project's shell scripts
+            web_files: Paths of the TypeScript/JavaScript files
+            read: File contents, None when unreadable
+            describe: A file's language (None if not indexed) and its symbols
+        """
+        self.graph = graph
+        self.project = graph.project
+        self.routes = routes
+        self.scripts = scripts
+        self.web_files = web_files
+        self.read = read
+        self.describe = describe
+
+    def _target(self, path: str) -> Dict[str, Any]:
+        language, symbols = self.describe(path)
+        entry: Dict[str, Any] = {"path": path, "language": language}
+        names = [s["name"] for s in symbols if not s.get("container")]
+        if names:
+            entry["symbols"] = names
+        return entry
+
+    # ------------------------------------------------------------------
+    # embed
+    # ------------------------------------------------------------------
+
+    @staticmethod
+    def _embed_patterns(directive: str) -> List[str]:
+        words = re.findall(r'"((?:[^"\\]|\\.)*)"|`([^`]*)`|(\S+)', directive[len("go:embed"):])
+        return [next(w for w in word if w) for word in words if any(word)]
+
+    @staticmethod
+    def _embedded_files(pkg_dir: str, pattern: str) -> List[str]:
+        every = pattern.startswith("all:")
+        pattern = pattern[4:] if every else pattern
+        files: List[str] = []
+        for match in sorted(glob.glob(os.path.join(pkg_dir, pattern))):
+            if os.path.isfile(match):
+                files.append(match)
+                continue
+            for directory, dirs, names in os.walk(match):
+                # Go leaves out . and _ files of an embedded directory without all:
+                dirs[:] = sorted(d for d in dirs if every or not d.startswith((".", "_")))
+                files.extend(os.path.join(directory, name) for name in sorted(names)
+                             if every or not name.startswith((".", "_")))
+        return files
+
+    def embed_links(self, keep: Callable[[str], bool]) -> Tuple[List[Dict[str, Any]], List[Dict[str, Any]]]:
+        links, unmatched = [], []
+        for path, parsed in sorted(self.project.files.items()):
+            if not keep(path):
+                continue
+            for symbol in parsed["symbols"]:
+                for directive in symbol.get("directives", []):
+                    if not directive.startswith("go:embed"):
+                        continue
+                    source = {"language": "go", "path": path, "line": symbol["start_line"], "symbol": symbol["name"]}
+                    for pattern in self._embed_patterns(directive):
+                        files = self._embedded_files(os.path.dirname(path), pattern)
+                        if not files:
+                            unmatched.append({**source, "pattern": pattern})
+                            continue
+                        evidence: Dict[str, Any] = {"directive": "//" + directive, "pattern": pattern}
+                        if len(files) > MAX_EMBEDDED_FILES:
+                            evidence["truncated"] = len(files)
+                        for file_path in files[:MAX_EMBEDDED_FILES]:
+                            links.append({"kind": "embed", "confidence": 1.0, "from": source,
+                                          "to": self._target(file_path), "evidence": evidence})
+        return links, unmatched
+
+    # ------------------------------------------------------------------
+    # exec
+    # ------------------------------------------------------------------
+
+    def _string(self, path: str, func: Optional[Dict[str, Any]], source: Optional[Dict[str, Any]],
+                text: str) -> Optional[str]:
+        """The constant an argument holds - through locals and package constants - or a Join of constants."""
+        for _ in range(4):
+            if not source:
+                break
+            if isinstance(source.get("value"), str):
+                return source["value"]
+            chain = source.get("chain")
+            if not chain:
+                break
+            if len(chain) == 1 and func and chain[0] in func.get("locals", {}):
+                source = func["locals"][chain[0]]
+                continue
+            value = self.graph.constant_value(path, chain)
+            if isinstance(value, str):
+                return value
+            break
+        head = text.split("(", 1)[0].strip()
+        if head in _JOINS and text.endswith(")"):
+            parts = _literals(text)
+            inner = text[len(head) + 1:-1]
+            if parts and len(parts) == len([p for p in inner.split(",") if p.strip()]):
+                return "/".join(parts)
+        return None
+
+    def _program(self, path: str, func: Optional[Dict[str, Any]], call: Dict[str, Any],
+                 index: int) -> Optional[Tuple[str, List[str]]]:
+        """The script a command runs, and the constant words of the command."""
+        args, texts = call.get("args", []), call.get("arg_texts", [])
+        words = [self._string(path, func, args[i] if i < len(args) else None, texts[i])
+                 for i in range(index, len(texts))]
+        if not words or words[0] is None:
+            return None
+        shown = [w if w is not None else texts[index + i] for i, w in enumerate(words)]
+        if os.path.basename(words[0]) not in _SHELLS:
+            return words[0], shown
+        rest = words[1:]
+        while rest and rest[0] is not None and rest[0].startswith("-"):
+            if rest[0] == "-c":
+                script = rest[1].split() if len(rest) > 1 and rest[1] else []
+                return (script[0], shown) if script else None
+            rest = rest[1:]
+        return (rest[0], shown) if rest and rest[0] else None
+
+    def _scripts_for(self, program: str, path: str) -> List[Tuple[str, float, str]]:
+        root = self.project.root or ""
+        name = program[2:] if program.startswith("./") else program
+        if os.path.isabs(name):
+            return [(name, 0.9, "absolute_path")] if name in self.scripts else []
+        for base, score, how in ((root, 0.9, "root_relative"), (os.path.dirname(path), 0.8, "file_relative")):
+            candidate = os.path.normpath(os.path.join(base, name))
+            if candidate in self.scripts:
+                return [(candidate, score, how)]
+        if "/" in name:
+            ends = [s for s in self.scripts if s.endswith(os.sep + name.replace("/", os.sep))]
+            if ends:
+                return [(s, 0.7 if len(ends) == 1 else 0.5, "path_suffix") for s in ends]
+        same = [s for s in self.scripts if os.path.basename(s) == os.path.basename(name)]
+        if same:
+            return [(s, 0.6 if len(same) == 1 else 0.4, "file_name") for s in same]
+        if "." not in os.path.basename(name):
+            stems = [s for s in self.scripts if os.path.splitext(os.path.basename(s))[0] == os.path.basename(name)]
+            return [(s, 0.3, "name_without_extension") for s in stems]
+        return []
+
+    def exec_links(self, keep: Callable[[str], bool]) -> List[Dict[str, Any]]:
+        if not self.scripts:
+            return []
+        sites = {(e["path"], e["line"], e["column"]): e["callee"] for e in self.graph.edges
+                 if e["external"] and e["kind"] != "reference"}
+        links = []
+        for path, parsed in sorted(self.project.files.items()):
+            if not keep(path):
+                continue
+            scopes = [(func, func.get("calls", [])) for func in parsed.get("functions", [])]
+            scopes.append((None, parsed.get("package_calls", [])))
+            for func, calls in scopes:
+                for call in calls:
+                    callee = sites.get((path, call["line"], call["column"]))
+                    if callee is None or callee[1] not in EXEC_FUNCS:
+                        continue
+                    found = self._program(path, func, call, EXEC_FUNCS[callee[1]])
+                    if found is None:
+                        continue
+                    program, words = found
+                    source = {"language": "go", "path": path, "line": call["line"], "column": call["column"],
+                              "function": (f"{func['receiver']}.{func['name']}" if func.get("receiver")
+                                           else func["name"]) if func else None}
+                    for script, score, how in self._scripts_for(program, path):
+                        links.append({"kind": "exec", "confidence": score, "from": source,
+                                      "to": self._target(script),
+                                      "evidence": {"call": callee[1].rsplit("/", 1)[-1], "command": words,
+                                                   "program": program, "matched": how}})
+        return links
+
+    # ------------------------------------------------------------------
+    # http
+    # ------------------------------------------------------------------
+
+    @staticmethod
+    def _close(tokens, i: int) -> int:
+        """The index of the token closing the bracket at i."""
+        depth = 0
+        for j in range(i, len(tokens)):
+            if tokens[j].kind == "punct" and tokens[j].value in "([{":
+                depth += 1
+            elif tokens[j].kind == "punct" and tokens[j].value in ")]}":
+                depth -= 1
+                if depth == 0:
+                    return j
+        return len(tokens) - 1
+
+    @staticmethod
+    def _url(tokens, i: int, end: int) -> Optional[Tuple[str, str]]:
+        """The URL an argument starting at i builds ("{}" for unknown parts), and its source text."""
+        parts, texts = [], []
+        expect_value = True
+        while i < end:
+            tok = tokens[i]
+            if tok.kind == "punct" and tok.value in (",", ")", "}"):
+                break
+            if expect_value:
+                if tok.kind == "string":
+                    parts.append(unquote(tok.value))
+                elif tok.kind == "template":
+                    parts.append(_template_text(tok.value))
+                elif tok.kind == "ident":
+                    # A base URL or parameter: the chain up to the next +
+                    while i + 2 < end and tokens[i + 1].value in (".", "?.") and tokens[i + 2].kind == "ident":
+                        i += 2
+                    parts.append(_PARAM)
+                else:
+                    return None
+                expect_value = False
+            elif tok.kind == "punct" and tok.value == "+":
+                expect_value = True
+            else:
+                return None
+            texts.append(tok.value)
+            i += 1
+        if not parts or all(p == _PARAM for p in parts):
+            return None
+        return "".join(parts), " ".join(texts)
+
+    def _config(self, tokens, i: int, end: int) -> Tuple[Optional[Tuple[str, str]], Optional[str], bool]:
+        """The url and method of an object literal's properties between i and end, and whether it has a method key."""
+        url, method, has_method = None, None, False
+        depth = 0
+        for j in range(i, end):
+            tok = tokens[j]
+            if tok.kind == "punct" and tok.value in "([{":
+                depth += 1
+            elif tok.kind == "punct" and tok.value in ")]}":
+                depth -= 1
+            if depth != 1 or tok.kind != "ident" or j + 2 >= end or tokens[j + 1].value != ":":
+                continue
+            if tok.value == "url":
+                url = self._url(tokens, j + 2, end)
+            elif tok.value in ("method", "type"):
+                has_method = True
+                if tokens[j + 2].kind == "string":
+                    method = unquote(tokens[j + 2].value).upper()
+        return url, method, has_method
+
+    def _requests(self, path: str, text: str) -> List[Dict[str, Any]]:
+        """The HTTP calls of one TypeScript/JavaScript file: url, method (None if unknown) and call text."""
+        tokens, _ = tokenize(text, allows_jsx(path))
+        found = []
+        for i, tok in enumerate(tokens):
+            if tok.kind != "ident" or i + 1 >= len(tokens):
+                continue
+            prev = tokens[i - 1] if i else None
+            member = prev is not None and prev.kind == "punct" and prev.value in (".", "?.")
+            receiver = tokens[i - 2].value if member and i >= 2 and tokens[i - 2].kind == "ident" else None
+            open_at = i + 1
+            if tokens[open_at].value == "<":
+                # Type arguments: api.get<User>(url)
+                depth = 0
+                while open_at < len(tokens):
+                    depth += {"<": 1, ">": -1}.get(tokens[open_at].value, 0)
+                    open_at += 1
+                    if depth == 0:
+                        break
+            if open_at >= len(tokens) or tokens[open_at].value != "(":
+                continue
+            close = self._close(tokens, open_at)
+            call, url, method, known = None, None, None, True
+            if tok.value == "fetch" and (not member or receiver in ("window", "globalThis", "self")):
+                call = "fetch"
+                url = self._url(tokens, open_at + 1, close)
+                options = next((j for j in range(open_at + 1, close)
+                                if tokens[j].value == "," and tokens[j + 1].value == "{"), None)
+                method = "GET"
+                if options is not None:
+                    _, given, has_method = self._config(tokens, options + 1, close)
+                    if has_method:
+                        method, known = given, given is not None
+            elif member and tok.value in _VERBS:
+                call = f"{receiver}.{tok.value}" if receiver else tok.value
+                url = self._url(tokens, open_at + 1, close)
+                method = _VERBS[tok.value]
+                known = receiver in _CLIENTS
+            elif (tok.value in _CONFIG_CALLS and (not member or receiver in ("axios", "$", "jQuery"))) \
+                    and open_at + 1 < close and tokens[open_at + 1].value == "{":
+                call = f"{receiver}.{tok.value}" if receiver else tok.value
+                url, method, _ = self._config(tokens, open_at + 1, close)
+                method = method or "GET"
+            if call is None or url is None:
+                continue
+            found.append({"call": call, "url": url[0], "text": url[1][:200], "method": method,
+                          "client": known, "line": tok.line, "column": tok.col})
+        return found
+
+    @staticmethod
+    def _enclosing(symbols: List[Dict[str, Any]], line: int) -> Optional[str]:
+        best = None
+        for symbol in symbols:
+            if symbol["start_line"] <= line <= symbol.get("end_line", symbol["start_line"]):
+                if best is None or symbol["start_line"] >= best["start_line"]:
+                    best = symbol
+        if best is None:
+            return None
+        return f"{best['container']}.{best['name']}" if best.get("container") else best["name"]
+
+    def _route_matches(self, request: Dict[str, Any]) -> List[Tuple[float, Dict[str, Any], Dict[str, Any]]]:
+        segments, prefixed = client_path(request["url"])
+        matches = []
+        for route in self.routes:
+            if route.get("dynamic") or not isinstance(route.get("route"), str):
+                continue
+            methods = route.get("method")
+            methods = [methods] if isinstance(methods, str) else (methods or [])
+            score = 0.95
+            if request["method"] is None:
+                score *= 0.8
+                method_match = "unknown"
+            elif not methods:
+                score *= 0.9
+                method_match = "any_method"
+            elif request["method"] in methods:
+                method_match = "exact"
+            else:
+                continue
+            route_segments = route_path(route["route"], route.get("framework"))
+            fit = match_segments(segments, route_segments)
+            how = None
+            if fit is not None:
+                how = fit[1]
+                score *= fit[0]
+            else:
+                literal_route = [s for s in route_segments if s[0] != "subtree"]
+                # A base URL before the request's path, or a router mounted under a prefix
+                tail = len(literal_route) - len(segments)
+                if segments and tail > 0:
+                    fit = match_segments(segments, literal_route[tail:])
+                    if fit is not None:
+                        how = "suffix"
+                        score *= fit[0] * (0.8 if prefixed else 0.5)
+                if how is None and len(segments) > len(literal_route) > 0:
+                    fit = match_segments(segments[len(segments) - len(literal_route):], literal_route)
+                    if fit is not None:
+                        how = "suffix"
+                        score *= fit[0] * 0.5
+            if how is None:
+                continue
+            if not request["client"]:
+                score *= 0.5
+            evidence = {"call": request["call"], "url": request["text"], "request_path": "/" + "/".join(segments),
+                        "method": request["method"], "route": route["route"], "matched": how,
+                        "method_match": method_match}
+            matches.append((round(score, 2), route, evidence))
+        if not matches:
+            return []
+        best = max(score for score, _, _ in matches)
+        return [m for m in matches if m[0] == best]
+
+    def http_links(self, keep: Callable[[str], bool]) -> List[Dict[str, Any]]:
+        if not self.routes:
+            return []
+        links = []
+        for path in sorted(self.web_files):
+            if not keep(path):
+                continue
+            text = self.read(path)
+            if not text:
+                continue
+            requests = self._requests(path, text)
+            if not requests:
+                continue
+            _, symbols = self.describe(path)
+            for request in requests:
+                for score, route, evidence in self._route_matches(request):
+                    handler = route.get("handler") or {}
+                    registered = route.get("registered_at") or {}
+                    target: Dict[str, Any] = {"language": "go", "path": handler.get("path") or registered.get("path"),
+                                              "line": handler.get("line") or registered.get("line")}
+                    if handler.get("name"):
+                        target["symbol"] = handler["name"]
+                    target.update({"route": route["route"], "method": route.get("method")})
+                    links.append({
+                        "kind": "http",
+                        "confidence": score,
+                        "from": {"language": source_language(path), "path": path, "line": request["line"],
+                                 "column": request["column"], "function": self._enclosing(symbols, request["line"])},
+                        "to": target,
+                        "evidence": evidence,
+                    })
+        return links
+
+    def links(self, kinds: Optional[List[str]] = None, keep: Optional[Callable[[str], bool]] = None,
+              min_confidence: float = 0.0) -> Dict[str, Any]:
+        """
+        Every link of the wanted kinds whose source file keep accepts, most
+        certain first within each source file.
+
+        Args:
+            kinds: Some of LINK_KINDS (default: all)
+            keep: Only links from files it accepts
+            min_confidence: Leave out links below this confidence
+        """
+        wanted = kinds or list(LINK_KINDS)
+        accept = keep or (lambda path: True)
+        links: List[Dict[str, Any]] = []
+        unmatched: List[Dict[str, Any]] = []
+        if "embed" in wanted:
+            found, unmatched = self.embed_links(accept)
+            links.extend(found)
+        if "exec" in wanted:
+            links.extend(self.exec_links(accept))
+        if "http" in wanted:
+            links.extend(self.http_links(accept))
+        links = [link for link in links if link["confidence"] >= min_confidence]
+        links.sort(key=lambda l: (l["from"]["path"], l["from"]["line"], l["from"].get("column") or 0,
+                                  -l["confidence"], l["kind"], l["to"]["path"] or ""))
+        result: Dict[str, Any] = {
+            "links": links,
+            "total_count": len(links),
+            "counts": {kind: sum(1 for l in links if l["kind"] == kind) for kind in wanted},
+        }
+        if unmatched:
+            result["unmatched_embeds"] = unmatched
+        return result
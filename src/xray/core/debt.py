@@ -0,0 +1,241 @@
+"""TODO, FIXME, HACK, XXX and NOTE markers in the comments of every language.
+
+find_markers runs with each parse (see parse_pool.parse_source), so the
+markers of a file are cached with its parse result. A small lexer per
+language tells comments from strings - Go raw strings and runes, Python
+prefixed and triple-quoted strings, JS/TS template literals (and the code
+inside their `${}`) and regular expression literals, Rust raw strings, char
+literals and nested block comments, Java text blocks, SQL `--` comments
+and dollar quotes, shell `#` only at the start of a word (not `$#` or
+`${#x}`), Makefile `#` unless escaped - so a marker spelled inside a string
+is not one. A
+marker counts in upper case only, as a word of its own:
+
+    // TODO(alice): retry on 503     {"marker": "TODO", "author": "alice", "text": "retry on 503"}
+    # FIXME(#412) flaky on CI        {"marker": "FIXME", "issue": "#412", "text": "flaky on CI"}
+
+The parenthesized part is an "author" unless it reads like an issue
+reference (`#412`, `JIRA-88`, a URL). Age, author from git blame and the
+enclosing symbol are added at query time (see XRayIndexer.list_debt).
+"""
+
+import re
+from typing import Any, Dict, Iterator, List, Tuple
+
+MARKERS = ("TODO", "FIXME", "HACK", "XXX", "NOTE")
+
+_MARKER = re.compile(r"(?<![A-Za-z0-9_])(" + "|".join(MARKERS) + r")(?![A-Za-z0-9_])(?:\(([^)\n]*)\))?:?[ \t]*(.*)")
+_ANY_MARKER = re.compile("|".join(MARKERS))
+_ISSUE = re.compile(r"^(#\d+|[A-Z][A-Z0-9]+-\d+|\w+://\S+|[\w.-]+/[\w.-]+#\d+)$")
+_PY_STRING = re.compile(r"(?i)(?:[rbuf]|rb|br|fr|rf)?('''|\"\"\"|'|\")")
+_RS_RAW = re.compile(r"b?r(#*)\"")
+_SQL_DOLLAR = re.compile(r"\$([A-Za-z_][A-Za-z0-9_]*)?\$")
+_RS_CHAR = re.compile(r"'(?:\\(?:u\{[0-9a-fA-F]{1,6}\}|x[0-9a-fA-F]{2}|.)|[^\\'\n])'")
+# After these, a '/' in JavaScript starts a regular expression rather than dividing
+_JS_REGEX_KEYWORDS = {"return", "typeof", "instanceof", "in", "of", "new", "delete", "void", "throw",
+                      "case", "do", "else", "yield", "await"}
+
+
+def _quoted(src: str, i: int, quote: str, multiline: bool = False) -> int:
+    """The offset past a string opened by quote at i (backslash escapes; ends at a newline unless multiline)."""
+    j = i + len(quote)
+    n = len(src)
+    while j < n:
+        if src[j] == "\\":
+            j += 2
+            continue
+        if src.startswith(quote, j):
+            return j + len(quote)
+        if src[j] == "\n" and not multiline:
+            return j
+        j += 1
+    return n
+
+
+def _block_end(src: str, i: int, nested: bool) -> int:
+    """The offset past a /* */ comment opened at i; Rust's nest."""
+    if not nested:
+        end = src.find("*/", i + 2)
+        return len(src) if end < 0 else end + 2
+    depth, j, n = 0, i, len(src)
+    while j < n:
+        if src.startswith("/*", j):
+            depth += 1
+            j += 2
+        elif src.startswith("*/", j):
+            depth -= 1
+            j += 2
+            if depth == 0:
+                return j
+        else:
+            j += 1
+    return n
+
+
+def _line_end(src: str, i: int) -> int:
+    end = src.find("\n", i)
+    return len(src) if end < 0 else end
+
+
+def _js_regex_allowed(src: str, i: int) -> bool:
+    """Whether a '/' at i starts a regular expression literal: judged by the code before it."""
+    j = i - 1
+    while j >= 0 and src[j] in " \t\r\n":
+        j -= 1
+    if j < 0:
+        return True
+    if src[j].isalnum() or src[j] in "_$":
+        start = j
+        while start > 0 and (src[start - 1].isalnum() or src[start - 1] in "_$"):
+            start -= 1
+        return src[start:j + 1] in _JS_REGEX_KEYWORDS
+    return src[j] not in ")]}"
+
+
+def _js_regex_end(src: str, i: int) -> int:
+    j, n, in_class = i + 1, len(src), False
+    while j < n and src[j] != "\n":
+        if src[j] == "\\":
+            j += 2
+            continue
+        if src[j] == "[":
+            in_class = True
+        elif src[j] == "]":
+            in_class = False
+        elif src[j] == "/" and not in_class:
+            return j + 1
+        j += 1
+    return j
+
+
+def _comments(src: str, language: str) -> Iterator[Tuple[int, str]]:
+    """(offset, text) of every comment of a source file, delimiters included."""
+    hash_comments = language in ("python", "make", "shell")
+    slash_comments = language not in ("python", "sql", "make", "shell")
+    n = len(src)
+    i = 0
+    # JS/TS: brace depths at which a template literal's `${` resumes
+    templates: List[int] = []
+    depth = 0
+    while i < n:
+        c = src[i]
+        if hash_comments and c == "#" and not (language == "shell" and i and src[i - 1] not in " \t\n;&|()") \
+                and not (language == "make" and i and src[i - 1] == "\\"):
+            end = _line_end(src, i)
+            yield i, src[i:end]
+            i = end
+        elif slash_comments and src.startswith("//", i):
+            end = _line_end(src, i)
+            yield i, src[i:end]
+            i = end
+        elif language == "sql" and src.startswith("--", i):
+            end = _line_end(src, i)
+            yield i, src[i:end]
+            i = end
+        elif (slash_comments or language == "sql") and src.startswith("/*", i):
+            end = _block_end(src, i, language == "rust")
+            yield i, src[i:end]
+            i = end
+        elif language == "python" and (c in "'\"" or c in "rRbBuUfF") and not (i and (src[i - 1].isalnum() or src[i - 1] == "_")):
+            match = _PY_STRING.match(src, i)
+            if not match:
+                i += 1
+                continue
+            quote = match.group(1)
+            i = _quoted(src, match.end() - len(quote), quote, len(quote) == 3)
+        elif language == "go" and c == "`":
+            end = src.find("`", i + 1)
+            i = n if end < 0 else end + 1
+        elif language == "rust" and c in "rb" and not (i and (src[i - 1].isalnum() or src[i - 1] == "_")) \
+                and _RS_RAW.match(src, i):
+            match = _RS_RAW.match(src, i)
+            closing = '"' + match.group(1)
+            end = src.find(closing, match.end())
+            i = n if end < 0 else end + len(closing)
+        elif language == "rust" and c == "'":
+            # A char literal, or the quote of a lifetime ('a, 'static)
+            match = _RS_CHAR.match(src, i)
+            i = match.end() if match else i + 1
+        elif language == "sql" and c == "$" and not (i and (src[i - 1].isalnum() or src[i - 1] == "_")) \
+                and _SQL_DOLLAR.match(src, i):
+            closing = _SQL_DOLLAR.match(src, i).group(0)
+            end = src.find(closing, i + len(closing))
+            i = n if end < 0 else end + len(closing)
+        elif language == "java" and src.startswith('"""', i):
+            i = _quoted(src, i, '"""', True)
+        elif c in "\"'" and language != "make":
+            i = _quoted(src, i, c, language == "rust")
+        elif language in ("typescript", "javascript"):
+            if c == "`":
+                i, opened = _template(src, i + 1)
+                if opened:
+                    templates.append(depth)
+                    depth += 1
+            elif c == "{":
+                depth += 1
+                i += 1
+            elif c == "}":
+                depth -= 1
+                i += 1
+                if templates and templates[-1] == depth:
+                    # Back inside the template literal whose `${` this closes
+                    i, opened = _template(src, i)
+                    if opened:
+                        depth += 1
+                    else:
+                        templates.pop()
+            elif c == "/" and _js_regex_allowed(src, i):
+                i = _js_regex_end(src, i)
+            else:
+                i += 1
+        else:
+            i += 1
+
+
+def _template(src: str, i: int) -> Tuple[int, bool]:
+    """
+    Skip template literal text from i: to past its closing backtick, or
+    past a `${` (then True: code follows until the matching brace).
+    """
+    n = len(src)
+    while i < n:
+        if src[i] == "\\":
+            i += 2
+            continue
+        if src[i] == "`":
+            return i + 1, False
+        if src.startswith("${", i):
+            return i + 2, True
+        i += 1
+    return n, False
+
+
+def find_markers(content: str, language: str) -> List[Dict[str, Any]]:
+    """
+    The debt markers in the comments of a file's content, in file order:
+    {"marker", "text", "line", "column"} plus "author" or "issue" from a
+    `TODO(...)` form. One per comment line; language as in LANGUAGE_MAP.
+    """
+    if not _ANY_MARKER.search(content):
+        return []
+    markers = []
+    line, counted = 1, 0
+    for offset, comment in _comments(content, language):
+        line += content.count("\n", counted, offset)
+        counted = offset
+        line_start = content.rfind("\n", 0, offset) + 1
+        for number, text in enumerate(comment.split("\n")):
+            match = _MARKER.search(text)
+            if not match:
+                continue
+            marker = {
+                "marker": match.group(1),
+                "text": re.sub(r"\s*\*/$", "", match.group(3)).strip(),
+                "line": line + number,
+                "column": match.start() + 1 + (offset - line_start if number == 0 else 0),
+            }
+            note = (match.group(2) or "").strip()
+            if note:
+                marker["issue" if _ISSUE.match(note) else "author"] = note
+            markers.append(marker)
+    return markers
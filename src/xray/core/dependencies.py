@@ -0,0 +1,265 @@
+"""Third-party dependencies from module files: versions, licenses, and the unused ones.
+
+Three ecosystems, one list:
+
+    go      the require directives of every go.mod (direct, or marked
+            "// indirect"), their replace directives and go.sum checksums
+    npm     dependencies, devDependencies, peerDependencies and
+            optionalDependencies of every package.json
+    python  the requirements of every requirements*.txt
+
+A Go pseudo-version (v0.0.0-20191109021931-daa7c04131f5) is decoded into
+the commit it names, that commit's time and the tag it follows, if any.
+
+Licenses are told from the files a dependency ships as it is on disk: the
+module's vendor/ directory or the module cache (GOMODCACHE, else
+GOPATH/pkg/mod) for Go, node_modules for npm, the dist-info of a virtualenv
+(.venv, venv, env) for Python. LICENSE, LICENCE and COPYING files are
+matched against the wording of the common licenses (MIT, Apache-2.0,
+BSD-2/3-Clause, ISC, MPL-2.0, the GPL family, ...), an SPDX-License-Identifier
+line winning; npm package.json "license" and Python License-Expression,
+License and classifier metadata are read first. Nothing downloaded, nothing
+on disk: no license.
+
+A direct dependency no indexed file imports is "removable": a Go module
+none of its module's files import a package of (longest required module
+path winning), an npm runtime dependency no file under its package.json
+imports (devDependencies, peerDependencies and @types/ packages are tools
+and typings, never flagged), a Python requirement whose installed
+top-level modules no file imports (not installed: the import names are not
+known, and it is not flagged).
+"""
+
+import json
+import os
+import re
+from datetime import datetime, timezone
+from typing import Any, Dict, Iterable, List, Optional, Tuple
+
+ECOSYSTEMS = ("go", "npm", "python")
+
+LICENSE_FILES = re.compile(r"^(?:LICEN[CS]E|COPYING|UNLICENSE)(?:[-._][\w.-]*)?$", re.IGNORECASE)
+
+# license.go and the like: code, not a license
+_SOURCE_EXTENSIONS = {".go", ".py", ".js", ".ts", ".rs", ".java", ".c", ".h"}
+
+_PSEUDO = re.compile(r"^(v\d+)\.(\d+)\.(\d+)-((?:[0-9A-Za-z.-]+\.)?0\.|)(\d{14})-([0-9a-f]{12})(\+incompatible)?$")
+_SPDX = re.compile(r"SPDX-License-Identifier:\s*([\w.+-]+(?:\s+(?:OR|AND|WITH)\s+[\w.+-]+)*)")
+_REQUIREMENT = re.compile(r"^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(\[[^\]]*\])?\s*(.*?)\s*(?:;.*)?$")
+_CLASSIFIER = re.compile(r"^Classifier:\s*License :: (?:OSI Approved :: )?(.+)$", re.MULTILINE)
+_PY_CLASSIFIERS = {
+    "MIT License": "MIT", "Apache Software License": "Apache-2.0", "BSD License": "BSD",
+    "ISC License (ISCL)": "ISC", "Mozilla Public License 2.0 (MPL 2.0)": "MPL-2.0",
+    "GNU General Public License v3 (GPLv3)": "GPL-3.0", "GNU General Public License v2 (GPLv2)": "GPL-2.0",
+    "GNU Lesser General Public License v3 (LGPLv3)": "LGPL-3.0",
+    "GNU Lesser General Public License v2 or later (LGPLv2+)": "LGPL-2.0-or-later",
+    "Python Software Foundation License": "PSF-2.0", "The Unlicense (Unlicense)": "Unlicense",
+}
+
+# (license, phrases that must all appear), most specific first
+_WORDINGS: List[Tuple[str, Tuple[str, ...]]] = [
+    ("AGPL-3.0", ("gnu affero general public license",)),
+    ("LGPL-3.0", ("gnu lesser general public license", "version 3")),
+    ("LGPL-2.1", ("gnu lesser general public license",)),
+    ("LGPL-2.0", ("gnu library general public license",)),
+    ("GPL-3.0", ("gnu general public license", "version 3")),
+    ("GPL-2.0", ("gnu general public license", "version 2")),
+    ("MPL-2.0", ("mozilla public license", "2.0")),
+    ("EPL-2.0", ("eclipse public license", "2.0")),
+    ("EPL-1.0", ("eclipse public license",)),
+    ("Apache-2.0", ("apache license", "version 2.0")),
+    ("BSL-1.0", ("boost software license",)),
+    ("Unlicense", ("this is free and unencumbered software released into the public domain",)),
+    ("CC0-1.0", ("cc0 1.0 universal",)),
+    ("MIT", ("permission is hereby granted, free of charge",)),
+    ("BSD-3-Clause", ("redistribution and use in source and binary forms", "neither the name")),
+    ("BSD-3-Clause", ("redistribution and use in source and binary forms", "names of its contributors")),
+    ("BSD-2-Clause", ("redistribution and use in source and binary forms",)),
+    ("ISC", ("permission to use, copy, modify, and/or distribute this software for any purpose",)),
+    ("ISC", ("permission to use, copy, modify, and distribute this software for any purpose",)),
+    ("Zlib", ("this software is provided 'as-is'", "altered source versions must be plainly marked")),
+]
+
+
+def decode_version(version: str) -> Optional[Dict[str, Any]]:
+    """
+    What a Go pseudo-version stands for, None for a tagged version:
+    {"commit", "time", "base"} - base the tag the commit follows
+    (v1.2.3 for v1.2.4-0...., v1.2.3-pre for v1.2.3-pre.0....), None
+    when no tag comes before it (v0.0.0-...).
+    """
+    match = _PSEUDO.match(version)
+    if match is None:
+        return None
+    major, minor, patch, infix, stamp, commit = match.group(1, 2, 3, 4, 5, 6)
+    if not infix:
+        base = None
+    elif infix == "0.":
+        base = f"{major}.{minor}.{int(patch) - 1}" if int(patch) > 0 else None
+    else:
+        base = f"{major}.{minor}.{patch}-{infix[:-3]}"
+    time = datetime.strptime(stamp, "%Y%m%d%H%M%S").replace(tzinfo=timezone.utc)
+    return {"commit": commit, "time": time.strftime("%Y-%m-%dT%H:%M:%SZ"), "base": base}
+
+
+def parse_go_sum(content: str) -> Dict[Tuple[str, str], Dict[str, bool]]:
+    """(module path, version) -> whether go.sum has the hash of its content ("module") and of its go.mod."""
+    sums: Dict[Tuple[str, str], Dict[str, bool]] = {}
+    for line in content.splitlines():
+        words = line.split()
+        if len(words) != 3:
+            continue
+        path, version = words[0], words[1]
+        go_mod = version.endswith("/go.mod")
+        entry = sums.setdefault((path, version[:-len("/go.mod")] if go_mod else version),
+                                {"module": False, "go_mod": False})
+        entry["go_mod" if go_mod else "module"] = True
+    return sums
+
+
+def parse_package_json(content: str) -> List[Dict[str, Any]]:
+    """The dependencies a package.json declares: {"name", "version" (the range as written), "kind"}."""
+    try:
+        manifest = json.loads(content)
+    except ValueError:
+        return []
+    if not isinstance(manifest, dict):
+        return []
+    declared = []
+    for field, kind in (("dependencies", "runtime"), ("devDependencies", "dev"), ("peerDependencies", "peer"),
+                        ("optionalDependencies", "optional")):
+        for name, version in sorted((manifest.get(field) or {}).items()):
+            declared.append({"name": name, "version": version if isinstance(version, str) else None, "kind": kind})
+    return declared
+
+
+def parse_requirements(content: str) -> List[Dict[str, Any]]:
+    """
+    The requirements of a requirements.txt: {"name", "version" (the
+    specifier as written, None without one), "extras", "line"}. Options
+    (-r, -e, --index-url), URLs and local paths are skipped.
+    """
+    requirements = []
+    for number, line in enumerate(content.splitlines(), 1):
+        text = line.split(" #", 1)[0].strip()
+        if not text or text.startswith(("#", "-")) or "://" in text or text.startswith((".", "/")):
+            continue
+        match = _REQUIREMENT.match(text)
+        if match is None:
+            continue
+        requirement = {"name": match.group(1), "version": match.group(3) or None, "line": number}
+        if match.group(2):
+            requirement["extras"] = [e.strip() for e in match.group(2)[1:-1].split(",") if e.strip()]
+        requirements.append(requirement)
+    return requirements
+
+
+def normalized_name(name: str) -> str:
+    """A Python distribution name as PEP 503 compares them."""
+    return re.sub(r"[-_.]+", "-", name).lower()
+
+
+def npm_package(specifier: str) -> Optional[str]:
+    """The package a bare import specifier names ("@scope/pkg/sub" -> "@scope/pkg"), None for a relative one."""
+    if not specifier or specifier.startswith((".", "/", "node:", "#")) or "://" in specifier:
+        return None
+    parts = specifier.split("/")
+    if specifier.startswith("@"):
+        return "/".join(parts[:2]) if len(parts) > 1 else None
+    return parts[0]
+
+
+def owning_requirement(import_path: str, required: Iterable[str]) -> Optional[str]:
+    """The required module an import path belongs to: the longest module path it is in."""
+    best = None
+    for path in required:
+        if (import_path == path or import_path.startswith(path + "/")) and (best is None or len(path) > len(best)):
+            best = path
+    return best
+
+
+def classify_license(text: str) -> Optional[str]:
+    """The license a license file's text reads as, None if it matches none of the known ones."""
+    spdx = _SPDX.search(text[:2000])
+    if spdx:
+        return spdx.group(1)
+    folded = re.sub(r"\s+", " ", text.lower().replace("’", "'"))
+    for license, phrases in _WORDINGS:
+        if all(phrase in folded for phrase in phrases):
+            return license
+    return None
+
+
+def detect_license(directory: str) -> Optional[Dict[str, Any]]:
+    """
+    The license of the package in a directory, from its license files:
+    {"license", "files"}, "unknown" for files matching no known license;
+    None without any license file. Several files of different licenses
+    (LICENSE-APACHE, LICENSE-MIT) are dual licensing, "Apache-2.0 OR MIT".
+    """
+    try:
+        names = sorted(name for name in os.listdir(directory)
+                       if LICENSE_FILES.match(name) and os.path.splitext(name)[1].lower() not in _SOURCE_EXTENSIONS)
+    except OSError:
+        return None
+    found: List[str] = []
+    files = []
+    for name in names:
+        path = os.path.join(directory, name)
+        if not os.path.isfile(path):
+            continue
+        try:
+            with open(path, "r", encoding="utf-8", errors="replace") as f:
+                text = f.read(65536)
+        except OSError:
+            continue
+        files.append(name)
+        license = classify_license(text)
+        if license and license not in found:
+            found.append(license)
+    if not files:
+        return None
+    return {"license": " OR ".join(found) if found else "unknown", "files": files}
+
+
+def npm_license(manifest: Dict[str, Any]) -> Optional[str]:
+    """The license an installed package's package.json declares."""
+    license = manifest.get("license")
+    if isinstance(license, dict):
+        license = license.get("type")
+    if isinstance(license, str) and license.strip():
+        return license.strip()
+    licenses = manifest.get("licenses")
+    if isinstance(licenses, list):
+        types = [entry.get("type") for entry in licenses if isinstance(entry, dict) and entry.get("type")]
+        return " OR ".join(types) if types else None
+    return None
+
+
+def python_license(metadata: str) -> Optional[str]:
+    """The license of a dist-info METADATA: License-Expression, a License classifier, or the License field."""
+    headers = metadata.split("\n\n", 1)[0]
+    expression = re.search(r"^License-Expression:\s*(.+)$", headers, re.MULTILINE)
+    if expression:
+        return expression.group(1).strip()
+    classifiers = [_PY_CLASSIFIERS.get(c.strip(), c.strip()) for c in _CLASSIFIER.findall(headers)]
+    if classifiers:
+        return " OR ".join(dict.fromkeys(classifiers))
+    field = re.search(r"^License:\s*(.+)$", headers, re.MULTILINE)
+    if field and len(field.group(1).strip()) <= 40 and field.group(1).strip().upper() != "UNKNOWN":
+        # Long License fields are the license text itself
+        return field.group(1).strip()
+    return None
+
+
+def module_cache_dir() -> Optional[str]:
+    """The Go module cache: GOMODCACHE, else the first GOPATH entry's pkg/mod (GOPATH defaults to ~/go)."""
+    if os.environ.get("GOMODCACHE"):
+        return os.environ["GOMODCACHE"]
+    gopath = os.environ.get("GOPATH", "").split(os.pathsep)[0] or os.path.join(os.path.expanduser("~"), "go")
+    return os.path.join(gopath, "pkg", "mod")
+
+
+def escaped_module_path(path: str) -> str:
+    """A module path as the module cache spells it: upper-case letters as "!" and the lower-case letter."""
+    return re.sub(r"[A-Z]", lambda m: "!" + m.group(0).lower(), path)
@@ -0,0 +1,274 @@
+"""Doc comments that no longer describe their declaration, and exported ones without any.
+
+Heuristics, each finding explained in its "message":
+
+    name_mismatch      a Go doc comment opens with another identifier than
+                       the declared name - "// GetUser returns ..." above a
+                       func FetchUser (after an article: "// A Store ...");
+                       flagged when that word reads like code (GetUser,
+                       userID, get_user) or is the name of another
+                       declaration of the package
+    unknown_parameter  the doc names a parameter the signature does not
+                       have: a `@param name` tag (Java, JS/TS), `:param name:`
+                       or a Google-style `Args:` entry (Python), a `* \\`name\\``
+                       under `# Arguments` (Rust), or in Go a code-looking
+                       identifier - `backquoted`, a [doc link], camelCase -
+                       that is no parameter, result, receiver or
+                       declaration of the package
+
+Git blame then compares when the declaration's code and its comment last
+changed (see XRayIndexer.find_stale_docs): code rewritten after the comment
+raises a finding's confidence one step.
+
+exported_undocumented tells the completeness side: exported functions,
+methods and types (in Go, methods of exported types) with no doc at all.
+Test files are left out.
+"""
+
+import difflib
+import re
+from typing import Any, Dict, List, Optional, Set
+
+from xray.core.go_parser import KEYWORDS
+
+CONFIDENCE = ("low", "medium", "high")
+
+# Declarations kinds a missing doc is reported for
+DOCUMENTED_KINDS = {"function", "method", "struct", "interface", "type", "class", "enum", "trait", "record"}
+
+_IDENTIFIER = re.compile(r"^[A-Za-z_][A-Za-z0-9_]*$")
+# GetUser, userID, get_user, v2: not an English word (nor an acronym, HTTP)
+_CODE_LIKE = re.compile(r"^(?:(?=\w*[a-z])[A-Za-z][a-z0-9]*[A-Z]\w*|\w*_\w+|[A-Za-z]+\d\w*)$")
+_WORD = re.compile(r"[A-Za-z_][A-Za-z0-9_]*")
+_METHOD_NAME = re.compile(r"^\(?\*?(\w+)\)?\.(\w+)$")
+_GO_MENTION = re.compile(r"`([a-z_]\w*)`|\[([a-z_]\w*)]|(?<![\w.`\[])([a-z]+[A-Z]\w*)(?![\w(`\]])")
+_PARAM_TAG = re.compile(r"@param\s+(?:\{[^}]*\}\s*)?\[?([A-Za-z_$][\w$]*)")
+_SPHINX_PARAM = re.compile(r":param\s+(?:[^:\s]+\s+)?\*{0,2}([A-Za-z_]\w*)\s*:")
+_SECTION = re.compile(r"^(\s*)(Args|Arguments|Parameters|Keyword Args|Keyword Arguments):\s*$")
+_GOOGLE_ENTRY = re.compile(r"^(\s+)\*{0,2}([A-Za-z_]\w*)\s*(?:\([^)]*\))?\s*:")
+_RUST_ARGUMENT = re.compile(r"^\s*[*-]\s*`([A-Za-z_]\w*)`")
+# Leading words that are not meant to be the name
+_PREAMBLE = {"Deprecated", "TODO", "FIXME", "NOTE", "BUG", "XXX", "HACK", "Copyright", "Package", "Code",
+             "Example", "Examples", "Implements", "See", "Returns", "Note"}
+_ARTICLES = {"A", "An", "The"}
+_GO_BUILTINS = {"append", "cap", "clear", "close", "complex", "copy", "delete", "imag", "len", "make", "max",
+                "min", "new", "panic", "print", "println", "real", "recover", "any", "bool", "byte", "comparable",
+                "error", "float32", "float64", "int", "int8", "int16", "int32", "int64", "rune", "string",
+                "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "nil", "true", "false", "iota"}
+# camelCase words that are names of things, not of code
+_BRANDS = {"iOS", "macOS", "tvOS", "watchOS", "iPadOS", "iPhone", "iPad", "iCloud", "eBay", "gRPC", "jQuery",
+           "mTLS", "eBPF", "pH", "npm"}
+
+
+def raise_confidence(confidence: str) -> str:
+    return CONFIDENCE[min(CONFIDENCE.index(confidence) + 1, len(CONFIDENCE) - 1)]
+
+
+def parameter_names(symbol: Dict[str, Any], language: str) -> Optional[Set[str]]:
+    """The names a doc may call parameters, None where the parser does not list them."""
+    if language == "go":
+        names = {p["name"] for p in symbol.get("params", []) + symbol.get("results", []) if p.get("name")}
+        names |= {p["name"] for p in symbol.get("typeParams") or [] if isinstance(p, dict) and p.get("name")}
+        if symbol.get("receiver"):
+            names.add(symbol["receiver"].get("name") or "")
+        return names
+    params = symbol.get("params", symbol.get("parameters"))
+    if params is None and language in ("typescript", "javascript") and symbol["type"] in ("function", "method"):
+        params = _ts_params(symbol.get("signature") or "")
+    if params is None:
+        return None
+    return {p["name"].lstrip("*") for p in params if p.get("name")}
+
+
+def _ts_params(signature: str) -> List[Dict[str, str]]:
+    """Parameter names from a TS/JS signature's parameter list, destructured ones left out."""
+    start = signature.find("(")
+    if start < 0:
+        return []
+    depth, part, parts = 0, "", []
+    for c in signature[start + 1:]:
+        if c in "([{<":
+            depth += 1
+        elif c in ")]}>":
+            if depth == 0:
+                break
+            depth -= 1
+        if c == "," and depth == 0:
+            parts.append(part)
+            part = ""
+        else:
+            part += c
+    parts.append(part)
+    names = []
+    for text in parts:
+        match = re.match(r"\s*(?:\.\.\.)?(?:(?:public|private|protected|readonly)\s+)*([A-Za-z_$][\w$]*)", text)
+        if match:
+            names.append({"name": match.group(1)})
+    return names
+
+
+def _documented_parameters(doc: str, language: str) -> List[str]:
+    """The parameter names a doc's tags or argument sections document."""
+    if language in ("java", "typescript", "javascript"):
+        return _PARAM_TAG.findall(doc)
+    if language == "rust":
+        names, inside = [], False
+        for line in doc.split("\n"):
+            if line.startswith("#"):
+                inside = line.lstrip("#").strip().lower() in ("arguments", "parameters")
+            elif inside:
+                match = _RUST_ARGUMENT.match(line)
+                if match:
+                    names.append(match.group(1))
+        return names
+    if language == "python":
+        names = _SPHINX_PARAM.findall(doc)
+        section: Optional[int] = None
+        entry: Optional[int] = None
+        for line in doc.split("\n"):
+            heading = _SECTION.match(line)
+            if heading:
+                section, entry = len(heading.group(1)), None
+                continue
+            if section is None or not line.strip():
+                continue
+            indent = len(line) - len(line.lstrip())
+            if indent <= section:
+                section = None
+                continue
+            match = _GOOGLE_ENTRY.match(line)
+            if match and (entry is None or len(match.group(1)) <= entry):
+                entry = len(match.group(1))
+                names.append(match.group(2))
+        return names
+    return []
+
+
+def _leading_name(doc: str) -> Optional[str]:
+    """The identifier a Go doc comment opens with (after an article), or None."""
+    words = doc.split(None, 2)
+    if not words:
+        return None
+    word = words[0]
+    if word in _ARTICLES and len(words) > 1:
+        word = words[1]
+    word = word.rstrip(",.:;")
+    method = _METHOD_NAME.match(word)
+    if method:
+        word = method.group(2)
+    if not _IDENTIFIER.match(word) or word in _PREAMBLE:
+        return None
+    return word
+
+
+def doc_findings(symbol: Dict[str, Any], language: str, declared: Set[str]) -> List[Dict[str, Any]]:
+    """
+    The name_mismatch and unknown_parameter findings of one declaration's
+    doc; declared holds the names declared in its package (fields and
+    methods too), which a doc may mention freely.
+    """
+    doc = symbol.get("doc") or ""
+    if not doc or symbol["type"] in ("field", "module", "package"):
+        return []
+    name = symbol["name"]
+    findings = []
+    if language == "go" and symbol["type"] in DOCUMENTED_KINDS:
+        # A var or const group's doc is the group's, not its first name's
+        leading = _leading_name(doc)
+        if leading and leading != name and (_CODE_LIKE.match(leading) or leading in declared) \
+                and leading not in _BRANDS:
+            similar = difflib.SequenceMatcher(None, leading.lower(), name.lower()).ratio() >= 0.5
+            findings.append({
+                "kind": "name_mismatch",
+                "word": leading,
+                "message": f"doc comment starts with '{leading}', but the {symbol['type']} is named {name}"
+                           + (f"; {leading} is declared elsewhere in the package" if leading in declared else ""),
+                "confidence": "medium" if similar or leading in declared else "low",
+            })
+
+    params = parameter_names(symbol, language) if symbol["type"] in ("function", "method") else None
+    if params is None:
+        return findings
+    if language == "go":
+        signature_words = set(_WORD.findall(symbol.get("signature") or ""))
+        seen: Set[str] = set()
+        for match in _GO_MENTION.finditer(doc):
+            word = next(group for group in match.groups() if group)
+            if word in seen or word in params or word in declared or word in signature_words \
+                    or word in KEYWORDS or word in _GO_BUILTINS or word in _BRANDS or word == name:
+                continue
+            seen.add(word)
+            findings.append({
+                "kind": "unknown_parameter",
+                "word": word,
+                "message": f"doc comment mentions '{word}', which is not a parameter, result or receiver of {name}"
+                           f" nor declared in the package",
+                "confidence": "low" if match.group(3) else "medium",
+            })
+        return findings
+    for word in dict.fromkeys(_documented_parameters(doc, language)):
+        if word in params or word in ("self", "cls"):
+            continue
+        findings.append({
+            "kind": "unknown_parameter",
+            "word": word,
+            "message": f"doc comment documents parameter '{word}', but {name} takes "
+                       + (", ".join(sorted(p for p in params if p not in ("self", "cls"))) or "no parameters"),
+            "confidence": "medium",
+        })
+    return findings
+
+
+def exported_undocumented(symbol: Dict[str, Any], language: str, exported_types: Set[str]) -> bool:
+    """Whether a declaration is exported, of a kind worth a doc, and has none."""
+    if symbol.get("doc") or symbol["type"] not in DOCUMENTED_KINDS or symbol.get("nested"):
+        return False
+    name = symbol["name"]
+    if language == "go":
+        if not name[:1].isupper() or symbol.get("container"):
+            return False
+        receiver = (symbol.get("receiver") or {}).get("type")
+        return receiver is None or receiver in exported_types
+    return bool(symbol.get("exported")) and not name.startswith("_")
+
+
+def doc_span(lines: List[str], symbol: Dict[str, Any], language: str) -> Optional[List[int]]:
+    """
+    The first and last line of a declaration's doc comment in its file
+    (lines, 0-based list), or None if it cannot be told: comment lines
+    right above the declaration - annotations and attributes in between
+    skipped - or a Python docstring below it.
+    """
+    start = symbol["start_line"]
+    if language == "python":
+        for number in range(start, min(symbol.get("end_line") or start, start + 20) + 1):
+            text = lines[number - 1].strip() if number <= len(lines) else ""
+            quote = re.match(r"^[rRuUbB]*('''|\"\"\")", text)
+            if not quote:
+                continue
+            rest = text[quote.end():]
+            end = number
+            while quote.group(1) not in rest and end < len(lines):
+                end += 1
+                rest = lines[end - 1]
+            return [number, end]
+        return None
+    first = last = None
+    number = start - 1
+    in_block = False
+    while number >= 1:
+        text = lines[number - 1].strip() if number <= len(lines) else ""
+        if in_block:
+            in_block = not text.startswith("/*")
+        elif text.endswith("*/"):
+            in_block = not text.startswith("/*")
+        elif not text.startswith("//"):
+            if last is None and (text.startswith("@") or text.startswith("#[")):
+                # Java annotations and Rust attributes between the doc and the declaration
+                number -= 1
+                continue
+            break
+        first = number
+        last = last or number
+        number -= 1
+    return [first, last] if first is not None else None
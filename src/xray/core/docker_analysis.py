@@ -0,0 +1,655 @@
+"""Containers: the stages of a project's Dockerfiles, its compose services, and the Go binaries they run.
+
+A Dockerfile is read stage by stage (see xray.core.docker_parser):
+
+    FROM golang:1.22 AS build      a stage; its base image split into image,
+                                   tag and digest (ARGs declared before the
+                                   first FROM substituted), or an earlier stage
+    COPY --from=build /out /app    copies, from the build context or a stage
+    RUN go build -o /out ./cmd/x   go commands, run in the project directory
+                                   the stage's WORKDIR was copied from and
+                                   resolved as xray.core.task_analysis does
+    EXPOSE 8080/tcp                ports; also ENTRYPOINT, CMD, ENV, WORKDIR
+
+The binary a container runs is the program of its ENTRYPOINT (else CMD),
+followed back through COPY --from to the `go build` or `go install` that
+wrote it, which names the main package. A compose service builds a
+Dockerfile (its build context and target stage) or runs an image; its ports,
+expose, command and entrypoint add to or override the image's.
+
+The ports a binary listens on are the constant addresses its packages give
+http.ListenAndServe, net.Listen, gin's Run, echo's Start and
+http.Server{Addr: ...}. A container that declares ports is checked against
+them both ways: a port listened on but not exposed, and one exposed that
+nothing listens on (unless some listener's address is only known at run
+time, from a flag or the environment).
+"""
+
+import os
+import posixpath
+import re
+from typing import Any, Callable, Dict, List, Optional, Set, Tuple
+
+from xray.core.docker_parser import YamlMap, compose_dockerfile
+from xray.core.go_analysis import GoCallGraph, GoProject
+from xray.core.go_helpers import file_imports
+from xray.core.go_parser import tokenize, unquote
+from xray.core.task_analysis import COMMAND_PREFIXES, go_command, simple_commands
+
+# Where a bare program name is looked up in an image
+_PATH = ("/usr/local/bin", "/usr/bin", "/bin", "/go/bin", "/usr/local/go/bin")
+# Programs that start the real one given after them
+_INIT_PROGRAMS = {"tini", "dumb-init", "--", "exec"}
+# Listening calls: (package, function) -> index of the address argument
+_LISTENERS = {
+    ("net/http", "ListenAndServe"): 0, ("net/http", "ListenAndServeTLS"): 0, ("net", "Listen"): 1,
+}
+# Framework methods taking an address, on a value of the framework's type
+_FRAMEWORK_LISTENERS = {
+    "github.com/gin-gonic/gin": "Run", "github.com/labstack/echo/v4": "Start", "github.com/labstack/echo": "Start",
+    "github.com/gofiber/fiber/v2": "Listen",
+}
+_NAMED_PORTS = {"http": 80, "https": 443}
+
+_VARIABLE = re.compile(r"\$(?:\{([A-Za-z_][A-Za-z0-9_]*)(?::?[-+]([^}]*))?\}|([A-Za-z_][A-Za-z0-9_]*))")
+_ASSIGNMENT = re.compile(r"^[A-Za-z_][A-Za-z0-9_]*=")
+
+
+def _expand(text: str, variables: Dict[str, str]) -> str:
+    """${NAME}, $NAME and ${NAME:-default} from variables; unknown ones are left as written."""
+    def value(match: re.Match) -> str:
+        name = match.group(1) or match.group(3)
+        if name in variables:
+            return variables[name]
+        return match.group(2) if match.group(2) is not None else match.group(0)
+    return _VARIABLE.sub(value, text)
+
+
+def _port(address: str) -> Optional[int]:
+    """The port of a listen address (":8080", "0.0.0.0:8080", ":http"), or None."""
+    port = address.rpartition(":")[2] if ":" in address else address
+    if port.isdigit():
+        return int(port)
+    return _NAMED_PORTS.get(port)
+
+
+def _ports(specs: List[Any], line: Optional[int], path: str, compose: bool = False) -> List[Dict[str, Any]]:
+    """
+    Container ports of EXPOSE words (8080, 8080/udp), or of compose
+    `ports` entries ("8080", "127.0.0.1:9000:8080/tcp", {target: 8080}).
+    """
+    ports = []
+    for spec in specs:
+        entry: Dict[str, Any] = {}
+        if isinstance(spec, dict):
+            target, protocol = str(spec.get("target") or ""), spec.get("protocol") or "tcp"
+            if spec.get("published"):
+                entry["published"] = str(spec["published"])
+        elif spec is not None:
+            text, _, protocol = str(spec).partition("/")
+            protocol = protocol or "tcp"
+            parts = text.split(":")
+            target = parts[-1]
+            if compose and len(parts) > 1:
+                entry["published"] = parts[-2]
+        else:
+            continue
+        first = target.split("-")[0]
+        if not first.isdigit():
+            continue
+        ports.append({"port": int(first), "protocol": protocol, **entry, "path": path, "line": line})
+    return ports
+
+
+def _words(entry: Any) -> List[str]:
+    """The words of an exec-form list or a shell-form command line."""
+    if isinstance(entry, list):
+        return [str(w) for w in entry if w is not None]
+    if isinstance(entry, str):
+        commands = simple_commands(entry)
+        return commands[0] if commands else []
+    return []
+
+
+def listeners(graph: GoCallGraph, read: Callable[[str], Optional[str]]) -> Dict[str, List[Dict[str, Any]]]:
+    """
+    Package directory -> the listen addresses of its non-test files: {"address"
+    and "port" when constant, else "dynamic", "call", "function", "path",
+    "line"}.
+    """
+    project = graph.project
+    found: Dict[str, List[Dict[str, Any]]] = {}
+    for path, parsed in sorted(project.files.items()):
+        if path.endswith("_test.go"):
+            continue
+        imports = file_imports(parsed)
+        frameworks = {_FRAMEWORK_LISTENERS[p] for p in imports.values() if p in _FRAMEWORK_LISTENERS}
+        entries = found.setdefault(os.path.dirname(path), [])
+        for func in parsed.get("functions", []):
+            name = f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+            for call in func.get("calls", []):
+                chain = call["chain"]
+                if len(chain) != 2:
+                    continue
+                if chain[0] in imports and chain[0] not in func.get("locals", {}):
+                    index = _LISTENERS.get((imports[chain[0]], chain[1]))
+                    label = f"{imports[chain[0]]}.{chain[1]}"
+                elif chain[1] in frameworks and chain[0] not in imports:
+                    index, label = 0, ".".join(chain)
+                else:
+                    continue
+                if index is None or len(call["args"]) <= index:
+                    continue
+                address = _address(graph, path, func, call["args"][index])
+                entry = {"call": label, "function": name, "path": path, "line": call["line"]}
+                entries.append({**({"address": address, "port": _port(address)} if address is not None
+                                   else {"dynamic": call["arg_texts"][index]}), **entry})
+        if "net/http" in imports.values():
+            entries.extend(_server_addresses(path, parsed, read))
+    return {d: e for d, e in found.items() if e}
+
+
+def _address(graph: GoCallGraph, path: str, func: Dict[str, Any], source: Optional[Dict[str, Any]]) -> Optional[str]:
+    """The constant value of an address argument, through a local or a package constant."""
+    source = source or {}
+    chain = source.get("chain")
+    if chain and len(chain) == 1 and chain[0] in func.get("locals", {}):
+        source = func["locals"][chain[0]] or {}
+    elif chain and len(chain) <= 2:
+        value = graph.constant_value(path, chain)
+        return value if isinstance(value, str) else None
+    value = source.get("value")
+    return value if isinstance(value, str) else None
+
+
+def _server_addresses(path: str, parsed: Dict[str, Any], read: Callable[[str], Optional[str]]) -> List[Dict[str, Any]]:
+    """The `Addr: "..."` fields of a file's composite literals (http.Server{Addr: ":8080"})."""
+    content = read(path)
+    if content is None or "Addr" not in content:
+        return []
+    tokens, _ = tokenize(content)
+    ends = {s["start_line"]: s["end_line"] for s in parsed["symbols"] if s["type"] in ("function", "method")}
+    entries = []
+    for i in range(len(tokens) - 3):
+        if tokens[i].value != "Addr" or tokens[i].kind != "ident" or tokens[i + 1].value != ":" or \
+                tokens[i + 2].kind != "string" or tokens[i + 3].value not in (",", "}", ";"):
+            continue
+        if i and tokens[i - 1].value not in ("{", ",", ";"):
+            continue
+        line = tokens[i].line
+        func = next((f for f in parsed.get("functions", []) if f["start_line"] <= line <= ends.get(f["start_line"], 0)),
+                    None)
+        address = unquote(tokens[i + 2].value)
+        entries.append({"address": address, "port": _port(address), "call": "net/http.Server{Addr}",
+                        "function": func and (f"{func['receiver']}.{func['name']}" if func.get("receiver")
+                                              else func["name"]),
+                        "path": path, "line": line})
+    return entries
+
+
+class ContainerMap:
+    """The Dockerfiles and compose services of a project, linked to its Go main packages."""
+
+    def __init__(self, go: GoProject, root: str, dockerfiles: Dict[str, Dict[str, Any]],
+                 composes: Dict[str, Tuple[Any, List[Dict[str, Any]]]],
+                 listening: Dict[str, List[Dict[str, Any]]]):
+        """
+        dockerfiles maps paths to parse_dockerfile results; composes maps
+        paths to (read_yaml result, compose_environment entries); listening
+        is what listeners found.
+        """
+        self.go = go
+        self.root = root
+        self.dockerfiles = dockerfiles
+        self.composes = composes
+        self.listening = listening
+        # Dockerfile -> the build context the first compose service building it gives
+        self._contexts: Dict[str, str] = {}
+        for path, (document, _) in sorted(composes.items()):
+            for service in self._services(document).values():
+                build = compose_dockerfile(path, service.get("build")) if isinstance(service, dict) else None
+                if build is not None:
+                    self._contexts.setdefault(build[1], build[0])
+        self._stages = {path: self._read_stages(path, parsed) for path, parsed in sorted(dockerfiles.items())}
+        self._closures: Dict[str, Set[str]] = {}
+
+    @staticmethod
+    def _services(document: Any) -> Dict[str, Any]:
+        services = document.get("services") if isinstance(document, dict) else None
+        return services if isinstance(services, dict) else {}
+
+    def _rel(self, path: str) -> str:
+        return os.path.relpath(path, self.root)
+
+    # ------------------------------------------------------------------
+    # Dockerfiles
+    # ------------------------------------------------------------------
+
+    def _context(self, path: str, parsed: Dict[str, Any]) -> str:
+        """The build context: a compose service's, else the Dockerfile's directory if its COPY sources are there."""
+        if path in self._contexts:
+            return self._contexts[path]
+        directory = os.path.dirname(path)
+        sources = [w for ins in parsed["instructions"] if ins["instruction"] in ("COPY", "ADD")
+                   and "from" not in ins["flags"] for w in (ins.get("exec") or ins["arguments"].split())[:-1]
+                   if not w.startswith("<<") and "://" not in w and "*" not in w and w != "."]
+        if directory != self.root and sources and not any(os.path.exists(os.path.join(directory, s))
+                                                           for s in sources):
+            return self.root
+        return directory
+
+    def _read_stages(self, path: str, parsed: Dict[str, Any]) -> Dict[str, Any]:
+        context = self._context(path, parsed)
+        arguments: Dict[str, str] = {}
+        stages: List[Dict[str, Any]] = []
+        for ins in parsed["instructions"]:
+            keyword, text = ins["instruction"], ins["arguments"]
+            if keyword == "FROM":
+                stage = self._stage(ins, arguments, stages)
+                stages.append(stage)
+                continue
+            if not stages:
+                if keyword == "ARG":
+                    for word in text.split():
+                        name, _, default = word.partition("=")
+                        arguments[name] = default.strip("\"'")
+                continue
+            stage = stages[-1]
+            variables = {**stage["_args"], **stage["env"]}
+            if keyword in ("ENV", "ARG"):
+                pairs = re.findall(r"([A-Za-z_][A-Za-z0-9_]*)=(\"[^\"]*\"|'[^']*'|\S*)", text)
+                if not pairs and keyword == "ENV" and " " in text:
+                    pairs = [tuple(text.split(None, 1))]
+                elif not pairs and keyword == "ARG":
+                    pairs = [(text.strip(), arguments.get(text.strip(), ""))]
+                for name, value in pairs:
+                    target = stage["env"] if keyword == "ENV" else stage["_args"]
+                    target[name] = _expand(value.strip("\"'"), variables)
+            elif keyword == "WORKDIR":
+                stage["workdir"] = posixpath.normpath(posixpath.join(stage["workdir"], _expand(text, variables)))
+            elif keyword in ("COPY", "ADD"):
+                words = ins.get("exec") or text.split()
+                if len(words) < 2 or words[0].startswith("<<"):
+                    continue
+                sources, dest = [_expand(w, variables) for w in words[:-1]], _expand(words[-1], variables)
+                copy: Dict[str, Any] = {"line": ins["line"], "sources": sources,
+                                        "dest": posixpath.normpath(posixpath.join(stage["workdir"], dest))}
+                # A destination ending in / (or taking several sources) is a directory to copy into
+                copy["_into"] = dest.endswith("/") or len(sources) > 1 or dest in (".", "./")
+                if ins["flags"].get("from"):
+                    copy["from"] = _expand(str(ins["flags"]["from"]), variables)
+                stage["copies"].append(copy)
+            elif keyword == "RUN":
+                bodies = [h["body"] for h in ins.get("heredocs", [])] or [" ".join(ins["exec"])
+                                                                        if "exec" in ins else text]
+                for body in bodies:
+                    self._run(stage, stages, body, ins["line"], context)
+            elif keyword == "EXPOSE":
+                stage["expose"].extend(_ports(_expand(text, variables).split(), ins["line"], path))
+            elif keyword in ("ENTRYPOINT", "CMD"):
+                stage[keyword.lower()] = {"line": ins["line"], "command": ins.get("exec", text),
+                                          "workdir": stage["workdir"]}
+                if keyword == "ENTRYPOINT":
+                    # An ENTRYPOINT resets the CMD inherited from the base
+                    stage.pop("cmd", None)
+            elif keyword == "USER":
+                stage["user"] = text
+        return {"context": context, "stages": stages}
+
+    def _stage(self, ins: Dict[str, Any], arguments: Dict[str, str], stages: List[Dict[str, Any]]) -> Dict[str, Any]:
+        words = ins["arguments"].split()
+        image = _expand(words[0], arguments) if words else ""
+        name = words[2] if len(words) >= 3 and words[1].lower() == "as" else None
+        base_stage = self._named(stages, image)
+        stage: Dict[str, Any] = {"name": name, "index": len(stages), "line": ins["line"]}
+        if base_stage is not None:
+            stage["base"] = {"stage": base_stage["name"] or str(base_stage["index"])}
+            for key in ("workdir", "entrypoint", "cmd", "user"):
+                if key in base_stage:
+                    stage[key] = base_stage[key]
+            stage["env"] = dict(base_stage["env"])
+            stage["expose"] = list(base_stage["expose"])
+        else:
+            base: Dict[str, Any] = {"image": image}
+            if "@" in image:
+                base["image"], base["digest"] = image.split("@", 1)
+            last = base["image"].rsplit("/", 1)[-1]
+            if ":" in last:
+                base["image"], base["tag"] = base["image"].rsplit(":", 1)
+            stage["base"] = base
+            stage["workdir"] = "/"
+            stage["env"] = {}
+            stage["expose"] = []
+        if ins["flags"].get("platform"):
+            stage["base"]["platform"] = ins["flags"]["platform"]
+        stage["_args"] = {}
+        stage["copies"] = []
+        stage["go"] = []
+        stage["_parent"] = base_stage
+        return stage
+
+    @staticmethod
+    def _named(stages: List[Dict[str, Any]], name: str) -> Optional[Dict[str, Any]]:
+        for stage in reversed(stages):
+            if stage["name"] and stage["name"].lower() == name.lower():
+                return stage
+        if name.isdigit() and int(name) < len(stages):
+            return stages[int(name)]
+        return None
+
+    def _run(self, stage: Dict[str, Any], stages: List[Dict[str, Any]], text: str, line: int, context: str):
+        """Add the go commands of a RUN to the stage, with the binaries they write."""
+        cwd = stage["workdir"]
+        variables = {**stage["_args"], **stage["env"]}
+        for words in simple_commands(text):
+            while words and (_ASSIGNMENT.match(words[0]) or words[0] in COMMAND_PREFIXES):
+                words = words[1:]
+            if not words:
+                continue
+            if words[0] == "cd" and len(words) > 1:
+                cwd = posixpath.normpath(posixpath.join(cwd, _expand(words[1], variables)))
+            elif os.path.basename(words[0]) == "go" and len(words) > 1:
+                run = go_command([_expand(w, variables) for w in words], line, self._host(stage, cwd, context),
+                                 self.go, self.root)
+                mains = [p for p in run.get("packages", []) if p.get("main")]
+                if run["command"] in ("go build", "go install") and len(mains) == 1:
+                    name = os.path.basename(mains[0]["dir"]) if mains[0]["dir"] != "." else \
+                        (mains[0].get("import_path") or self.root).rstrip("/").rsplit("/", 1)[-1]
+                    if run["command"] == "go install":
+                        binary = posixpath.join(variables.get("GOBIN") or posixpath.join(
+                            variables.get("GOPATH") or "/go", "bin"), name)
+                    elif "output" in run:
+                        output = posixpath.join(cwd, run["output"])
+                        binary = posixpath.join(output, name) if run["output"].endswith("/") else output
+                    else:
+                        binary = posixpath.join(cwd, name)
+                    run["binary"] = posixpath.normpath(binary)
+                stage["go"].append(run)
+
+    def _host(self, stage: Dict[str, Any], directory: str, context: str) -> str:
+        """The project directory a container directory was copied from; the build context when none was."""
+        chain = []
+        current: Optional[Dict[str, Any]] = stage
+        while current is not None:
+            chain = current["copies"] + chain
+            current = current["_parent"]
+        for copy in reversed(chain):
+            if copy.get("from"):
+                continue
+            for source in copy["sources"]:
+                host = os.path.normpath(os.path.join(context, source))
+                if os.path.isdir(host):
+                    host_dir = host
+                elif copy["_into"]:
+                    host_dir = os.path.dirname(host)
+                else:
+                    continue
+                dest = copy["dest"]
+                if directory == dest or directory.startswith(dest.rstrip("/") + "/"):
+                    return os.path.normpath(os.path.join(host_dir, posixpath.relpath(directory, dest)))
+                if dest.startswith(directory.rstrip("/") + "/"):
+                    # COPY cmd/ cmd/ beside WORKDIR /src: /src is the directory above
+                    below = posixpath.relpath(dest, directory).replace("/", os.sep)
+                    if host_dir.endswith(os.sep + below):
+                        return host_dir[:-len(below) - 1]
+        return context
+
+    def _binary(self, stages: List[Dict[str, Any]], stage: Dict[str, Any], program: str,
+                depth: int = 0) -> Optional[Dict[str, Any]]:
+        """Where the executable at program in a stage comes from: a go build of some stage, a copy or an image."""
+        if depth > len(stages) + 4:
+            return None
+        for run in reversed(stage["go"]):
+            if run.get("binary") == program:
+                package = next(p for p in run["packages"] if p.get("main"))
+                return {"package": package, "built_at": {"line": run["line"], "stage": stage["name"] or
+                                                         str(stage["index"]), "command": run["command"]}}
+        for copy in reversed(stage["copies"]):
+            for source in copy["sources"]:
+                dest = copy["dest"]
+                if copy["_into"]:
+                    dest = posixpath.join(dest, posixpath.basename(source.rstrip("/")))
+                if program == dest:
+                    origin = source
+                elif program.startswith(dest.rstrip("/") + "/"):
+                    origin = posixpath.join(source, posixpath.relpath(program, dest))
+                else:
+                    continue
+                source_stage = self._named(stages[:stage["index"]], copy["from"]) if copy.get("from") else None
+                if source_stage is not None:
+                    return self._binary(stages, source_stage, posixpath.normpath(posixpath.join("/", origin)),
+                                        depth + 1)
+                if copy.get("from"):
+                    return {"image": copy["from"], "copied_at": {"line": copy["line"]}}
+                return {"copied_from": origin, "copied_at": {"line": copy["line"]}}
+        if stage["_parent"] is not None:
+            return self._binary(stages, stage["_parent"], program, depth + 1)
+        return None
+
+    def _runs(self, stages: List[Dict[str, Any]], stage: Dict[str, Any], command: Optional[Dict[str, Any]],
+              path: str, context: str) -> Optional[Dict[str, Any]]:
+        """The binary an ENTRYPOINT or CMD runs, resolved where it can be."""
+        if command is None:
+            return None
+        words = _words(command["command"])
+        while words and os.path.basename(words[0]) in _INIT_PROGRAMS:
+            words = words[1:]
+        if not words:
+            return None
+        program = words[0]
+        result: Dict[str, Any] = {"program": program, "line": command["line"]}
+        if os.path.basename(program) == "go" and len(words) > 1:
+            run = go_command(words, command["line"], self._host(stage, command["workdir"], context),
+                             self.go, self.root)
+            main = next((p for p in run.get("packages", []) if p.get("main")), None)
+            if main is not None:
+                result["package"] = main
+                result["built_at"] = {"line": command["line"], "stage": stage["name"] or str(stage["index"]),
+                                      "command": run["command"]}
+            return result
+        if "/" in program:
+            candidates = [posixpath.normpath(posixpath.join(command["workdir"], program))]
+        else:
+            candidates = [posixpath.join(d, program) for d in _PATH] + [posixpath.join(command["workdir"], program)]
+        for candidate in candidates:
+            found = self._binary(stages, stage, candidate)
+            if found is not None:
+                result.update(found)
+                if "built_at" in found:
+                    result["built_at"]["path"] = path
+                return result
+        return result
+
+    def _entry_runs(self, stages: List[Dict[str, Any]], stage: Dict[str, Any], entrypoint: Optional[Dict[str, Any]],
+                    cmd: Optional[Dict[str, Any]], path: str, context: str) -> Optional[Dict[str, Any]]:
+        """What the ENTRYPOINT runs, or the CMD when the entrypoint is not a Go binary (a wrapper script)."""
+        runs = self._runs(stages, stage, entrypoint, path, context)
+        if runs is None or "package" not in runs:
+            runs = self._runs(stages, stage, cmd, path, context) or runs
+        return runs
+
+    def _final(self, path: str, target: Optional[str] = None) -> Optional[Dict[str, Any]]:
+        stages = self._stages[path]["stages"]
+        if target:
+            return self._named(stages, target)
+        return stages[-1] if stages else None
+
+    def _dockerfile_entry(self, path: str) -> Dict[str, Any]:
+        info = self._stages[path]
+        stages, context = info["stages"], info["context"]
+        entry: Dict[str, Any] = {"kind": "dockerfile", "name": self._rel(path), "path": path, "line": 1,
+                                 "context": self._rel(context)}
+        final = self._final(path)
+        entry["stages"] = [self._public(s, path) for s in stages]
+        if final is not None:
+            entry["expose"] = [{k: v for k, v in p.items() if k != "path"} for p in final["expose"]]
+            runs = self._entry_runs(stages, final, final.get("entrypoint"), final.get("cmd"), path, context)
+            if runs is not None:
+                entry["runs"] = runs
+        return entry
+
+    @staticmethod
+    def _public(stage: Dict[str, Any], path: str) -> Dict[str, Any]:
+        public = {k: v for k, v in stage.items() if not k.startswith("_")}
+        public["copies"] = [{k: v for k, v in c.items() if not k.startswith("_")} for c in stage["copies"]]
+        public["expose"] = [{k: v for k, v in p.items() if k != "path"} for p in stage["expose"]]
+        for key in ("entrypoint", "cmd"):
+            if key in public:
+                public[key] = {"line": public[key]["line"], "command": public[key]["command"]}
+        return public
+
+    # ------------------------------------------------------------------
+    # Compose services
+    # ------------------------------------------------------------------
+
+    def _service_entry(self, path: str, name: str, service: Dict[str, Any], line: Optional[int],
+                       environment: List[Dict[str, Any]]) -> Dict[str, Any]:
+        lines = service.lines if isinstance(service, YamlMap) else {}
+        entry: Dict[str, Any] = {"kind": "compose_service", "name": name, "path": path, "line": line}
+        if service.get("image"):
+            entry["image"] = service["image"]
+        build = compose_dockerfile(path, service.get("build"))
+        final = None
+        if build is not None:
+            context, dockerfile, target = build
+            entry["build"] = {"context": self._rel(context), "dockerfile": self._rel(dockerfile)}
+            if target:
+                entry["build"]["target"] = target
+            if dockerfile in self._stages:
+                final = self._final(dockerfile, target)
+        listed = {key: service[key] if isinstance(service.get(key), list) else [] for key in ("ports", "expose")}
+        ports = _ports(listed["ports"], lines.get("ports"), path, compose=True) + \
+            _ports(listed["expose"], lines.get("expose"), path)
+        if final is not None:
+            ports += final["expose"]
+        entry["ports"] = [{k: v for k, v in p.items() if k != "path"} for p in ports]
+        entry["environment"] = [{k: v for k, v in e.items() if k not in ("value", "service")}
+                                for e in environment if e.get("service") == name]
+        for key in ("command", "entrypoint"):
+            if service.get(key):
+                entry[key] = service[key]
+        depends = service.get("depends_on")
+        if depends:
+            entry["depends_on"] = sorted(depends) if isinstance(depends, (list, dict)) else [str(depends)]
+        if final is not None:
+            # A compose entrypoint replaces the image's and clears its CMD; a compose command replaces the CMD
+            entrypoint, cmd = final.get("entrypoint"), final.get("cmd")
+            if service.get("entrypoint"):
+                entrypoint = {"line": lines.get("entrypoint"), "command": service["entrypoint"],
+                              "workdir": final["workdir"]}
+                cmd = None
+            if service.get("command"):
+                cmd = {"line": lines.get("command"), "command": service["command"], "workdir": final["workdir"]}
+            runs = self._entry_runs(self._stages[build[1]]["stages"], final, entrypoint, cmd, build[1], build[0])
+            if runs is not None:
+                entry["runs"] = runs
+        entry["_ports"] = ports
+        return entry
+
+    # ------------------------------------------------------------------
+    # Ports
+    # ------------------------------------------------------------------
+
+    def _closure(self, main_dir: str) -> Set[str]:
+        """The package directories a main package builds in: it and its internal imports, test files aside."""
+        if main_dir not in self._closures:
+            seen = {main_dir}
+            work = [main_dir]
+            while work:
+                pkg_dir = work.pop()
+                for path in self.go.packages.get(pkg_dir, {}).get("files", []):
+                    if path.endswith("_test.go"):
+                        continue
+                    for imp in self.go.files[path].get("imports", []):
+                        target = self.go.import_dir(imp["path"], path)
+                        if target is not None and target not in seen and target in self.go.packages:
+                            seen.add(target)
+                            work.append(target)
+            self._closures[main_dir] = seen
+        return self._closures[main_dir]
+
+    def _check(self, entry: Dict[str, Any], ports: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """Listen addresses of the binary a container runs, and the mismatches with the ports it declares."""
+        package = (entry.get("runs") or {}).get("package")
+        if package is None:
+            return []
+        main_dir = os.path.normpath(os.path.join(self.root, package["dir"]))
+        listens = sorted((l for d in self._closure(main_dir) for l in self.listening.get(d, [])),
+                         key=lambda l: (l["path"], l["line"]))
+        if not listens:
+            return []
+        entry["runs"]["listens"] = listens
+        declared = {p["port"] for p in ports}
+        constant = {l["port"] for l in listens if l.get("port") is not None}
+        dynamic = any(l.get("port") is None for l in listens)
+        container = {"container": entry["name"], "kind": entry["kind"], "path": entry["path"]}
+        mismatches = []
+        if declared:
+            for listen in listens:
+                if listen.get("port") is not None and listen["port"] not in declared:
+                    mismatches.append({"issue": "listening_not_exposed", "port": listen["port"], **container,
+                                       "listener": {k: listen[k] for k in ("address", "call", "function", "path",
+                                                                            "line")}})
+        if constant and not dynamic:
+            reported: Set[int] = set()
+            for port in ports:
+                if port["port"] not in constant and port["port"] not in reported:
+                    reported.add(port["port"])
+                    mismatches.append({"issue": "exposed_not_listening", "port": port["port"], **container,
+                                       "declared_at": {"path": port["path"], "line": port["line"]},
+                                       "listening_on": sorted(constant)})
+        return mismatches
+
+    # ------------------------------------------------------------------
+    # Results
+    # ------------------------------------------------------------------
+
+    def containers(self, scope: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Every Dockerfile and compose service, with the binary it runs and
+        the ports it declares checked against that binary's listeners.
+
+        Returns:
+            {"containers", "port_mismatches"}
+        """
+        entries: List[Tuple[Dict[str, Any], List[Dict[str, Any]]]] = []
+        for path in sorted(self._stages):
+            entry = self._dockerfile_entry(path)
+            final = self._final(path)
+            entries.append((entry, final["expose"] if final is not None else []))
+        for path, (document, environment) in sorted(self.composes.items()):
+            services = self._services(document)
+            for name, service in services.items():
+                if not isinstance(service, dict):
+                    continue
+                line = services.lines.get(name) if isinstance(services, YamlMap) else None
+                entry = self._service_entry(path, name, service, line, environment)
+                entries.append((entry, entry.pop("_ports")))
+        built_by: Dict[str, List[str]] = {}
+        for entry, _ in entries:
+            if entry["kind"] == "compose_service" and "build" in entry:
+                built_by.setdefault(os.path.join(self.root, entry["build"]["dockerfile"]), []).append(entry["name"])
+        containers = []
+        mismatches = []
+        for entry, ports in entries:
+            if scope is not None and entry["path"] != scope and not entry["path"].startswith(scope.rstrip(os.sep) + os.sep):
+                continue
+            if entry["kind"] == "dockerfile" and entry["path"] in built_by:
+                entry["built_by"] = sorted(built_by[entry["path"]])
+            mismatches.extend(self._check(entry, ports))
+            containers.append(entry)
+        return {"containers": containers, "port_mismatches": mismatches}
+
+    def attach(self, services: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """Add to service_map services the containers running them; returns the port mismatches."""
+        found = self.containers()
+        by_dir: Dict[str, List[Dict[str, Any]]] = {}
+        for entry in found["containers"]:
+            package = (entry.get("runs") or {}).get("package")
+            if package is not None:
+                by_dir.setdefault(package["dir"].replace(os.sep, "/"), []).append(
+                    {"kind": entry["kind"], "name": entry["name"], "path": entry["path"], "line": entry["line"]})
+        for service in services:
+            if (service["dir"] or ".") in by_dir:
+                service["containers"] = by_dir[service["dir"] or "."]
+        return found["port_mismatches"]
@@ -0,0 +1,346 @@
+"""Dockerfile and docker compose parser for XRAY - how a project's binaries are packaged and run.
+
+Dockerfiles (Dockerfile, Containerfile, Dockerfile.*, *.Dockerfile) give
+their instructions, one per logical line: continuations (backslash, or the
+character of an `# escape=` directive) joined, comment and blank lines
+inside them dropped, and here-documents (`RUN <<EOF`) kept as the
+instruction's "heredocs". Leading `--flag=value` options are split off, and
+arguments in the JSON exec form (`["/app/server", "-v"]`) are kept as a
+list besides their text.
+
+Compose files (compose.yaml, docker-compose.*.yml) are read by a small
+YAML reader: block mappings and sequences, flow collections, quoted and
+block scalars; anchors are dropped and aliases and `<<` merges left out.
+Scalars stay strings (null and ~ are None). It is enough for the services
+of a compose file, not YAML at large. Every mapping remembers the line of
+each of its keys.
+
+Stages, base images, what a `go build` writes and which main package a
+container runs are worked out by xray.core.docker_analysis.
+"""
+
+import json
+import os
+import re
+from typing import Any, Dict, List, Optional, Tuple
+
+# File names (lower-cased) that are Dockerfiles, also with a .suffix or as a suffix
+DOCKERFILE_NAMES = ("dockerfile", "containerfile")
+
+_DIRECTIVE = re.compile(r"^#\s*([A-Za-z]+)\s*=\s*(\S+)\s*$")
+_INSTRUCTION = re.compile(r"^([A-Za-z]+)(?:\s+(.*))?$", re.S)
+_HEREDOC = re.compile(r"<<(-?)(['\"]?)([A-Za-z_]\w*)\2")
+_FLAG = re.compile(r"^--([a-z][a-z-]*)(?:=(\S*))?(?:\s+|$)")
+
+
+def is_dockerfile(name: str) -> bool:
+    """Whether a file name is a Dockerfile's: Dockerfile, Dockerfile.dev, api.Dockerfile, Containerfile."""
+    name = name.lower()
+    return any(name == n or name.startswith(n + ".") or name.endswith("." + n) for n in DOCKERFILE_NAMES)
+
+
+def parse_dockerfile(content: str) -> Dict[str, Any]:
+    """
+    Parse a Dockerfile.
+
+    Returns:
+        {"instructions", "escape"}; each instruction is {"instruction"
+        (upper-cased), "line", "end_line", "flags", "arguments"} plus "exec"
+        for the JSON form and "heredocs" [{"name", "body"}]
+    """
+    lines = content.replace("\r\n", "\n").split("\n")
+    escape = "\\"
+    for raw in lines:
+        directive = _DIRECTIVE.match(raw.strip())
+        if not directive:
+            break
+        if directive.group(1).lower() == "escape" and directive.group(2) in ("\\", "`"):
+            escape = directive.group(2)
+
+    instructions: List[Dict[str, Any]] = []
+    i = 0
+    while i < len(lines):
+        stripped = lines[i].strip()
+        if not stripped or stripped.startswith("#"):
+            i += 1
+            continue
+        first = i + 1
+        parts = []
+        text = stripped
+        while text.endswith(escape) and i + 1 < len(lines):
+            parts.append(text[:-1].strip())
+            i += 1
+            # Comment and blank lines inside a continuation are not part of it
+            while i + 1 < len(lines) and (not lines[i].strip() or lines[i].lstrip().startswith("#")):
+                i += 1
+            text = lines[i].strip()
+        parts.append(text[:-1].strip() if text.endswith(escape) else text)
+        i += 1
+        match = _INSTRUCTION.match(" ".join(p for p in parts if p))
+        if not match:
+            continue
+        keyword = match.group(1).upper()
+        rest = (match.group(2) or "").strip()
+        entry: Dict[str, Any] = {"instruction": keyword, "line": first, "end_line": i, "flags": {}}
+        while True:
+            flag = _FLAG.match(rest)
+            if not flag:
+                break
+            entry["flags"][flag.group(1)] = flag.group(2) if flag.group(2) is not None else True
+            rest = rest[flag.end():]
+        entry["arguments"] = rest
+        if rest.startswith("["):
+            try:
+                words = json.loads(rest)
+            except ValueError:
+                words = None
+            if isinstance(words, list) and all(isinstance(w, str) for w in words):
+                entry["exec"] = words
+        if keyword in ("RUN", "COPY", "ADD"):
+            heredocs = []
+            for heredoc in _HEREDOC.finditer(rest):
+                body = []
+                while i < len(lines):
+                    line = lines[i].lstrip("\t") if heredoc.group(1) else lines[i]
+                    i += 1
+                    if line.rstrip() == heredoc.group(3):
+                        break
+                    body.append(line)
+                heredocs.append({"name": heredoc.group(3), "body": "\n".join(body)})
+            if heredocs:
+                entry["heredocs"] = heredocs
+                entry["end_line"] = i
+        instructions.append(entry)
+    return {"instructions": instructions, "escape": escape}
+
+
+# Compose files
+
+class YamlMap(dict):
+    """A YAML mapping that remembers the line of each of its keys."""
+
+    def __init__(self):
+        super().__init__()
+        self.lines: Dict[str, int] = {}
+
+
+def _strip_comment(text: str) -> str:
+    quote = None
+    for i, c in enumerate(text):
+        if quote:
+            if c == quote:
+                quote = None
+        elif c in "'\"" and (i == 0 or text[i - 1] in " \t:[{,-"):
+            quote = c
+        elif c == "#" and (i == 0 or text[i - 1] in " \t"):
+            return text[:i]
+    return text
+
+
+def _unquote(text: str) -> Optional[str]:
+    if len(text) >= 2 and text[0] == text[-1] == "'":
+        return text[1:-1].replace("''", "'")
+    if len(text) >= 2 and text[0] == text[-1] == '"':
+        try:
+            return json.loads(text)
+        except ValueError:
+            return text[1:-1]
+    if text in ("", "~", "null", "Null", "NULL"):
+        return None
+    return text
+
+
+def _key(text: str) -> Optional[Tuple[str, str]]:
+    """(key, value text) of a `key: value` line, or None if it is not one."""
+    if text[:1] in ("'", '"'):
+        end = text.find(text[0], 1)
+        while end > 0 and text[0] == "'" and text[end + 1:end + 2] == "'":
+            end = text.find("'", end + 2)
+        if end < 0 or text[end + 1:end + 2] != ":" or text[end + 2:end + 3] not in ("", " ", "\t"):
+            return None
+        return _unquote(text[:end + 1]) or "", text[end + 2:].strip()
+    if text[:1] in ("[", "{", "-", "&", "*", "|", ">"):
+        return None
+    match = re.search(r":(?:\s|$)", text)
+    if not match:
+        return None
+    return text[:match.start()].strip(), text[match.end():].strip()
+
+
+class _FlowReader:
+    """A flow collection: [a, "b", {k: v}]."""
+
+    def __init__(self, text: str):
+        self.text = text
+        self.i = 0
+
+    def value(self) -> Any:
+        self._space()
+        c = self.text[self.i:self.i + 1]
+        if c == "[":
+            self.i += 1
+            items = []
+            while not self._closing("]"):
+                start = self.i
+                items.append(self.value())
+                self._comma()
+                if self.i == start:
+                    self.i += 1
+            return items
+        if c == "{":
+            self.i += 1
+            result = YamlMap()
+            while not self._closing("}"):
+                start = self.i
+                key = self.value()
+                self._space()
+                item = None
+                if self.text[self.i:self.i + 1] == ":":
+                    self.i += 1
+                    item = self.value()
+                result[str(key)] = item
+                self._comma()
+                if self.i == start:
+                    self.i += 1
+            return result
+        if c in ("'", '"'):
+            end = self.text.find(c, self.i + 1)
+            while end > 0 and c == '"' and self.text[end - 1] == "\\":
+                end = self.text.find(c, end + 1)
+            end = len(self.text) - 1 if end < 0 else end
+            scalar = self.text[self.i:end + 1]
+            self.i = end + 1
+            return _unquote(scalar)
+        match = re.compile(r"[^,\[\]{}]*?(?=\s*(?:[,\]}]|:\s|$))").match(self.text, self.i)
+        scalar = match.group(0) if match else ""
+        self.i += max(len(scalar), 1 if not match else 0)
+        return _unquote(scalar.strip())
+
+    def _space(self):
+        while self.i < len(self.text) and self.text[self.i] in " \t\n":
+            self.i += 1
+
+    def _closing(self, bracket: str) -> bool:
+        self._space()
+        if self.i >= len(self.text) or self.text[self.i] == bracket:
+            self.i += 1
+            return True
+        return False
+
+    def _comma(self):
+        self._space()
+        if self.text[self.i:self.i + 1] == ",":
+            self.i += 1
+
+
+class _YamlReader:
+    def __init__(self, content: str):
+        # [line number, indent, text without its comment]
+        self.lines: List[List[Any]] = []
+        for number, raw in enumerate(content.replace("\r\n", "\n").split("\n"), 1):
+            if raw.strip() in ("---", "..."):
+                if self.lines:
+                    break
+                continue
+            text = _strip_comment(raw).rstrip()
+            if text.strip():
+                self.lines.append([number, len(text) - len(text.lstrip()), text.strip()])
+        self.i = 0
+
+    def block(self, indent: int) -> Any:
+        if self.i >= len(self.lines) or self.lines[self.i][1] < indent:
+            return None
+        _, actual, text = self.lines[self.i]
+        if text == "-" or text.startswith("- "):
+            return self.sequence(actual)
+        return self.mapping(actual)
+
+    def sequence(self, indent: int) -> List[Any]:
+        items: List[Any] = []
+        while self.i < len(self.lines) and self.lines[self.i][1] == indent:
+            number, _, text = self.lines[self.i]
+            if not (text == "-" or text.startswith("- ")):
+                break
+            rest = text[1:].strip()
+            if not rest:
+                self.i += 1
+                items.append(self.block(indent + 1))
+            elif _key(rest) is not None:
+                # "- name: x" opens a mapping at the column of "name"
+                self.lines[self.i] = [number, indent + len(text) - len(rest), rest]
+                items.append(self.mapping(indent + len(text) - len(rest)))
+            else:
+                self.i += 1
+                items.append(self.scalar(rest, indent))
+        return items
+
+    def mapping(self, indent: int) -> YamlMap:
+        result = YamlMap()
+        while self.i < len(self.lines) and self.lines[self.i][1] == indent:
+            number, _, text = self.lines[self.i]
+            split = _key(text)
+            self.i += 1
+            if split is None:
+                continue
+            key, value = split
+            if value.startswith("&"):
+                value = value.partition(" ")[2].strip()
+            if value:
+                item = self.scalar(value, indent)
+            elif self.i < len(self.lines) and (self.lines[self.i][1] > indent or (
+                    self.lines[self.i][1] == indent and self.lines[self.i][2][:1] == "-")):
+                item = self.block(self.lines[self.i][1])
+            else:
+                item = None
+            if key != "<<":
+                result[key] = item
+                result.lines[key] = number
+        return result
+
+    def scalar(self, value: str, indent: int) -> Any:
+        if value.startswith("*"):
+            return None
+        if value[:1] in ("|", ">"):
+            body = []
+            while self.i < len(self.lines) and self.lines[self.i][1] > indent:
+                body.append(self.lines[self.i][2])
+                self.i += 1
+            return ("\n" if value[0] == "|" else " ").join(body)
+        if value[:1] in ("[", "{"):
+            # A flow collection may go on over the following lines
+            while self.i < len(self.lines) and _unbalanced(value):
+                value += " " + self.lines[self.i][2]
+                self.i += 1
+            return _FlowReader(value).value()
+        return _unquote(value)
+
+
+def _unbalanced(text: str) -> bool:
+    depth = 0
+    quote = None
+    for c in text:
+        if quote:
+            quote = None if c == quote else quote
+        elif c in "'\"":
+            quote = c
+        elif c in "[{":
+            depth += 1
+        elif c in "]}":
+            depth -= 1
+    return depth > 0
+
+
+def read_yaml(content: str) -> Any:
+    """The first document of a YAML file: YamlMap, list, string or None."""
+    return _YamlReader(content).block(0)
+
+
+def compose_dockerfile(compose_path: str, build: Any) -> Optional[Tuple[str, str, Optional[str]]]:
+    """(context directory, Dockerfile path, target stage) of a compose service's build, or None without one."""
+    if isinstance(build, str):
+        build = {"context": build}
+    if not isinstance(build, dict):
+        return None
+    context = os.path.normpath(os.path.join(os.path.dirname(compose_path), build.get("context") or "."))
+    dockerfile = os.path.normpath(os.path.join(context, build.get("dockerfile") or "Dockerfile"))
+    return context, dockerfile, build.get("target")
@@ -0,0 +1,167 @@
+"""Structured errors - what a failed tool call returns instead of a bare message.
+
+A tool that fails returns {"error": {"code", "message", "path", "ref"}}: a
+code from ERROR_CODES a client can branch on, the message a person reads,
+and the file or git ref involved when there is one (a changed symbol ID
+adds "closest_match", an ambiguous name "candidates", see
+xray.core.symbol_ids). A call that works for
+most of its input - an index missing the few files that could not be read
+or parsed - returns its result with objects of the same shape under
+"warnings" instead of failing.
+
+Exceptions carry their code in an `error_code` class attribute (XRayError
+and its subclasses, IndexingCancelled, GitError, GoSyntaxError...); others
+are classified by type.
+"""
+
+from typing import Any, Dict, List, Optional
+
+INVALID_ARGUMENT = "INVALID_ARGUMENT"
+PROJECT_NOT_INDEXED = "PROJECT_NOT_INDEXED"
+FILE_NOT_FOUND = "FILE_NOT_FOUND"
+PATH_NOT_ALLOWED = "PATH_NOT_ALLOWED"
+SYMBOL_NOT_FOUND = "SYMBOL_NOT_FOUND"
+SYMBOL_CHANGED = "SYMBOL_CHANGED"
+AMBIGUOUS_SYMBOL = "AMBIGUOUS_SYMBOL"
+PARSE_ERROR = "PARSE_ERROR"
+GIT_ERROR = "GIT_ERROR"
+GIT_UNAVAILABLE = "GIT_UNAVAILABLE"
+UNSUPPORTED_LANGUAGE = "UNSUPPORTED_LANGUAGE"
+INVALID_CONFIG = "INVALID_CONFIG"
+CANCELLED = "CANCELLED"
+TIMEOUT = "TIMEOUT"
+BUDGET_EXCEEDED = "BUDGET_EXCEEDED"
+INTERNAL_ERROR = "INTERNAL_ERROR"
+
+ERROR_CODES = {
+    INVALID_ARGUMENT: "A parameter is missing, unknown or malformed",
+    PROJECT_NOT_INDEXED: "No project of that name, or no root_path and no project added",
+    FILE_NOT_FOUND: "A path does not exist, cannot be read or is not an indexed source file",
+    PATH_NOT_ALLOWED: "A path resolves outside the directories the server was started with (--allow-dir)",
+    SYMBOL_NOT_FOUND: "No declaration matches the symbol",
+    SYMBOL_CHANGED: "No declaration has the symbol ID any more; closest_match is the likeliest one now",
+    AMBIGUOUS_SYMBOL: "A name matches declarations in several packages, files or receivers; candidates lists them",
+    PARSE_ERROR: "A file or expression could not be parsed",
+    GIT_ERROR: "A git command failed - an unknown ref, a missing object, a repository git refuses to read",
+    GIT_UNAVAILABLE: "The tool needs git history, and the project is in no git repository or git is not installed",
+    UNSUPPORTED_LANGUAGE: "The tool does not handle the file's language",
+    INVALID_CONFIG: "The project's .xray.yaml (or .xray.json) has an unknown key or a malformed value",
+    CANCELLED: "The request was cancelled",
+    TIMEOUT: "The call ran past its timeout_ms; the work done so far was discarded",
+    BUDGET_EXCEEDED: "A batch result did not fit what was left of the batch's max_tokens",
+    INTERNAL_ERROR: "Anything else; a bug worth reporting",
+}
+
+
+class XRayError(ValueError):
+    """A tool failure with a code, and the path or ref it is about."""
+
+    error_code = INVALID_ARGUMENT
+
+    def __init__(self, message: str, path: Optional[str] = None, ref: Optional[str] = None):
+        super().__init__(message)
+        self.path = path
+        self.ref = ref
+
+
+class ProjectNotIndexed(XRayError):
+    """A project name nothing is registered under, or no project to default to."""
+
+    error_code = PROJECT_NOT_INDEXED
+
+
+class FileNotFound(XRayError):
+    """A path that does not exist or is not an indexed source file."""
+
+    error_code = FILE_NOT_FOUND
+
+
+class PathNotAllowed(XRayError):
+    """A path outside the allowed directories, after resolving symlinks and .."""
+
+    error_code = PATH_NOT_ALLOWED
+
+
+class SymbolNotFound(XRayError):
+    """A symbol no declaration matches."""
+
+    error_code = SYMBOL_NOT_FOUND
+
+
+class SymbolChanged(SymbolNotFound):
+    """A symbol ID whose declaration changed signature, moved or was renamed; closest is its likeliest successor."""
+
+    error_code = SYMBOL_CHANGED
+
+    def __init__(self, message: str, closest: Dict[str, Any], path: Optional[str] = None):
+        super().__init__(message, path)
+        self.closest = closest
+
+
+class AmbiguousSymbol(XRayError):
+    """A name several declarations answer to; candidates lists them (symbol_id, package, kind, signature...)."""
+
+    error_code = AMBIGUOUS_SYMBOL
+
+    def __init__(self, message: str, candidates: List[Dict[str, Any]]):
+        super().__init__(message)
+        self.candidates = candidates
+
+
+class UnsupportedLanguage(XRayError):
+    """A file in a language the tool does not handle."""
+
+    error_code = UNSUPPORTED_LANGUAGE
+
+
+class InvalidConfig(XRayError):
+    """A project configuration file that does not validate; path is the file."""
+
+    error_code = INVALID_CONFIG
+
+
+class DeadlineExceeded(XRayError):
+    """A tool call that ran past its timeout."""
+
+    error_code = TIMEOUT
+
+
+class IndexingCancelled(Exception):
+    """Raised when indexing, a git command or an analysis is stopped through a cancel event."""
+
+    error_code = CANCELLED
+
+
+def error_code(exc: BaseException) -> str:
+    """The ERROR_CODES entry for an exception."""
+    code = getattr(type(exc), "error_code", None)
+    if code in ERROR_CODES:
+        return code
+    if isinstance(exc, UnicodeDecodeError):
+        return PARSE_ERROR
+    if isinstance(exc, OSError):
+        return FILE_NOT_FOUND
+    if isinstance(exc, ValueError):
+        return INVALID_ARGUMENT
+    return INTERNAL_ERROR
+
+
+def describe_error(exc: BaseException, action: Optional[str] = None,
+                   path: Optional[str] = None) -> Dict[str, Any]:
+    """
+    An exception as {"code", "message", "path", "ref"}, message prefixed with
+    the action that failed ("Error finding callers: ..."); path and ref are
+    left out when nothing names them.
+    """
+    message = str(exc) or type(exc).__name__
+    info: Dict[str, Any] = {"code": error_code(exc), "message": f"{action}: {message}" if action else message}
+    path = getattr(exc, "path", None) or getattr(exc, "filename", None) or path
+    if path:
+        info["path"] = str(path)
+    if getattr(exc, "ref", None):
+        info["ref"] = exc.ref
+    if getattr(exc, "closest", None):
+        info["closest_match"] = exc.closest
+    if getattr(exc, "candidates", None):
+        info["candidates"] = exc.candidates
+    return info
@@ -0,0 +1,219 @@
+"""The evolution of one symbol through the git history of its file.
+
+The file's history is walked newest first with rename following (git log
+--follow). Every commit's before and after versions of the file are parsed,
+and the symbol is compared between the two: a commit is listed when the
+signature or the body changed, with the body's added and removed lines.
+
+When the older version has no symbol of the tracked name, a symbol that
+disappeared in that commit is looked for: same kind, same receiver (or
+container), and a body at least RENAME_SIMILARITY alike once the names are
+swapped. It is reported as a rename and the walk continues under the old
+name. Without one the commit introduced the symbol and the walk ends there;
+code moved in from another file therefore shows up as added.
+
+Parsed versions are cached by blob SHA (see xray.core.blob_cache): blobs
+never change, so a file's history is parsed once however often it is asked
+about.
+"""
+
+import difflib
+import re
+from typing import Any, Callable, Dict, List, Optional
+
+from xray.core.blob_cache import UNPARSEABLE, BlobCache
+from xray.core.git_history import GitRepo, iso_date
+from xray.core.parse_pool import parse_source
+from xray.core.source_text import source_lines
+
+# How alike two bodies must be (difflib ratio, names swapped) to count as one symbol renamed
+RENAME_SIMILARITY = 0.75
+
+_WORD = r"\b{}\b"
+
+
+def _qualified(symbol: Dict[str, Any]) -> str:
+    if symbol.get("receiver"):
+        return f"{symbol['receiver']['type']}.{symbol['name']}"
+    if symbol.get("container"):
+        return f"{symbol['container']}.{symbol['name']}"
+    return symbol["name"]
+
+
+def _owner(symbol: Dict[str, Any]) -> Optional[str]:
+    return (symbol.get("receiver") or {}).get("type") or symbol.get("container")
+
+
+def _normalized(text: str) -> str:
+    return re.sub(r"\s+", " ", text).strip()
+
+
+def _full_signature(signature: str, text: str, python: bool) -> str:
+    """
+    The untruncated signature: parsers cut long ones to "prefix...", which
+    would hide a change past the cut, so the header is read from the text.
+    """
+    if not signature.endswith("..."):
+        return signature
+    flat = _normalized(text)
+    start = flat.find(_normalized(signature[:-3]))
+    if start < 0:
+        return signature
+    depth = 0
+    for i in range(start, len(flat)):
+        char = flat[i]
+        if char in "([":
+            depth += 1
+        elif char in ")]":
+            depth -= 1
+        elif depth == 0 and (char == ":" if python else char in "{;"):
+            return flat[start:i]
+    return flat[start:]
+
+
+def version_table(path: str, content: str) -> Dict[str, Dict[str, Any]]:
+    """Qualified name -> {name, type, owner, signature, full_signature, text, start_line, end_line} for one version."""
+    lines = source_lines(content)
+    table = {}
+    for symbol in parse_source(path, content)["symbols"]:
+        if symbol["type"] == "field":
+            continue
+        text = "\n".join(lines[symbol["start_line"] - 1:symbol["end_line"]])
+        table.setdefault(_qualified(symbol), {
+            "name": symbol["name"],
+            "type": symbol["type"],
+            "owner": _owner(symbol),
+            "signature": symbol.get("signature", ""),
+            "full_signature": _full_signature(symbol.get("signature", ""), text, path.endswith(".py")),
+            "text": text,
+            "start_line": symbol["start_line"],
+            "end_line": symbol["end_line"],
+        })
+    return table
+
+
+def _diff_stat(old: str, new: str) -> Dict[str, int]:
+    added = removed = 0
+    for line in difflib.unified_diff([l.rstrip() for l in old.splitlines()], [l.rstrip() for l in new.splitlines()],
+                                     lineterm="", n=0):
+        if line.startswith("+") and not line.startswith("+++"):
+            added += 1
+        elif line.startswith("-") and not line.startswith("---"):
+            removed += 1
+    return {"added": added, "removed": removed}
+
+
+def _renamed_from(symbol: Dict[str, Any], old: Dict[str, Dict[str, Any]],
+                  new: Dict[str, Dict[str, Any]]) -> Optional[str]:
+    """The symbol of the older version that `symbol` is a renamed copy of, if any."""
+    best, best_ratio = None, RENAME_SIMILARITY
+    for name, candidate in old.items():
+        if name in new or candidate["type"] != symbol["type"] or candidate["owner"] != symbol["owner"]:
+            continue
+        swapped = re.sub(_WORD.format(re.escape(candidate["name"])), symbol["name"], candidate["text"])
+        matcher = difflib.SequenceMatcher(None, _normalized(swapped), _normalized(symbol["text"]), autojunk=False)
+        if matcher.real_quick_ratio() < best_ratio or matcher.quick_ratio() < best_ratio:
+            continue
+        ratio = matcher.ratio()
+        if ratio >= best_ratio:
+            best, best_ratio = name, ratio
+    return best
+
+
+def symbol_history(repo: GitRepo, relpath: str, name: str, max_commits: Optional[int] = 50,
+                   rev: Optional[str] = None, cache: Optional[BlobCache] = None,
+                   progress: Optional[Callable[[int, int, str], None]] = None) -> Dict[str, Any]:
+    """
+    List the commits that changed one symbol, newest first.
+
+    Args:
+        repo: The repository
+        relpath: The file as of rev (or the working tree), relative to the repository root
+        name: The qualified name ("Type.Method") in that version
+        max_commits: Only walk this many most recent commits of the file
+        rev: Walk back from this commit instead of HEAD
+        cache: Where parsed versions are cached (version tables, under "versions")
+        progress: Called as (done, total, commit) per commit
+
+    Returns:
+        {"history": [...], "file_renames", "commits_scanned", "introduced_in" (or None when
+         the walk stopped first), "truncated", "original_name"}
+    """
+    cache = BlobCache() if cache is None else cache
+
+    def parse(blob: str, path: str) -> Any:
+        content = repo.blob(blob)
+        if content is None:
+            return None
+        try:
+            return version_table(path, content)
+        except Exception:
+            # A version that does not parse (e.g. a merge conflict committed by mistake)
+            return UNPARSEABLE
+
+    def table(blob: Optional[str], path: str) -> Optional[Dict[str, Dict[str, Any]]]:
+        if blob is None:
+            return {}
+        parsed = cache.get("versions", blob, path, lambda: parse(blob, path))
+        return None if parsed is None or parsed == UNPARSEABLE else parsed
+
+    commits = repo.file_log(relpath, max_commits, rev)
+    history: List[Dict[str, Any]] = []
+    file_renames: List[Dict[str, str]] = []
+    introduced = None
+    tracked: Optional[str] = name
+    for done, commit in enumerate(commits, 1):
+        if progress:
+            progress(done, len(commits), commit["commit"][:12])
+        if commit["old_path"] != commit["path"]:
+            file_renames.append({"commit": commit["commit"], "from": commit["old_path"], "to": commit["path"]})
+        new = table(commit["blob"], commit["path"])
+        old = table(commit["old_blob"], commit["old_path"])
+        if new is None or old is None or tracked not in new:
+            # Unparseable, or the symbol is not in this version under the tracked name: it
+            # only exists in later (or uncommitted) versions, so look further back by name
+            continue
+        after = new[tracked]
+        entry: Dict[str, Any] = {
+            "commit": commit["commit"],
+            "author": commit["author"],
+            "author_email": commit["author_email"],
+            "date": iso_date(commit["timestamp"]),
+            "summary": commit["summary"],
+            "path": commit["path"],
+        }
+        if commit["old_path"] != commit["path"]:
+            entry["file_renamed_from"] = commit["old_path"]
+        before_name = tracked if tracked in old else _renamed_from(after, old, new)
+        if before_name is None:
+            entry.update({"change": "added", "new_signature": after["full_signature"],
+                          "diff": _diff_stat("", after["text"])})
+            history.append(entry)
+            introduced = commit["commit"]
+            break
+        before = old[before_name]
+        # A rename alone does not change the signature
+        old_signature = re.sub(_WORD.format(re.escape(before["name"])), after["name"], before["full_signature"])
+        signature_changed = _normalized(old_signature) != _normalized(after["full_signature"])
+        if before_name == tracked and not signature_changed and _normalized(before["text"]) == _normalized(after["text"]):
+            continue
+        entry.update({
+            "change": "renamed" if before_name != tracked else "signature_changed" if signature_changed
+            else "body_changed",
+            "old_signature": before["full_signature"],
+            "new_signature": after["full_signature"],
+            "diff": _diff_stat(before["text"], after["text"]),
+        })
+        if before_name != tracked:
+            entry["renamedFrom"] = before_name
+            entry["signature_changed"] = signature_changed
+            tracked = before_name
+        history.append(entry)
+    return {
+        "history": history,
+        "file_renames": file_renames,
+        "commits_scanned": len(commits),
+        "introduced_in": introduced,
+        "truncated": introduced is None and bool(max_commits) and len(commits) >= max_commits,
+        "original_name": tracked,
+    }
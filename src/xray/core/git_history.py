@@ -0,0 +1,657 @@
+"""Thin git wrapper used by XRAY's history-aware tools.
+
+Everything goes through the git CLI with porcelain output formats, the same
+way the indexer shells out to rg and ast-grep, so no Python git bindings are
+needed.
+
+CI checkouts are often shallow and partial-clone filtered, and mirrors are
+bare, so none of that is an error:
+
+- A shallow clone's history stops at its boundary commits, whose parents
+  were never fetched. Their diff is against nothing - every file would read
+  as added in them - so history walks stop short of them, and a walk that
+  reaches them sets truncated_history: what is returned is all the clone
+  has, not all there was. Blame attributes the older lines to a boundary
+  commit, which it marks the same way.
+- A partial clone (--filter=blob:none) leaves out blobs. git would fetch
+  each one from the remote as a command needs it, one round trip at a time;
+  unless fetch_missing is set that is turned off (GIT_NO_LAZY_FETCH), and
+  what needs a missing blob is skipped and recorded in missing: line counts
+  go unknown, a commit's hunks in that file are left out, renames are not
+  followed.
+- A bare repository has no work tree; root is its git directory, and only
+  what reads commits (show, export, log and blame at a ref) works there.
+"""
+
+import io
+import os
+import re
+import shutil
+import subprocess
+import tarfile
+import threading
+from datetime import datetime, timezone
+from typing import Dict, List, Optional, Any, Set, Tuple
+
+from xray.core.errors import GIT_ERROR, GIT_UNAVAILABLE, IndexingCancelled
+from xray.core.source_text import decode_source, source_lines
+
+_HUNK = re.compile(r"^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@")
+_HUNK_BOTH = re.compile(r"^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@")
+
+# What git prints when an object a partial clone left out is needed and not fetched
+_MISSING = re.compile(r"from promisor remote|lazy fetching disabled|missing blob object")
+
+# Seconds between checks of the cancel event while a git command runs
+CANCEL_POLL = 0.1
+# Missing objects reported one by one; the rest are counted in a last warning
+MAX_MISSING_WARNINGS = 20
+
+
+def run_cancellable(cmd: List[str], cwd: Optional[str] = None, cancel: Optional[threading.Event] = None,
+                    text: bool = True, env: Optional[Dict[str, str]] = None) -> subprocess.CompletedProcess:
+    """
+    subprocess.run with captured output, except that the command is killed
+    as soon as the cancel event is set, raising IndexingCancelled.
+    """
+    process = subprocess.Popen(cmd, cwd=cwd, stdout=subprocess.PIPE, stderr=subprocess.PIPE, env=env,
+                               **({"text": True, "errors": "replace"} if text else {}))
+    while True:
+        try:
+            stdout, stderr = process.communicate(timeout=CANCEL_POLL if cancel is not None else None)
+            return subprocess.CompletedProcess(process.args, process.returncode, stdout, stderr)
+        except subprocess.TimeoutExpired:
+            # Retrying communicate() loses no output
+            if cancel.is_set():
+                process.kill()
+                process.communicate()
+                raise IndexingCancelled(f"{os.path.basename(cmd[0])} {cmd[1]} was cancelled")
+
+
+class GitError(Exception):
+    """Raised when a git command fails or the path is not inside a repository."""
+
+    error_code = GIT_ERROR
+
+    def __init__(self, message: str, path: Optional[str] = None, ref: Optional[str] = None):
+        super().__init__(message)
+        self.path = path
+        self.ref = ref
+
+
+class GitUnavailable(GitError):
+    """
+    Raised when there is no git to ask: the path is in no repository (an
+    exported tarball, a build sandbox) or git is not installed. Only the
+    history tools need git; everything else works on the files alone.
+    """
+
+    error_code = GIT_UNAVAILABLE
+
+
+def is_bare_repository(path: str) -> bool:
+    """Whether a directory looks like a bare repository (a mirror, say): a git directory with no work tree."""
+    return os.path.isfile(os.path.join(path, "HEAD")) and os.path.isdir(os.path.join(path, "objects")) and \
+        not os.path.exists(os.path.join(path, ".git"))
+
+
+class GitRepo:
+    """A git work tree, addressed by any directory inside it, or a bare repository."""
+
+    def __init__(self, path: str, cancel: Optional[threading.Event] = None, fetch_missing: bool = False):
+        self.path = str(path)
+        # Setting it kills the git command in progress, which raises IndexingCancelled
+        self.cancel = cancel
+        # Let git fetch the blobs a partial clone left out, as commands need them
+        self.fetch_missing = fetch_missing
+        # What was skipped for want of a blob, as xray.core.errors warnings
+        self.missing: List[Dict[str, Any]] = []
+        self._missing_count = 0
+        # Set once a history walk reaches the boundary of a shallow clone
+        self.truncated_history = False
+        self._boundaries: Optional[Set[str]] = None
+        self._cwd = self.path
+        result = self._exec(["rev-parse", "--is-shallow-repository", "--show-toplevel"])
+        if result.returncode == 0:
+            shallow, self.root = result.stdout.splitlines()[:2]
+            self.bare = False
+        else:
+            probe = self._exec(["rev-parse", "--is-bare-repository", "--is-shallow-repository", "--absolute-git-dir"])
+            lines = probe.stdout.splitlines()
+            if probe.returncode != 0 or not lines or lines[0] != "true":
+                if "not a git repository" in result.stderr:
+                    raise GitUnavailable(f"{self.path} is not inside a git repository (no .git there or in any "
+                                         "parent directory); only history tools need one", self.path)
+                raise GitError(result.stderr.strip() or "git rev-parse failed", self.path)
+            _, shallow, self.root = lines[:3]
+            self.bare = True
+        self.shallow = shallow == "true"
+        # Paths given to and printed by git are relative to the top of the work tree, wherever path is in it
+        if not self.bare:
+            self._cwd = self.root
+        # Where in the work tree path is: "." at its top, "services/api" for a project in a subdirectory
+        self.scope = "." if self.bare else self.relpath(self.path)
+
+    def _exec(self, args: List[str], text: bool = True) -> subprocess.CompletedProcess:
+        env = None if self.fetch_missing else {**os.environ, "GIT_NO_LAZY_FETCH": "1"}
+        try:
+            return run_cancellable(["git", *args], self._cwd, self.cancel, text, env)
+        except FileNotFoundError:
+            raise GitUnavailable("git is not installed; only history tools need it", self.path)
+
+    def run(self, *args: str, check: bool = True) -> str:
+        """Run a git command in the repository and return its stdout."""
+        result = self._exec(list(args))
+        if check and result.returncode != 0:
+            raise GitError(result.stderr.strip() or f"git {args[0]} failed", self.path)
+        return result.stdout
+
+    def shallow_commits(self) -> Set[str]:
+        """The boundary commits of a shallow clone: history stops there, their parents were not fetched."""
+        if self._boundaries is None:
+            self._boundaries = set()
+            if self.shallow:
+                path = self.run("rev-parse", "--git-path", "shallow").strip()
+                try:
+                    with open(os.path.join(self._cwd, path), "r", encoding="ascii") as f:
+                        self._boundaries = {line.strip() for line in f if line.strip()}
+                except OSError:
+                    pass
+        return self._boundaries
+
+    def _shallow_hint(self) -> str:
+        if not self.shallow:
+            return ""
+        return " (this is a shallow clone: fetch more history with git fetch --deepen=N or --unshallow)"
+
+    def _note_missing(self, what: str, path: Optional[str] = None, ref: Optional[str] = None):
+        """Record something skipped because a blob is missing from the partial clone."""
+        self._missing_count += 1
+        if len(self.missing) < MAX_MISSING_WARNINGS:
+            warning: Dict[str, Any] = {"code": GIT_ERROR, "message": f"{what}: not in this partial clone "
+                                       "(start the server with --fetch-missing to fetch missing blobs)"}
+            if path:
+                warning["path"] = path
+            if ref:
+                warning["ref"] = ref
+            self.missing.append(warning)
+
+    def warnings(self) -> List[Dict[str, Any]]:
+        """The missing-blob warnings, the ones past MAX_MISSING_WARNINGS as a count."""
+        extra = self._missing_count - len(self.missing)
+        if extra <= 0:
+            return list(self.missing)
+        return [*self.missing, {"code": GIT_ERROR, "message": f"{extra} more skipped for blobs missing from "
+                                "this partial clone"}]
+
+    def _walk(self, args: List[str], rev: Optional[str], pathspecs: Optional[List[str]]) -> List[str]:
+        """
+        A log walk's arguments, from rev (HEAD by default), stopping short of
+        the boundary commits of a shallow clone - and setting
+        truncated_history if the walk would otherwise have reached one.
+        """
+        specs = ["--", *pathspecs] if pathspecs else []
+        boundaries = self.shallow_commits()
+        if not boundaries:
+            return [*args, *([rev] if rev else []), *specs]
+        listed = self.run("log", "--format=%H", *[a for a in args if a.startswith(("--max-count", "--since",
+                                                                                     "--no-merges", "--follow"))],
+                          rev or "HEAD", *specs)
+        if boundaries & set(listed.split()):
+            self.truncated_history = True
+        return [*args, rev or "HEAD", *(f"^{sha}" for sha in sorted(boundaries)), *specs]
+
+    def head(self) -> Dict[str, Any]:
+        """Where HEAD is: its commit (None before the first commit) and branch (None when detached)."""
+        commit = self.run("rev-parse", "--verify", "--quiet", "HEAD^{commit}", check=False).strip() or None
+        branch = self.run("symbolic-ref", "--quiet", "--short", "HEAD", check=False).strip() or None
+        return {"commit": commit, "branch": branch, "detached": commit is not None and branch is None}
+
+    def scoped(self, pattern: str) -> str:
+        """A pathspec from the root for pattern as seen from path (scoped("*.go") is "services/api/*.go")."""
+        return pattern if self.scope == "." else f"{self.scope}/{pattern}"
+
+    def relpath(self, path: str) -> str:
+        """Return a path relative to the repository root, with forward slashes."""
+        return os.path.relpath(os.path.realpath(path), os.path.realpath(self.root)).replace(os.sep, "/")
+
+    def abspath(self, relpath: str) -> str:
+        return os.path.join(self.root, *relpath.split("/"))
+
+    def resolve(self, ref: str) -> str:
+        """Resolve a branch, tag or abbreviated SHA to a full commit SHA."""
+        try:
+            return self.run("rev-parse", "--verify", f"{ref}^{{commit}}").strip()
+        except GitError as e:
+            raise GitError(str(e) + self._shallow_hint(), self.path, ref) from None
+
+    def merge_base(self, a: str, b: str) -> str:
+        """The best common ancestor of two commits, where a branch forked off."""
+        try:
+            return self.run("merge-base", a, b).strip()
+        except GitError:
+            raise GitError(f"{a[:12]} and {b[:12]} have no common ancestor" + self._shallow_hint(), self.path, a) from None
+
+    def show(self, ref: str, relpath: str) -> Optional[str]:
+        """
+        Return a file's content at a ref straight from the object database, or
+        None; decoded and with line endings translated as working-tree files are
+        (see xray.core.source_text), so CRLF files parse to the same positions.
+        """
+        result = self._exec(["cat-file", "-p", f"{ref}:{relpath}"], text=False)
+        if result.returncode != 0 and _MISSING.search(result.stderr.decode(errors="replace")):
+            self._note_missing(f"{relpath} at {ref[:12]}", relpath, ref)
+        return decode_source(result.stdout)[0] if result.returncode == 0 else None
+
+    def export(self, sha: str, relpath: str, dest: str) -> None:
+        """
+        Write the tree of a commit (or one of its subdirectories) to dest.
+
+        Contents come straight from the object database via git archive, so
+        untracked and modified working-tree files never leak in. The tree is
+        extracted next to dest and renamed into place, so a snapshot is
+        either complete or absent.
+        """
+        treeish = sha if relpath == "." else f"{sha}:{relpath}"
+        result = self._exec(["archive", "--format=tar", treeish], text=False)
+        if result.returncode != 0:
+            raise GitError(result.stderr.decode(errors="replace").strip() or "git archive failed", self.path, sha)
+        staging = f"{dest}.tmp-{os.getpid()}"
+        os.makedirs(staging, exist_ok=True)
+        try:
+            with tarfile.open(fileobj=io.BytesIO(result.stdout)) as archive:
+                if hasattr(tarfile, "data_filter"):
+                    archive.extractall(staging, filter="data")
+                else:
+                    # Python without extraction filters (before 3.10.12)
+                    archive.extractall(staging)
+            os.makedirs(os.path.dirname(dest), exist_ok=True)
+            os.replace(staging, dest)
+        except OSError:
+            shutil.rmtree(staging, ignore_errors=True)
+            if not os.path.isdir(dest):
+                raise
+
+    def changed_files(self, base: str, head: str, pathspecs: Optional[List[str]] = None) -> List[Dict[str, str]]:
+        """
+        List files that differ between two refs, with rename detection.
+
+        Returns:
+            [{"status": "added|deleted|modified|renamed", "path", "old_path", "old_blob", "blob"}],
+            a blob None on the side the file is missing from
+        """
+        args = ["diff", "--raw", "--no-abbrev", "-M", base, head]
+        if pathspecs:
+            args += ["--", *pathspecs]
+        changes = []
+        for raw in self.run(*args).splitlines():
+            if not raw.startswith(":"):
+                continue
+            parts = raw.split("\t")
+            _, _, old_blob, blob, status = parts[0].split(" ")
+            code = status[:1]
+            blobs = {"old_blob": None if is_uncommitted(old_blob) else old_blob,
+                     "blob": None if is_uncommitted(blob) else blob}
+            if code == "R":
+                changes.append({"status": "renamed", "old_path": parts[1], "path": parts[2], **blobs})
+            elif code == "A":
+                changes.append({"status": "added", "old_path": None, "path": parts[1], **blobs})
+            elif code == "D":
+                changes.append({"status": "deleted", "old_path": parts[1], "path": parts[1], **blobs})
+            elif code in ("M", "T", "C"):
+                changes.append({"status": "modified", "old_path": parts[-2] if code == "C" else parts[1],
+                                "path": parts[-1], **blobs})
+        return changes
+
+    def _log_args(self, since: Optional[str], max_commits: Optional[int], include_merges: bool) -> List[str]:
+        args = []
+        if not include_merges:
+            args.append("--no-merges")
+        if since:
+            args.append(f"--since={since}")
+        if max_commits:
+            args.append(f"--max-count={max_commits}")
+        return args
+
+    def log_numstat(self, since: Optional[str] = None, max_commits: Optional[int] = None,
+                    include_merges: bool = False, pathspecs: Optional[List[str]] = None,
+                    mailmap: bool = False) -> List[Dict[str, Any]]:
+        """
+        Walk history and return the files each commit touched.
+
+        With mailmap, authors are the canonical names and emails of the
+        repository's .mailmap (as git blame reports them).
+
+        Returns:
+            [{"commit", "author", "author_email", "timestamp", "files": [{"path", "added", "deleted"}]}]
+            newest first; binary files have None for added/deleted
+        """
+        identity = "%aN%x1f%aE" if mailmap else "%an%x1f%ae"
+        fmt = f"--format=%x00%H%x1f{identity}%x1f%at"
+        window = self._walk(self._log_args(since, max_commits, include_merges), None, pathspecs)
+        result = self._exec(["log", "--no-renames", "--numstat", fmt, *window])
+        numstat = not self._missing_blobs(result)
+        if not numstat:
+            # Line counts need both versions of every file: list the files touched without them
+            self._note_missing("Line counts of the history walk")
+            result = self._exec(["log", "--no-renames", "--raw", "--no-abbrev", fmt, *window])
+        commits = []
+        for record in self._checked(result, "log").split("\x00")[1:]:
+            header, _, body = record.partition("\n")
+            sha, author, email, timestamp = header.split("\x1f")
+            files = []
+            for raw in body.splitlines():
+                parts = raw.split("\t")
+                if not numstat and raw.startswith(":") and len(parts) == 2:
+                    parts = ["-", "-", parts[1]]
+                if len(parts) != 3:
+                    continue
+                added, deleted, path = parts
+                files.append({
+                    "path": path,
+                    "added": int(added) if added.isdigit() else None,
+                    "deleted": int(deleted) if deleted.isdigit() else None,
+                })
+            commits.append({"commit": sha, "author": author, "author_email": email, "timestamp": int(timestamp),
+                            "files": files})
+        return commits
+
+    def file_log(self, relpath: str, max_commits: Optional[int] = None,
+                 rev: Optional[str] = None) -> List[Dict[str, Any]]:
+        """
+        Walk the history of one file, following renames (git log --follow).
+
+        Returns:
+            [{"commit", "author", "author_email", "timestamp", "summary", "status",
+              "path", "old_path", "blob", "old_blob"}] newest first; path is the
+            file's name in that commit, old_path its name before (differs on a
+            rename), and old_blob is None where the commit added the file
+        """
+        fmt = "--format=%x00%H%x1f%aN%x1f%aE%x1f%at%x1f%s"
+        window = self._walk(["--follow", *self._log_args(None, max_commits, False)], rev, [relpath])
+        result = self._exec(["log", "-M", "--raw", "--no-abbrev", fmt, *window])
+        if self._missing_blobs(result):
+            # Rename detection compares contents
+            self._note_missing(f"Renames of {relpath}", relpath, rev)
+            result = self._exec(["log", "--no-renames", "--raw", "--no-abbrev", fmt,
+                                 *[arg for arg in window if arg != "--follow"]])
+        commits = []
+        for record in self._checked(result, "log").split("\x00")[1:]:
+            header, _, body = record.partition("\n")
+            sha, author, email, timestamp, summary = header.split("\x1f", 4)
+            for raw in body.splitlines():
+                if not raw.startswith(":"):
+                    continue
+                meta, *paths = raw.split("\t")
+                _, _, old_blob, blob, status = meta.split(" ")
+                commits.append({
+                    "commit": sha, "author": author, "author_email": email, "timestamp": int(timestamp),
+                    "summary": summary, "status": status[0], "path": paths[-1], "old_path": paths[0],
+                    "blob": None if is_uncommitted(blob) else blob,
+                    "old_blob": None if is_uncommitted(old_blob) else old_blob,
+                })
+                break
+        return commits
+
+    def blob(self, sha: str) -> Optional[str]:
+        """Return the content of a blob, decoded as show does, or None."""
+        result = self._exec(["cat-file", "-p", sha], text=False)
+        if result.returncode != 0 and _MISSING.search(result.stderr.decode(errors="replace")):
+            self._note_missing(f"blob {sha[:12]}")
+        return decode_source(result.stdout)[0] if result.returncode == 0 else None
+
+    def log_hunks(self, since: Optional[str] = None, max_commits: Optional[int] = None,
+                  include_merges: bool = False, pathspecs: Optional[List[str]] = None) -> List[Dict[str, Any]]:
+        """
+        Walk history and return the changed line ranges of each commit.
+
+        Returns:
+            [{"commit", "author", "files": {path: [(new_start, new_count), ...]}, "blobs": {path: blob}}]
+            keyed by the path in that commit; deleted files are omitted
+        """
+        fmt = "--format=%x00%H%x1f%an%x1f%at"
+        window = self._walk(self._log_args(since, max_commits, include_merges), None, pathspecs)
+        result = self._exec(["log", "--no-renames", "-p", "--full-index", "--unified=0", fmt, *window])
+        if self._missing_blobs(result):
+            return self._blob_hunks(fmt, window)
+        commits = []
+        for record in self._checked(result, "log").split("\x00")[1:]:
+            header, _, body = record.partition("\n")
+            sha, author, _timestamp = header.split("\x1f")
+            files: Dict[str, List[Tuple[int, int]]] = {}
+            blobs: Dict[str, str] = {}
+            current = blob = None
+            for raw in body.splitlines():
+                if raw.startswith("diff --git "):
+                    blob = None
+                elif raw.startswith("index "):
+                    blob = raw.split()[1].partition("..")[2]
+                elif raw.startswith("+++ "):
+                    target = raw[4:]
+                    current = None if target == "/dev/null" else target[2:]
+                    if current is not None:
+                        files.setdefault(current, [])
+                        if blob:
+                            blobs[current] = blob
+                elif raw.startswith("@@ ") and current is not None:
+                    match = _HUNK.match(raw)
+                    if match:
+                        start = int(match.group(1))
+                        count = int(match.group(2)) if match.group(2) is not None else 1
+                        files[current].append((start, count))
+            commits.append({"commit": sha, "author": author, "files": files, "blobs": blobs})
+        return commits
+
+    def _blob_hunks(self, fmt: str, window: List[str]) -> List[Dict[str, Any]]:
+        """
+        log_hunks when the walk needs blobs missing from a partial clone: the
+        files each commit touched, diffed one by one, skipping those that
+        cannot be read.
+        """
+        commits = []
+        for record in self.run("log", "--no-renames", "--raw", "--no-abbrev", fmt, *window).split("\x00")[1:]:
+            header, _, body = record.partition("\n")
+            sha, author, _timestamp = header.split("\x1f")
+            files: Dict[str, List[Tuple[int, int]]] = {}
+            blobs: Dict[str, str] = {}
+            for raw in body.splitlines():
+                if not raw.startswith(":"):
+                    continue
+                meta, path = raw.split("\t", 1)
+                _, _, old_blob, blob, status = meta.split(" ")
+                if status.startswith("D"):
+                    continue
+                blobs[path] = blob
+                if is_uncommitted(old_blob):
+                    content = self.blob(blob)
+                    if content is not None:
+                        count = len(source_lines(content))
+                        files[path] = [(1, count)] if count else []
+                    continue
+                diff = self._exec(["diff", "--no-color", "--unified=0", old_blob, blob])
+                if diff.returncode != 0:
+                    self._note_missing(f"Changes to {path} in {sha[:12]}", path, sha)
+                    continue
+                files[path] = [(int(m.group(1)), int(m.group(2)) if m.group(2) is not None else 1)
+                               for m in map(_HUNK.match, diff.stdout.splitlines()) if m]
+            commits.append({"commit": sha, "author": author, "files": files, "blobs": blobs})
+        return commits
+
+    def _missing_blobs(self, result: subprocess.CompletedProcess) -> bool:
+        """Whether a command failed for want of a blob the partial clone does not have."""
+        return result.returncode != 0 and bool(_MISSING.search(result.stderr))
+
+    def _checked(self, result: subprocess.CompletedProcess, command: str) -> str:
+        if result.returncode != 0:
+            raise GitError(result.stderr.strip() or f"git {command} failed", self.path)
+        return result.stdout
+
+    def diff_hunks(self, *diff_args: str, pathspecs: Optional[List[str]] = None) -> Dict[str, Dict[str, Any]]:
+        """
+        Run git diff with zero context and return the hunks of every file.
+
+        Args:
+            diff_args: What to compare, e.g. () for unstaged, ("--cached",) for staged
+            pathspecs: Optional pathspecs to limit the diff to
+
+        Returns:
+            {path: {"status": "added|deleted|modified", "hunks": [(old_start, old_count, new_start, new_count)]}}
+        """
+        args = ["diff", "--no-renames", "--no-color", "--unified=0", *diff_args]
+        if pathspecs:
+            args += ["--", *pathspecs]
+        files: Dict[str, Dict[str, Any]] = {}
+        old_path = None
+        current = None
+        for raw in self.run(*args).splitlines():
+            if raw.startswith("--- "):
+                old_path = None if raw[4:] == "/dev/null" else raw[6:]
+            elif raw.startswith("+++ "):
+                new_path = None if raw[4:] == "/dev/null" else raw[6:]
+                status = "added" if old_path is None else "deleted" if new_path is None else "modified"
+                current = files.setdefault(new_path or old_path, {"status": status, "hunks": []})
+            elif raw.startswith("@@ ") and current is not None:
+                match = _HUNK_BOTH.match(raw)
+                if match:
+                    old_start, old_count, new_start, new_count = match.groups()
+                    current["hunks"].append((
+                        int(old_start), int(old_count) if old_count is not None else 1,
+                        int(new_start), int(new_count) if new_count is not None else 1,
+                    ))
+        return files
+
+    def untracked_files(self, pathspecs: Optional[List[str]] = None) -> List[str]:
+        """List untracked files that are not ignored, relative to the repository root."""
+        args = ["ls-files", "--others", "--exclude-standard", "--full-name"]
+        if pathspecs:
+            args += ["--", *pathspecs]
+        return [line for line in self.run(*args).splitlines() if line]
+
+    def blame(self, relpath: str, start_line: int, end_line: int,
+              rev: Optional[str] = None) -> List[Dict[str, Any]]:
+        """
+        Blame a line range of the working-tree file (or of the file at rev),
+        following moves and copies.
+
+        Returns:
+            One record per line: {"line", "commit", "author", "author_email",
+            "timestamp", "summary", "path", "boundary"} - "path" is the file the
+            line came from, which differs from relpath when the code was moved
+            or renamed; "boundary" marks lines blamed on a shallow clone's
+            boundary commit, which may be older
+        """
+        result = self._exec(["blame", "--porcelain", "-M", "-C", "-L", f"{start_line},{end_line}",
+                             *([rev] if rev else []), "--", relpath])
+        if self._missing_blobs(result):
+            raise GitError(f"Cannot blame {relpath}: its history needs blobs missing from this partial clone "
+                           "(start the server with --fetch-missing to fetch them)", relpath, rev)
+        output = self._checked(result, "blame")
+        boundaries = self.shallow_commits()
+        commits: Dict[str, Dict[str, Any]] = {}
+        lines: List[Dict[str, Any]] = []
+        current: Optional[Dict[str, Any]] = None
+        for raw in output.splitlines():
+            if raw.startswith("\t"):
+                if current is not None:
+                    lines.append(current)
+                current = None
+                continue
+            parts = raw.split(" ")
+            if current is None and len(parts) >= 3 and len(parts[0]) == 40:
+                sha = parts[0]
+                info = commits.setdefault(sha, {"commit": sha})
+                current = {"line": int(parts[2]), "info": info}
+                continue
+            if current is None:
+                continue
+            key, _, value = raw.partition(" ")
+            info = current["info"]
+            if key == "author":
+                info["author"] = value
+            elif key == "author-mail":
+                info["author_email"] = value.strip("<>")
+            elif key == "author-time":
+                info["timestamp"] = int(value)
+            elif key == "summary":
+                info["summary"] = value
+            elif key == "filename":
+                info.setdefault("path", value)
+        records = [
+            {
+                "line": entry["line"],
+                "commit": entry["info"]["commit"],
+                "author": entry["info"].get("author", ""),
+                "author_email": entry["info"].get("author_email", ""),
+                "timestamp": entry["info"].get("timestamp", 0),
+                "summary": entry["info"].get("summary", ""),
+                "path": entry["info"].get("path", relpath),
+                # A shallow clone's oldest commit: the line may be older still
+                "boundary": entry["info"]["commit"] in boundaries,
+            }
+            for entry in lines
+        ]
+        if any(line["boundary"] for line in records):
+            self.truncated_history = True
+        return records
+
+
+def iso_date(timestamp: int) -> str:
+    """Format a unix timestamp as an ISO-8601 UTC date."""
+    return datetime.fromtimestamp(timestamp, tz=timezone.utc).isoformat().replace("+00:00", "Z")
+
+
+def is_uncommitted(sha: str) -> bool:
+    """git blame reports lines that are not committed yet with an all-zero SHA."""
+    return set(sha) == {"0"}
+
+
+def blame_hunks(lines: List[Dict[str, Any]], relpath: str) -> List[Dict[str, Any]]:
+    """Group per-line blame records into contiguous hunks of the same commit."""
+    hunks: List[Dict[str, Any]] = []
+    for entry in lines:
+        last = hunks[-1] if hunks else None
+        if last and last["commit"] == entry["commit"] and last["end_line"] == entry["line"] - 1:
+            last["end_line"] = entry["line"]
+            continue
+        hunk = {
+            "start_line": entry["line"],
+            "end_line": entry["line"],
+            "commit": entry["commit"],
+            "author": entry["author"],
+            "author_email": entry["author_email"],
+            "date": iso_date(entry["timestamp"]) if entry["timestamp"] else None,
+            "summary": entry["summary"],
+        }
+        if is_uncommitted(entry["commit"]):
+            hunk["uncommitted"] = True
+        if entry["path"] != relpath:
+            hunk["original_path"] = entry["path"]
+        if entry.get("boundary"):
+            hunk["truncatedHistory"] = True
+        hunks.append(hunk)
+    return hunks
+
+
+def summarize_blame(lines: List[Dict[str, Any]]) -> Dict[str, Any]:
+    """Return the last modification and the authors ranked by line count."""
+    if not lines:
+        return {"last_modified": None, "primary_author": None, "authors": []}
+    latest = max(lines, key=lambda e: e["timestamp"])
+    counts: Dict[str, int] = {}
+    for entry in lines:
+        counts[entry["author"]] = counts.get(entry["author"], 0) + 1
+    authors = sorted(counts.items(), key=lambda item: (-item[1], item[0]))
+    return {
+        "last_modified": {
+            "commit": latest["commit"],
+            "author": latest["author"],
+            "date": iso_date(latest["timestamp"]) if latest["timestamp"] else None,
+            "summary": latest["summary"],
+        },
+        "primary_author": {
+            "author": authors[0][0],
+            "lines": authors[0][1],
+            "share": round(authors[0][1] / len(lines), 2),
+        },
+        "authors": [{"author": name, "lines": count} for name, count in authors],
+    }
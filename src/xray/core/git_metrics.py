@@ -0,0 +1,232 @@
+"""History-derived metrics: churn hotspots and co-change coupling."""
+
+import fnmatch
+import os
+from itertools import combinations
+from pathlib import Path
+from typing import Callable, Dict, List, Optional, Any, Tuple
+
+from xray.core.blob_cache import UNPARSEABLE, BlobCache
+from xray.core.git_history import GitRepo
+from xray.core.go_parser import parse_go_source
+from xray.core.ignore import is_generated_go
+
+SORT_KEYS = ("score", "churn", "complexity", "authors", "lines")
+
+# Directory names whose contents are never hand-maintained
+VENDORED_DIRS = {"vendor", "third_party", "node_modules"}
+# File names that code generators conventionally produce
+GENERATED_PATTERNS = ("*.pb.go", "*.pb.gw.go", "*_gen.go", "*_generated.go", "zz_generated*", "*.gen.go",
+                      "*_string.go", "mock_*.go", "*_mock.go")
+
+
+def _qualified(symbol: Dict[str, Any]) -> str:
+    if symbol.get("receiver"):
+        return f"{symbol['receiver']['type']}.{symbol['name']}"
+    return symbol["name"]
+
+
+def _top_level(content: str) -> List[Dict[str, Any]]:
+    return [s for s in parse_go_source(content)["symbols"] if "container" not in s]
+
+
+def _spans(repo: GitRepo, commit: str, path: str, blob: Optional[str]) -> Any:
+    """(qualified name, start line, end line) of each top-level symbol of a file at a commit, UNPARSEABLE or None."""
+    content = repo.blob(blob) if blob else repo.show(commit, path)
+    if content is None:
+        return None
+    try:
+        return [(_qualified(s), s["start_line"], s["end_line"]) for s in _top_level(content)]
+    except Exception:
+        return UNPARSEABLE
+
+
+def file_churn(repo: GitRepo, since: Optional[str], max_commits: Optional[int], include_merges: bool,
+               progress: Optional[Callable[[int, int, str], None]] = None,
+               pathspecs: Optional[List[str]] = None) -> Dict[str, Dict[str, Any]]:
+    """
+    Commits, distinct authors and line counts per file over the window,
+    optionally only of the files pathspecs select. progress, if given, is
+    called as (done, total, commit) per commit.
+    """
+    stats: Dict[str, Dict[str, Any]] = {}
+    commits = repo.log_numstat(since, max_commits, include_merges, pathspecs or [repo.scope])
+    for done, commit in enumerate(commits, 1):
+        if progress:
+            progress(done, len(commits), commit["commit"][:12])
+        for change in commit["files"]:
+            entry = stats.setdefault(change["path"], {"commits": 0, "authors": set(), "added": 0, "deleted": 0})
+            entry["commits"] += 1
+            entry["authors"].add(commit["author"])
+            entry["added"] += change["added"] or 0
+            entry["deleted"] += change["deleted"] or 0
+    return stats
+
+
+def symbol_churn(repo: GitRepo, since: Optional[str], max_commits: Optional[int], include_merges: bool,
+                 progress: Optional[Callable[[int, int, str], None]] = None,
+                 pathspecs: Optional[List[str]] = None,
+                 cache: Optional[BlobCache] = None) -> Dict[Tuple[str, str], Dict[str, Any]]:
+    """
+    Count the commits that touched each Go symbol.
+
+    Each commit's hunks are mapped onto the symbols of the file as it was
+    in that commit, so churn is attributed correctly even when the symbol
+    has since moved within the file. pathspecs replace the default "*.go"
+    to walk only part of the tree. progress, if given, is called as
+    (done, total, commit) before each commit is processed. The symbols of
+    each version are kept in cache by blob SHA (see xray.core.blob_cache).
+    """
+    cache = BlobCache() if cache is None else cache
+    churn: Dict[Tuple[str, str], Dict[str, Any]] = {}
+    commits = repo.log_hunks(since, max_commits, include_merges, pathspecs or [repo.scoped("*.go")])
+    for done, commit in enumerate(commits, 1):
+        if progress:
+            progress(done, len(commits), commit["commit"][:12])
+        for path, hunks in commit["files"].items():
+            if not path.endswith(".go"):
+                continue
+            blob = commit.get("blobs", {}).get(path)
+            if blob:
+                spans = cache.get("spans", blob, path, lambda: _spans(repo, commit["commit"], path, blob))
+            else:
+                spans = _spans(repo, commit["commit"], path, None)
+            if spans is None or spans == UNPARSEABLE:
+                continue
+            touched = set()
+            for start, count in hunks:
+                # A pure deletion (count 0) sits between lines start and start + 1
+                first, last = start, start + max(count, 1) - 1
+                for name, start_line, end_line in spans:
+                    if start_line <= last and end_line >= first:
+                        touched.add(name)
+            for name in touched:
+                entry = churn.setdefault((path, name), {"commits": 0, "authors": set()})
+                entry["commits"] += 1
+                entry["authors"].add(commit["author"])
+    return churn
+
+
+def rank_hotspots(current: List[Tuple[str, Dict[str, Any]]],
+                  churn: Dict[Tuple[str, str], Dict[str, Any]], sort_by: str) -> List[Dict[str, Any]]:
+    """
+    Join churn with the current symbol table and rank the result.
+
+    The score is churn x complexity for functions and methods (the classic
+    hotspot ranking) and churn x 1 for declarations without a body.
+    """
+    if sort_by not in SORT_KEYS:
+        raise ValueError(f"sort_by must be one of {', '.join(SORT_KEYS)}")
+    rows = []
+    for relpath, symbol in current:
+        name = _qualified(symbol)
+        stats = churn.get((relpath, name))
+        if not stats:
+            continue
+        complexity = symbol.get("complexity")
+        rows.append({
+            "name": name,
+            "type": symbol["type"],
+            "path": relpath,
+            "start_line": symbol["start_line"],
+            "churn": stats["commits"],
+            "authors": len(stats["authors"]),
+            "lines": symbol["end_line"] - symbol["start_line"] + 1,
+            "statements": symbol.get("statements"),
+            "complexity": complexity,
+            "score": stats["commits"] * (complexity or 1),
+        })
+    rows.sort(key=lambda r: (-(r[sort_by] or 0), r["path"], r["start_line"]))
+    return rows
+
+
+def is_excluded_path(relpath: str, root: str, patterns: Optional[List[str]] = None) -> bool:
+    """
+    Whether a file is vendored, generated or matches a user pattern.
+
+    Generated files are recognised by conventional names and, for files that
+    still exist, by the standard "Code generated ... DO NOT EDIT." header.
+    """
+    parts = relpath.split("/")
+    if any(part in VENDORED_DIRS for part in parts[:-1]):
+        return True
+    name = parts[-1]
+    if any(fnmatch.fnmatch(name, pattern) for pattern in GENERATED_PATTERNS):
+        return True
+    if patterns and any(fnmatch.fnmatch(relpath, p) or fnmatch.fnmatch(name, p) for p in patterns):
+        return True
+    if name.endswith(".go"):
+        return is_generated_go(Path(os.path.join(root, *parts)))
+    return False
+
+
+def co_changes(repo: GitRepo, max_commits: Optional[int], max_files_per_commit: int,
+               exclude: Optional[List[str]] = None,
+               progress: Optional[Callable[[int, int, str], None]] = None) -> Dict[str, Any]:
+    """
+    Count how often files, and pairs of files, change in the same commit.
+
+    Commits touching more than max_files_per_commit files (after exclusions)
+    are skipped: mass reformats and dependency bumps couple everything with
+    everything and drown out the real signal. progress, if given, is called
+    as (done, total, commit) per commit.
+    """
+    excluded: Dict[str, bool] = {}
+    file_counts: Dict[str, int] = {}
+    pair_counts: Dict[Tuple[str, str], int] = {}
+    analyzed = skipped = 0
+    commits = repo.log_numstat(None, max_commits, False, [repo.scope])
+    for done, commit in enumerate(commits, 1):
+        if progress:
+            progress(done, len(commits), commit["commit"][:12])
+        paths = set()
+        for change in commit["files"]:
+            path = change["path"]
+            if path not in excluded:
+                excluded[path] = is_excluded_path(path, repo.root, exclude)
+            if not excluded[path]:
+                paths.add(path)
+        if not paths:
+            continue
+        if len(paths) > max_files_per_commit:
+            skipped += 1
+            continue
+        analyzed += 1
+        for path in paths:
+            file_counts[path] = file_counts.get(path, 0) + 1
+        for pair in combinations(sorted(paths), 2):
+            pair_counts[pair] = pair_counts.get(pair, 0) + 1
+    return {
+        "files": file_counts,
+        "pairs": pair_counts,
+        "commits_analyzed": analyzed,
+        "commits_skipped": skipped,
+    }
+
+
+def coupled_pairs(stats: Dict[str, Any], min_support: int, min_confidence: float) -> List[Dict[str, Any]]:
+    """
+    Turn co-change counts into association rules between file pairs.
+
+    Support is the number of commits that changed both files; confidence
+    A -> B is support divided by the number of commits that changed A. A pair
+    is kept when either direction meets the confidence threshold.
+    """
+    rows = []
+    for (a, b), support in stats["pairs"].items():
+        if support < min_support:
+            continue
+        confidence_ab = support / stats["files"][a]
+        confidence_ba = support / stats["files"][b]
+        confidence = max(confidence_ab, confidence_ba)
+        if confidence < min_confidence:
+            continue
+        rows.append({
+            "files": [a, b],
+            "support": support,
+            "confidence": round(confidence, 3),
+            "confidence_a_to_b": round(confidence_ab, 3),
+            "confidence_b_to_a": round(confidence_ba, 3),
+        })
+    rows.sort(key=lambda r: (-r["support"], -r["confidence"], r["files"]))
+    return rows
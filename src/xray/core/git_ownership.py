@@ -0,0 +1,274 @@
+"""Code ownership from git: who wrote the code that is there now, and who keeps changing it.
+
+For every directory (and optionally every top-level symbol) two views of
+authorship are combined:
+
+- surviving lines: git blame of the current files, so an author whose code
+  was rewritten stops counting;
+- commits: the commits touching the directory over a window of history.
+
+Authors are identified by their email after the repository's .mailmap is
+applied (blame and `git log %aN/%aE` both use it), so one person committing
+from three addresses is one author once the addresses are mapped.
+
+The bus factor is the smallest number of authors owning BUS_FACTOR_SHARE of
+the surviving lines: 1 means one person wrote most of what is there.
+
+With a CODEOWNERS file (.github/, the root or docs/, first found, as GitHub
+looks them up) each directory is checked against it: the owners of the
+rules matching its files should include its dominant author. Owners are
+matched by email, or by a @handle equal to the email's local part or the
+author's name without spaces; @org/team owners cannot be resolved offline
+and leave the check open.
+"""
+
+import os
+import re
+from pathlib import Path
+from typing import Any, Dict, Iterable, List, Optional, Tuple
+
+from xray.core.git_history import is_uncommitted
+from xray.core.ignore import glob_regex
+
+# Share of the surviving lines the bus factor's authors own together
+BUS_FACTOR_SHARE = 0.8
+CODEOWNERS_LOCATIONS = (".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS")
+
+# GitHub noreply addresses: 12345+handle@users.noreply.github.com
+_NOREPLY = re.compile(r"^(?:\d+\+)?([^@]+)@users\.noreply\.github\.com$")
+
+
+def identity(name: str, email: str) -> str:
+    """The key an author is counted under: the (mailmapped) email, or the name without one."""
+    return email.strip().lower() or name
+
+
+def bus_factor(counts: Dict[str, int], share: float = BUS_FACTOR_SHARE) -> int:
+    """The fewest authors whose line counts reach `share` of the total; 0 for no lines."""
+    total = sum(counts.values())
+    if not total:
+        return 0
+    covered = 0
+    for k, count in enumerate(sorted(counts.values(), reverse=True), 1):
+        covered += count
+        if covered >= share * total:
+            return k
+    return len(counts)
+
+
+class _OwnerRule:
+    """One CODEOWNERS line: a gitignore-style pattern and its owners."""
+
+    def __init__(self, pattern: str, owners: List[str], line: int):
+        self.pattern = pattern
+        self.owners = owners
+        self.line = line
+        self.dir_only = pattern.endswith("/")
+        body = pattern.rstrip("/")
+        anchored = "/" in body
+        body = body.lstrip("/")
+        # `docs/*` owns the files directly in docs, not those of its subdirectories
+        self.files_only = "*" in body.rsplit("/", 1)[-1] and not body.endswith("**")
+        self.regex = re.compile(("^" if anchored else "(?:^|/)") + glob_regex(body) + "$")
+
+    def matches(self, relpath: str) -> bool:
+        parts = relpath.split("/")
+        if not self.dir_only and self.regex.search(relpath):
+            return True
+        if self.files_only:
+            return False
+        return any(self.regex.search("/".join(parts[:depth])) for depth in range(1, len(parts)))
+
+    def describe(self) -> str:
+        return f"{self.line}: {self.pattern} {' '.join(self.owners)}".rstrip()
+
+
+class CodeOwners:
+    """The rules of a repository's CODEOWNERS file; the last matching rule wins."""
+
+    def __init__(self, path: str, content: str):
+        self.path = path
+        self.rules: List[_OwnerRule] = []
+        for number, raw in enumerate(content.splitlines(), 1):
+            line = raw.split(" #", 1)[0].strip()
+            if not line or line.startswith("#"):
+                continue
+            pattern, *owners = line.split()
+            self.rules.append(_OwnerRule(pattern, owners, number))
+
+    @classmethod
+    def find(cls, repo_root: str) -> Optional["CodeOwners"]:
+        for location in CODEOWNERS_LOCATIONS:
+            path = Path(repo_root, location)
+            if path.is_file():
+                return cls(location, path.read_text(encoding="utf-8", errors="replace"))
+        return None
+
+    def rule_for(self, relpath: str) -> Optional[_OwnerRule]:
+        for rule in reversed(self.rules):
+            if rule.matches(relpath):
+                return rule
+        return None
+
+    @staticmethod
+    def owner_matches(owner: str, name: str, email: str) -> Optional[bool]:
+        """Whether an owner entry names the author; None for a team, which cannot be resolved."""
+        email = email.lower()
+        if not owner.startswith("@"):
+            return owner.lower() == email
+        handle = owner[1:].lower()
+        if "/" in handle:
+            return None
+        local = email.split("@", 1)[0]
+        noreply = _NOREPLY.match(email)
+        candidates = {local, name.replace(" ", "").lower(), noreply.group(1) if noreply else local}
+        return handle in candidates
+
+
+class OwnershipMap:
+    """Accumulates blame and commits per directory, then ranks the authors."""
+
+    def __init__(self, top: int = 5):
+        self.top = top
+        # identity -> (name, email) as last seen
+        self.names: Dict[str, Tuple[str, str]] = {}
+        # directory -> identity -> surviving lines / commits
+        self.lines: Dict[str, Dict[str, int]] = {}
+        self.commits: Dict[str, Dict[str, int]] = {}
+        self.totals: Dict[str, int] = {}
+        self.files: Dict[str, int] = {}
+        self.uncommitted: Dict[str, int] = {}
+        self.symbols: Dict[str, List[Dict[str, Any]]] = {}
+
+    def _count(self, lines: Iterable[Dict[str, Any]]) -> Tuple[Dict[str, int], int]:
+        counts: Dict[str, int] = {}
+        uncommitted = 0
+        for entry in lines:
+            if is_uncommitted(entry["commit"]):
+                uncommitted += 1
+                continue
+            key = identity(entry["author"], entry["author_email"])
+            self.names[key] = (entry["author"], entry["author_email"])
+            counts[key] = counts.get(key, 0) + 1
+        return counts, uncommitted
+
+    def add_file(self, relpath: str, blame: List[Dict[str, Any]],
+                 symbols: Optional[List[Dict[str, Any]]] = None):
+        """A file's per-line blame (GitRepo.blame), and the top-level symbols to attribute."""
+        directory = os.path.dirname(relpath) or "."
+        counts, uncommitted = self._count(blame)
+        totals = self.lines.setdefault(directory, {})
+        for key, count in counts.items():
+            totals[key] = totals.get(key, 0) + count
+        self.files[directory] = self.files.get(directory, 0) + 1
+        self.uncommitted[directory] = self.uncommitted.get(directory, 0) + uncommitted
+        by_line = {entry["line"]: entry for entry in blame}
+        for symbol in symbols or []:
+            span = [by_line[n] for n in range(symbol["start_line"], symbol["end_line"] + 1) if n in by_line]
+            counts, _ = self._count(span)
+            self.symbols.setdefault(directory, []).append({
+                "name": symbol["name"],
+                "type": symbol["type"],
+                "path": relpath,
+                "start_line": symbol["start_line"],
+                "end_line": symbol["end_line"],
+                "authors": [{k: v for k, v in a.items() if k != "commits"} for a in self._ranked(counts, {})[:self.top]],
+                "bus_factor": bus_factor(counts),
+            })
+
+    def add_commits(self, commits: List[Dict[str, Any]]):
+        """Commits from GitRepo.log_numstat(mailmap=True): one per directory each touched."""
+        for commit in commits:
+            key = identity(commit["author"], commit["author_email"])
+            self.names.setdefault(key, (commit["author"], commit["author_email"]))
+            self.totals[key] = self.totals.get(key, 0) + 1
+            for directory in {os.path.dirname(change["path"]) or "." for change in commit["files"]}:
+                counts = self.commits.setdefault(directory, {})
+                counts[key] = counts.get(key, 0) + 1
+
+    def _ranked(self, lines: Dict[str, int], commits: Dict[str, int]) -> List[Dict[str, Any]]:
+        total = sum(lines.values())
+        authors = []
+        for key in set(lines) | set(commits):
+            name, email = self.names[key]
+            authors.append({
+                "author": name,
+                "email": email,
+                "lines": lines.get(key, 0),
+                "share": round(lines.get(key, 0) / total, 2) if total else 0.0,
+                "commits": commits.get(key, 0),
+            })
+        authors.sort(key=lambda a: (-a["lines"], -a["commits"], a["author"]))
+        return authors
+
+    def _check(self, codeowners: CodeOwners, directory: str, files: List[str],
+               dominant: Optional[Dict[str, Any]]) -> Dict[str, Any]:
+        rules = {rule.line: rule for rule in (codeowners.rule_for(f) for f in files) if rule is not None}
+        owners = sorted({o for rule in rules.values() for o in rule.owners})
+        check: Dict[str, Any] = {"rules": [rules[n].describe() for n in sorted(rules)], "owners": owners}
+        if dominant is None:
+            return check
+        verdicts = [codeowners.owner_matches(o, dominant["author"], dominant["email"]) for o in owners]
+        if any(verdicts):
+            check["dominant_author_listed"] = True
+        elif None in verdicts:
+            # Only teams could list the author
+            check["dominant_author_listed"] = None
+        else:
+            check["dominant_author_listed"] = False
+            check["mismatch"] = "no_rule" if not owners else "not_an_owner"
+        return check
+
+    def result(self, codeowners: Optional[CodeOwners] = None,
+               files_by_dir: Optional[Dict[str, List[str]]] = None) -> Dict[str, Any]:
+        """
+        Directories ranked by bus factor (riskiest first), their top authors,
+        and with codeowners, a check of each directory's dominant author.
+        """
+        directories = []
+        mismatches = []
+        for directory in sorted(set(self.lines) | set(self.files)):
+            counts = self.lines.get(directory, {})
+            authors = self._ranked(counts, self.commits.get(directory, {}))
+            entry: Dict[str, Any] = {
+                "directory": directory,
+                "files": self.files.get(directory, 0),
+                "lines": sum(counts.values()),
+                "bus_factor": bus_factor(counts),
+                "authors": authors[:self.top],
+                "commits": sum(self.commits.get(directory, {}).values()),
+            }
+            if self.uncommitted.get(directory):
+                entry["uncommitted_lines"] = self.uncommitted[directory]
+            dominant = authors[0] if authors and authors[0]["lines"] else None
+            if codeowners is not None:
+                entry["codeowners"] = self._check(codeowners, directory, (files_by_dir or {}).get(directory, []),
+                                                  dominant)
+                if entry["codeowners"].get("mismatch"):
+                    mismatches.append({"directory": directory, "dominant_author": dominant["author"],
+                                       "email": dominant["email"], "share": dominant["share"],
+                                       "owners": entry["codeowners"]["owners"],
+                                       "reason": entry["codeowners"]["mismatch"]})
+            if directory in self.symbols:
+                entry["symbols"] = self.symbols[directory]
+            directories.append(entry)
+        directories.sort(key=lambda d: (d["bus_factor"] == 0, d["bus_factor"], -d["lines"], d["directory"]))
+
+        overall: Dict[str, int] = {}
+        for counts in self.lines.values():
+            for key, count in counts.items():
+                overall[key] = overall.get(key, 0) + count
+        result: Dict[str, Any] = {
+            "directories": directories,
+            "total_count": len(directories),
+            "project": {
+                "lines": sum(overall.values()),
+                "bus_factor": bus_factor(overall),
+                "authors": self._ranked(overall, self.totals)[:self.top],
+            },
+            "bus_factor_share": BUS_FACTOR_SHARE,
+        }
+        if codeowners is not None:
+            result["codeowners"] = {"path": codeowners.path, "rules": len(codeowners.rules),
+                                    "mismatches": mismatches}
+        return result
@@ -0,0 +1,153 @@
+"""Git submodules inside a project: where they are and which repository a file belongs to.
+
+Submodules are read from .gitmodules, recursively for initialized ones. An
+initialized submodule has its own work tree (a .git file or directory at
+its path) and its own history, so history-based tools must ask its
+repository, not the parent's, about the files in it - the parent only
+records which commit of the submodule it points at. An uninitialized one is
+an empty (or missing) directory: nothing to index, which is reported rather
+than treated as an error.
+"""
+
+import os
+import threading
+from pathlib import Path
+from typing import Any, Dict, List, Optional, Tuple
+
+from xray.core.git_history import GitRepo
+
+
+def read_gitmodules(repo_root: str) -> List[Dict[str, str]]:
+    """
+    The submodules declared in a work tree's .gitmodules.
+
+    Returns:
+        [{"name", "path", "url"}] in file order, path relative to repo_root
+    """
+    try:
+        with open(os.path.join(repo_root, ".gitmodules"), "r", encoding="utf-8") as f:
+            content = f.read()
+    except OSError:
+        return []
+    modules: List[Dict[str, str]] = []
+    current: Optional[Dict[str, str]] = None
+    for raw in content.splitlines():
+        line = raw.strip()
+        if not line or line[0] in "#;":
+            continue
+        if line.startswith("[") and line.endswith("]"):
+            section = line[1:-1].strip()
+            if section.startswith("submodule"):
+                current = {"name": section[len("submodule"):].strip().strip('"'), "path": "", "url": ""}
+                modules.append(current)
+            else:
+                current = None
+            continue
+        key, _, value = line.partition("=")
+        if current is not None and key.strip() in ("path", "url"):
+            current[key.strip()] = value.strip().strip('"')
+    return [m for m in modules if m["path"]]
+
+
+class Submodules:
+    """The submodules under a project root, nested ones included."""
+
+    def __init__(self, root: Path, repo_root: Optional[str] = None):
+        """
+        Args:
+            root: The project root (a ref's snapshot has no submodule work trees)
+            repo_root: The root of the work tree root lies in, if known
+        """
+        self.root = Path(root)
+        # Absolute path -> {"name", "path", "url", "initialized", "repository_root"}
+        self.modules: Dict[str, Dict[str, Any]] = {}
+        if repo_root is None:
+            repo_root = self._work_tree_root(self.root)
+        if repo_root is not None:
+            self._scan(Path(repo_root), "")
+
+    @staticmethod
+    def _work_tree_root(path: Path) -> Optional[str]:
+        for candidate in [path, *path.parents]:
+            if (candidate / ".git").exists():
+                return str(candidate)
+        return None
+
+    def _scan(self, repo_root: Path, prefix: str):
+        for module in read_gitmodules(str(repo_root)):
+            path = (repo_root / module["path"]).resolve()
+            initialized = (path / ".git").exists()
+            relpath = f"{prefix}{module['path'].strip('/')}"
+            if path == self.root or self.root in path.parents:
+                self.modules[str(path)] = {
+                    "name": module["name"],
+                    # Relative to the outermost repository, like the parent's own paths
+                    "path": relpath,
+                    "url": module["url"],
+                    "initialized": initialized,
+                }
+            if initialized:
+                self._scan(path, relpath + "/")
+
+    def __bool__(self) -> bool:
+        return bool(self.modules)
+
+    def is_submodule(self, path: Path) -> bool:
+        return str(path) in self.modules
+
+    def containing(self, path: str) -> Optional[Tuple[str, Dict[str, Any]]]:
+        """The innermost initialized submodule a path lies in, as (its directory, its entry)."""
+        best = None
+        for directory, module in self.modules.items():
+            if module["initialized"] and (path == directory or path.startswith(directory + os.sep)):
+                if best is None or len(directory) > len(best[0]):
+                    best = (directory, module)
+        return best
+
+    def repo_for(self, path: str, default: GitRepo, cancel: Optional[threading.Event] = None) -> GitRepo:
+        """The repository whose history a file's lines come from."""
+        found = self.containing(path)
+        return GitRepo(found[0], cancel, default.fetch_missing) if found else default
+
+    def repositories(self, default: GitRepo,
+                     cancel: Optional[threading.Event] = None) -> List[Tuple[str, GitRepo]]:
+        """
+        (path prefix, repository) for the parent and every initialized
+        submodule; prefixing a submodule's paths with it makes them paths of
+        the parent's work tree.
+        """
+        repos = [("", default)]
+        for directory, module in sorted(self.modules.items()):
+            if module["initialized"]:
+                repos.append((module["path"] + "/", GitRepo(directory, cancel, default.fetch_missing)))
+        return repos
+
+    def mark(self, result: Any) -> Any:
+        """Record, in place, the submodule of every symbol (a dict with "start_line") of one."""
+        def walk(value: Any, path: Optional[str]):
+            if isinstance(value, list):
+                for item in value:
+                    walk(item, path)
+                return
+            if not isinstance(value, dict):
+                return
+            own = next((value[k] for k in ("path", "file") if isinstance(value.get(k), str)), None)
+            if own is not None:
+                path = own if os.path.isabs(own) else os.path.join(str(self.root), own)
+            if path and "start_line" in value and "repository" not in value:
+                found = self.containing(path)
+                if found:
+                    value["repository"] = found[1]["path"]
+            for item in value.values():
+                if isinstance(item, (dict, list)):
+                    walk(item, path)
+
+        walk(result, None)
+        return result
+
+    def summary(self, files: Dict[str, int]) -> List[Dict[str, Any]]:
+        """The submodules with the number of indexed files of each (files: directory -> count)."""
+        return [
+            {**module, "files_indexed": files.get(directory, 0)}
+            for directory, module in sorted(self.modules.items(), key=lambda item: item[1]["path"])
+        ]
@@ -0,0 +1,1127 @@
+"""Cross-file semantic analysis for Go - method sets, interface satisfaction
+and the call graph.
+
+Builds on the per-file records produced by go_parser. A Go package is the set
+of files in one directory, so types and methods are grouped by directory.
+"""
+
+import os
+import re
+from typing import Callable, Dict, Iterator, List, Optional, Any, Set, Tuple
+
+from xray.core.go_helpers import file_imports
+from xray.core.go_modules import GoModules
+
+_QUALIFIER = re.compile(r"\b[A-Za-z_]\w*\.")
+_WHITESPACE = re.compile(r"\s+")
+
+
+def normalize_type(type_text: str, strip_qualifiers: bool = False) -> str:
+    """Normalize a type expression for comparison."""
+    text = _WHITESPACE.sub("", type_text)
+    if strip_qualifiers:
+        text = _QUALIFIER.sub("", text)
+    return text
+
+
+def signature_key(method: Dict[str, Any], strip_qualifiers: bool = False) -> Tuple[Tuple[str, ...], Tuple[str, ...]]:
+    """Return the comparable (param types, result types) of a method record."""
+    params = tuple(normalize_type(p["type"], strip_qualifiers) for p in method.get("params", []))
+    results = tuple(normalize_type(r["type"], strip_qualifiers) for r in method.get("results", []))
+    return params, results
+
+
+class GoProject:
+    """Package-level view over every parsed Go file in a project."""
+
+    def __init__(self, files: List[Tuple[str, Dict[str, Any]]], root: Optional[str] = None,
+                 modules: Optional[GoModules] = None):
+        self.files: Dict[str, Dict[str, Any]] = {}
+        self.root = root
+        # go.mod/go.work modules; without any, imports are matched by directory suffix
+        self.modules = modules
+        self.packages: Dict[str, Dict[str, Any]] = {}
+        # (package dir, type name) -> type symbol
+        self.types: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        # (package dir, type name) -> methods declared with that receiver
+        self.methods: Dict[Tuple[str, str], List[Dict[str, Any]]] = {}
+        # (package dir, interface name) -> method specs
+        self.interface_methods: Dict[Tuple[str, str], List[Dict[str, Any]]] = {}
+        # (package dir, struct name) -> fields, embedded ones included
+        self.fields: Dict[Tuple[str, str], List[Dict[str, Any]]] = {}
+        # methods keyed by the receiver as written, before alias resolution
+        self._declared_methods: Dict[Tuple[str, str], List[Dict[str, Any]]] = {}
+        # import path -> package dir (or None), reset when packages change
+        self._import_dirs: Dict[Any, Optional[str]] = {}
+        # declarations made in several files under different build constraints
+        self._variants: Optional[Dict[Tuple[str, str, str], List[Dict[str, Any]]]] = None
+
+        for path, parsed in sorted(files):
+            self._add_file(path, parsed)
+        self._merge_alias_methods()
+
+    def _add_file(self, path: str, parsed: Dict[str, Any]):
+        self.files[path] = parsed
+        pkg_dir = os.path.dirname(path)
+        package = self.packages.setdefault(pkg_dir, {"name": parsed.get("package", ""), "files": []})
+        package["files"].append(path)
+        package["files"].sort()
+        self._package_doc(pkg_dir)
+        for symbol in parsed["symbols"]:
+            record = {**symbol, "path": path, "package": package["name"]}
+            if symbol["type"] in ("struct", "interface", "type") and "container" not in symbol:
+                self.types[(pkg_dir, symbol["name"])] = record
+            elif symbol["type"] == "method" and symbol.get("receiver"):
+                self._declared_methods.setdefault((pkg_dir, symbol["receiver"]["type"]), []).append(record)
+            elif symbol["type"] == "method" and symbol.get("container"):
+                self.interface_methods.setdefault((pkg_dir, symbol["container"]), []).append(record)
+            elif symbol["type"] == "field":
+                self.fields.setdefault((pkg_dir, symbol["container"]), []).append(record)
+
+    def _remove_file(self, path: str):
+        if self.files.pop(path, None) is None:
+            return
+        pkg_dir = os.path.dirname(path)
+        package = self.packages[pkg_dir]
+        package["files"].remove(path)
+        if not package["files"]:
+            del self.packages[pkg_dir]
+        else:
+            self._package_doc(pkg_dir)
+        for key in [k for k, record in self.types.items() if record["path"] == path]:
+            del self.types[key]
+        for table in (self._declared_methods, self.interface_methods, self.fields):
+            for key in [k for k in table if k[0] == pkg_dir]:
+                table[key] = [record for record in table[key] if record["path"] != path]
+                if not table[key]:
+                    del table[key]
+
+    def _package_doc(self, pkg_dir: str):
+        """
+        Set a package's "doc" to its package comment and "doc_files" to the
+        files holding it. Different comments in several files are joined,
+        each followed by its file name; test files don't count.
+        """
+        package = self.packages[pkg_dir]
+        # doc.go is where a package comment conventionally lives
+        files = sorted((p for p in package["files"] if not p.endswith("_test.go")),
+                       key=lambda p: (os.path.basename(p) != "doc.go", p))
+        docs: List[Tuple[str, str]] = []
+        for path in files:
+            doc = (self.files[path].get("package_doc") or "").strip()
+            if doc and all(doc != other for _, other in docs):
+                docs.append((os.path.basename(path), doc))
+        if len(docs) > 1:
+            package["doc"] = "\n\n".join(f"{doc} ({name})" for name, doc in docs)
+        else:
+            package["doc"] = docs[0][1] if docs else ""
+        package["doc_files"] = [name for name, _ in docs]
+
+    def _merge_alias_methods(self):
+        # Methods declared on an alias belong to the aliased type
+        self.methods = {key: list(records) for key, records in self._declared_methods.items()}
+        for key in list(self.methods):
+            target = self.resolve_alias_key(key)
+            if target != key and target in self.types:
+                self.methods.setdefault(target, []).extend(self.methods.pop(key))
+        for records in self.methods.values():
+            records.sort(key=lambda r: (r["path"], r["start_line"]))
+
+    def update(self, changed: Dict[str, Dict[str, Any]], removed: List[str]):
+        """
+        Replace the declarations of changed files and drop removed ones.
+
+        Only the affected entries are touched; the alias-resolved method
+        table is recomputed from the per-file declarations, which is cheap.
+        """
+        for path in list(changed) + list(removed):
+            self._remove_file(path)
+        for path, parsed in sorted(changed.items()):
+            self._add_file(path, parsed)
+        self._import_dirs = {}
+        self._variants = None
+        self._merge_alias_methods()
+
+    def restricted(self, include: Callable[[Dict[str, Any]], bool]) -> "GoProject":
+        """A project of only the files include() accepts - those one build compiles."""
+        return GoProject([(path, parsed) for path, parsed in self.files.items() if include(parsed)], self.root,
+                         self.modules)
+
+    def set_modules(self, modules: GoModules):
+        """Switch to another module layout (a go.mod or go.work changed)."""
+        self.modules = modules
+        self._import_dirs = {}
+
+    def import_path(self, pkg_dir: str) -> Optional[str]:
+        """The canonical import path of a package directory (module path + directory), if it is in a module."""
+        return self.modules.import_path(pkg_dir) if self.modules is not None else None
+
+    @staticmethod
+    def declaration_name(symbol: Dict[str, Any]) -> Optional[str]:
+        """The package-level name of a declaration ("Func", "Type", "Recv.Method"), None for members."""
+        if symbol["type"] == "method" and symbol.get("receiver"):
+            return f"{symbol['receiver']['type']}.{symbol['name']}"
+        if symbol.get("container") or symbol["type"] in ("field", "method"):
+            return None
+        return symbol["name"]
+
+    def variants(self, path: str, symbol: Dict[str, Any]) -> List[Dict[str, Any]]:
+        """
+        The other declarations of the same name in the package of path, in
+        files with different build constraints (file_linux.go and
+        file_windows.go both declaring Open): where they are and which
+        builds compile them. Empty when the declaration has no variants.
+        """
+        if self._variants is None:
+            declared: Dict[Tuple[str, str, str], List[Dict[str, Any]]] = {}
+            for file_path, parsed in sorted(self.files.items()):
+                for sym in parsed["symbols"]:
+                    name = self.declaration_name(sym)
+                    if name is None:
+                        continue
+                    declared.setdefault((os.path.dirname(file_path), parsed.get("package", ""), name), []).append({
+                        "path": file_path,
+                        "line": sym["start_line"],
+                        "buildConstraints": parsed.get("buildConstraints"),
+                    })
+            self._variants = {key: records for key, records in declared.items()
+                              if len(records) > 1 and any(r["buildConstraints"] for r in records)}
+        name = self.declaration_name(symbol)
+        if name is None:
+            return []
+        key = (os.path.dirname(path), self.files[path].get("package", "") if path in self.files else "", name)
+        return [r for r in self._variants.get(key, ()) if r["path"] != path or r["line"] != symbol["start_line"]]
+
+    def import_dir(self, import_path: str, importer: Optional[str] = None) -> Optional[str]:
+        """
+        Map an import path onto a project package directory, if it is one.
+
+        Through the modules (module path prefix, or a local replace of the
+        importing file's module) when the project has go.mod files;
+        otherwise by a directory whose path relative to the root ends the
+        import path.
+        """
+        if self.modules is not None and self.modules.modules:
+            module = self.modules.module_of(os.path.dirname(importer)) if importer else None
+            cache_key = (module["dir"] if module else None, import_path)
+            if cache_key not in self._import_dirs:
+                directory = self.modules.resolve(import_path, module["dir"] if module else None)
+                self._import_dirs[cache_key] = directory if directory in self.packages else None
+            return self._import_dirs[cache_key]
+        if not self.root:
+            return None
+        if import_path not in self._import_dirs:
+            self._import_dirs[import_path] = None
+            for pkg_dir in sorted(self.packages):
+                rel = os.path.relpath(pkg_dir, self.root).replace(os.sep, "/")
+                if rel == ".":
+                    continue
+                if import_path == rel or import_path.endswith("/" + rel):
+                    self._import_dirs[import_path] = pkg_dir
+                    break
+        return self._import_dirs[import_path]
+
+    def find_types(self, name: str, path: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Return type symbols named `name`, optionally restricted to one file or directory."""
+        matches = []
+        for (pkg_dir, type_name), symbol in sorted(self.types.items()):
+            if type_name != name:
+                continue
+            if path and symbol["path"] != path and pkg_dir != path.rstrip(os.sep):
+                continue
+            matches.append(symbol)
+        return matches
+
+    def _resolve_type_ref(self, pkg_dir: str, ref: str, follow_aliases: bool = True) -> Optional[Tuple[str, str]]:
+        """Resolve a type reference (`Reader` or `io.Reader`) to a project type key."""
+        ref = ref.lstrip("*")
+        key = None
+        if "." not in ref:
+            key = (pkg_dir, ref.split("[", 1)[0])
+            if key not in self.types:
+                return None
+        else:
+            qualifier, name = ref.split(".", 1)
+            name = name.split("[", 1)[0]
+            for other_dir, package in sorted(self.packages.items()):
+                if package["name"] == qualifier and (other_dir, name) in self.types:
+                    key = (other_dir, name)
+                    break
+        if key is None:
+            return None
+        return self.resolve_alias_key(key) if follow_aliases else key
+
+    def resolve_type_ref(self, pkg_dir: str, ref: str) -> Optional[Tuple[str, str]]:
+        """The project type a name written in a package (`UserID`, `store.User`) refers to, aliases followed."""
+        return self._resolve_type_ref(pkg_dir, ref)
+
+    def alias_chain(self, key: Tuple[str, str]) -> Dict[str, Any]:
+        """
+        Follow `type A = B` declarations starting at a type key.
+
+        Returns:
+            {"chain": [alias names in order], "target": final type text,
+             "target_key": project type key or None, "last_alias": key of the last alias}
+        """
+        chain = []
+        seen = set()
+        current = key
+        last_alias = None
+        target_text = key[1]
+        while current in self.types and self.types[current].get("alias") and current not in seen:
+            seen.add(current)
+            chain.append(current[1])
+            last_alias = current
+            target_text = self.types[current].get("underlying", self.types[current]["type"])
+            nxt = self._resolve_type_ref(current[0], target_text, follow_aliases=False)
+            if nxt is None:
+                return {"chain": chain, "target": target_text, "target_key": None, "last_alias": last_alias}
+            current = nxt
+        return {"chain": chain, "target": target_text,
+                "target_key": current if current in self.types else None, "last_alias": last_alias}
+
+    def resolve_alias_key(self, key: Tuple[str, str]) -> Tuple[str, str]:
+        """Return the project type an alias chain ends at, or the key itself."""
+        if key not in self.types or not self.types[key].get("alias"):
+            return key
+        return self.alias_chain(key)["target_key"] or key
+
+    def aliases_of(self, key: Tuple[str, str]) -> List[Dict[str, Any]]:
+        """Return every alias (directly or through a chain) of a project type."""
+        aliases = []
+        for other_key, symbol in sorted(self.types.items()):
+            if other_key != key and symbol.get("alias") and self.alias_chain(other_key)["target_key"] == key:
+                aliases.append(symbol)
+        return aliases
+
+    def interface_method_set(self, pkg_dir: str, name: str,
+                             _seen: Optional[set] = None) -> Tuple[Dict[str, Dict[str, Any]], List[str]]:
+        """
+        Return the full method set of an interface, following embedded interfaces.
+
+        Returns:
+            (methods by name, embedded interfaces that could not be resolved)
+        """
+        seen = _seen if _seen is not None else set()
+        if (pkg_dir, name) in seen:
+            return {}, []
+        seen.add((pkg_dir, name))
+
+        methods = {m["name"]: m for m in self.interface_methods.get((pkg_dir, name), [])}
+        unresolved = []
+        symbol = self.types.get((pkg_dir, name))
+        for embed in (symbol or {}).get("embeds", []):
+            key = self._resolve_type_ref(pkg_dir, embed)
+            if key is None:
+                unresolved.append(embed)
+                continue
+            embedded, missing = self.interface_method_set(key[0], key[1], seen)
+            for method_name, method in embedded.items():
+                methods.setdefault(method_name, method)
+            unresolved.extend(missing)
+        return methods, unresolved
+
+    def embeds_of(self, key: Tuple[str, str]) -> List[Tuple[str, Optional[Tuple[str, str]]]]:
+        """
+        The types a struct or interface embeds, as written and resolved.
+
+        Returns:
+            (type as written, project type key or None if outside the project)
+        """
+        symbol = self.types.get(key)
+        if symbol is None:
+            return []
+        if symbol["type"] == "interface":
+            return [(embed, self._resolve_type_ref(key[0], embed)) for embed in symbol.get("embeds", [])]
+        return [(field["field_type"], self.embedded_type(field))
+                for field in self.fields.get(key, []) if field.get("embedded")]
+
+    def embedded_type(self, field: Dict[str, Any]) -> Optional[Tuple[str, str]]:
+        """Resolve an embedded field record to the project type it embeds."""
+        return self._resolve_type_ref(os.path.dirname(field["path"]), field["field_type"])
+
+    def member_set(self, pkg_dir: str, name: str, pointer: bool) -> Dict[str, Any]:
+        """
+        Return the methods and fields of T (pointer=False) or *T, including
+        members promoted through embedded fields.
+
+        Promotion follows the spec: a shallower member hides deeper ones, and
+        two members of the same name at the same depth cancel out and are
+        reported as ambiguous. Pointer-receiver methods of an embedded type
+        are promoted when the embedding value is addressable, i.e. for *T or
+        when the type is embedded as a pointer.
+
+        Returns:
+            {"methods": {...}, "fields": {...}, "ambiguous": {name: [via, ...]},
+             "unresolved_embeds": [...]}; promoted records carry "promoted_via"
+        """
+        methods: Dict[str, Dict[str, Any]] = {}
+        fields: Dict[str, Dict[str, Any]] = {}
+        ambiguous: Dict[str, List[str]] = {}
+        unresolved: List[str] = []
+        seen_names = set()
+        visited = set()
+        # (type key, addressable, embedding path)
+        level = [(self.resolve_alias_key((pkg_dir, name)), pointer, [])]
+
+        while level:
+            found: Dict[str, List[Tuple[str, Dict[str, Any], List[str]]]] = {}
+            next_level = []
+            for key, addressable, via in level:
+                if key in visited:
+                    continue
+                visited.add(key)
+                type_symbol = self.types.get(key)
+                if type_symbol is not None and type_symbol["type"] == "interface":
+                    iface_methods, missing = self.interface_method_set(*key)
+                    unresolved.extend(missing)
+                    for method_name, method in iface_methods.items():
+                        found.setdefault(method_name, []).append(("method", method, via))
+                    continue
+                for method in self.methods.get(key, []):
+                    if method["receiver"]["pointer"] and not addressable:
+                        continue
+                    found.setdefault(method["name"], []).append(("method", method, via))
+                for field in self.fields.get(key, []):
+                    found.setdefault(field["name"], []).append(("field", field, via))
+                    if field.get("embedded"):
+                        embedded = self.embedded_type(field)
+                        if embedded is None:
+                            unresolved.append(field["field_type"])
+                            continue
+                        next_level.append((embedded, addressable or field["embedded_pointer"], via + [field["name"]]))
+
+            for member_name, sources in sorted(found.items()):
+                if member_name in seen_names:
+                    continue
+                seen_names.add(member_name)
+                if len(sources) > 1:
+                    ambiguous[member_name] = [".".join(v) for _, _, v in sources]
+                    continue
+                kind, record, via = sources[0]
+                if via:
+                    record = {**record, "promoted_via": ".".join(via)}
+                (methods if kind == "method" else fields)[member_name] = record
+            level = next_level
+
+        return {"methods": methods, "fields": fields, "ambiguous": ambiguous, "unresolved_embeds": unresolved}
+
+    def concrete_method_set(self, pkg_dir: str, name: str, pointer: bool) -> Dict[str, Dict[str, Any]]:
+        """
+        Return the method set of T (pointer=False) or *T (pointer=True).
+
+        Value receivers belong to both; pointer receivers only to *T, which is
+        why a T value does not satisfy an interface through pointer methods.
+        Methods promoted from embedded fields are included.
+        """
+        return self.member_set(pkg_dir, name, pointer)["methods"]
+
+    def match_interface(self, iface_key: Tuple[str, str], type_key: Tuple[str, str]) -> Optional[Dict[str, Any]]:
+        """
+        Compare a concrete type's method sets against an interface.
+
+        Returns None when the type shares no method with the interface,
+        otherwise a dictionary describing a full or partial match. A method
+        promoted ambiguously (two embeds at the same depth define it) counts
+        as shared, its "ambiguous" entry naming the conflicting embeds.
+        """
+        required, unresolved = self.interface_method_set(*iface_key)
+        if not required:
+            return None
+        value_set = self.concrete_method_set(type_key[0], type_key[1], pointer=False)
+        pointer_members = self.member_set(type_key[0], type_key[1], pointer=True)
+        pointer_set = pointer_members["methods"]
+
+        matched, missing, mismatched, pointer_only, ambiguous, promoted = [], [], [], [], [], {}
+        for method_name, spec in sorted(required.items()):
+            method = pointer_set.get(method_name)
+            if method is None:
+                if method_name in pointer_members["ambiguous"]:
+                    ambiguous.append({"method": method_name, "candidates": pointer_members["ambiguous"][method_name]})
+                missing.append(method_name)
+                continue
+            strip = iface_key[0] != os.path.dirname(method["path"])
+            if signature_key(spec, strip) != signature_key(method, strip):
+                mismatched.append({
+                    "method": method_name,
+                    "expected": spec["signature"],
+                    "actual": method["signature"],
+                })
+                continue
+            matched.append(method_name)
+            if method.get("promoted_via"):
+                promoted[method_name] = method["promoted_via"]
+            if method_name not in value_set:
+                pointer_only.append(method_name)
+
+        if not matched and not mismatched and not ambiguous:
+            return None
+
+        type_symbol = self.types[type_key]
+        result = {
+            "name": type_key[1],
+            "type": type_symbol["type"],
+            "package": type_symbol["package"],
+            "path": type_symbol["path"],
+            "start_line": type_symbol["start_line"],
+            "matched": matched,
+        }
+        if not missing and not mismatched:
+            result["satisfied_by"] = f"*{type_key[1]}" if pointer_only else type_key[1]
+            if pointer_only:
+                result["pointer_receiver_methods"] = pointer_only
+        else:
+            result["missing"] = missing
+            if mismatched:
+                result["mismatched"] = mismatched
+            if ambiguous:
+                result["ambiguous"] = ambiguous
+        if promoted:
+            result["promoted"] = promoted
+        unresolved = unresolved + pointer_members["unresolved_embeds"]
+        if unresolved:
+            result["unresolved_embeds"] = unresolved
+        return result
+
+    def implementations_of(self, iface: Dict[str, Any]) -> Dict[str, Any]:
+        """Find concrete types that fully or partially implement an interface."""
+        iface_key = (os.path.dirname(iface["path"]), iface["name"])
+        required, unresolved = self.interface_method_set(*iface_key)
+
+        complete, partial = [], []
+        for type_key, symbol in sorted(self.types.items()):
+            if symbol["type"] == "interface" or symbol.get("alias"):
+                continue
+            match = self.match_interface(iface_key, type_key)
+            if match is None:
+                continue
+            (complete if "satisfied_by" in match else partial).append(match)
+
+        result = {
+            "interface": {
+                "name": iface["name"],
+                "package": iface["package"],
+                "path": iface["path"],
+                "start_line": iface["start_line"],
+                "methods": sorted(required),
+            },
+            "implementations": complete,
+            "partial": partial,
+            "total_count": len(complete),
+        }
+        if unresolved:
+            result["interface"]["unresolved_embeds"] = unresolved
+        return result
+
+    def interfaces_of(self, concrete: Dict[str, Any]) -> Dict[str, Any]:
+        """Find the project interfaces a concrete type fully or partially satisfies."""
+        type_key = (os.path.dirname(concrete["path"]), concrete["name"])
+
+        complete, partial = [], []
+        for iface_key, symbol in sorted(self.types.items()):
+            if symbol["type"] != "interface" or symbol.get("alias"):
+                continue
+            match = self.match_interface(iface_key, type_key)
+            if match is None:
+                continue
+            entry = {
+                "name": iface_key[1],
+                "package": symbol["package"],
+                "path": symbol["path"],
+                "start_line": symbol["start_line"],
+                "matched": match["matched"],
+            }
+            for key in ("satisfied_by", "pointer_receiver_methods", "missing", "mismatched", "ambiguous",
+                        "promoted", "unresolved_embeds"):
+                if key in match:
+                    entry[key] = match[key]
+            (complete if "satisfied_by" in match else partial).append(entry)
+
+        return {
+            "type": {
+                "name": concrete["name"],
+                "package": concrete["package"],
+                "path": concrete["path"],
+                "start_line": concrete["start_line"],
+                "methods": sorted(self.concrete_method_set(*type_key, pointer=True)),
+            },
+            "interfaces": complete,
+            "partial": partial,
+            "total_count": len(complete),
+        }
+
+
+_BUILTIN_VALUES = {"nil", "true", "false", "iota", "_"}
+
+
+def _element_type(type_text: str, index: int) -> Optional[str]:
+    """Return the key (index 0) or element (index 1) type of a slice, array or map."""
+    text = type_text.lstrip("*")
+    if text.startswith("map["):
+        depth = 0
+        for pos, char in enumerate(text):
+            if char == "[":
+                depth += 1
+            elif char == "]":
+                depth -= 1
+                if depth == 0:
+                    return text[4:pos] if index == 0 else text[pos + 1:]
+        return None
+    if text.startswith("["):
+        close = text.find("]")
+        return "int" if index == 0 else text[close + 1:]
+    if text == "string":
+        return "int" if index == 0 else "rune"
+    if text.startswith("chan ") and index == 0:
+        return text[5:]
+    return None
+
+
+class GoCallGraph:
+    """
+    Static call graph over a GoProject.
+
+    Nodes are functions and methods keyed by (package dir, "Name") or
+    (package dir, "Recv.Name"). Calls are resolved through local variables,
+    parameters, struct fields, package variables and imports; anything that
+    resolves outside the project is kept as an external node. Function
+    values that are passed around rather than called (for example a handler
+    given to http.HandleFunc) are recorded as edges of kind "reference".
+    A function declared once per platform (under different build
+    constraints) is one node, listing the other declarations as "variants".
+    """
+
+    def __init__(self, project: GoProject, progress: Optional[Callable[[int, int, str], None]] = None):
+        self.project = project
+        # node key -> node description
+        self.nodes: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        # (package dir, name) -> package-level function/method symbol
+        self.functions: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        # (package dir, name) -> (source, path) of package-level vars
+        self.package_vars: Dict[Tuple[str, str], Tuple[Dict[str, Any], str]] = {}
+        self.edges: List[Dict[str, Any]] = []
+        # caller file -> edges collected from it
+        self._edges_by_path: Dict[str, List[Dict[str, Any]]] = {}
+
+        for path, parsed in sorted(project.files.items()):
+            self._add_declarations(path, parsed)
+        paths = sorted(project.files)
+        for done, path in enumerate(paths, 1):
+            self._collect_file_edges(path)
+            if progress:
+                progress(done, len(paths), path)
+        self._flatten_edges()
+
+    def _add_declarations(self, path: str, parsed: Dict[str, Any]):
+        pkg_dir = os.path.dirname(path)
+        for symbol in parsed["symbols"]:
+            if symbol["type"] == "function":
+                key = (pkg_dir, symbol["name"])
+            elif symbol["type"] == "method" and symbol.get("receiver"):
+                key = (pkg_dir, f"{symbol['receiver']['type']}.{symbol['name']}")
+            elif symbol["type"] == "method" and symbol.get("container"):
+                key = (pkg_dir, f"{symbol['container']}.{symbol['name']}")
+            else:
+                continue
+            node = {
+                "name": key[1],
+                "package": parsed.get("package", ""),
+                "path": path,
+                "line": symbol["start_line"],
+                "signature": symbol.get("signature", ""),
+            }
+            if symbol.get("container"):
+                node["interface_method"] = True
+            if parsed.get("buildConstraints"):
+                node["buildConstraints"] = parsed["buildConstraints"]
+            variants = self.project.variants(path, symbol)
+            if variants:
+                if key in self.nodes:
+                    # One function per platform: the first file keeps the node, listing the others
+                    continue
+                node["variants"] = variants
+            self.functions[key] = symbol
+            self.nodes[key] = node
+        for name, source in parsed.get("package_vars", {}).items():
+            self.package_vars[(pkg_dir, name)] = (source, path)
+
+    def _remove_declarations(self, pkg_dirs: Set[str]):
+        for key in [k for k in self.nodes if k[0] in pkg_dirs]:
+            del self.nodes[key]
+            del self.functions[key]
+        for key in [k for k in self.package_vars if k[0] in pkg_dirs]:
+            del self.package_vars[key]
+
+    def _collect_file_edges(self, path: str):
+        edges = self._edges_by_path[path] = []
+        for func in self.project.files[path].get("functions", []):
+            self._collect_edges(path, func, edges)
+
+    def _flatten_edges(self):
+        self.edges = [edge for path in sorted(self._edges_by_path) for edge in self._edges_by_path[path]]
+
+    def _dependent_files(self, pkg_dirs: Set[str]) -> Set[str]:
+        """Files whose call resolution may go through the given packages, directly or transitively."""
+        imported_by: Dict[str, Set[str]] = {}
+        for path, parsed in self.project.files.items():
+            for imp in parsed.get("imports", []):
+                target = self.project.import_dir(imp["path"], path)
+                if target:
+                    imported_by.setdefault(target, set()).add(os.path.dirname(path))
+        affected = set(pkg_dirs)
+        frontier = list(pkg_dirs)
+        while frontier:
+            for importer in imported_by.get(frontier.pop(), ()):
+                if importer not in affected:
+                    affected.add(importer)
+                    frontier.append(importer)
+        return {path for path in self.project.files if os.path.dirname(path) in affected}
+
+    def update(self, changed: Dict[str, Dict[str, Any]], removed: List[str]):
+        """
+        Refresh the graph after project.update(changed, removed).
+
+        Declarations of the touched packages are redone in place. Edges are
+        re-resolved only for files in the touched packages and in packages
+        that import them (transitively), since only those can resolve a call
+        through a declaration that changed; all other edges are kept.
+        """
+        touched = set(changed) | set(removed)
+        # Whole packages, so variants across files stay linked
+        touched_dirs = {os.path.dirname(p) for p in touched}
+        self._remove_declarations(touched_dirs)
+        for path in sorted(p for p in self.project.files if os.path.dirname(p) in touched_dirs):
+            self._add_declarations(path, self.project.files[path])
+        for path in removed:
+            self._edges_by_path.pop(path, None)
+        for path in sorted(self._dependent_files(touched_dirs)):
+            self._collect_file_edges(path)
+        self._flatten_edges()
+
+    def dangling_edges(self) -> List[Dict[str, Any]]:
+        """
+        Edges the graph should no longer have: from a file no longer in the
+        project, or between project functions one of which is no longer a node.
+        """
+        return [edge for path, edges in sorted(self._edges_by_path.items()) for edge in edges
+                if path not in self.project.files or edge["caller"] not in self.nodes or
+                (not edge["external"] and edge["callee"] not in self.nodes)]
+
+    def drop_edges(self, edges: List[Dict[str, Any]]) -> int:
+        """Remove edges (as dangling_edges returns them) from the graph; how many were removed."""
+        dropped = {id(edge) for edge in edges}
+        removed = 0
+        for path in list(self._edges_by_path):
+            kept = [edge for edge in self._edges_by_path[path] if id(edge) not in dropped]
+            removed += len(self._edges_by_path[path]) - len(kept)
+            if kept or path in self.project.files:
+                self._edges_by_path[path] = kept
+            else:
+                del self._edges_by_path[path]
+        self._flatten_edges()
+        return removed
+
+    # ------------------------------------------------------------------
+    # Type evaluation
+    # ------------------------------------------------------------------
+
+    def _imports(self, path: str) -> Dict[str, str]:
+        """Return import name -> import path for a file (default and alias imports)."""
+        return file_imports(self.project.files[path])
+
+    def _named(self, type_text: str, path: str) -> Optional[Tuple[str, ...]]:
+        """
+        Resolve a type expression to a named type.
+
+        Returns:
+            ("project", pkg_dir, name), ("external", import_path, name) or None
+        """
+        text = type_text.strip().lstrip("*").split("[", 1)[0]
+        if not text or not re.match(r"^[A-Za-z_][\w.]*$", text):
+            return None
+        pkg_dir = os.path.dirname(path)
+        if "." not in text:
+            if (pkg_dir, text) in self.project.types:
+                return ("project", pkg_dir, text)
+            return None
+        qualifier, name = text.split(".", 1)
+        import_path = self._imports(path).get(qualifier)
+        if import_path is None:
+            return None
+        target_dir = self.project.import_dir(import_path, path)
+        if target_dir is not None:
+            return ("project", target_dir, name)
+        return ("external", import_path, name)
+
+    def _source_type(self, source: Optional[Dict[str, Any]], ctx: Dict[str, Any],
+                     depth: int = 0) -> Optional[Tuple[str, str]]:
+        """Evaluate a local/var source description to a (type text, file path) pair."""
+        if not source or depth > 12:
+            return None
+        if "type" in source:
+            return source["type"], ctx["path"]
+        if "chain" in source:
+            value = self._eval_chain(source["chain"], ctx, depth + 1)
+            if value and value[0] == "value":
+                return value[1]
+            return None
+        if "tuple" in source:
+            inner = source["tuple"]
+            if "chain" in inner and inner["chain"] and inner["chain"][-1] == "()":
+                value = self._eval_chain(inner["chain"][:-1], ctx, depth + 1)
+                if value and value[0] == "func":
+                    return self._result_type(value, source["index"])
+            if source["index"] == 0:
+                return self._source_type(inner, ctx, depth + 1)
+            return None
+        if "range" in source:
+            container = self._source_type(source["range"], ctx, depth + 1)
+            if container is None:
+                return None
+            element = _element_type(container[0], source["index"])
+            return (element, container[1]) if element else None
+        if "address_of" in source:
+            inner = self._source_type(source["address_of"], ctx, depth + 1)
+            return ("*" + inner[0], inner[1]) if inner else None
+        return None
+
+    def _result_type(self, func_value: Tuple[Any, ...], index: int = 0) -> Optional[Tuple[str, str]]:
+        """Return the type of result `index` of a resolved function."""
+        if func_value[1] != "project":
+            return None
+        key = func_value[2]
+        symbol = self.functions.get(key)
+        if not symbol or index >= len(symbol.get("results", [])):
+            return None
+        return symbol["results"][index]["type"], self.nodes[key]["path"]
+
+    def _member(self, value_type: Tuple[str, str], member: str) -> Optional[Tuple[Any, ...]]:
+        """Resolve `value.member` to a method or a field value."""
+        named = self._named(*value_type)
+        if named is None:
+            return None
+        if named[0] == "external":
+            return ("func", "external", f"{named[1]}.{named[2]}.{member}")
+        _, pkg_dir, type_name = named
+        if (pkg_dir, type_name) not in self.project.types:
+            return None
+        members = self.project.member_set(pkg_dir, type_name, pointer=True)
+        method = members["methods"].get(member)
+        if method is not None:
+            return ("func", "project", self.method_key(method))
+        field = members["fields"].get(member)
+        if field is not None:
+            return ("value", (field["field_type"], field["path"]))
+        return None
+
+    def _eval_chain(self, chain: List[str], ctx: Dict[str, Any], depth: int = 0) -> Optional[Tuple[Any, ...]]:
+        """
+        Evaluate a selector chain from a function body.
+
+        Returns one of:
+            ("value", (type text, path))
+            ("func", "project", node key) / ("func", "external", qualified name)
+            ("package", "project", pkg_dir) / ("package", "external", import path)
+            None when the chain cannot be resolved statically
+        """
+        if not chain or depth > 12:
+            return None
+        head = chain[0]
+        path = ctx["path"]
+        pkg_dir = os.path.dirname(path)
+        current: Optional[Tuple[Any, ...]] = None
+
+        if head in ctx["locals"]:
+            if head in ctx["resolving"]:
+                return None
+            ctx["resolving"].add(head)
+            try:
+                value_type = self._source_type(ctx["locals"][head], ctx, depth + 1)
+            finally:
+                ctx["resolving"].discard(head)
+            current = ("value", value_type) if value_type else None
+        elif head in self._imports(path):
+            import_path = self._imports(path)[head]
+            target_dir = self.project.import_dir(import_path, path)
+            current = ("package", "project", target_dir) if target_dir else ("package", "external", import_path)
+        else:
+            current = self._package_member(pkg_dir, head, depth)
+
+        for element in chain[1:]:
+            if current is None:
+                return None
+            kind = current[0]
+            if element == "()":
+                current = ("value", self._result_type(current)) if kind == "func" else None
+                if current is not None and current[1] is None:
+                    current = None
+            elif element == "[]":
+                if kind != "value":
+                    return None
+                element_type = _element_type(current[1][0], 1)
+                current = ("value", (element_type, current[1][1])) if element_type else None
+            elif kind == "package":
+                if current[1] == "external":
+                    current = ("func", "external", f"{current[2]}.{element}")
+                else:
+                    current = self._package_member(current[2], element, depth)
+            elif kind == "value":
+                current = self._member(current[1], element)
+            else:
+                return None
+        return current
+
+    def _package_member(self, pkg_dir: str, name: str, depth: int) -> Optional[Tuple[Any, ...]]:
+        """Resolve a package-level function or variable by name."""
+        if (pkg_dir, name) in self.functions:
+            return ("func", "project", (pkg_dir, name))
+        if (pkg_dir, name) in self.package_vars:
+            source, path = self.package_vars[(pkg_dir, name)]
+            ctx = {"path": path, "locals": {}, "resolving": set()}
+            value_type = self._source_type(source, ctx, depth + 1)
+            return ("value", value_type) if value_type else None
+        return None
+
+    def evaluate(self, path: str, func: Dict[str, Any], chain: List[str]) -> Optional[Tuple[Any, ...]]:
+        """Evaluate a selector chain in the scope of one function (see _eval_chain)."""
+        ctx = {"path": path, "locals": func.get("locals", {}), "resolving": set()}
+        return self._eval_chain(chain, ctx)
+
+    def type_of(self, path: str, func: Dict[str, Any], source: Optional[Dict[str, Any]],
+                elements: bool = False) -> Optional[Tuple[str, ...]]:
+        """
+        The named type a value source (a local's, or a call argument's)
+        evaluates to in one function, as _named resolves it; with elements,
+        slices, arrays and maps give their element type.
+        """
+        ctx = {"path": path, "locals": func.get("locals", {}), "resolving": set()}
+        value_type = self._source_type(source, ctx)
+        if value_type is None:
+            return None
+        text = value_type[0]
+        while elements and text.lstrip("*").startswith(("[", "map[")):
+            text = _element_type(text, 1) or ""
+        return self._named(text, value_type[1])
+
+    def value_type(self, path: str, func: Dict[str, Any], source: Optional[Dict[str, Any]]) -> Optional[str]:
+        """The type a value source evaluates to in one function, as written (`interface{}`, `*User`), or None."""
+        ctx = {"path": path, "locals": func.get("locals", {}), "resolving": set()}
+        value_type = self._source_type(source, ctx)
+        return value_type[0] if value_type else None
+
+    def field_of(self, path: str, func: Dict[str, Any], chain: List[str]) -> Optional[Dict[str, Any]]:
+        """The struct field symbol a selector chain ends in (`s.jobs` -> field jobs of s's type), or None."""
+        member = self.member_of(path, func, chain)
+        return member if member is not None and member["type"] == "field" else None
+
+    def member_of(self, path: str, func: Dict[str, Any], chain: List[str]) -> Optional[Dict[str, Any]]:
+        """The field or method symbol a selector chain ends in (`s.Close` -> method Close of s's type), or None."""
+        if len(chain) < 2:
+            return None
+        value = self.evaluate(path, func, chain[:-1])
+        if value is None or value[0] != "value":
+            return None
+        named = self._named(*value[1])
+        if named is None or named[0] != "project" or (named[1], named[2]) not in self.project.types:
+            return None
+        members = self.project.member_set(named[1], named[2], pointer=True)
+        return members["fields"].get(chain[-1]) or members["methods"].get(chain[-1])
+
+    def receiver_of(self, path: str, func: Dict[str, Any], chain: List[str]) -> Optional[Tuple[str, ...]]:
+        """The named type the receiver of a selector chain has (`s.svc.GetUser` -> s.svc's), as _named resolves it."""
+        if len(chain) < 2:
+            return None
+        value = self.evaluate(path, func, chain[:-1])
+        if value is None or value[0] != "value":
+            return None
+        return self._named(*value[1])
+
+    def constant_value(self, path: str, chain: List[str]) -> Optional[Any]:
+        """Return the folded value of a package constant referenced as `Name` or `pkg.Name`."""
+        pkg_dir = os.path.dirname(path)
+        if len(chain) == 2:
+            import_path = self._imports(path).get(chain[0])
+            pkg_dir = self.project.import_dir(import_path, path) if import_path else None
+            chain = chain[1:]
+        if pkg_dir is None or len(chain) != 1 or pkg_dir not in self.project.packages:
+            return None
+        for file_path in self.project.packages[pkg_dir]["files"]:
+            for symbol in self.project.files[file_path]["symbols"]:
+                if symbol["type"] == "constant" and symbol["name"] == chain[0] and symbol.get("evaluated"):
+                    return symbol["value"]
+        return None
+
+    # ------------------------------------------------------------------
+    # Edges
+    # ------------------------------------------------------------------
+
+    def _collect_edges(self, path: str, func: Dict[str, Any], edges: List[Dict[str, Any]]):
+        pkg_dir = os.path.dirname(path)
+        caller = (pkg_dir, f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"])
+        if caller not in self.nodes:
+            return
+        ctx = {"path": path, "locals": func.get("locals", {}), "resolving": set()}
+        seen = set()
+
+        def add(target, kind, site):
+            callee = target[2] if target[1] == "project" else ("", target[2])
+            marker = (callee, kind, site["line"], site["column"])
+            if marker in seen:
+                return
+            seen.add(marker)
+            edges.append({
+                "caller": caller,
+                "callee": callee,
+                "external": target[1] == "external",
+                "kind": kind,
+                "path": path,
+                "line": site["line"],
+                "column": site["column"],
+            })
+
+        for call in func.get("calls", []):
+            target = self._eval_chain(call["chain"], ctx)
+            if target is not None and target[0] == "func":
+                add(target, call["kind"], call)
+
+        for ref in func.get("value_refs", []):
+            if ref["chain"][0] in _BUILTIN_VALUES:
+                continue
+            target = self._eval_chain(ref["chain"], ctx)
+            if target is not None and target[0] == "func" and target[1] == "project":
+                add(target, "reference", ref)
+
+    # ------------------------------------------------------------------
+    # Queries
+    # ------------------------------------------------------------------
+
+    def find_nodes(self, symbol: str, path: Optional[str] = None) -> List[Tuple[str, str]]:
+        """Return node keys named `symbol` ("Func" or "Type.Method")."""
+        matches = []
+        for key, node in sorted(self.nodes.items()):
+            if key[1] != symbol:
+                continue
+            if path and node["path"] != path and key[0] != path.rstrip(os.sep) and \
+                    not any(v["path"] == path for v in node.get("variants", ())):
+                continue
+            matches.append(key)
+        return matches
+
+    @staticmethod
+    def method_key(method: Dict[str, Any]) -> Tuple[str, str]:
+        """The node key of a method record from a method set (concrete or interface)."""
+        owner = method["receiver"]["type"] if method.get("receiver") else method["container"]
+        return os.path.dirname(method["path"]), f"{owner}.{method['name']}"
+
+    def interface_links(self, key: Tuple[str, str]) -> List[Tuple[Tuple[str, str], Dict[str, Any]]]:
+        """
+        The methods a call through an interface connects a method node to.
+
+        For an interface method Service.GetUser: the GetUser of every type
+        implementing Service, as {"implementation": "*UserService"}. For a
+        concrete method UserService.GetUser: GetUser of every interface whose
+        implementers include UserService (or *UserService) with this very
+        method - promoted ones too - as {"interface": "Service"}. Matching goes
+        through the implementation map, so a GetUser on a type that does not
+        implement the interface is not linked.
+        """
+        if "." not in key[1] or key not in self.nodes:
+            return []
+        owner, name = key[1].rsplit(".", 1)
+        owner_key = self.project.resolve_alias_key((key[0], owner))
+        owner_symbol = self.project.types.get(owner_key)
+        if owner_symbol is None:
+            return []
+        if owner_symbol["type"] == "interface":
+            ifaces = [owner_key]
+        else:
+            ifaces = [k for k, symbol in sorted(self.project.types.items())
+                      if symbol["type"] == "interface" and not symbol.get("alias")
+                      and name in self.project.interface_method_set(*k)[0]]
+
+        links: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        for iface_key in ifaces:
+            spec = self.project.interface_method_set(*iface_key)[0].get(name)
+            if spec is None:
+                continue
+            iface_method = self.method_key(spec)
+            for type_key, symbol in sorted(self.project.types.items()):
+                if symbol["type"] == "interface" or symbol.get("alias"):
+                    continue
+                match = self.project.match_interface(iface_key, type_key)
+                if match is None or "satisfied_by" not in match:
+                    continue
+                method = self.project.member_set(*type_key, pointer=True)["methods"].get(name)
+                if method is None or not method.get("receiver"):
+                    continue
+                target = self.method_key(method)
+                if owner_symbol["type"] == "interface":
+                    # Named after the type declaring the method rather than one embedding it
+                    if target in self.nodes and target != key and \
+                            (target not in links or target == (type_key[0], f"{type_key[1]}.{name}")):
+                        links[target] = {"implementation": match["satisfied_by"]}
+                elif target == key and iface_method in self.nodes:
+                    links.setdefault(iface_method, {"interface": iface_key[1]})
+        return sorted(links.items())
+
+    def _describe(self, key: Tuple[str, str], external: bool) -> Dict[str, Any]:
+        if external:
+            qualified = key[1]
+            return {"name": qualified.rsplit("/", 1)[-1].split(".", 1)[-1],
+                    "qualified_name": qualified, "external": True}
+        return dict(self.nodes[key])
+
+    def walk(self, start: Tuple[str, str], depth: int, forward: bool,
+             keep: Optional[Callable[[str], bool]] = None
+             ) -> Iterator[Tuple[int, Tuple[str, str], Tuple[str, str], Dict[str, Any]]]:
+        """
+        Breadth-first over callees (forward) or callers of `start`.
+
+        Yields (level, node reached from, node reached, edge) for every edge
+        followed; external callees are reported but not followed. With keep,
+        a caller (or project callee) whose file it rejects is neither
+        reported nor walked through.
+        """
+        frontier = [start]
+        visited = {start}
+        for level in range(1, max(depth, 1) + 1):
+            next_frontier = []
+            for key in frontier:
+                for edge in self.edges:
+                    if (edge["caller"] if forward else edge["callee"]) != key:
+                        continue
+                    if not forward and edge["external"]:
+                        continue
+                    other = edge["callee"] if forward else edge["caller"]
+                    if keep is not None and not (forward and edge["external"]) and \
+                            not keep(self.nodes[other]["path"] if forward else edge["path"]):
+                        continue
+                    yield level, key, other, edge
+                    if not (forward and edge["external"]) and other not in visited:
+                        visited.add(other)
+                        next_frontier.append(other)
+            frontier = next_frontier
+
+    def _walk(self, start: Tuple[str, str], depth: int, forward: bool,
+              keep: Optional[Callable[[str], bool]] = None) -> List[Dict[str, Any]]:
+        results = []
+        for level, key, other, edge in self.walk(start, depth, forward, keep):
+            entry = self._describe(other, edge["external"] if forward else False)
+            entry.update({
+                "kind": edge["kind"],
+                "call_site": {"path": edge["path"], "line": edge["line"], "column": edge["column"]},
+                "depth": level,
+            })
+            if level > 1:
+                entry["via"] = key[1]
+            results.append(entry)
+        return results
+
+    def callers(self, key: Tuple[str, str], depth: int = 1,
+                keep: Optional[Callable[[str], bool]] = None) -> List[Dict[str, Any]]:
+        """Functions that call or reference `key`, up to `depth` levels away (in files keep accepts)."""
+        return self._walk(key, depth, forward=False, keep=keep)
+
+    def callees(self, key: Tuple[str, str], depth: int = 1) -> List[Dict[str, Any]]:
+        """Functions called or referenced by `key`, up to `depth` levels away."""
+        return self._walk(key, depth, forward=True)
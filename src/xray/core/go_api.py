@@ -0,0 +1,238 @@
+"""The exported API of Go packages, and how it changed between two versions.
+
+The surface of a package is what another package can name: exported
+functions, constants, variables and types, the exported methods of
+exported types, their exported fields, and the methods (and embedded
+interfaces) of exported interfaces. main packages and _test.go files have
+none. Every declaration gets a canonical, whitespace-normalized signature
+in the form go doc prints it - method receivers without their variable
+name - sorted by name within its package.
+
+Comparing two surfaces by declaration name gives:
+
+    addition      a new declaration (or package)
+    removal       a declaration (or package) that is gone
+    incompatible  a changed declaration that can break code using it:
+                  other parameter or result types, another field type or
+                  type definition, a constant with another value, a value
+                  receiver turned into a pointer receiver, or a method added
+                  to an interface that other packages can implement
+    compatible    a changed declaration that cannot: a pointer receiver
+                  turned into a value receiver
+
+Parameter names are not part of the API; renaming one is no change.
+"""
+
+import os
+import re
+from typing import Any, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoProject
+
+CHANGE_KINDS = ("addition", "removal", "incompatible", "compatible")
+
+
+def _normalized(text: str) -> str:
+    return re.sub(r"\s+", " ", text).strip()
+
+
+def is_exported(name: str) -> bool:
+    return name[:1].isupper()
+
+
+def _types(items: List[Dict[str, Any]]) -> str:
+    return ", ".join(_normalized(item["type"]) for item in items)
+
+
+def _type_params(symbol: Dict[str, Any]) -> str:
+    params = symbol.get("typeParams")
+    if not params:
+        return ""
+    return "[" + ", ".join(_normalized(f"{p['name']} {p.get('constraint', '')}") for p in params) + "]"
+
+
+def _entry(name: str, kind: str, signature: str, key: str, path: str, symbol: Dict[str, Any]) -> Dict[str, Any]:
+    return {"name": name, "kind": kind, "signature": _normalized(signature), "key": key,
+            "path": path, "start_line": symbol["start_line"]}
+
+
+def package_surface(project: GoProject, pkg_dir: str) -> List[Dict[str, Any]]:
+    """The exported declarations of one package, sorted by name."""
+    entries: Dict[str, Dict[str, Any]] = {}
+    exported_types = set()
+    interfaces = set()
+    symbols = []
+    for path in sorted(project.packages[pkg_dir]["files"]):
+        if path.endswith("_test.go"):
+            continue
+        for symbol in project.files[path]["symbols"]:
+            symbols.append((path, symbol))
+            if symbol["type"] in ("struct", "interface", "type") and not symbol.get("container") \
+                    and is_exported(symbol["name"]):
+                exported_types.add(symbol["name"])
+                if symbol["type"] == "interface":
+                    interfaces.add(symbol["name"])
+
+    for path, symbol in symbols:
+        name, kind = symbol["name"], symbol["type"]
+        container = symbol.get("container")
+        receiver = symbol.get("receiver")
+        signature = symbol.get("signature", "")
+        if kind == "function" and is_exported(name):
+            key = f"func{_type_params(symbol)}({_types(symbol.get('params', []))}) ({_types(symbol.get('results', []))})"
+            entry = _entry(name, "func", signature, key, path, symbol)
+        elif kind == "method" and receiver and is_exported(name) and receiver["type"] in exported_types:
+            if receiver.get("name"):
+                signature = re.sub(r"^func\s*\(\s*" + re.escape(receiver["name"]) + r"\s+", "func (", signature)
+            key = (f"{'*' if receiver.get('pointer') else ''}({_types(symbol.get('params', []))}) "
+                   f"({_types(symbol.get('results', []))})")
+            entry = _entry(f"{receiver['type']}.{name}", "method", signature, key, path, symbol)
+            entry["pointer_receiver"] = bool(receiver.get("pointer"))
+        elif kind == "method" and container in interfaces and is_exported(name):
+            key = f"({_types(symbol.get('params', []))}) ({_types(symbol.get('results', []))})"
+            entry = _entry(f"{container}.{name}", "interface_method", signature, key, path, symbol)
+        elif kind == "field" and container in exported_types and (
+                is_exported(name) or (symbol.get("embedded") and is_exported(name.split(".")[-1]))):
+            key = ("embedded " if symbol.get("embedded") else "") + _normalized(symbol.get("field_type", ""))
+            entry = _entry(f"{container}.{name}", "field", signature, key, path, symbol)
+        elif kind in ("struct", "interface", "type") and not container and is_exported(name):
+            entry = _entry(name, "type", signature, _normalized(signature), path, symbol)
+            for embed in symbol.get("embeds", []) if kind == "interface" else []:
+                embedded = _entry(f"{name}.{embed}", "interface_embed", embed, _normalized(embed), path, symbol)
+                entries.setdefault(embedded["name"], embedded)
+        elif kind == "constant" and not container and is_exported(name):
+            entry = _entry(name, "const", signature, _normalized(signature), path, symbol)
+        elif kind == "variable" and not container and is_exported(name):
+            entry = _entry(name, "var", signature, _normalized(symbol.get("var_type") or signature), path, symbol)
+        else:
+            continue
+        # The first of several build-constrained declarations stands for all
+        entries.setdefault(entry["name"], entry)
+    return sorted(entries.values(), key=lambda e: e["name"])
+
+
+def _sealed(project: GoProject, pkg_dir: str, interface: str) -> bool:
+    """An interface with an unexported method cannot be implemented outside its package."""
+    return any(not is_exported(m["name"]) for m in project.interface_methods.get((pkg_dir, interface), []))
+
+
+def api_surface(project: GoProject, root: str, path: Optional[str] = None) -> Dict[str, Any]:
+    """
+    The exported surface of every non-main package, optionally under path.
+
+    Returns:
+        {"packages": [{"directory", "import_path", "package", "internal",
+          "declarations": [{"name", "kind", "signature", "path", "start_line", ...}]}],
+         "total_count", "declarations"}
+    """
+    packages = []
+    for pkg_dir in sorted(project.packages):
+        if path and pkg_dir != path and not pkg_dir.startswith(path.rstrip(os.sep) + os.sep):
+            continue
+        info = project.packages[pkg_dir]
+        if info["name"] == "main" or all(f.endswith("_test.go") for f in info["files"]):
+            continue
+        directory = os.path.relpath(pkg_dir, root).replace(os.sep, "/")
+        declarations = package_surface(project, pkg_dir)
+        for entry in declarations:
+            if entry["kind"] in ("interface_method", "interface_embed"):
+                entry["sealed"] = _sealed(project, pkg_dir, entry["name"].split(".")[0])
+        packages.append({
+            "directory": directory,
+            "import_path": project.import_path(pkg_dir),
+            "package": info["name"],
+            "internal": "internal" in directory.split("/"),
+            "declarations": declarations,
+        })
+    return {
+        "packages": packages,
+        "total_count": len(packages),
+        "declarations": sum(len(p["declarations"]) for p in packages),
+    }
+
+
+def _classify(old: Dict[str, Any], new: Dict[str, Any]) -> Optional[Tuple[str, str]]:
+    """(change kind, reason) for a declaration present on both sides, None if its API is the same."""
+    if old["key"] == new["key"]:
+        return None
+    if old["kind"] != new["kind"]:
+        return "incompatible", f"{old['kind']} became {new['kind']}"
+    if old["kind"] == "method" and old["key"].lstrip("*") == new["key"].lstrip("*"):
+        if new["pointer_receiver"]:
+            return "incompatible", "receiver changed to a pointer: values no longer have the method"
+        return "compatible", "receiver changed from a pointer to a value"
+    reasons = {
+        "func": "signature changed",
+        "method": "signature changed",
+        "interface_method": "signature changed",
+        "field": "field type changed",
+        "type": "type definition changed",
+        "interface_embed": "embedded interface changed",
+        "const": "constant type or value changed",
+        "var": "variable type changed",
+    }
+    return "incompatible", reasons[old["kind"]]
+
+
+def _change(package: str, change: str, old: Optional[Dict[str, Any]], new: Optional[Dict[str, Any]],
+            reason: Optional[str] = None) -> Dict[str, Any]:
+    entry = new or old
+    result: Dict[str, Any] = {"package": package, "name": entry["name"], "kind": entry["kind"], "change": change}
+    if old is not None:
+        result["old_signature"] = old["signature"]
+    if new is not None:
+        result["new_signature"] = new["signature"]
+        result["path"] = new["path"]
+        result["start_line"] = new["start_line"]
+    if reason:
+        result["reason"] = reason
+    return result
+
+
+def api_diff(base: Dict[str, Any], head: Dict[str, Any]) -> Dict[str, Any]:
+    """
+    Compare two api_surface results package by package (matched by directory).
+
+    Returns:
+        {"changes": [{"package", "name", "kind", "change", "old_signature",
+          "new_signature", "reason"}], "summary": {change kind: count},
+         "compatible": no removal or incompatible change}
+    """
+    old_packages = {p["directory"]: p for p in base["packages"]}
+    new_packages = {p["directory"]: p for p in head["packages"]}
+    changes = []
+    for directory in sorted(set(old_packages) | set(new_packages)):
+        old_pkg, new_pkg = old_packages.get(directory), new_packages.get(directory)
+        if old_pkg is None or new_pkg is None:
+            pkg = new_pkg or old_pkg
+            changes.append({"package": directory, "name": pkg["import_path"] or directory, "kind": "package",
+                            "change": "addition" if old_pkg is None else "removal",
+                            "declarations": len(pkg["declarations"])})
+            continue
+        old = {e["name"]: e for e in old_pkg["declarations"]}
+        new = {e["name"]: e for e in new_pkg["declarations"]}
+        for name in sorted(set(old) | set(new)):
+            before, after = old.get(name), new.get(name)
+            if before is None:
+                owner = name.split(".")[0]
+                grows = after["kind"] in ("interface_method", "interface_embed") and owner in old \
+                    and not after.get("sealed", False)
+                if grows:
+                    changes.append(_change(directory, "incompatible", None, after,
+                                           "method added to an interface other packages can implement"))
+                else:
+                    changes.append(_change(directory, "addition", None, after))
+            elif after is None:
+                changes.append(_change(directory, "removal", before, None))
+            else:
+                classified = _classify(before, after)
+                if classified:
+                    changes.append(_change(directory, classified[0], before, after, classified[1]))
+
+    summary = {kind: sum(1 for c in changes if c["change"] == kind) for kind in CHANGE_KINDS}
+    return {
+        "changes": changes,
+        "total_count": len(changes),
+        "summary": summary,
+        "compatible": not summary["removal"] and not summary["incompatible"],
+    }
@@ -0,0 +1,290 @@
+"""Which parts of a Go package's exported API other packages use, and which they do not.
+
+Every exported declaration of the package (see go_api.package_surface) is
+counted against the packages referencing it:
+
+    functions, types, constants, variables   `pkg.Name` selectors of files
+                                             importing the package (the
+                                             parser's qualified_refs), and
+                                             bare names under a dot import
+    methods, interface methods               calls and method values the
+                                             call graph resolves to them
+
+Fields are left out (see field_usages). A consuming package is named by
+import path; an external test package in the package's own directory
+(package foo_test) is a consumer of its own, "<import path>_test". Every
+file counts whatever its build constraints, so a symbol only a
+windows-tagged file uses is used; a consumer all of whose references are
+in files with build constraints lists them. References from _test.go files
+count apart: a symbol only tests of other packages use is "test_only".
+
+    wide      used by at least `many` packages (non-test): change carefully
+    several   used by two or more, fewer than `many`
+    single    used by exactly one: a candidate to move there
+    test_only used by tests of other packages only
+    internal  used inside its own package only
+    none      used nowhere in the project
+
+internal and none symbols are unexport candidates unless something else
+needs the name exported: a method another type's interface (or the
+standard library, String, MarshalJSON...) calls through, an interface
+method implementations elsewhere would lose, or a type whose methods other
+packages call. References from outside the project - other modules
+importing a library - are not seen.
+"""
+
+import os
+from typing import Any, Callable, Dict, List, Optional, Set, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+from xray.core.go_api import package_surface
+from xray.core.go_unused import WELL_KNOWN_METHODS
+
+DEFAULT_MANY = 3
+USAGE_SORTS = ("references", "packages", "name")
+USAGE_KINDS = ("wide", "several", "single", "test_only", "internal", "none")
+
+USAGE_COLUMNS = ("symbol", "kind", "usage", "consumer", "references", "test_only", "package_count",
+                 "internal_references", "unexport_candidate", "path", "start_line")
+
+# The kinds of package_surface counted; fields and interface embeds are not
+_COUNTED = ("func", "method", "interface_method", "type", "const", "var")
+
+
+class ApiUsage:
+    """References to the exported declarations of one package, by consuming package."""
+
+    def __init__(self, graph: GoCallGraph, module: Optional[str] = None):
+        self.graph = graph
+        self.project = graph.project
+        self.module = module
+
+    def _package_id(self, pkg_dir: str) -> str:
+        import_path = self.project.import_path(pkg_dir)
+        if import_path:
+            return import_path
+        root = self.project.root or ""
+        rel = os.path.relpath(pkg_dir, root).replace(os.sep, "/") if root else pkg_dir
+        rel = "" if rel == "." else rel
+        if self.module:
+            return f"{self.module}/{rel}" if rel else self.module
+        return rel or "."
+
+    def _consumer(self, path: str, pkg_dir: str) -> Optional[str]:
+        """The package a file of the project belongs to, None for the target package itself."""
+        file_dir = os.path.dirname(path)
+        clause = self.project.files.get(path, {}).get("package", "")
+        if file_dir == pkg_dir:
+            return f"{self._package_id(pkg_dir)}_test" if clause.endswith("_test") else None
+        return self._package_id(file_dir)
+
+    def _package_refs(self, pkg_dir: str, names: Set[str]) -> List[Tuple[str, str]]:
+        """(name, file) of every pkg.Name selector, and dot-imported bare name, of the package's declarations."""
+        refs = []
+        for path, parsed in self.project.files.items():
+            if os.path.dirname(path) == pkg_dir and not parsed.get("package", "").endswith("_test"):
+                continue
+            imports = [imp for imp in parsed.get("imports", [])
+                       if self.project.import_dir(imp["path"], path) == pkg_dir]
+            if not imports:
+                continue
+            paths = {imp["path"] for imp in imports}
+            for ref in parsed.get("qualified_refs", []):
+                if ref["package"] in paths and ref["name"] in names:
+                    refs.append((ref["name"], path))
+            if any(imp["kind"] == "dot" for imp in imports):
+                counts = parsed.get("identifiers", {})
+                for name in parsed.get("unqualified_exported", []):
+                    if name in names:
+                        refs.extend((name, path) for _ in range(counts.get(name, 1)))
+        return refs
+
+    def _internal_counts(self, pkg_dir: str) -> Dict[str, int]:
+        """Mentions of each name in the package's own files, its declarations left out."""
+        counts: Dict[str, int] = {}
+        for path in self.project.packages[pkg_dir]["files"]:
+            parsed = self.project.files[path]
+            if parsed.get("package", "").endswith("_test"):
+                continue
+            for name, count in parsed.get("identifiers", {}).items():
+                counts[name] = counts.get(name, 0) + count
+            for symbol in parsed["symbols"]:
+                if symbol["name"] in counts:
+                    counts[symbol["name"]] -= 1
+        return counts
+
+    def _interface_method(self, pkg_dir: str, receiver: str, method: str) -> bool:
+        """Whether a method is called through an interface: a well-known one, or one a project interface requires."""
+        if method in WELL_KNOWN_METHODS:
+            return True
+        for key, symbol in self.project.types.items():
+            if symbol["type"] != "interface":
+                continue
+            required, _ = self.project.interface_method_set(*key)
+            if method in required:
+                match = self.project.match_interface(key, (pkg_dir, receiver))
+                if match and "satisfied_by" in match:
+                    return True
+        return False
+
+    def usage(self, pkg_dir: str, include_tests: bool = True, many: int = DEFAULT_MANY,
+              sort_by: str = "references", keep: Optional[Callable[[str], bool]] = None) -> Dict[str, Any]:
+        """
+        Every exported declaration of a package with the packages
+        referencing it, how widely it is used and whether it could be
+        unexported.
+
+        Args:
+            pkg_dir: The package directory
+            include_tests: Count the references of _test.go files (as test_only when only tests use a symbol)
+            many: Consuming packages that make a symbol "wide"
+            sort_by: One of USAGE_SORTS; references and packages sort the most used first
+            keep: Only the consuming files it accepts
+        """
+        if sort_by not in USAGE_SORTS:
+            raise ValueError(f"sort_by must be one of {', '.join(USAGE_SORTS)}")
+        if many < 2:
+            raise ValueError("many must be at least 2")
+        declared = [e for e in package_surface(self.project, pkg_dir) if e["kind"] in _COUNTED]
+        kept = keep or (lambda path: True)
+
+        def counted(path: str) -> bool:
+            return kept(path) and (include_tests or not path.endswith("_test.go"))
+
+        # name -> consumer -> files referencing it, once per reference
+        found: Dict[str, Dict[str, List[str]]] = {}
+        for name, path in self._package_refs(pkg_dir, {e["name"] for e in declared if "." not in e["name"]}):
+            consumer = self._consumer(path, pkg_dir)
+            if consumer is not None and counted(path):
+                found.setdefault(name, {}).setdefault(consumer, []).append(path)
+        internal_methods: Dict[str, int] = {}
+        methods = {e["name"] for e in declared if "." in e["name"]}
+        for edge in self.graph.edges:
+            if edge["external"] or edge["callee"][0] != pkg_dir or edge["callee"][1] not in methods:
+                continue
+            consumer = self._consumer(edge["path"], pkg_dir)
+            if consumer is None:
+                internal_methods[edge["callee"][1]] = internal_methods.get(edge["callee"][1], 0) + 1
+            elif counted(edge["path"]):
+                found.setdefault(edge["callee"][1], {}).setdefault(consumer, []).append(edge["path"])
+        internal = self._internal_counts(pkg_dir)
+        own_id = self._package_id(pkg_dir)
+        # Types whose methods other packages call cannot lose their exported name
+        used_types = {name.split(".")[0] for name in found if "." in name}
+
+        symbols = []
+        for entry in declared:
+            name = entry["name"]
+            consumers = []
+            for consumer, paths in sorted(found.get(name, {}).items()):
+                item: Dict[str, Any] = {"package": consumer, "references": len(paths)}
+                if all(p.endswith("_test.go") for p in paths):
+                    item["test_only"] = True
+                constraints = [self.project.files[p].get("buildConstraints") for p in paths]
+                if all(constraints):
+                    item["buildConstraints"] = sorted(set(constraints))
+                consumers.append(item)
+            packages = [c for c in consumers if not c.get("test_only")]
+            inside = internal_methods.get(name, 0) if "." in name else max(0, internal.get(name, 0))
+            if len(packages) >= many:
+                usage = "wide"
+            elif len(packages) > 1:
+                usage = "several"
+            elif packages:
+                usage = "single"
+            elif consumers:
+                usage = "test_only"
+            else:
+                usage = "internal" if inside else "none"
+            symbol: Dict[str, Any] = {
+                "name": name, "kind": entry["kind"], "signature": entry["signature"],
+                "path": entry["path"], "start_line": entry["start_line"],
+                "references": sum(c["references"] for c in consumers),
+                "package_count": len(packages),
+                "internal_references": inside,
+                "usage": usage,
+                "consumers": consumers,
+            }
+            constraints = self.project.files[entry["path"]].get("buildConstraints")
+            if constraints:
+                symbol["buildConstraints"] = constraints
+            if usage in ("internal", "none"):
+                reason = self._keep_exported(pkg_dir, entry, used_types)
+                symbol["unexport_candidate"] = reason is None
+                if reason:
+                    symbol["keep_exported"] = reason
+            elif usage == "test_only":
+                symbol["unexport_candidate"] = False
+                tests = sorted({c["package"] for c in consumers})
+                symbol["keep_exported"] = f"tests in {', '.join(tests)} use it"
+            symbols.append(symbol)
+
+        if sort_by == "name":
+            symbols.sort(key=lambda s: s["name"])
+        elif sort_by == "packages":
+            symbols.sort(key=lambda s: (-s["package_count"], -s["references"], s["name"]))
+        else:
+            symbols.sort(key=lambda s: (-s["references"], -s["package_count"], s["name"]))
+        by_consumer: Dict[str, Dict[str, int]] = {}
+        for symbol in symbols:
+            for consumer in symbol["consumers"]:
+                totals = by_consumer.setdefault(consumer["package"], {"symbols": 0, "references": 0})
+                totals["symbols"] += 1
+                totals["references"] += consumer["references"]
+        wide = [s for s in symbols if s["usage"] == "wide"]
+        single = [s for s in symbols if s["usage"] == "single"]
+        candidates = [s["name"] for s in symbols if s.get("unexport_candidate")]
+        result: Dict[str, Any] = {
+            "package": own_id,
+            "directory": os.path.relpath(pkg_dir, self.project.root).replace(os.sep, "/")
+            if self.project.root else pkg_dir,
+            "symbols": symbols,
+            "total_count": len(symbols),
+            "consumers": [{"package": package, **totals} for package, totals in
+                          sorted(by_consumer.items(), key=lambda item: (-item[1]["references"], item[0]))],
+            "summary": {
+                "wide": [s["name"] for s in wide],
+                "single_consumer": [{"name": s["name"], "package": next(
+                    c["package"] for c in s["consumers"] if not c.get("test_only")),
+                                     "internal_references": s["internal_references"]} for s in single],
+                "unexport_candidates": candidates,
+                "keep_exported": [{"name": s["name"], "reason": s["keep_exported"]}
+                                  for s in symbols if s.get("keep_exported")],
+            },
+            "many": many,
+        }
+        if candidates:
+            result["message"] = (f"{len(candidates)} of {len(symbols)} exported symbols have no references "
+                                 f"from other packages of the project and could be unexported")
+        return result
+
+    def _keep_exported(self, pkg_dir: str, entry: Dict[str, Any], used_types: Set[str]) -> Optional[str]:
+        """Why a symbol no other package references still needs its exported name, None if nothing does."""
+        if entry["kind"] == "interface_method":
+            return "an interface method: implementations in other packages would no longer satisfy it"
+        if entry["kind"] == "method":
+            receiver, method = entry["name"].split(".", 1)
+            if self._interface_method(pkg_dir, receiver, method):
+                return "satisfies an interface, through which it is called"
+        if entry["kind"] == "type" and entry["name"] in used_types:
+            return "other packages call its methods"
+        return None
+
+
+def usage_rows(result: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """The rows of USAGE_COLUMNS for a usage result: one per symbol and consumer, one per unused symbol."""
+    rows = []
+    for symbol in result["symbols"]:
+        common = {"symbol": symbol["name"], "kind": symbol["kind"], "usage": symbol["usage"],
+                  "package_count": symbol["package_count"], "internal_references": symbol["internal_references"],
+                  "unexport_candidate": symbol.get("unexport_candidate", False),
+                  "path": symbol["path"], "start_line": symbol["start_line"]}
+        for consumer in symbol["consumers"] or [None]:
+            row = dict(common)
+            if consumer is not None:
+                row.update(consumer=consumer["package"], references=consumer["references"],
+                           test_only=consumer.get("test_only", False))
+            else:
+                row["references"] = 0
+            rows.append(row)
+    return rows
@@ -0,0 +1,273 @@
+"""Go build constraints - which files a build includes.
+
+A file is constrained by a `//go:build` line in its header (or, in older
+code, `// +build` lines) and by its name: x_linux.go only builds for
+GOOS=linux, x_windows_amd64.go for windows on amd64. The parser records the
+combined constraint of each file as an expression in //go:build syntax,
+"buildConstraints": "(linux || darwin) && !cgo", on the file and on its
+symbols.
+
+By default every file is indexed, and declarations that exist once per
+platform (an Open in file_unix.go and another in file_windows.go) are
+linked as variants of one symbol. A BuildContext (GOOS, GOARCH and tags)
+picks the files one build would compile instead.
+"""
+
+import re
+from typing import Any, Dict, Iterable, List, Optional, Tuple, Union
+
+from xray.core.errors import PARSE_ERROR
+
+# GOOS and GOARCH values known to `go tool dist list` (go/build/syslist.go)
+KNOWN_OS = {
+    "aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios", "js", "linux",
+    "nacl", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows", "zos",
+}
+UNIX_OS = {
+    "aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios", "linux",
+    "netbsd", "openbsd", "solaris",
+}
+KNOWN_ARCH = {
+    "386", "amd64", "amd64p32", "arm", "armbe", "arm64", "arm64be", "loong64", "mips", "mipsle",
+    "mips64", "mips64le", "mips64p32", "mips64p32le", "ppc", "ppc64", "ppc64le", "riscv", "riscv64",
+    "s390", "s390x", "sparc", "sparc64", "wasm",
+}
+
+# GOOS values that also satisfy another OS tag
+_IMPLIED_OS = {"android": "linux", "illumos": "solaris", "ios": "darwin"}
+
+_RELEASE_TAG = re.compile(r"^go1\.\d+$")
+_TOKEN = re.compile(r"\s*(&&|\|\||!|\(|\)|[\w.]+)")
+
+# ("tag", name) | ("not", expr) | ("and", left, right) | ("or", left, right)
+Expr = Tuple[Any, ...]
+
+
+class BuildConstraintError(ValueError):
+    """A //go:build or // +build line that does not parse."""
+
+    error_code = PARSE_ERROR
+
+
+def parse_expression(text: str) -> Expr:
+    """Parse a //go:build expression ("linux && (amd64 || arm64)")."""
+    tokens: List[str] = []
+    pos = 0
+    text = text.strip()
+    while pos < len(text):
+        match = _TOKEN.match(text, pos)
+        if not match:
+            raise BuildConstraintError(f"unexpected {text[pos:].strip()[:1]!r} in build constraint")
+        tokens.append(match.group(1))
+        pos = match.end()
+        while pos < len(text) and text[pos].isspace():
+            pos += 1
+    position = [0]
+
+    def peek() -> Optional[str]:
+        return tokens[position[0]] if position[0] < len(tokens) else None
+
+    def take() -> str:
+        token = peek()
+        if token is None:
+            raise BuildConstraintError("unexpected end of build constraint")
+        position[0] += 1
+        return token
+
+    def parse_or() -> Expr:
+        expr = parse_and()
+        while peek() == "||":
+            take()
+            expr = ("or", expr, parse_and())
+        return expr
+
+    def parse_and() -> Expr:
+        expr = parse_not()
+        while peek() == "&&":
+            take()
+            expr = ("and", expr, parse_not())
+        return expr
+
+    def parse_not() -> Expr:
+        token = take()
+        if token == "!":
+            return ("not", parse_not())
+        if token == "(":
+            expr = parse_or()
+            if take() != ")":
+                raise BuildConstraintError("missing ')' in build constraint")
+            return expr
+        if token in ("&&", "||", ")"):
+            raise BuildConstraintError(f"unexpected {token!r} in build constraint")
+        return ("tag", token)
+
+    expr = parse_or()
+    if peek() is not None:
+        raise BuildConstraintError(f"unexpected {peek()!r} in build constraint")
+    return expr
+
+
+def parse_plus_build(lines: Iterable[str]) -> Optional[Expr]:
+    """
+    The expression of legacy `// +build` lines: the lines are ANDed, the
+    space-separated options of a line ORed, comma-separated terms ANDed.
+    """
+    result: Optional[Expr] = None
+    for line in lines:
+        line_expr: Optional[Expr] = None
+        for option in line.split():
+            option_expr: Optional[Expr] = None
+            for term in option.split(","):
+                negated = term.startswith("!")
+                name = term.lstrip("!")
+                if not name or not re.match(r"^[\w.]+$", name):
+                    raise BuildConstraintError(f"invalid term {term!r} in +build line")
+                term_expr: Expr = ("not", ("tag", name)) if negated else ("tag", name)
+                option_expr = term_expr if option_expr is None else ("and", option_expr, term_expr)
+            if option_expr is not None:
+                line_expr = option_expr if line_expr is None else ("or", line_expr, option_expr)
+        if line_expr is not None:
+            result = line_expr if result is None else ("and", result, line_expr)
+    return result
+
+
+def format_expression(expr: Expr) -> str:
+    """Write an expression back in //go:build syntax, with only the parentheses it needs."""
+    def write(node: Expr, parent: str) -> str:
+        kind = node[0]
+        if kind == "tag":
+            return node[1]
+        if kind == "not":
+            return "!" + write(node[1], "not")
+        text = f"{write(node[1], kind)} {'&&' if kind == 'and' else '||'} {write(node[2], kind)}"
+        # || inside && or !, and && or || inside !, need grouping
+        if (kind == "or" and parent in ("and", "not")) or (kind == "and" and parent == "not"):
+            return f"({text})"
+        return text
+
+    return write(expr, "")
+
+
+def filename_expression(filename: str) -> Optional[Expr]:
+    """
+    The constraint implied by a file name, as go/build reads it: a trailing
+    _GOOS, _GOARCH or _GOOS_GOARCH (before _test), never the first word.
+    """
+    name = filename[:-3] if filename.endswith(".go") else filename
+    if name.endswith("_test"):
+        name = name[:-5]
+    index = name.find("_")
+    if index < 0:
+        return None
+    parts = name[index:].split("_")
+    if len(parts) >= 2 and parts[-2] in KNOWN_OS and parts[-1] in KNOWN_ARCH:
+        return ("and", ("tag", parts[-2]), ("tag", parts[-1]))
+    if parts[-1] in KNOWN_OS or parts[-1] in KNOWN_ARCH:
+        return ("tag", parts[-1])
+    return None
+
+
+def file_constraints(filename: Optional[str], go_build: Optional[str],
+                     plus_build: List[str]) -> Optional[str]:
+    """
+    The combined constraint of a file: its //go:build expression (or, without
+    one, its // +build lines) ANDed with the one its name implies. None for a
+    file every build includes. Raises BuildConstraintError for a header line
+    that does not parse.
+    """
+    header = parse_expression(go_build) if go_build is not None else parse_plus_build(plus_build)
+    from_name = filename_expression(filename) if filename else None
+    if header is None and from_name is None:
+        return None
+    if header is None or from_name is None:
+        return format_expression(header or from_name)
+    # x_windows.go often repeats `//go:build windows`
+    missing = [term for term in _conjuncts(from_name) if term not in _conjuncts(header)]
+    for term in missing:
+        header = ("and", header, term)
+    return format_expression(header)
+
+
+def _conjuncts(expr: Expr) -> List[Expr]:
+    """The terms of a top-level && chain."""
+    if expr[0] == "and":
+        return _conjuncts(expr[1]) + _conjuncts(expr[2])
+    return [expr]
+
+
+class BuildContext:
+    """The GOOS, GOARCH and tags of one build, for choosing between file variants."""
+
+    def __init__(self, goos: str = "linux", goarch: str = "amd64", tags: Iterable[str] = ()):
+        self.goos = goos
+        self.goarch = goarch
+        self.tags = tuple(sorted(set(tags)))
+
+    @classmethod
+    def from_dict(cls, value: Union["BuildContext", Dict[str, Any]]) -> "BuildContext":
+        """
+        Read {"goos": "windows", "goarch": "arm64", "tags": ["integration"]};
+        GOOS/GOARCH may be upper case, tags a comma-separated string, and
+        anything left out defaults to linux/amd64 without tags.
+        """
+        if isinstance(value, BuildContext):
+            return value
+        if not isinstance(value, dict):
+            raise ValueError("build_context must be an object with goos, goarch and tags")
+        fields = {key.lower(): item for key, item in value.items()}
+        unknown = set(fields) - {"goos", "goarch", "tags"}
+        if unknown:
+            raise ValueError(f"Unknown build_context field(s): {', '.join(sorted(unknown))}")
+        goos = fields.get("goos") or "linux"
+        goarch = fields.get("goarch") or "amd64"
+        if goos not in KNOWN_OS:
+            raise ValueError(f"Unknown GOOS '{goos}'")
+        if goarch not in KNOWN_ARCH:
+            raise ValueError(f"Unknown GOARCH '{goarch}'")
+        tags = fields.get("tags") or []
+        if isinstance(tags, str):
+            tags = [tag for tag in re.split(r"[,\s]+", tags) if tag]
+        return cls(goos, goarch, tags)
+
+    def key(self) -> Tuple[str, str, Tuple[str, ...]]:
+        return self.goos, self.goarch, self.tags
+
+    def describe(self) -> Dict[str, Any]:
+        return {"goos": self.goos, "goarch": self.goarch, "tags": list(self.tags)}
+
+    def satisfies(self, tag: str) -> bool:
+        """Whether a single build tag holds, as go/build's matchTag decides."""
+        if tag in (self.goos, self.goarch) or tag in self.tags:
+            return True
+        if tag == "unix":
+            return self.goos in UNIX_OS
+        if _IMPLIED_OS.get(self.goos) == tag:
+            return True
+        if tag == "gc":
+            # The gc toolchain unless gccgo was asked for
+            return "gccgo" not in self.tags
+        # Release tags: any Go version, as built by a current toolchain
+        return bool(_RELEASE_TAG.match(tag))
+
+    def evaluate(self, expr: Expr) -> bool:
+        kind = expr[0]
+        if kind == "tag":
+            return self.satisfies(expr[1])
+        if kind == "not":
+            return not self.evaluate(expr[1])
+        if kind == "and":
+            return self.evaluate(expr[1]) and self.evaluate(expr[2])
+        return self.evaluate(expr[1]) or self.evaluate(expr[2])
+
+    def matches(self, constraints: Optional[str]) -> bool:
+        """Whether a file with these buildConstraints (None: none) is part of the build."""
+        if not constraints:
+            return True
+        try:
+            return self.evaluate(parse_expression(constraints))
+        except BuildConstraintError:
+            return False
+
+    def includes(self, parsed: Dict[str, Any]) -> bool:
+        """Whether a parsed Go file is part of the build."""
+        return self.matches(parsed.get("buildConstraints"))
@@ -0,0 +1,293 @@
+"""Duplicated Go functions: bodies copied between functions, packages and services.
+
+The parser records a fingerprint of every function body (see
+go_parser.body_fingerprint): its size in tokens, a hash of its "shape" -
+the tokens with every identifier and literal replaced by its kind, so a
+copy whose variables were renamed or whose constants changed hashes the
+same - and a bottom-k sketch of the shape's 4-token shingles.
+
+    identical  bodies whose shapes are equal (similarity 100)
+    similar    bodies whose shapes are at least min_similarity percent alike:
+               twice their longest common token subsequence over their
+               combined length, computed for the pairs whose sketches
+               share a value and whose shingles overlap enough for it to
+               be reached
+
+A group is the functions linked by such pairs. Bodies of fewer than
+go_parser.SKETCH_MIN_TOKENS tokens have no sketch, so they are only found
+identical. Bodies shorter than min_tokens are left out, so one-line getters
+and constructors do not flood the result, and so are copies of a function
+in files selected by build constraints (the same name in the same
+package), which are duplicated on purpose. Each copy is tagged with the
+services (see go_deps.service_map) building its package: groups whose
+copies live in different packages, and above all in different services,
+come first - code that drifted apart without anyone noticing.
+"""
+
+import os
+from typing import Any, Callable, Dict, List, Optional, Set, Tuple
+
+from xray.core.go_analysis import GoProject
+from xray.core.go_deps import service_map
+from xray.core.go_parser import SHINGLE, Token, body_shape, tokenize
+from xray.core.source_text import source_lines
+
+DEFAULT_MIN_TOKENS = 50
+DEFAULT_MIN_SIMILARITY = 90
+# A sketch value shared by more bodies than this is too common to pair them by
+MAX_BUCKET = 64
+
+Copy = Dict[str, Any]
+
+
+def _body_tokens(tokens: List[Token], start_line: int, end_line: int) -> Optional[List[Token]]:
+    """The tokens inside the braces of the function body ending on end_line; None if it opens before start_line."""
+    close = next((i for i in range(len(tokens) - 1, -1, -1)
+                  if tokens[i].value == "}" and tokens[i].kind == "op" and tokens[i].line <= end_line), None)
+    if close is None:
+        return None
+    depth = 0
+    for i in range(close, -1, -1):
+        tok = tokens[i]
+        if tok.line < start_line:
+            return None
+        if tok.kind != "op":
+            continue
+        if tok.value == "}":
+            depth += 1
+        elif tok.value == "{":
+            depth -= 1
+            if depth == 0:
+                return tokens[i + 1:close]
+    return None
+
+
+def common_tokens(a: List[str], b: List[str]) -> int:
+    """
+    The length of the longest common subsequence of two token lists, by
+    Hyyrö's bit-parallel algorithm: a row of the dynamic-programming table
+    is one integer, so each token of b costs a few big-integer operations.
+    """
+    if not a or not b:
+        return 0
+    masks: Dict[str, int] = {}
+    for i, tok in enumerate(a):
+        masks[tok] = masks.get(tok, 0) | (1 << i)
+    full = (1 << len(a)) - 1
+    row = full
+    for tok in b:
+        matched = row & masks.get(tok, 0)
+        row = ((row + matched) | (row - matched)) & full
+    return len(a) - bin(row).count("1")
+
+
+def _min_jaccard(min_similarity: float) -> float:
+    """
+    The least overlap of shingle sets two bodies min_similarity percent
+    alike can have: each differing token breaks up to SHINGLE shingles on
+    either side. Pairs below it are not compared token by token.
+    """
+    broken = SHINGLE * (1 - min_similarity / 100)
+    return max(0.0, (1 - broken) / (1 + broken))
+
+
+class _Groups:
+    """Union-find over copy indexes."""
+
+    def __init__(self):
+        self.parent: Dict[int, int] = {}
+
+    def find(self, i: int) -> int:
+        self.parent.setdefault(i, i)
+        while self.parent[i] != i:
+            self.parent[i] = self.parent[self.parent[i]]
+            i = self.parent[i]
+        return i
+
+    def join(self, a: int, b: int):
+        self.parent[self.find(a)] = self.find(b)
+
+
+class DuplicateFinder:
+    """Groups of Go functions with identical or near-identical bodies."""
+
+    def __init__(self, project: GoProject, read: Callable[[str], str], module: Optional[str] = None):
+        self.project = project
+        self.read = read
+        self.module = module
+        self._shapes: Dict[int, Optional[List[str]]] = {}
+        self._file_lines: Dict[str, List[str]] = {}
+        self._shingle_sets: Dict[int, Set[Tuple[str, ...]]] = {}
+
+    def _package_id(self, pkg_dir: str) -> str:
+        import_path = self.project.import_path(pkg_dir)
+        if import_path:
+            return import_path
+        root = self.project.root or ""
+        rel = os.path.relpath(pkg_dir, root).replace(os.sep, "/") if root else pkg_dir
+        return "." if rel in ("", ".") else rel
+
+    def _copies(self, min_tokens: int, include_tests: bool, scope: Optional[str],
+                keep: Optional[Callable[[str], bool]]) -> List[Copy]:
+        copies = []
+        for file_path, parsed in sorted(self.project.files.items()):
+            if not include_tests and file_path.endswith("_test.go"):
+                continue
+            if keep is not None and not keep(file_path):
+                continue
+            if scope and file_path != scope and not file_path.startswith(scope.rstrip(os.sep) + os.sep):
+                continue
+            ends = {(s["start_line"], s["name"]): s["end_line"] for s in parsed.get("symbols", [])
+                    if s["type"] in ("function", "method")}
+            for func in parsed.get("functions", []):
+                if func.get("body_tokens", 0) < min_tokens:
+                    continue
+                name = f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+                copy: Copy = {"function": name, "package": self._package_id(os.path.dirname(file_path)),
+                              "path": file_path, "line": func["start_line"],
+                              "end_line": ends.get((func["start_line"], func["name"]), func["start_line"]),
+                              "tokens": func["body_tokens"], "_hash": func["body_hash"],
+                              "_sketch": func.get("body_sketch", [])}
+                if file_path.endswith("_test.go"):
+                    copy["in_test"] = True
+                copies.append(copy)
+        return copies
+
+    def _shape(self, index: int, copy: Copy) -> Optional[List[str]]:
+        """A copy's body shape, from its lines tokenized again; None when it no longer reads the same."""
+        if index not in self._shapes:
+            path = copy["path"]
+            if path not in self._file_lines:
+                try:
+                    self._file_lines[path] = source_lines(self.read(path))
+                except Exception:
+                    self._file_lines[path] = []
+            lines = self._file_lines[path][copy["line"] - 1:copy["end_line"]]
+            body = _body_tokens(tokenize("\n".join(lines))[0], 1, len(lines)) if lines else None
+            shape = body_shape(body) if body is not None else None
+            self._shapes[index] = shape if shape is not None and len(shape) == copy["tokens"] else None
+        return self._shapes[index]
+
+    def _shingles(self, index: int, copy: Copy) -> Set[Tuple[str, ...]]:
+        if index not in self._shingle_sets:
+            shape = self._shape(index, copy) or []
+            self._shingle_sets[index] = {tuple(shape[k:k + SHINGLE]) for k in range(len(shape) - SHINGLE + 1)}
+        return self._shingle_sets[index]
+
+    def _similarity(self, i: int, j: int, copies: List[Copy]) -> Optional[float]:
+        if copies[i]["_hash"] == copies[j]["_hash"]:
+            return 100.0
+        a, b = self._shape(i, copies[i]), self._shape(j, copies[j])
+        if a is None or b is None:
+            return None
+        return round(200 * common_tokens(a, b) / (len(a) + len(b)), 1)
+
+    def _services(self) -> Dict[str, List[str]]:
+        """Package id -> the names of the services building it."""
+        services: Dict[str, List[str]] = {}
+        for service in service_map(self.project, module=self.module)["services"]:
+            for package in [service["package"]] + service["packages"]:
+                services.setdefault(package, []).append(service["name"])
+        return services
+
+    def find(self, min_tokens: int = DEFAULT_MIN_TOKENS, min_similarity: float = DEFAULT_MIN_SIMILARITY,
+             include_tests: bool = False, path: Optional[str] = None,
+             cross_package_only: bool = False, keep: Optional[Callable[[str], bool]] = None) -> Dict[str, Any]:
+        """
+        Groups of functions whose bodies are identical or at least
+        min_similarity percent alike, the most spread out and largest first.
+
+        Args:
+            min_tokens: Leave out bodies of fewer tokens
+            min_similarity: Percent of alike tokens that makes two bodies near copies; 100 for identical only
+            include_tests: Also compare the functions of _test.go files
+            path: Only functions in this file or package directory (and below)
+            cross_package_only: Only groups with copies in more than one package
+            keep: Only the files it accepts
+        """
+        copies = self._copies(min_tokens, include_tests, path, keep)
+        groups = _Groups()
+        by_hash: Dict[str, int] = {}
+        for i, copy in enumerate(copies):
+            first = by_hash.setdefault(copy["_hash"], i)
+            if first != i:
+                groups.join(i, first)
+        if min_similarity < 100:
+            buckets: Dict[int, List[int]] = {}
+            for i, copy in enumerate(copies):
+                if by_hash[copy["_hash"]] == i:
+                    for value in copy["_sketch"]:
+                        buckets.setdefault(value, []).append(i)
+            pairs: Set[Tuple[int, int]] = set()
+            for members in buckets.values():
+                if len(members) <= MAX_BUCKET:
+                    pairs.update((i, j) for x, i in enumerate(members) for j in members[x + 1:])
+            floor = _min_jaccard(min_similarity)
+            for i, j in sorted(pairs):
+                a, b = copies[i], copies[j]
+                # The similarity is at most 2*min/(len a + len b)
+                if 200 * min(a["tokens"], b["tokens"]) / (a["tokens"] + b["tokens"]) < min_similarity \
+                        or groups.find(i) == groups.find(j):
+                    continue
+                shingles_a, shingles_b = self._shingles(i, a), self._shingles(j, b)
+                if not shingles_a or not shingles_b or \
+                        len(shingles_a & shingles_b) < floor * len(shingles_a | shingles_b):
+                    continue
+                similarity = self._similarity(i, j, copies)
+                if similarity is not None and similarity >= min_similarity:
+                    groups.join(i, j)
+
+        members: Dict[int, List[int]] = {}
+        for i in range(len(copies)):
+            if i in groups.parent:
+                members.setdefault(groups.find(i), []).append(i)
+        services = self._services()
+        result_groups = []
+        for indexes in members.values():
+            # Build-constraint variants of one function are meant to be alike
+            names = {(os.path.dirname(copies[i]["path"]), copies[i]["function"]) for i in indexes}
+            if len(names) < 2:
+                continue
+            first = indexes[0]
+            entries = []
+            for i in indexes:
+                entry = {k: v for k, v in copies[i].items() if not k.startswith("_")}
+                entry["similarity"] = 100.0 if i == first else self._similarity(first, i, copies)
+                entry["services"] = services.get(entry["package"], [])
+                entries.append(entry)
+            packages = sorted({e["package"] for e in entries})
+            serving = [set(e["services"]) for e in entries if e["services"]]
+            group_services = sorted(set().union(*serving)) if serving else []
+            identical = all(copies[i]["_hash"] == copies[first]["_hash"] for i in indexes)
+            similarities = [e["similarity"] for e in entries if e["similarity"] is not None]
+            group = {
+                "kind": "identical" if identical else "similar",
+                "similarity": min(similarities) if similarities else None,
+                "tokens": max(e["tokens"] for e in entries),
+                "copy_count": len(entries),
+                # Tokens beyond one copy of the largest: what deduplicating would save
+                "duplicated_tokens": sum(e["tokens"] for e in entries) - max(e["tokens"] for e in entries),
+                "packages": packages,
+                "services": group_services,
+                "cross_package": len(packages) > 1,
+                "cross_service": len(group_services) > 1 and not set.intersection(*serving),
+                "copies": entries,
+            }
+            if cross_package_only and not group["cross_package"]:
+                continue
+            result_groups.append(group)
+        result_groups.sort(key=lambda g: (not g["cross_service"], not g["cross_package"],
+                                          -g["duplicated_tokens"], g["copies"][0]["path"],
+                                          g["copies"][0]["line"]))
+        result: Dict[str, Any] = {
+            "groups": result_groups,
+            "total_count": len(result_groups),
+            "function_count": len(copies),
+            "duplicated_tokens": sum(g["duplicated_tokens"] for g in result_groups),
+            "min_tokens": min_tokens,
+            "min_similarity": min_similarity,
+        }
+        cross = sum(1 for g in result_groups if g["cross_service"])
+        if cross:
+            result["message"] = f"{cross} of {len(result_groups)} groups of duplicated functions span services"
+        return result
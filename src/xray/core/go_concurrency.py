@@ -0,0 +1,348 @@
+"""Goroutine launch sites and channel usage across a Go project.
+
+The parser records, per function, its `go` statements (and `x.Go(func()
+{...})` launches such as errgroup's), the channels it makes or declares,
+and every send, receive, close and range over a chain. This module puts
+them together: goroutines reachable from a function through the call
+graph, and every use of one channel variable - a struct field, a package
+variable or a local - including the functions it is passed to as an
+argument, whose parameter then names the same channel.
+
+Closures launched as goroutines that read an enclosing loop variable are
+flagged. Before Go 1.22 all iterations share that variable, so the
+goroutines see whatever value it holds when they run; the go directive of
+go.mod decides which semantics apply.
+"""
+
+import os
+from typing import Any, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+from xray.core.go_parser import channel_type
+
+# Call edges that keep running code of the caller's making
+_FOLLOWED_KINDS = ("call", "go", "defer")
+
+# (kind, package dir, owner, name): owner is the struct type of a field,
+# the function of a local or parameter, and None for a package variable
+Channel = Tuple[str, str, Optional[str], str]
+
+
+def per_iteration_loops(go_version: Optional[str]) -> bool:
+    """Whether loop variables are per-iteration (go 1.22 and later) under a go.mod go directive."""
+    if not go_version:
+        return False
+    parts = go_version.split(".")
+    try:
+        return (int(parts[0]), int(parts[1]) if len(parts) > 1 else 0) >= (1, 22)
+    except ValueError:
+        return False
+
+
+class ConcurrencyMap:
+    """Goroutines and channels of a project, resolved through its call graph."""
+
+    def __init__(self, graph: GoCallGraph, go_version: Optional[str] = None):
+        self.graph = graph
+        self.project = graph.project
+        self.go_version = go_version
+        self.per_iteration = per_iteration_loops(go_version)
+        # node key -> (path, parsed function)
+        self.functions: Dict[Tuple[str, str], Tuple[str, Dict[str, Any]]] = {}
+        for path, parsed in sorted(self.project.files.items()):
+            pkg_dir = os.path.dirname(path)
+            for func in parsed.get("functions", []):
+                name = f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+                self.functions[(pkg_dir, name)] = (path, func)
+        self.callees: Dict[Tuple[str, str], List[Dict[str, Any]]] = {}
+        self._sites: Dict[Tuple[Tuple[str, str], int, int], Dict[str, Any]] = {}
+        for edge in graph.edges:
+            if edge["kind"] in _FOLLOWED_KINDS:
+                self.callees.setdefault(edge["caller"], []).append(edge)
+                self._sites.setdefault((edge["caller"], edge["line"], edge["column"]), edge)
+        self._index_channels()
+
+    # ------------------------------------------------------------------
+    # Goroutines
+    # ------------------------------------------------------------------
+
+    def _launch(self, key: Tuple[str, str], path: str, goroutine: Dict[str, Any]) -> Dict[str, Any]:
+        entry = {
+            "function": key[1],
+            "package": self.project.files[path].get("package", ""),
+            "path": path,
+            "line": goroutine["line"],
+            "column": goroutine["column"],
+            "kind": goroutine["kind"],
+        }
+        if goroutine.get("launcher"):
+            entry["launcher"] = goroutine["launcher"]
+        if goroutine["kind"] == "literal":
+            entry["end_line"] = goroutine["end_line"]
+        else:
+            entry["target"] = goroutine["target"]
+            edge = next((e for e in self.callees.get(key, ())
+                         if e["kind"] == "go" and e["line"] == goroutine["line"]), None)
+            if edge is not None and edge["external"]:
+                entry["launched"] = {"qualified_name": edge["callee"][1], "external": True}
+            elif edge is not None:
+                entry["launched"] = dict(self.graph.nodes[edge["callee"]])
+        if goroutine.get("arg_texts"):
+            entry["args"] = goroutine["arg_texts"]
+        if goroutine.get("captures"):
+            entry["captures_loop_vars"] = goroutine["captures"]
+            # Shared by all iterations before go 1.22: every goroutine may see the last value
+            entry["loop_var_bug"] = not self.per_iteration
+        return entry
+
+    def goroutines(self, path: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Every goroutine launch site, optionally in one file or package directory."""
+        results = []
+        for key, (file_path, func) in sorted(self.functions.items()):
+            if path and file_path != path and key[0] != path.rstrip(os.sep):
+                continue
+            for goroutine in func.get("concurrency", {}).get("goroutines", []):
+                results.append(self._launch(key, file_path, goroutine))
+        return results
+
+    def goroutines_from(self, start: Tuple[str, str], depth: int) -> List[Dict[str, Any]]:
+        """
+        Goroutines launched by a function or anything it calls, up to depth
+        calls away, including those started by the goroutines it launches.
+        Each carries its call "depth", the functions "via" which it is reached
+        and whether that path already runs on another goroutine.
+        """
+        results = []
+        frontier = [(start, [], False)]
+        visited = {start}
+        for level in range(0, max(depth, 0) + 1):
+            next_frontier = []
+            for key, via, on_goroutine in frontier:
+                if key in self.functions:
+                    path, func = self.functions[key]
+                    for goroutine in func.get("concurrency", {}).get("goroutines", []):
+                        entry = self._launch(key, path, goroutine)
+                        entry["depth"] = level
+                        if via:
+                            entry["via"] = via
+                        if on_goroutine:
+                            entry["on_goroutine"] = True
+                        results.append(entry)
+                for edge in self.callees.get(key, ()):
+                    callee = edge["callee"]
+                    if edge["external"] or callee in visited:
+                        continue
+                    visited.add(callee)
+                    next_frontier.append((callee, via + [callee[1]], on_goroutine or edge["kind"] == "go"))
+            frontier = next_frontier
+        return results
+
+    # ------------------------------------------------------------------
+    # Channels
+    # ------------------------------------------------------------------
+
+    def _resolve(self, key: Tuple[str, str], path: str, func: Dict[str, Any],
+                 chain: List[str]) -> Optional[Channel]:
+        """The channel variable a chain names inside a function, or None (calls, index expressions, unknowns)."""
+        if not chain or "()" in chain or "[]" in chain:
+            return None
+        pkg_dir = key[0]
+        head = chain[0]
+        if len(chain) == 1:
+            if head in func.get("locals", {}):
+                return ("local", pkg_dir, key[1], head)
+            if (pkg_dir, head) in self.graph.package_vars:
+                return ("var", pkg_dir, None, head)
+            return None
+        imports = {imp["name"]: imp["path"] for imp in self.project.files[path].get("imports", []) if imp["name"]}
+        if head in imports and head not in func.get("locals", {}):
+            target = self.project.import_dir(imports[head], path)
+            if target is not None and len(chain) == 2 and (target, chain[1]) in self.graph.package_vars:
+                return ("var", target, None, chain[1])
+            return None
+        field = self.graph.field_of(path, func, chain)
+        if field is None or not field.get("container"):
+            return None
+        return ("field", os.path.dirname(field["path"]), field["container"], field["name"])
+
+    def _literal_chain(self, func: Dict[str, Any], op: Dict[str, Any]) -> List[str]:
+        """Rewrite a chain rooted at a goroutine literal's parameter to the argument passed for it."""
+        chain = op["chain"]
+        if not op.get("goroutine_line"):
+            return chain
+        for goroutine in func.get("concurrency", {}).get("goroutines", []):
+            if goroutine["line"] != op["goroutine_line"] or chain[0] not in goroutine.get("params", []):
+                continue
+            pos = goroutine["params"].index(chain[0])
+            args = goroutine.get("args", [])
+            if pos < len(args) and args[pos].get("chain"):
+                return args[pos]["chain"] + chain[1:]
+        return chain
+
+    def _index_channels(self):
+        self.declarations: Dict[Channel, List[Dict[str, Any]]] = {}
+        self.uses: Dict[Channel, List[Dict[str, Any]]] = {}
+        # Uses whose operand is not a variable (`<-ctx.Done()`, `<-time.After(d)`)
+        self.unresolved: List[Dict[str, Any]] = []
+        # channel -> parameters it is passed to
+        self.passed: Dict[Channel, List[Tuple[Channel, Dict[str, Any]]]] = {}
+        ranges = []
+
+        for (pkg_dir, type_name), fields in sorted(self.project.fields.items()):
+            for field in fields:
+                channel = channel_type(field["field_type"]) if not field.get("embedded") else None
+                if channel:
+                    self.declarations.setdefault(("field", pkg_dir, type_name, field["name"]), []).append({
+                        "kind": "field", "path": field["path"], "line": field["start_line"],
+                        "column": field.get("column"), **channel,
+                    })
+        for path, parsed in sorted(self.project.files.items()):
+            for symbol in parsed["symbols"]:
+                if symbol["type"] == "variable" and symbol.get("channel"):
+                    self.declarations.setdefault(("var", os.path.dirname(path), None, symbol["name"]), []).append({
+                        "kind": "var", "path": path, "line": symbol["start_line"],
+                        "column": symbol.get("column"), **symbol["channel"],
+                    })
+
+        for key, (path, func) in sorted(self.functions.items()):
+            facts = func.get("concurrency", {})
+            for decl in facts.get("channels", []):
+                channel = self._resolve(key, path, func, decl["chain"])
+                if channel is None:
+                    continue
+                entry = {"kind": decl["kind"], "path": path, "line": decl["line"], "column": decl["column"],
+                         "direction": decl["direction"], "element_type": decl["element_type"], "function": key[1]}
+                if decl.get("buffer") is not None:
+                    entry["buffer"] = decl["buffer"]
+                self.declarations.setdefault(channel, []).append(entry)
+            for op in facts.get("channel_ops", []):
+                entry = {"op": op["op"], "path": path, "line": op["line"], "column": op["column"], "function": key[1]}
+                for k in ("select_line", "in_goroutine", "goroutine_line"):
+                    if k in op:
+                        entry[k] = op[k]
+                channel = self._resolve(key, path, func, self._literal_chain(func, op))
+                if op["op"] == "range":
+                    # Ranges over anything; kept once we know the operand is a channel
+                    ranges.append((channel, entry))
+                elif channel is None:
+                    self.unresolved.append({**entry, "channel": ".".join(op["chain"]).replace(".()", "()")})
+                else:
+                    self.uses.setdefault(channel, []).append(entry)
+            for call in func.get("calls", []):
+                edge = self._sites.get((key, call["line"], call["column"]))
+                if edge is None or edge["external"]:
+                    continue
+                params = self.graph.functions.get(edge["callee"], {}).get("params", [])
+                for pos, arg in enumerate(call.get("args", [])):
+                    if not arg or "chain" not in arg or pos >= len(params) or not params[pos]["name"]:
+                        continue
+                    if not channel_type(params[pos]["type"]):
+                        continue
+                    channel = self._resolve(key, path, func, arg["chain"])
+                    if channel is None:
+                        continue
+                    target = ("local", edge["callee"][0], edge["callee"][1], params[pos]["name"])
+                    self.passed.setdefault(channel, []).append((target, {
+                        "path": path, "line": call["line"], "column": call["column"], "function": key[1],
+                        "kind": edge["kind"],
+                    }))
+
+        known = set(self.declarations) | {t for targets in self.passed.values() for t, _ in targets}
+        for channel, entry in ranges:
+            if channel in known:
+                self.uses.setdefault(channel, []).append(entry)
+
+    @staticmethod
+    def display_name(channel: Channel) -> str:
+        kind, _, owner, name = channel
+        return f"{owner}.{name}" if owner else name
+
+    def _describe_channel(self, channel: Channel) -> Dict[str, Any]:
+        kind, pkg_dir, owner, name = channel
+        params = self.graph.functions.get((pkg_dir, owner), {}).get("params", []) if kind == "local" else []
+        if kind == "local" and any(p["name"] == name for p in params):
+            kind = "param"
+        package = ""
+        files = self.project.packages.get(pkg_dir, {}).get("files", [])
+        if files:
+            package = self.project.files[files[0]].get("package", "")
+        entry = {"name": self.display_name(channel), "kind": kind, "package": package}
+        if kind in ("local", "param"):
+            entry["function"] = owner
+        elif kind == "field":
+            entry["type"] = owner
+        return entry
+
+    def find_channels(self, name: str, path: Optional[str] = None) -> List[Channel]:
+        """
+        Channel variables matching name: "jobs" (any field, package variable,
+        local or parameter of that name), "Pool.jobs" (a field of Pool, or a
+        local of function Pool) or "Pool.Start.done" (a local of a method).
+        Most used first.
+        """
+        parts = name.split(".")
+        known = set(self.declarations) | set(self.uses) | {t for targets in self.passed.values() for t, _ in targets}
+        matches = []
+        for channel in known:
+            kind, pkg_dir, owner, var = channel
+            if var != parts[-1]:
+                continue
+            if len(parts) > 1 and owner != ".".join(parts[:-1]):
+                continue
+            if path:
+                decl_paths = {d["path"] for d in self.declarations.get(channel, [])}
+                if pkg_dir != path.rstrip(os.sep) and path not in decl_paths:
+                    continue
+            matches.append(channel)
+        return sorted(matches, key=lambda c: (-len(self.uses.get(c, [])), c[0] != "field", c[1], c[2] or "", c[3]))
+
+    def channel_usage(self, channel: Channel) -> Dict[str, Any]:
+        """
+        Every declaration and use of a channel and of the parameters it is
+        passed to (transitively), with the functions that send, receive and close.
+        """
+        aliases = [(channel, None)]
+        seen = {channel}
+        passed_to = []
+        i = 0
+        while i < len(aliases):
+            current, _ = aliases[i]
+            i += 1
+            for target, site in self.passed.get(current, ()):
+                passed_to.append({**site, "to": target[2], "param": target[3]})
+                if target not in seen:
+                    seen.add(target)
+                    aliases.append((target, self.display_name(target)))
+
+        uses = []
+        for current, alias in aliases:
+            for use in self.uses.get(current, []):
+                uses.append({**use, "via": alias} if alias else dict(use))
+        uses.sort(key=lambda u: (u["path"], u["line"], u["column"]))
+        declarations = sorted(self.declarations.get(channel, []), key=lambda d: (d["path"], d["line"]))
+
+        def functions(*ops: str) -> List[str]:
+            return sorted({u["function"] for u in uses if u["op"] in ops})
+
+        counts = {op: sum(1 for u in uses if u["op"] == op) for op in ("send", "receive", "range", "close")}
+        return {
+            "channel": self._describe_channel(channel),
+            "declarations": declarations,
+            "uses": uses,
+            "passed_to": passed_to,
+            "senders": functions("send"),
+            "receivers": functions("receive", "range"),
+            "closers": functions("close"),
+            "counts": counts,
+        }
+
+    def channels(self, path: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Every channel declaration, optionally in one file or package directory."""
+        results = []
+        for channel, declarations in sorted(self.declarations.items(), key=lambda item: tuple(x or "" for x in item[0])):
+            for decl in declarations:
+                if path and decl["path"] != path and os.path.dirname(decl["path"]) != path.rstrip(os.sep):
+                    continue
+                results.append({"channel": self.display_name(channel), **decl,
+                                "uses": len(self.uses.get(channel, []))})
+        return results
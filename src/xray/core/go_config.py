@@ -0,0 +1,369 @@
+"""Configuration knobs of a Go service: environment variables, flags and viper keys.
+
+Reads are recognised from the call sites recorded by go_parser:
+
+    env    os.Getenv, os.LookupEnv, syscall.Getenv
+    flag   flag.String/Int/Bool/Duration/... and their Var forms, on the flag
+           package or a FlagSet, and pflag's P forms (cobra's cmd.Flags())
+    viper  viper.Get/GetString/GetBool/.../IsSet, with viper.SetDefault
+           supplying defaults and viper.BindEnv the environment variables
+           behind a key
+
+A project function that passes one of its parameters to an env read
+(`getEnv(key, fallback string)`) is an accessor: its call sites count as
+reads of the key they pass, and with a second parameter, of its default.
+
+A read influences control flow when it sits in an if or switch header (or a
+case expression), or when a name it is assigned to is read by one later in
+the same function - for package-level variables, in any function of the
+package that does not shadow it.
+
+Keys built at runtime are reported with their expression text and
+"dynamic": true.
+"""
+
+import os
+import re
+from typing import Any, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+
+ENV_APIS = ("os.Getenv", "os.LookupEnv", "syscall.Getenv")
+FLAG_PACKAGES = ("flag", "github.com/spf13/pflag")
+VIPER_PACKAGE = "github.com/spf13/viper"
+# Imports that make an unresolved cmd.Flags().String(...) a pflag definition
+_FLAG_IMPORTS = FLAG_PACKAGES + ("github.com/spf13/cobra",)
+
+VIPER_GETTERS = {
+    "Get", "GetBool", "GetDuration", "GetFloat64", "GetInt", "GetInt32", "GetInt64", "GetIntSlice",
+    "GetSizeInBytes", "GetString", "GetStringMap", "GetStringMapString", "GetStringMapStringSlice",
+    "GetStringSlice", "GetTime", "GetUint", "GetUint16", "GetUint32", "GetUint64", "IsSet", "Sub",
+    "UnmarshalKey",
+}
+
+# flag.Int(name, default, usage), flag.IntVar(&p, name, default, usage), pflag's IntP(name, shorthand, default, usage)
+_FLAG_DEFINER = re.compile(
+    r"^(String|Bool|Int|Int8|Int16|Int32|Int64|Uint|Uint8|Uint16|Uint32|Uint64|Float32|Float64|Duration"
+    r"|StringSlice|StringArray|StringToString|IntSlice|IP|Text)(Var)?(P)?$")
+
+ENV_FILE_NAMES = (".env.example", ".env.sample", ".env.template", ".env.dist")
+_COMPOSE_FILE = re.compile(r"^(docker-)?compose(\.[\w-]+)?\.ya?ml$")
+_ENV_KEY = re.compile(r"[A-Za-z_][A-Za-z0-9_.]*")
+
+
+def is_compose_file(name: str) -> bool:
+    """Whether a file name is a docker compose file's (compose.yaml, docker-compose.prod.yml)."""
+    return bool(_COMPOSE_FILE.match(name))
+
+
+def is_env_declaration_file(name: str) -> bool:
+    """Whether a file name is an .env example or a docker compose file."""
+    return name in ENV_FILE_NAMES or is_compose_file(name)
+
+
+def read_env_file(content: str) -> List[Dict[str, Any]]:
+    """The KEY=value lines of an .env file: [{"key", "line", "value"}]."""
+    entries = []
+    for number, raw in enumerate(content.splitlines(), 1):
+        line = raw.strip()
+        if not line or line.startswith("#"):
+            continue
+        if line.startswith("export "):
+            line = line[len("export "):].strip()
+        key, _, value = line.partition("=")
+        if _ENV_KEY.fullmatch(key.strip()):
+            entries.append({"key": key.strip(), "line": number, "value": value.strip().strip("\"'")})
+    return entries
+
+
+def compose_environment(content: str) -> List[Dict[str, Any]]:
+    """
+    The environment blocks of a docker compose file, in both the list
+    (`- KEY=value`) and the map (`KEY: value`) form:
+    [{"key", "line", "value", "service"}]. A line-based reading, not a YAML parser.
+    """
+    entries = []
+    services_indent: Optional[int] = None
+    service_indent: Optional[int] = None
+    service: Optional[str] = None
+    env_indent: Optional[int] = None
+    for number, raw in enumerate(content.splitlines(), 1):
+        stripped = raw.strip()
+        if not stripped or stripped.startswith("#"):
+            continue
+        indent = len(raw) - len(raw.lstrip())
+        if env_indent is not None:
+            if indent > env_indent:
+                item = stripped[1:].strip() if stripped.startswith("-") else stripped
+                item = item.strip("\"'")
+                key, value = re.split(r"[=:]", item, 1) if re.search(r"[=:]", item) else (item, "")
+                if _ENV_KEY.fullmatch(key.strip()):
+                    entries.append({"key": key.strip(), "line": number,
+                                    "value": value.strip().strip("\"'"), "service": service})
+                continue
+            env_indent = None
+        if stripped == "services:":
+            services_indent, service_indent, service = indent, None, None
+            continue
+        if services_indent is not None and indent <= services_indent:
+            services_indent = service_indent = service = None
+        if services_indent is not None and stripped.endswith(":") and service_indent in (None, indent):
+            service_indent = indent
+            service = stripped[:-1].strip().strip("\"'")
+            continue
+        if stripped == "environment:":
+            env_indent = indent
+    return entries
+
+
+def _function_name(func: Optional[Dict[str, Any]]) -> Optional[str]:
+    if func is None:
+        return None
+    return f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+
+
+class ConfigUsageFinder:
+    """Collects configuration reads across every parsed Go file of a project."""
+
+    def __init__(self, graph: GoCallGraph):
+        self.graph = graph
+        self.project = graph.project
+        # Accessor node key -> (key parameter index, default parameter index or None)
+        self._accessors: Dict[Tuple[str, str], Tuple[int, Optional[int]]] = {}
+        self._viper_defaults: Dict[str, Dict[str, Any]] = {}
+        self._viper_env: Dict[str, List[str]] = {}
+
+    # ------------------------------------------------------------------
+    # Call classification
+    # ------------------------------------------------------------------
+
+    def _imports(self, path: str) -> List[str]:
+        return [imp["path"] for imp in self.project.files[path].get("imports", [])]
+
+    def _classify(self, path: str, func: Dict[str, Any],
+                  call: Dict[str, Any]) -> Optional[Tuple[str, str, int, Optional[int]]]:
+        """(source, api, key argument, default argument) for a configuration call, or None."""
+        chain = call["chain"]
+        name = chain[-1]
+        target = self.graph.evaluate(path, func, chain)
+        qualified = target[2] if target is not None and target[0] == "func" and target[1] == "external" else None
+        if qualified in ENV_APIS:
+            return "env", qualified, 0, None
+        if target is not None and target[0] == "func" and target[1] == "project" and target[2] in self._accessors:
+            key_index, default_index = self._accessors[target[2]]
+            return "env", self.graph.nodes[target[2]]["name"], key_index, default_index
+        flag = _FLAG_DEFINER.match(name)
+        if flag and len(chain) >= 2:
+            known = qualified is not None and qualified.startswith(tuple(p + "." for p in FLAG_PACKAGES))
+            # Unresolved receivers (cmd.Flags(), a FlagSet from a field) in a file using flags
+            guessed = qualified is None and any(p in _FLAG_IMPORTS for p in self._imports(path)) \
+                and (chain[0] == "flag" or "Flags" in chain or "PersistentFlags" in chain or flag.group(3))
+            if known or guessed:
+                key_index = 1 if flag.group(2) else 0
+                default_index = key_index + (2 if flag.group(3) else 1)
+                return "flag", qualified or ".".join(c for c in chain if c != "()"), key_index, default_index
+        if name in VIPER_GETTERS and len(chain) >= 2:
+            if (qualified or "").startswith(VIPER_PACKAGE + ".") or (
+                    qualified is None and VIPER_PACKAGE in self._imports(path) and len(chain) == 2):
+                return "viper", qualified or ".".join(chain), 0, None
+        return None
+
+    def _key(self, path: str, call: Dict[str, Any], index: int) -> Optional[Dict[str, Any]]:
+        if index >= len(call["args"]):
+            return None
+        source = call["args"][index] or {}
+        if "value" in source:
+            return {"key": source["value"], "dynamic": False}
+        if "chain" in source and len(source["chain"]) <= 2:
+            value = self.graph.constant_value(path, source["chain"])
+            if isinstance(value, str):
+                return {"key": value, "dynamic": False}
+        return {"key": call["arg_texts"][index], "dynamic": True}
+
+    @staticmethod
+    def _default(call: Dict[str, Any], index: Optional[int]) -> Optional[str]:
+        if index is None or index >= len(call["args"]):
+            return None
+        source = call["args"][index] or {}
+        return source["value"] if "value" in source else call["arg_texts"][index]
+
+    # ------------------------------------------------------------------
+    # Passes
+    # ------------------------------------------------------------------
+
+    def _sites(self):
+        """Every (path, function or None for package level, call) of the project."""
+        for path in sorted(self.project.files):
+            parsed = self.project.files[path]
+            for call in parsed.get("package_calls", []):
+                yield path, None, call
+            for func in parsed.get("functions", []):
+                for call in func.get("calls", []):
+                    yield path, func, call
+
+    def _find_accessors(self):
+        for path, func, call in self._sites():
+            if func is None or len(call["args"]) != 1:
+                continue
+            target = self.graph.evaluate(path, func, call["chain"])
+            if target is None or target[0] != "func" or target[2] not in ENV_APIS or not call["args"]:
+                continue
+            key = (os.path.dirname(path), f"{func['receiver']}.{func['name']}" if func.get("receiver")
+                   else func["name"])
+            symbol = self.graph.functions.get(key)
+            arg = call["args"][0] or {}
+            params = [p["name"] for p in (symbol or {}).get("params", [])]
+            if not symbol or "chain" not in arg or len(arg["chain"]) != 1 or arg["chain"][0] not in params:
+                continue
+            key_index = params.index(arg["chain"][0])
+            default_index = 1 - key_index if len(params) == 2 else None
+            self._accessors[key] = (key_index, default_index)
+
+    def _find_viper_settings(self):
+        for path, func, call in self._sites():
+            name = call["chain"][-1]
+            if name not in ("SetDefault", "BindEnv") or not call["args"]:
+                continue
+            target = self.graph.evaluate(path, func or {}, call["chain"])
+            qualified = target[2] if target is not None and target[0] == "func" and target[1] == "external" else None
+            if not (qualified or "").startswith(VIPER_PACKAGE + ".") and not (
+                    qualified is None and VIPER_PACKAGE in self._imports(path)):
+                continue
+            key = self._key(path, call, 0)
+            if key is None or key["dynamic"]:
+                continue
+            if name == "SetDefault" and len(call["args"]) > 1:
+                self._viper_defaults.setdefault(key["key"], {
+                    "value": self._default(call, 1), "path": path, "line": call["line"]})
+            elif name == "BindEnv":
+                names = [self._key(path, call, i) for i in range(1, len(call["args"]))] or \
+                    [{"key": key["key"].upper(), "dynamic": False}]
+                self._viper_env.setdefault(key["key"], []).extend(
+                    n["key"] for n in names if n and not n["dynamic"])
+
+    # ------------------------------------------------------------------
+    # Control flow
+    # ------------------------------------------------------------------
+
+    @staticmethod
+    def _in_condition(func: Dict[str, Any], call: Dict[str, Any]) -> bool:
+        position = (call["line"], call["column"])
+        return any((c["line"], c["column"]) <= position <= (c["end_line"], c["end_column"])
+                   for c in func.get("conditions", []))
+
+    def _decides(self, path: str, func: Optional[Dict[str, Any]], call: Dict[str, Any], names: List[str]) -> bool:
+        if func is not None:
+            if self._in_condition(func, call):
+                return True
+            return any(c["line"] >= call["line"] and set(names) & set(c["names"])
+                       for c in func.get("conditions", []))
+        # A package-level variable: any function of the package not shadowing it
+        for file_path in self.project.packages.get(os.path.dirname(path), {}).get("files", []):
+            for other in self.project.files[file_path].get("functions", []):
+                visible = [n for n in names if n not in other.get("locals", {})]
+                if any(set(visible) & set(c["names"]) for c in other.get("conditions", [])):
+                    return True
+        return False
+
+    # ------------------------------------------------------------------
+    # Results
+    # ------------------------------------------------------------------
+
+    def find(self, path: Optional[str] = None) -> List[Dict[str, Any]]:
+        """
+        Every configuration key read, optionally only by code under path.
+
+        Returns:
+            [{"key", "source", "dynamic", "default", "control_flow", "reads": [...]}]
+            sorted by source and key; viper keys carry "env" when bound
+        """
+        self._find_accessors()
+        self._find_viper_settings()
+        keys: Dict[Tuple[str, str, bool], Dict[str, Any]] = {}
+        for file_path, func, call in self._sites():
+            if path and file_path != path and not file_path.startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            classified = self._classify(file_path, func or {}, call)
+            if classified is None:
+                continue
+            source, api, key_index, default_index = classified
+            key = self._key(file_path, call, key_index)
+            if key is None:
+                continue
+            if func is not None and key["dynamic"] and source == "env":
+                node_key = (os.path.dirname(file_path),
+                            f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"])
+                if node_key in self._accessors:
+                    # The accessor's own read: its callers are reported instead
+                    continue
+            names = list(call.get("assigned_to", []))
+            if source == "flag" and default_index is not None and key_index == 1 and call["args"][0]:
+                # flag.IntVar(&port, ...) sets port
+                names.append(call["arg_texts"][0].lstrip("&").split(".")[-1])
+            read: Dict[str, Any] = {
+                "api": api,
+                "function": _function_name(func),
+                "path": file_path,
+                "line": call["line"],
+                "column": call["column"],
+                "control_flow": self._decides(file_path, func, call, names),
+            }
+            if func is None and names:
+                read["package_var"] = names[0]
+            default = self._default(call, default_index)
+            if default is not None:
+                read["default"] = default
+            entry = keys.setdefault((source, key["key"], key["dynamic"]), {
+                "key": key["key"], "source": source, "dynamic": key["dynamic"], "default": None, "reads": []})
+            entry["reads"].append(read)
+            if entry["default"] is None and default is not None:
+                entry["default"] = default
+
+        for entry in keys.values():
+            entry["control_flow"] = any(r["control_flow"] for r in entry["reads"])
+            if entry["source"] == "viper" and not entry["dynamic"]:
+                setting = self._viper_defaults.get(entry["key"])
+                if setting is not None and entry["default"] is None:
+                    entry["default"] = setting["value"]
+                    entry["default_at"] = {"path": setting["path"], "line": setting["line"]}
+                if entry["key"] in self._viper_env:
+                    entry["env"] = self._viper_env[entry["key"]]
+        return sorted(keys.values(), key=lambda e: (e["source"], e["dynamic"], e["key"]))
+
+
+def cross_reference(keys: List[Dict[str, Any]], declarations: List[Dict[str, Any]]) -> Dict[str, Any]:
+    """
+    Compare the environment variables read in code with those declared in
+    .env examples and compose files.
+
+    Args:
+        keys: ConfigUsageFinder.find() results
+        declarations: [{"path", "kind", "entries": [{"key", "line", ...}]}]
+
+    Returns:
+        {"files": [...], "missing_from_files": [keys read, declared nowhere],
+         "not_read_in_code": [declared keys no code reads]}
+    """
+    read = {}
+    for entry in keys:
+        if entry["dynamic"]:
+            continue
+        if entry["source"] == "env":
+            read.setdefault(entry["key"], entry)
+        for name in entry.get("env", []):
+            read.setdefault(name, entry)
+    declared: Dict[str, List[Dict[str, Any]]] = {}
+    for declaration in declarations:
+        for item in declaration["entries"]:
+            declared.setdefault(item["key"], []).append({"path": declaration["path"], **item})
+    missing = [{"key": name, "source": entry["source"], "read_at": [
+        {"path": r["path"], "line": r["line"]} for r in entry["reads"]]}
+        for name, entry in sorted(read.items()) if name not in declared]
+    # Values are left out: compose files can hold real secrets
+    unread = [{k: v for k, v in item.items() if v is not None and k != "value"}
+              for name in sorted(declared) if name not in read for item in declared[name]]
+    return {
+        "files": [{"path": d["path"], "kind": d["kind"], "keys": len(d["entries"])} for d in declarations],
+        "missing_from_files": missing,
+        "not_read_in_code": unread,
+    }
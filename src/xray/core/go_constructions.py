@@ -0,0 +1,241 @@
+"""Where values of a Go struct type are constructed.
+
+Before adding a field that must always be set you want every place a value
+of the type comes from. ConstructionFinder lists, for one struct type:
+
+    composite   `User{...}` and `&User{...}`, qualified (`store.User{}`),
+                instantiated (`Box[int]{}`) or with the type elided inside
+                a slice or map literal (`[]User{{...}}`); "keyed" literals
+                name the fields they set, "positional" ones set the fields
+                in declaration order, and `User{}` is "empty"
+    new         `new(User)`
+    zero_value  `var u User` without an initializer, alone or in a group
+
+With missing_field, only the constructions that leave that field unset
+are kept: keyed and empty literals without it, positional literals with
+too few elements to reach it, and every `new` and zero value.
+"""
+
+import os
+from typing import Any, Callable, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoProject
+from xray.core.go_helpers import token_is
+from xray.core.go_parser import Token, tokenize
+from xray.core.go_rename import literal_type
+
+CONSTRUCTION_KINDS = ("composite", "new", "zero_value")
+
+_OPENING = {")": "(", "]": "[", "}": "{"}
+
+
+def _closing(tokens: List[Token], opening: int) -> int:
+    depth = 0
+    for k in range(opening, len(tokens)):
+        tok = tokens[k]
+        if tok.kind != "op":
+            continue
+        if tok.value in ("(", "[", "{"):
+            depth += 1
+        elif tok.value in _OPENING:
+            depth -= 1
+            if depth == 0:
+                return k
+    return len(tokens) - 1
+
+
+def _elements(tokens: List[Token], brace: int) -> Tuple[List[Tuple[int, int]], bool]:
+    """The (start, end) token ranges of a literal's elements, and whether they are keyed."""
+    close = _closing(tokens, brace)
+    elements, start, depth, keyed = [], brace + 1, 0, False
+    for k in range(brace + 1, close):
+        tok = tokens[k]
+        if tok.kind != "op":
+            continue
+        if tok.value in ("(", "[", "{"):
+            depth += 1
+        elif tok.value in _OPENING:
+            depth -= 1
+        elif depth == 0 and tok.value == ":":
+            keyed = True
+        elif depth == 0 and tok.value in (",", ";"):
+            if start < k:
+                elements.append((start, k))
+            start = k + 1
+    if start < close:
+        elements.append((start, close))
+    return elements, keyed
+
+
+class ConstructionFinder:
+    """Finds the composite literals, new() calls and zero values of a struct type."""
+
+    def __init__(self, project: GoProject, read: Callable[[str], Optional[str]]):
+        self.project = project
+        self._read = read
+
+    def _package_dir(self, path: str, parsed: Dict[str, Any], qualifier: Optional[str]) -> List[str]:
+        """The project packages an unqualified (None) or qualified type name in a file can come from."""
+        if qualifier is None:
+            dirs = [os.path.dirname(path)]
+            dirs += [self.project.import_dir(imp["path"], path) for imp in parsed.get("imports", [])
+                     if imp["kind"] == "dot"]
+            return [d for d in dirs if d]
+        for imp in parsed.get("imports", []):
+            if imp["kind"] in ("default", "alias") and imp["name"] == qualifier:
+                found = self.project.import_dir(imp["path"], path)
+                return [found] if found else []
+        return []
+
+    def _names_target(self, path: str, parsed: Dict[str, Any], qualifier: Optional[str],
+                      target: Tuple[str, str], package: str) -> bool:
+        if target[0] not in self._package_dir(path, parsed, qualifier):
+            return False
+        # A file of the type's directory only sees it unqualified from the same package, not an external _test one
+        return qualifier is not None or os.path.dirname(path) != target[0] or parsed.get("package") == package
+
+    @staticmethod
+    def _type_at(tokens: List[Token], k: int) -> Tuple[Optional[Tuple[Optional[str], str]], int]:
+        """The (qualifier, name) of a type name starting at tokens[k], and the index after it."""
+        if k >= len(tokens) or tokens[k].kind != "ident":
+            return None, k
+        if token_is(tokens, k + 1, ".") and k + 2 < len(tokens) and tokens[k + 2].kind == "ident":
+            return (tokens[k].value, tokens[k + 2].value), k + 3
+        return (None, tokens[k].value), k + 1
+
+    @staticmethod
+    def _enclosing(parsed: Dict[str, Any], line: int) -> Optional[Dict[str, Any]]:
+        """The innermost package-level declaration spanning a line."""
+        best = None
+        for symbol in parsed["symbols"]:
+            if symbol.get("container") or symbol.get("nested") or symbol["type"] == "field":
+                continue
+            if symbol["start_line"] <= line <= symbol.get("end_line", symbol["start_line"]):
+                if best is None or symbol["start_line"] >= best["start_line"]:
+                    best = symbol
+        return best
+
+    def _composite(self, tokens: List[Token], brace: int, fields: List[str]) -> Optional[Tuple[int, Dict[str, Any]]]:
+        """A literal of the type opened at tokens[brace]: where it starts, its style and the fields it sets."""
+        elements, keyed = _elements(tokens, brace)
+        if not elements:
+            entry: Dict[str, Any] = {"style": "empty", "fields": []}
+        elif keyed:
+            entry = {"style": "keyed", "fields": [tokens[start].value for start, end in elements
+                                                  if tokens[start].kind == "ident" and token_is(tokens, start + 1, ":")]}
+        else:
+            entry = {"style": "positional", "fields": fields[:len(elements)]}
+        if tokens[brace - 1].kind == "op" and tokens[brace - 1].value in ("{", ",", ":"):
+            return brace, {"kind": "composite", "elided": True, **entry}
+        k = brace - 1
+        if token_is(tokens, k, "]"):
+            depth = 0
+            while k >= 0:
+                if token_is(tokens, k, "]"):
+                    depth += 1
+                elif token_is(tokens, k, "[") and depth == 1:
+                    break
+                elif token_is(tokens, k, "["):
+                    depth -= 1
+                k -= 1
+            k -= 1
+        if token_is(tokens, k - 1, "."):
+            k -= 2
+        before = tokens[k - 1] if k >= 1 else None
+        if before is not None and before.kind == "op" and before.value in (")", "*") or \
+                before is not None and before.kind == "keyword" and before.value == "func":
+            # A result type before a function body: `func New() *User {`
+            return None
+        address = token_is(tokens, k - 1, "&")
+        return (k - 1 if address else k), {"kind": "composite", "address": address, **entry}
+
+    def find(self, target: Dict[str, Any], missing_field: Optional[str] = None,
+             include_tests: bool = True, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Every construction of a struct type, in file and line order.
+
+        Args:
+            target: The type's symbol, as GoProject.find_types returns it
+            missing_field: Only constructions that leave this field unset
+            include_tests: Also scan _test.go files (entries get "in_test")
+            path: Only this file or package directory (and below)
+        """
+        key = (os.path.dirname(target["path"]), target["name"])
+        fields = [f["name"] for f in self.project.fields.get(key, [])]
+        if missing_field is not None and missing_field not in fields:
+            raise ValueError(f"{target['name']} has no field '{missing_field}'"
+                             + (f"; its fields are {', '.join(fields)}" if fields else ""))
+        name = target["name"]
+        constructions = []
+        for file_path, parsed in sorted(self.project.files.items()):
+            is_test = file_path.endswith("_test.go")
+            if is_test and not include_tests:
+                continue
+            if path and file_path != path and not file_path.startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            content = self._read(file_path)
+            if content is None or name not in content:
+                continue
+            tokens, _ = tokenize(content)
+            found: List[Tuple[Token, Dict[str, Any]]] = []
+            for index, tok in enumerate(tokens):
+                if tok.kind == "op" and tok.value == "{":
+                    lit = literal_type(tokens, index)
+                    if lit is None or lit[0] != "named" or lit[1] is None or lit[1][1] != name:
+                        continue
+                    if not self._names_target(file_path, parsed, lit[1][0], key, target["package"]):
+                        continue
+                    literal = self._composite(tokens, index, fields)
+                    if literal is not None:
+                        found.append((tokens[literal[0]], literal[1]))
+                elif tok.kind == "ident" and tok.value == "new" and token_is(tokens, index + 1, "("):
+                    named, after = self._type_at(tokens, index + 2)
+                    if named is None or named[1] != name or not token_is(tokens, after, ")"):
+                        continue
+                    if self._names_target(file_path, parsed, named[0], key, target["package"]):
+                        found.append((tok, {"kind": "new", "fields": []}))
+                elif tok.kind == "keyword" and tok.value == "var":
+                    specs = []
+                    if token_is(tokens, index + 1, "("):
+                        close, start = _closing(tokens, index + 1), index + 2
+                        for k in range(index + 2, close + 1):
+                            if k == close or token_is(tokens, k, ";"):
+                                specs.append(start)
+                                start = k + 1
+                    else:
+                        specs.append(index + 1)
+                    for start in specs:
+                        names, k = [], start
+                        while k < len(tokens) and tokens[k].kind == "ident":
+                            names.append(tokens[k].value)
+                            if not token_is(tokens, k + 1, ","):
+                                break
+                            k += 2
+                        named, after = self._type_at(tokens, k + 1)
+                        if not names or named is None or named[1] != name:
+                            continue
+                        if token_is(tokens, after, "=") or not self._names_target(file_path, parsed, named[0], key,
+                                                                              target["package"]):
+                            continue
+                        found.append((tokens[start], {"kind": "zero_value", "names": names, "fields": []}))
+            for at, entry in sorted(found, key=lambda item: (item[0].line, item[0].col)):
+                if missing_field is not None and missing_field in entry["fields"]:
+                    continue
+                record: Dict[str, Any] = {"path": file_path, "line": at.line, "column": at.col, **entry}
+                symbol = self._enclosing(parsed, at.line)
+                if symbol is not None:
+                    record["symbol"] = GoProject.declaration_name(symbol) or symbol["name"]
+                    record["symbol_type"] = symbol["type"]
+                if is_test:
+                    record["in_test"] = True
+                constructions.append(record)
+        result: Dict[str, Any] = {
+            "type": {"name": name, "package": target["package"], "path": target["path"],
+                     "start_line": target["start_line"], "fields": fields},
+            "constructions": constructions,
+            "total_count": len(constructions),
+            "counts": {kind: sum(1 for c in constructions if c["kind"] == kind) for kind in CONSTRUCTION_KINDS},
+        }
+        if missing_field is not None:
+            result["missing_field"] = missing_field
+        return result
@@ -0,0 +1,220 @@
+"""Context propagation audit for Go code.
+
+Two findings. Exported functions and methods that take no context.Context
+but call something that would accept one: a standard library API with a
+Context variant (db.QueryRow next to QueryRowContext, http.Get next to
+NewRequestWithContext), a project function whose first parameter is a
+context, or any call given a context as its first argument (gRPC clients).
+And calls to context.Background()/TODO() outside main() and tests, which cut
+a request's deadline and cancellation off.
+
+For both, the callers of the function are walked up to the nearest ones
+that do have a context - a context.Context parameter or an *http.Request -
+so the report shows where it was available and where it was dropped.
+"""
+
+import os
+from typing import Any, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+from xray.core.go_helpers import file_imports
+
+# How far up the callers to look for a context, and how many chains to list
+CHAIN_DEPTH = 6
+MAX_CHAINS = 5
+
+_FOLLOWED_KINDS = ("call", "go", "defer")
+
+# Standard library calls with a context-aware variant: method or function -> suggestion
+_SQL_METHODS = {
+    "Query": "QueryContext", "QueryRow": "QueryRowContext", "Exec": "ExecContext",
+    "Prepare": "PrepareContext", "Ping": "PingContext", "Begin": "BeginTx",
+}
+_CONTEXT_VARIANTS: Dict[str, str] = {
+    **{f"database/sql.{t}.{m}": v for t in ("DB", "Tx", "Conn") for m, v in _SQL_METHODS.items()},
+    **{f"database/sql.Stmt.{m}": f"{m}Context" for m in ("Query", "QueryRow", "Exec")},
+    **{f"net/http.{f}": "http.NewRequestWithContext + Client.Do" for f in ("Get", "Post", "Head", "PostForm")},
+    **{f"net/http.Client.{f}": "http.NewRequestWithContext + Client.Do" for f in ("Get", "Post", "Head", "PostForm")},
+    "net/http.NewRequest": "http.NewRequestWithContext",
+    "net.Dial": "net.Dialer.DialContext",
+    "net.DialTimeout": "net.Dialer.DialContext",
+    "net.LookupHost": "net.Resolver.LookupHost",
+    "net.LookupIP": "net.Resolver.LookupIPAddr",
+    "os/exec.Command": "exec.CommandContext",
+    **{f"github.com/jmoiron/sqlx.DB.{m}": f"{m}Context" for m in ("Get", "Select", "NamedExec", "Queryx", "QueryRowx")},
+}
+
+_CONTEXT_FACTORIES = ("Background", "TODO")
+
+
+class ContextAuditor:
+    """Finds where a context.Context stops being passed along."""
+
+    def __init__(self, graph: GoCallGraph):
+        self.graph = graph
+        self.project = graph.project
+        self._sites: Dict[Tuple[str, int, int], Dict[str, Any]] = {}
+        self._callers: Dict[Tuple[str, str], List[Dict[str, Any]]] = {}
+        for edge in graph.edges:
+            self._sites.setdefault((edge["path"], edge["line"], edge["column"]), edge)
+            if edge["kind"] in _FOLLOWED_KINDS and not edge["external"]:
+                self._callers.setdefault(edge["callee"], []).append(edge)
+
+    def context_source(self, key: Tuple[str, str]) -> Optional[str]:
+        """How a function can get at a context: its context.Context parameter, or r.Context() of an *http.Request."""
+        symbol = self.graph.functions.get(key)
+        if symbol is None:
+            return None
+        imports = file_imports(self.project.files[self.graph.nodes[key]["path"]])
+        names = {name: path for name, path in imports.items() if path in ("context", "net/http")}
+        for param in symbol.get("params", []):
+            qualifier, _, type_name = param["type"].lstrip("*").partition(".")
+            if names.get(qualifier) == "context" and type_name == "Context":
+                return param["name"] or "_"
+            if names.get(qualifier) == "net/http" and type_name == "Request" and param["type"].startswith("*"):
+                return f"{param['name']}.Context()" if param["name"] else None
+        return None
+
+    def _takes_context(self, key: Tuple[str, str]) -> bool:
+        """Whether a project function's first parameter is a context.Context."""
+        symbol = self.graph.functions.get(key)
+        params = symbol.get("params", []) if symbol else []
+        if not params:
+            return False
+        imports = file_imports(self.project.files[self.graph.nodes[key]["path"]])
+        qualifier, _, type_name = params[0]["type"].partition(".")
+        return imports.get(qualifier) == "context" and type_name == "Context"
+
+    @staticmethod
+    def _is_context_arg(arg: Optional[Dict[str, Any]], func: Dict[str, Any], context_names: List[str]) -> bool:
+        """
+        A first argument that is evidently a context: context.X(...), a local
+        made from one (`ctx, cancel := context.WithTimeout(...)`), a field
+        or variable named ctx, or r.Context().
+        """
+        if not arg or "chain" not in arg:
+            return False
+        chain = arg["chain"]
+        if len(chain) == 1:
+            source = func.get("locals", {}).get(chain[0]) or {}
+            source = source.get("tuple", source)
+            if source.get("chain") and source["chain"][0] in context_names:
+                return True
+        if chain[0] in context_names and len(chain) > 1:
+            return True
+        return chain[-1] == "ctx" or chain[-2:] == ["Context", "()"]
+
+    def _context_calls(self, path: str, key: Tuple[str, str], func: Dict[str, Any],
+                       imports: Dict[str, str]) -> List[Dict[str, Any]]:
+        """Calls in a function that would accept a context, with what to use instead."""
+        context_names = [name for name, imp in imports.items() if imp == "context"]
+        calls = []
+        for call in func.get("calls", []):
+            if call["chain"][0] in context_names:
+                continue
+            edge = self._sites.get((path, call["line"], call["column"]))
+            entry = {"line": call["line"], "column": call["column"]}
+            if edge is not None and edge["external"] and edge["callee"][1] in _CONTEXT_VARIANTS:
+                entry.update({"call": edge["callee"][1], "use_instead": _CONTEXT_VARIANTS[edge["callee"][1]]})
+            elif edge is not None and not edge["external"] and self._takes_context(edge["callee"]):
+                entry.update({"call": edge["callee"][1], "reason": "takes a context.Context first"})
+            elif call.get("args") and self._is_context_arg(call["args"][0], func, context_names):
+                entry.update({"call": ".".join(call["chain"]), "reason": f"is passed {call['arg_texts'][0]}"})
+            else:
+                continue
+            calls.append(entry)
+        return calls
+
+    def context_chains(self, key: Tuple[str, str]) -> List[Dict[str, Any]]:
+        """
+        Call chains from the nearest callers that have a context down to
+        key, which does not: each lists the functions from the one holding
+        the context ("context" tells how) to key, with the call sites.
+        """
+        chains = []
+        frontier: List[Tuple[Tuple[str, str], List[Dict[str, Any]]]] = [(key, [])]
+        visited = {key}
+        for _ in range(CHAIN_DEPTH):
+            next_frontier = []
+            for current, below in frontier:
+                for edge in self._callers.get(current, ()):
+                    caller = edge["caller"]
+                    if caller in visited:
+                        continue
+                    visited.add(caller)
+                    step = {"function": caller[1], "path": edge["path"], "line": edge["line"],
+                            "calls": current[1]}
+                    source = self.context_source(caller)
+                    if source is not None:
+                        chains.append({"context": source, "chain": [{**step, "context": source}] + below})
+                        if len(chains) >= MAX_CHAINS:
+                            return chains
+                    else:
+                        next_frontier.append((caller, [step] + below))
+            frontier = next_frontier
+        return chains
+
+    def audit(self, include_unexported: bool = False, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Args:
+            include_unexported: Also check unexported functions and methods
+                for missing contexts
+            path: Only this file or directory (and below)
+
+        Returns:
+            {"missing_context": [...], "background_calls": [...], "counts"}
+        """
+        missing = []
+        background = []
+        for file_path, parsed in sorted(self.project.files.items()):
+            if file_path.endswith("_test.go"):
+                continue
+            if path and file_path != path and not file_path.startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            imports = file_imports(parsed)
+            context_names = [name for name, imp in imports.items() if imp == "context"]
+            pkg_dir = os.path.dirname(file_path)
+            for func in parsed.get("functions", []):
+                name = f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+                key = (pkg_dir, name)
+                if key not in self.graph.nodes:
+                    continue
+                source = self.context_source(key)
+                node = self.graph.nodes[key]
+
+                if source is None and (include_unexported or func["name"][:1].isupper()):
+                    calls = self._context_calls(file_path, key, func, imports)
+                    if calls:
+                        missing.append({
+                            "function": name, "package": node["package"], "path": file_path,
+                            "line": node["line"], "signature": node["signature"],
+                            "calls": calls, "context_available_in": self.context_chains(key),
+                        })
+
+                if parsed.get("package") == "main" and func["name"] in ("main", "init") and not func.get("receiver"):
+                    continue
+                for call in func.get("calls", []):
+                    chain = call["chain"]
+                    if len(chain) != 2 or chain[0] not in context_names or chain[1] not in _CONTEXT_FACTORIES:
+                        continue
+                    text = f"{chain[0]}.{chain[1]}()"
+                    entry: Dict[str, Any] = {"function": name, "package": node["package"], "path": file_path,
+                                             "line": call["line"], "column": call["column"],
+                                             "call": f"context.{chain[1]}"}
+                    outer = next((c for c in func.get("calls", []) if c["line"] == call["line"]
+                                  and c is not call and text in c.get("arg_texts", [])), None)
+                    if outer is not None:
+                        entry["passed_to"] = ".".join(outer["chain"])
+                    if source is not None:
+                        # The function had a context and made a fresh one anyway
+                        entry["context_available"] = source
+                    else:
+                        entry["context_available_in"] = self.context_chains(key)
+                    background.append(entry)
+
+        return {
+            "missing_context": missing,
+            "background_calls": background,
+            "counts": {"missing_context": len(missing), "background_calls": len(background),
+                       "dropped": sum(1 for b in background if "context_available" in b)},
+        }
@@ -0,0 +1,289 @@
+"""Go coverage profiles mapped onto the functions they measure.
+
+`go test -coverprofile=cover.out` writes one line per basic block:
+
+    mode: set
+    example.com/app/store/store.go:23.45,26.16 2 1
+
+- file (import path and name), start line.column and end line.column, the
+  number of statements and how often the block ran. A function's statements
+  are those of the blocks inside it; it is covered per statement, as `go tool
+  cover -func` counts.
+
+A function's first block starts at its body's brace (or, from Go 1.22, at
+its first statement), every other block at a brace or statement. Each
+function is placed on the blocks by that shape: the line shifts putting a
+block at its entry are scored by how many blocks over its length start at
+its statements, and the shifts of a file are chosen together - functions
+keep their order and own separate blocks - fitting the most blocks, then
+changing least from one function to the next. A function placed unshifted
+with every block fitting is as profiled; one of a file changed since the
+profile was written is marked "approximate", with the "line_offset" from
+its lines to the profile's. Functions no block lines up with (added since)
+are listed as not in the profile. A function without statements is 0%
+covered, as `go tool cover` reports it.
+"""
+
+import os
+from typing import Any, Dict, List, NamedTuple, Optional, Set, Tuple
+
+from xray.core.go_analysis import GoProject
+from xray.core.go_parser import tokenize
+
+
+class CoverBlock(NamedTuple):
+    start_line: int
+    start_col: int
+    end_line: int
+    end_col: int
+    statements: int
+    count: int
+
+
+def parse_profile(text: str) -> Tuple[str, Dict[str, List[CoverBlock]]]:
+    """
+    The mode and blocks of a coverage profile, by file name as written.
+    Blocks listed more than once (several test binaries) are merged: a set
+    mode block ran if any run did, counts are added otherwise.
+    """
+    lines = [line.strip() for line in text.splitlines() if line.strip()]
+    if not lines or not lines[0].startswith("mode:"):
+        raise ValueError("Not a Go coverage profile: the first line must be 'mode: set|count|atomic'")
+    mode = lines[0].split(":", 1)[1].strip()
+    merged: Dict[str, Dict[Tuple[int, int, int, int], CoverBlock]] = {}
+    for number, line in enumerate(lines[1:], start=2):
+        if line.startswith("mode:"):
+            # Concatenated profiles repeat the header
+            continue
+        try:
+            location, statements, count = line.rsplit(" ", 2)
+            name, span = location.rsplit(":", 1)
+            start, end = span.split(",")
+            start_line, start_col = (int(part) for part in start.split("."))
+            end_line, end_col = (int(part) for part in end.split("."))
+            block = CoverBlock(start_line, start_col, end_line, end_col, int(statements), int(count))
+        except ValueError:
+            raise ValueError(f"Malformed coverage profile line {number}: {line!r}")
+        blocks = merged.setdefault(name, {})
+        key = block[:4]
+        if key in blocks:
+            previous = blocks[key].count
+            count = max(previous, block.count) if mode == "set" else previous + block.count
+            block = block._replace(count=count)
+        blocks[key] = block
+    return mode, {name: sorted(blocks.values()) for name, blocks in merged.items()}
+
+
+# Line shifts kept per function when aligning a changed file
+_CANDIDATES = 8
+
+
+def _statement_starts(source: str, functions: List[Dict[str, Any]]) -> Dict[int, Tuple[Set[Tuple[int, int]], Set[Tuple[int, int]]]]:
+    """
+    Where the coverage blocks of each function can start, by the function's
+    start line: (where its first block starts - the body's brace, just after
+    it for an empty body, or the first statement - and every brace and
+    statement start of the body), each as (line relative to the function's
+    start, column).
+    """
+    tokens, _ = tokenize(source)
+    by_position = {(tok.line, tok.col): idx for idx, tok in enumerate(tokens)}
+    starts = {}
+    for func in functions:
+        idx = by_position.get((func["start_line"], func["column"]))
+        if idx is None:
+            continue
+        depth = 0
+        while idx < len(tokens) and not (depth == 0 and tokens[idx].value == "{"):
+            if tokens[idx].value in ("(", "["):
+                depth += 1
+            elif tokens[idx].value in (")", "]"):
+                depth -= 1
+            idx += 1
+        if idx + 1 >= len(tokens):
+            continue
+        brace, first = tokens[idx], tokens[idx + 1]
+        base = func["start_line"]
+        entries = {(brace.line - base, brace.col), (brace.line - base, brace.col + 1), (first.line - base, first.col)}
+        positions = set(entries)
+        braces = 0
+        for k in range(idx, len(tokens)):
+            tok = tokens[k]
+            if tok.kind == "op" and tok.value == "{":
+                braces += 1
+                # Older profiles start a block at the brace
+                positions.add((tok.line - base, tok.col))
+            elif tok.kind == "op" and tok.value == "}":
+                braces -= 1
+                if braces == 0:
+                    break
+            if k > idx and tokens[k - 1].kind == "op" and tokens[k - 1].value in ("{", ";", ":"):
+                positions.add((tok.line - base, tok.col))
+        starts[base] = (entries, positions)
+    return starts
+
+
+def _percent(covered: int, statements: int) -> float:
+    return round(100.0 * covered / statements, 1) if statements else 0.0
+
+
+def _exported(name: str) -> bool:
+    return all(part[:1].isupper() for part in name.split("."))
+
+
+class CoverageMapper:
+    """Per-function and per-package coverage of a project from a profile."""
+
+    def __init__(self, project: GoProject):
+        self.project = project
+
+    def _file_of(self, name: str) -> Optional[str]:
+        """The project file a profile names: import path and file, or `_/abs/dir/file.go` outside modules."""
+        directory, base = name.rsplit("/", 1) if "/" in name else ("", name)
+        if directory.startswith("_/"):
+            pkg_dir = directory[1:]
+        elif os.path.isabs(name):
+            pkg_dir = directory
+        else:
+            pkg_dir = self.project.import_dir(directory) if directory else None
+        if pkg_dir is None:
+            return None
+        path = os.path.join(pkg_dir, base)
+        return path if path in self.project.files else None
+
+    def _file_functions(self, path: str, blocks: List[CoverBlock]) -> List[Dict[str, Any]]:
+        """Coverage of each function of one file."""
+        parsed = self.project.files[path]
+        every = sorted((s for s in parsed["symbols"] if s["type"] in ("function", "method")
+                        and "container" not in s and not s.get("nested") and "loc" in s),
+                       key=lambda s: s["start_line"])
+        try:
+            with open(path, encoding="utf-8", errors="replace") as handle:
+                shapes = _statement_starts(handle.read(), every)
+        except OSError:
+            shapes = {}
+        functions = [f for f in every if f["start_line"] in shapes]
+
+        def candidates(func: Dict[str, Any]) -> List[Tuple[int, List[int], int]]:
+            """(offset, block indexes, score) of the best shifts putting a block at the function's entry."""
+            entries, positions = shapes[func["start_line"]]
+            offsets = {b.start_line - func["start_line"] - line for b in blocks
+                       for line, col in entries if b.start_col == col}
+            found = []
+            for offset in offsets:
+                low, high = func["start_line"] + offset, func["end_line"] + offset
+                indexes = [i for i, b in enumerate(blocks) if low <= b.start_line and b.end_line <= high]
+                relative = [(blocks[i].start_line - low, blocks[i].start_col) for i in indexes]
+                if not relative or relative[0] not in entries:
+                    continue
+                fitting = sum(1 for p in relative if p in positions)
+                # Blocks of the range not at a statement belong to other code
+                score = 2 * fitting - len(relative)
+                if score > 0:
+                    found.append((offset, indexes, score))
+            found.sort(key=lambda c: (-c[2], abs(c[0])))
+            return found[:_CANDIDATES]
+
+        # Functions keep their order and own disjoint blocks: choose the shifts fitting the most blocks,
+        # then those changing least from function to function, then the smallest
+        items = [(n, candidate) for n, func in enumerate(functions) for candidate in candidates(func)]
+        best: List[Tuple[Tuple[int, int, int], int]] = []
+        for k, (n, (offset, indexes, score)) in enumerate(items):
+            value, previous = (score, 0, -abs(offset)), -1
+            for j in range(k):
+                m, (other, other_indexes, _) = items[j]
+                if m < n and other_indexes[-1] < indexes[0]:
+                    total = best[j][0]
+                    candidate = (total[0] + score, total[1] - (other != offset), total[2] - abs(offset))
+                    if candidate > value:
+                        value, previous = candidate, j
+            best.append((value, previous))
+        # function start line -> (block indexes, line offset, whether the function is as profiled)
+        matched: Dict[int, Tuple[List[int], int, bool]] = {}
+        k = max(range(len(items)), key=lambda i: best[i][0]) if items else -1
+        while k >= 0:
+            n, (offset, indexes, _) = items[k]
+            positions = shapes[functions[n]["start_line"]][1]
+            low = functions[n]["start_line"] + offset
+            exact = offset == 0 and all((blocks[i].start_line - low, blocks[i].start_col) in positions for i in indexes)
+            matched[functions[n]["start_line"]] = (indexes, offset, exact)
+            k = best[k][1]
+
+        entries = []
+        for func in every:
+            name = f"{func['receiver']['type']}.{func['name']}" if func.get("receiver") else func["name"]
+            entry: Dict[str, Any] = {"name": name, "type": func["type"], "package": parsed.get("package", ""),
+                                     "path": path, "start_line": func["start_line"], "end_line": func["end_line"]}
+            if func["start_line"] not in matched:
+                entry["in_profile"] = False
+                entries.append(entry)
+                continue
+            indexes, offset, exact = matched[func["start_line"]]
+            statements = sum(blocks[i].statements for i in indexes)
+            covered = sum(blocks[i].statements for i in indexes if blocks[i].count > 0)
+            entry.update({"covered": covered, "statements": statements, "percent": _percent(covered, statements)})
+            if not exact:
+                entry["approximate"] = True
+                entry["line_offset"] = offset
+            entries.append(entry)
+        return entries
+
+    def map(self, profile: str, threshold: Optional[float] = None, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Coverage by function, least covered first, with package rollups.
+
+        Args:
+            profile: The text of a coverage profile
+            threshold: Only list functions covered below this percentage
+            path: Only this file or package directory (and below)
+        """
+        mode, files = parse_profile(profile)
+        functions: List[Dict[str, Any]] = []
+        unmatched = []
+        for name, blocks in sorted(files.items()):
+            file_path = self._file_of(name)
+            if file_path is None:
+                unmatched.append(name)
+                continue
+            if path and file_path != path and not file_path.startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            functions.extend(self._file_functions(file_path, blocks))
+
+        measured = [f for f in functions if f.get("in_profile", True)]
+        packages: Dict[str, Dict[str, Any]] = {}
+        for func in measured:
+            pkg_dir = os.path.dirname(func["path"])
+            group = packages.setdefault(pkg_dir, {"package": func["package"], "directory": pkg_dir,
+                                                  "covered": 0, "statements": 0, "functions": 0})
+            group["covered"] += func["covered"]
+            group["statements"] += func["statements"]
+            group["functions"] += 1
+        for group in packages.values():
+            group["percent"] = _percent(group["covered"], group["statements"])
+        covered = sum(g["covered"] for g in packages.values())
+        statements = sum(g["statements"] for g in packages.values())
+
+        listed = [f for f in measured if threshold is None or f["percent"] < threshold]
+        listed.sort(key=lambda f: (f["percent"], -f["statements"], f["path"], f["start_line"]))
+        result: Dict[str, Any] = {
+            "functions": listed,
+            "total_count": len(listed),
+            "mode": mode,
+            "packages": [packages[d] for d in sorted(packages, key=lambda d: (packages[d]["percent"], d))],
+            "totals": {"covered": covered, "statements": statements, "percent": _percent(covered, statements),
+                       "functions": len(measured)},
+            "uncovered_exported": [
+                {k: f[k] for k in ("name", "package", "path", "start_line", "statements")}
+                for f in sorted(measured, key=lambda f: (f["path"], f["start_line"]))
+                if f["covered"] == 0 and f["statements"] and _exported(f["name"])
+            ],
+            "approximate_count": sum(1 for f in measured if f.get("approximate")),
+        }
+        missing = [{k: f[k] for k in ("name", "path", "start_line")} for f in functions if not f.get("in_profile", True)]
+        if missing:
+            result["not_in_profile"] = missing
+        if unmatched:
+            result["unmatched_files"] = unmatched
+        if threshold is not None:
+            result["threshold"] = threshold
+        return result
@@ -0,0 +1,250 @@
+"""Deprecated Go declarations and what still uses them.
+
+A declaration is deprecated by a `Deprecated:` paragraph in its doc comment
+(the Go convention go vet and gopls follow). DeprecationReport lists every
+deprecated declaration of a project with the references to it that remain,
+resolved as rename_preview resolves them (see xray.core.go_rename): its
+package's unqualified uses, `pkg.Name` uses elsewhere and selectors whose
+receiver is of the declaring type. References from _test.go files are
+counted apart, and every reference is grouped by the package making it.
+
+The deprecation text usually says what to use instead: "Use FetchUser
+instead", "Deprecated: replaced by [Client.Do]", "in favor of
+store.Open". The identifier it names is looked up in the project - a
+method of the same type, a declaration of the same package, then of the
+package the qualifier imports or names - so the report can say whether
+the replacement exists (and whether it is deprecated too). A name from a
+package outside the project (errors.Is) has "exists": null and the import
+path under "external".
+
+    deletable  no reference left in the project (an exported name may
+               still be used by other modules)
+    test_only  referenced by tests alone
+    in_use     referenced by non-test code
+"""
+
+import os
+import re
+from typing import Any, Callable, Dict, Iterable, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+from xray.core.go_helpers import file_imports
+from xray.core.go_rename import RenamePlanner
+from xray.core.go_tests import is_test_file
+
+# The phrases introducing a replacement, and the name after them ("[pkg.Name]", "*T", "Func()")
+_REPLACEMENT = re.compile(
+    r"\b(?:use|using|call|replaced\s+(?:by|with)|superseded\s+by|in\s+favou?r\s+of|prefer|switch\s+to|"
+    r"migrate\s+to|see)\s+(?:the\s+|a\s+)?\[?\*?([A-Za-z_][\w]*(?:\.[A-Za-z_]\w*)*)\]?(\(\))?(/[\w./-]+)?",
+    re.IGNORECASE)
+_STATUSES = ("in_use", "test_only", "deletable")
+# Edits of a rename that are not uses of the name
+_NOT_REFERENCES = {"definition", "doc_comment"}
+
+
+def replacement_names(text: str) -> List[str]:
+    """
+    The identifiers a deprecation text names as replacements, in order:
+    qualified ones and ones with an upper-case letter, or written as a
+    call - plain lower-case words ("use this package") are prose.
+    """
+    names = []
+    for match in _REPLACEMENT.finditer(text):
+        name, call, import_rest = match.groups()
+        if import_rest:
+            # An import path: github.com/org/repo/v2
+            continue
+        if "." not in name and not call and name.islower():
+            continue
+        if name not in names:
+            names.append(name)
+    return names
+
+
+class DeprecationReport:
+    """The deprecated declarations of a call graph's project and their remaining references."""
+
+    def __init__(self, graph: GoCallGraph, read: Callable[[str], Optional[str]],
+                 generated: Callable[[str], bool], extra_files: Iterable[Tuple[str, Dict[str, Any]]] = ()):
+        """Takes what RenamePlanner takes, to resolve references the same way."""
+        self.planner = RenamePlanner(graph, read, generated, extra_files)
+        self.project = graph.project
+        self._generated = generated
+        # (package dir, "Name" or "Type.Member") -> declarations
+        self._declared: Dict[Tuple[str, str], List[Dict[str, Any]]] = {}
+        for path, parsed in sorted(self.project.files.items()):
+            for symbol in parsed["symbols"]:
+                owner = (symbol.get("receiver") or {}).get("type") or symbol.get("container")
+                qualified = f"{owner}.{symbol['name']}" if owner else symbol["name"]
+                self._declared.setdefault((os.path.dirname(path), qualified), []).append(
+                    {**symbol, "path": path, "package": parsed.get("package", ""), "qualified_name": qualified})
+
+    def deprecated(self) -> List[Dict[str, Any]]:
+        """Every deprecated declaration, once per set of per-platform variants, by path and line."""
+        found, seen = [], set()
+        for records in self._declared.values():
+            for record in records:
+                if not record.get("deprecated") or record.get("column") is None:
+                    continue
+                if (record["path"], record["start_line"]) in seen:
+                    continue
+                seen.update((v["path"], v["line"]) for v in self.project.variants(record["path"], record))
+                seen.add((record["path"], record["start_line"]))
+                found.append(record)
+        found.sort(key=lambda r: (r["path"], r["start_line"]))
+        return found
+
+    def _lookup(self, pkg_dir: str, qualified: str) -> Optional[Dict[str, Any]]:
+        records = self._declared.get((pkg_dir, qualified))
+        return min(records, key=lambda r: (is_test_file(r["path"]), r["path"])) if records else None
+
+    def _imports(self, target: Dict[str, Any]) -> Dict[str, str]:
+        return file_imports(self.project.files[target["path"]])
+
+    def resolve(self, target: Dict[str, Any], name: str) -> Optional[Dict[str, Any]]:
+        """The project declaration a replacement name in target's deprecation text refers to."""
+        pkg_dir = os.path.dirname(target["path"])
+        owner = (target.get("receiver") or {}).get("type") or target.get("container")
+        head, _, rest = name.partition(".")
+        candidates: List[Tuple[str, str]] = []
+        if owner and not rest:
+            candidates.append((pkg_dir, f"{owner}.{name}"))
+        candidates.append((pkg_dir, name))
+        if rest:
+            imports = self._imports(target)
+            if head in imports:
+                imported = self.project.import_dir(imports[head], target["path"])
+                if imported:
+                    candidates.append((imported, rest))
+            named = sorted({os.path.dirname(path) for path, parsed in self.project.files.items()
+                            if parsed.get("package") == head or (self.project.import_path(os.path.dirname(path))
+                                                                 or "").endswith("/" + head)})
+            candidates.extend((directory, rest) for directory in named)
+        for candidate in candidates:
+            found = self._lookup(*candidate)
+            if found is not None:
+                return found
+        if name[:1].isupper():
+            # An exported name of any package, if only one declares it
+            anywhere = [records for (directory, qualified), records in self._declared.items() if qualified == name]
+            if len(anywhere) == 1:
+                return min(anywhere[0], key=lambda r: (is_test_file(r["path"]), r["path"]))
+        return None
+
+    def _replacement(self, target: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+        names = replacement_names(target.get("deprecation") or "")
+        if not names:
+            return None
+        for name in names:
+            found = self.resolve(target, name)
+            if found is None or found["path"] == target["path"] and found["start_line"] == target["start_line"]:
+                continue
+            replacement = {"name": name, "exists": True, "symbol": found["qualified_name"], "kind": found["type"],
+                           "package": found["package"], "path": found["path"], "line": found["start_line"]}
+            if found.get("deprecated"):
+                replacement["deprecated"] = True
+            return replacement
+        head, _, rest = names[0].partition(".")
+        imported = self._imports(target).get(head) if rest else None
+        if imported and self.project.import_dir(imported, target["path"]) is None:
+            # A package outside the project (errors.Is): there is nothing to check it against
+            return {"name": names[0], "exists": None, "external": imported}
+        return {"name": names[0], "exists": False}
+
+    @staticmethod
+    def _enclosing(parsed: Dict[str, Any], line: int) -> Optional[str]:
+        """The function or method (or other declaration) a line is in."""
+        inner = None
+        for symbol in parsed["symbols"]:
+            if symbol.get("container") or not symbol["start_line"] <= line <= (symbol.get("end_line") or 0):
+                continue
+            if inner is None or symbol["start_line"] >= inner["start_line"]:
+                inner = symbol
+        if inner is None:
+            return None
+        receiver = (inner.get("receiver") or {}).get("type")
+        return f"{receiver}.{inner['name']}" if receiver else inner["name"]
+
+    def usage(self, target: Dict[str, Any]) -> Dict[str, Any]:
+        """One deprecated declaration: its replacement, its remaining references by package, its status."""
+        found = self.planner.occurrences(target)
+        own = (target["path"], target["start_line"], target.get("end_line") or target["start_line"])
+        packages: Dict[str, Dict[str, Any]] = {}
+        references = test_references = 0
+        for edit in found["edits"]:
+            if edit["kind"] in _NOT_REFERENCES:
+                continue
+            if edit["path"] == own[0] and own[1] <= edit["line"] <= own[2]:
+                # The declaration using itself (recursion, a method on its own type)
+                continue
+            pkg_dir = os.path.dirname(edit["path"])
+            parsed = self.project.files.get(edit["path"]) or self.planner.extra.get(edit["path"]) or {"symbols": []}
+            group = packages.setdefault(pkg_dir, {
+                "package": parsed.get("package", ""),
+                "import_path": self.project.import_path(pkg_dir),
+                "path": pkg_dir,
+                "references": 0,
+                "test_references": 0,
+                "sites": [],
+            })
+            site = {"path": edit["path"], "line": edit["line"], "column": edit["column"], "kind": edit["kind"],
+                    "function": self._enclosing(parsed, edit["line"])}
+            if is_test_file(edit["path"]):
+                site["test"] = True
+                group["test_references"] += 1
+                test_references += 1
+            else:
+                group["references"] += 1
+                references += 1
+            if self._generated(edit["path"]):
+                site["generated"] = True
+            group["sites"].append(site)
+        unresolved = [u for u in found["unresolved"] if not (u["path"] == own[0] and own[1] <= u["line"] <= own[2])]
+
+        if references:
+            status = "in_use"
+        elif test_references:
+            status = "test_only"
+        else:
+            status = "deletable"
+        entry: Dict[str, Any] = {
+            "symbol": target["qualified_name"],
+            "kind": target["type"],
+            "package": target["package"],
+            "path": target["path"],
+            "line": target["start_line"],
+            "import_path": self.project.import_path(os.path.dirname(target["path"])),
+            "exported": target["name"][:1].isupper(),
+            "deprecation": target.get("deprecation") or "",
+        }
+        replacement = self._replacement(target)
+        if replacement is not None:
+            entry["replacement"] = replacement
+        entry.update({
+            "status": status,
+            "references": references,
+            "test_references": test_references,
+            # Non-test callers first; then by package
+            "by_package": sorted(packages.values(), key=lambda g: (-g["references"], -g["test_references"],
+                                                                    g["path"])),
+        })
+        if unresolved:
+            # Selectors whose receiver could not be typed: possibly more uses
+            entry["unresolved"] = [{"path": u["path"], "line": u["line"], "column": u["column"], "text": u["text"]}
+                                   for u in unresolved]
+        return entry
+
+    def report(self, targets: List[Dict[str, Any]]) -> Dict[str, Any]:
+        """The usage of each of targets (from deprecated()): the deletable first, then the most used."""
+        entries = [self.usage(target) for target in targets]
+        entries.sort(key=lambda e: (-_STATUSES.index(e["status"]), -e["references"], -e["test_references"],
+                                    e["path"], e["line"]))
+        counts = {status: sum(1 for e in entries if e["status"] == status) for status in _STATUSES}
+        counts.update({
+            "deprecated": len(entries),
+            "references": sum(e["references"] for e in entries),
+            "test_references": sum(e["test_references"] for e in entries),
+            "with_replacement": sum(1 for e in entries if e.get("replacement", {}).get("exists")),
+            "replacement_not_found": sum(1 for e in entries if e.get("replacement", {}).get("exists") is False),
+        })
+        return {"deprecated": entries, "total_count": len(entries), "counts": counts}
@@ -0,0 +1,846 @@
+"""The package import graph of a Go module, as JSON or GraphViz DOT.
+
+Nodes are packages named by import path; an edge A -> B means files of A
+import B, weighted by how many files of A do. TypeScript and JavaScript
+modules join the graph by directory, like Go packages, with edges for the
+imports that resolve to indexed files; Node builtins count as standard
+library and other bare specifiers as external packages. Python modules do
+the same, a directory with an `__init__.py` standing for its package, and
+`from pkg import mod` pointing at the submodule's directory. Rust files join
+by directory too, with edges for the files their `mod` declarations load and
+the modules their `use` paths resolve to; std, core and alloc are standard
+library and other crates external, named by crate. Packages can be collapsed to
+a directory depth, which merges their edges, and edges inside an import
+cycle (a strongly connected component of more than one node) are marked
+with the import statements that make them up.
+
+find_cycles looks at the Go packages alone, test files included: every
+cycle comes with elementary paths through it, the import lines behind
+each edge and a smallest set of edges whose removal breaks it.
+service_map walks the same graph from each main package, telling which
+packages go into which binaries, which several share, and which none does.
+coupling_metrics counts, for each Go package, the project packages that
+import it (afferent coupling, Ca) and those it imports (efferent, Ce), with
+the instability Ce / (Ca + Ce), and weighs every edge by the distinct
+identifiers its files reference across it (`store.Open`, `store.User`).
+"""
+
+import os
+from itertools import combinations
+from typing import Any, Callable, Dict, List, Optional, Set, Tuple
+
+from xray.core.go_analysis import GoProject
+from xray.core.py_analysis import PyProject, is_stdlib
+from xray.core.rs_analysis import RsProject
+from xray.core.ts_analysis import TsProject, is_builtin, is_relative, package_name
+from xray.core.ts_parser import source_language
+
+
+def is_std(import_path: str) -> bool:
+    """Standard library paths have no dot in their first element ("net/http", "fmt")."""
+    return "." not in import_path.split("/", 1)[0]
+
+
+def _truncate(path: str, depth: Optional[int]) -> str:
+    if depth is None:
+        return path
+    return "/".join(path.split("/")[:max(depth, 1)])
+
+
+def _cycles(nodes: List[str], edges: Dict[str, Set[str]]) -> List[List[str]]:
+    """Strongly connected components with more than one node (Tarjan), each sorted."""
+    index: Dict[str, int] = {}
+    low: Dict[str, int] = {}
+    stack: List[str] = []
+    on_stack: Set[str] = set()
+    components = []
+    counter = [0]
+
+    def visit(start: str):
+        # Iterative so deep import chains cannot hit the recursion limit
+        work = [(start, iter(sorted(edges.get(start, ()))))]
+        index[start] = low[start] = counter[0]
+        counter[0] += 1
+        stack.append(start)
+        on_stack.add(start)
+        while work:
+            node, children = work[-1]
+            child = next(children, None)
+            if child is not None:
+                if child not in index:
+                    index[child] = low[child] = counter[0]
+                    counter[0] += 1
+                    stack.append(child)
+                    on_stack.add(child)
+                    work.append((child, iter(sorted(edges.get(child, ())))))
+                elif child in on_stack:
+                    low[node] = min(low[node], index[child])
+                continue
+            work.pop()
+            if work:
+                parent = work[-1][0]
+                low[parent] = min(low[parent], low[node])
+            if low[node] == index[node]:
+                component = []
+                while True:
+                    member = stack.pop()
+                    on_stack.discard(member)
+                    component.append(member)
+                    if member == node:
+                        break
+                if len(component) > 1:
+                    components.append(sorted(component))
+
+    for node in nodes:
+        if node not in index:
+            visit(node)
+    return sorted(components)
+
+
+# What an import edge comes from, in order: a build error, an error under
+# `go test` (in-package test files), and what Go allows (external _test packages)
+CYCLE_KINDS = ("import", "test", "soft")
+
+# Elementary cycle paths listed per component, and the search budget behind them
+MAX_CYCLE_PATHS = 25
+_CYCLE_SEARCH_STEPS = 20000
+# Components with at most this many candidate edges get an exact smallest break set
+EXACT_BREAK_EDGES = 16
+
+
+def _elementary_cycles(members: Set[str], edges: Set[Tuple[str, str]],
+                       required: Optional[Set[Tuple[str, str]]] = None) -> Tuple[List[List[str]], bool]:
+    """
+    Simple cycles among members, shortest first, each starting at its
+    smallest node and closed by repeating it; with required, only cycles
+    using one of those edges. Returns (cycles, truncated).
+    """
+    successors: Dict[str, List[str]] = {}
+    for source, target in edges:
+        if source in members and target in members:
+            successors.setdefault(source, []).append(target)
+    for targets in successors.values():
+        targets.sort()
+    found: List[List[str]] = []
+    steps = 0
+    truncated = False
+    for start in sorted(members):
+        # Only nodes after start, so each cycle is found once, from its smallest node
+        stack = [(start, iter(successors.get(start, ())))]
+        path = [start]
+        while stack:
+            steps += 1
+            if steps > _CYCLE_SEARCH_STEPS:
+                truncated = True
+                break
+            node, children = stack[-1]
+            child = next(children, None)
+            if child is None:
+                stack.pop()
+                path.pop()
+                continue
+            if child == start:
+                cycle = path + [start]
+                pairs = set(zip(cycle, cycle[1:]))
+                if required is None or pairs & required:
+                    found.append(cycle)
+            elif child > start and child not in path:
+                path.append(child)
+                stack.append((child, iter(successors.get(child, ()))))
+        if truncated:
+            break
+    found.sort(key=lambda c: (len(c), c))
+    if len(found) > MAX_CYCLE_PATHS:
+        found, truncated = found[:MAX_CYCLE_PATHS], True
+    return found, truncated
+
+
+def _components(members: Set[str], edges: Set[Tuple[str, str]]) -> Set[frozenset]:
+    adjacency: Dict[str, Set[str]] = {}
+    for source, target in edges:
+        if source in members and target in members:
+            adjacency.setdefault(source, set()).add(target)
+    return {frozenset(c) for c in _cycles(sorted(members), adjacency)}
+
+
+def _breaking_edges(members: Set[str], edges: Set[Tuple[str, str]], candidates: List[Tuple[str, str]],
+                    baseline: Set[frozenset], weights: Dict[Tuple[str, str], int]) -> List[Tuple[str, str]]:
+    """
+    A smallest set of candidate edges whose removal leaves no cycle among
+    members beyond the baseline components (those of a stricter kind).
+    Exact for small components, greedy otherwise; ties go to the edges
+    made up of the fewest importing files.
+    """
+    def broken(removed) -> bool:
+        return _components(members, edges - set(removed)) <= baseline
+
+    chosen: List[Tuple[str, str]] = []
+    remaining = set(candidates)
+    while not broken(chosen) and remaining:
+        cycles, _ = _elementary_cycles(members, edges - set(chosen), remaining)
+        counts: Dict[Tuple[str, str], int] = {}
+        for cycle in cycles:
+            for pair in set(zip(cycle, cycle[1:])) & remaining:
+                counts[pair] = counts.get(pair, 0) + 1
+        if not counts:
+            counts = {pair: 0 for pair in remaining}
+        pick = min(counts, key=lambda pair: (-counts[pair], weights[pair], pair))
+        chosen.append(pick)
+        remaining.discard(pick)
+    # Drop edges the others made redundant
+    for pair in list(chosen):
+        rest = [p for p in chosen if p != pair]
+        if broken(rest):
+            chosen = rest
+    if len(candidates) <= EXACT_BREAK_EDGES:
+        for size in range(1, len(chosen)):
+            options = [combo for combo in combinations(sorted(candidates), size) if broken(combo)]
+            if options:
+                chosen = list(min(options, key=lambda combo: (sum(weights[p] for p in combo), combo)))
+                break
+    return sorted(chosen)
+
+
+def find_cycles(project: GoProject, module: Optional[str] = None, include_tests: bool = True) -> Dict[str, Any]:
+    """
+    Report every import cycle between the Go packages of a project.
+
+    Each edge takes the strictest kind of the files behind it: "import" for
+    regular files, "test" for in-package _test.go files (a cycle `go test`
+    rejects) and "soft" for external `package x_test` files, which Go allows.
+    A cycle's kind is that of the weakest edge it needs, so cycles that only
+    exist through test files are reported apart from real import cycles.
+
+    Args:
+        project: The parsed project
+        module: Module path from go.mod; without one, packages are named by directory
+            (packages of the project's go.mod modules take their import path)
+        include_tests: Also follow the imports of _test.go files
+
+    Returns:
+        {"module", "cycles", "total_count", "counts"}; every cycle lists its
+        packages, elementary paths ("path" is the shortest, closed by its
+        first package), the edges with their import sites, and
+        "suggested_break", a smallest set of edges whose removal breaks it
+    """
+    root = project.root or ""
+
+    def package_id(pkg_dir: str) -> str:
+        import_path = project.import_path(pkg_dir)
+        if import_path:
+            return import_path
+        rel = os.path.relpath(pkg_dir, root).replace(os.sep, "/") if root else pkg_dir
+        rel = "" if rel == "." else rel
+        if module:
+            return f"{module}/{rel}" if rel else module
+        return rel or "."
+
+    # (from, to) -> import sites, and the strictest kind among them
+    sites: Dict[Tuple[str, str], List[Dict[str, Any]]] = {}
+    levels: Dict[Tuple[str, str], int] = {}
+    for pkg_dir, info in sorted(project.packages.items()):
+        source = package_id(pkg_dir)
+        for path in info["files"]:
+            parsed = project.files[path]
+            level = 0
+            if path.endswith("_test.go"):
+                if not include_tests:
+                    continue
+                level = 2 if parsed.get("package", "").endswith("_test") else 1
+            for imp in parsed.get("imports", []):
+                target_dir = project.import_dir(imp["path"], path)
+                if target_dir is None or target_dir == pkg_dir:
+                    continue
+                pair = (source, package_id(target_dir))
+                sites.setdefault(pair, []).append({"path": path, "line": imp["line"], "column": imp.get("column"),
+                                                   "kind": CYCLE_KINDS[level]})
+                levels[pair] = min(levels.get(pair, level), level)
+
+    nodes = {n for pair in sites for n in pair}
+    weights = {pair: len({site["path"] for site in found}) for pair, found in sites.items()}
+    cycles = []
+    lower: Set[frozenset] = set()
+    for level, kind in enumerate(CYCLE_KINDS):
+        edges = {pair for pair, edge_level in levels.items() if edge_level <= level}
+        components = _components(nodes, edges)
+        for members in sorted(components - lower, key=sorted):
+            baseline = {c for c in lower if c <= members}
+            new_edges = {pair for pair in edges if levels[pair] == level and set(pair) <= members}
+            paths, truncated = _elementary_cycles(set(members), edges, new_edges if level else None)
+            # Only edges of this kind can break what the stricter edges did not already close
+            candidates = sorted(pair for pair in edges if set(pair) <= members and levels[pair] == level)
+            breaking = _breaking_edges(set(members), edges, candidates, baseline, weights)
+            entry = {
+                "kind": kind,
+                "permitted": kind == "soft",
+                "packages": sorted(members),
+                "path": paths[0] if paths else [],
+                "paths": paths,
+                "edges": [
+                    {"from": pair[0], "to": pair[1], "kind": CYCLE_KINDS[levels[pair]],
+                     "import_sites": [site for site in sites[pair] if CYCLE_KINDS.index(site["kind"]) <= level]}
+                    for pair in sorted(p for p in edges if set(p) <= members)
+                ],
+                "suggested_break": [
+                    {"from": pair[0], "to": pair[1], "files": weights[pair],
+                     "import_sites": [site for site in sites[pair] if CYCLE_KINDS.index(site["kind"]) <= level]}
+                    for pair in breaking
+                ],
+            }
+            if truncated:
+                entry["paths_truncated"] = True
+            cycles.append(entry)
+        lower |= components
+
+    return {
+        "module": module,
+        "cycles": cycles,
+        "total_count": len(cycles),
+        "counts": {kind: sum(1 for c in cycles if c["kind"] == kind) for kind in CYCLE_KINDS},
+    }
+
+
+def service_map(project: GoProject, module: Optional[str] = None,
+                keep: Optional[Callable[[str], bool]] = None) -> Dict[str, Any]:
+    """
+    The binaries of a project and the internal packages each one builds in.
+
+    A service is a `package main` with a `func main`; its packages are the
+    transitive closure of the internal imports of its non-test files. A
+    package no service reaches is "test_only" when test files import it,
+    "library" when it exports anything (other modules may use it), and
+    "dead" otherwise.
+
+    Args:
+        project: The parsed project
+        module: Module path from go.mod; without one, packages are named by directory
+            (packages of the project's go.mod modules take their import path)
+        keep: Only the files it accepts: the others neither import nor are
+            imported, so a main package left without files is no service
+
+    Returns:
+        {"module", "services", "shared", "unreachable", "service_count",
+        "package_count"}; services list their packages, the ones they share
+        with no other service counted as "exclusive"
+    """
+    root = project.root or ""
+    kept = keep or (lambda path: True)
+
+    def package_id(pkg_dir: str) -> str:
+        import_path = project.import_path(pkg_dir)
+        if import_path:
+            return import_path
+        rel = os.path.relpath(pkg_dir, root).replace(os.sep, "/") if root else pkg_dir
+        rel = "" if rel == "." else rel
+        if module:
+            return f"{module}/{rel}" if rel else module
+        return rel or "."
+
+    def rel(pkg_dir: str) -> str:
+        return os.path.relpath(pkg_dir, root).replace(os.sep, "/") if root else pkg_dir
+
+    # package dir -> internal packages its non-test files import; and those test files import
+    imports: Dict[str, Set[str]] = {}
+    tested: Set[str] = set()
+    mains: Dict[str, Dict[str, Any]] = {}
+    for pkg_dir, info in sorted(project.packages.items()):
+        files = [path for path in info["files"] if kept(path)]
+        if not any(not path.endswith("_test.go") for path in files):
+            continue
+        imports[pkg_dir] = set()
+        for path in files:
+            is_test = path.endswith("_test.go")
+            for imp in project.files[path].get("imports", []):
+                target_dir = project.import_dir(imp["path"], path)
+                if target_dir is None or target_dir == pkg_dir:
+                    continue
+                if is_test:
+                    tested.add(target_dir)
+                else:
+                    imports[pkg_dir].add(target_dir)
+            if info["name"] == "main" and not is_test:
+                for symbol in project.files[path]["symbols"]:
+                    if symbol["type"] == "function" and symbol["name"] == "main" and "container" not in symbol:
+                        mains[pkg_dir] = {"path": path, "line": symbol["start_line"]}
+    for targets in imports.values():
+        targets &= imports.keys()
+
+    reached: Dict[str, Set[str]] = {}
+    closures: Dict[str, Set[str]] = {}
+    for main_dir in mains:
+        seen: Set[str] = set()
+        work = [main_dir]
+        while work:
+            for target in imports[work.pop()]:
+                if target not in seen and target != main_dir:
+                    seen.add(target)
+                    work.append(target)
+        closures[main_dir] = seen
+        for pkg_dir in seen:
+            reached.setdefault(pkg_dir, set()).add(main_dir)
+
+    services = []
+    for main_dir, seen in sorted(closures.items(), key=lambda item: rel(item[0])):
+        name = os.path.basename(main_dir) if rel(main_dir) != "." else (module or os.path.basename(root)).split("/")[-1]
+        services.append({
+            "name": name,
+            "dir": rel(main_dir),
+            "package": package_id(main_dir),
+            "main": mains[main_dir],
+            "packages": sorted(package_id(d) for d in seen),
+            "package_count": len(seen),
+            "exclusive_count": sum(1 for d in seen if len(reached[d]) == 1),
+        })
+    shared = [
+        {"package": package_id(pkg_dir), "dir": rel(pkg_dir),
+         "services": sorted(rel(d) for d in users), "service_count": len(users)}
+        for pkg_dir, users in reached.items() if len(users) > 1
+    ]
+    shared.sort(key=lambda entry: (-entry["service_count"], entry["package"]))
+
+    unreachable = []
+    for pkg_dir in sorted(imports, key=package_id):
+        if pkg_dir in reached or pkg_dir in mains:
+            continue
+        exported = sum(1 for path in project.packages[pkg_dir]["files"]
+                       if kept(path) and not path.endswith("_test.go")
+                       for s in project.files[path]["symbols"]
+                       if "container" not in s and s["name"][:1].isupper())
+        importers = sorted(package_id(d) for d, targets in imports.items() if pkg_dir in targets)
+        kind = "test_only" if pkg_dir in tested else "library" if exported else "dead"
+        unreachable.append({"package": package_id(pkg_dir), "dir": rel(pkg_dir), "kind": kind,
+                            "exported": exported, "imported_by": importers})
+
+    return {
+        "module": module,
+        "services": services,
+        "shared": shared,
+        "unreachable": unreachable,
+        "service_count": len(services),
+        "package_count": len(imports),
+    }
+
+
+# Columns coupling_metrics sorts by; numbers go highest first, package names A-Z
+COUPLING_COLUMNS = ("afferent", "efferent", "instability", "files", "symbols_used", "symbols_provided", "package")
+
+
+def coupling_metrics(project: GoProject, module: Optional[str] = None, sort_by: str = "afferent",
+                     include_tests: bool = False, max_edges: int = 20,
+                     keep: Optional[Callable[[str], bool]] = None) -> Dict[str, Any]:
+    """
+    Afferent and efferent coupling and instability of every Go package.
+
+    Only imports between project packages count. An edge's weight is the
+    number of distinct identifiers the importing package's files select
+    from the imported one (`pkg.Name`, see the parser's qualified_refs);
+    blank and dot imports add an edge without weight. A package neither
+    importing nor imported has no instability (None).
+
+    Args:
+        project: The parsed project
+        module: Module path from go.mod; without one, packages are named by directory
+            (packages of the project's go.mod modules take their import path)
+        sort_by: One of COUPLING_COLUMNS; ties go by package
+        include_tests: Also count the imports and references of _test.go files
+        max_edges: How many of the heaviest edges to list
+        keep: Only the files it accepts: the others neither import nor are
+            imported, and a package left without files is left out
+
+    Returns:
+        {"module", "packages", "heaviest_edges", "total_count", "edge_count",
+        "sort_by"}; packages carry "afferent", "efferent", "instability",
+        "files", "symbols_used" (distinct identifiers of other project
+        packages they reference) and "symbols_provided" (their own that
+        other packages reference)
+    """
+    if sort_by not in COUPLING_COLUMNS:
+        raise ValueError(f"sort_by must be one of {', '.join(COUPLING_COLUMNS)}")
+    if max_edges < 0:
+        raise ValueError("max_edges must not be negative")
+    root = project.root or ""
+    kept = keep or (lambda path: True)
+
+    def package_id(pkg_dir: str) -> str:
+        import_path = project.import_path(pkg_dir)
+        if import_path:
+            return import_path
+        rel = os.path.relpath(pkg_dir, root).replace(os.sep, "/") if root else pkg_dir
+        rel = "" if rel == "." else rel
+        if module:
+            return f"{module}/{rel}" if rel else module
+        return rel or "."
+
+    def rel(pkg_dir: str) -> str:
+        return os.path.relpath(pkg_dir, root).replace(os.sep, "/") if root else pkg_dir
+
+    def counted(path: str) -> bool:
+        return kept(path) and (include_tests or not path.endswith("_test.go"))
+
+    file_counts = {pkg_dir: sum(1 for path in info["files"] if counted(path))
+                   for pkg_dir, info in project.packages.items()}
+    present = {pkg_dir for pkg_dir, count in file_counts.items() if count}
+
+    # (from dir, to dir) -> importing files, and identifier -> references
+    importers: Dict[Tuple[str, str], Set[str]] = {}
+    names: Dict[Tuple[str, str], Dict[str, int]] = {}
+    for pkg_dir in sorted(present):
+        for path in project.packages[pkg_dir]["files"]:
+            if not counted(path):
+                continue
+            parsed = project.files[path]
+            targets: Dict[str, str] = {}
+            for imp in parsed.get("imports", []):
+                target_dir = project.import_dir(imp["path"], path)
+                if target_dir is None or target_dir == pkg_dir or target_dir not in present:
+                    continue
+                targets[imp["path"]] = target_dir
+                pair = (pkg_dir, target_dir)
+                importers.setdefault(pair, set()).add(path)
+                names.setdefault(pair, {})
+            for ref in parsed.get("qualified_refs", []):
+                target_dir = targets.get(ref["package"])
+                if target_dir is not None:
+                    used = names[(pkg_dir, target_dir)]
+                    used[ref["name"]] = used.get(ref["name"], 0) + 1
+
+    afferent: Dict[str, Set[str]] = {}
+    efferent: Dict[str, Set[str]] = {}
+    provided: Dict[str, Set[str]] = {}
+    used_counts: Dict[str, Set[Tuple[str, str]]] = {}
+    for (source, target), used in names.items():
+        efferent.setdefault(source, set()).add(target)
+        afferent.setdefault(target, set()).add(source)
+        provided.setdefault(target, set()).update(used)
+        used_counts.setdefault(source, set()).update((target, name) for name in used)
+
+    packages = []
+    for pkg_dir in present:
+        ca, ce = len(afferent.get(pkg_dir, ())), len(efferent.get(pkg_dir, ()))
+        packages.append({
+            "package": package_id(pkg_dir),
+            "dir": rel(pkg_dir),
+            "afferent": ca,
+            "efferent": ce,
+            "instability": round(ce / (ca + ce), 3) if ca + ce else None,
+            "files": file_counts[pkg_dir],
+            "symbols_used": len(used_counts.get(pkg_dir, ())),
+            "symbols_provided": len(provided.get(pkg_dir, ())),
+        })
+    if sort_by == "package":
+        packages.sort(key=lambda entry: entry["package"])
+    else:
+        packages.sort(key=lambda entry: (entry[sort_by] is None, -(entry[sort_by] or 0), entry["package"]))
+
+    edges = [
+        {"from": package_id(source), "to": package_id(target), "symbol_count": len(used),
+         "references": sum(used.values()), "files": len(importers[(source, target)]), "symbols": sorted(used)}
+        for (source, target), used in names.items()
+    ]
+    edges.sort(key=lambda edge: (-edge["symbol_count"], -edge["references"], edge["from"], edge["to"]))
+
+    return {
+        "module": module,
+        "packages": packages,
+        "heaviest_edges": edges[:max_edges],
+        "total_count": len(packages),
+        "edge_count": len(edges),
+        "sort_by": sort_by,
+    }
+
+
+def dependency_graph(
+    project: GoProject,
+    module: Optional[str] = None,
+    requires: Optional[List[str]] = None,
+    depth: Optional[int] = None,
+    include_std: bool = False,
+    include_external: bool = False,
+    modules: Optional[TsProject] = None,
+    python: Optional[PyProject] = None,
+    rust: Optional[RsProject] = None,
+    keep: Optional[Callable[[str], bool]] = None,
+    describe: Optional[Callable[[str], Dict[str, Any]]] = None,
+    links: Optional[List[Dict[str, Any]]] = None,
+) -> Dict[str, Any]:
+    """
+    Build the import graph between the project's packages.
+
+    Args:
+        project: The parsed project
+        module: Module path from go.mod; without one, packages are named by directory
+            (packages of the project's go.mod modules take their import path)
+        requires: Module paths required in go.mod, used to group external imports
+        depth: Collapse internal packages to this many directory levels below their
+            module root; external imports are grouped by required module and
+            standard library ones cut to the same depth
+        include_std: Keep standard library packages as nodes
+        include_external: Keep packages of other modules as nodes
+        modules: TypeScript/JavaScript files to add, grouped by directory;
+            with depth set, npm imports are grouped by package
+        python: Python files to add, grouped by directory (package); with depth
+            set, imports of other distributions are grouped by top-level package
+        rust: Rust files to add, grouped by directory; other crates are
+            nodes named by crate
+        keep: Only the files it accepts: the others neither import nor are
+            imported, and a package left without files is no node
+        describe: The description of a directory ({"description", ...}) for
+            the internal nodes; a collapsed node takes that of the directory
+            its packages share
+        links: Cross-language links (see core/cross_language.py) to add as
+            edges of their kind between the directories they join; they take
+            no part in cycles
+    """
+    root = project.root or ""
+    kept = keep or (lambda path: True)
+    empty = {pkg_dir for pkg_dir, info in project.packages.items()
+             if keep and not any(keep(f) for f in info["files"])}
+    requires = sorted(requires or [], key=len, reverse=True)
+
+    def internal_id(pkg_dir: str) -> str:
+        import_path = project.modules.import_path(pkg_dir, depth) if project.modules is not None else None
+        if import_path:
+            return import_path
+        rel = os.path.relpath(pkg_dir, root).replace(os.sep, "/") if root else pkg_dir
+        rel = "" if rel == "." else _truncate(rel, depth)
+        if module:
+            return f"{module}/{rel}" if rel else module
+        return rel or "."
+
+    def external_id(import_path: str) -> str:
+        if depth is None:
+            return import_path
+        for required in requires:
+            if import_path == required or import_path.startswith(required + "/"):
+                return required
+        return import_path
+
+    def in_project(import_path: str, importer: str) -> bool:
+        if project.modules is not None and project.modules.modules:
+            return project.modules.owns(import_path, os.path.dirname(importer))
+        return bool(module) and (import_path == module or import_path.startswith(module + "/"))
+
+    nodes: Dict[str, Dict[str, Any]] = {}
+    # (from, to) -> files of `from` importing `to`, with the import's position
+    importers: Dict[Tuple[str, str], Dict[str, Dict[str, Any]]] = {}
+
+    def internal_node(pkg_dir: str, files: int, language: Optional[str]) -> str:
+        source = internal_id(pkg_dir)
+        node = nodes.setdefault(source, {"id": source, "kind": "internal", "packages": set(), "files": 0,
+                                         "languages": set()})
+        node.setdefault("languages", set())
+        node.setdefault("dirs", set()).add(pkg_dir)
+        node["packages"].add(os.path.relpath(pkg_dir, root).replace(os.sep, "/") if root else pkg_dir)
+        node["files"] += files
+        if language:
+            node["languages"].add(language)
+        return source
+
+    def add_edge(source: str, target: str, kind: str, package: str, path: str, imp: Dict[str, Any]):
+        if target == source:
+            return
+        if target not in nodes:
+            nodes[target] = {"id": target, "kind": kind, "packages": set(), "files": 0}
+        if kind != "internal":
+            nodes[target]["packages"].add(package)
+        importers.setdefault((source, target), {}).setdefault(
+            path, {"path": path, "line": imp["line"], "column": imp.get("column")})
+
+    for pkg_dir, info in sorted(project.packages.items()):
+        if pkg_dir in empty:
+            continue
+        files = [path for path in info["files"] if kept(path)]
+        source = internal_node(pkg_dir, len(files), "go")
+        for path in files:
+            for imp in project.files[path].get("imports", []):
+                target_dir = project.import_dir(imp["path"], path)
+                if (target_dir is None and in_project(imp["path"], path)) or target_dir in empty:
+                    # Inside a project module but not indexed (excluded or generated) or filtered out
+                    continue
+                if target_dir is not None:
+                    target, kind = internal_id(target_dir), "internal"
+                elif is_std(imp["path"]):
+                    if not include_std:
+                        continue
+                    target, kind = (_truncate(imp["path"], depth), "std")
+                else:
+                    if not include_external:
+                        continue
+                    target, kind = external_id(imp["path"]), "external"
+                add_edge(source, target, kind, imp["path"], path, imp)
+
+    for path in sorted(modules.files) if modules is not None else ():
+        if not kept(path):
+            continue
+        source = internal_node(os.path.dirname(path), 1, source_language(path))
+        for imp in modules.imports_of(path):
+            specifier = imp["source"]
+            if imp["resolved"] is not None and not kept(imp["resolved"]):
+                continue
+            if imp["resolved"] is not None:
+                target, kind = internal_id(os.path.dirname(imp["resolved"])), "internal"
+            elif is_relative(specifier):
+                # Points into the tree but not at an indexed file (excluded, or an asset)
+                continue
+            elif is_builtin(specifier):
+                if not include_std:
+                    continue
+                target, kind = "node:" + package_name(specifier), "std"
+            else:
+                if not include_external:
+                    continue
+                target, kind = (specifier if depth is None else package_name(specifier)), "external"
+            add_edge(source, target, kind, specifier, path, imp)
+
+    for path in sorted(python.files) if python is not None else ():
+        if not kept(path):
+            continue
+        pkg_dir = os.path.dirname(path)
+        source = internal_node(pkg_dir, 1, "python")
+        if pkg_dir in python.packages:
+            nodes[source].setdefault("python_packages", set()).add(python.dotted_name(python.packages[pkg_dir]))
+        for imp in python.imports_of(path):
+            dotted = python.target_of(path, imp)
+            # Each name of a from-import may be a submodule of its own
+            targets = {imp["resolved"]} if imp["kind"] == "import" or imp["star"] else \
+                {name["resolved"] for name in imp["names"]}
+            for resolved in sorted(targets, key=lambda t: t or ""):
+                if resolved is not None and not kept(resolved):
+                    continue
+                if resolved is not None:
+                    target, kind = internal_id(os.path.dirname(resolved)), "internal"
+                elif imp["level"]:
+                    continue
+                elif is_stdlib(dotted):
+                    if not include_std:
+                        continue
+                    target, kind = ".".join(dotted.split(".")[:depth]) if depth else dotted, "std"
+                else:
+                    if not include_external:
+                        continue
+                    target, kind = (dotted if depth is None else dotted.split(".", 1)[0]), "external"
+                add_edge(source, target, kind, dotted, path, imp)
+
+    for path in sorted(rust.files) if rust is not None else ():
+        if not kept(path):
+            continue
+        source = internal_node(os.path.dirname(path), 1, "rust")
+        for decl, child in rust.mod_children(path):
+            if kept(child):
+                add_edge(source, internal_id(os.path.dirname(child)), "internal", decl["name"], path, decl)
+        for imp in rust.imports_of(path):
+            if imp["origin"] == "internal":
+                if imp["resolved"] is None or not kept(imp["resolved"]):
+                    continue
+                target, kind = internal_id(os.path.dirname(imp["resolved"])), "internal"
+            elif imp["origin"] == "std":
+                if not include_std:
+                    continue
+                target, kind = imp["crate"], "std"
+            else:
+                if not include_external:
+                    continue
+                target, kind = imp["crate"], "external"
+            add_edge(source, target, kind, imp["path"], path, imp)
+
+    # (from, to, kind) -> links of the kind between the two
+    crossings: Dict[Tuple[str, str, str], int] = {}
+    for link in links or ():
+        source_path, target_path = link["from"]["path"], link["to"]["path"]
+        if not target_path or not kept(source_path) or not kept(target_path):
+            continue
+        source = internal_node(os.path.dirname(source_path), 0, link["from"].get("language"))
+        target = internal_node(os.path.dirname(target_path), 0, link["to"].get("language"))
+        if source != target:
+            crossings[(source, target, link["kind"])] = crossings.get((source, target, link["kind"]), 0) + 1
+
+    adjacency: Dict[str, Set[str]] = {}
+    for source, target in importers:
+        adjacency.setdefault(source, set()).add(target)
+    cycles = _cycles(sorted(nodes), adjacency)
+    component = {member: i for i, members in enumerate(cycles) for member in members}
+
+    edges = []
+    for (source, target), files in sorted(importers.items()):
+        edge = {"from": source, "to": target, "weight": len(files)}
+        if source in component and component.get(target) == component[source]:
+            edge["cycle"] = True
+            edge["import_sites"] = [files[path] for path in sorted(files)]
+        edges.append(edge)
+    edges.extend({"from": source, "to": target, "weight": count, "kind": kind}
+                 for (source, target, kind), count in sorted(crossings.items()))
+
+    node_list = []
+    for node_id in sorted(nodes):
+        node = nodes[node_id]
+        entry = {"id": node_id, "kind": node["kind"]}
+        if node["kind"] == "internal":
+            entry["files"] = node["files"]
+            entry["languages"] = sorted(node["languages"])
+            if node.get("python_packages"):
+                entry["python_packages"] = sorted(node["python_packages"])
+            if describe is not None:
+                entry.update(describe(os.path.commonpath(sorted(node["dirs"]))))
+        if depth is not None and len(node["packages"]) > 1:
+            entry["packages"] = sorted(node["packages"])
+        node_list.append(entry)
+
+    result: Dict[str, Any] = {
+        "module": module,
+        "depth": depth,
+        "nodes": node_list,
+        "edges": edges,
+        "cycles": cycles,
+        "node_count": len(node_list),
+        "edge_count": len(edges),
+    }
+    if project.modules is not None and len(project.modules.modules) > 1:
+        result["modules"] = sorted(m["path"] for m in project.modules.modules if m["path"])
+    return result
+
+
+def _quote(text: str) -> str:
+    """A DOT double-quoted string."""
+    return '"' + text.replace("\\", "\\\\").replace('"', '\\"').replace("\n", "\\n") + '"'
+
+
+def to_dot(graph: Dict[str, Any]) -> str:
+    """
+    Render a dependency_graph result as a GraphViz digraph.
+
+    Internal packages are boxes labelled relative to the module, other
+    packages dashed ellipses; edges carry their weight as label and
+    penwidth, cycle edges are red with class="cycle", and cross-language
+    links dashed, labelled and classed with their kind.
+    """
+    module = graph.get("module")
+
+    def label(node: Dict[str, Any]) -> str:
+        if node["kind"] == "internal" and module:
+            rel = node["id"][len(module):].lstrip("/")
+            return rel or os.path.basename(module) or module
+        return node["id"]
+
+    lines = [
+        "digraph dependencies {",
+        "    rankdir=LR;",
+        '    node [shape=box, fontname="Helvetica"];',
+        '    edge [fontname="Helvetica", fontsize=10];',
+    ]
+    for node in graph["nodes"]:
+        attrs = [f"label={_quote(label(node))}"]
+        if node["kind"] != "internal":
+            attrs += ["shape=ellipse", "style=dashed", f"class={_quote(node['kind'])}"]
+        lines.append(f"    {_quote(node['id'])} [{', '.join(attrs)}];")
+    for edge in graph["edges"]:
+        weight = edge["weight"]
+        attrs = [f"weight={weight}", f"label={_quote(str(weight))}", f"penwidth={min(1 + (weight - 1) * 0.5, 5):g}"]
+        if edge.get("kind"):
+            kind = edge["kind"]
+            attrs[1] = f"label={_quote(f'{kind} {weight}')}"
+            attrs += ["style=dashed", f"class={_quote(kind)}"]
+        if edge.get("cycle"):
+            attrs += ['color="red"', 'fontcolor="red"', 'class="cycle"']
+        lines.append(f"    {_quote(edge['from'])} -> {_quote(edge['to'])} [{', '.join(attrs)}];")
+    lines.append("}")
+    return "\n".join(lines) + "\n"
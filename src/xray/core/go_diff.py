@@ -0,0 +1,135 @@
+"""Symbol-level comparison of two versions of a Go file."""
+
+import re
+from typing import Dict, List, Optional, Any, Tuple
+
+from xray.core.go_parser import parse_go_source
+from xray.core.ranges import source_range
+from xray.core.source_text import source_lines
+
+_WORD = r"\b{}\b"
+
+
+def _qualified(symbol: Dict[str, Any]) -> str:
+    if symbol.get("receiver"):
+        return f"{symbol['receiver']['type']}.{symbol['name']}"
+    if symbol.get("container"):
+        return f"{symbol['container']}.{symbol['name']}"
+    return symbol["name"]
+
+
+def symbol_table(content: str) -> Dict[str, Dict[str, Any]]:
+    """Map qualified names to symbols with their source text and range for one file version."""
+    lines = source_lines(content)
+    table = {}
+    for symbol in parse_go_source(content)["symbols"]:
+        if symbol["type"] == "field":
+            continue
+        text = "\n".join(lines[symbol["start_line"] - 1:symbol["end_line"]])
+        table[_qualified(symbol)] = {**symbol, "text": text, "range": source_range(
+            lines, symbol["start_line"], end_line=symbol["end_line"], declaration=True)}
+    return table
+
+
+def _normalized(text: str) -> str:
+    return re.sub(r"\s+", " ", text).strip()
+
+
+def _entry(name: str, change: str, old: Optional[Dict[str, Any]], new: Optional[Dict[str, Any]]) -> Dict[str, Any]:
+    symbol = new or old
+    entry = {"name": name, "type": symbol["type"], "change": change}
+    if symbol.get("receiver"):
+        entry["receiver"] = symbol["receiver"]
+    if old is not None:
+        entry["old_signature"] = old["signature"]
+        entry["old_lines"] = [old["start_line"], old["end_line"]]
+        entry["old_range"] = old["range"]
+    if new is not None:
+        entry["new_signature"] = new["signature"]
+        entry["new_lines"] = [new["start_line"], new["end_line"]]
+        entry["new_range"] = new["range"]
+    return entry
+
+
+def diff_symbols(old_content: Optional[str], new_content: Optional[str]) -> List[Dict[str, Any]]:
+    """
+    Classify every symbol as added, removed, modified or signature_changed.
+
+    A removed and an added symbol of the same kind whose source is identical
+    once the name is swapped are reported as one "renamed" entry with low
+    confidence, since identical bodies can also be genuine duplicates.
+    """
+    return diff_tables(symbol_table(old_content) if old_content is not None else {},
+                       symbol_table(new_content) if new_content is not None else {})
+
+
+def diff_tables(old: Dict[str, Dict[str, Any]], new: Dict[str, Dict[str, Any]]) -> List[Dict[str, Any]]:
+    """diff_symbols of two versions already made into symbol tables."""
+    changes = []
+    for name in sorted(set(old) & set(new)):
+        before, after = old[name], new[name]
+        if _normalized(before["signature"]) != _normalized(after["signature"]):
+            changes.append(_entry(name, "signature_changed", before, after))
+        elif _normalized(before["text"]) != _normalized(after["text"]):
+            changes.append(_entry(name, "modified", before, after))
+
+    removed = {n: old[n] for n in sorted(set(old) - set(new))}
+    added = {n: new[n] for n in sorted(set(new) - set(old))}
+    renames: List[Tuple[str, str]] = []
+    for old_name, before in removed.items():
+        for new_name, after in added.items():
+            if before["type"] != after["type"] or any(new_name == r[1] for r in renames):
+                continue
+            swapped = re.sub(_WORD.format(re.escape(before["name"])), after["name"], before["text"])
+            if _normalized(swapped) == _normalized(after["text"]):
+                renames.append((old_name, new_name))
+                break
+    for old_name, new_name in renames:
+        entry = _entry(new_name, "renamed", removed.pop(old_name), added.pop(new_name))
+        entry["old_name"] = old_name
+        entry["confidence"] = "low"
+        changes.append(entry)
+
+    changes.extend(_entry(n, "removed", s, None) for n, s in removed.items())
+    changes.extend(_entry(n, "added", None, s) for n, s in added.items())
+    return changes
+
+
+def _overlapping(table: Dict[str, Dict[str, Any]], start: int, count: int) -> List[str]:
+    first, last = start, start + count - 1
+    return [name for name, symbol in table.items()
+            if symbol["start_line"] <= last and symbol["end_line"] >= first]
+
+
+def touched_symbols(old_content: Optional[str], new_content: Optional[str],
+                    hunks: List[Tuple[int, int, int, int]]) -> List[Dict[str, Any]]:
+    """
+    Map diff hunks onto the symbols they fall inside.
+
+    Old-side ranges are matched against the old version and new-side ranges
+    against the new one, so a symbol is reported as added, deleted, modified
+    or signature_changed according to which versions it exists in.
+    """
+    old = symbol_table(old_content) if old_content is not None else {}
+    new = symbol_table(new_content) if new_content is not None else {}
+
+    touched: Dict[str, None] = {}
+    for old_start, old_count, new_start, new_count in hunks:
+        if old_count:
+            touched.update(dict.fromkeys(_overlapping(old, old_start, old_count)))
+        if new_count:
+            touched.update(dict.fromkeys(_overlapping(new, new_start, new_count)))
+
+    changes = []
+    for name in touched:
+        before, after = old.get(name), new.get(name)
+        if before is None:
+            change = "added"
+        elif after is None:
+            change = "deleted"
+        elif before["signature"] != after["signature"]:
+            change = "signature_changed"
+        else:
+            change = "modified"
+        changes.append(_entry(name, change, before, after))
+    return changes
@@ -0,0 +1,414 @@
+"""Error-handling audit for Go code.
+
+Three findings, grouped by package and ordered by severity:
+
+- dropped errors: a call whose last result is an error, made as a statement
+  of its own (`f()`, `defer f.Close()`) or with that result assigned to `_`
+  (`_ = f()`, `v, _ := f()`). The callee is known from the project's
+  declarations or a list of standard library functions; other modules'
+  methods with a name that returns an error by convention (Close, Write,
+  Flush, ...) are reported with "confidence": "likely".
+- unwrapped returns: `return err` (or `return f()`) handing back an error
+  received from a call unchanged. The error is followed down the callees still
+  returning it unchanged to where it came from, so the report shows how many
+  frames it crossed without context. A library error returned raw is "high"
+  where it enters the project and "medium" in every frame above; an error
+  created in the project only counts once DEEP_FRAMES frames pass it on.
+- error definitions: the package's sentinel errors (`var ErrX =
+  errors.New(...)`) and types with an `Error() string` method, each with
+  where it is created (returned, wrapped, a composite literal of the type)
+  and where it is checked (errors.Is/As, ==, type assertions and switches).
+"""
+
+import os
+import re
+from typing import Any, Dict, List, Optional, Set, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+from xray.core.go_helpers import file_imports
+
+SEVERITIES = ("high", "medium", "low")
+
+# Frames an error created in the project passes unchanged before it is reported
+DEEP_FRAMES = 3
+
+# Standard library calls whose last result is an error: qualified name -> result count
+_ERROR_CALLS = {
+    **{f"os.{f}": 1 for f in ("Remove", "RemoveAll", "Mkdir", "MkdirAll", "Rename", "Chdir", "Chmod",
+                              "Chown", "Setenv", "Unsetenv", "WriteFile", "Symlink", "Truncate")},
+    **{f"os.File.{m}": 1 for m in ("Close", "Sync", "Chmod", "Truncate")},
+    **{f"os.File.{m}": 2 for m in ("Write", "WriteString", "WriteAt", "Seek")},
+    "io.Copy": 2, "io.CopyN": 2, "io.WriteString": 2, "io.ReadFull": 2,
+    "encoding/json.Unmarshal": 1, "encoding/json.Encoder.Encode": 1, "encoding/json.Decoder.Decode": 1,
+    "encoding/xml.Unmarshal": 1, "encoding/xml.Encoder.Encode": 1, "encoding/xml.Decoder.Decode": 1,
+    "encoding/gob.Encoder.Encode": 1, "encoding/gob.Decoder.Decode": 1,
+    **{f"database/sql.{t}.Close": 1 for t in ("DB", "Conn", "Rows", "Stmt")},
+    "database/sql.Rows.Scan": 1, "database/sql.Row.Scan": 1, "database/sql.Rows.Err": 1,
+    "database/sql.Tx.Commit": 1, "database/sql.Tx.Rollback": 1, "database/sql.DB.Ping": 1,
+    **{f"database/sql.{t}.Exec": 2 for t in ("DB", "Tx", "Conn", "Stmt")},
+    "net/http.ListenAndServe": 1, "net/http.ListenAndServeTLS": 1, "net/http.Serve": 1,
+    "net/http.Server.ListenAndServe": 1, "net/http.Server.ListenAndServeTLS": 1,
+    "net/http.Server.Serve": 1, "net/http.Server.Shutdown": 1, "net/http.Server.Close": 1,
+    "bufio.Writer.Flush": 1, "net.Conn.Close": 1, "net.Listener.Close": 1,
+    "os/exec.Cmd.Run": 1, "os/exec.Cmd.Start": 1, "os/exec.Cmd.Wait": 1,
+    "text/template.Template.Execute": 1, "html/template.Template.Execute": 1,
+    "text/template.Template.ExecuteTemplate": 1, "html/template.Template.ExecuteTemplate": 1,
+}
+
+# Methods that return an error by convention, on types of other modules: name -> result count
+_ERROR_METHODS = {
+    "Close": 1, "Flush": 1, "Sync": 1, "Shutdown": 1, "Commit": 1, "Rollback": 1,
+    "Encode": 1, "Decode": 1, "Scan": 1, "Write": 2, "WriteString": 2, "Exec": 2,
+}
+
+# Writers documented never to fail, left out like errcheck does
+_NEVER_FAILS = ("strings.Builder.", "bytes.Buffer.", "hash.Hash.", "fmt.")
+
+# Calls creating or wrapping an error: what they return is new, not passed on
+_ERROR_MAKERS = {"errors.New", "errors.Join", "fmt.Errorf"}
+_ERROR_PACKAGES = ("errors", "github.com/pkg/errors", "golang.org/x/xerrors")
+_WRAPPING_PACKAGES = ("github.com/pkg/errors.", "golang.org/x/xerrors.")
+
+# How a dropped error goes unhandled -> severity
+_DROP_SEVERITY = {"statement": "high", "blank": "medium", "defer": "low", "go": "low"}
+
+_TYPE_NAME = re.compile(r"[A-Za-z_]\w*(?:\.[A-Za-z_]\w*)?")
+
+
+def _function_name(func: Dict[str, Any]) -> str:
+    return f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+
+
+def _lower(severity: str) -> str:
+    return SEVERITIES[min(SEVERITIES.index(severity) + 1, len(SEVERITIES) - 1)]
+
+
+class ErrorAudit:
+    """Dropped errors, unwrapped returns and error definitions across a project."""
+
+    def __init__(self, graph: GoCallGraph):
+        self.graph = graph
+        self.project = graph.project
+        # call site -> resolved edge
+        self._sites: Dict[Tuple[str, int, int], Dict[str, Any]] = {}
+        for edge in graph.edges:
+            self._sites.setdefault((edge["path"], edge["line"], edge["column"]), edge)
+        # function key -> (path, function facts)
+        self._bodies: Dict[Tuple[str, str], Tuple[str, Dict[str, Any]]] = {}
+        for path, parsed in sorted(self.project.files.items()):
+            for func in parsed.get("functions", []):
+                self._bodies.setdefault((os.path.dirname(path), _function_name(func)), (path, func))
+        self._propagated: Dict[Tuple[str, str], Optional[Dict[str, Any]]] = {}
+        self._visiting: Set[Tuple[str, str]] = set()
+
+    # ------------------------------------------------------------------
+    # Callees
+    # ------------------------------------------------------------------
+
+    def _callee(self, path: str, func: Dict[str, Any], call: Dict[str, Any],
+                imports: Dict[str, str]) -> Dict[str, Any]:
+        """
+        What a call is: {"name", "project": key or None, "results": count
+        when its last result is an error, else None, "confidence"}.
+        """
+        chain = call["chain"]
+        edge = self._sites.get((path, call["line"], call["column"]))
+        text = ".".join(chain).replace(".()", "()").replace(".[]", "[]")
+        if edge is not None and not edge["external"]:
+            symbol = self.graph.functions.get(edge["callee"], {})
+            results = symbol.get("results") or []
+            errs = len(results) if results and results[-1]["type"] == "error" else None
+            return {"name": edge["callee"][1], "project": edge["callee"], "results": errs, "confidence": "certain"}
+        package_call = len(chain) == 2 and chain[0] in imports and chain[0] not in func.get("locals", {})
+        if edge is not None:
+            qualified = edge["callee"][1]
+        elif package_call:
+            qualified = f"{imports[chain[0]]}.{chain[1]}"
+        else:
+            qualified = None
+        name = qualified or text
+        if qualified is not None and qualified.startswith(_NEVER_FAILS):
+            return {"name": name, "project": None, "results": None, "confidence": "certain"}
+        if qualified in _ERROR_CALLS:
+            return {"name": name, "project": None, "results": _ERROR_CALLS[qualified], "confidence": "certain"}
+        if len(chain) > 1 and chain[-1] in _ERROR_METHODS and not package_call:
+            return {"name": name, "project": None, "results": _ERROR_METHODS[chain[-1]], "confidence": "likely"}
+        return {"name": name, "project": None, "results": None, "confidence": "certain"}
+
+    # ------------------------------------------------------------------
+    # Dropped errors
+    # ------------------------------------------------------------------
+
+    def _dropped(self, path: str, func: Dict[str, Any], imports: Dict[str, str]) -> List[Dict[str, Any]]:
+        findings = []
+        for call in func.get("calls", []):
+            if not call.get("statement") and "blank" not in call:
+                continue
+            callee = self._callee(path, func, call, imports)
+            results = callee["results"]
+            if results is None:
+                continue
+            if call.get("statement"):
+                how = call["kind"] if call["kind"] in ("go", "defer") else "statement"
+            elif results - 1 in call["blank"]:
+                how = "blank"
+            else:
+                continue
+            severity = _DROP_SEVERITY[how]
+            if callee["confidence"] == "likely":
+                severity = _lower(severity)
+            finding = {"kind": "dropped_error", "severity": severity, "function": _function_name(func),
+                       "path": path, "line": call["line"], "column": call["column"], "call": callee["name"],
+                       "how": how}
+            if callee["confidence"] == "likely":
+                finding["confidence"] = "likely"
+            findings.append(finding)
+        return findings
+
+    # ------------------------------------------------------------------
+    # Unwrapped returns
+    # ------------------------------------------------------------------
+
+    def _returned_call(self, func: Dict[str, Any], returned: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+        """The call an error return hands back: the call returned, or the last one assigning the name."""
+        calls = func.get("calls", [])
+        if "call" in returned:
+            return next((c for c in calls if c["line"] == returned["line"] and c["chain"] == returned["call"]), None)
+        name = returned.get("name")
+        if not name or name == "nil" or name not in func.get("locals", {}):
+            return None
+        before = [c for c in calls if name in c.get("assigned_to", []) and c["line"] <= returned["line"]]
+        return max(before, key=lambda c: (c["line"], c["column"])) if before else None
+
+    def _passed_on(self, path: str, func: Dict[str, Any], imports: Dict[str, str]) -> List[Dict[str, Any]]:
+        """Each return of the function handing back a callee's error unchanged, with the callee."""
+        passed = []
+        for returned in func.get("error_returns", []):
+            call = self._returned_call(func, returned)
+            if call is None:
+                continue
+            callee = self._callee(path, func, call, imports)
+            if callee["name"] in _ERROR_MAKERS or callee["name"].startswith(_WRAPPING_PACKAGES):
+                continue
+            passed.append({"return": returned, "call": call, "callee": callee})
+        return passed
+
+    def _propagation(self, key: Tuple[str, str]) -> Optional[Dict[str, Any]]:
+        """
+        The worst way a function passes an error on unchanged: {"chain":
+        functions from it down to the origin, "origin": the call the error
+        came from, "external"}; None when every error it returns is its own.
+        """
+        if key in self._propagated:
+            return self._propagated[key]
+        if key in self._visiting or key not in self._bodies:
+            return None
+        self._visiting.add(key)
+        path, func = self._bodies[key]
+        imports = file_imports(self.project.files[path])
+        best = None
+        for passed in self._passed_on(path, func, imports):
+            found = self._trace(key, path, passed)
+            if best is None or (found["external"], len(found["chain"])) > (best["external"], len(best["chain"])):
+                best = found
+        self._visiting.discard(key)
+        self._propagated[key] = best
+        return best
+
+    def _trace(self, key: Tuple[str, str], path: str, passed: Dict[str, Any]) -> Dict[str, Any]:
+        callee = passed["callee"]
+        deeper = self._propagation(callee["project"]) if callee["project"] else None
+        if deeper is not None:
+            return {**deeper, "chain": [key[1]] + deeper["chain"]}
+        return {"chain": [key[1]], "external": callee["project"] is None,
+                "origin": {"call": callee["name"], "path": path, "line": passed["call"]["line"]}}
+
+    def _unwrapped(self, path: str, func: Dict[str, Any], imports: Dict[str, str]) -> List[Dict[str, Any]]:
+        key = (os.path.dirname(path), _function_name(func))
+        findings = []
+        for passed in self._passed_on(path, func, imports):
+            found = self._trace(key, path, passed)
+            frames = len(found["chain"])
+            if found["external"]:
+                severity = "high" if frames == 1 else "medium"
+            elif frames >= DEEP_FRAMES:
+                severity = "low"
+            else:
+                continue
+            returned = passed["return"]
+            findings.append({"kind": "unwrapped_return", "severity": severity, "function": key[1], "path": path,
+                             "line": returned["line"], "column": returned["column"],
+                             "expression": returned["expression"], "from": passed["callee"]["name"],
+                             "frames": frames, "chain": found["chain"], "origin": found["origin"],
+                             "external": found["external"]})
+        return findings
+
+    # ------------------------------------------------------------------
+    # Error definitions
+    # ------------------------------------------------------------------
+
+    def _definitions(self) -> Dict[Tuple[str, str], Dict[str, Any]]:
+        """Sentinel errors and error types of the project by key."""
+        found: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        for path, parsed in sorted(self.project.files.items()):
+            pkg_dir = os.path.dirname(path)
+            imports = file_imports(parsed)
+            declared = {s["name"]: s for s in parsed["symbols"] if s["type"] == "variable"}
+            for name, source in parsed.get("package_vars", {}).items():
+                chain = (source or {}).get("chain") or []
+                if len(chain) != 3 or chain[0] not in imports or name not in declared:
+                    continue
+                if f"{imports[chain[0]]}.{chain[1]}" in _ERROR_MAKERS or imports[chain[0]] in _ERROR_PACKAGES:
+                    symbol = declared[name]
+                    found[(pkg_dir, name)] = {"kind": "sentinel", "name": name, "path": path,
+                                              "start_line": symbol["start_line"], "value": symbol.get("value", "")}
+        for (pkg_dir, name), symbol in sorted(self.project.types.items()):
+            if symbol["type"] == "interface":
+                continue
+            method = self.project.concrete_method_set(pkg_dir, name, True).get("Error")
+            if method and not method.get("params") and [r["type"] for r in method.get("results", [])] == ["string"]:
+                found[(pkg_dir, name)] = {"kind": "type", "name": name, "path": symbol["path"],
+                                          "start_line": symbol["start_line"]}
+        for definition in found.values():
+            definition.update({"created": [], "checked": []})
+        return found
+
+    def _definition_of(self, path: str, func: Dict[str, Any], chain: List[str], imports: Dict[str, str],
+                       definitions: Dict[Tuple[str, str], Dict[str, Any]]) -> Optional[Tuple[str, str]]:
+        """The definition a value reference (`ErrNotFound`, `store.ErrNotFound`) names, if any."""
+        if chain[0] in func.get("locals", {}):
+            return None
+        if len(chain) == 1:
+            key = (os.path.dirname(path), chain[0])
+        elif chain[0] in imports:
+            target = self.project.import_dir(imports[chain[0]], path)
+            key = (target, chain[1]) if target else None
+        else:
+            return None
+        return key if key in definitions else None
+
+    def _type_checks(self, path: str, func: Dict[str, Any],
+                     definitions: Dict[Tuple[str, str], Dict[str, Any]]) -> List[Tuple[Tuple[str, str], int, str]]:
+        """(definition, line, how) of every type assertion, type switch case and errors.As naming an error type."""
+        pkg_dir = os.path.dirname(path)
+        checks = []
+
+        def named(text: str) -> List[Tuple[str, str]]:
+            keys = {self.project.resolve_type_ref(pkg_dir, n) for n in _TYPE_NAME.findall(text)}
+            return [k for k in keys if k in definitions]
+
+        for assertion in func.get("assertions", []) + func.get("checked_assertions", []):
+            checks.extend((k, assertion["line"], "type_assertion") for k in named(assertion["asserted_type"]))
+        for switch in func.get("type_switches", []):
+            for case in switch["cases"]:
+                for case_type in case["types"]:
+                    checks.extend((k, case_type["line"], "type_switch") for k in named(case_type["type"]))
+        for call in func.get("calls", []):
+            if call["chain"] == ["errors", "As"] and len(call.get("args", [])) == 2:
+                target = self.graph.type_of(path, func, call["args"][1])
+                if target is not None and target[0] == "project" and (target[1], target[2]) in definitions:
+                    checks.append(((target[1], target[2]), call["line"], "errors.As"))
+        return checks
+
+    def _uses(self, include_tests: bool, definitions: Dict[Tuple[str, str], Dict[str, Any]]):
+        """Fill in where each definition is created and checked."""
+        for file_path, parsed in sorted(self.project.files.items()):
+            is_test = file_path.endswith("_test.go")
+            if is_test and not include_tests:
+                continue
+            imports = file_imports(parsed)
+            for func in parsed.get("functions", []):
+                site = {"function": _function_name(func), "path": file_path}
+                if is_test:
+                    site["in_test"] = True
+                checked: Set[Tuple[Tuple[str, str], int]] = set()
+                for key, line, how in self._type_checks(file_path, func, definitions):
+                    if (key, line) not in checked:
+                        checked.add((key, line))
+                        definitions[key]["checked"].append({**site, "line": line, "how": how})
+                is_calls = {c["line"]: ".".join(c["chain"]) for c in func.get("calls", [])
+                            if len(c["chain"]) == 2 and imports.get(c["chain"][0]) in _ERROR_PACKAGES
+                            and c["chain"][1] in ("Is", "As")}
+                conditions = func.get("conditions", [])
+                for ref in func.get("value_refs", []):
+                    key = self._definition_of(file_path, func, ref["chain"], imports, definitions)
+                    if key is None or (key, ref["line"]) in checked:
+                        continue
+                    definition = definitions[key]
+                    if ref["line"] in is_calls:
+                        how = is_calls[ref["line"]]
+                    elif any(c["line"] <= ref["line"] <= c["end_line"] and ref["chain"][0] in c["names"]
+                             for c in conditions):
+                        how = "comparison"
+                    elif definition["kind"] == "sentinel" or ref.get("composite"):
+                        definition["created"].append({**site, "line": ref["line"]})
+                        continue
+                    else:
+                        # A type named in a declaration or conversion, neither made nor checked
+                        continue
+                    checked.add((key, ref["line"]))
+                    definition["checked"].append({**site, "line": ref["line"], "how": how})
+
+    # ------------------------------------------------------------------
+    # Report
+    # ------------------------------------------------------------------
+
+    def audit(self, include_tests: bool = False, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Error-handling findings and definitions grouped by package directory.
+
+        Args:
+            include_tests: Also scan _test.go files (entries get "in_test")
+            path: Only findings and definitions in this file or package directory (and below)
+        """
+        def selected(file_path: str) -> bool:
+            return not path or file_path == path or file_path.startswith(path.rstrip(os.sep) + os.sep)
+
+        packages: Dict[str, Dict[str, Any]] = {}
+
+        def group_of(file_path: str) -> Dict[str, Any]:
+            pkg_dir = os.path.dirname(file_path)
+            return packages.setdefault(pkg_dir, {
+                "package": self.project.files[file_path].get("package", ""),
+                "directory": pkg_dir,
+                "findings": [],
+                "error_definitions": [],
+            })
+
+        for file_path, parsed in sorted(self.project.files.items()):
+            is_test = file_path.endswith("_test.go")
+            if (is_test and not include_tests) or not selected(file_path):
+                continue
+            imports = file_imports(parsed)
+            for func in parsed.get("functions", []):
+                for finding in self._dropped(file_path, func, imports) + self._unwrapped(file_path, func, imports):
+                    if is_test:
+                        finding["in_test"] = True
+                    group_of(file_path)["findings"].append(finding)
+
+        definitions = self._definitions()
+        self._uses(include_tests, definitions)
+        for definition in definitions.values():
+            if selected(definition["path"]):
+                group_of(definition["path"])["error_definitions"].append(definition)
+
+        groups = []
+        for pkg_dir in sorted(packages):
+            group = packages[pkg_dir]
+            group["findings"].sort(key=lambda f: (SEVERITIES.index(f["severity"]), f["path"], f["line"], f["column"]))
+            group["error_definitions"].sort(key=lambda d: (d["path"], d["start_line"]))
+            group["counts"] = {severity: sum(1 for f in group["findings"] if f["severity"] == severity)
+                               for severity in SEVERITIES}
+            groups.append(group)
+        # Packages with the most severe findings first
+        groups.sort(key=lambda g: tuple(-g["counts"][s] for s in SEVERITIES))
+        return {
+            "packages": groups,
+            "total_count": sum(len(g["findings"]) for g in groups),
+            "counts": {
+                **{severity: sum(g["counts"][severity] for g in groups) for severity in SEVERITIES},
+                "dropped_error": sum(1 for g in groups for f in g["findings"] if f["kind"] == "dropped_error"),
+                "unwrapped_return": sum(1 for g in groups for f in g["findings"] if f["kind"] == "unwrapped_return"),
+            },
+            "include_tests": include_tests,
+        }
@@ -0,0 +1,152 @@
+"""Places where a Go program can die.
+
+Every call to panic() (with its argument), recover() (with the deferred
+function it sits in), os.Exit and friends, log.Fatal*/log.Panic* and the
+Fatal methods of other loggers, and every type assertion without the
+comma-ok form. Calls are recognized through the file's imports and the call
+graph, so a local function named Exit or a testing.T's Fatal is not one.
+"""
+
+import os
+from typing import Any, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+from xray.core.go_helpers import file_imports
+
+FAILURE_KINDS = ("panic", "fatal", "exit", "recover", "type_assertion")
+
+# (import path, function) -> kind
+_PACKAGE_CALLS = {
+    ("os", "Exit"): "exit",
+    ("syscall", "Exit"): "exit",
+    ("runtime", "Goexit"): "exit",
+    ("log", "Fatal"): "fatal",
+    ("log", "Fatalf"): "fatal",
+    ("log", "Fatalln"): "fatal",
+    ("log", "Panic"): "panic",
+    ("log", "Panicf"): "panic",
+    ("log", "Panicln"): "panic",
+}
+
+# Methods of this name on loggers (log.Logger, logrus, zap) end the process
+_FATAL_METHODS = {"Fatal", "Fatalf", "Fatalln", "Fatalw"}
+
+# Fatal on these fails a test or benchmark rather than the process
+_TEST_TYPES = ("testing.T.", "testing.B.", "testing.F.", "testing.TB.", "testing.common.")
+
+
+class FailurePointFinder:
+    """Collects the failure points of every function in a project."""
+
+    def __init__(self, graph: GoCallGraph):
+        self.graph = graph
+        self.project = graph.project
+        # call site -> resolved edge
+        self._sites: Dict[Tuple[str, int, int], Dict[str, Any]] = {}
+        # functions run by a `defer f()` somewhere
+        self._deferred: Dict[Tuple[str, str], List[str]] = {}
+        for edge in graph.edges:
+            self._sites.setdefault((edge["path"], edge["line"], edge["column"]), edge)
+            if edge["kind"] == "defer" and not edge["external"]:
+                self._deferred.setdefault(edge["callee"], []).append(edge["caller"][1])
+
+    def _call_kind(self, path: str, func: Dict[str, Any], call: Dict[str, Any],
+                   imports: Dict[str, str]) -> Optional[Tuple[str, str]]:
+        """(kind, what is called) for a failure call site, or None."""
+        chain = call["chain"]
+        locals_ = func.get("locals", {})
+        if len(chain) == 1 and chain[0] in ("panic", "recover") and chain[0] not in locals_:
+            return chain[0], chain[0]
+        if len(chain) == 2 and chain[0] in imports and chain[0] not in locals_:
+            kind = _PACKAGE_CALLS.get((imports[chain[0]], chain[1]))
+            if kind:
+                return kind, f"{imports[chain[0]]}.{chain[1]}"
+            if chain[1] in _FATAL_METHODS and self.project.import_dir(imports[chain[0]], path) is None:
+                # Package-level Fatal of a logging library (logrus, klog, glog)
+                return "fatal", f"{imports[chain[0]]}.{chain[1]}"
+            return None
+        if chain[-1] not in _FATAL_METHODS:
+            return None
+        edge = self._sites.get((path, call["line"], call["column"]))
+        if edge is None or not edge["external"]:
+            return None
+        qualified = edge["callee"][1]
+        if qualified.startswith(_TEST_TYPES):
+            return None
+        return "fatal", qualified
+
+    def _function_points(self, path: str, func: Dict[str, Any], imports: Dict[str, str]) -> List[Dict[str, Any]]:
+        pkg_dir = os.path.dirname(path)
+        name = f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+        points = []
+        for call in func.get("calls", []):
+            found = self._call_kind(path, func, call, imports)
+            if found is None:
+                continue
+            kind, called = found
+            entry: Dict[str, Any] = {"kind": kind, "function": name, "path": path,
+                                     "line": call["line"], "column": call["column"], "call": called}
+            if kind in ("panic", "fatal", "exit") and call.get("arg_texts"):
+                entry["argument"] = ", ".join(call["arg_texts"])
+            if kind == "recover":
+                defer = next((d for d in func.get("deferred", []) if d["line"] <= call["line"] <= d["end_line"]), None)
+                if defer is not None:
+                    entry["defer_line"] = defer["line"]
+                elif (pkg_dir, name) in self._deferred:
+                    entry["deferred_by"] = sorted(set(self._deferred[(pkg_dir, name)]))
+                else:
+                    # recover() returns nil unless called directly by a deferred function
+                    entry["effective"] = False
+            points.append(entry)
+        for assertion in func.get("assertions", []):
+            points.append({"kind": "type_assertion", "function": name, "path": path, "line": assertion["line"],
+                           "column": assertion["column"], "expression": assertion["expression"],
+                           "asserted_type": assertion["asserted_type"]})
+        return points
+
+    def find(self, include_tests: bool = False, path: Optional[str] = None,
+             kinds: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        Failure points grouped by package directory.
+
+        Args:
+            include_tests: Also scan _test.go files (entries get "in_test")
+            path: Only this file or package directory (and below)
+            kinds: Only these kinds (see FAILURE_KINDS)
+        """
+        wanted = set(kinds or FAILURE_KINDS)
+        packages: Dict[str, Dict[str, Any]] = {}
+        for file_path, parsed in sorted(self.project.files.items()):
+            is_test = file_path.endswith("_test.go")
+            if is_test and not include_tests:
+                continue
+            if path and file_path != path and not file_path.startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            imports = file_imports(parsed)
+            pkg_dir = os.path.dirname(file_path)
+            for func in parsed.get("functions", []):
+                for point in self._function_points(file_path, func, imports):
+                    if point["kind"] not in wanted:
+                        continue
+                    if is_test:
+                        point["in_test"] = True
+                    group = packages.setdefault(pkg_dir, {
+                        "package": parsed.get("package", ""),
+                        "directory": pkg_dir,
+                        "failure_points": [],
+                    })
+                    group["failure_points"].append(point)
+
+        groups = []
+        for pkg_dir in sorted(packages):
+            group = packages[pkg_dir]
+            group["failure_points"].sort(key=lambda p: (p["path"], p["line"], p["column"] or 0))
+            group["counts"] = {kind: sum(1 for p in group["failure_points"] if p["kind"] == kind)
+                               for kind in FAILURE_KINDS if kind in wanted}
+            groups.append(group)
+        return {
+            "packages": groups,
+            "total_count": sum(len(g["failure_points"]) for g in groups),
+            "counts": {kind: sum(g["counts"].get(kind, 0) for g in groups) for kind in FAILURE_KINDS if kind in wanted},
+            "include_tests": include_tests,
+        }
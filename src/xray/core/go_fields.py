@@ -0,0 +1,456 @@
+"""Struct field usages - whether a Go field is read, written, or only exposed.
+
+Before deleting a field you want every place it is used, and selectors are
+only part of it. FieldUsageFinder reports, for one `Type.Field`:
+
+    selectors       `u.Email` read, or written - assigned, compound-assigned,
+                    incremented, through an index or a field of it, or its
+                    address taken (`rows.Scan(&u.Email)`: a scan destination)
+    struct literals `User{Email: e}` keyed, and `User{1, "n", e}` positional
+    encoders        values of the type (or embedding it, or slices and maps
+                    of it) passed to json/xml/yaml/... Marshal and Encode -
+                    a read under the field's tag - or Unmarshal and Decode,
+                    and to sqlx-style row scans filling fields by db tag
+    reflection      the field's name as a string literal given to FieldByName,
+                    or in a function calling into reflect
+
+Selectors resolve through the call graph's types, the way rename previews
+do (core/go_rename.py); ones whose receiver cannot be typed are listed as
+"unresolved". A field that is written but never read is called out: its
+writes are probably dead, though reflection and encoders outside the
+project can still read it.
+"""
+
+import os
+from typing import Any, Callable, Dict, Iterable, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+from xray.core.go_helpers import ident_at, token_is
+from xray.core.go_parser import Token, tokenize, unquote
+from xray.core.go_rename import BY_NAME, FileScan, RenamePlanner, literal_type
+
+# Import path segment -> the struct tag its encoder reads
+_CODECS = (
+    ("json", "json"), ("xml", "xml"), ("yaml", "yaml"), ("toml", "toml"), ("bson", "bson"),
+    ("msgpack", "msgpack"), ("mapstructure", "mapstructure"), ("sqlx", "db"), ("scany", "db"),
+    ("sqlscan", "db"), ("dbr", "db"),
+)
+_ENCODE = {"Marshal", "MarshalIndent", "MarshalToString", "Encode"}
+_DECODE = {"Unmarshal", "UnmarshalFromString", "Decode"}
+# Row scans filling a struct by its db tags
+_ROW_SCANS = {"Get", "Select", "StructScan", "ScanStruct", "ScanStructs", "ScanOne", "ScanAll"}
+
+_COMPOUND = {"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<=", ">>=", "&^="}
+_OPENING = {")": "(", "]": "[", "}": "{"}
+
+
+def _codec_tag(import_path: str) -> Optional[str]:
+    segments = import_path.lower().split("/")
+    for segment, tag in _CODECS:
+        if any(segment in s for s in segments[-2:]):
+            return tag
+    return None
+
+
+def _import_of(qualified: str) -> str:
+    """The import path of an external name ("github.com/x/sqlx.DB.Get" -> "github.com/x/sqlx")."""
+    prefix, _, last = qualified.rpartition("/")
+    return (prefix + "/" if prefix else "") + last.split(".", 1)[0]
+
+
+def _origin(chain: List[str], func: Dict[str, Any], imports: Dict[str, str], depth: int = 0) -> Optional[str]:
+    """The import a chain starts from, through locals (`dec := json.NewDecoder(r); dec.Decode`)."""
+    head = chain[0]
+    locals_ = func.get("locals", {})
+    if head in locals_ and depth < 8:
+        source = locals_[head] or {}
+        inner = source.get("chain") or (source.get("tuple") or {}).get("chain")
+        return _origin(inner, func, imports, depth + 1) if inner and inner[0] != head else None
+    return imports.get(head) if len(chain) > 1 else None
+
+
+def codec_call(graph: GoCallGraph, path: str, func: Dict[str, Any], call: Dict[str, Any],
+               imports: Dict[str, str]) -> Optional[Tuple[str, str, str]]:
+    """
+    (access, tag key, qualified name) of a call to an encoder, decoder or
+    row scan - access "read" for Marshal and Encode, "write" otherwise - or None.
+    """
+    chain = call["chain"]
+    method = chain[-1]
+    if method not in _ENCODE and method not in _DECODE and method not in _ROW_SCANS:
+        return None
+    import_path = _origin(chain, func, imports)
+    qualified = None
+    if import_path is not None:
+        # `json.NewEncoder(w).Encode`, or `dec.Decode` through the local it was made in
+        called = chain[1:] if chain[0] in imports and chain[0] not in func.get("locals", {}) else [method]
+        qualified = ".".join([import_path] + [e for e in called if e != "()"])
+    else:
+        value = graph.evaluate(path, func, chain)
+        if value is not None and value[0] == "func" and value[1] == "external":
+            import_path, qualified = _import_of(value[2]), value[2]
+    tag = _codec_tag(import_path) if import_path else None
+    if tag is None or (tag == "db") != (method in _ROW_SCANS):
+        return None
+    return ("read" if method in _ENCODE else "write"), tag, qualified
+
+
+def _back_to_opening(tokens: List[Token], close: int) -> Optional[int]:
+    depth = 0
+    for k in range(close, -1, -1):
+        tok = tokens[k]
+        if tok.kind != "op":
+            continue
+        if tok.value in _OPENING:
+            depth += 1
+        elif tok.value in ("(", "[", "{"):
+            depth -= 1
+            if depth == 0:
+                return k
+    return None
+
+
+def _enclosing_opening(tokens: List[Token], index: int) -> Optional[int]:
+    """The unmatched bracket before tokens[index] - the "(" of the call an argument is passed to."""
+    depth = 0
+    for k in range(index - 1, -1, -1):
+        tok = tokens[k]
+        if tok.kind != "op":
+            continue
+        if tok.value in _OPENING:
+            depth += 1
+        elif tok.value in ("(", "[", "{"):
+            if depth == 0:
+                return k
+            depth -= 1
+    return None
+
+
+def _forward_to_closing(tokens: List[Token], opening: int) -> int:
+    depth = 0
+    for k in range(opening, len(tokens)):
+        tok = tokens[k]
+        if tok.kind != "op":
+            continue
+        if tok.value in ("(", "[", "{"):
+            depth += 1
+        elif tok.value in _OPENING:
+            depth -= 1
+            if depth == 0:
+                return k
+    return len(tokens) - 1
+
+
+def _chain_head(tokens: List[Token], index: int) -> int:
+    """The first token of the selector/call/index chain ending at tokens[index]."""
+    k = index
+    while token_is(tokens, k - 1, "."):
+        k -= 2
+        if token_is(tokens, k, ")") or token_is(tokens, k, "]"):
+            opened = _back_to_opening(tokens, k)
+            if opened is None:
+                return k + 2
+            k = opened - 1
+        if ident_at(tokens, k) is None:
+            return k + 2
+    return k
+
+
+def _assignment_after(tokens: List[Token], k: int) -> Optional[str]:
+    """For `a.X, b[i] = ...` with tokens[k] a comma: the operator ending the list, if it is one."""
+    depth = 0
+    while k < len(tokens):
+        tok = tokens[k]
+        if tok.kind == "op":
+            if tok.value in ("(", "[", "{"):
+                depth += 1
+            elif tok.value in _OPENING:
+                if depth == 0:
+                    return None
+                depth -= 1
+            elif depth == 0 and tok.value in ("=", ":="):
+                return tok.value
+            elif tok.value == ";" or depth == 0 and tok.value not in (",", "."):
+                return None
+        elif tok.kind != "ident" and depth == 0:
+            return None
+        k += 1
+    return None
+
+
+class FieldUsageFinder:
+    """Finds the usages of struct fields across a call graph's project."""
+
+    def __init__(self, graph: GoCallGraph, read: Callable[[str], Optional[str]],
+                 generated: Callable[[str], bool], extra_files: Iterable[Tuple[str, Dict[str, Any]]] = ()):
+        """
+        Args:
+            graph: Call graph of the project, for resolving selectors
+            read: Returns the content of a file path, None if unreadable
+            generated: Whether a file path is generated code
+            extra_files: (path, parsed) of Go files outside the index, searched too
+        """
+        self.graph = graph
+        self.project = graph.project
+        self.planner = RenamePlanner(graph, read, generated, extra_files)
+        self._read = read
+        self._generated = generated
+
+    def find_fields(self, symbol: str, path: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Struct fields named "Type.Field", optionally inside one file or package directory."""
+        if "." not in symbol:
+            return []
+        return [m for m in self.planner.find(symbol, path) if m["type"] == "field"]
+
+    def _is_target(self, field: Optional[Dict[str, Any]], target: Dict[str, Any]) -> bool:
+        return field is not None and field.get("path") == target["path"] and \
+            field.get("start_line") == target["start_line"] and field.get("name") == target["name"]
+
+    # ------------------------------------------------------------------
+    # Usages
+    # ------------------------------------------------------------------
+
+    def usages(self, target: Dict[str, Any]) -> Dict[str, Any]:
+        """
+        Every usage of a field found by find_fields().
+
+        Returns:
+            {"symbol", "accesses": [{path, line, column, function, access,
+             kind, ...}], "reads", "writes", "written_from", "read_from",
+             "unresolved"} plus "written_only" or "unused" and a "message"
+            when nothing reads the field
+        """
+        name = target["name"]
+        owner = target["container"]
+        pkg_dir = os.path.dirname(target["path"])
+        accesses: List[Dict[str, Any]] = []
+        unresolved: List[Dict[str, Any]] = []
+
+        files = list(self.project.files.items()) + sorted(self.planner.extra.items())
+        for file_path, parsed in files:
+            if not name[:1].isupper() and os.path.dirname(file_path) != pkg_dir:
+                continue
+            content = self._read(file_path)
+            if content is None or (name not in content and owner not in content):
+                continue
+            tokens, _ = tokenize(content)
+            scan = FileScan(self.planner, file_path, parsed, tokens)
+            reflect = {imp["name"] for imp in parsed.get("imports", []) if imp["path"] == "reflect"}
+            for index, tok in enumerate(tokens):
+                found = None
+                if tok.kind == "string":
+                    if unquote(tok.value) == name:
+                        found = self._reflection(tokens, index, scan, reflect)
+                elif tok.kind == "ident" and tok.value == name and not scan.declared(tok):
+                    found = self._occurrence(tokens, index, scan, target, pkg_dir, owner, content, unresolved)
+                elif tok.kind == "ident" and tok.value == owner and token_is(tokens, index + 1, "{"):
+                    found = self._positional(tokens, index + 1, scan, target, pkg_dir, owner)
+                if found is None:
+                    continue
+                at = found.pop("at")
+                func = scan.enclosing(at.line)
+                accesses.append({
+                    "path": file_path,
+                    "line": at.line,
+                    "column": at.col,
+                    "function": scan.function_name(func) if func else None,
+                    **found,
+                })
+        accesses.extend(self._codecs(target))
+
+        for usage in accesses + unresolved:
+            if self._generated(usage["path"]):
+                usage["generated"] = True
+        accesses.sort(key=lambda u: (u["path"], u["line"], u["column"]))
+
+        reads = [u for u in accesses if u["access"] == "read"]
+        writes = [u for u in accesses if u["access"] == "write"]
+        result: Dict[str, Any] = {
+            "symbol": {
+                "name": name,
+                "qualified_name": f"{owner}.{name}",
+                "package": target["package"],
+                "path": target["path"],
+                "start_line": target["start_line"],
+                "signature": target["signature"],
+            },
+            "accesses": accesses,
+            "reads": len(reads),
+            "writes": len(writes),
+            "written_from": sorted({u["function"] for u in writes if u["function"]}),
+            "read_from": sorted({u["function"] for u in reads if u["function"]}),
+            "unresolved": unresolved,
+        }
+        if target.get("tags"):
+            result["symbol"]["tags"] = target["tags"]
+        if writes and not reads:
+            result["written_only"] = True
+            result["message"] = (f"{owner}.{name} is written {len(writes)} time(s) but never read in the project; "
+                                 f"the writes are likely dead unless something outside it reads the field")
+        elif not accesses:
+            result["unused"] = True
+            result["message"] = f"No usages of {owner}.{name} found" + \
+                                (f"; check the {len(unresolved)} unresolved selector(s)" if unresolved else "")
+        return result
+
+    def _occurrence(self, tokens: List[Token], index: int, scan: FileScan, target: Dict[str, Any],
+                    pkg_dir: str, owner: str, content: str,
+                    unresolved: List[Dict[str, Any]]) -> Optional[Dict[str, Any]]:
+        """A selector or literal key naming the field, as a usage; unresolved ones go to `unresolved`."""
+        tok = tokens[index]
+        kind = scan.classify(index, target, pkg_dir, owner)
+        if kind == "unresolved" and token_is(tokens, index - 1, ".") and scan.indexed:
+            # Fields in the middle of a chain (`u.Address.City`) have no recorded chain of their own
+            head = _chain_head(tokens, index)
+            func = scan.enclosing(tok.line)
+            chain = [t.value for t in tokens[head:index + 1:2]]
+            if func is not None and all(token_is(tokens, k, ".") for k in range(head + 1, index, 2)):
+                member = self.graph.member_of(scan.path, func, chain)
+                kind = "selector" if self._is_target(member, target) else (None if member else kind)
+        if kind == "selector":
+            return {"at": tok, **self._access(tokens, index, target)}
+        if kind == "composite_key":
+            return {"at": tok, "access": "write", "kind": "struct_literal", "keyed": True}
+        if kind == "unresolved":
+            unresolved.append({"path": scan.path, "line": tok.line, "column": tok.col,
+                               "text": scan.line_text(content, tok.line)})
+        return None
+
+    def _access(self, tokens: List[Token], index: int, target: Dict[str, Any]) -> Dict[str, Any]:
+        """Whether the selector ending at tokens[index] reads or writes the field, and how."""
+        j = index + 1
+        has_index = has_field = has_call = False
+        while j < len(tokens):
+            if token_is(tokens, j, ".") and ident_at(tokens, j + 1):
+                has_field = True
+                j += 2
+            elif token_is(tokens, j, "["):
+                has_index = True
+                j = _forward_to_closing(tokens, j) + 1
+            elif token_is(tokens, j, "("):
+                has_call = True
+                j = _forward_to_closing(tokens, j) + 1
+            else:
+                break
+        head = _chain_head(tokens, index)
+        after = tokens[j].value if j < len(tokens) and tokens[j].kind == "op" else None
+        if after == ",":
+            after = _assignment_after(tokens, j)
+
+        if has_call:
+            return {"access": "read", "kind": "call" if not has_field and not has_index else "read"}
+        if token_is(tokens, head - 1, "&") and not has_field and not has_index:
+            usage = {"access": "write", "kind": "address_of"}
+            opened = _enclosing_opening(tokens, head - 1)
+            call = ident_at(tokens, opened - 1) if opened is not None and token_is(tokens, opened, "(") else None
+            if call is not None:
+                usage["call"] = call
+                if call.startswith("Scan"):
+                    usage["kind"] = "scan_destination"
+                    tag = (target.get("tags") or {}).get("db")
+                    if tag and tag.get("name"):
+                        usage["key"] = tag["name"]
+            return usage
+        if after == "=":
+            kind = "index_assign" if has_index else ("field_assign" if has_field else "assign")
+            return {"access": "write", "kind": kind}
+        if after in _COMPOUND:
+            return {"access": "write", "kind": "compound_assign"}
+        if after in ("++", "--"):
+            return {"access": "write", "kind": "increment"}
+        return {"access": "read", "kind": "read"}
+
+    def _positional(self, tokens: List[Token], brace: int, scan: FileScan, target: Dict[str, Any],
+                    pkg_dir: str, owner: str) -> Optional[Dict[str, Any]]:
+        """The element of a positional `Owner{a, b, c}` literal that sets the field."""
+        k = brace - 2
+        if token_is(tokens, k, ".") and ident_at(tokens, k - 1):
+            k -= 2
+        if token_is(tokens, k, "*") or token_is(tokens, k, ")"):
+            # A result type before a function body: `func New() *Owner {`
+            return None
+        lit = literal_type(tokens, brace)
+        if lit is None or lit[0] != "named" or lit[1] is None or lit[1][1] != owner or \
+                scan.package_dir(lit[1][0]) != pkg_dir:
+            return None
+        close = _forward_to_closing(tokens, brace)
+        elements, start, depth = [], brace + 1, 0
+        for k in range(brace + 1, close):
+            tok = tokens[k]
+            if tok.kind != "op":
+                continue
+            if tok.value in ("(", "[", "{"):
+                depth += 1
+            elif tok.value in _OPENING:
+                depth -= 1
+            elif depth == 0 and tok.value == ":":
+                # Keyed; the keys are found as occurrences of the name
+                return None
+            elif depth == 0 and tok.value == ",":
+                elements.append(start)
+                start = k + 1
+        if start < close:
+            elements.append(start)
+        fields = self.project.fields.get((pkg_dir, owner), [])
+        position = next((i for i, f in enumerate(fields) if self._is_target(f, target)), None)
+        if position is None or position >= len(elements):
+            return None
+        return {"at": tokens[elements[position]], "access": "write", "kind": "struct_literal",
+                "positional": True, "position": position}
+
+    def _reflection(self, tokens: List[Token], index: int, scan: FileScan,
+                    reflect: set) -> Optional[Dict[str, Any]]:
+        """The field's name as a string given to FieldByName, or in a function that uses reflect."""
+        tok = tokens[index]
+        if token_is(tokens, index - 1, "(") and ident_at(tokens, index - 2) in BY_NAME:
+            return {"at": tok, "access": "unknown", "kind": "reflection", "call": tokens[index - 2].value}
+        func = scan.enclosing(tok.line)
+        if func is None or not reflect:
+            return None
+        if any(call["chain"][0] in reflect or call["chain"][-1] in BY_NAME for call in func.get("calls", [])):
+            return {"at": tok, "access": "unknown", "kind": "reflection", "near_reflect": True}
+        return None
+
+    # ------------------------------------------------------------------
+    # Encoders
+    # ------------------------------------------------------------------
+
+    def _codecs(self, target: Dict[str, Any]) -> List[Dict[str, Any]]:
+        """Calls handing a value holding the field to an encoder, decoder or row scan."""
+        name = target["name"]
+        if not name[:1].isupper():
+            # Reflection-based encoders only see exported fields
+            return []
+        accesses = []
+        for path, parsed in sorted(self.project.files.items()):
+            imports = {imp["name"]: imp["path"] for imp in parsed.get("imports", []) if imp["name"]}
+            for func in parsed.get("functions", []):
+                for call in func.get("calls", []):
+                    codec = codec_call(self.graph, path, func, call, imports)
+                    if codec is None:
+                        continue
+                    access, tag_key, qualified = codec
+                    tag = (target.get("tags") or {}).get(tag_key) or {}
+                    if tag.get("skip"):
+                        continue
+                    for source in call.get("args", []):
+                        named = self.graph.type_of(path, func, source, elements=True)
+                        if named is None or named[0] != "project":
+                            continue
+                        fields = self.project.member_set(named[1], named[2], pointer=True)["fields"]
+                        if not self._is_target(fields.get(name), target):
+                            continue
+                        accesses.append({
+                            "path": path,
+                            "line": call["line"],
+                            "column": call["column"],
+                            "function": f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"],
+                            "access": access,
+                            "kind": "encoded" if access == "read" else "decoded",
+                            "call": qualified,
+                            "format": tag_key,
+                            "key": tag.get("name") or (name.lower() if tag_key == "db" else name),
+                            "via": named[2],
+                        })
+                        break
+        return accesses
@@ -0,0 +1,255 @@
+"""printf-style format strings in Go code, and the calls whose arguments do not fit them.
+
+FormatChecker finds the calls of the fmt printf family (Printf, Sprintf,
+Fprintf, Errorf, Appendf and the Scanf functions) and of the ...f functions
+and methods of the log and testing packages, from the call graph. When the
+format is a constant - a literal, a constant, a local holding one - its
+verbs are counted the way fmt reads them: one argument per verb, one more
+per `*` width or precision, none for %%, and an explicit index (%[2]d)
+moves to that argument. A call passing fewer arguments than that is a
+mismatch, and so is one passing more unless an index reorders them - what
+go vet's printf check reports.
+
+    ok            the arguments fit the verbs
+    missing_args  a verb has no argument left
+    extra_args    arguments no verb reads
+    bad_format    a verb is cut off (`%` at the end) or an index is not a
+                  positive number
+    dynamic       the format is built at runtime: not checked
+    spread        the arguments are spread from a slice (`args...`): not
+                  checked
+
+Every call keeps its format string, and the formats are also listed per
+enclosing function ("symbols"), for finding where a message comes from.
+"""
+
+import os
+from typing import Any, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+
+# Qualified name -> index of the format argument
+PRINTF_FUNCS = {
+    "fmt.Printf": 0, "fmt.Sprintf": 0, "fmt.Errorf": 0, "fmt.Fprintf": 1, "fmt.Appendf": 1,
+    "fmt.Scanf": 0, "fmt.Sscanf": 1, "fmt.Fscanf": 1,
+    "log.Printf": 0, "log.Fatalf": 0, "log.Panicf": 0,
+    "log.Logger.Printf": 0, "log.Logger.Fatalf": 0, "log.Logger.Panicf": 0,
+}
+PRINTF_FUNCS.update({f"testing.{t}.{m}": 0 for t in ("T", "B", "F", "TB", "common")
+                     for m in ("Errorf", "Fatalf", "Logf", "Skipf")})
+
+STATUSES = ("ok", "missing_args", "extra_args", "bad_format", "dynamic", "spread")
+MISMATCHES = ("missing_args", "extra_args", "bad_format")
+
+_FLAGS = "#0+- "
+
+
+def _index(text: str, i: int) -> Tuple[Optional[int], int, bool]:
+    """An explicit argument index at i: (argument number or None, position after it, whether one was there)."""
+    if i >= len(text) or text[i] != "[":
+        return None, i, False
+    close = text.find("]", i)
+    if close < 0:
+        return -1, len(text), True
+    digits = text[i + 1:close]
+    return (int(digits) - 1 if digits.isdigit() and int(digits) > 0 else -1), close + 1, True
+
+
+def parse_format(text: str) -> Dict[str, Any]:
+    """
+    The directives of a format string and how many arguments they read:
+    {"verbs": [...], "args": n, "reordered": bool, "errors": [...]}.
+    """
+    verbs: List[str] = []
+    errors: List[str] = []
+    arg = needed = 0
+    reordered = False
+    i, end = 0, len(text)
+    while i < end:
+        if text[i] != "%":
+            i += 1
+            continue
+        start = i
+        i += 1
+        while i < end and text[i] in _FLAGS:
+            i += 1
+        number, i, indexed = _index(text, i)
+        for part in ("width", "precision"):
+            if part == "precision":
+                if i >= end or text[i] != ".":
+                    break
+                i += 1
+                number, i, indexed = _index(text, i)
+            if number is not None:
+                if number < 0:
+                    errors.append(f"bad argument index in {text[start:i]}")
+                else:
+                    arg, reordered = number, True
+            if i < end and text[i] == "*":
+                i += 1
+                arg += 1
+                needed = max(needed, arg)
+                number, indexed = None, False
+            else:
+                while i < end and text[i].isdigit():
+                    i += 1
+        if not indexed:
+            number, i, indexed = _index(text, i)
+            if number is not None:
+                if number < 0:
+                    errors.append(f"bad argument index in {text[start:i]}")
+                else:
+                    arg, reordered = number, True
+        if i >= end:
+            errors.append(f"missing verb at end of {text[start:]!r}")
+            break
+        verb = text[i]
+        i += 1
+        if verb == "%":
+            continue
+        verbs.append(text[start:i])
+        arg += 1
+        needed = max(needed, arg)
+    return {"verbs": verbs, "args": needed, "reordered": reordered, "errors": errors}
+
+
+def check_call(format_text: str, given: int, spread: bool) -> Tuple[str, Dict[str, Any], Optional[str]]:
+    """The status of a call with a constant format, its parse, and a message when it does not fit."""
+    parsed = parse_format(format_text)
+    needed = parsed["args"]
+    if parsed["errors"]:
+        return "bad_format", parsed, "; ".join(parsed["errors"])
+    if spread:
+        return "spread", parsed, None
+    if given < needed:
+        return "missing_args", parsed, f"format reads {needed} argument{'s' if needed != 1 else ''} " \
+                                       f"but the call passes {given}"
+    if given > needed and not parsed["reordered"]:
+        return "extra_args", parsed, f"format reads {needed} argument{'s' if needed != 1 else ''} " \
+                                     f"but the call passes {given}"
+    return "ok", parsed, None
+
+
+class FormatChecker:
+    """The printf-style calls of a call graph's project, checked against their format strings."""
+
+    def __init__(self, graph: GoCallGraph):
+        self.graph = graph
+
+    @staticmethod
+    def _imports(parsed: Dict[str, Any]) -> Dict[str, str]:
+        return {imp["name"]: imp["path"] for imp in parsed.get("imports", []) if imp.get("name")}
+
+    def _qualified(self, func: Optional[Dict[str, Any]], call: Dict[str, Any], callee: Optional[Tuple[str, str]],
+                   imports: Dict[str, str]) -> Optional[str]:
+        if callee is not None:
+            return callee[1] if callee[0] == "" else None
+        chain = call["chain"]
+        # Package-level calls have no edges: `var msg = fmt.Sprintf(...)`
+        if len(chain) == 2 and chain[0] in imports and chain[0] not in (func or {}).get("locals", {}):
+            return f"{imports[chain[0]]}.{chain[1]}"
+        return None
+
+    def _format(self, path: str, func: Optional[Dict[str, Any]], source: Optional[Dict[str, Any]]) -> Optional[str]:
+        """The constant a format argument holds, through locals and package constants."""
+        for _ in range(4):
+            if not source:
+                return None
+            if isinstance(source.get("value"), str):
+                return source["value"]
+            chain = source.get("chain")
+            if not chain:
+                return None
+            if len(chain) == 1 and func and chain[0] in func.get("locals", {}):
+                source = func["locals"][chain[0]]
+                continue
+            value = self.graph.constant_value(path, chain)
+            return value if isinstance(value, str) else None
+        return None
+
+    def _entry(self, path: str, func: Optional[Dict[str, Any]], call: Dict[str, Any],
+               qualified: str) -> Optional[Dict[str, Any]]:
+        index = PRINTF_FUNCS[qualified]
+        args, texts = call.get("args", []), call.get("arg_texts", [])
+        if len(texts) <= index:
+            return None
+        entry: Dict[str, Any] = {
+            "call": qualified,
+            "path": path,
+            "line": call["line"],
+            "column": call["column"],
+            "function": (f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"])
+                        if func else None,
+        }
+        format_text = self._format(path, func, args[index] if index < len(args) else None)
+        given = len(texts) - index - 1
+        spread = bool(texts) and len(texts) > index + 1 and texts[-1].endswith("...")
+        if format_text is None:
+            entry.update({"format": None, "format_expr": texts[index], "args": given,
+                          "status": "spread" if spread else "dynamic"})
+        else:
+            status, parsed, message = check_call(format_text, given, spread)
+            entry["format"] = format_text
+            if not texts[index].startswith(('"', "`")):
+                entry["format_expr"] = texts[index]
+            entry.update({"verbs": parsed["verbs"], "expected_args": parsed["args"], "args": given,
+                          "status": status})
+            if message:
+                entry["message"] = message
+        if path.endswith("_test.go"):
+            entry["in_test"] = True
+        return entry
+
+    def check(self, include_tests: bool = False, path: Optional[str] = None, symbol: Optional[str] = None,
+              mismatched_only: bool = False) -> Dict[str, Any]:
+        """
+        Every printf-style call in file and line order, with its format, the
+        arguments the format reads and passes, and its status.
+
+        Args:
+            include_tests: Also check the calls of _test.go files
+            path: Only this file or package directory (and below)
+            symbol: Only calls made in this function or method ("Type.Method" too)
+            mismatched_only: Only the calls whose arguments do not fit their format
+        """
+        sites = {(e["path"], e["line"], e["column"]): e["callee"] for e in self.graph.edges if e["kind"] != "reference"}
+        calls = []
+        for file_path, parsed in sorted(self.graph.project.files.items()):
+            if not include_tests and file_path.endswith("_test.go"):
+                continue
+            if path and file_path != path and not file_path.startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            imports = self._imports(parsed)
+            scopes = [(func, func.get("calls", [])) for func in parsed.get("functions", [])]
+            scopes.append((None, parsed.get("package_calls", [])))
+            for func, func_calls in scopes:
+                if symbol is not None:
+                    if func is None:
+                        continue
+                    name = f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+                    if symbol not in (name, func["name"]):
+                        continue
+                for call in func_calls:
+                    qualified = self._qualified(func, call, sites.get((file_path, call["line"], call["column"])),
+                                                imports)
+                    if qualified not in PRINTF_FUNCS:
+                        continue
+                    entry = self._entry(file_path, func, call, qualified)
+                    if entry is not None and (not mismatched_only or entry["status"] in MISMATCHES):
+                        calls.append(entry)
+        calls.sort(key=lambda e: (e["path"], e["line"], e["column"]))
+
+        symbols: Dict[Tuple[str, Optional[str]], Dict[str, Any]] = {}
+        for entry in calls:
+            group = symbols.setdefault((entry["path"], entry["function"]),
+                                       {"function": entry["function"], "path": entry["path"], "formats": []})
+            if entry["format"] is not None and entry["format"] not in group["formats"]:
+                group["formats"].append(entry["format"])
+        counts = {status: sum(1 for e in calls if e["status"] == status) for status in STATUSES}
+        return {
+            "calls": calls,
+            "total_count": len(calls),
+            "mismatch_count": sum(counts[status] for status in MISMATCHES),
+            "counts": counts,
+            "symbols": [group for group in symbols.values() if group["formats"]],
+        }
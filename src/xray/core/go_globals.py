@@ -0,0 +1,107 @@
+"""Read/write tracking for Go package-level variables.
+
+Shared mutable state is what makes concurrent Go code hard to reason about,
+so every access to a global is classified as a read or a write (assignment,
+map/slice element assignment, field assignment, ++/--, or taking its
+address), and writes made from goroutines are called out.
+"""
+
+import os
+from typing import Dict, List, Optional, Any
+
+from xray.core.go_analysis import GoCallGraph
+
+
+class GlobalUsageFinder:
+    """Finds the access sites of one package-level variable across the project."""
+
+    def __init__(self, graph: GoCallGraph):
+        self.graph = graph
+        self.project = graph.project
+        # Functions started with `go f(...)` run on their own goroutine
+        self.spawned = {edge["callee"] for edge in graph.edges
+                        if edge["kind"] == "go" and not edge["external"]}
+
+    def find_variables(self, name: str, path: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Return package-level variable symbols named `name`."""
+        matches = []
+        for file_path, parsed in sorted(self.project.files.items()):
+            pkg_dir = os.path.dirname(file_path)
+            if path and file_path != path and pkg_dir != path.rstrip(os.sep):
+                continue
+            for symbol in parsed["symbols"]:
+                if symbol["type"] == "variable" and symbol["name"] == name:
+                    matches.append({**symbol, "path": file_path, "package": parsed.get("package", "")})
+        return matches
+
+    def usages(self, variable: Dict[str, Any]) -> Dict[str, Any]:
+        name = variable["name"]
+        var_dir = os.path.dirname(variable["path"])
+        accesses = []
+        if variable.get("initialized"):
+            accesses.append(({
+                "path": variable["path"],
+                "line": variable["start_line"],
+                "column": None,
+                "function": None,
+                "access": "write",
+                "kind": "init",
+            }, (None, None)))
+
+        for file_path, parsed in sorted(self.project.files.items()):
+            pkg_dir = os.path.dirname(file_path)
+            imports = {imp["name"]: imp["path"] for imp in parsed.get("imports", []) if imp["name"]}
+            for func in parsed.get("functions", []):
+                func_name = f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+                spawned = (pkg_dir, func_name) in self.spawned
+                for use in func.get("free_uses", []):
+                    if use["name"] != name:
+                        continue
+                    if "qualifier" in use:
+                        if self.project.import_dir(imports.get(use["qualifier"], ""), file_path) != var_dir:
+                            continue
+                    elif pkg_dir != var_dir:
+                        continue
+                    entry = {
+                        "path": file_path,
+                        "line": use["line"],
+                        "column": use["column"],
+                        "function": func_name,
+                        "access": use["access"],
+                        "kind": use["kind"],
+                    }
+                    # Which goroutine the access runs on: a `go func(){}` literal, a
+                    # function started with `go`, or whoever calls the function
+                    if use.get("in_goroutine"):
+                        context = (func_name, use["goroutine_line"])
+                    elif spawned:
+                        context = (func_name, "go")
+                    else:
+                        context = (func_name, None)
+                    if context[1] is not None:
+                        entry["in_goroutine"] = True
+                    accesses.append((entry, context))
+
+        writes = [a for a, _ in accesses if a["access"] == "write"]
+        # Initializers run before main, so they never race with anything
+        contexts = {c for a, c in accesses if a["access"] == "write" and a["kind"] != "init"}
+        concurrent = len(contexts) > 1 and any(c[1] is not None for c in contexts)
+        accesses = [a for a, _ in accesses]
+
+        result = {
+            "symbol": {
+                "name": name,
+                "package": variable["package"],
+                "path": variable["path"],
+                "start_line": variable["start_line"],
+                "signature": variable["signature"],
+            },
+            "accesses": accesses,
+            "reads": len(accesses) - len(writes),
+            "writes": len(writes),
+            "written_from": sorted({a["function"] for a in writes if a["function"]}),
+            "read_from": sorted({a["function"] for a in accesses if a["access"] == "read"}),
+        }
+        if concurrent:
+            result["concurrent_writes"] = True
+        return result
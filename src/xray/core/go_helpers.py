@@ -0,0 +1,20 @@
+"""Helpers the Go analyses share for reading a file's tokens and imports (see xray.core.go_parser)."""
+
+from typing import Any, Dict, List, Optional
+
+from xray.core.go_parser import Token
+
+
+def token_is(tokens: List[Token], index: int, value: str) -> bool:
+    """Whether tokens[index] exists and is value - an operator, keyword or identifier, not a string's text."""
+    return 0 <= index < len(tokens) and tokens[index].value == value and tokens[index].kind != "string"
+
+
+def ident_at(tokens: List[Token], index: int) -> Optional[str]:
+    """The identifier at tokens[index], or None when there is none."""
+    return tokens[index].value if 0 <= index < len(tokens) and tokens[index].kind == "ident" else None
+
+
+def file_imports(parsed: Dict[str, Any]) -> Dict[str, str]:
+    """A parsed file's import name -> import path, for its default and aliased imports (not dot or blank ones)."""
+    return {imp["name"]: imp["path"] for imp in parsed.get("imports", []) if imp["kind"] in ("default", "alias")}
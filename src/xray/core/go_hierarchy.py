@@ -0,0 +1,220 @@
+"""Type hierarchies of Go types - embeds, aliases and underlying types.
+
+Go has no inheritance; types relate through embedding (a struct embedding
+Base, an interface embedding io.Reader), through implementing interfaces,
+and through being defined on the same underlying type (`type UserID int`,
+`type OrderID int`). TypeHierarchy walks those relations out from one type
+and returns them as a graph of nodes and edges for rendering:
+
+    struct      what it embeds, and the structs embedding it
+    interface   embedded interfaces, the interfaces and structs embedding
+                it, and the types implementing it (or nearly)
+    defined     its underlying type and the other types on the same one
+
+Every node carries an "id" - import path and name, "example.com/app/store.User"
+- along with the path and line follow-up tools take. Embeds are followed
+depth levels in each direction; a type reached twice is not followed
+again, so interfaces embedding each other (invalid Go, but parseable, one
+file at a time) end the walk instead of looping, with the edge closing the
+loop marked "cycle".
+"""
+
+import os
+import re
+from typing import Any, Dict, List, Optional, Set, Tuple
+
+from xray.core.go_analysis import GoProject
+
+_IDENTIFIER = re.compile(r"[A-Za-z_]\w*(?:\.[A-Za-z_]\w*)?")
+_PREDECLARED = {
+    "bool", "byte", "complex64", "complex128", "error", "float32", "float64", "int", "int8", "int16",
+    "int32", "int64", "rune", "string", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "any",
+    "comparable", "map", "chan", "func", "struct", "interface",
+}
+
+
+class TypeHierarchy:
+    """Builds the hierarchy graph around one project type."""
+
+    def __init__(self, project: GoProject):
+        self.project = project
+        self.nodes: Dict[str, Dict[str, Any]] = {}
+        self.edges: List[Dict[str, Any]] = []
+        self._embedded_by: Optional[Dict[Tuple[str, str], List[Tuple[str, str]]]] = None
+
+    def type_id(self, key: Tuple[str, str]) -> str:
+        """The id of a project type: its package's import path (or directory) and name."""
+        symbol = self.project.types.get(key)
+        package = self.project.import_path(key[0]) or (symbol or {}).get("package") or key[0]
+        return f"{package}.{key[1]}"
+
+    def _node(self, key: Tuple[str, str]) -> str:
+        node_id = self.type_id(key)
+        if node_id not in self.nodes:
+            symbol = self.project.types[key]
+            node = {
+                "id": node_id,
+                "name": key[1],
+                "kind": symbol["type"],
+                "package": symbol["package"],
+                "path": symbol["path"],
+                "start_line": symbol["start_line"],
+            }
+            if symbol.get("underlying") and not symbol.get("alias"):
+                node["underlying"] = symbol["underlying"]
+            self.nodes[node_id] = node
+        return node_id
+
+    def _external(self, written: str, key: Tuple[str, str]) -> str:
+        """A node for a type outside the project, id'd by import path where the file imports it."""
+        name = written.lstrip("*").split("[", 1)[0]
+        if "." in name:
+            qualifier, base = name.split(".", 1)
+            imports = {imp["name"]: imp["path"] for imp in self.project.files.get(
+                self.project.types[key]["path"], {}).get("imports", []) if imp["name"]}
+            name = f"{imports.get(qualifier, qualifier)}.{base}"
+        self.nodes.setdefault(name, {"id": name, "name": name.rsplit("/", 1)[-1], "kind": "external"})
+        return name
+
+    def _edge(self, source: str, target: str, relation: str, **extra: Any):
+        for edge in self.edges:
+            # The same embed reached walking down and walking up
+            if (edge["from"], edge["to"], edge["relation"]) == (source, target, relation):
+                edge.update(extra)
+                return
+        self.edges.append({"from": source, "to": target, "relation": relation, **extra})
+
+    def _embedded_by_index(self) -> Dict[Tuple[str, str], List[Tuple[str, str]]]:
+        """type key -> the project types embedding it."""
+        if self._embedded_by is None:
+            self._embedded_by = {}
+            for key in sorted(self.project.types):
+                for _, embedded in self.project.embeds_of(key):
+                    if embedded is not None and embedded != key:
+                        self._embedded_by.setdefault(embedded, []).append(key)
+        return self._embedded_by
+
+    def _walk_embeds(self, root: Tuple[str, str], depth: int, outward: bool):
+        """
+        Follow embeds from root, depth levels: down what each type embeds, or
+        (outward) up to the types embedding it. Edges always point from the
+        embedding type to the embedded one.
+        """
+        parents: Dict[Tuple[str, str], Optional[Tuple[str, str]]] = {root: None}
+        level = [root]
+        for _ in range(max(depth, 0)):
+            next_level = []
+            for key in level:
+                if outward:
+                    neighbours = [(None, other) for other in self._embedded_by_index().get(key, [])]
+                else:
+                    neighbours = self.project.embeds_of(key)
+                for written, other in neighbours:
+                    if other is None:
+                        self._edge(self._node(key), self._external(written, key), "embeds")
+                        continue
+                    source, target = (other, key) if outward else (key, other)
+                    extra = {"pointer": True} if written and written.startswith("*") else {}
+                    if other in parents:
+                        if self._is_ancestor(other, key, parents):
+                            extra["cycle"] = True
+                        self._edge(self._node(source), self._node(target), "embeds", **extra)
+                        continue
+                    parents[other] = key
+                    self._edge(self._node(source), self._node(target), "embeds", **extra)
+                    next_level.append(other)
+            level = next_level
+
+    @staticmethod
+    def _is_ancestor(candidate: Tuple[str, str], key: Tuple[str, str],
+                     parents: Dict[Tuple[str, str], Optional[Tuple[str, str]]]) -> bool:
+        current: Optional[Tuple[str, str]] = key
+        seen: Set[Tuple[str, str]] = set()
+        while current is not None and current not in seen:
+            if current == candidate:
+                return True
+            seen.add(current)
+            current = parents.get(current)
+        return False
+
+    def underlying(self, key: Tuple[str, str]) -> Tuple[str, List[Tuple[str, str]]]:
+        """
+        The underlying type of a defined type, through the project types it is
+        defined on (`type AdminID UserID` -> "int" via UserID).
+
+        Returns:
+            (underlying type text, project types passed through)
+        """
+        through: List[Tuple[str, str]] = []
+        current = key
+        text = self.project.types[key].get("underlying", "")
+        while _IDENTIFIER.fullmatch(text) and text not in _PREDECLARED:
+            next_key = self.project.resolve_type_ref(current[0], text)
+            # `type A B; type B A` does not compile, but parses
+            if next_key is None or next_key == key or next_key in through:
+                break
+            through.append(next_key)
+            symbol = self.project.types[next_key]
+            if symbol["type"] != "type":
+                # Defined on a struct or interface type
+                return symbol["type"], through
+            current, text = next_key, symbol.get("underlying", "")
+        return text, through
+
+    def _same_underlying(self, key: Tuple[str, str], text: str) -> List[Tuple[str, str]]:
+        """
+        Other defined types with the same underlying type. Names in a composite
+        underlying type ([]UserID) are package-relative, so those only match
+        within the package.
+        """
+        local = any(name not in _PREDECLARED for name in _IDENTIFIER.findall(text))
+        matches = []
+        for other, symbol in sorted(self.project.types.items()):
+            if other == key or symbol["type"] != "type" or symbol.get("alias"):
+                continue
+            if local and other[0] != key[0]:
+                continue
+            if self.underlying(other)[0] == text:
+                matches.append(other)
+        return matches
+
+    def build(self, symbol: Dict[str, Any], depth: int = 2) -> Dict[str, Any]:
+        """The hierarchy around a type symbol (an alias is followed to its target first)."""
+        key = (os.path.dirname(symbol["path"]), symbol["name"])
+        target = self.project.resolve_alias_key(key)
+        root = self._node(target)
+        kind = self.project.types[target]["type"]
+
+        for alias in self.project.aliases_of(target):
+            self._edge(self._node((os.path.dirname(alias["path"]), alias["name"])), root, "alias_of")
+
+        result: Dict[str, Any] = {"root": root, "kind": kind, "depth": depth}
+        if kind in ("struct", "interface"):
+            self._walk_embeds(target, depth, outward=False)
+            self._walk_embeds(target, depth, outward=True)
+        if kind == "interface":
+            matches = self.project.implementations_of(self.project.types[target])
+            for impl in matches["implementations"]:
+                impl_id = self._node((os.path.dirname(impl["path"]), impl["name"]))
+                self._edge(impl_id, root, "implements", satisfied_by=impl["satisfied_by"])
+            for impl in matches["partial"]:
+                impl_id = self._node((os.path.dirname(impl["path"]), impl["name"]))
+                self._edge(impl_id, root, "partially_implements", missing=impl["missing"])
+        elif kind == "type":
+            text, through = self.underlying(target)
+            previous = root
+            for step in through:
+                step_id = self._node(step)
+                self._edge(previous, step_id, "defined_on")
+                previous = step_id
+            result["underlying"] = text
+            for other in self._same_underlying(target, text):
+                if other in through:
+                    continue
+                self._edge(self._node(other), root, "same_underlying", underlying=text)
+
+        result["nodes"] = sorted(self.nodes.values(), key=lambda n: (n["id"] != root, n["id"]))
+        result["edges"] = self.edges
+        if any(edge.get("cycle") for edge in self.edges):
+            result["cycles"] = True
+        return result
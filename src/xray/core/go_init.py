@@ -0,0 +1,300 @@
+"""What runs before main: package initialization in Go code.
+
+Every package's variables are initialized and its init functions run once,
+before main, packages in the order the Go 1.21 spec fixes: repeatedly the
+first package, by import path, whose imports are all initialized. A package
+imported with `_` is there for exactly this code.
+
+InitAnalyzer lists per package its init functions and the variables whose
+initializer calls something (plain literals and composite literals are left
+out, and so is a func literal assigned to a variable - its body runs later),
+with the side effects each can have, directly or through the project
+functions it calls:
+
+    io     files, the network, databases, subprocesses (os.ReadFile,
+           http.Get, sql.Open, template.ParseFiles, ...)
+    env    environment variables (os.Getenv, os.LookupEnv, ...)
+    panic  panic(), Must* helpers (regexp.MustCompile, template.Must),
+           log.Fatal/log.Panic and os.Exit: initialization that can abort
+           the program
+
+Blank imports are listed with the init code they pull in: for a project
+package its init functions and initialized variables and the other project
+packages it brings along, for a well-known library package what importing
+it registers.
+"""
+
+import os
+from typing import Any, Dict, List, Optional, Set, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+from xray.core.go_config import ENV_APIS
+
+EFFECT_KINDS = ("io", "env", "panic")
+
+_IO_CALLS = {
+    *(f"os.{f}" for f in ("Open", "OpenFile", "Create", "ReadFile", "WriteFile", "ReadDir", "Mkdir", "MkdirAll",
+                          "MkdirTemp", "CreateTemp", "Remove", "RemoveAll", "Rename", "Stat", "Lstat", "Chdir",
+                          "Symlink", "Link", "Truncate", "Chmod", "Chown")),
+    *(f"io/ioutil.{f}" for f in ("ReadFile", "WriteFile", "ReadDir", "ReadAll", "TempFile", "TempDir")),
+    *(f"net.{f}" for f in ("Dial", "DialTimeout", "Listen", "ListenPacket", "LookupHost", "LookupIP",
+                           "LookupAddr", "LookupCNAME", "LookupMX", "LookupTXT", "LookupSRV")),
+    *(f"net/http.{f}" for f in ("Get", "Post", "PostForm", "Head", "ListenAndServe", "ListenAndServeTLS",
+                                "Serve", "Client.Do", "Client.Get", "Client.Post", "Client.Head")),
+    *(f"database/sql.{f}" for f in ("Open", "OpenDB", "DB.Ping", "DB.PingContext", "DB.Exec", "DB.Query",
+                                    "DB.QueryRow")),
+    *(f"os/exec.Cmd.{m}" for m in ("Run", "Start", "Output", "CombinedOutput")),
+    *(f"{p}.{f}" for p in ("text/template", "html/template") for f in ("ParseFiles", "ParseGlob", "ParseFS",
+                                                                       "Template.ParseFiles", "Template.ParseGlob",
+                                                                       "Template.ParseFS")),
+    "path/filepath.Walk", "path/filepath.WalkDir", "path/filepath.Glob",
+    "crypto/tls.LoadX509KeyPair", "crypto/x509.SystemCertPool",
+}
+_ENV_CALLS = set(ENV_APIS) | {"os.Environ", "os.ExpandEnv"}
+_EXIT_CALLS = {"os.Exit", "log.Fatal", "log.Fatalf", "log.Fatalln", "log.Panic", "log.Panicf", "log.Panicln"}
+
+# What importing a library package for its side effects does
+KNOWN_SIDE_EFFECTS = {
+    "net/http/pprof": "registers the /debug/pprof/ handlers on http.DefaultServeMux",
+    "expvar": "registers the /debug/vars handler on http.DefaultServeMux",
+    "embed": "allows //go:embed directives in the importing file",
+    "time/tzdata": "embeds the time zone database in the binary",
+    "image/png": "registers the PNG decoder with image.Decode",
+    "image/jpeg": "registers the JPEG decoder with image.Decode",
+    "image/gif": "registers the GIF decoder with image.Decode",
+    "golang.org/x/image/webp": "registers the WebP decoder with image.Decode",
+    "github.com/lib/pq": "registers the \"postgres\" database/sql driver",
+    "github.com/jackc/pgx/v5/stdlib": "registers the \"pgx\" database/sql driver",
+    "github.com/jackc/pgx/v4/stdlib": "registers the \"pgx\" database/sql driver",
+    "github.com/go-sql-driver/mysql": "registers the \"mysql\" database/sql driver",
+    "github.com/mattn/go-sqlite3": "registers the \"sqlite3\" database/sql driver",
+    "modernc.org/sqlite": "registers the \"sqlite\" database/sql driver",
+    "github.com/denisenkom/go-mssqldb": "registers the \"sqlserver\" and \"mssql\" database/sql drivers",
+    "github.com/microsoft/go-mssqldb": "registers the \"sqlserver\" database/sql driver",
+    "go.uber.org/automaxprocs": "sets GOMAXPROCS from the container CPU quota",
+    "github.com/joho/godotenv/autoload": "loads .env into the environment",
+}
+
+
+class InitAnalyzer:
+    """Init functions, initialized variables and their side effects across a project's packages."""
+
+    def __init__(self, graph: GoCallGraph, module: Optional[str] = None):
+        self.graph = graph
+        self.project = graph.project
+        self.module = module
+        self._sites = {(e["path"], e["line"], e["column"]): e["callee"] for e in graph.edges
+                       if e["kind"] != "reference"}
+        # function key -> its effects, direct and through its callees
+        self._effects: Dict[Tuple[str, str], List[Dict[str, Any]]] = {}
+
+    def _package_id(self, pkg_dir: str) -> str:
+        import_path = self.project.import_path(pkg_dir)
+        if import_path:
+            return import_path
+        root = self.project.root or ""
+        rel = os.path.relpath(pkg_dir, root).replace(os.sep, "/") if root else pkg_dir
+        rel = "" if rel == "." else rel
+        if self.module:
+            return f"{self.module}/{rel}" if rel else self.module
+        return rel or "."
+
+    @staticmethod
+    def _kind(qualified: str) -> Optional[str]:
+        if qualified in _IO_CALLS:
+            return "io"
+        if qualified in _ENV_CALLS:
+            return "env"
+        name = qualified.rsplit(".", 1)[-1]
+        if qualified in _EXIT_CALLS or name.startswith("Must") and name[4:5].isupper() or name == "Must":
+            return "panic"
+        return None
+
+    def _call_effects(self, path: str, call: Dict[str, Any], callee: Optional[Tuple[str, str]],
+                      stack: Set[Tuple[str, str]]) -> List[Dict[str, Any]]:
+        """The effects of one call: the call itself, or what a project callee does."""
+        site = {"path": path, "line": call["line"]}
+        if callee is None:
+            if call["chain"] == ["panic"]:
+                return [{"kind": "panic", "call": "panic", **site}]
+            return []
+        if callee[0] == "":
+            kind = self._kind(callee[1])
+            return [{"kind": kind, "call": callee[1], **site}] if kind else []
+        kind = self._kind(callee[1])
+        if kind == "panic":
+            # A project Must helper: say so at the call, whatever its body does
+            return [{"kind": kind, "call": callee[1], **site}]
+        node = self.graph.nodes.get(callee)
+        label = f"{node['package']}.{callee[1]}" if node else callee[1]
+        return [{**effect, "via": [label] + effect.get("via", [])}
+                for effect in self._function_effects(callee, stack)]
+
+    def _function_effects(self, key: Tuple[str, str], stack: Set[Tuple[str, str]]) -> List[Dict[str, Any]]:
+        if key in self._effects:
+            return self._effects[key]
+        if key in stack or key not in self.graph.nodes:
+            return []
+        stack.add(key)
+        path = self.graph.nodes[key]["path"]
+        func = next((f for f in self.project.files[path].get("functions", [])
+                     if (f"{f['receiver']}.{f['name']}" if f.get("receiver") else f["name"]) == key[1]), None)
+        effects = self._body_effects(path, func, stack) if func else []
+        stack.discard(key)
+        self._effects[key] = effects
+        return effects
+
+    def _body_effects(self, path: str, func: Dict[str, Any], stack: Set[Tuple[str, str]]) -> List[Dict[str, Any]]:
+        seen, effects = set(), []
+        for call in func.get("calls", []):
+            callee = self._sites.get((path, call["line"], call["column"]))
+            for effect in self._call_effects(path, call, callee, stack):
+                marker = (effect["kind"], effect["call"], effect["path"], effect["line"])
+                if marker not in seen:
+                    seen.add(marker)
+                    effects.append(effect)
+        return effects
+
+    def _initializer_effects(self, path: str, calls: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """The effects of the calls in a package-level variable's initializer."""
+        seen, effects = set(), []
+        scope = {"locals": {}}
+        for call in calls:
+            target = self.graph.evaluate(path, scope, call["chain"])
+            callee = None
+            if target is not None and target[0] == "func":
+                callee = target[2] if target[1] == "project" else ("", target[2])
+            for effect in self._call_effects(path, call, callee, set()):
+                marker = (effect["kind"], effect["call"], effect["path"], effect["line"])
+                if marker not in seen:
+                    seen.add(marker)
+                    effects.append(effect)
+        return effects
+
+    def _order(self, imports: Dict[str, Set[str]]) -> List[str]:
+        """Package dirs in initialization order (Go 1.21: the first ready package by import path)."""
+        done: List[str] = []
+        left = set(imports)
+        while left:
+            ready = [d for d in left if not imports[d] & left]
+            if not ready:
+                # An import cycle does not compile; take the rest by name
+                ready = list(left)
+            chosen = min(ready, key=self._package_id)
+            done.append(chosen)
+            left.discard(chosen)
+        return done
+
+    def analyze(self, include_tests: bool = False, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Init code per package in initialization order, and the blank imports.
+
+        Args:
+            include_tests: Also count _test.go files (their init code runs in tests only)
+            path: Only packages in this directory (and below); the order stays project-wide
+        """
+        imports: Dict[str, Set[str]] = {}
+        for pkg_dir, info in self.project.packages.items():
+            files = [p for p in info["files"] if include_tests or not p.endswith("_test.go")]
+            if not files:
+                continue
+            imports[pkg_dir] = set()
+            for file_path in files:
+                for imp in self.project.files[file_path].get("imports", []):
+                    target = self.project.import_dir(imp["path"], file_path)
+                    if target is not None and target != pkg_dir:
+                        imports[pkg_dir].add(target)
+        for targets in imports.values():
+            targets &= imports.keys()
+        order = self._order(imports)
+
+        packages: Dict[str, Dict[str, Any]] = {}
+        for pkg_dir in order:
+            entry: Dict[str, Any] = {"package": self._package_id(pkg_dir), "init_functions": [], "variables": []}
+            for file_path in sorted(self.project.packages[pkg_dir]["files"]):
+                if file_path.endswith("_test.go") and not include_tests:
+                    continue
+                parsed = self.project.files[file_path]
+                for func in parsed.get("functions", []):
+                    if func["name"] == "init" and not func.get("receiver"):
+                        entry["init_functions"].append({
+                            "path": file_path, "line": func["start_line"],
+                            "calls": list(dict.fromkeys(".".join(c["chain"]) for c in func.get("calls", []))),
+                            "effects": self._body_effects(file_path, func, set()),
+                        })
+                declared = {s["name"]: s for s in parsed["symbols"] if s["type"] == "variable"}
+                calls: Dict[str, List[Dict[str, Any]]] = {}
+                for call in parsed.get("package_calls", []):
+                    for name in call.get("assigned_to", []):
+                        if not (parsed.get("package_vars", {}).get(name) or {}).get("func_literal"):
+                            calls.setdefault(name, []).append(call)
+                for name, made in calls.items():
+                    symbol = declared.get(name)
+                    entry["variables"].append({
+                        "name": name, "path": file_path,
+                        "line": symbol["start_line"] if symbol else made[0]["line"],
+                        "calls": list(dict.fromkeys(".".join(c["chain"]) for c in made)),
+                        "effects": self._initializer_effects(file_path, made),
+                    })
+            entry["variables"].sort(key=lambda v: (v["path"], v["line"]))
+            entry["imports"] = sorted(self._package_id(d) for d in imports[pkg_dir])
+            packages[pkg_dir] = entry
+
+        def pulls_in(pkg_dir: str) -> List[str]:
+            seen, work = set(), [pkg_dir]
+            while work:
+                for target in imports.get(work.pop(), ()):
+                    if target not in seen and target != pkg_dir:
+                        seen.add(target)
+                        work.append(target)
+            return [self._package_id(d) for d in order if d in seen and
+                    (packages[d]["init_functions"] or packages[d]["variables"])]
+
+        blank_imports = []
+        for file_path in sorted(self.project.files):
+            if file_path.endswith("_test.go") and not include_tests:
+                continue
+            for imp in self.project.files[file_path].get("imports", []):
+                if imp["kind"] != "blank":
+                    continue
+                entry = {"path": file_path, "line": imp["line"], "import": imp["path"],
+                         "importer": self._package_id(os.path.dirname(file_path))}
+                target = self.project.import_dir(imp["path"], file_path)
+                if target in packages:
+                    entry["in_project"] = True
+                    entry["init_functions"] = packages[target]["init_functions"]
+                    entry["variables"] = [{"name": v["name"], "path": v["path"], "line": v["line"]}
+                                          for v in packages[target]["variables"]]
+                    entry["pulls_in"] = pulls_in(target)
+                    if not entry["init_functions"] and not entry["variables"] and not entry["pulls_in"]:
+                        entry["note"] = "the package has no init code: the import does nothing"
+                else:
+                    entry["in_project"] = False
+                    if imp["path"] in KNOWN_SIDE_EFFECTS:
+                        entry["effect"] = KNOWN_SIDE_EFFECTS[imp["path"]]
+                blank_imports.append(entry)
+
+        def inside(p: str) -> bool:
+            return not path or p == path.rstrip(os.sep) or p.startswith(path.rstrip(os.sep) + os.sep)
+
+        listed, counts = [], {"init_functions": 0, "variables": 0, **{kind: 0 for kind in EFFECT_KINDS}}
+        for index, pkg_dir in enumerate(order):
+            entry = packages[pkg_dir]
+            if not inside(pkg_dir) or not entry["init_functions"] and not entry["variables"]:
+                continue
+            flagged = {kind: 0 for kind in EFFECT_KINDS}
+            for code in entry["init_functions"] + entry["variables"]:
+                for kind in {effect["kind"] for effect in code["effects"]}:
+                    flagged[kind] += 1
+            listed.append({**entry, "dir": os.path.relpath(pkg_dir, self.project.root or pkg_dir).replace(os.sep, "/"),
+                           "order": index + 1, "flagged": flagged})
+            counts["init_functions"] += len(entry["init_functions"])
+            counts["variables"] += len(entry["variables"])
+            for kind in EFFECT_KINDS:
+                counts[kind] += flagged[kind]
+        return {
+            "packages": listed,
+            "order": [self._package_id(d) for d in order],
+            "blank_imports": [b for b in blank_imports if inside(os.path.dirname(b["path"]))],
+            "counts": counts,
+        }
@@ -0,0 +1,170 @@
+"""Interface usage - which methods of a Go interface its consumers call through it.
+
+Interfaces grow methods nobody calls through the interface: an implementer
+gains one, the interface follows, and the only caller holds the concrete
+type. InterfaceUsage counts, for each method of one interface (embedded
+interfaces' methods included), the call sites whose receiver expression
+has the interface as its static type - a parameter, field, local or result
+declared as the interface, or as an interface embedding it - and the
+method values taken from one (`f := svc.DeleteUser`).
+
+    calls            calls through the interface (or an interface embedding it)
+    references       method values taken through it
+    concrete_calls   calls of the method on a type implementing the interface:
+                     not uses of the interface, but a method called only this
+                     way could move off it
+    unresolved       selectors with the method's name whose receiver could
+                     not be typed - check those before dropping a method
+
+A method with no calls and no references is "unused": a candidate to drop,
+or to split into an interface of its own. The packages calling each method
+are listed, and consumers grouped by the methods they use, which is the
+split along consumer lines: one small interface per group.
+"""
+
+import os
+from typing import Any, Dict, List, Set, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+
+Key = Tuple[str, str]
+
+
+class InterfaceUsage:
+    """Calls made through one Go interface, method by method."""
+
+    def __init__(self, graph: GoCallGraph):
+        self.graph = graph
+        self.project = graph.project
+
+    def _package_id(self, pkg_dir: str) -> str:
+        import_path = self.project.import_path(pkg_dir)
+        if import_path:
+            return import_path
+        root = self.project.root or ""
+        rel = os.path.relpath(pkg_dir, root).replace(os.sep, "/") if root else pkg_dir
+        return "." if rel in ("", ".") else rel
+
+    def _embedders(self, key: Key) -> Set[Key]:
+        """The interfaces embedding key, directly or through each other."""
+        found: Set[Key] = set()
+        changed = True
+        while changed:
+            changed = False
+            for other, symbol in self.project.types.items():
+                if other == key or other in found or symbol["type"] != "interface" or symbol.get("alias"):
+                    continue
+                embedded = {self.project.resolve_alias_key(k) for _, k in self.project.embeds_of(other) if k}
+                if key in embedded or embedded & found:
+                    found.add(other)
+                    changed = True
+        return found
+
+    def usage(self, iface: Dict[str, Any], include_tests: bool = False) -> Dict[str, Any]:
+        """
+        The methods of iface with the call sites reaching each through the
+        interface, the packages making them, and the methods nobody calls
+        that way.
+
+        Args:
+            iface: The interface's type symbol
+            include_tests: Also count calls made in _test.go files
+        """
+        key = (os.path.dirname(iface["path"]), iface["name"])
+        specs, unresolved_embeds = self.project.interface_method_set(*key)
+        embedders = self._embedders(key)
+        implementers = {(os.path.dirname(m["path"]), m["name"])
+                        for m in self.project.implementations_of(iface)["implementations"]}
+        methods: Dict[str, Dict[str, Any]] = {}
+        for name, spec in sorted(specs.items()):
+            entry: Dict[str, Any] = {"name": name, "signature": spec.get("signature", ""),
+                                     "path": spec["path"], "line": spec["start_line"]}
+            if (os.path.dirname(spec["path"]), spec.get("container")) != key:
+                entry["declared_in"] = spec.get("container")
+            entry.update({"calls": 0, "references": 0, "concrete_calls": 0, "unresolved": 0,
+                          "consumer_packages": [], "call_sites": []})
+            methods[name] = entry
+
+        for file_path, parsed in sorted(self.project.files.items()):
+            if not include_tests and file_path.endswith("_test.go"):
+                continue
+            imports = {imp["name"] for imp in parsed.get("imports", []) if imp.get("name")}
+            package = self._package_id(os.path.dirname(file_path))
+            scopes = [(func, func.get("calls", []), func.get("value_refs", [])) for func in parsed.get("functions", [])]
+            scopes.append((None, parsed.get("package_calls", []), []))
+            for func, calls, refs in scopes:
+                scope = func or {"locals": {}}
+                function = (f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]) \
+                    if func else None
+                sites = [(call, "call") for call in calls] + [(ref, "reference") for ref in refs]
+                for site, kind in sites:
+                    chain = site["chain"]
+                    if len(chain) < 2 or chain[-1] not in methods:
+                        continue
+                    entry = methods[chain[-1]]
+                    named = self.graph.receiver_of(file_path, scope, chain)
+                    if named is None:
+                        if kind == "call" and not (len(chain) == 2 and chain[0] in imports
+                                                   and chain[0] not in scope.get("locals", {})):
+                            entry["unresolved"] += 1
+                        continue
+                    if named[0] != "project":
+                        continue
+                    receiver = self.project.resolve_alias_key((named[1], named[2]))
+                    if receiver in implementers:
+                        if kind == "call":
+                            entry["concrete_calls"] += 1
+                        continue
+                    if receiver != key and receiver not in embedders:
+                        continue
+                    call_site: Dict[str, Any] = {"path": file_path, "line": site["line"], "column": site["column"],
+                                                 "function": function, "package": package}
+                    if kind == "reference":
+                        call_site["kind"] = "reference"
+                    if receiver != key:
+                        call_site["via"] = receiver[1]
+                    if file_path.endswith("_test.go"):
+                        call_site["in_test"] = True
+                    entry["calls" if kind == "call" else "references"] += 1
+                    entry["call_sites"].append(call_site)
+                    if package not in entry["consumer_packages"]:
+                        entry["consumer_packages"].append(package)
+
+        consumers: Dict[str, Dict[str, Any]] = {}
+        for entry in methods.values():
+            entry["consumer_packages"].sort()
+            entry["call_sites"].sort(key=lambda s: (s["path"], s["line"], s["column"]))
+            for site in entry["call_sites"]:
+                consumer = consumers.setdefault(site["package"], {"package": site["package"], "methods": [],
+                                                                  "call_count": 0})
+                consumer["call_count"] += 1
+                if entry["name"] not in consumer["methods"]:
+                    consumer["methods"].append(entry["name"])
+        groups: Dict[Tuple[str, ...], List[str]] = {}
+        for package, consumer in sorted(consumers.items()):
+            consumer["methods"].sort()
+            groups.setdefault(tuple(consumer["methods"]), []).append(package)
+
+        unused = [name for name, entry in methods.items() if not entry["calls"] and not entry["references"]]
+        result: Dict[str, Any] = {
+            "interface": {"name": iface["name"], "package": iface.get("package", ""),
+                          "import_path": self.project.import_path(key[0]), "path": iface["path"],
+                          "start_line": iface["start_line"], "method_count": len(methods)},
+            "methods": list(methods.values()),
+            "unused": unused,
+            "consumers": sorted(consumers.values(), key=lambda c: c["package"]),
+            "consumer_groups": [{"methods": list(group), "packages": packages}
+                                for group, packages in sorted(groups.items(), key=lambda g: (-len(g[0]), g[0]))],
+            "total_count": len(methods),
+        }
+        if embedders:
+            result["embedded_by"] = sorted(name for _, name in embedders)
+        if unresolved_embeds:
+            result["interface"]["unresolved_embeds"] = unresolved_embeds
+        if unused:
+            result["message"] = (f"{len(unused)} of {len(methods)} methods of {iface['name']} are never called "
+                                 f"through it: {', '.join(unused)} - candidates to drop or split off")
+        elif len(groups) > 1:
+            result["message"] = (f"{len(groups)} groups of consumers use different methods of {iface['name']}: "
+                                 f"consumer_groups suggests a split")
+        return result
@@ -0,0 +1,283 @@
+"""String, number and boolean literals in Go code, found by value.
+
+Files are scanned token by token, so text in comments never matches. A
+query is a number when it reads as one (any base, `_` separators, floats
+compared by value: "100" finds `100`, `0x64` and `1e2`), a boolean for
+"true"/"false", and a string otherwise - matched exactly against the
+literal's unquoted value, or as a regular expression. A leading "-" only
+matches a negated number.
+
+Each hit is given the symbol it sits in and its syntactic role, read from
+the innermost bracket around it and the statement it is part of:
+- "function argument" (with "call"), "struct literal field" (with
+  "field"), "map entry", "composite element", "index"
+- "const value" / "var value" (with "name"), "comparison operand",
+  "case value", "return value", "assignment"
+- "struct tag", "import path", or "expression" for anything else
+"""
+
+import math
+import os
+import re
+from typing import Any, Callable, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoProject
+from xray.core.go_parser import Token, tokenize, unquote
+
+LITERAL_KINDS = ("string", "number", "bool")
+
+_COMPARISONS = {"==", "!=", "<", "<=", ">", ">="}
+_NUMBER_KINDS = {"int", "float"}
+# Tokens after which a "-" is a sign, not a subtraction
+_UNARY_AFTER = {"(", "[", "{", ",", ":", ";", "=", ":=", "==", "!=", "<", "<=", ">", ">=",
+                "+", "-", "*", "/", "%", "&&", "||", "!", "<-", "return", "case"}
+_BLOCK_HEADS = {"if", "for", "switch", "select", "else", "func"}
+
+
+def parse_number(text: str) -> Optional[float]:
+    """The value of a Go or query number ("100", "0x64", "1_000", "1e2", "0o17"), None if it is none."""
+    text = text.replace("_", "")
+    try:
+        if re.fullmatch(r"0[0-7]+", text):
+            return float(int(text, 8))
+        return float(int(text, 0))
+    except ValueError:
+        pass
+    try:
+        value = float.fromhex(text) if text.lower().startswith("0x") else float(text)
+    except ValueError:
+        return None
+    return value if math.isfinite(value) else None
+
+
+def _opener(tokens: List[Token], index: int) -> int:
+    """The index of the innermost bracket open at a token, -1 at top level."""
+    depth = 0
+    for k in range(index - 1, -1, -1):
+        value = tokens[k].value if tokens[k].kind == "op" else None
+        if value in (")", "]", "}"):
+            depth += 1
+        elif value in ("(", "[", "{"):
+            if depth == 0:
+                return k
+            depth -= 1
+    return -1
+
+
+def _statement_start(tokens: List[Token], index: int, opener: int) -> int:
+    """The first token of the statement (or spec of a const/var group) holding a token; a case or label ends at its colon."""
+    depth = 0
+    for k in range(index - 1, opener, -1):
+        value = tokens[k].value if tokens[k].kind == "op" else None
+        if value in (")", "]", "}"):
+            depth += 1
+        elif value in ("(", "[", "{"):
+            depth -= 1
+        elif value in (";", ":") and depth == 0:
+            return k + 1
+    return opener + 1
+
+
+def _callee(tokens: List[Token], paren: int) -> Optional[str]:
+    """The called name before a call's parenthesis ("fmt.Println", "s.db.Exec"), None if it is no call."""
+    parts = []
+    k = paren - 1
+    while k >= 0 and tokens[k].kind == "ident":
+        parts.append(tokens[k].value)
+        if k >= 1 and tokens[k - 1].value == "." and tokens[k - 1].kind == "op":
+            k -= 2
+        else:
+            break
+    if not parts:
+        if k >= 0 and tokens[k].kind == "op" and tokens[k].value in (")", "]"):
+            return "(...)"
+        return None
+    if k >= 1 and tokens[k - 1].kind == "keyword" and tokens[k - 1].value == "func":
+        return None
+    return ".".join(reversed(parts))
+
+
+def _composite(tokens: List[Token], brace: int) -> bool:
+    """Whether a brace opens a composite literal rather than a block or a type body."""
+    if brace == 0:
+        return False
+    before = tokens[brace - 1]
+    if before.kind == "keyword":
+        return before.value == "map"
+    if before.kind == "op" and before.value in ("{", ",", ":"):
+        # An element of a composite literal with its type elided: []T{{...}}
+        outer = _opener(tokens, brace)
+        return outer >= 0 and tokens[outer].value == "{" and _composite(tokens, outer)
+    if before.kind == "op" and before.value == "}":
+        # []struct{...}{...}: the brace after a struct type
+        inner = _opener(tokens, brace - 1)
+        return inner > 0 and tokens[inner - 1].kind == "keyword" and tokens[inner - 1].value == "struct"
+    if before.kind != "ident" and not (before.kind == "op" and before.value == "]"):
+        return False
+    # `if x == y {`, `f := func() T {` - after a statement header or a signature a brace opens the block
+    depth = 0
+    for tok in tokens[_statement_start(tokens, brace, _opener(tokens, brace)):brace]:
+        if tok.kind == "op" and tok.value in ("(", "[", "{"):
+            depth += 1
+        elif tok.kind == "op" and tok.value in (")", "]", "}"):
+            depth -= 1
+        elif depth == 0 and tok.kind == "keyword" and tok.value in _BLOCK_HEADS:
+            return False
+    return True
+
+
+def literal_role(tokens: List[Token], index: int) -> Dict[str, Any]:
+    """The syntactic role of the literal at index (its sign included), as {"role", ...details}."""
+    before = tokens[index - 1] if index else None
+    after = tokens[index + 1] if index + 1 < len(tokens) else None
+    opener = _opener(tokens, index)
+    start = _statement_start(tokens, index, opener)
+    head = tokens[start] if start < len(tokens) else None
+    group = tokens[opener - 1] if opener > 0 else None
+
+    if opener >= 0 and tokens[opener].value == "(" and group is not None and group.kind == "keyword" \
+            and group.value in ("const", "var", "import"):
+        kind = group.value
+    elif head is not None and head.kind == "keyword" and head.value in ("const", "var", "import"):
+        kind = head.value
+    else:
+        kind = None
+    if kind == "import":
+        return {"role": "import path"}
+    if before is not None and before.kind == "op" and before.value in _COMPARISONS \
+            or after is not None and after.kind == "op" and after.value in _COMPARISONS:
+        return {"role": "comparison operand"}
+    if opener >= 0 and tokens[opener].value == "(":
+        callee = _callee(tokens, opener)
+        if callee is not None:
+            return {"role": "function argument", "call": callee}
+    if opener >= 0 and tokens[opener].value == "[":
+        return {"role": "index"}
+    if opener >= 0 and tokens[opener].value == "{":
+        if opener > 0 and tokens[opener - 1].kind == "keyword" and tokens[opener - 1].value == "struct":
+            return {"role": "struct tag"}
+        if _composite(tokens, opener):
+            key = tokens[index - 2] if index >= 2 else None
+            if before is not None and before.value == ":" and key is not None and key.kind == "ident" \
+                    and tokens[index - 3].value in ("{", ","):
+                if tokens[opener - 1].kind == "ident" or tokens[opener - 1].value == "}":
+                    return {"role": "struct literal field", "field": key.value}
+            if before is not None and before.value == ":" or after is not None and after.value == ":":
+                return {"role": "map entry"}
+            return {"role": "composite element"}
+    if kind in ("const", "var"):
+        names = [t.value for t in tokens[start:index] if t.kind == "ident"]
+        entry: Dict[str, Any] = {"role": f"{kind} value"}
+        if names:
+            entry["name"] = names[0]
+        return entry
+    if head is not None and head.kind == "keyword" and head.value == "case":
+        return {"role": "case value"}
+    if head is not None and head.kind == "keyword" and head.value == "return":
+        return {"role": "return value"}
+    if any(t.kind == "op" and t.value in ("=", ":=", "+=", "-=", "*=", "/=", "|=", "&=") for t in tokens[start:index]):
+        return {"role": "assignment"}
+    return {"role": "expression"}
+
+
+class LiteralFinder:
+    """Searches the literals of a project's Go files by value."""
+
+    def __init__(self, project: GoProject, read: Callable[[str], Optional[str]]):
+        self.project = project
+        self._read = read
+
+    @staticmethod
+    def _matcher(value: str, kind: Optional[str], regex: bool) -> Tuple[str, Callable[[Token], bool]]:
+        """The kind a query searches and a test of one token against it."""
+        if kind is not None and kind not in LITERAL_KINDS:
+            raise ValueError(f"kind must be one of {', '.join(LITERAL_KINDS)}")
+        if kind is None:
+            if regex:
+                kind = "string"
+            elif value in ("true", "false"):
+                kind = "bool"
+            else:
+                kind = "number" if parse_number(value.lstrip("-")) is not None else "string"
+        if kind == "bool":
+            if value not in ("true", "false"):
+                raise ValueError("A bool literal is true or false")
+            return kind, lambda tok: tok.kind == "ident" and tok.value == value
+        if kind == "number":
+            number = parse_number(value.lstrip("-"))
+            if number is None:
+                raise ValueError(f"'{value}' is not a number")
+            return kind, lambda tok: tok.kind in _NUMBER_KINDS and parse_number(tok.value) == number
+        if regex:
+            try:
+                pattern = re.compile(value)
+            except re.error as e:
+                raise ValueError(f"Invalid regular expression: {e}")
+            return kind, lambda tok: tok.kind == "string" and pattern.search(unquote(tok.value)) is not None
+        return kind, lambda tok: tok.kind == "string" and unquote(tok.value) == value
+
+    @staticmethod
+    def _enclosing(parsed: Dict[str, Any], line: int) -> Optional[Dict[str, Any]]:
+        """The innermost package-level declaration spanning a line."""
+        best = None
+        for symbol in parsed["symbols"]:
+            if symbol.get("container") or symbol.get("nested") or symbol["type"] == "field":
+                continue
+            if symbol["start_line"] <= line <= symbol.get("end_line", symbol["start_line"]):
+                if best is None or symbol["start_line"] >= best["start_line"]:
+                    best = symbol
+        return best
+
+    def find(self, value: str, regex: bool = False, kind: Optional[str] = None,
+             include_tests: bool = False, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Every literal matching a value, in file and line order.
+
+        Args:
+            value: The number, true/false, or string (exact, or a pattern with regex)
+            regex: Match strings by regular expression
+            kind: Search as "string", "number" or "bool" instead of guessing from value
+            include_tests: Also scan _test.go files (entries get "in_test")
+            path: Only this file or package directory (and below)
+        """
+        kind, matches = self._matcher(value, kind, regex)
+        negative = kind == "number" and value.startswith("-")
+        plain = kind == "string" and not regex and value and not re.search(r'[\\"\n\t`]', value)
+        literals = []
+        for file_path, parsed in sorted(self.project.files.items()):
+            is_test = file_path.endswith("_test.go")
+            if is_test and not include_tests:
+                continue
+            if path and file_path != path and not file_path.startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            content = self._read(file_path)
+            if content is None or plain and value not in content:
+                continue
+            tokens, _ = tokenize(content)
+            for index, tok in enumerate(tokens):
+                if not matches(tok):
+                    continue
+                at = index
+                sign = tokens[index - 1] if index else None
+                signed = sign is not None and sign.kind == "op" and sign.value == "-" and index >= 2 \
+                    and (tokens[index - 2].value in _UNARY_AFTER)
+                if kind == "number" and signed != negative:
+                    continue
+                if signed:
+                    at = index - 1
+                entry: Dict[str, Any] = {"path": file_path, "line": tokens[at].line, "column": tokens[at].col,
+                                         "text": ("-" if signed else "") + tok.value,
+                                         "kind": tok.kind if kind != "bool" else "bool"}
+                entry.update(literal_role(tokens, at))
+                symbol = self._enclosing(parsed, tok.line)
+                if symbol is not None:
+                    entry["symbol"] = GoProject.declaration_name(symbol) or symbol["name"]
+                    entry["symbol_type"] = symbol["type"]
+                if is_test:
+                    entry["in_test"] = True
+                literals.append(entry)
+        return {
+            "literals": literals,
+            "total_count": len(literals),
+            "query": {"value": value, "kind": kind, "regex": regex},
+        }
@@ -0,0 +1,572 @@
+"""Logging calls in Go code, and the call sites a log line can come from.
+
+Calls to the standard log and log/slog packages, logrus and zap (Logger and
+SugaredLogger) are recognized from the call graph; a chained builder the
+graph cannot type (`logrus.WithField(...).Errorf(...)`, `zap.L().Info(...)`)
+is recognized from the package it starts with. Project functions count as
+loggers too:
+
+- methods of a type named like a logger (`Logger.Log`, `AuditLog.Infof`)
+  with a logging method name, taking the message as their first string
+  parameter
+- short functions handing a string or variadic parameter to a logging
+  call as (part of) the message, such as `func Emit(name, msg string) { log.Print(name + ": " + msg) }`;
+  a call to one gets the inner template with its arguments filled in
+
+Each call has its message template - constant parts as written, printf
+verbs kept, runtime parts as `{expr}` placeholders - its format arguments
+or structured fields (slog and zap key-value pairs and attributes, logrus
+WithField/WithFields/WithError, zap and slog With), and its level when the
+method or a level argument names it.
+
+A log text fragment is matched against the templates the way the line was
+produced: verbs and placeholders stand for any text (%d only for digits), the
+fragment may hold the whole message with a prefix and key-value pairs around
+it, lie within the message, or overlap its start or end.
+"""
+
+import os
+import re
+from typing import Any, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+from xray.core.go_helpers import file_imports
+from xray.core.go_parser import unquote
+
+LOG_LEVELS = ("trace", "debug", "info", "warn", "error", "dpanic", "panic", "fatal")
+
+LIBRARIES = {"log": "log", "log/slog": "slog", "github.com/sirupsen/logrus": "logrus", "go.uber.org/zap": "zap"}
+
+# Literal characters a fragment must share with a template to match it
+MIN_MATCH = 4
+
+# Statements a function forwarding its parameters to a logging call can have and still be a wrapper
+MAX_WRAPPER_STATEMENTS = 3
+
+_LEVEL_NAMES = {"Trace": "trace", "Debug": "debug", "Info": "info", "Print": None, "Warn": "warn",
+                "Warning": "warn", "Error": "error", "DPanic": "dpanic", "Panic": "panic", "Fatal": "fatal"}
+_BASES = {
+    "log": ("Print", "Fatal", "Panic"),
+    "slog": ("Debug", "Info", "Warn", "Error"),
+    "logrus": ("Trace", "Debug", "Info", "Print", "Warn", "Warning", "Error", "Fatal", "Panic"),
+    "zap": ("Debug", "Info", "Warn", "Error", "DPanic", "Panic", "Fatal"),
+}
+_WRAPPER_BASES = ("Log", "Trace", "Debug", "Info", "Print", "Warn", "Warning", "Error", "Fatal", "Panic")
+_PRINT_STYLES = {"": "print", "f": "printf", "ln": "println", "w": "kv"}
+
+_VERB = re.compile(r"%(?:%|[-+# 0]*(?:\d+|\*)?(?:\.(?:\d+|\*)?)?[a-zA-Z])")
+_PLACEHOLDER = re.compile(r"\{([^{}]*)\}")
+_ATTR = re.compile(r'^[\w.]+\(\s*"((?:[^"\\]|\\.)*)"')
+_FIELDS_ENTRY = re.compile(r'"((?:[^"\\]|\\.)*)"\s*:\s*([^,}]+)')
+_LEVEL_ARG = re.compile(r"(?:Level(\w+)|(\w+?)Level)$")
+_FRAGMENT_KEY = re.compile(r'(?:^|[\s,{])"?([A-Za-z_][\w.-]*)"?\s*[=:]')
+
+Part = Tuple[str, str]
+
+
+def _split(name: str, bases: Tuple[str, ...], suffixes: Tuple[str, ...]) -> Optional[Tuple[str, str]]:
+    """A method name as (base, suffix): "Warnf" -> ("Warn", "f")."""
+    if name in bases:
+        return name, ""
+    for suffix in suffixes:
+        if suffix and name.endswith(suffix) and name[:-len(suffix)] in bases:
+            return name[:-len(suffix)], suffix
+    return None
+
+
+def _method(library: str, name: str, sugared: bool) -> Optional[Tuple[Optional[str], str, int, Optional[int]]]:
+    """The (level, style, message index, level argument index) of a logging method, None if it logs nothing."""
+    if library == "slog":
+        context = name.endswith("Context")
+        base = name[:-len("Context")] if context else name
+        if base in _BASES["slog"]:
+            return _LEVEL_NAMES[base], "kv", 1 if context else 0, None
+        if name in ("Log", "LogAttrs"):
+            return None, "kv", 2, 1
+        return None
+    if library == "zap":
+        if name == "Log":
+            return None, "fields", 1, 0
+        split = _split(name, _BASES["zap"], ("ln", "f", "w") if sugared else ())
+        if split is None:
+            return None
+        style = _PRINT_STYLES[split[1]] if sugared else "fields"
+        return _LEVEL_NAMES[split[0]], style, 0, None
+    if library == "logrus" and name in ("Log", "Logf", "Logln"):
+        return None, _PRINT_STYLES[name[3:]], 1, 0
+    split = _split(name, _BASES[library], ("ln", "f"))
+    if split is None:
+        return None
+    level = _LEVEL_NAMES[split[0]]
+    if library == "logrus" and split[0] == "Print":
+        level = "info"
+    return level, _PRINT_STYLES[split[1]], 0, None
+
+
+def _level_of(text: str) -> Optional[str]:
+    """The level a level argument names: slog.LevelWarn, logrus.WarnLevel, zapcore.ErrorLevel."""
+    found = _LEVEL_ARG.search(text.split(".")[-1])
+    if not found:
+        return None
+    level = (found.group(1) or found.group(2)).lower()
+    level = "warn" if level == "warning" else level
+    return level if level in LOG_LEVELS else None
+
+
+def _verbs(parts: List[Part]) -> List[Part]:
+    """Printf verbs of the text parts as parts of their own."""
+    split: List[Part] = []
+    for kind, text in parts:
+        if kind != "text":
+            split.append((kind, text))
+            continue
+        at = 0
+        for verb in _VERB.finditer(text):
+            if verb.start() > at:
+                split.append(("text", text[at:verb.start()]))
+            split.append(("text", "%") if verb.group() == "%%" else ("verb", verb.group()))
+            at = verb.end()
+        if at < len(text):
+            split.append(("text", text[at:]))
+    return split
+
+
+def render(parts: List[Part]) -> str:
+    """A template as text: runtime parts as `{expr}`, verbs as written."""
+    return "".join(text if kind in ("text", "verb") else "{" + text + "}" for kind, text in parts)
+
+
+def _units(parts: List[Part]) -> List[Tuple[bool, str]]:
+    """The template as (literal, char) units; a placeholder is one unit matching runs of "digits" or "any"."""
+    units = []
+    for kind, text in parts:
+        if kind == "text":
+            units.extend((True, ch) for ch in text)
+        elif kind == "verb" and text[-1] in "dbo":
+            units.append((False, "digits"))
+        else:
+            units.append((False, "any"))
+    return units
+
+
+def match_template(parts: List[Part], fragment: str) -> Optional[Tuple[str, int]]:
+    """
+    How a log text fragment fits a template, as (how, literal characters
+    matched), None if it cannot have come from it.
+
+    "whole": the fragment holds the whole message (with text around it),
+    "part": it lies within the message, "overlap": it runs across the
+    message's start or end. Literal characters must make up MIN_MATCH of the
+    fragment, and half of it unless the whole message is there.
+    """
+    units = _units(parts)
+    n = len(units)
+    # state -> best literal count. ("pre",) reads text before the message, ("post", entry) after it;
+    # ("in", pos, entry) is at a unit, entered at its start after text (0), at its start (1) or inside it (2)
+    states: Dict[Tuple[Any, ...], int] = {("pre",): 0}
+
+    def close(found: Dict[Tuple[Any, ...], int]) -> Dict[Tuple[Any, ...], int]:
+        """Add the states reached without reading: past empty placeholders, out at the end."""
+        stack = list(found.items())
+        while stack:
+            state, score = stack.pop()
+            if state[0] != "in":
+                continue
+            if state[1] == n:
+                nxt = ("post", state[2])
+            elif not units[state[1]][0]:
+                nxt = ("in", state[1] + 1, state[2])
+            else:
+                continue
+            if found.get(nxt, -1) < score:
+                found[nxt] = score
+                stack.append((nxt, score))
+        return found
+
+    for index, ch in enumerate(fragment):
+        current = dict(states)
+        if index == 0:
+            current[("in", 0, 1)] = 0
+            for pos in range(1, n):
+                current[("in", pos, 2)] = 0
+        elif ("pre",) in current:
+            current[("in", 0, 0)] = max(current.get(("in", 0, 0), 0), 0)
+        following: Dict[Tuple[Any, ...], int] = {}
+        for state, score in close(current).items():
+            if state[0] == "in":
+                pos = state[1]
+                if pos >= n:
+                    continue
+                literal, value = units[pos]
+                if literal and value == ch:
+                    state, score = ("in", pos + 1, state[2]), score + 1
+                elif literal or value == "digits" and ch not in "-+0123456789":
+                    continue
+            if following.get(state, -1) < score:
+                following[state] = score
+        states = following
+        if not states:
+            return None
+    best = None
+    for state, score in close(states).items():
+        if state[0] == "pre":
+            continue
+        if state[0] == "post":
+            how = "whole" if state[1] != 2 else "overlap"
+        else:
+            how = "part" if state[2] != 0 else "overlap"
+        if how != "whole" and score * 2 < len(fragment):
+            # Only a whole message may leave most of the fragment to the text around it
+            continue
+        rank = (score, how == "whole", how == "part")
+        if best is None or rank > best[0]:
+            best = (rank, how, score)
+    if best is None or best[2] < min(MIN_MATCH, len(fragment)):
+        return None
+    return best[1], best[2]
+
+
+class LogFinder:
+    """Collects the logging calls across every parsed Go file of a project."""
+
+    def __init__(self, graph: GoCallGraph):
+        self.graph = graph
+
+    def _parts(self, path: str, func: Dict[str, Any], source: Optional[Dict[str, Any]], text: str) -> List[Part]:
+        """The template of a message argument: a constant, a built string with placeholders, or one placeholder."""
+        source = source or {}
+        chain = source.get("chain")
+        if chain and len(chain) == 1:
+            local = func.get("locals", {}).get(chain[0])
+            if local and ("value" in local or "template" in local):
+                source = local
+            elif local is None:
+                value = self.graph.constant_value(path, chain)
+                if isinstance(value, str):
+                    return [("text", value)]
+        elif chain and len(chain) == 2:
+            value = self.graph.constant_value(path, chain)
+            if isinstance(value, str):
+                return [("text", value)]
+        if "value" in source:
+            return [("text", source["value"])]
+        if "template" in source:
+            parts: List[Part] = []
+            at = 0
+            for placeholder in _PLACEHOLDER.finditer(source["template"]):
+                if placeholder.start() > at:
+                    parts.append(("text", source["template"][at:placeholder.start()]))
+                expr = placeholder.group(1)
+                # fmt.Sprintf("on %s", ":8080") fills the verb with the literal itself
+                quoted = len(expr) >= 2 and expr[0] == expr[-1] and expr[0] in "\"`"
+                parts.append(("text", unquote(expr)) if quoted else ("expr", expr))
+                at = placeholder.end()
+            if at < len(source["template"]):
+                parts.append(("text", source["template"][at:]))
+            return parts
+        return [("expr", text)]
+
+    def _fields(self, path: str, func: Dict[str, Any], call: Dict[str, Any], start: int,
+                style: str) -> List[Dict[str, Any]]:
+        """The key-value pairs or attributes of a call from argument `start` on."""
+        fields = []
+        args, texts = call["args"], call["arg_texts"]
+        k = start
+        while k < len(args):
+            text = texts[k]
+            attr = _ATTR.match(text)
+            key = self._parts(path, func, args[k], text) if args[k] and args[k].get("type") == "string" else None
+            if attr:
+                fields.append({"key": attr.group(1), "value": text})
+                k += 1
+            elif text.startswith("zap.Error("):
+                fields.append({"key": "error", "value": text})
+                k += 1
+            elif style == "kv" and key is not None and len(key) == 1 and key[0][0] == "text":
+                fields.append({"key": key[0][1], "value": texts[k + 1] if k + 1 < len(texts) else None})
+                k += 2
+            else:
+                fields.append({"key": None, "value": text})
+                k += 1
+        return fields
+
+    def _builder_fields(self, path: str, func: Dict[str, Any], call: Dict[str, Any],
+                        library: str) -> List[Dict[str, Any]]:
+        """Fields attached by the builder calls a chained call starts with (`WithField(...).Error(...)`)."""
+        fields = []
+        chain = call["chain"]
+        for k, elem in enumerate(chain):
+            if elem != "()":
+                continue
+            prefix = chain[:k]
+            inner = [c for c in func.get("calls", []) if c["chain"] == prefix and
+                     (c["line"], c["column"]) <= (call["line"], call["column"])]
+            if not inner:
+                continue
+            inner = inner[-1]
+            name, texts = prefix[-1], inner["arg_texts"]
+            if name == "WithField" and len(texts) >= 2:
+                key = self._parts(path, func, inner["args"][0], texts[0])
+                fields.append({"key": render(key) if key[0][0] == "text" else None, "value": texts[1]})
+            elif name == "WithFields" and texts:
+                fields.extend({"key": key, "value": value.strip()} for key, value in _FIELDS_ENTRY.findall(texts[0]))
+            elif name == "WithError" and texts:
+                fields.append({"key": "error", "value": texts[0]})
+            elif name == "With":
+                sugared = library != "zap" or "Sugar" in prefix or "S" in prefix
+                fields.extend(self._fields(path, func, inner, 0, "kv" if sugared else "fields"))
+        return fields
+
+    def _library_of(self, path: str, func: Dict[str, Any], call: Dict[str, Any],
+                    callee: Optional[Tuple[str, str]]) -> Optional[Tuple[str, str, bool]]:
+        """(library, api, resolved) of a call into a logging library."""
+        if callee is not None and callee[0] == "":
+            for package, library in LIBRARIES.items():
+                if callee[1].startswith(package + "."):
+                    return library, callee[1], True
+            return None
+        if callee is not None or len(call["chain"]) < 2:
+            return None
+        # A chain the graph cannot type: named by the package it starts from
+        imports = file_imports(self.graph.project.files[path])
+        chain = call["chain"]
+        local = func.get("locals", {}).get(chain[0])
+        if local is not None:
+            chain = (local.get("chain") or [""]) + chain[1:]
+        elif "()" not in chain:
+            return None
+        package = imports.get(chain[0])
+        if package not in LIBRARIES:
+            return None
+        return LIBRARIES[package], ".".join([package] + chain[1:]).replace(".()", "()"), False
+
+    def _entry(self, path: str, func: Dict[str, Any], call: Dict[str, Any], parts: List[Part], style: str,
+               level: Optional[str], fields: List[Dict[str, Any]], format_args: Optional[List[str]]) -> Dict[str, Any]:
+        if style == "printf":
+            parts = _verbs(parts)
+        entry: Dict[str, Any] = {
+            "path": path, "line": call["line"], "column": call["column"],
+            "message": render(parts), "dynamic": any(kind not in ("text", "verb") for kind, _ in parts),
+            "level": level, "style": style,
+        }
+        if format_args is not None:
+            entry["format_args"] = format_args
+        if fields:
+            entry["fields"] = fields
+        entry["symbol"] = f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+        entry["symbol_type"] = "method" if func.get("receiver") else "function"
+        if path.endswith("_test.go"):
+            entry["in_test"] = True
+        entry["_parts"] = parts
+        return entry
+
+    def _library_call(self, path: str, func: Dict[str, Any], call: Dict[str, Any],
+                      found: Tuple[str, str, bool]) -> Optional[Dict[str, Any]]:
+        library, api, resolved = found
+        chain = call["chain"]
+        sugared = ".SugaredLogger." in api or not resolved and ("Sugar" in chain or "S" in chain)
+        method = _method(library, chain[-1], sugared)
+        if method is None:
+            return None
+        level, style, index, level_index = method
+        args, texts = call["args"], call["arg_texts"]
+        if level_index is not None and level_index < len(texts):
+            level = _level_of(texts[level_index])
+        if style in ("print", "println"):
+            parts: List[Part] = []
+            for k in range(index, len(args)):
+                if k > index and style == "println":
+                    parts.append(("text", " "))
+                parts.extend(self._parts(path, func, args[k], texts[k]))
+            if not parts:
+                return None
+            fields, format_args = [], None
+        elif index < len(args):
+            parts = self._parts(path, func, args[index], texts[index])
+            format_args = texts[index + 1:] if style == "printf" else None
+            fields = [] if style == "printf" else self._fields(path, func, call, index + 1, style)
+        else:
+            return None
+        entry = self._entry(path, func, call, parts, style, level,
+                            self._builder_fields(path, func, call, library) + fields, format_args)
+        entry.update({"library": library, "api": api, "confidence": "high" if resolved else "medium"})
+        return entry
+
+    def _wrapper_call(self, path: str, func: Dict[str, Any], call: Dict[str, Any],
+                      wrapper: Dict[str, Any]) -> Dict[str, Any]:
+        args, texts = call["args"], call["arg_texts"]
+        parts: List[Part] = []
+        for kind, text in wrapper["parts"]:
+            if kind != "param":
+                parts.append((kind, text))
+                continue
+            index = int(text)
+            if index < len(args):
+                parts.extend(self._parts(path, func, args[index], texts[index]))
+            else:
+                parts.append(("expr", wrapper["params"][index]))
+        style, index = wrapper["style"], wrapper.get("index")
+        format_args, fields = None, []
+        if index is not None and style == "printf":
+            format_args = texts[index + 1:]
+        elif index is not None and style in ("kv", "fields"):
+            fields = self._fields(path, func, call, index + 1, style)
+        entry = self._entry(path, func, call, parts, style, wrapper["level"], wrapper["fields"] + fields,
+                            format_args)
+        entry.update({"library": "project", "api": wrapper["name"], "confidence": "high"})
+        if wrapper.get("wraps"):
+            entry["wraps"] = wrapper["wraps"]
+        return entry
+
+    def _named_wrappers(self) -> Dict[Tuple[str, str], Dict[str, Any]]:
+        """Logging methods of the project's logger types: `func (l *Logger) Log(message string)`."""
+        wrappers = {}
+        for key, symbol in self.graph.functions.items():
+            if "." not in key[1] or symbol.get("container"):
+                continue
+            owner, name = key[1].split(".", 1)
+            split = _split(name, _WRAPPER_BASES, ("ln", "f", "w"))
+            if split is None or not re.search(r"(?i)log", owner):
+                continue
+            params = symbol.get("params", [])
+            message = next((k for k, p in enumerate(params) if p.get("type") == "string"), None)
+            if message is None:
+                continue
+            style = _PRINT_STYLES[split[1]]
+            if style in ("printf", "kv") and not params[-1].get("type", "").startswith("..."):
+                style = "print"
+            wrappers[key] = self._wrapper(key, symbol, [("param", str(message))], style, message,
+                                          _LEVEL_NAMES.get(split[0]), [], None)
+        return wrappers
+
+    def _wrapper(self, key: Tuple[str, str], symbol: Dict[str, Any], parts: List[Part], style: str,
+                 index: Optional[int], level: Optional[str], fields: List[Dict[str, Any]],
+                 wraps: Optional[str]) -> Dict[str, Any]:
+        node = self.graph.nodes[key]
+        return {"name": f"{node['package']}.{key[1]}", "path": node["path"], "line": node["line"],
+                "params": [p.get("name", "") for p in symbol.get("params", [])], "parts": parts,
+                "style": style, "index": index, "level": level, "fields": fields, "wraps": wraps}
+
+    def _forwarding(self, key: Tuple[str, str], entry: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+        """The wrapper a function is when its logging call's message takes one of its parameters."""
+        symbol = self.graph.functions.get(key)
+        if symbol is None:
+            return None
+        params = [p.get("name", "") for p in symbol.get("params", [])]
+        parts = entry["_parts"]
+        if entry["style"] == "printf" and entry.get("format_args"):
+            # log.Printf("%s: %s", prefix, msg): the arguments fill the verbs
+            filled: List[Part] = []
+            args = iter(entry["format_args"])
+            for kind, text in parts:
+                value = next(args, None) if kind == "verb" else None
+                filled.append(("expr", value.rstrip(".")) if value is not None else (kind, text))
+            parts = filled
+        types = {p.get("name", ""): p.get("type", "") for p in symbol.get("params", [])}
+        mapped = [("param", str(params.index(text)))
+                  if kind == "expr" and (types.get(text) == "string" or types.get(text, "").startswith("..."))
+                  else (kind, text) for kind, text in parts]
+        if symbol.get("statements", 0) > MAX_WRAPPER_STATEMENTS or not any(kind == "param" for kind, _ in mapped):
+            return None
+        index = int(mapped[0][1]) if len(mapped) == 1 else None
+        style = entry["style"] if index is not None else "print"
+        variadic = symbol.get("params") and symbol["params"][-1].get("type", "").startswith("...")
+        if style == "printf" and not variadic:
+            style = "print"
+        return self._wrapper(key, symbol, mapped, style, index, entry["level"],
+                             [f for f in entry.get("fields", []) if not f["value"].endswith("...")],
+                             entry["api"])
+
+    def find(self, text: Optional[str] = None, level: Optional[str] = None, include_tests: bool = False,
+             path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Every logging call, in file and line order, or with text the ones that
+        could have produced it, best match first.
+
+        Args:
+            text: A log line or part of one to find the call sites of
+            level: Only calls at this level
+            include_tests: Also list calls of _test.go files (entries get "in_test")
+            path: Only this file or package directory (and below)
+        """
+        if level is not None and level not in LOG_LEVELS:
+            raise ValueError(f"level must be one of {', '.join(LOG_LEVELS)}")
+        sites = {(e["path"], e["line"], e["column"]): e["callee"] for e in self.graph.edges if e["kind"] != "reference"}
+        library_calls, project_calls = [], []
+        for file_path, parsed in sorted(self.graph.project.files.items()):
+            for func in parsed.get("functions", []):
+                caller = (os.path.dirname(file_path),
+                          f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"])
+                for call in func.get("calls", []):
+                    callee = sites.get((file_path, call["line"], call["column"]))
+                    found = self._library_of(file_path, func, call, callee)
+                    if found is not None:
+                        library_calls.append((file_path, func, call, caller, found))
+                    elif callee is not None and callee[0] and callee != caller:
+                        project_calls.append((file_path, func, call, caller, callee))
+
+        entries = []
+        for file_path, func, call, caller, found in library_calls:
+            entry = self._library_call(file_path, func, call, found)
+            if entry is not None:
+                entries.append((caller, entry))
+        wrappers = self._named_wrappers()
+        done = set()
+        for _ in range(4):
+            added = False
+            for caller, entry in entries:
+                if caller not in wrappers and caller in self.graph.nodes:
+                    wrapper = self._forwarding(caller, entry)
+                    if wrapper is not None:
+                        wrappers[caller] = wrapper
+                        added = True
+            for file_path, func, call, caller, callee in project_calls:
+                site = (file_path, call["line"], call["column"])
+                if callee in wrappers and site not in done:
+                    done.add(site)
+                    entries.append((caller, self._wrapper_call(file_path, func, call, wrappers[callee])))
+                    added = True
+            if not added:
+                break
+
+        calls = []
+        for _, entry in entries:
+            parts = entry.pop("_parts")
+            if entry.get("in_test") and not include_tests:
+                continue
+            if path and entry["path"] != path and not entry["path"].startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            if level is not None and entry["level"] != level:
+                continue
+            if text is not None:
+                matched = match_template(parts, text.strip())
+                if matched is None:
+                    continue
+                entry["match"], entry["matched_chars"] = matched
+                keys = {f["key"] for f in entry.get("fields", [])}
+                in_text = [key for key in dict.fromkeys(_FRAGMENT_KEY.findall(text)) if key in keys]
+                if in_text:
+                    entry["matched_fields"] = in_text
+            calls.append(entry)
+        if text is not None:
+            order = {"whole": 0, "part": 1, "overlap": 2}
+            calls.sort(key=lambda e: (order[e["match"]], -len(e.get("matched_fields", ())), -e["matched_chars"],
+                                      e["path"], e["line"], e["column"]))
+        else:
+            calls.sort(key=lambda e: (e["path"], e["line"], e["column"]))
+
+        counts: Dict[str, Dict[str, int]] = {"library": {}, "level": {}}
+        for entry in calls:
+            counts["library"][entry["library"]] = counts["library"].get(entry["library"], 0) + 1
+            name = entry["level"] or "none"
+            counts["level"][name] = counts["level"].get(name, 0) + 1
+        result: Dict[str, Any] = {
+            "log_calls": calls,
+            "total_count": len(calls),
+            "counts": counts,
+            "wrappers": [{"name": w["name"], "path": w["path"], "line": w["line"], "wraps": w["wraps"]}
+                         for _, w in sorted(wrappers.items())],
+        }
+        if text is not None:
+            result["query"] = text
+        return result
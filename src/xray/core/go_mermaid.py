@@ -0,0 +1,190 @@
+"""Mermaid serializations of XRAY's Go graphs, for pasting into PRs and docs.
+
+call_graph_flowchart draws the same traversal find_callers / find_callees
+return as JSON; type_hierarchy_diagram draws find_implementations' result
+as a classDiagram with implements and embeds relationships. Nodes get
+generated ids and carry package-qualified names as quoted labels, with the
+characters Mermaid treats as syntax written as entity codes.
+"""
+
+import os
+import re
+from typing import Any, Callable, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph, GoProject
+
+# Characters that end or restructure a label ("[T any]", "net/http", "|")
+_ESCAPES = {
+    '"': "#quot;", "#": "#35;", ";": "#59;", "<": "#lt;", ">": "#gt;",
+    "[": "#91;", "]": "#93;", "{": "#123;", "}": "#125;", "(": "#40;",
+    ")": "#41;", "|": "#124;", "/": "#47;", "`": "#96;",
+}
+
+# Type parameters of a generic function or receiver, as written in a signature
+_FUNC_PARAMS = re.compile(r"^func\s+(?:\([^)]*\)\s*)?\w+(\[[^(]*\])\s*\(")
+_RECV_PARAMS = re.compile(r"^func\s*\(\s*(?:\w+\s+)?\*?\w+(\[[^\]]*\])\s*\)")
+
+# Arrow per call-graph edge kind
+_CALL_ARROWS = {"call": "-->", "go": "-->|go|", "defer": "-->|defer|", "reference": "-.->|ref|"}
+
+
+def escape_label(text: str) -> str:
+    """Make text safe inside a quoted Mermaid label."""
+    return "".join(_ESCAPES.get(char, char) for char in text)
+
+
+class _Nodes:
+    """Assigns stable ids (n0, n1, ...) in order of first appearance."""
+
+    def __init__(self):
+        self.ids: Dict[Any, str] = {}
+        self.labels: List[Tuple[str, str]] = []
+
+    def id(self, key: Any, label: str) -> str:
+        if key not in self.ids:
+            self.ids[key] = f"n{len(self.ids)}"
+            self.labels.append((self.ids[key], label))
+        return self.ids[key]
+
+
+def _call_label(graph: GoCallGraph, key: Tuple[str, str], external: bool) -> str:
+    if external:
+        return key[1]
+    node = graph.nodes[key]
+    name = node["name"]
+    signature = node.get("signature") or ""
+    receiver = _RECV_PARAMS.match(signature)
+    if receiver and "." in name:
+        owner, method = name.split(".", 1)
+        name = f"{owner}{receiver.group(1)}.{method}"
+    func = _FUNC_PARAMS.match(signature)
+    if func:
+        name += func.group(1)
+    return f"{node['package']}.{name}" if node.get("package") else name
+
+
+def _type_label(symbol: Dict[str, Any]) -> str:
+    if symbol.get("language") == "rust":
+        return "::".join(filter(None, [symbol["package"], symbol["name"]]))
+    if symbol.get("language") == "java":
+        return ".".join(filter(None, [symbol["package"], symbol["name"]]))
+    params = symbol.get("typeParams")
+    if not params:
+        return f"{symbol['package']}.{symbol['name']}"
+    written = ", ".join(f"{p['name']} {p['constraint']}".strip() for p in params)
+    return f"{symbol['package']}.{symbol['name']}[{written}]"
+
+
+def call_graph_flowchart(graph: GoCallGraph, start: Tuple[str, str], depth: int, forward: bool,
+                         keep: Optional[Callable[[str], bool]] = None) -> Dict[str, Any]:
+    """
+    Flowchart of the callees (forward) or callers of start, arrows pointing
+    from caller to callee. Several call sites between the same two functions
+    are drawn as one edge per kind of use. keep filters the walk by file
+    (see GoCallGraph.walk).
+    """
+    nodes = _Nodes()
+    root = nodes.id(start, _call_label(graph, start, False))
+    external_ids = []
+    edges = []
+    seen = set()
+    for _, key, other, edge in graph.walk(start, depth, forward, keep):
+        external = forward and edge["external"]
+        other_id = nodes.id(other, _call_label(graph, other, external))
+        if external and other_id not in external_ids:
+            external_ids.append(other_id)
+        key_id = nodes.ids[key]
+        caller, callee = (key_id, other_id) if forward else (other_id, key_id)
+        marker = (caller, callee, edge["kind"])
+        if marker not in seen:
+            seen.add(marker)
+            edges.append(f"    {caller} {_CALL_ARROWS.get(edge['kind'], '-->')} {callee}")
+
+    lines = ["flowchart LR"]
+    lines += [f'    {node_id}["{escape_label(label)}"]' for node_id, label in nodes.labels]
+    lines += edges
+    lines.append("    classDef root stroke-width:3px")
+    lines.append(f"    class {root} root")
+    if external_ids:
+        lines.append("    classDef external stroke-dasharray:4 3")
+        lines.append(f"    class {','.join(external_ids)} external")
+    return {"diagram": "\n".join(lines), "nodes": len(nodes.labels), "edges": len(edges)}
+
+
+def type_hierarchy_diagram(project: GoProject, result: Dict[str, Any], depth: int = 1) -> Dict[str, Any]:
+    """
+    classDiagram of a find_implementations result: the interface (or type)
+    asked about, the types implementing it (or interfaces it satisfies),
+    partial matches as dashed dependencies, and embedded types followed
+    depth levels out from every drawn type. project may also be an RsProject,
+    whose supertraits are drawn as embeds and whose traits of other crates
+    come from the result entries themselves, or a JavaProject, whose
+    superinterfaces are drawn the same way.
+    """
+    nodes = _Nodes()
+    kinds: Dict[str, str] = {}
+    members: Dict[str, List[str]] = {}
+    relations: List[str] = []
+
+    def type_id(key: Tuple[str, str], entry: Optional[Dict[str, Any]] = None) -> str:
+        symbol = project.types.get(key) or entry
+        node_id = nodes.id(key, _type_label(symbol))
+        kinds[node_id] = symbol.get("type", "external")
+        return node_id
+
+    def key_of(entry: Dict[str, Any]) -> Tuple[str, str]:
+        return (os.path.dirname(entry["path"]), entry["name"])
+
+    is_interface = "interface" in result
+    root = result["interface"] if is_interface else result["type"]
+    root_key = key_of(root)
+    root_id = type_id(root_key)
+    if root.get("methods"):
+        members[root_id] = [f"+{name}()" for name in root["methods"]]
+
+    matches = result["implementations" if is_interface else "interfaces"]
+    for entry in matches + result.get("partial", []):
+        other = type_id(key_of(entry), entry)
+        concrete, iface = (other, root_id) if is_interface else (root_id, other)
+        if "satisfied_by" in entry:
+            via = " : pointer receiver" if entry.get("pointer_receiver_methods") else ""
+            relations.append(f"    {concrete} ..|> {iface}{via}")
+        else:
+            missing = len(entry.get("missing", [])) + len(entry.get("mismatched", []))
+            relations.append(f"    {concrete} ..> {iface} : partial, {missing} missing")
+
+    level = list(nodes.ids)
+    drawn = set(level)
+    for _ in range(max(depth, 0)):
+        next_level = []
+        for key in level:
+            outer = nodes.ids[key]
+            for written, embedded in project.embeds_of(key):
+                if embedded is None:
+                    inner = nodes.id(("", written), written.lstrip("*"))
+                    kinds.setdefault(inner, "external")
+                else:
+                    inner = type_id(embedded)
+                    if embedded not in drawn:
+                        drawn.add(embedded)
+                        next_level.append(embedded)
+                if project.types[key]["type"] in ("interface", "trait"):
+                    relation = "extends" if project.types[key].get("language") == "java" else "embeds"
+                    relations.append(f"    {inner} <|-- {outer} : {relation}")
+                else:
+                    relations.append(f"    {outer} *-- {inner} : embeds")
+        level = next_level
+
+    lines = ["classDiagram"]
+    for node_id, label in nodes.labels:
+        body = members.get(node_id)
+        if body:
+            lines.append(f'    class {node_id}["{escape_label(label)}"] {{')
+            lines += [f"        {member}" for member in body]
+            lines.append("    }")
+        else:
+            lines.append(f'    class {node_id}["{escape_label(label)}"]')
+        if kinds.get(node_id) in ("interface", "trait", "external"):
+            lines.append(f"    <<{kinds[node_id]}>> {node_id}")
+    lines += relations
+    return {"diagram": "\n".join(lines), "nodes": len(nodes.labels), "edges": len(relations)}
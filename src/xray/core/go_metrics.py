@@ -0,0 +1,81 @@
+"""Size and complexity metrics of Go functions - the worst offenders first.
+
+The parser measures every function and method with a body in the same
+pass that extracts its symbol, so the numbers are on the symbol records
+and cached with them:
+
+    loc               lines of the declaration holding code; blank and
+                      comment-only lines are left out
+    statements        statement terminators in the body
+    complexity        cyclomatic estimate: 1 + if, for, case, && and ||
+    max_nesting       deepest if/else/for/switch/select nesting
+    param_count       parameters, one per name (`a, b int` is two)
+    result_count      results, one per name or unnamed type
+    distinct_callees  different call expressions in the body, builtins
+                      and conversions to basic types left out
+
+rank_functions lists them with minimum thresholds, worst first.
+"""
+
+import os
+from typing import Any, Dict, Optional
+
+from xray.core.go_analysis import GoProject
+
+METRICS = ("complexity", "loc", "statements", "max_nesting", "param_count", "result_count", "distinct_callees")
+
+
+def rank_functions(project: GoProject, sort_by: str = "complexity", minimums: Optional[Dict[str, int]] = None,
+                   include_tests: bool = False, path: Optional[str] = None) -> Dict[str, Any]:
+    """
+    The measured functions and methods of a project, ranked.
+
+    Args:
+        project: The Go project
+        sort_by: One of METRICS; ties go by path and line
+        minimums: metric -> smallest value listed; a function must reach every one
+        include_tests: Also list functions of _test.go files
+        path: Only this file or directory (and below)
+
+    Returns:
+        {"functions": [...], "total_count", "measured", "sort_by",
+         "thresholds", "max": {metric: highest value among the measured}}
+    """
+    if sort_by not in METRICS:
+        raise ValueError(f"sort_by must be one of {', '.join(METRICS)}")
+    minimums = {k: v for k, v in (minimums or {}).items() if v is not None}
+    unknown = sorted(set(minimums) - set(METRICS))
+    if unknown:
+        raise ValueError(f"Unknown metric(s) {', '.join(unknown)}; expected {', '.join(METRICS)}")
+
+    measured = []
+    for file_path, parsed in sorted(project.files.items()):
+        if file_path.endswith("_test.go") and not include_tests:
+            continue
+        if path and file_path != path and not file_path.startswith(path.rstrip(os.sep) + os.sep):
+            continue
+        for symbol in parsed["symbols"]:
+            if symbol["type"] not in ("function", "method") or "complexity" not in symbol:
+                continue
+            receiver = (symbol.get("receiver") or {}).get("type")
+            row = {
+                "name": f"{receiver}.{symbol['name']}" if receiver else symbol["name"],
+                "type": symbol["type"],
+                "package": parsed.get("package", ""),
+                "path": file_path,
+                "start_line": symbol["start_line"],
+                "end_line": symbol["end_line"],
+            }
+            row.update({metric: symbol.get(metric, 0) for metric in METRICS})
+            measured.append(row)
+
+    rows = [r for r in measured if all(r[metric] >= value for metric, value in minimums.items())]
+    rows.sort(key=lambda r: (-r[sort_by], r["path"], r["start_line"]))
+    return {
+        "functions": rows,
+        "total_count": len(rows),
+        "measured": len(measured),
+        "sort_by": sort_by,
+        "thresholds": minimums,
+        "max": {metric: max((r[metric] for r in measured), default=0) for metric in METRICS},
+    }
@@ -0,0 +1,313 @@
+"""Go mocks gone stale: mocks whose interface changed since they were generated.
+
+Each mock MockFinder links to a project interface (see go_mocks.py) has its
+method set compared with the interface's:
+
+    missing     interface methods the mock lacks - the interface grew
+    extra       mock methods the interface no longer has - it shrank; not
+                looked for in handwritten fakes, which keep helpers of their
+                own, nor are the helpers generators add (EXPECT, moq's
+                <Method>Calls)
+    mismatched  methods whose parameter or result types differ
+
+The //go:generate directives running mockgen, mockery or moq (directly,
+through `go run` or `go tool`) say which interfaces should have a mock, in
+which file and under which name:
+
+    mockgen -source=store.go -destination=mocks/mock_store.go    every interface of store.go, as MockStore...
+    mockgen -destination=mocks/store.go example.com/shop/store Store,Lister
+    mockery --name=Store --output=mocks --case=underscore        mocks/store.go, struct Store
+    mockery --name=Store --inpackage                             mock_Store.go, struct MockStore
+    moq -out store_mock.go . Store                               StoreMock
+
+Paths are relative to the directive's directory, where go generate runs it.
+A directive whose file does not exist is a "missing_mock_file", a generated
+file without the mock of one of its interfaces a "missing_mock", and a mock
+found in a directive's file is checked against the directive's interface
+and names it under "generated_by", so regenerating it is one command.
+"""
+
+import os
+import re
+import shlex
+from typing import Any, Callable, Collection, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoProject
+from xray.core.go_mocks import MockFinder
+from xray.core.source_text import source_lines
+
+Key = Tuple[str, str]
+
+# Generator helpers on the mock type itself, not mocked methods
+_HELPERS = {"gomock": {"EXPECT", "ISGOMOCK"}, "testify": {"EXPECT"}}
+_GENERATE = re.compile(r"^\s*//go:generate\s+(.+)$")
+# Flags of each generator taking a value, for `-flag value` written apart
+_VALUE_FLAGS = {
+    "mockgen": {"source", "destination", "package", "imports", "aux_files", "build_flags", "mock_names",
+                "self_package", "copyright_file", "exclude_interfaces", "model"},
+    "mockery": {"name", "dir", "output", "outpkg", "filename", "structname", "case", "tags", "srcpkg",
+                "config", "log-level", "boilerplate-file", "mock-build-tags", "note", "replace-type"},
+    "moq": {"out", "pkg", "fmt"},
+}
+_GENERATORS = {"mockgen", "mockery", "moq"}
+
+
+def _generator(words: List[str]) -> Tuple[Optional[str], List[str]]:
+    """The generator a go:generate command runs and its arguments: `mockgen ...`, `go run .../mockgen@v1 ...`."""
+    if words[:2] in (["go", "run"], ["go", "tool"]) and len(words) > 2:
+        program, rest = words[2], words[3:]
+    elif words:
+        program, rest = words[0], words[1:]
+    else:
+        return None, []
+    name = [part for part in program.split("@", 1)[0].split("/") if not re.match(r"^v\d+$", part)]
+    name = name[-1] if name else ""
+    return (name, rest) if name in _GENERATORS else (None, [])
+
+
+def _flags(generator: str, args: List[str]) -> Tuple[Dict[str, str], List[str]]:
+    """The flags (-name=value, -name value, --switch) and positional arguments of a command."""
+    flags: Dict[str, str] = {}
+    positional: List[str] = []
+    i = 0
+    while i < len(args):
+        arg = args[i]
+        if arg.startswith("-") and len(arg) > 1:
+            name, sep, value = arg.lstrip("-").partition("=")
+            if not sep:
+                if name not in _VALUE_FLAGS[generator] or i + 1 >= len(args):
+                    value = "true"
+                else:
+                    i += 1
+                    value = args[i]
+            flags[name] = value
+        else:
+            positional.append(arg)
+        i += 1
+    return flags, positional
+
+
+def _snake(name: str) -> str:
+    """mockery's --case=underscore file name: UserStore -> user_store, HTTPClient -> http_client."""
+    return re.sub(r"(?<=[a-z0-9])(?=[A-Z])|(?<=[A-Z])(?=[A-Z][a-z])", "_", name).lower()
+
+
+def _moq_helper(name: str, methods: Collection[str]) -> bool:
+    """moq's call recorders: GetCalls, and with -with-resets ResetGetCalls and ResetCalls."""
+    base = name[len("Reset"):] if name.startswith("Reset") else name
+    return base == "Calls" or base.endswith("Calls") and base[:-len("Calls")] in methods
+
+
+def _switch(flags: Dict[str, str], name: str) -> bool:
+    return flags.get(name, "false").lower() not in ("false", "0")
+
+
+class MockCheck:
+    """Compares Go mocks with their interfaces, and go:generate directives with the mocks they generate."""
+
+    def __init__(self, project: GoProject, read: Callable[[str], Optional[str]]):
+        self.project = project
+        self.read = read
+        self.finder = MockFinder(project)
+
+    def _interfaces_in(self, pkg_dir: str, path: Optional[str] = None) -> List[str]:
+        """The names of the interfaces of a package, or of one of its files."""
+        return sorted(name for (directory, name), symbol in self.project.types.items()
+                      if directory == pkg_dir and symbol["type"] == "interface" and not symbol.get("alias")
+                      and (path is None or symbol["path"] == path))
+
+    def _planned(self, path: str, command: str) -> Optional[Dict[str, Any]]:
+        """
+        What a go:generate directive generates: {"generator", "file", "mocks":
+        [(interface key or None, interface name, mock type name)]}, or None
+        for a command that is no mock generator or writes to stdout.
+        """
+        try:
+            words = shlex.split(command)
+        except ValueError:
+            return None
+        generator, args = _generator(words)
+        if generator is None:
+            return None
+        flags, positional = _flags(generator, args)
+        here = os.path.dirname(path)
+        mocks: List[Tuple[Optional[Key], str, str]] = []
+        if generator == "mockgen":
+            if "destination" not in flags:
+                return None
+            target = os.path.normpath(os.path.join(here, flags["destination"]))
+            names = dict(pair.split("=", 1) for pair in flags.get("mock_names", "").split(",") if "=" in pair)
+            if "source" in flags:
+                source = os.path.normpath(os.path.join(here, flags["source"]))
+                pkg_dir = os.path.dirname(source)
+                excluded = set(filter(None, flags.get("exclude_interfaces", "").split(",")))
+                interfaces = [n for n in self._interfaces_in(pkg_dir, source) if n not in excluded]
+            elif len(positional) >= 2:
+                pkg_dir = here if positional[0] == "." else self.project.import_dir(positional[0], path)
+                interfaces = [n for n in positional[1].split(",") if n]
+            else:
+                return None
+            for name in interfaces:
+                mocks.append(((pkg_dir, name) if pkg_dir else None, name, names.get(name, "Mock" + name)))
+            return {"generator": generator, "file": target, "mocks": mocks}
+        if generator == "mockery":
+            pkg_dir = os.path.normpath(os.path.join(here, flags.get("dir", ".")))
+            if _switch(flags, "all"):
+                interfaces = self._interfaces_in(pkg_dir)
+            elif flags.get("name") and re.match(r"^[A-Za-z_]\w*$", flags["name"]):
+                interfaces = [flags["name"]]
+            else:
+                # A pattern, or the .mockery.yaml packages: no single file to expect
+                return None
+            inpackage = _switch(flags, "inpackage")
+            output = os.path.normpath(os.path.join(here, flags.get("output", "." if inpackage else "./mocks")))
+            plans = []
+            for name in interfaces:
+                stem = _snake(name) if flags.get("case") in ("underscore", "snake") else name
+                filename = flags.get("filename") or \
+                    ("mock_" if inpackage else "") + stem + ("_test" if _switch(flags, "testonly") else "") + ".go"
+                struct = flags.get("structname") or ("Mock" + name if inpackage else name)
+                plans.append({"generator": generator, "file": os.path.join(output, filename),
+                              "mocks": [((pkg_dir, name), name, struct)]})
+            # mockery writes one file per interface; --all is checked interface by interface
+            return plans[0] if len(plans) == 1 else {"generator": generator, "files": plans} if plans else None
+        if "out" not in flags or len(positional) < 2:
+            return None
+        pkg_dir = os.path.normpath(os.path.join(here, positional[0]))
+        for spec in positional[1:]:
+            name, _, struct = spec.partition(":")
+            mocks.append(((pkg_dir, name), name, struct or name + "Mock"))
+        return {"generator": generator, "file": os.path.normpath(os.path.join(here, flags["out"])), "mocks": mocks}
+
+    def directives(self) -> List[Dict[str, Any]]:
+        """The mock-generating go:generate directives of the project, one entry per file they write."""
+        found = []
+        for path in sorted(self.project.files):
+            content = self.read(path)
+            if not content or "go:generate" not in content:
+                continue
+            for number, text in enumerate(source_lines(content), 1):
+                match = _GENERATE.match(text)
+                if not match:
+                    continue
+                planned = self._planned(path, match.group(1).strip())
+                if planned is None:
+                    continue
+                for plan in planned.get("files", [planned]):
+                    found.append({**plan, "path": path, "line": number, "command": match.group(1).strip()})
+        return found
+
+    def _symbol(self, key: Key) -> Dict[str, Any]:
+        symbol = self.project.types[key]
+        return {"name": key[1], "package": symbol["package"], "path": symbol["path"],
+                "start_line": symbol["start_line"]}
+
+    def _delta(self, iface: Key, mock: Key, framework: str) -> Dict[str, List[Dict[str, Any]]]:
+        """The methods the mock lacks, has beyond the interface, and has with other types."""
+        required, _ = self.project.interface_method_set(*iface)
+        own = {m["name"]: m for m in self.project.methods.get(mock, [])}
+        match = self.project.match_interface(iface, mock) or {"missing": sorted(required)}
+        delta: Dict[str, List[Dict[str, Any]]] = {"missing": [], "extra": [], "mismatched": []}
+        for name in match.get("missing", []):
+            spec = required[name]
+            delta["missing"].append({"method": name, "signature": spec["signature"], "line": spec["start_line"]})
+        for mismatch in match.get("mismatched", []):
+            method = own.get(mismatch["method"])
+            delta["mismatched"].append({**mismatch, "interface_line": required[mismatch["method"]]["start_line"],
+                                        "line": method["start_line"] if method else None})
+        if framework != "handwritten":
+            helpers = _HELPERS.get(framework, set())
+            for name, method in sorted(own.items()):
+                if name in required or name in helpers:
+                    continue
+                if framework == "moq" and _moq_helper(name, set(own) | set(required)):
+                    continue
+                delta["extra"].append({"method": name, "signature": method["signature"],
+                                       "line": method["start_line"]})
+        return delta
+
+    def check(self, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Stale mocks and missing generated mock files, the most methods off first.
+
+        Args:
+            path: Only interfaces, mocks or directives in this file or directory (and below)
+        """
+        def inside(file_path: str) -> bool:
+            return not path or file_path == path or file_path.startswith(path.rstrip(os.sep) + os.sep)
+
+        mocks = self.finder.mocks()
+        by_file: Dict[str, List[Key]] = {}
+        for key in mocks:
+            by_file.setdefault(self.project.types[key]["path"], []).append(key)
+
+        findings: List[Dict[str, Any]] = []
+        # mock key -> (interface key, directive) a go:generate directive says it was made from
+        generated: Dict[Key, Tuple[Optional[Key], Dict[str, Any]]] = {}
+        for directive in self.directives():
+            source = {"path": directive["path"], "line": directive["line"], "command": directive["command"]}
+            target = directive["file"]
+            wanted = directive["mocks"]
+            if not any(inside(p) for p in [directive["path"], target] + [k[0] for k, _, _ in wanted if k]):
+                continue
+            if not os.path.exists(target):
+                findings.append({"kind": "missing_mock_file", "generator": directive["generator"],
+                                 "expected_path": target, "interfaces": [n for _, n, _ in wanted],
+                                 "generated_by": source})
+                continue
+            present = {key[1]: key for key in by_file.get(target, [])}
+            if target not in self.project.files:
+                # Excluded from the index: the file is there, its content unknown
+                continue
+            for iface, name, struct in wanted:
+                if struct in present:
+                    generated[present[struct]] = (iface if iface in self.project.types else None, source)
+                elif iface in self.project.types:
+                    findings.append({"kind": "missing_mock", "generator": directive["generator"],
+                                     "interface": self._symbol(iface), "expected_mock": struct,
+                                     "mock_file": target, "generated_by": source})
+
+        checked = 0
+        for key, record in sorted(mocks.items()):
+            if record.get("recorder_for"):
+                continue
+            iface, source = generated.get(key, (record.get("interface"), None))
+            if iface is None or iface not in self.project.types:
+                continue
+            mock = self.project.types[key]
+            if not inside(mock["path"]) and not inside(self.project.types[iface]["path"]):
+                continue
+            checked += 1
+            delta = self._delta(iface, key, record["framework"])
+            if not any(delta.values()):
+                continue
+            finding: Dict[str, Any] = {
+                "kind": "stale_mock",
+                "interface": self._symbol(iface),
+                "mock": {**self._symbol(key), "framework": record["framework"],
+                         "linked_by": "go:generate" if source else record.get("linked_by")},
+                "mock_file": mock["path"],
+            }
+            finding.update({field: items for field, items in delta.items() if items})
+            finding["delta"] = sum(len(items) for items in delta.values())
+            if source:
+                finding["generated_by"] = source
+            findings.append(finding)
+
+        order = {"stale_mock": 0, "missing_mock": 1, "missing_mock_file": 2}
+        findings.sort(key=lambda f: (order[f["kind"]], -f.get("delta", 0),
+                                     f.get("mock_file") or f.get("expected_path"),
+                                     (f.get("interface") or {}).get("name", "")))
+        counts: Dict[str, int] = {}
+        for finding in findings:
+            counts[finding["kind"]] = counts.get(finding["kind"], 0) + 1
+        result: Dict[str, Any] = {
+            "findings": findings,
+            "total_count": len(findings),
+            "counts": counts,
+            "checked_mocks": checked,
+        }
+        if findings:
+            result["message"] = ", ".join(f"{n} {kind.replace('_', ' ')}{'s' if n > 1 else ''}"
+                                          for kind, n in sorted(counts.items(), key=lambda c: order[c[0]]))
+        return result
@@ -0,0 +1,222 @@
+"""Mocks and stubs of Go interfaces, and the interfaces they stand in for.
+
+A type is a mock when it has the shape a mock generator gives it:
+- "gomock" (mockgen): a struct holding a *gomock.Controller; its
+  ...MockRecorder companion is a mock too, with "recorder_for"
+- "testify" (mockery, or written by hand): a struct embedding mock.Mock
+- "moq": a struct of <Method>Func func fields next to a calls struct
+
+or, in a file that looks like mocks - named *_mock.go or mock_*.go, in a
+mock/mocks/fake/fakes directory, or headed "Code generated by MockGen",
+mockery or moq - when its name starts or ends with Mock, Fake or Stub
+("handwritten").
+
+The interface a mock stands for is the one its generator's doc comment
+names ("MockStore is a mock of Store interface.", "Store is a mock type for
+the Store type", "StoreMock is a mock implementation of store.Store."), else
+the one named as the mock without its Mock/Fake/Stub affix, else the project
+interface with the most methods the mock fully implements. A name is looked
+up in the mock's package and the packages its file imports first, then
+across the project, preferring an interface the mock implements.
+"""
+
+import os
+import re
+from pathlib import Path
+from typing import Any, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoProject
+from xray.core.go_helpers import file_imports
+from xray.core.ignore import generated_header
+
+_GOMOCK_PACKAGES = {"github.com/golang/mock/gomock", "go.uber.org/mock/gomock"}
+_TESTIFY_MOCK = "github.com/stretchr/testify/mock"
+
+_MOCK_HEADERS = [re.compile(r"Code generated by (MockGen|mockery|moq)\b")]
+_MOCK_FILE = re.compile(r"(^mock_.*|.*_mocks?)\.go$|(^|_)(mock|fake|stub)s?(_test)?\.go$")
+_MOCK_DIRS = {"mock", "mocks", "fake", "fakes", "mockery"}
+_MOCK_NAME = re.compile(r"^(?:Mock|Fake|Stub|mock|fake|stub)_?(?P<prefixed>[A-Za-z]\w*)$|^(?P<suffixed>\w+?)_?(?:Mock|Fake|Stub)$")
+_MOCK_DOC = [
+    # mockgen
+    re.compile(r"is a mock of (?P<name>[\w.]+) interface"),
+    # mockery
+    re.compile(r"is a mock type for the (?P<name>[\w.]+) type"),
+    # moq
+    re.compile(r"is a mock implementation of (?P<name>[\w.]+)"),
+    re.compile(r"(?:mocks|fakes|stubs) (?:the )?(?P<name>[\w.]+) interface"),
+]
+
+
+def mock_file(path: str) -> bool:
+    """Whether a Go file looks like it holds mocks, by its name, directory or generator header."""
+    if _MOCK_FILE.match(os.path.basename(path)) or os.path.basename(os.path.dirname(path)) in _MOCK_DIRS:
+        return True
+    return generated_header(Path(path), _MOCK_HEADERS) is not None
+
+
+class MockFinder:
+    """Detects the mock types of a project and links them to their interfaces."""
+
+    def __init__(self, project: GoProject):
+        self.project = project
+        self._mocks: Optional[Dict[Tuple[str, str], Dict[str, Any]]] = None
+
+    def _framework(self, key: Tuple[str, str], imports: Dict[str, str]) -> Optional[str]:
+        """The generator whose shape a struct has, or None."""
+        fields = self.project.fields.get(key, [])
+        for field in fields:
+            qualifier, _, name = field["field_type"].lstrip("*").partition(".")
+            if name == "Controller" and imports.get(qualifier) in _GOMOCK_PACKAGES:
+                return "gomock"
+            if field.get("embedded") and name == "Mock" and imports.get(qualifier) == _TESTIFY_MOCK:
+                return "testify"
+        names = {f["name"] for f in fields}
+        funcs = [f for f in fields if f["name"].endswith("Func") and f["field_type"].startswith("func(")]
+        if funcs and "calls" in names:
+            return "moq"
+        return None
+
+    def _interface_named(self, name: str, mock_key: Tuple[str, str]) -> Optional[Tuple[str, str]]:
+        """A project interface of a (possibly qualified) name, the nearest to the mock and implemented first."""
+        key = self.project.resolve_type_ref(mock_key[0], name)
+        if key is not None and self.project.types[key]["type"] == "interface":
+            return key
+        base = name.rsplit(".", 1)[-1]
+        found = [(os.path.dirname(t["path"]), t["name"]) for t in self.project.find_types(base)
+                 if t["type"] == "interface" and not t.get("alias")]
+        if not found:
+            return None
+        imported = set()
+        for path in self.project.packages.get(mock_key[0], {}).get("files", []):
+            for imp in self.project.files[path].get("imports", []):
+                pkg_dir = self.project.import_dir(imp["path"], path)
+                if pkg_dir:
+                    imported.add(pkg_dir)
+
+        def rank(key: Tuple[str, str]) -> Tuple[bool, bool, str]:
+            match = self.project.match_interface(key, mock_key)
+            return (not (match and "satisfied_by" in match), key[0] not in imported, key[0])
+        return min(found, key=rank)
+
+    def _implemented(self, mock_key: Tuple[str, str],
+                     mocks: Dict[Tuple[str, str], Dict[str, Any]]) -> Optional[Tuple[str, str]]:
+        """The project interface (not itself a mock) with the most methods the mock fully implements."""
+        best, size = None, 0
+        for key, symbol in sorted(self.project.types.items()):
+            if symbol["type"] != "interface" or symbol.get("alias") or key in mocks:
+                continue
+            match = self.project.match_interface(key, mock_key)
+            if match and "satisfied_by" in match and len(match["matched"]) > size:
+                best, size = key, len(match["matched"])
+        return best
+
+    def mocks(self) -> Dict[Tuple[str, str], Dict[str, Any]]:
+        """(package dir, type name) -> {"framework", "interface" (key or None), "linked_by", "recorder_for"?}."""
+        if self._mocks is not None:
+            return self._mocks
+        mocks: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        mock_files: Dict[str, bool] = {}
+        for key, symbol in sorted(self.project.types.items()):
+            if symbol.get("alias"):
+                continue
+            path = symbol["path"]
+            imports = file_imports(self.project.files[path])
+            framework = self._framework(key, imports) if symbol["type"] == "struct" else None
+            if framework is None and symbol["type"] != "interface" and _MOCK_NAME.match(key[1]):
+                if path not in mock_files:
+                    mock_files[path] = mock_file(path)
+                if mock_files[path]:
+                    framework = "handwritten"
+            if framework is not None:
+                mocks[key] = {"framework": framework}
+
+        for key in [k for k, record in mocks.items() if record["framework"] == "gomock"]:
+            recorder = (key[0], key[1] + "MockRecorder")
+            if recorder in self.project.types:
+                mocks[recorder] = {"framework": "gomock", "recorder_for": key[1], "interface": None}
+
+        for key, record in mocks.items():
+            if record.get("recorder_for"):
+                continue
+            symbol = self.project.types[key]
+            target, linked_by = None, None
+            for pattern in _MOCK_DOC:
+                found = pattern.search(symbol.get("doc", ""))
+                if found:
+                    target, linked_by = self._interface_named(found.group("name"), key), "doc"
+                    break
+            name = _MOCK_NAME.match(key[1])
+            if target is None and name:
+                stem = name.group("prefixed") or name.group("suffixed")
+                target, linked_by = self._interface_named(stem, key), "name"
+                if target is None and stem[:1].islower():
+                    target = self._interface_named(stem[:1].upper() + stem[1:], key)
+            if target is None:
+                target, linked_by = self._implemented(key, mocks), "methods"
+            record["interface"] = target
+            if target is not None:
+                record["linked_by"] = linked_by
+        self._mocks = mocks
+        return self._mocks
+
+    def describe(self, key: Tuple[str, str]) -> Dict[str, Any]:
+        """The mock tags of a type for a result entry: {"mock": True, "framework", "mocks"?, ...}."""
+        record = self.mocks()[key]
+        tags: Dict[str, Any] = {"mock": True, "framework": record["framework"]}
+        if record.get("interface"):
+            iface = self.project.types[record["interface"]]
+            tags["mocks"] = {"name": iface["name"], "package": iface["package"], "path": iface["path"],
+                             "start_line": iface["start_line"]}
+        if record.get("recorder_for"):
+            tags["recorder_for"] = record["recorder_for"]
+        return tags
+
+    def list(self, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        The project's interfaces with and without mocks.
+
+        Args:
+            path: Only interfaces of this file or package directory (and below)
+        """
+        mocks = self.mocks()
+        by_interface: Dict[Tuple[str, str], List[Dict[str, Any]]] = {}
+        unlinked = []
+        for key, record in sorted(mocks.items()):
+            if record.get("recorder_for"):
+                continue
+            symbol = self.project.types[key]
+            entry = {"name": key[1], "package": symbol["package"], "path": symbol["path"],
+                     "start_line": symbol["start_line"], "framework": record["framework"]}
+            if record["interface"] is None:
+                unlinked.append(entry)
+                continue
+            entry["linked_by"] = record["linked_by"]
+            by_interface.setdefault(record["interface"], []).append(entry)
+
+        mocked, unmocked = [], []
+        for key, symbol in sorted(self.project.types.items()):
+            if symbol["type"] != "interface" or symbol.get("alias") or key in mocks:
+                continue
+            if path and symbol["path"] != path and not symbol["path"].startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            methods, _ = self.project.interface_method_set(*key)
+            if not methods:
+                continue
+            entry = {"name": key[1], "package": symbol["package"], "path": symbol["path"],
+                     "start_line": symbol["start_line"], "methods": len(methods)}
+            if key in by_interface:
+                entry["mocks"] = by_interface[key]
+                mocked.append(entry)
+            elif mock_file(symbol["path"]):
+                continue
+            else:
+                unmocked.append(entry)
+        result: Dict[str, Any] = {
+            "mocked": mocked,
+            "unmocked": unmocked,
+            "total_count": len(mocked) + len(unmocked),
+            "mock_count": sum(len(e["mocks"]) for e in mocked),
+        }
+        if unlinked:
+            result["unlinked_mocks"] = unlinked
+        return result
@@ -0,0 +1,184 @@
+"""The Go modules of a project - go.mod files and the go.work workspace.
+
+A package's import path is the path of the module it belongs to (the
+nearest go.mod above it) followed by its directory relative to the module
+root, so `lib/strutil` under a module declared `example.com/lib` in lib/go.mod
+is "example.com/lib/strutil" whatever directory the repository is checked
+out in. Imports are resolved the same way round: "example.com/lib/strutil"
+is looked for in the directory of the module whose path is the longest
+prefix of it, or in the local directory a replace directive points the
+module at (`replace example.com/lib => ../lib`).
+
+With a go.work at the project root, the modules it uses are the
+"workspace" modules. Other go.mod files found in the tree are "separate"
+modules - or, without a go.work, every go.mod but the root's, which is
+"main".
+"""
+
+import os
+from typing import Any, Callable, Dict, Iterable, List, Optional, Tuple
+
+MODULE_KINDS = ("main", "workspace", "separate")
+
+
+def _local(target: str) -> bool:
+    """Whether a replace target is a directory rather than a module path."""
+    return target.startswith(("./", "../", "/")) or target in (".", "..")
+
+
+class GoModules:
+    """Module paths, directories and local replacements of a project."""
+
+    def __init__(self, root: str, modules: List[Dict[str, Any]], workspace: Optional[Dict[str, Any]] = None):
+        self.root = root
+        # Deepest directories first, so module_of finds the nearest go.mod
+        self.modules = sorted(modules, key=lambda m: (-len(m["dir"]), m["dir"]))
+        self.workspace = workspace
+        # module path -> directory; a module's own directory is visible from anywhere
+        self._module_dirs = {m["path"]: m["dir"] for m in reversed(self.modules) if m["path"]}
+        # Scope (a module directory, None for no importer) -> (prefix, directory), longest first
+        self._scopes: Dict[Optional[str], List[Tuple[str, str]]] = {}
+
+    @classmethod
+    def discover(cls, root: str, package_dirs: Iterable[str],
+                 read: Callable[[str], Optional[Dict[str, Any]]],
+                 read_work: Callable[[str], Optional[Dict[str, Any]]]) -> "GoModules":
+        """
+        Find the modules of a project: those a go.work at root uses, and the
+        go.mod files at or above every package directory (up to root).
+
+        Args:
+            root: Project root
+            package_dirs: Directories holding Go files
+            read: Parses a go.mod path (parse_go_mod), None if unreadable
+            read_work: Parses a go.work path (parse_go_work), None if unreadable
+        """
+        workspace = read_work(os.path.join(root, "go.work")) if os.path.isfile(os.path.join(root, "go.work")) else None
+        used = {os.path.normpath(os.path.join(root, d)) for d in workspace["use"]} if workspace else set()
+        found = set(d for d in used if os.path.isfile(os.path.join(d, "go.mod")))
+        checked: Dict[str, bool] = {}
+        for pkg_dir in package_dirs:
+            directory = pkg_dir
+            while directory not in checked:
+                checked[directory] = os.path.isfile(os.path.join(directory, "go.mod"))
+                if checked[directory]:
+                    found.add(directory)
+                if directory == root or os.path.dirname(directory) == directory or \
+                        not directory.startswith(root):
+                    break
+                directory = os.path.dirname(directory)
+
+        modules = []
+        for directory in sorted(found):
+            parsed = read(os.path.join(directory, "go.mod"))
+            if parsed is None:
+                continue
+            if workspace is not None:
+                kind = "workspace" if directory in used else "separate"
+            else:
+                kind = "main" if directory == root else "separate"
+            modules.append({
+                "path": parsed["module"],
+                "dir": directory,
+                "go": parsed["go"],
+                "kind": kind,
+                "require": parsed["require"],
+                "replace": parsed["replace"],
+            })
+        return cls(root, modules, workspace)
+
+    def signature(self) -> Tuple[Any, ...]:
+        """What import resolution depends on; a change means the call graph is stale."""
+        return tuple((m["dir"], m["path"], m["kind"], tuple((r["path"], r["with"]) for r in m["replace"]))
+                     for m in self.modules) + \
+            (tuple((r["path"], r["with"]) for r in self.workspace["replace"]) if self.workspace else (),)
+
+    def module_of(self, directory: str) -> Optional[Dict[str, Any]]:
+        """The module a directory belongs to: the nearest go.mod at or above it."""
+        for module in self.modules:
+            if directory == module["dir"] or directory.startswith(module["dir"].rstrip(os.sep) + os.sep):
+                return module
+        return None
+
+    def import_path(self, pkg_dir: str, depth: Optional[int] = None) -> Optional[str]:
+        """
+        The import path of a package directory, None outside every module.
+        With depth, the directory is cut to that many levels below the module root.
+        """
+        module = self.module_of(pkg_dir)
+        if module is None or not module["path"]:
+            return None
+        rel = os.path.relpath(pkg_dir, module["dir"]).replace(os.sep, "/")
+        if rel == ".":
+            return module["path"]
+        if depth is not None:
+            rel = "/".join(rel.split("/")[:max(depth, 1)])
+        return f"{module['path']}/{rel}"
+
+    def _replaces(self, module: Optional[Dict[str, Any]]) -> List[Tuple[str, Dict[str, str]]]:
+        """
+        The replace directives that apply to imports from a module, with the
+        directory each is relative to. In a workspace those of go.work and of
+        every workspace module; elsewhere the module's own - and with no
+        importer, the main module's.
+        """
+        workspace = [(self.root, r) for r in self.workspace["replace"]] if self.workspace else []
+        if module is None:
+            owners = [m for m in self.modules if m["kind"] == "main"]
+        elif module["kind"] == "workspace":
+            owners = [m for m in self.modules if m["kind"] == "workspace"]
+        else:
+            owners, workspace = [module], []
+        # go.work replaces win over a module's
+        return [(m["dir"], r) for m in owners for r in m["replace"]] + workspace
+
+    def _targets(self, from_dir: Optional[str]) -> List[Tuple[str, str]]:
+        module = self.module_of(from_dir) if from_dir else None
+        scope = module["dir"] if module else None
+        if scope not in self._scopes:
+            targets = dict(self._module_dirs)
+            for base, replace in self._replaces(module):
+                if _local(replace["with"]):
+                    # A replacement wins over a module of the same path elsewhere in the tree
+                    targets[replace["path"]] = os.path.normpath(os.path.join(base, replace["with"]))
+            self._scopes[scope] = sorted(targets.items(), key=lambda item: -len(item[0]))
+        return self._scopes[scope]
+
+    def resolve(self, import_path: str, from_dir: Optional[str] = None) -> Optional[str]:
+        """
+        The local directory an import path points into, if a project module or
+        a replacement covers it. from_dir, the directory of the importing
+        package, decides which replace directives apply.
+        """
+        for prefix, directory in self._targets(from_dir):
+            if import_path == prefix:
+                return directory
+            if import_path.startswith(prefix + "/"):
+                return os.path.join(directory, *import_path[len(prefix) + 1:].split("/"))
+        return None
+
+    def owns(self, import_path: str, from_dir: Optional[str] = None) -> bool:
+        """Whether an import path belongs to one of the project's modules (or a local replacement)."""
+        return any(import_path == prefix or import_path.startswith(prefix + "/")
+                   for prefix, _ in self._targets(from_dir))
+
+    def requires(self) -> List[str]:
+        """Module paths required by any of the modules."""
+        return sorted({r["path"] for m in self.modules for r in m["require"]})
+
+    def describe(self) -> Dict[str, Any]:
+        """The modules and workspace for tool output, directories relative to the root."""
+        rel = lambda d: os.path.relpath(d, self.root).replace(os.sep, "/")
+        result: Dict[str, Any] = {
+            "modules": [
+                {"path": m["path"], "dir": rel(m["dir"]), "go": m["go"], "kind": m["kind"],
+                 "go_mod": rel(os.path.join(m["dir"], "go.mod")),
+                 **({"replace": [r for r in m["replace"] if _local(r["with"])]}
+                    if any(_local(r["with"]) for r in m["replace"]) else {})}
+                for m in sorted(self.modules, key=lambda m: m["dir"])
+            ],
+        }
+        if self.workspace is not None:
+            result["workspace"] = {"go_work": "go.work", "go": self.workspace["go"],
+                                   "use": self.workspace["use"], "replace": self.workspace["replace"]}
+        return result
@@ -0,0 +1,570 @@
+"""Package move plans for Go - the edits moving a package directory would take, without making them.
+
+Moving internal/store to pkg/storage is one `git mv` and then every import
+of it, every `store.` qualifier if the package name follows the directory,
+and whatever go.mod files the move reaches. PackageMovePlanner lists those
+edits the way rename_preview lists a rename's (see xray.core.go_rename):
+each a location with the text there and the text to put there.
+
+The package moves with the packages below it (internal/store/sql goes to
+pkg/storage/sql), but not with the nested modules below it, which keep
+their module paths - only the directives locating them change. Edits:
+
+    import_path      the import spec of every file importing a moved package
+    package_clause   `package store` (and `store_test`) when the name changes
+    package_doc      the `// Package store ...` comment starting with it
+    qualifier        `store.Open` in each file importing the package unaliased
+    go_mod, go_work  the module directive of a moved module root, requirements
+                     of a module whose path changes, `use` and local `replace`
+                     directives locating a moved module, and the require (and
+                     replace) lines a module needs once it imports a package
+                     that moved into another module
+
+What no edit can settle is listed for review: string literals and comments
+(go:generate directives among them) naming an old import path, build files
+(Makefiles, scripts, Dockerfiles, CI configuration) naming it or the old
+directory, and qualifiers a local of the same name may shadow. Collisions are
+what the move would break: the new name already bound in a file importing
+the package, or an internal package its importers may no longer import.
+"""
+
+import os
+import re
+from typing import Any, Callable, Dict, Iterable, List, Optional, Tuple
+
+from xray.core.docker_parser import is_dockerfile
+from xray.core.go_analysis import GoCallGraph
+from xray.core.go_parser import KEYWORDS, tokenize
+from xray.core.go_rename import FileScan, RenamePlanner
+from xray.core.source_text import source_lines
+from xray.core.task_parser import is_makefile
+
+_IDENTIFIER = re.compile(r"^[^\W\d]\w*$")
+_BUILD_SUFFIXES = (".sh", ".bash", ".yml", ".yaml", ".toml", ".json", ".bzl", ".bazel", ".nix")
+_BUILD_NAMES = {"build", "justfile", "taskfile", "earthfile", "jenkinsfile", "procfile", ".envrc"}
+_PACKAGE_DOC = re.compile(r"^(//|/\*)(\s*)Package\s+")
+_MOD_DIRECTIVES = ("module", "require", "replace", "use", "exclude", "retract", "go", "toolchain", "godebug")
+
+
+def is_build_file(name: str) -> bool:
+    """Whether a file may name Go packages outside Go code: build scripts, Dockerfiles, CI and tool configs."""
+    lower = name.lower()
+    return is_makefile(name) or is_dockerfile(name) or lower.endswith(_BUILD_SUFFIXES) or lower in _BUILD_NAMES
+
+
+def _under(path: str, directory: str) -> bool:
+    return path == directory or path.startswith(directory.rstrip(os.sep) + os.sep)
+
+
+def _mentions(text: str) -> "re.Pattern[str]":
+    """A path as written in text, not as the start of a longer name (store vs storage)."""
+    return re.compile(r"(?<![\w.-])" + re.escape(text) + r"(?![\w.-])")
+
+
+def _local(target: str) -> bool:
+    return target.startswith(("./", "../", "/")) or target in (".", "..")
+
+
+def mod_lines(content: str) -> Iterable[Tuple[int, str, List[Tuple[str, int]]]]:
+    """
+    The directive lines of a go.mod or go.work: (line number, directive,
+    [(word, column)]), the directive word itself left out inside a block
+    and comments dropped.
+    """
+    block = None
+    for number, line in enumerate(source_lines(content), 1):
+        code = line.split("//", 1)[0]
+        words = [(m.group(), m.start() + 1) for m in re.finditer(r"\S+", code)]
+        if not words:
+            continue
+        if block is not None:
+            if words[0][0] == ")":
+                block = None
+                continue
+            yield number, block, words
+            continue
+        if words[0][0] in _MOD_DIRECTIVES:
+            if len(words) > 1 and words[1][0] == "(":
+                block = words[0][0]
+                continue
+            yield number, words[0][0], words[1:]
+
+
+def internal_allowed(import_path: str, importer: Optional[str]) -> bool:
+    """Whether a package of import path importer may import import_path (the internal/ rule)."""
+    parts = import_path.split("/")
+    if "internal" not in parts:
+        return True
+    if importer is None:
+        return False
+    parent = "/".join(parts[:len(parts) - 1 - parts[::-1].index("internal")])
+    return not parent or importer == parent or importer.startswith(parent + "/")
+
+
+class PackageMovePlanner:
+    """Plans moves of the Go packages of a call graph's project."""
+
+    def __init__(self, graph: GoCallGraph, read: Callable[[str], Optional[str]],
+                 generated: Callable[[str], bool], extra_files: Iterable[Tuple[str, Dict[str, Any]]] = (),
+                 build_files: Iterable[str] = ()):
+        """
+        Takes what RenamePlanner takes, plus build_files: the paths of the
+        non-Go files to search for the old import path and directory (those
+        is_build_file accepts).
+        """
+        self.planner = RenamePlanner(graph, read, generated, extra_files)
+        self.project = graph.project
+        self.modules = graph.project.modules
+        self.root = graph.project.root
+        self._read = read
+        self._generated = generated
+        self.build_files = list(build_files)
+
+    def resolve(self, text: str) -> Optional[str]:
+        """The directory an import path of a project module points at, None if it is not one."""
+        if self.modules is None or os.path.isabs(text) or not self.modules.owns(text):
+            return None
+        return self.modules.resolve(text)
+
+    def movable(self, directory: str) -> bool:
+        """Whether a directory is a package of the project, or the root of one of its modules."""
+        return directory in self.project.packages or self.modules is not None and any(
+            m["dir"] == directory for m in self.modules.modules)
+
+    def _rel(self, path: str) -> str:
+        rel = os.path.relpath(path, self.root).replace(os.sep, "/")
+        return "" if rel == "." else rel
+
+    def _files(self) -> List[Tuple[str, Dict[str, Any]]]:
+        return list(self.project.files.items()) + sorted(self.planner.extra.items())
+
+    def _package_name(self, pkg_dir: str) -> str:
+        names = [parsed.get("package", "") for path, parsed in self._files()
+                 if os.path.dirname(path) == pkg_dir and not path.endswith("_test.go")]
+        names = names or [parsed.get("package", "").removesuffix("_test") for path, parsed in self._files()
+                          if os.path.dirname(path) == pkg_dir]
+        return max(sorted(set(names)), key=names.count) if names else ""
+
+    # ------------------------------------------------------------------
+    # Plan
+    # ------------------------------------------------------------------
+
+    def plan(self, old_dir: str, new_dir: str, package_name: Optional[str] = None) -> Dict[str, Any]:
+        """
+        The edit plan for moving the package in old_dir to new_dir (absolute
+        directories), renaming it to package_name - by default to the new
+        directory's name when its name was the old directory's.
+
+        Returns:
+            {"from", "to", "packages", "file_moves", "edits": [{path, line,
+             column, kind, old_text, new_text}], "review", "collisions",
+             "affected_files", "counts"}
+        """
+        old_dir, new_dir = os.path.normpath(old_dir), os.path.normpath(new_dir)
+        if not self.movable(old_dir):
+            raise ValueError(f"'{self._rel(old_dir) or '.'}' is not a Go package of the project")
+        if _under(new_dir, old_dir):
+            raise ValueError("a package cannot move into itself")
+        if not _under(new_dir, self.root):
+            raise ValueError(f"'{new_dir}' is outside the project")
+        if any(_under(os.path.dirname(path), new_dir) for path, _ in self._files()) or \
+                os.path.isdir(new_dir) and any(name.endswith(".go") for name in os.listdir(new_dir)):
+            raise ValueError(f"'{self._rel(new_dir)}' already holds Go files")
+
+        old_module = self.modules.module_of(old_dir) if self.modules is not None else None
+        new_module = self.modules.module_of(new_dir) if self.modules is not None else None
+        if old_module is None or not old_module["path"]:
+            raise ValueError("the package is in no Go module, so its import path is unknown")
+        old_name = self._package_name(old_dir)
+        new_name = self._new_name(old_dir, new_dir, old_name, package_name)
+
+        root_move = old_module["dir"] == old_dir
+        if root_move:
+            # The module moves with its go.mod; its path changes only where it named the directory
+            new_module_path = self._moved_module_path(old_module, new_dir)
+            new_root_path = new_module_path
+            target_module = {**old_module, "dir": new_dir, "path": new_module_path}
+        else:
+            if new_module is None or not new_module["path"]:
+                raise ValueError(f"'{self._rel(new_dir)}' is in no Go module")
+            new_root_path = self.modules.import_path(new_dir)
+            target_module = new_module
+        old_root_path = self.modules.import_path(old_dir)
+
+        packages = self._moved_packages(old_dir, new_dir, old_module, old_root_path, new_root_path)
+        moved_paths = {p["old_import_path"]: p for p in packages}
+        moved_dirs = {p["old_dir"]: p for p in packages}
+
+        edits: List[Dict[str, Any]] = []
+        review: List[Dict[str, Any]] = []
+        collisions: List[Dict[str, Any]] = []
+        importers: Dict[str, Dict[str, Any]] = {}
+        for path, parsed in self._files():
+            for imp in parsed.get("imports", []):
+                moved = moved_paths.get(imp["path"])
+                if moved is None or self.project.import_dir(imp["path"], path) != moved["old_dir"]:
+                    continue
+                if imp["path"] != moved["new_import_path"]:
+                    edits.append({"path": path, "line": imp["line"], "column": imp["column"],
+                                  "kind": "import_path", "old_text": f'"{imp["path"]}"',
+                                  "new_text": f'"{moved["new_import_path"]}"'})
+                importers.setdefault(path, {"parsed": parsed, "imports": []})["imports"].append((imp, moved))
+
+        root = moved_dirs.get(old_dir)
+        if root is not None and new_name != old_name:
+            for path, parsed in self._files():
+                if os.path.dirname(path) == old_dir:
+                    edits.extend(self._package_edits(path, old_name, new_name))
+            for path, entry in sorted(importers.items()):
+                for imp, moved in entry["imports"]:
+                    if moved is root and imp["kind"] == "default":
+                        found = self._qualifier_edits(path, entry["parsed"], old_name, new_name)
+                        edits.extend(found["edits"])
+                        review.extend(found["shadowed"])
+                        collisions.extend(self._name_collisions(path, entry["parsed"], imp, new_name,
+                                                                found["functions"]))
+
+        collisions.extend(self._internal_collisions(importers, moved_dirs, old_dir, new_dir))
+        edits.extend(self._mod_edits(old_dir, new_dir, old_module, target_module, root_move, importers,
+                                     moved_dirs))
+        for edit in edits:
+            if self._generated(edit["path"]):
+                edit["generated"] = True
+        edits.sort(key=lambda e: (e["path"], e["line"], e["column"]))
+
+        old_paths = sorted({p["old_import_path"] for p in packages if p["old_import_path"] != p["new_import_path"]},
+                           key=len)
+        review.extend(self._go_mentions(old_paths[:1]))
+        review.extend(self._build_mentions(old_paths[:1], self._rel(old_dir)))
+        review.sort(key=lambda r: (r["path"], r["line"], r["column"]))
+
+        file_moves = [{"from": path, "to": os.path.join(moved_dirs[os.path.dirname(path)]["new_dir"],
+                                                        os.path.basename(path))}
+                      for path, _ in sorted(self._files()) if os.path.dirname(path) in moved_dirs]
+        affected: Dict[str, Dict[str, Any]] = {}
+        for edit in edits:
+            entry = affected.setdefault(edit["path"], {"path": edit["path"], "edits": 0, "kinds": []})
+            entry["edits"] += 1
+            if edit["kind"] not in entry["kinds"]:
+                entry["kinds"].append(edit["kind"])
+        for entry in affected.values():
+            if any(_under(entry["path"], directory) for directory in moved_dirs):
+                entry["moved"] = True
+        return {
+            "from": {"path": self._rel(old_dir), "import_path": old_root_path, "package": old_name,
+                     "module": old_module["path"]},
+            "to": {"path": self._rel(new_dir), "import_path": new_root_path, "package": new_name,
+                   "module": target_module["path"]},
+            "crosses_modules": not root_move and target_module["dir"] != old_module["dir"],
+            "packages": [{"from": self._rel(p["old_dir"]), "to": self._rel(p["new_dir"]),
+                          "old_import_path": p["old_import_path"], "new_import_path": p["new_import_path"],
+                          "package": p["package"],
+                          **({"new_package": new_name} if p is root and new_name != old_name else {})}
+                         for p in packages],
+            "file_moves": file_moves,
+            "edits": edits,
+            "review": review,
+            "collisions": collisions,
+            "affected_files": sorted(affected.values(), key=lambda e: e["path"]),
+            "counts": {
+                "packages": len(packages),
+                "file_moves": len(file_moves),
+                "edits": len(edits),
+                "files": len(affected),
+                "importers": len({path for path in importers if not any(_under(path, d) for d in moved_dirs)}),
+                "review": len(review),
+                "collisions": len(collisions),
+            },
+        }
+
+    def _new_name(self, old_dir: str, new_dir: str, old_name: str, package_name: Optional[str]) -> str:
+        if package_name:
+            if not _IDENTIFIER.match(package_name) or package_name in KEYWORDS or package_name == "_":
+                raise ValueError(f"'{package_name}' is not a valid Go package name")
+            if old_name == "main" and package_name != "main":
+                raise ValueError("a main package keeps its name")
+            return package_name
+        base = os.path.basename(new_dir)
+        if old_name == os.path.basename(old_dir) and old_name != "main" and _IDENTIFIER.match(base) and \
+                base not in KEYWORDS:
+            return base
+        return old_name
+
+    def _moved_module_path(self, module: Dict[str, Any], new_dir: str) -> str:
+        """The path of a module whose root moves: its tail follows the directory if it named it."""
+        old_rel, new_rel = self._rel(module["dir"]), self._rel(new_dir)
+        if old_rel and (module["path"] == old_rel or module["path"].endswith("/" + old_rel)):
+            return module["path"][:len(module["path"]) - len(old_rel)] + new_rel
+        return module["path"]
+
+    def _moved_packages(self, old_dir: str, new_dir: str, module: Dict[str, Any], old_root: str,
+                        new_root: str) -> List[Dict[str, Any]]:
+        """The package and those below it in the same module, with their new directories and paths."""
+        packages = []
+        for pkg_dir in sorted(self.project.packages):
+            if not _under(pkg_dir, old_dir) or self.modules.module_of(pkg_dir) is not module:
+                continue
+            rel = os.path.relpath(pkg_dir, old_dir).replace(os.sep, "/")
+            packages.append({
+                "old_dir": pkg_dir,
+                "new_dir": new_dir if rel == "." else os.path.join(new_dir, *rel.split("/")),
+                "old_import_path": old_root if rel == "." else f"{old_root}/{rel}",
+                "new_import_path": new_root if rel == "." else f"{new_root}/{rel}",
+                "package": self._package_name(pkg_dir),
+            })
+        return packages
+
+    # ------------------------------------------------------------------
+    # Go edits
+    # ------------------------------------------------------------------
+
+    def _package_edits(self, path: str, old_name: str, new_name: str) -> List[Dict[str, Any]]:
+        """The package clause of a file of the moved package, and the package doc comment."""
+        content = self._read(path)
+        if content is None:
+            return []
+        tokens, comments = tokenize(content)
+        edits = []
+        for index, tok in enumerate(tokens[:-1]):
+            if tok.kind == "keyword" and tok.value == "package":
+                clause = tokens[index + 1]
+                for old, new in ((old_name, new_name), (old_name + "_test", new_name + "_test")):
+                    if clause.kind == "ident" and clause.value == old:
+                        edits.append({"path": path, "line": clause.line, "column": clause.col,
+                                      "kind": "package_clause", "old_text": old, "new_text": new})
+                break
+        else:
+            return edits
+        for comment in comments:
+            if comment.line >= clause.line:
+                break
+            match = _PACKAGE_DOC.match(comment.value)
+            if match and re.match(re.escape(old_name) + r"\b", comment.value[match.end():]) and \
+                    not clause.value.endswith("_test"):
+                edits.append({"path": path, "line": comment.line, "column": comment.col + match.end(),
+                              "kind": "package_doc", "old_text": old_name, "new_text": new_name})
+        return edits
+
+    def _qualifier_edits(self, path: str, parsed: Dict[str, Any], old_name: str,
+                         new_name: str) -> Dict[str, Any]:
+        """The `old.Name` qualifiers of a file importing the package by its name, and those a local may shadow."""
+        content = self._read(path)
+        found: Dict[str, Any] = {"edits": [], "shadowed": [], "functions": []}
+        if content is None or old_name not in content:
+            return found
+        tokens, _ = tokenize(content)
+        scan = FileScan(self.planner, path, parsed, tokens)
+        for index, tok in enumerate(tokens[:-1]):
+            if tok.kind != "ident" or tok.value != old_name or tokens[index + 1].value != "." or \
+                    tokens[index + 1].kind != "op" or (index and tokens[index - 1].value == "." and
+                                                        tokens[index - 1].kind == "op") or scan.declared(tok):
+                continue
+            site = {"path": path, "line": tok.line, "column": tok.col}
+            func = scan.enclosing(tok.line)
+            if func is not None and old_name in func.get("locals", ()):
+                found["shadowed"].append({**site, "kind": "shadowed_qualifier",
+                                          "function": scan.function_name(func),
+                                          "message": f"{scan.function_name(func)} has a local named {old_name}: "
+                                                     f"rewrite this qualifier only if it names the package",
+                                          "text": scan.line_text(content, tok.line)})
+                continue
+            found["edits"].append({**site, "kind": "qualifier", "old_text": old_name, "new_text": new_name})
+            if func is not None and func not in found["functions"]:
+                found["functions"].append(func)
+        return found
+
+    def _name_collisions(self, path: str, parsed: Dict[str, Any], imp: Dict[str, Any], new_name: str,
+                         functions: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """Where the new package name is already bound in a file that will import it by that name."""
+        collisions = []
+        for other in parsed.get("imports", []):
+            if other is not imp and other["name"] == new_name and other["kind"] in ("default", "alias"):
+                collisions.append({"kind": "import", "path": path, "line": other["line"],
+                                   "message": f"the file already imports {other['path']} as {new_name}: "
+                                              f"alias one of the imports"})
+        pkg_dir = os.path.dirname(path)
+        package = parsed.get("package", "")
+        for file_path, file_parsed in self._files():
+            if os.path.dirname(file_path) != pkg_dir or file_parsed.get("package", "") != package:
+                continue
+            for symbol in file_parsed["symbols"]:
+                if symbol["name"] == new_name and not symbol.get("container") and not symbol.get("receiver"):
+                    collisions.append({"kind": "declaration", "path": path, "line": imp["line"],
+                                       "declared_at": {"path": file_path, "line": symbol["start_line"]},
+                                       "message": f"{package} declares {new_name}, which the import would "
+                                                  f"shadow: alias the import"})
+        for func in functions:
+            if new_name in func.get("locals", ()):
+                name = FileScan.function_name(func)
+                collisions.append({"kind": "local", "path": path, "line": func["start_line"], "function": name,
+                                   "message": f"{name} has a local named {new_name}, which would hide the "
+                                              f"package in its qualifiers"})
+        return collisions
+
+    def _internal_collisions(self, importers: Dict[str, Dict[str, Any]], moved_dirs: Dict[str, Dict[str, Any]],
+                             old_dir: str, new_dir: str) -> List[Dict[str, Any]]:
+        """Importers an internal/ package could no longer be imported from at its new path."""
+        collisions = []
+        for path, entry in sorted(importers.items()):
+            importer_dir = os.path.dirname(path)
+            if importer_dir in moved_dirs:
+                importer_path = moved_dirs[importer_dir]["new_import_path"]
+            else:
+                importer_path = self.modules.import_path(importer_dir)
+            for imp, moved in entry["imports"]:
+                if internal_allowed(imp["path"], importer_path) and \
+                        not internal_allowed(moved["new_import_path"], importer_path):
+                    collisions.append({"kind": "internal", "path": path, "line": imp["line"],
+                                       "message": f"{moved['new_import_path']} is internal to a tree "
+                                                  f"{importer_path or 'this file'} is outside of"})
+        return collisions
+
+    # ------------------------------------------------------------------
+    # go.mod and go.work
+    # ------------------------------------------------------------------
+
+    def _mod_files(self) -> List[Tuple[str, str, Dict[str, Any]]]:
+        """(path, kind, module or None) of every go.mod, and the go.work (kind "go_work")."""
+        found = [(os.path.join(m["dir"], "go.mod"), "go_mod", m) for m in sorted(self.modules.modules,
+                                                                                key=lambda m: m["dir"])]
+        if self.modules.workspace is not None:
+            found.append((os.path.join(self.root, "go.work"), "go_work", None))
+        return found
+
+    def _mod_edits(self, old_dir: str, new_dir: str, old_module: Dict[str, Any], target_module: Dict[str, Any],
+                   root_move: bool, importers: Dict[str, Dict[str, Any]],
+                   moved_dirs: Dict[str, Dict[str, Any]]) -> List[Dict[str, Any]]:
+        edits = []
+        # Module roots the move relocates: the moved one and those nested below it
+        relocated = {m["dir"]: os.path.join(new_dir, os.path.relpath(m["dir"], old_dir)) if m["dir"] != old_dir
+                     else new_dir for m in self.modules.modules if _under(m["dir"], old_dir)}
+        renamed = {old_module["path"]: target_module["path"]} if root_move and \
+            target_module["path"] != old_module["path"] else {}
+        for path, kind, module in self._mod_files():
+            content = self._read(path)
+            if content is None:
+                continue
+            base = os.path.dirname(path)
+            new_base = relocated.get(base, base)
+            for number, directive, words in mod_lines(content):
+                site = {"path": path, "line": number, "kind": kind, "directive": directive}
+                if directive == "module" and words and words[0][0].strip('"') in renamed:
+                    word, column = words[0]
+                    edits.append({**site, "column": column, "old_text": word,
+                                  "new_text": renamed[word.strip('"')]})
+                    continue
+                if directive in ("require", "replace", "exclude") and words and words[0][0] in renamed:
+                    edits.append({**site, "column": words[0][1], "old_text": words[0][0],
+                                  "new_text": renamed[words[0][0]]})
+                if directive == "use" and words:
+                    targets = [words[0]]
+                elif directive == "replace" and "=>" in [w for w, _ in words]:
+                    targets = words[[w for w, _ in words].index("=>") + 1:][:1]
+                else:
+                    targets = []
+                for word, column in targets:
+                    text = word.strip('"')
+                    if not _local(text):
+                        continue
+                    located = os.path.normpath(os.path.join(base, text))
+                    moved_to = relocated.get(located, located)
+                    if located == moved_to and base == new_base:
+                        continue
+                    rewritten = os.path.relpath(moved_to, new_base).replace(os.sep, "/")
+                    rewritten = rewritten if rewritten.startswith(".") else "./" + rewritten
+                    if rewritten != text:
+                        edits.append({**site, "column": column, "old_text": word, "new_text": rewritten})
+            if module is not None and not root_move:
+                edits.extend(self._requirements(path, content, module, old_module, target_module, importers,
+                                                moved_dirs))
+        return edits
+
+    def _requirements(self, path: str, content: str, module: Dict[str, Any], old_module: Dict[str, Any],
+                      target_module: Dict[str, Any], importers: Dict[str, Dict[str, Any]],
+                      moved_dirs: Dict[str, Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """The require and replace lines a module's go.mod needs once the package lives in another module."""
+        if target_module["dir"] == old_module["dir"]:
+            return []
+        needed = set()
+        for file_path, entry in importers.items():
+            importer_dir = os.path.dirname(file_path)
+            if importer_dir not in moved_dirs and self.modules.module_of(importer_dir) is module and \
+                    module is not target_module:
+                needed.add(target_module["dir"])
+        if module is target_module:
+            # The moved files' own imports of the modules they left behind
+            for file_path, parsed in self._files():
+                if os.path.dirname(file_path) not in moved_dirs:
+                    continue
+                for imp in parsed.get("imports", []):
+                    imported = self.project.import_dir(imp["path"], file_path)
+                    owner = self.modules.module_of(imported) if imported and imported not in moved_dirs else None
+                    if owner is not None and owner is not module and owner["path"]:
+                        needed.add(owner["dir"])
+        edits = []
+        line = len(source_lines(content)) + 1
+        required = {r["path"] for r in module["require"]}
+        replaced = {r["path"] for r in module["replace"]}
+        workspace = module["kind"] == "workspace"
+        for required_dir in sorted(needed):
+            other = next(m for m in self.modules.modules if m["dir"] == required_dir)
+            site = {"path": path, "line": line, "column": 1, "kind": "go_mod", "old_text": ""}
+            if other["path"] not in required:
+                versions = [r["version"] for m in self.modules.modules for r in m["require"]
+                            if r["path"] == other["path"]]
+                edits.append({**site, "directive": "require",
+                              "new_text": f"require {other['path']} {versions[0] if versions else 'v0.0.0'}\n"})
+            if other["path"] not in replaced and not (workspace and other["kind"] == "workspace"):
+                target = os.path.relpath(other["dir"], module["dir"]).replace(os.sep, "/")
+                target = target if target.startswith(".") else "./" + target
+                edits.append({**site, "directive": "replace", "new_text": f"replace {other['path']} => {target}\n"})
+        return edits
+
+    # ------------------------------------------------------------------
+    # Review
+    # ------------------------------------------------------------------
+
+    def _go_mentions(self, old_paths: List[str]) -> List[Dict[str, Any]]:
+        """String literals and comments of Go files naming an old import path (imports aside)."""
+        if not old_paths:
+            return []
+        patterns = [_mentions(p) for p in old_paths]
+        found = []
+        for path, parsed in self._files():
+            content = self._read(path)
+            if content is None or not any(p in content for p in old_paths):
+                continue
+            specs = {(imp["line"], imp["column"]) for imp in parsed.get("imports", [])}
+            tokens, comments = tokenize(content)
+            for tok in [t for t in tokens if t.kind == "string"] + comments:
+                if (tok.line, tok.col) in specs or not any(p.search(tok.value) for p in patterns):
+                    continue
+                if tok.kind == "comment":
+                    context = "go_generate" if tok.value.startswith("//go:generate") else "comment"
+                else:
+                    context = "string"
+                text = tok.value if len(tok.value) <= 120 else tok.value[:117] + "..."
+                found.append({"kind": "string_literal" if tok.kind == "string" else "comment", "context": context,
+                              "path": path, "line": tok.line, "column": tok.col, "text": text})
+        return found
+
+    def _build_mentions(self, old_paths: List[str], old_rel: str) -> List[Dict[str, Any]]:
+        """The lines of build files naming an old import path or the old directory."""
+        patterns = [(_mentions(p), "import_path") for p in old_paths]
+        if old_rel:
+            patterns.append((_mentions(old_rel), "directory"))
+        found = []
+        for path in self.build_files:
+            content = self._read(path)
+            if content is None:
+                continue
+            for number, line in enumerate(source_lines(content), 1):
+                for pattern, context in patterns:
+                    match = pattern.search(line)
+                    if match:
+                        text = line.strip()
+                        found.append({"kind": "build_file", "context": context, "path": path, "line": number,
+                                      "column": match.start() + 1,
+                                      "text": text if len(text) <= 120 else text[:117] + "..."})
+                        break
+        return found
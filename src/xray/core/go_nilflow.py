@@ -0,0 +1,809 @@
+"""Where a Go variable gets its values inside one function, and which of them can be nil.
+
+A nil pointer panic names the line that dereferenced a variable, not the
+one that left it nil. VariableTracer follows one variable name through one
+function body and lists every assignment to it:
+
+    parameter    a parameter, receiver or named result of the function, or
+                 a parameter of a func literal inside it
+    short_decl   `v := ...`, alone or among others (`v, err := f()`)
+    var          `var v T`, `var v = ...`
+    assign       `v = ...`, alone or in a multi-assignment
+    range        `for _, v := range xs`, `for _, v = range xs`
+    type_switch  `switch v := x.(type)`
+    compound     `v += ...`, `v++`
+
+with whether its right-hand side can be nil - true, false, or null when
+the tracer cannot tell:
+
+    the nil literal                             true
+    a map lookup without ok                     true when the map's values are nilable
+    a call returning a pointer and an error     true: nil whenever the error is not
+    the zero value of a nilable type            true (`var v *T`, a named result)
+    a parameter of a nilable type               true: the caller may pass nil
+    &T{...}, &x, new(T), make(...), literals    false
+    a comma-ok map lookup or type assertion     false: nil only when ok is false
+
+Types come from the call graph's evaluation of selector chains (see
+xray.core.go_analysis); a value whose type it cannot find is null. The
+dereferences of the variable follow - `v.Field`, `v.Method()`, `*v`,
+`v[i]`, a write to a nil map - each with the assignment it comes after in
+source order and whether a nil check guards it: inside `if v != nil {`, or
+after an `if v == nil {` block that returns. Pointer passes, `&v` or
+`&v.Field` handed to a call such as `row.Scan(&user.ID)`, are where a
+callee writes through the variable.
+
+Shadowing is followed by block: a `:=` in an inner block - an if, for,
+switch or select header's included - declares another variable of the
+name, and every entry names the declaration it belongs to. The analysis
+stays inside the function: nothing follows the variable into callees or
+back to callers.
+"""
+
+from typing import Any, Callable, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+from xray.core.go_parser import Token, tokenize
+from xray.core.source_text import source_lines
+
+ASSIGNMENT_KINDS = ("parameter", "short_decl", "var", "assign", "range", "type_switch", "compound")
+
+_BASIC_TYPES = {
+    "bool", "string", "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16",
+    "uint32", "uint64", "uintptr", "byte", "rune", "float32", "float64", "complex64", "complex128",
+}
+_NILABLE_PREFIXES = ("*", "[]", "map[", "chan ", "chan<-", "<-chan", "func", "interface")
+_COMPOUND = {"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<=", ">>=", "&^="}
+# Tokens an assignment's left-hand side may hold besides identifiers
+_LHS_OPS = {",", ".", "*", "[", "]", "(", ")"}
+_OPENING = {")": "(", "]": "[", "}": "{"}
+
+
+def _is(tokens: List[Token], index: int, value: str) -> bool:
+    return 0 <= index < len(tokens) and tokens[index].value == value and tokens[index].kind in ("op", "keyword")
+
+
+def _match_forward(tokens: List[Token], opening: int, upper: int) -> int:
+    """The index of the bracket closing tokens[opening], or upper."""
+    depth = 0
+    for k in range(opening, upper):
+        tok = tokens[k]
+        if tok.kind != "op":
+            continue
+        if tok.value in ("(", "[", "{"):
+            depth += 1
+        elif tok.value in _OPENING:
+            depth -= 1
+            if depth == 0:
+                return k
+    return upper
+
+
+def _split(tokens: List[Token], start: int, end: int) -> List[Tuple[int, int]]:
+    """tokens[start:end] split at top-level commas."""
+    parts, depth, part_start = [], 0, start
+    for k in range(start, end):
+        tok = tokens[k]
+        if tok.kind != "op":
+            continue
+        if tok.value in ("(", "[", "{"):
+            depth += 1
+        elif tok.value in _OPENING:
+            depth -= 1
+        elif tok.value == "," and depth == 0:
+            parts.append((part_start, k))
+            part_start = k + 1
+    parts.append((part_start, end))
+    return parts
+
+
+def _type_brace(tokens: List[Token], index: int) -> bool:
+    """Whether the { at index opens a struct or interface type rather than a block."""
+    return index > 0 and tokens[index - 1].kind == "keyword" and tokens[index - 1].value in ("struct", "interface")
+
+
+def _type_text(tokens: List[Token], start: int, end: int) -> str:
+    """The type tokens[start:end] spell, spaced as gofmt would (`chan int`, `map[string]*User`)."""
+    words = ("ident", "keyword")
+    return "".join((" " if k > start and tokens[k].kind in words and tokens[k - 1].kind in words else "")
+                   + tokens[k].value for k in range(start, end))
+
+
+def _operand_end(tok: Token) -> bool:
+    """Whether a token ends an operand, so a following * or & is binary."""
+    return tok.kind in ("ident", "string", "int", "float", "imag", "char") or \
+        (tok.kind == "op" and tok.value in (")", "]", "}")) or (tok.kind == "keyword" and tok.value in ("nil",))
+
+
+class VariableTracer:
+    """The assignments, nil sources and dereferences of one variable in one Go function."""
+
+    def __init__(self, graph: GoCallGraph, read: Callable[[str], Optional[str]]):
+        self.graph = graph
+        self.project = graph.project
+        self._read = read
+
+    # ------------------------------------------------------------------
+    # Types
+    # ------------------------------------------------------------------
+
+    def _nilable(self, type_text: Optional[str], path: str, func: Dict[str, Any]) -> Optional[bool]:
+        """Whether values of a type can be nil: pointers, slices, maps, channels, funcs and interfaces."""
+        if not type_text:
+            return None
+        text = type_text.strip()
+        if text.startswith(_NILABLE_PREFIXES) or text in ("error", "any"):
+            return True
+        if text.startswith("[") or text in _BASIC_TYPES or text.startswith("struct"):
+            return False
+        named = self.graph.type_of(path, func, {"type": text})
+        if named is None or named[0] != "project":
+            return None
+        symbol = self.project.types.get(self.project.resolve_alias_key((named[1], named[2])))
+        if symbol is None:
+            return None
+        if symbol["type"] == "interface":
+            return True
+        return False if symbol["type"] == "struct" else None
+
+    def _value_type(self, path: str, func: Dict[str, Any], source: Dict[str, Any]) -> Optional[str]:
+        try:
+            return self.graph.value_type(path, func, source)
+        except (KeyError, TypeError):
+            return None
+
+    @staticmethod
+    def _chain(tokens: List[Token], start: int, end: int) -> Optional[List[str]]:
+        """The selector chain tokens[start:end] spell (`s.db.QueryRow(...).Scan(...)`), or None."""
+        if start >= end or tokens[start].kind != "ident":
+            return None
+        chain, k = [tokens[start].value], start + 1
+        while k < end:
+            if _is(tokens, k, ".") and k + 1 < end and tokens[k + 1].kind == "ident":
+                chain.append(tokens[k + 1].value)
+                k += 2
+            elif _is(tokens, k, "(") or _is(tokens, k, "["):
+                close = _match_forward(tokens, k, end)
+                if close >= end:
+                    return None
+                chain.append("()" if tokens[k].value == "(" else "[]")
+                k = close + 1
+            else:
+                return None
+        return chain
+
+    def _callee(self, path: str, func: Dict[str, Any], chain: List[str]) -> Optional[Tuple[str, Any]]:
+        """What a call chain (ending in "()") calls: ("project", function symbol) or ("external", name)."""
+        value = self.graph.evaluate(path, func, chain[:-1])
+        if value is None or value[0] != "func":
+            return None
+        if value[1] == "external":
+            return "external", value[2]
+        symbol = self.graph.functions.get(value[2])
+        return ("project", symbol) if symbol else None
+
+    def _rhs(self, tokens: List[Token], start: int, end: int, position: int, names: int, values: int,
+             path: str, func: Dict[str, Any]) -> Dict[str, Any]:
+        """
+        Whether the value a name at position (of names) receives from the
+        right-hand side tokens[start:end] (values expressions) can be nil,
+        why, and its type where known.
+        """
+        exprs = _split(tokens, start, end)
+        a, b = exprs[position] if len(exprs) == names else exprs[0]
+        while b > a and tokens[b - 1].implicit:
+            b -= 1
+        first, last = tokens[a], tokens[b - 1]
+        facts: Dict[str, Any] = {}
+
+        def result(can_be_nil: Optional[bool], reason: str, type_text: Optional[str] = None) -> Dict[str, Any]:
+            facts.update({"can_be_nil": can_be_nil, "nil_reason": reason})
+            if type_text:
+                facts["type"] = type_text
+            return facts
+
+        if b - a == 1 and first.value == "nil" and first.kind in ("ident", "keyword"):
+            return result(True, "the nil literal")
+        if first.kind in ("string", "int", "float", "imag", "char") or \
+                (b - a == 1 and first.kind == "ident" and first.value in ("true", "false")):
+            return result(False, "a constant")
+        if _is(tokens, a, "&"):
+            inner = self._chain(tokens, a + 1, b)
+            type_text = None
+            if _is(tokens, b - 1, "}"):
+                brace = next((k for k in range(a + 1, b) if _is(tokens, k, "{")), None)
+                type_text = "*" + "".join(t.value for t in tokens[a + 1:brace]) if brace else None
+                return result(False, "the address of a composite literal", type_text)
+            if inner is not None:
+                type_text = self._value_type(path, func, {"address_of": {"chain": inner}})
+            return result(False, "the address of a variable", type_text)
+        if first.kind == "ident" and first.value in ("new", "make") and _is(tokens, a + 1, "("):
+            close = _match_forward(tokens, a + 1, b)
+            type_text = _type_text(tokens, *_split(tokens, a + 2, close)[0])
+            if first.value == "new":
+                return result(False, "new() never returns nil", "*" + type_text)
+            return result(False, "make() never returns nil", type_text)
+        if first.kind == "keyword" and first.value == "func":
+            return result(False, "a func literal")
+        if _is(tokens, b - 1, "}"):
+            # T{...}, []T{...}, map[K]V{...}
+            brace = next((k for k in range(a, b) if _is(tokens, k, "{")), None)
+            if brace is not None and brace > a:
+                return result(False, "a composite literal", "".join(t.value for t in tokens[a:brace]))
+        if _is(tokens, a, "<-"):
+            return result(None, "a channel receive: nil if a nil was sent or the channel's element type is nilable "
+                                "and it is closed")
+
+        # Type assertions: x.(T), v, ok := x.(T)
+        if _is(tokens, b - 1, ")") and b - a > 3:
+            opening = b - 1
+            depth = 0
+            for k in range(b - 1, a - 1, -1):
+                if _is(tokens, k, ")"):
+                    depth += 1
+                elif _is(tokens, k, "("):
+                    depth -= 1
+                    if depth == 0:
+                        opening = k
+                        break
+            if _is(tokens, opening - 1, "."):
+                asserted = "".join(t.value for t in tokens[opening + 1:b - 1])
+                if asserted == "type":
+                    return result(None, "a type switch binding: the case's type, nil in a case nil", None)
+                if names == 2 and values == 1 and position == 0:
+                    return result(False, "a comma-ok type assertion: the zero value only when ok is false", asserted)
+                return result(False, "a type assertion: it panics rather than yield a mismatched value", asserted)
+
+        chain = self._chain(tokens, a, b)
+        if chain is None:
+            return result(None, "an expression the tracer cannot type")
+        if chain[-1] == "[]":
+            container = self._value_type(path, func, {"chain": chain[:-1]})
+            element = self._value_type(path, func, {"chain": chain})
+            nilable = self._nilable(element, path, func)
+            if container is not None and container.lstrip("*").startswith("map["):
+                if names == 2 and values == 1 and position == 0:
+                    return result(False, "a comma-ok map lookup: the zero value only when ok is false", element)
+                if nilable:
+                    return result(True, "a map lookup without ok: nil when the key is missing", element)
+                if nilable is False:
+                    return result(False, "a map lookup of non-nilable values", element)
+                return result(None, "a map lookup without ok: the zero value when the key is missing", element)
+            if nilable is False:
+                return result(False, "an element of a non-nilable type", element)
+            return result(None, "an indexed element: nil if one was stored", element)
+        if chain[-1] == "()":
+            callee = self._callee(path, func, chain)
+            if callee is None:
+                return result(None, "a call the tracer cannot resolve")
+            if callee[0] == "external":
+                return result(None, f"a call of {callee[1]}, outside the project")
+            results = callee[1].get("results", [])
+            name = callee[1]["name"]
+            index = position if names > 1 and values == 1 else 0
+            if not results or index >= len(results):
+                return result(None, f"a call of {name}")
+            type_text = results[index]["type"]
+            nilable = self._nilable(type_text, path, func)
+            if len(results) >= 2 and results[-1]["type"] == "error":
+                if index == len(results) - 1:
+                    return result(True, f"the error {name} returns: nil on success", type_text)
+                if nilable and names > 1:
+                    return result(True, f"{name} returns it with an error: nil whenever the error is not",
+                                  type_text)
+            if nilable is False:
+                return result(False, f"{name} returns a {type_text}", type_text)
+            return result(None, f"{name} returns a {type_text}, which can be nil", type_text)
+        # A copy of another variable, field or package value
+        type_text = self._value_type(path, func, {"chain": chain})
+        nilable = self._nilable(type_text, path, func)
+        text = ".".join(chain)
+        if nilable is False:
+            return result(False, f"a copy of {text}, a {type_text}", type_text)
+        return result(None, f"a copy of {text}" + (f", a {type_text}" if type_text else ""), type_text)
+
+    # ------------------------------------------------------------------
+    # Scopes
+    # ------------------------------------------------------------------
+
+    @staticmethod
+    def _blocks(tokens: List[Token], start: int, end: int) -> Tuple[Dict[int, int], List[int]]:
+        """The closing brace of every { in tokens[start:end], and the innermost open brace at each index."""
+        closes: Dict[int, int] = {}
+        enclosing = [start] * (end + 1)
+        stack = [start]
+        for k in range(start, end + 1):
+            tok = tokens[k]
+            if k > start and tok.kind == "op" and tok.value == "{":
+                stack.append(k)
+            elif tok.kind == "op" and tok.value == "}" and len(stack) > 1:
+                closes[stack.pop()] = k
+            enclosing[k] = stack[-1]
+        closes[start] = end
+        return closes, enclosing
+
+    @staticmethod
+    def _header_block(tokens: List[Token], idx: int, end: int) -> Optional[int]:
+        """The { opening the block after the if, for or switch header idx is in."""
+        depth = 0
+        for k in range(idx, end):
+            tok = tokens[k]
+            if tok.kind != "op":
+                continue
+            if tok.value in ("(", "["):
+                depth += 1
+            elif tok.value in (")", "]"):
+                depth -= 1
+            elif tok.value == "{" and depth == 0 and not _type_brace(tokens, k):
+                # A composite literal `T{` in a header sits in parentheses; this is the block
+                return k
+        return None
+
+    def _scope_end(self, tokens: List[Token], block: int, closes: Dict[int, int], end: int, chain_else: bool) -> int:
+        """Where a declaration scoped to the block at tokens[block] ends: its }, or an if's last else."""
+        close = closes.get(block, end)
+        while chain_else and _is(tokens, close + 1, "else"):
+            nxt = close + 2
+            if _is(tokens, nxt, "if"):
+                nxt = self._header_block(tokens, nxt, end)
+                if nxt is None:
+                    break
+            close = closes.get(nxt, end)
+        return close
+
+    # ------------------------------------------------------------------
+    # The trace
+    # ------------------------------------------------------------------
+
+    def _function(self, key: Tuple[str, str]) -> Tuple[str, Dict[str, Any], Dict[str, Any]]:
+        """The file, symbol and parsed function dict of a call graph node."""
+        node = self.graph.nodes[key]
+        symbol = self.graph.functions[key]
+        path = node["path"]
+        func = next((f for f in self.project.files[path].get("functions", [])
+                     if f["name"] == symbol["name"] and f.get("start_line") == symbol["start_line"]), {"locals": {}})
+        return path, symbol, func
+
+    def trace(self, key: Tuple[str, str], variable: str) -> Dict[str, Any]:
+        """
+        Every assignment to variable in the function with node key, whether
+        each can leave it nil, and the dereferences and pointer passes that
+        follow (see the module docstring).
+        """
+        path, symbol, func = self._function(key)
+        source = self._read(path) or ""
+        tokens, _ = tokenize(source)
+        lines = source_lines(source)
+        function = {"name": self.graph.nodes[key]["name"], "package": self.graph.nodes[key].get("package", ""),
+                    "path": path, "start_line": symbol["start_line"], "end_line": symbol.get("end_line"),
+                    "signature": symbol.get("signature", "")}
+        result: Dict[str, Any] = {"function": function, "variable": variable, "declarations": [],
+                                  "assignments": [], "dereferences": [], "pointer_passes": [], "nil_checks": []}
+
+        func_idx = next((k for k, t in enumerate(tokens) if t.line >= symbol["start_line"]
+                         and t.kind == "keyword" and t.value == "func"), None)
+        end_line = symbol.get("end_line", symbol["start_line"])
+        close = next((k for k in range(len(tokens) - 1, -1, -1) if tokens[k].line == end_line
+                      and _is(tokens, k, "}")), None)
+        if func_idx is None or close is None or close < func_idx:
+            result.update({"total_count": 0, "message": f"{function['name']} has no body to trace"})
+            return result
+        depth, body = 0, None
+        for k in range(close, func_idx, -1):
+            if _is(tokens, k, "}"):
+                depth += 1
+            elif _is(tokens, k, "{"):
+                depth -= 1
+                if depth == 0:
+                    body = k
+                    break
+        if body is None:
+            result.update({"total_count": 0, "message": f"{function['name']} has no body to trace"})
+            return result
+        closes, enclosing = self._blocks(tokens, body, close)
+
+        declarations: List[Dict[str, Any]] = []
+        # Per declaration: token index of each assignment, of each nil comparison
+        assigned_at: List[List[Tuple[int, Dict[str, Any]]]] = []
+        checked_at: List[List[Tuple[int, int]]] = []
+
+        def text_of(a: int, b: int) -> str:
+            return source[tokens[a].start:tokens[b - 1].end] if a < b else ""
+
+        def line_text(line: int) -> str:
+            return lines[line - 1].strip() if 0 < line <= len(lines) else ""
+
+        def declare(idx: int, kind: str, scope: Tuple[int, int], type_text: Optional[str] = None) -> int:
+            entry: Dict[str, Any] = {"line": tokens[idx].line, "column": tokens[idx].col, "kind": kind,
+                                     "scope": {"start_line": tokens[scope[0]].line, "end_line": tokens[scope[1]].line}}
+            if type_text:
+                entry["type"] = type_text
+            if any(d["_scope"][0] <= idx <= d["_scope"][1] for d in declarations):
+                entry["shadows"] = True
+            entry["_scope"] = scope
+            entry["_block"] = enclosing[idx] if kind != "parameter" else scope[0]
+            declarations.append(entry)
+            assigned_at.append([])
+            checked_at.append([])
+            return len(declarations) - 1
+
+        def visible(idx: int) -> Optional[int]:
+            best = None
+            for number, decl in enumerate(declarations):
+                if decl["_scope"][0] <= idx <= decl["_scope"][1]:
+                    best = number
+            return best
+
+        def assign(number: int, idx: int, kind: str, facts: Dict[str, Any]):
+            entry = {"line": tokens[idx].line, "column": tokens[idx].col, "kind": kind, "declaration": number + 1,
+                     "text": line_text(tokens[idx].line), **facts}
+            result["assignments"].append(entry)
+            assigned_at[number].append((idx, entry))
+            if "type" in facts and "type" not in declarations[number]:
+                declarations[number]["type"] = facts["type"]
+
+        # The function's parameters, receiver and named results: in scope in the whole body
+        signature_params = [p for p in symbol.get("params", []) if p.get("name") == variable]
+        receiver = symbol.get("receiver") or {}
+        named_results = [r for r in symbol.get("results", []) if r.get("name") == variable]
+        if signature_params or receiver.get("name") == variable or named_results:
+            name_idx = next((k for k in range(func_idx, body) if tokens[k].kind == "ident"
+                             and tokens[k].value == variable), func_idx)
+            if signature_params or receiver.get("name") == variable:
+                type_text = signature_params[0]["type"] if signature_params else \
+                    ("*" if receiver.get("pointer") else "") + receiver.get("type", "")
+                number = declare(name_idx, "parameter", (body, close), type_text)
+                nilable = self._nilable(type_text, path, func)
+                assign(number, name_idx, "parameter", {
+                    "can_be_nil": nilable,
+                    "nil_reason": "a parameter of a nilable type: the caller may pass nil" if nilable else
+                    f"a {type_text} parameter" if nilable is False else "a parameter of a type the tracer cannot "
+                                                                         "resolve"})
+            else:
+                type_text = named_results[0]["type"]
+                number = declare(name_idx, "parameter", (body, close), type_text)
+                nilable = self._nilable(type_text, path, func)
+                assign(number, name_idx, "parameter", {
+                    "can_be_nil": nilable,
+                    "nil_reason": "a named result: nil until assigned" if nilable else "a named result"})
+
+        # var ( ... ) groups: their specs start after ( or ;
+        var_groups = []
+        for k in range(body, close):
+            if _is(tokens, k, "var") and _is(tokens, k + 1, "("):
+                var_groups.append((k + 1, _match_forward(tokens, k + 1, close)))
+
+        def in_var_group(k: int) -> bool:
+            return any(open_ < k < end_ for open_, end_ in var_groups)
+
+        k = body + 1
+        while k < close:
+            tok = tokens[k]
+            # A func literal's parameters
+            if tok.kind == "keyword" and tok.value == "func" and _is(tokens, k + 1, "("):
+                params_close = _match_forward(tokens, k + 1, close)
+                literal_body = next((j for j in range(params_close + 1, close) if _is(tokens, j, "{")
+                                     and not (tokens[j - 1].kind in ("ident", "keyword")
+                                              and tokens[j - 1].value in ("struct", "interface"))), None)
+                for a, b in _split(tokens, k + 2, params_close):
+                    if b - a >= 2 and tokens[a].kind == "ident" and tokens[a].value == variable and literal_body:
+                        type_text = text_of(a + 1, b)
+                        number = declare(a, "parameter", (literal_body, closes.get(literal_body, close)), type_text)
+                        nilable = self._nilable(type_text, path, func)
+                        assign(number, a, "parameter", {
+                            "can_be_nil": nilable,
+                            "nil_reason": "a func literal's parameter of a nilable type" if nilable else
+                            f"a func literal's {type_text} parameter"})
+                k = params_close + 1
+                continue
+            if tok.kind != "ident" or tok.value != variable or _is(tokens, k - 1, ".") or \
+                    (_is(tokens, k + 1, ":") and not in_var_group(k)):
+                k += 1
+                continue
+            handled = self._statement(tokens, k, body, close, closes, enclosing, in_var_group, variable,
+                                      declarations, declare, visible, assign, path, func)
+            if not handled:
+                self._use(tokens, k, close, closes, enclosing, visible, declarations, result, checked_at, text_of)
+            k += 1
+
+        # Dereferences: the assignment each follows and whether a nil check lies between
+        for deref in result["dereferences"]:
+            number = deref["declaration"] - 1
+            idx = deref.pop("_idx")
+            before = [(at, entry) for at, entry in assigned_at[number] if at < idx]
+            if before:
+                at, entry = before[-1]
+                deref["after_line"] = entry["line"]
+                deref["after_can_be_nil"] = entry["can_be_nil"]
+                if any(at < guard_start < idx <= guard_end for guard_start, guard_end in checked_at[number]):
+                    deref["nil_checked"] = True
+        # Of a declaration known not to be nilable, nothing is a nil dereference
+        result["dereferences"] = [d for d in result["dereferences"]
+                                  if self._nilable(declarations[d["declaration"] - 1].get("type"), path, func)
+                                  is not False]
+        for number, decl in enumerate(declarations):
+            decl.pop("_scope")
+            decl.pop("_block")
+            values = [entry["can_be_nil"] for _, entry in assigned_at[number]]
+            decl["can_be_nil"] = True if any(v is True for v in values) else \
+                (None if any(v is None for v in values) else False)
+        result["declarations"] = [{"declaration": n + 1, **d} for n, d in enumerate(declarations)]
+        result["total_count"] = len(result["assignments"])
+
+        nilable = [a for a in result["assignments"] if a["can_be_nil"] is True]
+        unchecked = [d for d in result["dereferences"] if not d.get("nil_checked")
+                     and d.get("after_can_be_nil") is not False]
+        if not declarations:
+            result["message"] = f"No variable named '{variable}' is declared in {function['name']}"
+        elif nilable:
+            result["message"] = (f"{len(nilable)} of {len(result['assignments'])} assignments of {variable} can leave "
+                                 f"it nil (line {', '.join(str(a['line']) for a in nilable)}); "
+                                 f"{len(unchecked)} dereferences are not guarded by a nil check")
+        return result
+
+    def _statement(self, tokens: List[Token], k: int, body: int, close: int, closes: Dict[int, int],
+                   enclosing: List[int], in_var_group: Callable[[int], bool], variable: str,
+                   declarations: List[Dict[str, Any]], declare: Callable[..., int],
+                   visible: Callable[[int], Optional[int]], assign: Callable[..., None],
+                   path: str, func: Dict[str, Any]) -> bool:
+        """Record the assignment tokens[k] is the target of, if it is one; False for any other use."""
+        # The statement start: back over the left-hand side list
+        start, depth = k, 0
+        while start - 1 > body:
+            prev = tokens[start - 1]
+            if prev.kind == "op" and prev.value in (")", "]"):
+                depth += 1
+            elif prev.kind == "op" and prev.value in ("(", "["):
+                if depth == 0:
+                    break
+                depth -= 1
+            elif depth == 0 and not (prev.kind == "ident" or (prev.kind == "op" and prev.value in _LHS_OPS)):
+                break
+            start -= 1
+        before = tokens[start - 1]
+        group = in_var_group(k) and (_is(tokens, start - 1, "(") or _is(tokens, start - 1, ";"))
+        is_var = _is(tokens, start - 1, "var") or group
+        if depth == 0 and _is(tokens, start - 1, "(") and not group:
+            return False
+        if is_var:
+            # var a, v T / var a, v = x, y
+            names, j = [], start
+            while j < close and tokens[j].kind == "ident":
+                names.append(j)
+                if not _is(tokens, j + 1, ","):
+                    break
+                j += 2
+            position = next((n for n, a in enumerate(names) if a == k), None)
+            if position is None:
+                return False
+            spec_start = spec_end = names[-1] + 1
+            while spec_end < close and not (_is(tokens, spec_end, ";") or _is(tokens, spec_end, "=")
+                                            or (group and _is(tokens, spec_end, ")"))):
+                if tokens[spec_end].kind == "op" and tokens[spec_end].value in ("(", "[", "{"):
+                    spec_end = _match_forward(tokens, spec_end, close)
+                spec_end += 1
+            type_text = _type_text(tokens, spec_start, spec_end) or None
+            statement_end = spec_end
+            facts: Dict[str, Any]
+            if _is(tokens, spec_end, "="):
+                statement_end = self._rhs_end(tokens, spec_end + 1, close, False)
+                facts = self._rhs(tokens, spec_end + 1, statement_end, position, len(names),
+                                  len(_split(tokens, spec_end + 1, statement_end)), path, func)
+            else:
+                nilable = self._nilable(type_text, path, func)
+                facts = {"can_be_nil": nilable,
+                         "nil_reason": f"declared without a value: the zero value of {type_text}"
+                         + (" is nil" if nilable else "")}
+                if type_text:
+                    facts["type"] = type_text
+            block = enclosing[k]
+            number = declare(k, "var", (statement_end, closes.get(block, close)), type_text or facts.get("type"))
+            assign(number, k, "var", facts)
+            return True
+
+        # The assignment operator: forward over the rest of the left-hand side
+        op, depth = k + 1, 0
+        while op < close:
+            tok = tokens[op]
+            if tok.kind == "op" and tok.value in ("(", "["):
+                depth += 1
+            elif tok.kind == "op" and tok.value in (")", "]"):
+                if depth == 0:
+                    return False
+                depth -= 1
+            elif depth == 0 and not (tok.kind == "ident" or (tok.kind == "op" and tok.value in _LHS_OPS)):
+                break
+            op += 1
+        if op >= close:
+            return False
+        operator = tokens[op]
+        header = before.kind == "keyword" and before.value in ("if", "for", "switch")
+        case = before.kind == "keyword" and before.value == "case"
+
+        if operator.kind == "op" and operator.value in _COMPOUND | {"++", "--"} and op == k + 1:
+            number = visible(k)
+            if number is None:
+                return True
+            assign(number, k, "compound", {"can_be_nil": False,
+                                           "nil_reason": f"{operator.value} on a number or string"})
+            return True
+        if not (operator.kind == "op" and operator.value in (":=", "=")):
+            return False
+        targets = _split(tokens, start, op)
+        position = next((n for n, (a, b) in enumerate(targets) if a == k and b == k + 1), None)
+        if position is None:
+            # v.Field = x, v[i] = x, *v = x: a use of v, not an assignment to it
+            return False
+        rhs_start = op + 1
+        rhs_end = self._rhs_end(tokens, rhs_start, close, header or case)
+        ranged = _is(tokens, rhs_start, "range")
+        if operator.value == ":=":
+            block = enclosing[k]
+            number = visible(k)
+            # := assigns a variable already declared in the same block, declaring the others
+            redeclared = number is not None and declarations[number]["_block"] == block and not header and not case
+            if not redeclared:
+                if header:
+                    opening = self._header_block(tokens, k, close)
+                    scope = (rhs_end, self._scope_end(tokens, opening, closes, close, before.value == "if")) \
+                        if opening is not None else (rhs_end, closes.get(block, close))
+                elif case:
+                    scope = (rhs_end, self._clause_end(tokens, rhs_end, close, closes, enclosing))
+                else:
+                    scope = (rhs_end, closes.get(block, close))
+                kind = "range" if ranged else "short_decl"
+                number = declare(k, kind, scope)
+        else:
+            number = visible(k)
+            if number is None:
+                return True
+        if ranged:
+            expr = self._chain(tokens, rhs_start + 1, rhs_end)
+            facts = {"can_be_nil": False, "nil_reason": "a range key"} if position == 0 else \
+                self._range_value(expr, path, func)
+            assign(number, k, "range", facts)
+            return True
+        facts = self._rhs(tokens, rhs_start, rhs_end, position, len(targets),
+                          len(_split(tokens, rhs_start, rhs_end)), path, func)
+        if facts.get("nil_reason", "").startswith("a type switch binding"):
+            kind = "type_switch"
+            declarations[number]["kind"] = "type_switch"
+        else:
+            kind = "short_decl" if operator.value == ":=" and declarations[number]["line"] == tokens[k].line \
+                and declarations[number]["column"] == tokens[k].col else "assign"
+        assign(number, k, kind, facts)
+        return True
+
+    def _range_value(self, chain: Optional[List[str]], path: str, func: Dict[str, Any]) -> Dict[str, Any]:
+        """Whether the value a range loop assigns can be nil, from the element type of what it ranges over."""
+        if chain is None:
+            return {"can_be_nil": None, "nil_reason": "a range value of an expression the tracer cannot type"}
+        element = self._value_type(path, func, {"range": {"chain": chain}, "index": 1})
+        nilable = self._nilable(element, path, func)
+        facts: Dict[str, Any] = {
+            "can_be_nil": None if nilable else False,
+            "nil_reason": f"a range value of {'.'.join(chain)}" + (f": {element} elements, nil if one is stored"
+                                                                   if nilable else f", a {element}" if element else ""),
+        }
+        if element:
+            facts["type"] = element
+        return facts
+
+    @staticmethod
+    def _rhs_end(tokens: List[Token], idx: int, upper: int, header: bool) -> int:
+        """Where the right-hand side starting at idx ends: a ; or, in a header, the block's {."""
+        depth = 0
+        while idx < upper:
+            tok = tokens[idx]
+            if tok.kind == "op":
+                if tok.value in ("(", "["):
+                    depth += 1
+                elif tok.value in (")", "]"):
+                    if depth == 0:
+                        return idx
+                    depth -= 1
+                elif tok.value == "{":
+                    if depth == 0 and header and not _type_brace(tokens, idx):
+                        return idx
+                    idx = _match_forward(tokens, idx, upper) + 1
+                    continue
+                elif tok.value == "}" and depth == 0:
+                    return idx
+                elif tok.value in (";", ":") and depth == 0:
+                    return idx
+            idx += 1
+        return upper
+
+    @staticmethod
+    def _clause_end(tokens: List[Token], idx: int, upper: int, closes: Dict[int, int], enclosing: List[int]) -> int:
+        """The end of the select or switch clause idx is in: the next case or default, or the block's }."""
+        block = enclosing[idx]
+        end = closes.get(block, upper)
+        for k in range(idx + 1, end):
+            if enclosing[k] == block and tokens[k].kind == "keyword" and tokens[k].value in ("case", "default"):
+                return k - 1
+        return end
+
+    def _guard(self, tokens: List[Token], k: int, close: int, closes: Dict[int, int], enclosing: List[int],
+               comparison: str) -> Optional[Tuple[int, int]]:
+        """
+        The token range a nil comparison in an if header guards: the if's
+        block for `v != nil`, the rest of the enclosing block after it for
+        `if v == nil { return }`; None for a comparison anywhere else.
+        """
+        j = k - 1
+        while j > 0 and not (tokens[j].kind == "keyword" and tokens[j].value == "if"):
+            if _is(tokens, j, ";") or _is(tokens, j, "{") or _is(tokens, j, "}"):
+                return None
+            j -= 1
+        block = self._header_block(tokens, k, close)
+        if block is None:
+            return None
+        if comparison == "!=":
+            return block, closes.get(block, close)
+        end = closes.get(block, close)
+        exits = [tokens[i] for i in range(block + 1, end) if enclosing[i] == block]
+        if not any(t.kind == "keyword" and t.value in ("return", "continue", "break", "goto") or
+                   (t.kind == "ident" and t.value in ("panic", "Fatal", "Fatalf", "Exit")) for t in exits):
+            return None
+        return end, closes.get(enclosing[j], close)
+
+    def _use(self, tokens: List[Token], k: int, close: int, closes: Dict[int, int], enclosing: List[int],
+             visible: Callable[[int], Optional[int]], declarations: List[Dict[str, Any]], result: Dict[str, Any],
+             checked_at: List[List[Tuple[int, int]]], text_of: Callable[[int, int], str]):
+        """Record a read of the variable at tokens[k]: a dereference, a pointer pass or a nil comparison."""
+        number = visible(k)
+        if number is None:
+            return
+        tok = tokens[k]
+        site = {"line": tok.line, "column": tok.col, "declaration": number + 1}
+
+        # v == nil, nil != v
+        if (_is(tokens, k + 1, "==") or _is(tokens, k + 1, "!=")) and tokens[k + 2].value == "nil" or \
+                (_is(tokens, k - 1, "==") or _is(tokens, k - 1, "!=")) and tokens[k - 2].value == "nil":
+            reversed_ = tokens[k - 2].value == "nil"
+            comparison = tokens[k - 1 if reversed_ else k + 1].value
+            check = {**site, "text": text_of(k - 2 if reversed_ else k, k + 1 if reversed_ else k + 3)}
+            guard = self._guard(tokens, k, close, closes, enclosing, comparison)
+            if guard:
+                check["guards_lines"] = {"start_line": tokens[guard[0]].line, "end_line": tokens[guard[1]].line}
+                checked_at[number].append(guard)
+            result["nil_checks"].append(check)
+            return
+        # The selector chain after the name
+        end = k + 1
+        while _is(tokens, end, ".") and end + 1 < close and tokens[end + 1].kind == "ident":
+            end += 2
+        address = _is(tokens, k - 1, "&") and not _operand_end(tokens[k - 2])
+        if address:
+            call = None
+            depth = 0
+            for j in range(k - 2, -1, -1):
+                if _is(tokens, j, ")"):
+                    depth += 1
+                elif _is(tokens, j, "("):
+                    if depth == 0:
+                        call = tokens[j - 1].value if tokens[j - 1].kind == "ident" else None
+                        break
+                    depth -= 1
+                elif _is(tokens, j, ";") or _is(tokens, j, "{") or _is(tokens, j, "}"):
+                    break
+            passed = {**site, "text": text_of(k - 1, end)}
+            if call:
+                passed["call"] = call
+            result["pointer_passes"].append(passed)
+        if end > k + 1:
+            kind = "method_call" if _is(tokens, end, "(") and end == k + 3 else "field"
+            result["dereferences"].append({**site, "kind": kind, "text": text_of(k, k + 3), "_idx": k})
+        elif _is(tokens, k - 1, "*") and not _operand_end(tokens[k - 2]):
+            result["dereferences"].append({**site, "kind": "star", "text": text_of(k - 1, k + 1), "_idx": k})
+        elif _is(tokens, k + 1, "["):
+            type_text = declarations[number].get("type") or ""
+            index_close = _match_forward(tokens, k + 1, close)
+            if type_text.startswith("map["):
+                if _is(tokens, index_close + 1, "="):
+                    result["dereferences"].append({**site, "kind": "map_write", "text": text_of(k, index_close + 1),
+                                                   "_idx": k})
+            else:
+                result["dereferences"].append({**site, "kind": "index", "text": text_of(k, index_close + 1),
+                                               "_idx": k})
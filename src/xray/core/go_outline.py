@@ -0,0 +1,176 @@
+"""The whole shape of one Go type, wherever in its package it is declared.
+
+Go lets a type's methods live in any file of its package, tests included,
+and has no constructor syntax. TypeOutline gathers, for one project type:
+
+    type          the declaration itself, doc comment included
+    fields        a struct's fields in declaration order, embedded ones
+                  marked, with their tags and docs
+    methods       every method declared on the type (a struct's or defined
+                  type's receiver methods, an interface's method specs),
+                  exported ones first, then by name; one declared in a
+                  _test.go file is "test": true, as it exists in test
+                  builds only
+    promoted      methods of *T promoted from embedded fields
+    interfaces    the project interfaces T or *T satisfies (for an
+                  interface: the types implementing it)
+    constructors  functions of the project, any package, returning T or *T
+                  as their first result - `func NewUserService() *UserService`,
+                  `func Open(dsn string) (*DB, error)`, but also `Default()`
+                  - found by their result types, not their names;
+                  "conventional" says whether the name reads New.../new...
+
+A type alias is followed to the type it names.
+"""
+
+import os
+import re
+from typing import Any, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoProject
+from xray.core.go_tests import is_test_file
+from xray.core.report import summary_line
+
+_TYPE_ARGS = re.compile(r"\[.*\]$")
+
+
+def _exported(name: str) -> bool:
+    return name[:1].isupper()
+
+
+class TypeOutline:
+    """Assembles the outline of one project type from a GoProject."""
+
+    def __init__(self, project: GoProject):
+        self.project = project
+
+    def _member(self, record: Dict[str, Any]) -> Dict[str, Any]:
+        member = {
+            "name": record["name"],
+            "signature": record.get("signature"),
+            "exported": _exported(record["name"]),
+            "path": record["path"],
+            "line": record["start_line"],
+        }
+        if record.get("doc"):
+            member["doc"] = summary_line(record["doc"])
+        return member
+
+    def _fields(self, key: Tuple[str, str]) -> List[Dict[str, Any]]:
+        fields = []
+        for record in self.project.fields.get(key, []):
+            field = self._member(record)
+            field["type"] = record["field_type"]
+            if record.get("embedded"):
+                field["embedded"] = True
+                if record.get("embedded_pointer"):
+                    field["pointer"] = True
+            if record.get("tag"):
+                field["tag"] = record["tag"]
+            fields.append(field)
+        return fields
+
+    def _methods(self, key: Tuple[str, str], interface: bool) -> List[Dict[str, Any]]:
+        records = self.project.interface_methods.get(key, []) if interface else self.project.methods.get(key, [])
+        methods = []
+        for record in records:
+            method = self._member(record)
+            if not interface:
+                method["pointer_receiver"] = bool(record["receiver"].get("pointer"))
+            if is_test_file(record["path"]):
+                method["test"] = True
+            methods.append(method)
+        methods.sort(key=lambda m: (not m["exported"], m["name"], m["path"], m["line"]))
+        return methods
+
+    def _promoted(self, key: Tuple[str, str]) -> List[Dict[str, Any]]:
+        promoted = []
+        for name, record in sorted(self.project.concrete_method_set(*key, pointer=True).items()):
+            if "promoted_via" not in record:
+                continue
+            method = self._member(record)
+            method["via"] = record["promoted_via"]
+            promoted.append(method)
+        return promoted
+
+    def _returned_type(self, path: str, type_text: str) -> Optional[Tuple[str, str]]:
+        """The project type a result type names, directly or through one pointer."""
+        text = type_text.strip()
+        if text.startswith("*"):
+            text = text[1:]
+        if not re.match(r"^[A-Za-z_][\w.]*(?:\[.*\])?$", text):
+            # Slices, maps, channels, funcs: a collection of T is no constructor
+            return None
+        return self.project.resolve_type_ref(os.path.dirname(path), _TYPE_ARGS.sub("", text))
+
+    def _constructors(self, key: Tuple[str, str]) -> List[Dict[str, Any]]:
+        constructors = []
+        for path, parsed in sorted(self.project.files.items()):
+            for symbol in parsed["symbols"]:
+                if symbol["type"] != "function" or symbol.get("receiver") or not symbol.get("results"):
+                    continue
+                result = symbol["results"][0]["type"]
+                if self._returned_type(path, result) != key:
+                    continue
+                constructor = self._member({**symbol, "path": path})
+                constructor["package"] = parsed.get("package", "")
+                constructor["returns"] = result
+                if any(r["type"] == "error" for r in symbol["results"][1:]):
+                    constructor["returns_error"] = True
+                constructor["conventional"] = bool(re.match(r"^[Nn]ew(?:[A-Z_]|$)", symbol["name"]))
+                if is_test_file(path):
+                    constructor["test"] = True
+                constructors.append(constructor)
+        # Same package first, then the conventional names
+        constructors.sort(key=lambda c: (os.path.dirname(c["path"]) != key[0], c.get("test", False),
+                                         not c["conventional"], c["name"], c["path"]))
+        return constructors
+
+    def build(self, target: Dict[str, Any]) -> Dict[str, Any]:
+        """The outline of a type symbol of the project (as GoProject.types holds them)."""
+        key = (os.path.dirname(target["path"]), target["name"])
+        if target.get("alias"):
+            resolved = self.project.alias_chain(key)["target_key"]
+            if resolved is None:
+                raise ValueError(f"'{target['name']}' is an alias of a type outside the project")
+            key, target = resolved, self.project.types[resolved]
+        interface = target["type"] == "interface"
+        outline: Dict[str, Any] = {
+            "type": {
+                "name": target["name"],
+                "kind": target["type"],
+                "package": target["package"],
+                "import_path": self.project.import_path(key[0]),
+                "signature": target.get("signature"),
+                "path": target["path"],
+                "start_line": target["start_line"],
+                "end_line": target.get("end_line"),
+                "doc": target.get("doc") or None,
+            },
+        }
+        if target["type"] == "struct":
+            outline["fields"] = self._fields(key)
+        outline["methods"] = self._methods(key, interface)
+        if interface:
+            implementations = self.project.implementations_of(target)["implementations"]
+            outline["embeds"] = target.get("embeds", [])
+            outline["implementations"] = [
+                {k: entry[k] for k in ("name", "package", "path", "start_line", "satisfied_by") if k in entry}
+                for entry in implementations
+            ]
+        else:
+            outline["promoted"] = self._promoted(key)
+            outline["interfaces"] = [
+                {k: entry[k] for k in ("name", "package", "path", "start_line", "satisfied_by") if k in entry}
+                for entry in self.project.interfaces_of(target)["interfaces"]
+            ]
+        outline["constructors"] = self._constructors(key)
+        outline["counts"] = {
+            "methods": len(outline["methods"]),
+            "exported_methods": sum(1 for m in outline["methods"] if m["exported"]),
+            "test_methods": sum(1 for m in outline["methods"] if m.get("test")),
+            "constructors": len(outline["constructors"]),
+        }
+        if "fields" in outline:
+            outline["counts"]["fields"] = len(outline["fields"])
+        return outline
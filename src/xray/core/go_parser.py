@@ -0,0 +1,3549 @@
+"""Go source parser for XRAY - tokenizer and declaration parser.
+
+The regex patterns used for JS/TS cannot cope with Go's newer syntax
+(generics in particular), so Go files get a small hand-written lexer and a
+recursive-descent parser for top-level declarations. Function bodies are
+not parsed into a full AST; they are kept as token ranges for later passes.
+"""
+
+import bisect
+import hashlib
+import heapq
+import re
+import zlib
+from typing import Dict, List, Optional, Any, Set, Tuple
+
+from xray.core.errors import PARSE_ERROR
+from xray.core.go_build import BuildConstraintError, file_constraints
+
+# Bump whenever the shape of extracted records changes so cached results are discarded
+PARSER_VERSION = 37
+
+KEYWORDS = {
+    "break", "case", "chan", "const", "continue", "default", "defer", "else",
+    "fallthrough", "for", "func", "go", "goto", "if", "import", "interface",
+    "map", "package", "range", "return", "select", "struct", "switch", "type", "var",
+}
+
+# Longest operators first so the lexer can match greedily
+OPERATORS = [
+    "<<=", ">>=", "&^=", "...",
+    "&&", "||", "<-", "++", "--", "==", "!=", "<=", ">=", ":=",
+    "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<", ">>", "&^",
+    "+", "-", "*", "/", "%", "&", "|", "^", "<", ">", "=", "!", "~",
+    "(", ")", "[", "]", "{", "}", ",", ";", ".", ":",
+]
+
+# Lines git writes around the two sides of a merge conflict (||||||| with diff3)
+_CONFLICT_MARKER = re.compile(r"^(?:<{7}|\|{7}|={7}|>{7})(?:[ \t].*)?$", re.MULTILINE)
+
+_CLOSERS = {"(": ")", "[": "]", "{": "}"}
+
+# Test function prefixes of `go test` -> (test kind, parameter type in package testing)
+_TEST_PREFIXES = (("Test", "test", "T"), ("Benchmark", "benchmark", "B"),
+                  ("Fuzz", "fuzz", "F"), ("Example", "example", None))
+
+# Fields that name the cases of a table-driven test
+_CASE_NAME_FIELDS = ("name", "desc", "description", "title", "testname", "scenario")
+MAX_CASE_NAMES = 50
+
+# Keywords that begin a top-level declaration; gofmt puts them in column 1
+_DECL_KEYWORDS = ("func", "type", "var", "const", "import")
+
+# Tokens after which a newline inserts an implicit semicolon (Go spec)
+_SEMI_KINDS = {"ident", "int", "float", "imag", "char", "string"}
+_SEMI_KEYWORDS = {"break", "continue", "fallthrough", "return"}
+_SEMI_OPS = {"++", "--", ")", "]", "}"}
+# Go number literals start with ASCII digits only
+_DIGITS = "0123456789"
+
+
+class GoSyntaxError(Exception):
+    """Raised when the parser cannot make sense of a declaration."""
+
+    error_code = PARSE_ERROR
+
+    def __init__(self, message: str, line: int = 0, col: int = 0):
+        super().__init__(message)
+        self.line = line
+        self.col = col
+
+
+class Token:
+    """A single Go token with its source position."""
+
+    __slots__ = ("kind", "value", "line", "col", "end_line", "end_col", "start", "end", "implicit")
+
+    def __init__(self, kind, value, line, col, end_line, end_col, start, end, implicit=False):
+        self.kind = kind
+        self.value = value
+        self.line = line
+        self.col = col
+        self.end_line = end_line
+        self.end_col = end_col
+        self.start = start
+        self.end = end
+        self.implicit = implicit
+
+    def __repr__(self):
+        return f"Token({self.kind}, {self.value!r}, {self.line}:{self.col})"
+
+
+def conflict_regions(src: str) -> Tuple[str, List[Dict[str, Any]]]:
+    """
+    Find merge conflict markers and blank them out.
+
+    Returns the source with every marker line replaced by spaces, so both
+    sides of a conflict are parsed and positions stay put, and one error of
+    kind "merge_conflict" per conflict, from its <<<<<<< line to its
+    >>>>>>> line (or the end of the file).
+    """
+    markers = list(_CONFLICT_MARKER.finditer(src))
+    if not any(m.group().startswith("<<<<<<<") for m in markers):
+        return src, []
+    regions: List[Dict[str, Any]] = []
+    parts = []
+    last = 0
+    current: Optional[Dict[str, Any]] = None
+    for match in markers:
+        text = match.group()
+        label = text[7:].strip()
+        line = src.count("\n", 0, match.start()) + 1
+        if text.startswith("<<<<<<<"):
+            if current is not None:
+                regions.append(current)
+            current = {"kind": "merge_conflict", "message": "unresolved merge conflict",
+                       "line": line, "column": 1, "end_line": line, "ours": label}
+        elif current is None:
+            # A line of equals signs or the like outside any conflict
+            continue
+        elif text.startswith(">>>>>>>"):
+            current.update({"end_line": line, "theirs": label})
+            regions.append(current)
+            current = None
+        parts.append(src[last:match.start()])
+        parts.append(" " * len(text))
+        last = match.end()
+    if current is not None:
+        current["end_line"] = src.count("\n") + 1
+        regions.append(current)
+    for region in regions:
+        sides = [region.pop("ours", ""), region.pop("theirs", "")]
+        if any(sides):
+            region["message"] += f" ({sides[0] or '?'} vs {sides[1] or '?'})"
+    parts.append(src[last:])
+    return "".join(parts), regions
+
+
+def tokenize(src: str, errors: Optional[List[Dict[str, Any]]] = None) -> Tuple[List[Token], List[Token]]:
+    """
+    Split Go source into tokens, inserting semicolons per the spec.
+
+    Lexical errors do not stop the lexer: a string or comment left open ends
+    at the end of its line instead of swallowing the rest of the file, and
+    stray characters are skipped. Each is appended to errors if given.
+
+    Returns:
+        (tokens, comments) - comments are returned separately so the parser
+        can ignore them while doc extraction can still find them.
+    """
+    def error(message: str, line: int, col: int):
+        if errors is not None:
+            errors.append({"kind": "syntax", "message": message, "line": line, "column": col})
+
+    tokens: List[Token] = []
+    comments: List[Token] = []
+    i = 0
+    n = len(src)
+    line = 1
+    line_start = 0
+
+    def needs_semi() -> bool:
+        if not tokens:
+            return False
+        last = tokens[-1]
+        if last.kind in _SEMI_KINDS:
+            return True
+        if last.kind == "keyword" and last.value in _SEMI_KEYWORDS:
+            return True
+        return last.kind == "op" and last.value in _SEMI_OPS
+
+    def insert_semi():
+        last = tokens[-1]
+        tokens.append(Token("op", ";", last.end_line, last.end_col, last.end_line, last.end_col,
+                            last.end, last.end, implicit=True))
+
+    while i < n:
+        ch = src[i]
+
+        if ch == "\n":
+            if needs_semi():
+                insert_semi()
+            i += 1
+            line += 1
+            line_start = i
+            continue
+
+        if ch in " \t\r\ufeff":
+            i += 1
+            continue
+
+        start = i
+        col = i - line_start + 1
+
+        # Comments
+        if src.startswith("//", i):
+            end = src.find("\n", i)
+            if end == -1:
+                end = n
+            comments.append(Token("comment", src[i:end], line, col, line, col + (end - i), i, end))
+            i = end
+            continue
+        if src.startswith("/*", i):
+            end = src.find("*/", i + 2)
+            if end == -1:
+                error("comment not terminated", line, col)
+                end = src.find("\n", i)
+                end = n if end == -1 else end
+            else:
+                end += 2
+            text = src[i:end]
+            newlines = text.count("\n")
+            if newlines:
+                end_line = line + newlines
+                end_col = end - (src.rfind("\n", i, end) + 1) + 1
+            else:
+                end_line, end_col = line, col + (end - i)
+            comments.append(Token("comment", text, line, col, end_line, end_col, i, end))
+            # A multi-line general comment acts like a newline
+            if newlines and needs_semi():
+                insert_semi()
+            line += newlines
+            if newlines:
+                line_start = src.rfind("\n", i, end) + 1
+            i = end
+            continue
+
+        # Identifiers and keywords: as the spec has it, Unicode letters (category L)
+        # and "_", followed by those and decimal digits (category Nd) - not "²"
+        if ch.isalpha() or ch == "_":
+            while i < n and (src[i].isalpha() or src[i].isdecimal() or src[i] == "_"):
+                i += 1
+            word = src[start:i]
+            kind = "keyword" if word in KEYWORDS else "ident"
+            tokens.append(Token(kind, word, line, col, line, col + (i - start), start, i))
+            continue
+
+        # Numbers
+        if ch in _DIGITS or (ch == "." and i + 1 < n and src[i + 1] in _DIGITS):
+            kind = "int"
+            if src.startswith(("0x", "0X"), i):
+                i += 2
+                while i < n and (src[i] in "0123456789abcdefABCDEF_." or
+                                 (src[i] in "pP") or
+                                 (src[i] in "+-" and src[i - 1] in "pP")):
+                    if src[i] in ".pP":
+                        kind = "float"
+                    i += 1
+            else:
+                while i < n and (src[i].isalnum() or src[i] in "_." or
+                                 (src[i] in "+-" and src[i - 1] in "eE" and
+                                  not src.startswith(("0b", "0B"), start))):
+                    if src[i] == "." and src.startswith("...", i):
+                        break
+                    if src[i] in ".eE" and not src.startswith(("0b", "0B", "0o", "0O"), start):
+                        kind = "float"
+                    i += 1
+            if src[i - 1] == "i":
+                kind = "imag"
+            tokens.append(Token(kind, src[start:i], line, col, line, col + (i - start), start, i))
+            continue
+
+        # Interpreted strings and runes
+        if ch in "\"'":
+            quote = ch
+            i += 1
+            while i < n and src[i] != quote and src[i] != "\n":
+                if src[i] == "\\" and i + 1 < n and src[i + 1] != "\n":
+                    i += 1
+                i += 1
+            kind = "string" if quote == '"' else "char"
+            if i < n and src[i] == quote:
+                i += 1
+            else:
+                error(f"{'string' if kind == 'string' else 'rune'} literal not terminated", line, col)
+            tokens.append(Token(kind, src[start:i], line, col, line, col + (i - start), start, i))
+            continue
+
+        # Raw strings may span lines
+        if ch == "`":
+            end = src.find("`", i + 1)
+            if end == -1:
+                error("raw string literal not terminated", line, col)
+                end = src.find("\n", i)
+                end = n if end == -1 else end
+            else:
+                end += 1
+            text = src[i:end]
+            newlines = text.count("\n")
+            if newlines:
+                end_line = line + newlines
+                new_line_start = src.rfind("\n", i, end) + 1
+                end_col = end - new_line_start + 1
+            else:
+                end_line, end_col = line, col + (end - i)
+                new_line_start = line_start
+            tokens.append(Token("string", text, line, col, end_line, end_col, start, end))
+            line = end_line
+            line_start = new_line_start
+            i = end
+            continue
+
+        # Operators and punctuation
+        for op in OPERATORS:
+            if src.startswith(op, i):
+                i += len(op)
+                tokens.append(Token("op", op, line, col, line, col + len(op), start, i))
+                break
+        else:
+            # Unknown character - skip it rather than abort the whole file
+            error(f"invalid character {ch!r}", line, col)
+            i += 1
+
+    if needs_semi():
+        insert_semi()
+    return tokens, comments
+
+
+# Normalized body tokens: identifiers and literals by kind only, so a copy with renamed
+# variables or other constants has the same shape
+_SHAPE_KINDS = {"ident": "$", "int": "0", "float": "0", "imag": "0", "char": "'", "string": '"'}
+# Shingles of this many shape tokens make up a body's sketch; the SKETCH_SIZE smallest
+# of their hashes are kept for bodies of at least SKETCH_MIN_TOKENS tokens
+SHINGLE = 4
+SKETCH_SIZE = 16
+SKETCH_MIN_TOKENS = 20
+
+
+def body_shape(tokens: List[Token]) -> List[str]:
+    """A function body's tokens with identifiers and literals replaced by their kind."""
+    return [_SHAPE_KINDS.get(tok.kind, tok.value) for tok in tokens]
+
+
+def body_fingerprint(shape: List[str]) -> Dict[str, Any]:
+    """
+    The size of a body shape, a hash of it - equal for bodies that differ
+    only in names and literals - and, for bodies large enough to be worth
+    comparing, a bottom-k sketch of its shingles, for finding near copies
+    without comparing every pair of bodies (see core/go_clones.py).
+    """
+    text = " ".join(shape)
+    result: Dict[str, Any] = {"body_tokens": len(shape),
+                              "body_hash": hashlib.blake2b(text.encode(), digest_size=8).hexdigest()}
+    if len(shape) >= SKETCH_MIN_TOKENS:
+        shingles = {zlib.crc32(" ".join(shape[i:i + SHINGLE]).encode()) for i in range(len(shape) - SHINGLE + 1)}
+        result["body_sketch"] = sorted(heapq.nsmallest(SKETCH_SIZE, shingles))
+    return result
+
+
+def unquote(literal: str) -> str:
+    """Return the contents of a Go string literal without its quotes."""
+    if len(literal) >= 2 and literal[0] == literal[-1] and literal[0] in "\"`":
+        body = literal[1:-1]
+        if literal[0] == '"':
+            try:
+                return bytes(body, "utf-8").decode("unicode_escape").encode("latin-1").decode("utf-8")
+            except (UnicodeDecodeError, UnicodeEncodeError):
+                return body
+        return body
+    return literal
+
+
+# //go:build and legacy // +build lines in a file's header
+_BUILD_LINE = re.compile(r"^(//go:build|//\s*\+build)(?:\s+(.*))?$")
+
+# Tool directives such as //go:generate - no space after the slashes
+_DIRECTIVE = re.compile(r"^//go:[a-z]")
+
+
+# Predeclared identifiers that can never be package-level variables of the file
+_PREDECLARED = {
+    "true", "false", "nil", "iota", "_", "append", "cap", "clear", "close", "complex", "copy",
+    "delete", "imag", "len", "make", "max", "min", "new", "panic", "print", "println", "real",
+    "recover", "any", "error", "comparable",
+}
+
+# Tokens after which `T{` opens a composite literal rather than a block
+_COMPOSITE_OPENERS = {"&", "(", ",", "=", ":=", ":", "[", "{"}
+
+_BASIC_TYPES = {
+    "bool", "string", "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16",
+    "uint32", "uint64", "uintptr", "byte", "rune", "float32", "float64", "complex64", "complex128",
+}
+
+
+class _NotConstant(Exception):
+    """Raised when an expression cannot be folded to a constant."""
+
+
+_BINARY_PRECEDENCE = {
+    "||": 1, "&&": 2,
+    "==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+    "+": 4, "-": 4, "|": 4, "^": 4,
+    "*": 5, "/": 5, "%": 5, "<<": 5, ">>": 5, "&": 5, "&^": 5,
+}
+
+
+class _ConstEvaluator:
+    """Precedence-climbing evaluator for Go constant expressions."""
+
+    def __init__(self, tokens: List[Token], iota: int, known: Dict[str, Any], type_names: set):
+        self.tokens = [t for t in tokens if not t.implicit]
+        self.pos = 0
+        self.iota = iota
+        self.known = known
+        self.type_names = type_names
+
+    def at_end(self) -> bool:
+        return self.pos >= len(self.tokens)
+
+    def _peek(self) -> Optional[Token]:
+        return self.tokens[self.pos] if self.pos < len(self.tokens) else None
+
+    def expression(self, min_prec: int) -> Any:
+        left = self.unary()
+        while True:
+            tok = self._peek()
+            if tok is None or tok.kind != "op" or tok.value not in _BINARY_PRECEDENCE:
+                return left
+            prec = _BINARY_PRECEDENCE[tok.value]
+            if prec <= min_prec:
+                return left
+            self.pos += 1
+            right = self.expression(prec)
+            left = self._apply(tok.value, left, right)
+
+    def unary(self) -> Any:
+        tok = self._peek()
+        if tok is None:
+            raise _NotConstant()
+        if tok.kind == "op" and tok.value in ("-", "+", "^", "!"):
+            self.pos += 1
+            operand = self.unary()
+            if tok.value == "-":
+                return -operand
+            if tok.value == "+":
+                return operand
+            if tok.value == "!":
+                return not operand
+            if isinstance(operand, bool) or not isinstance(operand, int):
+                raise _NotConstant()
+            return ~operand
+        return self.primary()
+
+    def primary(self) -> Any:
+        tok = self.tokens[self.pos]
+        self.pos += 1
+        if tok.kind == "int":
+            return int(tok.value.replace("_", ""), 0) if not _is_legacy_octal(tok.value) \
+                else int(tok.value.replace("_", ""), 8)
+        if tok.kind == "float":
+            return float(tok.value.replace("_", ""))
+        if tok.kind == "char":
+            text = unquote(tok.value)
+            if len(text) != 1:
+                raise _NotConstant()
+            return ord(text)
+        if tok.kind == "string":
+            return unquote(tok.value)
+        if tok.kind == "op" and tok.value == "(":
+            value = self.expression(0)
+            self._close()
+            return value
+        if tok.kind == "ident":
+            nxt = self._peek()
+            # Conversions such as Status(1) or uint8(x) keep the operand's value
+            if nxt is not None and nxt.kind == "op" and nxt.value == "(":
+                if tok.value not in _BASIC_TYPES and tok.value not in self.type_names:
+                    raise _NotConstant()
+                self.pos += 1
+                value = self.expression(0)
+                self._close()
+                return value
+            if tok.value == "iota":
+                return self.iota
+            if tok.value in ("true", "false"):
+                return tok.value == "true"
+            if tok.value in self.known:
+                return self.known[tok.value]
+        raise _NotConstant()
+
+    def _close(self):
+        tok = self._peek()
+        if tok is None or tok.value != ")":
+            raise _NotConstant()
+        self.pos += 1
+
+    @staticmethod
+    def _apply(op: str, left: Any, right: Any) -> Any:
+        if isinstance(left, str) or isinstance(right, str):
+            if op == "+" and isinstance(left, str) and isinstance(right, str):
+                return left + right
+            if op in ("==", "!="):
+                return (left == right) == (op == "==")
+            raise _NotConstant()
+        both_int = isinstance(left, int) and isinstance(right, int)
+        if op in ("<<", ">>", "&", "|", "^", "&^", "%") and not both_int:
+            raise _NotConstant()
+        if op == "+":
+            return left + right
+        if op == "-":
+            return left - right
+        if op == "*":
+            return left * right
+        if op == "/":
+            if both_int:
+                quotient = abs(left) // abs(right)
+                return quotient if (left >= 0) == (right >= 0) else -quotient
+            return left / right
+        if op == "%":
+            return left - right * int(left / right)
+        if op == "<<":
+            return left << right
+        if op == ">>":
+            return left >> right
+        if op == "&":
+            return left & right
+        if op == "|":
+            return left | right
+        if op == "^":
+            return left ^ right
+        if op == "&^":
+            return left & ~right
+        if op == "&&":
+            return bool(left) and bool(right)
+        if op == "||":
+            return bool(left) or bool(right)
+        return {"==": left == right, "!=": left != right, "<": left < right,
+                "<=": left <= right, ">": left > right, ">=": left >= right}[op]
+
+
+_FORMAT_VERB = re.compile(r"%[-+# 0-9.*\[\]]*[a-zA-Z%]")
+
+
+def _fill_format(fmt: str, args: List[str]) -> str:
+    """Replace the verbs of a Printf-style format with `{arg}` placeholders."""
+    remaining = iter(args)
+
+    def repl(match):
+        if match.group(0) == "%%":
+            return "%"
+        return "{" + next(remaining, "?") + "}"
+
+    return _FORMAT_VERB.sub(repl, fmt)
+
+
+def _is_legacy_octal(literal: str) -> bool:
+    """Go accepts 0755 as octal; Python's int(x, 0) does not."""
+    return len(literal) > 1 and literal[0] == "0" and literal[1].isdigit()
+
+
+class GoFileParser:
+    """Parse a Go source file into package, import, and symbol records."""
+
+    def __init__(self, content: str, test_file: bool = False, filename: Optional[str] = None):
+        self.test_file = test_file
+        self.filename = filename
+        self.src, self.errors = conflict_regions(content)
+        self.tokens, self.comments = tokenize(self.src, self.errors)
+        self.pos = 0
+        self.package = ""
+        self.package_doc = ""
+        self.imports: List[Dict[str, Any]] = []
+        self.symbols: List[Dict[str, Any]] = []
+        self._generic_methods: List[Tuple[Dict[str, Any], str]] = []
+        self.functions: List[Dict[str, Any]] = []
+        self.package_vars: Dict[str, Dict[str, Any]] = {}
+        # Calls made by package-level variable initializers
+        self.package_calls: List[Dict[str, Any]] = []
+        self._const_values: Dict[str, Any] = {}
+        self._spec_index = 0
+        self._const_prev = None
+        self._group: Optional[str] = None
+        self._first_code_col: Optional[Dict[int, int]] = None
+        self._comment_ends: List[int] = []
+        # Package-level composite literals that could be test tables: name -> table
+        self._tables: Dict[str, Dict[str, Any]] = {}
+        # Token indices of the struct keywords of named type declarations
+        self._named_structs: Set[int] = set()
+
+    # ------------------------------------------------------------------
+    # Token helpers
+    # ------------------------------------------------------------------
+
+    def _peek(self, offset: int = 0) -> Optional[Token]:
+        idx = self.pos + offset
+        if idx < len(self.tokens):
+            return self.tokens[idx]
+        return None
+
+    def _next(self) -> Token:
+        tok = self._peek()
+        if tok is None:
+            raise GoSyntaxError("unexpected end of file")
+        self.pos += 1
+        return tok
+
+    def _is(self, value: str, offset: int = 0) -> bool:
+        tok = self._peek(offset)
+        return tok is not None and tok.value == value and tok.kind in ("op", "keyword")
+
+    def _accept(self, value: str) -> bool:
+        if self._is(value):
+            self.pos += 1
+            return True
+        return False
+
+    def _expect(self, value: str) -> Token:
+        tok = self._peek()
+        if tok is None or tok.value != value or tok.kind not in ("op", "keyword"):
+            where = f"{tok.line}:{tok.col}" if tok else "EOF"
+            found = tok.value if tok else "EOF"
+            raise GoSyntaxError(f"expected '{value}', found '{found}' at {where}",
+                                tok.line if tok else 0, tok.col if tok else 0)
+        self.pos += 1
+        return tok
+
+    def _expect_ident(self) -> Token:
+        tok = self._peek()
+        if tok is None or tok.kind != "ident":
+            where = f"{tok.line}:{tok.col}" if tok else "EOF"
+            found = tok.value if tok else "EOF"
+            raise GoSyntaxError(f"expected identifier, found '{found}' at {where}",
+                                tok.line if tok else 0, tok.col if tok else 0)
+        self.pos += 1
+        return tok
+
+    def _text(self, start: int, end: int) -> str:
+        """Render tokens[start:end] as single-line source text."""
+        parts = []
+        prev = None
+        for idx in range(start, end):
+            tok = self.tokens[idx]
+            if tok.implicit:
+                nxt = self.tokens[idx + 1] if idx + 1 < end else None
+                if nxt is None or nxt.value in ("}", ")"):
+                    continue
+            if prev is not None and self.src[prev.end:tok.start]:
+                parts.append(" ")
+            parts.append(tok.value)
+            prev = tok
+        return "".join(parts)
+
+    def _skip_balanced(self, open_val: str, close_val: str):
+        """Skip from an opening bracket to just past its matching close."""
+        self._expect(open_val)
+        depth = 1
+        while depth:
+            tok = self._next()
+            if tok.kind == "op":
+                if tok.value == open_val:
+                    depth += 1
+                elif tok.value == close_val:
+                    depth -= 1
+
+    # ------------------------------------------------------------------
+    # Types
+    # ------------------------------------------------------------------
+
+    def _parse_type(self):
+        """Consume a type expression. Raises GoSyntaxError if none is present."""
+        tok = self._peek()
+        if tok is None:
+            raise GoSyntaxError("expected type, found EOF")
+
+        if tok.kind == "ident":
+            self.pos += 1
+            if self._is(".") and self._peek(1) is not None and self._peek(1).kind == "ident":
+                self.pos += 2
+            if self._is("[") and self._looks_like_type_args():
+                self._parse_type_args()
+            return
+        if tok.value == "*" and tok.kind == "op":
+            self.pos += 1
+            self._parse_type()
+            return
+        if tok.value == "(" and tok.kind == "op":
+            self.pos += 1
+            self._parse_type()
+            self._expect(")")
+            return
+        if tok.value == "[" and tok.kind == "op":
+            self.pos += 1
+            if not self._is("]"):
+                # Array length: any expression up to the closing bracket
+                depth = 0
+                while True:
+                    t = self._next()
+                    if t.kind == "op" and t.value in "([{":
+                        depth += 1
+                    elif t.kind == "op" and t.value in ")]}":
+                        if depth == 0:
+                            break
+                        depth -= 1
+                self.pos -= 1
+            self._expect("]")
+            self._parse_type()
+            return
+        if tok.value == "...":
+            self.pos += 1
+            self._parse_type()
+            return
+        if tok.kind == "keyword":
+            if tok.value == "map":
+                self.pos += 1
+                self._expect("[")
+                self._parse_type()
+                self._expect("]")
+                self._parse_type()
+                return
+            if tok.value == "chan":
+                self.pos += 1
+                self._accept("<-")
+                self._parse_type()
+                return
+            if tok.value == "func":
+                self.pos += 1
+                self._parse_signature()
+                return
+            if tok.value in ("struct", "interface"):
+                self.pos += 1
+                self._skip_balanced("{", "}")
+                return
+        if tok.value == "<-" and self._is("chan", 1):
+            self.pos += 2
+            self._parse_type()
+            return
+        raise GoSyntaxError(f"expected type, found '{tok.value}' at {tok.line}:{tok.col}", tok.line, tok.col)
+
+    def _looks_like_type_args(self) -> bool:
+        """Decide whether '[' after a type name starts type arguments."""
+        nxt = self._peek(1)
+        if nxt is None or (nxt.kind == "op" and nxt.value in ("]", "...")):
+            return False
+        if nxt.kind in ("int", "float", "imag", "char", "string"):
+            return False
+        return True
+
+    def _parse_type_args(self):
+        self._expect("[")
+        while True:
+            self._parse_type()
+            if not self._accept(","):
+                break
+            if self._is("]"):
+                break
+        self._expect("]")
+
+    def _parse_constraint(self):
+        """Consume a type constraint such as ~int | ~string or interface{...}."""
+        while True:
+            self._accept("~")
+            self._parse_type()
+            if not self._accept("|"):
+                break
+
+    # ------------------------------------------------------------------
+    # Parameter lists
+    # ------------------------------------------------------------------
+
+    def _split_list(self, close_val: str) -> List[Tuple[int, int]]:
+        """Split a bracketed list into (start, end) token ranges at top-level commas."""
+        entries = []
+        depth = 0
+        start = self.pos
+        while True:
+            tok = self._peek()
+            if tok is None:
+                raise GoSyntaxError(f"unterminated list, expected '{close_val}'")
+            if tok.kind == "op":
+                if tok.value in ("(", "[", "{"):
+                    depth += 1
+                elif tok.value in (")", "]", "}"):
+                    if depth == 0:
+                        if tok.value != close_val:
+                            raise GoSyntaxError(f"expected '{close_val}', found '{tok.value}' at {tok.line}:{tok.col}",
+                                                tok.line, tok.col)
+                        break
+                    depth -= 1
+                elif tok.value == "," and depth == 0:
+                    entries.append((start, self.pos))
+                    self.pos += 1
+                    start = self.pos
+                    continue
+                elif tok.value == ";" and tok.implicit and depth == 0:
+                    # Trailing newline inside a parenthesized list
+                    self.pos += 1
+                    if start == self.pos - 1:
+                        start = self.pos
+                    continue
+            self.pos += 1
+        if self.pos > start:
+            entries.append((start, self.pos))
+        return entries
+
+    def _range_is_type(self, start: int, end: int, constraint: bool = False) -> bool:
+        if start >= end:
+            return False
+        saved = self.pos
+        self.pos = start
+        try:
+            if constraint:
+                self._parse_constraint()
+            else:
+                self._parse_type()
+            return self.pos == end or (self.pos == end - 1 and self.tokens[end - 1].implicit)
+        except GoSyntaxError:
+            return False
+        finally:
+            self.pos = saved
+
+    def _parse_param_list(self, close_val: str = ")", type_params: bool = False) -> List[Dict[str, str]]:
+        """
+        Parse a parameter (or type parameter) list body up to close_val.
+
+        Go lists are either all named (`a, b int`) or all unnamed (`int, string`);
+        names without a type borrow the type of the next entry.
+        """
+        entries = self._split_list(close_val)
+        parsed = []
+        any_named = False
+        for start, end in entries:
+            while end > start and self.tokens[end - 1].implicit:
+                end -= 1
+            first = self.tokens[start]
+            if (end - start > 1 and first.kind == "ident" and not
+                    (self.tokens[start + 1].kind == "op" and self.tokens[start + 1].value == ".") and
+                    self._range_is_type(start + 1, end, constraint=type_params)):
+                parsed.append({"name": first.value, "type": self._text(start + 1, end)})
+                any_named = True
+            else:
+                parsed.append({"name": "", "type": self._text(start, end), "_single_ident":
+                               end - start == 1 and first.kind == "ident"})
+
+        if type_params or any_named:
+            # Names without a type take the type of the next typed entry
+            result = []
+            pending = []
+            for entry in parsed:
+                if entry["name"]:
+                    for name in pending:
+                        result.append({"name": name, "type": entry["type"]})
+                    pending = []
+                    result.append({"name": entry["name"], "type": entry["type"]})
+                elif entry.get("_single_ident"):
+                    pending.append(entry["type"])
+                else:
+                    raise GoSyntaxError("mixed named and unnamed parameters")
+            if pending:
+                raise GoSyntaxError("missing parameter type")
+            return result
+        return [{"name": "", "type": e["type"]} for e in parsed]
+
+    def _parse_type_params(self) -> List[Dict[str, str]]:
+        """Parse `[T any, U comparable]` into name/constraint pairs."""
+        self._expect("[")
+        params = self._parse_param_list("]", type_params=True)
+        self._expect("]")
+        return [{"name": p["name"], "constraint": p["type"]} for p in params]
+
+    def _parse_signature(self) -> Tuple[List[Dict[str, str]], List[Dict[str, str]]]:
+        """Parse `(params) results` and return (params, results)."""
+        self._expect("(")
+        params = self._parse_param_list(")")
+        self._expect(")")
+        results: List[Dict[str, str]] = []
+        if self._is("("):
+            self.pos += 1
+            results = self._parse_param_list(")")
+            self._expect(")")
+        else:
+            tok = self._peek()
+            if tok is not None and not (tok.kind == "op" and tok.value in (";", "{", ")", "]", "}", ",", "=")) \
+                    and not (tok.kind == "string"):
+                start = self.pos
+                self._parse_type()
+                results = [{"name": "", "type": self._text(start, self.pos)}]
+        return params, results
+
+    def _type_params_at_decl(self) -> bool:
+        """After `type Name`, decide whether '[' opens type parameters or an array type."""
+        if not self._is("["):
+            return False
+        first = self._peek(1)
+        second = self._peek(2)
+        if first is None or first.kind != "ident" or second is None:
+            return False
+        if second.kind != "op":
+            return True
+        # [N]T, [pkg.N]T, [N / 8]T, [len(x)]T are array lengths
+        if second.value in ("]", ".", "(", "/", "+", "-", "%", "<<", ">>", "&", "&^", "|", "^"):
+            return False
+        third = self._peek(3)
+        return not (second.value == "*" and third is not None and third.kind in ("int", "float", "char"))
+
+    # ------------------------------------------------------------------
+    # Declarations
+    # ------------------------------------------------------------------
+
+    def parse(self) -> Dict[str, Any]:
+        """Parse the whole file and return package, imports, and symbols."""
+        if self._is("package"):
+            self.package_doc = self._doc_for(self._next().line)
+            self.package = self._expect_ident().value
+            self._accept(";")
+
+        while self._peek() is not None:
+            start, marks = self.pos, self._marks()
+            try:
+                self._parse_decl()
+            except GoSyntaxError as e:
+                self._recover(start, marks, e)
+
+        # Methods on generic types inherit the constraints declared on the type
+        declared = {sym["name"]: sym.get("typeParams", []) for sym in self.symbols
+                    if sym["type"] in ("struct", "interface", "type")}
+        for method, base in self._generic_methods:
+            constraints = [p["constraint"] for p in declared.get(base, [])]
+            for i, param in enumerate(method["typeParams"]):
+                if i < len(constraints):
+                    param["constraint"] = constraints[i]
+
+        # Names no function body can use as a package-level variable, computed once per file
+        import_names = {imp["name"] for imp in self.imports if imp["name"]}
+        skip = {sym["name"] for sym in self.symbols
+                if sym["type"] in ("function", "struct", "interface", "type", "constant") and "container" not in sym}
+        for func in self.functions:
+            func["facts"]["free_uses"] = self._free_uses(func, import_names, skip)
+        if self.test_file:
+            self._classify_tests()
+        constraints = self._build_constraints()
+        if constraints:
+            for sym in self.symbols:
+                sym["buildConstraints"] = constraints
+
+        nested = self._nested_decls()
+        if constraints:
+            for sym in nested:
+                sym["buildConstraints"] = constraints
+
+        # Symbols a syntax error or a conflict falls inside may be incomplete
+        for error in self.errors:
+            first, last = error["line"], error.get("end_line", error["line"])
+            for sym in self.symbols + nested:
+                if sym["start_line"] <= last and sym["end_line"] >= first:
+                    sym["approximate"] = True
+
+        result = {
+            "package": self.package,
+            "package_doc": self.package_doc,
+            "imports": self.imports,
+            "symbols": self.symbols,
+            "qualified_refs": self._qualified_refs(),
+            "package_vars": self.package_vars,
+            "identifiers": self._identifier_counts(),
+            "functions": [
+                {
+                    "name": f["symbol"]["name"],
+                    "receiver": f["symbol"].get("receiver", {}).get("type"),
+                    "start_line": f["symbol"]["start_line"],
+                    **f["facts"],
+                }
+                for f in self.functions
+            ],
+        }
+        if nested:
+            result["nested"] = nested
+        if self.package_calls:
+            result["package_calls"] = self.package_calls
+        if any(imp["kind"] == "dot" for imp in self.imports):
+            result["unqualified_exported"] = self._unqualified_exported()
+        if constraints:
+            result["buildConstraints"] = constraints
+        if self.errors:
+            result["parseErrors"] = sorted(self.errors, key=lambda e: (e["line"], e["column"]))
+        return result
+
+    def _build_constraints(self) -> Optional[str]:
+        """
+        The file's build constraint (see core/go_build.py): the //go:build or
+        // +build lines of its header - the line comments before the package
+        clause, not counting the package doc that runs into it - and its name.
+        """
+        package_line = self.tokens[0].line if self.tokens else None
+        doc = {c.line for c in self._leading_comments(package_line)} if package_line else set()
+        go_build = None
+        plus_build = []
+        line = None
+        for comment in self.comments:
+            if (package_line and comment.line >= package_line) or not comment.value.startswith("//"):
+                break
+            if comment.line in doc:
+                continue
+            match = _BUILD_LINE.match(comment.value)
+            if not match:
+                continue
+            if match.group(1) == "//go:build" and go_build is None:
+                go_build, line = match.group(2) or "", comment.line
+            elif match.group(1) != "//go:build":
+                plus_build.append(match.group(2) or "")
+                line = line or comment.line
+        try:
+            return file_constraints(self.filename, go_build, plus_build)
+        except BuildConstraintError as e:
+            self.errors.append({"kind": "syntax", "message": str(e), "line": line or 1, "column": 1})
+            return None
+
+    def _identifier_counts(self) -> Dict[str, int]:
+        """Count identifier tokens by name; used for project-wide reference counts."""
+        counts: Dict[str, int] = {}
+        for tok in self.tokens:
+            if tok.kind == "ident":
+                counts[tok.value] = counts.get(tok.value, 0) + 1
+        return counts
+
+    def _unqualified_exported(self) -> List[str]:
+        """
+        Exported identifiers used without a qualifier and not declared in this file.
+
+        With a dot import these may come from the imported package's scope;
+        the indexer removes names declared elsewhere in the same package.
+        """
+        declared = {sym["name"] for sym in self.symbols}
+        names = set()
+        tokens = self.tokens
+        for idx, tok in enumerate(tokens):
+            if tok.kind != "ident" or not tok.value[:1].isupper() or tok.value in declared:
+                continue
+            if idx > 0 and tokens[idx - 1].kind == "op" and tokens[idx - 1].value == ".":
+                continue
+            names.add(tok.value)
+        return sorted(names)
+
+    def _resync(self):
+        """Skip ahead to the next top-level declaration keyword at column 1."""
+        self.pos += 1
+        while self._peek() is not None:
+            if self._at_decl(self.pos):
+                return
+            self.pos += 1
+
+    def _at_decl(self, idx: int) -> bool:
+        tok = self.tokens[idx]
+        return tok.col == 1 and tok.kind == "keyword" and tok.value in _DECL_KEYWORDS
+
+    def _error(self, message: str, tok: Optional[Token]):
+        """Record a syntax error at tok (the last token when None, i.e. at the end of the file)."""
+        tok = tok or (self.tokens[-1] if self.tokens else None)
+        self.errors.append({"kind": "syntax", "message": message,
+                            "line": tok.line if tok else 1, "column": tok.col if tok else 1})
+
+    def _parse_decl(self):
+        """Parse the top-level declaration at the current token."""
+        tok = self._peek()
+        if tok.kind == "keyword" and tok.value == "import":
+            self._parse_import_decl()
+        elif tok.kind == "keyword" and tok.value == "func":
+            self._parse_func_decl()
+        elif tok.kind == "keyword" and tok.value == "type":
+            self._parse_gen_decl(self._parse_type_spec)
+        elif tok.kind == "keyword" and tok.value in ("var", "const"):
+            self._parse_gen_decl(self._parse_value_spec)
+        elif tok.value == ";":
+            self.pos += 1
+        else:
+            self._error("non-declaration statement outside function body", tok)
+            self._resync()
+
+    def _marks(self) -> Tuple[int, ...]:
+        """How much each list a declaration appends to holds, to roll one back to."""
+        return (len(self.imports), len(self.symbols), len(self.functions), len(self.package_calls),
+                len(self._generic_methods))
+
+    def _roll_back(self, marks: Tuple[int, ...]):
+        for items, mark in zip((self.imports, self.symbols, self.functions, self.package_calls,
+                                self._generic_methods), marks):
+            del items[mark:]
+
+    def _unclosed(self, start: int, end: int) -> List[int]:
+        """The token indices of the brackets tokens[start:end] leaves open, outermost first."""
+        stack: List[int] = []
+        for idx in range(start, end):
+            tok = self.tokens[idx]
+            if tok.kind == "op" and tok.value in "({[":
+                stack.append(idx)
+            elif tok.kind == "op" and tok.value in ")}]" and stack and _CLOSERS[self.tokens[stack[-1]].value] == tok.value:
+                stack.pop()
+        return stack
+
+    def _recover(self, start: int, marks: Tuple[int, ...], error: GoSyntaxError):
+        """
+        Pick up after a declaration that failed to parse: record the error and
+        resume at the next top-level declaration keyword in column 1 past the
+        one that began at token start.
+
+        A declaration that ran into the next one - an unclosed parenthesis,
+        group or struct - would otherwise take everything after it along and
+        fail at the end of the file. It is reported at the bracket left open
+        instead, and parsed again with its brackets closed where its lines
+        end. What it declares is kept, marked "partial": all of it when it
+        parses that way, else its name (see _stub).
+        """
+        message = re.sub(r" at \d+:\d+$", "", str(error))
+        end = next((i for i in range(start + 1, len(self.tokens)) if self._at_decl(i)), len(self.tokens))
+        last = self.tokens[end - 1]
+        open_brackets = self._unclosed(start, end)
+        if open_brackets and (not error.line or (error.line, error.col) > (last.end_line, last.end_col)):
+            self._reparse(start, end, marks, open_brackets)
+            return
+        if error.line:
+            self.errors.append({"kind": "syntax", "message": message, "line": error.line, "column": error.col})
+        else:
+            self._error(message, self._peek())
+        self.pos = start
+        self._resync()
+        if self.pos < len(self.tokens):
+            resume = self.tokens[self.pos].line
+            self.symbols = [sym for sym in self.symbols if sym["start_line"] < resume]
+            self.functions = [f for f in self.functions if f["symbol"]["start_line"] < resume]
+            self.imports = [imp for imp in self.imports if imp["line"] < resume]
+
+    def _reparse(self, start: int, end: int, marks: Tuple[int, ...], open_brackets: List[int]):
+        """Parse the declaration in tokens[start:end] again, its open brackets closed at its end (see _recover)."""
+        opener = self.tokens[open_brackets[0]]
+        last = self.tokens[end - 1]
+        self.errors.append({"kind": "syntax", "message": f"unclosed '{opener.value}'", "line": opener.line,
+                            "column": opener.col, "end_line": last.end_line})
+        closers = [Token("op", _CLOSERS[self.tokens[idx].value], last.end_line, last.end_col, last.end_line,
+                         last.end_col, last.end, last.end, implicit=True) for idx in reversed(open_brackets)]
+        closers.append(Token("op", ";", last.end_line, last.end_col, last.end_line, last.end_col, last.end, last.end,
+                             implicit=True))
+        self.tokens[end:end] = closers
+        self._roll_back(marks)
+        self.pos = start
+        try:
+            self._parse_decl()
+        except GoSyntaxError:
+            self._roll_back(marks)
+            self._stub(start)
+        for symbol in self.symbols[marks[1]:]:
+            symbol["partial"] = True
+        self.pos = end + len(closers)
+
+    def _stub(self, start: int):
+        """
+        The symbol of a function, method or type declaration at token start
+        that does not parse even with its brackets closed, as far as its
+        header names it; nothing for other declarations.
+        """
+        self.pos = start
+        keyword = self._next()
+        receiver: List[Dict[str, str]] = []
+        try:
+            if keyword.value == "func":
+                if self._accept("("):
+                    receiver = self._parse_param_list(")")
+                    self._expect(")")
+                kind = "method" if receiver else "function"
+            elif keyword.value == "type":
+                kind = "type"
+            else:
+                return
+            name_tok = self._expect_ident()
+        except GoSyntaxError:
+            return
+        symbol = {
+            "name": name_tok.value,
+            "type": kind,
+            "signature": self._text(start, self.pos),
+            "start_line": keyword.line,
+            "column": name_tok.col,
+            "end_line": self.tokens[self.pos - 1].end_line,
+            "doc": self._doc_for(keyword.line),
+            **self._doc_extras(keyword.line),
+        }
+        if receiver:
+            recv_type = receiver[0]["type"]
+            symbol["receiver"] = {
+                "name": receiver[0]["name"],
+                "type": receiver_base_type(recv_type),
+                "pointer": recv_type.lstrip().startswith("*"),
+            }
+        self.symbols.append(symbol)
+
+    def _parse_gen_decl(self, spec_parser):
+        keyword = self._next()
+        # Per-declaration state for const specs: iota and the implicit repetition
+        self._spec_index = 0
+        self._const_prev = None
+        self._group = None
+        if self._accept("("):
+            self._group = f"{keyword.value}@{keyword.line}"
+            while not self._is(")"):
+                if self._accept(";"):
+                    continue
+                if self._peek() is None:
+                    raise GoSyntaxError(f"unterminated {keyword.value} group, expected ')'")
+                spec_parser(keyword)
+                self._spec_index += 1
+                if not self._is(")"):
+                    self._expect(";")
+            self._expect(")")
+        else:
+            spec_parser(keyword)
+        self._group = None
+        self._accept(";")
+
+    def _parse_import_decl(self):
+        def spec(_keyword):
+            tok = self._next()
+            alias = None
+            if tok.kind == "ident" or (tok.kind == "op" and tok.value == "."):
+                alias = tok.value
+                tok = self._next()
+            if tok.kind != "string":
+                raise GoSyntaxError(f"expected import path at {tok.line}:{tok.col}", tok.line, tok.col)
+            path = unquote(tok.value)
+            if alias == "_":
+                kind, name = "blank", None
+            elif alias == ".":
+                kind, name = "dot", None
+            elif alias:
+                kind, name = "alias", alias
+            else:
+                kind, name = "default", default_import_name(path)
+            self.imports.append({
+                "path": path,
+                "alias": alias,
+                "name": name,
+                "kind": kind,
+                "line": tok.line,
+                "column": tok.col,
+            })
+
+        self._parse_gen_decl(spec)
+
+    def _parse_value_spec(self, keyword: Token):
+        """Emit variable symbols and record the type source of package-level vars."""
+        if keyword.value == "const":
+            self._parse_const_spec(keyword)
+            return
+        spec_start = self.pos
+        self._skip_value_spec(keyword)
+        spec_end = self.pos
+        names = []
+        name_toks = []
+        idx = spec_start
+        while idx < spec_end and self.tokens[idx].kind == "ident":
+            names.append(self.tokens[idx].value)
+            name_toks.append(self.tokens[idx])
+            if idx + 1 < spec_end and self.tokens[idx + 1].value == ",":
+                idx += 2
+                continue
+            idx += 1
+            break
+        eq = idx
+        while eq < spec_end and not (self.tokens[eq].kind == "op" and self.tokens[eq].value == "="):
+            eq += 1
+        type_text = self._text(idx, eq) if eq > idx else ""
+        exprs = self._split_exprs(eq + 1, spec_end) if eq < spec_end else []
+        if self.test_file and len(exprs) == len(names):
+            for name, expr in zip(names, exprs):
+                table = self._table_literal(*expr)
+                if table is not None:
+                    self._tables[name] = {"variable": name, "line": self.tokens[expr[0]].line, **table}
+        for pos, expr in enumerate(exprs):
+            # Calls in initializers (var port = flag.Int(...)), assigned to the variable(s)
+            targets = [names[pos]] if len(exprs) == len(names) else names
+            for call in self._body_facts(expr[0] - 1, expr[1])["calls"]:
+                call["assigned_to"] = [name for name in targets if name != "_"]
+                self.package_calls.append(call)
+        for pos, name in enumerate(names):
+            if name == "_":
+                continue
+            if type_text:
+                self.package_vars[name] = {"type": type_text}
+            elif pos < len(exprs):
+                self.package_vars[name] = self._expr_source(*exprs[pos]) or {}
+            symbol = {
+                "name": name,
+                "type": "variable",
+                "signature": f"var {name} {type_text}".rstrip(),
+                "start_line": name_toks[pos].line,
+                "column": name_toks[pos].col,
+                "end_line": self.tokens[spec_end - 1].end_line,
+                "doc": self._doc_for(name_toks[0].line),
+                **self._doc_extras(name_toks[0].line),
+            }
+            if type_text:
+                symbol["var_type"] = type_text
+            if len(exprs) == len(names):
+                symbol["value"] = self._text(*exprs[pos])
+            elif len(exprs) == 1:
+                # var conn, err = dial(): every name takes one result of the same call
+                symbol["value"] = self._text(*exprs[0])
+                symbol["value_index"] = pos
+            if exprs:
+                symbol["initialized"] = True
+            if len(exprs) == len(names) and self.tokens[exprs[pos][0]].value == "func":
+                symbol["function_literal"] = True
+            channel = channel_type(type_text) if type_text else \
+                (self._made_channel(*exprs[pos]) if pos < len(exprs) else None)
+            if channel:
+                symbol["channel"] = channel
+            if self._group:
+                symbol["group"] = self._group
+            self.symbols.append(symbol)
+
+    def _parse_const_spec(self, keyword: Token):
+        """
+        Emit constant symbols for one const spec.
+
+        Inside a parenthesized block a spec without `= ...` repeats the
+        previous spec's type and expressions with the next iota, which is how
+        enum-style blocks are written.
+        """
+        spec_start = self.pos
+        self._skip_value_spec(keyword)
+        spec_end = self.pos
+        first = self.tokens[spec_start]
+
+        names, idx = [], spec_start
+        while idx < spec_end and self.tokens[idx].kind == "ident":
+            names.append(self.tokens[idx])
+            if idx + 1 < spec_end and self.tokens[idx + 1].value == ",":
+                idx += 2
+                continue
+            idx += 1
+            break
+        eq = idx
+        while eq < spec_end and not (self.tokens[eq].kind == "op" and self.tokens[eq].value == "="):
+            eq += 1
+
+        if eq < spec_end:
+            const_type = self._text(idx, eq) if eq > idx else None
+            exprs = self._split_exprs(eq + 1, spec_end)
+            self._const_prev = (const_type, exprs)
+        elif self._const_prev is not None:
+            const_type, exprs = self._const_prev
+        else:
+            const_type, exprs = (self._text(idx, spec_end) or None), []
+
+        for pos, name_tok in enumerate(names):
+            if name_tok.value == "_":
+                continue
+            expr = exprs[pos] if pos < len(exprs) else None
+            expression = self._text(*expr) if expr else ""
+            value = self._const_value(*expr) if expr else None
+            if value is not None:
+                self._const_values[name_tok.value] = value
+            signature = f"const {name_tok.value}"
+            if const_type:
+                signature += f" {const_type}"
+            if expression:
+                signature += f" = {expression}"
+            symbol = {
+                "name": name_tok.value,
+                "type": "constant",
+                "signature": signature,
+                "start_line": name_tok.line,
+                "column": name_tok.col,
+                "end_line": self.tokens[spec_end - 1].end_line if spec_end > spec_start else name_tok.line,
+                "doc": self._doc_for(first.line),
+                **self._doc_extras(first.line),
+                "value": value if value is not None else expression,
+                "evaluated": value is not None,
+                "expression": expression,
+            }
+            if const_type:
+                symbol["const_type"] = const_type
+            if self._group:
+                symbol["group"] = self._group
+                symbol["iota"] = self._spec_index
+            self.symbols.append(symbol)
+
+    def _const_value(self, start: int, end: int) -> Optional[Any]:
+        """Constant-fold an expression, substituting iota; None if not foldable."""
+        type_names = {sym["name"] for sym in self.symbols if sym["type"] in ("struct", "interface", "type")}
+        evaluator = _ConstEvaluator(self.tokens[start:end], self._spec_index, self._const_values, type_names)
+        try:
+            value = evaluator.expression(0)
+        except (_NotConstant, ArithmeticError, ValueError, IndexError):
+            return None
+        if not evaluator.at_end():
+            return None
+        return value
+
+    def _skip_value_spec(self, _keyword):
+        """Skip a var/const spec up to the end of its statement."""
+        depth = 0
+        while self._peek() is not None:
+            tok = self._peek()
+            if tok.kind == "op":
+                if tok.value in ("(", "[", "{"):
+                    depth += 1
+                elif tok.value in (")", "]", "}"):
+                    if depth == 0:
+                        return
+                    depth -= 1
+                elif tok.value == ";" and depth == 0:
+                    return
+            self.pos += 1
+        if depth:
+            raise GoSyntaxError("unexpected end of file")
+
+    def _leading_comments(self, line: int) -> List[Token]:
+        """
+        Return the contiguous comment block ending directly above `line`.
+
+        A blank line ends the block, and comments that trail code on their
+        own line are not part of it.
+        """
+        if self._first_code_col is None:
+            self._first_code_col = {}
+            for tok in self.tokens:
+                if not tok.implicit:
+                    self._first_code_col.setdefault(tok.line, tok.col)
+            # Comments come in source order, so their end lines are sorted
+            self._comment_ends = [c.end_line for c in self.comments]
+        block: List[Token] = []
+        expected = line - 1
+        k = bisect.bisect_right(self._comment_ends, expected) - 1
+        while k >= 0:
+            comment = self.comments[k]
+            k -= 1
+            if comment.end_line > expected:
+                continue
+            if comment.end_line < expected:
+                break
+            if self._first_code_col.get(comment.line, comment.col + 1) < comment.col:
+                break
+            block.append(comment)
+            expected = comment.line - 1
+        block.reverse()
+        return block
+
+    def _doc_for(self, line: int) -> str:
+        """Return the doc comment directly above a declaration, markers stripped."""
+        lines = []
+        for comment in self._leading_comments(line):
+            text = comment.value
+            if text.startswith("//"):
+                if _DIRECTIVE.match(text):
+                    continue
+                body = text[2:]
+                lines.append(body[1:] if body.startswith(" ") else body)
+            else:
+                body_lines = text[2:-2].strip("\n").split("\n")
+                lines.extend(re.sub(r"^\s*\* ?", "", l) if l.lstrip().startswith("*") else l.strip()
+                             for l in body_lines)
+        return "\n".join(lines).strip()
+
+    def _doc_extras(self, line: int) -> Dict[str, Any]:
+        """Return the `deprecated` flag and `//go:` directives of a declaration's doc block."""
+        extras: Dict[str, Any] = {}
+        directives = [c.value[2:] for c in self._leading_comments(line) if _DIRECTIVE.match(c.value)]
+        if directives:
+            extras["directives"] = directives
+        for paragraph in self._doc_for(line).split("\n\n"):
+            if paragraph.startswith("Deprecated:"):
+                extras["deprecated"] = True
+                extras["deprecation"] = " ".join(paragraph[len("Deprecated:"):].split())
+                break
+        return extras
+
+    def _parse_type_spec(self, keyword: Token):
+        name_tok = self._expect_ident()
+        start_line = name_tok.line if self.tokens[self.pos - 2] is not keyword else keyword.line
+        type_params: List[Dict[str, str]] = []
+        if self._type_params_at_decl():
+            type_params = self._parse_type_params()
+        alias = self._accept("=")
+
+        type_start = self.pos
+        type_tok = self._peek()
+        self._parse_type()
+        end_tok = self.tokens[self.pos - 1]
+
+        if type_tok.kind == "keyword" and type_tok.value == "struct":
+            kind = "struct"
+            rendered = "struct"
+            self._named_structs.add(type_start)
+        elif type_tok.kind == "keyword" and type_tok.value == "interface":
+            kind = "interface"
+            rendered = "interface"
+        else:
+            kind = "type"
+            rendered = self._text(type_start, self.pos)
+
+        signature = f"type {name_tok.value}{format_type_params(type_params)} {'= ' if alias else ''}{rendered}"
+        symbol = {
+            "name": name_tok.value,
+            "type": kind,
+            "signature": signature,
+            "start_line": start_line,
+            "column": name_tok.col,
+            "end_line": end_tok.end_line,
+            "doc": self._doc_for(start_line),
+            **self._doc_extras(start_line),
+            "alias": alias,
+        }
+        if kind == "type":
+            symbol["underlying"] = rendered
+        if type_params:
+            symbol["typeParams"] = type_params
+        if self._group:
+            symbol["group"] = self._group
+        self.symbols.append(symbol)
+
+        if kind == "struct":
+            end = self.pos
+            self.pos = type_start + 1
+            self._parse_struct_fields(name_tok.value)
+            self.pos = end
+        elif kind == "interface":
+            end = self.pos
+            self.pos = type_start + 1
+            embeds, type_set = self._parse_interface_elems(name_tok.value)
+            if embeds:
+                symbol["embeds"] = embeds
+            if type_set:
+                symbol["type_set"] = type_set
+            self.pos = end
+
+    def _parse_interface_elems(self, container: str) -> Tuple[List[str], List[str]]:
+        """
+        Emit a method symbol for every method spec in the interface body at self.pos.
+
+        Returns:
+            (embeds, type_set) - embedded interface names, and the union
+            elements of constraint interfaces such as `~int | ~float64`
+        """
+        embeds: List[str] = []
+        type_set: List[str] = []
+        self._expect("{")
+        while not self._is("}"):
+            if self._accept(";"):
+                continue
+            tok = self._peek()
+            nxt = self._peek(1)
+            if tok.kind == "ident" and nxt is not None and nxt.kind == "op" and nxt.value == "(":
+                self.pos += 1
+                sig_start = self.pos
+                params, results = self._parse_signature()
+                self.symbols.append({
+                    "name": tok.value,
+                    "type": "method",
+                    "container": container,
+                    "signature": f"{tok.value}{self._text(sig_start, self.pos)}",
+                    "params": params,
+                    "results": results,
+                    "start_line": tok.line,
+                    "column": tok.col,
+                    "end_line": self.tokens[self.pos - 1].end_line,
+                    "doc": self._doc_for(tok.line),
+                    **self._doc_extras(tok.line),
+                })
+            else:
+                elem_start = self.pos
+                self._parse_constraint()
+                text = self._text(elem_start, self.pos)
+                if "|" in text or "~" in text or self.tokens[elem_start].kind != "ident":
+                    type_set.append(text)
+                else:
+                    embeds.append(text)
+            if not self._is("}"):
+                self._expect(";")
+        self._expect("}")
+        return embeds, type_set
+
+    def _parse_struct_fields(self, container: str):
+        """Emit a field symbol for every field in the struct body at self.pos."""
+        self._expect("{")
+        while not self._is("}"):
+            if self._accept(";"):
+                continue
+            decl_start = self.pos
+            names: List[Token] = []
+            # Named fields: `A, B int` - otherwise an embedded type
+            saved = self.pos
+            try:
+                names.append(self._expect_ident())
+                while self._accept(","):
+                    names.append(self._expect_ident())
+                type_start = self.pos
+                self._parse_type()
+            except GoSyntaxError:
+                names = []
+                self.pos = saved
+                self._accept("*")
+                type_start = saved
+                self._parse_type()
+            type_end = self.pos
+
+            raw_tag = None
+            tag_tok = self._peek()
+            if tag_tok is not None and tag_tok.kind == "string":
+                raw_tag = unquote(tag_tok.value)
+                self.pos += 1
+            last_tok = self.tokens[self.pos - 1]
+
+            field_type = self._text(type_start, type_end)
+            if not names:
+                # Embedded field: its name is the unqualified type name
+                base = receiver_base_type(field_type).rsplit(".", 1)[-1]
+                base_tok = next((t for t in self.tokens[type_start:type_end]
+                                 if t.kind == "ident" and t.value == base), self.tokens[decl_start])
+                field = {
+                    "name": base,
+                    "type": "field",
+                    "container": container,
+                    "field_type": field_type,
+                    "signature": field_type,
+                    "start_line": self.tokens[decl_start].line,
+                    "column": base_tok.col,
+                    "end_line": last_tok.end_line,
+                    "doc": self._doc_for(self.tokens[decl_start].line),
+                    **self._doc_extras(self.tokens[decl_start].line),
+                    "embedded": True,
+                    "embedded_pointer": field_type.startswith("*"),
+                }
+                if raw_tag is not None:
+                    tags, ok = parse_struct_tag(raw_tag)
+                    field["tag"] = raw_tag
+                    field["tags"] = tags
+                    if not ok:
+                        field["parseError"] = True
+                self.symbols.append(field)
+            for name_tok in names:
+                field = {
+                    "name": name_tok.value,
+                    "type": "field",
+                    "container": container,
+                    "field_type": field_type,
+                    "signature": f"{name_tok.value} {field_type}",
+                    "start_line": name_tok.line,
+                    "column": name_tok.col,
+                    "end_line": last_tok.end_line,
+                    "doc": self._doc_for(self.tokens[decl_start].line),
+                    **self._doc_extras(self.tokens[decl_start].line),
+                }
+                if raw_tag is not None:
+                    tags, ok = parse_struct_tag(raw_tag)
+                    field["tag"] = raw_tag
+                    field["tags"] = tags
+                    if not ok:
+                        field["parseError"] = True
+                self.symbols.append(field)
+
+            if not self._is("}"):
+                self._expect(";")
+        self._expect("}")
+
+    def _parse_func_decl(self):
+        decl_start = self.pos
+        func_tok = self._expect("func")
+        receiver_text = None
+        receiver: List[Dict[str, str]] = []
+        receiver_params: List[Dict[str, str]] = []
+        if self._is("("):
+            self.pos += 1
+            recv_start = self.pos
+            receiver = self._parse_param_list(")")
+            receiver_text = self._text(recv_start, self.pos)
+            self._expect(")")
+            if receiver:
+                receiver_params = receiver_type_params(receiver[0]["type"])
+
+        name_tok = self._expect_ident()
+        type_params: List[Dict[str, str]] = []
+        if self._is("["):
+            type_params = self._parse_type_params()
+
+        sig_start = self.pos
+        params, results = self._parse_signature()
+        sig_text = self._text(sig_start, self.pos)
+
+        body = None
+        if self._is("{"):
+            body = self._skip_body()
+        end_tok = self.tokens[self.pos - 1]
+        self._accept(";")
+
+        if receiver_text is not None:
+            signature = f"func ({receiver_text}) {name_tok.value}{sig_text}"
+        else:
+            signature = f"func {name_tok.value}{format_type_params(type_params)}{sig_text}"
+        symbol = {
+            "name": name_tok.value,
+            "type": "method" if receiver_text is not None else "function",
+            "signature": signature,
+            "params": params,
+            "results": results,
+            "start_line": func_tok.line,
+            "column": name_tok.col,
+            "end_line": end_tok.end_line,
+            "doc": self._doc_for(func_tok.line),
+            **self._doc_extras(func_tok.line),
+        }
+        if receiver_text is not None and receiver:
+            recv_type = receiver[0]["type"]
+            symbol["receiver"] = {
+                "name": receiver[0]["name"],
+                "type": receiver_base_type(recv_type),
+                "pointer": recv_type.lstrip().startswith("*"),
+            }
+        if body:
+            symbol["loc"] = self._line_count(decl_start, body[1])
+            symbol.update(self._body_metrics(*body))
+            symbol["param_count"] = len(params)
+            symbol["result_count"] = len(results)
+        if type_params:
+            symbol["typeParams"] = type_params
+        elif receiver_params:
+            # Constraints live on the type declaration; filled in by parse()
+            symbol["typeParams"] = receiver_params
+            self._generic_methods.append((symbol, receiver_base_type(receiver[0]["type"])))
+        self.symbols.append(symbol)
+
+        local_types = {p["name"]: {"type": p["type"]} for p in receiver + params + results
+                       if p["name"] and p["name"] != "_"}
+        facts = self._body_facts(*body) if body else {"locals": {}, "calls": [], "value_refs": []}
+        for name, source in local_types.items():
+            facts["locals"].setdefault(name, source)
+        concurrency = self._concurrency(*body) if body else {}
+        for param in params:
+            channel = channel_type(param["type"]) if param["name"] and param["name"] != "_" else None
+            if channel:
+                concurrency.setdefault("channels", []).insert(0, {
+                    "name": param["name"], "chain": [param["name"]], "kind": "param",
+                    "line": name_tok.line, "column": name_tok.col, **channel,
+                })
+        if concurrency:
+            facts["concurrency"] = concurrency
+        if body:
+            facts.update(self._failure_facts(*body))
+            facts.update(body_fingerprint(body_shape(self.tokens[body[0] + 1:body[1]])))
+            if results and results[-1]["type"] == "error":
+                returns = self._error_returns(*body)
+                if returns:
+                    facts["error_returns"] = returns
+            symbol["distinct_callees"] = len({
+                ".".join(call["chain"]) for call in facts["calls"]
+                if not (len(call["chain"]) == 1 and (call["chain"][0] in _PREDECLARED or
+                                                     call["chain"][0] in _BASIC_TYPES))
+            })
+        self.functions.append({
+            "symbol": symbol,
+            "span": (decl_start, self.pos - 1),
+            "body": body,
+            "locals": set(facts["locals"]),
+            "facts": facts,
+        })
+
+    # ------------------------------------------------------------------
+    # Nested declarations
+    # ------------------------------------------------------------------
+
+    def _nested_decls(self) -> List[Dict[str, Any]]:
+        """
+        Symbols declared below the top level, kept apart from the file's
+        symbols: function literals given a name inside a function (handler :=
+        func(...) {...}, var handler = func...), the types and constants
+        declared in function bodies, and anonymous struct types, named
+        "struct@line:column" after where they start, with their fields. Each
+        carries "nested": true and names its innermost enclosing declaration
+        in "parent" ("Server.Start", "Server.Start.handler", "defaultConfig").
+        """
+        nested: List[Dict[str, Any]] = []
+        bodies = {f["body"][0]: (f["body"][1], self._qualified(f["symbol"])) for f in self.functions if f["body"]}
+        top_level = [sym for sym in self.symbols if "container" not in sym]
+
+        def enclosing(line: int) -> Optional[str]:
+            around = [sym for sym in top_level if sym["start_line"] <= line <= sym["end_line"]]
+            return self._qualified(min(around, key=lambda sym: sym["end_line"] - sym["start_line"])) \
+                if around else None
+
+        saved = self.pos
+        idx = 0
+        while idx < len(self.tokens):
+            if idx in bodies:
+                end, parent = bodies[idx]
+                self._nested_in(idx + 1, end, parent, nested)
+                idx = end + 1
+                continue
+            # Outside function bodies: package-level var types and values, fields of named structs
+            tok = self.tokens[idx]
+            if tok.kind == "keyword" and tok.value == "func" and idx and self.tokens[idx - 1].value != ";":
+                literal = self._func_literal(idx, len(self.tokens))
+                if literal is not None and not any(t.value == ";" for t in self.tokens[idx:literal[1]]):
+                    self._nested_in(literal[1] + 1, literal[2], enclosing(tok.line), nested)
+                    idx = literal[2] + 1
+                    continue
+            elif tok.kind == "keyword" and tok.value == "struct" and idx not in self._named_structs \
+                    and self._is_anonymous_struct(idx):
+                idx = self._nested_struct(idx, enclosing(tok.line), nested)
+                continue
+            idx += 1
+        self.pos = saved
+        return nested
+
+    @staticmethod
+    def _qualified(symbol: Dict[str, Any]) -> str:
+        if symbol.get("receiver"):
+            return f"{symbol['receiver']['type']}.{symbol['name']}"
+        if symbol.get("parent"):
+            return f"{symbol['parent']}.{symbol['name']}"
+        return symbol["name"]
+
+    def _is_anonymous_struct(self, idx: int) -> bool:
+        return idx + 1 < len(self.tokens) and self.tokens[idx + 1].value == "{"
+
+    def _nested_in(self, start: int, end: int, parent: Optional[str], nested: List[Dict[str, Any]],
+                   consts: Optional[Dict[str, Any]] = None):
+        """
+        Collect the nested declarations of the function body tokens[start:end];
+        consts holds the constant values in scope, local ones added as declared.
+        """
+        consts = dict(self._const_values) if consts is None else consts
+        idx = start
+        while idx < end:
+            tok = self.tokens[idx]
+            if tok.kind == "keyword" and tok.value == "func":
+                literal = self._func_literal(idx, end)
+                if literal is None or any(t.value == ";" for t in self.tokens[idx:literal[1]]):
+                    # A function type (var f func(int)), not a literal
+                    idx += 1
+                    continue
+                _, body_start, body_end = literal
+                name_tok = self._literal_name(idx)
+                inner = parent
+                if name_tok is not None:
+                    symbol = {
+                        "name": name_tok.value,
+                        "type": "function",
+                        "signature": f"func {name_tok.value}{self._text(idx + 1, body_start)}",
+                        "start_line": name_tok.line,
+                        "column": name_tok.col,
+                        "end_line": self.tokens[body_end].end_line,
+                        "doc": self._doc_for(name_tok.line),
+                        "function_literal": True,
+                        "nested": True,
+                        "parent": parent,
+                    }
+                    nested.append(symbol)
+                    inner = self._qualified(symbol)
+                # Declarations of an unnamed closure belong to the function around it
+                self._nested_in(body_start + 1, body_end, inner, nested, dict(consts))
+                idx = body_end + 1
+            elif tok.kind == "keyword" and tok.value in ("type", "const") and \
+                    self.tokens[idx - 1].value in (";", "{", ":"):
+                idx = self._nested_gen_decl(idx, end, parent, nested, consts)
+            elif tok.kind == "keyword" and tok.value == "struct" and self._is_anonymous_struct(idx):
+                idx = self._nested_struct(idx, parent, nested)
+            else:
+                idx += 1
+
+    def _literal_name(self, idx: int) -> Optional[Token]:
+        """The name a function literal at idx is declared as: `name := func`, `var name = func`."""
+        if idx < 2 or self.tokens[idx - 2].kind != "ident":
+            return None
+        before = self.tokens[idx - 1].value
+        if before == ":=" or (before == "=" and idx >= 3 and self.tokens[idx - 3].value == "var"):
+            return self.tokens[idx - 2] if self.tokens[idx - 3].value in (";", "{", ":", "var") else None
+        return None
+
+    def _nested_gen_decl(self, idx: int, end: int, parent: Optional[str], nested: List[Dict[str, Any]],
+                         consts: Dict[str, Any]) -> int:
+        """Parse the type or const declaration at idx inside a function body; the index after it."""
+        symbols, const_values, errors = self.symbols, self._const_values, len(self.errors)
+        # Local constants fold within their scope only, never into package-level ones
+        self.symbols, self._const_values = [], consts
+        self.pos = idx
+        try:
+            self._parse_gen_decl(self._parse_type_spec if self.tokens[idx].value == "type" else self._parse_value_spec)
+            declared, after = self.symbols, self.pos
+        except GoSyntaxError:
+            declared, after = [], idx + 1
+            del self.errors[errors:]
+        finally:
+            self.symbols, self._const_values = symbols, const_values
+        for symbol in declared:
+            symbol["nested"] = True
+            symbol["parent"] = parent
+        nested.extend(declared)
+        return min(max(after, idx + 1), end)
+
+    def _nested_struct(self, idx: int, parent: Optional[str], nested: List[Dict[str, Any]]) -> int:
+        """Record the anonymous struct type at idx, fields included; the index to scan on from."""
+        tok = self.tokens[idx]
+        name = f"struct@{tok.line}:{tok.col}"
+        symbols, errors = self.symbols, len(self.errors)
+        self.symbols = []
+        self.pos = idx + 1
+        try:
+            self._parse_struct_fields(name)
+            fields, after = self.symbols, self.pos
+        except GoSyntaxError:
+            fields, after = [], idx + 1
+            del self.errors[errors:]
+        finally:
+            self.symbols = symbols
+        symbol = {
+            "name": name,
+            "type": "struct",
+            "signature": "struct{" + "; ".join(
+                f["field_type"] if f.get("embedded") else f"{f['name']} {f['field_type']}" for f in fields) + "}",
+            "start_line": tok.line,
+            "column": tok.col,
+            "end_line": self.tokens[after - 1].end_line,
+            "doc": "",
+            "anonymous": True,
+        }
+        for symbol_or_field in [symbol] + fields:
+            symbol_or_field["nested"] = True
+            if parent:
+                symbol_or_field["parent"] = parent
+        nested.append(symbol)
+        nested.extend(fields)
+        # Fields may have anonymous struct types of their own
+        return idx + 1
+
+    # ------------------------------------------------------------------
+    # Tests
+    # ------------------------------------------------------------------
+
+    def _classify_tests(self):
+        """
+        Mark the test functions of a _test.go file with their "testKind":
+        test, benchmark, fuzz and example as `go test` finds them (name and
+        signature), plus "main" for TestMain. Examples name the symbol they
+        document in "example_of"; tests driven by a table of cases describe
+        it in "table".
+        """
+        testing = {imp["name"] for imp in self.imports if imp["path"] == "testing" and imp["kind"] in ("default", "alias")}
+        for func in self.functions:
+            symbol = func["symbol"]
+            if symbol["type"] != "function" or symbol.get("typeParams"):
+                continue
+            kind = _test_kind(symbol, testing)
+            if kind is None:
+                continue
+            symbol["testKind"] = kind
+            if kind == "example":
+                target = example_target(symbol["name"])
+                if target:
+                    symbol["example_of"] = target
+            elif kind in ("test", "benchmark", "fuzz") and func["body"]:
+                table = self._test_table(*func["body"])
+                if table:
+                    symbol["table"] = table
+
+    def _table_literal(self, start: int, end: int) -> Optional[Dict[str, Any]]:
+        """
+        The cases of a composite literal of structs - []struct{...}{{...}, ...},
+        []testCase{...} or map[string]struct{...}{"name": {...}} - in
+        tokens[start:end], or None if it is something else.
+        """
+        tokens = self.tokens
+        idx = start
+        if idx < end and tokens[idx].value == "map" and idx + 1 < end and tokens[idx + 1].value == "[":
+            keyed = True
+            idx = self._match_forward(idx + 1, end) + 1
+        elif idx + 1 < end and tokens[idx].value == "[" and tokens[idx + 1].value == "]":
+            keyed = False
+            idx += 2
+        else:
+            return None
+        if idx < end and tokens[idx].value == "*":
+            idx += 1
+        if idx < end and tokens[idx].value == "struct" and idx + 1 < end and tokens[idx + 1].value == "{":
+            idx = self._match_forward(idx + 1, end) + 1
+        elif idx < end and tokens[idx].kind == "ident":
+            idx += 1
+            if idx + 1 < end and tokens[idx].value == "." and tokens[idx + 1].kind == "ident":
+                idx += 2
+        else:
+            return None
+        if idx >= end or tokens[idx].value != "{":
+            return None
+        close = self._match_forward(idx, end)
+        entries = [(a, b) for a, b in self._split_exprs(idx + 1, close)
+                   if any(not tokens[k].implicit for k in range(a, b))]
+        if not entries:
+            return None
+        names = []
+        for a, b in entries:
+            while a < b and tokens[a].implicit:
+                a += 1
+            if keyed:
+                if tokens[a].kind != "string" or a + 1 >= b or tokens[a + 1].value != ":":
+                    return None
+                names.append(unquote(tokens[a].value))
+                a += 2
+            if tokens[a].value == "&":
+                a += 1
+            if tokens[a].kind == "ident" and a + 1 < b and tokens[a + 1].value == "{":
+                a += 1
+            if tokens[a].value != "{":
+                return None
+            if not keyed:
+                name = self._case_name(a, self._match_forward(a, b))
+                if name is not None:
+                    names.append(name)
+        table: Dict[str, Any] = {"cases": len(entries)}
+        if names:
+            table["case_names"] = names[:MAX_CASE_NAMES]
+        return table
+
+    def _case_name(self, start: int, end: int) -> Optional[str]:
+        """The string given to a name-like field in the struct literal tokens[start:end]."""
+        tokens = self.tokens
+        for a, b in self._split_exprs(start + 1, end):
+            while a < b and tokens[a].implicit:
+                a += 1
+            if (b - a >= 3 and tokens[a].kind == "ident" and tokens[a].value.lower() in _CASE_NAME_FIELDS
+                    and tokens[a + 1].value == ":" and tokens[a + 2].kind == "string"):
+                return unquote(tokens[a + 2].value)
+        return None
+
+    def _test_table(self, start: int, end: int) -> Optional[Dict[str, Any]]:
+        """
+        The table of a table-driven test: a slice or map of struct cases,
+        declared in the test or at package level, that a for ... range
+        loop in the test walks. Records the loop variable and whether each
+        case runs as a subtest (t.Run inside the loop).
+        """
+        tokens = self.tokens
+        tables = dict(self._tables)
+        for idx in range(start + 1, end):
+            tok = tokens[idx]
+            if tok.kind == "ident" and idx + 2 < end and tokens[idx + 1].value in (":=", "="):
+                literal_end = self._rhs_end(idx + 2, end, False)
+                table = self._table_literal(idx + 2, literal_end)
+                if table is not None:
+                    tables[tok.value] = {"variable": tok.value, "line": tok.line, **table}
+        for body_open, body_close, names, line in self._loops(start, end):
+            head = next((i for i in range(body_open - 1, start, -1) if tokens[i].value == "range"), None)
+            if head is None or tokens[head].line != line or head + 1 >= body_open:
+                continue
+            target = tokens[head + 1]
+            if target.kind != "ident" or target.value not in tables or head + 2 != body_open:
+                continue
+            table = dict(tables[target.value])
+            table["loop_variable"] = names[-1] if names else None
+            table["loop_line"] = line
+            table["subtests"] = any(
+                tokens[i].value == "Run" and tokens[i - 1].value == "." and tokens[i + 1].value == "("
+                for i in range(body_open + 1, body_close)
+            )
+            return table
+        return None
+
+    def _skip_body(self) -> Tuple[int, int]:
+        """
+        Skip a function body, returning the token range from its { to its }.
+
+        A body whose braces never balance (a half-written block) ends before
+        the next declaration keyword in column 1 instead of at the end of the
+        file. Unbalanced parentheses and brackets are reported as well; the
+        declarations either way are still extracted.
+        """
+        body_start = self.pos
+        stack: List[Token] = []
+        idx = body_start
+        while idx < len(self.tokens):
+            tok = self.tokens[idx]
+            if tok.kind == "op" and tok.value in "({[":
+                stack.append(tok)
+            elif tok.kind == "op" and tok.value in ")}]":
+                if not any(_CLOSERS[opener.value] == tok.value for opener in stack):
+                    self._error(f"unexpected '{tok.value}'", tok)
+                else:
+                    while _CLOSERS[stack[-1].value] != tok.value:
+                        self._error(f"unclosed '{stack[-1].value}'", stack.pop())
+                    stack.pop()
+                    if not stack:
+                        break
+            idx += 1
+        if idx < len(self.tokens):
+            self.pos = idx + 1
+            return body_start, idx
+
+        # Ran off the end: the body stops at the next top-level declaration
+        idx = next((i for i in range(body_start + 1, len(self.tokens)) if self._at_decl(i)), len(self.tokens))
+        self._error("unclosed '{'", self.tokens[body_start])
+        self.pos = idx
+        while self.pos - 1 > body_start and self.tokens[self.pos - 1].implicit:
+            self.pos -= 1
+        return body_start, self.pos - 1
+
+    # ------------------------------------------------------------------
+    # Function body facts
+    # ------------------------------------------------------------------
+
+    def _body_metrics(self, start: int, end: int) -> Dict[str, int]:
+        """
+        Size and complexity proxies for a function body.
+
+        "statements" counts statement terminators (explicit and inserted
+        semicolons); "complexity" is a cyclomatic estimate: one plus every
+        if, for, case, select/communication clause, && and ||.
+        "max_nesting" is the deepest if/else/for/switch/select block
+        nesting, 0 for a body without any.
+        """
+        statements = 0
+        complexity = 1
+        # One entry per open brace: whether it opens a control block
+        blocks: List[bool] = []
+        header = False
+        max_nesting = 0
+        for idx in range(start + 1, end):
+            tok = self.tokens[idx]
+            if tok.kind == "op" and tok.value == ";":
+                statements += 1
+            elif tok.kind == "keyword" and tok.value in ("if", "for", "case"):
+                complexity += 1
+            elif tok.kind == "op" and tok.value in ("&&", "||"):
+                complexity += 1
+            if tok.kind == "keyword" and tok.value in ("if", "else", "for", "switch", "select"):
+                header = True
+            elif tok.kind == "op" and tok.value == "{":
+                # A composite literal in a header (`range []int{1, 2} {`) is followed by more header
+                control = header and self._ends_statement(self._match_forward(idx, end) + 1, end)
+                if control:
+                    header = False
+                blocks.append(control)
+                max_nesting = max(max_nesting, sum(blocks))
+            elif tok.kind == "op" and tok.value == "}" and blocks:
+                blocks.pop()
+        return {"statements": statements, "complexity": complexity, "max_nesting": max_nesting}
+
+    def _ends_statement(self, idx: int, end: int) -> bool:
+        """Whether a block closing just before tokens[idx] ends its statement."""
+        if idx >= end:
+            return True
+        tok = self.tokens[idx]
+        return (tok.kind == "op" and tok.value in (";", "}")) or (tok.kind == "keyword" and tok.value == "else")
+
+    def _call_statement(self, first: int, close: int) -> bool:
+        """
+        Whether the call spanning tokens[first:close + 1] (its go or defer
+        included) is a statement of its own, so its results go unused.
+        """
+        before, after = self.tokens[first - 1], self.tokens[close + 1]
+        if before.kind != "op" or after.kind != "op" or after.value not in (";", "}"):
+            return False
+        if before.value == ";":
+            return True
+        if before.value == ":":
+            # A case clause or label, not the key of a composite literal
+            return after.value == ";"
+        # `{ f() }` is a block only after a signature or condition, `T{f()}` is a literal
+        return before.value == "{" and (after.value == ";" or self.tokens[first - 2].value == ")")
+
+    def _line_count(self, start: int, end: int) -> int:
+        """Lines holding a token between two token indexes - comment-only and blank lines left out."""
+        lines = set()
+        for tok in self.tokens[start:end + 1]:
+            if not tok.implicit:
+                lines.update(range(tok.line, tok.end_line + 1))
+        return len(lines)
+
+    def _match_back(self, idx: int, open_val: str, close_val: str, lower: int) -> int:
+        """Return the index of the bracket opening the one closed at idx, or -1."""
+        depth = 0
+        while idx >= lower:
+            tok = self.tokens[idx]
+            if tok.kind == "op":
+                if tok.value == close_val:
+                    depth += 1
+                elif tok.value == open_val:
+                    depth -= 1
+                    if depth == 0:
+                        return idx
+            idx -= 1
+        return -1
+
+    def _match_forward(self, idx: int, upper: int) -> int:
+        """Return the index of the bracket closing the one opened at idx, or upper."""
+        pairs = {"(": ")", "[": "]", "{": "}"}
+        open_val = self.tokens[idx].value
+        close_val = pairs[open_val]
+        depth = 0
+        while idx < upper:
+            tok = self.tokens[idx]
+            if tok.kind == "op":
+                if tok.value == open_val:
+                    depth += 1
+                elif tok.value == close_val:
+                    depth -= 1
+                    if depth == 0:
+                        return idx
+            idx += 1
+        return upper
+
+    def _chain_back(self, idx: int, lower: int) -> Tuple[Optional[List[str]], int]:
+        """
+        Walk a selector chain backwards from the token at idx.
+
+        `json.NewEncoder(w).Encode` ending at `Encode` yields
+        ["json", "NewEncoder", "()", "Encode"]; calls and index expressions
+        inside the chain become "()" and "[]" elements.
+
+        Returns:
+            (elements, start index) or (None, idx) if this is not a chain
+        """
+        elems: List[str] = []
+        k = idx
+        while k >= lower:
+            tok = self.tokens[k]
+            if tok.kind == "ident":
+                elems.insert(0, tok.value)
+                prev = self.tokens[k - 1] if k - 1 >= lower else None
+                if prev is not None and prev.kind == "op" and prev.value == ".":
+                    k -= 2
+                    continue
+                return elems, k
+            if tok.kind == "op" and tok.value in (")", "]"):
+                open_val = "(" if tok.value == ")" else "["
+                m = self._match_back(k, open_val, tok.value, lower)
+                if m <= lower:
+                    return None, idx
+                elems.insert(0, "()" if open_val == "(" else "[]")
+                k = m - 1
+                continue
+            return None, idx
+        return None, idx
+
+    def _expr_source(self, start: int, end: int) -> Optional[Dict[str, Any]]:
+        """
+        Describe where the value of the expression tokens[start:end] comes from.
+
+        Types cannot be computed inside a single file (callees may live
+        elsewhere), so this returns a lazily evaluated description that
+        go_analysis resolves against the whole project.
+        """
+        while end > start and self.tokens[end - 1].implicit:
+            end -= 1
+        if start >= end:
+            return None
+        first = self.tokens[start]
+        last = self.tokens[end - 1]
+
+        if first.kind in ("string", "ident") or (first.kind == "op" and first.value == "("):
+            value = self._const_value(start, end)
+            if isinstance(value, str):
+                return {"type": "string", "value": value}
+            template = self._string_template(start, end)
+            if template is not None:
+                return {"type": "string", "template": template}
+            if first.kind == "string":
+                return {"type": "string"}
+        if first.kind in ("int", "float", "imag", "char") and end - start == 1:
+            return {"type": {"int": "int", "float": "float64", "imag": "complex128", "char": "rune"}[first.kind]}
+        if first.kind == "ident" and first.value in ("true", "false") and end - start == 1:
+            return {"type": "bool"}
+        if first.kind == "ident" and first.value == "nil" and end - start == 1:
+            return {"nil": True}
+
+        if first.kind == "keyword" and first.value == "func":
+            saved = self.pos
+            self.pos = start
+            try:
+                self.pos += 1
+                sig_start = self.pos
+                self._parse_signature()
+                source = {"type": "func" + self._text(sig_start, self.pos), "func_literal": True}
+                literal = self._func_literal(start, end)
+                if literal is not None:
+                    # The body's extent and parameters scope calls made inside it (router groups)
+                    source["end_line"] = self.tokens[literal[2]].end_line
+                    if literal[0]:
+                        source["params"] = literal[0]
+                return source
+            except GoSyntaxError:
+                return None
+            finally:
+                self.pos = saved
+
+        pointer = False
+        type_start = start
+        if first.kind == "op" and first.value == "&":
+            pointer = True
+            type_start += 1
+
+        # Composite literal: T{...}, &T{...}, []T{...}, map[K]V{...}
+        if last.kind == "op" and last.value == "}":
+            open_idx = self._match_back(end - 1, "{", "}", type_start)
+            if open_idx > type_start and self._range_is_type(type_start, open_idx):
+                text = self._text(type_start, open_idx)
+                return {"composite": text, "type": ("*" if pointer else "") + text}
+
+        if pointer:
+            inner = self._expr_source(type_start, end)
+            if inner and "type" in inner:
+                return {"type": "*" + inner["type"]}
+            return {"address_of": inner} if inner else None
+
+        if first.kind == "ident" and first.value in ("make", "new") and end - start > 2 and \
+                self.tokens[start + 1].value == "(" and last.value == ")":
+            type_end = start + 2
+            depth = 0
+            while type_end < end - 1:
+                tok = self.tokens[type_end]
+                if tok.kind == "op" and tok.value in "([{":
+                    depth += 1
+                elif tok.kind == "op" and tok.value in ")]}":
+                    depth -= 1
+                elif tok.kind == "op" and tok.value == "," and depth == 0:
+                    break
+                type_end += 1
+            text = self._text(start + 2, type_end)
+            return {"type": ("*" + text) if first.value == "new" else text}
+
+        if first.kind == "op" and first.value == "<-":
+            inner = self._expr_source(start + 1, end)
+            return {"receive": inner} if inner else None
+
+        # Type assertion x.(T)
+        if last.kind == "op" and last.value == ")" and end - start > 3:
+            open_idx = self._match_back(end - 1, "(", ")", start)
+            if open_idx > start and self.tokens[open_idx - 1].value == ".":
+                if self.tokens[open_idx + 1].value == "type":
+                    return None
+                return {"type": self._text(open_idx + 1, end - 1), "assertion": True}
+
+        if last.kind in ("ident",) or (last.kind == "op" and last.value in (")", "]")):
+            elems, chain_start = self._chain_back(end - 1, start)
+            if elems is not None and chain_start == start:
+                return {"chain": elems}
+        return None
+
+    def _string_template(self, start: int, end: int) -> Optional[str]:
+        """
+        Render a string built at runtime with its dynamic parts as placeholders.
+
+        `"SELECT * FROM " + table + " WHERE id = ?"` becomes
+        `SELECT * FROM {table} WHERE id = ?`, and fmt.Sprintf verbs are
+        replaced by their arguments the same way. Returns None unless the
+        expression contains at least one string literal.
+        """
+        operands = []
+        depth = 0
+        part_start = start
+        for idx in range(start, end):
+            tok = self.tokens[idx]
+            if tok.kind == "op":
+                if tok.value in ("(", "[", "{"):
+                    depth += 1
+                elif tok.value in (")", "]", "}"):
+                    depth -= 1
+                elif tok.value == "+" and depth == 0:
+                    operands.append((part_start, idx))
+                    part_start = idx + 1
+        operands.append((part_start, end))
+
+        pieces, literal_seen = [], False
+        for a, b in operands:
+            if a >= b:
+                return None
+            value = self._const_value(a, b)
+            if isinstance(value, str):
+                pieces.append(value)
+                literal_seen = literal_seen or self.tokens[a].kind == "string"
+                continue
+            # fmt.Sprintf("... %s ...", x)
+            if b - a > 4 and self.tokens[a].value == "fmt" and self.tokens[a + 1].value == "." \
+                    and self.tokens[a + 2].value == "Sprintf" and self.tokens[a + 3].value == "(":
+                close = self._match_forward(a + 3, b)
+                args = [(x, y) for x, y in self._split_exprs(a + 4, close) if x < y]
+                fmt_value = self._const_value(*args[0]) if args else None
+                if isinstance(fmt_value, str):
+                    fills = [self._text(x, y) for x, y in args[1:]]
+                    pieces.append(_fill_format(fmt_value, fills))
+                    literal_seen = True
+                    continue
+            pieces.append("{" + self._text(a, b) + "}")
+        return "".join(pieces) if literal_seen else None
+
+    def _rhs_end(self, idx: int, upper: int, header: bool) -> int:
+        """Find where the right-hand side starting at idx ends."""
+        depth = 0
+        while idx < upper:
+            tok = self.tokens[idx]
+            if tok.kind == "op":
+                if tok.value in ("(", "["):
+                    depth += 1
+                elif tok.value in (")", "]"):
+                    if depth == 0:
+                        return idx
+                    depth -= 1
+                elif tok.value == "{":
+                    if depth == 0 and header:
+                        return idx
+                    idx = self._match_forward(idx, upper) + 1
+                    continue
+                elif tok.value == "}" and depth == 0:
+                    return idx
+                elif tok.value == ";" and depth == 0:
+                    return idx
+            idx += 1
+        return upper
+
+    def _split_exprs(self, start: int, end: int) -> List[Tuple[int, int]]:
+        """Split tokens[start:end] at top-level commas."""
+        parts = []
+        depth = 0
+        part_start = start
+        for idx in range(start, end):
+            tok = self.tokens[idx]
+            if tok.kind == "op":
+                if tok.value in ("(", "[", "{"):
+                    depth += 1
+                elif tok.value in (")", "]", "}"):
+                    depth -= 1
+                elif tok.value == "," and depth == 0:
+                    parts.append((part_start, idx))
+                    part_start = idx + 1
+        parts.append((part_start, end))
+        return parts
+
+    def _body_facts(self, start: int, end: int) -> Dict[str, Any]:
+        """
+        Collect locals, call sites, and value references inside a function body.
+
+        Calls on the right-hand side of an assignment carry the names
+        assigned in "assigned_to" and, when the call is the whole expression,
+        the positions of its results assigned to `_` in "blank"; calls that
+        are statements of their own carry "statement". Value references
+        naming the type of a composite literal (`&User{}`) carry "composite".
+        "conditions" (see _conditions) is only present when the body has any.
+
+        Returns:
+            {"locals": {name: source}, "calls": [...], "value_refs": [...], "conditions": [...]}
+        """
+        tokens = self.tokens
+        local_sources: Dict[str, Dict[str, Any]] = {}
+        calls: List[Dict[str, Any]] = []
+        value_refs: List[Dict[str, Any]] = []
+        seen_refs = set()
+        # Token index of each call's '(' / (rhs start, rhs end, names) of each assignment
+        call_tokens: List[int] = []
+        call_spans: List[Tuple[int, int]] = []
+        assigned: List[Tuple[int, int, List[str]]] = []
+        # (rhs start, rhs end) of each expression assigned to `_` -> positions of its results dropped
+        blanks: Dict[Tuple[int, int], List[int]] = {}
+
+        for idx in range(start + 1, end):
+            tok = tokens[idx]
+
+            # Short variable declarations: a, b := rhs
+            if tok.kind == "op" and tok.value == ":=":
+                names = []
+                j = idx - 1
+                while j > start and tokens[j].kind == "ident":
+                    names.insert(0, tokens[j].value)
+                    if not (tokens[j - 1].kind == "op" and tokens[j - 1].value == ","):
+                        break
+                    j -= 2
+                before = tokens[j - 1] if names else None
+                header = before is not None and before.kind == "keyword" and before.value in ("if", "for", "switch")
+                rhs_start = idx + 1
+                rhs_end = self._rhs_end(rhs_start, end, header)
+                if rhs_start < rhs_end and tokens[rhs_start].kind == "keyword" and tokens[rhs_start].value == "range":
+                    source = self._expr_source(rhs_start + 1, rhs_end)
+                    for pos, name in enumerate(names):
+                        if name != "_" and source is not None:
+                            local_sources.setdefault(name, {"range": source, "index": pos})
+                    continue
+                exprs = self._split_exprs(rhs_start, rhs_end)
+                self._assignment_spans(names, exprs, assigned, blanks)
+                for pos, name in enumerate(names):
+                    if name == "_":
+                        continue
+                    if len(exprs) == len(names):
+                        source = self._expr_source(*exprs[pos])
+                    else:
+                        source = self._expr_source(*exprs[0])
+                        if source is not None:
+                            source = {"tuple": source, "index": pos}
+                    local_sources.setdefault(name, source or {})
+
+            # var x T / var x = rhs
+            elif tok.kind == "keyword" and tok.value == "var" and idx + 1 < end:
+                j = idx + 1
+                names = []
+                while j < end and tokens[j].kind == "ident":
+                    names.append(tokens[j].value)
+                    if tokens[j + 1].kind == "op" and tokens[j + 1].value == ",":
+                        j += 2
+                        continue
+                    j += 1
+                    break
+                if not names:
+                    continue
+                type_source = None
+                if not (tokens[j].kind == "op" and tokens[j].value in ("=", ";")):
+                    type_start = j
+                    depth = 0
+                    while j < end and not (depth == 0 and tokens[j].kind == "op" and tokens[j].value in ("=", ";")):
+                        if tokens[j].kind == "op" and tokens[j].value in "([{":
+                            depth += 1
+                        elif tokens[j].kind == "op" and tokens[j].value in ")]}":
+                            depth -= 1
+                        j += 1
+                    type_source = {"type": self._text(type_start, j)}
+                for name in names:
+                    if name == "_":
+                        continue
+                    if type_source is not None:
+                        local_sources.setdefault(name, type_source)
+                    elif tokens[j].value == "=":
+                        rhs_end = self._rhs_end(j + 1, end, False)
+                        exprs = self._split_exprs(j + 1, rhs_end)
+                        pos = names.index(name)
+                        source = self._expr_source(*exprs[pos]) if pos < len(exprs) else None
+                        local_sources.setdefault(name, source or {})
+
+            # Assignments to local names: a, b = rhs (and the = of var x = rhs)
+            elif tok.kind == "op" and tok.value == "=" and tokens[idx - 1].kind == "ident":
+                names = []
+                j = idx - 1
+                while j > start and tokens[j].kind == "ident":
+                    names.insert(0, tokens[j].value)
+                    if not (tokens[j - 1].kind == "op" and tokens[j - 1].value == ","):
+                        break
+                    j -= 2
+                before = tokens[j - 1]
+                # Not a field (s.x = ...) nor the type of `var x T = ...`
+                if before.kind == "ident" or (before.kind == "op" and before.value == "."):
+                    continue
+                self._assignment_spans(names, self._split_exprs(idx + 1, self._rhs_end(idx + 1, end, False)),
+                                       assigned, blanks)
+
+            # Call sites: chain followed by '('
+            elif tok.kind == "op" and tok.value == "(" and idx - 1 > start:
+                prev = tokens[idx - 1]
+                if not (prev.kind == "ident" or (prev.kind == "op" and prev.value in (")", "]"))):
+                    continue
+                elems, chain_start = self._chain_back(idx - 1, start + 1)
+                if elems is None:
+                    continue
+                before = tokens[chain_start - 1]
+                if before.kind == "op" and before.value == ".":
+                    continue
+                kind = "call"
+                if before.kind == "keyword" and before.value in ("go", "defer"):
+                    kind = before.value
+                close = self._match_forward(idx, end)
+                name_tok = prev if prev.kind == "ident" else tokens[chain_start]
+                arg_ranges = [(a, b) for a, b in self._split_exprs(idx + 1, close) if a < b]
+                calls.append({
+                    "chain": elems,
+                    "kind": kind,
+                    "line": name_tok.line,
+                    "column": name_tok.col,
+                    "args": [self._expr_source(a, b) for a, b in arg_ranges],
+                    "arg_texts": [self._text(a, b) for a, b in arg_ranges],
+                })
+                if self._call_statement(chain_start if kind == "call" else chain_start - 1, close):
+                    calls[-1]["statement"] = True
+                call_tokens.append(idx)
+                call_spans.append((chain_start, close + 1))
+
+            # Value references: an identifier chain not followed by a call
+            elif tok.kind == "ident":
+                prev = tokens[idx - 1]
+                if prev.kind == "op" and prev.value == ".":
+                    continue
+                j = idx
+                elems = [tok.value]
+                while j + 2 < end and tokens[j + 1].kind == "op" and tokens[j + 1].value == "." \
+                        and tokens[j + 2].kind == "ident":
+                    elems.append(tokens[j + 2].value)
+                    j += 2
+                after = tokens[j + 1] if j + 1 < end else None
+                if after is not None and after.kind == "op" and after.value in ("(", ":=", ":"):
+                    continue
+                key = (tuple(elems), tok.line)
+                if key in seen_refs:
+                    continue
+                seen_refs.add(key)
+                value_refs.append({"chain": elems, "line": tok.line, "column": tokens[j].col})
+                # `T{...}` where an expression starts, not the block of an if, for or switch
+                if after is not None and after.kind == "op" and after.value == "{" and (
+                        (prev.kind == "op" and prev.value in _COMPOSITE_OPENERS)
+                        or (prev.kind == "keyword" and prev.value == "return")):
+                    value_refs[-1]["composite"] = True
+
+        # A call inside the right-hand side of an assignment feeds the names assigned
+        for call, idx, span in zip(calls, call_tokens, call_spans):
+            spans = [span for span in assigned if span[0] <= idx < span[1]]
+            if spans:
+                call["assigned_to"] = min(spans, key=lambda span: span[1] - span[0])[2]
+            if span in blanks:
+                call["blank"] = blanks[span]
+
+        facts = {"locals": local_sources, "calls": calls, "value_refs": value_refs}
+        conditions = self._conditions(start, end)
+        if conditions:
+            facts["conditions"] = conditions
+        return facts
+
+    @staticmethod
+    def _assignment_spans(names: List[str], exprs: List[Tuple[int, int]],
+                          assigned: List[Tuple[int, int, List[str]]],
+                          blanks: Dict[Tuple[int, int], List[int]]):
+        """
+        Record the token range of each assigned expression with the names it
+        is assigned to, and in blanks the positions of its results given to `_`.
+        """
+        for pos, (a, b) in enumerate(exprs):
+            targets = [names[pos]] if len(exprs) == len(names) else names
+            dropped = [k for k, name in enumerate(targets) if name == "_"]
+            if dropped and a < b:
+                blanks[(a, b)] = dropped
+            targets = [name for name in targets if name != "_"]
+            if targets and a < b:
+                assigned.append((a, b, targets))
+
+    def _conditions(self, start: int, end: int) -> List[Dict[str, Any]]:
+        """
+        The headers of if and switch statements and the expressions of case
+        clauses, with the names each reads: where values decide control flow.
+        """
+        tokens = self.tokens
+        conditions = []
+        for idx in range(start + 1, end):
+            tok = tokens[idx]
+            if tok.kind != "keyword" or tok.value not in ("if", "switch", "case"):
+                continue
+            clause = tok.value == "case"
+            j = idx + 1
+            while j < end:
+                cur = tokens[j]
+                if cur.kind == "op" and cur.value in ("(", "["):
+                    j = self._match_forward(j, end)
+                elif cur.kind == "op" and cur.value == "{":
+                    close = self._match_forward(j, end)
+                    # A composite literal in the header (`if x == (T{}) {`) is followed by more header
+                    if not clause and self._ends_statement(close + 1, end):
+                        break
+                    j = close
+                elif clause and cur.kind == "op" and cur.value == ":":
+                    break
+                j += 1
+            names = sorted({tokens[k].value for k in range(idx + 1, j)
+                            if tokens[k].kind == "ident" and tokens[k - 1].value != "."})
+            if names and j > idx + 1:
+                conditions.append({"kind": tok.value, "line": tok.line, "column": tok.col,
+                                   "end_line": tokens[j - 1].end_line, "end_column": tokens[j - 1].end_col,
+                                   "names": names})
+        return conditions
+
+    def _go_literal_ranges(self, start: int, end: int) -> List[Tuple[int, int]]:
+        """Return the body token ranges of `go func() {...}()` literals in a function."""
+        ranges = []
+        for idx in range(start, end - 1):
+            tok = self.tokens[idx]
+            if tok.kind == "keyword" and tok.value == "go" and self.tokens[idx + 1].value == "func":
+                j = idx + 2
+                while j < end and not (self.tokens[j].kind == "op" and self.tokens[j].value == "{"):
+                    j += 1
+                if j < end:
+                    ranges.append((j, self._match_forward(j, end)))
+        return ranges
+
+    def _func_literal(self, idx: int, end: int) -> Optional[Tuple[List[str], int, int]]:
+        """For the `func` keyword at idx, the literal's parameter names and the indices of its body braces."""
+        if idx + 1 >= end or self.tokens[idx + 1].value != "(":
+            return None
+        close = self._match_forward(idx + 1, end)
+        names = []
+        for k in range(idx + 2, close):
+            # A name opens each parameter unless the list is types only; `a, b int` names both
+            tok = self.tokens[k]
+            if tok.kind == "ident" and self.tokens[k - 1].value in ("(", ",") \
+                    and self.tokens[k + 1].value not in (")", "."):
+                names.append(tok.value)
+        j = close + 1
+        while j < end and not (self.tokens[j].kind == "op" and self.tokens[j].value == "{"):
+            if self.tokens[j].kind == "op" and self.tokens[j].value in ("(", "["):
+                j = self._match_forward(j, end)
+            j += 1
+        if j >= end:
+            return None
+        return names, j, self._match_forward(j, end)
+
+    def _loops(self, start: int, end: int) -> List[Tuple[int, int, List[str], int]]:
+        """(body open, body close, variables declared by the header, line) of every for loop in a body."""
+        loops = []
+        for idx in range(start + 1, end):
+            tok = self.tokens[idx]
+            if tok.kind != "keyword" or tok.value != "for":
+                continue
+            j = idx + 1
+            declare = None
+            first_semi = None
+            while j < end:
+                t = self.tokens[j]
+                if t.kind == "op" and t.value in ("(", "["):
+                    j = self._match_forward(j, end)
+                elif t.kind == "op" and t.value == "{":
+                    break
+                elif t.kind == "op" and t.value == ":=" and declare is None and first_semi is None:
+                    declare = j
+                elif t.kind == "op" and t.value == ";" and first_semi is None:
+                    first_semi = j
+                j += 1
+            if j >= end:
+                continue
+            names = []
+            if declare is not None:
+                k = declare - 1
+                while k > idx and self.tokens[k].kind == "ident":
+                    if self.tokens[k].value != "_":
+                        names.insert(0, self.tokens[k].value)
+                    if not (self.tokens[k - 1].kind == "op" and self.tokens[k - 1].value == ","):
+                        break
+                    k -= 2
+            loops.append((j, self._match_forward(j, end), names, tok.line))
+        return loops
+
+    def _chain_forward(self, idx: int, end: int) -> Tuple[Optional[List[str]], int]:
+        """
+        Walk a selector chain forwards from idx (`s.jobs`, `ctx.Done()`,
+        `chans[i]`), the counterpart of _chain_back. Returns (elements,
+        index after the chain) or (None, idx).
+        """
+        if idx >= end or self.tokens[idx].kind != "ident":
+            return None, idx
+        elems = [self.tokens[idx].value]
+        j = idx + 1
+        while j < end:
+            tok = self.tokens[j]
+            if tok.kind == "op" and tok.value == "." and j + 1 < end and self.tokens[j + 1].kind == "ident":
+                elems.append(self.tokens[j + 1].value)
+                j += 2
+            elif tok.kind == "op" and tok.value in ("(", "["):
+                elems.append("()" if tok.value == "(" else "[]")
+                j = self._match_forward(j, end) + 1
+            else:
+                break
+        return elems, j
+
+    def _made_channel(self, start: int, end: int) -> Optional[Dict[str, Any]]:
+        """The channel type and buffer of a `make(chan T, n)` expression, or None."""
+        while end > start and self.tokens[end - 1].implicit:
+            end -= 1
+        if end - start < 4 or self.tokens[start].value != "make" or self.tokens[start + 1].value != "(" \
+                or self._match_forward(start + 1, end) != end - 1:
+            return None
+        args = [(a, b) for a, b in self._split_exprs(start + 2, end - 1) if a < b]
+        channel = channel_type(self._text(*args[0])) if args else None
+        if channel is None:
+            return None
+        buffer: Any = 0
+        if len(args) > 1:
+            value = self._const_value(*args[1])
+            buffer = value if isinstance(value, int) else self._text(*args[1])
+        return {**channel, "buffer": buffer}
+
+    def _concurrency(self, start: int, end: int) -> Dict[str, Any]:
+        """
+        Goroutine launches, channel declarations and channel operations in a body.
+
+        "goroutines": `go` statements and `x.Go(func() {...})` launches
+        (errgroup, sync.WaitGroup); a launched literal lists the enclosing
+        loop variables it captures ("captures") unless they are passed as
+        arguments or re-declared in the loop body first. "channels": channels
+        made or declared in the body. "channel_ops": sends, receives, close
+        calls and range loops (the operand may not be a channel; callers
+        check), marked with the select statement or goroutine literal they
+        sit in. "selects": select statements, with their case count and
+        whether a default case makes them non-blocking.
+        """
+        tokens = self.tokens
+        loops = self._loops(start, end)
+        goroutines: List[Dict[str, Any]] = []
+        literal_ranges: List[Tuple[int, int, int]] = []
+        for idx in range(start + 1, end):
+            tok = tokens[idx]
+            launcher = None
+            if tok.kind == "keyword" and tok.value == "go":
+                func_idx = idx + 1
+            elif tok.kind == "ident" and tok.value == "Go" and tokens[idx - 1].value == "." \
+                    and tokens[idx + 1].value == "(" and tokens[idx + 2].value == "func":
+                elems, _ = self._chain_back(idx, start + 1)
+                launcher = ".".join(elems) if elems else "Go"
+                func_idx = idx + 2
+            else:
+                continue
+            entry: Dict[str, Any] = {"line": tok.line, "column": tok.col}
+            literal = self._func_literal(func_idx, end) if tokens[func_idx].value == "func" else None
+            if literal is not None:
+                params, body_open, body_close = literal
+                entry["kind"] = "literal"
+                entry["end_line"] = tokens[body_close].end_line
+                args: List[Tuple[int, int]] = []
+                if body_close + 1 < end and tokens[body_close + 1].value == "(":
+                    args_close = self._match_forward(body_close + 1, end)
+                    args = [(a, b) for a, b in self._split_exprs(body_close + 2, args_close) if a < b]
+                if params:
+                    entry["params"] = params
+                if args:
+                    entry["args"] = [self._expr_source(a, b) or {} for a, b in args]
+                    entry["arg_texts"] = [self._text(a, b) for a, b in args]
+                literal_ranges.append((body_open, body_close, tok.line))
+                used = {tokens[k].value for k in range(body_open + 1, body_close)
+                        if tokens[k].kind == "ident" and tokens[k - 1].value != "."}
+                for loop_open, loop_close, names, loop_line in loops:
+                    if not loop_open < idx < loop_close:
+                        continue
+                    # `v := v` (or any re-declaration) in the loop body gives each iteration its own copy
+                    redeclared = set()
+                    for k in range(loop_open + 1, idx):
+                        if tokens[k].kind == "op" and tokens[k].value == ":=":
+                            j = k - 1
+                            while j > loop_open and tokens[j].kind == "ident":
+                                redeclared.add(tokens[j].value)
+                                if tokens[j - 1].value != ",":
+                                    break
+                                j -= 2
+                    captured = [n for n in names if n in used and n not in params and n not in redeclared]
+                    if captured:
+                        entry.setdefault("captures", []).extend(
+                            {"name": n, "loop_line": loop_line} for n in captured)
+            else:
+                call_idx = func_idx
+                while call_idx < end and not (tokens[call_idx].kind == "op" and tokens[call_idx].value in ("(", ";")):
+                    if tokens[call_idx].kind == "op" and tokens[call_idx].value == "[":
+                        call_idx = self._match_forward(call_idx, end)
+                    call_idx += 1
+                if call_idx >= end or tokens[call_idx].value != "(":
+                    continue
+                entry["kind"] = "call"
+                entry["target"] = self._text(func_idx, call_idx)
+                elems, _ = self._chain_back(call_idx - 1, func_idx)
+                if elems:
+                    entry["chain"] = elems
+                args_close = self._match_forward(call_idx, end)
+                entry["arg_texts"] = [self._text(a, b) for a, b in self._split_exprs(call_idx + 1, args_close) if a < b]
+            if launcher:
+                entry["launcher"] = launcher
+            goroutines.append(entry)
+
+        # select statements: the comm clause of every case, and default
+        selects: List[Dict[str, Any]] = []
+        select_clauses: List[Tuple[int, int, int]] = []
+        for idx in range(start + 1, end):
+            tok = tokens[idx]
+            if tok.kind != "keyword" or tok.value != "select" or tokens[idx + 1].value != "{":
+                continue
+            close = self._match_forward(idx + 1, end)
+            cases = 0
+            has_default = False
+            k = idx + 2
+            while k < close:
+                t = tokens[k]
+                if t.kind == "op" and t.value in ("(", "[", "{"):
+                    k = self._match_forward(k, close) + 1
+                    continue
+                if t.kind == "keyword" and t.value == "case":
+                    colon = k + 1
+                    while colon < close and not (tokens[colon].kind == "op" and tokens[colon].value == ":"):
+                        if tokens[colon].kind == "op" and tokens[colon].value in ("(", "[", "{"):
+                            colon = self._match_forward(colon, close)
+                        colon += 1
+                    select_clauses.append((k, colon, tok.line))
+                    cases += 1
+                    k = colon
+                elif t.kind == "keyword" and t.value == "default":
+                    has_default = True
+                k += 1
+            selects.append({"line": tok.line, "column": tok.col, "cases": cases, "default": has_default})
+
+        def placed(entry: Dict[str, Any], idx: int) -> Dict[str, Any]:
+            for a, b, line in select_clauses:
+                if a < idx < b:
+                    entry["select_line"] = line
+                    break
+            for a, b, line in literal_ranges:
+                if a < idx < b:
+                    entry["in_goroutine"] = True
+                    entry["goroutine_line"] = line
+            return entry
+
+        channels: List[Dict[str, Any]] = []
+        ops: List[Dict[str, Any]] = []
+        for idx in range(start + 1, end):
+            tok = tokens[idx]
+            prev = tokens[idx - 1]
+            if tok.kind == "op" and tok.value == "<-":
+                nxt = tokens[idx + 1]
+                if (prev.kind == "keyword" and prev.value == "chan") or (nxt.kind == "keyword" and nxt.value == "chan"):
+                    continue
+                if prev.kind == "ident" or (prev.kind == "op" and prev.value in (")", "]")):
+                    elems, chain_start = self._chain_back(idx - 1, start + 1)
+                    if elems is None:
+                        continue
+                    first = tokens[chain_start]
+                    ops.append(placed({"op": "send", "chain": elems, "line": first.line, "column": first.col}, idx))
+                else:
+                    elems, _ = self._chain_forward(idx + 1, end)
+                    if elems is None:
+                        continue
+                    ops.append(placed({"op": "receive", "chain": elems, "line": tok.line, "column": tok.col}, idx))
+            elif tok.kind == "ident" and tok.value == "close" and tokens[idx + 1].value == "(" \
+                    and not (prev.kind == "op" and prev.value == "."):
+                close = self._match_forward(idx + 1, end)
+                elems, after = self._chain_forward(idx + 2, close)
+                if elems is not None and after == close:
+                    ops.append(placed({"op": "close", "chain": elems, "line": tok.line, "column": tok.col}, idx))
+            elif tok.kind == "keyword" and tok.value == "range":
+                elems, after = self._chain_forward(idx + 1, end)
+                if elems is not None and after < end and tokens[after].value == "{" and elems[-1] != "()":
+                    ops.append(placed({"op": "range", "chain": elems, "line": tok.line,
+                                       "column": tokens[idx + 1].col}, idx))
+            elif tok.kind == "op" and tok.value in (":=", "="):
+                # x := make(chan T, n) and field or multi-value forms
+                rhs_end = self._rhs_end(idx + 1, end, False)
+                exprs = self._split_exprs(idx + 1, rhs_end)
+                made = [self._made_channel(a, b) for a, b in exprs]
+                if not any(made):
+                    continue
+                k = idx - 1
+                targets: List[List[str]] = []
+                chain_start = idx
+                while k > start:
+                    elems, chain_start = self._chain_back(k, start + 1)
+                    if elems is None:
+                        break
+                    targets.insert(0, elems)
+                    if tokens[chain_start - 1].value != ",":
+                        break
+                    k = chain_start - 2
+                # `var c chan T = make(...)` is handled with its type below
+                if len(targets) != len(exprs) or tokens[chain_start - 1].value not in (";", "{", "}", "var"):
+                    continue
+                for target, channel, (a, _) in zip(targets, made, exprs):
+                    if channel:
+                        channels.append(placed({"name": ".".join(target), "chain": target, "kind": "make",
+                                                "line": tokens[a].line, "column": tokens[a].col, **channel}, idx))
+            elif tok.kind == "keyword" and tok.value == "var" and tokens[idx + 1].kind == "ident":
+                names = []
+                k = idx + 1
+                while k < end and tokens[k].kind == "ident":
+                    names.append(tokens[k])
+                    if tokens[k + 1].value != ",":
+                        break
+                    k += 2
+                type_start = k + 1
+                type_end = type_start
+                while type_end < end and not (tokens[type_end].kind == "op" and tokens[type_end].value in ("=", ";")):
+                    type_end += 1
+                channel = channel_type(self._text(type_start, type_end)) if type_end > type_start else None
+                if not channel:
+                    continue
+                made: List[Optional[Dict[str, Any]]] = []
+                if tokens[type_end].value == "=":
+                    rhs = self._split_exprs(type_end + 1, self._rhs_end(type_end + 1, end, False))
+                    made = [self._made_channel(a, b) for a, b in rhs]
+                for pos, name_tok in enumerate(names):
+                    # A nil channel until assigned, unless made here
+                    buffer = made[pos]["buffer"] if pos < len(made) and made[pos] else None
+                    channels.append(placed({"name": name_tok.value, "chain": [name_tok.value],
+                                            "kind": "make" if buffer is not None else "var",
+                                            "line": name_tok.line, "column": name_tok.col, **channel,
+                                            "buffer": buffer}, idx))
+
+        result: Dict[str, Any] = {}
+        for key, value in (("goroutines", goroutines), ("channels", channels), ("channel_ops", ops),
+                           ("selects", selects)):
+            if value:
+                result[key] = value
+        return result
+
+    def _failure_facts(self, start: int, end: int) -> Dict[str, Any]:
+        """
+        Type assertions and deferred function literals in a body.
+
+        "assertions": `x.(T)` outside the comma-ok form (`v, ok := x.(T)`),
+        which panic when x holds another type; "checked_assertions" the
+        comma-ok ones. Type switches are not assertions. "deferred": the
+        line range of every `defer func() {...}()`, where a recover() call
+        can stop a panic.
+        """
+        tokens = self.tokens
+        assertions = []
+        checked = []
+        deferred = []
+        for idx in range(start + 1, end - 1):
+            tok = tokens[idx]
+            if tok.kind == "keyword" and tok.value == "defer" and tokens[idx + 1].value == "func":
+                literal = self._func_literal(idx + 1, end)
+                if literal is not None:
+                    deferred.append({"line": tok.line, "end_line": tokens[literal[2]].end_line})
+                continue
+            if not (tok.kind == "op" and tok.value == "." and tokens[idx + 1].value == "("):
+                continue
+            if tokens[idx + 2].kind == "keyword" and tokens[idx + 2].value == "type":
+                continue
+            close = self._match_forward(idx + 1, end)
+            elems, expr_start = self._chain_back(idx - 1, start + 1)
+            if elems is None:
+                continue
+            # Comma-ok: the assertion is the whole right-hand side of `a, b :=` or `a, b =`
+            before = tokens[expr_start - 1]
+            after = tokens[close + 1] if close + 1 <= end else None
+            if before.kind == "op" and before.value in (":=", "=") and after is not None \
+                    and after.kind == "op" and after.value in (";", "{", ")", "}"):
+                k = expr_start - 2
+                targets = 0
+                while k > start and (tokens[k].kind == "ident" or tokens[k].value in ("]", ")")):
+                    elems_lhs, lhs_start = self._chain_back(k, start + 1)
+                    if elems_lhs is None:
+                        break
+                    targets += 1
+                    if tokens[lhs_start - 1].value != ",":
+                        break
+                    k = lhs_start - 2
+            else:
+                targets = 0
+            first = tokens[expr_start]
+            (checked if targets == 2 else assertions).append({
+                "line": tokens[idx].line,
+                "column": first.col,
+                "expression": self._text(expr_start, close + 1),
+                "asserted_type": self._text(idx + 2, close),
+            })
+        result: Dict[str, Any] = {}
+        if assertions:
+            result["assertions"] = assertions
+        if checked:
+            result["checked_assertions"] = checked
+        switches = self._type_switches(start, end)
+        if switches:
+            result["type_switches"] = switches
+        if deferred:
+            result["deferred"] = deferred
+        return result
+
+    def _error_returns(self, start: int, end: int) -> List[Dict[str, Any]]:
+        """
+        The error each return statement of a body gives back: the last
+        expression returned, as written, with "name" when it is a bare
+        identifier (`return nil, err`) and the call's chain in "call" when it
+        is a call (`return s.flush()`). Returns inside function literals
+        belong to the literal and are left out.
+        """
+        tokens = self.tokens
+        returns = []
+        idx = start + 1
+        while idx < end:
+            tok = tokens[idx]
+            if tok.kind == "keyword" and tok.value == "func":
+                literal = self._func_literal(idx, end)
+                # A func type (`var cb func() error`) ends its statement before any brace
+                if literal is not None and not any(tokens[k].value == ";" for k in range(idx, literal[1])):
+                    idx = literal[2] + 1
+                    continue
+            if tok.kind == "keyword" and tok.value == "return":
+                stmt_end = self._rhs_end(idx + 1, end, False)
+                exprs = [(a, b) for a, b in self._split_exprs(idx + 1, stmt_end) if a < b]
+                if exprs:
+                    a, b = exprs[-1]
+                    entry = {"line": tok.line, "column": tokens[a].col, "expression": self._text(a, b)}
+                    if b == a + 1 and tokens[a].kind == "ident":
+                        entry["name"] = tokens[a].value
+                    elif tokens[b - 1].value == ")":
+                        open_idx = self._match_back(b - 1, "(", ")", a)
+                        elems, chain_start = self._chain_back(open_idx - 1, a) if open_idx > a else (None, None)
+                        if elems is not None and chain_start == a:
+                            entry["call"] = elems
+                    returns.append(entry)
+                idx = stmt_end
+                continue
+            idx += 1
+        return returns
+
+    def _type_switches(self, start: int, end: int) -> List[Dict[str, Any]]:
+        """
+        The type switches of a body: the expression switched on, the name
+        bound to it (`switch v := x.(type)`) and the types of each case
+        clause, every type with its position (nil included, default aside).
+        """
+        tokens = self.tokens
+        switches = []
+        for idx in range(start + 1, end - 4):
+            if not (tokens[idx].kind == "op" and tokens[idx].value == "." and tokens[idx + 1].value == "("
+                    and tokens[idx + 2].kind == "keyword" and tokens[idx + 2].value == "type"
+                    and tokens[idx + 3].value == ")" and tokens[idx + 4].value == "{"):
+                continue
+            elems, expr_start = self._chain_back(idx - 1, start + 1)
+            if elems is None:
+                continue
+            before = tokens[expr_start - 1]
+            binding = tokens[expr_start - 2].value if before.kind == "op" and before.value == ":=" \
+                and tokens[expr_start - 2].kind == "ident" else None
+            body_end = self._match_forward(idx + 4, end)
+            cases = []
+            depth, k = 0, idx + 5
+            while k < body_end:
+                tok = tokens[k]
+                if tok.kind == "op" and tok.value in ("(", "[", "{"):
+                    depth += 1
+                elif tok.kind == "op" and tok.value in (")", "]", "}"):
+                    depth -= 1
+                elif depth == 0 and tok.kind == "keyword" and tok.value == "case":
+                    types, first, inner = [], k + 1, 0
+                    k += 1
+                    while k < body_end:
+                        tok = tokens[k]
+                        if tok.kind == "op" and tok.value in ("(", "[", "{"):
+                            inner += 1
+                        elif tok.kind == "op" and tok.value in (")", "]", "}"):
+                            inner -= 1
+                        elif inner == 0 and tok.kind == "op" and tok.value in (",", ":"):
+                            if k > first:
+                                types.append({"type": self._text(first, k), "line": tokens[first].line,
+                                              "column": tokens[first].col})
+                            first = k + 1
+                            if tok.value == ":":
+                                break
+                        k += 1
+                    cases.append({"line": types[0]["line"] if types else tokens[k].line, "types": types})
+                k += 1
+            switches.append({
+                "line": tokens[expr_start].line,
+                "column": tokens[expr_start].col,
+                "expression": self._text(expr_start, idx),
+                "binding": binding,
+                "cases": cases,
+            })
+        return switches
+
+    def _free_uses(self, func: Dict[str, Any], import_names: Set[str], skip: Set[str]) -> List[Dict[str, Any]]:
+        """
+        Classify every use of a name that is not local to a function body.
+
+        Package-level variables live in any file of the package, so callers
+        filter these by name afterwards. Each use records whether it reads or
+        writes the name: plain, compound and index/field assignments,
+        ++/--, and taking the address all count as writes.
+        """
+        if not func["body"]:
+            return []
+        start, end = func["body"]
+        tokens = self.tokens
+        go_ranges = self._go_literal_ranges(start, end)
+        uses = []
+        for idx in range(start + 1, end):
+            tok = tokens[idx]
+            if tok.kind != "ident" or tok.value in func["locals"] or tok.value in _PREDECLARED \
+                    or tok.value in _BASIC_TYPES:
+                continue
+            prev = tokens[idx - 1]
+            if prev.kind == "op" and prev.value == ".":
+                continue
+            qualifier = None
+            name_idx = idx
+            if tok.value in import_names:
+                if not (tokens[idx + 1].value == "." and tokens[idx + 2].kind == "ident"):
+                    continue
+                qualifier = tok.value
+                name_idx = idx + 2
+            elif tok.value in skip:
+                continue
+
+            # Walk the postfix chain: .field, [index], (call)
+            j = name_idx + 1
+            has_index = has_field = has_call = False
+            while j < end:
+                nxt = tokens[j]
+                if nxt.kind == "op" and nxt.value == "." and tokens[j + 1].kind == "ident":
+                    has_field = True
+                    j += 2
+                elif nxt.kind == "op" and nxt.value == "[":
+                    has_index = True
+                    j = self._match_forward(j, end) + 1
+                elif nxt.kind == "op" and nxt.value == "(" and not has_call:
+                    has_call = True
+                    j = self._match_forward(j, end) + 1
+                else:
+                    break
+            after = tokens[j] if j < end else None
+            if after is not None and after.kind == "op" and after.value == ":" and not has_index:
+                # Composite literal key or label
+                continue
+
+            access, kind = "read", "read"
+            if prev.kind == "op" and prev.value == "&" and not has_call:
+                access, kind = "write", "address_of"
+            elif has_call:
+                kind = "call" if (has_field or qualifier) and not has_index else "read"
+            elif after is not None and after.kind == "op":
+                assign_op = after.value
+                if assign_op == ",":
+                    assign_op = self._lhs_list_op(j, end)
+                if assign_op == "=":
+                    access = "write"
+                    kind = "index_assign" if has_index else ("field_assign" if has_field else "assign")
+                elif assign_op in ("+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<=", ">>=", "&^="):
+                    access, kind = "write", "compound_assign"
+                elif assign_op in ("++", "--"):
+                    access, kind = "write", "increment"
+
+            use = {
+                "name": tokens[name_idx].value,
+                "line": tok.line,
+                "column": tok.col,
+                "access": access,
+                "kind": kind,
+            }
+            if qualifier:
+                use["qualifier"] = qualifier
+            for a, b in go_ranges:
+                if a <= idx <= b:
+                    use["in_goroutine"] = True
+                    use["goroutine_line"] = tokens[a].line
+                    break
+            uses.append(use)
+        return uses
+
+    def _lhs_list_op(self, idx: int, end: int) -> Optional[str]:
+        """For `a, b[i], c.f = ...` return the assignment operator ending the list."""
+        depth = 0
+        while idx < end:
+            tok = self.tokens[idx]
+            if tok.kind == "op":
+                if tok.value in ("(", "[", "{"):
+                    depth += 1
+                elif tok.value in (")", "]", "}"):
+                    if depth == 0:
+                        return None
+                    depth -= 1
+                elif depth == 0 and tok.value in ("=", ":="):
+                    return tok.value
+                elif depth == 0 and tok.value not in (",", "."):
+                    return None
+                elif tok.value == ";":
+                    return None
+            elif tok.kind not in ("ident",) and depth == 0:
+                return None
+            idx += 1
+        return None
+
+    def _qualified_refs(self) -> List[Dict[str, Any]]:
+        """
+        Find `pkg.Name` selectors whose qualifier is an imported package name.
+
+        A qualifier preceded by '.' is a field access (`s.db.QueryRow`), and a
+        qualifier shadowed by a local or parameter of the enclosing function is
+        a variable, so neither counts as a package reference.
+        """
+        local_names = {}
+        for imp in self.imports:
+            if imp["kind"] in ("default", "alias"):
+                local_names[imp["name"]] = imp["path"]
+        if not local_names:
+            return []
+
+        spans = sorted((f["span"][0], f["span"][1], f) for f in self.functions)
+        refs = []
+        tokens = self.tokens
+        span_idx = 0
+        for idx in range(len(tokens) - 2):
+            tok = tokens[idx]
+            if tok.kind != "ident" or tok.value not in local_names:
+                continue
+            dot, sel = tokens[idx + 1], tokens[idx + 2]
+            if not (dot.kind == "op" and dot.value == "." and sel.kind == "ident"):
+                continue
+            if idx > 0 and tokens[idx - 1].kind == "op" and tokens[idx - 1].value == ".":
+                continue
+
+            enclosing = None
+            while span_idx < len(spans) and spans[span_idx][1] < idx:
+                span_idx += 1
+            if span_idx < len(spans) and spans[span_idx][0] <= idx:
+                enclosing = spans[span_idx][2]
+            if enclosing and tok.value in enclosing["locals"]:
+                continue
+
+            refs.append({
+                "package": local_names[tok.value],
+                "qualifier": tok.value,
+                "name": sel.value,
+                "line": tok.line,
+                "column": tok.col,
+                "in": enclosing["symbol"]["name"] if enclosing else None,
+            })
+        return refs
+
+
+def default_import_name(path: str) -> str:
+    """
+    Guess the package name an import path binds when it has no alias.
+
+    Go uses the package clause of the imported package, which we cannot see
+    for external modules, so fall back to the conventional last element while
+    skipping major-version suffixes (`/v2`) and `.vN`/`go-` decorations.
+    """
+    parts = [p for p in path.split("/") if p]
+    if not parts:
+        return path
+    name = parts[-1]
+    if len(parts) > 1 and name.startswith("v") and name[1:].isdigit():
+        name = parts[-2]
+    if "." in name:
+        base, _, suffix = name.rpartition(".")
+        if suffix.startswith("v") and suffix[1:].isdigit():
+            name = base
+    for prefix in ("go-", "go."):
+        if name.startswith(prefix) and len(name) > len(prefix):
+            name = name[len(prefix):]
+    return name.replace("-", "_")
+
+
+def parse_struct_tag(raw: str) -> Tuple[Dict[str, Dict[str, Any]], bool]:
+    """
+    Parse a struct tag using the reflect.StructTag conventions.
+
+    `json:"name,omitempty" db:"user_name"` becomes
+    {"json": {"name": "name", "options": ["omitempty"]}, "db": {"name": "user_name", "options": []}}.
+    A bare "-" value marks the field as skipped by that encoder.
+
+    Returns:
+        (tags, ok) - ok is False when the tag is malformed; tags then holds
+        whatever pairs parsed cleanly before the error.
+    """
+    tags: Dict[str, Dict[str, Any]] = {}
+    i = 0
+    n = len(raw)
+    while i < n:
+        while i < n and raw[i] == " ":
+            i += 1
+        if i >= n:
+            break
+        key_start = i
+        while i < n and raw[i] > " " and raw[i] not in ':"\x7f':
+            i += 1
+        key = raw[key_start:i]
+        if not key or i + 1 >= n or raw[i] != ":" or raw[i + 1] != '"':
+            return tags, False
+        i += 2
+        value_start = i
+        while i < n and raw[i] != '"':
+            if raw[i] == "\\":
+                i += 1
+            i += 1
+        if i >= n:
+            return tags, False
+        value = unquote('"' + raw[value_start:i] + '"')
+        i += 1
+
+        if value == "-":
+            tags[key] = {"name": "", "options": [], "skip": True}
+        else:
+            parts = value.split(",")
+            tags[key] = {"name": parts[0], "options": [p for p in parts[1:] if p]}
+    return tags, True
+
+
+def _test_kind(symbol: Dict[str, Any], testing: set) -> Optional[str]:
+    """What `go test` runs a function as, from its name and signature, or None."""
+    name, params, results = symbol["name"], symbol.get("params", []), symbol.get("results", [])
+    if results:
+        return None
+    if name == "TestMain":
+        return "main" if len(params) == 1 and _testing_type(params[0]["type"], testing) == "M" else None
+    for prefix, kind, param_type in _TEST_PREFIXES:
+        if not name.startswith(prefix):
+            continue
+        rest = name[len(prefix):]
+        # TestFoo and Test_foo count, Testfoo does not
+        if rest[:1].islower():
+            return None
+        if param_type is None:
+            return kind if not params else None
+        if len(params) == 1 and _testing_type(params[0]["type"], testing) == param_type:
+            return kind
+        return None
+    return None
+
+
+def _testing_type(type_text: str, testing: set) -> Optional[str]:
+    """"T" for *testing.T (under whatever name the file imports testing), and so on."""
+    if not type_text.startswith("*"):
+        return None
+    qualifier, _, name = type_text[1:].strip().partition(".")
+    return name if qualifier in testing else None
+
+
+def example_target(name: str) -> Optional[str]:
+    """
+    The symbol an example documents, by the go doc naming convention:
+    ExampleF -> F, ExampleT_M -> T.M, each with an optional _suffix that
+    starts with a lower-case letter. None for a package example.
+    """
+    rest = name[len("Example"):]
+    if not rest or rest.startswith("_"):
+        return None
+    parts = rest.split("_")
+    if len(parts) > 1 and parts[-1][:1].islower():
+        parts = parts[:-1]
+    return ".".join(parts[:2])
+
+
+def channel_type(type_text: str) -> Optional[Dict[str, str]]:
+    """
+    The direction and element type of a channel type ("chan<- int" ->
+    {"direction": "send", "element_type": "int"}), or None for other types.
+    """
+    text = type_text.strip()
+    if text.startswith("<-"):
+        rest = text[2:].lstrip()
+        if not re.match(r"chan\b", rest):
+            return None
+        return {"direction": "receive", "element_type": rest[4:].strip()}
+    if not re.match(r"chan\b", text):
+        return None
+    rest = text[4:].lstrip()
+    if rest.startswith("<-"):
+        return {"direction": "send", "element_type": rest[2:].strip()}
+    return {"direction": "both", "element_type": rest}
+
+
+def receiver_base_type(type_text: str) -> str:
+    """Return the bare type name of a receiver, e.g. `*Cache[K, V]` -> `Cache`."""
+    return type_text.lstrip("*").strip().split("[", 1)[0].strip()
+
+
+def receiver_type_params(type_text: str) -> List[Dict[str, str]]:
+    """Return the type parameter names a generic receiver binds, without constraints."""
+    if "[" not in type_text:
+        return []
+    inner = type_text.split("[", 1)[1].rsplit("]", 1)[0]
+    return [{"name": name.strip(), "constraint": ""} for name in inner.split(",") if name.strip()]
+
+
+def method_set(symbols: List[Dict[str, Any]], type_name: str, pointer: bool = False) -> Dict[str, Dict[str, Any]]:
+    """
+    Return the method set of `type_name` (or `*type_name`) keyed by method name.
+
+    Following the Go spec, the method set of a value type only holds methods
+    with value receivers, while the method set of a pointer (or an addressable
+    value) also holds methods declared on the pointer receiver.
+    """
+    methods = {}
+    for symbol in symbols:
+        receiver = symbol.get("receiver")
+        if symbol.get("type") != "method" or not receiver or receiver["type"] != type_name:
+            continue
+        if receiver["pointer"] and not pointer:
+            continue
+        methods[symbol["name"]] = symbol
+    return methods
+
+
+def format_type_params(type_params: List[Dict[str, str]]) -> str:
+    """Render type parameters back to Go syntax, e.g. `[K comparable, V any]`."""
+    if not type_params:
+        return ""
+    return "[" + ", ".join(f"{p['name']} {p['constraint']}" for p in type_params) + "]"
+
+
+def parse_go_source(content: str, test_file: bool = False, filename: Optional[str] = None) -> Dict[str, Any]:
+    """
+    Parse Go source text and return package, imports, and symbols; for a
+    _test.go file (test_file), test functions are classified as well. The
+    file's base name, when given, adds its _GOOS/_GOARCH build constraint.
+    """
+    return GoFileParser(content, test_file, filename).parse()
+
+
+def _mod_directives(content: str):
+    """Yield (directive, arguments, comment) for each line of a go.mod or go.work file, blocks unrolled."""
+    block = None
+    for raw in content.splitlines():
+        line, _, comment = raw.partition("//")
+        words = line.split()
+        if not words:
+            continue
+        if block:
+            if words == [")"]:
+                block = None
+                continue
+            directive = block
+        else:
+            directive, words = words[0], words[1:]
+            if words == ["("]:
+                block = directive
+                continue
+        yield directive, words, comment
+
+
+def _replace_directive(words: List[str]) -> Optional[Dict[str, Any]]:
+    arrow = words.index("=>")
+    old, new = words[:arrow], words[arrow + 1:]
+    if not old or not new:
+        return None
+    return {"path": old[0], "version": old[1] if len(old) > 1 else None,
+            "with": new[0], "with_version": new[1] if len(new) > 1 else None}
+
+
+def parse_go_mod(content: str) -> Dict[str, Any]:
+    """
+    Read the module path, go version and requirements of a go.mod file.
+
+    Returns:
+        {"module", "go", "require": [{"path", "version", "indirect"}],
+         "replace": [{"path", "version", "with", "with_version"}]}
+    """
+    result: Dict[str, Any] = {"module": None, "go": None, "require": [], "replace": []}
+    for directive, words, comment in _mod_directives(content):
+        if directive == "module" and words:
+            result["module"] = words[0].strip('"')
+        elif directive == "go" and words:
+            result["go"] = words[0]
+        elif directive == "require" and len(words) >= 2:
+            result["require"].append({"path": words[0], "version": words[1],
+                                      "indirect": comment.strip() == "indirect"})
+        elif directive == "replace" and "=>" in words:
+            replace = _replace_directive(words)
+            if replace:
+                result["replace"].append(replace)
+    return result
+
+
+def parse_go_work(content: str) -> Dict[str, Any]:
+    """
+    Read the go version, module directories and replacements of a go.work file.
+
+    Returns:
+        {"go", "use": [directory as written], "replace": [...]} with
+        replacements shaped as in parse_go_mod
+    """
+    result: Dict[str, Any] = {"go": None, "use": [], "replace": []}
+    for directive, words, _ in _mod_directives(content):
+        if directive == "go" and words:
+            result["go"] = words[0]
+        elif directive == "use" and words:
+            result["use"].append(words[0].strip('"'))
+        elif directive == "replace" and "=>" in words:
+            replace = _replace_directive(words)
+            if replace:
+                result["replace"].append(replace)
+    return result
@@ -0,0 +1,176 @@
+"""Call paths between Go functions, through the static call graph.
+
+CallPathFinder answers "can userHandler reach db.QueryRow, and how": the
+simple paths (no function twice, so recursion cannot loop them) of at most
+max_depth calls from one function to another, shortest first, each an
+ordered list of call edges with their call sites. Distances to the target
+are computed first, walking callers back from it, so only functions that
+can still reach it in the calls left are tried.
+
+Calls through an interface stop at the interface method (Store.Get) unless
+expanded: then an interface method steps on to the method of each type
+implementing it, an edge of kind "dispatch" naming the implementation.
+
+The target may be outside the project: "database/sql.DB.QueryRow", or
+any dot-separated tail of it ("sql.DB.QueryRow", "DB.QueryRow"), case
+aside, so "db.QueryRow" finds it too.
+
+entry_points lists where a production run can start - main and init
+functions and the HTTP handlers the project registers - for the reverse
+question, "is this code reachable in prod": the paths from any of them.
+"""
+
+import os
+from typing import Any, Callable, Dict, List, Optional, Set, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+
+Key = Tuple[str, str]
+
+# Paths tried before giving up on finding more (the result says "truncated")
+SEARCH_BUDGET = 200_000
+
+
+def external_targets(graph: GoCallGraph, name: str) -> List[Key]:
+    """The external callees whose qualified name is name or ends with it after a "/" or "."."""
+    wanted = name.lower()
+    targets = set()
+    for edge in graph.edges:
+        if not edge["external"]:
+            continue
+        qualified = edge["callee"][1].lower()
+        if qualified == wanted or qualified.endswith("." + wanted) or qualified.endswith("/" + wanted):
+            targets.add(edge["callee"])
+    return sorted(targets)
+
+
+def entry_points(graph: GoCallGraph, routes: List[Dict[str, Any]]) -> Dict[Key, Dict[str, Any]]:
+    """main and init functions and registered HTTP handlers, by node key, each with its kind."""
+    found: Dict[Key, Dict[str, Any]] = {}
+    for key, node in sorted(graph.nodes.items()):
+        if key[1] == "main" and node["package"] == "main":
+            found[key] = {"kind": "main"}
+        elif key[1] == "init":
+            found[key] = {"kind": "init"}
+    for route in routes:
+        handler = route.get("handler") or {}
+        if "path" not in handler:
+            continue
+        key = (os.path.dirname(handler["path"]), handler["name"])
+        if key in graph.nodes and key not in found:
+            method = route.get("method")
+            method = ",".join(method) if isinstance(method, list) else method
+            found[key] = {"kind": "http_handler", "route": f"{method} {route['route']}" if method else route["route"]}
+    return found
+
+
+class CallPathFinder:
+    """
+    Path search over a GoCallGraph. keep, given a file path, says whether
+    calls made in that file may be followed (include_tests, globs);
+    expanded adds the interface dispatch edges.
+    """
+
+    def __init__(self, graph: GoCallGraph, expanded: bool = False,
+                 keep: Optional[Callable[[str], bool]] = None):
+        self.graph = graph
+        self.expanded = expanded
+        self.forward: Dict[Key, Dict[Key, List[Dict[str, Any]]]] = {}
+        self.backward: Dict[Key, Dict[Key, List[Dict[str, Any]]]] = {}
+        for edge in graph.edges:
+            if keep is not None and not keep(edge["path"]):
+                continue
+            self.forward.setdefault(edge["caller"], {}).setdefault(edge["callee"], []).append(edge)
+            self.backward.setdefault(edge["callee"], {}).setdefault(edge["caller"], []).append(edge)
+        self._keep = keep
+        self._dispatch_out: Dict[Key, Dict[Key, List[Dict[str, Any]]]] = {}
+        self._dispatch_in: Dict[Key, Dict[Key, List[Dict[str, Any]]]] = {}
+
+    def _dispatch(self, interface_method: Key, implementation: Key, link: Dict[str, Any]) -> Dict[str, Any]:
+        node = self.graph.nodes[implementation]
+        return {"caller": interface_method, "callee": implementation, "external": False, "kind": "dispatch",
+                "path": node["path"], "line": node["line"], "column": None, **link}
+
+    def _successors(self, key: Key) -> Dict[Key, List[Dict[str, Any]]]:
+        successors = self.forward.get(key, {})
+        if not self.expanded or not self.graph.nodes.get(key, {}).get("interface_method"):
+            return successors
+        if key not in self._dispatch_out:
+            self._dispatch_out[key] = {
+                other: [self._dispatch(key, other, link)] for other, link in self.graph.interface_links(key)
+                if "implementation" in link and (self._keep is None or self._keep(self.graph.nodes[other]["path"]))}
+        return {**successors, **self._dispatch_out[key]}
+
+    def _predecessors(self, key: Key) -> Dict[Key, List[Dict[str, Any]]]:
+        predecessors = self.backward.get(key, {})
+        if not self.expanded or key not in self.graph.nodes or self.graph.nodes[key].get("interface_method"):
+            return predecessors
+        if key not in self._dispatch_in:
+            if self._keep is not None and not self._keep(self.graph.nodes[key]["path"]):
+                self._dispatch_in[key] = {}
+            else:
+                self._dispatch_in[key] = {
+                    other: [self._dispatch(other, key, {"implementation": key[1].rsplit(".", 1)[0]})]
+                    for other, link in self.graph.interface_links(key) if "interface" in link}
+        return {**predecessors, **self._dispatch_in[key]}
+
+    def distances(self, targets: Set[Key], max_depth: int) -> Dict[Key, int]:
+        """How many calls each function needs, at least, to reach one of targets (at most max_depth)."""
+        distance = {target: 0 for target in targets}
+        frontier = sorted(targets)
+        for level in range(1, max_depth + 1):
+            next_frontier = []
+            for key in frontier:
+                for other in self._predecessors(key):
+                    if other not in distance:
+                        distance[other] = level
+                        next_frontier.append(other)
+            frontier = next_frontier
+        return distance
+
+    def paths(self, sources: List[Key], targets: Set[Key], max_depth: int,
+              max_paths: int) -> Tuple[List[List[Dict[str, Any]]], bool, Dict[Key, int]]:
+        """
+        Up to max_paths paths from any of sources to any of targets, shortest
+        first, each a list of edges; whether the search budget ran out
+        before all were tried; and the distances computed on the way.
+        """
+        distance = self.distances(targets, max_depth)
+        found: List[List[Dict[str, Any]]] = []
+        budget = [SEARCH_BUDGET]
+
+        def extend(key: Key, length: int, path: List[Dict[str, Any]], on_path: Set[Key]) -> bool:
+            """Paths of exactly length calls from key; False once max_paths are found or the budget is spent."""
+            for other, edges in sorted(self._successors(key).items()):
+                if other in on_path or distance.get(other, max_depth + 1) > length - 1:
+                    continue
+                budget[0] -= 1
+                if budget[0] <= 0:
+                    return False
+                step = path + [_edge(edges)]
+                if other in targets:
+                    if length == 1:
+                        found.append(step)
+                        if len(found) >= max_paths:
+                            return False
+                    # A path ends at its first target
+                    continue
+                if not extend(other, length - 1, step, on_path | {other}):
+                    return False
+            return True
+
+        reachable = [source for source in sources if source in distance and source not in targets]
+        for length in range(min((distance[s] for s in reachable), default=max_depth + 1), max_depth + 1):
+            for source in reachable:
+                if distance[source] <= length and not extend(source, length, [], {source}):
+                    return found, budget[0] <= 0, distance
+        return found, False, distance
+
+
+def _edge(edges: List[Dict[str, Any]]) -> Dict[str, Any]:
+    """One step of a path: the first of the calls between two functions, and how many there are."""
+    first = min(edges, key=lambda e: (e["path"], e["line"], e["column"] or 0))
+    step = dict(first)
+    if len(edges) > 1:
+        step["call_sites"] = len(edges)
+    return step
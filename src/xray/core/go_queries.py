@@ -0,0 +1,107 @@
+"""Inline SQL extraction for Go code.
+
+Finds string queries handed to database/sql (Query, QueryRow, Exec, Prepare
+and their Context variants) and to sqlx (Get, Select, NamedExec, ...).
+Queries assembled at runtime are rendered with `{expr}` placeholders for the
+dynamic parts, which is what makes them worth reviewing for injection.
+"""
+
+import os
+import re
+from typing import Dict, List, Optional, Any
+
+from xray.core.go_analysis import GoCallGraph
+
+SQL_PACKAGES = ("database/sql", "github.com/jmoiron/sqlx")
+
+# Method name -> index of the query argument
+QUERY_METHODS = {
+    "Query": 0, "QueryRow": 0, "Exec": 0, "Prepare": 0,
+    "QueryContext": 1, "QueryRowContext": 1, "ExecContext": 1, "PrepareContext": 1,
+    # sqlx
+    "Queryx": 0, "QueryRowx": 0, "MustExec": 0, "Preparex": 0, "NamedExec": 0, "NamedQuery": 0,
+    "PrepareNamed": 0, "QueryxContext": 1, "QueryRowxContext": 1, "MustExecContext": 1,
+    "PreparexContext": 1, "NamedExecContext": 1, "NamedQueryContext": 1, "PrepareNamedContext": 1,
+    "Get": 1, "Select": 1, "GetContext": 2, "SelectContext": 2,
+}
+
+LOOKS_LIKE_SQL = re.compile(
+    r"^\s*(SELECT|INSERT|UPDATE|DELETE|WITH|CREATE|ALTER|DROP|REPLACE|MERGE|UPSERT|TRUNCATE)\b", re.I)
+
+
+class QueryExtractor:
+    """Collects SQL query call sites across every parsed Go file of a project."""
+
+    def __init__(self, graph: GoCallGraph):
+        self.graph = graph
+
+    def _query_text(self, path: str, func: Dict[str, Any], source: Optional[Dict[str, Any]],
+                    text: str) -> Dict[str, Any]:
+        """Return {"query", "dynamic"} for the query argument."""
+        source = source or {}
+        if "chain" in source and len(source["chain"]) == 1:
+            local = func.get("locals", {}).get(source["chain"][0])
+            if local and ("value" in local or "template" in local):
+                source = local
+            if local is None:
+                value = self.graph.constant_value(path, source["chain"])
+                if isinstance(value, str):
+                    return {"query": value, "dynamic": False}
+        elif "chain" in source and len(source["chain"]) == 2:
+            value = self.graph.constant_value(path, source["chain"])
+            if isinstance(value, str):
+                return {"query": value, "dynamic": False}
+        if "value" in source:
+            return {"query": source["value"], "dynamic": False}
+        if "template" in source:
+            return {"query": source["template"], "dynamic": True}
+        return {"query": "{" + text + "}", "dynamic": True}
+
+    def _api(self, path: str, func: Dict[str, Any], chain: List[str]) -> Optional[str]:
+        """Return the resolved SQL API (e.g. database/sql.DB.QueryRow) of a call, if it is one."""
+        target = self.graph.evaluate(path, func, chain)
+        if target is None or target[0] != "func" or target[1] != "external":
+            return None
+        qualified = target[2]
+        for package in SQL_PACKAGES:
+            if qualified.startswith(package + "."):
+                return qualified
+        return None
+
+    def queries_in(self, path: str) -> List[Dict[str, Any]]:
+        parsed = self.graph.project.files[path]
+        imports_sql = any(imp["path"] in SQL_PACKAGES for imp in parsed.get("imports", []))
+
+        queries = []
+        for func in parsed.get("functions", []):
+            for call in func.get("calls", []):
+                chain = call["chain"]
+                index = QUERY_METHODS.get(chain[-1])
+                if index is None or len(chain) < 2 or index >= len(call["args"]):
+                    continue
+                api = self._api(path, func, chain)
+                query = self._query_text(path, func, call["args"][index], call["arg_texts"][index])
+                if api is None:
+                    # Receiver type unknown: keep it only if it clearly is SQL
+                    if not imports_sql or not LOOKS_LIKE_SQL.match(query["query"]):
+                        continue
+                queries.append({
+                    **query,
+                    "method": chain[-1],
+                    "api": api,
+                    "confidence": "high" if api else "medium",
+                    "function": f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"],
+                    "path": path,
+                    "line": call["line"],
+                    "column": call["column"],
+                })
+        return queries
+
+    def extract(self, path: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Return every query call site, optionally limited to one file or directory."""
+        queries = []
+        for file_path in sorted(self.graph.project.files):
+            if path and file_path != path and not file_path.startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            queries.extend(self.queries_in(file_path))
+        return queries
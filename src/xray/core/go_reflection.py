@@ -0,0 +1,196 @@
+"""Where Go code leaves static typing: reflection, type assertions, marshaling.
+
+Four kinds of entry, each with the function it sits in and the concrete
+types it mentions. "reflect": calls to reflect.TypeOf, ValueOf and New,
+with the type of the value passed. "type_assertion": every `x.(T)`, comma-ok
+or not ("checked"). "type_switch": every `switch v := x.(type)` with the
+types of each case. "marshal": encoding/json, xml, yaml and the other
+encoders and decoders given an interface{} (or a map or slice of one), whose
+shape only shows at run time.
+
+A mentioned type the project declares is linked to its declaration
+("name", "path", "start_line"); types of other modules are named as written
+and carry their import path. Basic types, nil and interface literals are left
+out. The case types of type switches are also what what_breaks tags as
+"type_switch_case" references of a type.
+"""
+
+import os
+import re
+from typing import Any, Dict, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph, GoProject
+from xray.core.go_fields import codec_call
+from xray.core.go_helpers import file_imports
+
+REFLECTION_KINDS = ("reflect", "type_assertion", "type_switch", "marshal")
+
+_REFLECT_CALLS = {"TypeOf", "ValueOf", "New"}
+
+_TYPE_NAME = re.compile(r"[A-Za-z_]\w*(?:\.[A-Za-z_]\w*)?")
+_EMPTY_INTERFACE = re.compile(r"\binterface\s*\{\s*\}|\bany\b")
+# Words of a type expression that name no type
+_NOT_TYPES = {
+    "map", "chan", "func", "interface", "struct", "nil", "any", "error", "comparable",
+    "bool", "byte", "rune", "string", "uintptr", "int", "int8", "int16", "int32", "int64",
+    "uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64", "complex64", "complex128",
+}
+
+
+def switch_cases(project: GoProject, key: Tuple[str, str]) -> List[Dict[str, Any]]:
+    """Every type switch case naming a project type: {path, line, column, function, expression, type}."""
+    cases = []
+    for file_path, parsed in sorted(project.files.items()):
+        pkg_dir = os.path.dirname(file_path)
+        for func in parsed.get("functions", []):
+            name = f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+            for switch in func.get("type_switches", []):
+                for case in switch["cases"]:
+                    for case_type in case["types"]:
+                        names = _TYPE_NAME.findall(case_type["type"])
+                        if any(project.resolve_type_ref(pkg_dir, n) == key for n in names):
+                            cases.append({"path": file_path, "line": case_type["line"],
+                                          "column": case_type["column"], "function": name,
+                                          "expression": switch["expression"], "type": case_type["type"]})
+    return cases
+
+
+class ReflectionFinder:
+    """Collects reflection, type assertions and dynamic marshaling across a project."""
+
+    def __init__(self, graph: GoCallGraph):
+        self.graph = graph
+        self.project = graph.project
+
+    def _types(self, text: Optional[str], path: str, imports: Dict[str, str]) -> List[Dict[str, Any]]:
+        """The named types a type expression mentions, linked to their declarations."""
+        if not text:
+            return []
+        # Method sets and field lists of literal types are not types mentioned
+        text = re.sub(r"\b(interface|struct)\s*\{[^{}]*\}", "", text)
+        found = []
+        for name in _TYPE_NAME.findall(text):
+            if name in _NOT_TYPES:
+                continue
+            entry: Dict[str, Any] = {"name": name}
+            key = self.project.resolve_type_ref(os.path.dirname(path), name)
+            if key is not None:
+                symbol = self.project.types[key]
+                entry = {"name": symbol["name"], "path": symbol["path"], "start_line": symbol["start_line"]}
+            elif "." in name and name.split(".", 1)[0] in imports:
+                entry["import"] = imports[name.split(".", 1)[0]]
+            elif "." in name:
+                continue
+            if entry not in found:
+                found.append(entry)
+        return found
+
+    def _calls(self, path: str, func: Dict[str, Any], name: str,
+               imports: Dict[str, str]) -> List[Dict[str, Any]]:
+        entries = []
+        locals_ = func.get("locals", {})
+        for call in func.get("calls", []):
+            chain = call["chain"]
+            args = call.get("args") or []
+            texts = call.get("arg_texts") or []
+            if len(chain) == 2 and chain[0] not in locals_ and imports.get(chain[0]) == "reflect" \
+                    and chain[1] in _REFLECT_CALLS:
+                value_type = self.graph.value_type(path, func, args[0]) if args else None
+                entry = {"kind": "reflect", "function": name, "path": path, "line": call["line"],
+                         "column": call["column"], "call": f"reflect.{chain[1]}",
+                         "argument": ", ".join(texts), "types": self._types(value_type, path, imports)}
+                if value_type:
+                    entry["value_type"] = value_type
+                entries.append(entry)
+                continue
+            codec = codec_call(self.graph, path, func, call, imports)
+            if codec is None or codec[1] == "db":
+                continue
+            for idx, arg in enumerate(args):
+                value_type = self.graph.value_type(path, func, arg)
+                if not value_type or not _EMPTY_INTERFACE.search(value_type):
+                    continue
+                entries.append({"kind": "marshal", "function": name, "path": path, "line": call["line"],
+                                "column": call["column"], "call": codec[2] or chain[-1],
+                                "format": codec[1], "direction": "encode" if codec[0] == "read" else "decode",
+                                "argument": texts[idx] if idx < len(texts) else "", "value_type": value_type,
+                                "types": self._types(value_type, path, imports)})
+        return entries
+
+    def _function_entries(self, path: str, func: Dict[str, Any], imports: Dict[str, str]) -> List[Dict[str, Any]]:
+        name = f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+        entries = self._calls(path, func, name, imports)
+        for checked, key in ((False, "assertions"), (True, "checked_assertions")):
+            for assertion in func.get(key, []):
+                entries.append({"kind": "type_assertion", "function": name, "path": path,
+                                "line": assertion["line"], "column": assertion["column"],
+                                "expression": assertion["expression"], "asserted_type": assertion["asserted_type"],
+                                "checked": checked, "types": self._types(assertion["asserted_type"], path, imports)})
+        for switch in func.get("type_switches", []):
+            types: List[Dict[str, Any]] = []
+            for case in switch["cases"]:
+                for case_type in case["types"]:
+                    for mentioned in self._types(case_type["type"], path, imports):
+                        if mentioned not in types:
+                            types.append(mentioned)
+            entry = {"kind": "type_switch", "function": name, "path": path, "line": switch["line"],
+                     "column": switch["column"], "expression": switch["expression"],
+                     "cases": [{"line": c["line"], "types": [t["type"] for t in c["types"]]}
+                               for c in switch["cases"]],
+                     "types": types}
+            if switch.get("binding"):
+                entry["binding"] = switch["binding"]
+            entries.append(entry)
+        return entries
+
+    def _files(self, include_tests: bool, path: Optional[str]):
+        for file_path, parsed in sorted(self.project.files.items()):
+            is_test = file_path.endswith("_test.go")
+            if is_test and not include_tests:
+                continue
+            if path and file_path != path and not file_path.startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            imports = file_imports(parsed)
+            yield file_path, parsed, imports, is_test
+
+    def find(self, include_tests: bool = False, path: Optional[str] = None,
+             kinds: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        Reflection and dynamic typing grouped by package directory.
+
+        Args:
+            include_tests: Also scan _test.go files (entries get "in_test")
+            path: Only this file or package directory (and below)
+            kinds: Only these kinds (see REFLECTION_KINDS)
+        """
+        wanted = set(kinds or REFLECTION_KINDS)
+        packages: Dict[str, Dict[str, Any]] = {}
+        for file_path, parsed, imports, is_test in self._files(include_tests, path):
+            pkg_dir = os.path.dirname(file_path)
+            for func in parsed.get("functions", []):
+                for entry in self._function_entries(file_path, func, imports):
+                    if entry["kind"] not in wanted:
+                        continue
+                    if is_test:
+                        entry["in_test"] = True
+                    group = packages.setdefault(pkg_dir, {
+                        "package": parsed.get("package", ""),
+                        "directory": pkg_dir,
+                        "usages": [],
+                    })
+                    group["usages"].append(entry)
+
+        groups = []
+        for pkg_dir in sorted(packages):
+            group = packages[pkg_dir]
+            group["usages"].sort(key=lambda u: (u["path"], u["line"], u["column"] or 0))
+            group["counts"] = {kind: sum(1 for u in group["usages"] if u["kind"] == kind)
+                               for kind in REFLECTION_KINDS if kind in wanted}
+            groups.append(group)
+        return {
+            "packages": groups,
+            "total_count": sum(len(g["usages"]) for g in groups),
+            "counts": {kind: sum(g["counts"].get(kind, 0) for g in groups)
+                       for kind in REFLECTION_KINDS if kind in wanted},
+            "include_tests": include_tests,
+        }
@@ -0,0 +1,570 @@
+"""Rename previews for Go declarations - the edits a rename would make, without making them.
+
+A preview lists every place the name has to change: the declaration (and
+its per-platform variants), the doc comment that starts with it, and each
+reference - unqualified uses in its package, `pkg.Name` uses elsewhere,
+selectors whose receiver resolves to the declaring type, composite literal
+keys and method expressions. Each edit is a location with the text to put
+there.
+
+Alongside the edits come what the rename could break:
+
+- collisions: the new name already declared in the package, in the type's
+  method set, as an import of a file that uses the name, or as a local of a
+  function whose reference it would capture;
+- string literals that mention the old name (struct tags, reflection by
+  name, SQL), which a rename leaves alone but code may depend on;
+- references from other packages to an exported name, from generated files
+  (rewritten the next time they are generated), and interfaces the renamed
+  method takes part in;
+- selectors named like the symbol whose receiver cannot be typed, which may
+  or may not need the edit ("unresolved").
+"""
+
+import os
+import re
+from typing import Any, Callable, Dict, Iterable, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+from xray.core.go_helpers import file_imports, ident_at, token_is
+from xray.core.go_parser import KEYWORDS, Token, tokenize, unquote
+from xray.core.go_queries import LOOKS_LIKE_SQL
+from xray.core.source_text import source_lines
+
+# reflect lookups that take a field or method name as a string
+BY_NAME = {"FieldByName", "MethodByName"}
+_STRUCT_TAG = re.compile(r'^(\s*\w+:"[^"]*")+\s*$')
+_IDENTIFIER = re.compile(r"^[^\W\d]\w*$")
+
+# Serializers whose field names default to the Go field name
+_SERIALIZER_TAGS = ("json", "yaml", "xml", "toml", "bson", "msgpack", "mapstructure")
+
+
+def _word(name: str) -> "re.Pattern[str]":
+    return re.compile(r"(?<!\w)" + re.escape(name) + r"(?!\w)")
+
+
+def _open_brace(tokens: List[Token], index: int) -> Optional[int]:
+    """Index of the unmatched "{" enclosing tokens[index]."""
+    depth = 0
+    for k in range(index - 1, -1, -1):
+        tok = tokens[k]
+        if tok.kind != "op":
+            continue
+        if tok.value in (")", "]", "}"):
+            depth += 1
+        elif tok.value in ("(", "["):
+            depth -= 1
+        elif tok.value == "{":
+            if depth == 0:
+                return k
+            depth -= 1
+    return None
+
+
+# (qualifier, name) of a named type as written; qualifier None in its own package
+_Named = Tuple[Optional[str], str]
+
+
+def literal_type(tokens: List[Token], brace: int, depth: int = 0) -> Optional[Tuple[str, Optional[_Named]]]:
+    """
+    The type of the composite literal opened at tokens[brace]: ("named",
+    (qualifier, name)), ("map", element) or ("slice", element), where an
+    element is the (qualifier, name) of a named element type, if any.
+    Elided element literals (`[]T{{...}}`) take the element type of the
+    literal around them. None when the braces are not a literal we can type.
+    """
+    if brace < 1 or depth > 8:
+        return None
+    prev = tokens[brace - 1]
+    if prev.kind == "op" and prev.value in ("{", ",", ":"):
+        outer = _open_brace(tokens, brace)
+        lit = literal_type(tokens, outer, depth + 1) if outer is not None else None
+        if lit is not None and lit[0] in ("map", "slice") and lit[1] is not None:
+            return ("named", lit[1])
+        return None
+    k = brace - 1
+    if token_is(tokens, k, "]"):
+        # Generic instantiation, T[int]{...}
+        opened = _matching_bracket(tokens, k)
+        if opened is None or ident_at(tokens, opened - 1) is None:
+            return None
+        k = opened - 1
+    name = ident_at(tokens, k)
+    if name is None:
+        return None
+    named: _Named = (None, name)
+    k -= 1
+    if token_is(tokens, k, ".") and ident_at(tokens, k - 1):
+        named = (tokens[k - 1].value, name)
+        k -= 2
+    if token_is(tokens, k, "*"):
+        k -= 1
+    if token_is(tokens, k, "]"):
+        opened = _matching_bracket(tokens, k)
+        if opened is None:
+            return None
+        return ("map" if token_is(tokens, opened - 1, "map") else "slice", named)
+    if k >= 0 and tokens[k].kind == "keyword" and tokens[k].value in ("func", "struct", "interface"):
+        return None
+    return ("named", named)
+
+
+def _matching_bracket(tokens: List[Token], close: int) -> Optional[int]:
+    depth = 0
+    for k in range(close, -1, -1):
+        if token_is(tokens, k, "]"):
+            depth += 1
+        elif token_is(tokens, k, "["):
+            depth -= 1
+            if depth == 0:
+                return k
+    return None
+
+
+class RenamePlanner:
+    """Plans renames of the Go declarations of a call graph's project."""
+
+    def __init__(self, graph: GoCallGraph, read: Callable[[str], Optional[str]],
+                 generated: Callable[[str], bool], extra_files: Iterable[Tuple[str, Dict[str, Any]]] = ()):
+        """
+        Args:
+            graph: Call graph of the project, for resolving selectors
+            read: Returns the content of a file path, None if unreadable
+            generated: Whether a file path is generated code
+            extra_files: (path, parsed) of Go files outside the index (skipped
+                generated files), searched for references too
+        """
+        self.graph = graph
+        self.project = graph.project
+        self._read = read
+        self._generated = generated
+        self.extra = dict(extra_files)
+
+    def find(self, symbol: str, path: Optional[str] = None) -> List[Dict[str, Any]]:
+        """
+        Declarations named "Name" (package level), "Type.Method" or
+        "Type.Field", optionally inside one file or package directory;
+        non-test files first.
+        """
+        owner, _, name = symbol.rpartition(".")
+        matches = []
+        for file_path, parsed in sorted(self.project.files.items()):
+            if path and file_path != path and os.path.dirname(file_path) != path.rstrip(os.sep):
+                continue
+            for sym in parsed["symbols"]:
+                if sym["name"] != name or sym.get("column") is None:
+                    continue
+                container = (sym.get("receiver") or {}).get("type") or sym.get("container") or ""
+                if container == owner:
+                    matches.append({**sym, "path": file_path, "package": parsed.get("package", "")})
+        matches.sort(key=lambda m: m["path"].endswith("_test.go"))
+        return matches
+
+    # ------------------------------------------------------------------
+    # Preview
+    # ------------------------------------------------------------------
+
+    def preview(self, target: Dict[str, Any], new_name: str) -> Dict[str, Any]:
+        """
+        The edit plan for renaming a declaration found by find().
+
+        Returns:
+            {"symbol", "new_name", "edits": [{path, line, column, kind,
+             old_text, new_text}], "collisions", "risks", "unresolved",
+             "counts"}
+        """
+        name = target["name"]
+        if new_name == name:
+            raise ValueError(f"'{new_name}' is already the name of {name}")
+        if not _IDENTIFIER.match(new_name) or new_name in KEYWORDS or new_name == "_":
+            raise ValueError(f"'{new_name}' is not a valid Go identifier")
+        pkg_dir = os.path.dirname(target["path"])
+        owner = (target.get("receiver") or {}).get("type") or target.get("container")
+        found = self.occurrences(target, new_name)
+        edits, unresolved, strings = found["edits"], found["unresolved"], found["strings"]
+
+        for edit in edits:
+            edit.update({"old_text": name, "new_text": new_name})
+            if self._generated(edit["path"]):
+                edit["generated"] = True
+
+        collisions = self._collisions(target, new_name, pkg_dir, owner, edits)
+        collisions += [{"kind": "local", "message": f"{c['function']} has a local named {new_name}, "
+                                                    f"which would capture this reference", **c}
+                       for c in found["captured"]]
+        risks = self._risks(target, new_name, pkg_dir, owner, edits, strings)
+        return {
+            "symbol": {
+                "name": name,
+                "qualified_name": f"{owner}.{name}" if owner else name,
+                "type": target["type"],
+                "package": target["package"],
+                "path": target["path"],
+                "line": target["start_line"],
+                "exported": name[:1].isupper(),
+            },
+            "new_name": new_name,
+            "edits": edits,
+            "collisions": collisions,
+            "risks": risks,
+            "unresolved": unresolved,
+            "counts": {
+                "edits": len(edits),
+                "files": len({e["path"] for e in edits}),
+                "collisions": len(collisions),
+                "risks": len(risks),
+                "unresolved": len(unresolved),
+            },
+        }
+
+    def occurrences(self, target: Dict[str, Any], new_name: Optional[str] = None) -> Dict[str, List[Dict[str, Any]]]:
+        """
+        Every occurrence of a declaration's name that resolves to it, by
+        path, line and column: "edits" (the declarations, doc comments and
+        references, each with its kind), "unresolved" selectors, "strings"
+        mentioning the name and, given new_name, the references a local of
+        that name would capture ("captured").
+        """
+        name = target["name"]
+        pkg_dir = os.path.dirname(target["path"])
+        owner = (target.get("receiver") or {}).get("type") or target.get("container")
+
+        declarations = [target] + self._variant_declarations(target)
+        positions = {(d["path"], d["start_line"], d["column"]) for d in declarations}
+        edits: List[Dict[str, Any]] = []
+        unresolved: List[Dict[str, Any]] = []
+        strings: List[Dict[str, Any]] = []
+        captured: List[Dict[str, Any]] = []
+        word = _word(name)
+
+        files = list(self.project.files.items()) + sorted(self.extra.items())
+        for file_path, parsed in files:
+            if not name[:1].isupper() and os.path.dirname(file_path) != pkg_dir:
+                # Unexported names cannot be used outside their package
+                continue
+            content = self._read(file_path)
+            if content is None or name not in content:
+                continue
+            tokens, comments = tokenize(content)
+            scan = FileScan(self, file_path, parsed, tokens)
+            for index, tok in enumerate(tokens):
+                if tok.kind == "string":
+                    if word.search(tok.value):
+                        strings.append(self._string_literal(file_path, tokens, index))
+                    continue
+                if tok.kind != "ident" or tok.value != name:
+                    continue
+                site = {"path": file_path, "line": tok.line, "column": tok.col}
+                if (file_path, tok.line, tok.col) in positions:
+                    edits.append({**site, "kind": "definition"})
+                    continue
+                if scan.declared(tok):
+                    continue
+                kind = scan.classify(index, target, pkg_dir, owner)
+                if kind == "unresolved":
+                    unresolved.append({**site, "text": scan.line_text(content, tok.line)})
+                elif kind is not None:
+                    edits.append({**site, "kind": kind})
+                    func = scan.enclosing(tok.line)
+                    if kind == "reference" and func is not None and new_name is not None and \
+                            new_name in func.get("locals", {}):
+                        captured.append({**site, "function": scan.function_name(func)})
+            for declaration in declarations:
+                if declaration["path"] == file_path:
+                    edits.extend(self._doc_comment(file_path, comments, declaration))
+
+        edits.sort(key=lambda e: (e["path"], e["line"], e["column"]))
+        return {"edits": edits, "unresolved": unresolved, "strings": strings, "captured": captured}
+
+    def _variant_declarations(self, target: Dict[str, Any]) -> List[Dict[str, Any]]:
+        """The same declaration in the other per-platform files of the package."""
+        declarations = []
+        for variant in self.project.variants(target["path"], target):
+            for sym in self.project.files[variant["path"]]["symbols"]:
+                if sym["name"] == target["name"] and sym["start_line"] == variant["line"] and sym.get("column"):
+                    declarations.append({**sym, "path": variant["path"], "package": target["package"]})
+        return declarations
+
+    @staticmethod
+    def _doc_comment(path: str, comments: List[Token], declaration: Dict[str, Any]) -> List[Dict[str, Any]]:
+        """The edit of a doc comment that starts with the declared name (`// Name does...`)."""
+        line = declaration["start_line"]
+        block = [c for c in comments if c.value.startswith("//") and c.end_line < line]
+        first = None
+        expected = line - 1
+        for comment in reversed(block):
+            if comment.line != expected:
+                break
+            first = comment
+            expected -= 1
+        if first is None:
+            return []
+        text = first.value[2:]
+        stripped = text.lstrip()
+        if not _word(declaration["name"]).match(stripped):
+            return []
+        return [{"path": path, "line": first.line, "column": first.col + 2 + len(text) - len(stripped),
+                 "kind": "doc_comment"}]
+
+    def _string_literal(self, path: str, tokens: List[Token], index: int) -> Dict[str, Any]:
+        tok = tokens[index]
+        value = unquote(tok.value)
+        if _STRUCT_TAG.match(value) and tok.value.startswith("`"):
+            context = "struct_tag"
+        elif token_is(tokens, index - 1, "(") and ident_at(tokens, index - 2) in BY_NAME:
+            context = "reflection"
+        elif LOOKS_LIKE_SQL.match(value):
+            context = "sql"
+        else:
+            context = "string"
+        text = tok.value if len(tok.value) <= 120 else tok.value[:117] + "..."
+        return {"kind": "string_literal", "context": context, "path": path, "line": tok.line,
+                "column": tok.col, "text": text}
+
+    # ------------------------------------------------------------------
+    # Collisions and risks
+    # ------------------------------------------------------------------
+
+    def _collisions(self, target: Dict[str, Any], new_name: str, pkg_dir: str, owner: Optional[str],
+                    edits: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        collisions = []
+        package_files = [p for p in self.project.packages.get(pkg_dir, {}).get("files", [])
+                         if self.project.files[p].get("package") == target["package"]]
+        if owner is None:
+            for file_path in package_files:
+                for sym in self.project.files[file_path]["symbols"]:
+                    if sym["name"] == new_name and self.project.declaration_name(sym) == new_name:
+                        collisions.append({"kind": "package", "message": f"{new_name} is already declared in "
+                                                                         f"package {target['package']}",
+                                           "path": file_path, "line": sym["start_line"], "type": sym["type"]})
+            using = {e["path"] for e in edits if e["kind"] in ("reference", "definition")}
+            for file_path in sorted(using):
+                for imp in self.project.files.get(file_path, self.extra.get(file_path, {})).get("imports", []):
+                    if imp.get("name") == new_name:
+                        collisions.append({"kind": "import", "message": f"the file imports {imp['path']} as "
+                                                                        f"{new_name}",
+                                           "path": file_path, "line": imp["line"]})
+            return collisions
+
+        key = (pkg_dir, owner)
+        if key in self.project.types and self.project.types[key]["type"] == "interface":
+            members, _ = self.project.interface_method_set(*key)
+        else:
+            member_set = self.project.member_set(pkg_dir, owner, pointer=True)
+            members = {**member_set["fields"], **member_set["methods"]}
+        existing = members.get(new_name)
+        if existing is not None:
+            collisions.append({"kind": "member", "message": f"{owner} already has a {existing['type']} "
+                                                            f"{new_name}"
+                                                            + (f" (promoted via {existing['promoted_via']})"
+                                                               if existing.get("promoted_via") else ""),
+                               "path": existing["path"], "line": existing["start_line"]})
+        return collisions
+
+    def _risks(self, target: Dict[str, Any], new_name: str, pkg_dir: str, owner: Optional[str],
+               edits: List[Dict[str, Any]], strings: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        risks: List[Dict[str, Any]] = []
+        name = target["name"]
+        outside = sorted({os.path.dirname(e["path"]) for e in edits
+                          if os.path.dirname(e["path"]) != pkg_dir or
+                          self.project.files.get(e["path"], {}).get("package") != target["package"]})
+        if name[:1].isupper() and outside:
+            risks.append({"kind": "other_packages",
+                          "message": f"{len(outside)} other package(s) use {name}; they are edited too",
+                          "packages": [self.project.import_path(d) or d for d in outside],
+                          "references": sum(1 for e in edits if os.path.dirname(e["path"]) in outside)})
+        if name[:1].isupper() and not new_name[:1].isupper():
+            risks.append({"kind": "visibility",
+                          "message": f"{new_name} is unexported: uses outside the package stop compiling"
+                                     + (", including the ones in this plan" if outside else "")})
+        elif new_name[:1].isupper() and not name[:1].isupper():
+            risks.append({"kind": "visibility", "message": f"{new_name} is exported: it becomes part of the "
+                                                           f"package's API"})
+        generated = sorted({e["path"] for e in edits if e.get("generated")})
+        if generated:
+            risks.append({"kind": "generated", "message": "generated files reference the symbol; regenerate "
+                                                          "them rather than editing by hand",
+                          "files": generated})
+        if target["type"] == "method":
+            risks.extend(self._interface_risks(target, pkg_dir, owner))
+        if target["type"] == "field":
+            risks.extend(self._serialization_risks(target, pkg_dir, owner))
+        risks.extend(strings)
+        return risks
+
+    def _interface_risks(self, target: Dict[str, Any], pkg_dir: str, owner: Optional[str]) -> List[Dict[str, Any]]:
+        name = target["name"]
+        type_symbol = self.project.types.get((pkg_dir, owner or ""))
+        if type_symbol is None:
+            return []
+        risks = []
+        if type_symbol["type"] == "interface":
+            for match in self.project.implementations_of(type_symbol)["implementations"]:
+                if name not in match["matched"] or name in match.get("promoted", {}):
+                    continue
+                method = self.project.member_set(os.path.dirname(match["path"]), match["name"],
+                                                 pointer=True)["methods"][name]
+                risks.append({"kind": "implementation",
+                              "message": f"{match['name']}.{name} implements this method and needs the "
+                                         f"same rename", "path": method["path"], "line": method["start_line"]})
+            return risks
+        for iface in self.project.interfaces_of(type_symbol)["interfaces"]:
+            if name in iface["matched"]:
+                risks.append({"kind": "interface",
+                              "message": f"{owner} satisfies {iface['package']}.{iface['name']} through {name}; "
+                                         f"renaming it breaks that", "path": iface["path"],
+                              "line": iface["start_line"]})
+        return risks
+
+    def _serialization_risks(self, target: Dict[str, Any], pkg_dir: str, owner: Optional[str]) -> List[Dict[str, Any]]:
+        """An untagged field of a struct whose other fields are tagged is probably serialized under its name."""
+        if not target["name"][:1].isupper() or target.get("embedded"):
+            return []
+        tagged = set()
+        for field in self.project.fields.get((pkg_dir, owner or ""), []):
+            tagged.update(k for k in (field.get("tags") or {}) if k in _SERIALIZER_TAGS)
+        missing = sorted(k for k in tagged if k not in (target.get("tags") or {}))
+        if not missing:
+            return []
+        return [{"kind": "serialization",
+                 "message": f"{target['name']} has no {'/'.join(missing)} tag: encoders use the field name, "
+                            f"so the rename changes the encoded key", "path": target["path"],
+                 "line": target["start_line"]}]
+
+
+class FileScan:
+    """Per-file lookups for classifying occurrences of a name."""
+
+    def __init__(self, planner: RenamePlanner, path: str, parsed: Dict[str, Any], tokens: List[Token]):
+        self.planner = planner
+        self.project = planner.project
+        self.path = path
+        self.parsed = parsed
+        self.tokens = tokens
+        self.indexed = path in self.project.files
+        self.imports = file_imports(parsed)
+        self.dot_imports = [imp["path"] for imp in parsed.get("imports", []) if imp["kind"] == "dot"]
+        self._declared = {(s["start_line"], s["column"]) for s in parsed["symbols"] if s.get("column")}
+        ends = {(s["name"], (s.get("receiver") or {}).get("type"), s["start_line"]): s["end_line"]
+                for s in parsed["symbols"] if s["type"] in ("function", "method") and not s.get("container")}
+        self._functions = [
+            (f["start_line"], ends.get((f["name"], f.get("receiver"), f["start_line"]), f["start_line"]), f)
+            for f in parsed.get("functions", [])
+        ]
+
+    def declared(self, tok: Token) -> bool:
+        """Whether the token names a declaration of its own (another field, method or parameter list entry)."""
+        return (tok.line, tok.col) in self._declared
+
+    def enclosing(self, line: int) -> Optional[Dict[str, Any]]:
+        for start, end, func in self._functions:
+            if start <= line <= end:
+                return func
+        return None
+
+    @staticmethod
+    def function_name(func: Dict[str, Any]) -> str:
+        return f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+
+    @staticmethod
+    def line_text(content: str, line: int) -> str:
+        lines = source_lines(content)
+        return lines[line - 1].strip() if 0 < line <= len(lines) else ""
+
+    def _chain_at(self, line: int, column: int, func: Dict[str, Any]) -> Optional[List[str]]:
+        """The selector chain recorded in a function body whose last element is at (line, column)."""
+        for record in func.get("value_refs", []) + func.get("calls", []):
+            if record["line"] == line and record["column"] == column:
+                return record["chain"]
+        return None
+
+    def package_dir(self, qualifier: Optional[str]) -> Optional[str]:
+        """The project package a qualifier (None: this file's own) refers to."""
+        if qualifier is None:
+            return os.path.dirname(self.path)
+        if qualifier not in self.imports:
+            return None
+        return self.project.import_dir(self.imports[qualifier], self.path)
+
+    def _same_package(self, pkg_dir: str, package: str) -> bool:
+        if os.path.dirname(self.path) == pkg_dir and self.parsed.get("package") == package:
+            return True
+        return any(self.project.import_dir(imp, self.path) == pkg_dir for imp in self.dot_imports)
+
+    def classify(self, index: int, target: Dict[str, Any], pkg_dir: str, owner: Optional[str]) -> Optional[str]:
+        """
+        What an identifier token with the target's name is: an edit kind,
+        "unresolved", or None when it names something else.
+        """
+        tokens = self.tokens
+        tok = tokens[index]
+        func = self.enclosing(tok.line)
+        locals_ = func.get("locals", {}) if func else {}
+        selector = token_is(tokens, index - 1, ".")
+
+        if owner is None:
+            if selector:
+                qualifier = ident_at(tokens, index - 2)
+                if qualifier is None or qualifier in locals_ or token_is(tokens, index - 3, "."):
+                    return None
+                return "qualified_reference" if qualifier in self.imports and \
+                    self.package_dir(qualifier) == pkg_dir else None
+            if not self._same_package(pkg_dir, target["package"]) or tok.value in locals_:
+                return None
+            if token_is(tokens, index + 1, ":") and (token_is(tokens, index - 1, "{") or token_is(tokens, index - 1, ",")):
+                brace = _open_brace(tokens, index)
+                lit = literal_type(tokens, brace) if brace is not None else None
+                if lit is None:
+                    return "unresolved"
+                if lit[0] == "named" and lit[1] is not None:
+                    # A key of a struct literal is a field name
+                    qualifier, type_name = lit[1]
+                    key_dir = self.package_dir(qualifier)
+                    if qualifier is not None or key_dir is None or (key_dir, type_name) in self.project.types:
+                        return None
+            return "reference"
+
+        if not selector:
+            if target["type"] == "field" and token_is(tokens, index + 1, ":") and \
+                    (token_is(tokens, index - 1, "{") or token_is(tokens, index - 1, ",")):
+                brace = _open_brace(tokens, index)
+                lit = literal_type(tokens, brace) if brace is not None else None
+                if lit is None:
+                    return "unresolved"
+                if lit[0] == "named" and lit[1] is not None and lit[1][1] == owner and \
+                        self.package_dir(lit[1][0]) == pkg_dir:
+                    return "composite_key"
+            return None
+
+        # Method expressions: T.Method, (*T).Method, pkg.T.Method
+        if target["type"] == "method":
+            base = index - 2
+            if token_is(tokens, base, ")") and token_is(tokens, base - 2, "*") and token_is(tokens, base - 3, "("):
+                base -= 1
+            type_name = ident_at(tokens, base)
+            if type_name is not None and type_name not in locals_ and type_name not in self.imports:
+                qualifier = ident_at(tokens, base - 2) if token_is(tokens, base - 1, ".") else None
+                type_dir = self.package_dir(qualifier)
+                if type_name == owner and type_dir == pkg_dir and \
+                        (qualifier is not None or self._same_package(pkg_dir, target["package"])):
+                    return "method_expression"
+                if (type_dir, type_name) in self.project.types:
+                    # A method expression of another type
+                    return None
+
+        if not self.indexed:
+            return "unresolved"
+        chain = self._chain_at(tok.line, tok.col, func) if func else None
+        if chain is None or chain[-1] != tok.value:
+            return "unresolved"
+        member = self.planner.graph.member_of(self.path, func, chain)
+        if member is None:
+            # A package-qualified name, or a value whose type is not known
+            head = chain[0]
+            if len(chain) == 2 and head in self.imports and head not in locals_:
+                return None
+            return "unresolved"
+        if member["path"] == target["path"] and member["start_line"] == target["start_line"]:
+            return "selector"
+        return None
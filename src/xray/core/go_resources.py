@@ -0,0 +1,597 @@
+"""Resources Go code acquires without releasing them, and defers that run too late.
+
+ResourceAudit looks through every function body for the standard
+library's resources that must be given back, and for what gives each back:
+
+    file         os.Open, os.OpenFile, os.Create, os.CreateTemp       f.Close()
+    rows         Query, QueryContext of sql.DB, Tx, Conn and Stmt     rows.Close()
+    statement    Prepare, PrepareContext of sql.DB, Tx and Conn       stmt.Close()
+    transaction  Begin, BeginTx of sql.DB and Conn                    tx.Rollback() or tx.Commit()
+    connection   net.Dial, DialTimeout, net.Dialer's, sql.DB.Conn     conn.Close()
+    listener     net.Listen, net.ListenPacket                         ln.Close()
+    response     http.Get, Post, Head, PostForm, http.Client's        resp.Body.Close()
+    cancel       context.WithCancel, WithTimeout, WithDeadline (and   cancel()
+                 their Cause forms), signal.NotifyContext
+    ticker       time.NewTicker                                       t.Stop()
+    lock         x.Lock(), x.RLock()                                  x.Unlock(), x.RUnlock()
+
+Acquisitions are recognized through the call graph, so a project function
+named Open is not one. A resource is left alone once it escapes the
+function - returned, a named result, stored in a field or another
+variable, sent on a channel, put in a composite literal or appended, or
+used by a goroutine or a func literal: whoever has it then releases it.
+Otherwise:
+
+    discarded               the result holding it is not kept (`ctx, _ :=
+                            context.WithCancel(ctx)`, `db.Query(q)` as a
+                            statement), so nothing can release it
+    never_released          no release follows it, deferred or not
+    released_on_some_paths  it is released, not in a defer, and a return
+                            (or the end of the function) comes without a
+                            release before it in an enclosing block; the
+                            returns of the `if err != nil` right after the
+                            acquisition are its own failure and do not count
+    defer_in_loop           a defer inside a for loop of the same function:
+                            it runs when the function returns, not after the
+                            iteration, so every iteration's resource is held
+                            until then - and a deferred Unlock after a Lock
+                            deadlocks on the second iteration
+
+A release in a deferred call (`defer f.Close()`, `defer func() { ... }()`,
+`defer closeQuietly(f)`) or in a func literal covers every path. Every
+defer statement is also recorded with what it defers: close, unlock,
+cancel, rollback, stop, done, recover, or other.
+
+This is a heuristic over the tokens of one function at a time: nothing
+follows a resource into a callee, so a function returning with a mutex
+locked on purpose, or closing through a helper it does not defer, is
+reported. Each finding names the acquisition and the release it looked for
+and did not find. A `//xray:ignore` comment on the line of a finding, or
+on the line above it, suppresses it.
+"""
+
+import bisect
+import os
+from typing import Any, Callable, Dict, List, Optional, Set, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+from xray.core.go_parser import Token, tokenize
+from xray.core.source_text import source_lines
+
+FINDING_KINDS = ("discarded", "never_released", "released_on_some_paths", "defer_in_loop")
+DEFER_KINDS = ("close", "unlock", "cancel", "rollback", "stop", "done", "recover", "other")
+SEVERITIES = ("high", "medium", "low")
+SUPPRESS_MARKER = "xray:ignore"
+
+_CLOSE = (("Close",),)
+_SQL = "database/sql"
+# (resource, index of the result holding it, the selector chains releasing it, callees)
+_ACQUIRERS = (
+    ("file", 0, _CLOSE, ("os.Open", "os.OpenFile", "os.Create", "os.CreateTemp", "io/ioutil.TempFile")),
+    ("rows", 0, _CLOSE, tuple(f"{_SQL}.{t}.{m}" for t in ("DB", "Tx", "Conn", "Stmt") for m in ("Query", "QueryContext"))),
+    ("statement", 0, _CLOSE, tuple(f"{_SQL}.{t}.{m}" for t in ("DB", "Tx", "Conn") for m in ("Prepare", "PrepareContext"))),
+    ("transaction", 0, (("Rollback",), ("Commit",)), (f"{_SQL}.DB.Begin", f"{_SQL}.DB.BeginTx", f"{_SQL}.Conn.BeginTx")),
+    ("connection", 0, _CLOSE, ("net.Dial", "net.DialTimeout", "net.Dialer.Dial", "net.Dialer.DialContext",
+                               f"{_SQL}.DB.Conn")),
+    ("listener", 0, _CLOSE, ("net.Listen", "net.ListenPacket")),
+    ("response", 0, (("Body", "Close"),), ("net/http.Get", "net/http.Post", "net/http.Head", "net/http.PostForm")
+     + tuple(f"net/http.Client.{m}" for m in ("Do", "Get", "Post", "Head", "PostForm"))),
+    ("cancel", 1, ((),), ("context.WithCancel", "context.WithTimeout", "context.WithDeadline",
+                          "context.WithCancelCause", "context.WithTimeoutCause", "context.WithDeadlineCause",
+                          "os/signal.NotifyContext")),
+    ("ticker", 0, (("Stop",),), ("time.NewTicker",)),
+)
+# Callee -> (resource, result index, releases)
+ACQUISITIONS: Dict[str, Tuple[str, int, Tuple[Tuple[str, ...], ...]]] = {
+    callee: (resource, index, releases) for resource, index, releases, callees in _ACQUIRERS for callee in callees
+}
+_LOCKS = {"Lock": "Unlock", "RLock": "RUnlock"}
+_DEFERRED_METHODS = {"Close": "close", "Unlock": "unlock", "RUnlock": "unlock", "Rollback": "rollback",
+                     "Stop": "stop", "Done": "done"}
+_SEVERITY_RANK = {severity: rank for rank, severity in enumerate(SEVERITIES)}
+
+
+class _Body:
+    """The tokens of one function declaration: brackets, blocks, func literals, loops and statements."""
+
+    def __init__(self, tokens: List[Token]):
+        self.tokens = tokens
+        self.match: Dict[int, int] = {}
+        # The innermost open bracket around each token
+        self.parent: List[Optional[int]] = []
+        stack: List[int] = []
+        for i, tok in enumerate(tokens):
+            self.parent.append(stack[-1] if stack else None)
+            if tok.kind != "op":
+                continue
+            if tok.value in ("(", "[", "{"):
+                stack.append(i)
+            elif tok.value in (")", "]", "}") and stack:
+                self.match[stack.pop()] = i
+        self.close = max((i for i, t in enumerate(tokens) if t.kind == "op" and t.value == "}"), default=-1)
+        self.open = next((o for o, c in self.match.items() if c == self.close), -1)
+        self.braces = sorted((o, c) for o, c in self.match.items() if tokens[o].value == "{" and o >= self.open)
+        # func literal -> its body's braces; for loop -> its block's
+        self.literals: Dict[int, Tuple[int, int]] = {}
+        self.loops: Dict[int, Tuple[int, int]] = {}
+        for i in range(self.open + 1, self.close):
+            tok = tokens[i]
+            if tok.kind == "keyword" and tok.value == "func":
+                block = self._literal_block(i)
+                if block is not None:
+                    self.literals[i] = block
+            elif tok.kind == "keyword" and tok.value == "for":
+                block = self._loop_block(i)
+                if block is not None:
+                    self.loops[i] = block
+
+    def _literal_block(self, i: int) -> Optional[Tuple[int, int]]:
+        j = i + 1
+        while j < self.close:
+            tok = self.tokens[j]
+            if tok.kind == "op" and tok.value in ("(", "["):
+                j = self.match.get(j, j) + 1
+                continue
+            if tok.kind == "op" and tok.value == "{":
+                if self.tokens[j - 1].kind == "keyword" and self.tokens[j - 1].value in ("interface", "struct"):
+                    j = self.match.get(j, j) + 1
+                    continue
+                return (j, self.match[j]) if j in self.match else None
+            j += 1
+        return None
+
+    def _loop_block(self, i: int) -> Optional[Tuple[int, int]]:
+        """The block of a for statement: the first brace of its header ending a line, not a composite literal's."""
+        first = None
+        j = i + 1
+        while j < self.close:
+            tok = self.tokens[j]
+            if tok.kind == "op" and tok.value in ("(", "["):
+                j = self.match.get(j, j) + 1
+                continue
+            if tok.kind == "op" and tok.value == "{" and j in self.match:
+                first = first if first is not None else j
+                following = self.tokens[j + 1]
+                if j == i + 1 or following.line > tok.line:
+                    return j, self.match[j]
+                j = self.match[j] + 1
+                continue
+            if tok.kind == "op" and tok.value == ";" and self.parent[j] == self.parent[i] and \
+                    self.tokens[j - 1].line < tok.line:
+                break
+            j += 1
+        return (first, self.match[first]) if first is not None else None
+
+    def statement_end(self, i: int) -> int:
+        """The index of the ';' ending the statement token i starts (or the last token before its block closes)."""
+        depth = 0
+        for j in range(i, self.close):
+            tok = self.tokens[j]
+            if tok.kind != "op":
+                continue
+            if tok.value in ("(", "[", "{"):
+                depth += 1
+            elif tok.value in (")", "]", "}"):
+                depth -= 1
+                if depth < 0:
+                    return j - 1
+            elif tok.value == ";" and depth == 0:
+                return j
+        return self.close - 1
+
+    def scope(self, i: int) -> Tuple[int, int]:
+        """The body token i belongs to: the innermost func literal's, else the function's."""
+        best = (self.open, self.close)
+        for open_, close in self.literals.values():
+            if open_ < i < close and open_ > best[0]:
+                best = (open_, close)
+        return best
+
+    def block(self, i: int) -> Tuple[int, int]:
+        """The innermost braces around token i."""
+        best = (self.open, self.close)
+        for open_, close in self.braces:
+            if open_ > i:
+                break
+            if i < close and open_ > best[0]:
+                best = (open_, close)
+        return best
+
+    def chain_at(self, i: int, chain: List[str]) -> Optional[int]:
+        """The index after a selector chain starting at token i (not itself a selector), None if it is not there."""
+        tokens = self.tokens
+        if tokens[i].kind != "ident" or tokens[i].value != chain[0]:
+            return None
+        if i > 0 and tokens[i - 1].kind == "op" and tokens[i - 1].value == ".":
+            return None
+        j = i + 1
+        for name in chain[1:]:
+            if j + 1 >= len(tokens) or tokens[j].value != "." or tokens[j + 1].kind != "ident" \
+                    or tokens[j + 1].value != name:
+                return None
+            j += 2
+        return j
+
+    def is_call(self, j: int) -> bool:
+        return j < len(self.tokens) and self.tokens[j].kind == "op" and self.tokens[j].value == "("
+
+    def keyword_spans(self, keyword: str) -> List[Tuple[int, int]]:
+        """(first, last) token of every statement starting with a keyword (defer, go, return)."""
+        return [(i, self.statement_end(i)) for i in range(self.open + 1, self.close)
+                if self.tokens[i].kind == "keyword" and self.tokens[i].value == keyword]
+
+
+class ResourceAudit:
+    """Acquisitions without a release on every path, discarded resources and defers in loops."""
+
+    def __init__(self, graph: GoCallGraph, read: Callable[[str], str]):
+        self.graph = graph
+        self.project = graph.project
+        self.read = read
+        self._sites: Dict[Tuple[str, int, int], Dict[str, Any]] = {}
+        for edge in graph.edges:
+            self._sites.setdefault((edge["path"], edge["line"], edge["column"]), edge)
+
+    def _acquisitions(self, path: str, func: Dict[str, Any]) -> List[Dict[str, Any]]:
+        """The calls of a function acquiring a resource, with what holds it and what releases it."""
+        found = []
+        for call in func.get("calls", []):
+            if call["kind"] != "call":
+                continue
+            chain = call["chain"]
+            edge = self._sites.get((path, call["line"], call["column"]))
+            callee = edge["callee"][1] if edge is not None and edge["external"] else None
+            text = f"{'.'.join(chain)}({', '.join(call.get('arg_texts', []))})"
+            if callee in ACQUISITIONS:
+                resource, index, releases = ACQUISITIONS[callee]
+                # assigned_to leaves out the blank identifiers, listed by position in blank
+                names = iter(call.get("assigned_to", []))
+                blank = set(call.get("blank", []))
+                assigned = [("_" if i in blank else next(names, "_"))
+                            for i in range(len(call.get("assigned_to", [])) + len(blank))]
+                holder = assigned[index] if index < len(assigned) else None
+                found.append({"resource": resource, "call": call, "callee": callee, "acquired": text,
+                              "holder": holder, "statement": bool(call.get("statement")) and not assigned,
+                              "error": assigned[-1] if len(assigned) > index + 1 else None,
+                              "releases": releases})
+            elif len(chain) >= 2 and chain[-1] in _LOCKS and not call.get("args") and call.get("statement"):
+                if edge is not None and edge["external"] and not edge["callee"][1].startswith("sync."):
+                    continue
+                found.append({"resource": "lock", "call": call, "callee": callee or ".".join(chain),
+                              "acquired": text, "holder": ".".join(chain[:-1]), "statement": False,
+                              "error": None, "releases": ((_LOCKS[chain[-1]],),)})
+        return found
+
+    def audit(self, include_tests: bool = False, path: Optional[str] = None,
+              list_defers: bool = False) -> Dict[str, Any]:
+        """
+        Every finding in the project's Go functions, most severe first, and
+        the defer statements counted by what they defer.
+
+        Args:
+            include_tests: Also audit _test.go files
+            path: Only functions in this file or directory (and below)
+            list_defers: Also list every defer statement
+        """
+        findings: List[Dict[str, Any]] = []
+        defers: List[Dict[str, Any]] = []
+        suppressed = 0
+        for file_path, parsed in sorted(self.project.files.items()):
+            if not include_tests and file_path.endswith("_test.go"):
+                continue
+            if path and file_path != path and not file_path.startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            work = [(func, self._acquisitions(file_path, func)) for func in parsed.get("functions", [])]
+            work = [(func, acquired) for func, acquired in work
+                    if acquired or any(call["kind"] == "defer" for call in func.get("calls", []))
+                    or func.get("deferred")]
+            if not work:
+                continue
+            try:
+                content = self.read(file_path)
+            except Exception:
+                continue
+            tokens, comments = tokenize(content)
+            lines = source_lines(content)
+            ignored = {c.line for c in comments if SUPPRESS_MARKER in c.value}
+            token_lines = [t.line for t in tokens]
+            symbols = {(s["start_line"], s["name"]): s for s in parsed.get("symbols", [])
+                       if s["type"] in ("function", "method")}
+            for func, acquired in work:
+                symbol = symbols.get((func["start_line"], func["name"]))
+                if symbol is None:
+                    continue
+                first = bisect.bisect_left(token_lines, func["start_line"])
+                last = bisect.bisect_right(token_lines, symbol["end_line"])
+                body = _Body(tokens[first:last])
+                if body.open < 0:
+                    continue
+                name = f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+                results = {r.get("name") for r in symbol.get("results", []) if r.get("name")}
+                found = self._function_findings(body, acquired, results, name, file_path, lines, defers)
+                for finding in found:
+                    if finding["line"] in ignored or finding["line"] - 1 in ignored:
+                        suppressed += 1
+                    else:
+                        findings.append(finding)
+
+        findings.sort(key=lambda f: (_SEVERITY_RANK[f["severity"]], f["path"], f["line"], f["column"]))
+        counts: Dict[str, int] = {severity: 0 for severity in SEVERITIES}
+        counts.update({kind: 0 for kind in FINDING_KINDS})
+        for finding in findings:
+            counts[finding["severity"]] += 1
+            counts[finding["kind"]] += 1
+        by_kind = {kind: 0 for kind in DEFER_KINDS}
+        for entry in defers:
+            by_kind[entry["defers"]] += 1
+        result: Dict[str, Any] = {
+            "findings": findings,
+            "total_count": len(findings),
+            "counts": counts,
+            "defers": {"total": len(defers), "by_kind": by_kind,
+                       "in_loops": sum(1 for entry in defers if entry.get("in_loop"))},
+            "suppressed_count": suppressed,
+            "include_tests": include_tests,
+        }
+        if list_defers:
+            result["defer_statements"] = defers
+        return result
+
+    def _function_findings(self, body: _Body, acquired: List[Dict[str, Any]], results: Set[str], name: str,
+                           path: str, lines: List[str], defers: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        tokens = body.tokens
+        defer_spans = body.keyword_spans("defer")
+        go_spans = body.keyword_spans("go")
+        return_spans = body.keyword_spans("return")
+        cancels = {a["holder"] for a in acquired if a["resource"] == "cancel" and a["holder"]}
+        findings = []
+
+        def base(kind: str, severity: str, resource: str, line: int, column: int) -> Dict[str, Any]:
+            return {"kind": kind, "severity": severity, "resource": resource, "function": name,
+                    "path": path, "line": line, "column": column}
+
+        for d, end in defer_spans:
+            entry = {"function": name, "path": path, "line": tokens[d].line, "column": tokens[d].col,
+                     "defers": self._defer_kind(body, d, end, cancels),
+                     "call": (lines[tokens[d].line - 1].strip()[len("defer "):]
+                              if tokens[d].line <= len(lines) else "")}
+            scope = body.scope(d)
+            loop = next((f for f, (open_, close) in sorted(body.loops.items(), reverse=True)
+                         if open_ < d < close and body.scope(f) == scope), None)
+            if loop is not None:
+                entry["in_loop"] = True
+                finding = base("defer_in_loop", "medium", entry["defers"], tokens[d].line, tokens[d].col)
+                finding["defer"] = entry["call"]
+                finding["loop_line"] = tokens[loop].line
+                finding["reason"] = (f"deferred inside the loop at line {tokens[loop].line}: it runs when "
+                                     f"{'the func literal' if scope[0] != body.open else name} returns, not after "
+                                     f"each iteration, so every iteration's resource is held until then")
+                if entry["defers"] == "unlock" and self._locked_in(body, d, end, loop):
+                    finding["severity"] = "high"
+                    finding["reason"] += "; the lock taken in the loop is still held on the next iteration: deadlock"
+                findings.append(finding)
+            defers.append(entry)
+
+        for acquisition in acquired:
+            call = acquisition["call"]
+            resource = acquisition["resource"]
+            holder = acquisition["holder"]
+            index = next((i for i, t in enumerate(tokens) if t.line == call["line"] and t.col == call["column"]), None)
+            if index is None:
+                continue
+            release = self._release_text(holder or "_", acquisition["releases"])
+            finding = base("", "high", resource, call["line"], call["column"])
+            finding.update(acquired=acquisition["acquired"], call=acquisition["callee"])
+            if holder:
+                finding["variable"] = holder
+            if acquisition["statement"] or holder == "_":
+                finding.update(kind="discarded", release=self._release_text(resource, acquisition["releases"]),
+                               reason=(f"the {'cancel function' if resource == 'cancel' else resource} "
+                                       f"{acquisition['acquired']} returns is not kept, so nothing can release it"))
+                findings.append(finding)
+                continue
+            if holder is None or "." in holder and resource != "lock" or holder in results:
+                # Stored in a field, or handed back as a named result
+                continue
+            verdict = self._releases(body, index, holder.split("."), acquisition, defer_spans, go_spans,
+                                     return_spans)
+            if verdict is None:
+                continue
+            explicit, exits = verdict
+            finding["release"] = release
+            if not explicit:
+                if resource == "lock" and "lock" in name.lower():
+                    # A lock() helper leaves the lock taken for its caller
+                    continue
+                finding.update(kind="never_released", severity="medium" if resource == "lock" else "high",
+                               reason=f"no {release} follows, deferred or not, and {holder} does not leave "
+                                      f"the function")
+                findings.append(finding)
+            elif exits:
+                shown = ", ".join(f"line {tokens[e].line}" if tokens[e].value == "return" else "the end"
+                                  for e in exits)
+                finding.update(kind="released_on_some_paths", severity="medium",
+                               released_at=sorted({tokens[q].line for q in explicit}),
+                               unreleased_exits=[{"line": tokens[e].line,
+                                                  "exit": "return" if tokens[e].value == "return" else "end"}
+                                                 for e in exits],
+                               reason=f"{release} is called but not deferred, and the return at {shown} comes "
+                                      f"without it" if len(exits) == 1 else
+                                      f"{release} is called but not deferred, and the returns at {shown} come "
+                                      f"without it")
+                findings.append(finding)
+        return findings
+
+    @staticmethod
+    def _release_text(holder: str, releases: Tuple[Tuple[str, ...], ...]) -> str:
+        return " or ".join(f"{holder}{''.join('.' + name for name in chain)}()" for chain in releases)
+
+    @staticmethod
+    def _locked_in(body: _Body, d: int, end: int, loop: int) -> bool:
+        """Whether the loop around a deferred Unlock takes the same lock before it."""
+        tokens = body.tokens
+        text = [t.value for t in tokens[d + 1:end]]
+        if len(text) < 4 or text[-3] not in ("Unlock", "RUnlock"):
+            return False
+        chain = [v for v in text[:-4] if v != "."]
+        method = "Lock" if text[-3] == "Unlock" else "RLock"
+        open_ = body.loops[loop][0]
+        for i in range(open_ + 1, d):
+            after = body.chain_at(i, chain + [method]) if chain else None
+            if after is not None and body.is_call(after):
+                return True
+        return False
+
+    def _defer_kind(self, body: _Body, d: int, end: int, cancels: Set[str]) -> str:
+        tokens = body.tokens
+        if tokens[d + 1].kind == "keyword" and tokens[d + 1].value == "func":
+            kinds = []
+            for i in range(d + 2, end):
+                tok = tokens[i]
+                if tok.kind == "ident" and body.is_call(i + 1):
+                    if tok.value == "recover":
+                        return "recover"
+                    if tok.value in _DEFERRED_METHODS and tokens[i - 1].value == ".":
+                        kinds.append(_DEFERRED_METHODS[tok.value])
+                    elif tok.value in cancels:
+                        kinds.append("cancel")
+            return kinds[0] if kinds else "other"
+        # The called chain: the identifiers up to the call's parenthesis
+        names = []
+        i = d + 1
+        while i < end and tokens[i].kind == "ident":
+            names.append(tokens[i].value)
+            if tokens[i + 1].value != ".":
+                break
+            i += 2
+        if not names:
+            return "other"
+        if len(names) > 1 and names[-1] in _DEFERRED_METHODS:
+            return _DEFERRED_METHODS[names[-1]]
+        if len(names) == 1 and (names[0] in cancels or "cancel" in names[0].lower()):
+            return "cancel"
+        return "other"
+
+    def _releases(self, body: _Body, index: int, holder: List[str], acquisition: Dict[str, Any],
+                  defer_spans: List[Tuple[int, int]], go_spans: List[Tuple[int, int]],
+                  return_spans: List[Tuple[int, int]]) -> Optional[Tuple[List[int], List[int]]]:
+        """
+        (explicit releases, exits reached without one) after an
+        acquisition; None when it is released in a defer or a func literal,
+        or escapes.
+        """
+        tokens = body.tokens
+        scope = body.scope(index)
+        start = body.statement_end(index)
+        lock = acquisition["resource"] == "lock"
+        explicit: List[int] = []
+        if lock and any(body.chain_at(i, holder) is not None
+                        and any(self._released(body, body.chain_at(i, holder), chain)
+                                for chain in acquisition["releases"])
+                        for i in range(body.open + 1, index)):
+            # Unlocked before it is locked: the function is called with the lock held
+            return None
+
+        def inside(i: int, spans: List[Tuple[int, int]]) -> bool:
+            return any(first <= i <= last and body.scope(first) == scope for first, last in spans)
+
+        for i in range(start + 1, scope[1]):
+            after = body.chain_at(i, holder)
+            if after is None:
+                continue
+            released = any(self._released(body, after, chain) for chain in acquisition["releases"])
+            if released:
+                if inside(i, defer_spans) or body.scope(i) != scope:
+                    return None
+                explicit.append(i)
+                continue
+            if lock or after < len(tokens) and tokens[after].value in (".", "(", "["):
+                continue
+            # The holder itself, not a selector on it: where does it go?
+            if inside(i, defer_spans):
+                return None
+            if inside(i, go_spans) or body.scope(i) != scope or inside(i, return_spans):
+                return None
+            prev = tokens[i - 1]
+            if prev.kind == "op" and prev.value in ("=", ":="):
+                if not (tokens[i - 2].value == "_" and tokens[i - 3].value in (";", "{")):
+                    return None
+            elif prev.kind == "op" and prev.value == "<-":
+                return None
+            parent = body.parent[i]
+            if parent is not None and tokens[parent].value == "{" and prev.value in ("{", ",", ":") \
+                    and (parent, body.match.get(parent)) not in self._blocks(body):
+                return None
+            if parent is not None and tokens[parent].value == "(" and parent > 0 and tokens[parent - 1].value == "append":
+                return None
+        if not explicit:
+            return [], []
+
+        exempt = self._error_check(body, start, scope, acquisition["error"])
+        exits = [r for r, _ in return_spans if r > start and body.scope(r) == scope
+                 and not (exempt and exempt[0] < r < exempt[1])]
+        if not self._ends_in_return(body, scope):
+            exits.append(scope[1])
+        uncovered = []
+        if exempt and any(exempt[0] < q < exempt[1] for q in explicit):
+            # Released when the acquisition succeeded: `if err == nil { f.Close() }`
+            return explicit, []
+        returns = dict(return_spans)
+        for e in exits:
+            # Released before it in a block enclosing it, or in one the acquisition cannot leave
+            # without passing the release, or by the return itself (`return tx.Commit()`)
+            if not any(q < e and (body.block(q)[0] <= e <= body.block(q)[1]
+                                  or body.block(q)[0] < index < body.block(q)[1])
+                       or e < q <= returns.get(e, -1) for q in explicit):
+                uncovered.append(e)
+        return explicit, uncovered
+
+    @staticmethod
+    def _released(body: _Body, after: int, chain: Tuple[str, ...]) -> bool:
+        """Whether the tokens after the holder are a call of a release chain (`.Close(`, `.Body.Close(`, `(`)."""
+        tokens = body.tokens
+        j = after
+        for name in chain:
+            if j + 1 >= len(tokens) or tokens[j].value != "." or tokens[j + 1].value != name:
+                return False
+            j += 2
+        return body.is_call(j)
+
+    @staticmethod
+    def _blocks(body: _Body) -> Set[Tuple[int, int]]:
+        """The braces that are blocks: bodies, func literals, loops, and those after if, switch, select, else, case."""
+        blocks = {(body.open, body.close)} | set(body.literals.values()) | set(body.loops.values())
+        tokens = body.tokens
+        for open_, close in body.braces:
+            prev = tokens[open_ - 1]
+            if open_ + 1 < len(tokens) and tokens[open_ + 1].line > tokens[open_].line or \
+                    prev.kind == "keyword" or prev.value in (";", "{", "}", ":"):
+                blocks.add((open_, close))
+        return blocks
+
+    @staticmethod
+    def _error_check(body: _Body, start: int, scope: Tuple[int, int],
+                     error: Optional[str]) -> Optional[Tuple[int, int]]:
+        """The block of the `if` right after an acquisition when its condition tests the acquisition's error."""
+        if not error or error == "_":
+            return None
+        tokens = body.tokens
+        k = start + 1
+        if k >= scope[1] or tokens[k].kind != "keyword" or tokens[k].value != "if":
+            return None
+        block = next(((o, c) for o, c in body.braces if o > k), None)
+        if block is None or not any(t.kind == "ident" and t.value == error for t in tokens[k + 1:block[0]]):
+            return None
+        return block
+
+    @staticmethod
+    def _ends_in_return(body: _Body, scope: Tuple[int, int]) -> bool:
+        """Whether the last statement of a body is a return or a panic."""
+        tokens = body.tokens
+        statements = [i for i in range(scope[0] + 1, scope[1]) if body.parent[i] == scope[0]
+                      and (i == scope[0] + 1 or tokens[i - 1].value == ";")]
+        statements = [i for i in statements if tokens[i].value != ";"]
+        if not statements:
+            return False
+        last = tokens[statements[-1]]
+        return last.value == "return" or last.value == "panic" and body.is_call(statements[-1] + 1)
@@ -0,0 +1,396 @@
+"""HTTP route extraction for Go services.
+
+Route registrations are recognised from the call sites recorded by
+go_parser: net/http's Handle/HandleFunc (including Go 1.22 "METHOD /path"
+patterns), gorilla/mux's HandleFunc(...).Methods(...), chi's Get/Post/...
+and Method/MethodFunc, and gin/echo style GET/POST/... helpers. Handlers are
+resolved to project symbols through the call graph's evaluator.
+
+Every route also gets its middleware stack, outermost first, as far as it
+is visible in the registering function:
+
+- wrappers of the router passed to http.ListenAndServe (or Serve);
+- Use(...) on the router and on its parents, for sub-routers made with
+  Group, With, Route or PathPrefix(...).Subrouter() (chi and gin only count
+  the Use calls before the registration, as they only apply to later routes);
+- middleware given to Group(...) and With(...) along the way;
+- gin's handlers before the last one, echo's middleware after the handler;
+- wrappers around the handler at registration: `logRequests(h)`,
+  `chain(a, b)(h)` and alice's `New(a, b).Then(h)`.
+
+Conversions such as http.HandlerFunc(h) are not middleware. A wrapper that
+cannot be resolved to a symbol is kept in the stack, marked unresolved.
+"""
+
+import os
+import re
+from typing import Dict, List, Optional, Any, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+
+# Import paths that mark a file as registering routes, keyed to a framework label
+ROUTER_IMPORTS = {
+    "net/http": "net/http",
+    "github.com/gorilla/mux": "gorilla/mux",
+    "github.com/go-chi/chi": "chi",
+    "github.com/go-chi/chi/v5": "chi",
+    "github.com/gin-gonic/gin": "gin",
+    "github.com/labstack/echo": "echo",
+    "github.com/labstack/echo/v4": "echo",
+}
+
+HTTP_METHODS = ("GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "CONNECT", "OPTIONS", "TRACE")
+
+# chi uses Get/Post/..., gin and echo use GET/POST/...
+_METHOD_HELPERS = {m.capitalize(): m for m in HTTP_METHODS}
+_METHOD_HELPERS.update({m: m for m in HTTP_METHODS})
+_METHOD_HELPERS["Any"] = None
+
+_HANDLE = ("Handle", "HandleFunc", "HandlerFunc")
+
+# Adapters that turn a function into a handler without wrapping it
+_CONVERSIONS = ("HandlerFunc", "WrapF", "WrapH")
+# Router methods whose result is a sub-router inheriting the receiver's middleware
+_GROUPS = ("Group", "With", "Route", "Subrouter", "PathPrefix")
+_SERVE = ("ListenAndServe", "ListenAndServeTLS", "Serve", "ServeTLS")
+# Parameter types a wrapper takes the wrapped handler as
+_HANDLER_TYPE = re.compile(r"Handler|ResponseWriter|Context\)|\bMux\b|\bRouter\b")
+
+
+def _frameworks(parsed: Dict[str, Any]) -> List[str]:
+    found = []
+    for imp in parsed.get("imports", []):
+        label = ROUTER_IMPORTS.get(imp["path"])
+        if label and label not in found:
+            found.append(label)
+    return found
+
+
+class RouteExtractor:
+    """Collects route registrations across every parsed Go file of a project."""
+
+    def __init__(self, graph: GoCallGraph):
+        self.graph = graph
+
+    def _route_value(self, path: str, call: Dict[str, Any], index: int) -> Dict[str, Any]:
+        """Return {"route": ...} for a constant pattern, or the expression flagged dynamic."""
+        source = call["args"][index] or {}
+        if "value" in source:
+            return {"route": source["value"]}
+        if "chain" in source:
+            value = self.graph.constant_value(path, source["chain"])
+            if isinstance(value, str):
+                return {"route": value}
+        return {"route": call["arg_texts"][index], "dynamic": True}
+
+    def _call_at(self, func: Dict[str, Any], line: int, column: int,
+                 chain: List[str]) -> Optional[Dict[str, Any]]:
+        """The first call of `chain` at or after a position: the callee of a call-valued argument."""
+        found = None
+        for other in func.get("calls", []):
+            if other["chain"] == chain and (other["line"], other["column"]) >= (line, column):
+                if found is None or (other["line"], other["column"]) < (found["line"], found["column"]):
+                    found = other
+        return found
+
+    def _call_before(self, func: Dict[str, Any], line: int, chain: List[str]) -> Optional[Dict[str, Any]]:
+        """The last call of `chain` up to a line: where a local router was made."""
+        found = None
+        for other in func.get("calls", []):
+            if other["chain"] == chain and other["line"] <= line:
+                if found is None or (other["line"], other["column"]) > (found["line"], found["column"]):
+                    found = other
+        return found
+
+    def _symbol(self, path: str, func: Dict[str, Any], source: Dict[str, Any], text: str) -> Dict[str, Any]:
+        """A middleware or handler expression resolved to its function, or left as text."""
+        if source.get("func_literal"):
+            return {"expression": "func literal", "resolved": False}
+        chain = source.get("chain")
+        node: Dict[str, Any] = {}
+        if chain and chain[-1] == "()" and len(chain) > 1:
+            # A middleware factory call such as middleware.Timeout(30 * time.Second)
+            node["expression"] = text
+            chain = chain[:-1]
+        target = self.graph.evaluate(path, func, chain) if chain else None
+        if target is not None and target[0] == "func" and target[1] == "project":
+            graph_node = self.graph.nodes[target[2]]
+            return {"name": graph_node["name"], "path": graph_node["path"], "line": graph_node["line"],
+                    "signature": graph_node["signature"], **node, "resolved": True}
+        if target is not None and target[0] == "func":
+            return {"name": target[2], "external": True, **node, "resolved": True}
+        return {"expression": text, "resolved": False}
+
+    def _layer(self, path: str, func: Dict[str, Any], source: Optional[Dict[str, Any]], text: str,
+               via: str, line: int) -> Dict[str, Any]:
+        return {**self._symbol(path, func, source or {}, text), "via": via, "applied_at": line}
+
+    def _handler_like(self, path: str, func: Dict[str, Any], source: Dict[str, Any]) -> bool:
+        if source.get("func_literal"):
+            return True
+        chain = source.get("chain")
+        if not chain:
+            return False
+        if chain[-1] == "()":
+            return True
+        target = self.graph.evaluate(path, func, chain)
+        if target is not None and target[0] == "func":
+            return True
+        named = self.graph.type_of(path, func, source)
+        return named is not None and bool(_HANDLER_TYPE.search(named[-1]))
+
+    def _wrapped_argument(self, path: str, func: Dict[str, Any], callee: List[str],
+                          inner: Dict[str, Any]) -> Optional[int]:
+        """Which argument of `callee(...)` is the handler it wraps, or None when it wraps none."""
+        target = self.graph.evaluate(path, func, callee)
+        if target is not None and target[0] == "func" and target[1] == "project":
+            symbol = self.graph.functions.get(target[2], {})
+            for index, param in enumerate(symbol.get("params", [])[:len(inner["args"])]):
+                if _HANDLER_TYPE.search(param["type"]):
+                    return index
+            return None
+        # Unknown wrappers wrap their argument when it is a handler itself
+        for index, arg in enumerate(inner["args"]):
+            if arg and self._handler_like(path, func, arg):
+                return index
+        return None
+
+    def _unwrap(self, path: str, func: Dict[str, Any], call: Dict[str, Any],
+                index: int) -> Tuple[Dict[str, Any], str, List[Dict[str, Any]]]:
+        """Peel the wrappers off a handler argument: (handler source, its text, wrappers outermost first)."""
+        source = call["args"][index] or {}
+        text = call["arg_texts"][index]
+        line, column = call["line"], call["column"]
+        wrappers: List[Dict[str, Any]] = []
+        while True:
+            chain = source.get("chain")
+            if not chain or chain[-1] != "()" or len(chain) < 2:
+                break
+            callee = chain[:-1]
+            inner = self._call_at(func, line, column, callee)
+            if inner is None or not inner["args"]:
+                break
+            if callee[-1] == "()" or (callee[-1] in ("Then", "ThenFunc") and len(callee) > 2 and callee[-2] == "()"):
+                # chain(a, b)(h) or alice.New(a, b).Then(h): the builder's arguments wrap h, first outermost
+                if len(inner["args"]) != 1:
+                    break
+                builder_chain = callee[:-1] if callee[-1] == "()" else callee[:-2]
+                builder = self._call_at(func, line, column, builder_chain)
+                if builder is not None and builder["args"]:
+                    wrappers.extend(self._layer(path, func, arg, arg_text, "chain", builder["line"])
+                                    for arg, arg_text in zip(builder["args"], builder["arg_texts"]))
+                else:
+                    wrappers.append(self._layer(path, func, {"chain": builder_chain + ["()"]},
+                                                ".".join(builder_chain) + "(...)", "chain", inner["line"]))
+                position = 0
+            elif callee[-1] in _CONVERSIONS and len(inner["args"]) == 1:
+                position = 0
+            else:
+                position = self._wrapped_argument(path, func, callee, inner)
+                if position is None:
+                    break
+                wrappers.append(self._layer(path, func, {"chain": callee}, ".".join(callee), "wrapper",
+                                            inner["line"]))
+            source = inner["args"][position] or {}
+            text = inner["arg_texts"][position]
+            line, column = inner["line"], inner["column"]
+        return source, text, wrappers
+
+    def _handler(self, path: str, func: Dict[str, Any], call: Dict[str, Any],
+                 index: int) -> Tuple[Dict[str, Any], List[Dict[str, Any]]]:
+        """Resolve the handler argument to a project symbol where possible, with the wrappers around it."""
+        source, text, wrappers = self._unwrap(path, func, call, index)
+        if source.get("func_literal"):
+            return {"expression": "func literal", "line": call["line"]}, wrappers
+        chain = source.get("chain")
+        if chain:
+            target = self.graph.evaluate(path, func, chain)
+            if target is not None and target[0] == "func" and target[1] == "project":
+                node = self.graph.nodes[target[2]]
+                return {"name": node["name"], "path": node["path"], "line": node["line"],
+                        "signature": node["signature"]}, wrappers
+        return {"expression": text}, wrappers
+
+    def _scope(self, func: Dict[str, Any], var: str, line: int, column: int) -> Optional[Dict[str, Any]]:
+        """The call whose func literal argument binds `var` at a position (chi's Route/Group), if any."""
+        found = None
+        for call in func.get("calls", []):
+            if (call["line"], call["column"]) >= (line, column):
+                continue
+            for arg in call["args"]:
+                if arg and arg.get("func_literal") and var in arg.get("params", []) \
+                        and line <= arg.get("end_line", 0):
+                    if found is None or (call["line"], call["column"]) > (found["line"], found["column"]):
+                        found = call
+        return found
+
+    def _chain_layers(self, path: str, func: Dict[str, Any], call: Dict[str, Any]) -> List[Dict[str, Any]]:
+        """Middleware handed to Group(...) or With(...) along a call's selector chain."""
+        layers = []
+        chain = call["chain"]
+        for i, element in enumerate(chain):
+            if element not in ("Group", "With") or (i + 1 < len(chain) and chain[i + 1] != "()"):
+                continue
+            step = call if i + 1 == len(chain) else self._call_at(func, call["line"], 0, chain[:i + 1])
+            if step is None:
+                continue
+            for arg, arg_text in zip(step["args"], step["arg_texts"]):
+                if arg and (arg.get("type") == "string" or arg.get("func_literal")):
+                    continue
+                layers.append(self._layer(path, func, arg, arg_text, element.lower(), step["line"]))
+        return layers
+
+    def _server_layers(self, path: str, func: Dict[str, Any], var: str) -> List[Dict[str, Any]]:
+        """The wrappers a router goes through when it is served: http.ListenAndServe(addr, logRequests(mux))."""
+        for call in func.get("calls", []):
+            if call["chain"][-1] not in _SERVE or not call["args"]:
+                continue
+            source, _, wrappers = self._unwrap(path, func, call, len(call["args"]) - 1)
+            if source.get("chain") == [var]:
+                return [{**w, "via": "server"} for w in wrappers]
+        return []
+
+    def _router_layers(self, path: str, func: Dict[str, Any], var: str, line: int, column: int,
+                       framework: str, depth: int = 0) -> List[Dict[str, Any]]:
+        """The middleware a router variable applies at a position, its parents' first."""
+        if depth > 16:
+            return []
+        layers: List[Dict[str, Any]] = []
+        scope = self._scope(func, var, line, column)
+        local = (func.get("locals", {}).get(var) or {}).get("chain")
+        if scope is not None:
+            layers += self._router_layers(path, func, scope["chain"][0], scope["line"], scope["column"],
+                                          framework, depth + 1)
+            layers += self._chain_layers(path, func, scope)
+        elif local and local[-1] == "()" and local[0] != var and any(e in _GROUPS for e in local):
+            made = self._call_before(func, line, local[:-1])
+            if made is not None:
+                layers += self._router_layers(path, func, local[0], made["line"], made["column"],
+                                              framework, depth + 1)
+                layers += self._chain_layers(path, func, made)
+        else:
+            layers += self._server_layers(path, func, var)
+        for use in func.get("calls", []):
+            if use["chain"] != [var, "Use"] or self._scope(func, var, use["line"], use["column"]) is not scope:
+                continue
+            if framework in ("chi", "gin") and (use["line"], use["column"]) > (line, column):
+                continue
+            layers += [self._layer(path, func, arg, arg_text, "use", use["line"])
+                       for arg, arg_text in zip(use["args"], use["arg_texts"])]
+        return layers
+
+    def _gorilla_methods(self, func: Dict[str, Any], call: Dict[str, Any]) -> Optional[List[str]]:
+        """Find `.Methods("GET", ...)` chained off a registration call."""
+        for other in func.get("calls", []):
+            chain = other["chain"]
+            if other["line"] != call["line"] or chain[-1] != "Methods":
+                continue
+            if chain[:len(call["chain"])] != call["chain"]:
+                continue
+            methods = [a["value"] for a in other["args"] if a and "value" in a]
+            return methods or None
+        return None
+
+    def routes_in(self, path: str) -> List[Dict[str, Any]]:
+        parsed = self.graph.project.files[path]
+        frameworks = _frameworks(parsed)
+        if not frameworks:
+            return []
+
+        routes = []
+        for func in parsed.get("functions", []):
+            for call in func.get("calls", []):
+                chain = call["chain"]
+                name = chain[-1]
+                args = call["args"]
+                method: Optional[str] = None
+                route_index, handler_index = 0, len(args) - 1
+
+                if len(chain) < 2:
+                    continue
+                third_party = [f for f in frameworks if f != "net/http"]
+                if name in ("Method", "MethodFunc") and len(args) == 3 and "chi" in frameworks:
+                    first = args[0] or {}
+                    if "value" not in first:
+                        continue
+                    method = first["value"].upper()
+                    route_index, handler_index = 1, 2
+                    framework = "chi"
+                elif name in _HANDLE and len(args) == 2:
+                    framework = None if third_party else "net/http"
+                elif name in _METHOD_HELPERS and len(args) >= 2 and third_party and chain[0] != "http":
+                    method = _METHOD_HELPERS[name]
+                    framework = None
+                else:
+                    continue
+
+                route = self._route_value(path, call, route_index)
+                if route.get("dynamic") and (args[route_index] or {}).get("type") != "string" \
+                        and name not in _HANDLE:
+                    # Not recognisably a path: probably an unrelated Get/Post method
+                    continue
+                if route.get("dynamic") is None and name in _HANDLE and " " in route["route"]:
+                    # Go 1.22 ServeMux patterns: "GET /users/{id}"
+                    prefix, rest = route["route"].split(" ", 1)
+                    if prefix in HTTP_METHODS:
+                        method, route["route"] = prefix, rest.strip()
+
+                entry: Dict[str, Any] = {"method": method, **route}
+                if name in _HANDLE:
+                    methods = self._gorilla_methods(func, call)
+                    if methods:
+                        entry["method"] = methods[0] if len(methods) == 1 else methods
+                        framework = "gorilla/mux"
+                framework = framework or self._receiver_framework(path, func, chain) \
+                    or self._guess_framework(frameworks)
+                inline = []
+                if name in _METHOD_HELPERS and len(args) > route_index + 2:
+                    # gin: GET(path, middleware..., handler); echo: GET(path, handler, middleware...)
+                    if framework == "echo":
+                        handler_index = route_index + 1
+                        inline = list(range(handler_index + 1, len(args)))
+                    else:
+                        inline = list(range(route_index + 1, handler_index))
+                entry["handler"], wrappers = self._handler(path, func, call, handler_index)
+                entry["framework"] = framework
+                entry["middleware"] = (
+                    self._router_layers(path, func, chain[0], call["line"], call["column"], framework)
+                    + self._chain_layers(path, func, call)
+                    + [self._layer(path, func, args[i], call["arg_texts"][i], "argument", call["line"])
+                       for i in inline]
+                    + wrappers
+                )
+                entry["registered_at"] = {
+                    "path": path,
+                    "line": call["line"],
+                    "column": call["column"],
+                    "function": f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"],
+                }
+                routes.append(entry)
+        return routes
+
+    def _receiver_framework(self, path: str, func: Dict[str, Any], chain: List[str]) -> Optional[str]:
+        """Name the router package a registration's receiver was created from, if visible."""
+        imports = {imp["name"]: imp["path"] for imp in self.graph.project.files[path].get("imports", [])
+                   if imp["name"]}
+        head = chain[0]
+        source = func.get("locals", {}).get(head)
+        if source and "chain" in source:
+            head = source["chain"][0]
+        return ROUTER_IMPORTS.get(imports.get(head, ""))
+
+    @staticmethod
+    def _guess_framework(frameworks: List[str]) -> str:
+        for label in ("chi", "gorilla/mux", "gin", "echo", "net/http"):
+            if label in frameworks:
+                return label
+        return frameworks[0]
+
+    def extract(self, path: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Return every route registration, optionally limited to one file or directory."""
+        routes = []
+        for file_path in sorted(self.graph.project.files):
+            if path and file_path != path and not file_path.startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            routes.extend(self.routes_in(file_path))
+        return routes
@@ -0,0 +1,432 @@
+"""House rules: calls, imports and type assertions a Go project forbids or requires, checked on the index.
+
+Rules are declared in the project's configuration file (the "rules"
+setting, see xray.core.project_config), one mapping per rule:
+
+    id               what findings are reported under (required, unique)
+    call             globs of the functions matched, by qualified name
+                     ("time.Now", "example.com/app/auth.Check",
+                     "database/sql.DB.Query") or by package name and
+                     function ("auth.Check", "fmt.Print*")
+    import           globs of the import paths matched ("unsafe", "github.com/pkg/errors")
+    type_assertion   globs of the asserted types matched, as written
+                     ("*MyError", a leading * being the pointer) or
+                     qualified ("*net.OpError")
+    polarity         "forbidden" (default): every match in scope is a
+                     finding; "required": every function in scope without
+                     a match is one (for imports: every package)
+    packages         only packages matching one of these globs - by import
+                     path, directory relative to the root, or name ("main")
+    exclude_packages not in packages matching one of these
+    functions        only functions and methods named like one of these
+                     ("Handle*", "*Handler.ServeHTTP")
+    exclude_functions not in those named like one of these
+    exported_only    only exported functions and methods
+    signature        only functions whose signature matches this glob
+                     ("*http.ResponseWriter*")
+    transitive       required calls and assertions may be made by any
+                     project function the scoped one calls, not just itself
+    message          the finding's message (a default names the match)
+    severity         "error", "warning" (default) or "note"
+
+Exactly one of call, import and type_assertion is given. A forbidden call
+also matches a function value taken (`now := time.Now`) and calls at
+package level (`var started = time.Now()`), which function scoping leaves
+out. Type switches are not type assertions here. BUILTIN_RULES are
+examples run when the configuration declares no rules.
+"""
+
+import difflib
+import fnmatch
+import os
+import re
+from typing import Any, Dict, List, Optional, Set, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+
+MATCHES = ("call", "import", "type_assertion")
+POLARITIES = ("forbidden", "required")
+SEVERITIES = ("error", "warning", "note")
+_GLOB_FIELDS = ("packages", "exclude_packages", "functions", "exclude_functions")
+_FIELDS = ("id", "message", "severity", "polarity", "exported_only", "signature", "transitive") \
+    + MATCHES + _GLOB_FIELDS
+
+BUILTIN_RULES = [
+    {"id": "no-unsafe", "import": ["unsafe"], "polarity": "forbidden", "severity": "warning",
+     "message": "unsafe bypasses Go's type and memory safety"},
+    {"id": "no-exit-outside-main", "call": ["os.Exit", "log.Fatal*", "log.Logger.Fatal*"],
+     "polarity": "forbidden", "exclude_packages": ["main"], "severity": "warning",
+     "message": "only main should end the process: return an error instead"},
+    {"id": "no-print-outside-main", "call": ["fmt.Print*"], "polarity": "forbidden",
+     "exclude_packages": ["main"], "severity": "note",
+     "message": "library code should not write to stdout: take an io.Writer or a logger"},
+    {"id": "test-helpers-call-helper", "call": ["testing.*.Helper"], "polarity": "required",
+     "signature": "*testing.[TB]*", "exclude_functions": ["Test*", "Benchmark*", "Fuzz*", "Example*"],
+     "severity": "note", "message": "test helpers should call t.Helper() so failures point at the caller"},
+]
+
+Key = Tuple[str, str]
+
+
+def parse_rule(data: Any, where: str) -> Tuple[Optional[Dict[str, Any]], List[str]]:
+    """A rule declaration checked and normalized (globs as lists), or None and what is wrong with it."""
+    if not isinstance(data, dict):
+        return None, [f"{where}: expected a mapping, got {data!r}"]
+    errors = []
+    rule: Dict[str, Any] = {}
+    for key, value in data.items():
+        if key not in _FIELDS:
+            close = difflib.get_close_matches(str(key), _FIELDS, 1, 0.6)
+            hint = f"did you mean '{close[0]}'?" if close else f"known: {', '.join(_FIELDS)}"
+            errors.append(f"{where}: unknown field '{key}' - {hint}")
+        elif key in MATCHES + _GLOB_FIELDS:
+            globs = [value] if isinstance(value, str) else value
+            if not isinstance(globs, list) or not globs or \
+                    not all(isinstance(g, str) and g for g in globs):
+                errors.append(f"{where}: {key} takes a glob or a list of globs")
+            else:
+                rule[key] = globs
+        elif key in ("exported_only", "transitive"):
+            if isinstance(value, bool):
+                rule[key] = value
+            else:
+                errors.append(f"{where}: {key} takes true or false")
+        elif key in ("id", "message", "signature"):
+            if isinstance(value, str) and value.strip():
+                rule[key] = value.strip()
+            else:
+                errors.append(f"{where}: {key} takes a non-empty string")
+        elif key == "severity" and value not in SEVERITIES:
+            errors.append(f"{where}: severity is one of {', '.join(SEVERITIES)}")
+        elif key == "polarity" and value not in POLARITIES:
+            errors.append(f"{where}: polarity is one of {', '.join(POLARITIES)}")
+        else:
+            rule[key] = value
+    if "id" not in rule and "id" not in data:
+        errors.append(f"{where}: every rule needs an id")
+    given = [m for m in MATCHES if m in data]
+    if len(given) != 1:
+        errors.append(f"{where}: give exactly one of {', '.join(MATCHES)}"
+                      + (f", not {' and '.join(given)}" if given else ""))
+    if errors:
+        return None, errors
+    rule.setdefault("polarity", "forbidden")
+    rule.setdefault("severity", "warning")
+    if data.get("transitive") and rule["polarity"] != "required":
+        return None, [f"{where}: transitive applies to required rules only"]
+    return rule, []
+
+
+def parse_rules(data: Any, where: str = "rules") -> Tuple[List[Dict[str, Any]], List[str]]:
+    """Every usable rule of a list of declarations, and the problems with the others (duplicate ids too)."""
+    if not isinstance(data, list):
+        return [], [f"{where}: expected a list of rules"]
+    rules, errors, seen = [], [], set()
+    for i, item in enumerate(data):
+        rule, problems = parse_rule(item, f"{where}[{i}]")
+        if rule is not None and rule["id"] in seen:
+            problems = [f"{where}[{i}]: rule id '{rule['id']}' is used twice"]
+        if problems:
+            errors.extend(problems)
+            continue
+        seen.add(rule["id"])
+        rules.append(rule)
+    return rules, errors
+
+
+def _matches(value: Optional[str], globs: List[str]) -> bool:
+    return value is not None and any(fnmatch.fnmatchcase(value, g) for g in globs)
+
+
+def _short(qualified: str) -> str:
+    """"github.com/acme/auth.Check" -> "auth.Check": the package's last path element and the name."""
+    return qualified.rsplit("/", 1)[-1]
+
+
+def _type_glob(glob: str) -> str:
+    """A type glob with a pointer's leading * taken literally: "*MyError" is not "MyError"."""
+    return "[*]" + glob[1:] if re.match(r"\*[A-Za-z_]", glob) else glob
+
+
+def _external(call: Dict[str, Any], func: Optional[Dict[str, Any]], imports: Dict[str, str]) -> Optional[Key]:
+    """The imported function `pkg.Name` a call or value names, for those without an edge."""
+    chain = call["chain"]
+    if len(chain) == 2 and chain[0] in imports and chain[0] not in (func or {}).get("locals", {}):
+        return "", f"{imports[chain[0]]}.{chain[1]}"
+    return None
+
+
+class RuleEngine:
+    """The findings of declarative rules on one call graph's Go project."""
+
+    def __init__(self, graph: GoCallGraph):
+        self.graph = graph
+        self.project = graph.project
+        self._package_names: Dict[str, List[str]] = {}
+
+    def _package_ids(self, pkg_dir: str) -> List[str]:
+        """What a package glob is matched against: import path, directory relative to the root, name."""
+        if pkg_dir not in self._package_names:
+            ids = []
+            import_path = self.project.import_path(pkg_dir)
+            if import_path:
+                ids.append(import_path)
+            root = self.project.root or ""
+            rel = os.path.relpath(pkg_dir, root).replace(os.sep, "/") if root else pkg_dir
+            ids.append("." if rel in ("", ".") else rel)
+            name = self.project.packages.get(pkg_dir, {}).get("name")
+            if name:
+                ids.append(name)
+            self._package_names[pkg_dir] = ids
+        return self._package_names[pkg_dir]
+
+    def _package_in_scope(self, rule: Dict[str, Any], pkg_dir: str) -> bool:
+        ids = self._package_ids(pkg_dir)
+        if "packages" in rule and not any(_matches(i, rule["packages"]) for i in ids):
+            return False
+        return not any(_matches(i, rule.get("exclude_packages", [])) for i in ids)
+
+    @staticmethod
+    def _function_scoped(rule: Dict[str, Any]) -> bool:
+        return any(k in rule for k in ("functions", "exclude_functions", "exported_only", "signature"))
+
+    def _function_in_scope(self, rule: Dict[str, Any], key: Key) -> bool:
+        name = key[1]
+        if "functions" in rule and not _matches(name, rule["functions"]):
+            return False
+        if _matches(name, rule.get("exclude_functions", [])):
+            return False
+        if rule.get("exported_only") and not name.rsplit(".", 1)[-1][:1].isupper():
+            return False
+        if "signature" in rule and not fnmatch.fnmatchcase(self.graph.nodes[key].get("signature", ""),
+                                                           rule["signature"]):
+            return False
+        return True
+
+    def _qualified(self, callee: Key) -> Optional[str]:
+        if callee[0] == "":
+            return callee[1]
+        import_path = self.project.import_path(callee[0])
+        return f"{import_path}.{callee[1]}" if import_path else None
+
+    def _call_matches(self, rule: Dict[str, Any], callee: Key) -> Optional[str]:
+        """The name a callee is matched by, if it matches."""
+        qualified = self._qualified(callee)
+        if qualified is not None:
+            names = [qualified, _short(qualified)]
+        else:
+            package = self.graph.nodes.get(callee, {}).get("package")
+            names = [f"{package}.{callee[1]}"] if package else [callee[1]]
+        return names[0] if any(_matches(n, rule["call"]) for n in names) else None
+
+    @staticmethod
+    def _imports(parsed: Dict[str, Any]) -> Dict[str, str]:
+        return {imp["name"]: imp["path"] for imp in parsed.get("imports", []) if imp.get("name")}
+
+    @staticmethod
+    def _assertion_matches(rule: Dict[str, Any], asserted: str, imports: Dict[str, str]) -> bool:
+        names = [asserted]
+        qualified = re.sub(r"\b([A-Za-z_]\w*)\.", lambda m: f"{imports[m.group(1)]}." if m.group(1) in imports
+                           else m.group(0), asserted)
+        if qualified != asserted:
+            names.append(qualified)
+        return any(_matches(n, [_type_glob(g) for g in rule["type_assertion"]]) for n in names)
+
+    def _files(self, include_tests: bool, scope: Optional[str]) -> List[Tuple[str, Dict[str, Any]]]:
+        files = []
+        for file_path, parsed in sorted(self.project.files.items()):
+            if not include_tests and file_path.endswith("_test.go"):
+                continue
+            if scope and file_path != scope and not file_path.startswith(scope.rstrip(os.sep) + os.sep):
+                continue
+            files.append((file_path, parsed))
+        return files
+
+    def _keys(self, file_path: str, parsed: Dict[str, Any]) -> List[Tuple[Key, Dict[str, Any]]]:
+        """The call graph keys of a file's functions, with their parse."""
+        pkg_dir = os.path.dirname(file_path)
+        keys = []
+        for func in parsed.get("functions", []):
+            key = (pkg_dir, f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"])
+            if key in self.graph.nodes:
+                keys.append((key, func))
+        return keys
+
+    def _finding(self, rule: Dict[str, Any], detail: str, path: str, line: int, column: Optional[int],
+                 function: Optional[str], match: Optional[str] = None) -> Dict[str, Any]:
+        finding: Dict[str, Any] = {"rule": rule["id"], "severity": rule["severity"], "polarity": rule["polarity"],
+                                   "message": rule.get("message") or detail}
+        if rule.get("message"):
+            finding["detail"] = detail
+        finding.update({"path": path, "line": line})
+        if column:
+            finding["column"] = column
+        if function:
+            finding["function"] = function
+        finding["package"] = self._package_ids(os.path.dirname(path))[0]
+        if match:
+            finding["match"] = match
+        if path.endswith("_test.go"):
+            finding["in_test"] = True
+        return finding
+
+    # ------------------------------------------------------------------
+    # Forbidden: every match in scope
+    # ------------------------------------------------------------------
+
+    def _forbidden(self, rule: Dict[str, Any], files: List[Tuple[str, Dict[str, Any]]],
+                   sites: Dict[Tuple[str, int, int], Key]) -> List[Dict[str, Any]]:
+        findings = []
+        scoped = self._function_scoped(rule)
+        for file_path, parsed in files:
+            if not self._package_in_scope(rule, os.path.dirname(file_path)):
+                continue
+            imports = self._imports(parsed)
+            if "import" in rule:
+                if scoped:
+                    continue
+                for imp in parsed.get("imports", []):
+                    if _matches(imp["path"], rule["import"]):
+                        findings.append(self._finding(rule, f"imports {imp['path']}", file_path, imp["line"],
+                                                      imp.get("column"), None, imp["path"]))
+                continue
+            keys = self._keys(file_path, parsed)
+            for key, func in keys:
+                if scoped and not self._function_in_scope(rule, key):
+                    continue
+                if "call" in rule:
+                    for what, site in [("calls", c) for c in func.get("calls", [])] + \
+                            [("takes the function value of", r) for r in func.get("value_refs", [])]:
+                        callee = sites.get((file_path, site["line"], site["column"])) or \
+                            (_external(site, func, imports) if what != "calls" else None)
+                        name = self._call_matches(rule, callee) if callee is not None else None
+                        if name is not None:
+                            findings.append(self._finding(rule, f"{key[1]} {what} {name}", file_path,
+                                                          site["line"], site["column"], key[1], name))
+                else:
+                    for assertion in func.get("assertions", []) + func.get("checked_assertions", []):
+                        if self._assertion_matches(rule, assertion["asserted_type"], imports):
+                            findings.append(self._finding(
+                                rule, f"{key[1]} asserts {assertion['expression']} to {assertion['asserted_type']}",
+                                file_path, assertion["line"], assertion["column"], key[1],
+                                assertion["asserted_type"]))
+            if "call" in rule and not scoped:
+                # Package-level calls have no edges: `var started = time.Now()`
+                for call in parsed.get("package_calls", []):
+                    callee = _external(call, None, imports)
+                    name = self._call_matches(rule, callee) if callee is not None else None
+                    if name is not None:
+                        findings.append(self._finding(rule, f"package scope calls {name}", file_path,
+                                                      call["line"], call["column"], None, name))
+        return findings
+
+    # ------------------------------------------------------------------
+    # Required: every function (or package) in scope without a match
+    # ------------------------------------------------------------------
+
+    def _own_match(self, rule: Dict[str, Any], key: Key, callees: Dict[Key, List[Key]]) -> Optional[str]:
+        if "call" in rule:
+            return next((name for name in (self._call_matches(rule, c) for c in callees.get(key, [])) if name), None)
+        symbol = self.graph.functions.get(key, {})
+        node_path = self.graph.nodes[key]["path"]
+        imports = self._imports(self.project.files.get(node_path, {}))
+        for assertion in symbol.get("assertions", []) + symbol.get("checked_assertions", []):
+            if self._assertion_matches(rule, assertion["asserted_type"], imports):
+                return assertion["asserted_type"]
+        return None
+
+    def _required_match(self, rule: Dict[str, Any], key: Key, callees: Dict[Key, List[Key]]) -> Optional[str]:
+        """The match made by key - or, transitive, by a project function it reaches."""
+        queue = [key]
+        seen: Set[Key] = {key}
+        while queue:
+            current = queue.pop(0)
+            found = self._own_match(rule, current, callees)
+            if found:
+                return found
+            if not rule.get("transitive"):
+                return None
+            for callee in callees.get(current, []):
+                if callee[0] and callee in self.graph.nodes and callee not in seen:
+                    seen.add(callee)
+                    queue.append(callee)
+        return None
+
+    def _required(self, rule: Dict[str, Any], files: List[Tuple[str, Dict[str, Any]]],
+                  callees: Dict[Key, List[Key]]) -> Tuple[List[Dict[str, Any]], int]:
+        findings = []
+        checked = 0
+        if "import" in rule:
+            packages: Dict[str, List[Tuple[str, Dict[str, Any]]]] = {}
+            for file_path, parsed in files:
+                if not file_path.endswith("_test.go"):
+                    packages.setdefault(os.path.dirname(file_path), []).append((file_path, parsed))
+            for pkg_dir, pkg_files in sorted(packages.items()):
+                if not self._package_in_scope(rule, pkg_dir):
+                    continue
+                checked += 1
+                if not any(_matches(imp["path"], rule["import"]) for _, parsed in pkg_files
+                           for imp in parsed.get("imports", [])):
+                    package = self._package_ids(pkg_dir)[0]
+                    findings.append(self._finding(rule, f"package {package} does not import "
+                                                        f"{' or '.join(rule['import'])}",
+                                                  pkg_files[0][0], 1, None, None))
+            return findings, checked
+        for file_path, parsed in files:
+            if not self._package_in_scope(rule, os.path.dirname(file_path)):
+                continue
+            for key, func in self._keys(file_path, parsed):
+                if self.graph.nodes[key].get("interface_method") or self.graph.nodes[key]["path"] != file_path \
+                        or not self._function_in_scope(rule, key):
+                    continue
+                checked += 1
+                if self._required_match(rule, key, callees) is None:
+                    what = f"call {' or '.join(rule['call'])}" if "call" in rule else \
+                        f"assert to {' or '.join(rule['type_assertion'])}"
+                    through = " directly or through the functions it calls" if rule.get("transitive") else ""
+                    findings.append(self._finding(rule, f"{key[1]} does not {what}{through}", file_path,
+                                                  func["start_line"], None, key[1]))
+        return findings, checked
+
+    def run(self, rules: List[Dict[str, Any]], include_tests: bool = False,
+            path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        The findings of rules in file and line order, with the number each
+        rule produced and, for required rules, how many functions (or
+        packages) it checked.
+
+        Args:
+            rules: Rules as parse_rules returns them
+            include_tests: Also check _test.go files
+            path: Only this file or package directory (and below)
+        """
+        files = self._files(include_tests, path)
+        sites: Dict[Tuple[str, int, int], Key] = {}
+        callees: Dict[Key, List[Key]] = {}
+        for edge in self.graph.edges:
+            sites[(edge["path"], edge["line"], edge["column"])] = edge["callee"]
+            if edge["kind"] != "reference":
+                callees.setdefault(edge["caller"], []).append(edge["callee"])
+        findings: List[Dict[str, Any]] = []
+        summary = []
+        for rule in rules:
+            entry: Dict[str, Any] = {"id": rule["id"], "polarity": rule["polarity"], "severity": rule["severity"]}
+            if "source" in rule:
+                entry["source"] = rule["source"]
+            for kind in MATCHES:
+                if kind in rule:
+                    entry[kind] = rule[kind]
+            if rule["polarity"] == "forbidden":
+                found = self._forbidden(rule, files, sites)
+            else:
+                found, entry["checked"] = self._required(rule, files, callees)
+            entry["finding_count"] = len(found)
+            summary.append(entry)
+            findings.extend(found)
+        findings.sort(key=lambda f: (f["path"], f["line"], f.get("column") or 0, f["rule"]))
+        result: Dict[str, Any] = {"rules": summary, "findings": findings, "total_count": len(findings)}
+        broken = [entry["id"] for entry in summary if entry["finding_count"]]
+        if broken:
+            result["message"] = f"{len(findings)} finding{'s' if len(findings) != 1 else ''} from " \
+                                f"{len(broken)} of {len(rules)} rules: {', '.join(broken)}"
+        return result
@@ -0,0 +1,321 @@
+"""Name search over the declarations of a Go project and its TS/JS, Python, Rust, Java, .proto, .sql, Makefile and shell files.
+
+Three ways to match a query against symbol names: case-insensitive (or
+exact-case) substring, a regular expression, and fuzzy matching that
+favours the start of CamelCase humps, so "usvc" finds UserService and
+"gbid" finds GetByID. Every mode yields a 0-100 score; results are ranked
+by score, then name, then location.
+"""
+
+import os
+import re
+from typing import Callable, Dict, List, Optional, Any, Set, Tuple
+
+from xray.core.go_analysis import GoProject
+from xray.core.go_tests import is_test_file
+from xray.core.java_analysis import JavaProject
+from xray.core.proto_analysis import ProtoProject
+from xray.core.py_analysis import PyProject
+from xray.core.rs_analysis import RsProject
+from xray.core.sql_analysis import SqlProject
+from xray.core.task_analysis import TaskProject
+from xray.core.ts_analysis import TsProject
+
+MODES = ("substring", "regex", "fuzzy")
+LANGUAGES = ("go", "typescript", "javascript", "python", "rust", "java", "proto", "sql", "make", "shell")
+
+# Filter names -> symbol "type" values of the Go, TypeScript, Python, Rust, Java, .proto, SQL, Makefile and shell parsers
+KINDS: Dict[str, Set[str]] = {
+    "func": {"function"},
+    "method": {"method"},
+    "type": {"struct", "interface", "type", "class", "enum", "record", "annotation", "trait", "message"},
+    "interface": {"interface"},
+    "struct": {"struct"},
+    "class": {"class"},
+    "enum": {"enum"},
+    "record": {"record"},
+    "annotation": {"annotation"},
+    "const": {"constant", "enum_value"},
+    "var": {"variable"},
+    "field": {"field", "property"},
+    "namespace": {"namespace"},
+    "module": {"module", "package"},
+    "trait": {"trait"},
+    "macro": {"macro"},
+    "message": {"message"},
+    "service": {"service"},
+    "rpc": {"rpc"},
+    "table": {"table"},
+    "column": {"column"},
+    "index": {"index"},
+    "target": {"target"},
+}
+
+# Fuzzy scoring weights
+_MATCH = 10
+_HUMP_START = 15
+_CONSECUTIVE = 8
+_NAME_START = 10
+_MAX_GAP_PENALTY = 3
+
+
+def _hump_starts(name: str) -> Set[int]:
+    """Indexes where a CamelCase, snake_case or digit run begins ("HTTPServer" -> 0, 4)."""
+    starts = {0}
+    for i in range(1, len(name)):
+        prev, char = name[i - 1], name[i]
+        nxt = name[i + 1] if i + 1 < len(name) else ""
+        if prev in "_.":
+            starts.add(i)
+        elif char.isupper() and (prev.islower() or prev.isdigit() or (prev.isupper() and nxt.islower())):
+            starts.add(i)
+        elif char.isdigit() and not prev.isdigit():
+            starts.add(i)
+    return starts
+
+
+def _folded(name: str, starts: Set[int]) -> Tuple[str, Set[int]]:
+    """A name case-folded ("Größe" -> "grösse") and its hump starts as indexes into the folded text."""
+    folded, folded_starts = [], set()
+    for i, char in enumerate(name):
+        if i in starts:
+            folded_starts.add(len(folded))
+        folded.extend(char.casefold())
+    return "".join(folded), folded_starts
+
+
+def fuzzy_score(query: str, name: str) -> Optional[int]:
+    """
+    Score query as a subsequence of name, or None if it is not one.
+
+    Matches at hump starts and runs of consecutive characters score higher;
+    skipped characters cost a little. The best alignment is found by
+    dynamic programming and scaled to 0-100. Both are compared case-folded,
+    so "STRASSE" matches "straße".
+    """
+    q = query.casefold()
+    if not q:
+        return None
+    lowered, starts = _folded(name, _hump_starts(name))
+    # best[i]: best score so far with the current query char matched at lowered[i]
+    best: Dict[int, int] = {}
+    for j, char in enumerate(q):
+        current: Dict[int, int] = {}
+        for i, candidate in enumerate(lowered):
+            if candidate != char:
+                continue
+            gain = _MATCH + (_HUMP_START if i in starts else 0)
+            if j == 0:
+                current[i] = gain + (_NAME_START if i == 0 else 0)
+                continue
+            options = [score + (_CONSECUTIVE if k == i - 1 else -min(i - k - 1, _MAX_GAP_PENALTY))
+                       for k, score in best.items() if k < i]
+            if options:
+                current[i] = max(options) + gain
+        if not current:
+            return None
+        best = current
+    if q == lowered:
+        return 100
+    # Relative to a query whose every character starts a hump of the name
+    ceiling = len(q) * (_MATCH + _HUMP_START) + _NAME_START
+    return max(0, min(99, round(100 * max(best.values()) / ceiling)))
+
+
+def _substring_score(query: str, name: str, case_sensitive: bool) -> Optional[int]:
+    if not case_sensitive:
+        query, name = query.casefold(), name.casefold()
+    if name == query:
+        return 100
+    if name.startswith(query):
+        return 90
+    if query in name:
+        return 70
+    return None
+
+
+def symbol_filter(kinds: Optional[List[str]] = None, exported_only: bool = False,
+                  top_level_only: bool = False) -> Callable[[Dict[str, Any]], bool]:
+    """
+    A predicate on symbol records of every parser: of one of these kinds
+    (KINDS names), exported (capitalized in Go, as its parser marks it
+    elsewhere), and not inside another declaration - a field, an interface
+    method spec, a class member or a nested declaration.
+    """
+    allowed = _allowed_types(kinds)
+
+    def keep(symbol: Dict[str, Any]) -> bool:
+        if allowed is not None and symbol["type"] not in allowed:
+            return False
+        if exported_only:
+            exported = symbol["name"][:1].isupper() if symbol.get("language", "go") == "go" else symbol.get("exported")
+            if not exported:
+                return False
+        return not (top_level_only and (symbol.get("container") or symbol.get("nested")))
+    return keep
+
+
+def _allowed_types(kinds: Optional[List[str]]) -> Optional[Set[str]]:
+    """The symbol types of KINDS filter names, None for every type."""
+    if not kinds:
+        return None
+    unknown = [k for k in kinds if k not in KINDS]
+    if unknown:
+        raise ValueError(f"Unknown kind(s) {', '.join(unknown)}; use {', '.join(KINDS)}")
+    return set().union(*(KINDS[k] for k in kinds))
+
+
+def search_symbols(
+    project: GoProject,
+    query: str,
+    mode: str = "substring",
+    case_sensitive: bool = False,
+    kinds: Optional[List[str]] = None,
+    package: Optional[str] = None,
+    exported_only: bool = False,
+    modules: Optional[TsProject] = None,
+    language: Optional[str] = None,
+    python: Optional[PyProject] = None,
+    rust: Optional[RsProject] = None,
+    protos: Optional[ProtoProject] = None,
+    include_tests: bool = True,
+    java: Optional[JavaProject] = None,
+    sql: Optional[SqlProject] = None,
+    tasks: Optional[TaskProject] = None,
+) -> List[Dict[str, Any]]:
+    """
+    Return the project's symbols whose names match query, best first.
+    Nested Go declarations (function literals given a name, types and
+    constants of function bodies, anonymous structs) are searched too and
+    name their enclosing declaration in "parent", as do Java local and
+    anonymous classes.
+
+    Args:
+        project: The Go packages to search
+        query: Substring, regular expression or fuzzy pattern
+        mode: One of substring, regex, fuzzy
+        case_sensitive: Match case exactly (substring and regex modes)
+        kinds: Only these kinds (func, method, type, interface, class, enum, record, annotation, const, var, field,
+            namespace, module, trait, macro, message, service, rpc, table, column, index, target)
+        package: Only packages or modules whose directory, relative to the project root, is or is under this
+        exported_only: Only exported names (capitalized in Go, exported from the module in TS/JS,
+            public or listed in __all__ in Python, pub in Rust, public or protected in Java)
+        modules: The TypeScript/JavaScript files to search as well
+        language: Only symbols of this language (go, typescript, javascript, python, rust, java, proto, sql, make,
+            shell)
+        python: The Python files to search as well
+        rust: The Rust files to search as well
+        protos: The .proto files to search as well
+        include_tests: Also search test files (_test.go, test_*.py, *.test.ts...)
+        java: The Java files to search as well
+        sql: The .sql files to search as well
+        tasks: The Makefiles and shell scripts to search as well
+    """
+    if mode not in MODES:
+        raise ValueError(f"mode must be one of {', '.join(MODES)}")
+    if language is not None and language not in LANGUAGES:
+        raise ValueError(f"language must be one of {', '.join(LANGUAGES)}")
+    allowed = _allowed_types(kinds)
+    pattern = None
+    if mode == "regex":
+        try:
+            pattern = re.compile(query, 0 if case_sensitive else re.IGNORECASE)
+        except re.error as e:
+            raise ValueError(f"Invalid regex '{query}': {e}")
+    prefix = package.strip("/") if package else None
+
+    def score_of(name: str, qualified: str) -> Optional[int]:
+        if mode == "substring":
+            return _substring_score(query, name, case_sensitive)
+        if mode == "regex":
+            return 100 if pattern.search(qualified) else None
+        return fuzzy_score(query, name)
+
+    def rel_dir_of(directory: str, root: Optional[str]) -> Optional[str]:
+        rel_dir = os.path.relpath(directory, root).replace(os.sep, "/") if root else directory
+        if rel_dir == ".":
+            rel_dir = ""
+        if prefix and rel_dir != prefix and not rel_dir.startswith(prefix + "/"):
+            return None
+        return rel_dir
+
+    results = []
+    for pkg_dir, info in sorted(project.packages.items()):
+        if language not in (None, "go"):
+            break
+        rel_dir = rel_dir_of(pkg_dir, project.root)
+        if rel_dir is None:
+            continue
+        for path in info["files"]:
+            if not include_tests and is_test_file(path):
+                continue
+            parsed = project.files[path]
+            for symbol in parsed["symbols"] + parsed.get("nested", []):
+                if allowed is not None and symbol["type"] not in allowed:
+                    continue
+                name = symbol["name"]
+                if exported_only and not name[:1].isupper():
+                    continue
+                owner = symbol["receiver"]["type"] if symbol.get("receiver") else symbol.get("container")
+                qualified = f"{owner}.{name}" if owner else name
+                if symbol.get("parent") and not owner:
+                    qualified = f"{symbol['parent']}.{name}"
+                score = score_of(name, qualified)
+                if score is None:
+                    continue
+                results.append({
+                    "name": name,
+                    "qualified_name": qualified,
+                    "kind": symbol["type"],
+                    "language": "go",
+                    "container": {"package": info["name"], "package_dir": rel_dir, "receiver": owner},
+                    "path": path,
+                    "line": symbol["start_line"],
+                    "signature": symbol.get("signature"),
+                    "score": score,
+                })
+                if symbol.get("nested"):
+                    results[-1]["parent"] = symbol.get("parent")
+                if symbol.get("testKind"):
+                    results[-1]["testKind"] = symbol["testKind"]
+                if symbol.get("buildConstraints"):
+                    results[-1]["buildConstraints"] = symbol["buildConstraints"]
+                    variants = project.variants(path, symbol)
+                    if variants:
+                        results[-1]["variants"] = variants
+    sources = [(source, path, symbol) for source in (modules, python, rust, java, protos, sql, tasks)
+               if source is not None
+               for path, symbol in source.symbols()]
+    for source, path, symbol in sources:
+        if language not in (None, symbol["language"]):
+            continue
+        if allowed is not None and symbol["type"] not in allowed:
+            continue
+        if exported_only and not symbol.get("exported"):
+            continue
+        if not include_tests and is_test_file(path):
+            continue
+        rel_dir = rel_dir_of(os.path.dirname(path), source.root)
+        if rel_dir is None:
+            continue
+        name, owner = symbol["name"], symbol.get("container")
+        qualified = f"{owner}.{name}" if owner else name
+        score = score_of(name, qualified)
+        if score is None:
+            continue
+        results.append({
+            "name": name,
+            "qualified_name": qualified,
+            "kind": symbol["type"],
+            "language": symbol["language"],
+            "container": {"package_dir": rel_dir, "receiver": owner,
+                          "module": os.path.basename(path) if source is modules else source.dotted_name(path)},
+            "path": path,
+            "line": symbol["start_line"],
+            "signature": symbol.get("signature"),
+            "score": score,
+        })
+        if symbol.get("nested") and symbol.get("parent"):
+            results[-1]["parent"] = symbol["parent"]
+    results.sort(key=lambda r: (-r["score"], r["name"], r["path"], r["line"]))
+    return results
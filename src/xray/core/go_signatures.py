@@ -0,0 +1,237 @@
+"""Go functions and methods searched by the shape of their signature.
+
+SignatureIndex keeps every function, method and interface method spec of
+a GoProject with its receiver, parameter and result types normalized: a
+type is split into its wrappers ("*", "[]", "[N]", "..." in order from the
+outside in) and the named type they wrap, qualified by its package's
+import path - `ctxpkg.Context` under `import ctxpkg "context"` is
+context.Context, a bare `User` is the User of the declaring package.
+Types that name no single type (maps, funcs, channels, struct and
+interface literals) keep their text, whitespace normalized.
+
+A type pattern is matched against those normalized types:
+
+    User           User of any package, behind any wrappers: User, *User,
+                   []*User and ...User all match
+    *User          a pointer to User exactly; []User, []*User, ...User,
+                   [4]User likewise
+    store.User     User of a package whose import path is or ends with
+                   store ("example.com/app/store"); the full import path
+                   works too ("example.com/app/store.User")
+    _    *         any type; *_ any pointer, []_ any slice, sql._ any
+                   type of package sql
+    map[string]any other types match their text
+
+Parameter and result patterns are matched independently of position, each
+by a different parameter (or result).
+"""
+
+import os
+import re
+from typing import Any, Dict, Iterable, List, Optional, Tuple
+
+from xray.core.go_analysis import GoProject
+from xray.core.go_helpers import file_imports
+from xray.core.go_tests import is_test_file
+
+_SPACE = re.compile(r"\s+")
+_WRAPPER = re.compile(r"^(\*|\[\]|\[[^\]]*\]|\.\.\.)")
+_NAMED = re.compile(r"^(?:([A-Za-z_][\w./-]*)\.)?([A-Za-z_]\w*)(\[.*\])?$")
+_PREDECLARED = {
+    "any", "bool", "byte", "comparable", "complex64", "complex128", "error", "float32", "float64", "int",
+    "int8", "int16", "int32", "int64", "rune", "string", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+}
+_ANY = "_"
+
+# A normalized type: (wrappers, package import path or None, name or None, text)
+NormType = Tuple[Tuple[str, ...], Optional[str], Optional[str], str]
+
+
+def _peel(text: str) -> Tuple[Tuple[str, ...], str]:
+    wrappers = []
+    while True:
+        match = _WRAPPER.match(text)
+        if not match:
+            return tuple(wrappers), text
+        wrappers.append("[N]" if match.group(1) not in ("*", "[]", "...") else match.group(1))
+        text = text[match.end():]
+
+
+def normalize_type(text: str, imports: Dict[str, str], package: Optional[str],
+                   type_params: Iterable[str] = ()) -> NormType:
+    """
+    A type as written in a file, normalized; imports maps the file's import
+    names to their paths, package is the declaring package's import path
+    and type_params the type parameters in scope (never qualified).
+    """
+    text = _SPACE.sub(" ", text.strip())
+    compact = text.replace(" ", "")
+    wrappers, rest = _peel(compact)
+    named = _NAMED.match(rest)
+    if not named:
+        return wrappers, None, None, text
+    qualifier, name = named.group(1), named.group(2)
+    if qualifier is not None:
+        path = imports.get(qualifier, qualifier)
+    elif name in _PREDECLARED or name in type_params:
+        path = None
+    else:
+        path = package
+    return wrappers, path, name, text
+
+
+class TypePattern:
+    """One type pattern of a signature search (see the module doc)."""
+
+    def __init__(self, pattern: str):
+        self.pattern = pattern.strip()
+        if not self.pattern:
+            raise ValueError("A type pattern cannot be empty")
+        compact = _SPACE.sub("", self.pattern)
+        if compact in ("*", _ANY):
+            self.wrappers: Optional[Tuple[str, ...]] = None
+            rest = _ANY
+        else:
+            wrappers, rest = _peel(compact)
+            # No wrappers given: User matches *User and []User as well
+            self.wrappers = wrappers or None
+            if not rest:
+                rest = _ANY
+        named = _NAMED.match(rest)
+        self.text = None if named else _SPACE.sub(" ", self.pattern)
+        self.package = named.group(1) if named else None
+        self.name = named.group(2) if named else None
+        self.args = named.group(3) if named else None
+
+    def matches(self, norm: NormType) -> bool:
+        wrappers, package, name, text = norm
+        if self.text is not None:
+            return self.text.replace(" ", "") == text.replace(" ", "")
+        if self.wrappers is not None and self.wrappers != wrappers:
+            return False
+        if self.name != _ANY and self.name != name:
+            return False
+        if self.name == _ANY and self.package is None:
+            return True
+        if self.package is not None:
+            if package is None or not (package == self.package or package.endswith("/" + self.package)):
+                return False
+        if self.args is not None:
+            return text.replace(" ", "").endswith(self.args)
+        return True
+
+
+def _assign(patterns: List[TypePattern], types: List[NormType], taken: Optional[set] = None) -> bool:
+    """Whether each pattern matches a different one of types."""
+    if not patterns:
+        return True
+    taken = taken or set()
+    first, rest = patterns[0], patterns[1:]
+    for position, norm in enumerate(types):
+        if position not in taken and first.matches(norm) and _assign(rest, types, taken | {position}):
+            return True
+    return False
+
+
+class SignatureIndex:
+    """The normalized signatures of every Go function and method of a GoProject."""
+
+    def __init__(self, project: GoProject):
+        self.records: List[Dict[str, Any]] = []
+        for path, parsed in sorted(project.files.items()):
+            imports = file_imports(parsed)
+            package = project.import_path(os.path.dirname(path)) or parsed.get("package", "")
+            for symbol in parsed["symbols"]:
+                if symbol["type"] not in ("function", "method"):
+                    continue
+                self.records.append(self._record(path, symbol, imports, package))
+
+    @staticmethod
+    def _record(path: str, symbol: Dict[str, Any], imports: Dict[str, str], package: str) -> Dict[str, Any]:
+        type_params = {p["name"] for p in symbol.get("typeParams") or [] if isinstance(p, dict) and p.get("name")}
+        receiver = symbol.get("receiver")
+        owner = (receiver or {}).get("type") or symbol.get("container")
+
+        def norm(text: str) -> NormType:
+            return normalize_type(text, imports, package, type_params)
+
+        params = [norm(p["type"]) for p in symbol.get("params", [])]
+        record = {
+            "symbol": f"{owner}.{symbol['name']}" if owner else symbol["name"],
+            "kind": symbol["type"],
+            "signature": symbol.get("signature"),
+            "package": package,
+            "path": path,
+            "line": symbol["start_line"],
+            "exported": symbol["name"][:1].isupper(),
+            "params": params,
+            "results": [norm(r["type"]) for r in symbol.get("results", [])],
+            "variadic": bool(params) and params[-1][0][:1] == ("...",),
+        }
+        if receiver:
+            record["receiver"] = normalize_type(("*" if receiver.get("pointer") else "") + receiver["type"],
+                                                {}, package)
+        elif symbol.get("container"):
+            # An interface method spec: its interface is the receiver
+            record["receiver"] = normalize_type(symbol["container"], {}, package)
+            record["interface"] = symbol["container"]
+        if is_test_file(path):
+            record["test"] = True
+        return record
+
+    def search(self, params: Optional[List[str]] = None, returns: Optional[List[str]] = None,
+               receiver: Optional[str] = None, kind: Optional[str] = None, variadic: Optional[bool] = None,
+               min_params: Optional[int] = None, max_params: Optional[int] = None,
+               exact_params: bool = False, exact_returns: bool = False,
+               include_tests: bool = True) -> List[Dict[str, Any]]:
+        """
+        The functions and methods matching every criterion given, by package,
+        file and line.
+
+        Args:
+            params: Type patterns each matching a different parameter
+            returns: Type patterns each matching a different result
+            receiver: Type pattern of the method receiver (the interface, for a method spec)
+            kind: "func" (no receiver), "method", or None for both
+            variadic: Only variadic (True) or non-variadic (False) ones
+            min_params: At least this many parameters
+            max_params: At most this many parameters
+            exact_params: params lists every parameter, no others allowed
+            exact_returns: returns lists every result, no others allowed
+            include_tests: Also functions of _test.go files
+        """
+        if kind not in (None, "func", "method"):
+            raise ValueError("kind must be func or method")
+        if min_params is not None and max_params is not None and min_params > max_params:
+            raise ValueError("min_params is greater than max_params")
+        param_patterns = [TypePattern(p) for p in params or []]
+        result_patterns = [TypePattern(r) for r in returns or []]
+        receiver_pattern = TypePattern(receiver) if receiver else None
+        if receiver_pattern is not None and kind is None:
+            kind = "method"
+        if receiver_pattern is not None and kind == "func":
+            raise ValueError("A function has no receiver; use kind method")
+
+        matches = []
+        for record in self.records:
+            if kind == "func" and record["kind"] != "function" or kind == "method" and record["kind"] != "method":
+                continue
+            if not include_tests and record.get("test"):
+                continue
+            count = len(record["params"])
+            if min_params is not None and count < min_params or max_params is not None and count > max_params:
+                continue
+            if variadic is not None and record["variadic"] != variadic:
+                continue
+            if exact_params and count != len(param_patterns):
+                continue
+            if exact_returns and len(record["results"]) != len(result_patterns):
+                continue
+            if receiver_pattern is not None and not receiver_pattern.matches(record["receiver"]):
+                continue
+            if not _assign(param_patterns, record["params"]) or not _assign(result_patterns, record["results"]):
+                continue
+            matches.append({key: value for key, value in record.items()
+                            if key not in ("params", "results", "receiver")})
+        matches.sort(key=lambda m: (m["package"], m["path"], m["line"]))
+        return matches
@@ -0,0 +1,695 @@
+"""Go text/template and html/template templates, and the Go values they are executed with.
+
+TemplateIndex reads a project's templates from two places: template files
+(.tmpl, .gotmpl, .gohtml, ...) anywhere in the tree, along with the other
+files ParseFiles, ParseGlob and ParseFS name (an .html page), and string
+literals handed to Parse in Go code -
+`template.Must(template.New("page").Parse(`...`))`.
+
+Each template - the body of a file or string, and every {{define}} and
+{{block}} in it - lists the templates it invokes ({{template "row" .Item}}),
+the functions its pipelines call (builtins, ones registered with Funcs,
+the field of {{call .Fn}}), and the fields and methods it reads. A field is
+read relative to the dot where it is written, so
+`{{range .Users}}{{.Email}}{{end}}` reads Users[].Email.
+
+On the Go side, a template value is followed from where it is made - a
+variable or struct field assigned from template.New, Must or ParseFiles,
+with the Parse calls made on it - to its Execute and ExecuteTemplate calls.
+The type of the data argument at such a call is the executed template's
+dot, and a {{template}} invocation hands on the type of its argument. Field
+paths are resolved against those types, through embedded fields, so a
+template reading `.Email` of a User uses User.Email - and a path the type
+does not have is reported as missing: executing the template fails there.
+"""
+
+import fnmatch
+import os
+import re
+from typing import Any, Callable, Dict, Iterable, Iterator, List, Optional, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+from xray.core.go_parser import unquote
+from xray.core.source_text import source_lines
+
+TEMPLATE_EXTENSIONS = (".tmpl", ".tpl", ".gotmpl", ".gohtml", ".gotxt", ".gtpl")
+TEMPLATE_PACKAGES = ("text/template", "html/template")
+
+BUILTINS = {"and", "call", "html", "index", "slice", "js", "len", "not", "or", "print", "printf", "println",
+            "urlquery", "eq", "ne", "lt", "le", "gt", "ge"}
+_KEYWORDS = {"if", "else", "range", "with", "end", "define", "block", "template", "break", "continue",
+             "nil", "true", "false"}
+# Methods of a template value that parse more templates into it
+_PARSE = {"Parse", "ParseFiles", "ParseGlob", "ParseFS"}
+
+_TOKEN = re.compile(r'"(?:[^"\\]|\\.)*"|`[^`]*`|\'(?:[^\'\\]|\\.)*\'|-?\d[\w.+-]*|\$\w*(?:\.\w+)*|(?:\.\w+)+|\.'
+                    r'|[A-Za-z_]\w*(?:\.\w+)*|:=|[=|(),]|\S')
+_FUNC_KEY = re.compile(r'"(\w+)"\s*:')
+_ASSIGNED = re.compile(r"([A-Za-z_][\w.]*)\s*=\s*[\w.]*$")
+
+Key = Tuple[Any, ...]
+# A type as (package directory it is written in, type expression)
+TypeRef = Tuple[str, str]
+
+
+def is_template_file(name: str) -> bool:
+    return name.endswith(TEMPLATE_EXTENSIONS)
+
+
+def actions(text: str) -> Iterator[Tuple[int, str, bool]]:
+    """The {{...}} actions of a template: (offset, inner text, whether text other than space precedes it)."""
+    i = 0
+    while True:
+        start = text.find("{{", i)
+        if start < 0:
+            return
+        gap = text[i:start].strip()
+        j, quote = start + 2, None
+        while j < len(text):
+            char = text[j]
+            if quote:
+                if char == "\\" and quote != "`":
+                    j += 1
+                elif char == quote:
+                    quote = None
+            elif char in "\"`'":
+                quote = char
+            elif text.startswith("}}", j):
+                break
+            j += 1
+        inner = text[start + 2:j]
+        inner = inner[1:] if inner.startswith("-") else inner
+        inner = inner[:-1] if inner.endswith("-") else inner
+        yield start, inner.strip(), bool(gap)
+        i = j + 2
+
+
+def render_path(path: List[str]) -> str:
+    """A field path as the template reads it: ["Users", "[]", "Email"] -> "Users[].Email"."""
+    text = ""
+    for step in path:
+        text += step if step == "[]" else ("." if text else "") + step
+    return text
+
+
+def _unit(name: str, line: int, kind: str) -> Dict[str, Any]:
+    return {"name": name, "kind": kind, "line": line, "fields": [], "functions": [], "invokes": [], "text": False}
+
+
+def parse_template(text: str, name: str, line: int = 1, kind: str = "file") -> Tuple[List[Dict[str, Any]], List[str]]:
+    """
+    The templates of one file or string - its body, named name, then each
+    {{define}} and {{block}} - and its syntax errors.
+    """
+    body = _unit(name, line, kind)
+    units = [body]
+    errors: List[str] = []
+    stack: List[Dict[str, Any]] = [{"dot": [], "vars": {"$": []}, "unit": body}]
+
+    def path_of(token: str, scope: Dict[str, Any]) -> Optional[List[str]]:
+        if token.startswith("."):
+            if scope["dot"] is None or token == ".":
+                return scope["dot"]
+            return scope["dot"] + token[1:].split(".")
+        if token.startswith("$"):
+            variable, _, rest = token.partition(".")
+            base = scope["vars"].get(variable)
+            return None if base is None else base + (rest.split(".") if rest else [])
+        return None
+
+    def pipe_path(words: List[str], scope: Dict[str, Any]) -> Optional[List[str]]:
+        return path_of(words[0], scope) if len(words) == 1 else None
+
+    def read(tokens: List[Tuple[str, int]], scope: Dict[str, Any], at: int, inner: str):
+        unit = scope["unit"]
+        for index, (token, offset) in enumerate(tokens):
+            if token.startswith((".", "$")) and token not in (".", "$"):
+                if token.startswith(".") and offset and inner[offset - 1] == ")":
+                    # A field of a parenthesized value: (index .M "k").Name
+                    continue
+                path = path_of(token, scope)
+                if token.startswith("$") and "." not in token:
+                    continue
+                field = {"ref": token, "path": path, "line": at}
+                if index and tokens[index - 1][0] == "call":
+                    field["call"] = True
+                unit["fields"].append(field)
+            elif re.match(r"[A-Za-z_]", token) and token not in _KEYWORDS:
+                unit["functions"].append({"name": token.split(".")[0], "line": at})
+
+    for offset, inner, gap in actions(text):
+        at = line + text.count("\n", 0, offset)
+        if len(stack) == 1 and gap:
+            body["text"] = True
+        if inner.startswith("/*"):
+            continue
+        tokens = [(m.group(), m.start()) for m in _TOKEN.finditer(inner)]
+        words = [token for token, _ in tokens]
+        if not words:
+            continue
+        scope = stack[-1]
+        head = words[0]
+        if len(stack) == 1 and head not in ("define", "end"):
+            body["text"] = True
+        if head == "end":
+            if len(stack) > 1:
+                stack.pop()
+            else:
+                errors.append(f"line {at}: unexpected {{{{end}}}}")
+            continue
+        if head == "else":
+            outer = stack[-2] if len(stack) > 1 else scope
+            scope["dot"], scope["vars"] = outer["dot"], dict(outer["vars"])
+            tokens, words = tokens[1:], words[1:]
+            if not words:
+                continue
+            head = words[0]
+            read(tokens[1:], scope, at, inner)
+            if head == "with":
+                scope["dot"] = pipe_path(words[1:], scope)
+            continue
+        if head in ("define", "block", "template"):
+            if len(words) < 2 or not words[1].startswith(('"', "`")):
+                errors.append(f"line {at}: {{{{{head}}}}} without a template name")
+                continue
+            named = unquote(words[1])
+            if head != "define":
+                read(tokens[2:], scope, at, inner)
+                scope["unit"]["invokes"].append({"name": named, "argument": " ".join(words[2:]) or None,
+                                                 "path": pipe_path(words[2:], scope) if words[2:] else None,
+                                                 "line": at})
+            if head != "template":
+                unit = _unit(named, at, head)
+                units.append(unit)
+                stack.append({"dot": [], "vars": {"$": []}, "unit": unit})
+            continue
+        if head in ("if", "range", "with"):
+            pipe, declared = words[1:], []
+            if ":=" in pipe or "=" in pipe:
+                split = pipe.index(":=") if ":=" in pipe else pipe.index("=")
+                declared = [w for w in pipe[:split] if w.startswith("$")]
+                pipe = pipe[split + 1:]
+            read(tokens[1:], scope, at, inner)
+            path = pipe_path(pipe, scope)
+            dot = scope["dot"]
+            variables = dict(scope["vars"])
+            if head == "range":
+                element = path + ["[]"] if path is not None else None
+                dot = element
+                if declared:
+                    variables[declared[-1]] = element
+                    if len(declared) > 1:
+                        variables[declared[0]] = None
+            elif head == "with":
+                dot = path
+                if declared:
+                    variables[declared[0]] = path
+            elif declared:
+                variables[declared[0]] = path
+            stack.append({"dot": dot, "vars": variables, "unit": scope["unit"]})
+            continue
+        if head in ("break", "continue"):
+            continue
+        if len(words) > 2 and words[0].startswith("$") and words[1] in (":=", "="):
+            scope["vars"][words[0]] = pipe_path(words[2:], scope)
+            read(tokens[2:], scope, at, inner)
+            continue
+        read(tokens, scope, at, inner)
+    if text[text.rfind("}}") + 2:].strip() if "}}" in text else text.strip():
+        body["text"] = True
+    if len(stack) > 1:
+        errors.append(f"unclosed action in {stack[-1]['unit']['name']}")
+    if not body["text"] and len(units) > 1:
+        # Only {{define}}s: the body is empty, and a define of its name replaces it
+        units.remove(body)
+    return units, errors
+
+
+def _element(text: str) -> Optional[str]:
+    """The element type of a slice, array or map type expression."""
+    text = text.strip().lstrip("*")
+    if text.startswith("map["):
+        depth = 0
+        for pos, char in enumerate(text):
+            depth += {"[": 1, "]": -1}.get(char, 0)
+            if char == "]" and depth == 0:
+                return text[pos + 1:]
+        return None
+    if text.startswith("["):
+        return text[text.index("]") + 1:]
+    return None
+
+
+class TemplateIndex:
+    """The templates of a call graph's project and of template files, linked to their Execute calls."""
+
+    def __init__(self, graph: GoCallGraph, root: str, read: Callable[[str], Optional[str]], files: Iterable[str]):
+        """
+        files are the candidate template files of the tree: any with a
+        template extension is read, other ones (.html) when a Parse call
+        names them.
+        """
+        self.graph = graph
+        self.project = graph.project
+        self.root = root
+        self.read = read
+        self.candidates = sorted(files)
+        # Set key -> {"engine", "root", "strings", "patterns", "funcs", "created"}
+        self.sets: Dict[Key, Dict[str, Any]] = {}
+        self.sites: List[Dict[str, Any]] = []
+        self.errors: List[Dict[str, Any]] = []
+        self._file_units: Dict[str, List[Dict[str, Any]]] = {}
+        self._scan()
+
+    # ------------------------------------------------------------------
+    # Go: where templates are made and executed
+    # ------------------------------------------------------------------
+
+    @staticmethod
+    def _function(func: Optional[Dict[str, Any]]) -> Optional[str]:
+        if func is None:
+            return None
+        return f"{func['receiver']}.{func['name']}" if func.get("receiver") else func["name"]
+
+    def _named_key(self, path: str, func: Optional[Dict[str, Any]], name: str) -> Key:
+        if func is not None and name in func.get("locals", {}):
+            return ("local", path, func["start_line"], name)
+        return ("var", os.path.dirname(path), name)
+
+    def _field_key(self, path: str, func: Optional[Dict[str, Any]], chain: List[str]) -> Optional[Key]:
+        field = self.graph.field_of(path, func, chain) if func is not None else None
+        if field is None:
+            return None
+        return ("field", os.path.dirname(field["path"]), f"{field['container']}.{field['name']}")
+
+    def _target(self, path: str, func: Optional[Dict[str, Any]], call: Dict[str, Any]) -> Key:
+        """The variable or field a statement making a template assigns it to, or the statement itself."""
+        assigned = [name for name in call.get("assigned_to") or [] if name != "_"]
+        if assigned:
+            return self._named_key(path, func, assigned[0])
+        line = (source_lines(self.read(path) or "") or [""])[call["line"] - 1:call["line"]]
+        match = _ASSIGNED.search(line[0][:max(call["column"] - 1, 0)]) if line else None
+        if match:
+            chain = match.group(1).split(".")
+            if len(chain) == 1:
+                return self._named_key(path, func, chain[0])
+            key = self._field_key(path, func, chain)
+            if key is not None:
+                return key
+        return ("site", path, call["line"])
+
+    def _receiver_set(self, path: str, func: Optional[Dict[str, Any]], receiver: List[str],
+                      packages: Dict[str, str]) -> Optional[Key]:
+        """The template set a receiver chain holds, when it is one this index knows."""
+        if not receiver or receiver[0] in packages and receiver[0] not in (func or {}).get("locals", {}):
+            return None
+        # t.New("row").Parse(...): the methods called on a template keep to its set
+        head = receiver[:receiver.index("()") - 1] if "()" in receiver else receiver
+        if len(head) == 1:
+            key = self._named_key(path, func, head[0])
+            return key if key in self.sets else None
+        if len(head) == 2 and func is not None:
+            key = self._field_key(path, func, head)
+            return key if key in self.sets else None
+        return None
+
+    def _set(self, key: Key, engine: Optional[str], path: str, line: int) -> Dict[str, Any]:
+        entry = self.sets.setdefault(key, {"engine": engine, "root": None, "strings": [], "patterns": [],
+                                           "funcs": set(), "created": []})
+        if engine and not entry["engine"]:
+            entry["engine"] = engine
+        if {"path": path, "line": line} not in entry["created"]:
+            entry["created"].append({"path": path, "line": line})
+        return entry
+
+    @staticmethod
+    def _strings(call: Dict[str, Any]) -> List[str]:
+        return [arg["value"] for arg in call.get("args") or [] if isinstance(arg, dict)
+                and isinstance(arg.get("value"), str)]
+
+    def _template_name(self, calls: List[Dict[str, Any]], call: Dict[str, Any]) -> Optional[str]:
+        """The name a Parse call's template gets from a New earlier in its chain: New("row").Parse(...)."""
+        chain = call["chain"]
+        news = [i for i, element in enumerate(chain[:-1]) if element == "New"]
+        if not news:
+            return None
+        prefix = chain[:news[-1] + 1]
+        for other in calls:
+            if other["chain"] == prefix and abs(other["line"] - call["line"]) <= 5:
+                names = self._strings(other)
+                return names[0] if names else None
+        return None
+
+    def _making(self, path: str, func: Optional[Dict[str, Any]], calls: List[Dict[str, Any]],
+                packages: Dict[str, str]):
+        """Record the template sets a function (or the package scope) makes and parses into."""
+        statement: List[Tuple[Dict[str, Any], Key]] = []
+        for call in calls:
+            chain = call["chain"]
+            method = chain[-1]
+            engine = None
+            if chain[0] in packages and chain[0] not in (func or {}).get("locals", {}):
+                engine = packages[chain[0]]
+                key = None
+                if not call.get("assigned_to"):
+                    # Nested in the statement before: template.Must(template.ParseFiles(...))
+                    for earlier, earlier_key in statement:
+                        if any(text.startswith(chain[0] + ".") and f"{method}(" in text
+                               for text in earlier.get("arg_texts", [])):
+                            key = earlier_key
+                            break
+                if key is None:
+                    key = self._target(path, func, call)
+                    statement = []
+                statement.append((call, key))
+            else:
+                key = self._receiver_set(path, func, chain[:-1], packages)
+                if key is None or method not in _PARSE and method not in ("New", "Funcs"):
+                    continue
+            entry = self._set(key, engine, path, call["line"])
+            strings = self._strings(call)
+            if method == "New" and strings and engine is not None and len(chain) == 2 and entry["root"] is None:
+                entry["root"] = strings[0]
+            elif method == "Parse" and strings:
+                name = self._template_name(calls, call) or entry["root"] or key[-1]
+                entry["strings"].append({"text": strings[0], "name": name, "path": path, "line": call["line"]})
+            elif method in ("ParseFiles", "ParseGlob", "ParseFS"):
+                entry["patterns"].extend((pattern, path) for pattern in strings)
+                if method == "ParseFiles" and strings and entry["root"] is None:
+                    # Named after its first file; a glob's first match is only known once files are read
+                    entry["root"] = os.path.basename(strings[0])
+            elif method == "Funcs":
+                entry["funcs"].update(name for text in call.get("arg_texts", []) for name in _FUNC_KEY.findall(text))
+
+    def _data_type(self, path: str, func: Dict[str, Any], source: Optional[Dict[str, Any]]) -> Optional[TypeRef]:
+        if not source or source.get("nil"):
+            return None
+        named = self.graph.type_of(path, func, source)
+        text = self.graph.value_type(path, func, source)
+        if text and text.lstrip("*").startswith(("[", "map[")):
+            return os.path.dirname(path), text
+        if named is not None and named[0] == "project":
+            return named[1], named[2]
+        return None
+
+    def _executing(self, path: str, func: Dict[str, Any], call: Dict[str, Any], packages: Dict[str, str],
+                   callee: Optional[Tuple[str, str]]):
+        chain = call["chain"]
+        method, receiver = chain[-1], chain[:-1]
+        args = call.get("args") or []
+        key = self._receiver_set(path, func, receiver, packages)
+        if key is None:
+            typed = (callee is not None and callee[0] == "" and callee[1].startswith(TEMPLATE_PACKAGES)) or \
+                "template.Template" in (self.graph.value_type(path, func, {"chain": receiver}) or "")
+            named = method == "ExecuteTemplate" and len(args) == 3 and len(self._strings({"args": args[1:2]})) == 1
+            if not typed and not named:
+                return
+        name = None
+        if method == "ExecuteTemplate":
+            names = self._strings({"args": args[1:2]})
+            name = names[0] if names else None
+        data = self._data_type(path, func, args[-1] if len(args) >= 2 else None)
+        self.sites.append({"path": path, "line": call["line"], "column": call["column"],
+                           "function": self._function(func), "method": method, "set": key, "template": name,
+                           "data": data, "argument": (call.get("arg_texts") or [""])[-1]})
+
+    def _scan(self):
+        sites = {(e["path"], e["line"], e["column"]): e["callee"] for e in self.graph.edges if e["kind"] != "reference"}
+        scopes = []
+        for path, parsed in sorted(self.project.files.items()):
+            packages = {imp["name"]: imp["path"] for imp in parsed.get("imports", [])
+                        if imp.get("name") and imp["path"] in TEMPLATE_PACKAGES}
+            scopes.append((path, None, parsed.get("package_calls", []), packages))
+            scopes.extend((path, func, func.get("calls", []), packages) for func in parsed.get("functions", []))
+        for path, func, calls, packages in scopes:
+            self._making(path, func, calls, packages)
+        for path, func, calls, packages in scopes:
+            if func is None:
+                continue
+            for call in calls:
+                if call["chain"][-1] in ("Execute", "ExecuteTemplate") and len(call["chain"]) > 1:
+                    self._executing(path, func, call, packages, sites.get((path, call["line"], call["column"])))
+
+    # ------------------------------------------------------------------
+    # Templates
+    # ------------------------------------------------------------------
+
+    def _units_of_file(self, path: str) -> List[Dict[str, Any]]:
+        if path not in self._file_units:
+            content = self.read(path)
+            units: List[Dict[str, Any]] = []
+            if content is not None:
+                units, errors = parse_template(content, os.path.basename(path))
+                self.errors.extend({"path": path, "error": error} for error in errors)
+            for unit in units:
+                unit["path"] = path
+            self._file_units[path] = units
+        return self._file_units[path]
+
+    def _matches(self, pattern: str, go_path: str) -> List[str]:
+        """The candidate files a ParseFiles path or ParseGlob pattern names, from the root or the package."""
+        pattern = pattern[2:] if pattern.startswith("./") else pattern
+        found = []
+        for path in self.candidates:
+            relative = [os.path.relpath(path, self.root), os.path.relpath(path, os.path.dirname(go_path))]
+            if any(fnmatch.fnmatchcase(r, pattern) or fnmatch.fnmatchcase(r, "*/" + pattern) for r in relative):
+                found.append(path)
+        return found
+
+    def templates(self) -> Tuple[Dict[Key, Dict[str, Dict[str, Any]]], List[Dict[str, Any]]]:
+        """Each set's templates by name, and every template once (string ones first by set, then files)."""
+        by_set: Dict[Key, Dict[str, Dict[str, Any]]] = {}
+        every: Dict[Tuple[str, int, str], Dict[str, Any]] = {}
+        in_sets = set()
+        for key, entry in self.sets.items():
+            named = by_set.setdefault(key, {})
+            for pattern, go_path in entry["patterns"]:
+                for path in self._matches(pattern, go_path):
+                    in_sets.add(path)
+                    for unit in self._units_of_file(path):
+                        named.setdefault(unit["name"], unit)
+                        unit.setdefault("sets", []).append(key)
+                    if entry["root"] is None:
+                        entry["root"] = os.path.basename(path)
+            for text in entry["strings"]:
+                units, errors = parse_template(text["text"], text["name"], text["line"], "string")
+                self.errors.extend({"path": text["path"], "line": text["line"], "error": e} for e in errors)
+                for unit in units:
+                    unit["path"] = text["path"]
+                    unit["sets"] = [key]
+                    named[unit["name"]] = unit
+            for unit in named.values():
+                unit["engine"] = unit.get("engine") or entry["engine"]
+                unit.setdefault("funcs", set()).update(entry["funcs"])
+        for path in self.candidates:
+            if path in in_sets or not is_template_file(path):
+                continue
+            key = ("file", path)
+            named = by_set.setdefault(key, {})
+            for unit in self._units_of_file(path):
+                named.setdefault(unit["name"], unit)
+                unit.setdefault("sets", []).append(key)
+        for named in by_set.values():
+            for unit in named.values():
+                every.setdefault((unit["path"], unit["line"], unit["name"]), unit)
+        return by_set, sorted(every.values(), key=lambda u: (u["path"], u["line"], u["kind"] != "file"))
+
+    # ------------------------------------------------------------------
+    # Types
+    # ------------------------------------------------------------------
+
+    def _type_name(self, ref: TypeRef) -> str:
+        directory, text = ref
+        key = self.project.resolve_type_ref(directory, text.strip().lstrip("*"))
+        if key is None:
+            return text
+        package = self.project.packages.get(key[0], {}).get("name")
+        return f"{package}.{key[1]}" if package else key[1]
+
+    def step(self, ref: TypeRef, name: str) -> Tuple[Optional[Dict[str, Any]], Optional[TypeRef], str]:
+        """
+        One step along a field path: (the member read, the type it
+        gives, how) - "member", "element" for a range, "opaque" for a type
+        whose members cannot be told (a map key, an interface, an outside
+        type), "missing" when the type has no such field or method.
+        """
+        directory, text = ref
+        text = text.strip().lstrip("*")
+        if name == "[]":
+            element = _element(text)
+            return None, ((directory, element) if element else None), "element"
+        if text.startswith("map["):
+            return None, None, "opaque"
+        key = self.project.resolve_type_ref(directory, text)
+        if key is None or (self.project.types.get(key) or {}).get("type") != "struct":
+            return None, None, "opaque"
+        members = self.project.member_set(key[0], key[1], pointer=True)
+        if name in members["ambiguous"]:
+            return None, None, "opaque"
+        field = members["fields"].get(name)
+        method = members["methods"].get(name)
+        record = field if field is not None else method
+        if record is None:
+            return None, None, "missing"
+        owner = field["container"] if field is not None else method["receiver"]["type"]
+        member = {"member": f"{owner}.{name}", "kind": "field" if field is not None else "method",
+                  "path": record["path"], "line": record["start_line"]}
+        if owner != key[1]:
+            # Promoted through an embedded field: read as User.Email, declared as Contact.Email
+            member["read_as"] = f"{key[1]}.{name}"
+        if field is not None:
+            return member, (os.path.dirname(field["path"]), field["field_type"]), "member"
+        results = method.get("results") or []
+        return member, ((os.path.dirname(method["path"]), results[0]["type"]) if results else None), "member"
+
+    def resolve(self, ref: TypeRef, path: List[str]) -> Tuple[List[Dict[str, Any]], Optional[TypeRef], Optional[str]]:
+        """The members a field path reads from a type, the type it ends in, and its first missing step."""
+        members = []
+        current: Optional[TypeRef] = ref
+        for index, name in enumerate(path):
+            if current is None:
+                return members, None, None
+            member, current, how = self.step(current, name)
+            if how == "missing":
+                return members, None, render_path(path[:index + 1])
+            if member is not None:
+                members.append(member)
+        return members, current, None
+
+    def bindings(self, by_set: Dict[Key, Dict[str, Dict[str, Any]]]) -> Dict[int, List[Dict[str, Any]]]:
+        """The types each template is executed with (by id of the template), following {{template}} calls."""
+        bound: Dict[int, List[Dict[str, Any]]] = {}
+        queue: List[Tuple[Dict[str, Any], Key, TypeRef, Dict[str, Any]]] = []
+
+        def bind(unit, key, ref, via):
+            if any(b["ref"] == ref for b in bound.get(id(unit), [])):
+                return
+            bound.setdefault(id(unit), []).append({"ref": ref, "via": via})
+            queue.append((unit, key, ref, via))
+
+        for site in self.sites:
+            if site["data"] is None or site["set"] is None and site["template"] is None:
+                continue
+            via = {"path": site["path"], "line": site["line"], "function": site["function"]}
+            keys = [site["set"]] if site["set"] is not None else list(by_set)
+            for key in keys:
+                named = by_set.get(key, {})
+                name = site["template"] or self.sets.get(key, {}).get("root")
+                if name in named:
+                    bind(named[name], key, site["data"], via)
+        while queue:
+            unit, key, ref, via = queue.pop(0)
+            for invoke in unit["invokes"]:
+                target = by_set.get(key, {}).get(invoke["name"])
+                if target is None or invoke["path"] is None:
+                    continue
+                _, passed, missing = self.resolve(ref, invoke["path"])
+                if passed is not None and not missing:
+                    bind(target, key, passed, {"path": unit["path"], "line": invoke["line"],
+                                               "template": unit["name"]})
+        return bound
+
+    # ------------------------------------------------------------------
+    # Report
+    # ------------------------------------------------------------------
+
+    def _entry(self, unit: Dict[str, Any], bound: List[Dict[str, Any]]) -> Dict[str, Any]:
+        functions: Dict[str, Dict[str, Any]] = {}
+        funcs = unit.get("funcs", set())
+        for function in unit["functions"]:
+            kind = "builtin" if function["name"] in BUILTINS else \
+                "registered" if function["name"] in funcs else "unknown"
+            functions.setdefault(function["name"], {"name": function["name"], "kind": kind,
+                                                    "line": function["line"]})
+        uses: Dict[str, Dict[str, Any]] = {}
+        missing: List[Dict[str, Any]] = []
+        for binding in bound:
+            for field in unit["fields"]:
+                if field["path"] is None:
+                    continue
+                members, _, gap = self.resolve(binding["ref"], field["path"])
+                for member in members:
+                    use = uses.setdefault(member["member"], {
+                        **{k: v for k, v in member.items() if k != "read_as"},
+                        "package": self.project.packages.get(os.path.dirname(member["path"]), {}).get("name", ""),
+                        "lines": []})
+                    if member.get("read_as") and member["read_as"] not in use.setdefault("read_as", []):
+                        use["read_as"].append(member["read_as"])
+                    if field["line"] not in use["lines"]:
+                        use["lines"].append(field["line"])
+                if gap is not None:
+                    problem = {"ref": field["ref"], "type": self._type_name(binding["ref"]), "missing": gap,
+                               "line": field["line"]}
+                    if problem not in missing:
+                        missing.append(problem)
+        fields = []
+        for field in unit["fields"]:
+            entry = {"ref": field["ref"], "line": field["line"]}
+            if field["path"] is not None:
+                entry["path"] = render_path(field["path"])
+            if field.get("call"):
+                entry["call"] = True
+            if entry not in fields:
+                fields.append(entry)
+        return {
+            "name": unit["name"],
+            "kind": unit["kind"],
+            "path": unit["path"],
+            "line": unit["line"],
+            "engine": unit.get("engine"),
+            "invokes": [{"name": i["name"], "argument": i["argument"], "line": i["line"]} for i in unit["invokes"]],
+            "functions": list(functions.values()),
+            "fields": fields,
+            "executed_with": [{"type": self._type_name(b["ref"]), **b["via"]} for b in bound],
+            "uses": sorted(uses.values(), key=lambda u: u["member"]),
+            "missing": missing,
+        }
+
+    @staticmethod
+    def _matches_member(query: str, use: Dict[str, Any]) -> bool:
+        for member in (use["member"], *use.get("read_as", [])):
+            owner = member.split(".")[0]
+            if query in (member, f"{use['package']}.{member}", owner, f"{use['package']}.{owner}"):
+                return True
+        return False
+
+    def find(self, member: Optional[str] = None, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Every template with what it reads and the types it is executed
+        with, or with member ("User.Email", "store.User.Email", or a type
+        name for any of its members) the templates using it.
+
+        Args:
+            member: Only templates reading this field or method, or a member of this type
+            path: Only templates from this file or directory (and below)
+        """
+        by_set, units = self.templates()
+        bound = self.bindings(by_set)
+        entries = []
+        for unit in units:
+            if path and unit["path"] != path and not unit["path"].startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            entry = self._entry(unit, bound.get(id(unit), []))
+            if member is not None:
+                entry["uses"] = [use for use in entry["uses"] if self._matches_member(member, use)]
+                if not entry["uses"]:
+                    continue
+            entries.append(entry)
+        sites = []
+        for site in self.sites:
+            entry = {key: site[key] for key in ("path", "line", "column", "function", "method", "argument")}
+            entry["template"] = site["template"] or self.sets.get(site["set"], {}).get("root")
+            entry["data_type"] = self._type_name(site["data"]) if site["data"] else None
+            if site["set"] is None:
+                entry["set_unknown"] = True
+            sites.append(entry)
+        result: Dict[str, Any] = {
+            "templates": entries,
+            "total_count": len(entries),
+            "missing_count": sum(len(e["missing"]) for e in entries),
+            "execute_sites": sites,
+            "parseErrors": self.errors,
+        }
+        if member is not None:
+            result["query"] = member
+        return result
@@ -0,0 +1,163 @@
+"""Which Go tests exercise a symbol.
+
+The parser classifies the functions of _test.go files the way `go test`
+does - "testKind" test, benchmark, fuzz, example or main (TestMain) -
+links examples to what they document ("example_of") and describes the
+case table of table-driven tests ("table"). Tests are matched to a function
+or method two ways: by the naming conventions (TestGetUser,
+TestUserService_GetUser, BenchmarkGetUser_cached, ExampleUserService_GetUser)
+and through the call graph, walking callers from the symbol up to the test
+functions that reach it.
+"""
+
+import os
+import re
+from typing import Any, Dict, List, Optional, Tuple
+
+from xray.core.errors import SymbolNotFound
+from xray.core.go_analysis import GoCallGraph
+
+TEST_KINDS = ("test", "benchmark", "fuzz", "example", "main")
+
+# Levels of callers walked from a symbol looking for tests
+DEFAULT_DEPTH = 4
+
+_TEST_FILE_PATTERNS = (
+    re.compile(r"_test\.go$"),
+    re.compile(r"(^|/)(test_[^/]*|[^/]*_test|conftest)\.py$"),
+    re.compile(r"\.(test|spec)\.[cm]?[jt]sx?$"),
+    re.compile(r"(^|/)src/test/java/|Tests?\.java$"),
+    re.compile(r"(^|/)(tests|__tests__)/"),
+)
+
+_PREFIXES = {"test": "Test", "benchmark": "Benchmark", "fuzz": "Fuzz"}
+
+
+def is_test_file(path: str) -> bool:
+    """
+    Whether a file holds tests by its language's convention: _test.go,
+    test_*.py / *_test.py / conftest.py, *.test.ts / *.spec.js and the
+    like, Java's *Test.java and Maven's src/test/java, and anything under a
+    tests/ or __tests__/ directory.
+    """
+    path = path.replace(os.sep, "/")
+    return any(pattern.search(path) for pattern in _TEST_FILE_PATTERNS)
+
+
+def _name_matches(test_name: str, prefix: str, target: str) -> bool:
+    """
+    Whether TestX names target "F" or "T.M": X is F, M, T_M or TM, alone or
+    followed by a suffix (_case, or a capitalized word: TestGetUserMissing).
+    """
+    rest = test_name[len(prefix):].lstrip("_")
+    owner, _, member = target.rpartition(".")
+    forms = [member, f"{owner}_{member}", f"{owner}{member}"] if owner else [member]
+    for form in forms:
+        if rest == form:
+            return True
+        if rest.startswith(form):
+            following = rest[len(form)]
+            if following == "_" or following.isupper() or following.isdigit():
+                return True
+    return False
+
+
+class TestFinder:
+    """The test functions of a project, matched to the code they exercise."""
+
+    def __init__(self, graph: GoCallGraph):
+        self.graph = graph
+        # node key -> test function symbol
+        self.tests: Dict[Tuple[str, str], Dict[str, Any]] = {
+            key: symbol for key, symbol in graph.functions.items()
+            if symbol.get("testKind") and graph.nodes[key]["path"].endswith("_test.go")
+        }
+
+    def _methods(self, type_name: str, path: Optional[str]) -> List[Tuple[str, str]]:
+        """Call graph nodes of the methods of a type, in its package only."""
+        methods = [key for key, node in sorted(self.graph.nodes.items())
+                   if key[1].startswith(type_name + ".") and not node.get("interface_method")
+                   and (not path or node["path"] == path or key[0] == path.rstrip(os.sep))]
+        first = methods[0][0] if methods else None
+        return [key for key in methods if key[0] == first]
+
+    def find(self, symbol: str, path: Optional[str] = None, depth: int = DEFAULT_DEPTH) -> Dict[str, Any]:
+        """
+        Tests, benchmarks, fuzz targets and examples that exercise a function
+        or method - or, given a type, any of its methods.
+
+        Args:
+            symbol: "Func", "Type.Method" or "Type"
+            path: Optional file or package directory to disambiguate the name
+            depth: Levels of callers to walk from the symbol to a test
+
+        Returns:
+            {"symbol", "tests": [{..., "testKind", "matched_by", "call_chain"}],
+             "total_count", "counts"}; each test's "matched_by" lists "name",
+            "example" and/or "calls"
+        """
+        candidates = [key for key in self.graph.find_nodes(symbol, path) if key not in self.tests]
+        targets = candidates[:1] or self._methods(symbol, path)
+        if not targets:
+            raise SymbolNotFound(f"No Go function, method or type named '{symbol}' found")
+        target_dirs = {key[0] for key in targets}
+
+        found: Dict[Tuple[str, str], Dict[str, Any]] = {}
+
+        def entry(key: Tuple[str, str]) -> Dict[str, Any]:
+            if key not in found:
+                test = self.tests[key]
+                found[key] = {**self.graph.nodes[key], "testKind": test["testKind"], "matched_by": []}
+                if test.get("table"):
+                    found[key]["table"] = {k: test["table"][k] for k in ("variable", "cases", "subtests")}
+            return found[key]
+
+        # Naming conventions, within the package directory of the target
+        for key, test in sorted(self.tests.items()):
+            if key[0] not in target_dirs:
+                continue
+            kind = test["testKind"]
+            for target in targets:
+                if kind == "example" and test.get("example_of") in (target[1], symbol):
+                    entry(key)["matched_by"].append("example")
+                    break
+                if kind in _PREFIXES and (_name_matches(test["name"], _PREFIXES[kind], target[1])
+                                          or _name_matches(test["name"], _PREFIXES[kind], symbol)):
+                    entry(key)["matched_by"].append("name")
+                    break
+
+        # The call graph: callers of the target that are tests, with the chain between
+        for target in targets:
+            chains: Dict[Tuple[str, str], List[str]] = {target: []}
+            for level, reached_from, caller, edge in self.graph.walk(target, depth, forward=False):
+                if caller not in chains:
+                    chains[caller] = [reached_from[1]] + chains[reached_from]
+                if caller not in self.tests:
+                    continue
+                test = entry(caller)
+                if "calls" not in test["matched_by"]:
+                    test["matched_by"].append("calls")
+                    test["depth"] = level
+                    test["call_chain"] = [caller[1]] + chains[caller]
+                    test["call_site"] = {"path": edge["path"], "line": edge["line"], "column": edge["column"]}
+
+        tests = sorted(found.values(), key=lambda t: (TEST_KINDS.index(t["testKind"]), t["path"], t["line"]))
+        if candidates:
+            symbol_info = self.graph.nodes[targets[0]]
+        else:
+            symbol_info = {"name": symbol, "type": "type", "package": self.graph.nodes[targets[0]]["package"],
+                           "methods": [key[1] for key in targets]}
+        result: Dict[str, Any] = {
+            "symbol": symbol_info,
+            "tests": tests,
+            "total_count": len(tests),
+            "counts": {kind: sum(1 for t in tests if t["testKind"] == kind)
+                       for kind in TEST_KINDS if any(t["testKind"] == kind for t in tests)},
+            "depth": depth,
+        }
+        if len(candidates) > 1:
+            result["other_candidates"] = [
+                {key: self.graph.nodes[c][key] for key in ("name", "package", "path", "line")}
+                for c in candidates[1:]
+            ]
+        return result
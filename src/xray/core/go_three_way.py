@@ -0,0 +1,209 @@
+"""Go symbols changed on both sides of a fork: the semantic-conflict candidates of a merge.
+
+compare_refs reviews a branch against the point it forked off; a long-lived
+branch also has to survive what its base did in the meantime, and git only
+reports lines both sides edited. One side changing what GetUser returns
+while the other adds a caller relying on the old behavior merges cleanly -
+and breaks.
+
+ThreeWayImpact takes the symbol diffs (see xray.core.go_diff) from the merge
+base to the head and to the base tip, keys every change by its package
+directory and its name at the merge base, and pairs the two sides up:
+
+    both_changed   modified, re-signatured, removed or renamed on both sides
+    new_reference  changed on one side and referred to by a declaration the
+                   other side added or changed, which did not refer to it at
+                   the merge base: it was written against the old version
+    both_added     declared anew in the same package on both sides, a
+                   duplicate declaration once merged
+
+References are found by name in the declaration's text: the bare name within
+its own package, `.Name` elsewhere - a method, or a qualified use from a
+file importing the package. Each side of an overlap lists the commits of
+that side that changed the symbol (see xray.core.git_evolution).
+"""
+
+import posixpath
+import re
+from typing import Any, Callable, Dict, List, Optional, Tuple
+
+from xray.core.blob_cache import BlobCache
+from xray.core.git_evolution import symbol_history
+from xray.core.git_history import GitRepo, iso_date
+from xray.core.go_diff import symbol_table
+
+SIDES = ("head", "base")
+KINDS = ("both_changed", "new_reference", "both_added")
+
+# Changes to a declaration that existed at the merge base
+_CHANGED = {"modified", "signature_changed", "removed", "renamed"}
+# Changes leaving a declaration whose text can refer to others
+_WRITTEN = {"added", "modified", "signature_changed", "renamed"}
+
+Key = Tuple[str, str]
+
+
+def side_changes(symbols: Dict[str, Any]) -> Dict[Key, Dict[str, Any]]:
+    """
+    The changes of one side (an xray.core.indexer diff_symbols result) by
+    (package directory, name) as of the merge base - for an added
+    declaration, where it was added.
+    """
+    found: Dict[Key, Dict[str, Any]] = {}
+    for entry in symbols["files"]:
+        fork_path = entry.get("old_path", entry["path"])
+        for symbol in entry["symbols"]:
+            if symbol["change"] == "added":
+                key = (posixpath.dirname(entry["path"]), symbol["name"])
+            else:
+                key = (posixpath.dirname(fork_path), symbol.get("old_name", symbol["name"]))
+            found[key] = {**symbol, "path": entry["path"], "fork_path": fork_path}
+    return found
+
+
+class ThreeWayImpact:
+    """The overlaps of the changes a head and a base tip made since their merge base."""
+
+    def __init__(self, repo: GitRepo, fork: str, shas: Dict[str, str], changes: Dict[str, Dict[Key, Dict[str, Any]]],
+                 import_path: Callable[[str], Optional[str]],
+                 cache: Optional[BlobCache] = None):
+        """
+        shas and changes are by side ("head", "base"), changes as side_changes
+        returns them; import_path maps a package directory (relative to the
+        repository root) to its import path; cache is the history cache
+        symbol_history shares with the indexer (see xray.core.blob_cache).
+        """
+        self.repo = repo
+        self.fork = fork
+        self.shas = shas
+        self.changes = changes
+        self.import_path = import_path
+        self.cache = BlobCache() if cache is None else cache
+        self._contents: Dict[Tuple[str, str], Optional[str]] = {}
+        self._symbols: Dict[Tuple[str, str], Dict[str, Dict[str, Any]]] = {}
+
+    def _content(self, sha: str, path: str) -> Optional[str]:
+        if (sha, path) not in self._contents:
+            self._contents[(sha, path)] = self.repo.show(sha, path)
+        return self._contents[(sha, path)]
+
+    def _text(self, sha: str, path: str, name: str) -> Optional[str]:
+        """The source of a declaration in one version of a file, None where it is not declared."""
+        if (sha, path) not in self._symbols:
+            content = self._content(sha, path)
+            try:
+                self._symbols[(sha, path)] = symbol_table(content) if content is not None else {}
+            except Exception:
+                self._symbols[(sha, path)] = {}
+        symbol = self._symbols[(sha, path)].get(name)
+        return symbol["text"] if symbol else None
+
+    def _reference(self, key: Key, change: Dict[str, Any]) -> Callable[[str, str, str], bool]:
+        """A test of whether a declaration's text, in a file at a commit, names the changed symbol."""
+        directory, name = key
+        short = re.escape(name.split(".")[-1])
+        method = "." in name or bool(change.get("receiver"))
+        bare = re.compile(rf"(?<![\w.]){short}\b")
+        qualified = re.compile(rf"\.{short}\b")
+        imported = self.import_path(directory)
+
+        def refers(text: str, path: str, sha: str) -> bool:
+            if method:
+                return bool(qualified.search(text))
+            if posixpath.dirname(path) == directory:
+                return bool(bare.search(text))
+            if not qualified.search(text):
+                return False
+            content = self._content(sha, path) or ""
+            return imported is None or f'"{imported}"' in content
+
+        return refers
+
+    def _commits(self, side: str, change: Dict[str, Any]) -> Dict[str, Any]:
+        """The commits of one side between the merge base and its tip that changed a symbol."""
+        sha = self.shas[side]
+        window = f"{self.fork}..{sha}"
+        if change["change"] == "removed":
+            # Gone at the tip, so its history cannot be walked back from there: the file's commits
+            commits = self.repo.file_log(change["fork_path"], None, window)
+            return {"commits": [_commit(c) for c in commits], "commits_of": "file"}
+        history = symbol_history(self.repo, change["path"], change["name"], None, window, self.cache)
+        return {"commits": [{key: entry[key] for key in ("commit", "author", "date", "summary")}
+                            for entry in history["history"]]}
+
+    def _side(self, side: str, change: Dict[str, Any]) -> Dict[str, Any]:
+        entry = {"change": change["change"], "path": change["path"]}
+        if change["change"] == "renamed":
+            entry["new_name"] = change["name"]
+        for field in ("old_signature", "new_signature"):
+            if change.get(field):
+                entry[field] = change[field]
+        entry.update(self._commits(side, change))
+        return entry
+
+    def _new_references(self, changed_side: str) -> List[Dict[str, Any]]:
+        """The symbols changed on one side that the other side's new or changed code newly refers to."""
+        other = "base" if changed_side == "head" else "head"
+        sha = self.shas[other]
+        found = []
+        for key, change in sorted(self.changes[changed_side].items()):
+            if change["change"] not in _CHANGED or key in self.changes[other]:
+                continue
+            refers = self._reference(key, change)
+            referrers = []
+            for other_key, written in sorted(self.changes[other].items()):
+                if written["change"] not in _WRITTEN:
+                    continue
+                text = self._text(sha, written["path"], written["name"])
+                if text is None or not refers(text, written["path"], sha):
+                    continue
+                if written["change"] != "added":
+                    before = self._text(self.fork, written["fork_path"], other_key[1])
+                    if before is not None and refers(before, written["fork_path"], self.fork):
+                        continue
+                referrers.append(written)
+            if not referrers:
+                continue
+            commits: Dict[str, Dict[str, Any]] = {}
+            for written in referrers:
+                for commit in self._commits(other, written)["commits"]:
+                    commits.setdefault(commit["commit"], commit)
+            found.append({
+                "kind": "new_reference",
+                "symbol": key[1],
+                "package_dir": key[0],
+                "type": change["type"],
+                "changed_on": changed_side,
+                changed_side: self._side(changed_side, change),
+                other: {
+                    "referenced_by": [{"name": w["name"], "change": w["change"], "path": w["path"],
+                                       **({"line": w["new_lines"][0]} if w.get("new_lines") else {})}
+                                      for w in referrers],
+                    "commits": sorted(commits.values(), key=lambda c: c["date"], reverse=True),
+                },
+            })
+        return found
+
+    def overlaps(self) -> List[Dict[str, Any]]:
+        """Every overlap, both_changed first, each with the change and the commits of both sides."""
+        head, base = self.changes["head"], self.changes["base"]
+        found = []
+        for key in sorted(set(head) & set(base)):
+            ours, theirs = head[key], base[key]
+            if ours["change"] in _CHANGED and theirs["change"] in _CHANGED:
+                kind = "both_changed"
+            elif ours["change"] == theirs["change"] == "added":
+                kind = "both_added"
+            else:
+                continue
+            found.append({"kind": kind, "symbol": key[1], "package_dir": key[0], "type": ours["type"],
+                          "head": self._side("head", ours), "base": self._side("base", theirs)})
+        found.extend(self._new_references("head"))
+        found.extend(self._new_references("base"))
+        found.sort(key=lambda o: (KINDS.index(o["kind"]), o["package_dir"], o["symbol"]))
+        return found
+
+
+def _commit(commit: Dict[str, Any]) -> Dict[str, Any]:
+    return {"commit": commit["commit"], "author": commit["author"], "date": iso_date(commit["timestamp"]),
+            "summary": commit["summary"]}
@@ -0,0 +1,150 @@
+"""Dead-code detection for Go projects.
+
+A package-level symbol counts as used when its name is mentioned beyond
+its own declaration(s) - anywhere in the project if it is exported, in its
+own package if not, since nothing else can refer to it - and, for functions
+and methods, when the call graph resolves at least one call or reference to
+it. The usual entry points (main in package main, init, test functions) and
+interface plumbing are exempt.
+"""
+
+import os
+from typing import Dict, Optional, Any, Tuple
+
+from xray.core.go_analysis import GoCallGraph
+
+# Methods the standard library calls through interfaces without a visible call site
+WELL_KNOWN_METHODS = {
+    "String", "GoString", "Error", "Unwrap", "Is", "As", "Format",
+    "ServeHTTP", "MarshalJSON", "UnmarshalJSON", "MarshalText", "UnmarshalText",
+    "MarshalYAML", "UnmarshalYAML", "MarshalBinary", "UnmarshalBinary",
+    "Scan", "Value", "Len", "Less", "Swap", "Push", "Pop",
+    "Read", "Write", "Close", "Seek", "ReadFrom", "WriteTo",
+}
+
+_TEST_ENTRY_PREFIXES = ("Test", "Benchmark", "Example", "Fuzz")
+
+_KINDS = ("function", "method", "struct", "interface", "type", "constant", "variable")
+
+
+def _is_exported(name: str) -> bool:
+    return name[:1].isupper()
+
+
+class UnusedFinder:
+    """Reports package-level symbols with no references inside the project."""
+
+    def __init__(self, graph: GoCallGraph):
+        self.graph = graph
+        self.project = graph.project
+        # name -> mentions split by test / non-test files
+        self.mentions: Dict[str, Dict[str, int]] = {}
+        # (package dir, name) -> the same, within one package
+        self.package_mentions: Dict[Tuple[str, str], Dict[str, int]] = {}
+        declarations: Dict[Tuple[str, str, bool], int] = {}
+        for path, parsed in self.project.files.items():
+            is_test = path.endswith("_test.go")
+            pkg_dir = os.path.dirname(path)
+            for name, count in parsed.get("identifiers", {}).items():
+                for entry in (self.mentions.setdefault(name, {"code": 0, "test": 0}),
+                              self.package_mentions.setdefault((pkg_dir, name), {"code": 0, "test": 0})):
+                    entry["test" if is_test else "code"] += count
+            for symbol in parsed["symbols"]:
+                key = (pkg_dir, symbol["name"], is_test)
+                declarations[key] = declarations.get(key, 0) + 1
+        # A declaration's own name token is not a reference
+        for (pkg_dir, name, is_test), count in declarations.items():
+            side = "test" if is_test else "code"
+            for entry in (self.mentions.get(name), self.package_mentions.get((pkg_dir, name))):
+                if entry:
+                    entry[side] = max(0, entry[side] - count)
+
+        callee_counts: Dict[Tuple[str, str], int] = {}
+        for edge in graph.edges:
+            if not edge["external"] and edge["callee"] != edge["caller"]:
+                callee_counts[edge["callee"]] = callee_counts.get(edge["callee"], 0) + 1
+        self.callee_counts = callee_counts
+
+    def _interface_exempt(self, pkg_dir: str, receiver: str, method: str) -> bool:
+        """True if the method is required by a project interface that is itself referenced."""
+        for iface_key, symbol in self.project.types.items():
+            if symbol["type"] != "interface":
+                continue
+            required, _ = self.project.interface_method_set(*iface_key)
+            if method not in required:
+                continue
+            mentions = self.mentions.get(iface_key[1], {})
+            if not (mentions.get("code") or mentions.get("test")):
+                continue
+            match = self.project.match_interface(iface_key, (pkg_dir, receiver))
+            if match and "satisfied_by" in match:
+                return True
+        return False
+
+    def _classify(self, path: str, package: str, symbol: Dict[str, Any],
+                  include_exported: bool) -> Optional[Dict[str, Any]]:
+        """Return {"confidence", "reason"} for an unused symbol, None if it is used or exempt."""
+        name = symbol["name"]
+        kind = symbol["type"]
+        pkg_dir = os.path.dirname(path)
+        is_test_file = path.endswith("_test.go")
+
+        if kind == "function" and not symbol.get("receiver") and \
+                (name == "init" or (name == "main" and package == "main")):
+            return None
+        if is_test_file and kind == "function" and (name == "TestMain" or name.startswith(_TEST_ENTRY_PREFIXES)):
+            return None
+        if name == "_":
+            return None
+        if _is_exported(name) and package != "main" and not include_exported:
+            return None
+        if kind == "method" and name in WELL_KNOWN_METHODS:
+            return None
+
+        mentions = (self.mentions.get(name) if _is_exported(name) else self.package_mentions.get((pkg_dir, name))) \
+            or {"code": 0, "test": 0}
+        total = mentions["code"] + mentions["test"]
+
+        resolved = None
+        if kind in ("function", "method"):
+            key_name = f"{symbol['receiver']['type']}.{name}" if symbol.get("receiver") else name
+            resolved = self.callee_counts.get((pkg_dir, key_name), 0)
+            if kind == "method" and self._interface_exempt(pkg_dir, symbol["receiver"]["type"], name):
+                return None
+
+        if total == 0:
+            return {"confidence": "high", "reason": "never mentioned outside its declaration"}
+        if mentions["code"] == 0 and not is_test_file:
+            # Helpers declared in _test.go files are used by tests; production
+            # code that only tests touch is a deletion candidate, but a weaker one
+            return {"confidence": "medium", "reason": "only referenced from _test.go files"}
+        if resolved == 0:
+            return {"confidence": "low", "reason": "name is mentioned but no call or reference resolves to it"}
+        return None
+
+    def find(self, include_exported: bool = False) -> Dict[str, Any]:
+        """Return unused symbols grouped by file."""
+        files = []
+        total = 0
+        for path, parsed in sorted(self.project.files.items()):
+            package = parsed.get("package", "")
+            unused = []
+            for symbol in parsed["symbols"]:
+                if symbol["type"] not in _KINDS or "container" in symbol:
+                    continue
+                verdict = self._classify(path, package, symbol, include_exported)
+                if verdict is None:
+                    continue
+                unused.append({
+                    "name": f"{symbol['receiver']['type']}.{symbol['name']}" if symbol.get("receiver")
+                    else symbol["name"],
+                    "type": symbol["type"],
+                    "signature": symbol["signature"],
+                    "start_line": symbol["start_line"],
+                    "end_line": symbol["end_line"],
+                    **verdict,
+                })
+            if unused:
+                files.append({"path": path, "package": package, "unused": unused})
+                total += len(unused)
+        return {"files": files, "total_count": total, "include_exported": include_exported}
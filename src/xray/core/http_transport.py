@@ -0,0 +1,188 @@
+"""Serve MCP over HTTP - the streamable HTTP transport, for a shared server.
+
+`--listen 127.0.0.1:8765` serves the MCP endpoint at /mcp instead of
+talking over stdin/stdout. Clients POST JSON-RPC messages there and get a
+JSON reply or an SSE stream (progress notifications, then the result); a
+GET opens the stream of server-initiated notifications (resource updates,
+list changes). Every client gets its own session, named by the
+Mcp-Session-Id header, so one server can be shared by several agents.
+
+With --metrics, GET /metrics serves the server's usage metrics (see
+xray.core.metrics) in the Prometheus text format, next to the MCP endpoint.
+
+A request with an Origin header - one a browser sends for a page's script -
+is answered only if that origin is allowed: loopback origins by default
+(http://localhost:3000), those given with --allow-origin otherwise, and
+others get 403. This is the DNS-rebinding guard the MCP streamable HTTP
+transport asks for: a page served from a name that resolves to 127.0.0.1 is
+still not of an allowed origin. Clients other than browsers send no Origin.
+
+With a token, every request must carry `Authorization: Bearer <token>`;
+others get 401 - /metrics included, so a scraper needs the token too. SIGTERM or Ctrl-C stops accepting connections, lets
+requests in flight finish (for up to SHUTDOWN_GRACE seconds) and then runs
+the shutdown hook.
+"""
+
+import hmac
+import json
+import sys
+from typing import Any, Awaitable, Callable, Iterable, Optional, Tuple
+from urllib.parse import urlsplit
+
+DEFAULT_HOST = "127.0.0.1"
+MCP_PATH = "/mcp"
+METRICS_PATH = "/metrics"
+PROMETHEUS_CONTENT_TYPE = b"text/plain; version=0.0.4; charset=utf-8"
+# Seconds requests in flight get to finish after SIGTERM
+SHUTDOWN_GRACE = 30
+
+_LOOPBACK = {"127.0.0.1", "::1", "localhost"}
+
+
+def parse_listen(value: str) -> Tuple[str, int]:
+    """
+    Read a --listen address: "8765", ":8765", "0.0.0.0:8765" or "[::1]:8765".
+    The host defaults to 127.0.0.1.
+    """
+    host, _, port = value.strip().rpartition(":")
+    host = host.strip("[]") or DEFAULT_HOST
+    if not port.isdigit() or not 0 < int(port) < 65536:
+        raise ValueError(f"Invalid --listen address '{value}' - expected [HOST:]PORT")
+    return host, int(port)
+
+
+def _reject(status: int, message: str, extra: Iterable[Tuple[bytes, bytes]] = ()) -> Tuple[dict, dict]:
+    """The two ASGI messages of a JSON error response."""
+    body = json.dumps({"error": message}).encode()
+    start = {
+        "type": "http.response.start",
+        "status": status,
+        "headers": [(b"content-type", b"application/json"), (b"content-length", str(len(body)).encode()), *extra],
+    }
+    return start, {"type": "http.response.body", "body": body}
+
+
+def parse_origin(value: str) -> str:
+    """
+    Read an --allow-origin value: "*" for any, else scheme://host[:port]
+    ("https://app.example.com"), lowercased and without a trailing slash.
+    """
+    value = value.strip().rstrip("/").lower()
+    if value == "*":
+        return value
+    parts = urlsplit(value)
+    if parts.scheme not in ("http", "https") or not parts.hostname or parts.path or parts.query:
+        raise ValueError(f"Invalid --allow-origin '{value}' - expected scheme://host[:port] or *")
+    return value
+
+
+class OriginCheck:
+    """ASGI middleware turning away HTTP requests whose Origin is not allowed (no Origin is allowed)."""
+
+    def __init__(self, app: Any, origins: Iterable[str] = ()):
+        self.app = app
+        self.origins = {parse_origin(origin) for origin in origins}
+
+    def allowed(self, origin: str) -> bool:
+        origin = origin.rstrip("/").lower()
+        if "*" in self.origins or origin in self.origins:
+            return True
+        if self.origins:
+            return False
+        parts = urlsplit(origin)
+        return parts.scheme in ("http", "https") and parts.hostname in _LOOPBACK
+
+    def origin(self, scope: dict) -> Optional[str]:
+        for name, value in scope.get("headers", []):
+            if name.lower() == b"origin":
+                return value.decode("latin-1")
+        return None
+
+    async def __call__(self, scope: dict, receive: Callable, send: Callable):
+        origin = self.origin(scope) if scope["type"] == "http" else None
+        if origin is None or self.allowed(origin):
+            await self.app(scope, receive, send)
+            return
+        for message in _reject(403, f"Origin {origin} is not allowed"):
+            await send(message)
+
+
+class BearerAuth:
+    """ASGI middleware turning away HTTP requests without the bearer token."""
+
+    def __init__(self, app: Any, token: str):
+        self.app = app
+        self.expected = f"Bearer {token}".encode()
+
+    def authorized(self, scope: dict) -> bool:
+        for name, value in scope.get("headers", []):
+            if name.lower() == b"authorization":
+                return hmac.compare_digest(value, self.expected)
+        return False
+
+    async def __call__(self, scope: dict, receive: Callable, send: Callable):
+        # Lifespan events carry no headers
+        if scope["type"] != "http" or self.authorized(scope):
+            await self.app(scope, receive, send)
+            return
+        for message in _reject(401, "Missing or invalid bearer token", [(b"www-authenticate", b'Bearer realm="xray"')]):
+            await send(message)
+
+
+class MetricsEndpoint:
+    """ASGI middleware answering GET /metrics with the text render returns; other requests pass on."""
+
+    def __init__(self, app: Any, render: Callable[[], str]):
+        self.app = app
+        self.render = render
+
+    async def __call__(self, scope: dict, receive: Callable, send: Callable):
+        if scope["type"] != "http" or scope.get("path") != METRICS_PATH:
+            await self.app(scope, receive, send)
+            return
+        if scope.get("method") not in ("GET", "HEAD"):
+            status, content_type, body = 405, b"application/json", json.dumps(
+                {"error": "Only GET is allowed on /metrics"}).encode()
+        else:
+            status, content_type, body = 200, PROMETHEUS_CONTENT_TYPE, self.render().encode()
+        await send({
+            "type": "http.response.start",
+            "status": status,
+            "headers": [(b"content-type", content_type), (b"content-length", str(len(body)).encode())],
+        })
+        await send({"type": "http.response.body", "body": b"" if scope.get("method") == "HEAD" else body})
+
+
+def http_app(mcp: Any, token: Optional[str] = None, metrics: Optional[Callable[[], str]] = None,
+             origins: Iterable[str] = ()) -> Any:
+    """
+    The ASGI app of a FastMCP server's streamable HTTP endpoint, with
+    /metrics served from metrics when given, behind BearerAuth with a token
+    and, first of all, OriginCheck allowing origins (loopback ones if none).
+    """
+    if hasattr(mcp, "http_app"):
+        app = mcp.http_app(path=MCP_PATH)
+    else:
+        # FastMCP releases before http_app
+        app = mcp.streamable_http_app()
+    if metrics is not None:
+        app = MetricsEndpoint(app, metrics)
+    if token:
+        app = BearerAuth(app, token)
+    return OriginCheck(app, origins)
+
+
+async def serve(app: Any, host: str, port: int, on_shutdown: Callable[[], Awaitable[None]]):
+    """Run an ASGI app until SIGTERM/SIGINT, then await on_shutdown."""
+    import uvicorn
+
+    if host not in _LOOPBACK and not isinstance(getattr(app, "app", app), BearerAuth):
+        print(f"xray: listening on {host}:{port} without --auth-token; anyone who can reach it "
+              f"can read the projects it serves", file=sys.stderr)
+    config = uvicorn.Config(app, host=host, port=port, log_level="warning",
+                            timeout_graceful_shutdown=SHUTDOWN_GRACE)
+    try:
+        # uvicorn handles the signals: no new connections, then waits for open requests
+        await uvicorn.Server(config).serve()
+    finally:
+        await on_shutdown()
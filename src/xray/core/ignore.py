@@ -0,0 +1,357 @@
+"""gitignore-compatible path filtering for the indexer.
+
+Rules are collected the way git does: the global excludes file, the
+repository's info/exclude, and every .gitignore from the repository root
+down to the directory being checked, deeper files taking precedence.
+
+PathGlobs narrows a query to part of the tree with include and exclude
+globs in doublestar syntax, matched against paths relative to the project
+root.
+"""
+
+import os
+import posixpath
+import re
+import subprocess
+from pathlib import Path
+from typing import Dict, List, Optional, Tuple
+
+# Characters that end the literal directory prefix of a glob
+_GLOB_CHARS = frozenset("*?[{")
+# https://go.dev/s/generatedcode
+_GENERATED_HEADER = re.compile(r"^// Code generated .* DO NOT EDIT\.$")
+
+
+def is_generated_go(path: Path) -> bool:
+    """Whether a Go file carries the standard generated-code marker before its package clause."""
+    try:
+        with open(path, "r", encoding="utf-8", errors="replace") as f:
+            for line in f:
+                line = line.rstrip("\r\n")
+                if _GENERATED_HEADER.match(line):
+                    return True
+                stripped = line.strip()
+                if stripped and not stripped.startswith("//") and not stripped.startswith("/*") \
+                        and not stripped.startswith("*"):
+                    return False
+    except OSError:
+        pass
+    return False
+
+
+def generated_header(path: Path, patterns: List["re.Pattern"]) -> Optional[str]:
+    """
+    The first of patterns matching a line of a file's leading comments and
+    blank lines (before its first line of code), or None.
+    """
+    try:
+        with open(path, "r", encoding="utf-8", errors="replace") as f:
+            for line in f:
+                line = line.rstrip("\r\n")
+                for pattern in patterns:
+                    if pattern.search(line):
+                        return pattern.pattern
+                stripped = line.strip()
+                if stripped and not stripped.startswith(("//", "/*", "*", "#")):
+                    return None
+    except OSError:
+        pass
+    return None
+
+
+def glob_regex(pattern: str) -> str:
+    """Translate a gitignore glob into a regex body matching a slash-separated path."""
+    out = []
+    i = 0
+    while i < len(pattern):
+        char = pattern[i]
+        if pattern.startswith("**/", i):
+            out.append("(?:.*/)?")
+            i += 3
+            continue
+        if pattern.startswith("/**", i) and i + 3 == len(pattern):
+            out.append("/.*")
+            i += 3
+            continue
+        if pattern.startswith("**", i):
+            out.append(".*")
+            i += 2
+            continue
+        if char == "*":
+            out.append("[^/]*")
+        elif char == "?":
+            out.append("[^/]")
+        elif char == "\\" and i + 1 < len(pattern):
+            i += 1
+            out.append(re.escape(pattern[i]))
+        elif char == "[":
+            end = pattern.find("]", i + 2)
+            if end == -1:
+                out.append(re.escape(char))
+            else:
+                body = pattern[i + 1:end]
+                if body.startswith("!"):
+                    body = "^" + body[1:]
+                out.append("[" + body.replace("\\", "\\\\") + "]")
+                i = end
+        else:
+            out.append(re.escape(char))
+        i += 1
+    return "".join(out)
+
+
+class _Rule:
+    def __init__(self, pattern: str, source: str):
+        self.source = source
+        self.negated = pattern.startswith("!")
+        if self.negated:
+            pattern = pattern[1:]
+        elif pattern.startswith("\\!") or pattern.startswith("\\#"):
+            pattern = pattern[1:]
+        self.dir_only = pattern.endswith("/")
+        pattern = pattern.rstrip("/")
+        anchored = "/" in pattern
+        pattern = pattern.lstrip("/")
+        body = glob_regex(pattern)
+        self.regex = re.compile(("^" if anchored else "(?:^|/)") + body + "$")
+
+    def matches(self, relpath: str, is_dir: bool) -> bool:
+        if self.dir_only and not is_dir:
+            return False
+        return bool(self.regex.search(relpath))
+
+
+def _read_rules(path: Path, label: str) -> List[_Rule]:
+    rules = []
+    try:
+        with open(path, "r", encoding="utf-8", errors="replace") as f:
+            for number, line in enumerate(f, 1):
+                line = line.rstrip("\r\n")
+                # Trailing spaces are ignored unless escaped
+                if not line.endswith("\\ "):
+                    line = line.rstrip(" ")
+                if not line or line.startswith("#"):
+                    continue
+                rules.append(_Rule(line, f"{label}:{number}: {line}"))
+    except OSError:
+        pass
+    return rules
+
+
+def _global_excludes_file(repo_root: Path) -> Optional[Path]:
+    try:
+        result = subprocess.run(["git", "config", "--path", "core.excludesFile"],
+                                cwd=repo_root, capture_output=True, text=True)
+        if result.returncode == 0 and result.stdout.strip():
+            return Path(os.path.expanduser(result.stdout.strip()))
+    except FileNotFoundError:
+        pass
+    config_home = os.environ.get("XDG_CONFIG_HOME") or os.path.join(os.path.expanduser("~"), ".config")
+    return Path(config_home) / "git" / "ignore"
+
+
+class GitIgnore:
+    """Answers "is this path ignored, and by which rule?" for paths under a root."""
+
+    def __init__(self, root: Path):
+        self.root = Path(root)
+        self.repo_root = self._find_repo_root()
+        self._base = str(self.repo_root or self.root)
+        # Rules that apply everywhere, lowest precedence first, matched against repo-relative paths
+        self.base_rules: List[_Rule] = []
+        # repo-relative directory ("" for the top) -> rules of its .gitignore
+        self._dir_rules: Dict[str, List[_Rule]] = {}
+        self._decisions: Dict[Tuple[str, bool], Optional[str]] = {}
+        if self.repo_root:
+            excludes = _global_excludes_file(self.repo_root)
+            if excludes:
+                self.base_rules += _read_rules(excludes, str(excludes))
+            self.base_rules += _read_rules(self.repo_root / ".git" / "info" / "exclude", ".git/info/exclude")
+
+    def _find_repo_root(self) -> Optional[Path]:
+        for candidate in [self.root, *self.root.parents]:
+            if (candidate / ".git").exists():
+                return candidate
+        return None
+
+    def _rules_for(self, directory: str) -> List[_Rule]:
+        if directory not in self._dir_rules:
+            label = f"{directory}/.gitignore" if directory else ".gitignore"
+            self._dir_rules[directory] = _read_rules(Path(self._base, *directory.split("/"), ".gitignore"), label)
+        return self._dir_rules[directory]
+
+    def _own_decision(self, relpath: str, is_dir: bool) -> Optional[str]:
+        verdict: Optional[_Rule] = None
+        for rule in self.base_rules:
+            if rule.matches(relpath, is_dir):
+                verdict = rule
+        # .gitignore files from the top down; each matches paths relative to its own directory
+        parts = relpath.split("/")
+        for depth in range(len(parts)):
+            directory = "/".join(parts[:depth])
+            rules = self._rules_for(directory)
+            if not rules:
+                continue
+            local = "/".join(parts[depth:])
+            for rule in rules:
+                if rule.matches(local, is_dir):
+                    verdict = rule
+        if verdict is None or verdict.negated:
+            return None
+        return verdict.source
+
+    def _match_relative(self, relpath: str, is_dir: bool) -> Optional[str]:
+        key = (relpath, is_dir)
+        if key not in self._decisions:
+            parent = relpath.rpartition("/")[0]
+            decision = self._match_relative(parent, True) if parent else None
+            self._decisions[key] = decision or self._own_decision(relpath, is_dir)
+        return self._decisions[key]
+
+    def match(self, path: Path, is_dir: Optional[bool] = None) -> Optional[str]:
+        """
+        Return the rule that ignores path (as "file:line: pattern"), or None.
+
+        A path inside an ignored directory is ignored too, whatever later
+        rules say, as in git.
+        """
+        relpath = os.path.relpath(str(path), self._base).replace(os.sep, "/")
+        if relpath == "." or relpath.startswith("../"):
+            return None
+        if is_dir is None:
+            is_dir = os.path.isdir(path)
+        return self._match_relative(relpath, is_dir)
+
+
+def _brace_group(pattern: str) -> Optional[Tuple[int, int, List[str]]]:
+    """(start, end, alternatives) of a glob's first top-level {a,b} group, None without one."""
+    depth, start, options, last = 0, 0, [], 0
+    i = 0
+    while i < len(pattern):
+        char = pattern[i]
+        if char == "\\":
+            i += 2
+            continue
+        if char == "{":
+            if depth == 0:
+                start, options, last = i, [], i + 1
+            depth += 1
+        elif char == "," and depth == 1:
+            options.append(pattern[last:i])
+            last = i + 1
+        elif char == "}" and depth:
+            depth -= 1
+            if depth == 0 and options:
+                return start, i, options + [pattern[last:i]]
+        i += 1
+    return None
+
+
+def expand_braces(pattern: str) -> List[str]:
+    """The alternatives of a glob with {a,b} groups, nested ones included."""
+    group = _brace_group(pattern)
+    if group is None:
+        return [pattern]
+    start, end, options = group
+    tails = expand_braces(pattern[end + 1:])
+    return [pattern[:start] + alternative + tail
+            for option in options for alternative in expand_braces(option) for tail in tails]
+
+
+class PathGlobs:
+    """
+    Include and exclude globs over the files of a project.
+
+    Patterns use doublestar syntax against slash-separated paths relative to
+    the root: * and ? stay within a path segment, ** spans any number of
+    them and {a,b} picks alternatives, so "**/*_test.go" is every test file
+    and "internal/{store,api}/**" two subtrees. A pattern matching a
+    directory covers everything below it. A file is kept when it matches an
+    include pattern (any file, without one) and no exclude pattern.
+
+    A pattern may also be given as an absolute path, or with ./ and ../
+    segments, or through a symlinked directory: its literal prefix (up to
+    the first segment with a wildcard) is resolved and made relative to the
+    root, so "/repo/internal/**", "./internal/" and "cmd/../internal/**"
+    all mean "internal/**".
+    """
+
+    def __init__(self, root: Path, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None):
+        self.root = str(root)
+        self.include = [self._relative_pattern(p) for p in include or [] if p]
+        self.exclude = [self._relative_pattern(p) for p in exclude or [] if p]
+        self._include = self._compile(self.include)
+        self._exclude = self._compile(self.exclude)
+        self._excludes = [(p, self._compile([p])) for p in self.exclude]
+
+    @staticmethod
+    def _compile(patterns: List[str]) -> Optional["re.Pattern"]:
+        bodies = [glob_regex(alternative.strip("/")) for pattern in patterns for alternative in expand_braces(pattern)]
+        if not bodies:
+            return None
+        try:
+            return re.compile("^(?:" + "|".join(bodies) + ")(?:/.*)?$")
+        except re.error as e:
+            raise ValueError(f"Invalid glob in {patterns}: {e}")
+
+    def _relative_pattern(self, pattern: str) -> str:
+        parts = pattern.replace(os.sep, "/").split("/")
+        literal = next((i for i, part in enumerate(parts) if _GLOB_CHARS & set(part)), len(parts))
+        prefix, rest = "/".join(parts[:literal]), [part for part in parts[literal:] if part]
+        if not prefix.strip("/"):
+            return pattern
+        target = os.path.realpath(prefix if os.path.isabs(prefix) else os.path.join(self.root, prefix))
+        root = os.path.realpath(self.root)
+        if target != root and not target.startswith(root.rstrip(os.sep) + os.sep):
+            # Outside the root: matches nothing, as given
+            return pattern
+        prefix = os.path.relpath(target, root).replace(os.sep, "/")
+        return posixpath.join(*([] if prefix == "." else [prefix]), *rest) if rest or prefix != "." else "**"
+
+    def __bool__(self) -> bool:
+        return bool(self.include or self.exclude)
+
+    def relpath(self, path: str) -> str:
+        path = str(path)
+        if os.path.isabs(path):
+            path = os.path.relpath(path, self.root)
+        return path.replace(os.sep, "/")
+
+    def matches(self, path: str) -> bool:
+        """Whether a file (absolute, or relative to the root) is kept."""
+        relpath = self.relpath(path)
+        if self._include is not None and not self._include.match(relpath):
+            return False
+        return self._exclude is None or not self._exclude.match(relpath)
+
+    def included(self, path: str) -> bool:
+        """Whether a file (absolute, or relative to the root) matches an include pattern; True without one."""
+        return self._include is None or bool(self._include.match(self.relpath(path)))
+
+    def excluded_by(self, path: str) -> Optional[str]:
+        """The first exclude pattern matching a file or directory, or None."""
+        relpath = self.relpath(path)
+        return next((p for p, regex in self._excludes if regex.match(relpath)), None)
+
+    def pathspecs(self, default: List[str], scope: str = ".") -> List[str]:
+        """
+        The git pathspecs selecting the same files, for history walks run
+        from the repository root; scope is where the project root is in it
+        (GitRepo.scope) and default stands in for a missing include.
+        """
+        def specs(patterns: List[str], magic: str) -> List[str]:
+            found = []
+            for pattern in patterns:
+                for alternative in expand_braces(pattern):
+                    alternative = alternative.strip("/")
+                    if scope != ".":
+                        alternative = f"{scope}/{alternative}"
+                    found.append(f":({magic}){alternative}")
+                    if not alternative.endswith("**"):
+                        found.append(f":({magic}){alternative}/**")
+            return found
+
+        return (specs(self.include, "glob") or default) + specs(self.exclude, "exclude,glob")
+
+    def describe(self) -> Dict[str, List[str]]:
+        return {"include": self.include, "exclude": self.exclude}
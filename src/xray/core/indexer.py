@@ -1,30 +1,166 @@
-"""Core indexing engine for XRAY - ast-grep based implementation."""
+"""Core indexing engine for XRAY - native parsers for Go, TypeScript/JavaScript, Python, Rust, Java, Protocol Buffers, SQL, Makefiles and shell scripts."""
 
 import os
 import re
-import ast
 import json
 import subprocess
-import hashlib
-import pickle
+import tempfile
+import threading
+import time
+from contextlib import contextmanager
 from pathlib import Path
-from typing import Dict, List, Optional, Any, Set, Tuple
+from stat import S_ISREG
+from typing import Callable, Dict, Iterable, List, Optional, Any, Set, Tuple
 import fnmatch
 from thefuzz import fuzz
 
+from xray.core.allowlist import AllowList
+from xray.core.blob_cache import BlobCache, cache_file
+from xray.core.buffers import dangling_calls, dangling_imports, declaration_changes
+from xray.core.cache import IndexCache, cache_root
+from xray.core.chunks import CHUNK_COLUMNS, DEFAULT_MAX_TOKENS, DEFAULT_OVERLAP_TOKENS, DEFAULT_TARGET_TOKENS, \
+    Chunker, estimator
+from xray.core.cross_language import LINK_KINDS, CrossLanguageLinker
+from xray.core.debt import MARKERS
+from xray.core.dependencies import (ECOSYSTEMS, decode_version, detect_license, escaped_module_path, module_cache_dir,
+                                    normalized_name, npm_license, npm_package, owning_requirement, parse_go_sum,
+                                    parse_package_json, parse_requirements, python_license)
+from xray.core.doc_drift import doc_findings, doc_span, exported_undocumented, raise_confidence
+from xray.core.docker_analysis import ContainerMap, listeners
+from xray.core.docker_parser import is_dockerfile, parse_dockerfile, read_yaml
+from xray.core.errors import (AmbiguousSymbol, FileNotFound, InvalidConfig, SymbolChanged, SymbolNotFound,
+                              UnsupportedLanguage, XRayError, describe_error)
+from xray.core.go_modules import GoModules
+from xray.core.go_parser import PARSER_VERSION, parse_go_mod, parse_go_work
+from xray.core.go_analysis import GoCallGraph, GoProject
+from xray.core.go_api import api_diff, api_surface
+from xray.core.go_api_usage import DEFAULT_MANY, USAGE_COLUMNS, ApiUsage, usage_rows
+from xray.core.git_evolution import symbol_history
+from xray.core.git_history import (GitError, GitRepo, blame_hunks, is_bare_repository, iso_date, run_cancellable,
+                                   summarize_blame)
+from xray.core.git_metrics import co_changes, coupled_pairs, file_churn, rank_hotspots, symbol_churn
+from xray.core.git_ownership import CODEOWNERS_LOCATIONS, CodeOwners, OwnershipMap
+from xray.core.git_submodules import Submodules
+from xray.core.go_deps import coupling_metrics, dependency_graph, find_cycles, service_map, to_dot
+from xray.core.go_errors import ErrorAudit
+from xray.core.go_failures import FAILURE_KINDS, FailurePointFinder
+from xray.core.go_formats import FormatChecker
+from xray.core.go_fields import FieldUsageFinder
+from xray.core.go_reflection import REFLECTION_KINDS, ReflectionFinder, switch_cases
+from xray.core.go_diff import diff_tables, symbol_table, touched_symbols
+from xray.core.go_concurrency import ConcurrencyMap
+from xray.core.go_clones import DEFAULT_MIN_SIMILARITY as DUPLICATE_SIMILARITY, DEFAULT_MIN_TOKENS as \
+    DUPLICATE_TOKENS, DuplicateFinder
+from xray.core.go_config import (ConfigUsageFinder, compose_environment, cross_reference, is_compose_file,
+                                 is_env_declaration_file, read_env_file)
+from xray.core.go_constructions import ConstructionFinder
+from xray.core.go_context import ContextAuditor
+from xray.core.go_deprecation import DeprecationReport
+from xray.core.go_coverage import CoverageMapper
+from xray.core.go_globals import GlobalUsageFinder
+from xray.core.go_hierarchy import TypeHierarchy
+from xray.core.go_init import InitAnalyzer
+from xray.core.go_interfaces import InterfaceUsage
+from xray.core.go_literals import LiteralFinder
+from xray.core.go_logging import LogFinder
+from xray.core.go_mermaid import call_graph_flowchart, type_hierarchy_diagram
+from xray.core.go_metrics import rank_functions
+from xray.core.go_outline import TypeOutline
+from xray.core.go_paths import CallPathFinder, entry_points, external_targets
+from xray.core.go_mock_check import MockCheck
+from xray.core.go_mocks import MockFinder, mock_file
+from xray.core.go_nilflow import VariableTracer
+from xray.core.go_queries import QueryExtractor
+from xray.core.go_resources import ResourceAudit
+from xray.core.go_move import PackageMovePlanner, is_build_file
+from xray.core.go_rename import RenamePlanner
+from xray.core.go_routes import RouteExtractor
+from xray.core.go_rules import BUILTIN_RULES, RuleEngine, parse_rules
+from xray.core.go_build import BuildContext
+from xray.core.go_search import search_symbols, symbol_filter
+from xray.core.go_signatures import SignatureIndex
+from xray.core.go_templates import TemplateIndex, is_template_file
+from xray.core.go_tests import DEFAULT_DEPTH as TEST_SEARCH_DEPTH, TestFinder, is_test_file
+from xray.core.go_three_way import KINDS as THREE_WAY_KINDS, SIDES, ThreeWayImpact, side_changes
+from xray.core.go_unused import UnusedFinder
+from xray.core.locate import ChangeLocator, check_weights, term_counts, terms_of
+from xray.core.memory import MEGABYTE, OnDemand, approximate_size, body_size, call_sites, intern_strings
+from xray.core.metrics import Latencies
+from xray.core.ignore import GitIgnore, PathGlobs, generated_header, is_generated_go
+from xray.core.java_analysis import JavaProject
+from xray.core.java_parser import JAVA_PARSER_VERSION
+from xray.core.parse_pool import IndexingCancelled, default_concurrency, parse_files, parse_source
+from xray.core.partial import PARTIAL_FILE_SIZE, WHOLE_FILE_LANGUAGES, is_binary
+from xray.core.languages import NATIVE_LANGUAGES, LanguageMap, sniff_file
+from xray.core.paths import canonical_case, posix_paths, relative
+from xray.core.project_config import ProjectConfig, load_config
+from xray.core.proto_analysis import ProtoProject, generated_source
+from xray.core.proto_parser import PROTO_PARSER_VERSION
+from xray.core.py_analysis import PyProject, is_stdlib
+from xray.core.py_parser import PY_PARSER_VERSION
+from xray.core.ranges import MAX_SNIPPET_LINES, SourceLines, add_ranges, mark_files, snippet
+from xray.core.rs_analysis import RsProject
+from xray.core.rs_parser import RS_PARSER_VERSION
+from xray.core.report import ReportBuilder, render_report
+from xray.core.source_text import content_hash, read_source, read_text, source_lines
+from xray.core.symbol_ids import (SymbolId, add_symbol_ids, closest_match, in_package, parse_symbol_id,
+                                  split_qualifier, symbol_id)
+from xray.core.sarif import (concurrent_write_results, cycle_results, find_cycles_results, format_results,
+                             rule_results, sarif_log, secret_results, unused_results)
+from xray.core.secrets import CONFIDENCE as SECRET_CONFIDENCE, config_section, is_config_file, is_fixture, \
+    lower_confidence, scan_text
+from xray.core.scip import encode_scip, scip_index
+from xray.core.snapshots import SnapshotStore, compare, new_snapshot, symbol_key
+from xray.core.sql_analysis import SqlProject
+from xray.core.sql_parser import SQL_PARSER_VERSION
+from xray.core.symbol_table import EXPORT_FORMATS, REFERENCE_COLUMNS, SYMBOL_COLUMNS, symbol_row, write_table
+from xray.core.tags import build_tags
+from xray.core.task_analysis import TaskProject
+from xray.core.task_parser import TASK_PARSER_VERSION
+from xray.core.ts_analysis import TsProject, load_tsconfig
+from xray.core.ts_parser import TS_PARSER_VERSION
+
 # Default exclusions
 DEFAULT_EXCLUSIONS = {
     # Directories
-    "node_modules", "vendor", "__pycache__", "venv", ".venv", "env",
+    "node_modules", "vendor", "testdata", "__pycache__", "venv", ".venv", "env",
     "target", "build", "dist", ".git", ".svn", ".hg", ".idea", ".vscode",
     ".xray", "site-packages", ".tox", ".pytest_cache", ".mypy_cache",
     
     # File patterns
     "*.pyc", "*.pyo", "*.pyd", "*.so", "*.dll", "*.log", 
-    ".DS_Store", "Thumbs.db", "*.swp", "*.swo", "*~"
+    ".DS_Store", "Thumbs.db", "*.swp", "*.swo", "*~",
+    # TypeScript declaration files and minified bundles describe or repeat code kept elsewhere
+    "*.d.ts", "*.d.mts", "*.d.cts", "*.min.js",
+}
+
+# diff_impact scopes: git diff arguments, old side, new side ("" = index, None = working tree)
+DIFF_SCOPES = {
+    "staged": (("--cached",), "HEAD", ""),
+    "unstaged": ((), "", None),
+    "all": (("HEAD",), "HEAD", None),
 }
 
+# Serializations of the call-graph and type-hierarchy results
+GRAPH_FORMATS = ("json", "mermaid")
+# How find_callers treats calls through interfaces: only the method called, or also
+# the interface methods it implements / the implementations of an interface method
+INTERFACE_RESOLUTIONS = ("strict", "expanded")
+# Serializations of the package dependency graph
+DEPENDENCY_FORMATS = ("json", "dot", "sarif")
+# Serializations of finding reports (SARIF for code scanning)
+FINDING_FORMATS = ("json", "sarif")
+# Characters of a package description kept in listings (description_length in .xray.yaml)
+DESCRIPTION_LENGTH = 200
+# README files a directory without a package comment is described by, in order
+README_NAMES = ("README.md", "readme.md", "README.markdown", "README")
+
 # Language extensions
+# Minimum seconds between two progress events of the same run
+PROGRESS_INTERVAL = 0.25
+# Files a quick index parses in full per deepen step, the project lock held meanwhile
+DEEP_INDEX_BATCH = 500
+
 LANGUAGE_MAP = {
     ".py": "python",
     ".js": "javascript", 
@@ -33,63 +169,555 @@ LANGUAGE_MAP = {
     ".ts": "typescript",
     ".tsx": "typescript",
     ".go": "go",
+    ".rs": "rust",
+    ".java": "java",
+    ".proto": "proto",
+    ".sql": "sql",
+    ".sh": "shell",
+    ".bash": "shell",
+    ".mk": "make",
+}
+# Files whose name, not their extension, gives their language
+FILE_NAME_LANGUAGES = {"makefile": "make", "gnumakefile": "make"}
+
+# Rust types that take paths (`u8::MAX`, `str::from_utf8`) without being crates
+RUST_PRIMITIVES = {
+    "bool", "char", "str", "f32", "f64", "i8", "i16", "i32", "i64", "i128", "isize",
+    "u8", "u16", "u32", "u64", "u128", "usize",
 }
 
+# Declaration kinds of the symbols call graph and type tools take (see _symbol_arg)
+FUNCTION_KINDS = {"function", "method"}
+TYPE_KINDS = {"struct", "interface", "type", "enum", "trait", "class", "record", "annotation"}
+# Languages whose parsers keep doc comments, for find_stale_docs
+DOC_LANGUAGES = {"go", "typescript", "javascript", "python", "rust", "java"}
+# Exclusion reason of a symlink leading inside the project (see _symlink_reason)
+SYMLINK_ALIAS = "symlink: alias"
+
+
+def language_of(path) -> Optional[str]:
+    """The language of a source file by its name (Makefile) or else its extension; None if it is not indexed."""
+    name = os.path.basename(str(path)).lower()
+    return FILE_NAME_LANGUAGES.get(name) or LANGUAGE_MAP.get(os.path.splitext(name)[1])
+
 
 class XRayIndexer:
-    """Main indexer for XRAY - provides file tree and symbol extraction using ast-grep."""
+    """Main indexer for XRAY - provides file tree and symbol extraction from the language parsers."""
     
-    def __init__(self, root_path: str):
-        self.root_path = Path(root_path).resolve()
+    def __init__(self, root_path: str, ref: Optional[str] = None, include_generated: bool = False,
+                 allowlist: Optional[AllowList] = None, include_submodules: bool = True,
+                 fetch_missing: bool = False, quick_index: bool = False, blob_cache: Optional[BlobCache] = None,
+                 language_map: Optional[Dict[str, str]] = None):
+        self.source_root = Path(root_path).resolve()
+        # Files must resolve into these (or into a ref's snapshot); see core/allowlist.py
+        self.allowlist = allowlist or AllowList()
+        self.root_path = self.source_root
+        self.ref = ref
+        self.include_generated = include_generated
+        # Index the work trees of git submodules, each with its own history (see core/git_submodules.py)
+        self.include_submodules = include_submodules
+        # Let git fetch the blobs a partial clone left out instead of skipping what needs them
+        self.fetch_missing = fetch_missing
+        self._submodules: Optional[Submodules] = None
+        # README directory -> ((mtime_ns, size), file name, first paragraph), see _readme_paragraph
+        self._readmes: Dict[str, Tuple[Tuple[int, int], str, str]] = {}
+        # The project's .xray.yaml / .xray.json, re-read when it changes (see core/project_config.py)
+        self._config = ProjectConfig()
+        self._config_excludes = PathGlobs(self.source_root)
+        self.ref_commit = None
         self._cache = {}
+        self._project: Optional[GoProject] = None
+        self._modules: Optional[TsProject] = None
+        self._python: Optional[PyProject] = None
+        self._rust: Optional[RsProject] = None
+        self._java: Optional[JavaProject] = None
+        self._protos: Optional[ProtoProject] = None
+        self._sql: Optional[SqlProject] = None
+        self._tasks: Optional[TaskProject] = None
+        # Generated .pb.go files left out of the index: path -> (stamp, parse result)
+        self._generated_go: Dict[str, Tuple[Tuple[int, int], Dict[str, Any]]] = {}
+        self._protos_linked = -1
+        # go.mod and go.work files read: path -> (stamp, parse result)
+        self._module_files: Dict[str, Tuple[Tuple[int, int], Optional[Dict[str, Any]]]] = {}
+        self._graph: Optional[GoCallGraph] = None
+        # Call graphs of the files one build compiles: build context key -> (generation, graph)
+        self._context_graphs: Dict[Tuple[str, str, Tuple[str, ...]], Tuple[int, GoCallGraph]] = {}
+        self.last_refresh: Dict[str, List[str]] = {"added": [], "modified": [], "removed": []}
+        # When the tree was last walked and what it skipped
+        self.last_walk: Optional[Dict[str, Any]] = None
+        # How long bringing the index up to date with the tree took, per catch-up
+        self.refresh_latency = Latencies()
+        # Source files the last walk found but could not read or parse: path -> warning
+        self._unindexed: Dict[str, Dict[str, Any]] = {}
+        # Source files indexed from their declaration skeleton only (see xray.core.partial)
+        self._partial: Set[str] = set()
+        # Quick index: the package directories parsed in full so far, None once every one is (see deepen)
+        self._deep_packages: Optional[Set[str]] = set() if quick_index else None
+        # Source files a quick index holds the skeleton of, waiting for their package's full parse
+        self._shallow: Set[str] = set()
+        self._walked_files = 0
+        # Source files read in another encoding than UTF-8: path -> encoding (see xray.core.source_text)
+        self._transcoded: Dict[str, str] = {}
+        # NUL sniffing results: path -> ((mtime_ns, size), binary)
+        self._binary: Dict[str, Tuple[Tuple[int, int], bool]] = {}
+        # File names and extensions -> language from the server (--language-map), under the project's own
+        self.language_map = dict(language_map or {})
+        self._language_table: Optional[Tuple[ProjectConfig, LanguageMap]] = None
+        # Shebang and modeline sniffing of extensionless files: path -> ((mtime_ns, size), language)
+        self._sniffed: Dict[str, Tuple[Tuple[int, int], Optional[str]]] = {}
+        # The last parse of a file as a language a call forced: path -> ((mtime_ns, size), language, parse result)
+        self._forced: Dict[str, Tuple[Tuple[int, int], str, Dict[str, Any]]] = {}
+        # The last buffer analyze_buffer parsed per file: path -> (content hash, parse result)
+        self._buffers: Dict[str, Tuple[Tuple[str, str], Dict[str, Any]]] = {}
+        # Symlinks the last walk found leading inside the project: relative link -> relative target
+        self._symlinks: Dict[str, str] = {}
+        # Bumped whenever the indexed content changes; keys derived summaries
+        self._generation = 0
+        # Inside tracking(): whether the call already caught up with the tree, so it keeps that snapshot
+        self._tracked = False
+        self._pinned = False
+        self._overview: Optional[Tuple[int, Dict[str, Any]]] = None
+        self._signatures: Optional[Tuple[int, SignatureIndex]] = None
+        # What history tools extracted from old file versions, by blob SHA (see core/blob_cache.py);
+        # the server shares one among its projects
+        self.blob_cache = blob_cache if blob_cache is not None else BlobCache(path=cache_file())
+        # Approximate bytes of each parse result: path -> (parse result measured, bytes)
+        self._sizes: Dict[str, Tuple[Dict[str, Any], int]] = {}
+        # Identifier and comment words of each file, for locate_change: path -> (content hash, stem counts)
+        self._terms: Dict[str, Tuple[str, Any]] = {}
+        self.concurrency = default_concurrency()
+        self._cancel = threading.Event()
+        self.progress: Optional[Callable[[Dict[str, Any]], None]] = None
+        self._progress_sent = 0.0
+        if ref:
+            self._init_snapshot(ref)
+        elif is_bare_repository(str(self.source_root)):
+            raise GitError(f"{self.source_root} is a bare repository, with no working tree to index: pass ref "
+                           "(a branch, tag or commit such as HEAD) to analyze one of its commits", str(self.source_root))
         self._init_cache()
     
+    def _init_snapshot(self, ref: str):
+        """
+        Point the indexer at a read-only snapshot of a git ref.
+        
+        The snapshot is exported from the object database once per commit
+        and reused, so every analysis runs unchanged against the ref's tree
+        while results are reported with the project's real paths.
+        """
+        repo = self._git(self.source_root)
+        self.ref_commit = repo.resolve(ref)
+        snapshot = cache_root() / "snapshots" / self.ref_commit
+        relpath = repo.relpath(str(self.source_root))
+        if relpath != ".":
+            snapshot = snapshot / relpath
+        if not snapshot.is_dir():
+            repo.export(self.ref_commit, relpath, str(snapshot))
+        self.root_path = snapshot.resolve()
+        # Submodule work trees are not part of a commit's tree: all of them read as uninitialized
+        self._submodules = Submodules(self.root_path, str(cache_root() / "snapshots" / self.ref_commit))
+    
     def _init_cache(self):
-        """Initialize cache based on git commit SHA."""
+        """Load the persisted index for this project and commit (see core/cache.py)."""
+        self.commit_sha = self.ref_commit or self._head_commit()
+        self.index_cache = IndexCache(self.root_path, self.commit_sha or "worktree")
+        self._load_cache()
+    
+    def _head_commit(self) -> Optional[str]:
+        """The commit checked out in the working tree, or None outside a repository."""
         try:
-            # Get current git commit SHA
             result = subprocess.run(
                 ["git", "rev-parse", "HEAD"],
                 cwd=self.root_path,
                 capture_output=True,
                 text=True
             )
-            if result.returncode == 0:
-                self.commit_sha = result.stdout.strip()
-                self.cache_dir = Path(f"/tmp/.xray_cache/{self.commit_sha}")
-                self.cache_dir.mkdir(parents=True, exist_ok=True)
-                self._load_cache()
-            else:
-                self.commit_sha = None
-                self.cache_dir = None
-        except:
-            self.commit_sha = None
-            self.cache_dir = None
+            return result.stdout.strip() if result.returncode == 0 else None
+        except FileNotFoundError:
+            return None
     
     def _load_cache(self):
         """Load cache from disk if available."""
-        if not self.cache_dir:
-            return
-        
-        cache_file = self.cache_dir / "symbols.pkl"
-        if cache_file.exists():
-            try:
-                with open(cache_file, 'rb') as f:
-                    self._cache = pickle.load(f)
-            except:
-                self._cache = {}
+        self._cache = self.index_cache.load()
+        if self.index_cache.loaded_from and self.index_cache.loaded_from != str(self.index_cache.path):
+            # Seeded from another commit: only the parse indexes are validated per file
+            self._cache = {k: v for k, v in self._cache.items()
+                           if k.startswith(("go-index:", "ts-index:", "py-index:", "rs-index:", "java-index:",
+                                             "proto-index:", "sql-index:",
+                                             "task-index:"))}
+        self.cache_stats = {
+            "persisted_files": sum(len(index) for index in self._parse_indexes()),
+            "hits": 0,
+            "misses": 0,
+        }
+        self._cache_dirty = False
     
     def _save_cache(self):
         """Save cache to disk."""
-        if not self.cache_dir:
+        if not self._cache_dirty:
             return
+        if self.index_cache.save(self._cache):
+            self._cache_dirty = False
+    
+    def flush(self):
+        """Write the index, and the history cache, to disk if they changed since last saved (at shutdown)."""
+        self._save_cache()
+        self.blob_cache.save()
+    
+    def cache_status(self) -> Dict[str, Any]:
+        """Describe the persisted index and how well it has served this session."""
+        return {
+            "cache_file": str(self.index_cache.path),
+            "exists": self.index_cache.path.is_file(),
+            "size_bytes": self.index_cache.size(),
+            "commit": self.commit_sha,
+            "loaded_from": self.index_cache.loaded_from,
+            "load_error": self.index_cache.load_error,
+            "files_in_index": sum(len(index) for index in self._parse_indexes()),
+            **self.cache_stats,
+        }
+    
+    def usage_stats(self) -> Dict[str, Any]:
+        """
+        What server_stats reports of this index, from what is in memory:
+        nothing is walked, parsed or measured anew, so it is cheap at any
+        size. approximate_bytes is only there once index_stats has measured
+        every file.
+        """
+        files = symbols = total = 0
+        measured = True
+        for index in self._parse_indexes():
+            for path, entry in list(index.items()):
+                parsed = entry["parsed"]
+                files += 1
+                symbols += len(parsed["symbols"]) + len(parsed.get("nested", []))
+                cached = self._sizes.get(path)
+                if cached is None or cached[0] is not parsed:
+                    measured = False
+                else:
+                    total += cached[1]
+        looked_up = self.cache_stats["hits"] + self.cache_stats["misses"]
+        refresh = self.refresh_latency.summary()
+        return {
+            "root": str(self.source_root),
+            "ref": self.ref_commit,
+            "files": files,
+            "symbols": symbols,
+            "approximate_bytes": total if measured and files else None,
+            "cache_bytes": self.index_cache.size(),
+            "call_graph": {"nodes": len(self._graph.nodes), "edges": len(self._graph.edges)}
+                          if self._graph is not None else None,
+            "cache": {"hits": self.cache_stats["hits"], "misses": self.cache_stats["misses"],
+                      "hit_rate": round(self.cache_stats["hits"] / looked_up, 3) if looked_up else None},
+            "refresh": refresh if refresh["count"] else None,
+            "indexed_at": iso_date(int(self.last_walk["at"])) if self.last_walk else None,
+        }
+    
+    def index_stats(self, max_packages: int = 20) -> Dict[str, Any]:
+        """
+        Count what the index holds and estimate the memory it takes.
         
-        cache_file = self.cache_dir / "symbols.pkl"
-        try:
-            with open(cache_file, 'wb') as f:
-                pickle.dump(self._cache, f)
-        except:
-            pass
+        Sizes are approximate (see core/memory.py): each parse result is
+        measured on its own, so a string shared by several files counts in
+        each of them and the estimate errs high.
+        
+        Args:
+            max_packages: Largest packages listed, by approximate bytes
+            
+        Returns:
+            {"files", "symbols", "references", "approximate_bytes", "by_language",
+             "memory", "call_graph", "history_cache", "package_count", "packages"};
+            references are qualified references (pkg.Name) plus Go call
+            sites, history_cache the parses of old file versions history
+            tools keep (see core/blob_cache.py), with its hits and misses
+        """
+        self._go_project()
+        scopes: Dict[str, str] = {}
+        packages: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        by_language: Dict[str, Dict[str, int]] = {}
+        for index in self._parse_indexes():
+            for path, entry in sorted(index.items()):
+                parsed = entry["parsed"]
+                language = self._language_of(path) or "go"
+                key = (language, os.path.dirname(path))
+                if key not in packages:
+                    scope = self._symbol_scope(path, language, scopes) if language == "go" \
+                        else relative(os.path.dirname(path), self.root_path)
+                    packages[key] = {"package": scope, "language": language, "files": 0, "symbols": 0,
+                                     "references": 0, "bytes": 0}
+                counts = {"files": 1, "symbols": len(parsed["symbols"]) + len(parsed.get("nested", [])),
+                          "references": len(parsed.get("qualified_refs", [])) + call_sites(parsed),
+                          "bytes": self._file_size(path, parsed)}
+                totals = by_language.setdefault(language, {"files": 0, "symbols": 0, "references": 0, "bytes": 0})
+                for field, count in counts.items():
+                    packages[key][field] += count
+                    totals[field] += count
+                if isinstance(parsed, OnDemand):
+                    # Bodies re-parsed when read: what keeping them would take
+                    packages[key]["lean"] = True
+                    packages[key]["on_demand_bytes"] = packages[key].get("on_demand_bytes", 0) + parsed.body_bytes
+        ranked = sorted(packages.values(), key=lambda p: (-p["bytes"], p["language"], p["package"]))
+        total = sum(p["bytes"] for p in ranked)
+        budget = self._config.get("max_memory_mb")
+        result: Dict[str, Any] = {
+            "files": sum(p["files"] for p in ranked),
+            "symbols": sum(p["symbols"] for p in ranked),
+            "references": sum(p["references"] for p in ranked),
+            "approximate_bytes": total,
+            "by_language": dict(sorted(by_language.items())),
+            "memory": {
+                "max_memory_mb": budget,
+                "within_budget": total <= budget * MEGABYTE if budget else None,
+                "lean_packages": sum(1 for p in ranked if p.get("lean")),
+                "on_demand_bytes": sum(p.get("on_demand_bytes", 0) for p in ranked),
+            },
+            "call_graph": None,
+            "history_cache": self.blob_cache.stats(),
+            "package_count": len(ranked),
+            "packages": ranked[:max_packages],
+        }
+        if self._graph is not None:
+            result["call_graph"] = {"nodes": len(self._graph.nodes), "edges": len(self._graph.edges),
+                                    "approximate_bytes": approximate_size([self._graph.nodes, self._graph.edges])}
+        return result
+    
+    def diagnostics(self, repair: bool = False, max_paths: int = 20) -> Dict[str, Any]:
+        """
+        Look inside the index for what would make results wrong, as it is
+        now - without catching it up with the tree first.
+        
+        Categories: files on disk the index does not have ("not_indexed":
+        new since the last walk, failed to read or parse, or excluded by a
+        rule, with the reason), entries whose file is gone ("deleted") or
+        whose stored hash no longer matches the file ("stale"), parse errors
+        per language, call graph edges to or from functions that no longer
+        exist ("dangling_edges") and the integrity of the persisted index
+        files ("cache"). Exclusions and parse errors are reported but are
+        not problems of the index: "healthy" ignores them.
+        
+        Args:
+            repair: Fix what can be fixed - re-parse stale and new files, drop
+                deleted entries and dangling edges, rewrite or delete corrupt
+                cache files - and report what was done
+            max_paths: Maximum example paths listed per category
+        """
+        rel = lambda path: relative(path, self.root_path)
+        sample = lambda paths: [rel(p) for p in sorted(paths)[:max_paths]]
+        indexed: Set[str] = set()
+        deleted, stale, rehash = [], [], []
+        parse_errors: Dict[str, Dict[str, Any]] = {}
+        touched = 0
+        for index in self._parse_indexes():
+            for path, entry in sorted(index.items()):
+                indexed.add(path)
+                try:
+                    stat = os.stat(path)
+                except OSError:
+                    deleted.append(path)
+                    continue
+                parsed = entry["parsed"]
+                errors = [parsed["parseError"]] if parsed.get("parseError") else parsed.get("parseErrors", [])
+                if errors:
+                    language = self._language_of(path) or "go"
+                    counts = parse_errors.setdefault(language, {"files": 0, "errors": 0, "paths": []})
+                    counts["files"] += 1
+                    counts["errors"] += len(errors)
+                    if len(counts["paths"]) < max_paths:
+                        counts["paths"].append(rel(path))
+                try:
+                    content, encoding = read_source(path)
+                except OSError:
+                    deleted.append(path)
+                    continue
+                if content_hash(content, encoding) != entry["hash"]:
+                    stale.append(path)
+                    if entry["stamp"] == (stat.st_mtime_ns, stat.st_size):
+                        # Same mtime and size: a refresh would trust the entry as it is
+                        rehash.append((index, path))
+                elif entry["stamp"] != (stat.st_mtime_ns, stat.st_size):
+                    touched += 1
+        for path in self._cache.get("file-lines", {}):
+            indexed.add(path)
+            if not os.path.exists(path):
+                deleted.append(path)
+        
+        skipped: Dict[str, List[str]] = {}
+        new, failed = [], []
+        for file_path in self._iter_source_files(skipped=skipped):
+            path = str(file_path)
+            if path in self._unindexed:
+                failed.append({"path": rel(path), "reason": self._unindexed[path]["message"]})
+            elif path not in indexed:
+                new.append(path)
+        
+        result: Dict[str, Any] = {
+            "not_indexed": {
+                "new": {"count": len(new), "paths": sample(new)},
+                "failed": {"count": len(failed), "paths": failed[:max_paths]},
+                "excluded": [
+                    {"reason": reason, "count": len(paths), "paths": paths[:max_paths]}
+                    for reason, paths in sorted(skipped.items(), key=lambda item: (-len(item[1]), item[0]))
+                ],
+            },
+            "deleted": {"count": len(deleted), "paths": sample(deleted)},
+            # touched: mtime moved, content the same - not stale
+            "stale": {"count": len(stale), "paths": sample(stale), "touched": touched},
+            "parseErrors": {
+                "files": sum(c["files"] for c in parse_errors.values()),
+                "errors": sum(c["errors"] for c in parse_errors.values()),
+                "by_language": dict(sorted(parse_errors.items())),
+            },
+        }
+        graph = self._graph
+        dangling = graph.dangling_edges() if graph is not None else []
+        result["dangling_edges"] = {
+            # The call graph is only checked once a tool has built it
+            "checked": graph is not None,
+            "count": len(dangling),
+            "edges": [{"caller": edge["caller"][1], "callee": edge["callee"][1], "path": rel(edge["path"]),
+                       "line": edge["line"]} for edge in dangling[:max_paths]],
+        }
+        cache = self.index_cache.verify()
+        corrupt = [entry for entry in [cache] + cache["others"] if entry.get("valid") is False]
+        result["cache"] = {**cache, "unsaved_changes": self._cache_dirty}
+        issues = len(new) + len(failed) + len(deleted) + len(stale) + len(dangling) + len(corrupt)
+        result["issues"] = issues
+        result["healthy"] = issues == 0
+        if not repair:
+            return result
+        
+        repaired: Dict[str, Any] = {}
+        for index, path in rehash:
+            # Replaced, not edited (see tracking): the next walk compares hashes and re-parses
+            index[path] = {**index[path], "stamp": None}
+        if new or deleted or stale:
+            had_graph = self._graph is not None
+            self._go_project()
+            if had_graph:
+                self._call_graph()
+            refresh = self.last_refresh
+            repaired.update({"added": len(refresh["added"]), "reparsed": len(refresh["modified"]),
+                             "removed": len(refresh["removed"])})
+        if self._graph is not None:
+            dangling = self._graph.dangling_edges()
+            if dangling:
+                repaired["dropped_edges"] = self._graph.drop_edges(dangling)
+        if corrupt:
+            for entry in corrupt:
+                Path(entry["path"]).unlink(missing_ok=True)
+            repaired["deleted_cache_files"] = len(corrupt)
+            self._cache_dirty = True
+        if self._cache_dirty:
+            self._save_cache()
+            repaired["cache_saved"] = not self._cache_dirty
+        if failed:
+            # Unreadable or unparsable files stay out until they are fixed
+            repaired["not_repairable"] = {"failed": len(failed)}
+        result["repaired"] = repaired
+        return result
+    
+    def clear_cache(self) -> Dict[str, Any]:
+        """Delete the persisted index of this project (every commit) and drop it from memory."""
+        freed = self.index_cache.clear()
+        self._cache = {}
+        self._project = None
+        self._modules = None
+        self._python = None
+        self._rust = None
+        self._java = None
+        self._protos = None
+        self._sql = None
+        self._tasks = None
+        self._graph = None
+        self._context_graphs = {}
+        self._buffers = {}
+        self._signatures = None
+        self._sizes = {}
+        self._terms = {}
+        self.cache_stats = {"persisted_files": 0, "hits": 0, "misses": 0}
+        self._cache_dirty = False
+        return {"cleared": str(self.index_cache.project_dir), "bytes_freed": freed}
+    
+    def _snapshot(self) -> Dict[str, Any]:
+        """The symbol table of the current tree, as a snapshot (see xray.core.snapshots)."""
+        graph = self._call_graph()
+        scopes: Dict[str, str] = {}
+        sites: Dict[Tuple[str, str], int] = {}
+        for edge in graph.edges:
+            if not edge["external"] and edge["callee"][0]:
+                sites[edge["callee"]] = sites.get(edge["callee"], 0) + 1
+        indexes = [{path: entry for path, entry in self._go_file_index().items() if path in graph.project.files},
+                   self._ts_file_index(), self._py_file_index(), self._rs_file_index(), self._java_file_index(),
+                   self._proto_file_index(), self._sql_file_index(), self._task_file_index()]
+        symbols: Dict[str, Any] = {}
+        packages: Dict[str, Dict[str, int]] = {}
+        for index in indexes:
+            for path, entry in sorted(index.items()):
+                declarations = [d for d in self._declarations(path, entry["parsed"], scopes) if not d["nested"]]
+                language = self._language_of(path) or "go"
+                scope = self._symbol_scope(path, language, scopes) if language == "go" \
+                    else relative(os.path.dirname(path), self.root_path)
+                package = packages.setdefault(symbol_key(language, scope, ""), {"files": 0, "lines": 0, "symbols": 0})
+                package["files"] += 1
+                package["lines"] += entry.get("lines", 0)
+                package["symbols"] += len(declarations)
+                for decl in declarations:
+                    references = None
+                    if decl["language"] == "go" and decl["kind"] in ("function", "method") and not decl["member"]:
+                        references = sites.get((os.path.dirname(path), decl["qualified"]), 0)
+                    symbols.setdefault(symbol_key(decl["language"], decl["scope"], decl["qualified"]), (
+                        decl["kind"], decl["language"], decl["signature"], relative(path, self.root_path),
+                        decl["line"], references))
+        return new_snapshot(symbols, packages, self.ref_commit or self.commit_sha, self.ref)
+    
+    def snapshot_index(self, name: str) -> Dict[str, Any]:
+        """
+        Save the symbol table of the current tree under a name, committed or
+        not, to compare later states against (see xray.core.snapshots).
+        
+        Args:
+            name: The snapshot's label; one of the same name is replaced
+        """
+        store = SnapshotStore(self.source_root)
+        snapshot = self._snapshot()
+        size = store.save(name, snapshot)
+        return {"name": name, "created_at": snapshot["created_at"], "commit": snapshot["commit"], "ref": self.ref,
+                "symbols": len(snapshot["symbols"]), "packages": len(snapshot["packages"]), "bytes": size}
+    
+    def compare_snapshots(self, a: str, b: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Diff two snapshots, or one against the current tree.
+        
+        Args:
+            a: The earlier snapshot
+            b: The later snapshot; None compares a with the tree as it is now
+            
+        Returns:
+            Dictionary with the symbols added, removed, changed in signature
+            or moved, Go functions whose call sites changed in number, and
+            packages whose size changed
+        """
+        store = SnapshotStore(self.source_root)
+        old = store.load(a)
+        new = store.load(b) if b is not None else self._snapshot()
+        result = compare(old, new, a, b if b is not None else "(current)")
+        for key in ("added", "removed", "signature_changed", "moved", "references"):
+            for entry in result[key]:
+                entry["path"] = str(self.root_path / entry["path"])
+                if "old_path" in entry:
+                    entry["old_path"] = str(self.root_path / entry["old_path"])
+        return result
+    
+    def list_snapshots(self) -> Dict[str, Any]:
+        """The snapshots saved for this project, oldest first."""
+        snapshots = SnapshotStore(self.source_root).list()
+        return {"snapshots": snapshots, "total_count": len(snapshots)}
+    
+    def delete_snapshot(self, name: str) -> Dict[str, Any]:
+        """Delete a saved snapshot."""
+        store = SnapshotStore(self.source_root)
+        if not store.delete(name):
+            raise FileNotFound(f"No snapshot named '{name}'")
+        return {"deleted": name}
     
     def _get_cache_key(self, file_path: Path) -> str:
         """Generate cache key for a file."""
@@ -118,8 +746,8 @@ class XRayIndexer:
         Returns:
             Formatted tree string
         """
-        # Get gitignore patterns if available
-        gitignore_patterns = self._parse_gitignore()
+        # Load .gitignore rules (nested, info/exclude and global excludes)
+        ignore_rules = self._parse_gitignore()
         
         # Build the tree
         tree_lines = []
@@ -127,7 +755,7 @@ class XRayIndexer:
             self.root_path, 
             tree_lines, 
             "", 
-            gitignore_patterns,
+            ignore_rules,
             current_depth=0,
             max_depth=max_depth,
             include_symbols=include_symbols,
@@ -142,44 +770,187 @@ class XRayIndexer:
         
         return "\n".join(tree_lines)
     
-    def _parse_gitignore(self) -> Set[str]:
-        """Parse .gitignore file if it exists."""
-        patterns = set()
-        gitignore_path = self.root_path / ".gitignore"
-        
-        if gitignore_path.exists():
-            try:
-                with open(gitignore_path, 'r', encoding='utf-8') as f:
-                    for line in f:
-                        line = line.strip()
-                        if line and not line.startswith('#'):
-                            patterns.add(line)
-            except Exception:
-                pass
-        
-        return patterns
+    def _parse_gitignore(self) -> GitIgnore:
+        """Load the ignore rules that apply under the project root, and its configuration file."""
+        self._project_config()
+        return GitIgnore(self.root_path)
     
-    def _should_exclude(self, path: Path, gitignore_patterns: Set[str]) -> bool:
-        """Check if a path should be excluded."""
+    def _project_config(self) -> ProjectConfig:
+        """
+        The project's configuration file, re-read when it changed since the
+        last call. Raises InvalidConfig when it does not validate.
+        """
+        config = load_config(self.source_root, sorted(set(LANGUAGE_MAP.values())), self._config)
+        if config is not self._config:
+            self._config = config
+            self._config_excludes = PathGlobs(self.root_path, exclude=config.get("exclude"))
+        if config.errors:
+            raise InvalidConfig(f"{os.path.basename(config.path)} is invalid: {'; '.join(config.errors)}", config.path)
+        return config
+    
+    def setting(self, key: str, value: Any, default: Any) -> Any:
+        """A tool parameter; left out (None), the project's configured value, else default."""
+        if value is not None:
+            return value
+        return self._project_config().get(key, default)
+    
+    def _exclusion_reason(self, path: Path, ignore_rules: GitIgnore) -> Optional[str]:
+        """Explain why a path is left out of the index, or None if it is indexed."""
         name = path.name
         
         # Check default exclusions
         if name in DEFAULT_EXCLUSIONS:
-            return True
+            return f"default: {name}"
         
         # Check file pattern exclusions
         for pattern in DEFAULT_EXCLUSIONS:
             if '*' in pattern and fnmatch.fnmatch(name, pattern):
-                return True
+                return f"default: {pattern}"
         
-        # Check gitignore patterns (simplified)
-        for pattern in gitignore_patterns:
-            if pattern in str(path.relative_to(self.root_path)):
-                return True
-            if fnmatch.fnmatch(name, pattern):
-                return True
+        submodules = self._submodule_map()
+        if submodules and submodules.is_submodule(path):
+            if not self.include_submodules:
+                return "submodule"
+            if not submodules.modules[str(path)]["initialized"]:
+                return "submodule: not initialized"
+        
+        rule = ignore_rules.match(path)
+        if rule:
+            return f"gitignore: {rule}"
+        
+        config = self._config
+        pattern = self._config_excludes.excluded_by(str(path))
+        if pattern:
+            return f"config: exclude {pattern}"
+        if path.is_symlink():
+            return self._symlink_reason(path)
+        language = self._language_of(path)
+        if language and not config.language_enabled(language) and path.is_file():
+            return f"config: {language} disabled"
+        max_size = config.get("max_file_size")
+        if max_size and language and path.is_file() and path.stat().st_size > max_size:
+            return f"config: larger than max_file_size ({max_size} bytes)"
+        if language and path.is_file() and self._is_binary(path):
+            return "binary"
         
-        return False
+        if not self.include_generated and path.suffix == ".go" and path.is_file() and is_generated_go(path):
+            return "generated"
+        headers = config.get("generated_headers")
+        if headers and not self.include_generated and language and path.is_file():
+            pattern = generated_header(path, headers)
+            if pattern:
+                return f"generated: {pattern}"
+        
+        return None
+    
+    def _symlink_reason(self, path: Path) -> str:
+        """
+        Why a symlink is not walked. One leading outside the project, or
+        nowhere, leaves its files out; one leading inside is an alias: its
+        files are indexed once, under their own paths, and the link recorded
+        so a path through it resolves to them.
+        """
+        try:
+            target = path.resolve(strict=True)
+        except FileNotFoundError:
+            return "symlink: dangling"
+        except (OSError, RuntimeError):
+            # ELOOP, or RuntimeError before Python 3.13
+            return "symlink: loop"
+        if not target.is_relative_to(self.root_path):
+            return "symlink: outside project"
+        self._symlinks[relative(path, self.root_path)] = relative(target, self.root_path)
+        return SYMLINK_ALIAS
+    
+    def _languages(self) -> LanguageMap:
+        """The project's extension mapping over the server's, rebuilt when the configuration file changed."""
+        config = self._config
+        if self._language_table is None or self._language_table[0] is not config:
+            self._language_table = (config, LanguageMap(config.get("extensions"), self.language_map))
+        return self._language_table[1]
+    
+    def _language_of(self, path) -> Optional[str]:
+        """
+        The language of a file: the project's or the server's mapping, else
+        its name or extension, else - without an extension - the shebang or
+        modeline of its first lines (see core/languages.py); None if unknown.
+        """
+        mapped = self._languages().language(path)
+        if mapped:
+            return mapped
+        language = language_of(path)
+        if language or os.path.splitext(os.path.basename(str(path)))[1]:
+            return language
+        return self._sniff(str(path))
+    
+    def _sniff(self, path: str) -> Optional[str]:
+        """What an extensionless file declares itself, sniffed again only when its mtime or size moves."""
+        try:
+            stat = os.stat(path)
+        except OSError:
+            return None
+        if not S_ISREG(stat.st_mode):
+            return None
+        stamp = (stat.st_mtime_ns, stat.st_size)
+        cached = self._sniffed.get(path)
+        if cached is None or cached[0] != stamp:
+            cached = (stamp, sniff_file(path))
+            self._sniffed[path] = cached
+        return cached[1]
+    
+    def _unknown_reason(self, path: Path) -> Optional[str]:
+        """Why a file of no known language is not listed (as "unknown"), None if it is."""
+        max_size = self._config.get("max_file_size") or self._config.get("partial_file_size", PARTIAL_FILE_SIZE)
+        try:
+            if path.stat().st_size > max_size:
+                return f"larger than {max_size} bytes"
+        except OSError:
+            return "unreadable"
+        return "binary" if self._is_binary(path) else None
+    
+    def _is_binary(self, path: Path) -> bool:
+        """Whether a source-named file is binary, sniffed again only when its mtime or size moves."""
+        stat = path.stat()
+        stamp = (stat.st_mtime_ns, stat.st_size)
+        cached = self._binary.get(str(path))
+        if cached is None or cached[0] != stamp:
+            cached = (stamp, is_binary(str(path)))
+            self._binary[str(path)] = cached
+        return cached[1]
+    
+    def _parses_partially(self, path: str, size: int) -> bool:
+        """Whether a source file of this size is indexed from its declaration skeleton only."""
+        return size > self._config.get("partial_file_size", PARTIAL_FILE_SIZE) and self._language_of(path) not in WHOLE_FILE_LANGUAGES
+    
+    def _parses_shallow(self, path: str) -> bool:
+        """Whether a quick index parses a source file from its skeleton for now: its package is not deep yet."""
+        return self._deep_packages is not None and os.path.dirname(path) not in self._deep_packages and \
+            self._language_of(path) not in WHOLE_FILE_LANGUAGES
+    
+    def _parse_outdated(self, path: str, size: int, parsed: Dict[str, Any]) -> bool:
+        """
+        Whether a file's parse result no longer has the depth it should: a
+        quick index's skeleton once its package's turn came, or a result the
+        partial threshold has moved past. A full parse is kept while its
+        package waits, a quick index never trading it for a skeleton.
+        """
+        if parsed.get("shallow"):
+            return not self._parses_shallow(path)
+        return bool(parsed.get("partial")) != self._parses_partially(path, size)
+    
+    def _submodule_map(self) -> Submodules:
+        """The git submodules under the root, read from .gitmodules once per walk."""
+        if self._submodules is None:
+            self._submodules = Submodules(self.root_path)
+        return self._submodules
+    
+    def _repo_for(self, path: str) -> GitRepo:
+        """The repository holding a file's history: its submodule's, or the project's."""
+        return self._submodule_map().repo_for(path, self._git(self.source_root), self._cancel)
+    
+    def _should_exclude(self, path: Path, ignore_rules: GitIgnore) -> bool:
+        """Check if a path should be excluded."""
+        return self._exclusion_reason(path, ignore_rules) is not None
     
     def _should_include_dir(self, path: Path, focus_dirs: Optional[List[str]], current_depth: int) -> bool:
         """Check if a directory should be included based on focus_dirs."""
@@ -194,7 +965,7 @@ class XRayIndexer:
         path: Path, 
         tree_lines: List[str], 
         prefix: str, 
-        gitignore_patterns: Set[str],
+        ignore_rules: GitIgnore,
         current_depth: int,
         max_depth: Optional[int],
         include_symbols: bool,
@@ -203,7 +974,7 @@ class XRayIndexer:
         is_last: bool = False
     ):
         """Recursively build the tree representation with enhanced features."""
-        if self._should_exclude(path, gitignore_patterns):
+        if self._should_exclude(path, ignore_rules):
             return
         
         # Check depth limit
@@ -219,7 +990,7 @@ class XRayIndexer:
         connector = "└── " if is_last else "├── "
         
         # For files, add skeleton if requested
-        if path.is_file() and include_symbols and path.suffix.lower() in LANGUAGE_MAP:
+        if path.is_file() and include_symbols and self._language_of(path) is not None:
             skeleton = self._get_file_skeleton_enhanced(path, max_symbols_per_file)
             if skeleton:
                 # Format with indented skeleton
@@ -253,7 +1024,7 @@ class XRayIndexer:
             try:
                 children = sorted(path.iterdir(), key=lambda p: (not p.is_dir(), p.name.lower()))
                 # Filter out excluded items
-                children = [c for c in children if not self._should_exclude(c, gitignore_patterns)]
+                children = [c for c in children if not self._should_exclude(c, ignore_rules)]
                 
                 # Apply focus_dirs filter at top level
                 if current_depth == 0 and focus_dirs:
@@ -268,7 +1039,7 @@ class XRayIndexer:
                         child, 
                         tree_lines, 
                         new_prefix, 
-                        gitignore_patterns,
+                        ignore_rules,
                         current_depth + 1,
                         max_depth,
                         include_symbols,
@@ -287,21 +1058,16 @@ class XRayIndexer:
             cached_symbols = self._cache[cache_key]
             return self._format_enhanced_skeleton(cached_symbols, max_symbols)
         
-        language = LANGUAGE_MAP.get(file_path.suffix.lower())
-        if not language:
+        if self._language_of(file_path) not in NATIVE_LANGUAGES:
             return []
         
         try:
-            with open(file_path, 'r', encoding='utf-8') as f:
-                content = f.read()
-            
-            if language == "python":
-                symbols = self._extract_python_symbols_enhanced(content)
-            else:
-                symbols = self._extract_regex_symbols_enhanced(content, language)
+            symbols = [s for s in self._get_symbols(file_path)
+                       if "container" not in s and s["type"] not in ("module", "package")]
             
             # Cache the results
             self._cache[cache_key] = symbols
+            self._cache_dirty = True
             
             return self._format_enhanced_skeleton(symbols, max_symbols)
         
@@ -319,7 +1085,7 @@ class XRayIndexer:
         for symbol in symbols[:shown_count]:
             line = symbol['signature']
             if symbol.get('doc'):
-                line += f" # {symbol['doc']}"
+                line += f" # {symbol['doc'].splitlines()[0]}"
             lines.append(line)
         
         if len(symbols) > max_symbols:
@@ -328,230 +1094,5834 @@ class XRayIndexer:
         
         return lines
     
-    def _extract_python_symbols_enhanced(self, content: str) -> List[Dict[str, str]]:
-        """Extract Python symbols with signatures and docstrings."""
-        symbols = []
+    def _go_file_index(self) -> Dict[str, Dict[str, Any]]:
+        """Per-file Go parse results: path -> {"stamp": (mtime_ns, size), "hash", "lines", "parsed"}."""
+        return self._cache.setdefault(f"go-index:{PARSER_VERSION}", {})
+    
+    def _ts_file_index(self) -> Dict[str, Dict[str, Any]]:
+        """Per-file TypeScript/JavaScript parse results, shaped like the Go index."""
+        return self._cache.setdefault(f"ts-index:{TS_PARSER_VERSION}", {})
+    
+    def _py_file_index(self) -> Dict[str, Dict[str, Any]]:
+        """Per-file Python parse results, shaped like the Go index."""
+        return self._cache.setdefault(f"py-index:{PY_PARSER_VERSION}", {})
+    
+    def _rs_file_index(self) -> Dict[str, Dict[str, Any]]:
+        """Per-file Rust parse results, shaped like the Go index."""
+        return self._cache.setdefault(f"rs-index:{RS_PARSER_VERSION}", {})
+    
+    def _java_file_index(self) -> Dict[str, Dict[str, Any]]:
+        """Per-file Java parse results, shaped like the Go index."""
+        return self._cache.setdefault(f"java-index:{JAVA_PARSER_VERSION}", {})
+    
+    def _proto_file_index(self) -> Dict[str, Dict[str, Any]]:
+        """Per-file Protocol Buffers parse results, shaped like the Go index."""
+        return self._cache.setdefault(f"proto-index:{PROTO_PARSER_VERSION}", {})
+    
+    def _sql_file_index(self) -> Dict[str, Dict[str, Any]]:
+        """Per-file SQL parse results, shaped like the Go index."""
+        return self._cache.setdefault(f"sql-index:{SQL_PARSER_VERSION}", {})
+    
+    def _task_file_index(self) -> Dict[str, Dict[str, Any]]:
+        """Per-file Makefile and shell script parse results, shaped like the Go index."""
+        return self._cache.setdefault(f"task-index:{TASK_PARSER_VERSION}", {})
+    
+    def _parse_indexes(self) -> List[Dict[str, Dict[str, Any]]]:
+        """The Go, TypeScript/JavaScript, Python, Rust, Java, .proto, .sql and Makefile/shell parse indexes."""
+        return [self._go_file_index(), self._ts_file_index(), self._py_file_index(), self._rs_file_index(),
+                self._java_file_index(), self._proto_file_index(), self._sql_file_index(), self._task_file_index()]
+    
+    def _file_index(self, file_path: Path) -> Dict[str, Dict[str, Any]]:
+        """The parse index holding a Go, TypeScript, JavaScript, Python, Rust, Java, .proto, .sql, Makefile or shell file."""
+        language = self._language_of(file_path)
+        if language == "go":
+            return self._go_file_index()
+        if language == "rust":
+            return self._rs_file_index()
+        if language == "java":
+            return self._java_file_index()
+        if language == "proto":
+            return self._proto_file_index()
+        if language == "sql":
+            return self._sql_file_index()
+        if language in ("make", "shell"):
+            return self._task_file_index()
+        return self._py_file_index() if language == "python" else self._ts_file_index()
+    
+    def _refresh_file(self, file_path: Path, content: Optional[str] = None) -> Tuple[Dict[str, Any], bool]:
+        """
+        Return a source file's parse result and whether it had to be re-parsed.
+        
+        An unchanged mtime and size is trusted as-is; otherwise the content
+        hash decides, so touching a file without editing it costs a read but
+        not a parse.
+        """
+        index = self._file_index(file_path)
+        stat = file_path.stat()
+        stamp = (stat.st_mtime_ns, stat.st_size)
+        entry = index.get(str(file_path))
+        if entry and self._parse_outdated(str(file_path), stat.st_size, entry["parsed"]):
+            entry = None
+        if entry and entry["stamp"] == stamp:
+            return self._noted(str(file_path), entry["parsed"]), False
+        
+        encoding = None
         try:
-            tree = ast.parse(content)
-            for node in ast.iter_child_nodes(tree):
-                if isinstance(node, ast.ClassDef):
-                    sig = f"class {node.name}"
-                    if node.bases:
-                        base_names = []
-                        for base in node.bases:
-                            if isinstance(base, ast.Name):
-                                base_names.append(base.id)
-                            elif isinstance(base, ast.Attribute):
-                                base_names.append(ast.unparse(base))
-                        if base_names:
-                            sig += f"({', '.join(base_names)})"
-                    sig += ":"
-                    
-                    doc = ast.get_docstring(node)
-                    if doc:
-                        doc = doc.split('\n')[0].strip()[:50]
-                    
-                    symbols.append({'signature': sig, 'doc': doc or ''})
-                    
-                elif isinstance(node, (ast.FunctionDef, ast.AsyncFunctionDef)):
-                    # Build function signature
-                    sig = "async def " if isinstance(node, ast.AsyncFunctionDef) else "def "
-                    sig += f"{node.name}("
-                    
-                    # Add parameters
-                    args = []
-                    for arg in node.args.args:
-                        args.append(arg.arg)
-                    if args:
-                        sig += ", ".join(args)
-                    sig += "):"
-                    
-                    doc = ast.get_docstring(node)
-                    if doc:
-                        doc = doc.split('\n')[0].strip()[:50]
-                    
-                    symbols.append({'signature': sig, 'doc': doc or ''})
-        except:
-            pass
-        return symbols
-    
-    def _extract_regex_symbols_enhanced(self, content: str, language: str) -> List[Dict[str, str]]:
-        """Extract symbols with signatures and comments for JS/TS/Go."""
-        symbols = []
-        
-        # Language-specific patterns
-        if language in ["javascript", "typescript"]:
-            patterns = [
-                # Function with preceding comment
-                (r'(?://\s*(.+?)\n)?^\s*(?:export\s+)?(?:async\s+)?function\s+(\w+)\s*\((.*?)\)', 
-                 lambda m: {'signature': f"function {m.group(2)}({m.group(3)}):", 'doc': (m.group(1) or '').strip()}),
-                
-                # Class with preceding comment
-                (r'(?://\s*(.+?)\n)?^\s*(?:export\s+)?class\s+(\w+)(?:\s+extends\s+(\w+))?', 
-                 lambda m: {'signature': f"class {m.group(2)}" + (f" extends {m.group(3)}" if m.group(3) else "") + ":", 
-                           'doc': (m.group(1) or '').strip()}),
-                
-                # Arrow function with const
-                (r'(?://\s*(.+?)\n)?^\s*(?:export\s+)?const\s+(\w+)\s*=\s*(?:async\s*)?\((.*?)\)\s*=>', 
-                 lambda m: {'signature': f"const {m.group(2)} = ({m.group(3)}) =>", 'doc': (m.group(1) or '').strip()}),
-            ]
-        elif language == "go":
-            patterns = [
-                # Function with preceding comment
-                (r'(?://\s*(.+?)\n)?^func\s+(\w+)\s*\((.*?)\)', 
-                 lambda m: {'signature': f"func {m.group(2)}({m.group(3)})", 'doc': (m.group(1) or '').strip()}),
-                
-                # Method with preceding comment
-                (r'(?://\s*(.+?)\n)?^func\s*\((\w+\s+[*]?\w+)\)\s*(\w+)\s*\((.*?)\)', 
-                 lambda m: {'signature': f"func ({m.group(2)}) {m.group(3)}({m.group(4)})", 
-                           'doc': (m.group(1) or '').strip()}),
-                
-                # Type struct with preceding comment
-                (r'(?://\s*(.+?)\n)?^type\s+(\w+)\s+struct', 
-                 lambda m: {'signature': f"type {m.group(2)} struct", 'doc': (m.group(1) or '').strip()}),
-            ]
+            if content is None:
+                content, encoding = read_source(str(file_path))
+            digest = content_hash(content, encoding)
+            if entry and entry["hash"] == digest:
+                index[str(file_path)] = {**entry, "stamp": stamp, "lines": len(source_lines(content))}
+                self._cache_dirty = True
+                return self._noted(str(file_path), entry["parsed"]), False
+            
+            shallow = self._parses_shallow(str(file_path))
+            parsed = parse_source(str(file_path), content, shallow or self._parses_partially(str(file_path), stat.st_size),
+                                  self._language_of(file_path))
+        except Exception as e:
+            # A caller skipping the file still reports it under the result's warnings
+            self._unindexed[str(file_path)] = describe_error(e, "Not indexed", str(file_path))
+            raise
+        self._unindexed.pop(str(file_path), None)
+        if shallow and parsed.get("partial"):
+            parsed["shallow"] = True
+        parsed = intern_strings(parsed)
+        if encoding:
+            parsed["transcodedFrom"] = encoding
+        index[str(file_path)] = {"stamp": stamp, "hash": digest, "lines": len(source_lines(content)), "parsed": parsed}
+        self.cache_stats["misses"] += 1
+        self._cache_dirty = True
+        return self._noted(str(file_path), parsed), True
+    
+    def _noted(self, path: str, parsed: Dict[str, Any]) -> Dict[str, Any]:
+        """Record whether a file's parse result is partial or transcoded, for present to mark its locations."""
+        if parsed.get("partial"):
+            self._partial.add(path)
         else:
-            return symbols
+            self._partial.discard(path)
+        if parsed.get("shallow"):
+            self._shallow.add(path)
+        else:
+            self._shallow.discard(path)
+        if parsed.get("transcodedFrom"):
+            self._transcoded[path] = parsed["transcodedFrom"]
+        else:
+            self._transcoded.pop(path, None)
+        return parsed
+    
+    def _parse_go_file(self, file_path: Path, content: Optional[str] = None) -> Dict[str, Any]:
+        """Parse a Go file with the native Go parser, caching the result."""
+        return self._refresh_file(file_path, content)[0]
+    
+    def _parsed_as(self, file_path: Path, language: Optional[str] = None) -> Dict[str, Any]:
+        """
+        A file's parse result, as language when a call forces one. A file
+        the index takes for another language is parsed on its own and never
+        enters the index; the last such parse of each file is kept.
+        """
+        if not language or language == self._language_of(file_path):
+            return self._refresh_file(file_path)[0]
+        if language not in NATIVE_LANGUAGES:
+            raise UnsupportedLanguage(f"XRAY has no parser for '{language}' - parsers: "
+                                      f"{', '.join(sorted(NATIVE_LANGUAGES))}", str(file_path))
+        stat = file_path.stat()
+        stamp = (stat.st_mtime_ns, stat.st_size)
+        cached = self._forced.get(str(file_path))
+        if cached is None or cached[0] != stamp or cached[1] != language:
+            content, encoding = read_source(str(file_path))
+            parsed = parse_source(str(file_path), content, self._parses_partially(str(file_path), stat.st_size),
+                                  language)
+            if encoding:
+                parsed["transcodedFrom"] = encoding
+            cached = self._forced[str(file_path)] = (stamp, language, parsed)
+        return cached[2]
+    
+    def _get_go_symbols(self, file_path: Path, content: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Return the symbol records of a Go file."""
+        return self._parse_go_file(file_path, content)["symbols"]
+    
+    def _get_symbols(self, file_path: Path, content: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Return the symbol records of a Go, TypeScript or JavaScript file."""
+        return self._refresh_file(file_path, content)[0]["symbols"]
+    
+    @staticmethod
+    def _qualified_name(symbol: Dict[str, Any]) -> str:
+        """
+        "Type.Method" for Go methods, "Owner.member" for TS/JS members,
+        "Parent.name" for nested Go declarations, else the bare name.
+        """
+        if symbol.get("receiver"):
+            return f"{symbol['receiver']['type']}.{symbol['name']}"
+        if symbol.get("parent") and symbol.get("type") != "field":
+            return f"{symbol['parent']}.{symbol['name']}"
+        if symbol.get("language", "go") != "go" and symbol.get("container"):
+            return f"{symbol['container']}.{symbol['name']}"
+        return symbol["name"]
+    
+    def _symbol_scope(self, path: str, language: str, scopes: Dict[str, str]) -> str:
+        """
+        The scope of a file's symbol IDs (see xray.core.symbol_ids): a Go
+        file's package import path, else the file relative to the root.
+        scopes memoizes the Go package directories looked up.
+        """
+        if language != "go":
+            return relative(path, self.root_path)
+        pkg_dir = os.path.dirname(path)
+        if pkg_dir not in scopes:
+            modules = GoModules.discover(str(self.root_path), [pkg_dir], self._module_reader(parse_go_mod),
+                                         self._module_reader(parse_go_work))
+            scopes[pkg_dir] = modules.import_path(pkg_dir) or relative(pkg_dir, self.root_path)
+        return scopes[pkg_dir]
+    
+    def _declarations(self, path: str, parsed: Dict[str, Any], scopes: Dict[str, str]) -> List[Dict[str, Any]]:
+        """The declarations of a parsed file, nested ones included, with their symbol IDs."""
+        declarations = []
+        for sym in parsed["symbols"] + parsed.get("nested", []):
+            language = sym.get("language") or self._language_of(path) or "go"
+            if language == "go" and sym.get("container"):
+                # Struct fields and interface method specs, as field_usages takes them
+                qualified = f"{sym['container']}.{sym['name']}"
+            else:
+                qualified = self._qualified_name(sym)
+            scope = self._symbol_scope(path, language, scopes)
+            declarations.append({
+                "id": symbol_id(language, scope, qualified, sym["type"], sym.get("signature")),
+                "name": sym["name"],
+                "qualified": qualified,
+                "scope": scope,
+                "path": path,
+                "line": sym["start_line"],
+                "column": sym.get("column"),
+                "range": sym.get("range"),
+                "kind": sym["type"],
+                "language": language,
+                "signature": sym.get("signature"),
+                "member": language == "go" and bool(sym.get("container")),
+                "nested": bool(sym.get("nested")),
+            })
+        return declarations
+    
+    def _symbol_arg(self, symbol: str, path: Optional[str] = None, kinds: Optional[Set[str]] = None,
+                    languages: Optional[Set[str]] = None) -> Tuple[str, Optional[str]]:
+        """
+        Resolve a tool's symbol argument to the qualified name and path the
+        tool looks it up by (see xray.core.symbol_ids).
         
-        # Apply patterns
-        for pattern, extractor in patterns:
-            for match in re.finditer(pattern, content, re.MULTILINE):
-                symbols.append(extractor(match))
+        A symbol ID stands for the declaration that has it now. A package
+        qualifier ("internal/api.Config", "api.Config") is replaced by the
+        package's directory (the file, outside Go) and a Go method expression
+        ("(*UserService).GetUser") by "Type.Method". A plain name is returned
+        as it is, once it is known to mean one declaration: among those of
+        the kinds and languages the tool handles (every declaration but Go
+        fields and interface method specs by default) and under path.
         
-        return symbols
+        Raises SymbolChanged, naming the closest declaration, for an ID none
+        has any more, and AmbiguousSymbol, listing the candidates, for a name
+        declared in several packages, files or receivers.
+        """
+        wanted = parse_symbol_id(symbol)
+        if wanted is not None:
+            return self._symbol_by_id(symbol, wanted)
+        package, name = split_qualifier(symbol)
+        scope = Path(self._scope(path)) if path else None
+        scopes: Dict[str, str] = {}
+        declared = self._named_declarations(name, kinds, languages, scope, scopes)
+        if package is None and not declared and "." in name:
+            # Not Type.member: the first part is the package
+            package, name = name.split(".", 1)
+            declared = self._named_declarations(name, kinds, languages, scope, scopes)
+        if package is not None:
+            declared = [d for d in declared if in_package(package, d["scope"], relative(d["path"], self.root_path))]
+        # Declarations sharing scope and name - build-constrained variants, overloads - are one symbol
+        distinct: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        for decl in declared:
+            distinct.setdefault((decl["scope"], decl["qualified"]), decl)
+        if len(distinct) > 1:
+            candidates = [{"symbol_id": d["id"], "name": d["qualified"], "package": d["scope"], "kind": d["kind"],
+                           "language": d["language"], "signature": d["signature"], "path": d["path"],
+                           "line": d["line"]} for d in distinct.values()]
+            raise AmbiguousSymbol(
+                f"'{symbol}' matches {len(candidates)} declarations; retry with one's symbol_id, "
+                f"a package qualifier (\"{candidates[0]['package']}.{candidates[0]['name']}\") or a path",
+                candidates)
+        if package is None:
+            return name, path
+        if not distinct:
+            raise SymbolNotFound(f"No declaration named '{name}' in package '{package}'")
+        match = next(iter(distinct.values()))
+        return match["qualified"], os.path.dirname(match["path"]) if match["language"] == "go" else match["path"]
     
-    def find_symbol(self, query: str, limit: int = 10) -> List[Dict[str, Any]]:
+    def _named_declarations(self, name: str, kinds: Optional[Set[str]], languages: Optional[Set[str]],
+                            scope: Optional[Path], scopes: Dict[str, str]) -> List[Dict[str, Any]]:
         """
-        Find symbols matching the query using fuzzy search.
-        Uses ast-grep to find all symbols, then fuzzy matches against the query.
+        The declarations a name - "Name" or "Type.member" - may mean, as
+        _locate_symbol matches them: nested Go declarations only when no
+        top-level one matches.
+        """
+        matches, nested = [], []
+        for file_path in self._iter_source_files(languages or NATIVE_LANGUAGES):
+            if scope is not None and file_path != scope and scope not in file_path.parents:
+                continue
+            try:
+                parsed = self._refresh_file(file_path)[0]
+            except Exception:
+                continue
+            for decl in self._declarations(str(file_path), parsed, scopes):
+                if (decl["kind"] not in kinds) if kinds is not None else decl["member"]:
+                    continue
+                if decl["qualified"] == name or ("." not in name and decl["name"] == name):
+                    (nested if decl["nested"] else matches).append(decl)
+        return matches or nested
+    
+    def _symbol_by_id(self, symbol: str, wanted: SymbolId) -> Tuple[str, Optional[str]]:
+        """The qualified name and file of the declaration with a symbol ID, raising SymbolChanged if none has it."""
+        scopes: Dict[str, str] = {}
+        
+        def declared(files: List[Path]) -> List[Dict[str, Any]]:
+            found = []
+            for file_path in files:
+                try:
+                    found.extend(self._declarations(str(file_path), self._refresh_file(file_path)[0], scopes))
+                except Exception:
+                    continue
+            return found
         
-        Returns a list of the top matching "Exact Symbol" objects.
+        files = list(self._iter_source_files({wanted.language}))
+        in_scope = [f for f in files if self._symbol_scope(str(f), wanted.language, scopes) == wanted.scope]
+        candidates = declared(in_scope)
+        match = next((d for d in candidates if d["id"] == symbol), None)
+        if match:
+            return match["qualified"], match["path"]
+        if not any(d["qualified"] == wanted.name for d in candidates):
+            # Moved or renamed: look for it in the rest of the project too
+            others = set(files) - set(in_scope)
+            candidates.extend(declared([f for f in files if f in others]))
+        closest = closest_match(wanted, candidates)
+        if closest is None:
+            raise SymbolNotFound(f"No declaration has the symbol ID '{symbol}', nor one like it")
+        raise SymbolChanged(
+            f"Symbol '{symbol}' changed; closest match is {closest['qualified']} ({closest['id']})",
+            {"symbol_id": closest["id"], "name": closest["qualified"], "path": closest["path"],
+             "line": closest["line"]}, closest["path"])
+    
+    def _allowed(self, path: Path) -> bool:
+        """Whether a path resolves into the allowed directories, or into the ref snapshot analyzed."""
+        real = path.resolve()
+        return (self.ref is not None and real.is_relative_to(self.root_path)) or self.allowlist.allows(real)
+    
+    def _resolve_path(self, path: str) -> Path:
         """
-        all_symbols = []
+        Resolve a path given as absolute or relative to the project root, in
+        the casing of the file on disk (see xray.core.paths), backslashes
+        read as separators. Raises PathNotAllowed if it leads outside the
+        allowed directories, and XRayError (INVALID_ARGUMENT) if it leads
+        outside the project root - through "..", "/" or a symlink - whether or
+        not directories are allowed.
+        """
+        if os.sep == "/" and "\\" in path and not (self.root_path / path).exists():
+            # A Windows client's separators
+            path = path.replace("\\", "/")
+        target = Path(path)
+        if not target.is_absolute():
+            target = self.root_path / target
+        elif self.ref and target.resolve().is_relative_to(self.source_root):
+            target = self.root_path / target.resolve().relative_to(self.source_root)
+        if not self._allowed(target):
+            self.allowlist.check(target)
+        real = target.resolve()
+        resolved = canonical_case(real)
+        if not real.is_relative_to(self.root_path) and not resolved.is_relative_to(canonical_case(self.root_path)):
+            raise XRayError(f"Path '{path}' is outside the project root {self.source_root} (resolves to '{real}')",
+                            path)
+        if self._deep_packages is not None:
+            # Quick index: a package a query names is parsed in full when the index next catches up
+            self._deep_packages.add(str(resolved if resolved.is_dir() else resolved.parent))
+        return resolved
+    
+    def _scope(self, path: Optional[str]) -> Optional[str]:
+        """
+        A path narrowing a query, resolved as _resolve_path does; raises
+        FileNotFound for one that does not exist, which would otherwise
+        narrow it to nothing.
+        """
+        if not path:
+            return None
+        target = self._resolve_path(path)
+        if not target.exists():
+            shown = target.relative_to(self.root_path).as_posix() if target.is_relative_to(self.root_path) else target
+            raise FileNotFound(f"Path '{path}' does not exist (resolved to '{shown}')", str(self._source_path(str(target))))
+        return str(target)
+    
+    def _path_globs(self, include: Optional[List[str]], exclude: Optional[List[str]]) -> PathGlobs:
+        """
+        The include and exclude globs of a query (see PathGlobs); raises
+        FileNotFound when the include patterns match no indexed file.
+        """
+        globs = PathGlobs(self.root_path, include, exclude)
+        if globs.include and not any(globs.included(path) for index in
+                                     self._parse_indexes() + [self._cache.get("file-lines", {})] for path in index):
+            raise FileNotFound(f"No indexed file matches include {globs.include}")
+        return globs
+    
+    def _source_path(self, path: str) -> str:
+        """Map a path inside a ref snapshot back to the project's own path."""
+        if self.ref and Path(path).is_relative_to(self.root_path):
+            return str(self.source_root / Path(path).relative_to(self.root_path))
+        return path
+    
+    def _indexed_declarations(self) -> Callable[[str], List[Dict[str, Any]]]:
+        """The declarations of a file as present finds them in the index, without parsing anything."""
+        scopes: Dict[str, str] = {}
         
-        # Define patterns for different symbol types
-        patterns = [
-            # Python functions and classes
-            ("def $NAME($$$):", "function"),
-            ("class $NAME($$$):", "class"),
-            ("async def $NAME($$$):", "function"),
-            
-            # JavaScript/TypeScript functions and classes
-            ("function $NAME($$$)", "function"),
-            ("const $NAME = ($$$) =>", "function"),
-            ("let $NAME = ($$$) =>", "function"),
-            ("var $NAME = ($$$) =>", "function"),
-            ("class $NAME", "class"),
-            ("interface $NAME", "interface"),
-            ("type $NAME =", "type"),
-            
-            # Go functions and types
-            ("func $NAME($$$)", "function"),
-            ("func ($$$) $NAME($$$)", "method"),
-            ("type $NAME struct", "struct"),
-            ("type $NAME interface", "interface"),
-        ]
+        def declarations(path: str) -> List[Dict[str, Any]]:
+            if self._language_of(path) not in NATIVE_LANGUAGES:
+                return []
+            entry = self._file_index(Path(path)).get(path)
+            return self._declarations(path, entry["parsed"], scopes) if entry else []
+        return declarations
+    
+    def present(self, result: Any) -> Any:
+        """
+        Prepare a result for callers: give every location a source range
+        (see xray.core.ranges) and every symbol its ID (see
+        xray.core.symbol_ids) and, when analyzing a ref, rewrite snapshot
+        paths to project paths and record which commit was analyzed. Locations
+        in files indexed only partially are marked "partial": true, those in
+        files not in UTF-8 "transcodedFrom": their encoding; while a quick
+        index has packages left to parse in full, a dict result is marked
+        "index_depth": "shallow" with the deep_index_percent done (not
+        "depth", which call graph results use for their own). On Windows the
+        project's paths are returned with forward slashes (see xray.core.paths).
+        """
+        add_ranges(result, str(self.root_path))
+        if self._partial or self._transcoded:
+            notes: Dict[str, Dict[str, Any]] = {path: {"partial": True} for path in self._partial}
+            for path, encoding in self._transcoded.items():
+                notes[path] = {**notes.get(path, {}), "transcodedFrom": encoding}
+            mark_files(result, notes, str(self.root_path))
+        if self._shallow and isinstance(result, dict) and "$schema" not in result:
+            # A quick index still parsing packages in full: body-level facts of the partial files are missing
+            result = {**result, "index_depth": "shallow", "deep_index_percent": self.deep_index_status()["percent"]}
+        add_symbol_ids(result, str(self.root_path), self._indexed_declarations())
+        if self.include_submodules and self._submodule_map():
+            self._submodule_map().mark(result)
+        if not self.ref:
+            return posix_paths(result, str(self.root_path))
+        snapshot, source = str(self.root_path), str(self.source_root)
         
-        # Run ast-grep for each pattern
-        for pattern, symbol_type in patterns:
-            cmd = [
-                "ast-grep",
-                "--pattern", pattern,
-                "--json",
-                str(self.root_path)
-            ]
-            
-            result = subprocess.run(cmd, capture_output=True, text=True)
+        def rewrite(value):
+            if isinstance(value, str):
+                return value.replace(snapshot, source) if snapshot in value else value
+            if isinstance(value, dict):
+                return {rewrite(k): rewrite(v) for k, v in value.items()}
+            if isinstance(value, (list, tuple)):
+                return [rewrite(v) for v in value]
+            return value
+        
+        result = posix_paths(rewrite(result), source)
+        info = {"name": self.ref, "commit": self.ref_commit}
+        if isinstance(result, dict):
+            result["ref"] = info
+        elif isinstance(result, list):
+            result = [{**item, "ref": info} if isinstance(item, dict) else item for item in result]
+        elif isinstance(result, str):
+            result = f"# {self.ref} @ {self.ref_commit}\n{result}"
+        return result
+    
+    def file_dependencies(self, path: str, language: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Resolve the imports of a Go, Python or Rust file into real dependencies.
+        
+        Aliased imports are attributed to their import path rather than the
+        alias, one path imported under several names is reported once, blank
+        imports are flagged as side-effect only, and dot imports list the
+        exported names they may contribute to the file scope.
+        
+        Args:
+            path: Go, Python or Rust file, absolute or relative to the project root
+            language: Read the file as Go whatever its extension ("go"); Python
+                and Rust files are resolved against the index, so only as their own
             
-            if result.returncode == 0:
+        Returns:
+            Dictionary with the file's package and its resolved dependencies
+        """
+        file_path = self._resolve_path(path)
+        if not file_path.is_file():
+            raise FileNotFound(f"'{file_path}' is not a Go, Python or Rust source file", str(file_path))
+        own = self._language_of(file_path)
+        language = language.lower() if language else own
+        if language != own and language != "go":
+            raise UnsupportedLanguage(f"'{file_path}' can only be read as Go or as its own language", str(file_path))
+        if language == "python":
+            return self._python_dependencies(file_path)
+        if language == "rust":
+            return self._rust_dependencies(file_path)
+        if language != "go":
+            raise UnsupportedLanguage(f"'{file_path}' is not a Go, Python or Rust source file", str(file_path))
+        
+        parsed = self._parsed_as(file_path, language)
+        
+        dependencies: Dict[str, Dict[str, Any]] = {}
+        for imp in parsed["imports"]:
+            dep = dependencies.setdefault(imp["path"], {
+                "path": imp["path"],
+                "names": [],
+                "kinds": [],
+                "lines": [],
+                "used": set(),
+            })
+            if imp["name"] and imp["name"] not in dep["names"]:
+                dep["names"].append(imp["name"])
+            if imp["kind"] not in dep["kinds"]:
+                dep["kinds"].append(imp["kind"])
+            dep["lines"].append(imp["line"])
+        
+        for ref in parsed["qualified_refs"]:
+            dependencies[ref["package"]]["used"].add(ref["name"])
+        
+        dot_paths = [p for p, d in dependencies.items() if "dot" in d["kinds"]]
+        if dot_paths:
+            # Names declared anywhere in this package cannot come from a dot import
+            package_names = set()
+            for sibling in file_path.parent.glob("*.go"):
                 try:
-                    matches = json.loads(result.stdout)
-                    for match in matches:
-                        # Extract details from match
-                        text = match.get("text", "")
-                        file_path = match.get("file", "")
-                        start = match.get("range", {}).get("start", {})
-                        end = match.get("range", {}).get("end", {})
-                        
-                        # Extract the name from metavariables
-                        metavars = match.get("metaVariables", {})
-                        name = None
-                        
-                        # Try to get NAME from metavariables
-                        if "NAME" in metavars:
-                            name = metavars["NAME"]["text"]
-                        else:
-                            # Fallback to regex extraction
-                            name = self._extract_symbol_name(text)
-                        
-                        if name:
-                            symbol = {
-                                "name": name,
-                                "type": symbol_type,
-                                "path": file_path,
-                                "start_line": start.get("line", 1),
-                                "end_line": end.get("line", start.get("line", 1))
-                            }
-                            all_symbols.append(symbol)
-                except json.JSONDecodeError:
+                    package_names.update(s["name"] for s in self._get_go_symbols(sibling))
+                except Exception:
                     continue
+            candidates = [n for n in parsed.get("unqualified_exported", []) if n not in package_names]
+            for dot_path in dot_paths:
+                dependencies[dot_path]["used"].update(candidates)
         
-        # Deduplicate symbols (same name and location)
-        seen = set()
-        unique_symbols = []
-        for symbol in all_symbols:
-            key = (symbol["name"], symbol["path"], symbol["start_line"])
-            if key not in seen:
-                seen.add(key)
-                unique_symbols.append(symbol)
+        results = []
+        for dep in dependencies.values():
+            dep["used"] = sorted(dep["used"])
+            dep["side_effect_only"] = dep["kinds"] == ["blank"]
+            if "dot" in dep["kinds"]:
+                dep["merged_into_file_scope"] = True
+            results.append(dep)
         
-        # Now perform fuzzy matching against the query
-        scored_symbols = []
-        for symbol in unique_symbols:
-            # Calculate similarity score
-            score = fuzz.partial_ratio(query.lower(), symbol["name"].lower())
-            
-            # Boost score for exact substring matches
-            if query.lower() in symbol["name"].lower():
-                score = max(score, 80)
-            
-            scored_symbols.append((score, symbol))
+        self._save_cache()
+        result = {
+            "path": str(file_path),
+            "package": parsed["package"],
+            "dependencies": results,
+            "total_count": len(results)
+        }
+        if parsed.get("parseErrors"):
+            result["parseErrors"] = parsed["parseErrors"]
+        return result
+    
+    def _python_dependencies(self, file_path: Path) -> Dict[str, Any]:
+        """
+        The imports of a Python file, one entry per module, following the
+        shape of the Go dependencies: `import a as b` and `from x import y as z`
+        are attributed to a and x (or to the submodule x.y), the aliases listed
+        in names, the imported names in used, and star imports merged into the
+        file scope.
+        """
+        project = self._py_project()
+        path = str(file_path)
+        if path not in project.files:
+            raise FileNotFound(f"'{file_path}' is not an indexed Python file", path)
+        parsed = project.files[path]
         
-        # Sort by score and take top results
-        scored_symbols.sort(key=lambda x: x[0], reverse=True)
-        top_symbols = [s[1] for s in scored_symbols[:limit]]
+        dependencies: Dict[str, Dict[str, Any]] = {}
         
-        return top_symbols
-    
-    def _extract_symbol_name(self, text: str) -> Optional[str]:
-        """Extract the symbol name from matched text."""
-        # Patterns to extract names from different definition types
-        patterns = [
-            r'(?:def|class|function|interface|type)\s+(\w+)',
-            r'(?:const|let|var)\s+(\w+)\s*=',
-            r'func\s+(?:\([^)]+\)\s+)?(\w+)',
-        ]
+        def dependency(module: str, resolved: Optional[str]) -> Dict[str, Any]:
+            return dependencies.setdefault(module, {
+                "path": module,
+                "resolved": resolved,
+                "names": [],
+                "kinds": [],
+                "lines": [],
+                "used": set(),
+                "type_checking_only": True,
+            })
         
-        for pattern in patterns:
-            match = re.search(pattern, text)
-            if match:
-                return match.group(1)
+        def note(dep: Dict[str, Any], kind: str, imp: Dict[str, Any], alias: Optional[str] = None):
+            if alias and alias not in dep["names"]:
+                dep["names"].append(alias)
+            if kind not in dep["kinds"]:
+                dep["kinds"].append(kind)
+            if imp["line"] not in dep["lines"]:
+                dep["lines"].append(imp["line"])
+            dep["type_checking_only"] &= bool(imp.get("type_checking"))
         
-        return None
+        locals_: Dict[str, List[Dict[str, Any]]] = {}
+        for imp in project.imports_of(path):
+            module = project.target_of(path, imp)
+            if imp["kind"] == "import":
+                dep = dependency(module, imp["resolved"])
+                note(dep, "alias" if imp["aliased"] else "default", imp, imp["local"] if imp["aliased"] else None)
+                locals_.setdefault(imp["local"], []).append(dep)
+                continue
+            for name in imp["names"]:
+                if name["imported"] == "*":
+                    dep = dependency(module, imp["resolved"])
+                    note(dep, "star", imp)
+                    dep["merged_into_file_scope"] = True
+                    continue
+                submodule = f"{module}.{name['imported']}" if module else name["imported"]
+                if name["resolved"] is not None and name["resolved"] != imp["resolved"]:
+                    dep = dependency(submodule, name["resolved"])
+                    aliased = name["local"] != name["imported"]
+                    note(dep, "alias" if aliased else "default", imp, name["local"] if aliased else None)
+                    locals_.setdefault(name["local"], []).append(dep)
+                    continue
+                dep = dependency(module, imp["resolved"])
+                aliased = name["local"] != name["imported"]
+                note(dep, "alias" if aliased else "from", imp, name["local"] if aliased else None)
+                dep["used"].add(name["imported"])
+        
+        for ref in parsed["qualified_refs"]:
+            for dep in locals_.get(ref["local"], []):
+                dep["used"].add(ref["name"])
+        
+        results = []
+        for dep in dependencies.values():
+            dep["used"] = sorted(dep["used"])
+            dep["origin"] = "internal" if dep["resolved"] else ("stdlib" if is_stdlib(dep["path"]) else "external")
+            results.append(dep)
+        
+        self._save_cache()
+        result = {
+            "path": path,
+            "module": project.dotted_name(path),
+            "dependencies": results,
+            "total_count": len(results)
+        }
+        if parsed.get("parseError"):
+            result["parseError"] = parsed["parseError"]
+        return result
     
-    def what_breaks(self, exact_symbol: Dict[str, Any]) -> Dict[str, Any]:
+    def _rust_dependencies(self, file_path: Path) -> Dict[str, Any]:
         """
-        Find what uses a symbol (reverse dependencies).
-        Simplified to use basic text search for speed and simplicity.
-        
-        Returns a dictionary with references and a standard caveat.
+        The dependencies of a Rust file, one entry per module or crate: its
+        use items and extern crates, the files its `mod name;` declarations
+        load, and crates used through a path without a use (`serde_json::
+        to_string`). Items are attributed to the module they come from - for
+        paths outside the project a lowercase last segment is taken to be a
+        module, anything else an item of its parent - and used lists those
+        items, the names referenced through an imported module and the
+        imported macros invoked.
         """
-        symbol_name = exact_symbol['name']
-        references = []
+        project = self._rs_project()
+        path = str(file_path)
+        if path not in project.files:
+            raise FileNotFound(f"'{file_path}' is not an indexed Rust file", path)
+        parsed = project.files[path]
         
-        # Use simple grep-like search for the symbol name
-        # Check if ripgrep is available, otherwise fall back to Python
-        try:
+        dependencies: Dict[str, Dict[str, Any]] = {}
+        
+        def dependency(key: str, target: Dict[str, Any]) -> Dict[str, Any]:
+            return dependencies.setdefault(key, {
+                "path": key,
+                "resolved": target["resolved"],
+                "crate": target["crate"],
+                "origin": target["origin"],
+                "names": [],
+                "kinds": [],
+                "lines": [],
+                "used": set(),
+            })
+        
+        def note(dep: Dict[str, Any], kind: str, line: int, alias: Optional[str] = None):
+            if alias and alias not in dep["names"]:
+                dep["names"].append(alias)
+            if kind not in dep["kinds"]:
+                dep["kinds"].append(kind)
+            if line not in dep["lines"]:
+                dep["lines"].append(line)
+        
+        def key_of(written: str, target: Dict[str, Any]) -> Tuple[str, Optional[str]]:
+            """(module the path names, the item after it or None)."""
+            segments = written.lstrip(":").split("::")
+            if target["origin"] == "internal":
+                module = target["crate"] + target["module"][len("crate"):]
+                last = segments[-1]
+                return module, None if last in ("*", target["module"].rsplit("::", 1)[-1]) else last
+            if segments[-1] == "*" or segments[-1][:1].islower():
+                return "::".join(s for s in segments if s != "*"), None
+            return "::".join(segments[:-1]) or segments[0], segments[-1]
+        
+        locals_: Dict[str, List[Dict[str, Any]]] = {}
+        for imp in project.imports_of(path):
+            module, item = key_of(imp["path"], imp)
+            dep = dependency(module, imp)
+            if imp["kind"] == "glob":
+                note(dep, "glob", imp["line"])
+                dep["merged_into_file_scope"] = True
+            elif imp["kind"] == "extern_crate":
+                note(dep, "extern_crate", imp["line"])
+            else:
+                note(dep, "alias" if imp.get("alias") else "use", imp["line"], imp["local"] if imp.get("alias") else None)
+                if item:
+                    dep["used"].add(item)
+                else:
+                    # A module: paths through its local name use its items
+                    locals_.setdefault(imp["local"], []).append(dep)
+            if imp.get("visibility"):
+                dep["reexported"] = True
+        
+        for decl, child in project.mod_children(path):
+            crate = project.crates[project.crate_of[child]]
+            dep = dependency(project.dotted_name(child), {"resolved": child, "crate": crate, "origin": "internal"})
+            note(dep, "mod", decl["line"])
+        
+        # Paths through an imported module, or into a crate named without a use item
+        for ref in parsed["qualified_refs"]:
+            if ref["local"] in locals_:
+                for dep in locals_[ref["local"]]:
+                    dep["used"].add(ref["name"])
+            elif ref["local"][:1].islower() and ref["local"] not in RUST_PRIMITIVES:
+                target = project.resolve(path, f"{ref['local']}::{ref['name']}")
+                if target["origin"] != "internal" or ref["local"] in ("crate", "self", "super", target["crate"]):
+                    key = target["crate"] + target["module"][len("crate"):] if target["module"] else target["crate"]
+                    dep = dependency(key, target)
+                    note(dep, "path", ref["line"])
+                    dep["used"].add(ref["name"])
+        for call in parsed["macro_calls"]:
+            for dep in locals_.get(call["name"], []) if "path" not in call else ():
+                dep["used"].add(call["name"] + "!")
+        
+        results = []
+        for dep in dependencies.values():
+            dep["used"] = sorted(dep["used"])
+            results.append(dep)
+        
+        self._save_cache()
+        return {
+            "path": path,
+            "module": project.dotted_name(path),
+            "dependencies": results,
+            "total_count": len(results)
+        }
+    
+    def _iter_source_files(self, languages: Optional[Set[str]] = None,
+                           skipped: Optional[Dict[str, List[str]]] = None, unknown: bool = False):
+        """
+        Yield indexable source files under the root, pruning excluded directories.
+        
+        When a skipped dict is given, every excluded directory and source file
+        is recorded in it under the reason it was excluded. With unknown, the
+        text files of no known language not excluded are yielded too, for
+        their line counts (see _unknown_reason).
+        """
+        ignore_rules = self._parse_gitignore()
+        if not self.ref:
+            self._submodules = None
+        self._symlinks = {}
+        
+        def excluded(path: Path, is_dir: bool) -> bool:
+            reason = self._exclusion_reason(path, ignore_rules)
+            # An alias leaves nothing out: its files are walked under their own paths
+            if reason and reason != SYMLINK_ALIAS and skipped is not None:
+                label = path.relative_to(self.root_path).as_posix() + ("/" if is_dir else "")
+                skipped.setdefault(reason, []).append(label)
+            return reason is not None
+        
+        # Directories are compared by (device, inode), so no path reaches one twice
+        visited: Set[Tuple[int, int]] = set()
+        for dirpath, dirnames, filenames in os.walk(self.root_path):
+            current = Path(dirpath)
+            stat = current.stat()
+            if (stat.st_dev, stat.st_ino) in visited:
+                dirnames[:] = []
+                continue
+            visited.add((stat.st_dev, stat.st_ino))
+            dirnames[:] = sorted(d for d in dirnames if not excluded(current / d, True))
+            for filename in sorted(filenames):
+                file_path = current / filename
+                language = self._language_of(file_path)
+                if not language:
+                    if unknown and not languages and self._exclusion_reason(file_path, ignore_rules) is None \
+                            and self._unknown_reason(file_path) is None:
+                        yield file_path
+                    continue
+                if languages and language not in languages:
+                    continue
+                if excluded(file_path, False):
+                    continue
+                yield file_path
+    
+    def index_summary(self, max_paths: int = 20) -> Dict[str, Any]:
+        """
+        Count the files the indexer covers and explain what it leaves out.
+        
+        Args:
+            max_paths: Maximum example paths listed per skip reason
+        """
+        skipped: Dict[str, List[str]] = {}
+        by_language: Dict[str, int] = {}
+        by_submodule: Dict[str, int] = {}
+        partial = []
+        transcoded = []
+        for file_path in self._iter_source_files(skipped=skipped, unknown=True):
+            language = self._language_of(file_path) or "unknown"
+            by_language[language] = by_language.get(language, 0) + 1
+            if language in NATIVE_LANGUAGES and self._parses_partially(str(file_path), file_path.stat().st_size):
+                partial.append(file_path.relative_to(self.root_path).as_posix())
+            entry = self._file_index(file_path).get(str(file_path)) if language in NATIVE_LANGUAGES else None
+            if entry and entry["parsed"].get("transcodedFrom"):
+                transcoded.append({"path": file_path.relative_to(self.root_path).as_posix(),
+                                   "transcodedFrom": entry["parsed"]["transcodedFrom"]})
+            found = self._submodule_map().containing(str(file_path))
+            if found:
+                by_submodule[found[0]] = by_submodule.get(found[0], 0) + 1
+        result = {
+            "root": str(self.root_path),
+            "files_indexed": sum(by_language.values()),
+            "by_language": by_language,
+            "include_generated": self.include_generated,
+            "include_submodules": self.include_submodules,
+            "config_file": self._config.path,
+            "skipped": [
+                {"reason": reason, "count": len(paths), "paths": paths[:max_paths]}
+                for reason, paths in sorted(skipped.items(), key=lambda item: (-len(item[1]), item[0]))
+            ],
+        }
+        if partial:
+            # Indexed for their declarations only: no calls or references inside them
+            result["partial"] = {"partial_file_size": self._config.get("partial_file_size", PARTIAL_FILE_SIZE),
+                                 "count": len(partial), "paths": partial[:max_paths]}
+        if transcoded:
+            # Not UTF-8: indexed from their text in the encoding detected (see xray.core.source_text)
+            result["transcoded"] = {"count": len(transcoded), "paths": transcoded[:max_paths]}
+        if self._symlinks:
+            # Links inside the project: their files are indexed under the paths they lead to
+            result["aliases"] = {"count": len(self._symlinks), "paths": [
+                {"path": link, "target": target} for link, target in sorted(self._symlinks.items())[:max_paths]]}
+        if self._submodule_map():
+            result["submodules"] = self._submodule_map().summary(by_submodule)
+        return result
+    
+    def show_config(self, path: Optional[str] = None, overrides: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+        """
+        The configuration in effect: the built-in defaults, overridden by the
+        project's configuration file, overridden by the call's parameters.
+        The server's --language-map is the default of "extensions", the
+        file's entries added over it.
+        
+        Args:
+            path: Optional file or directory to explain - indexed or not, and the rule leaving it out
+            overrides: The parameters a call passed (None for those left out)
+            
+        Returns:
+            {"config_file", "valid", "errors", "file" (its settings), "effective",
+             "sources" (setting -> "call", "file", "server" or "default"), "path"}
+        """
+        config = load_config(self.source_root, sorted(set(LANGUAGE_MAP.values())), self._config)
+        defaults = {
+            "exclude": [],
+            "include_generated": False,
+            "include_tests": True,
+            "max_file_size": None,
+            "partial_file_size": PARTIAL_FILE_SIZE,
+            "languages": {language: True for language in sorted(NATIVE_LANGUAGES)},
+            "extensions": dict(self.language_map),
+            "generated_headers": [],
+            "description_length": DESCRIPTION_LENGTH,
+            "max_memory_mb": None,
+            "rules": [],
+        }
+        settings = config.describe()
+        effective: Dict[str, Any] = {}
+        sources: Dict[str, str] = {}
+        for key, default in defaults.items():
+            if (overrides or {}).get(key) is not None:
+                effective[key], sources[key] = overrides[key], "call"
+            elif key in settings:
+                effective[key] = {**default, **settings[key]} if key in ("languages", "extensions") else settings[key]
+                sources[key] = "file"
+            else:
+                effective[key], sources[key] = default, "server" if key == "extensions" and default else "default"
+        result: Dict[str, Any] = {
+            "config_file": config.path,
+            "valid": not config.errors,
+            "errors": config.errors,
+            "file": settings,
+            "effective": effective,
+            "sources": sources,
+        }
+        if path:
+            result["path"] = self._explain_path(path) if not config.errors else \
+                {"path": path, "indexed": False, "reason": "the configuration file is invalid"}
+        return result
+    
+    def _explain_path(self, path: str) -> Dict[str, Any]:
+        """Whether the index covers a file or directory, and if not, which rule leaves it out where."""
+        target = self._resolve_path(path)
+        if not target.exists():
+            raise FileNotFound(f"Path '{target}' does not exist", str(target))
+        relpath = target.relative_to(self.root_path).as_posix() if target != self.root_path else "."
+        ignore_rules = self._parse_gitignore()
+        current = self.root_path
+        for part in target.relative_to(self.root_path).parts:
+            current = current / part
+            reason = self._exclusion_reason(current, ignore_rules)
+            if reason:
+                return {"path": relpath, "indexed": False, "reason": reason,
+                        "excluded_at": current.relative_to(self.root_path).as_posix()}
+        if not target.is_file():
+            return {"path": relpath, "indexed": True}
+        language = self._language_of(target)
+        reason = self._unknown_reason(target) if language is None else None
+        if reason:
+            return {"path": relpath, "indexed": False, "reason": f"no known language, and {reason}"}
+        return {"path": relpath, "indexed": True, "language": language or "unknown"}
+    
+    def resource_entries(self) -> List[Dict[str, Any]]:
+        """
+        List the browsable resources of the project: every indexed source
+        file, and every directory holding a Go package (its outline).
+
+        Paths are relative to the root, POSIX-style and sorted, with both
+        kinds interleaved by path.
+        """
+        project = self._go_project()
+        entries = []
+        for path in [p for index in self._parse_indexes() + [self._cache.get("file-lines", {})] for p in index]:
+            relpath = Path(path).relative_to(self.root_path).as_posix()
+            language = self._language_of(path) or "unknown"
+            entries.append({"path": relpath, "kind": "file", "language": language})
+        for pkg_dir, info in project.packages.items():
+            relpath = Path(pkg_dir).relative_to(self.root_path).as_posix()
+            entries.append({"path": "" if relpath == "." else relpath, "kind": "package",
+                            "package": info["name"], "files": len(info["files"])})
+        entries.sort(key=lambda e: (e["path"], e["kind"]))
+        return entries
+
+    def read_resource(self, relpath: str, kinds: Optional[List[str]] = None, exported_only: bool = False,
+                      top_level_only: bool = False) -> Tuple[str, str]:
+        """
+        Read a resource by its path relative to the root.
+
+        Returns (mime type, text): the content of an indexed source file, or
+        for a Go package directory a JSON outline of the symbols of each file,
+        filtered as list_symbols filters them. Anything outside the index -
+        excluded, non-source or outside the root - is refused.
+        """
+        keep = symbol_filter(kinds, exported_only, top_level_only)
+        target = (self.root_path / relpath).resolve()
+        if target != self.root_path and self.root_path not in target.parents:
+            raise XRayError(f"'{relpath}' is outside the project", relpath)
+        project = self._go_project()
+        if target.is_dir():
+            info = project.packages.get(str(target))
+            if info is None:
+                raise FileNotFound(f"'{relpath or '.'}' is not a Go package directory", relpath or ".")
+            outline = {
+                "package": info["name"],
+                "dir": relpath,
+                "files": [
+                    {
+                        "path": Path(path).name,
+                        "symbols": [
+                            {key: symbol[key] for key in ("name", "type", "signature", "start_line", "end_line",
+                                                          "container", "approximate")
+                             if symbol.get(key) is not None}
+                            for symbol in project.files[path]["symbols"] if keep(symbol)
+                        ],
+                        **({"parseErrors": project.files[path]["parseErrors"]}
+                           if project.files[path].get("parseErrors") else {}),
+                    }
+                    for path in info["files"]
+                ],
+            }
+            # File paths in the outline are relative to the package directory
+            return "application/json", json.dumps(add_ranges(outline, str(target)), indent=2)
+        path = str(target)
+        if all(path not in indexed for indexed in self._parse_indexes() + [self._cache.get("file-lines", {})]):
+            raise FileNotFound(f"'{relpath}' is not an indexed source file", relpath)
+        return "text/plain", read_text(path)
+
+    def _readme_paragraph(self, directory: str) -> Tuple[str, str]:
+        """
+        The first paragraph of prose in a directory's README - headings,
+        badges and HTML left out - and the README's name, ("", "") without
+        one. Cached by modification time and size.
+        """
+        for name in README_NAMES:
+            path = os.path.join(directory, name)
+            try:
+                stat = os.stat(path)
+            except OSError:
+                continue
+            stamp = (stat.st_mtime_ns, stat.st_size)
+            cached = self._readmes.get(directory)
+            if cached is not None and cached[0] == stamp and cached[1] == name:
+                return cached[1], cached[2]
+            try:
+                text = read_text(path)
+            except (OSError, UnicodeDecodeError):
+                continue
+            paragraph: List[str] = []
+            for line in text.splitlines():
+                stripped = line.strip()
+                if not stripped:
+                    if paragraph:
+                        break
+                    continue
+                if stripped.startswith(("#", "![", "[![", "<", "```")) or set(stripped) <= set("=-"):
+                    if paragraph:
+                        break
+                    continue
+                paragraph.append(stripped)
+            self._readmes[directory] = (stamp, name, " ".join(paragraph))
+            return name, " ".join(paragraph)
+        self._readmes.pop(directory, None)
+        return "", ""
+    
+    def _package_description(self, project: GoProject, pkg_dir: str) -> Dict[str, Any]:
+        """
+        The description of a package directory for listings: its package
+        comment (see GoProject._package_doc), else the first paragraph of
+        its README, on one line and cut to description_length characters.
+        A Go package's record keeps it too.
+        
+        Returns:
+            {"description", "description_from": [file names]}, the
+            description "" and no description_from without either
+        """
+        info = project.packages.get(pkg_dir)
+        text, sources = (info["doc"], info["doc_files"]) if info and info.get("doc") else ("", [])
+        if not text:
+            name, text = self._readme_paragraph(pkg_dir)
+            sources = [name] if text else []
+        text = " ".join(text.split())
+        limit = self.setting("description_length", None, DESCRIPTION_LENGTH)
+        if len(text) > limit:
+            text = text[:limit].rsplit(" ", 1)[0].rstrip(",;:") + "..."
+        description: Dict[str, Any] = {"description": text}
+        if sources:
+            description["description_from"] = sources
+        if info is not None:
+            info.update(description)
+        return description
+    
+    def project_overview(self, max_items: int = 20) -> Dict[str, Any]:
+        """
+        Orientation summary of the project, derived from the index.
+        
+        Figures come from the parse results and line counts the index already
+        holds; the summary is only recomputed when indexed content changed
+        since the previous call, so calling this repeatedly is cheap.
+        
+        Args:
+            max_items: Maximum entries in each list (packages, routes, largest files...)
+        """
+        graph = self._call_graph()
+        if self._overview and self._overview[0] == (self._generation, max_items):
+            overview = self._overview[1]
+        else:
+            overview = self._build_overview(graph, max_items)
+            self._overview = ((self._generation, max_items), overview)
+        
+        git = self._git_context()
+        head = self.ref_commit or git.get("commit")
+        skipped = self.last_walk["skipped"] if self.last_walk else {}
+        result = {
+            **overview,
+            "git": git,
+            "index": {
+                "indexed_at": iso_date(int(self.last_walk["at"])) if self.last_walk else None,
+                "head": head,
+                "ref": self.ref,
+                "include_generated": self.include_generated,
+                "skipped": [
+                    {"reason": reason, "count": len(paths)}
+                    for reason, paths in sorted(skipped.items(), key=lambda item: (-len(item[1]), item[0]))
+                ],
+                "cache_file": str(self.index_cache.path),
+            },
+        }
+        deep = self.deep_index_status()
+        if deep is not None:
+            # Quick index: the figures leave out calls and references of the files not parsed in full yet
+            result["index"]["deep_index"] = deep
+        return result
+    
+    def _git_context(self) -> Dict[str, Any]:
+        """
+        Whether the project has git history to offer, and where HEAD is. The
+        project may be the whole work tree or a directory inside one; without
+        a repository (or git) only the history tools are out of reach.
+        """
+        try:
+            repo = self._git(self.source_root)
+            head = repo.head()
+        except GitError as e:
+            return {"available": False, "reason": str(e)}
+        context: Dict[str, Any] = {"available": True, "root": repo.root, **head, "shallow": repo.shallow}
+        if repo.bare:
+            context["bare"] = True
+        elif Path(repo.root).resolve() != self.source_root.resolve():
+            context["subdirectory"] = relative(str(self.source_root.resolve()), Path(repo.root).resolve())
+        return context
+    
+    def _build_overview(self, graph: GoCallGraph, max_items: int) -> Dict[str, Any]:
+        project = graph.project
+        index = self._go_file_index()
+        rel = lambda path: relative(path, self.root_path)
+        
+        files = [(path, "go", entry.get("lines", 0)) for path, entry in index.items() if path in project.files]
+        files += [(path, self._language_of(path), entry.get("lines", 0))
+                  for index in (self._ts_file_index(), self._py_file_index(), self._rs_file_index(),
+                                self._java_file_index(), self._proto_file_index(), self._sql_file_index(),
+                                self._task_file_index())
+                  for path, entry in index.items()]
+        files += [(path, self._language_of(path) or "unknown", entry["lines"])
+                  for path, entry in self._cache.get("file-lines", {}).items()]
+        languages: Dict[str, Dict[str, int]] = {}
+        for _, language, lines in files:
+            stats = languages.setdefault(language, {"files": 0, "lines": 0})
+            stats["files"] += 1
+            stats["lines"] += lines
+        
+        packages = []
+        main_packages = []
+        functions = []
+        for pkg_dir, info in sorted(project.packages.items()):
+            symbols = [s for path in info["files"] for s in project.files[path]["symbols"] if "container" not in s]
+            packages.append({
+                "dir": rel(pkg_dir),
+                "import_path": project.import_path(pkg_dir),
+                "name": info["name"],
+                "files": len(info["files"]),
+                "symbols": len(symbols),
+                "exported": sum(1 for s in symbols if s["name"][:1].isupper()),
+                **self._package_description(project, pkg_dir),
+            })
+            for path in info["files"]:
+                for s in project.files[path]["symbols"]:
+                    if s["type"] not in ("function", "method") or "container" in s:
+                        continue
+                    name = f"{s['receiver']['type']}.{s['name']}" if s.get("receiver") else s["name"]
+                    functions.append({"name": name, "path": path, "start_line": s["start_line"],
+                                      "lines": s["end_line"] - s["start_line"] + 1})
+                    if info["name"] == "main" and name == "main":
+                        main_packages.append({"dir": rel(pkg_dir), "path": path, "line": s["start_line"]})
+        
+        routes = RouteExtractor(graph).extract()
+        module = self._go_mod()
+        
+        files.sort(key=lambda f: (-f[2], f[0]))
+        functions.sort(key=lambda f: (-f["lines"], f["path"], f["start_line"]))
+        return {
+            "root": str(self.root_path),
+            "files": len(files),
+            "lines": sum(f[2] for f in files),
+            "languages": dict(sorted(languages.items(), key=lambda item: -item[1]["lines"])),
+            "package_count": len(packages),
+            "packages": sorted(packages, key=lambda p: (-p["symbols"], p["dir"]))[:max_items],
+            "module": module and {"path": module["module"], "go": module["go"]},
+            **(project.modules.describe() if project.modules is not None else {"modules": []}),
+            "dependencies": module["require"] if module else [],
+            "replacements": module["replace"] if module else [],
+            "entry_points": {
+                "main_packages": main_packages[:max_items],
+                "route_count": len(routes),
+                "routes": [
+                    {"method": r["method"], "route": r["route"], "handler": r["handler"] and r["handler"].get("name"),
+                     "path": r["registered_at"]["path"], "line": r["registered_at"]["line"]}
+                    for r in routes[:max_items]
+                ],
+            },
+            "largest_files": [{"path": path, "language": language, "lines": lines}
+                              for path, language, lines in files[:max_items]],
+            "largest_functions": functions[:max_items],
+        }
+    
+    def generate_report(self, packages: bool = True, types: bool = True, entry_points: bool = True,
+                        dependencies: bool = True, hotspots: bool = True, max_items: int = 20) -> Dict[str, Any]:
+        """
+        Render a Markdown architecture report of the Go code.
+        
+        Args:
+            packages: Package tree with package doc summaries
+            types: Exported interfaces and types with their methods
+            entry_points: main packages and HTTP routes
+            dependencies: External modules and who imports them
+            hotspots: Symbols ranked by git churn x complexity
+            max_items: Maximum entries per list
+            
+        Returns:
+            Dictionary with the markdown, the sections included and its line count
+        """
+        graph = self._call_graph()
+        go_mod = self._go_mod()
+        builder = ReportBuilder(graph.project, str(self.root_path), max_items)
+        rendered = []
+        if packages:
+            rendered.append(("packages", builder.packages()))
+        if types:
+            rendered.append(("types", builder.types()))
+        if entry_points:
+            rendered.append(("entry_points", builder.entry_points(RouteExtractor(graph).extract())))
+        if dependencies:
+            rendered.append(("dependencies", builder.dependencies(go_mod)))
+        if hotspots:
+            try:
+                repo = self._git(self.root_path)
+                rows = [{**row, "path": repo.abspath(row["path"])}
+                        for row in self.hotspots(max_files=0)["symbols"]]
+                rendered.append(("hotspots", builder.hotspots(rows)))
+            except Exception as e:
+                rendered.append(("hotspots", builder.hotspots(None, str(e).strip().splitlines()[-1] if str(e).strip() else None)))
+        
+        title = (go_mod or {}).get("module") or self.source_root.name
+        markdown = render_report(f"{title} architecture", [lines for _, lines in rendered])
+        return {
+            "markdown": markdown,
+            "sections": [name for name, _ in rendered],
+            "line_count": markdown.count("\n"),
+        }
+    
+    def _go_mod(self) -> Optional[Dict[str, Any]]:
+        """The parsed go.mod at the project root, or None if there is none."""
+        go_mod = self.root_path / "go.mod"
+        if not go_mod.is_file():
+            return None
+        try:
+            return parse_go_mod(go_mod.read_text(encoding="utf-8"))
+        except (OSError, UnicodeDecodeError):
+            return None
+    
+    def dependency_graph(self, depth: Optional[int] = None, include_std: bool = False,
+                         include_external: bool = False, format: str = "json",
+                         include: Optional[List[str]] = None, exclude: Optional[List[str]] = None,
+                         cross_language: bool = False) -> Dict[str, Any]:
+        """
+        Build the package import graph of the module, TypeScript/JavaScript
+        and Python directories included.
+        
+        Args:
+            depth: Collapse packages to this many directory levels below the module root
+            include_std: Also show standard library packages (Go, Node and Python)
+            include_external: Also show packages of other modules
+            format: "json", "dot" for the same graph as GraphViz source, or
+                "sarif" for its import cycles as code scanning results
+            include: Only the files matching one of these globs (see PathGlobs)
+            exclude: Not the files matching one of these globs
+            cross_language: Also add the embed, exec and http links of
+                cross_language_links as edges of their kind
+        """
+        if format not in DEPENDENCY_FORMATS:
+            raise ValueError(f"format must be one of {', '.join(DEPENDENCY_FORMATS)}")
+        if depth is not None and depth < 1:
+            raise ValueError("depth must be at least 1")
+        globs = self._path_globs(include, exclude)
+        go_mod = self._go_mod()
+        project = self._go_project()
+        graph = dependency_graph(
+            project,
+            module=go_mod and go_mod["module"],
+            requires=project.modules.requires() if project.modules is not None else [],
+            depth=depth,
+            include_std=include_std,
+            include_external=include_external,
+            modules=self._ts_project(),
+            python=self._python,
+            rust=self._rust,
+            keep=globs.matches if globs else None,
+            describe=lambda directory: self._package_description(project, directory),
+            links=self._cross_language_linker().links(keep=lambda p: not is_test_file(p))["links"]
+            if cross_language else None,
+        )
+        if globs:
+            graph["path_filter"] = globs.describe()
+        if format == "dot":
+            return {"format": "dot", "dot": to_dot(graph),
+                    **{k: graph[k] for k in ("module", "depth", "cycles", "node_count", "edge_count")}}
+        if format == "sarif":
+            return self._sarif(cycle_results(self.root_path, graph))
+        return graph
+    
+    def find_cycles(self, include_tests: bool = True, format: str = "json") -> Dict[str, Any]:
+        """
+        Find the import cycles between the module's Go packages.
+        
+        Args:
+            include_tests: Also follow the imports of _test.go files; cycles
+                that only exist through them are reported as "test" (in-package
+                tests, rejected by go test) or "soft" (external _test
+                packages, which Go permits)
+            format: "json", or "sarif" for a code scanning log
+            
+        Returns:
+            Dictionary with every cycle's packages, the full paths around it,
+            the files and import lines behind each edge, and the smallest set
+            of edges whose removal would break it
+        """
+        if format not in FINDING_FORMATS:
+            raise ValueError(f"format must be one of {', '.join(FINDING_FORMATS)}")
+        go_mod = self._go_mod()
+        result = find_cycles(self._go_project(), module=go_mod and go_mod["module"], include_tests=include_tests)
+        if format == "sarif":
+            return self._sarif(find_cycles_results(self.root_path, result))
+        return result
+    
+    def service_map(self, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        Map the module's main packages to the internal packages they build in.
+        
+        Args:
+            include: Only the files matching one of these globs (see PathGlobs)
+            exclude: Not the files matching one of these globs, e.g. tool
+                directories that should not count as services
+            
+        Returns:
+            Dictionary with each service's packages and the Dockerfiles or
+            compose services running it, the packages several services
+            share, and those no service reaches; with containers, the ports
+            they expose that the binaries do not listen on and vice versa
+        """
+        globs = self._path_globs(include, exclude)
+        go_mod = self._go_mod()
+        result = service_map(self._go_project(), module=go_mod and go_mod["module"],
+                             keep=globs.matches if globs else None)
+        containers = self._container_map(globs)
+        if containers.dockerfiles or containers.composes:
+            result["port_mismatches"] = containers.attach(result["services"])
+        if globs:
+            result["path_filter"] = globs.describe()
+        return result
+    
+    def find_duplicates(self, min_tokens: int = DUPLICATE_TOKENS, min_similarity: float = DUPLICATE_SIMILARITY,
+                        include_tests: bool = False, path: Optional[str] = None, cross_package_only: bool = False,
+                        include: Optional[List[str]] = None, exclude: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        Find Go functions whose bodies are copies of each other - identical
+        up to names and literals, or near-identical - from the body
+        fingerprints recorded at parse time (see core/go_clones.py).
+        
+        Args:
+            min_tokens: Leave out function bodies of fewer tokens
+            min_similarity: Percent of alike tokens that makes two bodies near copies (100: identical only)
+            include_tests: Also compare the functions of _test.go files
+            path: Optional file or directory to limit the search to
+            cross_package_only: Only groups with copies in more than one package
+            include: Only the files matching one of these globs (see PathGlobs)
+            exclude: Not the files matching one of these globs, e.g. ["**/*_gen.go"]
+            
+        Returns:
+            Dictionary with the groups of copies - kind, similarity, size,
+            packages and services, and each copy's location - those spanning
+            services and packages first
+        """
+        if min_tokens < 1:
+            raise ValueError("min_tokens must be at least 1")
+        if not 0 < min_similarity <= 100:
+            raise ValueError("min_similarity must be a percentage above 0 and at most 100")
+        globs = self._path_globs(include, exclude)
+        go_mod = self._go_mod()
+        finder = DuplicateFinder(self._go_project(), self._source_readers()[0], go_mod and go_mod["module"])
+        scope = self._scope(path)
+        result = finder.find(min_tokens, min_similarity, include_tests, scope, cross_package_only,
+                             keep=globs.matches if globs else None)
+        if globs:
+            result["path_filter"] = globs.describe()
+        return result
+    
+    def coupling_metrics(self, sort_by: str = "afferent", include_tests: bool = False, max_edges: int = 20,
+                         include: Optional[List[str]] = None, exclude: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        Measure how coupled the module's Go packages are: who imports whom,
+        the instability that follows, and the edges carrying the most symbols.
+        
+        Args:
+            sort_by: One of afferent, efferent, instability, files,
+                symbols_used, symbols_provided, package
+            include_tests: Also count the imports and references of _test.go files
+            max_edges: How many of the heaviest edges to list
+            include: Only the files matching one of these globs (see PathGlobs)
+            exclude: Not the files matching one of these globs
+            
+        Returns:
+            Dictionary with each package's afferent and efferent coupling and
+            instability, and the import edges using the most distinct symbols
+        """
+        globs = self._path_globs(include, exclude)
+        go_mod = self._go_mod()
+        result = coupling_metrics(self._go_project(), module=go_mod and go_mod["module"], sort_by=sort_by,
+                                  include_tests=include_tests, max_edges=max_edges,
+                                  keep=globs.matches if globs else None)
+        if globs:
+            result["path_filter"] = globs.describe()
+        return result
+    
+    def init_analysis(self, include_tests: bool = False, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        List what a Go program runs before main: each package's init
+        functions and call-initialized variables in initialization order,
+        with the I/O, environment reads and panics they can cause, and the
+        blank imports with the init code they pull in (see core/go_init.py).
+        
+        Args:
+            include_tests: Also count _test.go files
+            path: Optional directory to limit the packages listed to
+            
+        Returns:
+            Dictionary with the packages that have init code in
+            initialization order, the order of all packages, the blank
+            imports and the counts of flagged side effects
+        """
+        scope = self._scope(path)
+        go_mod = self._go_mod()
+        return InitAnalyzer(self._call_graph(), go_mod and go_mod["module"]).analyze(include_tests, scope)
+    
+    def _go_project(self) -> GoProject:
+        """
+        Return the GoProject for the current tree, updating it incrementally.
+        
+        Only added and modified files are re-parsed; the project and, if one
+        was built, the call graph are patched for those files and for
+        removed ones instead of being rebuilt. TypeScript and JavaScript
+        files are parsed in the same pass into the TsProject (_ts_project),
+        Python files into the PyProject (_py_project), Rust files into
+        the RsProject (_rs_project), Java files into the JavaProject
+        (_java_project), .proto files into the ProtoProject
+        (_proto_project), .sql files into the SqlProject (_sql_project)
+        and Makefiles and shell scripts into the TaskProject (_task_project).
+        
+        Within a tracked call only the first use catches up (see tracking).
+        """
+        if self._pinned and self._project is not None:
+            return self._project
+        started = time.perf_counter()
+        project = self._project
+        index = self._go_file_index()
+        ts_index = self._ts_file_index()
+        py_index = self._py_file_index()
+        rs_index = self._rs_file_index()
+        java_index = self._java_file_index()
+        proto_index = self._proto_file_index()
+        sql_index = self._sql_file_index()
+        task_index = self._task_file_index()
+        
+        # Files whose mtime and size moved go to the parser pool; languages
+        # without a parser only get their line counts refreshed
+        paths = []
+        ts_paths = []
+        py_paths = []
+        rs_paths = []
+        java_paths = []
+        proto_paths = []
+        sql_paths = []
+        task_paths = []
+        jobs = []
+        # Files a quick index parses from their skeleton for now
+        shallow: Set[str] = set()
+        skipped: Dict[str, List[str]] = {}
+        others = self._cache.setdefault("file-lines", {})
+        other_paths = set()
+        others_changed = False
+        # Paths parsed as another language than their extension's (see core/languages.py)
+        mapped: Dict[str, str] = {}
+        for file_path in self._iter_source_files(skipped=skipped, unknown=True):
+            path = str(file_path)
+            language = self._language_of(file_path)
+            if language == "go":
+                paths.append(path)
+                entry = index.get(path)
+            elif language in ("typescript", "javascript"):
+                ts_paths.append(path)
+                entry = ts_index.get(path)
+            elif language == "python":
+                py_paths.append(path)
+                entry = py_index.get(path)
+            elif language == "rust":
+                rs_paths.append(path)
+                entry = rs_index.get(path)
+            elif language == "java":
+                java_paths.append(path)
+                entry = java_index.get(path)
+            elif language == "proto":
+                proto_paths.append(path)
+                entry = proto_index.get(path)
+            elif language == "sql":
+                sql_paths.append(path)
+                entry = sql_index.get(path)
+            elif language in ("make", "shell"):
+                task_paths.append(path)
+                entry = task_index.get(path)
+            else:
+                other_paths.add(path)
+                others_changed |= self._count_lines(file_path, others)
+                continue
+            self._report("scanning", len(paths) + len(ts_paths) + len(py_paths) + len(rs_paths) + len(java_paths) +
+                         len(proto_paths) + len(sql_paths) + len(task_paths), None, path)
+            try:
+                stat = file_path.stat()
+            except OSError as e:
+                self._unindexed[path] = describe_error(e, "Not indexed", path)
+                continue
+            if entry and self._parse_outdated(path, stat.st_size, entry["parsed"]):
+                # The partial threshold moved past the file, or a quick index reached its package: parse it again
+                jobs.append((path, None))
+            elif not entry or entry["stamp"] != (stat.st_mtime_ns, stat.st_size) or "lines" not in entry:
+                jobs.append((path, entry["hash"] if entry else None))
+            else:
+                continue
+            if language != language_of(file_path):
+                mapped[path] = language
+            if self._parses_shallow(path):
+                shallow.add(path)
+        for path in [p for p in others if p not in other_paths]:
+            del others[path]
+            others_changed = True
+        walked = set(paths).union(ts_paths, py_paths, rs_paths, java_paths, proto_paths, sql_paths, task_paths)
+        for path in [p for p in self._unindexed if p not in walked]:
+            del self._unindexed[path]
+        
+        reparsed = set()
+        parsing = lambda done, total, path: self._report("parsing", done, total, path)
+        partial_size = self._config.get("partial_file_size", PARTIAL_FILE_SIZE)
+        for path, result in parse_files(jobs, self.concurrency, self._cancel, parsing, partial_size,
+                                        frozenset(shallow), mapped).items():
+            self._cache_dirty = True
+            target = self._file_index(Path(path))
+            self._unindexed.pop(path, None)
+            if "error" in result:
+                target.pop(path, None)
+                self._unindexed[path] = {"code": result["code"], "message": f"Not indexed: {result['error']}",
+                                         "path": path}
+            elif result["parsed"] is None:
+                # Entries are replaced, never edited: a reader holding the old one keeps a whole version
+                target[path] = {**target[path], "stamp": result["stamp"], "lines": result["lines"]}
+            else:
+                intern_strings(result["parsed"])
+                target[path] = {key: result[key] for key in ("stamp", "hash", "lines", "parsed")}
+                reparsed.add(path)
+        self.cache_stats["misses"] += len(reparsed)
+        self._partial = set()
+        self._shallow = set()
+        self._transcoded = {}
+        self._walked_files = len(walked)
+        for path in walked:
+            entry = self._file_index(Path(path)).get(path)
+            if entry:
+                self._noted(path, entry["parsed"])
+        if project is None:
+            # First build this session: everything not re-parsed came from the persisted index
+            self.cache_stats["hits"] += sum(1 for p in paths if p in index) + sum(1 for p in ts_paths if p in ts_index) + \
+                sum(1 for p in py_paths if p in py_index) + sum(1 for p in rs_paths if p in rs_index) + \
+                sum(1 for p in java_paths if p in java_index) + sum(1 for p in proto_paths if p in proto_index) + \
+                sum(1 for p in sql_paths if p in sql_index) + sum(1 for p in task_paths if p in task_index) - \
+                len(reparsed)
+        ts_refresh = self._update_ts_project(ts_paths, reparsed)
+        py_refresh = self._update_py_project(py_paths, reparsed)
+        rs_refresh = self._update_rs_project(rs_paths, reparsed)
+        java_refresh = self._update_java_project(java_paths, reparsed)
+        proto_refresh = self._update_proto_project(proto_paths, reparsed)
+        sql_refresh = self._update_sql_project(sql_paths, reparsed)
+        task_refresh = self._update_task_project(task_paths, reparsed)
+        
+        self._fit_memory_budget(paths)
+        
+        changed: Dict[str, Dict[str, Any]] = {}
+        present = set()
+        for path in paths:
+            entry = index.get(path)
+            if entry is None:
+                continue
+            present.add(path)
+            if project is None or path in reparsed or project.files.get(path) is not entry["parsed"]:
+                changed[path] = entry["parsed"]
+        
+        for path in [p for p in index if p not in present]:
+            del index[path]
+            self._cache_dirty = True
+        
+        if project is None:
+            self._project = GoProject(list(changed.items()), str(self.root_path))
+            self._graph = None
+            self.last_refresh = {"added": sorted(changed), "modified": [], "removed": []}
+        else:
+            removed = sorted(p for p in project.files if p not in present)
+            self.last_refresh = {
+                "added": sorted(p for p in changed if p not in project.files),
+                "modified": sorted(p for p in changed if p in project.files),
+                "removed": removed,
+            }
+            if changed or removed:
+                project.update(changed, removed)
+                if self._graph is not None:
+                    self._graph.update(changed, removed)
+        modules = self._go_modules(self._project)
+        previous = self._project.modules
+        if previous is None or previous.signature() != modules.signature():
+            if previous is not None:
+                # Imports may resolve elsewhere now: rebuild the call graphs
+                self._graph = None
+                self._context_graphs = {}
+            self._project.set_modules(modules)
+        else:
+            self._project.modules = modules
+        if previous is not None and previous.describe() != modules.describe():
+            self._generation += 1
+        self.last_refresh = {key: sorted(self.last_refresh[key] + ts_refresh[key] + py_refresh[key] +
+                                         rs_refresh[key] + java_refresh[key] + proto_refresh[key] +
+                                         sql_refresh[key] + task_refresh[key])
+                             for key in self.last_refresh}
+        if project is None or any(self.last_refresh.values()) or others_changed:
+            self._generation += 1
+        if others_changed:
+            self._cache_dirty = True
+        self.last_walk = {"at": time.time(), "skipped": skipped}
+        self._save_cache()
+        self.refresh_latency.add((time.perf_counter() - started) * 1000)
+        self._pinned = self._tracked
+        return self._project
+    
+    def _file_size(self, path: str, parsed: Dict[str, Any]) -> int:
+        """Approximate bytes a parse result holds (see core/memory.py), remembered while it is the same object."""
+        cached = self._sizes.get(path)
+        if cached is None or cached[0] is not parsed:
+            cached = (parsed, approximate_size(parsed))
+            self._sizes[path] = cached
+        return cached[1]
+    
+    def _fit_memory_budget(self, paths: List[str]):
+        """
+        Keep the parse indexes within max_memory_mb (.xray.yaml): make the Go
+        packages with the most body-level facts lean, re-parsing their
+        function bodies when a query reads them (see core/memory.py), and
+        the others whole again.
+        """
+        index = self._go_file_index()
+        budget = self._config.get("max_memory_mb")
+        lean: Set[str] = set()
+        if budget:
+            # What the indexes would take with every package whole
+            total = sum(self._file_size(path, entry["parsed"]) + (entry["parsed"].body_bytes
+                        if isinstance(entry["parsed"], OnDemand) else 0)
+                        for other in self._parse_indexes() for path, entry in other.items())
+            bodies: Dict[str, int] = {}
+            for path in paths:
+                entry = index.get(path)
+                if entry:
+                    bodies[os.path.dirname(path)] = bodies.get(os.path.dirname(path), 0) + body_size(entry["parsed"])
+            for pkg_dir, size in sorted(bodies.items(), key=lambda item: (-item[1], item[0])):
+                if total <= budget * MEGABYTE:
+                    break
+                lean.add(pkg_dir)
+                total -= size
+        for path in paths:
+            entry = index.get(path)
+            if entry is None:
+                continue
+            parsed = entry["parsed"]
+            if os.path.dirname(path) in lean and not isinstance(parsed, OnDemand):
+                entry["parsed"] = OnDemand(parsed, path, entry["hash"])
+            elif os.path.dirname(path) not in lean and isinstance(parsed, OnDemand):
+                entry["parsed"] = intern_strings(parsed.full())
+            else:
+                continue
+            self._cache_dirty = True
+        if len(self._sizes) > sum(len(other) for other in self._parse_indexes()):
+            known = set().union(*self._parse_indexes())
+            self._sizes = {path: size for path, size in self._sizes.items() if path in known}
+    
+    def _module_reader(self, parse: Callable[[str], Dict[str, Any]]) -> Callable[[str], Optional[Dict[str, Any]]]:
+        """A go.mod or go.work reader for GoModules.discover, re-parsing a file only when it changes."""
+        def read(path: str) -> Optional[Dict[str, Any]]:
+            try:
+                stat = os.stat(path)
+                stamp = (stat.st_mtime_ns, stat.st_size)
+                cached = self._module_files.get(path)
+                if cached is None or cached[0] != stamp:
+                    with open(path, 'r', encoding='utf-8') as f:
+                        cached = (stamp, parse(f.read()))
+                    self._module_files[path] = cached
+                return cached[1]
+            except (OSError, UnicodeDecodeError):
+                return None
+        return read
+    
+    def _go_modules(self, project: GoProject) -> GoModules:
+        """The go.work workspace and go.mod modules of the tree (see core/go_modules.py)."""
+        return GoModules.discover(str(self.root_path), sorted(project.packages),
+                                  self._module_reader(parse_go_mod), self._module_reader(parse_go_work))
+    
+    def _sync_index(self, index: Dict[str, Dict[str, Any]], paths: List[str], reparsed: Set[str],
+                    known: Set[str]) -> Tuple[List[str], Dict[str, List[str]]]:
+        """
+        Drop files no longer in the tree from a parse index. Returns the paths
+        still present, and those added, modified and removed relative to
+        known (the files of the previous build).
+        """
+        present = [p for p in paths if p in index]
+        for path in set(index) - set(present):
+            del index[path]
+            self._cache_dirty = True
+        changed = [p for p in present if p in reparsed or p not in known]
+        return present, {
+            "added": [p for p in changed if p not in known],
+            "modified": [p for p in changed if p in known],
+            "removed": sorted(known - set(present)),
+        }
+    
+    def _update_ts_project(self, ts_paths: List[str], reparsed: Set[str]) -> Dict[str, List[str]]:
+        """Rebuild the TsProject if any TypeScript/JavaScript file came, went or changed."""
+        ts_index = self._ts_file_index()
+        known = set(self._modules.files) if self._modules is not None else set()
+        present, refresh = self._sync_index(ts_index, ts_paths, reparsed, known)
+        if self._modules is None or any(refresh.values()):
+            self._modules = TsProject({p: ts_index[p]["parsed"] for p in sorted(present)},
+                                      str(self.root_path), load_tsconfig(str(self.root_path)))
+        return refresh
+    
+    def _update_py_project(self, py_paths: List[str], reparsed: Set[str]) -> Dict[str, List[str]]:
+        """Rebuild the PyProject if any Python file came, went or changed."""
+        py_index = self._py_file_index()
+        known = set(self._python.files) if self._python is not None else set()
+        present, refresh = self._sync_index(py_index, py_paths, reparsed, known)
+        if self._python is None or any(refresh.values()):
+            self._python = PyProject({p: py_index[p]["parsed"] for p in sorted(present)}, str(self.root_path))
+        return refresh
+    
+    def _update_rs_project(self, rs_paths: List[str], reparsed: Set[str]) -> Dict[str, List[str]]:
+        """Rebuild the RsProject if any Rust file came, went or changed."""
+        rs_index = self._rs_file_index()
+        known = set(self._rust.files) if self._rust is not None else set()
+        present, refresh = self._sync_index(rs_index, rs_paths, reparsed, known)
+        if self._rust is None or any(refresh.values()):
+            self._rust = RsProject({p: rs_index[p]["parsed"] for p in sorted(present)}, str(self.root_path))
+        return refresh
+    
+    def _update_java_project(self, java_paths: List[str], reparsed: Set[str]) -> Dict[str, List[str]]:
+        """Rebuild the JavaProject if any Java file came, went or changed."""
+        java_index = self._java_file_index()
+        known = set(self._java.files) if self._java is not None else set()
+        present, refresh = self._sync_index(java_index, java_paths, reparsed, known)
+        if self._java is None or any(refresh.values()):
+            self._java = JavaProject({p: java_index[p]["parsed"] for p in sorted(present)}, str(self.root_path))
+        return refresh
+    
+    def _update_proto_project(self, proto_paths: List[str], reparsed: Set[str]) -> Dict[str, List[str]]:
+        """Rebuild the ProtoProject if any .proto file came, went or changed."""
+        proto_index = self._proto_file_index()
+        known = set(self._protos.files) if self._protos is not None else set()
+        present, refresh = self._sync_index(proto_index, proto_paths, reparsed, known)
+        if self._protos is None or any(refresh.values()):
+            self._protos = ProtoProject({p: proto_index[p]["parsed"] for p in sorted(present)}, str(self.root_path))
+        return refresh
+    
+    def _update_sql_project(self, sql_paths: List[str], reparsed: Set[str]) -> Dict[str, List[str]]:
+        """Rebuild the SqlProject if any .sql file came, went or changed."""
+        sql_index = self._sql_file_index()
+        known = set(self._sql.files) if self._sql is not None else set()
+        present, refresh = self._sync_index(sql_index, sql_paths, reparsed, known)
+        if self._sql is None or any(refresh.values()):
+            self._sql = SqlProject({p: sql_index[p]["parsed"] for p in sorted(present)}, str(self.root_path))
+        return refresh
+    
+    def _update_task_project(self, task_paths: List[str], reparsed: Set[str]) -> Dict[str, List[str]]:
+        """Rebuild the TaskProject if any Makefile or shell script came, went or changed."""
+        task_index = self._task_file_index()
+        known = set(self._tasks.files) if self._tasks is not None else set()
+        present, refresh = self._sync_index(task_index, task_paths, reparsed, known)
+        if self._tasks is None or any(refresh.values()):
+            self._tasks = TaskProject({p: task_index[p]["parsed"] for p in sorted(present)}, str(self.root_path))
+        return refresh
+    
+    def _ts_project(self) -> TsProject:
+        """Return the TypeScript/JavaScript modules of the current tree, kept in step with _go_project()."""
+        self._go_project()
+        return self._modules
+    
+    def _py_project(self) -> PyProject:
+        """Return the Python modules of the current tree, kept in step with _go_project()."""
+        self._go_project()
+        return self._python
+    
+    def _rs_project(self) -> RsProject:
+        """Return the Rust crates of the current tree, kept in step with _go_project()."""
+        self._go_project()
+        return self._rust
+    
+    def _java_project(self) -> JavaProject:
+        """Return the Java packages of the current tree, kept in step with _go_project()."""
+        self._go_project()
+        return self._java
+    
+    def _sql_project(self) -> SqlProject:
+        """Return the .sql files of the current tree, kept in step with _go_project()."""
+        self._go_project()
+        return self._sql
+    
+    def _task_project(self) -> TaskProject:
+        """Return the Makefiles and shell scripts of the current tree, kept in step with _go_project()."""
+        self._go_project()
+        return self._tasks
+    
+    def _proto_project(self) -> ProtoProject:
+        """
+        Return the .proto files of the current tree linked to the Go
+        generated from them.
+        
+        Generated .pb.go files are indexed only with include_generated;
+        otherwise they are parsed here, outside the index, so that messages
+        and services can still be matched to their Go types.
+        """
+        project = self._go_project()
+        if self._protos_linked == self._generation and self._protos.go is not None:
+            return self._protos
+        generated = {p: generated_source(p) for p in project.files if p.endswith(".pb.go")}
+        extra = self._skipped_generated_go(".pb.go")
+        for path, _ in extra:
+            generated[path] = generated_source(path)
+        go = GoProject(list(project.files.items()) + extra, str(self.root_path), project.modules) if extra else project
+        self._protos.link(go, generated)
+        self._protos_linked = self._generation
+        return self._protos
+    
+    def _skipped_generated_go(self, suffix: str = ".go",
+                              include: Optional[Callable[[str], bool]] = None) -> List[Tuple[str, Dict[str, Any]]]:
+        """
+        Parse the generated Go files the index skipped (all of them, or those
+        ending in suffix and accepted by include), cached by modification
+        time and size.
+        """
+        skipped = self.last_walk["skipped"].get("generated", []) if self.last_walk else []
+        extra = []
+        for label in skipped:
+            if not label.endswith(suffix):
+                continue
+            file_path = self.root_path / label
+            if include is not None and not include(str(file_path)):
+                continue
+            try:
+                stat = file_path.stat()
+                stamp = (stat.st_mtime_ns, stat.st_size)
+                cached = self._generated_go.get(str(file_path))
+                if cached is None or cached[0] != stamp:
+                    cached = (stamp, parse_source(str(file_path), read_text(str(file_path)),
+                                                  self._parses_partially(str(file_path), stat.st_size)))
+                    self._generated_go[str(file_path)] = cached
+            except (OSError, UnicodeDecodeError):
+                continue
+            extra.append((str(file_path), cached[1]))
+        skipped_paths = {str(self.root_path / label) for label in skipped}
+        for path in [p for p in self._generated_go if p not in skipped_paths]:
+            del self._generated_go[path]
+        return extra
+    
+    def _mock_project(self) -> GoProject:
+        """
+        The GoProject with the generated mock files the index skipped (see
+        core/go_mocks.py) added back, or the project itself without any.
+        """
+        project = self._go_project()
+        extra = self._skipped_generated_go(include=mock_file)
+        if not extra:
+            return project
+        return GoProject(list(project.files.items()) + extra, project.root, project.modules)
+    
+    @staticmethod
+    def _count_lines(file_path: Path, counts: Dict[str, Dict[str, Any]]) -> bool:
+        """Refresh the line count of a file no parser covers if its mtime or size moved; True if it did."""
+        try:
+            stat = file_path.stat()
+        except OSError:
+            return False
+        stamp = (stat.st_mtime_ns, stat.st_size)
+        entry = counts.get(str(file_path))
+        if entry and entry["stamp"] == stamp:
+            return False
+        try:
+            with open(file_path, 'r', encoding='utf-8', errors='replace') as f:
+                lines = sum(1 for _ in f)
+        except OSError:
+            lines = 0
+        counts[str(file_path)] = {"stamp": stamp, "lines": lines}
+        return True
+    
+    def _call_graph(self, build_context: Optional[Dict[str, Any]] = None) -> GoCallGraph:
+        """
+        Return the call graph of the current tree, kept in step with _go_project().
+        
+        With a build context (see core/go_build.py) the graph only has the
+        files that build compiles, so calls resolve to the variant of a
+        platform-specific function it would use. Those graphs are rebuilt
+        when the tree changes rather than updated.
+        """
+        project = self._go_project()
+        report = lambda done, total, path: self._report("call graph", done, total, path)
+        if build_context is not None:
+            context = BuildContext.from_dict(build_context)
+            cached = self._context_graphs.get(context.key())
+            if cached is None or cached[0] != self._generation:
+                cached = (self._generation, GoCallGraph(project.restricted(context.includes), report))
+                self._context_graphs[context.key()] = cached
+            return cached[1]
+        if self._graph is None:
+            self._graph = GoCallGraph(project, report)
+        return self._graph
+    
+    def cancel(self):
+        """Ask the tracked call in progress (on another thread) to stop."""
+        self._cancel.set()
+    
+    @contextmanager
+    def tracking(self, progress: Optional[Callable[[Dict[str, Any]], None]] = None):
+        """
+        Scope one tool call: progress events go to the callback, and cancel()
+        makes the call raise IndexingCancelled at its next checkpoint.
+        
+        Nothing built by a cancelled phase is kept - parse results are only
+        merged and the project or call graph only stored once complete.
+        
+        The call sees one snapshot of the tree: the index catches up with
+        the files on disk the first time the call needs it, and a file
+        saved while the call runs waits for the next call, instead of
+        answering half of a query from the old version and half from the
+        new one.
+        """
+        self._cancel.clear()
+        self.progress = progress
+        self._progress_sent = 0.0
+        self._tracked, self._pinned = True, False
+        try:
+            yield
+        finally:
+            self.progress = None
+            self._cancel.clear()
+            self._tracked = self._pinned = False
+    
+    def warnings(self) -> List[Dict[str, Any]]:
+        """
+        What keeps results from covering the whole project, as xray.core.errors
+        objects: the source files found but not indexed, by path.
+        """
+        return [self._unindexed[path] for path in sorted(self._unindexed)]
+    
+    def _report(self, phase: str, done: int, total: Optional[int] = None, current: Optional[str] = None):
+        """Checkpoint of a long phase: stop if cancelled, else send a throttled progress event."""
+        if self._cancel.is_set():
+            raise IndexingCancelled(f"{phase} was cancelled")
+        if self.progress is None:
+            return
+        now = time.monotonic()
+        if done != total and now - self._progress_sent < PROGRESS_INTERVAL:
+            return
+        self._progress_sent = now
+        if current and os.path.isabs(current):
+            current = relative(current, self.root_path)
+        self.progress({"phase": phase, "done": done, "total": total, "current": current})
+    
+    def reindex(self, force: bool = False, concurrency: Optional[int] = None) -> Dict[str, Any]:
+        """
+        Bring the Go, TypeScript/JavaScript, Python and Rust indexes up to date with the working tree.
+        
+        Args:
+            force: Drop every cached parse result and rebuild from scratch
+            concurrency: Parser worker processes to use from now on
+            
+        Returns:
+            The files that were added, modified or removed, and the time taken
+        """
+        if concurrency is not None:
+            self.concurrency = max(1, concurrency)
+        started = time.perf_counter()
+        if force:
+            self._cache.clear()
+            self._cache_dirty = True
+            self._project = None
+            self._modules = None
+            self._python = None
+            self._rust = None
+            self._java = None
+            self._protos = None
+            self._sql = None
+            self._tasks = None
+            self._graph = None
+            self._context_graphs = {}
+            # A rebuild is a full one, quick index or not
+            self._deep_packages = None
+        self._call_graph()
+        refresh = self.last_refresh
+        return {
+            "forced": force,
+            "files_indexed": len(self._project.files) + len(self._modules.files) + len(self._python.files) +
+                             len(self._rust.files) + len(self._java.files) + len(self._protos.files) +
+                             len(self._sql.files) + len(self._tasks.files),
+            "added": len(refresh["added"]),
+            "modified": refresh["modified"],
+            "removed": refresh["removed"],
+            "duration_ms": round((time.perf_counter() - started) * 1000, 1),
+            "concurrency": self.concurrency,
+        }
+    
+    def refresh(self, head_moved: bool = False) -> Dict[str, List[str]]:
+        """
+        Catch the index up after the watcher saw changes on disk.
+        
+        Re-indexing is incremental, as for any call; after a branch switch
+        the index is also saved under the newly checked out commit, so the
+        persisted index keeps following HEAD.
+        
+        Returns:
+            Paths added, modified and removed - files of every language for
+            additions and removals, parsed (Go, TypeScript, JavaScript,
+            Python) files only for modifications
+        """
+        if head_moved and not self.ref_commit:
+            commit = self._head_commit()
+            if commit != self.commit_sha:
+                self.commit_sha = commit
+                self.index_cache = IndexCache(self.root_path, commit or "worktree")
+                self._cache_dirty = True
+        before = set(self._cache.get("file-lines", {}))
+        if self._graph is not None:
+            self._call_graph()
+        else:
+            self._go_project()
+        after = set(self._cache.get("file-lines", {}))
+        return {
+            "added": sorted(set(self.last_refresh["added"]) | (after - before)),
+            "modified": self.last_refresh["modified"],
+            "removed": sorted(set(self.last_refresh["removed"]) | (before - after)),
+        }
+    
+    def deepen(self, max_files: int = DEEP_INDEX_BATCH) -> bool:
+        """
+        One step of a quick index's deep indexing, run in the background
+        between tool calls: the first builds the shallow index - every
+        file's declaration skeleton - and each later one parses the next
+        packages still shallow in full, in path order, up to max_files
+        files, patching the project and call graph as any catch-up does.
+        Packages a query named (see _resolve_path) have gone first.
+        
+        Returns:
+            Whether packages are left, False once the whole index is deep
+        """
+        if self._deep_packages is None:
+            return False
+        if self._project is not None:
+            pending: Dict[str, int] = {}
+            for path in self._shallow:
+                pending[os.path.dirname(path)] = pending.get(os.path.dirname(path), 0) + 1
+            files = 0
+            for package in sorted(pending):
+                if files and files + pending[package] > max_files:
+                    break
+                self._deep_packages.add(package)
+                files += pending[package]
+        self._go_project()
+        if not self._shallow:
+            self._deep_packages = None
+            return False
+        return True
+    
+    def deep_index_status(self) -> Optional[Dict[str, Any]]:
+        """
+        How far a quick index's deep indexing is: the percentage of files
+        parsed in full, and the packages still shallow - None once it is done.
+        """
+        if self._deep_packages is None:
+            return None
+        files = max(self._walked_files, len(self._shallow))
+        return {
+            "percent": round(100 * (files - len(self._shallow)) / files, 1) if files else 0.0,
+            "files": files,
+            "shallow_files": len(self._shallow),
+            "shallow_packages": len({os.path.dirname(path) for path in self._shallow}),
+        }
+    
+    def watch_relevant(self, path: str) -> bool:
+        """Whether a change to path can affect the index (cheap pre-filter for the watcher)."""
+        try:
+            parts = Path(path).relative_to(self.root_path).parts
+        except ValueError:
+            return False
+        if any(part in DEFAULT_EXCLUSIONS for part in parts):
+            return False
+        # Directory events (a moved or deleted package) carry no suffix
+        return not Path(path).suffix or Path(path).suffix.lower() in LANGUAGE_MAP or \
+            self._languages().language(path) is not None
+    
+    def source_stamps(self) -> Dict[str, Tuple[int, int]]:
+        """mtime and size of every indexable file, for the polling watcher."""
+        stamps = {}
+        for file_path in self._iter_source_files():
+            try:
+                stat = file_path.stat()
+            except OSError:
+                continue
+            stamps[str(file_path)] = (stat.st_mtime_ns, stat.st_size)
+        return stamps
+    
+    def find_implementations(self, name: str, path: Optional[str] = None, format: str = "json",
+                             depth: int = 1, include_mocks: bool = False) -> Dict[str, Any]:
+        """
+        Match interfaces against concrete types across the project.
+        
+        Given an interface, returns the concrete types whose method sets satisfy
+        it plus partial implementers with their missing methods. Given a
+        concrete type, returns the interfaces it satisfies (or nearly does).
+        Rust traits and types are matched through their impl blocks and
+        derive attributes instead, Java interfaces and classes through their
+        implements and extends clauses; a Go type of the name comes first.
+        
+        Args:
+            name: Interface or type name
+            path: Optional file or package directory to disambiguate the name
+            format: "json", or "mermaid" for the same result as a classDiagram
+            depth: Levels of embedded types drawn around each type (mermaid only)
+            include_mocks: Also list mock implementations (see core/go_mocks.py),
+                generated mock files the index skips included; they are left
+                out and counted in "mocks_excluded" otherwise
+        """
+        name, path = self._symbol_arg(name, path, TYPE_KINDS, {"go", "rust", "java"})
+        if format not in GRAPH_FORMATS:
+            raise ValueError(f"format must be one of {', '.join(GRAPH_FORMATS)}")
+        project = self._mock_project() if include_mocks else self._go_project()
+        scope = self._scope(path)
+        candidates = project.find_types(name, scope) + self._rust.find_types(name, scope) + \
+            self._java.find_types(name, scope)
+        if not candidates:
+            raise SymbolNotFound(f"No Go, Rust or Java type named '{name}' found")
+        
+        target = candidates[0]
+        if target.get("language") == "rust":
+            project = self._rust
+        elif target.get("language") == "java":
+            project = self._java
+        elif target.get("alias"):
+            key = project.alias_chain((os.path.dirname(target["path"]), target["name"]))["target_key"]
+            if key is None:
+                raise ValueError(f"'{name}' is an alias of a type outside the project")
+            target = project.types[key]
+        if target["type"] in ("interface", "trait"):
+            result = project.implementations_of(target)
+        else:
+            result = project.interfaces_of(target)
+        if project is not self._rust and project is not self._java:
+            self._tag_mocks(MockFinder(project), target, result, include_mocks)
+        
+        if len(candidates) > 1:
+            result["other_candidates"] = [
+                {"name": c["name"], "package": c["package"], "path": c["path"], "start_line": c["start_line"]}
+                for c in candidates[1:]
+            ]
+        if format == "mermaid":
+            return {"format": "mermaid", **type_hierarchy_diagram(project, result, depth),
+                    **{k: result[k] for k in ("total_count", "other_candidates") if k in result}}
+        return result
+    
+    @staticmethod
+    def _tag_mocks(mocks: MockFinder, target: Dict[str, Any], result: Dict[str, Any], include_mocks: bool):
+        """Mark the mocks of an implementations result, leaving them out without include_mocks."""
+        found = mocks.mocks()
+        if "type" in result:
+            key = (os.path.dirname(target["path"]), target["name"])
+            if key in found:
+                result["type"].update(mocks.describe(key))
+            return
+        excluded = 0
+        for group in ("implementations", "partial"):
+            kept = []
+            for entry in result[group]:
+                key = (os.path.dirname(entry["path"]), entry["name"])
+                if key in found:
+                    if not include_mocks:
+                        excluded += 1
+                        continue
+                    entry.update(mocks.describe(key))
+                kept.append(entry)
+            result[group] = kept
+        result["total_count"] = len(result["implementations"])
+        if excluded:
+            result["mocks_excluded"] = excluded
+    
+    def interface_usage(self, name: str, path: Optional[str] = None, include_tests: bool = False) -> Dict[str, Any]:
+        """
+        Count the calls made through a Go interface, method by method (see
+        core/go_interfaces.py): call sites whose receiver has the interface
+        (or one embedding it) as its static type, not a concrete implementer.
+        
+        Args:
+            name: Interface name ("Service"); an alias is followed to its target
+            path: Optional file or package directory to disambiguate the name
+            include_tests: Also count calls made in _test.go files
+            
+        Returns:
+            Dictionary with each method's calls, references, call sites and
+            consumer packages, the methods never called through the interface
+            ("unused"), and the consumers grouped by the methods they use
+        """
+        name, path = self._symbol_arg(name, path, {"interface"}, {"go"})
+        project = self._go_project()
+        scope = self._scope(path)
+        candidates = project.find_types(name, scope)
+        if not candidates:
+            raise SymbolNotFound(f"No Go interface named '{name}' found")
+        
+        target = candidates[0]
+        if target.get("alias"):
+            key = project.alias_chain((os.path.dirname(target["path"]), target["name"]))["target_key"]
+            if key is None:
+                raise ValueError(f"'{name}' is an alias of a type outside the project")
+            target = project.types[key]
+        if target["type"] != "interface":
+            raise ValueError(f"'{name}' is a {target['type']}, not an interface")
+        result = InterfaceUsage(self._call_graph()).usage(target, include_tests)
+        if len(candidates) > 1:
+            result["other_candidates"] = [
+                {"name": c["name"], "package": c["package"], "path": c["path"], "start_line": c["start_line"]}
+                for c in candidates[1:]
+            ]
+        return result
+    
+    def trace_variable(self, symbol: str, variable: str, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Trace one variable through one Go function body (see
+        core/go_nilflow.py): every assignment to it, whether each can leave
+        it nil, and the dereferences and pointer passes that follow.
+        
+        Args:
+            symbol: Function or method name ("GetUser" or "UserService.GetUser")
+            variable: The variable's name ("user"); parameters, receivers and
+                named results count
+            path: Optional file or package directory to disambiguate the function
+            
+        Returns:
+            Dictionary with the variable's declarations (one per shadowing
+            scope), its assignments with can_be_nil (true, false, or null when
+            unknown) and nil_reason, the nil checks, the dereferences with the
+            assignment each follows, and the pointer passes
+        """
+        symbol, path = self._symbol_arg(symbol, path, FUNCTION_KINDS, {"go"})
+        graph = self._call_graph()
+        scope = self._scope(path)
+        candidates = graph.find_nodes(symbol, scope)
+        if not candidates:
+            raise SymbolNotFound(f"No Go function or method named '{symbol}' found")
+        result = VariableTracer(graph, self._source_readers()[0]).trace(candidates[0], variable)
+        if len(candidates) > 1:
+            result["other_candidates"] = [graph.nodes[c] for c in candidates[1:]]
+        return result
+    
+    def list_mocks(self, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        List the project's interfaces with and without mocks.
+        
+        Args:
+            path: Optional file or directory to limit the interfaces to
+            
+        Returns:
+            Dictionary with "mocked" interfaces and their mock types,
+            "unmocked" ones, and mocks linked to no project interface;
+            generated mock files the index skips are read for this too
+        """
+        scope = self._scope(path)
+        return MockFinder(self._mock_project()).list(scope)
+    
+    def check_mocks(self, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Find Go mocks out of step with their interfaces, and mock files the
+        project's go:generate directives should have generated.
+        
+        Args:
+            path: Optional file or directory to limit the interfaces, mocks and directives to
+            
+        Returns:
+            Findings: "stale_mock" with the interface methods the mock is
+            "missing", the "extra" ones it still has and the "mismatched"
+            signatures; "missing_mock" and "missing_mock_file" for mockgen,
+            mockery and moq directives whose output lacks a mock or is not
+            there at all; each with the directive to re-run when one is known
+        """
+        scope = self._scope(path)
+        return MockCheck(self._mock_project(), self._source_readers()[0]).check(scope)
+    
+    def type_hierarchy(self, symbol: str, path: Optional[str] = None, depth: int = 2) -> Dict[str, Any]:
+        """
+        The embeds, aliases and underlying type around a Go type, as a graph
+        (see core/go_hierarchy.py). A Java class or interface, when no Go
+        type has the name, gets its superclasses, interfaces and subtypes.
+        
+        Args:
+            symbol: Type name ("Service", "UserID"); an alias is followed to its target
+            path: Optional file or package directory to disambiguate the name
+            depth: Levels of embeds followed in each direction
+            
+        Returns:
+            Dictionary with the root node id, its kind, and the nodes and edges
+        """
+        symbol, path = self._symbol_arg(symbol, path, TYPE_KINDS, {"go", "java"})
+        project = self._go_project()
+        scope = self._scope(path)
+        candidates = project.find_types(symbol, scope)
+        if candidates:
+            result = TypeHierarchy(project).build(candidates[0], depth)
+        else:
+            candidates = self._java.find_types(symbol, scope)
+            if not candidates:
+                raise SymbolNotFound(f"No Go or Java type named '{symbol}' found")
+            result = self._java.hierarchy(candidates[0], depth)
+        if len(candidates) > 1:
+            result["other_candidates"] = [
+                {"name": c["name"], "package": c["package"], "path": c["path"], "start_line": c["start_line"]}
+                for c in candidates[1:]
+            ]
+        return result
+    
+    def type_outline(self, symbol: str, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Everything about one Go type in one place (see core/go_outline.py):
+        its declaration, fields, the methods of every file of its package
+        (those of test files flagged), the interfaces it satisfies and the
+        functions constructing it, told by their result types.
+        
+        Args:
+            symbol: Type name ("UserService"); an alias is followed to its target
+            path: Optional file or package directory to disambiguate the name
+            
+        Returns:
+            Dictionary with the type, its fields, methods (exported first),
+            promoted methods, interfaces (implementations, for an interface),
+            constructors and counts
+        """
+        symbol, path = self._symbol_arg(symbol, path, TYPE_KINDS, {"go"})
+        project = self._go_project()
+        scope = self._scope(path)
+        candidates = project.find_types(symbol, scope)
+        if not candidates:
+            raise SymbolNotFound(f"No Go type named '{symbol}' found")
+        result = TypeOutline(project).build(candidates[0])
+        if len(candidates) > 1:
+            result["other_candidates"] = [
+                {"name": c["name"], "package": c["package"], "path": c["path"], "start_line": c["start_line"]}
+                for c in candidates[1:]
+            ]
+        return result
+    
+    def _call_graph_query(self, symbol: str, path: Optional[str], depth: int, forward: bool,
+                          format: str = "json", include_tests: bool = True,
+                          build_context: Optional[Dict[str, Any]] = None,
+                          interface_resolution: str = "strict",
+                          globs: Optional[PathGlobs] = None) -> Dict[str, Any]:
+        """Shared implementation of find_callers / find_callees."""
+        symbol, path = self._symbol_arg(symbol, path, FUNCTION_KINDS, {"go"})
+        if format not in GRAPH_FORMATS:
+            raise ValueError(f"format must be one of {', '.join(GRAPH_FORMATS)}")
+        if interface_resolution not in INTERFACE_RESOLUTIONS:
+            raise ValueError(f"interface_resolution must be one of {', '.join(INTERFACE_RESOLUTIONS)}")
+        graph = self._call_graph(build_context)
+        scope = self._scope(path)
+        candidates = graph.find_nodes(symbol, scope)
+        if not candidates:
+            raise SymbolNotFound(f"No Go function or method named '{symbol}' found")
+        
+        target = candidates[0]
+        keep = globs.matches if globs else None
+        if format == "mermaid":
+            result = {"format": "mermaid", "symbol": graph.nodes[target],
+                      **call_graph_flowchart(graph, target, depth, forward, keep), "depth": depth}
+        else:
+            edges = graph.callees(target, depth) if forward else graph.callers(target, depth, keep)
+            links = graph.interface_links(target) if interface_resolution == "expanded" else []
+            for other, link in links:
+                # Calls through the interface (or on an implementation) reaching the target
+                marker = {"viaInterface": True, **link} if "interface" in link else link
+                edges.extend({**edge, **marker} for edge in graph.callers(other, depth, keep))
+            if not include_tests:
+                edges = [e for e in edges if not is_test_file(e.get("path") or e["call_site"]["path"])]
+            result = {
+                "symbol": graph.nodes[target],
+                "callees" if forward else "callers": edges,
+                "total_count": len(edges),
+                "depth": depth,
+            }
+            if interface_resolution == "expanded":
+                result["interface_resolution"] = "expanded"
+                result["linked"] = [{"name": other[1], "path": graph.nodes[other]["path"],
+                                     "line": graph.nodes[other]["line"], **link} for other, link in links]
+        if len(candidates) > 1:
+            result["other_candidates"] = [
+                {"name": graph.nodes[key]["name"], "package": graph.nodes[key]["package"],
+                 "path": graph.nodes[key]["path"], "line": graph.nodes[key]["line"]}
+                for key in candidates[1:]
+            ]
+        if build_context is not None:
+            result["build_context"] = BuildContext.from_dict(build_context).describe()
+        if globs:
+            result["path_filter"] = globs.describe()
+        return result
+    
+    def find_callers(self, symbol: str, path: Optional[str] = None, depth: int = 1,
+                     format: str = "json", include_tests: bool = True,
+                     build_context: Optional[Dict[str, Any]] = None,
+                     interface_resolution: str = "strict",
+                     include: Optional[List[str]] = None, exclude: Optional[List[str]] = None,
+                     snippet_lines: Optional[int] = None) -> Dict[str, Any]:
+        """
+        Find the functions that call (or take a reference to) a Go function or method.
+        
+        With interface_resolution "expanded" (JSON format), callers of a
+        concrete method also include calls through the interfaces it
+        implements (flagged viaInterface, with the interface), and callers of
+        an interface method the direct calls on its implementations (with the
+        implementation).
+        
+        With include or exclude globs (see PathGlobs), callers in other files
+        are left out and their own callers are not followed.
+        
+        Args:
+            symbol: Function name ("userHandler") or "Type.Method" ("UserService.GetUser")
+            path: Optional file or package directory to disambiguate the name
+            depth: How many levels of callers to follow
+            format: "json", or "mermaid" for the same graph as a flowchart
+            include_tests: Also list callers in test files
+            build_context: Optional {"goos", "goarch", "tags"}: only the files that build compiles
+            interface_resolution: "strict" (the method's own callers) or "expanded"
+            include: Only callers in files matching one of these globs
+            exclude: No callers in files matching one of these globs
+            snippet_lines: Inline each call site's line with this many lines
+                of context either side (JSON format; see ranges.snippet)
+        """
+        self._check_snippet_lines(snippet_lines)
+        result = self._call_graph_query(symbol, path, depth, forward=False, format=format, include_tests=include_tests,
+                                        build_context=build_context, interface_resolution=interface_resolution,
+                                        globs=self._path_globs(include, exclude))
+        if "callers" in result:
+            self._add_snippets(result["callers"], snippet_lines, lambda caller: caller.get("call_site") and (
+                caller["call_site"]["path"], caller["call_site"]["line"], caller["call_site"].get("column"), None))
+        return result
+    
+    def find_callees(self, symbol: str, path: Optional[str] = None, depth: int = 1,
+                     format: str = "json", include_tests: bool = True,
+                     build_context: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+        """
+        Find the functions a Go function or method calls (or takes a reference to).
+        
+        Calls into packages outside the project are reported as external
+        callees with their import-qualified name.
+        
+        Args:
+            symbol: Function name ("userHandler") or "Type.Method" ("UserService.GetUser")
+            path: Optional file or package directory to disambiguate the name
+            depth: How many levels of callees to follow
+            format: "json", or "mermaid" for the same graph as a flowchart
+            include_tests: Also list callees declared in test files (test helpers)
+            build_context: Optional {"goos", "goarch", "tags"}: only the files that build compiles
+        """
+        return self._call_graph_query(symbol, path, depth, forward=True, format=format, include_tests=include_tests,
+                                      build_context=build_context)
+    
+    def find_paths(self, from_symbol: Optional[str], to_symbol: str, max_depth: int = 8, max_paths: int = 20,
+                   interface_resolution: str = "strict", reverse: bool = False, include_tests: bool = True,
+                   from_path: Optional[str] = None, to_path: Optional[str] = None,
+                   build_context: Optional[Dict[str, Any]] = None, include: Optional[List[str]] = None,
+                   exclude: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        Find the call paths from one Go function or method to another (see
+        core/go_paths.py): each an ordered list of calls with their call
+        sites, shortest first. Recursion cannot loop a path: no function
+        is on one twice.
+        
+        Args:
+            from_symbol: Function name or "Type.Method" the paths start at
+                (None with reverse)
+            to_symbol: Function or "Type.Method" they end at; one outside the
+                project by its qualified name or a tail of it ("sql.DB.QueryRow")
+            max_depth: Most calls on a path
+            max_paths: Most paths returned
+            interface_resolution: "strict" (calls end at an interface method) or
+                "expanded" (interface methods dispatch to their implementations)
+            reverse: Instead of from_symbol, start at every entry point (main
+                and init functions, HTTP handlers): is to_symbol reachable in
+                production code
+            include_tests: Also follow calls made in test files
+            from_path: Optional file or package directory to disambiguate from_symbol
+            to_path: Optional file or package directory to disambiguate to_symbol
+            build_context: Optional {"goos", "goarch", "tags"}: only the files that build compiles
+            include: Only follow calls made in files matching one of these globs
+            exclude: Do not follow calls made in files matching one of these globs
+            
+        Returns:
+            Dictionary with the endpoints, the paths (with reverse, each
+            with its entry point), and whether the target is reachable at all
+            within max_depth
+        """
+        if interface_resolution not in INTERFACE_RESOLUTIONS:
+            raise ValueError(f"interface_resolution must be one of {', '.join(INTERFACE_RESOLUTIONS)}")
+        if reverse == (from_symbol is not None):
+            raise ValueError("Give from_symbol, or reverse to search from the entry points, not both")
+        if max_depth < 1 or max_paths < 1:
+            raise ValueError("max_depth and max_paths must be at least 1")
+        graph = self._call_graph(build_context)
+        globs = self._path_globs(include, exclude)
+        
+        def keep(file_path: str) -> bool:
+            return (include_tests or not is_test_file(file_path)) and (not globs or globs.matches(file_path))
+        
+        def located(symbol: str, path: Optional[str]) -> List[Tuple[str, str]]:
+            try:
+                name, path = self._symbol_arg(symbol, path, FUNCTION_KINDS, {"go"})
+            except SymbolNotFound:
+                return []
+            return graph.find_nodes(name, self._scope(path))
+        
+        targets = located(to_symbol, to_path) or external_targets(graph, to_symbol)
+        if not targets:
+            raise SymbolNotFound(f"No Go function or method named '{to_symbol}' found, nor any call to one")
+        external = not targets[0][0]
+        # Every external callee of a name is the target; of project ones, the first
+        targets = targets if external else targets[:1]
+        
+        def describe(key: Tuple[str, str]) -> Dict[str, Any]:
+            if not key[0]:
+                return {"name": key[1].rsplit("/", 1)[-1].split(".", 1)[-1], "qualified_name": key[1],
+                        "external": True}
+            node = graph.nodes[key]
+            return {"name": node["name"], "package": node["package"], "path": node["path"], "line": node["line"]}
+        
+        finder = CallPathFinder(graph, interface_resolution == "expanded", keep)
+        result: Dict[str, Any] = {}
+        if reverse:
+            entries = entry_points(graph, RouteExtractor(graph).extract())
+            entries = {key: entry for key, entry in entries.items() if keep(graph.nodes[key]["path"])}
+            sources = sorted(entries)
+        else:
+            sources = located(from_symbol, from_path)
+            if not sources:
+                raise SymbolNotFound(f"No Go function or method named '{from_symbol}' found")
+            result["from"] = describe(sources[0])
+            if len(sources) > 1:
+                result["other_candidates"] = [describe(key) for key in sources[1:]]
+            sources = sources[:1]
+        result["to"] = describe(targets[0])
+        if external and len(targets) > 1:
+            result["to"]["also"] = [key[1] for key in targets[1:]]
+        
+        found, truncated, distance = finder.paths(sources, set(targets), max_depth, max_paths)
+        paths = []
+        for steps in found:
+            calls = []
+            for edge in steps:
+                call = {"caller": graph.nodes[edge["caller"]]["name"], "callee": describe(edge["callee"])["name"],
+                        "kind": edge["kind"],
+                        "call_site": {"path": edge["path"], "line": edge["line"], "column": edge["column"]}}
+                if edge["kind"] == "dispatch":
+                    call["implementation"] = edge["implementation"]
+                if edge.get("call_sites"):
+                    call["call_sites"] = edge["call_sites"]
+                calls.append(call)
+            entry = {"length": len(calls), "symbols": [calls[0]["caller"]] + [c["callee"] for c in calls],
+                     "calls": calls}
+            if reverse:
+                entry["entry_point"] = {**describe(steps[0]["caller"]), **entries[steps[0]["caller"]]}
+            paths.append(entry)
+        result.update({
+            "paths": paths,
+            "total_count": len(paths),
+            "reachable": any(source in distance for source in sources),
+            "max_depth": max_depth,
+        })
+        if reverse:
+            reaching = sorted((key for key in sources if key in distance), key=lambda k: (distance[k], k))
+            result["entry_points"] = [{**describe(key), **entries[key], "distance": distance[key]} for key in reaching]
+            result["entry_point_count"] = len(entries)
+        if len(paths) >= max_paths or truncated:
+            result["truncated"] = True
+        if interface_resolution == "expanded":
+            result["interface_resolution"] = "expanded"
+        if build_context is not None:
+            result["build_context"] = BuildContext.from_dict(build_context).describe()
+        if globs:
+            result["path_filter"] = globs.describe()
+        return result
+    
+    def find_tests_for(self, symbol: str, path: Optional[str] = None, depth: int = TEST_SEARCH_DEPTH,
+                       build_context: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+        """
+        Find the tests, benchmarks, fuzz targets and examples that exercise a
+        Go function, method or type (see core/go_tests.py).
+        
+        Args:
+            symbol: Function name, "Type.Method" or a type name (all its methods)
+            path: Optional file or package directory to disambiguate the name
+            depth: Levels of callers to walk from the symbol to a test
+            build_context: Optional {"goos", "goarch", "tags"}: only the tests that build compiles
+        """
+        symbol, path = self._symbol_arg(symbol, path, FUNCTION_KINDS | TYPE_KINDS, {"go"})
+        scope = self._scope(path)
+        result = TestFinder(self._call_graph(build_context)).find(symbol, scope, depth)
+        if build_context is not None:
+            result["build_context"] = BuildContext.from_dict(build_context).describe()
+        return result
+    
+    def extract_routes(self, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        List the HTTP routes a Go project registers, and the request
+        mappings of its Spring controllers (see core/java_analysis.py).
+        
+        Args:
+            path: Optional file or directory to limit the scan to
+            
+        Returns:
+            Dictionary with the routes (pattern, method when known, resolved
+            handler, middleware stack outermost first, registration site)
+            and their count
+        """
+        graph = self._call_graph()
+        scope = self._scope(path)
+        routes = RouteExtractor(graph).extract(scope) + self._java_project().routes(scope)
+        return {"routes": routes, "total_count": len(routes)}
+    
+    def cross_language_links(self, kinds: Optional[List[str]] = None, path: Optional[str] = None,
+                             min_confidence: float = 0.0, include_tests: bool = False) -> Dict[str, Any]:
+        """
+        Link Go code to the files of other languages it embeds, runs or
+        serves (see core/cross_language.py): //go:embed directives to the
+        embedded files, exec.Command calls to shell scripts, and the
+        TypeScript/JavaScript HTTP calls to the Go routes they request.
+        
+        Args:
+            kinds: Optional subset of embed, exec, http
+            path: Optional file or directory the links start from
+            min_confidence: Leave out links less certain than this (0-1)
+            include_tests: Also link from test files
+            
+        Returns:
+            Dictionary with each link's kind, source, target, confidence and
+            evidence, the count per kind, and embed patterns matching nothing
+        """
+        unknown = sorted(set(kinds or []) - set(LINK_KINDS))
+        if unknown:
+            raise ValueError(f"Unknown kind(s) {', '.join(unknown)}; expected {', '.join(LINK_KINDS)}")
+        if not 0 <= min_confidence <= 1:
+            raise ValueError("min_confidence must be between 0 and 1")
+        scope = self._scope(path)
+        
+        def keep(file_path: str) -> bool:
+            if not include_tests and is_test_file(file_path):
+                return False
+            return scope is None or file_path == scope or file_path.startswith(scope.rstrip(os.sep) + os.sep)
+        
+        return self._cross_language_linker().links(kinds, keep, min_confidence)
+    
+    def _cross_language_linker(self) -> CrossLanguageLinker:
+        graph = self._call_graph()
+        read, _ = self._source_readers()
+        
+        def describe(file_path: str) -> Tuple[Optional[str], List[Dict[str, Any]]]:
+            language = self._language_of(file_path)
+            entry = self._file_index(Path(file_path)).get(file_path) if language else None
+            return language, (entry or {}).get("parsed", {}).get("symbols", [])
+        
+        return CrossLanguageLinker(graph, RouteExtractor(graph).extract(None), self._task_project().scripts,
+                                   sorted(self._ts_project().files), read, describe)
+    
+    def list_queries(self, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        List the SQL queries a Go project passes to database/sql or sqlx.
+        
+        Args:
+            path: Optional file or directory to limit the scan to
+            
+        Returns:
+            Dictionary with each query's text (dynamic parts as `{expr}`
+            placeholders), enclosing function and location
+        """
+        graph = self._call_graph()
+        scope = self._scope(path)
+        queries = QueryExtractor(graph).extract(scope)
+        return {"queries": queries, "total_count": len(queries),
+                "dynamic_count": sum(1 for q in queries if q["dynamic"])}
+    
+    def sql_schema(self, table: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Return the schema the project's .sql files build: every up statement
+        of its migrations folded in the order they are applied (see
+        core/sql_analysis.py).
+        
+        Args:
+            table: Optional table to return alone, with its history
+            
+        Returns:
+            Dictionary with the tables (columns, indexes, the columns dropped
+            or renamed since, where each was defined), the migrations in
+            order, the tables dropped or renamed, and the statements the
+            parser could not read
+        """
+        project = self._sql_project()
+        schema = project.schema()
+        if table is not None:
+            found = project.table(table)
+            if found is None:
+                dropped = schema["dropped_tables"].get(table.lower())
+                if dropped is None:
+                    raise SymbolNotFound(f"No table named '{table}' in the project's .sql files")
+                return {"tables": [], "dropped_tables": [dropped], "total_count": 0}
+            return {"tables": [project.describe(found, True)], "total_count": 1}
+        tables = [project.describe(t) for _, t in sorted(schema["tables"].items())]
+        result: Dict[str, Any] = {
+            "tables": tables,
+            "total_count": len(tables),
+            "migrations": project.migrations(),
+            "dropped_tables": [d for _, d in sorted(schema["dropped_tables"].items())],
+        }
+        unparsed = project.unparsed()
+        if unparsed:
+            result["unparsed"] = unparsed
+        if schema["warnings"]:
+            result["warnings"] = schema["warnings"]
+        return result
+    
+    def table_usages(self, table: str, column: Optional[str] = None, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        List the Go queries (see list_queries) that read or write a table,
+        or one of its columns, and the functions they are in.
+        
+        Args:
+            table: Table name, as the .sql files or the queries spell it
+            column: Optional column of the table; queries selecting * count too
+            path: Optional file or directory to limit the Go scan to
+            
+        Returns:
+            Dictionary with each query's operation, the table's columns it
+            names, enclosing function and location, and the functions with
+            their operations
+        """
+        project = self._sql_project()
+        scope = self._scope(path)
+        result = project.usages(table, column, QueryExtractor(self._call_graph()).extract(scope))
+        if not result["queries"] and project.table(table) is None:
+            raise SymbolNotFound(f"No table named '{table}' in the .sql files or the Go queries")
+        return result
+    
+    def stale_queries(self, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Check the Go queries (see list_queries) against the schema the .sql
+        migrations build, for tables and columns dropped or renamed since
+        or never declared.
+        
+        Args:
+            path: Optional file or directory to limit the Go scan to
+            
+        Returns:
+            Dictionary with each issue's kind, table and column, the
+            migration that dropped or renamed it, and the query's function
+            and location
+        """
+        project = self._sql_project()
+        scope = self._scope(path)
+        queries = QueryExtractor(self._call_graph()).extract(scope)
+        issues = project.stale(queries)
+        return {"issues": issues, "total_count": len(issues), "queries_checked": len(queries)}
+    
+    def list_tasks(self, path: Optional[str] = None, task: Optional[str] = None) -> Dict[str, Any]:
+        """
+        List the build entry points of a project - Makefile targets, shell
+        scripts and their functions - with their prerequisites, the commands
+        they run and what those resolve to: other targets, scripts,
+        functions, and the Go packages (main or not) of go build, test, run
+        and install (see core/task_analysis.py).
+        
+        Args:
+            path: Optional file or directory to limit the listing to
+            task: Optional target, function or script (name or path) to list
+                with everything it runs, directly or not
+            
+        Returns:
+            Dictionary with each task's kind, location, doc, prerequisites,
+            commands, runs, tools and run_by, in file and line order
+        """
+        project = self._task_project()
+        scope = self._scope(path)
+        tasks = project.tasks(self._go_project(), scope, task)
+        if task is not None and not tasks:
+            raise SymbolNotFound(f"No Makefile target, shell function or script named '{task}'")
+        return {"tasks": tasks, "total_count": len(tasks), "makefiles": len(project.makefiles),
+                "scripts": len(project.scripts)}
+    
+    def find_log_calls(self, text: Optional[str] = None, level: Optional[str] = None,
+                       include_tests: bool = False, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        List the logging calls of a Go project - log, slog, logrus, zap and
+        the project's own logger wrappers (see core/go_logging.py) - or the
+        ones whose message template could have produced a log text.
+        
+        Args:
+            text: Optional log line or fragment to find the call sites of
+            level: Only calls at this level (debug, info, warn, ...)
+            include_tests: Also list calls of _test.go files
+            path: Optional file or directory to limit the listing to
+            
+        Returns:
+            Dictionary with each call's message template, format arguments or
+            fields, level, enclosing symbol and location, best match first
+            with text, and the wrappers recognized
+        """
+        graph = self._call_graph()
+        scope = self._scope(path)
+        return LogFinder(graph).find(text, level, include_tests, scope)
+    
+    def format_strings(self, include_tests: bool = False, path: Optional[str] = None, symbol: Optional[str] = None,
+                       mismatched_only: bool = False, format: str = "json") -> Dict[str, Any]:
+        """
+        List the printf-style calls of a Go project - fmt's Printf family,
+        the log and testing ...f functions - with their format strings, and
+        check each constant format's verbs against the arguments passed (see
+        core/go_formats.py).
+        
+        Args:
+            include_tests: Also check calls of _test.go files
+            path: Optional file or directory to limit the check to
+            symbol: Only calls made in this function or method
+            mismatched_only: Only calls whose arguments do not fit their format
+            format: "json", or "sarif" for a code scanning log of the mismatches
+            
+        Returns:
+            Dictionary with each call's format, verbs, expected and passed
+            argument counts and status, the counts by status, and the formats
+            of each enclosing function
+        """
+        if format not in FINDING_FORMATS:
+            raise ValueError(f"format must be one of {', '.join(FINDING_FORMATS)}")
+        scope = self._scope(path)
+        result = FormatChecker(self._call_graph()).check(include_tests, scope, symbol, mismatched_only)
+        if format == "sarif":
+            return self._sarif(format_results(self.root_path, result))
+        return result
+    
+    def run_rules(self, rules: Optional[List[Dict[str, Any]]] = None, only: Optional[List[str]] = None,
+                  builtin: bool = False, include_tests: bool = False, path: Optional[str] = None,
+                  format: str = "json") -> Dict[str, Any]:
+        """
+        Check a Go project against declarative house rules - calls, imports
+        and type assertions forbidden where they match, or required in every
+        function in scope - from the project's configuration file (see
+        core/go_rules.py). Without rules there, the built-in examples run.
+        
+        Args:
+            rules: Rule declarations to run instead of the configured ones
+            only: Only the rules with these ids
+            builtin: Also run the built-in example rules
+            include_tests: Also check _test.go files
+            path: Optional file or directory to limit the check to
+            format: "json", or "sarif" for a code scanning log of the findings
+            
+        Returns:
+            Dictionary with the rules run (their match, polarity, source and
+            finding count) and each finding's rule, message and location
+        """
+        if format not in FINDING_FORMATS:
+            raise ValueError(f"format must be one of {', '.join(FINDING_FORMATS)}")
+        if rules is not None:
+            declared, errors = parse_rules(rules)
+            if errors:
+                raise ValueError(f"invalid rules: {'; '.join(errors)}")
+            source = "call"
+        else:
+            declared, source = self._project_config().get("rules", []), "config"
+        selected = [dict(rule, source=source) for rule in declared]
+        if builtin or not declared:
+            ids = {rule["id"] for rule in declared}
+            selected += [dict(rule, source="builtin") for rule in BUILTIN_RULES if rule["id"] not in ids]
+        if only:
+            unknown = sorted(set(only) - {rule["id"] for rule in selected})
+            if unknown:
+                raise ValueError(f"unknown rule {', '.join(unknown)} - known: "
+                                 f"{', '.join(rule['id'] for rule in selected)}")
+            selected = [rule for rule in selected if rule["id"] in only]
+        scope = self._scope(path)
+        result = RuleEngine(self._call_graph()).run(selected, include_tests, scope)
+        if format == "sarif":
+            return self._sarif(rule_results(self.root_path, result))
+        return result
+    
+    def template_usage(self, member: Optional[str] = None, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        List the text/template and html/template templates of a project -
+        template files and strings parsed in Go code - with the fields,
+        functions and templates they use, linked to the Go types they are
+        executed with (see core/go_templates.py).
+        
+        Args:
+            member: Only templates using this field or method ("User.Email"),
+                or any member of this type ("User")
+            path: Optional file or directory to limit the listing to
+            
+        Returns:
+            Dictionary with each template's invocations, functions, field
+            references, the types it is executed with and the struct members
+            they resolve to (and the paths those types lack), and the Execute
+            calls found
+        """
+        read, _ = self._source_readers()
+        files = [str(file_path) for file_path in
+                 self._named_files(lambda name: is_template_file(name) or name.endswith((".html", ".htm")))]
+        index = TemplateIndex(self._call_graph(), str(self.root_path), read, files)
+        scope = self._scope(path)
+        return index.find(member, scope)
+    
+    def config_usage(self, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        List the configuration keys a Go project reads: environment
+        variables, flags and viper keys (see core/go_config.py).
+        
+        When the tree has .env example or docker compose files, the
+        environment variables read in code are checked against the ones they
+        declare, both ways.
+        
+        Args:
+            path: Optional file or directory to limit the scan to
+            
+        Returns:
+            Dictionary with the keys (default, whether a value decides an if
+            or switch, every read with its function and location), their
+            count, and with declaration files, "declarations"
+        """
+        scope = self._scope(path)
+        keys = ConfigUsageFinder(self._call_graph()).find(scope)
+        result: Dict[str, Any] = {
+            "keys": keys,
+            "total_count": len(keys),
+            "dynamic_count": sum(1 for k in keys if k["dynamic"]),
+        }
+        
+        declarations = []
+        for file_path in self._named_files(is_env_declaration_file):
+            content = file_path.read_text(encoding="utf-8", errors="replace")
+            compose = not file_path.name.startswith(".env")
+            declarations.append({
+                "path": str(file_path),
+                "kind": "docker_compose" if compose else "env_file",
+                "entries": compose_environment(content) if compose else read_env_file(content),
+            })
+        if declarations:
+            result["declarations"] = cross_reference(keys, declarations)
+        return result
+    
+    def _named_files(self, accept: Callable[[str], bool], descend: Tuple[str, ...] = ()) -> List[Path]:
+        """
+        The files of the tree, ignore rules applied, whose names accept takes,
+        in walk order; descend names default exclusions walked into anyway.
+        """
+        ignore_rules = self._parse_gitignore()
+        allowed = {f"default: {name}" for name in descend}
+        found = []
+        for dirpath, dirnames, filenames in os.walk(self.root_path):
+            current = Path(dirpath)
+            dirnames[:] = sorted(d for d in dirnames if self._exclusion_reason(current / d, ignore_rules) in
+                                 allowed | {None})
+            found.extend(current / f for f in sorted(filenames) if accept(f))
+        return found
+    
+    def _container_map(self, globs: Optional[PathGlobs] = None) -> ContainerMap:
+        """The Dockerfiles and compose files of the tree, read afresh, linked to the Go project."""
+        dockerfiles, composes = {}, {}
+        for file_path in self._named_files(lambda name: is_dockerfile(name) or is_compose_file(name)):
+            if globs and not globs.matches(str(file_path)):
+                continue
+            content = file_path.read_text(encoding="utf-8", errors="replace")
+            if is_dockerfile(file_path.name):
+                dockerfiles[str(file_path)] = parse_dockerfile(content)
+            else:
+                composes[str(file_path)] = (read_yaml(content), compose_environment(content))
+        read, _ = self._source_readers()
+        graph = self._call_graph()
+        return ContainerMap(graph.project, str(self.root_path), dockerfiles, composes, listeners(graph, read))
+    
+    def list_containers(self, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        List the project's Dockerfiles and compose services (see
+        core/docker_analysis.py): build stages, base images, copies, ports,
+        entrypoints, compose build contexts and environment, and the Go main
+        package each one runs.
+        
+        Args:
+            path: Optional file or directory to limit the listing to
+            
+        Returns:
+            Dictionary with each container's stages or service settings and
+            the binary it runs with the addresses that binary listens on,
+            and the mismatches between those and the ports it exposes
+        """
+        scope = self._scope(path)
+        result = self._container_map().containers(scope)
+        return {"containers": result["containers"], "total_count": len(result["containers"]),
+                "port_mismatches": result["port_mismatches"]}
+    
+    def list_grpc_services(self, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        List the gRPC services defined in the project's .proto files.
+        
+        Args:
+            path: Optional .proto file or directory to limit the listing to
+            
+        Returns:
+            Dictionary with each service's rpc methods (request and response
+            types resolved to full names, streaming flags, HTTP bindings), the
+            generated Go server and client interfaces, and the Go types that
+            implement the server with the location of every method
+        """
+        protos = self._proto_project()
+        scope = self._scope(path)
+        services = protos.services(scope)
+        return {"services": services, "total_count": len(services),
+                "method_count": sum(len(s["methods"]) for s in services)}
+    
+    def _sarif(self, results: List[Dict[str, Any]]) -> Dict[str, Any]:
+        """A SARIF log of findings, with the analyzed ref recorded if there is one."""
+        return sarif_log(self.root_path, self.source_root, results, self.ref, self.ref_commit)
+    
+    def find_unused(self, include_exported: bool = False, format: str = "json") -> Dict[str, Any]:
+        """
+        Find package-level Go symbols that nothing in the project references.
+        
+        main in package main, init, test entry points, methods that satisfy
+        a referenced interface and well-known stdlib interface methods are
+        exempt. Unexported names count as mentioned only in their own package.
+        
+        Args:
+            include_exported: Also report exported symbols of library packages
+            format: "json", or "sarif" for a code scanning log
+            
+        Returns:
+            Unused symbols grouped by file, each with a confidence level
+        """
+        if format not in FINDING_FORMATS:
+            raise ValueError(f"format must be one of {', '.join(FINDING_FORMATS)}")
+        result = UnusedFinder(self._call_graph()).find(include_exported)
+        if format == "sarif":
+            return self._sarif(unused_results(self.root_path, result))
+        return result
+    
+    def global_usages(self, symbol: str, path: Optional[str] = None, format: str = "json") -> Dict[str, Any]:
+        """
+        Classify every read and write of a Go package-level variable.
+        
+        Args:
+            symbol: Variable name
+            path: Optional file or package directory to disambiguate the name
+            format: "json", or "sarif" for a code scanning log of concurrent writes
+            
+        Returns:
+            Dictionary with each access (location, enclosing function, read or
+            write and how), read/write counts, and a concurrent_writes flag
+            when the variable is written from more than one goroutine context
+        """
+        symbol, path = self._symbol_arg(symbol, path, {"variable"}, {"go"})
+        if format not in FINDING_FORMATS:
+            raise ValueError(f"format must be one of {', '.join(FINDING_FORMATS)}")
+        finder = GlobalUsageFinder(self._call_graph())
+        scope = self._scope(path)
+        candidates = finder.find_variables(symbol, scope)
+        if not candidates:
+            raise SymbolNotFound(f"No package-level Go variable named '{symbol}' found")
+        
+        result = finder.usages(candidates[0])
+        if format == "sarif":
+            return self._sarif(concurrent_write_results(self.root_path, result))
+        if len(candidates) > 1:
+            result["other_candidates"] = [
+                {"name": c["name"], "package": c["package"], "path": c["path"], "start_line": c["start_line"]}
+                for c in candidates[1:]
+            ]
+        return result
+    
+    def field_usages(self, symbol: str, path: Optional[str] = None,
+                     snippet_lines: Optional[int] = None) -> Dict[str, Any]:
+        """
+        Classify every use of a Go struct field: selectors reading or writing
+        it, struct literals setting it, encoders and row scans reaching it
+        through its type, and reflection by name (see core/go_fields.py).
+        
+        Args:
+            symbol: "Type.Field"
+            path: Optional file or package directory to disambiguate the type
+            snippet_lines: Inline the line of each access and unresolved selector
+                with this many lines of context either side (see ranges.snippet)
+            
+        Returns:
+            Dictionary with each access (location, enclosing function, read or
+            write and how), read/write counts, selectors that could not be
+            typed, and written_only when nothing reads the field
+        """
+        self._check_snippet_lines(snippet_lines)
+        symbol, path = self._symbol_arg(symbol, path, {"field"}, {"go"})
+        read, is_generated = self._source_readers()
+        finder = FieldUsageFinder(self._call_graph(), read, is_generated, self._skipped_generated_go())
+        scope = self._scope(path)
+        candidates = finder.find_fields(symbol, scope)
+        if not candidates:
+            raise SymbolNotFound(f"No Go struct field '{symbol}' found - pass it as Type.Field")
+        
+        result = finder.usages(candidates[0])
+        self._add_snippets(result["accesses"] + result["unresolved"], snippet_lines,
+                           lambda access: (access["path"], access["line"], access.get("column"), None))
+        if len(candidates) > 1:
+            result["other_candidates"] = [
+                {"name": c["name"], "package": c["package"], "path": c["path"], "start_line": c["start_line"]}
+                for c in candidates[1:]
+            ]
+        return result
+    
+    def find_failure_points(self, include_tests: bool = False, path: Optional[str] = None,
+                            kinds: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        Find where Go code can panic or end the process.
+        
+        Args:
+            include_tests: Also scan _test.go files
+            path: Optional file or directory to limit the search to
+            kinds: Optional subset of panic, fatal, exit, recover, type_assertion
+            
+        Returns:
+            Failure points grouped by package, each with its kind, enclosing
+            function, location and the call or expression behind it
+        """
+        unknown = sorted(set(kinds or []) - set(FAILURE_KINDS))
+        if unknown:
+            raise ValueError(f"Unknown kind(s) {', '.join(unknown)}; expected {', '.join(FAILURE_KINDS)}")
+        scope = self._scope(path)
+        return FailurePointFinder(self._call_graph()).find(include_tests, scope, kinds)
+    
+    def reflection_usages(self, include_tests: bool = False, path: Optional[str] = None,
+                          kinds: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        Find where Go code leaves static typing.
+        
+        Args:
+            include_tests: Also scan _test.go files
+            path: Optional file or directory to limit the search to
+            kinds: Optional subset of reflect, type_assertion, type_switch, marshal
+            
+        Returns:
+            Usages grouped by package, each with its kind, enclosing function,
+            location and the concrete types it mentions
+        """
+        unknown = sorted(set(kinds or []) - set(REFLECTION_KINDS))
+        if unknown:
+            raise ValueError(f"Unknown kind(s) {', '.join(unknown)}; expected {', '.join(REFLECTION_KINDS)}")
+        scope = self._scope(path)
+        return ReflectionFinder(self._call_graph()).find(include_tests, scope, kinds)
+    
+    def find_constructions(self, symbol: str, missing_field: Optional[str] = None, include_tests: bool = True,
+                           path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Find where values of a Go struct type are constructed: composite
+        literals, new() calls and zero-value var declarations (see
+        core/go_constructions.py).
+        
+        Args:
+            symbol: Struct type name ("User", "store.User")
+            missing_field: Only constructions that leave this field unset
+            include_tests: Also scan _test.go files
+            path: Optional file or package directory to disambiguate the type
+            
+        Returns:
+            Dictionary with every construction site, its kind, whether a
+            literal is keyed or positional and the fields it sets
+        """
+        symbol, path = self._symbol_arg(symbol, path, TYPE_KINDS, {"go"})
+        project = self._go_project()
+        scope = self._scope(path)
+        candidates = [t for t in project.find_types(symbol, scope) if t["type"] == "struct" and not t.get("alias")]
+        if not candidates:
+            raise SymbolNotFound(f"No Go struct type named '{symbol}' found")
+        read, _ = self._source_readers()
+        result = ConstructionFinder(project, read).find(candidates[0], missing_field, include_tests)
+        if len(candidates) > 1:
+            result["other_candidates"] = [
+                {"name": c["name"], "package": c["package"], "path": c["path"], "start_line": c["start_line"]}
+                for c in candidates[1:]
+            ]
+        return result
+    
+    def find_literals(self, value: str, regex: bool = False, kind: Optional[str] = None,
+                      include_tests: bool = False, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Find the string, number and boolean literals of Go code matching a value.
+        
+        Args:
+            value: A number ("100", "0x64", "-1"), true/false, or a string
+            regex: Match string literals by regular expression instead of exactly
+            kind: Search as "string", "number" or "bool" instead of guessing from value
+            include_tests: Also scan _test.go files
+            path: Optional file or directory to limit the search to
+            
+        Returns:
+            Dictionary with the matching literals in file order, each with its
+            location, enclosing symbol and syntactic role
+        """
+        read, _ = self._source_readers()
+        scope = self._scope(path)
+        return LiteralFinder(self._go_project(), read).find(value, regex, kind, include_tests, scope)
+    
+    def function_metrics(self, sort_by: str = "complexity", min_complexity: Optional[int] = None,
+                         min_loc: Optional[int] = None, min_nesting: Optional[int] = None,
+                         min_params: Optional[int] = None, min_callees: Optional[int] = None,
+                         include_tests: bool = False, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Rank Go functions and methods by the size and complexity metrics the
+        parser stores on their symbols (see core/go_metrics.py).
+        
+        Args:
+            sort_by: One of complexity, loc, statements, max_nesting, param_count,
+                result_count, distinct_callees
+            min_complexity, min_loc, min_nesting, min_params, min_callees: Only
+                functions reaching every threshold given
+            include_tests: Also list functions of _test.go files
+            path: Optional file or directory to limit the listing to
+            
+        Returns:
+            Dictionary with the matching functions, worst first, and the
+            highest value of each metric across the project
+        """
+        scope = self._scope(path)
+        minimums = {"complexity": min_complexity, "loc": min_loc, "max_nesting": min_nesting,
+                    "param_count": min_params, "distinct_callees": min_callees}
+        return rank_functions(self._go_project(), sort_by, minimums, include_tests, scope)
+    
+    def coverage_by_symbol(self, profile_path: str, threshold: Optional[float] = None,
+                           path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Map a Go coverage profile onto the project's functions.
+        
+        Args:
+            profile_path: The profile `go test -coverprofile` wrote, absolute or
+                relative to the project root (read from the working tree)
+            threshold: Only list functions covered below this percentage
+            path: Optional file or directory to limit the listing to
+            
+        Returns:
+            Dictionary with per-function covered and total statements, least
+            covered first, package rollups, totals and the exported functions
+            no test reaches; functions placed on a drifted profile by their
+            shape are marked "approximate"
+        """
+        profile = self._source_path(str(self._resolve_path(profile_path)))
+        if not os.path.isfile(profile):
+            raise FileNotFoundError(f"Coverage profile not found: {profile_path}")
+        with open(profile, 'r', encoding='utf-8', errors='replace') as f:
+            text = f.read()
+        scope = self._scope(path)
+        return CoverageMapper(self._go_project()).map(text, threshold, scope)
+    
+    def audit_context(self, include_unexported: bool = False, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Find where Go code drops a context.Context.
+        
+        Args:
+            include_unexported: Also check unexported functions for a missing context
+            path: Optional file or directory to limit the audit to
+            
+        Returns:
+            Dictionary with "missing_context" (functions without a context
+            that call APIs accepting one) and "background_calls"
+            (context.Background/TODO outside main and tests), each with the
+            call chains from the nearest callers that had a context
+        """
+        scope = self._scope(path)
+        return ContextAuditor(self._call_graph()).audit(include_unexported, scope)
+    
+    def audit_errors(self, include_tests: bool = False, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Find where Go code drops errors or passes them on without context.
+        
+        Args:
+            include_tests: Also scan _test.go files
+            path: Optional file or directory to limit the audit to
+            
+        Returns:
+            Findings grouped by package, most severe first: "dropped_error"
+            and "unwrapped_return" (with the frames the error crossed
+            unchanged), and each package's sentinel errors and error types
+            with where they are created and checked
+        """
+        scope = self._scope(path)
+        return ErrorAudit(self._call_graph()).audit(include_tests, scope)
+    
+    def audit_resources(self, include_tests: bool = False, path: Optional[str] = None,
+                        list_defers: bool = False) -> Dict[str, Any]:
+        """
+        Find Go resources acquired without a release on every path, and defers in loops.
+        
+        Args:
+            include_tests: Also scan _test.go files
+            path: Optional file or directory to limit the audit to
+            list_defers: Also list every defer statement with what it defers
+            
+        Returns:
+            Findings most severe first: "discarded", "never_released" and
+            "released_on_some_paths" resources (files, rows, transactions,
+            responses, cancel functions, locks, ...) with the release looked
+            for, and "defer_in_loop"; every defer counted by what it defers
+        """
+        scope = self._scope(path)
+        return ResourceAudit(self._call_graph(), self._source_readers()[0]).audit(include_tests, scope, list_defers)
+    
+    def concurrency_map(self, function: Optional[str] = None, channel: Optional[str] = None,
+                        path: Optional[str] = None, depth: int = 10) -> Dict[str, Any]:
+        """
+        Map goroutine launch sites and channel usage.
+        
+        Args:
+            function: Go function or method ("Func" or "Type.Method"): list the
+                goroutines it launches, directly or through what it calls
+            channel: Channel variable ("jobs", "Pool.jobs" for a field or
+                "Fan.out" for a local): list every place it is used
+            path: Optional file or package directory, to disambiguate a name
+                or, without one, to scope the overview
+            depth: How many calls deep to follow from the function
+            
+        Returns:
+            With function, the goroutines reachable from it; with channel, its
+            declarations, sends, receives, closes and ranges; otherwise every
+            launch site and channel declaration. Goroutine literals capturing
+            a loop variable carry captures_loop_vars.
+        """
+        if function and channel:
+            raise ValueError("Give either function or channel, not both")
+        if function:
+            function, path = self._symbol_arg(function, path, FUNCTION_KINDS, {"go"})
+        go_mod = self._go_mod()
+        concurrency = ConcurrencyMap(self._call_graph(), go_mod and go_mod["go"])
+        scope = self._scope(path)
+        result: Dict[str, Any] = {"go_version": concurrency.go_version,
+                                  "loop_semantics": "per_iteration" if concurrency.per_iteration else "shared"}
+        if function:
+            graph = concurrency.graph
+            candidates = graph.find_nodes(function, scope)
+            if not candidates:
+                raise SymbolNotFound(f"No Go function or method named '{function}' found")
+            goroutines = concurrency.goroutines_from(candidates[0], depth)
+            result.update({"symbol": graph.nodes[candidates[0]], "goroutines": goroutines,
+                           "total_count": len(goroutines), "depth": depth})
+        elif channel:
+            candidates = concurrency.find_channels(channel, scope)
+            if not candidates:
+                raise SymbolNotFound(f"No Go channel variable named '{channel}' found")
+            result.update(concurrency.channel_usage(candidates[0]))
+            if len(candidates) > 1:
+                result["other_candidates"] = [
+                    {"name": concurrency.display_name(c), "kind": c[0], "package_dir": c[1]} for c in candidates[1:]
+                ]
+        else:
+            result.update({"goroutines": concurrency.goroutines(scope), "channels": concurrency.channels(scope)})
+        result["loop_captures"] = sum(1 for g in result.get("goroutines", []) if g.get("captures_loop_vars"))
+        return result
+    
+    def export_tags(self, output: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Write a Universal Ctags-compatible tags file of the Go symbol index.
+        
+        Args:
+            output: Where to write, relative to the project root (default "tags");
+                file names in it are relative to its directory
+            
+        Returns:
+            Dictionary with the path written and tag and file counts
+        """
+        target = Path(output or "tags")
+        if not target.is_absolute():
+            target = self.source_root / target
+        target = Path(self.allowlist.check(target))
+        if target.is_dir():
+            raise XRayError(f"{target} is a directory", str(target))
+        
+        project = self._go_project()
+        lines, file_count = build_tags(project, str(target.parent), self._source_path)
+        target.parent.mkdir(parents=True, exist_ok=True)
+        fd, tmp = tempfile.mkstemp(dir=target.parent, prefix=".tags-")
+        try:
+            with os.fdopen(fd, "w", encoding="utf-8", newline="\n") as f:
+                f.write("\n".join(lines) + "\n")
+            os.replace(tmp, target)
+        except BaseException:
+            os.unlink(tmp)
+            raise
+        return {
+            "path": str(target),
+            "tag_count": sum(1 for line in lines if not line.startswith("!_TAG_")),
+            "file_count": file_count,
+        }
+    
+    def export_scip(self, output: Optional[str] = None, version: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Write a SCIP index of the Go symbols and references, for Sourcegraph.
+        
+        Args:
+            output: Where to write, relative to the project root (default "index.scip")
+            version: Version of the module's symbols; defaults to the commit analyzed
+            
+        Returns:
+            Dictionary with the path written and document, occurrence and symbol counts
+        """
+        target = Path(output or "index.scip")
+        if not target.is_absolute():
+            target = self.source_root / target
+        target = Path(self.allowlist.check(target))
+        if target.is_dir():
+            raise XRayError(f"{target} is a directory", str(target))
+        
+        go_mod = self._go_mod() or {}
+        index = scip_index(
+            self._go_project(),
+            self._call_graph(),
+            relpath=lambda path: Path(path).relative_to(self.root_path).as_posix(),
+            project_root=self.source_root.as_uri(),
+            module=go_mod.get("module"),
+            version=version or self.ref_commit or self._head_commit(),
+            go_version=go_mod.get("go"),
+            requires=go_mod.get("require", []),
+        )
+        target.parent.mkdir(parents=True, exist_ok=True)
+        fd, tmp = tempfile.mkstemp(dir=target.parent, prefix=".scip-")
+        try:
+            with os.fdopen(fd, "wb") as f:
+                f.write(encode_scip(index))
+            os.replace(tmp, target)
+        except BaseException:
+            os.unlink(tmp)
+            raise
+        return {
+            "path": str(target),
+            "module": go_mod.get("module"),
+            "document_count": len(index["documents"]),
+            "occurrence_count": sum(len(doc["occurrences"]) for doc in index["documents"]),
+            "symbol_count": sum(len(doc["symbols"]) for doc in index["documents"]),
+            "external_symbol_count": len(index["external_symbols"]),
+        }
+    
+    def export_symbols(self, output: Optional[str] = None, format: str = "csv") -> Dict[str, Any]:
+        """
+        Write the whole symbol table, every language, as CSV or JSON Lines.
+        
+        Rows are streamed to the file one declaration at a time (see
+        xray.core.symbol_table for the columns).
+        
+        Args:
+            output: Where to write, relative to the project root (default "symbols.csv" or "symbols.jsonl")
+            format: "csv" or "jsonl"
+            
+        Returns:
+            Dictionary with the path written, the columns and symbol and file counts
+        """
+        files = [0]
+        
+        def rows():
+            scopes: Dict[str, str] = {}
+            for file_path in sorted(self._iter_source_files(NATIVE_LANGUAGES)):
+                try:
+                    parsed = self._refresh_file(file_path)[0]
+                except Exception:
+                    continue
+                path = str(file_path)
+                file = relative(path, self.root_path)
+                files[0] += 1
+                symbols = parsed["symbols"] + parsed.get("nested", [])
+                for declaration, symbol in zip(self._declarations(path, parsed, scopes), symbols):
+                    package = declaration["scope"] if declaration["language"] == "go" else os.path.dirname(file)
+                    yield symbol_row(declaration, symbol, file, package)
+        
+        target, count = self._write_table(output, "symbols", format, SYMBOL_COLUMNS, rows())
+        return {"path": target, "format": format, "columns": list(SYMBOL_COLUMNS),
+                "symbol_count": count, "file_count": files[0]}
+    
+    def export_references(self, output: Optional[str] = None, format: str = "csv") -> Dict[str, Any]:
+        """
+        Write every call of, and reference to, a Go function or method as CSV or JSON Lines.
+        
+        Each row names the calling and the called declaration by symbol ID
+        and qualified name, and where the call is; see xray.core.symbol_table.
+        
+        Args:
+            output: Where to write, relative to the project root (default "references.csv" or "references.jsonl")
+            format: "csv" or "jsonl"
+            
+        Returns:
+            Dictionary with the path written, the columns and the reference count
+        """
+        graph = self._call_graph()
+        
+        def rows():
+            scopes: Dict[str, str] = {}
+            names: Dict[Tuple[str, str], Tuple[str, str]] = {}
+            
+            def named(key: Tuple[str, str]) -> Tuple[Optional[str], str]:
+                if key not in graph.functions:
+                    return None, key[1]
+                if key not in names:
+                    symbol = graph.functions[key]
+                    scope = self._symbol_scope(graph.nodes[key]["path"], "go", scopes)
+                    names[key] = (symbol_id("go", scope, key[1], symbol["type"], symbol.get("signature")),
+                                  f"{scope}.{key[1]}")
+                return names[key]
+            
+            for edge in graph.edges:
+                caller_id, caller = named(edge["caller"])
+                callee_id, callee = (None, edge["callee"][1]) if edge["external"] else named(edge["callee"])
+                yield {
+                    "from_symbol_id": caller_id,
+                    "from_symbol": caller,
+                    "to_symbol_id": callee_id,
+                    "to_symbol": callee,
+                    "kind": edge["kind"],
+                    "external": edge["external"],
+                    "file": relative(edge["path"], self.root_path),
+                    "line": edge["line"],
+                    "column": edge["column"],
+                }
+        
+        target, count = self._write_table(output, "references", format, REFERENCE_COLUMNS, rows())
+        return {"path": target, "format": format, "columns": list(REFERENCE_COLUMNS), "reference_count": count}
+    
+    def export_chunks(self, output: Optional[str] = None, path: Optional[str] = None,
+                      target_tokens: int = DEFAULT_TARGET_TOKENS, max_chunk_tokens: int = DEFAULT_MAX_TOKENS,
+                      overlap_tokens: int = DEFAULT_OVERLAP_TOKENS, tokenizer: str = "chars") -> Dict[str, Any]:
+        """
+        Write the indexed source cut into embedding chunks as JSON Lines.
+        
+        Each top-level declaration is a chunk with its doc comment, small
+        neighbours are joined and large ones split at statement boundaries
+        (see xray.core.chunks); chunks are streamed to the file one file at
+        a time, in path order.
+        
+        Args:
+            output: Where to write, relative to the project root (default "chunks.jsonl")
+            path: Optional file or directory to chunk instead of the whole project
+            target_tokens: Size small neighbouring declarations are joined up to
+            max_chunk_tokens: Size over which a declaration is split
+            overlap_tokens: Size of the tail of a split part repeated at the start of the next
+            tokenizer: Token estimator, a name of chunks.ESTIMATORS
+            
+        Returns:
+            Dictionary with the path written, the columns and the chunk, file and token counts
+        """
+        chunker = Chunker(estimator(tokenizer), target_tokens, max_chunk_tokens, overlap_tokens)
+        scope = Path(self._scope(path)) if path else None
+        counts = {"files": 0, "tokens": 0, "split_symbols": 0}
+        
+        def rows():
+            scopes: Dict[str, str] = {}
+            for file_path in sorted(self._iter_source_files(NATIVE_LANGUAGES)):
+                if scope is not None and file_path != scope and scope not in file_path.parents:
+                    continue
+                try:
+                    content, parsed, digest = self._read_indexed(file_path)
+                except Exception:
+                    continue
+                key = str(file_path)
+                file = relative(key, self.root_path)
+                language = self._language_of(file_path)
+                declarations = self._declarations(key, parsed, scopes)
+                package = self._symbol_scope(key, language, scopes) if language == "go" else os.path.dirname(file)
+                counts["files"] += 1
+                for chunk in chunker.chunks(content, parsed["symbols"], declarations, file, language, package,
+                                            digest):
+                    counts["tokens"] += chunk["tokens"]
+                    counts["split_symbols"] += chunk["part"] == 1
+                    yield chunk
+        
+        target, count = self._write_table(output, "chunks", "jsonl", CHUNK_COLUMNS, rows())
+        return {"path": target, "format": "jsonl", "columns": list(CHUNK_COLUMNS), "chunk_count": count,
+                "file_count": counts["files"], "token_count": counts["tokens"],
+                "split_symbol_count": counts["split_symbols"], "tokenizer": tokenizer}
+    
+    def _write_table(self, output: Optional[str], name: str, format: str, columns: Tuple[str, ...],
+                    rows: Iterable[Dict[str, Any]]) -> Tuple[str, int]:
+        """Stream table rows to output (default "{name}.{format}") through a temporary file; (path, row count)."""
+        if format not in EXPORT_FORMATS:
+            raise ValueError(f"format must be one of {', '.join(EXPORT_FORMATS)}")
+        target = Path(output or f"{name}.{format}")
+        if not target.is_absolute():
+            target = self.source_root / target
+        target = Path(self.allowlist.check(target))
+        if target.is_dir():
+            raise XRayError(f"{target} is a directory", str(target))
+        
+        target.parent.mkdir(parents=True, exist_ok=True)
+        fd, tmp = tempfile.mkstemp(dir=target.parent, prefix=f".{name}-")
+        try:
+            with os.fdopen(fd, "w", encoding="utf-8", newline="") as f:
+                count = write_table(f, columns, rows, format)
+            os.replace(tmp, target)
+        except BaseException:
+            os.unlink(tmp)
+            raise
+        return str(target), count
+    
+    def _locate_symbol(self, symbol: str, path: Optional[str] = None) -> List[Dict[str, Any]]:
+        """
+        Find a symbol's current location by parsing the working tree now.
+        
+        Go symbols are matched as "Name" or "Type.Method", TypeScript and
+        JavaScript ones as "Name" or "Class.member"; other languages fall back
+        to an exact-name find_symbol lookup. Nested Go declarations match as
+        "Parent.name" or, after every top-level match, "name".
+        """
+        scope = Path(self._scope(path)) if path else None
+        if scope is not None and scope.is_file():
+            files = [scope]
+        else:
+            files = [p for p in self._iter_source_files(NATIVE_LANGUAGES)
+                     if scope is None or scope in p.parents]
+        
+        matches, nested = [], []
+        for file_path in files:
+            if self._language_of(file_path) not in NATIVE_LANGUAGES:
+                continue
+            try:
+                parsed = self._refresh_file(file_path)[0]
+            except Exception:
+                continue
+            for found, symbols in ((matches, parsed["symbols"]), (nested, parsed.get("nested", []))):
+                for sym in symbols:
+                    # Go struct fields and interface method specs are not declarations of their own
+                    if "container" in sym and sym.get("language", "go") == "go":
+                        continue
+                    qualified = self._qualified_name(sym)
+                    if qualified == symbol or ("." not in symbol and sym["name"] == symbol):
+                        found.append({"language": "go", **sym, "qualified_name": qualified, "path": str(file_path)})
+        matches.extend(nested)
+        
+        if not matches:
+            for found in self.find_symbol(symbol.split(".")[-1], limit=50):
+                if found["name"] == symbol.split(".")[-1] and (scope is None or str(scope) in found["path"]):
+                    matches.append({**found, "qualified_name": found["name"]})
+        return matches
+    
+    def blame_symbol(self, symbol: str, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Blame the current line range of a symbol.
+        
+        The range is re-resolved against the working tree at query time, so
+        edits since indexing are accounted for; moved or renamed code is
+        followed back to its origin (git blame -M -C).
+        
+        Returns:
+            Per-hunk blame plus the last modification and primary author
+        """
+        symbol, path = self._symbol_arg(symbol, path)
+        matches = self._locate_symbol(symbol, path)
+        if not matches:
+            raise SymbolNotFound(f"Symbol '{symbol}' not found")
+        target = matches[0]
+        
+        repo = self._repo_for(self._source_path(target["path"]))
+        relpath = repo.relpath(self._source_path(target["path"]))
+        lines = repo.blame(relpath, target["start_line"], target["end_line"], self.ref_commit)
+        
+        result = {
+            "symbol": {
+                "name": target["qualified_name"],
+                "type": target["type"],
+                "path": target["path"],
+                "start_line": target["start_line"],
+                "end_line": target["end_line"],
+            },
+            "hunks": blame_hunks(lines, relpath),
+            **summarize_blame(lines),
+        }
+        if len(matches) > 1:
+            result["other_candidates"] = [
+                {"name": m["qualified_name"], "path": m["path"], "start_line": m["start_line"]}
+                for m in matches[1:]
+            ]
+        return self._with_history(result, [repo])
+    
+    def symbol_history(self, symbol: str, path: Optional[str] = None, max_commits: Optional[int] = 50) -> Dict[str, Any]:
+        """
+        List the commits that changed a symbol's signature or body, newest first.
+        
+        The history of the symbol's file is walked with renames followed and
+        each version parsed (cached per blob); where the symbol was renamed
+        it is tracked further under its old name.
+        
+        Args:
+            symbol: "Name" or "Type.Method"
+            path: Optional file or directory to disambiguate the name
+            max_commits: Only walk this many most recent commits of the file
+        """
+        symbol, path = self._symbol_arg(symbol, path)
+        matches = self._locate_symbol(symbol, path)
+        if not matches:
+            raise SymbolNotFound(f"Symbol '{symbol}' not found")
+        target = matches[0]
+        
+        repo = self._repo_for(self._source_path(target["path"]))
+        relpath = repo.relpath(self._source_path(target["path"]))
+        history = symbol_history(repo, relpath, target["qualified_name"], max_commits, self.ref_commit,
+                                 self.blob_cache,
+                                 lambda done, total, commit: self._report("history", done, total, commit))
+        result = {
+            "symbol": {
+                "name": target["qualified_name"],
+                "type": target["type"],
+                "path": target["path"],
+                "start_line": target["start_line"],
+                "end_line": target["end_line"],
+                "signature": target.get("signature", ""),
+            },
+            **history,
+            "total_count": len(history["history"]),
+        }
+        if len(matches) > 1:
+            result["other_candidates"] = [
+                {"name": m["qualified_name"], "path": m["path"], "start_line": m["start_line"]}
+                for m in matches[1:]
+            ]
+        return self._with_history(result, [repo])
+    
+    def _read_indexed(self, file_path: Path) -> Tuple[str, Dict[str, Any], str]:
+        """
+        Read a source file together with the parse result of exactly that content.
+        
+        The index entry is refreshed first; if its hash still differs from the
+        bytes just read (the file changed in between, or was rewritten with
+        the same mtime and size) the entry is dropped and the read retried, so
+        line numbers never come from a different version than the text.
+        """
+        index = self._file_index(file_path)
+        for _ in range(3):
+            content, encoding = read_source(str(file_path))
+            digest = content_hash(content, encoding)
+            parsed, _ = self._refresh_file(file_path)
+            entry = index.get(str(file_path))
+            if entry and entry["hash"] == digest:
+                return content, parsed, digest
+            index.pop(str(file_path), None)
+            self._cache_dirty = True
+        raise ValueError(f"{file_path} kept changing while being read; the index version does not match its content")
+    
+    @staticmethod
+    def _doc_start(lines: List[str], start_line: int) -> int:
+        """First line of the comment block directly above a declaration (start_line if none)."""
+        line = start_line - 1
+        while line >= 1:
+            text = lines[line - 1].strip()
+            if text.startswith("//"):
+                line -= 1
+            elif text.endswith("*/"):
+                while line >= 1 and "/*" not in lines[line - 1]:
+                    line -= 1
+                line -= 1
+            else:
+                break
+        return line + 1
+    
+    def _snippet(self, file_path: Path, sym: Dict[str, Any], context_lines: int) -> Dict[str, Any]:
+        """The source of one declaration, doc comment included, from a verified read."""
+        content, parsed, digest = self._read_indexed(file_path)
+        qualified = self._qualified_name
+        candidates = [s for s in parsed["symbols"] + (parsed.get("nested", []) if sym.get("nested") else [])
+                      if ("container" not in s or s.get("language", "go") != "go")
+                      and qualified(s) == qualified(sym) and s["type"] == sym["type"]]
+        if not candidates:
+            raise SymbolNotFound(f"'{qualified(sym)}' is no longer declared in {file_path}", str(file_path))
+        # Several same-named declarations (e.g. init) - take the one nearest the located line
+        current = min(candidates, key=lambda s: abs(s["start_line"] - sym["start_line"]))
+        
+        lines = source_lines(content)
+        start = self._doc_start(lines, current["start_line"])
+        end = current["end_line"]
+        snippet = {
+            "name": qualified(current),
+            "type": current["type"],
+            "language": current.get("language", "go"),
+            "path": str(file_path),
+            "signature": current.get("signature"),
+            "start_line": start,
+            "end_line": end,
+            "declaration_line": current["start_line"],
+            "source": "\n".join(lines[start - 1:end]),
+            "content_hash": digest,
+        }
+        if context_lines > 0:
+            before = max(1, start - context_lines)
+            after = min(len(lines), end + context_lines)
+            snippet["context_before"] = {"start_line": before, "text": "\n".join(lines[before - 1:start - 1])}
+            snippet["context_after"] = {"end_line": after, "text": "\n".join(lines[end:after])}
+        return snippet
+    
+    def get_symbol_source(self, symbol: str, path: Optional[str] = None, context_lines: int = 0,
+                          include_type: bool = False) -> Dict[str, Any]:
+        """
+        Return the exact source of a Go, TypeScript or JavaScript declaration,
+        doc comment (or JSDoc block) included.
+        
+        Args:
+            symbol: "Name", "Type.Method" or "Class.member"
+            path: Optional file or directory to disambiguate the name
+            context_lines: Lines of surrounding code to add above and below
+            include_type: For methods, also return the receiver type's declaration
+            
+        Returns:
+            The snippet with its line range and the content hash of the file
+            version it was cut from
+        """
+        symbol, path = self._symbol_arg(symbol, path)
+        matches = [m for m in self._locate_symbol(symbol, path)
+                   if self._language_of(m["path"]) in NATIVE_LANGUAGES]
+        if not matches:
+            raise SymbolNotFound(f"No Go, TypeScript or JavaScript symbol named '{symbol}' found")
+        target = matches[0]
+        result = self._snippet(Path(target["path"]), target, context_lines)
+        
+        if include_type and target.get("receiver"):
+            type_name = target["receiver"]["type"].split("[")[0]
+            pkg_dir = os.path.dirname(target["path"])
+            for file_path in sorted(Path(pkg_dir).glob("*.go")):
+                if not self._allowed(file_path):
+                    continue
+                try:
+                    symbols = self._get_go_symbols(file_path)
+                except Exception:
+                    continue
+                decl = next((s for s in symbols if s["name"] == type_name and "container" not in s
+                             and s["type"] not in ("function", "method", "variable", "constant")), None)
+                if decl:
+                    result["receiver_type"] = self._snippet(file_path, decl, 0)
+                    break
+        
+        if len(matches) > 1:
+            result["other_candidates"] = [
+                {"name": m["qualified_name"], "path": m["path"], "start_line": m["start_line"]}
+                for m in matches[1:]
+            ]
+        self._save_cache()
+        return result
+    
+    def analyze_buffer(self, path: str, content: str, language: Optional[str] = None) -> Dict[str, Any]:
+        """
+        What writing content to a file would change: its declarations added,
+        removed or re-signatured against the indexed file, and the references
+        from the rest of the index those leave dangling (see xray.core.buffers).
+        
+        The buffer is parsed on its own and never enters the index, so the
+        answer is a what-if; only the last buffer of each file is kept, to
+        answer the same content again without parsing it.
+        
+        Args:
+            path: The file, absolute or relative to the project root; it need not exist yet
+            content: The file's content as the editor holds it
+            language: Parse the buffer as this language whatever the file's
+                extension; against an indexed file of another language every
+                declaration is new
+            
+        Returns:
+            Dictionary with the changes, the dangling references, counts of
+            both and the buffer's parse errors (Go and SQL)
+        """
+        file_path = self._resolve_path(path)
+        own = self._language_of(file_path)
+        language = language.lower() if language else own
+        if language not in NATIVE_LANGUAGES:
+            raise UnsupportedLanguage(f"'{file_path}' is not a source file of a language XRAY parses", str(file_path))
+        self._go_project()
+        key = str(file_path)
+        entry = self._file_index(file_path).get(key)
+        scopes: Dict[str, str] = {}
+        old = self._declarations(key, entry["parsed"], scopes) if entry and language == own else []
+        
+        digest = content_hash(content)
+        cached = self._buffers.get(key)
+        if cached is None or cached[0] != (digest, language):
+            partial = self._parses_partially(key, len(content.encode("utf-8")))
+            cached = self._buffers[key] = ((digest, language), parse_source(key, content, partial, language))
+        parsed = cached[1]
+        changes = declaration_changes(old, self._declarations(key, parsed, scopes))
+        
+        if language == "go":
+            dangling = dangling_calls(self._call_graph(), key, changes)
+        elif language in ("typescript", "javascript"):
+            modules = self._ts_project()
+            dangling = dangling_imports(key, changes, ((p, modules.imports_of(p)) for p in sorted(modules.files)))
+        elif language == "python":
+            python = self._py_project()
+            dangling = dangling_imports(key, changes, ((p, python.imports_of(p)) for p in sorted(python.files)))
+        else:
+            dangling = []
+        
+        counts: Dict[str, int] = {}
+        for change in changes:
+            counts[change["change"]] = counts.get(change["change"], 0) + 1
+        result = {
+            "path": key,
+            "language": language,
+            "indexed": entry is not None,
+            "changes": changes,
+            "dangling_references": dangling,
+            "counts": {**counts, "dangling_references": len(dangling)},
+        }
+        if parsed.get("parseErrors"):
+            result["parseErrors"] = parsed["parseErrors"]
+        if parsed.get("partial"):
+            result["partial"] = True
+        return result
+    
+    def diff_symbols(self, base: str, head: str = "HEAD") -> Dict[str, Any]:
+        """
+        Compare the Go symbols of two git refs without checking either out.
+        
+        Both versions of every changed .go file are read from the object
+        database and parsed; a file that cannot be parsed on either side is
+        reported as a file-level modification instead.
+        
+        Args:
+            base: Base ref (branch, tag or SHA)
+            head: Head ref, defaults to HEAD
+        """
+        repo = self._git(self.source_root)
+        base_sha, head_sha = repo.resolve(base), repo.resolve(head)
+        scope = repo.relpath(str(self.source_root))
+        pathspec = "*.go" if scope == "." else f"{scope}/*.go"
+        
+        def parse(blob: str) -> Any:
+            content = repo.blob(blob)
+            if content is None:
+                return None
+            try:
+                return symbol_table(content)
+            except Exception as e:
+                # Cached as the error, so the version is not parsed again
+                return str(e)
+        
+        def table(blob: Optional[str], relpath: str) -> Any:
+            if blob is None:
+                return {}
+            parsed = self.blob_cache.get("symbols", blob, relpath, lambda: parse(blob))
+            return {} if parsed is None else parsed
+        
+        files = []
+        counts: Dict[str, int] = {}
+        for change in repo.changed_files(base_sha, head_sha, [pathspec]):
+            old = table(change["old_blob"] if change["old_path"] else None, change["old_path"])
+            new = table(None if change["status"] == "deleted" else change["blob"], change["path"])
+            entry = {"path": change["path"], "status": change["status"]}
+            if change["status"] == "renamed":
+                entry["old_path"] = change["old_path"]
+            errors = [t for t in (old, new) if isinstance(t, str)]
+            entry["symbols"] = [] if errors else diff_tables(old, new)
+            if errors:
+                entry["parseError"] = errors[0]
+            for symbol in entry["symbols"]:
+                counts[symbol["change"]] = counts.get(symbol["change"], 0) + 1
+            files.append(entry)
+        
+        return {
+            "base": {"ref": base, "sha": base_sha},
+            "head": {"ref": head, "sha": head_sha},
+            "files": files,
+            "summary": counts,
+        }
+    
+    def compare_refs(self, base: str) -> Dict[str, Any]:
+        """
+        Review the branch being analyzed (the ref, or HEAD) against a base branch.
+        
+        Changes are taken from the merge base of the two, as a pull request
+        shows them: the changed files, the Go symbols added, removed or
+        modified, and for every modified, removed or renamed exported symbol
+        the dependents the branch left untouched - callers from the call
+        graph, references from a whole-word search, both in this tree - which
+        are the updates a change may have missed. Requirements added to or
+        bumped in a go.mod are listed too.
+        
+        Args:
+            base: The branch, tag or SHA the changes are to be merged into
+        """
+        repo = self._git(self.source_root)
+        head = self.ref or "HEAD"
+        head_sha = self.ref_commit or repo.resolve("HEAD")
+        base_sha = repo.resolve(base)
+        fork = repo.merge_base(base_sha, head_sha)
+        scope = repo.relpath(str(self.source_root))
+        pathspecs = None if scope == "." else [scope]
+        
+        symbols = self.diff_symbols(fork, head_sha)
+        by_path = {entry["path"]: entry for entry in symbols["files"]}
+        hunks = repo.diff_hunks(fork, head_sha, pathspecs=pathspecs)
+        
+        def in_tree(relpath: str) -> str:
+            # Absolute path of a repository file in the analyzed tree (the snapshot for a ref)
+            return str(self.root_path / Path(repo.abspath(relpath)).relative_to(self.source_root))
+        
+        def updated(path: str, line: int) -> bool:
+            relpath = repo.relpath(self._source_path(path))
+            changed = hunks.get(relpath)
+            if changed and any(start <= line < start + max(count, 1) for _, _, start, count in changed["hunks"]):
+                return True
+            return any(s.get("new_lines") and s["new_lines"][0] <= line <= s["new_lines"][1]
+                       for s in by_path.get(relpath, {}).get("symbols", []))
+        
+        changes = repo.changed_files(fork, head_sha, pathspecs)
+        files = []
+        for change in changes:
+            entry = {"path": change["path"], "status": change["status"]}
+            if change["status"] == "renamed":
+                entry["old_path"] = change["old_path"]
+            if change["path"] in by_path:
+                entry["symbols"] = by_path[change["path"]]["symbols"]
+                if "parseError" in by_path[change["path"]]:
+                    entry["parseError"] = by_path[change["path"]]["parseError"]
+            files.append(entry)
+        
+        reviewed = []
+        graph = None
+        for entry in symbols["files"]:
+            for symbol in entry["symbols"]:
+                name = symbol.get("old_name", symbol["name"]) if symbol["change"] == "renamed" else symbol["name"]
+                if symbol["change"] not in ("modified", "signature_changed", "removed", "renamed") \
+                        or not name.split(".")[-1][:1].isupper():
+                    continue
+                if graph is None:
+                    graph = self._call_graph()
+                target = {
+                    "name": name,
+                    "type": symbol["type"],
+                    # The old name of a removed or renamed symbol is only found by text
+                    "change": "deleted" if symbol["change"] in ("removed", "renamed") else symbol["change"],
+                    "path": in_tree(entry["path"]),
+                    "new_lines": symbol.get("new_lines"),
+                }
+                dependents = self._dependents(graph, target)
+                missed = [d for d in dependents if not updated(d["path"], d["line"])]
+                reviewed.append({
+                    "name": symbol["name"],
+                    "change": symbol["change"],
+                    "path": entry["path"],
+                    **({"old_name": name} if symbol["change"] == "renamed" else {}),
+                    "dependents": len(dependents),
+                    "unchanged_dependents": missed,
+                })
+        
+        added, updated_requirements = [], []
+        for change in changes:
+            if os.path.basename(change["path"]) != "go.mod" or change["status"] == "deleted":
+                continue
+            old = repo.show(fork, change["old_path"]) if change["old_path"] else None
+            new = repo.show(head_sha, change["path"]) or ""
+            before = {r["path"]: r for r in parse_go_mod(old)["require"]} if old else {}
+            for requirement in parse_go_mod(new)["require"]:
+                previous = before.get(requirement["path"])
+                if previous is None:
+                    added.append({"go_mod": change["path"], **requirement})
+                elif previous["version"] != requirement["version"]:
+                    updated_requirements.append({"go_mod": change["path"], **requirement,
+                                                 "old_version": previous["version"]})
+        
+        return {
+            "base": {"ref": base, "sha": base_sha},
+            "head": {"ref": head, "sha": head_sha},
+            "merge_base": fork,
+            "files": files,
+            "summary": symbols["summary"],
+            "changed_exported_symbols": reviewed,
+            "unchanged_dependents_count": sum(len(r["unchanged_dependents"]) for r in reviewed),
+            "new_dependencies": added,
+            "updated_dependencies": updated_requirements,
+        }
+    
+    def three_way_impact(self, base: str, head: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Find the semantic-conflict candidates of merging a branch into a base
+        that moved on since the fork (see xray.core.go_three_way).
+        
+        The Go symbols the head changed since the merge base are paired with
+        those the base tip changed: a symbol changed on both sides, one side
+        newly referring to a symbol the other side changed, and names both
+        sides declared anew - each with the commits of both sides involved,
+        whether or not git would report a textual conflict.
+        
+        Args:
+            base: The branch the head is to be merged into (its current tip)
+            head: The branch under review, defaults to the ref analyzed (or HEAD)
+        """
+        repo = self._git(self.source_root)
+        head = head or self.ref or "HEAD"
+        shas = {"head": repo.resolve(head), "base": repo.resolve(base)}
+        fork = repo.merge_base(shas["base"], shas["head"])
+        changes = {side: side_changes(self.diff_symbols(fork, shas[side])) for side in SIDES}
+        project = self._go_project()
+        
+        def import_path(directory: str) -> Optional[str]:
+            # As in the analyzed tree: a module path rarely moves between the two sides
+            relative = Path(repo.abspath(directory)).relative_to(self.source_root)
+            return project.import_path(str(self.root_path / relative))
+        
+        overlaps = ThreeWayImpact(repo, fork, shas, changes, import_path, self.blob_cache).overlaps()
+        counts = {kind: sum(1 for o in overlaps if o["kind"] == kind) for kind in THREE_WAY_KINDS}
+        result = {
+            "base": {"ref": base, "sha": shas["base"]},
+            "head": {"ref": head, "sha": shas["head"]},
+            "merge_base": fork,
+            "changed_symbols": {side: len(changes[side]) for side in SIDES},
+            "overlaps": overlaps,
+            "total_count": len(overlaps),
+            "counts": counts,
+        }
+        if fork == shas["base"]:
+            result["note"] = "The base has not moved since the fork: compare_refs covers the review."
+        return self._with_history(result, [repo])
+    
+    def api_surface(self, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        List the exported API of every Go package, as go doc would show it.
+        
+        Exported functions, constants, variables and types, the exported
+        methods and fields of exported types and the methods of exported
+        interfaces, sorted by name per package, with canonical signatures
+        (see core/go_api.py). main packages are skipped.
+        
+        Args:
+            path: Optional package directory to limit the listing to (its
+                subdirectories included)
+        """
+        scope = self._scope(path)
+        return self._public_surface(api_surface(self._go_project(), str(self.root_path), scope))
+    
+    @staticmethod
+    def _public_surface(surface: Dict[str, Any]) -> Dict[str, Any]:
+        # The comparison keys are for api_diff only
+        for package in surface["packages"]:
+            for entry in package["declarations"]:
+                entry.pop("key", None)
+        return surface
+    
+    def api_usage(self, package: str, include_tests: bool = True, many: int = DEFAULT_MANY,
+                  sort_by: str = "references", format: str = "json", output: Optional[str] = None,
+                  include: Optional[List[str]] = None, exclude: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        Count the references other packages make to each exported symbol of
+        one Go package, by consuming package, and say which symbols many
+        packages use, which one package uses and which none does (see
+        core/go_api_usage.py).
+        
+        Args:
+            package: The package's directory, absolute or relative to the root, or its import path
+            include_tests: Count the references of _test.go files too
+            many: Consuming packages that make a symbol widely used
+            sort_by: references, packages or name
+            format: "json", or "csv" / "jsonl" for a table of symbol and
+                consumer written to output (see xray.core.symbol_table)
+            output: Where the table goes, relative to the project root (default "api_usage.csv" or ".jsonl")
+            include: Only the consuming files matching one of these globs (see PathGlobs)
+            exclude: Not the consuming files matching one of these globs
+            
+        Returns:
+            Dictionary with each exported symbol's consumers and usage, the
+            consuming packages and a summary of the wide, single-consumer
+            and unexport-candidate symbols; for a table, the path written
+            and the summary
+        """
+        if format != "json" and format not in EXPORT_FORMATS:
+            raise ValueError(f"format must be one of json, {', '.join(EXPORT_FORMATS)}")
+        globs = self._path_globs(include, exclude)
+        project = self._go_project()
+        pkg_dir = None if os.path.isabs(package) else project.import_dir(package)
+        pkg_dir = pkg_dir or self._scope(package)
+        if pkg_dir not in project.packages:
+            raise FileNotFound(f"'{package}' is not a Go package of the project", package)
+        if project.packages[pkg_dir]["name"] == "main":
+            raise XRayError(f"'{package}' is a main package: other packages cannot import it", package)
+        go_mod = self._go_mod()
+        result = ApiUsage(self._call_graph(), go_mod and go_mod["module"]).usage(
+            pkg_dir, include_tests, many, sort_by, keep=globs.matches if globs else None)
+        if globs:
+            result["path_filter"] = globs.describe()
+        if format == "json":
+            return result
+        rows = [dict(row, path=relative(row["path"], self.root_path)) for row in usage_rows(result)]
+        target, count = self._write_table(output, "api_usage", format, USAGE_COLUMNS, rows)
+        return {"path": target, "format": format, "columns": list(USAGE_COLUMNS), "row_count": count,
+                "package": result["package"], "total_count": result["total_count"], "summary": result["summary"]}
+    
+    def api_diff(self, base: str) -> Dict[str, Any]:
+        """
+        Compare the exported Go API of a base ref with the tree being analyzed.
+        
+        Packages are matched by directory and declarations by name; every
+        difference is an addition, a removal, or an incompatible (or, rarely,
+        compatible) modification with the old and new signatures and the
+        reason, so the result says whether the change needs a new major
+        version.
+        
+        Args:
+            base: The branch, tag or SHA of the earlier version
+        """
+        repo = self._git(self.source_root)
+        head = self.ref or "HEAD"
+        head_sha = self.ref_commit or repo.resolve("HEAD")
+        base_sha = repo.resolve(base)
+        
+        earlier = XRayIndexer(str(self.source_root), base_sha, self.include_generated, self.allowlist,
+                              self.include_submodules, blob_cache=self.blob_cache,
+                              language_map=self.language_map)
+        earlier._cancel = self._cancel
+        earlier.progress = self.progress
+        # The base's snapshot and index are cached on disk like any ref's
+        old = api_surface(earlier._go_project(), str(earlier.root_path))
+        new = api_surface(self._go_project(), str(self.root_path))
+        result = api_diff(old, new)
+        return {
+            "base": {"ref": base, "sha": base_sha},
+            # The working tree, uncommitted changes included, unless a ref is analyzed
+            "head": {"ref": head if self.ref else "working tree", "sha": head_sha},
+            **result,
+        }
+    
+    def diff_impact(self, scope: str = "all") -> Dict[str, Any]:
+        """
+        Report the blast radius of the uncommitted changes.
+        
+        Hunks of the chosen diff are mapped onto the Go symbols they fall
+        inside; each changed symbol is listed with its dependents, from the
+        call graph for functions and methods and from a whole-word reference
+        search otherwise. Deleted symbols keep the dependents that still
+        mention them, which are exactly the ones that will break.
+        
+        Args:
+            scope: "staged", "unstaged", or "all" (working tree against HEAD)
+        """
+        if scope not in DIFF_SCOPES:
+            raise ValueError(f"scope must be one of {', '.join(DIFF_SCOPES)}")
+        diff_args, old_rev, new_rev = DIFF_SCOPES[scope]
+        repo = self._git(self.source_root)
+        relscope = repo.relpath(str(self.source_root))
+        pathspec = "*.go" if relscope == "." else f"{relscope}/*.go"
+        
+        files = repo.diff_hunks(*diff_args, pathspecs=[pathspec])
+        untracked = repo.untracked_files([pathspec]) if new_rev is None else []
+        
+        def content(rev, relpath):
+            if rev is not None:
+                return repo.show(rev, relpath)
+            try:
+                return read_text(repo.abspath(relpath))
+            except OSError:
+                return None
+        
+        changed = []
+        errors = {}
+        for relpath, change in sorted(files.items()) + [(p, None) for p in untracked]:
+            old_content = None if change is None or change["status"] == "added" else content(old_rev, relpath)
+            new_content = None if change is not None and change["status"] == "deleted" else content(new_rev, relpath)
+            if change is None:
+                # Untracked: everything in the file is new
+                lines = new_content.count("\n") + 1 if new_content else 0
+                hunks = [(0, 0, 1, lines)]
+            else:
+                hunks = change["hunks"]
+            try:
+                symbols = touched_symbols(old_content, new_content, hunks)
+            except Exception as e:
+                errors[relpath] = str(e)
+                continue
+            for symbol in symbols:
+                changed.append({**symbol, "path": repo.abspath(relpath), "untracked": change is None})
+        
+        graph = self._call_graph() if changed else None
+        for symbol in changed:
+            symbol["dependents"] = self._dependents(graph, symbol)
+            symbol["dependent_count"] = len(symbol["dependents"])
+            symbol["test_dependents"] = sum(1 for d in symbol["dependents"] if d["in_test"])
+            if not symbol["untracked"]:
+                del symbol["untracked"]
+        
+        counts: Dict[str, int] = {}
+        for symbol in changed:
+            counts[symbol["change"]] = counts.get(symbol["change"], 0) + 1
+        result = {
+            "scope": scope,
+            "changed_symbols": changed,
+            "summary": counts,
+            "untracked_files": [repo.abspath(p) for p in untracked],
+        }
+        if errors:
+            result["parseErrors"] = errors
+        return result
+    
+    def _dependents(self, graph: GoCallGraph, symbol: Dict[str, Any]) -> List[Dict[str, Any]]:
+        """Callers of a changed function or method, or reference sites of anything else."""
+        if symbol["change"] != "deleted" and symbol["type"] in ("function", "method"):
+            nodes = graph.find_nodes(symbol["name"], symbol["path"])
+            if nodes:
+                return [
+                    {"name": edge["name"], "path": edge["path"], "line": edge["call_site"]["line"],
+                     "kind": edge["kind"], "in_test": edge["path"].endswith("_test.go")}
+                    for edge in graph.callers(nodes[0])
+                ]
+        own = symbol.get("new_lines") if symbol["change"] != "deleted" else None
+        dependents = []
+        for ref in self._text_references(symbol["name"].split(".")[-1]):
+            if own and ref["file"] == symbol["path"] and own[0] <= ref["line"] <= own[1]:
+                continue
+            dependents.append({"path": ref["file"], "line": ref["line"], "text": ref["text"],
+                               "kind": "reference", "in_test": ref["file"].endswith("_test.go")})
+        return dependents
+    
+    def hotspots(
+        self,
+        since: Optional[str] = None,
+        max_commits: Optional[int] = 500,
+        include_merges: bool = False,
+        sort_by: str = "score",
+        include: Optional[List[str]] = None,
+        exclude: Optional[List[str]] = None,
+        max_files: int = 50
+    ) -> Dict[str, Any]:
+        """
+        Rank Go symbols by how often they change and how complex they are.
+        
+        include and exclude globs (see PathGlobs) select the files to rank;
+        the history walk is limited to them too, so max_commits counts only
+        the commits touching them.
+        
+        Args:
+            since: Only consider commits newer than this (any git date, e.g. "6 months ago")
+            max_commits: Only consider this many most recent commits
+            include_merges: Count merge commits too (excluded by default)
+            sort_by: One of score, churn, complexity, authors, lines
+            include: Only files matching one of these globs
+            exclude: No files matching one of these globs
+            max_files: Number of most churned files to list
+            
+        Returns:
+            Every current symbol, ranked, plus the most churned files
+        """
+        repo = self._git(self.root_path)
+        globs = self._path_globs(include, exclude)
+        files: Dict[str, Dict[str, Any]] = {}
+        churn: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        repos = self._history_repos(repo)
+        # Files of a submodule have their history in its own repository
+        for prefix, history in repos:
+            # The globs are relative to the project root; a submodule's walk is filtered afterwards
+            file_specs = globs.pathspecs([history.scope], history.scope) if globs and not prefix else None
+            go_specs = globs.pathspecs([history.scoped("*.go")], history.scope) if globs and not prefix else None
+            files.update((prefix + path, stats) for path, stats in file_churn(
+                history, since, max_commits, include_merges,
+                lambda done, total, commit: self._report("file history", done, total, commit), file_specs).items())
+            churn.update(((prefix + path, name), stats) for (path, name), stats in symbol_churn(
+                history, since, max_commits, include_merges,
+                lambda done, total, commit: self._report("history", done, total, commit), go_specs,
+                self.blob_cache).items())
+        if globs:
+            files = {path: stats for path, stats in files.items() if globs.matches(repo.abspath(path))}
+        
+        current = []
+        go_files = [f for f in self._iter_source_files({"go"}) if not globs or globs.matches(str(f))]
+        for done, file_path in enumerate(go_files, 1):
+            self._report("symbols", done, len(go_files), str(file_path))
+            try:
+                symbols = self._get_go_symbols(file_path)
+            except Exception:
+                continue
+            relpath = repo.relpath(str(file_path))
+            current.extend((relpath, s) for s in symbols if "container" not in s)
+        self._save_cache()
+        
+        ranked = rank_hotspots(current, churn, sort_by)
+        top_files = sorted(files.items(), key=lambda item: (-item[1]["commits"], item[0]))[:max_files]
+        return self._with_history({
+            "symbols": ranked,
+            "files": [
+                {"path": path, "commits": stats["commits"], "authors": len(stats["authors"]),
+                 "lines_added": stats["added"], "lines_deleted": stats["deleted"]}
+                for path, stats in top_files
+            ],
+            "total_count": len(ranked),
+            "sort_by": sort_by,
+            **({"path_filter": globs.describe()} if globs else {}),
+        }, [history for _, history in repos])
+    
+    def _git(self, path: Path) -> GitRepo:
+        """The repository holding a path, fetching missing partial-clone blobs as the server was told to."""
+        return GitRepo(str(path), self._cancel, self.fetch_missing)
+    
+    @staticmethod
+    def _with_history(result: Dict[str, Any], repos: List[GitRepo]) -> Dict[str, Any]:
+        """
+        Record on a history tool's result whether a shallow clone cut its walk
+        short, and what blobs missing from a partial clone made it skip.
+        """
+        result["truncatedHistory"] = any(repo.truncated_history for repo in repos)
+        warnings = [warning for repo in repos for warning in repo.warnings()]
+        if warnings:
+            result["warnings"] = warnings
+        return result
+    
+    def _history_repos(self, repo: GitRepo) -> List[Tuple[str, GitRepo]]:
+        """The project's repository and, when indexed, every initialized submodule's, with path prefixes."""
+        if not self.include_submodules:
+            return [("", repo)]
+        return self._submodule_map().repositories(repo, self._cancel)
+    
+    def coupling(
+        self,
+        max_commits: Optional[int] = 500,
+        min_support: int = 3,
+        min_confidence: float = 0.5,
+        max_files_per_commit: int = 30,
+        exclude: Optional[List[str]] = None,
+        limit: Optional[int] = None
+    ) -> Dict[str, Any]:
+        """
+        Find file pairs that keep changing together in git history.
+        
+        Each pair is annotated with the static dependency between the two
+        files according to the Go import graph; Go pairs with no dependency
+        at all are flagged as hidden coupling.
+        
+        Args:
+            max_commits: Only mine this many most recent commits
+            min_support: Minimum number of commits that changed both files
+            min_confidence: Minimum share of one file's commits that also changed the other
+            max_files_per_commit: Skip commits touching more files than this
+            exclude: Extra glob patterns to ignore (vendored and generated files always are)
+            limit: Maximum number of pairs to return (all by default)
+        """
+        repo = self._git(self.root_path)
+        stats: Dict[str, Any] = {"files": {}, "pairs": {}, "commits_analyzed": 0, "commits_skipped": 0}
+        repos = self._history_repos(repo)
+        # Files of different repositories never share a commit; each is mined on its own
+        for prefix, history in repos:
+            found = co_changes(history, max_commits, max_files_per_commit, exclude,
+                               lambda done, total, commit: self._report("history", done, total, commit))
+            stats["files"].update((prefix + path, count) for path, count in found["files"].items())
+            stats["pairs"].update(((prefix + a, prefix + b), count) for (a, b), count in found["pairs"].items())
+            stats["commits_analyzed"] += found["commits_analyzed"]
+            stats["commits_skipped"] += found["commits_skipped"]
+        pairs = coupled_pairs(stats, min_support, min_confidence)
+        
+        project = self._go_project() if any(
+            f.endswith(".go") for pair in pairs for f in pair["files"]) else None
+        imports: Dict[str, Set[str]] = {}
+        if project:
+            for path, parsed in project.files.items():
+                deps = imports.setdefault(os.path.dirname(path), set())
+                for imp in parsed.get("imports", []):
+                    target = project.import_dir(imp["path"], path)
+                    if target:
+                        deps.add(target)
+        
+        for pair in pairs:
+            a, b = (repo.abspath(f) for f in pair["files"])
+            if not project or a not in project.files or b not in project.files:
+                pair["static_dependency"] = None
+                continue
+            dir_a, dir_b = os.path.dirname(a), os.path.dirname(b)
+            a_to_b = dir_b in imports.get(dir_a, ())
+            b_to_a = dir_a in imports.get(dir_b, ())
+            if dir_a == dir_b:
+                pair["static_dependency"] = "same_package"
+            elif a_to_b and b_to_a:
+                pair["static_dependency"] = "mutual_imports"
+            elif a_to_b or b_to_a:
+                pair["static_dependency"] = "a_imports_b" if a_to_b else "b_imports_a"
+            else:
+                pair["static_dependency"] = "none"
+            pair["hidden_coupling"] = pair["static_dependency"] == "none"
+        
+        return self._with_history({
+            "pairs": pairs[:limit],
+            "total_count": len(pairs),
+            "commits_analyzed": stats["commits_analyzed"],
+            "commits_skipped": stats["commits_skipped"],
+        }, [history for _, history in repos])
+    
+    def ownership(
+        self,
+        path: Optional[str] = None,
+        since: Optional[str] = None,
+        max_commits: Optional[int] = 500,
+        include_symbols: bool = False,
+        top: int = 5,
+        check_codeowners: bool = False
+    ) -> Dict[str, Any]:
+        """
+        Report who owns each directory: top authors by surviving lines
+        (git blame of every source file) and by commits over a window, plus
+        the bus factor - how many authors own 80% of the lines.
+        
+        Authors go through the repository's .mailmap. With check_codeowners,
+        directories whose dominant author no CODEOWNERS rule lists are
+        flagged.
+        
+        Args:
+            path: Only this file or directory (and below)
+            since: Only count commits newer than this (any git date)
+            max_commits: Only count this many most recent commits
+            include_symbols: Also attribute every top-level symbol
+            top: Authors listed per directory and symbol
+            check_codeowners: Cross-check the dominant authors against CODEOWNERS
+        """
+        repo = self._git(self.source_root)
+        target = Path(self._scope(path)) if path else self.root_path
+        files = [f for f in self._iter_source_files()
+                 if f == target or f.is_relative_to(target)] if target.is_dir() else [target]
+        
+        owners = OwnershipMap(top)
+        files_by_dir: Dict[str, List[str]] = {}
+        skipped = []
+        used: Dict[str, GitRepo] = {}
+        for done, file_path in enumerate(files, 1):
+            self._report("blame", done, len(files), str(file_path))
+            source = self._source_path(str(file_path))
+            relpath = repo.relpath(source)
+            history = self._repo_for(source)
+            history = used.setdefault(history.root, history)
+            try:
+                line_count = len(source_lines(read_text(str(file_path))))
+                blame = history.blame(history.relpath(source), 1, line_count, self.ref_commit) if line_count else []
+            except (OSError, UnicodeDecodeError, GitError):
+                # Untracked, or not text
+                skipped.append(relpath)
+                continue
+            symbols = None
+            if include_symbols:
+                try:
+                    symbols = [s for s in self._get_symbols(file_path) if "container" not in s]
+                except Exception:
+                    symbols = []
+            owners.add_file(relpath, blame, symbols)
+            files_by_dir.setdefault(os.path.dirname(relpath) or ".", []).append(relpath)
+        self._save_cache()
+        
+        source = self._source_path(str(target))
+        repos = self._history_repos(repo)
+        for prefix, history in repos:
+            within = history.relpath(source)
+            if within.startswith(".."):
+                # A submodule outside the scope, or holding all of it
+                if not Path(history.root).is_relative_to(source):
+                    continue
+                within = "."
+            commits = history.log_numstat(since, max_commits, False, None if within == "." else [within],
+                                          mailmap=True)
+            owners.add_commits([{**c, "files": [{**f, "path": prefix + f["path"]} for f in c["files"]]}
+                                for c in commits] if prefix else commits)
+        codeowners = None
+        if check_codeowners:
+            codeowners = CodeOwners.find(repo.abspath("."))
+            if codeowners is None:
+                raise ValueError(f"No CODEOWNERS file found (looked for {', '.join(CODEOWNERS_LOCATIONS)})")
+        result = owners.result(codeowners, files_by_dir)
+        result["window"] = {"since": since, "max_commits": max_commits}
+        if skipped:
+            result["skipped_files"] = skipped
+        return self._with_history(result, [*used.values(), *(history for _, history in repos)])
+    
+    def list_debt(self, markers: Optional[List[str]] = None, min_age_days: Optional[int] = None,
+                  author: Optional[str] = None, blame: bool = True,
+                  include: Optional[List[str]] = None, exclude: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        List the TODO, FIXME, HACK, XXX and NOTE markers in the comments of
+        every indexed file (see core/debt.py), each with its enclosing
+        symbol and, from git blame, when and by whom its line was written.
+        
+        Args:
+            markers: Only these markers
+            min_age_days: Only markers whose line is at least this many days old
+                (markers of unknown age are left out)
+            author: Only markers whose TODO(name) or blamed author (name or
+                email) contains this, case-insensitively
+            blame: Blame the marker lines for their age; without, markers
+                have no age and min_age_days cannot be used
+            include: Only the files matching one of these globs (see PathGlobs)
+            exclude: Not the files matching one of these globs
+            
+        Returns:
+            Dictionary with the markers in file order, their counts by
+            marker, and the packages (directories outside Go) holding the
+            most of them
+        """
+        wanted = {marker.upper() for marker in markers} if markers else set(MARKERS)
+        unknown = sorted(wanted - set(MARKERS))
+        if unknown:
+            raise ValueError(f"Unknown marker(s) {', '.join(unknown)}; expected {', '.join(MARKERS)}")
+        if min_age_days is not None and not blame:
+            raise ValueError("min_age_days needs blame")
+        globs = self._path_globs(include, exclude)
+        self._go_project()
+        
+        scopes: Dict[str, str] = {}
+        found: List[Dict[str, Any]] = []
+        by_file: Dict[str, List[Dict[str, Any]]] = {}
+        for index in self._parse_indexes():
+            for path, entry in sorted(index.items()):
+                parsed = entry["parsed"]
+                if not parsed.get("markers") or (globs and not globs.matches(path)):
+                    continue
+                language = self._language_of(path) or "go"
+                package = self._symbol_scope(path, language, scopes) if language == "go" \
+                    else relative(os.path.dirname(path), self.root_path)
+                symbols = [sym for sym in parsed["symbols"] + parsed.get("nested", [])
+                           if sym["type"] not in ("field", "module")]
+                for marker in parsed["markers"]:
+                    if marker["marker"] not in wanted:
+                        continue
+                    enclosing = max((sym for sym in symbols if sym["start_line"] <= marker["line"] <= sym["end_line"]),
+                                    key=lambda sym: (sym["start_line"], -sym["end_line"]), default=None)
+                    item = {**marker, "path": path, "language": language, "package": package,
+                            "symbol": self._qualified_name(enclosing) if enclosing else None}
+                    found.append(item)
+                    by_file.setdefault(path, []).append(item)
+        
+        used: Dict[str, GitRepo] = {}
+        if blame:
+            now = time.time()
+            for done, (path, items) in enumerate(by_file.items(), 1):
+                self._report("blame", done, len(by_file), path)
+                for item in items:
+                    item["age_days"] = None
+                try:
+                    source = self._source_path(path)
+                    history = self._repo_for(source)
+                    history = used.setdefault(history.root, history)
+                    lines = history.blame(history.relpath(source), min(i["line"] for i in items),
+                                          max(i["line"] for i in items), self.ref_commit)
+                except GitError:
+                    # Untracked, or no repository at all
+                    continue
+                blamed = {record["line"]: record for record in lines}
+                for item in items:
+                    record = blamed.get(item["line"])
+                    if record is None:
+                        continue
+                    if record["commit"].strip("0") == "":
+                        # Not committed yet: written just now, by no one git knows
+                        item["age_days"] = 0
+                        item["uncommitted"] = True
+                        continue
+                    item["age_days"] = int((now - record["timestamp"]) // 86400)
+                    item["blame"] = {"commit": record["commit"], "author": record["author"],
+                                     "author_email": record["author_email"], "date": iso_date(record["timestamp"])}
+        
+        if min_age_days is not None:
+            found = [item for item in found if item.get("age_days") is not None and item["age_days"] >= min_age_days]
+        if author:
+            needle = author.lower()
+            found = [item for item in found
+                     if needle in (item.get("author") or "").lower()
+                     or needle in (item.get("blame", {}).get("author") or "").lower()
+                     or needle in (item.get("blame", {}).get("author_email") or "").lower()]
+        
+        found.sort(key=lambda item: (item["path"], item["line"]))
+        packages: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        for item in found:
+            key = (item["language"], item["package"])
+            package = packages.setdefault(key, {"package": item["package"], "language": item["language"],
+                                                "count": 0, "by_marker": {}, "oldest_days": None})
+            package["count"] += 1
+            package["by_marker"][item["marker"]] = package["by_marker"].get(item["marker"], 0) + 1
+            if item.get("age_days") is not None:
+                package["oldest_days"] = max(package["oldest_days"] or 0, item["age_days"])
+        result = {
+            "markers": found,
+            "total_count": len(found),
+            "counts": {marker: sum(1 for item in found if item["marker"] == marker) for marker in MARKERS},
+            "by_package": sorted(packages.values(), key=lambda p: (-p["count"], p["language"], p["package"])),
+        }
+        if globs:
+            result["path_filter"] = globs.describe()
+        return self._with_history(result, list(used.values())) if used else result
+    
+    def dependencies(self, ecosystem: Optional[str] = None, direct_only: bool = False,
+                     licenses: bool = True) -> Dict[str, Any]:
+        """
+        List the third-party dependencies of the project's go.mod,
+        package.json and requirements*.txt files (see core/dependencies.py):
+        versions, replacements, licenses, and the direct ones no indexed
+        file imports.
+        
+        Args:
+            ecosystem: Only "go", "npm" or "python" dependencies
+            direct_only: Leave out indirect Go requirements
+            licenses: Look for each dependency's license on disk (vendor/,
+                the module cache, node_modules, virtualenvs)
+            
+        Returns:
+            Dictionary with the dependencies by ecosystem, manifest and name,
+            the manifests read, counts, licenses and the removable ones
+        """
+        if ecosystem is not None and ecosystem not in ECOSYSTEMS:
+            raise ValueError(f"ecosystem must be one of {', '.join(ECOSYSTEMS)}")
+        project = self._go_project()
+        read, _ = self._source_readers()
+        root = str(self.root_path)
+        found: List[Dict[str, Any]] = []
+        manifests: List[Dict[str, Any]] = []
+        
+        if ecosystem in (None, "go") and project.modules is not None:
+            cache = module_cache_dir() if licenses else None
+            for module in sorted(project.modules.modules, key=lambda m: m["dir"]):
+                go_mod = relative(os.path.join(module["dir"], "go.mod"), self.root_path)
+                required = {r["path"] for r in module["require"]}
+                # Files importing a package of each required module
+                importers: Dict[str, int] = {}
+                for file_path, parsed in project.files.items():
+                    if project.modules.module_of(os.path.dirname(file_path)) is not module:
+                        continue
+                    owners = {owning_requirement(imp["path"], required) for imp in parsed.get("imports", [])}
+                    for owner in owners - {None}:
+                        importers[owner] = importers.get(owner, 0) + 1
+                sums = parse_go_sum(read(os.path.join(module["dir"], "go.sum")) or "")
+                replaces = {r["path"]: r for r in module["replace"]}
+                manifests.append({"ecosystem": "go", "path": go_mod, "module": module["path"],
+                                  "go_sum": bool(sums)})
+                for requirement in module["require"]:
+                    if direct_only and requirement["indirect"]:
+                        continue
+                    entry: Dict[str, Any] = {
+                        "ecosystem": "go",
+                        "manifest": go_mod,
+                        "name": requirement["path"],
+                        "version": requirement["version"],
+                        "direct": not requirement["indirect"],
+                    }
+                    pseudo = decode_version(requirement["version"])
+                    if pseudo:
+                        entry["pseudo_version"] = pseudo
+                    source_path, source_version = requirement["path"], requirement["version"]
+                    replace = replaces.get(requirement["path"])
+                    if replace and replace.get("version") in (None, requirement["version"]):
+                        entry["replaced_by"] = {"path": replace["with"], "version": replace.get("with_version")}
+                        if replace.get("with_version"):
+                            source_path, source_version = replace["with"], replace["with_version"]
+                            pseudo = decode_version(replace["with_version"])
+                            if pseudo:
+                                entry["replaced_by"]["pseudo_version"] = pseudo
+                    if sums and not (replace and not replace.get("with_version")):
+                        # Whether go.sum pins the module's content; a local replacement has nothing to pin
+                        entry["checksum"] = sums.get((source_path, source_version), {}).get("module", False)
+                    entry["imported_by"] = importers.get(requirement["path"], 0)
+                    if entry["direct"] and not entry["imported_by"]:
+                        entry["removable"] = True
+                    if licenses:
+                        candidates = []
+                        if replace and not replace.get("with_version"):
+                            candidates.append(("replacement", os.path.normpath(os.path.join(module["dir"],
+                                                                                            replace["with"]))))
+                        candidates += [("vendor", os.path.join(d, "vendor", *requirement["path"].split("/")))
+                                       for d in dict.fromkeys((module["dir"], root))]
+                        candidates.append(("module_cache", os.path.join(
+                            cache, escaped_module_path(source_path) + "@" + escaped_module_path(source_version))))
+                        self._add_license(entry, candidates)
+                    found.append(entry)
+        
+        if ecosystem in (None, "npm"):
+            ts_index = self._ts_file_index()
+            for manifest in self._manifests(ts_index, "package.json"):
+                declared = parse_package_json(read(manifest) or "")
+                directory = os.path.dirname(manifest)
+                used: Set[str] = set()
+                for file_path, entry in ts_index.items():
+                    if self._nearest_manifest(file_path, "package.json") == manifest:
+                        used.update(filter(None, (npm_package(imp.get("source", ""))
+                                                  for imp in entry["parsed"].get("imports", []))))
+                manifests.append({"ecosystem": "npm", "path": relative(manifest, self.root_path)})
+                for dependency in declared:
+                    entry = {"ecosystem": "npm", "manifest": relative(manifest, self.root_path),
+                             "name": dependency["name"], "version": dependency["version"],
+                             "direct": True, "kind": dependency["kind"],
+                             "imported": dependency["name"] in used}
+                    if dependency["kind"] == "runtime" and not entry["imported"] \
+                            and not dependency["name"].startswith("@types/"):
+                        entry["removable"] = True
+                    if licenses:
+                        installed = self._installed_npm(directory, dependency["name"], read)
+                        if installed is not None:
+                            entry["installed_version"] = installed[1].get("version")
+                            declared_license = npm_license(installed[1])
+                            if declared_license:
+                                entry.update({"license": declared_license, "license_source": "node_modules"})
+                            else:
+                                self._add_license(entry, [("node_modules", installed[0])])
+                    found.append(entry)
+        
+        if ecosystem in (None, "python"):
+            py_index = self._py_file_index()
+            # Installed metadata tells the import names, for removable, as well as the license
+            installed = self._installed_python(read)
+            for manifest in self._manifests(py_index, "requirements*.txt"):
+                used = set()
+                for file_path, entry in py_index.items():
+                    if os.path.dirname(file_path) == os.path.dirname(manifest) or \
+                            file_path.startswith(os.path.dirname(manifest).rstrip(os.sep) + os.sep):
+                        used.update(imp["module"].split(".")[0] for imp in entry["parsed"].get("imports", [])
+                                    if imp.get("module") and not imp.get("level"))
+                manifests.append({"ecosystem": "python", "path": relative(manifest, self.root_path)})
+                for requirement in parse_requirements(read(manifest) or ""):
+                    entry = {"ecosystem": "python", "manifest": relative(manifest, self.root_path),
+                             "name": requirement["name"], "version": requirement["version"], "direct": True,
+                             "line": requirement["line"]}
+                    distribution = installed.get(normalized_name(requirement["name"]))
+                    if distribution is not None:
+                        entry["installed_version"] = distribution["version"]
+                        if distribution["top_level"]:
+                            entry["imported"] = bool(used & set(distribution["top_level"]))
+                            if not entry["imported"]:
+                                entry["removable"] = True
+                        if licenses:
+                            if distribution["license"]:
+                                entry.update({"license": distribution["license"], "license_source": "dist-info"})
+                            else:
+                                self._add_license(entry, [("dist-info", distribution["dir"])])
+                    found.append(entry)
+        
+        by_license: Dict[str, int] = {}
+        for entry in found:
+            license = entry.get("license", "not found")
+            by_license[license] = by_license.get(license, 0) + 1
+        result = {
+            "dependencies": found,
+            "total_count": len(found),
+            "manifests": manifests,
+            "counts": {name: sum(1 for entry in found if entry["ecosystem"] == name) for name in ECOSYSTEMS},
+            "direct_count": sum(1 for entry in found if entry["direct"]),
+            "removable": [{key: entry[key] for key in ("ecosystem", "manifest", "name")}
+                          for entry in found if entry.get("removable")],
+        }
+        if licenses:
+            result["licenses"] = dict(sorted(by_license.items(), key=lambda item: (-item[1], item[0])))
+        return result
+    
+    @staticmethod
+    def _add_license(entry: Dict[str, Any], candidates: List[Tuple[str, str]]):
+        """Set a dependency's license from the first candidate (source, directory) holding license files."""
+        for source, directory in candidates:
+            detected = detect_license(directory)
+            if detected is not None:
+                entry.update({"license": detected["license"], "license_files": detected["files"],
+                              "license_source": source})
+                return
+    
+    def _manifests(self, index: Dict[str, Any], pattern: str) -> List[str]:
+        """
+        The manifest files matching pattern ("requirements*.txt") in the
+        project root and the directories, up to the root, of the files of
+        a parse index.
+        """
+        root = str(self.root_path)
+        directories = {root}
+        for file_path in index:
+            directory = os.path.dirname(file_path)
+            while directory not in directories and directory.startswith(root):
+                directories.add(directory)
+                directory = os.path.dirname(directory)
+        found = []
+        for directory in sorted(directories):
+            try:
+                entries = sorted(os.listdir(directory))
+            except OSError:
+                continue
+            found += [os.path.join(directory, name) for name in entries
+                      if fnmatch.fnmatch(name, pattern) and os.path.isfile(os.path.join(directory, name))]
+        return found
+    
+    def _nearest_manifest(self, file_path: str, name: str) -> Optional[str]:
+        """The closest file called name in the directory of file_path or above it, up to the root."""
+        root = str(self.root_path)
+        directory = os.path.dirname(file_path)
+        while directory.startswith(root):
+            candidate = os.path.join(directory, name)
+            if os.path.isfile(candidate):
+                return candidate
+            if directory == root:
+                break
+            directory = os.path.dirname(directory)
+        return None
+    
+    def _installed_npm(self, directory: str, name: str,
+                       read: Callable[[str], Optional[str]]) -> Optional[Tuple[str, Dict[str, Any]]]:
+        """The directory and package.json of an installed npm package, looked up the way Node does."""
+        root = str(self.root_path)
+        while directory.startswith(root):
+            package_dir = os.path.join(directory, "node_modules", *name.split("/"))
+            content = read(os.path.join(package_dir, "package.json"))
+            if content is not None:
+                try:
+                    manifest = json.loads(content)
+                    return package_dir, manifest if isinstance(manifest, dict) else {}
+                except ValueError:
+                    return package_dir, {}
+            if directory == root:
+                break
+            directory = os.path.dirname(directory)
+        return None
+    
+    def _installed_python(self, read: Callable[[str], Optional[str]]) -> Dict[str, Dict[str, Any]]:
+        """The distributions installed in the project's virtualenvs, by normalized name."""
+        installed: Dict[str, Dict[str, Any]] = {}
+        for venv in (".venv", "venv", "env"):
+            for site in sorted(Path(self.root_path, venv).glob("lib/python*/site-packages")) + \
+                    [Path(self.root_path, venv, "Lib", "site-packages")]:
+                if not site.is_dir():
+                    continue
+                for info in sorted(site.glob("*.dist-info")):
+                    metadata = read(str(info / "METADATA")) or ""
+                    name = re.search(r"^Name:\s*(.+)$", metadata, re.MULTILINE)
+                    version = re.search(r"^Version:\s*(.+)$", metadata, re.MULTILINE)
+                    if not name:
+                        continue
+                    top_level = (read(str(info / "top_level.txt")) or "").split()
+                    installed.setdefault(normalized_name(name.group(1).strip()), {
+                        "version": version.group(1).strip() if version else None,
+                        "license": python_license(metadata),
+                        "top_level": top_level,
+                        "dir": str(info),
+                    })
+        return installed
+    
+    def find_stale_docs(self, blame: bool = True, include: Optional[List[str]] = None,
+                        exclude: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        Find doc comments that no longer match their declaration - another
+        name, parameters the signature lost - and the exported declarations
+        without a doc (see core/doc_drift.py). Test files are left out.
+        
+        Args:
+            blame: Blame each finding's declaration and comment; code changed
+                after its comment raises the finding's confidence
+            include: Only the files matching one of these globs (see PathGlobs)
+            exclude: Not the files matching one of these globs
+            
+        Returns:
+            Dictionary with the findings by package and file order, their
+            counts by kind, and per package how many exported declarations
+            have a doc and which do not
+        """
+        globs = self._path_globs(include, exclude)
+        project = self._go_project()
+        
+        scopes: Dict[str, str] = {}
+        found: List[Dict[str, Any]] = []
+        by_file: Dict[str, List[Tuple[Dict[str, Any], Dict[str, Any]]]] = {}
+        packages: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        go_names: Dict[str, Tuple[Set[str], Set[str]]] = {}
+        for index in self._parse_indexes():
+            for file_path, entry in sorted(index.items()):
+                language = self._language_of(file_path) or "go"
+                if language not in DOC_LANGUAGES or is_test_file(file_path) or (globs and not globs.matches(file_path)):
+                    continue
+                parsed = entry["parsed"]
+                symbols = parsed["symbols"] + parsed.get("nested", [])
+                if language == "go":
+                    pkg_dir = os.path.dirname(file_path)
+                    if pkg_dir not in go_names:
+                        in_package = [sym for f in project.packages.get(pkg_dir, {}).get("files", [file_path])
+                                      for sym in project.files.get(f, parsed)["symbols"]]
+                        go_names[pkg_dir] = ({sym["name"] for sym in in_package},
+                                             {sym["name"] for sym in in_package
+                                              if sym["type"] in TYPE_KINDS and sym["name"][:1].isupper()})
+                    declared, exported_types = go_names[pkg_dir]
+                    package = self._symbol_scope(file_path, language, scopes)
+                else:
+                    declared, exported_types = {sym["name"] for sym in symbols}, set()
+                    package = relative(os.path.dirname(file_path), self.root_path)
+                summary = packages.setdefault((language, package), {
+                    "package": package, "language": language, "count": 0, "by_kind": {},
+                    "exported": 0, "documented": 0, "undocumented": []})
+                for symbol in symbols:
+                    undocumented = exported_undocumented(symbol, language, exported_types)
+                    if undocumented or (symbol.get("doc") and exported_undocumented(
+                            {**symbol, "doc": ""}, language, exported_types)):
+                        summary["exported"] += 1
+                        if undocumented:
+                            summary["undocumented"].append(self._qualified_name(symbol))
+                        else:
+                            summary["documented"] += 1
+                    for finding in doc_findings(symbol, language, declared - {symbol["name"]}):
+                        item = {**finding, "symbol": self._qualified_name(symbol), "symbol_kind": symbol["type"],
+                                "path": file_path, "line": symbol["start_line"], "language": language,
+                                "package": package}
+                        found.append(item)
+                        by_file.setdefault(file_path, []).append((item, symbol))
+                        summary["count"] += 1
+                        summary["by_kind"][item["kind"]] = summary["by_kind"].get(item["kind"], 0) + 1
+        
+        used: Dict[str, GitRepo] = {}
+        if blame:
+            read, _ = self._source_readers()
+            now = time.time()
+            for done, (file_path, items) in enumerate(by_file.items(), 1):
+                self._report("blame", done, len(by_file), file_path)
+                lines = source_lines(read(self._source_path(file_path)) or "")
+                spans = {id(item): doc_span(lines, symbol, item["language"]) for item, symbol in items}
+                first = min([span[0] for span in spans.values() if span] + [s["start_line"] for _, s in items])
+                last = max(s.get("end_line") or s["start_line"] for _, s in items)
+                try:
+                    source = self._source_path(file_path)
+                    history = self._repo_for(source)
+                    history = used.setdefault(history.root, history)
+                    records = history.blame(history.relpath(source), first, last, self.ref_commit)
+                except GitError:
+                    # Untracked, or no repository at all
+                    continue
+                changed = {r["line"]: now if r["commit"].strip("0") == "" else r["timestamp"] for r in records}
+                for item, symbol in items:
+                    span = spans[id(item)]
+                    if span is None:
+                        continue
+                    doc_lines = range(span[0], span[1] + 1)
+                    code_lines = [n for n in range(symbol["start_line"], (symbol.get("end_line") or
+                                                                          symbol["start_line"]) + 1)
+                                  if n not in doc_lines]
+                    doc_time = max((changed[n] for n in doc_lines if n in changed), default=None)
+                    code_time = max((changed[n] for n in code_lines if n in changed), default=None)
+                    if doc_time is None or code_time is None:
+                        continue
+                    item["doc_changed"] = iso_date(doc_time)
+                    item["code_changed"] = iso_date(code_time)
+                    if code_time > doc_time:
+                        item["code_changed_after_doc"] = True
+                        item["confidence"] = raise_confidence(item["confidence"])
+        
+        found.sort(key=lambda item: (item["language"], item["package"], item["path"], item["line"]))
+        completeness = []
+        for summary in packages.values():
+            if summary["exported"]:
+                summary["coverage"] = round(summary["documented"] / summary["exported"], 3)
+            if summary["count"] or summary["exported"]:
+                completeness.append(summary)
+        result = {
+            "findings": found,
+            "total_count": len(found),
+            "counts": {kind: sum(1 for item in found if item["kind"] == kind)
+                       for kind in ("name_mismatch", "unknown_parameter")},
+            "by_package": sorted(completeness, key=lambda p: (-p["count"], p["language"], p["package"])),
+            "undocumented_count": sum(len(p["undocumented"]) for p in completeness),
+        }
+        if globs:
+            result["path_filter"] = globs.describe()
+        return self._with_history(result, list(used.values())) if used else result
+    
+    def deprecated_usage(self, include: Optional[List[str]] = None,
+                         exclude: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        List every Go declaration marked `Deprecated:` with the references
+        to it that remain, grouped by the package making them, and the
+        replacement its deprecation text names when one is found in the
+        project (see core/go_deprecation.py).
+        
+        References are resolved as rename_preview resolves them; those from
+        test files are counted apart, so a declaration only tests use is
+        "test_only" and one nothing uses "deletable".
+        
+        Args:
+            include: Only the declarations in files matching one of these globs (see PathGlobs)
+            exclude: Not the declarations in files matching one of these globs
+            
+        Returns:
+            Dictionary with the deprecated declarations, the deletable first
+            and then the most used, and counts by status
+        """
+        globs = self._path_globs(include, exclude)
+        read, is_generated = self._source_readers()
+        report = DeprecationReport(self._call_graph(), read, is_generated, self._skipped_generated_go())
+        targets = [t for t in report.deprecated() if not globs or globs.matches(t["path"])]
+        result = report.report(targets)
+        if result["counts"]["deletable"]:
+            result["note"] = ("A deletable declaration has no reference left in this project; an exported one "
+                              "may still be used by other modules importing its package.")
+        if globs:
+            result["path_filter"] = globs.describe()
+        return result
+    
+    def scan_secrets(self, blame: bool = True, format: str = "json", include: Optional[List[str]] = None,
+                     exclude: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        Scan the string literals of every indexed file and the config-like
+        files of the tree (.env, YAML, JSON, TOML, INI, key files) for
+        hard-coded credentials (see core/secrets.py). Run on request only:
+        nothing of it is indexed or cached, and no finding holds the secret.
+        
+        Tests and files under testdata/ or fixtures/ are scanned too, their
+        findings a confidence step lower and listed last.
+        
+        Args:
+            blame: Blame each finding's line for the commit that introduced it
+            format: "json", or "sarif" for a code scanning log
+            include: Only the files matching one of these globs (see PathGlobs)
+            exclude: Not the files matching one of these globs
+            
+        Returns:
+            Dictionary with the findings, most confident first, each with
+            its redacted value and preview, enclosing symbol or config
+            section, and introducing commit; their counts by kind and
+            confidence, and how many files were scanned
+        """
+        if format not in FINDING_FORMATS:
+            raise ValueError(f"format must be one of {', '.join(FINDING_FORMATS)}")
+        globs = self._path_globs(include, exclude)
+        parsed_files = {path: entry["parsed"] for index in self._parse_indexes() for path, entry in index.items()}
+        ignore_rules = self._parse_gitignore()
+        # Fixture directories hold secrets too, if only fake ones: scanned, then downranked
+        candidates = [path for path in self._named_files(lambda name: is_config_file(name) or bool(self._language_of(name)),
+                                                         descend=("testdata",))
+                      if self._exclusion_reason(path, ignore_rules) is None]
+        max_size = self._config.get("max_file_size") or MEGABYTE
+        
+        read, _ = self._source_readers()
+        found: List[Dict[str, Any]] = []
+        by_file: Dict[str, List[Dict[str, Any]]] = {}
+        scanned = 0
+        for file_path in sorted(set(map(str, candidates)) | set(parsed_files)):
+            if globs and not globs.matches(file_path):
+                continue
+            path = Path(file_path)
+            if not path.is_file() or path.stat().st_size > max_size or is_binary(file_path):
+                continue
+            content = read(file_path)
+            if content is None:
+                continue
+            scanned += 1
+            is_config = file_path not in parsed_files and not self._language_of(file_path)
+            findings = scan_text(content, is_config)
+            if not findings:
+                continue
+            parsed = parsed_files.get(file_path)
+            symbols = [sym for sym in parsed["symbols"] + parsed.get("nested", [])
+                       if sym["type"] not in ("field", "module")] if parsed else []
+            lines = source_lines(content) if is_config else []
+            fixture = is_fixture(relative(file_path, self.root_path))
+            for finding in findings:
+                item = {**finding, "path": file_path}
+                if is_config:
+                    section = config_section(lines, finding["line"], path.suffix.lower())
+                    if section:
+                        item["section"] = section
+                else:
+                    enclosing = max((sym for sym in symbols if sym["start_line"] <= finding["line"] <= sym["end_line"]),
+                                    key=lambda sym: (sym["start_line"], -sym["end_line"]), default=None)
+                    item["language"] = self._language_of(file_path)
+                    item["symbol"] = self._qualified_name(enclosing) if enclosing else None
+                if fixture:
+                    item["confidence"] = lower_confidence(item["confidence"])
+                    item["test_fixture"] = True
+                found.append(item)
+                by_file.setdefault(file_path, []).append(item)
+        
+        used: Dict[str, GitRepo] = {}
+        if blame:
+            for done, (file_path, items) in enumerate(by_file.items(), 1):
+                self._report("blame", done, len(by_file), file_path)
+                try:
+                    source = self._source_path(file_path)
+                    history = self._repo_for(source)
+                    history = used.setdefault(history.root, history)
+                    records = history.blame(history.relpath(source), min(i["line"] for i in items),
+                                            max(i["line"] for i in items), self.ref_commit)
+                except GitError:
+                    # Untracked, or no repository at all
+                    continue
+                blamed = {record["line"]: record for record in records}
+                for item in items:
+                    record = blamed.get(item["line"])
+                    if record is None:
+                        continue
+                    if record["commit"].strip("0") == "":
+                        # Not committed yet: nothing to rotate in the history, only the working tree
+                        item["uncommitted"] = True
+                        continue
+                    item["introduced"] = {"commit": record["commit"], "author": record["author"],
+                                          "author_email": record["author_email"],
+                                          "date": iso_date(record["timestamp"])}
+        
+        found.sort(key=lambda item: (item.get("test_fixture", False), -SECRET_CONFIDENCE.index(item["confidence"]),
+                                     item["path"], item["line"]))
+        counts: Dict[str, int] = {}
+        for item in found:
+            counts[item["kind"]] = counts.get(item["kind"], 0) + 1
+        result = {
+            "findings": found,
+            "total_count": len(found),
+            "counts": dict(sorted(counts.items())),
+            "by_confidence": {level: sum(1 for item in found if item["confidence"] == level)
+                              for level in reversed(SECRET_CONFIDENCE)},
+            "test_fixture_count": sum(1 for item in found if item.get("test_fixture")),
+            "files_scanned": scanned,
+        }
+        if globs:
+            result["path_filter"] = globs.describe()
+        if format == "sarif":
+            return self._sarif(secret_results(self.root_path, result))
+        return self._with_history(result, list(used.values())) if used else result
+    
+    def list_symbols(self, path: str, include_tests: bool = True, include: Optional[List[str]] = None,
+                     exclude: Optional[List[str]] = None, include_nested: bool = False,
+                     kinds: Optional[List[str]] = None, exported_only: bool = False,
+                     top_level_only: bool = False, language: Optional[str] = None) -> Dict[str, Any]:
+        """
+        List the symbols declared in a Go, TypeScript or JavaScript file, or in
+        every such file of a directory.
+        
+        Args:
+            path: File or directory, absolute or relative to the project root
+            include: For a directory, only its files matching one of these globs (see PathGlobs)
+            exclude: For a directory, not its files matching one of these globs
+            include_nested: Also list Go declarations below the top level (see
+                GoFileParser._nested_decls): named function literals, types and
+                constants of function bodies, anonymous structs; and Java local
+                and anonymous classes
+            kinds: Only these kinds, as search_symbols takes them (func, method, type, field...)
+            exported_only: Only exported names
+            top_level_only: Only declarations no other one contains: no fields,
+                interface method specs, class members or nested declarations
+            language: For a file, parse it as this language ("go", "typescript"...)
+                whatever its extension; a .gotmpl file read as Go, say
+            
+        Returns:
+            Symbol records with name, type, signature, location and, for generic
+            declarations, the type parameters with their constraints. Struct
+            fields are included as "field" records with their parsed tags;
+            embedded fields are flagged and linked to the embedded type, and
+            aliases carry the type their alias chain resolves to. TS/JS class and
+            interface members carry their owner in "container", as do Java
+            members, which also carry their annotations. .proto
+            definitions carry the generated Go declaration they map to in "go".
+            Files with syntax errors or merge conflicts list them under
+            "parseErrors"; the symbols they touch are flagged "approximate",
+            and those of a Go declaration left unclosed "partial" as well.
+            Go test functions carry their "testKind"; without include_tests
+            the test files of a directory are skipped. Declarations of
+            platform-specific files carry their "buildConstraints" and the
+            other platforms' declarations of the same name as "variants".
+        """
+        keep = symbol_filter(kinds, exported_only, top_level_only)
+        target = self._resolve_path(path)
+        native = lambda p: self._language_of(p) in NATIVE_LANGUAGES
+        globs = self._path_globs(include, exclude)
+        
+        if target.is_dir():
+            if language:
+                raise ValueError("language applies to a single file, not a directory")
+            files = sorted(p for p in target.iterdir() if p.is_file() and native(p) and self._allowed(p)
+                           and (include_tests or not is_test_file(str(p))) and (not globs or globs.matches(str(p))))
+        elif target.is_file():
+            if language:
+                language = language.lower()
+            elif not native(target):
+                raise UnsupportedLanguage(f"'{target}' is not a Go, TypeScript or JavaScript source file", str(target))
+            files = [target]
+        else:
+            raise FileNotFound(f"Path '{target}' does not exist", str(target))
+        
+        results = []
+        errors = []
+        project = None
+        protos = None
+        for file_path in files:
+            errors.extend({"path": str(file_path), **error} for error in self._parse_errors(file_path, language))
+            parsed = self._parsed_as(file_path, language)
+            # A file parsed as another language than the index's has no place in its projects
+            forced = bool(language) and language != self._language_of(file_path)
+            for symbol in parsed["symbols"] + (parsed.get("nested", []) if include_nested else []):
+                if not keep(symbol):
+                    continue
+                record = {"language": "go", **symbol, "path": str(file_path)}
+                if forced:
+                    results.append(record)
+                    continue
+                if record["language"] == "proto":
+                    protos = protos or self._proto_project()
+                    link = protos.go_link(str(file_path), symbol)
+                    if link:
+                        record["go"] = link
+                if symbol.get("alias"):
+                    project = project or self._go_project()
+                    chain = project.alias_chain((str(file_path.parent), symbol["name"]))
+                    record["resolved_type"] = chain["target"]
+                    if len(chain["chain"]) > 1:
+                        record["alias_chain"] = chain["chain"]
+                    if chain["target_key"] is None and "." in chain["target"]:
+                        last = project.types[chain["last_alias"]]
+                        qualifier = chain["target"].lstrip("*").split(".", 1)[0]
+                        for imp in project.files[last["path"]]["imports"]:
+                            if imp["name"] == qualifier:
+                                record["resolved_import"] = imp["path"]
+                if symbol.get("buildConstraints") and record["language"] == "go":
+                    project = project or self._go_project()
+                    variants = project.variants(str(file_path), symbol)
+                    if variants:
+                        record["variants"] = variants
+                if symbol.get("embedded"):
+                    project = project or self._go_project()
+                    key = project.embedded_type(record)
+                    if key is not None:
+                        embedded = project.types[key]
+                        record["embedded_symbol"] = {
+                            "name": embedded["name"],
+                            "type": embedded["type"],
+                            "path": embedded["path"],
+                            "start_line": embedded["start_line"],
+                        }
+                results.append(record)
+        
+        self._save_cache()
+        result: Dict[str, Any] = {"symbols": results}
+        if errors:
+            result["parseErrors"] = errors
+        if globs and target.is_dir():
+            result["path_filter"] = globs.describe()
+        if language:
+            result["language"] = language
+        return result
+    
+    def _parse_errors(self, file_path: Path, language: Optional[str] = None) -> List[Dict[str, Any]]:
+        """The syntax errors and merge conflicts recorded for a file when it was parsed."""
+        parsed = self._parsed_as(file_path, language)
+        if parsed.get("parseError"):
+            # Python's ast stops at the first error
+            return [{"kind": "syntax", **parsed["parseError"]}]
+        return parsed.get("parseErrors", [])
+    
+    def search_symbols(
+        self,
+        query: str,
+        mode: str = "substring",
+        case_sensitive: bool = False,
+        kinds: Optional[List[str]] = None,
+        package: Optional[str] = None,
+        exported_only: bool = False,
+        language: Optional[str] = None,
+        include_tests: bool = True,
+        include: Optional[List[str]] = None,
+        exclude: Optional[List[str]] = None
+    ) -> List[Dict[str, Any]]:
+        """
+        Search Go, TypeScript/JavaScript, Python, Rust, Java, .proto and .sql declarations by name (see core/go_search.py).
+        
+        Args:
+            query: Substring, regular expression or fuzzy pattern
+            mode: One of substring, regex, fuzzy
+            case_sensitive: Match case exactly (substring and regex modes)
+            kinds: Only these kinds (func, method, type, interface, struct, class, enum, record, annotation, const, var,
+                field, namespace, module, trait, macro, message, service, rpc, table, column, index)
+            package: Only packages at or under this directory, relative to the project root
+            exported_only: Only exported names
+            language: Only symbols of this language (go, typescript, javascript, python, rust, java, proto, sql)
+            include_tests: Also search test files (_test.go, test_*.py, *.test.ts...)
+            include: Only symbols of files matching one of these globs (see PathGlobs)
+            exclude: No symbols of files matching one of these globs
+            
+        Returns:
+            Matches ranked by score, then name
+        """
+        project = self._go_project()
+        matches = search_symbols(project, query, mode, case_sensitive, kinds, package, exported_only,
+                                 self._modules, language, self._python, self._rust, self._protos, include_tests,
+                                 self._java, self._sql, self._tasks)
+        globs = self._path_globs(include, exclude)
+        return [m for m in matches if globs.matches(m["path"])] if globs else matches
+    
+    def search_by_signature(self, params: Optional[List[str]] = None, returns: Optional[List[str]] = None,
+                            receiver: Optional[str] = None, kind: Optional[str] = None,
+                            variadic: Optional[bool] = None, min_params: Optional[int] = None,
+                            max_params: Optional[int] = None, exact_params: bool = False,
+                            exact_returns: bool = False, include_tests: bool = True,
+                            include: Optional[List[str]] = None,
+                            exclude: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        Search Go functions and methods by the types they take and return
+        (see core/go_signatures.py for the type patterns).
+        
+        Args:
+            params: Type patterns each matching a different parameter, in any order
+            returns: Type patterns each matching a different result, in any order
+            receiver: Type pattern of the method receiver
+            kind: "func", "method", or None for both
+            variadic: Only variadic (True) or non-variadic (False) ones
+            min_params: At least this many parameters
+            max_params: At most this many parameters
+            exact_params: No parameters besides those params matched
+            exact_returns: No results besides those returns matched
+            include_tests: Also functions of _test.go files
+            include: Only the files matching one of these globs (see PathGlobs)
+            exclude: Not the files matching one of these globs
+            
+        Returns:
+            Dictionary with the matching functions and methods by package,
+            file and line
+        """
+        project = self._go_project()
+        if self._signatures is None or self._signatures[0] != self._generation:
+            # Normalized once per index generation, queried many times
+            self._signatures = (self._generation, SignatureIndex(project))
+        matches = self._signatures[1].search(params, returns, receiver, kind, variadic, min_params, max_params,
+                                             exact_params, exact_returns, include_tests)
+        globs = self._path_globs(include, exclude)
+        if globs:
+            matches = [m for m in matches if globs.matches(m["path"])]
+        result = {"matches": matches, "total_count": len(matches)}
+        if globs:
+            result["path_filter"] = globs.describe()
+        return result
+    
+    def _symbol_entries(self, include_tests: bool = True) -> List[Dict[str, Any]]:
+        """Every declaration of the indexed files as an "Exact Symbol" object, fields and properties left out."""
+        all_symbols = []
+        project = None
+        
+        for file_path in self._iter_source_files(NATIVE_LANGUAGES):
+            if not include_tests and is_test_file(str(file_path)):
+                continue
+            try:
+                parsed = self._refresh_file(file_path)[0]
+            except Exception:
+                continue
+            for symbol in parsed["symbols"] + parsed.get("nested", []):
+                if symbol["type"] in ("field", "property"):
+                    continue
+                entry = {
+                    "name": symbol["name"],
+                    "type": symbol["type"],
+                    "language": symbol.get("language", "go"),
+                    "path": str(file_path),
+                    "start_line": symbol["start_line"],
+                    "end_line": symbol["end_line"],
+                    "signature": symbol["signature"]
+                }
+                for key in ("doc", "deprecated", "directives", "testKind", "example_of", "table", "approximate",
+                            "partial", "buildConstraints"):
+                    if symbol.get(key):
+                        entry[key] = symbol[key]
+                if symbol.get("receiver"):
+                    entry["receiver"] = symbol["receiver"]
+                if symbol.get("nested"):
+                    entry["nested"] = True
+                    entry["parent"] = symbol.get("parent")
+                if entry["language"] != "go":
+                    for key in ("container", "exported", "decorators", "annotations"):
+                        if key in symbol:
+                            entry[key] = symbol[key]
+                if symbol.get("typeParams"):
+                    entry["typeParams"] = symbol["typeParams"]
+                if symbol.get("buildConstraints") and entry["language"] == "go":
+                    project = project or self._go_project()
+                    variants = project.variants(str(file_path), symbol)
+                    if variants:
+                        entry["variants"] = variants
+                if symbol["type"] == "constant":
+                    for key in ("value", "const_type", "group"):
+                        if key in symbol:
+                            entry[key] = symbol[key]
+                elif symbol["type"] == "variable":
+                    for key in ("value", "value_index", "var_type", "group"):
+                        if key in symbol:
+                            entry[key] = symbol[key]
+                elif symbol.get("group"):
+                    entry["group"] = symbol["group"]
+                all_symbols.append(entry)
+        
+        # Deduplicate symbols (same name and location)
+        seen = set()
+        unique_symbols = []
+        for symbol in all_symbols:
+            key = (symbol["name"], symbol["path"], symbol["start_line"])
+            if key not in seen:
+                seen.add(key)
+                unique_symbols.append(symbol)
+        return unique_symbols
+    
+    def find_symbol(self, query: str, limit: Optional[int] = 10, include_tests: bool = True) -> List[Dict[str, Any]]:
+        """
+        Find symbols matching the query using fuzzy search.
+        Symbols come from the Go, TypeScript/JavaScript, Python, Rust, Java, .proto and SQL parsers
+        behind the index and are fuzzy matched against the query.
+        
+        Returns a list of the top matching "Exact Symbol" objects, best first
+        (ties by path and line, so the order is stable); limit=None ranks them all.
+        Without include_tests, symbols of test files are left out.
+        """
+        unique_symbols = self._symbol_entries(include_tests)
+        
+        # Now perform fuzzy matching against the query
+        scored_symbols = []
+        for symbol in unique_symbols:
+            # Calculate similarity score
+            # Case-folded, so "STRASSE" finds "straße"
+            score = fuzz.partial_ratio(query.casefold(), symbol["name"].casefold())
+            
+            # Boost score for exact substring matches
+            if query.casefold() in symbol["name"].casefold():
+                score = max(score, 80)
+            
+            scored_symbols.append((score, symbol))
+        
+        # Sort by score and take top results
+        scored_symbols.sort(key=lambda x: (-x[0], x[1]["path"], x[1]["start_line"], x[1]["name"]))
+        top_symbols = [s[1] for s in scored_symbols[:limit]]
+        
+        return top_symbols
+    
+    def locate_change(self, description: Optional[str] = None, keywords: Optional[List[str]] = None,
+                      limit: int = 20, weights: Optional[Dict[str, Any]] = None,
+                      since: Optional[str] = "6 months ago", max_commits: Optional[int] = 500,
+                      include_tests: bool = False, include: Optional[List[str]] = None,
+                      exclude: Optional[List[str]] = None) -> Dict[str, Any]:
+        """
+        Rank the symbols and files a described change most likely touches
+        (see core/locate.py): name match, term frequency in identifiers and
+        comments, churn over the history window and call-graph proximity to
+        the best matches, each with its evidence.
+        
+        Without git history the churn signal is left out, and without Go
+        files the graph signal; "unavailable" says which.
+        
+        Args:
+            description: The change in words ("user email validation")
+            keywords: Identifiers or words to match as well
+            limit: Symbols and files returned
+            weights: Weight of each signal (name, text, churn, graph), overriding the defaults
+            since: History window of the churn signal (any git date)
+            max_commits: Most recent commits the churn signal reads
+            include_tests: Also rank test files and their symbols
+            include: Only files matching one of these globs (see PathGlobs)
+            exclude: No files matching one of these globs
+        """
+        terms = terms_of(description, keywords)
+        if not terms:
+            raise ValueError("Nothing to look for: give a description or keywords with at least one word "
+                             "that is not a stop word")
+        weights = check_weights(weights)
+        globs = self._path_globs(include, exclude)
+        
+        def wanted(path: str) -> bool:
+            return (include_tests or not is_test_file(path)) and (not globs or globs.matches(path))
+        
+        symbols = [s for s in self._symbol_entries(include_tests) if wanted(s["path"])]
+        entries = [(path, entry) for index in self._parse_indexes() for path, entry in index.items() if wanted(path)]
+        counts = {}
+        for done, (path, entry) in enumerate(sorted(entries, key=lambda e: e[0]), 1):
+            self._report("terms", done, len(entries), path)
+            cached = self._terms.get(path)
+            if cached is None or cached[0] != entry.get("hash"):
+                try:
+                    cached = (entry.get("hash"), term_counts(read_text(path)))
+                except (OSError, UnicodeDecodeError):
+                    continue
+                self._terms[path] = cached
+            counts[path] = cached[1]
+        
+        unavailable = {}
+        churn = owners = None
+        repos: List[GitRepo] = []
+        try:
+            repo = self._git(self.root_path)
+            repos.append(repo)
+            churn = {}
+            for done, commit in enumerate(repo.log_numstat(since, max_commits, False, [repo.scope],
+                                                           mailmap=True), 1):
+                self._report("history", done, None, commit["commit"][:12])
+                for change in commit["files"]:
+                    stats = churn.setdefault(repo.abspath(change["path"]), {"commits": 0, "authors": {}})
+                    stats["commits"] += 1
+                    stats["authors"][commit["author"]] = stats["authors"].get(commit["author"], 0) + 1
+            codeowners = CodeOwners.find(repo.abspath("."))
+            if codeowners is not None:
+                def owners(path: str) -> Optional[List[str]]:
+                    rule = codeowners.rule_for(repo.relpath(path))
+                    return rule.owners if rule else None
+        except GitError as e:
+            churn = None
+            unavailable["churn"] = str(e)
+        edges = None
+        if any(s.get("language", "go") == "go" for s in symbols):
+            graph = self._call_graph()
+            edges = [(e["caller"], e["callee"]) for e in graph.edges if not e["external"]]
+        else:
+            unavailable["graph"] = "No Go functions to walk the call graph of"
+        
+        def read(path: str) -> Optional[str]:
+            try:
+                return read_text(path)
+            except (OSError, UnicodeDecodeError):
+                return None
+        
+        result = ChangeLocator(terms, weights, symbols, counts, read, churn, edges, owners).rank(max(1, limit))
+        result = {"terms": terms, "weights": weights, **result,
+                  "window": {"since": since, "max_commits": max_commits}}
+        if unavailable:
+            result["unavailable"] = unavailable
+        if globs:
+            result["path_filter"] = globs.describe()
+        return self._with_history(result, repos) if repos else result
+    
+    def what_breaks(self, exact_symbol: Dict[str, Any], include_aliases: bool = False,
+                    include_tests: bool = True, build_context: Optional[Dict[str, Any]] = None,
+                    include: Optional[List[str]] = None, exclude: Optional[List[str]] = None,
+                    snippet_lines: Optional[int] = None) -> Dict[str, Any]:
+        """
+        Find what uses a symbol (reverse dependencies).
+        Simplified to use basic text search for speed and simplicity.
+        
+        With include_aliases, usages of Go type aliases that resolve to the
+        symbol (`type Account = User`, including alias chains) are searched
+        too and tagged with the alias they go through. Without include_tests,
+        references in test files are left out; with a build_context
+        ({"goos", "goarch", "tags"}), references in Go files that build
+        would not compile. include and exclude globs (see PathGlobs) keep
+        only the references in the files they select.
+        
+        For a .proto definition the Go names protoc-gen-go gives it are searched
+        as well (tagged "via_go"), and the result links the generated Go
+        declaration; services and rpcs list the Go methods implementing them.
+        
+        A symbol carrying a symbol_id is looked up by it, so a symbol object
+        kept from an earlier call names the declaration as it is now. With
+        snippet_lines, each reference inlines its line and that many lines
+        of context either side, the name it matched marked by its columns.
+        
+        Returns a dictionary with references and a standard caveat.
+        """
+        self._check_snippet_lines(snippet_lines)
+        if exact_symbol.get('symbol_id'):
+            # Given by ID: where the declaration is now, under its current name
+            qualified, path = self._symbol_arg(exact_symbol['symbol_id'])
+            exact_symbol = {**exact_symbol, 'name': qualified.rsplit('.', 1)[-1], 'path': path}
+        symbol_name = exact_symbol['name']
+        references = self._text_references(symbol_name)
+        result = {
+            "references": references,
+            "total_count": len(references),
+            "note": f"Found {len(references)} potential references based on a text search for the name '{symbol_name}'. This may include comments, strings, or other unrelated symbols."
+        }
+        
+        if include_aliases and str(exact_symbol.get('path', '')).endswith('.go'):
+            project = self._go_project()
+            key = project.resolve_alias_key((os.path.dirname(str(self._resolve_path(exact_symbol['path']))), symbol_name))
+            aliases = project.aliases_of(key)
+            for alias in aliases:
+                for ref in self._text_references(alias['name']):
+                    references.append({**ref, "via_alias": alias['name']})
+            result["aliases"] = [
+                {"name": a["name"], "path": a["path"], "start_line": a["start_line"], "signature": a["signature"]}
+                for a in aliases
+            ]
+            result["total_count"] = len(references)
+        
+        if str(exact_symbol.get('path', '')).endswith('.proto'):
+            self._link_proto_references(exact_symbol, result)
+        if str(exact_symbol.get('path', '')).endswith('.go'):
+            self._tag_type_switch_cases(exact_symbol, references)
+            result["total_count"] = len(references)
+        
+        if not include_tests:
+            references[:] = [r for r in references if not is_test_file(r["file"])]
+            result["total_count"] = len(references)
+        if build_context is not None:
+            context = BuildContext.from_dict(build_context)
+            files = self._go_project().files
+            references[:] = [r for r in references if r["file"] not in files or context.includes(files[r["file"]])]
+            result["total_count"] = len(references)
+            result["build_context"] = context.describe()
+        globs = self._path_globs(include, exclude)
+        if globs:
+            references[:] = [r for r in references if globs.matches(r["file"])]
+            result["total_count"] = len(references)
+            result["path_filter"] = globs.describe()
+        
+        # ripgrep reports files in whatever order its threads finish
+        references.sort(key=lambda r: (r["file"], r["line"], r.get("via_alias", ""), r.get("via_go", "")))
+        self._add_snippets(references, snippet_lines, lambda r: (
+            r["file"], r["line"], r.get("column"), r.get("via_alias") or r.get("via_go") or symbol_name))
+        return result
+    
+    @staticmethod
+    def _check_snippet_lines(snippet_lines: Optional[int]):
+        if snippet_lines is not None and not 0 <= snippet_lines <= MAX_SNIPPET_LINES:
+            raise ValueError(f"snippet_lines must be between 0 and {MAX_SNIPPET_LINES}")
+    
+    @staticmethod
+    def _add_snippets(entries: List[Dict[str, Any]], snippet_lines: Optional[int],
+                      locate: Callable[[Dict[str, Any]], Optional[Tuple[str, int, Optional[int], Optional[str]]]]):
+        """
+        Give each entry, in place, a "snippet" of the source around it (see
+        ranges.snippet); locate maps an entry to (path, line, column, name),
+        or None for one without a location. Nothing without snippet_lines.
+        """
+        if snippet_lines is None:
+            return
+        sources = SourceLines()
+        for entry in entries:
+            where = locate(entry)
+            if not where:
+                continue
+            path, line, column, name = where
+            found = snippet(sources.lines(path), line, column, name, snippet_lines)
+            if found is not None:
+                entry["snippet"] = found
+    
+    def _tag_type_switch_cases(self, exact_symbol: Dict[str, Any], references: List[Dict[str, Any]]):
+        """Tag the references of a Go type that are type switch cases, adding any the text search missed."""
+        project = self._go_project()
+        key = (os.path.dirname(str(self._resolve_path(exact_symbol['path']))), exact_symbol['name'])
+        if key not in project.types:
+            return
+        by_line = {(r["file"], r["line"]): r for r in references if "via_alias" not in r}
+        for case in switch_cases(project, key):
+            reference = by_line.get((case["path"], case["line"]))
+            if reference is None:
+                reference = {"file": case["path"], "line": case["line"], "text": f"case {case['type']}:", "language": "go"}
+                references.append(reference)
+            reference.update({"usage": "type_switch_case", "function": case["function"],
+                              "switch": case["expression"]})
+    
+    def _link_proto_references(self, exact_symbol: Dict[str, Any], result: Dict[str, Any]):
+        """Add the Go side of a .proto definition to a what_breaks result."""
+        protos = self._proto_project()
+        path = str(self._resolve_path(exact_symbol['path']))
+        if path not in protos.files:
+            return
+        candidates = [s for s in protos.files[path]["symbols"] if s["name"] == exact_symbol['name']]
+        symbol = next((s for s in candidates if s["start_line"] == exact_symbol.get('start_line')),
+                      candidates[0] if candidates else None)
+        if symbol is None:
+            return
+        for go_name in protos.go_identifiers(symbol):
+            if go_name == symbol["name"]:
+                continue
+            for ref in self._text_references(go_name):
+                if ref["language"] == "go":
+                    result["references"].append({**ref, "via_go": go_name})
+        result["total_count"] = len(result["references"])
+        result["go"] = protos.go_link(path, symbol)
+        if symbol["type"] in ("service", "rpc"):
+            service_name = symbol["name"] if symbol["type"] == "service" else symbol["container"]
+            for service in protos.services(path):
+                if service["name"] != service_name:
+                    continue
+                if symbol["type"] == "service":
+                    result["implementations"] = service["implementations"]
+                else:
+                    rpc = next((m for m in service["methods"] if m["name"] == symbol["name"]), None)
+                    result["implementations"] = rpc["implementations"] if rpc else []
+    
+    @staticmethod
+    def _source_readers() -> Tuple[Callable[[str], Optional[str]], Callable[[str], bool]]:
+        """A file reader (None when unreadable) and a memoized generated-file check, for token scans."""
+        generated: Dict[str, bool] = {}
+        
+        def is_generated(file_path: str) -> bool:
+            if file_path not in generated:
+                generated[file_path] = file_path.endswith(".go") and is_generated_go(Path(file_path))
+            return generated[file_path]
+        
+        def read(file_path: str) -> Optional[str]:
+            try:
+                return read_text(file_path)
+            except OSError:
+                return None
+        
+        return read, is_generated
+    
+    def rename_preview(self, symbol: str, new_name: str, path: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Plan renaming a symbol without doing it: every definition and
+        reference to edit, with the replacement text, plus collisions with
+        the new name and what else could break (see xray.core.go_rename).
+        
+        Go declarations ("Name", "Type.Method", "Type.Field") are resolved
+        through imports and selector types. Symbols of other languages fall
+        back to a whole-word text search, each match an edit to review.
+        
+        Args:
+            symbol: The symbol to rename
+            new_name: Its new name
+            path: Optional file or package directory to disambiguate the name
+        """
+        symbol, path = self._symbol_arg(symbol, path)
+        scope = self._scope(path)
+        graph = self._call_graph()
+        read, is_generated = self._source_readers()
+        planner = RenamePlanner(graph, read, is_generated, self._skipped_generated_go())
+        matches = planner.find(symbol, scope)
+        if matches:
+            variants = {(v["path"], v["line"]) for v in graph.project.variants(matches[0]["path"], matches[0])}
+            result = planner.preview(matches[0], new_name)
+            others = [m for m in matches[1:] if (m["path"], m["start_line"]) not in variants]
+        else:
+            located = [m for m in self._locate_symbol(symbol, path) if m.get("language") != "go"]
+            if not located:
+                raise SymbolNotFound(f"No symbol named '{symbol}' found")
+            result = self._text_rename_preview(located[0], new_name, read)
+            others = located[1:]
+        if others:
+            result["other_candidates"] = [
+                {"name": m["name"], "type": m["type"], "path": m["path"], "line": m["start_line"]} for m in others
+            ]
+        return result
+    
+    def plan_package_move(self, old_path: str, new_path: str, package_name: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Plan moving a Go package without doing it: the import paths to
+        rewrite in every importing file, the package clause and qualifiers
+        if its name changes, the go.mod and go.work edits the move needs,
+        and the strings and build files naming the old path, for review
+        (see xray.core.go_move).
+        
+        Args:
+            old_path: The package directory (relative to the root, or absolute) or its import path
+            new_path: The directory to move it to, or the import path it should get
+            package_name: Optional new package name (default: the new directory's name, if the old
+                name was the old directory's)
+        """
+        graph = self._call_graph()
+        read, is_generated = self._source_readers()
+        build_files = [str(p) for p in self._named_files(is_build_file)]
+        planner = PackageMovePlanner(graph, read, is_generated, self._skipped_generated_go(), build_files)
+        old_dir = planner.resolve(old_path) or str(self._resolve_path(old_path))
+        if not planner.movable(old_dir):
+            raise FileNotFound(f"'{old_path}' is not a Go package of the project", old_path)
+        new_dir = planner.resolve(new_path) or str(self._resolve_path(new_path))
+        return planner.plan(old_dir, new_dir, package_name)
+    
+    def _text_rename_preview(self, target: Dict[str, Any], new_name: str,
+                             read: Callable[[str], Optional[str]]) -> Dict[str, Any]:
+        """A rename plan from whole-word matches of the name, for languages without reference resolution."""
+        name = target["name"]
+        if not re.match(r"^[^\W\d]\w*$", new_name) or new_name == name:
+            raise ValueError(f"'{new_name}' is not a valid new name for {name}")
+        pattern = re.compile(r"(?<!\w)" + re.escape(name) + r"(?!\w)")
+        edits = []
+        for file_path in sorted({r["file"] for r in self._text_references(name)}):
+            content = read(file_path)
+            if content is None:
+                continue
+            for number, line in enumerate(source_lines(content), 1):
+                for match in pattern.finditer(line):
+                    definition = file_path == target["path"] and number == target["start_line"]
+                    edits.append({"path": file_path, "line": number, "column": match.start() + 1,
+                                  "kind": "definition" if definition else "text_match",
+                                  "old_text": name, "new_text": new_name})
+        collisions = [
+            {"kind": "file", "message": f"{new_name} is already declared in this file",
+             "path": target["path"], "line": sym["start_line"], "type": sym["type"]}
+            for sym in self._get_symbols(Path(target["path"])) if sym["name"] == new_name
+        ]
+        return {
+            "symbol": {"name": name, "qualified_name": target.get("qualified_name", name), "type": target["type"],
+                       "path": target["path"], "line": target["start_line"], "language": target.get("language")},
+            "new_name": new_name,
+            "edits": edits,
+            "collisions": collisions,
+            "risks": [],
+            "unresolved": [],
+            "counts": {"edits": len(edits), "files": len({e["path"] for e in edits}),
+                       "collisions": len(collisions), "risks": 0, "unresolved": 0},
+            "note": f"References to {name} in {target.get('language')} code are not resolved: every whole-word "
+                    f"match, in comments and strings too, is listed as an edit to review.",
+        }
+    
+    def _text_references(self, symbol_name: str) -> List[Dict[str, Any]]:
+        """Whole-word search for a name across the project's source files."""
+        references = []
+        
+        # Use simple grep-like search for the symbol name
+        # Check if ripgrep is available, otherwise fall back to Python
+        try:
             # Try using ripgrep if available
             cmd = [
                 "rg",
@@ -561,7 +6931,7 @@ class XRayIndexer:
                 str(self.root_path)
             ]
             
-            result = subprocess.run(cmd, capture_output=True, text=True)
+            result = run_cancellable(cmd, cancel=self._cancel)
             
             if result.returncode == 0:
                 # Parse ripgrep JSON output
@@ -571,10 +6941,12 @@ class XRayIndexer:
                             data = json.loads(line)
                             if data.get("type") == "match":
                                 match_data = data.get("data", {})
+                                file_name = match_data.get("path", {}).get("text", "")
                                 references.append({
-                                    "file": match_data.get("path", {}).get("text", ""),
+                                    "file": file_name,
                                     "line": match_data.get("line_number", 0),
-                                    "text": match_data.get("lines", {}).get("text", "").strip()
+                                    "text": match_data.get("lines", {}).get("text", "").strip(),
+                                    "language": self._language_of(file_name)
                                 })
                         except json.JSONDecodeError:
                             continue
@@ -585,41 +6957,28 @@ class XRayIndexer:
             # Ripgrep not installed, use Python fallback
             references = self._python_text_search(symbol_name)
         
-        return {
-            "references": references,
-            "total_count": len(references),
-            "note": f"Found {len(references)} potential references based on a text search for the name '{symbol_name}'. This may include comments, strings, or other unrelated symbols."
-        }
+        return references
     
     def _python_text_search(self, symbol_name: str) -> List[Dict[str, Any]]:
         """Fallback text search using Python when ripgrep is not available."""
         references = []
-        gitignore_patterns = self._parse_gitignore()
         
         # Create word boundary pattern
         pattern = re.compile(r'\b' + re.escape(symbol_name) + r'\b')
         
-        for file_path in self.root_path.rglob('*'):
-            if not file_path.is_file():
-                continue
-            
-            # Skip excluded files
-            if self._should_exclude(file_path, gitignore_patterns):
-                continue
-            
-            # Only search in source files
-            if file_path.suffix.lower() not in LANGUAGE_MAP:
-                continue
+        # The indexed source files: excluded directories pruned, symlinks not followed
+        for searched, file_path in enumerate(self._iter_source_files(), 1):
+            self._report("searching", searched)
             
             try:
-                with open(file_path, 'r', encoding='utf-8') as f:
-                    for line_num, line in enumerate(f, 1):
-                        if pattern.search(line):
-                            references.append({
-                                "file": str(file_path),
-                                "line": line_num,
-                                "text": line.strip()
-                            })
+                for line_num, line in enumerate(source_lines(read_text(str(file_path))), 1):
+                    if pattern.search(line):
+                        references.append({
+                            "file": str(file_path),
+                            "line": line_num,
+                            "text": line.strip(),
+                            "language": self._language_of(file_path)
+                        })
             except Exception:
                 continue
         
@@ -0,0 +1,438 @@
+"""Cross-file view of the Java sources of a project: packages, inheritance and Spring routes.
+
+Type names in extends and implements clauses resolve the way javac
+resolves them: the member types of the enclosing classes first, then the
+types of the same file, single-type imports, the file's package, a fully
+qualified name and finally on-demand (`.*`) imports. A name none of them
+finds - java.lang, or a library - stays external, qualified by its import
+where there is one.
+
+Inheritance is followed transitively: a class implements the interfaces
+its superclasses and superinterfaces do, and find_implementations lists
+the classes (anonymous and local ones included) reaching an interface that
+way with the type they inherit it "via".
+
+Spring MVC controllers - classes annotated @RestController or @Controller,
+or carrying a class-level @RequestMapping - give routes for their methods'
+@GetMapping, @PostMapping, @PutMapping, @DeleteMapping, @PatchMapping and
+@RequestMapping annotations, the class-level path in front.
+"""
+
+import os
+import re
+from typing import Any, Dict, Iterator, List, Optional, Tuple
+
+from xray.core.java_parser import type_base
+
+# Symbol kinds find_implementations and type_hierarchy can start from
+TYPE_KINDS = ("class", "interface", "enum", "record", "annotation")
+
+# Spring request mapping annotations -> the HTTP method they fix (None: given by method =)
+SPRING_MAPPINGS = {
+    "GetMapping": "GET",
+    "PostMapping": "POST",
+    "PutMapping": "PUT",
+    "DeleteMapping": "DELETE",
+    "PatchMapping": "PATCH",
+    "RequestMapping": None,
+}
+SPRING_CONTROLLERS = ("RestController", "Controller")
+
+# An annotation argument naming a constant (Paths.USERS) rather than spelling the path
+_CONSTANT = re.compile(r"^[A-Za-z_$][\w$]*(?:\.[A-Za-z_$][\w$]*)+$")
+
+
+def _short(annotation: Dict[str, Any]) -> str:
+    """An annotation's simple name, as written qualified or not."""
+    return annotation["name"].rsplit(".", 1)[-1]
+
+
+def _paths(annotation: Optional[Dict[str, Any]]) -> List[str]:
+    """The value (or path) of a mapping annotation as a list; [""] when it gives none."""
+    arguments = (annotation or {}).get("arguments", {})
+    value = arguments.get("value", arguments.get("path"))
+    if value is None:
+        return [""]
+    if isinstance(value, list):
+        return value or [""]
+    return [value]
+
+
+def _join(prefix: str, path: str) -> str:
+    """Spring's combination of a class-level and a method-level path."""
+    joined = "/".join(part.strip("/") for part in (prefix, path) if part.strip("/"))
+    return "/" + joined
+
+
+class JavaProject:
+    """The parsed Java files of a project, their types and the inheritance between them."""
+
+    def __init__(self, files: Dict[str, Dict[str, Any]], root: str):
+        self.files = files
+        self.root = root
+        self._resolved: Dict[Tuple[str, str, str], Optional[Dict[str, Any]]] = {}
+        self._index_types()
+
+    def symbols(self) -> Iterator[Tuple[str, Dict[str, Any]]]:
+        """(path, symbol) for every declaration, nested ones included, files in sorted order."""
+        for path in sorted(self.files):
+            parsed = self.files[path]
+            for symbol in parsed["symbols"] + parsed.get("nested", []):
+                yield path, symbol
+
+    def dotted_name(self, path: str) -> str:
+        """A file's package ("com.example.users"), or its class name in the default package."""
+        return self.files[path].get("package") or os.path.splitext(os.path.basename(path))[0]
+
+    # ------------------------------------------------------------------
+    # Types and name resolution
+    # ------------------------------------------------------------------
+
+    def _index_types(self):
+        self._type_records: List[Dict[str, Any]] = []
+        # (directory, name in the file) -> record, the key type_hierarchy_diagram looks types up by
+        self.types: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        # "com.example.Outer.Inner" -> record (local and anonymous classes have no such name)
+        self.by_qualified: Dict[str, Dict[str, Any]] = {}
+        # (file, name in the file) -> record
+        self._in_file: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        for path, symbol in self.symbols():
+            if symbol["type"] not in TYPE_KINDS:
+                continue
+            package = self.files[path].get("package", "")
+            local = bool(symbol.get("nested"))
+            name = symbol["name"] if local or not symbol.get("container") else f"{symbol['container']}.{symbol['name']}"
+            record = {
+                "name": name,
+                "type": symbol["type"],
+                "package": package,
+                "path": path,
+                "start_line": symbol["start_line"],
+                "language": "java",
+                "qualified": ".".join(filter(None, [package, name])),
+                "symbol": symbol,
+            }
+            self._type_records.append(record)
+            self.types.setdefault((os.path.dirname(path), name), record)
+            self._in_file.setdefault((path, name), record)
+            if not local:
+                self.by_qualified.setdefault(record["qualified"], record)
+        # type -> [(relation, written, resolved record or None)], and the reverse
+        self._supers: Dict[int, List[Tuple[str, str, Optional[Dict[str, Any]]]]] = {}
+        self._subs: Dict[int, List[Tuple[str, Dict[str, Any]]]] = {}
+        for record in self._type_records:
+            symbol = record["symbol"]
+            scope = symbol.get("container") or symbol.get("parent") or ""
+            supers = []
+            written = [("extends", w) for w in symbol.get("extends", [])] + \
+                      [("implements", w) for w in symbol.get("implements", [])]
+            if symbol.get("anonymous"):
+                written.append(("extends", symbol["supertype"]))
+            for relation, text in written:
+                target = self.resolve(record["path"], text, scope)
+                if symbol.get("anonymous") and target is not None and target["type"] == "interface":
+                    relation = "implements"
+                supers.append((relation, text, target))
+                if target is not None and target is not record:
+                    self._subs.setdefault(id(target), []).append((relation, record))
+            self._supers[id(record)] = supers
+
+    def resolve(self, path: str, written: str, scope: str = "") -> Optional[Dict[str, Any]]:
+        """
+        The project type a type name written in path means, looked up from
+        scope (the dotted name of the enclosing class or member) outwards;
+        None for a type outside the project.
+        """
+        name = type_base(written)
+        key = (path, name, scope)
+        if key in self._resolved:
+            return self._resolved[key]
+        self._resolved[key] = result = self._lookup(path, name, scope) if name else None
+        return result
+
+    def _lookup(self, path: str, name: str, scope: str) -> Optional[Dict[str, Any]]:
+        parts = scope.split(".") if scope else []
+        for i in range(len(parts), 0, -1):
+            found = self._in_file.get((path, ".".join(parts[:i] + [name])))
+            if found is not None:
+                return found
+        found = self._in_file.get((path, name))
+        if found is not None:
+            return found
+        parsed = self.files.get(path, {})
+        first, _, rest = name.partition(".")
+        for imp in parsed.get("imports", []):
+            if not imp["wildcard"] and imp["name"] == first:
+                target = self.by_qualified.get(".".join(filter(None, [imp["path"], rest])))
+                if target is not None or not imp["static"]:
+                    return target
+        package = parsed.get("package", "")
+        found = self.by_qualified.get(".".join(filter(None, [package, name])))
+        if found is not None:
+            return found
+        found = self.by_qualified.get(name)
+        if found is not None:
+            return found
+        for imp in parsed.get("imports", []):
+            if imp["wildcard"]:
+                found = self.by_qualified.get(f"{imp['path']}.{name}")
+                if found is not None:
+                    return found
+        return None
+
+    def external_name(self, path: str, written: str) -> str:
+        """The qualified name of a type outside the project where the file imports it, else as written."""
+        name = type_base(written)
+        first, _, rest = name.partition(".")
+        for imp in self.files.get(path, {}).get("imports", []):
+            if not imp["wildcard"] and imp["name"] == first:
+                return ".".join(filter(None, [imp["path"], rest]))
+        return name
+
+    @staticmethod
+    def _public(record: Dict[str, Any]) -> Dict[str, Any]:
+        entry = {key: record[key] for key in ("name", "type", "package", "path", "start_line", "language")}
+        if record["symbol"].get("anonymous"):
+            entry["anonymous"] = True
+            entry["parent"] = record["symbol"]["parent"]
+        elif record["symbol"].get("nested"):
+            entry["parent"] = record["symbol"]["parent"]
+        return entry
+
+    def _external(self, record: Dict[str, Any], written: str) -> Dict[str, Any]:
+        qualified = self.external_name(record["path"], written)
+        package, _, name = qualified.rpartition(".")
+        return {"name": name, "package": package, "path": record["path"], "start_line": record["start_line"],
+                "language": "java", "external": True}
+
+    def find_types(self, name: str, path: Optional[str] = None) -> List[Dict[str, Any]]:
+        """
+        Classes, interfaces, enums, records and annotation types named `name`
+        ("Inner", or "Outer.Inner"), optionally restricted to one file or directory.
+        """
+        return [self._public(r) for r in self._type_records
+                if (r["name"] == name or r["name"].endswith("." + name)) and
+                (not path or r["path"] == path or os.path.dirname(r["path"]) == path.rstrip(os.sep))]
+
+    def _record(self, entry: Dict[str, Any]) -> Dict[str, Any]:
+        return next(r for r in self._type_records if r["path"] == entry["path"] and
+                    r["start_line"] == entry["start_line"] and r["name"] == entry["name"])
+
+    def _interface_methods(self, record: Dict[str, Any]) -> List[str]:
+        """The methods of an interface and of the interfaces it extends."""
+        methods = set()
+        queue, seen = [record], set()
+        while queue:
+            current = queue.pop(0)
+            if id(current) in seen:
+                continue
+            seen.add(id(current))
+            methods.update(current["symbol"].get("methods", []))
+            queue.extend(target for _, _, target in self._supers[id(current)] if target is not None)
+        return sorted(methods)
+
+    def implementations_of(self, interface_entry: Dict[str, Any]) -> Dict[str, Any]:
+        """
+        The classes, enums and records implementing an interface, directly or
+        through a supertype ("via"), in find_implementations' interface shape;
+        interfaces extending it are listed as "extended_by". Java names the
+        interfaces it implements, so there are never partial matches.
+        """
+        interface = self._record(interface_entry)
+        wanted = self._interface_methods(interface)
+        complete, extended = [], []
+        queue = [interface]
+        seen = {id(interface)}
+        while queue:
+            current = queue.pop(0)
+            via = None if current is interface else current["name"]
+            for _, sub in self._subs.get(id(current), []):
+                if id(sub) in seen:
+                    continue
+                seen.add(id(sub))
+                queue.append(sub)
+                entry = self._public(sub)
+                if via:
+                    entry["via"] = via
+                if sub["type"] in ("interface", "annotation"):
+                    extended.append(entry)
+                    continue
+                entry.pop("type")
+                entry.update({"satisfied_by": sub["name"],
+                              "methods": sorted(set(wanted) & set(sub["symbol"].get("methods", [])))})
+                if "abstract" in sub["symbol"].get("modifiers", []):
+                    entry["abstract"] = True
+                complete.append(entry)
+
+        result = {
+            "interface": {**self._public(interface), "methods": wanted},
+            "implementations": complete,
+            "partial": [],
+            "total_count": len(complete),
+        }
+        if extended:
+            result["extended_by"] = extended
+        return result
+
+    def interfaces_of(self, type_entry: Dict[str, Any]) -> Dict[str, Any]:
+        """
+        The interfaces a type implements, directly or through a supertype
+        ("via"), and its superclasses nearest first, in find_implementations'
+        type shape.
+        """
+        record = self._record(type_entry)
+        complete, superclasses = [], []
+        queue = [record]
+        seen = {id(record)}
+        while queue:
+            current = queue.pop(0)
+            via = None if current is record else current["name"]
+            for relation, written, target in self._supers[id(current)]:
+                if target is None:
+                    entry = self._external(current, written)
+                    interface = relation == "implements" or current["type"] == "interface"
+                else:
+                    if id(target) in seen:
+                        continue
+                    seen.add(id(target))
+                    queue.append(target)
+                    entry = self._public(target)
+                    interface = entry.pop("type") in ("interface", "annotation")
+                if via:
+                    entry["via"] = via
+                if interface:
+                    complete.append({**entry, "satisfied_by": record["name"]})
+                else:
+                    superclasses.append(entry)
+
+        result = {
+            "type": {**self._public(record), "methods": record["symbol"].get("methods", [])},
+            "interfaces": complete,
+            "partial": [],
+            "total_count": len(complete),
+        }
+        if superclasses:
+            result["superclasses"] = superclasses
+        return result
+
+    def embeds_of(self, key: Tuple[str, str]) -> List[Tuple[str, Optional[Tuple[str, str]]]]:
+        """The interfaces an interface extends, as written and resolved to a types key (None outside the project)."""
+        record = self.types.get(key)
+        if record is None or record["type"] != "interface":
+            return []
+        return [(written, (os.path.dirname(target["path"]), target["name"]) if target else None)
+                for _, written, target in self._supers[id(record)]]
+
+    def hierarchy(self, type_entry: Dict[str, Any], depth: int = 2) -> Dict[str, Any]:
+        """
+        The supertypes and subtypes around a type, depth levels each way, in
+        type_hierarchy's graph shape: edges point from the subtype to the
+        type it "extends" or "implements".
+        """
+        root = self._record(type_entry)
+        nodes: Dict[str, Dict[str, Any]] = {}
+        edges: List[Dict[str, Any]] = []
+
+        def node(record: Dict[str, Any]) -> str:
+            node_id = record["qualified"]
+            if node_id not in nodes:
+                nodes[node_id] = {"id": node_id, **{k: v for k, v in self._public(record).items()
+                                                    if k not in ("type", "language")}, "kind": record["type"]}
+            return node_id
+
+        def edge(source: str, target: str, relation: str):
+            if not any((e["from"], e["to"], e["relation"]) == (source, target, relation) for e in edges):
+                edges.append({"from": source, "to": target, "relation": relation})
+
+        root_id = node(root)
+        for upward in (True, False):
+            level, seen = [root], {id(root)}
+            for _ in range(max(depth, 0)):
+                next_level = []
+                for current in level:
+                    if upward:
+                        links = [(relation, target, written) for relation, written, target in self._supers[id(current)]]
+                    else:
+                        links = [(relation, sub, None) for relation, sub in self._subs.get(id(current), [])]
+                    for relation, other, written in links:
+                        if other is None:
+                            external = self.external_name(current["path"], written)
+                            nodes.setdefault(external, {"id": external, "name": external.rsplit(".", 1)[-1],
+                                                        "kind": "external"})
+                            edge(node(current), external, relation)
+                            continue
+                        source, target = (current, other) if upward else (other, current)
+                        edge(node(source), node(target), relation)
+                        if id(other) not in seen:
+                            seen.add(id(other))
+                            next_level.append(other)
+                level = next_level
+        return {
+            "root": root_id,
+            "kind": root["type"],
+            "depth": depth,
+            "nodes": sorted(nodes.values(), key=lambda n: (n["id"] != root_id, n["id"])),
+            "edges": edges,
+        }
+
+    # ------------------------------------------------------------------
+    # Spring routes
+    # ------------------------------------------------------------------
+
+    def routes(self, scope: Optional[str] = None) -> List[Dict[str, Any]]:
+        """The request mappings of the Spring controllers under scope (a file or directory), in extract_routes' shape."""
+        routes = []
+        for record in self._type_records:
+            path, symbol = record["path"], record["symbol"]
+            if scope and path != scope and not path.startswith(scope.rstrip(os.sep) + os.sep):
+                continue
+            if symbol["type"] != "class" or symbol.get("nested"):
+                continue
+            annotations = {_short(a): a for a in symbol.get("annotations", [])}
+            if not any(name in annotations for name in SPRING_CONTROLLERS + ("RequestMapping",)):
+                continue
+            prefixes = _paths(annotations.get("RequestMapping"))
+            default_methods = self._http_methods(annotations.get("RequestMapping"))
+            for method in self.files[path]["symbols"]:
+                if method.get("container") != record["name"] or method["type"] != "method":
+                    continue
+                for annotation in method.get("annotations", []):
+                    mapping = _short(annotation)
+                    if mapping not in SPRING_MAPPINGS:
+                        continue
+                    http = SPRING_MAPPINGS[mapping]
+                    methods = [http] if http else self._http_methods(annotation) or default_methods
+                    function = f"{record['name']}.{method['name']}"
+                    for prefix in prefixes:
+                        for sub in _paths(annotation):
+                            entry: Dict[str, Any] = {
+                                "method": methods[0] if len(methods) == 1 else methods or None,
+                                "route": _join(prefix, sub),
+                            }
+                            if any(self._dynamic(part) for part in (prefix, sub)):
+                                entry["dynamic"] = True
+                            entry["handler"] = {"name": function, "package": record["package"], "path": path,
+                                                "line": method["start_line"], "signature": method["signature"],
+                                                "language": "java"}
+                            entry["framework"] = "spring"
+                            entry["middleware"] = []
+                            for key in ("consumes", "produces"):
+                                if key in annotation.get("arguments", {}):
+                                    entry[key] = annotation["arguments"][key]
+                            entry["registered_at"] = {"path": path, "line": method["start_line"],
+                                                      "column": method["column"], "function": function}
+                            routes.append(entry)
+        return routes
+
+    @staticmethod
+    def _http_methods(annotation: Optional[Dict[str, Any]]) -> List[str]:
+        """The method = RequestMethod.X (or a list of them) of a @RequestMapping."""
+        value = (annotation or {}).get("arguments", {}).get("method")
+        if value is None:
+            return []
+        return [item.rsplit(".", 1)[-1] for item in (value if isinstance(value, list) else [value])]
+
+    @staticmethod
+    def _dynamic(path: Any) -> bool:
+        """Whether a mapping path is an expression (a constant or a concatenation) rather than a literal."""
+        return not isinstance(path, str) or bool(_CONSTANT.match(path)) or '"' in path
@@ -0,0 +1,820 @@
+"""Java source parser for XRAY - tokenizer and declaration scanner.
+
+Like the other native parsers this is a small lexer plus a parser for the
+declarations that matter to the index: the package clause, imports, and
+classes, interfaces, enums, records and annotation types at any depth of
+nesting, with their methods, constructors, fields and enum constants.
+Every declaration keeps its annotations with their arguments:
+
+    @GetMapping(value = "/users/{id}", produces = {"application/json"})
+        {"name": "GetMapping", "arguments": {"value": "/users/{id}", "produces": ["application/json"]}}
+
+A lone argument is the "value" one, as Java reads it; string literals come
+back unquoted, other values as written. Method bodies, initializers and
+field values are skipped over as balanced token ranges, except for the
+classes declared inside them: anonymous classes (`new Runnable() {...}`,
+named like javac names them, "Worker$1") and local classes come back as
+"nested" records whose "parent" names the enclosing declaration.
+"""
+
+import bisect
+import re
+from typing import Any, Dict, List, Optional, Tuple
+
+# Bump whenever the shape of extracted records changes so cached results are discarded
+JAVA_PARSER_VERSION = 1
+
+# Longest punctuators first. `>>`, `>=` and `<<` are left as single characters
+# so that closing generics (`Map<String, List<Integer>>`) can be counted one by one.
+PUNCTUATORS = [
+    "...", "::", "->", "==", "!=", "<=", "&&", "||", "++", "--", "+=", "-=", "*=", "/=",
+    "%=", "^=", "&=", "|=",
+    "{", "}", "(", ")", "[", "]", ";", ",", ".", "<", ">", "+", "-", "*", "/", "%", "^",
+    "!", "&", "|", "=", "@", "?", ":", "~",
+]
+
+MODIFIERS = {"public", "protected", "private", "static", "final", "abstract", "sealed", "non-sealed",
+             "strictfp", "default", "synchronized", "native", "transient", "volatile"}
+
+# Declaration keywords -> symbol type
+TYPE_KEYWORDS = {"class": "class", "interface": "interface", "enum": "enum", "record": "record"}
+
+# Longest signature text quoted from a declaration head
+MAX_SIGNATURE = 120
+
+_IDENT = re.compile(r"(?:[^\W\d]|\$)(?:\w|\$)*")
+_NUMBER = re.compile(r"0[xXbB][0-9a-fA-F_]*[lL]?|(?:\d[\d_]*(?:\.[\d_]*)?|\.\d[\d_]*)(?:[eE][+-]?\d+)?[fFdDlL]?")
+
+
+class Token:
+    """A single Java token with its source position."""
+
+    __slots__ = ("kind", "value", "line", "col", "end_line", "start", "end")
+
+    def __init__(self, kind, value, start, end):
+        self.kind = kind
+        self.value = value
+        self.start = start
+        self.end = end
+        self.line = self.col = self.end_line = 0
+
+    def __repr__(self):
+        return f"Token({self.kind}, {self.value!r}, {self.line}:{self.col})"
+
+
+def tokenize(src: str) -> Tuple[List[Token], List[Token]]:
+    """
+    Split Java source into tokens.
+
+    Returns:
+        (tokens, doc comments) - plain comments are dropped, `/** */` ones
+        come back as "doc"
+    """
+    tokens: List[Token] = []
+    comments: List[Token] = []
+    i, n = 0, len(src)
+    while i < n:
+        ch = src[i]
+        if ch.isspace():
+            i += 1
+            continue
+        if src.startswith("//", i):
+            end = src.find("\n", i)
+            i = n if end == -1 else end
+            continue
+        if src.startswith("/*", i):
+            end = src.find("*/", i + 2)
+            end = n if end == -1 else end + 2
+            text = src[i:end]
+            if text.startswith("/**") and text != "/**/":
+                comments.append(Token("doc", text, i, end))
+            i = end
+            continue
+        if src.startswith('"""', i):
+            # Text block
+            j = i + 3
+            while j < n and not src.startswith('"""', j):
+                j += 2 if src[j] == "\\" else 1
+            end = min(j + 3, n)
+            tokens.append(Token("string", src[i:end], i, end))
+            i = end
+            continue
+        if ch in "\"'":
+            j = i + 1
+            while j < n and src[j] != ch and src[j] != "\n":
+                j += 2 if src[j] == "\\" else 1
+            end = min(j + 1, n)
+            tokens.append(Token("string" if ch == '"' else "char", src[i:end], i, end))
+            i = end
+            continue
+        if ch.isdigit() or (ch == "." and src[i + 1:i + 2].isdigit()):
+            match = _NUMBER.match(src, i)
+            tokens.append(Token("number", match.group(), i, match.end()))
+            i = match.end()
+            continue
+        match = _IDENT.match(src, i)
+        if match:
+            tokens.append(Token("ident", match.group(), i, match.end()))
+            i = match.end()
+            continue
+        value = next((p for p in PUNCTUATORS if src.startswith(p, i)), ch)
+        tokens.append(Token("punct", value, i, i + len(value)))
+        i += len(value)
+
+    line_starts = [0] + [m.end() for m in re.finditer("\n", src)]
+
+    def locate(offset: int) -> Tuple[int, int]:
+        line = bisect.bisect_right(line_starts, offset)
+        return line, offset - line_starts[line - 1] + 1
+
+    for tok in tokens + comments:
+        tok.line, tok.col = locate(tok.start)
+        tok.end_line = locate(max(tok.end - 1, tok.start))[0]
+    return tokens, comments
+
+
+def unquote(literal: str) -> str:
+    """Return the contents of a string literal or text block without its quotes."""
+    if literal.startswith('"""'):
+        body = literal[3:-3] if literal.endswith('"""') and len(literal) >= 6 else literal[3:]
+        lines = body.split("\n")[1:]
+        indent = min((len(line) - len(line.lstrip()) for line in lines if line.strip()), default=0)
+        return "\n".join(line[indent:] for line in lines)
+    if len(literal) >= 2 and literal[0] == literal[-1] and literal[0] in "\"'":
+        return literal[1:-1]
+    return literal
+
+
+def type_base(written: str) -> str:
+    """
+    The type a written type comes down to, without type arguments and arrays,
+    as dotted as it was written ("Map.Entry<K, V>[]" -> "Map.Entry").
+    """
+    text = re.sub(r"@[\w.]+(?:\([^)]*\))?\s*", "", written)
+    text = text.split("<", 1)[0]
+    return re.sub(r"\s+|\[\]|\.\.\.", "", text)
+
+
+def _clip(text: str) -> str:
+    return text if len(text) <= MAX_SIGNATURE else text[:MAX_SIGNATURE - 3].rstrip() + "..."
+
+
+def _doc_text(comment: Token) -> str:
+    lines = []
+    for line in comment.value[3:-2].splitlines():
+        line = line.strip()
+        if line.startswith("*"):
+            line = line[1:]
+        lines.append(line[1:] if line.startswith(" ") else line)
+    return "\n".join(lines).strip("\n")
+
+
+class JavaFileParser:
+    """Parse a Java file into package, import and symbol records."""
+
+    def __init__(self, content: str, bodies: bool = True):
+        self.src = content
+        # Without bodies, method bodies and initializers are not searched for classes
+        self.bodies = bodies
+        self.tokens, self.comments = tokenize(content)
+        self._comment_starts = [c.start for c in self.comments]
+        self.package = ""
+        self.imports: List[Dict[str, Any]] = []
+        self.symbols: List[Dict[str, Any]] = []
+        self.nested: List[Dict[str, Any]] = []
+        # Anonymous classes declared so far per enclosing class binary name, for their numbers
+        self._anonymous: Dict[str, int] = {}
+        self.pairs: Dict[int, int] = {}
+        stack: List[int] = []
+        closers = {")": "(", "]": "[", "}": "{"}
+        for idx, tok in enumerate(self.tokens):
+            if tok.kind != "punct":
+                continue
+            if tok.value in "([{":
+                stack.append(idx)
+            elif tok.value in closers:
+                # A stray closer only pops the opener it matches
+                if stack and self.tokens[stack[-1]].value == closers[tok.value]:
+                    self.pairs[stack.pop()] = idx
+        for idx in stack:
+            self.pairs[idx] = len(self.tokens) - 1
+
+    # ------------------------------------------------------------------
+    # Token helpers
+    # ------------------------------------------------------------------
+
+    def _v(self, idx: int) -> Optional[str]:
+        return self.tokens[idx].value if 0 <= idx < len(self.tokens) else None
+
+    def _kind(self, idx: int) -> Optional[str]:
+        return self.tokens[idx].kind if 0 <= idx < len(self.tokens) else None
+
+    def _text(self, start: int, end: int) -> str:
+        """Source text of tokens[start:end] with whitespace collapsed."""
+        if start >= end or start >= len(self.tokens):
+            return ""
+        end = min(end, len(self.tokens))
+        return re.sub(r"\s+", " ", self.src[self.tokens[start].start:self.tokens[end - 1].end])
+
+    def _match(self, idx: int) -> int:
+        """Index of the bracket closing the one at idx."""
+        return self.pairs.get(idx, idx)
+
+    def _is_open(self, idx: int) -> bool:
+        return self._kind(idx) == "punct" and self._v(idx) in ("(", "[", "{")
+
+    def _match_angle(self, idx: int) -> int:
+        """Index of the `>` closing the `<` at idx."""
+        depth = 0
+        j = idx
+        while j < len(self.tokens):
+            value = self._v(j)
+            if self._is_open(j):
+                j = self._match(j) + 1
+                continue
+            if value == "<":
+                depth += 1
+            elif value == ">":
+                depth -= 1
+                if depth == 0:
+                    return j
+            elif value in (";", "{", "}"):
+                break
+            j += 1
+        return min(j, len(self.tokens) - 1)
+
+    def _split(self, start: int, end: int) -> List[Tuple[int, int]]:
+        """Ranges of tokens[start:end] between top-level commas, generics counted as brackets."""
+        ranges = []
+        depth = 0
+        first = j = start
+        while j < end:
+            value = self._v(j)
+            if self._is_open(j):
+                j = self._match(j) + 1
+                continue
+            if value == "<":
+                depth += 1
+            elif value == ">" and depth:
+                depth -= 1
+            elif value == "," and depth == 0:
+                if j > first:
+                    ranges.append((first, j))
+                first = j + 1
+            j += 1
+        if end > first:
+            ranges.append((first, end))
+        return ranges
+
+    def _statement_end(self, idx: int, end: int) -> int:
+        """The `;` ending the statement at idx (brackets skipped), or end."""
+        j = idx
+        while j < end:
+            if self._is_open(j):
+                j = self._match(j) + 1
+                continue
+            if self._v(j) in (";", "}"):
+                return j
+            j += 1
+        return end
+
+    def _skip(self, idx: int, end: int) -> int:
+        """Skip an unrecognized member: up to a `;` or past a block."""
+        j = idx
+        while j < end:
+            if self._v(j) == "{":
+                return self._match(j) + 1
+            if self._is_open(j):
+                j = self._match(j) + 1
+                continue
+            if self._v(j) == ";":
+                return j + 1
+            j += 1
+        return end
+
+    def _dotted(self, idx: int) -> Tuple[str, int]:
+        """A dotted name at idx ("org.springframework.GetMapping") and the index past it."""
+        parts = []
+        j = idx
+        while self._kind(j) == "ident":
+            parts.append(self._v(j))
+            if self._v(j + 1) == "." and self._kind(j + 2) == "ident":
+                j += 2
+                continue
+            j += 1
+            break
+        return ".".join(parts), j
+
+    def _type_end(self, idx: int) -> int:
+        """The index past a written type at idx: `java.util.Map<K, V>[]`, `@NonNull String...`."""
+        j = idx
+        while self._v(j) == "@" and self._kind(j + 1) == "ident":
+            _, j = self._dotted(j + 1)
+            if self._v(j) == "(":
+                j = self._match(j) + 1
+        if self._kind(j) != "ident":
+            return j
+        while True:
+            j += 1
+            if self._v(j) == "<":
+                j = self._match_angle(j) + 1
+            if self._v(j) == "." and self._kind(j + 1) == "ident":
+                j += 1
+                continue
+            break
+        while self._v(j) == "[" and self._v(j + 1) == "]":
+            j += 2
+        if self._v(j) == "...":
+            j += 1
+        return j
+
+    # ------------------------------------------------------------------
+    # Annotations, modifiers and docs
+    # ------------------------------------------------------------------
+
+    def _value(self, start: int, end: int) -> Any:
+        """An annotation argument: unquoted for a string literal, a list for an array, else as written."""
+        if end - start == 1 and self._kind(start) == "string":
+            return unquote(self._v(start))
+        if self._v(start) == "{" and self._match(start) == end - 1:
+            return [self._value(a, b) for a, b in self._split(start + 1, end - 1)]
+        return self._text(start, end)
+
+    def _annotation(self, at: int) -> Tuple[Dict[str, Any], int]:
+        """The annotation at the `@` at idx, and the index past it."""
+        name, j = self._dotted(at + 1)
+        annotation: Dict[str, Any] = {"name": name}
+        if self._v(j) == "(":
+            close = self._match(j)
+            parts = self._split(j + 1, close)
+            arguments: Dict[str, Any] = {}
+            if len(parts) == 1 and not (self._kind(parts[0][0]) == "ident" and self._v(parts[0][0] + 1) == "="):
+                arguments["value"] = self._value(*parts[0])
+            else:
+                for a, b in parts:
+                    if self._kind(a) == "ident" and self._v(a + 1) == "=":
+                        arguments[self._v(a)] = self._value(a + 2, b)
+            if arguments:
+                annotation["arguments"] = arguments
+            j = close + 1
+        return annotation, j
+
+    def _modifiers(self, idx: int, end: int) -> Tuple[List[Dict[str, Any]], List[str], int]:
+        """The annotations and modifiers at idx, in any order, and the index past them."""
+        annotations: List[Dict[str, Any]] = []
+        modifiers: List[str] = []
+        while idx < end:
+            value = self._v(idx)
+            if value == "@" and self._kind(idx + 1) == "ident" and self._v(idx + 1) != "interface":
+                annotation, idx = self._annotation(idx)
+                annotations.append(annotation)
+            elif value == "non" and self._v(idx + 1) == "-" and self._v(idx + 2) == "sealed":
+                modifiers.append("non-sealed")
+                idx += 3
+            elif value in MODIFIERS and self._kind(idx) == "ident":
+                if value == "sealed" and self._kind(idx + 1) != "ident":
+                    break
+                modifiers.append(value)
+                idx += 1
+            else:
+                break
+        return annotations, modifiers, idx
+
+    def _doc_for(self, first: int) -> str:
+        """The Javadoc comment ending right before a declaration (its annotations included)."""
+        start = self.tokens[first].start
+        pos = bisect.bisect_left(self._comment_starts, start) - 1
+        if pos < 0:
+            return ""
+        comment = self.comments[pos]
+        previous = self.tokens[first - 1].end if first > 0 else 0
+        if comment.start < previous:
+            return ""
+        return _doc_text(comment).strip()
+
+    # ------------------------------------------------------------------
+    # Declarations
+    # ------------------------------------------------------------------
+
+    def parse(self) -> Dict[str, Any]:
+        """Parse the whole file and return its package, imports and symbol records."""
+        pos, end = 0, len(self.tokens)
+        annotations, modifiers, after = self._modifiers(pos, end)
+        if self._v(after) == "package":
+            self.package, pos = self._dotted(after + 1)
+            pos = self._statement_end(pos, end) + 1
+        while self._v(pos) in ("import", ";"):
+            if self._v(pos) == "import":
+                pos = self._import(pos, end)
+            else:
+                pos += 1
+        self._members(pos, end, None)
+        result: Dict[str, Any] = {
+            "package": self.package,
+            "imports": self.imports,
+            "symbols": self.symbols,
+        }
+        if annotations and self._v(after) == "package":
+            # package-info.java
+            result["package_annotations"] = annotations
+        if self.nested:
+            result["nested"] = self.nested
+        return result
+
+    def _import(self, pos: int, end: int) -> int:
+        stop = self._statement_end(pos, end)
+        static = self._v(pos + 1) == "static"
+        path = self._text(pos + (2 if static else 1), stop).replace(" ", "")
+        wildcard = path.endswith(".*")
+        if wildcard:
+            path = path[:-2]
+        self.imports.append({
+            "path": path,
+            "name": "*" if wildcard else path.rsplit(".", 1)[-1],
+            "static": static,
+            "wildcard": wildcard,
+            "line": self.tokens[pos].line,
+        })
+        return stop + 1
+
+    def _members(self, start: int, end: int, owner: Optional[Dict[str, Any]]):
+        """Parse the declarations in tokens[start:end] - a file or a class body."""
+        pos = start
+        while pos < end:
+            pos = max(self._member(pos, end, owner), pos + 1)
+
+    def _member(self, pos: int, end: int, owner: Optional[Dict[str, Any]]) -> int:
+        start = pos
+        annotations, modifiers, pos = self._modifiers(pos, end)
+        if pos >= end:
+            return pos
+        item = {"start": start, "head": pos, "annotations": annotations, "modifiers": modifiers, "owner": owner}
+        value = self._v(pos)
+        if value == ";":
+            return pos + 1
+        if value == "{":
+            # An initializer block
+            close = self._match(pos)
+            if owner is not None:
+                self._scan_body(pos + 1, close, owner, owner["name"])
+            return close + 1
+        if self._is_type_keyword(pos):
+            return self._type(item, pos, end)
+        if owner is None:
+            return self._skip(pos, end)
+        type_params = None
+        if value == "<":
+            close = self._match_angle(pos)
+            type_params = self._text(pos, close + 1)
+            pos = close + 1
+        if self._kind(pos) == "ident" and self._v(pos) == owner["simple"] and self._v(pos + 1) in ("(", "{"):
+            return self._method(item, pos, pos, end, type_params, constructor=True)
+        type_end = self._type_end(pos)
+        if type_end == pos or self._kind(type_end) != "ident":
+            return self._skip(pos, end)
+        if self._v(type_end + 1) == "(":
+            return self._method(item, pos, type_end, end, type_params)
+        return self._fields(item, pos, type_end, end)
+
+    def _is_type_keyword(self, idx: int) -> bool:
+        value = self._v(idx)
+        if self._kind(idx) != "ident" and value != "@":
+            return False
+        if value in ("class", "interface", "enum"):
+            return self._kind(idx + 1) == "ident"
+        if value == "@":
+            return self._v(idx + 1) == "interface" and self._kind(idx + 2) == "ident"
+        # record is a keyword only where a record is declared
+        return value == "record" and self._kind(idx + 1) == "ident" and self._v(idx + 2) in ("(", "<")
+
+    def _visibility(self, modifiers: List[str], owner: Optional[Dict[str, Any]]) -> str:
+        for modifier in ("public", "protected", "private"):
+            if modifier in modifiers:
+                return modifier
+        if owner is not None and owner["kind"] in ("interface", "annotation"):
+            # Interface members are implicitly public
+            return "public"
+        return "package"
+
+    def _symbol(self, item: Dict[str, Any], name_idx: int, kind: str, last: int, signature: str,
+                **extra) -> Dict[str, Any]:
+        """Record a symbol spanning tokens[item start..last]."""
+        owner = item["owner"]
+        visibility = self._visibility(item["modifiers"], owner)
+        exported = visibility in ("public", "protected") and (owner is None or owner["exported"])
+        symbol = {
+            "name": self._v(name_idx),
+            "type": kind,
+            "signature": _clip(signature),
+            "start_line": self.tokens[item["start"]].line,
+            "column": self.tokens[name_idx].col,
+            "end_line": self.tokens[min(max(last, item["start"]), len(self.tokens) - 1)].end_line,
+            "doc": self._doc_for(item["start"]),
+            "exported": exported,
+            "visibility": visibility,
+            "language": "java",
+        }
+        if owner is not None and item.get("parent") is None:
+            symbol["container"] = owner["name"]
+        modifiers = [m for m in item["modifiers"] if m not in ("public", "protected", "private")]
+        if modifiers:
+            symbol["modifiers"] = modifiers
+        if item["annotations"]:
+            symbol["annotations"] = item["annotations"]
+            if any(a["name"] in ("Deprecated", "java.lang.Deprecated") for a in item["annotations"]):
+                symbol["deprecated"] = True
+        symbol.update(extra)
+        self._place(symbol, owner, item.get("parent"))
+        return symbol
+
+    def _place(self, symbol: Dict[str, Any], owner: Optional[Dict[str, Any]], parent: Optional[str]):
+        """File a symbol with the top-level ones, or with the nested ones under its parent."""
+        if parent is not None:
+            symbol["nested"] = True
+            symbol["parent"] = parent
+            self.nested.append(symbol)
+        elif owner is not None and owner["nested"]:
+            symbol["nested"] = True
+            symbol["parent"] = owner["name"]
+            self.nested.append(symbol)
+        else:
+            self.symbols.append(symbol)
+
+    def _head(self, item: Dict[str, Any], start: int, end: int) -> str:
+        """The written head of a declaration: its modifiers, then tokens[start:end]."""
+        return " ".join(item["modifiers"] + [self._text(start, end)])
+
+    def _supertypes(self, start: int, end: int) -> List[str]:
+        return [re.sub(r"\s+", "", self._text(a, b)).replace(",", ", ") for a, b in self._split(start, end)]
+
+    def _type(self, item: Dict[str, Any], pos: int, end: int) -> int:
+        keyword = pos
+        if self._v(pos) == "@":
+            kind, name_idx = "annotation", pos + 2
+        else:
+            kind, name_idx = TYPE_KEYWORDS[self._v(pos)], pos + 1
+        j = name_idx + 1
+        extra: Dict[str, Any] = {}
+        if self._v(j) == "<":
+            close = self._match_angle(j)
+            extra["typeParams"] = self._text(j, close + 1)
+            j = close + 1
+        components: List[Tuple[int, int]] = []
+        if kind == "record" and self._v(j) == "(":
+            close = self._match(j)
+            components = self._split(j + 1, close)
+            j = close + 1
+        clauses: Dict[str, Tuple[int, int]] = {}
+        current = None
+        while j < end and self._v(j) not in ("{", ";"):
+            if self._v(j) in ("extends", "implements", "permits") and self._kind(j) == "ident":
+                if current:
+                    clauses[current[0]] = (current[1], j)
+                current = (self._v(j), j + 1)
+            elif self._is_open(j):
+                j = self._match(j)
+            j += 1
+        if current:
+            clauses[current[0]] = (current[1], j)
+        for clause, (a, b) in clauses.items():
+            extra[clause] = self._supertypes(a, b)
+        body = j if self._v(j) == "{" else None
+        last = self._match(body) if body is not None else j
+
+        owner = item["owner"]
+        name = self._v(name_idx)
+        nested = item.get("parent") is not None or (owner is not None and owner["nested"])
+        qualified = name if item.get("parent") is not None or owner is None else f"{owner['name']}.{name}"
+        binary = f"{owner['binary']}${name}" if owner is not None else name
+        symbol = self._symbol(item, name_idx, kind, last, self._head(item, keyword, body if body else j), **extra)
+        context = {"name": qualified, "simple": name, "kind": kind, "binary": binary,
+                   "exported": symbol["exported"], "nested": nested, "methods": []}
+        for a, b in components:
+            self._component(context, a, b)
+        if body is not None:
+            close = self._match(body)
+            first = body + 1
+            if kind == "enum":
+                first = self._enum_constants(context, first, close)
+            self._members(first, close, context)
+        methods = [m["name"] for m in context["methods"] if not m.get("constructor")]
+        symbol["methods"] = sorted(set(methods))
+        if kind == "interface":
+            defaults = sorted({m["name"] for m in context["methods"] if m.get("body")})
+            if defaults:
+                symbol["default_methods"] = defaults
+        return last + 1
+
+    def _component(self, owner: Dict[str, Any], start: int, end: int):
+        """A record component: a private final field (and its accessor) of the record."""
+        annotations, _, j = self._modifiers(start, end)
+        type_end = self._type_end(j)
+        if self._kind(type_end) != "ident":
+            return
+        item = {"start": start, "head": j, "annotations": annotations, "modifiers": ["private", "final"],
+                "owner": owner}
+        self._symbol(item, type_end, "field", type_end, self._text(j, type_end + 1),
+                     field_type=self._text(j, type_end), component=True)
+
+    def _enum_constants(self, owner: Dict[str, Any], start: int, end: int) -> int:
+        """Record the constants opening an enum body; returns where its other members start."""
+        j = start
+        while j < end:
+            first = j
+            annotations, _, j = self._modifiers(j, end)
+            if self._v(j) == ";":
+                return j + 1
+            if self._kind(j) != "ident":
+                return j
+            name_idx = j
+            j += 1
+            if self._v(j) == "(":
+                j = self._match(j) + 1
+            item = {"start": first, "head": name_idx, "annotations": annotations,
+                    "modifiers": ["public", "static", "final"], "owner": owner}
+            last = j - 1
+            if self._v(j) == "{":
+                last = self._match(j)
+            symbol = self._symbol(item, name_idx, "enum_value", last, self._text(name_idx, j))
+            symbol.pop("modifiers", None)
+            if self._v(j) == "{":
+                # A constant with a body of its own: an anonymous subclass of the enum
+                self._anonymous_class(j, owner, f"{owner['name']}.{symbol['name']}", owner["simple"])
+                j = last + 1
+            if self._v(j) == ",":
+                j += 1
+                continue
+            return j + 1 if self._v(j) == ";" else j
+        return j
+
+    def _params(self, start: int, end: int) -> List[Dict[str, Any]]:
+        params = []
+        for a, b in self._split(start, end):
+            _, _, j = self._modifiers(a, b)
+            type_end = self._type_end(j)
+            if self._kind(type_end) == "ident" and type_end < b:
+                param = {"name": self._v(type_end), "type": self._text(j, type_end)}
+                if self._v(type_end - 1) == "...":
+                    param["variadic"] = True
+                params.append(param)
+        return params
+
+    def _method(self, item: Dict[str, Any], type_start: int, name_idx: int, end: int,
+                type_params: Optional[str], constructor: bool = False) -> int:
+        owner = item["owner"]
+        extra: Dict[str, Any] = {}
+        if type_params:
+            extra["typeParams"] = type_params
+        j = name_idx + 1
+        if self._v(j) == "(":
+            close = self._match(j)
+            extra["parameters"] = self._params(j + 1, close)
+            j = close + 1
+        else:
+            # A compact record constructor
+            extra["parameters"] = []
+            extra["compact"] = True
+        while self._v(j) == "[" and self._v(j + 1) == "]":
+            j += 2
+        signature_end = j
+        if self._v(j) == "throws":
+            k = j + 1
+            while k < end and self._v(k) not in ("{", ";", "default"):
+                k += 1
+            extra["throws"] = self._supertypes(j + 1, k)
+            signature_end = j = k
+        if constructor:
+            extra["constructor"] = True
+        else:
+            extra["returns"] = self._text(type_start, name_idx)
+        if self._v(j) == "default":
+            stop = self._statement_end(j, end)
+            extra["default"] = self._value(j + 1, stop)
+            last = j = stop
+        elif self._v(j) == "{":
+            last = self._match(j)
+            extra["body"] = True
+        else:
+            last = self._statement_end(j, end)
+        signature = self._head(item, type_start if not type_params else item["head"], signature_end)
+        symbol = self._symbol(item, name_idx, "method", last, signature, **extra)
+        body = symbol.pop("body", False)
+        owner["methods"].append({"name": symbol["name"], "constructor": constructor,
+                                 "body": body and (owner["kind"] != "interface" or "static" not in item["modifiers"])})
+        if body:
+            self._scan_body(j + 1, last, owner, f"{owner['name']}.{symbol['name']}")
+        return last + 1
+
+    def _fields(self, item: Dict[str, Any], type_start: int, name_idx: int, end: int) -> int:
+        owner = item["owner"]
+        stop = self._statement_end(name_idx, end)
+        field_type = self._text(type_start, name_idx)
+        constant = owner["kind"] in ("interface", "annotation") or \
+            {"static", "final"} <= set(item["modifiers"])
+        j = name_idx
+        while j < stop:
+            if self._kind(j) != "ident":
+                break
+            name = j
+            k = j + 1
+            while self._v(k) == "[" and self._v(k + 1) == "]":
+                k += 2
+            extra: Dict[str, Any] = {"field_type": field_type}
+            # The declarator ends at a comma followed by the next declarator's name
+            value_end = k
+            if self._v(k) == "=":
+                value_end = k + 1
+                while value_end < stop:
+                    if self._is_open(value_end):
+                        value_end = self._match(value_end) + 1
+                        continue
+                    if self._v(value_end) == "," and self._kind(value_end + 1) == "ident" and \
+                            self._v(value_end + 2) in ("=", ",", ";", "["):
+                        break
+                    value_end += 1
+                extra["value"] = _clip(self._text(k + 1, value_end))
+            more = self._v(value_end) == ","
+            signature = self._head(item, type_start, name_idx) + " " + self._v(name)
+            self._symbol(item, name, "constant" if constant else "field", value_end - 1 if more else stop,
+                         signature, **extra)
+            if extra.get("value") is not None:
+                self._scan_body(k + 1, value_end, owner, f"{owner['name']}.{self._v(name)}")
+            if not more:
+                break
+            j = value_end + 1
+        return stop + 1
+
+    # ------------------------------------------------------------------
+    # Classes inside bodies
+    # ------------------------------------------------------------------
+
+    def _scan_body(self, start: int, end: int, owner: Dict[str, Any], parent: str):
+        """Find the anonymous and local classes of a method body, initializer or field value."""
+        if not self.bodies:
+            return
+        j = start
+        while j < end:
+            value = self._v(j)
+            if value == "new" and self._kind(j) == "ident":
+                type_end = self._type_end(j + 1)
+                if self._v(type_end) == "(":
+                    close = self._match(type_end)
+                    self._scan_body(type_end + 1, close, owner, parent)
+                    if self._v(close + 1) == "{":
+                        written = self._text(j + 1, type_end)
+                        self._anonymous_class(close + 1, owner, parent, written, j)
+                        j = self._match(close + 1) + 1
+                        continue
+                    j = close + 1
+                    continue
+                j = max(type_end, j + 1)
+                continue
+            if self._is_type_keyword(j) and self._v(j - 1) != ".":
+                first = j
+                while first > start and (self._v(first - 1) in ("final", "abstract", "static", "strictfp")):
+                    first -= 1
+                annotations, modifiers, _ = self._modifiers(first, j)
+                item = {"start": first, "head": j, "annotations": annotations, "modifiers": modifiers,
+                        "owner": owner, "parent": parent}
+                j = self._type(item, j, end)
+                continue
+            j += 1
+
+    def _anonymous_class(self, body: int, owner: Dict[str, Any], parent: str, written: str,
+                         new_idx: Optional[int] = None):
+        """Record the class a `new T(...) {...}` (or an enum constant body) declares, and its members."""
+        count = self._anonymous.get(owner["binary"], 0) + 1
+        self._anonymous[owner["binary"]] = count
+        name = f"{owner['binary']}${count}"
+        close = self._match(body)
+        start = new_idx if new_idx is not None else body
+        tok = self.tokens[start]
+        symbol = {
+            "name": name,
+            "type": "class",
+            "signature": _clip(f"new {written}() {{...}}"),
+            "start_line": tok.line,
+            "column": tok.col,
+            "end_line": self.tokens[close].end_line,
+            "doc": "",
+            "exported": False,
+            "visibility": "private",
+            "language": "java",
+            "anonymous": True,
+            "supertype": re.sub(r"\s+", "", written).replace(",", ", "),
+            "nested": True,
+            "parent": parent,
+        }
+        self.nested.append(symbol)
+        context = {"name": name, "simple": name, "kind": "class", "binary": name, "exported": False,
+                   "nested": True, "methods": []}
+        self._members(body + 1, close, context)
+        symbol["methods"] = sorted({m["name"] for m in context["methods"] if not m.get("constructor")})
+
+
+def parse_java_source(content: str, bodies: bool = True) -> Dict[str, Any]:
+    """
+    Parse Java source text and return package, import and symbol records;
+    without bodies the classes declared inside method bodies are left out.
+    """
+    return JavaFileParser(content, bodies).parse()
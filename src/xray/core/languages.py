@@ -0,0 +1,189 @@
+"""The language of a file the built-in extensions do not settle: a mapping, a shebang, a modeline.
+
+A file's language is decided, first match wins, by:
+
+    the project's mapping   "extensions" in .xray.yaml: a file name
+                            ("Tiltfile") or extension (".bzl", ".pb.txt", the
+                            longest wins) -> a language name
+    the server's mapping    --language-map / XRAY_LANGUAGE_MAP, the same way
+    the built-in names      Makefile, GNUmakefile and the extensions of
+                            indexer.LANGUAGE_MAP
+    the file's first lines  for files without an extension only, see
+                            sniff_language
+
+A language XRAY has a parser for (NATIVE_LANGUAGES) routes the file
+through that parser, so `.gotmpl: go` would parse templates as Go; any other
+name ("starlark") gets the file counted, with its lines, under that name.
+"""
+
+import os
+import re
+from typing import Dict, Mapping, Optional, Tuple
+
+# Languages with a parser behind the index (the rest get line counts only)
+NATIVE_LANGUAGES = {"go", "typescript", "javascript", "python", "rust", "java", "proto", "sql", "make", "shell"}
+# Bytes read from each end of an extensionless file to sniff its language
+SNIFF_BYTES = 4096
+# Lines at the start or end of a file a modeline is looked for in, as vim does
+MODELINE_LINES = 5
+
+# Interpreter of a shebang line -> language
+INTERPRETERS = {
+    "python": "python", "pypy": "python",
+    "node": "javascript", "nodejs": "javascript", "bun": "javascript",
+    "deno": "typescript", "ts-node": "typescript", "tsx": "typescript",
+    "sh": "shell", "bash": "shell", "dash": "shell", "ksh": "shell", "zsh": "shell", "ash": "shell",
+    "make": "make", "gmake": "make",
+    "go": "go", "gorun": "go", "yaegi": "go",
+    "rust-script": "rust", "java": "java",
+}
+# vim filetype or emacs mode of a modeline -> language
+MODELINE_TYPES = {
+    "python": "python", "py": "python",
+    "go": "go",
+    "sh": "shell", "bash": "shell", "zsh": "shell", "shell-script": "shell",
+    "javascript": "javascript", "js": "javascript",
+    "typescript": "typescript", "ts": "typescript",
+    "rust": "rust", "java": "java",
+    "make": "make", "makefile": "make",
+    "sql": "sql", "proto": "proto", "protobuf": "proto",
+}
+
+_NAME = re.compile(r"^[a-z][a-z0-9_+#-]*$")
+_VERSION = re.compile(r"[\d.]+$")
+_VIM = re.compile(r"(?:^|\s)(?:vi|vim|ex)\d*:.*?\b(?:ft|filetype|syntax)=([\w+-]+)")
+_EMACS = re.compile(r"-\*-\s*(?:.*?\bmode:\s*)?([\w+-]+)\s*(?:;.*?)?-\*-", re.IGNORECASE)
+# `//usr/bin/env go run "$0" "$@"; exit`, and the `/// 2>/dev/null; exec go run` variants
+_GO_RUN = re.compile(r"^//.*\b(?:go\s+run|gorun)\b")
+_GO_FILE = re.compile(r"^(?://go:build\s|// \+build\s|package\s+[A-Za-z_]\w*\s*(?://.*)?$)")
+
+
+def valid_language(name: str) -> bool:
+    """Whether a language name can be mapped to: lowercase, starting with a letter."""
+    return bool(_NAME.match(name))
+
+
+def valid_key(key: str) -> bool:
+    """Whether a mapping key is an extension (".bzl") or a file name ("Tiltfile"), not a path or glob."""
+    return bool(key) and key != "." and not any(char in key for char in "/\\*?[")
+
+
+class LanguageMap:
+    """File names and extensions mapped to languages, the project's entries over the server's."""
+
+    def __init__(self, *mappings: Optional[Mapping[str, str]]):
+        self.names: Dict[str, str] = {}
+        self.extensions: Dict[str, str] = {}
+        # Later mappings win, so the server's goes first
+        for mapping in reversed([m for m in mappings if m]):
+            for key, language in mapping.items():
+                if key.startswith("."):
+                    self.extensions[key.lower()] = language
+                else:
+                    self.names[key.lower()] = language
+        self._longest = max((len(e) for e in self.extensions), default=0)
+
+    def __bool__(self) -> bool:
+        return bool(self.names or self.extensions)
+
+    def language(self, path) -> Optional[str]:
+        """The mapped language of a file, None when no entry names it."""
+        name = os.path.basename(str(path)).lower()
+        if name in self.names:
+            return self.names[name]
+        for i in range(max(0, len(name) - self._longest), len(name)):
+            if name[i] == "." and i > 0 and name[i:] in self.extensions:
+                return self.extensions[name[i:]]
+        return None
+
+    def describe(self) -> Dict[str, str]:
+        return {**self.names, **self.extensions}
+
+
+def parse_mapping(text: str) -> Tuple[Dict[str, str], Optional[str]]:
+    """A "KEY=LANGUAGE,KEY=LANGUAGE" mapping as --language-map takes it, with the first problem in it."""
+    mapping: Dict[str, str] = {}
+    for item in filter(None, (part.strip() for part in text.split(","))):
+        key, sep, language = item.partition("=")
+        key, language = key.strip(), language.strip().lower()
+        if not sep or not valid_key(key) or not valid_language(language):
+            return mapping, f"'{item}': expected EXTENSION=LANGUAGE or FILENAME=LANGUAGE, e.g. .bzl=starlark"
+        mapping[key] = language
+    return mapping, None
+
+
+def _interpreter(line: str) -> Optional[str]:
+    """The language of a shebang line's interpreter (`#!/usr/bin/env -S python3 -u` is python)."""
+    words = line[2:].split()
+    if not words:
+        return None
+    program = os.path.basename(words[0])
+    if program == "env":
+        rest = [w for w in words[1:] if not w.startswith("-") and "=" not in w]
+        if not rest:
+            return None
+        program = os.path.basename(rest[0])
+    return INTERPRETERS.get(program) or INTERPRETERS.get(_VERSION.sub("", program))
+
+
+def _modeline(line: str) -> Optional[str]:
+    for pattern in (_VIM, _EMACS):
+        match = pattern.search(line)
+        if match and match.group(1).lower() in MODELINE_TYPES:
+            return MODELINE_TYPES[match.group(1).lower()]
+    return None
+
+
+def sniff_language(head: str, tail: str = "") -> Optional[str]:
+    """
+    The language the first (and last) lines of a file declare, None if
+    they do not:
+
+        #!/usr/bin/env python3           shebang, the interpreter's language
+        //usr/bin/env go run "$0" "$@"   a Go file run as a script
+        //go:build ignore                a Go file kept out of the build
+        package main                     a Go file's first code line
+        # vim: set ft=python :           vim modeline, in the first or last 5 lines
+        # -*- mode: python -*-           emacs mode line, the same
+    """
+    lines = head.splitlines()
+    if not lines:
+        return None
+    first = lines[0].strip()
+    if first.startswith("#!"):
+        language = _interpreter(first)
+        if language:
+            return language
+    if _GO_RUN.match(first):
+        return "go"
+    edge = lines[:MODELINE_LINES] + (tail.splitlines()[-MODELINE_LINES:] if tail else lines[-MODELINE_LINES:])
+    for line in edge:
+        language = _modeline(line)
+        if language:
+            return language
+    for line in lines:
+        stripped = line.strip()
+        if not stripped or stripped.startswith("#!"):
+            continue
+        if _GO_FILE.match(stripped):
+            return "go"
+        if not stripped.startswith("//"):
+            break
+    return None
+
+
+def sniff_file(path: str) -> Optional[str]:
+    """The language an extensionless file declares in its first or last lines (see sniff_language)."""
+    try:
+        with open(path, "rb") as f:
+            head = f.read(SNIFF_BYTES)
+            tail = b""
+            if len(head) == SNIFF_BYTES:
+                f.seek(max(SNIFF_BYTES, os.fstat(f.fileno()).st_size - SNIFF_BYTES))
+                tail = f.read(SNIFF_BYTES)
+    except OSError:
+        return None
+    if b"\0" in head:
+        return None
+    decode = lambda data: data.decode("utf-8", errors="replace")
+    return sniff_language(decode(head), decode(tail))
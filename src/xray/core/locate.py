@@ -0,0 +1,335 @@
+"""Where a described change probably goes: files and symbols ranked by weak signals combined.
+
+An agent told to "fix user email validation" starts with grep and gets
+every line mentioning email. ChangeLocator ranks the project's symbols
+and files instead, scoring each signal from 0 to 1:
+
+    name   the terms against the words of the symbol's name (UserEmail ->
+           user, email): the same stem scores 1, one word the start of the
+           other 0.7, otherwise a fuzzy subsequence match (see
+           xray.core.go_search) half its score
+    text   how often the terms occur in the identifiers and comments of the
+           symbol's lines and its doc comment, or for a file, the whole
+           file - tf-idf, so a term every file uses counts for little -
+           relative to the best
+    churn  commits touching the file in the history window, on a log scale
+           relative to the most changed file
+    graph  in the Go call graph, one call away (1) or two (0.5) from one of
+           the best name and text matches
+
+A candidate's score is the weighted sum of its signals; the weights are
+the caller's to change. Churn alone never makes a candidate. Terms are the
+description's words without stop words and the keywords, reduced to a
+crude stem ("validation", "validate" -> "valid"), so inflections meet.
+Every candidate lists the evidence of the signals that matched, and every
+file the authors of its recent commits and its CODEOWNERS owners.
+"""
+
+import math
+import os
+import re
+from collections import Counter
+from typing import Any, Callable, Dict, Iterable, List, Optional, Set, Tuple
+
+from xray.core.go_search import fuzzy_score
+from xray.core.source_text import source_lines
+
+SIGNALS = ("name", "text", "churn", "graph")
+DEFAULT_WEIGHTS = {"name": 0.4, "text": 0.3, "churn": 0.1, "graph": 0.2}
+
+# Words of a description that say what to do, not where
+STOP_WORDS = {
+    "a", "an", "and", "are", "as", "at", "be", "by", "can", "do", "does", "for", "from", "has", "have", "how",
+    "in", "into", "is", "it", "its", "not", "of", "on", "or", "should", "so", "that", "the", "their", "them",
+    "then", "there", "this", "to", "was", "we", "when", "where", "which", "while", "with", "without",
+    "add", "adding", "allow", "change", "fix", "fixing", "handle", "implement", "make", "new", "support",
+    "update", "use",
+}
+# Longest first: the first that leaves a stem of MIN_STEM characters is cut
+_SUFFIXES = ("ations", "ation", "ators", "ator", "ating", "ated", "ates", "ate", "ings", "ing", "ions", "ion",
+             "ers", "er", "ed", "es", "s")
+MIN_STEM = 3
+# Matches the graph signal walks out from
+SEEDS = 10
+# Files whose symbols get a text score of their own, best file text first
+TEXT_FILES = 200
+MIN_FUZZY = 70
+
+_IDENTIFIER = re.compile(r"[A-Za-z][A-Za-z0-9_]*")
+_WORD = re.compile(r"[A-Z]+(?=[A-Z][a-z])|[A-Z]?[a-z]+|[A-Z]+|\d+")
+
+
+def stem(word: str) -> str:
+    word = word.lower()
+    for suffix in _SUFFIXES:
+        if word.endswith(suffix) and len(word) - len(suffix) >= MIN_STEM:
+            return word[:-len(suffix)]
+    return word
+
+
+def words(identifier: str) -> List[str]:
+    """The lowercase words of an identifier: "parseHTTPRequest" -> parse, http, request."""
+    return [w.lower() for w in _WORD.findall(identifier)]
+
+
+def terms_of(description: Optional[str], keywords: Optional[List[str]]) -> List[str]:
+    """The stems searched for: the description's words but its stop words, then every keyword's words."""
+    found: List[str] = []
+    sources = [(word, True) for token in _IDENTIFIER.findall(description or "") for word in words(token)]
+    sources += [(word, False) for keyword in keywords or [] for token in _IDENTIFIER.findall(keyword)
+                for word in words(token)]
+    for word, described in sources:
+        if described and word in STOP_WORDS or len(word) < 2 or word.isdigit():
+            continue
+        term = stem(word)
+        if term not in found:
+            found.append(term)
+    return found
+
+
+def term_counts(text: str) -> Counter:
+    """Stems of every identifier and comment word of a source file, counted."""
+    return Counter(stem(w) for token in _IDENTIFIER.findall(text) for w in words(token))
+
+
+def check_weights(weights: Optional[Dict[str, Any]]) -> Dict[str, float]:
+    """The default weights, overridden by any given."""
+    result = dict(DEFAULT_WEIGHTS)
+    for signal, weight in (weights or {}).items():
+        if signal not in SIGNALS:
+            raise ValueError(f"Unknown signal '{signal}' in weights - expected one of {', '.join(SIGNALS)}")
+        if not isinstance(weight, (int, float)) or isinstance(weight, bool) or weight < 0:
+            raise ValueError(f"The weight of '{signal}' must be a number of at least 0")
+        result[signal] = float(weight)
+    if not any(result.values()):
+        raise ValueError("At least one weight must be above 0")
+    return result
+
+
+class ChangeLocator:
+    """The symbols and files of a project ranked against the terms of a described change."""
+
+    def __init__(self, terms: List[str], weights: Dict[str, float], symbols: List[Dict[str, Any]],
+                 counts: Dict[str, Counter], read: Callable[[str], Optional[str]],
+                 churn: Optional[Dict[str, Dict[str, Any]]] = None,
+                 edges: Optional[Iterable[Tuple[Tuple[str, str], Tuple[str, str]]]] = None,
+                 owners: Optional[Callable[[str], Optional[List[str]]]] = None):
+        """
+        symbols are find_symbol's entries; counts the term_counts of every
+        file; read returns a file's text. churn maps a path to its
+        {"commits", "authors": {author: commits}}, None without history;
+        edges are the Go call graph's (caller, callee) node keys, None
+        without one; owners gives a path's CODEOWNERS owners.
+        """
+        self.terms = terms
+        self.weights = weights
+        self.symbols = symbols
+        self.counts = counts
+        self.read = read
+        self.churn = churn
+        self.owners = owners
+        self.adjacent: Dict[Tuple[str, str], Set[Tuple[str, str]]] = {}
+        for caller, callee in edges or ():
+            if caller != callee:
+                self.adjacent.setdefault(caller, set()).add(callee)
+                self.adjacent.setdefault(callee, set()).add(caller)
+
+    # Signals
+
+    def _name(self, name: str) -> Tuple[float, Dict[str, str]]:
+        """The name signal of a qualified name, and the word each matched term met."""
+        name_words = [(w, stem(w)) for w in words(name)]
+        total = 0.0
+        matched: Dict[str, str] = {}
+        for term in self.terms:
+            best, hit = 0.0, None
+            for word, word_stem in name_words:
+                if word_stem == term:
+                    best, hit = 1.0, word
+                    break
+                if min(len(word), len(term)) >= MIN_STEM and (word.startswith(term) or term.startswith(word_stem)):
+                    if best < 0.7:
+                        best, hit = 0.7, word
+            if best == 0.0:
+                score = fuzzy_score(term, name)
+                if score is not None and score >= MIN_FUZZY:
+                    best, hit = score / 200, name
+            if hit is not None:
+                total += best
+                matched[term] = hit
+        return total / len(self.terms), matched
+
+    def _idf(self) -> Dict[str, float]:
+        files = len(self.counts) or 1
+        return {term: math.log(1 + files / (1 + sum(1 for c in self.counts.values() if c.get(term))))
+                for term in self.terms}
+
+    def _tf_idf(self, counts: Dict[str, int], idf: Dict[str, float]) -> float:
+        return sum(math.log(1 + counts.get(term, 0)) * idf[term] for term in self.terms)
+
+    def _line_counts(self, path: str) -> List[Counter]:
+        """The terms on each line of a file (index 0 is line 1)."""
+        text = self.read(path) or ""
+        terms = set(self.terms)
+        lines = []
+        for line in source_lines(text):
+            lines.append(Counter(t for t in (stem(w) for token in _IDENTIFIER.findall(line) for w in words(token))
+                                 if t in terms))
+        return lines
+
+    def _churn(self, path: str, most: int) -> Tuple[float, Optional[Dict[str, Any]]]:
+        stats = (self.churn or {}).get(path)
+        if not stats or not most:
+            return 0.0, None
+        return math.log(1 + stats["commits"]) / math.log(1 + most), stats
+
+    def _proximity(self, seeds: List[Tuple[Tuple[str, str], str]]) -> Dict[Tuple[str, str], Dict[str, Any]]:
+        """Node -> {"score", "near", "hops"}: the closest seed one or two calls away."""
+        near: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        for key, label in seeds:
+            frontier, seen = {key}, {key}
+            for hops, score in ((1, 1.0), (2, 0.5)):
+                frontier = {n for node in frontier for n in self.adjacent.get(node, ())} - seen
+                seen |= frontier
+                for node in frontier:
+                    if node not in near or near[node]["score"] < score:
+                        near[node] = {"score": score, "near": label, "hops": hops}
+        return near
+
+    # Ranking
+
+    @staticmethod
+    def _qualified(symbol: Dict[str, Any]) -> str:
+        if symbol.get("receiver"):
+            return f"{symbol['receiver']['type']}.{symbol['name']}"
+        if symbol.get("container"):
+            return f"{symbol['container']}.{symbol['name']}"
+        if symbol.get("parent"):
+            return f"{symbol['parent']}.{symbol['name']}"
+        return symbol["name"]
+
+    @staticmethod
+    def _node(symbol: Dict[str, Any], qualified: str) -> Optional[Tuple[str, str]]:
+        if symbol.get("language", "go") != "go" or symbol["type"] not in ("function", "method"):
+            return None
+        return os.path.dirname(symbol["path"]), qualified
+
+    def _score(self, signals: Dict[str, float]) -> float:
+        return round(sum(self.weights[s] * signals.get(s, 0.0) for s in SIGNALS), 3)
+
+    def rank(self, limit: int) -> Dict[str, Any]:
+        """The top symbols and files, each with its score and the evidence of its signals."""
+        idf = self._idf()
+        file_text = {path: self._tf_idf(counts, idf) for path, counts in self.counts.items()}
+        best_text = max(file_text.values(), default=0.0) or 1.0
+        most_commits = max((s["commits"] for s in (self.churn or {}).values()), default=0)
+
+        by_file: Dict[str, List[Dict[str, Any]]] = {}
+        for symbol in self.symbols:
+            by_file.setdefault(symbol["path"], []).append(symbol)
+        # Symbols get a text score of their own in the files the terms occur in most
+        terms = set(self.terms)
+        text_files = sorted((p for p, score in file_text.items() if score > 0 and p in by_file),
+                            key=lambda p: (-file_text[p], p))[:TEXT_FILES]
+        symbol_text: Dict[int, Tuple[float, Counter]] = {}
+        raw_best = 0.0
+        for path in text_files:
+            lines = self._line_counts(path)
+            for symbol in by_file[path]:
+                # Its doc comment sits above its first line
+                counts = Counter(t for t in term_counts(symbol.get("doc") or "").elements() if t in terms)
+                for line in lines[symbol["start_line"] - 1:symbol["end_line"]]:
+                    counts.update(line)
+                if counts:
+                    raw = self._tf_idf(counts, idf)
+                    symbol_text[id(symbol)] = (raw, counts)
+                    raw_best = max(raw_best, raw)
+
+        candidates = []
+        for symbol in self.symbols:
+            qualified = self._qualified(symbol)
+            name, matched = self._name(qualified)
+            text, counts = symbol_text.get(id(symbol), (0.0, Counter()))
+            candidates.append({"symbol": symbol, "qualified": qualified, "node": self._node(symbol, qualified),
+                               "signals": {"name": name, "text": text / raw_best if raw_best else 0.0},
+                               "evidence": {"name": matched, "text": counts}})
+        seeds = sorted((c for c in candidates if c["node"] and (c["signals"]["name"] or c["signals"]["text"])),
+                       key=lambda c: (-(self.weights["name"] * c["signals"]["name"] +
+                                        self.weights["text"] * c["signals"]["text"]),
+                                      c["symbol"]["path"], c["symbol"]["start_line"]))[:SEEDS]
+        near = self._proximity([(c["node"], c["qualified"]) for c in seeds]) if self.adjacent else {}
+
+        ranked = []
+        for candidate in candidates:
+            signals, evidence = candidate["signals"], {}
+            graph = near.get(candidate["node"]) if candidate["node"] else None
+            if not (signals["name"] or signals["text"] or graph):
+                continue
+            churn, stats = self._churn(candidate["symbol"]["path"], most_commits)
+            signals.update({"churn": churn, "graph": graph["score"] if graph else 0.0})
+            if signals["name"]:
+                evidence["name"] = {"score": round(signals["name"], 3), "matched": candidate["evidence"]["name"]}
+            if signals["text"]:
+                evidence["text"] = {"score": round(signals["text"], 3),
+                                    "counts": dict(sorted(candidate["evidence"]["text"].items()))}
+            if stats:
+                evidence["churn"] = {"score": round(churn, 3), "commits": stats["commits"]}
+            if graph:
+                evidence["graph"] = {"score": graph["score"], "near": graph["near"], "hops": graph["hops"]}
+            ranked.append({**candidate["symbol"], "score": self._score(signals), "evidence": evidence})
+        ranked.sort(key=lambda s: (-s["score"], s["path"], s["start_line"], s["name"]))
+
+        files = self._files(ranked, file_text, best_text, most_commits)
+        return {"symbols": ranked[:limit], "total_count": len(ranked),
+                "files": [self._owned(entry) for entry in files[:limit]], "file_count": len(files)}
+
+    def _files(self, ranked: List[Dict[str, Any]], file_text: Dict[str, float], best_text: float,
+               most_commits: int) -> List[Dict[str, Any]]:
+        """Files ranked by the best name and graph signals of their symbols, their own text and their churn."""
+        best: Dict[str, Dict[str, Any]] = {}
+        for symbol in ranked:
+            entry = best.setdefault(symbol["path"], {"name": 0.0, "graph": 0.0, "symbols": []})
+            evidence = symbol["evidence"]
+            for signal in ("name", "graph"):
+                if signal in evidence and evidence[signal]["score"] > entry[signal]:
+                    entry[signal] = evidence[signal]["score"]
+            if "name" in evidence:
+                entry["symbols"].append(self._qualified(symbol))
+        files = []
+        for path in set(best) | {p for p, score in file_text.items() if score > 0}:
+            entry = best.get(path, {"name": 0.0, "graph": 0.0, "symbols": []})
+            path_name, path_matched = self._name(os.path.splitext(os.path.basename(path))[0])
+            signals = {"name": max(entry["name"], path_name), "text": file_text.get(path, 0.0) / best_text,
+                       "graph": entry["graph"]}
+            churn, stats = self._churn(path, most_commits)
+            signals["churn"] = churn
+            evidence: Dict[str, Any] = {}
+            if signals["name"]:
+                evidence["name"] = {"score": round(signals["name"], 3)}
+                if entry["symbols"]:
+                    evidence["name"]["symbols"] = entry["symbols"][:5]
+                if path_matched:
+                    evidence["name"]["file_name"] = path_matched
+            if signals["text"]:
+                counts = self.counts.get(path, {})
+                evidence["text"] = {"score": round(signals["text"], 3),
+                                    "counts": {t: counts[t] for t in self.terms if counts.get(t)}}
+            if stats:
+                evidence["churn"] = {"score": round(churn, 3), "commits": stats["commits"]}
+            if signals["graph"]:
+                evidence["graph"] = {"score": signals["graph"]}
+            files.append({"path": path, "score": self._score(signals), "evidence": evidence})
+        files.sort(key=lambda f: (-f["score"], f["path"]))
+        return files
+
+    def _owned(self, entry: Dict[str, Any]) -> Dict[str, Any]:
+        """A file entry with the authors of its recent commits and its CODEOWNERS owners."""
+        owners: Dict[str, Any] = {}
+        stats = (self.churn or {}).get(entry["path"])
+        if stats and stats.get("authors"):
+            owners["recent_authors"] = [{"author": author, "commits": commits} for author, commits in
+                                        sorted(stats["authors"].items(), key=lambda a: (-a[1], a[0]))[:5]]
+        codeowners = self.owners(entry["path"]) if self.owners else None
+        if codeowners:
+            owners["codeowners"] = codeowners
+        return {**entry, "owners": owners} if owners else entry
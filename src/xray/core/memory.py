@@ -0,0 +1,152 @@
+"""Keeping the index small: shared strings, size estimates and lean Go packages.
+
+The parsers keep no syntax trees - a parse result is already the compact
+facts the tools need - but a large project still holds millions of small
+strings, most of them repeats: package names, import paths, type names,
+receiver names. intern_strings makes every short string in a parse result
+the one shared copy, which also survives a round trip through the
+persisted index (pickle writes a shared object once).
+
+With max_memory_mb set (.xray.yaml), the Go packages holding the most
+body-level facts - the calls, locals and assignments of every function,
+see BODY_KEYS - are made lean once the index would exceed it: their parse
+results keep declarations, imports and package variables, and re-parse
+the file whenever a tool asks for its bodies. Symbol lookups stay as fast
+as before; call-graph and body-level queries over those packages pay a
+parse per file, softened by a small cache of recent re-parses.
+"""
+
+import sys
+import threading
+from collections import OrderedDict
+from typing import Any, Dict, Optional, Set
+
+from xray.core.parse_pool import parse_source
+from xray.core.source_text import read_source
+
+# The parts of a Go parse result only body-level queries read
+BODY_KEYS = ("functions", "package_calls")
+# Longer strings (doc comments, literals) are rarely repeated
+INTERN_LENGTH = 100
+MEGABYTE = 1024 ** 2
+# Re-parsed bodies kept, most recent first
+RECENT_PARSES = 32
+
+_recent: "OrderedDict[tuple[str, str], Dict[str, Any]]" = OrderedDict()
+_recent_lock = threading.Lock()
+
+
+def intern_strings(value: Any) -> Any:
+    """Replace the short strings in a parse result, in place, by their interned copies; returns it."""
+    if isinstance(value, dict):
+        for key, item in list(value.items()):
+            if isinstance(item, str):
+                if len(item) <= INTERN_LENGTH:
+                    value[key] = sys.intern(item)
+            elif isinstance(item, (dict, list)):
+                intern_strings(item)
+    elif isinstance(value, list):
+        for i, item in enumerate(value):
+            if isinstance(item, str):
+                if len(item) <= INTERN_LENGTH:
+                    value[i] = sys.intern(item)
+            elif isinstance(item, (dict, list)):
+                intern_strings(item)
+    return value
+
+
+def approximate_size(value: Any, seen: Optional[Set[int]] = None) -> int:
+    """
+    Bytes held by a value and everything it contains, each object counted
+    once (across calls sharing seen), so shared strings are not counted twice.
+    """
+    seen = set() if seen is None else seen
+    total = 0
+    stack = [value]
+    while stack:
+        item = stack.pop()
+        if id(item) in seen:
+            continue
+        seen.add(id(item))
+        total += sys.getsizeof(item)
+        if isinstance(item, dict):
+            stack.extend(item.keys())
+            stack.extend(item.values())
+        elif isinstance(item, (list, tuple, set, frozenset)):
+            stack.extend(item)
+    return total
+
+
+def body_size(parsed: Dict[str, Any]) -> int:
+    """Bytes of a parse result's body-level facts: the part making it lean drops (or dropped)."""
+    if isinstance(parsed, OnDemand):
+        return parsed.body_bytes
+    return sum(approximate_size(parsed[key]) for key in BODY_KEYS if key in parsed)
+
+
+def call_sites(parsed: Dict[str, Any]) -> int:
+    """The calls inside a Go file's functions, counted without re-parsing a lean one."""
+    if isinstance(parsed, OnDemand):
+        return parsed.call_sites
+    return sum(len(func.get("calls", [])) for func in parsed.get("functions", []))
+
+
+class OnDemand(dict):
+    """
+    A lean Go parse result: everything but the BODY_KEYS, which are parsed
+    again from the file whenever they are read.
+    """
+
+    def __init__(self, parsed: Dict[str, Any], path: str, digest: str,
+                 body_bytes: Optional[int] = None, calls: Optional[int] = None):
+        super().__init__((key, value) for key, value in parsed.items() if key not in BODY_KEYS)
+        self.path = path
+        self.digest = digest
+        self.body_bytes = body_size(parsed) if body_bytes is None else body_bytes
+        self.call_sites = call_sites(parsed) if calls is None else calls
+
+    def __reduce__(self):
+        return OnDemand, (dict(self), self.path, self.digest, self.body_bytes, self.call_sites)
+
+    def bodies(self) -> Dict[str, Any]:
+        """The file parsed again (one from the recent cache if its content is unchanged)."""
+        key = (self.path, self.digest)
+        with _recent_lock:
+            cached = _recent.get(key)
+            if cached is not None:
+                _recent.move_to_end(key, last=False)
+                return cached
+        try:
+            content, _ = read_source(self.path)
+            parsed = parse_source(self.path, content, bool(super().get("partial")), "go")
+        except (OSError, UnicodeDecodeError, ValueError):
+            # Gone or unreadable since it was indexed: the next refresh drops or re-parses it
+            parsed = {}
+        with _recent_lock:
+            _recent[key] = parsed
+            _recent.move_to_end(key, last=False)
+            while len(_recent) > RECENT_PARSES:
+                _recent.popitem()
+        return parsed
+
+    def full(self) -> Dict[str, Any]:
+        """The whole parse result again, as a plain dict."""
+        bodies = self.bodies()
+        return {**self, **{key: bodies[key] for key in BODY_KEYS if key in bodies}}
+
+    def __missing__(self, key):
+        if key in BODY_KEYS:
+            bodies = self.bodies()
+            if key in bodies:
+                return bodies[key]
+        raise KeyError(key)
+
+    def get(self, key, default=None):
+        if key in BODY_KEYS:
+            return self.bodies().get(key, default)
+        return super().get(key, default)
+
+    def __contains__(self, key):
+        if key in BODY_KEYS:
+            return key in self.bodies()
+        return super().__contains__(key)
@@ -0,0 +1,268 @@
+"""Usage metrics of a running server: tool calls, their latency, and slow-call logging.
+
+Every tool call is counted per tool with its outcome (ok, or the error code
+it failed with, see xray.core.errors) and its duration. Durations keep the
+last LATENCY_SAMPLES of each tool, from which the percentiles are read when
+a snapshot is taken; recording a call is a lock, two additions and an
+append, so metrics stay on. A call slower than the threshold is logged to
+stderr with its tool name and arguments - sanitized: secret-looking
+parameters are redacted, long strings (buffer contents) cut to their
+length, long lists to their first entries.
+
+prometheus_text renders a server_stats snapshot in the Prometheus text
+exposition format, for the HTTP transport's /metrics endpoint.
+"""
+
+import asyncio
+import json
+import os
+import re
+import sys
+import threading
+import time
+from collections import deque
+from contextlib import contextmanager
+from contextvars import ContextVar
+from typing import Any, Dict, Iterator, List, Optional, TextIO
+
+from xray.core.errors import CANCELLED, error_code
+
+# Durations kept per tool (and per project's index refreshes) for percentiles
+LATENCY_SAMPLES = 1024
+# Calls slower than this many milliseconds are logged (--slow-call-ms / XRAY_SLOW_CALL_MS); 0 logs none
+DEFAULT_SLOW_CALL_MS = 5000
+PERCENTILES = (50, 90, 99)
+
+# Parameters whose value is never logged
+_SECRET = re.compile(r"token|secret|password|passwd|credential|auth|api_?key", re.IGNORECASE)
+_LONG_STRING = 200
+_LIST_ENTRIES = 10
+_DEPTH = 3
+
+# The outcome of the tool call in progress, for _error to mark it failed
+_current: ContextVar[Optional[Dict[str, Any]]] = ContextVar("xray_call", default=None)
+
+
+class Latencies:
+    """Durations of one kind of operation: a count and total of all, the last samples for percentiles."""
+
+    def __init__(self, samples: int = LATENCY_SAMPLES):
+        self.count = 0
+        self.total_ms = 0.0
+        self.max_ms = 0.0
+        self.last_ms: Optional[float] = None
+        self._samples: "deque[float]" = deque(maxlen=samples)
+        self._lock = threading.Lock()
+
+    def add(self, ms: float):
+        with self._lock:
+            self.count += 1
+            self.total_ms += ms
+            self.max_ms = max(self.max_ms, ms)
+            self.last_ms = ms
+            self._samples.append(ms)
+
+    def summary(self) -> Dict[str, Any]:
+        """{"count", "mean_ms", "p50_ms", "p90_ms", "p99_ms", "max_ms", "last_ms", "total_ms"}."""
+        with self._lock:
+            samples = sorted(self._samples)
+            count, total, peak, last = self.count, self.total_ms, self.max_ms, self.last_ms
+        result: Dict[str, Any] = {"count": count, "mean_ms": round(total / count, 1) if count else None}
+        for p in PERCENTILES:
+            # Nearest rank
+            result[f"p{p}_ms"] = round(samples[max(0, -(-len(samples) * p // 100) - 1)], 1) if samples else None
+        result.update({"max_ms": round(peak, 1), "last_ms": round(last, 1) if last is not None else None,
+                       "total_ms": round(total, 1)})
+        return result
+
+
+def sanitize_arguments(value: Any, key: str = "", depth: int = 0) -> Any:
+    """Tool arguments fit for a log line: secrets redacted, long strings and lists cut."""
+    if key and _SECRET.search(key):
+        return "<redacted>"
+    if isinstance(value, str):
+        return value if len(value) <= _LONG_STRING else f"<{len(value)} characters>"
+    if isinstance(value, dict):
+        if depth >= _DEPTH:
+            return f"<{len(value)} keys>"
+        return {str(k): sanitize_arguments(v, str(k), depth + 1) for k, v in value.items() if k != "ctx"}
+    if isinstance(value, (list, tuple)):
+        if depth >= _DEPTH:
+            return f"<{len(value)} entries>"
+        entries = [sanitize_arguments(v, "", depth + 1) for v in value[:_LIST_ENTRIES]]
+        if len(value) > _LIST_ENTRIES:
+            entries.append(f"<{len(value) - _LIST_ENTRIES} more>")
+        return entries
+    if value is None or isinstance(value, (bool, int, float)):
+        return value
+    return f"<{type(value).__name__}>"
+
+
+def mark_failed(code: str):
+    """Record that the tool call in progress failed with an error code (see xray.core.errors)."""
+    outcome = _current.get()
+    if outcome is not None and outcome["error"] is None:
+        outcome["error"] = code
+
+
+class ServerMetrics:
+    """The calls of every tool since the server started."""
+
+    def __init__(self, slow_call_ms: int = DEFAULT_SLOW_CALL_MS, log: Optional[TextIO] = None):
+        self.slow_call_ms = slow_call_ms
+        self.log = log
+        self.started = time.time()
+        self._tools: Dict[str, Dict[str, Any]] = {}
+        self._lock = threading.Lock()
+
+    def record(self, tool: str, ms: float, error: Optional[str] = None, slow: bool = False):
+        with self._lock:
+            stats = self._tools.get(tool)
+            if stats is None:
+                stats = self._tools[tool] = {"calls": 0, "errors": {}, "slow_calls": 0, "latency": Latencies()}
+            stats["calls"] += 1
+            if error is not None:
+                stats["errors"][error] = stats["errors"].get(error, 0) + 1
+            if slow:
+                stats["slow_calls"] += 1
+        stats["latency"].add(ms)
+
+    @contextmanager
+    def measure(self, tool: str, arguments: Optional[Dict[str, Any]] = None) -> Iterator[Dict[str, Any]]:
+        """
+        Time one call of a tool, run in the with block. A tool that returns
+        its error instead of raising it marks the call with mark_failed.
+        """
+        outcome: Dict[str, Any] = {"error": None}
+        token = _current.set(outcome)
+        started = time.perf_counter()
+        try:
+            yield outcome
+        except BaseException as e:
+            outcome["error"] = outcome["error"] or (CANCELLED if isinstance(e, asyncio.CancelledError)
+                                                     else error_code(e))
+            raise
+        finally:
+            _current.reset(token)
+            ms = (time.perf_counter() - started) * 1000
+            slow = 0 < self.slow_call_ms <= ms
+            self.record(tool, ms, outcome["error"], slow)
+            if slow:
+                self._log_slow(tool, ms, arguments or {}, outcome["error"])
+
+    def _log_slow(self, tool: str, ms: float, arguments: Dict[str, Any], error: Optional[str]):
+        try:
+            shown = json.dumps(sanitize_arguments(arguments), ensure_ascii=False, default=str, sort_keys=True)
+        except (TypeError, ValueError):
+            shown = "<unprintable>"
+        failed = f", failed with {error}" if error else ""
+        print(f"xray: slow call: {tool} took {round(ms)} ms{failed}; arguments {shown}",
+              file=self.log or sys.stderr, flush=True)
+
+    def tools(self) -> List[Dict[str, Any]]:
+        """Per-tool counts and latency percentiles, the most called first."""
+        with self._lock:
+            entries = [(tool, stats["calls"], dict(stats["errors"]), stats["slow_calls"], stats["latency"])
+                       for tool, stats in self._tools.items()]
+        result = []
+        for tool, calls, errors, slow, latency in entries:
+            summary = latency.summary()
+            del summary["count"]
+            result.append({"tool": tool, "calls": calls, "errors": sum(errors.values()), "error_codes": errors,
+                           "slow_calls": slow, **summary})
+        result.sort(key=lambda e: (-e["calls"], e["tool"]))
+        return result
+
+
+def process_memory() -> Dict[str, Optional[int]]:
+    """The server process's resident memory now and at its peak, in bytes (None where the platform does not say)."""
+    rss = peak = None
+    try:
+        with open("/proc/self/statm") as statm:
+            rss = int(statm.read().split()[1]) * os.sysconf("SC_PAGE_SIZE")
+    except (OSError, ValueError, IndexError, AttributeError):
+        pass
+    try:
+        import resource
+
+        peak = resource.getrusage(resource.RUSAGE_SELF).ru_maxrss
+        # Kilobytes on Linux, bytes on macOS
+        peak = peak if sys.platform == "darwin" else peak * 1024
+    except (ImportError, OSError, ValueError):
+        pass
+    return {"rss_bytes": rss, "peak_rss_bytes": peak}
+
+
+def _labels(**labels: Any) -> str:
+    def escape(value: Any) -> str:
+        return str(value).replace("\\", "\\\\").replace('"', '\\"').replace("\n", "\\n")
+
+    return "{" + ",".join(f'{key}="{escape(value)}"' for key, value in labels.items() if value is not None) + "}"
+
+
+def _family(lines: List[str], name: str, kind: str, help_text: str):
+    lines.extend([f"# HELP {name} {help_text}", f"# TYPE {name} {kind}"])
+
+
+def _summary(lines: List[str], name: str, latency: Dict[str, Any], **labels: Any):
+    """A latency summary (milliseconds) as a Prometheus summary in seconds."""
+    for p in PERCENTILES:
+        value = latency.get(f"p{p}_ms")
+        if value is not None:
+            lines.append(f"{name}{_labels(**labels, quantile=p / 100)} {value / 1000}")
+    lines.append(f"{name}_sum{_labels(**labels)} {latency.get('total_ms', 0) / 1000}")
+    lines.append(f"{name}_count{_labels(**labels)} {latency.get('count', 0)}")
+
+
+def prometheus_text(stats: Dict[str, Any]) -> str:
+    """A server_stats snapshot in the Prometheus text exposition format (version 0.0.4)."""
+    lines: List[str] = []
+    _family(lines, "xray_uptime_seconds", "gauge", "Seconds since the server started")
+    lines.append(f"xray_uptime_seconds {stats['uptime_s']}")
+
+    tools = stats["tools"]
+    _family(lines, "xray_tool_calls_total", "counter", "Tool calls")
+    lines.extend(f"xray_tool_calls_total{_labels(tool=t['tool'])} {t['calls']}" for t in tools)
+    _family(lines, "xray_tool_errors_total", "counter", "Tool calls that failed, by error code")
+    lines.extend(f"xray_tool_errors_total{_labels(tool=t['tool'], code=code)} {count}"
+                 for t in tools for code, count in sorted(t["error_codes"].items()))
+    _family(lines, "xray_tool_slow_calls_total", "counter", "Tool calls slower than the slow-call threshold")
+    lines.extend(f"xray_tool_slow_calls_total{_labels(tool=t['tool'])} {t['slow_calls']}" for t in tools)
+    _family(lines, "xray_tool_duration_seconds", "summary", "Tool call duration")
+    for t in tools:
+        _summary(lines, "xray_tool_duration_seconds", {**t, "count": t["calls"]}, tool=t["tool"])
+
+    projects = stats["projects"]
+    gauges = [("xray_index_files", "files", "Files in the index"),
+              ("xray_index_symbols", "symbols", "Symbols in the index"),
+              ("xray_index_cache_bytes", "cache_bytes", "Size of the persisted index on disk")]
+    for name, field, help_text in gauges:
+        _family(lines, name, "gauge", help_text)
+        lines.extend(f"{name}{_labels(project=p['root'], ref=p['ref'])} {p[field]}"
+                     for p in projects if p.get(field) is not None)
+    for field, help_text in (("hits", "Files whose persisted parse result was reused"),
+                             ("misses", "Files parsed because no persisted result was current")):
+        name = f"xray_index_cache_{field}_total"
+        _family(lines, name, "counter", help_text)
+        lines.extend(f"{name}{_labels(project=p['root'], ref=p['ref'])} {p['cache'][field]}"
+                     for p in projects if p.get("cache"))
+    _family(lines, "xray_index_refresh_duration_seconds", "summary",
+            "Duration of bringing an index up to date with the tree")
+    for p in projects:
+        if p.get("refresh"):
+            _summary(lines, "xray_index_refresh_duration_seconds", p["refresh"], project=p["root"], ref=p["ref"])
+
+    pages = stats["pages"]
+    for field, help_text in (("hits", "Cursors served from a stored result"),
+                             ("misses", "Cursors whose stored result had expired")):
+        name = f"xray_page_cursor_{field}_total"
+        _family(lines, name, "counter", help_text)
+        lines.append(f"{name} {pages[field]}")
+    memory = stats["memory"]
+    for field, name, help_text in (("rss_bytes", "xray_process_resident_memory_bytes", "Resident memory"),
+                                   ("peak_rss_bytes", "xray_process_peak_resident_memory_bytes",
+                                    "Peak resident memory")):
+        if memory.get(field) is not None:
+            _family(lines, name, "gauge", help_text)
+            lines.append(f"{name} {memory[field]}")
+    return "\n".join(lines) + "\n"
@@ -0,0 +1,138 @@
+"""Cursor pagination and token budgets for listing tools.
+
+A listing query runs once: its full result is kept in a small in-memory
+store and later pages are sliced from it, so fetching the next page never
+re-runs the query and cannot shift if the tree changes in between. Cursors
+are opaque strings naming the stored result and the offset of the next
+entry; producers return entries in a deterministic order.
+"""
+
+import base64
+import json
+import secrets
+import threading
+import time
+from collections import OrderedDict
+from typing import Dict, List, Optional, Any, Tuple
+
+DEFAULT_LIMIT = 100
+# Results kept for cursors, least recently used evicted first
+MAX_STORED_RESULTS = 32
+# Seconds a stored result stays valid after its last use
+RESULT_TTL = 900
+# Bulky free-text fields, dropped from a page before whole entries are
+SNIPPET_FIELDS = ("text", "snippet", "context", "doc")
+
+
+def estimate_tokens(value: Any) -> int:
+    """Rough token count of a JSON value (about four characters per token)."""
+    return len(json.dumps(value, default=str)) // 4 + 1
+
+
+def _encode_cursor(result_id: str, offset: int) -> str:
+    return base64.urlsafe_b64encode(f"{result_id}:{offset}".encode()).decode().rstrip("=")
+
+
+def _decode_cursor(cursor: str) -> Tuple[str, int]:
+    try:
+        padded = cursor + "=" * (-len(cursor) % 4)
+        result_id, offset = base64.urlsafe_b64decode(padded.encode()).decode().split(":")
+        return result_id, int(offset)
+    except Exception:
+        raise ValueError(f"Invalid cursor '{cursor}'")
+
+
+def _strip_snippets(entry: Any) -> Tuple[Any, List[str]]:
+    if not isinstance(entry, dict):
+        return entry, []
+    removed = [field for field in SNIPPET_FIELDS if field in entry]
+    return {k: v for k, v in entry.items() if k not in removed}, removed
+
+
+class PageStore:
+    """Holds full listing results between the pages a client fetches."""
+
+    def __init__(self, max_results: int = MAX_STORED_RESULTS, ttl: float = RESULT_TTL):
+        self.max_results = max_results
+        self.ttl = ttl
+        # result id -> (query key, result, last used)
+        self._results: "OrderedDict[str, Tuple[str, Dict[str, Any], float]]" = OrderedDict()
+        self._lock = threading.Lock()
+        # Cursors served from a stored result, and those whose result had expired
+        self.hits = 0
+        self.misses = 0
+
+    def _store(self, key: str, result: Dict[str, Any]) -> str:
+        with self._lock:
+            now = time.monotonic()
+            for result_id in [r for r, (_, _, used) in self._results.items() if now - used > self.ttl]:
+                del self._results[result_id]
+            while len(self._results) >= self.max_results:
+                self._results.popitem(last=False)
+            result_id = secrets.token_hex(8)
+            self._results[result_id] = (key, result, now)
+            return result_id
+
+    def _resume(self, key: str, cursor: str) -> Tuple[str, Dict[str, Any], int]:
+        result_id, offset = _decode_cursor(cursor)
+        with self._lock:
+            entry = self._results.get(result_id)
+            if entry is None or time.monotonic() - entry[2] > self.ttl:
+                self._results.pop(result_id, None)
+                self.misses += 1
+                raise ValueError("Cursor has expired; repeat the call without a cursor to run the query again")
+            if entry[0] != key:
+                raise ValueError("Cursor belongs to a different query; pass the same arguments as the first call")
+            self.hits += 1
+            self._results[result_id] = (key, entry[1], time.monotonic())
+            self._results.move_to_end(result_id)
+            return result_id, entry[1], offset
+
+    def stats(self) -> Dict[str, Any]:
+        """Results stored now, and how often later pages found theirs."""
+        with self._lock:
+            served = self.hits + self.misses
+            return {"stored_results": len(self._results), "hits": self.hits, "misses": self.misses,
+                    "hit_rate": round(self.hits / served, 3) if served else None}
+
+    def first_page(self, key: str, result: Dict[str, Any], field: str, limit: int,
+                   max_tokens: Optional[int] = None) -> Dict[str, Any]:
+        """Return the first page of result[field], storing the result if more pages follow."""
+        return self._page(key, None, result, field, 0, limit, max_tokens)
+
+    def next_page(self, key: str, cursor: str, field: str, limit: int,
+                  max_tokens: Optional[int] = None) -> Dict[str, Any]:
+        """Return the page a cursor points at, from the stored result of the same query."""
+        result_id, result, offset = self._resume(key, cursor)
+        return self._page(key, result_id, result, field, offset, limit, max_tokens)
+
+    def _page(self, key: str, result_id: Optional[str], result: Dict[str, Any], field: str,
+              offset: int, limit: int, max_tokens: Optional[int]) -> Dict[str, Any]:
+        items = result.get(field) or []
+        limit = max(1, limit)
+        entries = items[offset:offset + limit]
+        page = {k: v for k, v in result.items() if k != field}
+        page[field] = entries
+        page["total_count"] = len(items)
+
+        if max_tokens is not None and entries and estimate_tokens(page) > max_tokens:
+            # Snippets go first; whole entries are only dropped if that is not enough
+            removed = set()
+            stripped = []
+            for entry in entries:
+                entry, fields = _strip_snippets(entry)
+                stripped.append(entry)
+                removed.update(fields)
+            page[field] = entries = stripped
+            if removed:
+                page["trimmed_fields"] = sorted(removed)
+            # Always keep one entry so paging makes progress
+            while len(entries) > 1 and estimate_tokens(page) > max_tokens:
+                entries.pop()
+
+        end = offset + len(entries)
+        if end < len(items):
+            if result_id is None:
+                result_id = self._store(key, result)
+            page["next_cursor"] = _encode_cursor(result_id, end)
+        return page
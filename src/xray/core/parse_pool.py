@@ -0,0 +1,210 @@
+"""Parallel parsing of Go, TypeScript, JavaScript, Python, Rust, Java, .proto, .sql, Makefile and shell files for (re-)indexing.
+
+The parsers are pure Python, so threads would serialize on the GIL; batches
+of files are parsed in worker processes instead. Results are returned keyed
+by path and merged by the caller in sorted order, which keeps the index
+identical whatever order the workers finish in.
+"""
+
+import multiprocessing
+import os
+import threading
+from concurrent.futures import FIRST_COMPLETED, ProcessPoolExecutor, wait
+from concurrent.futures.process import BrokenProcessPool
+from typing import Callable, Dict, FrozenSet, List, Optional, Any, Tuple
+
+from xray.core.debt import find_markers
+from xray.core.errors import IndexingCancelled, error_code
+from xray.core.go_parser import PARSER_VERSION, parse_go_source
+from xray.core.java_parser import JAVA_PARSER_VERSION, parse_java_source
+from xray.core.partial import WHOLE_FILE_LANGUAGES, drop_body_metrics, skeleton
+from xray.core.proto_parser import PROTO_PARSER_VERSION, parse_proto_source
+from xray.core.py_analysis import module_name
+from xray.core.py_parser import PY_PARSER_VERSION, parse_py_source
+from xray.core.ranges import add_declaration_ranges
+from xray.core.rs_parser import RS_PARSER_VERSION, parse_rs_source
+from xray.core.source_text import content_hash, read_source, source_lines
+from xray.core.sql_parser import SQL_PARSER_VERSION, parse_sql_source
+from xray.core.task_parser import TASK_PARSER_VERSION, is_makefile, parse_makefile, parse_shell_source
+from xray.core.ts_parser import TS_PARSER_VERSION, allows_jsx, parse_ts_source, source_language
+
+# Below this many files the cost of starting workers outweighs the gain
+MIN_PARALLEL_FILES = 64
+# Files handed to a worker per task; small enough to notice cancellation quickly
+BATCH_SIZE = 32
+
+
+def default_concurrency() -> int:
+    """Worker count: XRAY_CONCURRENCY if set, otherwise one per CPU (like GOMAXPROCS)."""
+    try:
+        configured = int(os.environ.get("XRAY_CONCURRENCY", "0"))
+    except ValueError:
+        configured = 0
+    return configured if configured > 0 else (os.cpu_count() or 1)
+
+
+def _language(path: str) -> str:
+    if is_makefile(path):
+        return "make"
+    extension = os.path.splitext(path)[1]
+    return {".go": "go", ".py": "python", ".rs": "rust", ".java": "java", ".proto": "proto",
+            ".sql": "sql", ".sh": "shell", ".bash": "shell"}.get(extension) or source_language(path)
+
+
+def parser_version(path: str, language: Optional[str] = None) -> int:
+    """The version of the parser for a file's extension (or language): results of another version are stale."""
+    return {"go": PARSER_VERSION, "python": PY_PARSER_VERSION, "rust": RS_PARSER_VERSION, "java": JAVA_PARSER_VERSION,
+            "proto": PROTO_PARSER_VERSION, "sql": SQL_PARSER_VERSION, "make": TASK_PARSER_VERSION,
+            "shell": TASK_PARSER_VERSION}.get(language or _language(path), TS_PARSER_VERSION)
+
+
+def parse_source(path: str, content: str, partial: bool = False, language: Optional[str] = None) -> Dict[str, Any]:
+    """
+    Parse file content with the parser for its extension, or for language
+    when the file's language is mapped or forced (see xray.core.languages);
+    partial parses only its declaration skeleton (see xray.core.partial),
+    marking the result and leaving out the metrics of its blanked bodies.
+    A partial Java file is parsed without scanning its
+    method bodies instead. Either way the debt markers of its comments (see
+    xray.core.debt) come from the whole content, as do the ranges of its
+    declarations (see xray.core.ranges).
+    """
+    language = language or _language(path)
+    if partial and language == "java":
+        parsed = parse_java_source(content, bodies=False)
+        parsed["partial"] = True
+    elif partial and language not in WHOLE_FILE_LANGUAGES:
+        parsed = drop_body_metrics(_parse(path, skeleton(content, "typescript" if language == "javascript" else language),
+                                          language))
+        parsed["partial"] = True
+    else:
+        parsed = _parse(path, content, language)
+    parsed["markers"] = find_markers(content, language)
+    return add_declaration_ranges(parsed, content)
+
+
+def _parse(path: str, content: str, language: str) -> Dict[str, Any]:
+    if language == "go":
+        return parse_go_source(content, path.endswith("_test.go"), os.path.basename(path))
+    if language == "python":
+        return parse_py_source(content, *module_name(path))
+    if language == "rust":
+        return parse_rs_source(content)
+    if language == "java":
+        return parse_java_source(content)
+    if language == "proto":
+        return parse_proto_source(content)
+    if language == "sql":
+        return parse_sql_source(content)
+    if language == "make":
+        return parse_makefile(content)
+    if language == "shell":
+        return parse_shell_source(content)
+    if language not in ("typescript", "javascript"):
+        language = source_language(path)
+    return parse_ts_source(content, language, allows_jsx(path))
+
+
+def parse_file(path: str, known_hash: Optional[str] = None, partial_size: Optional[int] = None,
+               shallow: bool = False, language: Optional[str] = None) -> Dict[str, Any]:
+    """
+    Read, hash and parse one Go, TypeScript, JavaScript, Python, Rust, Java, .proto, .sql, Makefile or shell file,
+    as language if given; one larger than partial_size bytes only partially, one not in UTF-8
+    transcoded (see xray.core.source_text). A shallow file is parsed
+    partially whatever its size, its result marked "shallow": true, for a
+    quick index to parse it in full later.
+
+    Returns:
+        {"path", "stamp", "hash", "lines", "parsed"} - parsed is None when
+        the content hash equals known_hash - or {"path", "error", "code"} if
+        the file could not be read or parsed (code from xray.core.errors)
+    """
+    try:
+        stat = os.stat(path)
+        content, encoding = read_source(path)
+        digest = content_hash(content, encoding)
+        partial = shallow or (partial_size is not None and stat.st_size > partial_size)
+        parsed = None if digest == known_hash else parse_source(path, content, partial, language)
+        if parsed is not None and shallow and parsed.get("partial"):
+            parsed["shallow"] = True
+        if parsed is not None and encoding:
+            parsed["transcodedFrom"] = encoding
+        return {"path": path, "stamp": (stat.st_mtime_ns, stat.st_size), "hash": digest,
+                "lines": len(source_lines(content)), "parsed": parsed}
+    except Exception as e:
+        return {"path": path, "error": str(e), "code": error_code(e)}
+
+
+def _parse_batch(jobs: List[Tuple[str, Optional[str]]], partial_size: Optional[int] = None,
+                 shallow: FrozenSet[str] = frozenset(),
+                 languages: Optional[Dict[str, str]] = None) -> List[Dict[str, Any]]:
+    languages = languages or {}
+    return [parse_file(path, known_hash, partial_size, path in shallow, languages.get(path))
+            for path, known_hash in jobs]
+
+
+def parse_files(jobs: List[Tuple[str, Optional[str]]], concurrency: int,
+                cancel: Optional[threading.Event] = None,
+                progress: Optional[Callable[[int, int, str], None]] = None,
+                partial_size: Optional[int] = None,
+                shallow: FrozenSet[str] = frozenset(),
+                languages: Optional[Dict[str, str]] = None) -> Dict[str, Dict[str, Any]]:
+    """
+    Parse (path, known_hash) jobs, in parallel when there are enough of them;
+    files larger than partial_size bytes, and the shallow paths, are parsed
+    partially, and the paths in languages as the language given there rather
+    than their extension's.
+
+    A failure in one file is reported in its result and never affects the
+    others; nor does a worker process dying, its files being parsed in this
+    process instead. Setting the cancel event stops the workers and raises
+    IndexingCancelled. progress, if given, is called as (done, total, path)
+    for every result as it comes in.
+    """
+    def check():
+        if cancel is not None and cancel.is_set():
+            raise IndexingCancelled("indexing was cancelled")
+
+    results: Dict[str, Dict[str, Any]] = {}
+    languages = languages or {}
+    if concurrency <= 1 or len(jobs) < MIN_PARALLEL_FILES:
+        for path, known_hash in jobs:
+            check()
+            results[path] = parse_file(path, known_hash, partial_size, path in shallow, languages.get(path))
+            if progress:
+                progress(len(results), len(jobs), path)
+        return results
+
+    batches = [jobs[i:i + BATCH_SIZE] for i in range(0, len(jobs), BATCH_SIZE)]
+    # spawn rather than fork: the server runs tools on threads, and forking a
+    # threaded process can deadlock the child
+    executor = ProcessPoolExecutor(max_workers=min(concurrency, len(batches)),
+                                   mp_context=multiprocessing.get_context("spawn"))
+    try:
+        pending = {executor.submit(_parse_batch, batch, partial_size, frozenset(p for p, _ in batch if p in shallow),
+                                   {p: languages[p] for p, _ in batch if p in languages}): batch
+                   for batch in batches}
+        while pending:
+            done, _ = wait(pending, timeout=0.1, return_when=FIRST_COMPLETED)
+            check()
+            for future in done:
+                batch = pending.pop(future)
+                try:
+                    batch_results = future.result()
+                except BrokenProcessPool:
+                    # A worker died (killed, out of memory): the pool is gone,
+                    # and this batch's files are parsed here one by one instead
+                    batch_results = []
+                    for path, known_hash in batch:
+                        check()
+                        batch_results.append(parse_file(path, known_hash, partial_size, path in shallow,
+                                                        languages.get(path)))
+                for result in batch_results:
+                    results[result["path"]] = result
+                    if progress:
+                        progress(len(results), len(jobs), result["path"])
+    except BaseException:
+        executor.shutdown(wait=False, cancel_futures=True)
+        raise
+    executor.shutdown()
+    return results
@@ -0,0 +1,234 @@
+"""Binary files, and declaration skeletons of source files too large to parse in full.
+
+A file whose first 8000 bytes hold a NUL byte is binary, the test git uses
+(UTF-16 text starting with a byte order mark aside, see
+xray.core.source_text): it is skipped rather than read as source, whatever
+its name.
+
+A source file larger than the partial threshold (the partial_file_size
+setting) is not parsed as it is. A line scan first reduces it to a skeleton
+with the same line numbers - blank lines where bodies were - and the
+language's parser reads that instead:
+
+    Go, Rust, TS/JS  the contents of every multi-line {...} or [...] at the
+                     top level are blanked: function bodies, composite and
+                     array literals; struct and interface bodies (in Rust
+                     also enum, trait and union, in TS interface and enum)
+                     are kept, raw and template strings emptied
+    Python           top-level and class-level def, class, import and
+                     decorator lines are kept, function bodies become
+                     "...", multi-line assignments "NAME = ..."
+
+So the symbols of such a file are its declarations with their signatures
+and line ranges, but calls, references and other body-level facts are
+missing; its parse result, and every tool result location in it, is marked
+"partial": true. The size metrics of bodies (BODY_METRICS: loc, statements,
+complexity...) would be those of the blanked skeleton, so they are left out:
+the metrics tool does not rank such functions, and exports leave the cells
+empty. A quick index (see XRayIndexer.deepen) starts from the
+skeleton of every file, whatever its size, until its package is parsed in
+full. .proto and .sql files hold nothing but declarations, and
+Makefiles and shell scripts have no bodies a skeleton could drop, so these
+are always parsed in full; Java files are too, except that their method
+bodies are not scanned for local and anonymous classes.
+"""
+
+import re
+from typing import Any, Dict, List, Optional
+
+from xray.core.source_text import UTF16_BOMS
+
+# Bytes sniffed for a NUL
+BINARY_SNIFF = 8000
+# Files larger than this many bytes are parsed from their skeleton
+PARTIAL_FILE_SIZE = 2_000_000
+# Languages parsed in full whatever their size
+WHOLE_FILE_LANGUAGES = ("proto", "sql", "make", "shell")
+# Symbol metrics measured on bodies, which a skeleton has blanked (see xray.core.go_metrics)
+BODY_METRICS = ("complexity", "loc", "statements", "max_nesting", "distinct_callees")
+
+_BRACE_TOKENS = {
+    "go": re.compile(r'//[^\n]*|/\*.*?(?:\*/|$)|"(?:\\.|[^"\\\n])*"?|\'(?:\\.|[^\'\\\n])*\'?|`[^`]*`?|[{}\[\]\n]',
+                     re.S),
+    "rust": re.compile(r'//[^\n]*|/\*.*?(?:\*/|$)|r(#*)".*?(?:"\1|$)|b?"(?:\\.|[^"\\])*"?|'
+                       r"b?'(?:\\.|[^'\\\n])'|[{}\[\]\n]", re.S),
+    "typescript": re.compile(r'//[^\n]*|/\*.*?(?:\*/|$)|"(?:\\.|[^"\\\n])*"?|\'(?:\\.|[^\'\\\n])*\'?|'
+                             r'`(?:\\.|[^`\\])*`?|[{}\[\]\n]', re.S),
+}
+_BRACE_TOKENS["javascript"] = _BRACE_TOKENS["typescript"]
+
+# A body opened after text matching this (from the start of its line) is kept whole
+_KEPT_BODIES = {
+    "go": re.compile(r"\b(struct|interface)\s*$"),
+    "rust": re.compile(r"^\s*(pub(\([^)]*\))?\s+)?(struct|enum|trait|union)\b"),
+    "typescript": re.compile(r"^\s*(export\s+)?(declare\s+)?(const\s+)?(interface|enum)\b"),
+}
+_KEPT_BODIES["javascript"] = _KEPT_BODIES["typescript"]
+
+_CLOSING = {"{": "}", "[": "]"}
+_OPENING_QUOTE = re.compile(r'r#*"|b?["\']|[`"\']')
+
+_PY_TOKENS = re.compile(r'#|[rbuf]*("""|\'\'\')|[rbuf]*"(?:\\.|[^"\\\n])*"?|[rbuf]*\'(?:\\.|[^\'\\\n])*\'?|'
+                        r'[()\[\]{}]', re.I)
+_PY_DECLARATION = re.compile(r"(async\s+def|def|class|import|from)\b|@")
+_PY_ASSIGNMENT = re.compile(r"[A-Za-z_]\w*\s*(:[^=]+)?(=(?!=)|$)")
+_PY_COMMENT = re.compile(r"\s*#[^'\"]*$")
+
+
+def is_binary(path: str) -> bool:
+    """Whether a file holds a NUL byte in its first BINARY_SNIFF bytes (UTF-16 text with a BOM aside)."""
+    try:
+        with open(path, "rb") as f:
+            head = f.read(BINARY_SNIFF)
+    except OSError:
+        return False
+    return b"\0" in head and not head.startswith(tuple(UTF16_BOMS))
+
+
+def _blank(text: str) -> str:
+    """Text reduced to its line breaks."""
+    return "\n" * text.count("\n")
+
+
+def _brace_skeleton(content: str, language: str) -> str:
+    tokens, kept = _BRACE_TOKENS[language], _KEPT_BODIES[language]
+    out: List[str] = []
+    # Where the text still to copy starts, and the bracket a blanked body opened with
+    copied, depth, opened = 0, 0, None
+    for match in tokens.finditer(content):
+        token, start = match.group(0), match.start()
+        if depth == 0 and token[0] in "`rb\"'" and "\n" in token:
+            # A multi-line string at the top level: keep its delimiters only
+            opening = _OPENING_QUOTE.match(token).group(0)
+            closing = '"' + "#" * (len(opening) - 2) if opening[0] == "r" else opening[-1]
+            out.append(content[copied:start] + opening + _blank(token) + closing)
+            copied = match.end()
+        elif token in "{[":
+            if depth == 0:
+                line_start = content.rfind("\n", 0, start) + 1
+                if kept.search(content[line_start:start]):
+                    continue
+                out.append(content[copied:match.end()])
+                copied, opened = match.end(), token
+            if opened:
+                depth += 1
+        elif token in "}]" and depth:
+            depth -= 1
+            if depth == 0:
+                body = content[copied:start]
+                # A bracket pair within one line, like map[string]int, stays as it is
+                out.append(_blank(body) if "\n" in body else body)
+                copied, opened = start, None
+    if depth:
+        # Unbalanced: close what is still open so the parser sees complete declarations
+        out.append(_blank(content[copied:]) + _CLOSING[opened])
+    else:
+        out.append(content[copied:])
+    return "".join(out)
+
+
+def _py_statement_end(lines: List[str], i: int) -> int:
+    """The last line of the logical line starting at lines[i]: where its brackets and strings close."""
+    depth, quote, end = 0, None, i
+    while True:
+        line, pos = lines[end], 0
+        while pos < len(line):
+            if quote:
+                close = line.find(quote, pos)
+                if close < 0:
+                    break
+                quote, pos = None, close + 3
+                continue
+            match = _PY_TOKENS.search(line, pos)
+            if not match or match.group(0) == "#":
+                break
+            pos = match.end()
+            if match.group(1):
+                quote = match.group(1)
+            elif match.group(0) in "([{":
+                depth += 1
+            elif match.group(0) in ")]}":
+                depth = max(0, depth - 1)
+        if (depth or quote or line.rstrip().endswith("\\")) and end + 1 < len(lines):
+            end += 1
+            continue
+        return end
+
+
+def _opens_block(line: str) -> bool:
+    return _PY_COMMENT.sub("", line).rstrip().endswith(":")
+
+
+def _with_body(line: str) -> str:
+    """A block header given "..." for a body, before its comment."""
+    return _PY_COMMENT.sub("", line).rstrip() + " ..."
+
+
+def _py_skeleton(content: str) -> str:
+    lines = content.split("\n")
+    out: List[str] = []
+    # Indentation of the class bodies whose members are kept, innermost last; a
+    # class header is remembered (by its output index) until a member follows it
+    classes: List[int] = []
+    open_class: Optional[int] = None
+    i = 0
+    while i < len(lines):
+        end = _py_statement_end(lines, i)
+        statement = lines[i:end + 1]
+        stripped = lines[i].strip()
+        indent = len(lines[i]) - len(lines[i].lstrip())
+        i = end + 1
+        blank = ["" for _ in statement]
+        if not stripped or stripped.startswith("#"):
+            out.extend(blank)
+            continue
+        while classes and indent < classes[-1]:
+            classes.pop()
+        if open_class is not None and (not classes or indent < classes[-1]):
+            out[open_class] = _with_body(out[open_class])
+            open_class = None
+        if indent != 0 and not (classes and indent == classes[-1]):
+            out.extend(blank)
+            continue
+        if _PY_DECLARATION.match(stripped):
+            kept = list(statement)
+            if stripped.startswith("class") and _opens_block(kept[-1]):
+                # Members follow indented past the class; the first line of code tells how far
+                following = next((line for line in lines[i:] if line.strip() and not line.strip().startswith("#")), "")
+                member_indent = len(following) - len(following.lstrip())
+                if member_indent > indent:
+                    classes.append(member_indent)
+            elif re.match(r"(async\s+)?def\b", stripped) and _opens_block(kept[-1]):
+                kept[-1] = _with_body(kept[-1])
+        elif _PY_ASSIGNMENT.match(stripped):
+            # A multi-line value (a large literal, typically) is left out
+            kept = [" " * indent + _PY_ASSIGNMENT.match(stripped).group(0).rstrip("= ") + " = ..."] + blank[1:] \
+                if len(statement) > 1 else list(statement)
+        else:
+            # Expressions and control flow: no declarations of their own
+            out.extend(blank)
+            continue
+        open_class = None
+        out.extend(kept)
+        if stripped.startswith("class") and classes and _opens_block(kept[-1]) and classes[-1] > indent:
+            open_class = len(out) - 1
+    if open_class is not None:
+        out[open_class] = _with_body(out[open_class])
+    return "\n".join(out)
+
+
+def skeleton(content: str, language: str) -> str:
+    """The declaration skeleton of a source file, line for line (see the module docstring)."""
+    if language == "python":
+        return _py_skeleton(content)
+    if language in _BRACE_TOKENS:
+        return _brace_skeleton(content, language)
+    return content
+
+
+def drop_body_metrics(parsed: Dict[str, Any]) -> Dict[str, Any]:
+    """A partial parse result without the body metrics its emptied bodies would misstate."""
+    for symbol in parsed.get("symbols", []) + parsed.get("nested", []):
+        for metric in BODY_METRICS:
+            symbol.pop(metric, None)
+    return parsed
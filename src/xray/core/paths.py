@@ -0,0 +1,86 @@
+"""Paths the same on every platform: forward slashes and the casing on disk.
+
+Relative paths - package directories, glob matching, skip lists - always
+use forward slashes, and on Windows so do the absolute paths of tool
+results, so a path copied from one result into a glob or another call means
+the same file on every platform.
+
+On a case-insensitive filesystem (the default on macOS and Windows)
+"Src/Main.go" and "src/main.go" name one file; a path given with casing
+other than the file's own is mapped to the casing on disk, so the file has
+one index entry, not one per spelling.
+"""
+
+import os
+from functools import lru_cache
+from pathlib import Path
+from typing import Any, Union
+
+PathLike = Union[str, Path]
+
+
+def posix(path: PathLike) -> str:
+    """A path with forward slashes."""
+    return str(path).replace(os.sep, "/") if os.sep != "/" else str(path)
+
+
+def relative(path: PathLike, root: PathLike) -> str:
+    """A path relative to root, with forward slashes."""
+    return posix(os.path.relpath(path, root))
+
+
+@lru_cache(maxsize=256)
+def case_insensitive(directory: str) -> bool:
+    """Whether the filesystem holding an existing directory ignores case in names."""
+    path = Path(directory)
+    for candidate in [path, *path.parents]:
+        name = candidate.name
+        if name.swapcase() != name:
+            flipped = candidate.with_name(name.swapcase())
+            try:
+                return flipped.exists() and os.path.samefile(flipped, candidate)
+            except OSError:
+                return False
+    # No cased letter anywhere in the path to try; go by the platform's default
+    return os.name == "nt"
+
+
+def canonical_case(path: PathLike) -> Path:
+    """
+    An absolute path with each component spelled as on disk, when the
+    filesystem ignores case; the path as it is otherwise, or where a
+    component does not exist.
+    """
+    path = Path(path)
+    if not path.is_absolute() or not case_insensitive(str(path if path.is_dir() else path.parent)):
+        return path
+    current = Path(path.anchor)
+    for part in path.parts[1:]:
+        if not (current / part).exists():
+            return path
+        try:
+            entries = os.listdir(current)
+        except OSError:
+            return path
+        if part not in entries:
+            part = next((entry for entry in entries if entry.casefold() == part.casefold()), part)
+        current = current / part
+    return current
+
+
+def posix_paths(result: Any, prefix: str) -> Any:
+    """Rewrite, in place, every string of a result starting with prefix to forward slashes."""
+    if os.sep == "/":
+        return result
+
+    def rewrite(value: Any) -> Any:
+        if isinstance(value, str):
+            return posix(value) if value.startswith(prefix) else value
+        if isinstance(value, list):
+            value[:] = [rewrite(item) for item in value]
+        elif isinstance(value, dict):
+            for key in value:
+                value[key] = rewrite(value[key])
+        return value
+
+    return rewrite(result)
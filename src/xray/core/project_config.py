@@ -0,0 +1,352 @@
+"""Per-repository analysis settings: .xray.yaml (or .xray.yml, .xray.json) at the project root.
+
+The file is committed with the code so everyone analyzing the repository
+indexes the same files the same way:
+
+    exclude            globs left out of the index (doublestar syntax, see
+                       ignore.PathGlobs), e.g. ["docs/**", "**/*_mock.go"]
+    include_generated  default of the tools' include_generated
+    include_tests      default of the tools' include_tests
+    max_file_size      skip source files larger than this: bytes, or a string
+                       such as "512KB" or "2MB"
+    partial_file_size  parse source files larger than this (default "2MB")
+                       for their declarations only, see xray.core.partial
+    languages          {language: false} turns a language off, e.g. {"rust": false}
+    extensions         file names and extensions mapped to a language, e.g.
+                       {".bzl": starlark, ".gotmpl": gotemplate, Tiltfile:
+                       starlark}; see xray.core.languages
+    generated_headers  regular expressions; a file with a leading comment line
+                       matching one counts as generated, like Go's
+                       "// Code generated ... DO NOT EDIT." marker
+    description_length characters of a package description (its package
+                       comment, else its README's first paragraph) kept in
+                       listings (default 200)
+    max_memory_mb      keep the index within this many MiB by re-parsing the
+                       function bodies of its largest Go packages on demand,
+                       see xray.core.memory (default: no limit)
+    rules              house rules run_rules checks: calls, imports and type
+                       assertions forbidden or required where they match,
+                       one mapping per rule, see xray.core.go_rules
+
+A parameter passed to a tool call wins over the file, the file over the
+built-in default. The file is read from the working tree whenever the index
+is refreshed, also for analyses of a git ref, so editing it takes effect on
+the next call. Unknown keys and malformed values are errors naming the key
+(and the closest known one) rather than being ignored.
+
+YAML files are read without a YAML library, so only what such a file needs
+is understood: top-level "key: value" pairs, flow lists ([a, b]) and maps
+({rust: false}), block lists ("- item", a rule as "- {id: no-unsafe,
+import: unsafe}") and one level of nested mapping, quoted or plain scalars
+and # comments. Anything else - anchors, multi-line strings, deeper
+nesting - is reported as an error; .xray.json takes the same settings as a
+JSON object.
+"""
+
+import difflib
+import json
+import re
+from pathlib import Path
+from typing import Any, Dict, List, Optional, Tuple
+
+from xray.core.go_rules import parse_rules
+from xray.core.languages import valid_key, valid_language
+
+# Looked for in this order; the first one present is used
+CONFIG_FILES = (".xray.yaml", ".xray.yml", ".xray.json")
+
+# Setting -> the kind of value it takes, as error messages describe it
+SETTINGS = {
+    "exclude": "a list of glob strings",
+    "include_generated": "true or false",
+    "include_tests": "true or false",
+    "max_file_size": 'a number of bytes or a size such as "512KB" or "2MB"',
+    "partial_file_size": 'a number of bytes or a size such as "512KB" or "2MB"',
+    "languages": "a mapping of language name to true or false",
+    "extensions": 'a mapping of extension (".bzl") or file name to a lowercase language name',
+    "generated_headers": "a list of regular expressions",
+    "description_length": "a positive number of characters",
+    "max_memory_mb": "a positive number of megabytes",
+    "rules": "a list of rules, one mapping each",
+}
+
+_SIZE = re.compile(r"^\s*(\d+)\s*([KMG]i?B|B)?\s*$", re.IGNORECASE)
+_UNITS = {"b": 1, "kb": 1000, "mb": 1000 ** 2, "gb": 1000 ** 3, "kib": 1024, "mib": 1024 ** 2, "gib": 1024 ** 3}
+
+
+def _strip_comment(line: str) -> str:
+    """A line without its # comment (one at the start or after whitespace, outside quotes)."""
+    quote = None
+    for i, char in enumerate(line):
+        if quote:
+            if char == quote:
+                quote = None
+        elif char in "'\"":
+            quote = char
+        elif char == "#" and (i == 0 or line[i - 1] in " \t"):
+            return line[:i]
+    return line
+
+
+def _split_flow(body: str, number: int) -> List[str]:
+    """The comma-separated items of a flow list or map body."""
+    items, quote, depth, start = [], None, 0, 0
+    for i, char in enumerate(body):
+        if quote:
+            if char == quote:
+                quote = None
+        elif char in "'\"":
+            quote = char
+        elif char in "[{":
+            depth += 1
+        elif char in "]}":
+            depth -= 1
+        elif char == "," and depth == 0:
+            items.append(body[start:i])
+            start = i + 1
+    if depth or quote:
+        raise ValueError(f"line {number}: unbalanced brackets or quotes")
+    items.append(body[start:])
+    return [item.strip() for item in items if item.strip()]
+
+
+def _scalar(text: str, number: int) -> Any:
+    text = text.strip()
+    if text in ("", "~", "null"):
+        return None
+    if text[0] in "[{":
+        closing = "]" if text[0] == "[" else "}"
+        if not text.endswith(closing):
+            raise ValueError(f"line {number}: '{text[0]}' is not closed on the same line")
+        items = _split_flow(text[1:-1], number)
+        if closing == "]":
+            return [_scalar(item, number) for item in items]
+        mapping = {}
+        for item in items:
+            key, sep, value = item.partition(":")
+            if not sep:
+                raise ValueError(f"line {number}: expected 'key: value' in '{item}'")
+            mapping[str(_scalar(key, number))] = _scalar(value, number)
+        return mapping
+    if text[0] == '"':
+        try:
+            return json.loads(text)
+        except ValueError:
+            raise ValueError(f"line {number}: malformed double-quoted string {text}")
+    if text[0] == "'":
+        if len(text) < 2 or not text.endswith("'"):
+            raise ValueError(f"line {number}: malformed single-quoted string {text}")
+        return text[1:-1].replace("''", "'")
+    if text[0] in "&*!|>":
+        raise ValueError(f"line {number}: YAML anchors, tags and block scalars are not supported")
+    lowered = text.lower()
+    if lowered in ("true", "yes", "on"):
+        return True
+    if lowered in ("false", "no", "off"):
+        return False
+    if re.fullmatch(r"-?\d+", text):
+        return int(text)
+    return text
+
+
+def parse_yaml(text: str) -> Dict[str, Any]:
+    """The settings of a YAML file in the subset described above; ValueError names the bad line."""
+    result: Dict[str, Any] = {}
+    current: Optional[str] = None
+    for number, raw in enumerate(text.splitlines(), 1):
+        line = _strip_comment(raw).rstrip()
+        body = line.strip()
+        if not body or body in ("---", "..."):
+            continue
+        indent = len(line) - len(line.lstrip(" \t"))
+        if "\t" in line[:indent]:
+            raise ValueError(f"line {number}: indent with spaces, not tabs")
+        if indent == 0:
+            key, sep, value = body.partition(":")
+            if not sep or not key.strip():
+                raise ValueError(f"line {number}: expected 'key: value'")
+            key = str(_scalar(key, number))
+            if key in result:
+                raise ValueError(f"line {number}: '{key}' is set twice")
+            result[key] = _scalar(value, number)
+            current = key if not value.strip() else None
+            continue
+        if current is None:
+            raise ValueError(f"line {number}: unexpected indentation")
+        if body == "-" or body.startswith("- "):
+            if result[current] is None:
+                result[current] = []
+            if not isinstance(result[current], list):
+                raise ValueError(f"line {number}: list item inside the mapping '{current}'")
+            result[current].append(_scalar(body[1:], number))
+            continue
+        key, sep, value = body.partition(":")
+        if not sep or not value.strip():
+            raise ValueError(f"line {number}: expected '- item' or 'key: value' under '{current}'")
+        if result[current] is None:
+            result[current] = {}
+        if not isinstance(result[current], dict):
+            raise ValueError(f"line {number}: 'key: value' inside the list '{current}'")
+        result[current][str(_scalar(key, number))] = _scalar(value, number)
+    return result
+
+
+def _size(value: Any) -> Optional[int]:
+    if isinstance(value, bool):
+        return None
+    if isinstance(value, int):
+        return value if value > 0 else None
+    match = _SIZE.match(value) if isinstance(value, str) else None
+    if not match:
+        return None
+    size = int(match.group(1)) * _UNITS[(match.group(2) or "b").lower()]
+    return size if size > 0 else None
+
+
+def _strings(value: Any) -> bool:
+    return isinstance(value, list) and all(isinstance(item, str) and item for item in value)
+
+
+def validate(data: Any, languages: List[str]) -> Tuple[Dict[str, Any], List[str]]:
+    """
+    The usable settings of a parsed file and the problems with the rest.
+
+    max_file_size and partial_file_size come back in bytes and generated_headers compiled; a
+    setting with a malformed value is left out.
+    """
+    if not isinstance(data, dict):
+        return {}, ["the file must hold a mapping of settings"]
+    settings: Dict[str, Any] = {}
+    errors = []
+    # Languages the file maps files to can be turned off like the built-in ones
+    mapped = data.get("extensions")
+    if isinstance(mapped, dict):
+        languages = list(languages) + [v for v in mapped.values() if isinstance(v, str) and valid_language(v)]
+    for key, value in data.items():
+        if key not in SETTINGS:
+            close = difflib.get_close_matches(key, SETTINGS, 1, 0.6) or \
+                difflib.get_close_matches(re.sub(r"(?<!^)(?=[A-Z])", "_", key).lower(), SETTINGS, 1, 0.6)
+            hint = f"did you mean '{close[0]}'?" if close else f"known keys: {', '.join(SETTINGS)}"
+            errors.append(f"unknown key '{key}' - {hint}")
+            continue
+        problem = None
+        if key == "exclude":
+            if _strings(value):
+                settings[key] = value
+            else:
+                problem = SETTINGS[key]
+        elif key in ("include_generated", "include_tests"):
+            if isinstance(value, bool):
+                settings[key] = value
+            else:
+                problem = SETTINGS[key]
+        elif key in ("max_file_size", "partial_file_size"):
+            size = _size(value)
+            if size is None:
+                problem = SETTINGS[key]
+            else:
+                settings[key] = size
+        elif key in ("description_length", "max_memory_mb"):
+            if isinstance(value, int) and not isinstance(value, bool) and value > 0:
+                settings[key] = value
+            else:
+                problem = SETTINGS[key]
+        elif key == "languages":
+            if not isinstance(value, dict) or not all(isinstance(v, bool) for v in value.values()):
+                problem = SETTINGS[key]
+            else:
+                unknown = sorted(set(value) - set(languages))
+                if unknown:
+                    errors.append(f"languages: unknown language {', '.join(repr(u) for u in unknown)} - "
+                                  f"known: {', '.join(sorted(languages))}")
+                settings[key] = {k: v for k, v in value.items() if k in languages}
+        elif key == "extensions":
+            if not isinstance(value, dict) or not all(isinstance(v, str) for v in value.values()):
+                problem = SETTINGS[key]
+            else:
+                bad = [k for k, v in value.items() if not valid_key(k) or not valid_language(v)]
+                for k in bad:
+                    errors.append(f"extensions: '{k}: {value[k]}' - expected an extension (\".bzl\") or file "
+                                  f"name mapped to a lowercase language name")
+                settings[key] = {k: v for k, v in value.items() if k not in bad}
+        elif key == "generated_headers":
+            if not _strings(value):
+                problem = SETTINGS[key]
+            else:
+                compiled = []
+                for i, pattern in enumerate(value):
+                    try:
+                        compiled.append(re.compile(pattern))
+                    except re.error as e:
+                        errors.append(f"generated_headers[{i}]: invalid regular expression {pattern!r}: {e}")
+                settings[key] = compiled
+        elif key == "rules":
+            rules, problems = parse_rules(value)
+            errors.extend(problems)
+            settings[key] = rules
+        if problem:
+            errors.append(f"{key}: expected {problem}, got {json.dumps(value, default=str)}")
+    return settings, errors
+
+
+class ProjectConfig:
+    """The settings of one project's configuration file (none, when it has no file)."""
+
+    def __init__(self, path: Optional[str] = None, settings: Optional[Dict[str, Any]] = None,
+                 errors: Optional[List[str]] = None, stamp: Optional[Tuple[int, int]] = None):
+        self.path = path
+        self.settings = settings or {}
+        self.errors = errors or []
+        # (mtime_ns, size) of the file when read, None without one
+        self.stamp = stamp
+
+    def get(self, key: str, default: Any = None) -> Any:
+        return self.settings.get(key, default)
+
+    def language_enabled(self, language: Optional[str]) -> bool:
+        return self.settings.get("languages", {}).get(language, True)
+
+    def describe(self) -> Dict[str, Any]:
+        """The file's settings as JSON: generated_headers back to their pattern text."""
+        described = dict(self.settings)
+        if "generated_headers" in described:
+            described["generated_headers"] = [p.pattern for p in described["generated_headers"]]
+        return described
+
+
+def _stamp(path: Path) -> Optional[Tuple[int, int]]:
+    try:
+        stat = path.stat()
+    except OSError:
+        return None
+    return stat.st_mtime_ns, stat.st_size
+
+
+def config_file(root: Path) -> Optional[Path]:
+    """The configuration file of a project root, None without one."""
+    return next((Path(root) / name for name in CONFIG_FILES if (Path(root) / name).is_file()), None)
+
+
+def load_config(root: Path, languages: List[str], previous: Optional[ProjectConfig] = None) -> ProjectConfig:
+    """
+    Read a project's configuration, or reuse previous when the file has not
+    changed since it was read. Problems are collected, not raised.
+    """
+    path = config_file(root)
+    if path is None:
+        return previous if previous is not None and previous.path is None else ProjectConfig()
+    stamp = _stamp(path)
+    if previous is not None and previous.path == str(path) and previous.stamp == stamp:
+        return previous
+    try:
+        text = path.read_text(encoding="utf-8")
+    except (OSError, UnicodeDecodeError) as e:
+        return ProjectConfig(str(path), errors=[f"cannot be read: {e}"], stamp=stamp)
+    try:
+        data = json.loads(text) if path.suffix == ".json" else parse_yaml(text)
+    except ValueError as e:
+        return ProjectConfig(str(path), errors=[f"invalid {'JSON' if path.suffix == '.json' else 'YAML'}: {e}"],
+                             stamp=stamp)
+    settings, errors = validate(data, languages)
+    return ProjectConfig(str(path), settings, errors, stamp)
+
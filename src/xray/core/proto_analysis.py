@@ -0,0 +1,353 @@
+"""Cross-file view of the Protocol Buffers definitions of a project, linked to their generated Go.
+
+Type references inside .proto files resolve the way protoc resolves them:
+a leading dot names a fully qualified type, anything else is looked up
+from the innermost enclosing message outwards through the package.
+
+Generated Go is found through the `// source: x.proto` header protoc-gen-go
+and protoc-gen-go-grpc write into every .pb.go file, falling back to the
+go_package option mapped onto a package directory. Go names follow the
+protogen conventions: definitions are CamelCased with nested names joined
+by underscores (User.Address -> User_Address), enum values are prefixed
+with the enclosing message or enum (User_ACTIVE), and a service S yields
+the SServer and SClient interfaces, UnimplementedSServer and
+RegisterSServer. The implementations of a service are the types that
+satisfy its SServer interface; methods they only inherit from the embedded
+UnimplementedSServer are reported as not implemented.
+"""
+
+import os
+import re
+from typing import Any, Dict, Iterator, List, Optional, Tuple
+
+from xray.core.go_analysis import GoProject
+
+# Definitions other files can refer to by name
+TYPE_KINDS = ("message", "enum", "service")
+
+_SOURCE_HEADER = re.compile(r"^// source: (\S+\.proto)\s*$")
+# Generated headers stop at the package clause
+_PACKAGE_CLAUSE = re.compile(r"^package\s")
+
+
+def go_camel_case(name: str) -> str:
+    """protogen.GoCamelCase: "user_id" -> "UserId", "User.Address" -> "User_Address", "_x" -> "X_x"."""
+    out = []
+    i, n = 0, len(name)
+    while i < n:
+        ch = name[i]
+        if ch == "." and i + 1 < n and name[i + 1].islower():
+            pass
+        elif ch == ".":
+            out.append("_")
+        elif ch == "_" and (i == 0 or name[i - 1] == "."):
+            out.append("X")
+        elif ch == "_" and i + 1 < n and name[i + 1].islower():
+            pass
+        elif ch.isdigit():
+            out.append(ch)
+        else:
+            out.append(ch.upper() if "a" <= ch <= "z" else ch)
+            while i + 1 < n and "a" <= name[i + 1] <= "z":
+                i += 1
+                out.append(name[i])
+        i += 1
+    return "".join(out)
+
+
+def go_package(parsed: Dict[str, Any], path: str) -> Dict[str, Optional[str]]:
+    """
+    The Go package a .proto file generates into, from its go_package option
+    ("example.com/api/v1;apiv1" -> import path and name); without the
+    option only a name derived from the proto package or file is known.
+    """
+    option = parsed.get("options", {}).get("go_package")
+    if isinstance(option, str) and option:
+        import_path, _, name = option.partition(";")
+        name = name or import_path.rstrip("/").rsplit("/", 1)[-1]
+        return {"import_path": import_path, "name": re.sub(r"\W", "_", name)}
+    base = parsed.get("package") or os.path.splitext(os.path.basename(path))[0]
+    return {"import_path": None, "name": re.sub(r"\W", "_", base.replace(".", "_"))}
+
+
+def generated_source(path: str) -> Optional[str]:
+    """The .proto file a generated Go file names in its header, as given to protoc."""
+    try:
+        with open(path, "r", encoding="utf-8") as f:
+            for line in f:
+                if _PACKAGE_CLAUSE.match(line):
+                    break
+                match = _SOURCE_HEADER.match(line.rstrip("\n"))
+                if match:
+                    return match.group(1)
+    except (OSError, UnicodeDecodeError):
+        pass
+    return None
+
+
+class ProtoProject:
+    """The parsed .proto files of a project and, once linked, the Go generated from them."""
+
+    def __init__(self, files: Dict[str, Dict[str, Any]], root: str):
+        self.files = files
+        self.root = root
+        # Fully qualified name -> (path, definition) for messages, enums and services
+        self.definitions: Dict[str, Tuple[str, Dict[str, Any]]] = {}
+        self.packages = set()
+        for path in sorted(files):
+            package = files[path].get("package", "")
+            parts = package.split(".") if package else []
+            for i in range(1, len(parts) + 1):
+                self.packages.add(".".join(parts[:i]))
+            for symbol in files[path]["symbols"]:
+                if symbol["type"] in TYPE_KINDS:
+                    self.definitions.setdefault(symbol["full_name"], (path, symbol))
+        self.go: Optional[GoProject] = None
+        # .proto path -> Go package dir of its generated code
+        self.go_dirs: Dict[str, str] = {}
+        self.generated: Dict[str, Optional[str]] = {}
+
+    def symbols(self) -> Iterator[Tuple[str, Dict[str, Any]]]:
+        """(path, symbol) for every definition, files in sorted order."""
+        for path in sorted(self.files):
+            for symbol in self.files[path]["symbols"]:
+                yield path, symbol
+
+    def dotted_name(self, path: str) -> str:
+        """The proto package of a file ("acme.users.v1"), or its file name without one."""
+        return self.files[path].get("package") or os.path.basename(path)
+
+    def resolve(self, path: str, written: str, container: Optional[str] = None) -> Optional[str]:
+        """The fully qualified name a type reference written in path (inside container) stands for."""
+        if written.startswith("."):
+            return written[1:] if written[1:] in self.definitions else None
+        package = self.files[path].get("package", "")
+        scope = (package.split(".") if package else []) + (container.split(".") if container else [])
+        first = written.split(".", 1)[0]
+        for depth in range(len(scope), -1, -1):
+            prefix = ".".join(scope[:depth])
+            # protoc binds the first component, then looks the rest up inside it
+            head = f"{prefix}.{first}" if prefix else first
+            if head in self.definitions or head in self.packages:
+                full = f"{prefix}.{written}" if prefix else written
+                return full if full in self.definitions else None
+        return None
+
+    def go_name(self, symbol: Dict[str, Any]) -> str:
+        """The Go identifier protoc-gen-go gives a definition (fields: the struct field, rpcs: the method)."""
+        container = symbol.get("container")
+        if symbol["type"] in TYPE_KINDS:
+            return go_camel_case(f"{container}.{symbol['name']}" if container else symbol["name"])
+        if symbol["type"] == "enum_value":
+            # Prefixed with the enum's parent message, or the enum itself at top level
+            owner = container.rsplit(".", 1)[0] if container and "." in container else container
+            return f"{go_camel_case(owner)}_{symbol['name']}"
+        return go_camel_case(symbol["name"])
+
+    def go_identifiers(self, symbol: Dict[str, Any]) -> List[str]:
+        """Every Go identifier generated for a definition; a service adds its server, client and registration names."""
+        name = self.go_name(symbol)
+        if symbol["type"] != "service":
+            return [name]
+        return [name, f"{name}Server", f"{name}Client", f"Unimplemented{name}Server",
+                f"Register{name}Server", f"New{name}Client"]
+
+    def link(self, go: GoProject, generated: Dict[str, Optional[str]]):
+        """
+        Attach the Go project (generated files included) and work out the
+        package directory each .proto file generates into.
+
+        Args:
+            go: The Go packages, with the generated .pb.go files
+            generated: Generated Go file -> the .proto source its header names
+        """
+        self.go = go
+        self.generated = generated
+        self.go_dirs = {}
+        by_source: Dict[str, str] = {}
+        for go_path, source in sorted(generated.items()):
+            if source:
+                by_source.setdefault(source, os.path.dirname(go_path))
+        for path in sorted(self.files):
+            rel = os.path.relpath(path, self.root).replace(os.sep, "/")
+            found = None
+            # protoc names the file relative to its include path; the longest match wins
+            for source, go_dir in sorted(by_source.items(), key=lambda item: -len(item[0])):
+                if rel == source or rel.endswith("/" + source):
+                    found = go_dir
+                    break
+            if found is None:
+                import_path = go_package(self.files[path], path)["import_path"]
+                found = go.import_dir(import_path) if import_path else None
+            if found is not None:
+                self.go_dirs[path] = found
+
+    def _go_record(self, symbol: Optional[Dict[str, Any]]) -> Optional[Dict[str, Any]]:
+        if symbol is None:
+            return None
+        record = {"name": symbol["name"], "type": symbol["type"], "path": symbol["path"],
+                  "start_line": symbol["start_line"], "signature": symbol.get("signature")}
+        if symbol.get("receiver"):
+            record["receiver"] = symbol["receiver"]["type"]
+        elif symbol.get("container"):
+            record["container"] = symbol["container"]
+        return record
+
+    def _go_constant(self, go_dir: str, name: str) -> Optional[Dict[str, Any]]:
+        for path in self.go.packages.get(go_dir, {}).get("files", []):
+            for symbol in self.go.files[path]["symbols"]:
+                if symbol["type"] == "constant" and symbol["name"] == name:
+                    return {**symbol, "path": path}
+        return None
+
+    def go_link(self, path: str, symbol: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+        """
+        The generated Go declaration of a definition, or None when no
+        generated code was found. Services link their server and client
+        interfaces, rpcs the server interface method.
+        """
+        go_dir = self.go_dirs.get(path)
+        if self.go is None or go_dir is None:
+            return None
+        kind = symbol["type"]
+        name = self.go_name(symbol)
+        if kind in ("message", "enum"):
+            return self._go_record(self.go.types.get((go_dir, name)))
+        if kind == "service":
+            link = {
+                "server": self._go_record(self.go.types.get((go_dir, f"{name}Server"))),
+                "client": self._go_record(self.go.types.get((go_dir, f"{name}Client"))),
+                "unimplemented": self._go_record(self.go.types.get((go_dir, f"Unimplemented{name}Server"))),
+            }
+            return link if any(link.values()) else None
+        if kind == "rpc":
+            server = f"{go_camel_case(symbol['container'])}Server"
+            for method in self.go.interface_methods.get((go_dir, server), []):
+                if method["name"] == name:
+                    return self._go_record(method)
+            return None
+        if kind == "field":
+            owner = go_camel_case(symbol["container"])
+            if symbol.get("oneof"):
+                # Oneof members become fields of a wrapper struct Msg_Member
+                wrapper = self.go.fields.get((go_dir, f"{owner}_{name}"), [])
+                return next((self._go_record(f) for f in wrapper if f["name"] == name), None)
+            fields = self.go.fields.get((go_dir, owner), [])
+            return next((self._go_record(f) for f in fields if f["name"] == name), None)
+        if kind == "oneof":
+            fields = self.go.fields.get((go_dir, go_camel_case(symbol["container"])), [])
+            return next((self._go_record(f) for f in fields if f["name"] == name), None)
+        if kind == "enum_value":
+            return self._go_record(self._go_constant(go_dir, name))
+        return None
+
+    def _rpc_entry(self, path: str, rpc: Dict[str, Any]) -> Dict[str, Any]:
+        entry = {
+            "name": rpc["name"],
+            "go_name": self.go_name(rpc),
+            "line": rpc["start_line"],
+            "signature": rpc["signature"],
+            "request": self.resolve(path, rpc["request"], rpc["container"]) or rpc["request"].lstrip("."),
+            "response": self.resolve(path, rpc["response"], rpc["container"]) or rpc["response"].lstrip("."),
+            "client_streaming": rpc["client_streaming"],
+            "server_streaming": rpc["server_streaming"],
+        }
+        for key in ("doc", "http", "deprecated"):
+            if rpc.get(key):
+                entry[key] = rpc[key]
+        return entry
+
+    def _implementations(self, path: str, service: Dict[str, Any],
+                         rpcs: List[Dict[str, Any]]) -> Tuple[List[Dict[str, Any]], str]:
+        """Go types serving a service, and how they were found ("server_interface" or "method_names")."""
+        if self.go is None:
+            return [], "none"
+        name = self.go_name(service)
+        go_dir = self.go_dirs.get(path)
+        server = self.go.types.get((go_dir, f"{name}Server")) if go_dir else None
+        wanted = [rpc["go_name"] for rpc in rpcs]
+        candidates = []
+        if server is not None and server["type"] == "interface":
+            matched_by = "server_interface"
+            for match in self.go.implementations_of(server)["implementations"]:
+                candidates.append(((os.path.dirname(match["path"]), match["name"]), match["satisfied_by"]))
+        else:
+            # No generated code in the tree: fall back to types embedding the
+            # Unimplemented server or declaring every rpc as a method
+            matched_by = "method_names"
+            unimplemented = f"Unimplemented{name}Server"
+            for key, symbol in sorted(self.go.types.items()):
+                if symbol["type"] == "interface" or symbol.get("alias") or not wanted:
+                    continue
+                embeds = any(f.get("embedded") and f["field_type"].lstrip("*").rsplit(".", 1)[-1] == unimplemented
+                             for f in self.go.fields.get(key, []))
+                methods = self.go.concrete_method_set(*key, pointer=True)
+                if embeds or all(method in methods for method in wanted):
+                    candidates.append((key, symbol["name"]))
+
+        implementations = []
+        for key, satisfied_by in candidates:
+            symbol = self.go.types[key]
+            if symbol["path"] in self.generated:
+                continue
+            methods = self.go.concrete_method_set(*key, pointer=True)
+            implemented, unimplemented = {}, []
+            for method_name in wanted:
+                method = methods.get(method_name)
+                if method is None or (method.get("promoted_via") and method["path"] in self.generated):
+                    unimplemented.append(method_name)
+                else:
+                    implemented[method_name] = {"path": method["path"], "line": method["start_line"]}
+                    if method.get("promoted_via"):
+                        implemented[method_name]["promoted_via"] = method["promoted_via"]
+            entry = {
+                "name": key[1],
+                "package": symbol["package"],
+                "path": symbol["path"],
+                "start_line": symbol["start_line"],
+                "satisfied_by": satisfied_by,
+                "methods": implemented,
+            }
+            if unimplemented:
+                entry["unimplemented"] = unimplemented
+            implementations.append(entry)
+        return implementations, matched_by
+
+    def services(self, path: Optional[str] = None) -> List[Dict[str, Any]]:
+        """
+        Every service with its rpc methods, the generated Go it links to and
+        the Go types implementing it, optionally restricted to one .proto
+        file or the files under a directory.
+        """
+        results = []
+        for file_path in sorted(self.files):
+            if path and file_path != path and not file_path.startswith(path.rstrip(os.sep) + os.sep):
+                continue
+            symbols = self.files[file_path]["symbols"]
+            for service in (s for s in symbols if s["type"] == "service"):
+                rpcs = [self._rpc_entry(file_path, s) for s in symbols
+                        if s["type"] == "rpc" and s.get("container") == service["name"]]
+                implementations, matched_by = self._implementations(file_path, service, rpcs)
+                for rpc in rpcs:
+                    rpc["implementations"] = [
+                        {"type": impl["name"], **impl["methods"][rpc["go_name"]]}
+                        for impl in implementations if rpc["go_name"] in impl["methods"]
+                    ]
+                link = self.go_link(file_path, service)
+                entry = {
+                    "name": service["name"],
+                    "full_name": service["full_name"],
+                    "path": file_path,
+                    "line": service["start_line"],
+                    "go_package": go_package(self.files[file_path], file_path),
+                    "generated": link,
+                    "methods": rpcs,
+                    "implementations": implementations,
+                    "matched_by": matched_by,
+                }
+                if service.get("doc"):
+                    entry["doc"] = service["doc"]
+                if file_path in self.go_dirs:
+                    entry["go_package"]["package_dir"] = self.go_dirs[file_path]
+                results.append(entry)
+        return results
@@ -0,0 +1,669 @@
+"""Protocol Buffers parser for XRAY - tokenizer and definition scanner.
+
+Reads .proto files in proto2, proto3 and editions syntax: the package,
+file options (go_package among them), imports, messages with their fields,
+oneofs and nested types, enums and their values, services with their rpc
+methods, and `extend` blocks. Option values are kept when they are plain
+constants; aggregate values are only read for `(google.api.http)` rules.
+Comments directly above a definition, or trailing it on the same line,
+become its doc, the way protoc attaches them to descriptors.
+"""
+
+from typing import Any, Dict, List, Optional, Tuple
+
+# Bump whenever the shape of extracted records changes so cached results are discarded
+PROTO_PARSER_VERSION = 3
+
+# Longest signature text quoted from a definition
+MAX_SIGNATURE = 120
+
+LABELS = ("optional", "required", "repeated")
+
+# google.api.http rule keys that name an HTTP method
+HTTP_METHODS = ("get", "put", "post", "delete", "patch")
+
+
+class Token:
+    """A single .proto token with its position (1-based line, 0-based column)."""
+
+    __slots__ = ("kind", "value", "line", "col", "end_line")
+
+    def __init__(self, kind, value, line, col, end_line=None):
+        self.kind = kind
+        self.value = value
+        self.line = line
+        self.col = col
+        self.end_line = end_line or line
+
+    def __repr__(self):
+        return f"Token({self.kind}, {self.value!r}, {self.line}:{self.col})"
+
+
+def tokenize(src: str) -> Tuple[List[Token], List[Token]]:
+    """
+    Split .proto source into tokens.
+
+    Returns:
+        (tokens, comments) - comments come back as "comment" tokens whose
+        value is the text without the comment markers
+    """
+    tokens: List[Token] = []
+    comments: List[Token] = []
+    i, n = 0, len(src)
+    line, line_start = 1, 0
+    while i < n:
+        ch = src[i]
+        if ch == "\n":
+            line += 1
+            line_start = i + 1
+            i += 1
+            continue
+        if ch.isspace():
+            i += 1
+            continue
+        col = i - line_start
+        if src.startswith("//", i):
+            end = src.find("\n", i)
+            end = n if end == -1 else end
+            comments.append(Token("comment", src[i + 2:end].strip(), line, col))
+            i = end
+            continue
+        if src.startswith("/*", i):
+            end = src.find("*/", i + 2)
+            end = n if end == -1 else end + 2
+            text = src[i + 2:end - 2]
+            lines = [part.strip().lstrip("*").strip() for part in text.split("\n")]
+            start_line = line
+            line += text.count("\n")
+            if "\n" in text:
+                line_start = i + 2 + text.rfind("\n") + 1
+            comments.append(Token("comment", "\n".join(part for part in lines if part), start_line, col, line))
+            i = end
+            continue
+        if ch in "\"'":
+            j = i + 1
+            while j < n and src[j] != ch and src[j] != "\n":
+                j += 2 if src[j] == "\\" else 1
+            tokens.append(Token("string", src[i:j + 1], line, col))
+            i = j + 1
+            continue
+        if ch.isalpha() or ch == "_":
+            j = i + 1
+            while j < n and (src[j].isalnum() or src[j] == "_"):
+                j += 1
+            tokens.append(Token("ident", src[i:j], line, col))
+            i = j
+            continue
+        if ch.isdigit() or (ch == "." and i + 1 < n and src[i + 1].isdigit()):
+            j = i + 1
+            while j < n and (src[j].isalnum() or src[j] in "._" or (src[j] in "+-" and src[j - 1] in "eE")):
+                j += 1
+            tokens.append(Token("number", src[i:j], line, col))
+            i = j
+            continue
+        tokens.append(Token("punct", ch, line, col))
+        i += 1
+    return tokens, comments
+
+
+def unquote(literal: str) -> str:
+    """The text of a string literal without quotes, with the common escapes undone."""
+    body = literal[1:-1] if len(literal) >= 2 else literal
+    if "\\" not in body:
+        return body
+    return body.encode("latin-1", "backslashreplace").decode("unicode_escape")
+
+
+def _clip(text: str) -> str:
+    text = " ".join(text.split())
+    return text if len(text) <= MAX_SIGNATURE else text[:MAX_SIGNATURE - 3] + "..."
+
+
+class ProtoFileParser:
+    """Walks the tokens of one .proto file and collects its definitions."""
+
+    def __init__(self, src: str):
+        self.tokens, comments = tokenize(src)
+        self.pos = 0
+        self.result: Dict[str, Any] = {
+            "syntax": "proto2", "package": "", "options": {}, "imports": [], "symbols": [],
+        }
+        # Lines with a token on them, and each comment block by the line it ends on
+        self.token_lines = {t.line for t in self.tokens}
+        self.comments_by_end: Dict[int, Token] = {}
+        for comment in comments:
+            previous = self.comments_by_end.get(comment.line - 1)
+            if previous is not None and comment.line == comment.end_line and previous.line not in self.token_lines \
+                    and comment.line not in self.token_lines:
+                merged = Token("comment", previous.value + "\n" + comment.value, previous.line, previous.col, comment.end_line)
+                del self.comments_by_end[comment.line - 1]
+                self.comments_by_end[comment.end_line] = merged
+            else:
+                self.comments_by_end[comment.end_line] = comment
+        self.trailing = {c.line: c for c in comments if c.line == c.end_line}
+
+    # -- token helpers -------------------------------------------------------
+
+    def peek(self, offset: int = 0) -> Optional[Token]:
+        index = self.pos + offset
+        return self.tokens[index] if index < len(self.tokens) else None
+
+    def next(self) -> Optional[Token]:
+        token = self.peek()
+        if token is not None:
+            self.pos += 1
+        return token
+
+    def at(self, value: str, offset: int = 0) -> bool:
+        token = self.peek(offset)
+        return token is not None and token.value == value and token.kind in ("ident", "punct")
+
+    def accept(self, value: str) -> bool:
+        if self.at(value):
+            self.pos += 1
+            return True
+        return False
+
+    def skip_statement(self):
+        """Skip to the end of the current statement, or past its balanced block; stops before an enclosing `}`."""
+        depth = 0
+        while self.peek() is not None:
+            token = self.next()
+            if token.kind != "punct":
+                continue
+            if token.value in "{[(":
+                depth += 1
+            elif token.value in "}])":
+                if depth == 0 and token.value == "}":
+                    self.pos -= 1
+                    return
+                depth -= 1
+                if depth <= 0 and token.value == "}":
+                    return
+            elif token.value == ";" and depth <= 0:
+                return
+
+    def full_ident(self) -> str:
+        """A dotted name, possibly fully qualified (".pkg.Type")."""
+        parts = []
+        if self.accept("."):
+            parts.append("")
+        while self.peek() is not None and self.peek().kind == "ident":
+            parts.append(self.next().value)
+            if not (self.at(".") and self.peek(1) is not None and self.peek(1).kind == "ident"):
+                break
+            self.pos += 1
+        return ".".join(parts)
+
+    def option_name(self) -> str:
+        """`name`, `(custom.ext)` or `(custom.ext).field`."""
+        parts = []
+        while True:
+            if self.accept("("):
+                parts.append("(" + self.full_ident() + ")")
+                self.accept(")")
+            elif self.peek() is not None and self.peek().kind == "ident":
+                parts.append(self.next().value)
+            else:
+                break
+            if not self.accept("."):
+                break
+        return ".".join(parts)
+
+    def constant(self) -> Any:
+        """An option value: string, number, bool, identifier, or an aggregate `{...}` as a dict."""
+        if self.at("{"):
+            return self.aggregate()
+        sign = "-" if self.accept("-") else ""
+        self.accept("+")
+        token = self.next()
+        if token is None:
+            return None
+        if token.kind == "string":
+            value = unquote(token.value)
+            while self.peek() is not None and self.peek().kind == "string":
+                value += unquote(self.next().value)
+            return value
+        if token.kind == "number":
+            text = sign + token.value
+            try:
+                return int(text, 0)
+            except ValueError:
+                try:
+                    return float(text)
+                except ValueError:
+                    return text
+        if token.value in ("true", "false"):
+            return token.value == "true"
+        if token.kind == "ident":
+            self.pos -= 1
+            return sign + self.full_ident()
+        return token.value
+
+    def aggregate(self) -> Dict[str, Any]:
+        """A text-format message literal (`{ get: "/v1/x" body: "*" }`); repeated keys keep the first value."""
+        values: Dict[str, Any] = {}
+        self.accept("{")
+        while self.peek() is not None and not self.at("}"):
+            if self.peek().kind != "ident" and not self.at("["):
+                self.pos += 1
+                continue
+            if self.accept("["):
+                key = "[" + self.full_ident() + "]"
+                self.accept("]")
+            else:
+                key = self.next().value
+            self.accept(":")
+            value = self.constant() if not self.at("[") else self.list_value()
+            values.setdefault(key, value)
+            if not self.accept(","):
+                self.accept(";")
+        self.accept("}")
+        return values
+
+    def list_value(self) -> List[Any]:
+        items = []
+        self.accept("[")
+        while self.peek() is not None and not self.at("]"):
+            items.append(self.constant())
+            self.accept(",")
+        self.accept("]")
+        return items
+
+    def field_options(self) -> Dict[str, Any]:
+        """`[deprecated = true, json_name = "x"]` after a field or enum value."""
+        options: Dict[str, Any] = {}
+        if not self.accept("["):
+            return options
+        while self.peek() is not None and not self.at("]"):
+            name = self.option_name()
+            if not name or not self.accept("="):
+                self.pos += 1
+                continue
+            options[name] = self.constant()
+            self.accept(",")
+        self.accept("]")
+        return options
+
+    def doc_for(self, token: Token) -> str:
+        """The comment block ending on the line above token, else a comment trailing token's line."""
+        comment = self.comments_by_end.get(token.line - 1)
+        if comment is not None and comment.line not in self.token_lines:
+            return comment.value
+        trailing = self.trailing.get(token.line)
+        if trailing is not None and trailing.col > token.col:
+            return trailing.value
+        return ""
+
+    def end_line(self) -> int:
+        return self.tokens[self.pos - 1].end_line if self.pos else 1
+
+    # -- definitions ---------------------------------------------------------
+
+    def symbol(self, name_token: Token, kind: str, signature: str, container: List[str],
+               start: Token, **extra) -> Dict[str, Any]:
+        package = self.result["package"]
+        local = ".".join(container + [name_token.value])
+        record = {
+            "name": name_token.value,
+            "type": kind,
+            "signature": _clip(signature),
+            "start_line": start.line,
+            "column": name_token.col + 1,
+            "end_line": start.line,
+            "doc": self.doc_for(start),
+            "language": "proto",
+            "exported": True,
+            "full_name": f"{package}.{local}" if package else local,
+        }
+        if container:
+            record["container"] = ".".join(container)
+        record.update(extra)
+        self.result["symbols"].append(record)
+        return record
+
+    def parse(self) -> Dict[str, Any]:
+        while self.peek() is not None:
+            self.top_level()
+        return self.result
+
+    def top_level(self):
+        token = self.peek()
+        if token.kind != "ident":
+            self.pos += 1
+            return
+        word = token.value
+        if word in ("syntax", "edition") and self.at("=", 1):
+            self.pos += 2
+            value = self.constant()
+            if word == "syntax":
+                self.result["syntax"] = value
+            else:
+                self.result["syntax"] = "editions"
+                self.result["edition"] = value
+            self.accept(";")
+        elif word == "package":
+            self.pos += 1
+            self.result["package"] = self.full_ident()
+            self.accept(";")
+        elif word == "import":
+            self.pos += 1
+            kind = "default"
+            if self.at("public") or self.at("weak"):
+                kind = self.next().value
+            path_token = self.next()
+            if path_token is not None and path_token.kind == "string":
+                self.result["imports"].append({"path": unquote(path_token.value), "kind": kind, "line": token.line})
+            self.accept(";")
+        elif word == "option":
+            self.pos += 1
+            name, value = self.option()
+            if name:
+                self.result["options"][name] = value
+        elif word in ("message", "enum", "service", "extend"):
+            self.definition([])
+        else:
+            self.skip_statement()
+
+    def option(self) -> Tuple[str, Any]:
+        name = self.option_name()
+        if not self.accept("="):
+            self.skip_statement()
+            return "", None
+        value = self.constant()
+        self.accept(";")
+        return name, value
+
+    def definition(self, container: List[str]) -> bool:
+        """Parse a message, enum, service or extend block if one starts here."""
+        token = self.peek()
+        if token is None or token.kind != "ident" or self.peek(1) is None:
+            return False
+        if token.value == "message" and self.peek(1).kind == "ident":
+            self.message(container)
+        elif token.value == "enum" and self.peek(1).kind == "ident":
+            self.enum(container)
+        elif token.value == "service" and self.peek(1).kind == "ident" and not container:
+            self.service()
+        elif token.value == "extend" and self.peek(1).kind in ("ident", "punct") and not self.at("=", 1):
+            self.extend(container)
+        else:
+            return False
+        return True
+
+    def message(self, container: List[str]):
+        start = self.next()
+        name = self.next()
+        record = self.symbol(name, "message", f"message {name.value}", container, start)
+        scope = container + [name.value]
+        fields: List[str] = []
+        reserved: List[Any] = []
+        if not self.accept("{"):
+            self.skip_statement()
+            return
+        while self.peek() is not None and not self.at("}"):
+            self.message_item(scope, fields, reserved, record)
+        self.accept("}")
+        record["end_line"] = self.end_line()
+        record["fields"] = fields
+        if reserved:
+            record["reserved"] = reserved
+
+    def message_item(self, scope: List[str], fields: List[str], reserved: List[Any],
+                     record: Dict[str, Any], oneof: Optional[str] = None):
+        token = self.peek()
+        if token.kind == "punct":
+            self.pos += 1
+            return
+        if self.definition(scope):
+            return
+        word = token.value
+        if word == "option" and not self.at("=", 1):
+            self.pos += 1
+            name, value = self.option()
+            if name == "deprecated" and value is True:
+                record["deprecated"] = True
+            elif name:
+                record.setdefault("options", {})[name] = value
+        elif word == "oneof" and oneof is None and self.peek(1) is not None and self.peek(1).kind == "ident":
+            self.oneof(scope, fields, reserved, record)
+        elif word == "reserved" and not self.at("=", 1):
+            self.pos += 1
+            while self.peek() is not None and not self.at(";"):
+                item = self.next()
+                if item.kind == "string":
+                    reserved.append(unquote(item.value))
+                elif item.kind == "ident":
+                    reserved.append(item.value)
+                elif item.kind == "number":
+                    if self.at("to"):
+                        self.pos += 1
+                        end = self.next()
+                        reserved.append(f"{item.value} to {end.value if end is not None else ''}")
+                    else:
+                        reserved.append(int(item.value, 0) if item.value.isdigit() else item.value)
+            self.accept(";")
+        elif word == "extensions" and not self.at("=", 1):
+            self.skip_statement()
+        else:
+            name = self.field(scope, oneof)
+            if name:
+                fields.append(name)
+
+    def oneof(self, scope: List[str], fields: List[str], reserved: List[Any], record: Dict[str, Any]):
+        start = self.next()
+        name = self.next()
+        oneof = self.symbol(name, "oneof", f"oneof {name.value}", scope, start)
+        before = len(fields)
+        if self.accept("{"):
+            while self.peek() is not None and not self.at("}"):
+                self.message_item(scope, fields, reserved, record, name.value)
+            self.accept("}")
+        else:
+            self.skip_statement()
+        oneof["end_line"] = self.end_line()
+        oneof["fields"] = fields[before:]
+
+    def field(self, scope: List[str], oneof: Optional[str] = None, extends: Optional[str] = None) -> Optional[str]:
+        """A field (`repeated string tags = 4 [packed = true];`, `map<string, int32> counts = 5;`)."""
+        start = self.peek()
+        label = ""
+        if start.value in LABELS and self.peek(1) is not None and self.peek(1).kind == "ident" \
+                and not self.at("=", 1):
+            label = self.next().value
+        extra: Dict[str, Any] = {}
+        if self.at("map") and self.at("<", 1):
+            self.pos += 2
+            key = self.full_ident()
+            self.accept(",")
+            value = self.full_ident()
+            self.accept(">")
+            field_type = f"map<{key}, {value}>"
+            extra.update(map_key=key, map_value=value)
+        elif self.at("group"):
+            # proto2 groups declare a nested message and a field of its type at once
+            self.skip_statement()
+            return None
+        else:
+            field_type = self.full_ident()
+        name = self.next()
+        if not field_type or name is None or name.kind != "ident" or not self.accept("="):
+            self.skip_statement()
+            return None
+        number_token = self.next()
+        try:
+            number = int(number_token.value, 0)
+        except (AttributeError, ValueError):
+            number = None
+        options = self.field_options()
+        self.accept(";")
+        signature = " ".join(part for part in (label, field_type, name.value) if part)
+        signature += f" = {number_token.value if number_token is not None else ''}"
+        if oneof:
+            extra["oneof"] = oneof
+        if extends:
+            extra["extends"] = extends
+        if options.get("deprecated") is True:
+            extra["deprecated"] = True
+        if "json_name" in options:
+            extra["json_name"] = options["json_name"]
+        if "default" in options:
+            extra["default"] = options["default"]
+        self.symbol(name, "field", signature, scope, start, field_type=field_type, label=label,
+                    number=number, **extra)
+        return name.value
+
+    def enum(self, container: List[str]):
+        start = self.next()
+        name = self.next()
+        record = self.symbol(name, "enum", f"enum {name.value}", container, start)
+        scope = container + [name.value]
+        values: List[str] = []
+        if not self.accept("{"):
+            self.skip_statement()
+            return
+        while self.peek() is not None and not self.at("}"):
+            token = self.peek()
+            if token.kind != "ident":
+                self.pos += 1
+                continue
+            if token.value == "option" and not self.at("=", 1):
+                self.pos += 1
+                option, value = self.option()
+                if option == "allow_alias" and value is True:
+                    record["allow_alias"] = True
+                elif option == "deprecated" and value is True:
+                    record["deprecated"] = True
+                continue
+            if token.value == "reserved" and not self.at("=", 1):
+                self.skip_statement()
+                continue
+            self.pos += 1
+            if not self.accept("="):
+                self.skip_statement()
+                continue
+            negative = self.accept("-")
+            number_token = self.next()
+            try:
+                number = int(number_token.value, 0) * (-1 if negative else 1)
+            except (AttributeError, ValueError):
+                number = None
+            options = self.field_options()
+            self.accept(";")
+            extra = {"deprecated": True} if options.get("deprecated") is True else {}
+            self.symbol(token, "enum_value", f"{token.value} = {number}", scope, token, number=number, **extra)
+            values.append(token.value)
+        self.accept("}")
+        record["end_line"] = self.end_line()
+        record["values"] = values
+
+    def service(self):
+        start = self.next()
+        name = self.next()
+        record = self.symbol(name, "service", f"service {name.value}", [], start)
+        methods: List[str] = []
+        if not self.accept("{"):
+            self.skip_statement()
+            return
+        while self.peek() is not None and not self.at("}"):
+            token = self.peek()
+            if token.kind == "ident" and token.value == "rpc" and self.peek(1) is not None and self.peek(1).kind == "ident":
+                methods.append(self.rpc(name.value))
+            elif token.kind == "ident" and token.value == "option":
+                self.pos += 1
+                option, value = self.option()
+                if option == "deprecated" and value is True:
+                    record["deprecated"] = True
+                elif option:
+                    record.setdefault("options", {})[option] = value
+            elif token.kind == "ident":
+                self.skip_statement()
+            else:
+                self.pos += 1
+        self.accept("}")
+        record["end_line"] = self.end_line()
+        record["methods"] = methods
+
+    def rpc_type(self) -> Tuple[str, bool]:
+        """`(stream pkg.Request)` -> ("pkg.Request", True)."""
+        if not self.accept("("):
+            return "", False
+        streaming = self.at("stream") and self.peek(1) is not None and self.peek(1).kind in ("ident", "punct") \
+            and not self.at(")", 1)
+        if streaming:
+            self.pos += 1
+        written = self.full_ident()
+        self.accept(")")
+        return written, streaming
+
+    def rpc(self, service: str) -> str:
+        start = self.next()
+        name = self.next()
+        request, client_streaming = self.rpc_type()
+        self.accept("returns")
+        response, server_streaming = self.rpc_type()
+        signature = (f"rpc {name.value}({'stream ' if client_streaming else ''}{request}) "
+                     f"returns ({'stream ' if server_streaming else ''}{response})")
+        record = self.symbol(name, "rpc", signature, [service], start, request=request, response=response,
+                             client_streaming=client_streaming, server_streaming=server_streaming)
+        if self.accept("{"):
+            while self.peek() is not None and not self.at("}"):
+                if self.at("option"):
+                    self.pos += 1
+                    option, value = self.option()
+                    if option == "deprecated" and value is True:
+                        record["deprecated"] = True
+                    elif option == "(google.api.http)" and isinstance(value, dict):
+                        record["http"] = _http_rules(value)
+                    elif option:
+                        record.setdefault("options", {})[option] = value
+                else:
+                    self.skip_statement()
+            self.accept("}")
+        self.accept(";")
+        record["end_line"] = self.end_line()
+        return name.value
+
+    def extend(self, container: List[str]):
+        self.pos += 1
+        extendee = self.full_ident()
+        if not self.accept("{"):
+            self.skip_statement()
+            return
+        while self.peek() is not None and not self.at("}"):
+            if self.peek().kind != "ident":
+                self.pos += 1
+                continue
+            self.field(container, extends=extendee)
+        self.accept("}")
+
+
+def _http_rules(rule: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """The HTTP bindings of a google.api.http option, additional_bindings flattened in."""
+    rules = []
+    for method in HTTP_METHODS:
+        if isinstance(rule.get(method), str):
+            rules.append({"method": method.upper(), "path": rule[method]})
+    custom = rule.get("custom")
+    if isinstance(custom, dict) and isinstance(custom.get("path"), str):
+        rules.append({"method": str(custom.get("kind", "")).upper(), "path": custom["path"]})
+    if rules and isinstance(rule.get("body"), str):
+        rules[0]["body"] = rule["body"]
+    extra = rule.get("additional_bindings")
+    if isinstance(extra, dict):
+        rules.extend(_http_rules(extra))
+    return rules
+
+
+def parse_proto_source(content: str) -> Dict[str, Any]:
+    """
+    Parse .proto source.
+
+    Returns:
+        {"syntax", "package", "options", "imports", "symbols"} plus "edition"
+        for editions files. Symbols are "message", "field", "oneof",
+        "enum", "enum_value", "service" and "rpc" records; nested ones name
+        their enclosing message (or enum, or service) path in "container",
+        and every one carries its fully qualified "full_name".
+    """
+    return ProtoFileParser(content).parse()
@@ -0,0 +1,136 @@
+"""Cross-file view of the Python modules of a project.
+
+Modules are named the way the import system would name them: a file's
+dotted name runs up through the enclosing directories that hold an
+`__init__.py`, and the first directory without one is a source root
+(alongside the project root and a `src/` directory, the usual layouts).
+Absolute imports are looked up under every source root, relative ones
+against the importing package. `from x import y` names the submodule x.y
+when there is one and module x otherwise, and an alias (`import a.b as c`,
+`from x import y as z`) is attributed to the module it binds, not to
+the alias. Anything else is the standard library or a distribution,
+named by its top-level package.
+"""
+
+import os
+import sys
+from typing import Any, Dict, Iterator, List, Optional, Tuple
+
+# Python 3.10+ lists its own modules; older interpreters fall back to a short list
+STDLIB_MODULES = set(getattr(sys, "stdlib_module_names", ())) or {
+    "abc", "argparse", "ast", "asyncio", "base64", "collections", "contextlib", "copy", "csv",
+    "dataclasses", "datetime", "enum", "functools", "glob", "hashlib", "http", "importlib",
+    "inspect", "io", "itertools", "json", "logging", "math", "multiprocessing", "os", "pathlib",
+    "pickle", "random", "re", "shutil", "socket", "sqlite3", "string", "subprocess", "sys",
+    "tempfile", "threading", "time", "typing", "unittest", "urllib", "uuid", "warnings", "weakref",
+}
+
+
+def is_stdlib(module: str) -> bool:
+    """Standard library modules, by top-level name ("os.path" -> True)."""
+    top = module.split(".", 1)[0]
+    return top in STDLIB_MODULES or top == "__future__"
+
+
+def module_name(path: str) -> Tuple[str, bool]:
+    """The name a file declares for itself (its stem, or its directory for __init__.py) and whether it is a package."""
+    stem = os.path.splitext(os.path.basename(path))[0]
+    if stem == "__init__":
+        return os.path.basename(os.path.dirname(path)), True
+    return stem, False
+
+
+class PyProject:
+    """The parsed Python files of a project and the imports between them."""
+
+    def __init__(self, files: Dict[str, Dict[str, Any]], root: str):
+        self.files = files
+        self.root = root
+        # Package directory -> its __init__.py
+        self.packages = {os.path.dirname(p): p for p in files if os.path.basename(p) == "__init__.py"}
+        self.modules: Dict[str, str] = {}
+        roots = {root, os.path.join(root, "src")}
+        for path in sorted(files):
+            name, roots_dir = self._dotted(path)
+            self.modules.setdefault(name, path)
+            roots.add(roots_dir)
+        self.roots = sorted(roots, key=lambda r: (r != root, r))
+        self._resolved: Dict[Tuple[str, str, int], Optional[str]] = {}
+
+    def _dotted(self, path: str) -> Tuple[str, str]:
+        """(dotted module name, the source root it is relative to)."""
+        stem = os.path.splitext(os.path.basename(path))[0]
+        parts = [] if stem == "__init__" else [stem]
+        directory = os.path.dirname(path)
+        while directory in self.packages and directory != os.path.dirname(directory):
+            parts.append(os.path.basename(directory))
+            directory = os.path.dirname(directory)
+        return ".".join(reversed(parts)) or os.path.basename(os.path.dirname(path)), directory
+
+    def dotted_name(self, path: str) -> str:
+        return self._dotted(path)[0]
+
+    def symbols(self) -> Iterator[Tuple[str, Dict[str, Any]]]:
+        """(path, symbol) for every declaration, files in sorted order."""
+        for path in sorted(self.files):
+            for symbol in self.files[path]["symbols"]:
+                yield path, symbol
+
+    def _lookup(self, base: str) -> Optional[str]:
+        """The indexed file of a module path without extension ("pkg/mod" -> pkg/mod.py or pkg/mod/__init__.py)."""
+        for candidate in (base + ".py", os.path.join(base, "__init__.py")):
+            if candidate in self.files:
+                return candidate
+        return None
+
+    def resolve(self, importer: str, module: str, level: int = 0) -> Optional[str]:
+        """The indexed file `module` names when imported from importer (level dots for relative imports), or None."""
+        key = (os.path.dirname(importer), module, level)
+        if key in self._resolved:
+            return self._resolved[key]
+        found = None
+        relative = module.replace(".", os.sep) if module else ""
+        if level:
+            base = key[0]
+            for _ in range(level - 1):
+                base = os.path.dirname(base)
+            found = self._lookup(os.path.join(base, relative)) if relative else self.packages.get(base)
+        else:
+            for source_root in self.roots:
+                found = self._lookup(os.path.join(source_root, relative))
+                if found:
+                    break
+        self._resolved[key] = found
+        return found
+
+    def imports_of(self, path: str) -> List[Dict[str, Any]]:
+        """
+        The imports of a file, each with the indexed file it resolves to
+        ("resolved", or None). For `from` imports every name also gets
+        "resolved" - the submodule it names, or else the module it comes from.
+        """
+        results = []
+        for imp in self.files[path]["imports"]:
+            resolved = self.resolve(path, imp["module"], imp["level"])
+            entry = {**imp, "resolved": resolved}
+            if imp["kind"] == "from":
+                names = []
+                for name in imp["names"]:
+                    submodule = None
+                    if name["imported"] != "*":
+                        dotted = f"{imp['module']}.{name['imported']}" if imp["module"] else name["imported"]
+                        submodule = self.resolve(path, dotted, imp["level"])
+                    names.append({**name, "resolved": submodule or resolved})
+                entry["names"] = names
+            results.append(entry)
+        return results
+
+    def target_of(self, path: str, imp: Dict[str, Any]) -> str:
+        """The absolute dotted module an import refers to (relative imports made absolute)."""
+        if not imp["level"]:
+            return imp["module"]
+        package = self.dotted_name(path).split(".")
+        if os.path.basename(path) != "__init__.py":
+            package = package[:-1]
+        base = package[:len(package) - (imp["level"] - 1)] if imp["level"] > 1 else package
+        return ".".join(base + ([imp["module"]] if imp["module"] else []))
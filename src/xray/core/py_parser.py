@@ -0,0 +1,340 @@
+"""Python source parser for XRAY - declarations and imports from the ast module.
+
+Unlike Go and TypeScript, Python ships its own parser, so this module only
+walks the tree `ast.parse` builds: the module, its classes (with bases)
+and their methods, functions, module-level constants, the decorators on
+each of them, and every import with the names it binds. Declarations
+nested in module-level `if`/`try`/`with` blocks count as module level;
+function bodies are only searched for imports. A file the running Python
+cannot parse (Python 2 code, for one) still yields its module record,
+carrying the syntax error, so one bad file never stops indexing.
+"""
+
+import ast
+import re
+from typing import Any, Dict, List, Optional, Set
+
+from xray.core.source_text import source_lines
+
+# Bump whenever the shape of extracted records changes so cached results are discarded
+PY_PARSER_VERSION = 3
+
+MAX_SIGNATURE = 120
+
+# UPPER_CASE names are module constants by convention (PEP 8)
+_CONSTANT = re.compile(r"^_*[A-Z][A-Z0-9_]*$")
+
+# Decorators that change what kind of method a def is
+_METHOD_FLAGS = {
+    "staticmethod": "static",
+    "classmethod": "classmethod",
+    "property": "property",
+    "cached_property": "property",
+    "abstractmethod": "abstract",
+}
+
+_BLOCKS = tuple(getattr(ast, name) for name in ("If", "Try", "TryStar", "With") if hasattr(ast, name))
+
+
+def _clip(text: str) -> str:
+    text = " ".join(text.split())
+    return text if len(text) <= MAX_SIGNATURE else text[:MAX_SIGNATURE - 3].rstrip() + "..."
+
+
+def _dotted(node: ast.AST) -> Optional[str]:
+    """"a.b.c" for a Name/Attribute chain (the callee of a call), else None."""
+    if isinstance(node, ast.Call):
+        node = node.func
+    parts = []
+    while isinstance(node, ast.Attribute):
+        parts.append(node.attr)
+        node = node.value
+    if not isinstance(node, ast.Name):
+        return None
+    parts.append(node.id)
+    return ".".join(reversed(parts))
+
+
+def _is_type_checking(test: ast.AST) -> bool:
+    """`if TYPE_CHECKING:` or `if typing.TYPE_CHECKING:`."""
+    return _dotted(test) in ("TYPE_CHECKING", "typing.TYPE_CHECKING")
+
+
+class PyFileParser:
+    """Parse a Python file into import and symbol records."""
+
+    def __init__(self, content: str, module: str, package: bool = False):
+        self.content = content
+        self.lines = source_lines(content)
+        self.module = module
+        self.package = package
+        self.symbols: List[Dict[str, Any]] = []
+        self.imports: List[Dict[str, Any]] = []
+        self.exports: Optional[List[str]] = None
+
+    def _column(self, line: int, offset: int) -> int:
+        """1-based character column of an ast byte offset."""
+        if not 1 <= line <= len(self.lines):
+            return offset + 1
+        return len(self.lines[line - 1].encode("utf-8")[:offset].decode("utf-8", "replace")) + 1
+
+    def _name_column(self, node: ast.AST, name: str) -> int:
+        # ast only records where `def`/`class` starts, not the name
+        text = self.lines[node.lineno - 1] if node.lineno <= len(self.lines) else ""
+        match = re.search(r"\b(?:def|class)\s+(" + re.escape(name) + r")\b", text)
+        return match.start(1) + 1 if match else self._column(node.lineno, node.col_offset)
+
+    # ------------------------------------------------------------------
+    # Records
+    # ------------------------------------------------------------------
+
+    def parse(self) -> Dict[str, Any]:
+        """Parse the whole file and return imports, exports, and symbols."""
+        module = {
+            "name": self.module,
+            "type": "package" if self.package else "module",
+            "signature": f"{'package' if self.package else 'module'} {self.module}",
+            "start_line": 1,
+            "column": 1,
+            "end_line": max(len(self.lines), 1),
+            "doc": "",
+            "exported": not self.module.startswith("_") or self.module.startswith("__"),
+            "language": "python",
+        }
+        self.symbols.append(module)
+        try:
+            tree = ast.parse(self.content)
+        except (SyntaxError, ValueError, RecursionError) as e:
+            line = getattr(e, "lineno", None)
+            column = getattr(e, "offset", None)
+            module["parseError"] = {"message": getattr(e, "msg", None) or str(e), "line": line, "column": column}
+            return {"imports": [], "exports": [], "symbols": self.symbols, "qualified_refs": [],
+                    "parseError": module["parseError"]}
+        module["doc"] = ast.get_docstring(tree) or ""
+
+        self._body(tree.body, None, False)
+        self._imports(tree)
+        if self.exports is not None:
+            public = set(self.exports)
+            for symbol in self.symbols[1:]:
+                if "container" not in symbol:
+                    symbol["exported"] = symbol["name"] in public
+        # Members are public when their name and their class are
+        owners = {s["name"]: s["exported"] for s in self.symbols if s["type"] == "class" and "container" not in s}
+        for symbol in self.symbols:
+            if "container" in symbol:
+                top = symbol["container"].split(".", 1)[0]
+                symbol["exported"] = symbol["exported"] and owners.get(top, True)
+        return {
+            "imports": self.imports,
+            "exports": sorted(self.exports) if self.exports is not None else
+            sorted(s["name"] for s in self.symbols[1:] if s["exported"] and "container" not in s),
+            "symbols": self.symbols,
+            "qualified_refs": self._qualified_refs(tree),
+        }
+
+    def _symbol(self, node: ast.AST, name: str, kind: str, signature: str,
+                container: Optional[str], doc: str = "", **extra) -> Dict[str, Any]:
+        decorators = getattr(node, "decorator_list", [])
+        symbol = {
+            "name": name,
+            "type": kind,
+            "signature": _clip(signature),
+            # Decorators belong to the declaration they wrap
+            "start_line": min([d.lineno for d in decorators] + [node.lineno]),
+            "column": self._name_column(node, name) if kind != "constant" else self._column(node.lineno, node.col_offset),
+            "end_line": node.end_lineno or node.lineno,
+            "doc": doc,
+            "exported": not name.startswith("_") or (name.startswith("__") and name.endswith("__")),
+            "language": "python",
+        }
+        if decorators:
+            symbol["decorators"] = [ast.unparse(d) for d in decorators]
+            deprecated = next((d for d in decorators if (_dotted(d) or "").split(".")[-1] == "deprecated"), None)
+            if deprecated is not None:
+                symbol["deprecated"] = True
+                args = deprecated.args if isinstance(deprecated, ast.Call) else []
+                if args and isinstance(args[0], ast.Constant) and isinstance(args[0].value, str):
+                    symbol["deprecation"] = args[0].value
+        if container:
+            symbol["container"] = container
+        symbol.update(extra)
+        self.symbols.append(symbol)
+        return symbol
+
+    # ------------------------------------------------------------------
+    # Declarations
+    # ------------------------------------------------------------------
+
+    def _body(self, body: List[ast.stmt], container: Optional[str], in_class: bool):
+        for node in body:
+            if isinstance(node, (ast.FunctionDef, ast.AsyncFunctionDef)):
+                self._function(node, container, in_class)
+            elif isinstance(node, ast.ClassDef):
+                self._class(node, container)
+            elif container is None and isinstance(node, (ast.Assign, ast.AnnAssign, ast.AugAssign)):
+                self._assignment(node)
+            elif isinstance(node, _BLOCKS):
+                for block in (getattr(node, "body", []), getattr(node, "orelse", []),
+                              getattr(node, "finalbody", [])):
+                    self._body(block, container, in_class)
+                for handler in getattr(node, "handlers", []):
+                    self._body(handler.body, container, in_class)
+
+    def _class(self, node: ast.ClassDef, container: Optional[str]):
+        bases = [ast.unparse(b) for b in node.bases]
+        keywords = {k.arg: ast.unparse(k.value) for k in node.keywords if k.arg}
+        arguments = bases + [f"{k}={v}" for k, v in keywords.items()]
+        signature = f"class {node.name}" + (f"({', '.join(arguments)})" if arguments else "")
+        extra: Dict[str, Any] = {"bases": bases}
+        if "metaclass" in keywords:
+            extra["metaclass"] = keywords["metaclass"]
+        self._symbol(node, node.name, "class", signature, container, ast.get_docstring(node) or "", **extra)
+        self._body(node.body, f"{container}.{node.name}" if container else node.name, True)
+
+    def _function(self, node: ast.AST, container: Optional[str], in_class: bool):
+        is_async = isinstance(node, ast.AsyncFunctionDef)
+        params = self._params(node.args)
+        returns = ast.unparse(node.returns) if node.returns else None
+        rendered = ", ".join(p["text"] for p in params)
+        signature = f"{'async ' if is_async else ''}def {node.name}({rendered})" + (f" -> {returns}" if returns else "")
+        extra: Dict[str, Any] = {"params": [{k: v for k, v in p.items() if k != "text"}
+                                            for p in params if p["kind"] != "separator"]}
+        if returns:
+            extra["returns"] = returns
+        if is_async:
+            extra["async"] = True
+        if in_class:
+            for decorator in node.decorator_list:
+                name = (_dotted(decorator) or "").split(".")[-1]
+                if name in _METHOD_FLAGS:
+                    extra[_METHOD_FLAGS[name]] = True
+                elif name in ("setter", "getter", "deleter"):
+                    extra["property"] = True
+        self._symbol(node, node.name, "method" if in_class else "function", signature, container,
+                     ast.get_docstring(node) or "", **extra)
+
+    def _params(self, args: ast.arguments) -> List[Dict[str, Any]]:
+        """Parameters in declaration order, with their annotations and defaults; `/` and `*` as separators."""
+        positional = args.posonlyargs + args.args
+        defaults = [None] * (len(positional) - len(args.defaults)) + list(args.defaults)
+        params = []
+
+        def add(arg: ast.arg, kind: str, default: Optional[ast.AST], prefix: str = ""):
+            param: Dict[str, Any] = {"name": arg.arg, "kind": kind}
+            text = prefix + arg.arg
+            if arg.annotation is not None:
+                param["type"] = ast.unparse(arg.annotation)
+                text += f": {param['type']}"
+            if default is not None:
+                param["default"] = ast.unparse(default)
+                text += f" = {param['default']}" if arg.annotation is not None else f"={param['default']}"
+            param["text"] = text
+            params.append(param)
+
+        for i, arg in enumerate(positional):
+            add(arg, "positional_only" if i < len(args.posonlyargs) else "positional", defaults[i])
+            if args.posonlyargs and i == len(args.posonlyargs) - 1:
+                params.append({"name": "/", "kind": "separator", "text": "/"})
+        if args.vararg:
+            add(args.vararg, "var_positional", None, "*")
+        elif args.kwonlyargs:
+            params.append({"name": "*", "kind": "separator", "text": "*"})
+        for arg, default in zip(args.kwonlyargs, args.kw_defaults):
+            add(arg, "keyword_only", default)
+        if args.kwarg:
+            add(args.kwarg, "var_keyword", None, "**")
+        return params
+
+    def _assignment(self, node: ast.AST):
+        if isinstance(node, ast.AnnAssign):
+            targets = [node.target]
+        elif isinstance(node, ast.AugAssign):
+            targets = [node.target]
+        else:
+            targets = node.targets
+        names = [t.id for t in targets if isinstance(t, ast.Name)]
+        if "__all__" in names:
+            self._all(node)
+            return
+        if isinstance(node, ast.AugAssign) or node.value is None:
+            return
+        value = _clip(ast.unparse(node.value))
+        annotation = ast.unparse(node.annotation) if isinstance(node, ast.AnnAssign) else None
+        for name in names:
+            if not _CONSTANT.match(name):
+                continue
+            signature = f"{name}: {annotation} = {value}" if annotation else f"{name} = {value}"
+            extra: Dict[str, Any] = {"value": value}
+            if annotation:
+                extra["annotation"] = annotation
+            self._symbol(node, name, "constant", signature, None, **extra)
+
+    def _all(self, node: ast.AST):
+        """Collect the string literals of `__all__ = [...]` and `__all__ += [...]`."""
+        if not isinstance(node.value, (ast.List, ast.Tuple)):
+            return
+        names = [e.value for e in node.value.elts if isinstance(e, ast.Constant) and isinstance(e.value, str)]
+        if isinstance(node, ast.AugAssign) and self.exports is not None:
+            self.exports += names
+        else:
+            self.exports = names
+
+    # ------------------------------------------------------------------
+    # Imports
+    # ------------------------------------------------------------------
+
+    def _imports(self, tree: ast.Module):
+        """Every import statement, flagged when it sits in a function or a TYPE_CHECKING block."""
+        def visit(node: ast.AST, lazy: bool, type_checking: bool):
+            for child in ast.iter_child_nodes(node):
+                if isinstance(child, (ast.Import, ast.ImportFrom)):
+                    self._import(child, lazy, type_checking)
+                elif isinstance(child, (ast.FunctionDef, ast.AsyncFunctionDef, ast.Lambda)):
+                    visit(child, True, type_checking)
+                elif isinstance(child, ast.If) and _is_type_checking(child.test):
+                    for stmt in child.body:
+                        visit(ast.Module(body=[stmt], type_ignores=[]), lazy, True)
+                    for stmt in child.orelse:
+                        visit(ast.Module(body=[stmt], type_ignores=[]), lazy, type_checking)
+                else:
+                    visit(child, lazy, type_checking)
+
+        visit(tree, False, False)
+        self.imports.sort(key=lambda imp: (imp["line"], imp["column"]))
+
+    def _import(self, node: ast.AST, lazy: bool, type_checking: bool):
+        base = {"line": node.lineno, "column": self._column(node.lineno, node.col_offset)}
+        if lazy:
+            base["lazy"] = True
+        if type_checking:
+            base["type_checking"] = True
+        if isinstance(node, ast.Import):
+            # `import a.b` binds `a`; `import a.b as c` binds `c` to a.b itself
+            for alias in node.names:
+                self.imports.append({
+                    "module": alias.name, "kind": "import", "level": 0,
+                    "local": alias.asname or alias.name.split(".", 1)[0],
+                    "aliased": alias.asname is not None, "names": [], **base,
+                })
+            return
+        names = [{"imported": a.name, "local": a.asname or a.name} for a in node.names]
+        self.imports.append({
+            "module": node.module or "", "kind": "from", "level": node.level, "names": names,
+            "star": any(a.name == "*" for a in node.names), **base,
+        })
+
+    def _qualified_refs(self, tree: ast.Module) -> List[Dict[str, Any]]:
+        """`alias.name` uses of names bound by `import x` statements, first line of each."""
+        locals_: Set[str] = {imp["local"] for imp in self.imports if imp["kind"] == "import"}
+        seen: Dict[tuple, int] = {}
+        for node in ast.walk(tree):
+            if isinstance(node, ast.Attribute) and isinstance(node.value, ast.Name) and node.value.id in locals_:
+                key = (node.value.id, node.attr)
+                seen[key] = min(seen.get(key, node.lineno), node.lineno)
+        return [{"local": local, "name": name, "line": line} for (local, name), line in sorted(seen.items())]
+
+
+def parse_py_source(content: str, module: str = "", package: bool = False) -> Dict[str, Any]:
+    """Parse Python source text and return imports, exports, symbols and qualified references."""
+    return PyFileParser(content, module, package).parse()
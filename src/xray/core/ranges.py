@@ -0,0 +1,263 @@
+"""Source ranges for the locations in tool output.
+
+Every location a tool reports - a symbol, a reference, a call site, a
+finding - also gets a "range":
+
+    {"startLine": 12, "startColumn": 5, "endLine": 12, "endColumn": 14}
+
+Lines and columns are 1-based. Columns count UTF-8 bytes, so a tab is one
+column and "é" two, whichever language the file is in (and whichever
+encoding: a transcoded file's columns are those of its UTF-8 text); endColumn is
+exclusive (one past the last byte), as in SARIF and LSP-style editors.
+What a range covers depends on what the location names:
+
+- a declaration (start_line/end_line): from the first character of its
+  first line to the end of its last line;
+- a location with a column and an end_line (a goroutine's function
+  literal): from the column to the end of the last line;
+- a point with a column (a call, a reference, an import): the token there -
+  an identifier, a string literal or a single character;
+- a line and a name without a column: the name's first whole-word
+  occurrence on the line (the last part of a qualified name);
+- a bare line: the text of the line without its indentation.
+
+A declaration's range is computed once, when its file is parsed
+(add_declaration_ranges), and any location symbol IDs name the declaration
+of (see xray.core.symbol_ids) - a symbol listed, a caller, a rename
+target - takes that range, so every tool gives a declaration the same one.
+
+The line/column fields the parsers record (1-based, counting characters)
+stay as they are for existing callers.
+
+A snippet inlines the source around a location in the result itself:
+
+    {"first_line": 103, "text": "...", "match_line": 105, "match_start": 22, "match_end": 33}
+
+"text" holds the located line with up to N lines of context either side;
+match_start and match_end are columns on the match_line as shown, counted
+like a range's. Lines wider than SNIPPET_WIDTH are cut, the located one to
+a window around the match, and marked with "…" ("trimmed": true).
+"""
+
+import os
+import re
+from typing import Any, Dict, List, Optional
+
+from xray.core.source_text import read_text, source_lines
+
+_IDENT = re.compile(r"\w+")
+_STRING = re.compile(r'"(?:[^"\\\n]|\\.)*"?|`[^`]*`?|\'(?:[^\'\\\n]|\\.)*\'?')
+
+_PATH_KEYS = ("path", "file")
+
+# Context lines a snippet can take either side, and characters kept per line
+MAX_SNIPPET_LINES = 20
+SNIPPET_WIDTH = 200
+_ELLIPSIS = "…"
+
+
+def _byte_column(text: str, column: int) -> int:
+    """UTF-8 column of a 1-based character column on a line."""
+    return len(text[:column - 1].encode("utf-8")) + 1
+
+
+def _token_end(text: str, column: int) -> int:
+    """Character column just past the token starting at column."""
+    start = column - 1
+    if start >= len(text):
+        return column
+    match = _IDENT.match(text, start) or _STRING.match(text, start)
+    return match.end() + 1 if match else column + 1
+
+
+def _number(value: Any) -> bool:
+    return isinstance(value, int) and not isinstance(value, bool) and value > 0
+
+
+class SourceLines:
+    """The lines of the files a result refers to, read once each."""
+
+    def __init__(self):
+        self._files: Dict[str, Optional[List[str]]] = {}
+        self._dirs: Dict[str, bool] = {}
+
+    def is_dir(self, path: str) -> bool:
+        if path not in self._dirs:
+            self._dirs[path] = os.path.isdir(path)
+        return self._dirs[path]
+
+    def lines(self, path: str) -> Optional[List[str]]:
+        if path not in self._files:
+            try:
+                self._files[path] = source_lines(read_text(path))
+            except OSError:
+                self._files[path] = None
+        return self._files[path]
+
+
+def _name_column(text: str, name: Optional[str]) -> Optional[int]:
+    """Character column of the first whole-word occurrence of a name's last part on a line."""
+    if not name:
+        return None
+    word = re.split(r"[.:]", name)[-1]
+    match = re.search(rf"(?<!\w){re.escape(word)}(?!\w)", text) if word else None
+    return match.start() + 1 if match else None
+
+
+def source_range(lines: Optional[List[str]], line: int, column: Optional[int] = None,
+                 end_line: Optional[int] = None, declaration: bool = False,
+                 name: Optional[str] = None) -> Dict[str, int]:
+    """
+    The range of a location, given the lines of its file (None when it
+    could not be read: columns are then taken as they are).
+
+    A declaration spans from the first character of its first line to the
+    end of end_line; any other location with an end_line spans from its
+    column. Without an end_line, a column gives the token there, a name
+    the word it is on the line, and a bare line its text.
+    """
+    def text(number: int) -> Optional[str]:
+        return lines[number - 1] if lines is not None and 1 <= number <= len(lines) else None
+
+    def indent(value: str) -> int:
+        return len(value) - len(value.lstrip()) + 1
+
+    def line_end(number: int) -> int:
+        value = text(number)
+        return len(value.rstrip().encode("utf-8")) + 1 if value is not None else 1
+
+    first = text(line)
+    if not declaration and end_line is None and not column and first is not None:
+        column = _name_column(first, name)
+    if declaration or end_line is not None or not column:
+        last = end_line if end_line is not None and end_line >= line else line
+        start = column if column and not declaration else None
+        if first is None:
+            return {"startLine": line, "startColumn": start or 1, "endLine": last, "endColumn": start or 1}
+        return {"startLine": line, "startColumn": _byte_column(first, start or indent(first)),
+                "endLine": last, "endColumn": line_end(last)}
+    if first is None:
+        return {"startLine": line, "startColumn": column, "endLine": line, "endColumn": column}
+    return {"startLine": line, "startColumn": _byte_column(first, column),
+            "endLine": line, "endColumn": _byte_column(first, _token_end(first, column))}
+
+
+def add_declaration_ranges(parsed: Dict[str, Any], content: str) -> Dict[str, Any]:
+    """Give, in place, every symbol of a parse result the range of its declaration in content."""
+    lines = source_lines(content)
+    for symbol in parsed.get("symbols", []) + parsed.get("nested", []):
+        if _number(symbol.get("start_line")):
+            symbol["range"] = source_range(lines, symbol["start_line"], None,
+                                           symbol["end_line"] if _number(symbol.get("end_line")) else None,
+                                           declaration=True)
+    return parsed
+
+
+def snippet(lines: Optional[List[str]], line: int, column: Optional[int] = None, name: Optional[str] = None,
+            context: int = 0) -> Optional[Dict[str, Any]]:
+    """
+    The lines around a location, with the match on its line: the token
+    at column, else the first whole-word occurrence of name, else the
+    line's text without its indentation. None when the line cannot be read.
+    """
+    if lines is None or not 1 <= line <= len(lines):
+        return None
+    text = lines[line - 1]
+    start = end = None
+    if column and column <= len(text):
+        start, end = column - 1, _token_end(text, column) - 1
+    elif name:
+        match = re.search(rf"(?<!\w){re.escape(name)}(?!\w)", text)
+        if match:
+            start, end = match.span()
+    if start is None:
+        start, end = len(text) - len(text.lstrip()), len(text.rstrip())
+
+    # A window of the located line around the match, then the context lines cut at the width
+    trimmed = False
+    if len(text) > SNIPPET_WIDTH:
+        left = max(0, min(start - max(SNIPPET_WIDTH - (end - start), 0) // 2, len(text) - SNIPPET_WIDTH))
+        shown = text[left:left + SNIPPET_WIDTH]
+        prefix = _ELLIPSIS if left else ""
+        text = prefix + shown + (_ELLIPSIS if left + SNIPPET_WIDTH < len(text) else "")
+        start = start - left + len(prefix)
+        end = min(end - left, len(shown)) + len(prefix)
+        trimmed = True
+    first = max(1, line - context)
+    last = min(len(lines), line + context)
+    shown_lines = []
+    for number in range(first, last + 1):
+        value = text if number == line else lines[number - 1]
+        if number != line and len(value) > SNIPPET_WIDTH:
+            value = value[:SNIPPET_WIDTH] + _ELLIPSIS
+            trimmed = True
+        shown_lines.append(value)
+    result = {
+        "first_line": first,
+        "text": "\n".join(shown_lines),
+        "match_line": line,
+        "match_start": _byte_column(text, start + 1),
+        "match_end": _byte_column(text, end + 1),
+    }
+    if trimmed:
+        result["trimmed"] = True
+    return result
+
+
+def mark_files(result: Any, notes: Dict[str, Dict[str, Any]], root: str) -> Any:
+    """
+    Copy, in place, the notes about a file (path -> fields) into every
+    location in it, the locations add_ranges gives a range.
+    """
+    def walk(value: Any, path: Optional[str]):
+        if isinstance(value, list):
+            for item in value:
+                walk(item, path)
+            return
+        if not isinstance(value, dict):
+            return
+        own = next((value[k] for k in _PATH_KEYS if isinstance(value.get(k), str)), None)
+        if own is not None:
+            path = own if os.path.isabs(own) else os.path.join(root, own)
+        if path in notes and any(_number(value.get(k)) for k in ("line", "start_line")):
+            value.update(notes[path])
+        for item in value.values():
+            if isinstance(item, (dict, list)):
+                walk(item, path)
+
+    walk(result, None)
+    return result
+
+
+def add_ranges(result: Any, root: str, sources: Optional[SourceLines] = None) -> Any:
+    """
+    Add a "range" to every location in a result, in place: each dict with a
+    "line" or "start_line" and a "path"/"file" of its own or of the nearest
+    enclosing dict. Relative paths are taken from root; directories and
+    dicts that already have a range are left alone.
+    """
+    sources = sources or SourceLines()
+
+    def walk(value: Any, path: Optional[str]):
+        if isinstance(value, list):
+            for item in value:
+                walk(item, path)
+            return
+        if not isinstance(value, dict):
+            return
+        own = next((value[k] for k in _PATH_KEYS if isinstance(value.get(k), str)), None)
+        if own is not None:
+            path = own if os.path.isabs(own) else os.path.join(root, own)
+        key = next((k for k in ("line", "start_line") if _number(value.get(k))), None)
+        if key and path and "range" not in value and not sources.is_dir(path):
+            value["range"] = source_range(sources.lines(path), value[key],
+                                          value.get("column") if _number(value.get("column")) else None,
+                                          value.get("end_line") if _number(value.get("end_line")) else None,
+                                          declaration=key == "start_line",
+                                          name=value.get("name") if isinstance(value.get("name"), str) else None)
+        for item in value.values():
+            if isinstance(item, (dict, list)):
+                walk(item, path)
+
+    walk(result, None)
+    return result
@@ -0,0 +1,194 @@
+"""A Markdown architecture report of a Go project, built from the index.
+
+Each section is rendered from data the index (or git history) already
+holds, in a fixed order with every list sorted, so regenerating the report
+for an unchanged tree gives the same bytes - it can be committed and
+diffed. Declarations link to `path#Lstart-Lend` relative to the project
+root, which GitHub turns into highlighted line ranges.
+"""
+
+import os
+import re
+from typing import Any, Dict, List, Optional, Set
+from urllib.parse import quote
+
+from xray.core.go_analysis import GoProject
+from xray.core.go_deps import is_std
+
+SECTIONS = ("packages", "types", "entry_points", "dependencies", "hotspots")
+
+_SENTENCE = re.compile(r"^(.+?[.!?])(?:\s|$)")
+
+
+def summary_line(doc: str) -> str:
+    """The first sentence of a doc comment, on one line."""
+    paragraph = " ".join(doc.split("\n\n", 1)[0].split())
+    match = _SENTENCE.match(paragraph)
+    return match.group(1) if match else paragraph
+
+
+def _cell(text: Any) -> str:
+    """Text safe inside a Markdown table cell."""
+    return str(text).replace("|", "\\|").replace("\n", " ")
+
+
+class ReportBuilder:
+    """Renders report sections with links relative to the project root."""
+
+    def __init__(self, project: GoProject, root: str, max_items: int = 20):
+        self.project = project
+        self.root = root
+        self.max_items = max_items
+
+    def rel(self, path: str) -> str:
+        return os.path.relpath(path, self.root).replace(os.sep, "/")
+
+    def link(self, text: str, path: str, start: Optional[int] = None, end: Optional[int] = None) -> str:
+        target = quote(self.rel(path))
+        if start:
+            target += f"#L{start}" + (f"-L{end}" if end and end > start else "")
+        return f"[`{text}`]({target})"
+
+    # ------------------------------------------------------------------
+    # Sections
+    # ------------------------------------------------------------------
+
+    def packages(self) -> List[str]:
+        lines = ["## Packages", ""]
+        dirs = sorted(self.project.packages)
+        for pkg_dir in dirs:
+            info = self.project.packages[pkg_dir]
+            # Nested under the closest enclosing package that is also listed
+            depth = sum(1 for other in dirs if pkg_dir.startswith(other + os.sep))
+            # doc.go is where a package comment conventionally lives
+            files = sorted(info["files"], key=lambda path: os.path.basename(path) != "doc.go")
+            doc = next((d for d in (self.project.files[path].get("package_doc") for path in files) if d), "")
+            rel_dir = self.rel(pkg_dir)
+            label = f"[`{rel_dir}/`]({quote(rel_dir)})" if rel_dir != "." else "`./`"
+            count = len(files)
+            entry = f"{'  ' * depth}- {label} (package `{info['name']}`, {count} file{'s' if count != 1 else ''})"
+            if doc:
+                entry += f" - {summary_line(doc)}"
+            lines.append(entry)
+        return lines
+
+    def types(self) -> List[str]:
+        """Exported interfaces, then the exported types with the most methods."""
+        interfaces, concrete = [], []
+        for key, symbol in self.project.types.items():
+            if not symbol["name"][:1].isupper() or symbol.get("alias"):
+                continue
+            if symbol["type"] == "interface":
+                specs, _ = self.project.interface_method_set(*key)
+                interfaces.append((key, symbol, [specs[name] for name in sorted(specs)]))
+            else:
+                methods = sorted(self.project.methods.get(key, []), key=lambda m: m["name"])
+                if methods:
+                    concrete.append((key, symbol, methods))
+        interfaces.sort(key=lambda item: (-len(item[2]), self.rel(item[1]["path"]), item[0][1]))
+        concrete.sort(key=lambda item: (-len(item[2]), self.rel(item[1]["path"]), item[0][1]))
+
+        lines = ["## Key types", ""]
+        for title, entries in (("Interfaces", interfaces), ("Types", concrete)):
+            if not entries:
+                continue
+            lines += [f"### {title}", ""]
+            for key, symbol, methods in entries[:self.max_items]:
+                entry = f"- {self.link(symbol['name'], symbol['path'], symbol['start_line'], symbol.get('end_line'))}" \
+                        f" ({symbol['type']}, package `{symbol['package']}`)"
+                if symbol.get("doc"):
+                    entry += f" - {summary_line(symbol['doc'])}"
+                lines.append(entry)
+                lines += [f"  - {self.link(m['name'], m['path'], m['start_line'], m.get('end_line'))}"
+                          f" `{m.get('signature', '')}`" for m in methods]
+            if len(entries) > self.max_items:
+                lines.append(f"- ... and {len(entries) - self.max_items} more")
+            lines.append("")
+        if len(lines) == 2:
+            lines.append("_No exported interfaces or types with methods._")
+        return lines[:-1] if lines[-1] == "" else lines
+
+    def entry_points(self, routes: List[Dict[str, Any]]) -> List[str]:
+        lines = ["## Entry points", ""]
+        mains = []
+        for pkg_dir, info in sorted(self.project.packages.items()):
+            if info["name"] != "main":
+                continue
+            for path in info["files"]:
+                for symbol in self.project.files[path]["symbols"]:
+                    if symbol["type"] == "function" and symbol["name"] == "main":
+                        link = self.link("func main", path, symbol["start_line"], symbol["end_line"])
+                        mains.append(f"- {link} in `{self.rel(pkg_dir)}/`")
+        lines += mains or ["_No main packages._"]
+        lines += ["", "### HTTP routes", ""]
+        if not routes:
+            lines.append("_No HTTP routes found._")
+            return lines
+        lines += ["| Method | Route | Handler | Registered in |", "| --- | --- | --- | --- |"]
+        for route in routes[:self.max_items]:
+            handler = route.get("handler")
+            handler_cell = self.link(handler["name"], handler["path"], handler["line"]) if handler and handler.get("path") \
+                else (_cell(handler["name"]) if handler else "")
+            site = route["registered_at"]
+            site_cell = self.link(site.get("function") or self.rel(site["path"]), site["path"], site["line"])
+            lines.append(f"| {route.get('method') or 'any'} | `{_cell(route['route'])}` | {handler_cell} | {site_cell} |")
+        if len(routes) > self.max_items:
+            lines.append(f"\n_... and {len(routes) - self.max_items} more routes._")
+        return lines
+
+    def dependencies(self, go_mod: Optional[Dict[str, Any]]) -> List[str]:
+        """Modules required in go.mod with the packages importing them, or imported paths without one."""
+        lines = ["## External dependencies", ""]
+        module = go_mod and go_mod.get("module")
+        importers: Dict[str, Set[str]] = {}
+        for path, parsed in self.project.files.items():
+            for imp in parsed.get("imports", []):
+                if is_std(imp["path"]) or self.project.import_dir(imp["path"], path) is not None:
+                    continue
+                if module and (imp["path"] == module or imp["path"].startswith(module + "/")):
+                    continue
+                importers.setdefault(imp["path"], set()).add(self.rel(os.path.dirname(path)))
+        if module:
+            lines.append(f"Module `{module}`" + (f", Go {go_mod['go']}." if go_mod.get("go") else "."))
+            lines.append("")
+        requires = go_mod["require"] if go_mod else []
+        if not requires and not importers:
+            lines.append("_No external dependencies._")
+            return lines
+        if requires:
+            lines += ["| Module | Version | Imported by |", "| --- | --- | --- |"]
+            for req in sorted(requires, key=lambda r: r["path"]):
+                users = sorted({pkg for path, pkgs in importers.items()
+                                if path == req["path"] or path.startswith(req["path"] + "/") for pkg in pkgs})
+                version = req["version"] + (" (indirect)" if req.get("indirect") else "")
+                used = ", ".join(f"`{u}`" for u in users) if users else "-"
+                lines.append(f"| `{req['path']}` | {version} | {used} |")
+        else:
+            lines += ["| Import | Imported by |", "| --- | --- |"]
+            for path in sorted(importers):
+                lines.append(f"| `{path}` | {', '.join(f'`{u}`' for u in sorted(importers[path]))} |")
+        return lines
+
+    def hotspots(self, rows: Optional[List[Dict[str, Any]]], error: Optional[str] = None) -> List[str]:
+        lines = ["## Hotspots", ""]
+        if rows is None:
+            lines.append(f"_Git history unavailable: {error}_" if error else "_Git history unavailable._")
+            return lines
+        rows = [r for r in rows if r["churn"] and r["type"] in ("function", "method")][:self.max_items]
+        if not rows:
+            lines.append("_No function has changed in the history considered._")
+            return lines
+        lines += ["Ranked by commits touching the symbol × cyclomatic complexity.", "",
+                  "| Symbol | Commits | Complexity | Authors | Lines |", "| --- | --- | --- | --- | --- |"]
+        for row in rows:
+            symbol = self.link(row["name"], row["path"], row["start_line"], row["start_line"] + row["lines"] - 1)
+            lines.append(f"| {symbol} | {row['churn']} | {row['complexity'] or '-'} | {row['authors']} | {row['lines']} |")
+        return lines
+
+
+def render_report(title: str, sections: List[List[str]]) -> str:
+    """Join rendered sections under a title."""
+    lines = [f"# {title}", ""]
+    for section in sections:
+        lines += section + [""]
+    return "\n".join(lines).rstrip("\n") + "\n"
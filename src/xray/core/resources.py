@@ -0,0 +1,123 @@
+"""Stable xray:// URIs for the files and packages of indexed projects.
+
+A project is known by a short name - its directory name, suffixed on a
+collision - recorded in the user cache dir, so a URI saved in one session
+resolves to the same file in the next:
+
+    xray://<project>/<path relative to the project root>
+
+A file URI reads the file; a directory URI reads the outline of the Go
+package in it, which query parameters filter as list_symbols' arguments
+filter its symbols:
+
+    xray://<project>/internal/store?kinds=func,method&exported_only=true&top_level_only=true
+"""
+
+import json
+import os
+import tempfile
+import threading
+from pathlib import Path
+from typing import Any, Dict, List, Optional, Tuple
+from urllib.parse import parse_qs, quote, unquote, urlsplit
+
+from xray.core.cache import cache_root
+
+SCHEME = "xray"
+
+
+def resource_uri(project: str, relpath: str = "") -> str:
+    """The URI of a file or package directory of a registered project."""
+    relpath = "" if relpath in ("", ".") else relpath.replace(os.sep, "/")
+    return f"{SCHEME}://{quote(project)}/{quote(relpath)}"
+
+
+def parse_uri(uri: str) -> Tuple[str, str]:
+    """Split an xray:// URI into (project name, relative path)."""
+    parts = urlsplit(str(uri))
+    if parts.scheme != SCHEME or not parts.netloc:
+        raise ValueError(f"Not an {SCHEME}:// resource URI: {uri}")
+    return unquote(parts.netloc), unquote(parts.path).strip("/")
+
+
+def outline_filters(uri: str) -> Dict[str, Any]:
+    """
+    The outline filters of a URI's query: {"kinds", "exported_only",
+    "top_level_only"} for those given. kinds may be comma-separated or
+    repeated; a flag is on for "true", "1", "yes" or no value at all.
+    """
+    query = parse_qs(urlsplit(str(uri)).query, keep_blank_values=True)
+    filters: Dict[str, Any] = {}
+    if "kinds" in query:
+        filters["kinds"] = [kind.strip() for value in query["kinds"] for kind in value.split(",") if kind.strip()]
+    for flag in ("exported_only", "top_level_only"):
+        if flag in query:
+            filters[flag] = query[flag][-1].strip().lower() in ("", "true", "1", "yes")
+    return filters
+
+
+def resource_stamp(root: str, relpath: str) -> Optional[Tuple]:
+    """
+    A cheap fingerprint of a resource for change polling: mtime and size of
+    a file, or of every file directly inside a directory. None if missing.
+    """
+    target = os.path.join(root, relpath)
+    try:
+        if os.path.isdir(target):
+            stamps = []
+            for entry in sorted(os.scandir(target), key=lambda e: e.name):
+                if entry.is_file():
+                    stat = entry.stat()
+                    stamps.append((entry.name, stat.st_mtime_ns, stat.st_size))
+            return tuple(stamps)
+        stat = os.stat(target)
+        return (stat.st_mtime_ns, stat.st_size)
+    except OSError:
+        return None
+
+
+class ProjectRegistry:
+    """Persistent project name <-> root path mapping behind resource URIs."""
+
+    def __init__(self, path: Optional[Path] = None):
+        self.path = Path(path) if path else cache_root() / "resources.json"
+        self._lock = threading.Lock()
+        self._roots: Dict[str, str] = self._load()
+
+    def _load(self) -> Dict[str, str]:
+        try:
+            data = json.loads(self.path.read_text(encoding="utf-8"))
+            return {str(k): str(v) for k, v in data.items()} if isinstance(data, dict) else {}
+        except (OSError, ValueError):
+            return {}
+
+    def _save(self):
+        try:
+            self.path.parent.mkdir(parents=True, exist_ok=True)
+            fd, tmp = tempfile.mkstemp(dir=self.path.parent, prefix=".resources-")
+            with os.fdopen(fd, "w", encoding="utf-8") as f:
+                json.dump(self._roots, f, indent=2, sort_keys=True)
+            os.replace(tmp, self.path)
+        except OSError:
+            pass
+
+    def name_for(self, root: str) -> str:
+        """Return the project's name, registering it on first sight."""
+        with self._lock:
+            for name, known in self._roots.items():
+                if known == root:
+                    return name
+            base = os.path.basename(root.rstrip(os.sep)) or "root"
+            name, n = base, 2
+            while name in self._roots:
+                name, n = f"{base}-{n}", n + 1
+            self._roots[name] = root
+            self._save()
+            return name
+
+    def root_for(self, name: str) -> Optional[str]:
+        return self._roots.get(name)
+
+    def projects(self) -> List[Tuple[str, str]]:
+        """Registered (name, root) pairs whose root still exists, by name."""
+        return [(name, root) for name, root in sorted(self._roots.items()) if os.path.isdir(root)]
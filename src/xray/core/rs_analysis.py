@@ -0,0 +1,427 @@
+"""Cross-file view of the Rust crates of a project.
+
+Module files are found the way rustc finds them: `mod foo;` in a crate
+root or a mod.rs names foo.rs or foo/mod.rs beside it, in any other file
+bar.rs it names bar/foo.rs or bar/foo/mod.rs, and a #[path] attribute
+overrides both. A file no declaration reaches is a crate root (lib.rs,
+main.rs, a binary, test, example or build script), named after the
+package of the nearest Cargo.toml when it is the package's lib.rs or
+main.rs and after its file otherwise. `use` paths are resolved through
+that module tree: crate::, self:: and super:: against the importing
+module, a leading name of a child module or local item (2018 paths), or
+another crate of the workspace by name. std, core and alloc are the
+standard library; anything else is a dependency named by its crate.
+"""
+
+import os
+import re
+from typing import Any, Dict, Iterator, List, Optional, Set, Tuple
+
+from xray.core.rs_parser import type_base
+
+STD_CRATES = {"std", "core", "alloc", "proc_macro", "test"}
+
+# Symbol kinds find_implementations can start from
+TYPE_KINDS = ("struct", "enum", "trait", "type")
+
+_SECTION = re.compile(r"^\s*\[([^\]]+)\]")
+_NAME = re.compile(r'^\s*name\s*=\s*"([^"]+)"')
+
+
+def load_crate_name(directory: str) -> Optional[str]:
+    """The library name ([lib] name, else [package] name) of the Cargo.toml in directory, as code spells it."""
+    try:
+        with open(os.path.join(directory, "Cargo.toml"), "r", encoding="utf-8") as f:
+            lines = f.read().splitlines()
+    except OSError:
+        return None
+    section = None
+    names: Dict[str, str] = {}
+    for line in lines:
+        match = _SECTION.match(line)
+        if match:
+            section = match.group(1).strip()
+            continue
+        match = _NAME.match(line)
+        if match and section in ("package", "lib"):
+            names.setdefault(section, match.group(1))
+    name = names.get("lib") or names.get("package")
+    return name.replace("-", "_") if name else None
+
+
+class RsProject:
+    """The parsed Rust files of a project, their module tree and the impls between their types and traits."""
+
+    def __init__(self, files: Dict[str, Dict[str, Any]], root: str):
+        self.files = files
+        self.root = root
+        # File -> (declaring file, mod record)
+        self.declared: Dict[str, Tuple[str, Dict[str, Any]]] = {}
+        # File -> its module path ("crate", "crate::shapes") and its crate root file
+        self.module_of: Dict[str, str] = {}
+        self.crate_of: Dict[str, str] = {}
+        # Crate root file -> crate name
+        self.crates: Dict[str, str] = {}
+        # (crate root, module path) -> (file, path of the inline module within the file)
+        self.modules: Dict[Tuple[str, str], Tuple[str, str]] = {}
+        self._manifests: Dict[str, Optional[str]] = {}
+        self._build_tree()
+        # Crate name -> root, libraries first, for `use other_crate::...`
+        self.libraries: Dict[str, str] = {}
+        for path in sorted(self.crates, key=lambda p: (os.path.basename(p) != "lib.rs", p)):
+            self.libraries.setdefault(self.crates[path], path)
+        self._resolved: Dict[Tuple[str, str, str], Dict[str, Any]] = {}
+        self._index_types()
+
+    # ------------------------------------------------------------------
+    # Module tree
+    # ------------------------------------------------------------------
+
+    def mod_file(self, path: str, decl: Dict[str, Any], owns_dir: Optional[bool] = None) -> Optional[str]:
+        """The indexed file a `mod name;` declaration in path names, or None."""
+        base = os.path.dirname(path)
+        if decl.get("path"):
+            candidate = os.path.normpath(os.path.join(base, decl["path"]))
+            return candidate if candidate in self.files else None
+        if owns_dir is None:
+            owns_dir = os.path.basename(path) in ("mod.rs", "lib.rs", "main.rs") or path in self.crates
+        directory = base if owns_dir else os.path.join(base, os.path.splitext(os.path.basename(path))[0])
+        if decl.get("module_path"):
+            directory = os.path.join(directory, *decl["module_path"].split("::"))
+        for candidate in (os.path.join(directory, decl["name"] + ".rs"),
+                          os.path.join(directory, decl["name"], "mod.rs")):
+            if candidate in self.files:
+                return candidate
+        return None
+
+    def _build_tree(self):
+        external = {path: [m for m in parsed.get("mods", []) if not m["inline"]] for path, parsed in self.files.items()}
+
+        def claim(path: str, owns_dir: Optional[bool]):
+            for decl in external[path]:
+                child = self.mod_file(path, decl, owns_dir)
+                if child is not None and child != path and child not in self.declared:
+                    self.declared[child] = (path, decl)
+
+        for path in sorted(self.files):
+            claim(path, None)
+        # Any root owns its directory like lib.rs does (tests/it.rs, src/bin/tool.rs)
+        for path in sorted(p for p in self.files if p not in self.declared):
+            claim(path, True)
+
+        pending = sorted(p for p in self.files if p not in self.declared)
+        while pending:
+            for root in pending:
+                self.crates[root] = self._crate_name(root)
+                self._walk(root)
+            # A declaration cycle leaves files unreached; each becomes a crate of its own
+            pending = sorted(p for p in self.files if p not in self.crate_of)
+
+    def _walk(self, root: str):
+        queue = [root]
+        self.module_of[root] = "crate"
+        while queue:
+            path = queue.pop(0)
+            if path in self.crate_of:
+                continue
+            self.crate_of[path] = root
+            module = self.module_of[path]
+            self.modules.setdefault((root, module), (path, ""))
+            for decl in self.files[path].get("mods", []):
+                scope = decl.get("module_path", "")
+                full = "::".join(filter(None, [module, scope, decl["name"]]))
+                if decl["inline"]:
+                    self.modules.setdefault((root, full), (path, "::".join(filter(None, [scope, decl["name"]]))))
+                    continue
+                child = self.mod_file(path, decl, True if path == root else None)
+                if child is not None and self.declared.get(child, (None,))[0] == path and child not in self.crate_of:
+                    self.module_of[child] = full
+                    queue.append(child)
+
+    def _crate_name(self, root: str) -> str:
+        """Package name for a package's src/lib.rs or src/main.rs, the file (or its directory) otherwise."""
+        stem = os.path.splitext(os.path.basename(root))[0]
+        directory = os.path.dirname(root)
+        if stem in ("lib", "main") and os.path.basename(directory) == "src":
+            package_dir = os.path.dirname(directory)
+            if package_dir not in self._manifests:
+                self._manifests[package_dir] = load_crate_name(package_dir)
+            return self._manifests[package_dir] or os.path.basename(package_dir).replace("-", "_")
+        if stem in ("main", "mod", "lib"):
+            return os.path.basename(directory).replace("-", "_")
+        return stem.replace("-", "_")
+
+    def dotted_name(self, path: str) -> str:
+        """A file's module path with its crate's name in place of `crate` ("geo::shapes::circle")."""
+        module = self.module_of.get(path, "crate")
+        crate = self.crates.get(self.crate_of.get(path, ""), "crate")
+        return crate + module[len("crate"):]
+
+    def module_name(self, path: str, scope: str = "") -> str:
+        """The module path of an inline module of a file, or of the file itself."""
+        return "::".join(filter(None, [self.module_of.get(path, "crate"), scope]))
+
+    def mod_children(self, path: str) -> List[Tuple[Dict[str, Any], str]]:
+        """(mod record, file) for each `mod name;` of path that names an indexed file."""
+        return [(decl, child) for child, (parent, decl) in sorted(self.declared.items()) if parent == path]
+
+    def symbols(self) -> Iterator[Tuple[str, Dict[str, Any]]]:
+        """(path, symbol) for every declaration, files in sorted order."""
+        for path in sorted(self.files):
+            for symbol in self.files[path]["symbols"]:
+                yield path, symbol
+
+    # ------------------------------------------------------------------
+    # use paths
+    # ------------------------------------------------------------------
+
+    @staticmethod
+    def _parent(module: str) -> str:
+        return module.rsplit("::", 1)[0] if "::" in module else module
+
+    def resolve(self, importer: str, path: str, scope: str = "") -> Dict[str, Any]:
+        """
+        Resolve a use path (or the path of a reference) written in importer,
+        inside the inline module scope if given.
+
+        Returns:
+            {"origin": internal|std|external, "crate": name, "module": the
+            deepest module the path names, "resolved": the file holding it}
+            - module and resolved are None outside the project
+        """
+        key = (importer, path, scope)
+        if key in self._resolved:
+            return self._resolved[key]
+        segments = path.split("::")
+        absolute = segments[0] == ""
+        if absolute:
+            segments = segments[1:]
+        root = self.crate_of.get(importer)
+        current = self.module_name(importer, scope)
+        first = segments[0] if segments else ""
+        result = None
+        if root is None:
+            base, rest = None, []
+        elif first == "crate" and not absolute:
+            base, rest = "crate", segments[1:]
+        elif first in ("self", "super") and not absolute:
+            base, rest = current, segments
+            while rest and rest[0] in ("self", "super"):
+                if rest[0] == "super":
+                    base = self._parent(base)
+                rest = rest[1:]
+        elif not absolute and ((root, f"{current}::{first}") in self.modules or self._declares(importer, scope, first)):
+            base, rest = current, segments
+        elif first in self.libraries:
+            root = self.libraries[first]
+            base, rest = "crate", segments[1:]
+        else:
+            base, rest = None, []
+            result = {"origin": "std" if first in STD_CRATES else "external", "crate": first,
+                      "module": None, "resolved": None}
+        if result is None and base is None:
+            result = {"origin": "external", "crate": first, "module": None, "resolved": None}
+        if result is None:
+            module = base
+            for segment in rest:
+                if (root, f"{module}::{segment}") not in self.modules:
+                    break
+                module = f"{module}::{segment}"
+            found = self.modules.get((root, module))
+            result = {"origin": "internal", "crate": self.crates[root], "module": module,
+                      "resolved": found[0] if found else None}
+        self._resolved[key] = result
+        return result
+
+    def _declares(self, path: str, scope: str, name: str) -> bool:
+        """Whether an item called name is declared at the top of the given inline module of path."""
+        return any(s["name"] == name and "container" not in s and s.get("module_path", "") == scope
+                   for s in self.files[path]["symbols"])
+
+    def imports_of(self, path: str) -> List[Dict[str, Any]]:
+        """The use items and extern crates of a file, each with where it resolves to (see resolve)."""
+        return [{**imp, **self.resolve(path, imp["path"], imp.get("module_path", ""))}
+                for imp in self.files[path]["imports"]]
+
+    def _expand(self, path: str, written: str, scope: str = "") -> Optional[Dict[str, Any]]:
+        """
+        Resolve a path as written in code: a leading name brought in by a use
+        item is replaced by what it imports. None for a bare name that is
+        neither imported nor declared there (a prelude item, or a glob import).
+        """
+        segments = written.split("::")
+        for imp in self.files[path]["imports"]:
+            if imp["kind"] != "glob" and imp["local"] == segments[0] and imp.get("module_path", "") == scope:
+                return self.resolve(path, "::".join([imp["path"]] + segments[1:]), scope)
+        if len(segments) == 1:
+            if self._declares(path, scope, written):
+                return {"origin": "internal", "crate": self.crates.get(self.crate_of.get(path)),
+                        "module": self.module_name(path, scope), "resolved": path}
+            return None
+        return self.resolve(path, written, scope)
+
+    # ------------------------------------------------------------------
+    # Types, traits and impls
+    # ------------------------------------------------------------------
+
+    def _index_types(self):
+        self._type_records: List[Dict[str, Any]] = []
+        # (directory, name) -> record, the key type_hierarchy_diagram looks types up by
+        self.types: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        for path, symbol in self.symbols():
+            if symbol["type"] not in TYPE_KINDS or "container" in symbol:
+                continue
+            scope = symbol.get("module_path", "")
+            record = {
+                "name": symbol["name"],
+                "type": symbol["type"],
+                "package": "::".join(filter(None, [self.dotted_name(path), scope])),
+                "path": path,
+                "start_line": symbol["start_line"],
+                "language": "rust",
+                "module": self.module_name(path, scope),
+                "crate_root": self.crate_of.get(path),
+                "symbol": symbol,
+            }
+            self._type_records.append(record)
+            self.types.setdefault((os.path.dirname(path), symbol["name"]), record)
+        self._impls = [(path, impl) for path in sorted(self.files) for impl in self.files[path].get("impls", [])]
+
+    @staticmethod
+    def _public(record: Dict[str, Any]) -> Dict[str, Any]:
+        return {key: record[key] for key in ("name", "type", "package", "path", "start_line", "language")}
+
+    def find_types(self, name: str, path: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Structs, enums, traits and type aliases named `name`, optionally restricted to one file or directory."""
+        return [self._public(r) for r in self._type_records if r["name"] == name and
+                (not path or r["path"] == path or os.path.dirname(r["path"]) == path.rstrip(os.sep))]
+
+    def _record(self, entry: Dict[str, Any]) -> Dict[str, Any]:
+        return next(r for r in self._type_records if r["path"] == entry["path"] and
+                    r["start_line"] == entry["start_line"] and r["name"] == entry["name"])
+
+    def _refers_to(self, path: str, written: str, scope: str, record: Dict[str, Any]) -> bool:
+        """Whether a type or trait path written in path may name record (unresolvable bare names may)."""
+        name, prefix = type_base(written)
+        if name != record["name"]:
+            return False
+        target = self._expand(path, f"{prefix}::{name}" if prefix else name, scope)
+        if target is None:
+            # Prelude, glob import or macro-generated name: only the same crate can tell
+            return self.crate_of.get(path) == record["crate_root"] or record["symbol"]["exported"]
+        return target["origin"] == "internal" and target["module"] == record["module"] and \
+            target["crate"] == self.crates.get(record["crate_root"])
+
+    def _lookup(self, path: str, written: str, scope: str, kinds: Tuple[str, ...]) -> Optional[Dict[str, Any]]:
+        """The project type or trait a written path names, if any."""
+        name = type_base(written)[0]
+        candidates = [r for r in self._type_records if r["name"] == name and r["type"] in kinds]
+        return next((r for r in candidates if self._refers_to(path, written, scope, r)), None)
+
+    def _derived(self, record: Dict[str, Any], trait: Dict[str, Any]) -> bool:
+        """Whether the type behind record derives trait (a project trait with a derive macro of its name)."""
+        for written in record["symbol"].get("derives", []):
+            if self._refers_to(record["path"], written, record["symbol"].get("module_path", ""), trait):
+                return True
+        return False
+
+    def implementations_of(self, trait_entry: Dict[str, Any]) -> Dict[str, Any]:
+        """
+        The types with an impl of a trait (explicit, blanket over a type
+        parameter, or derived), in find_implementations' interface shape.
+        Rust impls are explicit, so there are never partial matches.
+        """
+        trait = self._record(trait_entry)
+        symbol = trait["symbol"]
+        provided = [m for m in symbol.get("methods", []) if m not in symbol.get("required_methods", [])]
+        complete = []
+        for path, impl in self._impls:
+            if impl.get("trait") != trait["name"] or impl.get("negative"):
+                continue
+            if not self._refers_to(path, impl["trait_path"], impl.get("module_path", ""), trait):
+                continue
+            target = self._lookup(path, impl["type_text"], impl.get("module_path", ""), ("struct", "enum", "type"))
+            if target:
+                entry = self._public(target)
+                entry.pop("type")
+            else:
+                # A type of another crate, or the type parameter of a blanket impl
+                entry = {"name": impl["type"], "package": "" if impl.get("blanket") else self.dotted_name(path),
+                         "path": path, "start_line": impl["line"], "language": "rust"}
+                if not impl.get("blanket"):
+                    entry["external"] = True
+            entry.update({"satisfied_by": impl["type_text"], "impl": {"path": path, "line": impl["line"]},
+                          "methods": sorted(impl["methods"])})
+            defaulted = [m for m in provided if m not in impl["methods"]]
+            if defaulted:
+                entry["defaulted"] = defaulted
+            if impl.get("blanket"):
+                entry["blanket"] = impl["generics"]
+            complete.append(entry)
+        for record in self._type_records:
+            if record["type"] != "trait" and self._derived(record, trait):
+                entry = self._public(record)
+                entry.pop("type")
+                complete.append({**entry, "satisfied_by": record["name"], "derived": True})
+
+        return {
+            "interface": {**self._public(trait), "methods": sorted(symbol.get("methods", []))},
+            "implementations": complete,
+            "partial": [],
+            "total_count": len(complete),
+        }
+
+    def interfaces_of(self, type_entry: Dict[str, Any]) -> Dict[str, Any]:
+        """The traits a type implements or derives, in find_implementations' type shape."""
+        record = self._record(type_entry)
+        symbol = record["symbol"]
+        complete = []
+        methods: Set[str] = set()
+        for path, impl in self._impls:
+            if impl["type"] != record["name"] or impl.get("negative"):
+                continue
+            scope = impl.get("module_path", "")
+            if not self._refers_to(path, impl["type_text"], scope, record):
+                continue
+            methods.update(impl["methods"])
+            if not impl.get("trait"):
+                continue
+            trait = self._lookup(path, impl["trait_path"], scope, ("trait",))
+            if trait:
+                entry = self._public(trait)
+                entry.pop("type")
+            else:
+                target = self._expand(path, impl["trait_path"], scope)
+                entry = {"name": impl["trait"], "package": target["crate"] if target else "",
+                         "path": path, "start_line": impl["line"], "language": "rust", "external": True}
+            entry.update({"satisfied_by": impl["type_text"], "impl": {"path": path, "line": impl["line"]},
+                          "methods": sorted(impl["methods"])})
+            complete.append(entry)
+        for written in symbol.get("derives", []):
+            trait = self._lookup(record["path"], written, symbol.get("module_path", ""), ("trait",))
+            if trait:
+                entry = self._public(trait)
+                entry.pop("type")
+            else:
+                name, prefix = type_base(written)
+                entry = {"name": name, "package": prefix, "path": record["path"],
+                         "start_line": record["start_line"], "language": "rust", "external": True}
+            complete.append({**entry, "satisfied_by": record["name"], "derived": True})
+
+        return {
+            "type": {**self._public(record), "methods": sorted(methods)},
+            "interfaces": complete,
+            "partial": [],
+            "total_count": len(complete),
+        }
+
+    def embeds_of(self, key: Tuple[str, str]) -> List[Tuple[str, Optional[Tuple[str, str]]]]:
+        """The supertraits of a trait, as written and resolved to a types key (None outside the project)."""
+        record = self.types.get(key)
+        if record is None or record["type"] != "trait":
+            return []
+        results = []
+        for written in record["symbol"].get("supertraits", []):
+            target = self._lookup(record["path"], written, record["symbol"].get("module_path", ""), ("trait",))
+            results.append((written, (os.path.dirname(target["path"]), target["name"]) if target else None))
+        return results
@@ -0,0 +1,960 @@
+"""Rust source parser for XRAY - tokenizer and item scanner.
+
+Like the other native parsers this is a small lexer plus a parser for the
+items that matter to the index: `mod` declarations and `use` trees,
+structs, enums, unions, traits, impl blocks and their associated items,
+functions, consts, statics, type aliases and `macro_rules!` macros.
+Function bodies and initializers are only skipped over as balanced token
+ranges. Macro invocations are recorded by name wherever they appear,
+without expansion, and derive lists are kept on the type they decorate.
+"""
+
+import bisect
+import re
+from typing import Any, Dict, List, Optional, Tuple
+
+# Bump whenever the shape of extracted records changes so cached results are discarded
+RS_PARSER_VERSION = 2
+
+# Longest punctuators first. `>>`, `>=` and `<<` are left as single characters
+# so that closing generics (`Vec<Vec<u8>>`) can be counted one by one.
+PUNCTUATORS = [
+    "...", "..=", "::", "->", "=>", "==", "!=", "<=", "&&", "||", "+=", "-=", "*=", "/=",
+    "%=", "^=", "&=", "|=", "..",
+    "{", "}", "(", ")", "[", "]", ";", ",", ".", "<", ">", "+", "-", "*", "/", "%", "^",
+    "!", "&", "|", "=", "@", "#", "$", "?", ":", "~",
+]
+
+# Words that may precede the keyword of an item
+_QUALIFIERS = {"default", "unsafe", "async", "auto", "safe"}
+
+# Longest signature text quoted from an item head or initializer
+MAX_SIGNATURE = 120
+
+_IDENT = re.compile(r"[^\W\d]\w*")
+_NUMBER = re.compile(r"0[xob][0-9a-fA-F_]*\w*|\d[\d_]*(?:\.\d[\d_]*|\.(?![.\w]))?(?:[eE][+-]?\d[\d_]*)?\w*")
+_RAW_STRING = re.compile(r'(?:b|c)?r(#*)"')
+# The path at the start of a written type, after references, `dyn` and lifetimes
+_TYPE_PATH = re.compile(r"^(?:&\s*|'\w+\s*|(?:mut|dyn|impl|const)\s+|\*\s*|\?\s*)*((?:::)?(?:\w+\s*::\s*)*)(\w+)")
+_GENERIC_NAMES = re.compile(r"[<,]\s*(?:const\s+)?([A-Za-z_]\w*)")
+
+
+def type_base(written: str) -> Tuple[str, str]:
+    """
+    The name a written type or trait comes down to, and the path in front
+    of it ("&mut fmt::Formatter<'_>" -> ("Formatter", "fmt")); ("", "")
+    for tuples, slices and other types without a name.
+    """
+    match = _TYPE_PATH.match(written.strip())
+    if not match:
+        return "", ""
+    return match.group(2), re.sub(r"\s+", "", match.group(1)).rstrip(":")
+
+
+class Token:
+    """A single Rust token with its source position."""
+
+    __slots__ = ("kind", "value", "line", "col", "end_line", "start", "end")
+
+    def __init__(self, kind, value, start, end):
+        self.kind = kind
+        self.value = value
+        self.start = start
+        self.end = end
+        self.line = self.col = self.end_line = 0
+
+    def __repr__(self):
+        return f"Token({self.kind}, {self.value!r}, {self.line}:{self.col})"
+
+
+def tokenize(src: str) -> Tuple[List[Token], List[Token]]:
+    """
+    Split Rust source into tokens.
+
+    Returns:
+        (tokens, doc comments) - plain comments are dropped; `///` and `/** */`
+        come back as "doc", `//!` and `/*! */` as "inner_doc"
+    """
+    tokens: List[Token] = []
+    comments: List[Token] = []
+    i, n = 0, len(src)
+    if src.startswith("#!") and not src.startswith("#!["):
+        end = src.find("\n")
+        i = n if end == -1 else end
+    while i < n:
+        ch = src[i]
+        if ch.isspace():
+            i += 1
+            continue
+        if src.startswith("//", i):
+            end = src.find("\n", i)
+            end = n if end == -1 else end
+            text = src[i:end]
+            if text.startswith("///") and not text.startswith("////"):
+                comments.append(Token("doc", text, i, end))
+            elif text.startswith("//!"):
+                comments.append(Token("inner_doc", text, i, end))
+            i = end
+            continue
+        if src.startswith("/*", i):
+            # Block comments nest
+            depth, j = 1, i + 2
+            while j < n and depth:
+                if src.startswith("/*", j):
+                    depth += 1
+                    j += 2
+                elif src.startswith("*/", j):
+                    depth -= 1
+                    j += 2
+                else:
+                    j += 1
+            text = src[i:j]
+            if text.startswith("/**") and not text.startswith("/***") and text != "/**/":
+                comments.append(Token("doc", text, i, j))
+            elif text.startswith("/*!"):
+                comments.append(Token("inner_doc", text, i, j))
+            i = j
+            continue
+        raw = _RAW_STRING.match(src, i) if ch in "bcr" else None
+        if raw:
+            closer = '"' + raw.group(1)
+            end = src.find(closer, raw.end())
+            end = n if end == -1 else end + len(closer)
+            tokens.append(Token("string", src[i:end], i, end))
+            i = end
+            continue
+        if ch == '"' or (ch in "bc" and src.startswith('"', i + 1)):
+            j = i + (1 if ch == '"' else 2)
+            while j < n and src[j] != '"':
+                j += 2 if src[j] == "\\" else 1
+            tokens.append(Token("string", src[i:j + 1], i, j + 1))
+            i = j + 1
+            continue
+        if ch == "'" or (ch == "b" and src.startswith("'", i + 1)):
+            j = i + (1 if ch == "'" else 2)
+            if src.startswith("\\", j):
+                end = src.find("'", j + 2)
+                end = n if end == -1 else end + 1
+                tokens.append(Token("char", src[i:end], i, end))
+                i = end
+                continue
+            if src.startswith("'", j + 1):
+                tokens.append(Token("char", src[i:j + 2], i, j + 2))
+                i = j + 2
+                continue
+            if ch == "'":
+                # A lifetime or loop label: 'a, 'static, '_
+                match = _IDENT.match(src, j)
+                end = match.end() if match else j
+                tokens.append(Token("lifetime", src[i:end], i, end))
+                i = end
+                continue
+        if src.startswith("r#", i):
+            match = _IDENT.match(src, i + 2)
+            if match:
+                # Raw identifier: r#type is the identifier "type"
+                tokens.append(Token("ident", match.group(), i, match.end()))
+                i = match.end()
+                continue
+        match = _IDENT.match(src, i)
+        if match:
+            tokens.append(Token("ident", match.group(), i, match.end()))
+            i = match.end()
+            continue
+        if ch.isdigit():
+            match = _NUMBER.match(src, i)
+            tokens.append(Token("number", match.group(), i, match.end()))
+            i = match.end()
+            continue
+        value = next((p for p in PUNCTUATORS if src.startswith(p, i)), ch)
+        tokens.append(Token("punct", value, i, i + len(value)))
+        i += len(value)
+
+    line_starts = [0] + [m.end() for m in re.finditer("\n", src)]
+
+    def locate(offset: int) -> Tuple[int, int]:
+        line = bisect.bisect_right(line_starts, offset)
+        return line, offset - line_starts[line - 1] + 1
+
+    for tok in tokens + comments:
+        tok.line, tok.col = locate(tok.start)
+        tok.end_line = locate(max(tok.end - 1, tok.start))[0]
+    return tokens, comments
+
+
+def unquote(literal: str) -> str:
+    """Return the contents of a string literal (plain, byte or raw) without its quotes."""
+    match = re.match(r'^(?:b|c)?r?(#*)"(.*)"\1$', literal, re.DOTALL)
+    return match.group(2) if match else literal
+
+
+def _clip(text: str) -> str:
+    return text if len(text) <= MAX_SIGNATURE else text[:MAX_SIGNATURE - 3].rstrip() + "..."
+
+
+def _doc_text(comment: Token) -> str:
+    text = comment.value
+    if text.startswith(("///", "//!")):
+        text = text[3:]
+        return text[1:] if text.startswith(" ") else text
+    lines = []
+    for line in text[3:-2].splitlines():
+        line = line.strip()
+        if line.startswith("*"):
+            line = line[1:]
+        lines.append(line[1:] if line.startswith(" ") else line)
+    return "\n".join(lines).strip("\n")
+
+
+class RsFileParser:
+    """Parse a Rust file into use, mod, impl and symbol records."""
+
+    def __init__(self, content: str):
+        self.src = content
+        self.tokens, self.comments = tokenize(content)
+        self._comment_starts = [c.start for c in self.comments]
+        self.imports: List[Dict[str, Any]] = []
+        self.mods: List[Dict[str, Any]] = []
+        self.impls: List[Dict[str, Any]] = []
+        self.symbols: List[Dict[str, Any]] = []
+        # Token indexes inside `use` items, whose paths are not references
+        self._in_use = [False] * len(self.tokens)
+        self.pairs: Dict[int, int] = {}
+        stack: List[int] = []
+        closers = {")": "(", "]": "[", "}": "{"}
+        for idx, tok in enumerate(self.tokens):
+            if tok.kind != "punct":
+                continue
+            if tok.value in "([{":
+                stack.append(idx)
+            elif tok.value in closers:
+                # A stray closer only pops the opener it matches
+                if stack and self.tokens[stack[-1]].value == closers[tok.value]:
+                    self.pairs[stack.pop()] = idx
+        for idx in stack:
+            self.pairs[idx] = len(self.tokens) - 1
+
+    # ------------------------------------------------------------------
+    # Token helpers
+    # ------------------------------------------------------------------
+
+    def _v(self, idx: int) -> Optional[str]:
+        return self.tokens[idx].value if 0 <= idx < len(self.tokens) else None
+
+    def _kind(self, idx: int) -> Optional[str]:
+        return self.tokens[idx].kind if 0 <= idx < len(self.tokens) else None
+
+    def _text(self, start: int, end: int) -> str:
+        """Source text of tokens[start:end] with whitespace collapsed."""
+        if start >= end or start >= len(self.tokens):
+            return ""
+        end = min(end, len(self.tokens))
+        return re.sub(r"\s+", " ", self.src[self.tokens[start].start:self.tokens[end - 1].end])
+
+    def _match(self, idx: int) -> int:
+        """Index of the bracket closing the one at idx."""
+        return self.pairs.get(idx, idx)
+
+    def _is_open(self, idx: int) -> bool:
+        return self._kind(idx) == "punct" and self._v(idx) in ("(", "[", "{")
+
+    def _match_angle(self, idx: int) -> int:
+        """Index of the `>` closing the `<` at idx."""
+        depth = 0
+        j = idx
+        while j < len(self.tokens):
+            value = self._v(j)
+            if self._is_open(j):
+                j = self._match(j) + 1
+                continue
+            if value == "<":
+                depth += 1
+            elif value == ">":
+                depth -= 1
+                if depth == 0:
+                    return j
+            elif value == ";":
+                break
+            j += 1
+        return min(j, len(self.tokens) - 1)
+
+    def _split(self, start: int, end: int, sep: str = ",") -> List[Tuple[int, int]]:
+        """Ranges of tokens[start:end] between top-level separators, generics counted as brackets."""
+        ranges = []
+        depth = 0
+        first = j = start
+        while j < end:
+            value = self._v(j)
+            if self._is_open(j):
+                j = self._match(j) + 1
+                continue
+            if value == "<":
+                depth += 1
+            elif value == ">" and depth:
+                depth -= 1
+            elif value == sep and depth == 0:
+                if j > first:
+                    ranges.append((first, j))
+                first = j + 1
+            j += 1
+        if end > first:
+            ranges.append((first, end))
+        return ranges
+
+    def _find(self, start: int, end: int, values: Tuple[str, ...]) -> int:
+        """First top-level token in values (outside brackets and generics), or end."""
+        depth = 0
+        j = start
+        while j < end:
+            value = self._v(j)
+            if self._is_open(j):
+                if depth == 0 and value in values:
+                    return j
+                j = self._match(j) + 1
+                continue
+            if depth == 0 and value in values:
+                return j
+            if value == "<":
+                depth += 1
+            elif value == ">" and depth:
+                depth -= 1
+            j += 1
+        return end
+
+    def _head_end(self, idx: int, end: int) -> Tuple[int, Optional[int]]:
+        """Where an item head stops: the `{` of its body or its `;`, and its `where` clause if any."""
+        where = None
+        j = idx
+        while j < end:
+            value = self._v(j)
+            if value in ("{", ";"):
+                return j, where
+            if self._is_open(j):
+                j = self._match(j) + 1
+                continue
+            if value == "where" and where is None:
+                where = j
+            j += 1
+        return end, where
+
+    def _skip(self, idx: int, end: int) -> int:
+        """Skip an unrecognized item: up to a `;` or past a block."""
+        j = idx
+        while j < end:
+            if self._v(j) == "{":
+                return self._match(j) + 1
+            if self._is_open(j):
+                j = self._match(j) + 1
+                continue
+            if self._v(j) == ";":
+                return j + 1
+            j += 1
+        return end
+
+    # ------------------------------------------------------------------
+    # Attributes, visibility and docs
+    # ------------------------------------------------------------------
+
+    def _attributes(self, idx: int, end: int) -> Tuple[List[Tuple[int, int]], int]:
+        """The outer attributes at idx, as (`#`, `]`) token indexes; inner ones are skipped."""
+        attrs = []
+        while idx < end and self._v(idx) == "#":
+            bang = self._v(idx + 1) == "!"
+            bracket = idx + (2 if bang else 1)
+            if self._v(bracket) != "[":
+                break
+            close = self._match(bracket)
+            if not bang:
+                attrs.append((idx, close))
+            idx = close + 1
+        return attrs, idx
+
+    def _visibility(self, idx: int) -> Tuple[str, int]:
+        if self._v(idx) != "pub":
+            return "", idx
+        if self._v(idx + 1) == "(" and self._v(idx + 2) in ("crate", "self", "super", "in"):
+            close = self._match(idx + 1)
+            return self._text(idx, close + 1), close + 1
+        return "pub", idx + 1
+
+    def _attribute_info(self, attrs: List[Tuple[int, int]]) -> Dict[str, Any]:
+        """derive lists, #[doc], #[deprecated], #[path] and the other attributes as written."""
+        info: Dict[str, Any] = {"derives": [], "attributes": [], "doc": []}
+        for hash_idx, close in attrs:
+            start = hash_idx + 2
+            name_end = start
+            while name_end < close and (self._kind(name_end) == "ident" or self._v(name_end) == "::"):
+                name_end += 1
+            name = self._text(start, name_end).replace(" ", "")
+            after = self._v(name_end)
+            if name == "derive" and after == "(":
+                info["derives"] += [self._text(a, b).replace(" ", "")
+                                    for a, b in self._split(name_end + 1, self._match(name_end))]
+            elif name == "doc" and after == "=" and self._kind(name_end + 1) == "string":
+                info["doc"].append(unquote(self._v(name_end + 1)).strip())
+            elif name == "path" and after == "=" and self._kind(name_end + 1) == "string":
+                info["path"] = unquote(self._v(name_end + 1))
+            elif name == "deprecated":
+                info["deprecated"] = True
+                if after == "=" and self._kind(name_end + 1) == "string":
+                    info["deprecation"] = unquote(self._v(name_end + 1))
+                elif after == "(":
+                    for a, b in self._split(name_end + 1, self._match(name_end)):
+                        if self._v(a) == "note" and self._v(a + 1) == "=" and self._kind(a + 2) == "string":
+                            info["deprecation"] = unquote(self._v(a + 2))
+            else:
+                info["attributes"].append(self._text(hash_idx, close + 1))
+        return info
+
+    def _doc_for(self, first: int, keyword: int) -> str:
+        """
+        The doc comments of an item: the block of `///` lines ending on the
+        line before its first attribute, and any between its attributes.
+        """
+        first_tok = self.tokens[first]
+        pos = bisect.bisect_left(self._comment_starts, first_tok.start)
+        block: List[Token] = []
+        line = first_tok.line
+        back = pos - 1
+        while back >= 0 and self.comments[back].kind == "doc" and self.comments[back].end_line == line - 1:
+            block.insert(0, self.comments[back])
+            line = self.comments[back].line
+            back -= 1
+        stop = self.tokens[keyword].start
+        while pos < len(self.comments) and self.comments[pos].start < stop:
+            if self.comments[pos].kind == "doc":
+                block.append(self.comments[pos])
+            pos += 1
+        return "\n".join(_doc_text(c) for c in block).strip()
+
+    # ------------------------------------------------------------------
+    # Items
+    # ------------------------------------------------------------------
+
+    def parse(self) -> Dict[str, Any]:
+        """Parse the whole file and return use, mod, impl, symbol and macro records."""
+        self._items(0, len(self.tokens), [], None)
+        module_doc = "\n".join(_doc_text(c) for c in self.comments if c.kind == "inner_doc").strip()
+        return {
+            "doc": module_doc,
+            "imports": self.imports,
+            "mods": self.mods,
+            "impls": self.impls,
+            "symbols": self.symbols,
+            "macro_calls": self._macro_calls(),
+            "qualified_refs": self._qualified_refs(),
+        }
+
+    def _items(self, start: int, end: int, scope: List[str], owner: Optional[Dict[str, Any]]):
+        """Parse the items in tokens[start:end] - a file, inline module, trait, impl or extern block."""
+        pos = start
+        while pos < end:
+            pos = max(self._item(pos, end, scope, owner), pos + 1)
+
+    def _item(self, pos: int, end: int, scope: List[str], owner: Optional[Dict[str, Any]]) -> int:
+        start = pos
+        attrs, pos = self._attributes(pos, end)
+        if pos >= end:
+            return pos
+        head = pos
+        visibility, pos = self._visibility(pos)
+        qualifiers: List[str] = []
+        abi = None
+        while pos < end:
+            value = self._v(pos)
+            if value in _QUALIFIERS and self._kind(pos + 1) == "ident":
+                qualifiers.append(value)
+                pos += 1
+            elif value == "const" and self._v(pos + 1) in ("fn", "unsafe", "async", "extern"):
+                qualifiers.append(value)
+                pos += 1
+            elif value == "extern" and self._v(pos + 1) != "crate":
+                pos += 1
+                if self._kind(pos) == "string":
+                    abi = unquote(self._v(pos))
+                    pos += 1
+                if self._v(pos) == "{":
+                    # Foreign functions and statics declared by an extern block
+                    close = self._match(pos)
+                    self._items(pos + 1, close, scope, {"kind": "extern", "abi": abi or "C"})
+                    return close + 1
+                qualifiers.append("extern")
+            else:
+                break
+
+        item = {"start": start, "head": head, "attrs": attrs, "visibility": visibility,
+                "qualifiers": qualifiers, "abi": abi, "scope": scope, "owner": owner}
+        value = self._v(pos)
+        if self._kind(pos) != "ident":
+            return self._skip(pos, end)
+        if value == "fn":
+            return self._function(item, pos, end)
+        if value in ("struct", "enum") or (value == "union" and self._kind(pos + 1) == "ident"):
+            return self._adt(item, pos, end)
+        if value == "trait":
+            return self._trait(item, pos, end)
+        if value == "impl":
+            return self._impl(item, pos, end)
+        if value == "mod" and self._kind(pos + 1) == "ident":
+            return self._mod(item, pos, end)
+        if value == "use":
+            return self._use(item, pos, end)
+        if value == "type" and self._kind(pos + 1) == "ident":
+            return self._type_alias(item, pos, end)
+        if value in ("const", "static") and self._kind(pos + 1) == "ident":
+            return self._const(item, pos, end)
+        if value == "macro_rules" and self._v(pos + 1) == "!" and self._kind(pos + 2) == "ident":
+            return self._macro_rules(item, pos, end)
+        if value == "extern" and self._v(pos + 1) == "crate":
+            return self._extern_crate(item, pos, end)
+        return self._skip(pos, end)
+
+    def _symbol(self, item: Dict[str, Any], name_idx: int, kind: str, last: int, signature: str,
+                **extra) -> Dict[str, Any]:
+        """Record a symbol spanning tokens[item start..last]."""
+        owner = item["owner"]
+        info = self._attribute_info(item["attrs"])
+        doc = self._doc_for(item["start"], name_idx)
+        if info["doc"]:
+            doc = "\n".join(filter(None, [doc] + info["doc"]))
+        visibility = item["visibility"]
+        if owner is not None and owner["kind"] == "trait":
+            # Trait items are as visible as the trait
+            visibility, exported = "inherited", owner["exported"]
+        elif owner is not None and owner["kind"] == "impl" and owner.get("trait"):
+            # So are the items of a trait impl, written without pub
+            visibility, exported = "inherited", True
+        else:
+            exported = visibility == "pub"
+        symbol = {
+            "name": self._v(name_idx),
+            "type": kind,
+            "signature": _clip(signature),
+            "start_line": self.tokens[item["start"]].line,
+            "column": self.tokens[name_idx].col,
+            "end_line": self.tokens[min(max(last, item["start"]), len(self.tokens) - 1)].end_line,
+            "doc": doc,
+            "exported": exported,
+            "visibility": visibility or "private",
+            "language": "rust",
+        }
+        if owner is not None and owner.get("name"):
+            symbol["container"] = owner["name"]
+        if item["scope"]:
+            symbol["module_path"] = "::".join(item["scope"])
+        if info["derives"]:
+            symbol["derives"] = info["derives"]
+        if info["attributes"]:
+            symbol["attributes"] = info["attributes"]
+        if info.get("deprecated"):
+            symbol["deprecated"] = True
+            if info.get("deprecation"):
+                symbol["deprecation"] = info["deprecation"]
+        symbol.update(extra)
+        self.symbols.append(symbol)
+        return symbol
+
+    def _generics(self, idx: int) -> Tuple[Optional[str], int]:
+        if self._v(idx) != "<":
+            return None, idx
+        close = self._match_angle(idx)
+        return self._text(idx, close + 1), close + 1
+
+    def _params(self, start: int, end: int) -> Tuple[List[Dict[str, Any]], Optional[str]]:
+        """The parameters between a function's parentheses, and its self parameter as written."""
+        params = []
+        self_param = None
+        for a, b in self._split(start, end):
+            _, a = self._attributes(a, b)
+            colon = self._find(a, b, (":",))
+            pattern = self._text(a, colon)
+            if not params and self_param is None and re.fullmatch(r"(&\s*('\w+\s*)?)?(mut\s+)?self", pattern):
+                self_param = self._text(a, b)
+                continue
+            param = {"name": pattern}
+            if colon < b:
+                param["type"] = self._text(colon + 1, b)
+            params.append(param)
+        return params, self_param
+
+    def _function(self, item: Dict[str, Any], pos: int, end: int) -> int:
+        name_idx = pos + 1
+        if self._kind(name_idx) != "ident":
+            return self._skip(pos, end)
+        generics, idx = self._generics(name_idx + 1)
+        if self._v(idx) != "(":
+            return self._skip(pos, end)
+        close = self._match(idx)
+        params, self_param = self._params(idx + 1, close)
+        stop, where = self._head_end(close + 1, end)
+        head_end = where if where is not None else stop
+        has_body = self._v(stop) == "{"
+        last = self._match(stop) if has_body else stop
+        owner = item["owner"]
+        extra: Dict[str, Any] = {"params": params}
+        if self._v(close + 1) == "->":
+            extra["returns"] = self._text(close + 2, head_end)
+        if generics:
+            extra["generics"] = generics
+        for qualifier in ("async", "const", "unsafe"):
+            if qualifier in item["qualifiers"]:
+                extra[qualifier] = True
+        if item["abi"] or "extern" in item["qualifiers"]:
+            extra["abi"] = item["abi"] or "C"
+        kind = "function"
+        if owner is not None and owner["kind"] in ("trait", "impl"):
+            kind = "method"
+            if self_param:
+                extra["self_param"] = self_param
+            else:
+                extra["static"] = True
+            if owner["kind"] == "trait" and not has_body:
+                extra["required"] = True
+            if owner.get("trait"):
+                extra["trait"] = owner["trait"]
+            owner["methods"].append(self._v(name_idx))
+            if owner["kind"] == "trait" and not has_body:
+                owner["required"].append(self._v(name_idx))
+        elif owner is not None and owner["kind"] == "extern":
+            extra["abi"] = owner["abi"]
+            extra["foreign"] = True
+        self._symbol(item, name_idx, kind, last, self._text(item["head"], head_end), **extra)
+        return last + 1
+
+    def _adt(self, item: Dict[str, Any], pos: int, end: int) -> int:
+        keyword = self._v(pos)
+        name_idx = pos + 1
+        if self._kind(name_idx) != "ident":
+            return self._skip(pos, end)
+        name = self._v(name_idx)
+        generics, idx = self._generics(name_idx + 1)
+        stop, where = self._head_end(idx, end)
+        has_body = self._v(stop) == "{"
+        last = self._match(stop) if has_body else stop
+        head_end = where if where is not None else stop
+        extra: Dict[str, Any] = {}
+        if generics:
+            extra["generics"] = generics
+        if keyword == "union":
+            extra["union"] = True
+        if keyword == "struct" and self._v(idx) == "(":
+            # Tuple struct: the field list is part of the head
+            extra["tuple_fields"] = [self._text(*self._tuple_field(a, b))
+                                     for a, b in self._split(idx + 1, self._match(idx))]
+        symbol = self._symbol(item, name_idx, "enum" if keyword == "enum" else "struct", last,
+                              self._text(item["head"], head_end), **extra)
+        if not has_body:
+            return last + 1
+        if keyword == "enum":
+            symbol["variants"] = self._variants(stop + 1, last)
+            return last + 1
+        field_owner = {"kind": "struct", "name": name}
+        for first, b in self._split(stop + 1, last):
+            attrs, a = self._attributes(first, b)
+            visibility, name_at = self._visibility(a)
+            if self._kind(name_at) != "ident" or self._v(name_at + 1) != ":":
+                continue
+            field = {"start": first, "head": a, "attrs": attrs,
+                     "visibility": visibility, "qualifiers": [], "abi": None, "scope": item["scope"],
+                     "owner": field_owner}
+            field_type = self._text(name_at + 2, b)
+            self._symbol(field, name_at, "field", b - 1, self._text(a, b), field_type=field_type)
+        return last + 1
+
+    def _tuple_field(self, a: int, b: int) -> Tuple[int, int]:
+        _, a = self._attributes(a, b)
+        return self._visibility(a)[1], b
+
+    def _variants(self, start: int, end: int) -> List[Dict[str, Any]]:
+        variants = []
+        for a, b in self._split(start, end):
+            _, a = self._attributes(a, b)
+            if self._kind(a) != "ident":
+                continue
+            shape = {"(": "tuple", "{": "struct"}.get(self._v(a + 1), "unit")
+            variant = {"name": self._v(a), "kind": shape, "line": self.tokens[a].line}
+            eq = self._find(a + 1, b, ("=",))
+            if eq < b:
+                variant["discriminant"] = _clip(self._text(eq + 1, b))
+            variants.append(variant)
+        return variants
+
+    def _trait(self, item: Dict[str, Any], pos: int, end: int) -> int:
+        name_idx = pos + 1
+        if self._kind(name_idx) != "ident":
+            return self._skip(pos, end)
+        generics, idx = self._generics(name_idx + 1)
+        stop, where = self._head_end(idx, end)
+        head_end = where if where is not None else stop
+        extra: Dict[str, Any] = {}
+        if self._v(idx) in (":", "="):
+            # Supertraits, or the bounds a trait alias stands for
+            extra["supertraits"] = [self._text(a, b) for a, b in self._split(idx + 1, head_end, "+")
+                                    if self._kind(a) != "lifetime"]
+            if self._v(idx) == "=":
+                extra["alias"] = True
+        if generics:
+            extra["generics"] = generics
+        for qualifier in ("unsafe", "auto"):
+            if qualifier in item["qualifiers"]:
+                extra[qualifier] = True
+        has_body = self._v(stop) == "{"
+        last = self._match(stop) if has_body else stop
+        symbol = self._symbol(item, name_idx, "trait", last, self._text(item["head"], head_end), **extra)
+        owner = {"kind": "trait", "name": self._v(name_idx), "exported": symbol["exported"],
+                 "methods": [], "required": [], "types": []}
+        if has_body:
+            self._items(stop + 1, last, item["scope"], owner)
+        symbol["methods"] = owner["methods"]
+        symbol["required_methods"] = owner["required"]
+        if owner["types"]:
+            symbol["associated_types"] = owner["types"]
+        return last + 1
+
+    def _impl(self, item: Dict[str, Any], pos: int, end: int) -> int:
+        generics, idx = self._generics(pos + 1)
+        if self._v(idx) == "const":
+            idx += 1
+        negative = self._v(idx) == "!"
+        if negative:
+            idx += 1
+        stop, where = self._head_end(idx, end)
+        head_end = where if where is not None else stop
+        # `impl Trait for Type`: the first `for` outside generics, `for<'a>` bounds aside
+        split = None
+        depth = 0
+        j = idx
+        while j < head_end:
+            value = self._v(j)
+            if self._is_open(j):
+                j = self._match(j) + 1
+                continue
+            if value == "<":
+                depth += 1
+            elif value == ">" and depth:
+                depth -= 1
+            elif value == "for" and depth == 0:
+                if self._v(j + 1) == "<":
+                    j = self._match_angle(j + 1) + 1
+                    continue
+                split = j
+                break
+            j += 1
+        type_text = self._text(split + 1 if split is not None else idx, head_end)
+        type_name = type_base(type_text)[0] or type_text
+        impl: Dict[str, Any] = {
+            "type": type_name,
+            "type_text": type_text,
+            "trait": None,
+            "line": self.tokens[item["start"]].line,
+            "column": self.tokens[pos].col,
+            "methods": [],
+        }
+        if split is not None:
+            trait_text = self._text(idx, split)
+            trait_name, trait_prefix = type_base(trait_text)
+            impl["trait"] = trait_name or trait_text
+            impl["trait_path"] = f"{trait_prefix}::{trait_name}" if trait_prefix else impl["trait"]
+            impl["trait_text"] = trait_text
+        if generics:
+            impl["generics"] = generics
+            if type_name in _GENERIC_NAMES.findall(generics):
+                # impl<T: Bound> Trait for T
+                impl["blanket"] = True
+        if negative:
+            impl["negative"] = True
+        if "unsafe" in item["qualifiers"]:
+            impl["unsafe"] = True
+        if item["scope"]:
+            impl["module_path"] = "::".join(item["scope"])
+        has_body = self._v(stop) == "{"
+        last = self._match(stop) if has_body else stop
+        impl["end_line"] = self.tokens[last].end_line
+        self.impls.append(impl)
+        if has_body:
+            owner = {"kind": "impl", "name": type_name, "trait": impl["trait"], "methods": impl["methods"],
+                     "required": [], "types": []}
+            self._items(stop + 1, last, item["scope"], owner)
+            if owner["types"]:
+                impl["associated_types"] = owner["types"]
+        return last + 1
+
+    def _mod(self, item: Dict[str, Any], pos: int, end: int) -> int:
+        name_idx = pos + 1
+        name = self._v(name_idx)
+        inline = self._v(pos + 2) == "{"
+        last = self._match(pos + 2) if inline else pos + 2
+        symbol = self._symbol(item, name_idx, "module", last, self._text(item["head"], pos + 2), inline=inline)
+        decl = {"name": name, "inline": inline, "line": self.tokens[pos].line, "column": self.tokens[name_idx].col,
+                "visibility": item["visibility"] or "private"}
+        info = self._attribute_info(item["attrs"])
+        if info.get("path"):
+            decl["path"] = info["path"]
+        if item["scope"]:
+            decl["module_path"] = "::".join(item["scope"])
+        if any(attr.replace(" ", "") == "#[cfg(test)]" for attr in symbol.get("attributes", [])):
+            decl["cfg_test"] = True
+        self.mods.append(decl)
+        if inline:
+            self._items(pos + 3, last, item["scope"] + [name], None)
+        return last + 1
+
+    def _use(self, item: Dict[str, Any], pos: int, end: int) -> int:
+        stop = pos + 1
+        while stop < end and self._v(stop) != ";":
+            stop = self._match(stop) + 1 if self._is_open(stop) else stop + 1
+        for idx in range(pos, min(stop + 1, len(self.tokens))):
+            self._in_use[idx] = True
+        self._use_tree(pos + 1, stop, [], item)
+        return stop + 1
+
+    def _use_tree(self, start: int, end: int, prefix: List[str], item: Dict[str, Any]):
+        """Flatten a use tree (`a::{b, c::d as e, *}`) into one import per leaf."""
+        segments = list(prefix)
+        j = start
+        if self._v(j) == "::":
+            segments.append("")
+            j += 1
+        while j < end:
+            value = self._v(j)
+            if value == "{":
+                for a, b in self._split(j + 1, self._match(j)):
+                    self._use_tree(a, b, segments, item)
+                return
+            if value == "*":
+                self._import(item, segments + ["*"], "*", j, "glob")
+                return
+            if self._kind(j) != "ident":
+                return
+            segments.append(value)
+            j += 1
+            if self._v(j) == "::":
+                j += 1
+                continue
+            local = segments[-1]
+            if local == "self":
+                # `use a::b::{self}` imports b itself
+                segments.pop()
+                local = segments[-1] if segments else "self"
+            if self._v(j) == "as" and self._kind(j + 1) == "ident":
+                local = self._v(j + 1)
+            self._import(item, segments, local, j - 1, "use")
+            return
+
+    def _import(self, item: Dict[str, Any], segments: List[str], local: str, idx: int, kind: str):
+        imp = {"path": "::".join(segments), "local": local, "kind": kind,
+               "line": self.tokens[idx].line, "column": self.tokens[idx].col}
+        if kind == "use" and local != (segments[-1] if segments else local):
+            imp["alias"] = True
+        if item["visibility"]:
+            # A re-export
+            imp["visibility"] = item["visibility"]
+        if item["scope"]:
+            imp["module_path"] = "::".join(item["scope"])
+        self.imports.append(imp)
+
+    def _extern_crate(self, item: Dict[str, Any], pos: int, end: int) -> int:
+        name_idx = pos + 2
+        stop = self._skip(pos, end)
+        if self._kind(name_idx) == "ident":
+            local = self._v(name_idx + 2) if self._v(name_idx + 1) == "as" else self._v(name_idx)
+            self._import(item, [self._v(name_idx)], local, name_idx, "extern_crate")
+        return stop
+
+    def _type_alias(self, item: Dict[str, Any], pos: int, end: int) -> int:
+        name_idx = pos + 1
+        generics, idx = self._generics(name_idx + 1)
+        stop = self._find(idx, end, (";",))
+        eq = self._find(idx, stop, ("=",))
+        owner = item["owner"]
+        extra: Dict[str, Any] = {}
+        if generics:
+            extra["generics"] = generics
+        if eq < stop:
+            extra["aliased"] = self._text(eq + 1, stop)
+        if self._v(idx) == ":":
+            extra["bounds"] = self._text(idx + 1, eq)
+        if owner is not None and owner["kind"] in ("trait", "impl"):
+            owner["types"].append(self._v(name_idx))
+            extra["associated"] = True
+        self._symbol(item, name_idx, "type", stop, self._text(item["head"], stop), **extra)
+        return stop + 1
+
+    def _const(self, item: Dict[str, Any], pos: int, end: int) -> int:
+        keyword = self._v(pos)
+        name_idx = pos + 1
+        mutable = keyword == "static" and self._v(name_idx) == "mut"
+        if mutable:
+            name_idx += 1
+        # Initializers are expressions: only brackets count, not angle brackets
+        stop = name_idx
+        while stop < end and self._v(stop) != ";":
+            stop = self._match(stop) + 1 if self._is_open(stop) else stop + 1
+        if self._v(name_idx) == "_" or self._kind(name_idx) != "ident":
+            return stop + 1
+        colon = name_idx + 1 if self._v(name_idx + 1) == ":" else None
+        eq = self._find(name_idx + 1, stop, ("=",))
+        extra: Dict[str, Any] = {}
+        if colon is not None:
+            extra["const_type"] = self._text(colon + 1, eq)
+        if eq < stop:
+            extra["value"] = _clip(self._text(eq + 1, stop))
+        if keyword == "static":
+            extra["static"] = True
+            if mutable:
+                extra["mutable"] = True
+        owner = item["owner"]
+        if owner is not None and owner["kind"] == "extern":
+            extra["foreign"] = True
+        self._symbol(item, name_idx, "constant", stop, self._text(item["head"], eq), **extra)
+        return stop + 1
+
+    def _macro_rules(self, item: Dict[str, Any], pos: int, end: int) -> int:
+        name_idx = pos + 2
+        body = name_idx + 1
+        last = self._match(body) if self._is_open(body) else body
+        symbol = self._symbol(item, name_idx, "macro", last, f"macro_rules! {self._v(name_idx)}")
+        # Only #[macro_export] puts a macro_rules! macro in the crate's public surface
+        if any(attr.replace(" ", "").startswith("#[macro_export") for attr in symbol.get("attributes", [])):
+            symbol["exported"], symbol["visibility"] = True, "pub"
+        else:
+            symbol["exported"] = False
+        return last + 2 if self._v(last + 1) == ";" else last + 1
+
+    # ------------------------------------------------------------------
+    # References
+    # ------------------------------------------------------------------
+
+    def _macro_calls(self) -> List[Dict[str, Any]]:
+        """`name!(...)`, `path::name![...]` and `name! {...}` anywhere in the file, unexpanded."""
+        calls = []
+        for idx, tok in enumerate(self.tokens):
+            if tok.kind != "ident" or self._v(idx + 1) != "!" or not self._is_open(idx + 2):
+                continue
+            if tok.value == "macro_rules" or self._v(idx - 1) == "$":
+                continue
+            parts = [tok.value]
+            k = idx
+            while self._v(k - 1) == "::" and self._kind(k - 2) == "ident":
+                parts.insert(0, self._v(k - 2))
+                k -= 2
+            call = {"name": tok.value, "line": tok.line, "column": tok.col}
+            if len(parts) > 1:
+                call["path"] = "::".join(parts)
+            calls.append(call)
+        return calls
+
+    def _qualified_refs(self) -> List[Dict[str, Any]]:
+        """`a::b` paths outside use items: the leading name and the one after it."""
+        refs = []
+        for idx, tok in enumerate(self.tokens):
+            if tok.kind != "ident" or self._in_use[idx] or self._v(idx - 1) == "::":
+                continue
+            if self._v(idx + 1) == "::" and self._kind(idx + 2) == "ident":
+                refs.append({"local": tok.value, "name": self._v(idx + 2), "line": tok.line})
+        return refs
+
+
+def parse_rs_source(content: str) -> Dict[str, Any]:
+    """Parse Rust source text and return use, mod, impl, symbol and macro records."""
+    return RsFileParser(content).parse()
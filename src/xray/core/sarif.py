@@ -0,0 +1,304 @@
+"""SARIF 2.1.0 logs of XRAY's analysis findings, for GitHub code scanning.
+
+Every finding maps to one of a fixed set of rules whose ids never change
+(new kinds of findings get new ids), so alerts stay matched up between
+uploads. Locations are relative to the project root under the %SRCROOT%
+base id, and each result carries a fingerprint of what it is about rather
+than where, so moving code around does not reopen alerts.
+"""
+
+import hashlib
+import linecache
+import os
+from pathlib import Path
+from typing import Any, Dict, List, Optional
+
+from xray import __version__
+
+SCHEMA = "https://json.schemastore.org/sarif-2.1.0.json"
+INFORMATION_URI = "https://github.com/Jamie-BitFlight/git-project-xray-mcp"
+
+# Fixed order: results refer to rules by index as well as id
+RULES = [
+    {
+        "id": "XRAY001",
+        "name": "UnusedSymbol",
+        "shortDescription": {"text": "Package-level symbol is never referenced"},
+        "fullDescription": {"text": "A Go function, method, type, constant or variable that nothing in the "
+                                    "project refers to; entry points and interface methods are exempt."},
+        "defaultConfiguration": {"level": "warning"},
+    },
+    {
+        "id": "XRAY002",
+        "name": "ImportCycle",
+        "shortDescription": {"text": "Packages import each other in a cycle"},
+        "fullDescription": {"text": "An import cycle between packages, or between directories when the "
+                                    "dependency graph is collapsed by depth."},
+        "defaultConfiguration": {"level": "error"},
+    },
+    {
+        "id": "XRAY003",
+        "name": "ConcurrentGlobalWrite",
+        "shortDescription": {"text": "Package-level variable written from several goroutines"},
+        "fullDescription": {"text": "A package-level Go variable assigned from more than one goroutine "
+                                    "context, which races unless access is synchronized."},
+        "defaultConfiguration": {"level": "warning"},
+    },
+    {
+        "id": "XRAY004",
+        "name": "HardcodedSecret",
+        "shortDescription": {"text": "Credential or secret committed in the source"},
+        "fullDescription": {"text": "A string matching a known credential format (cloud keys, API tokens, "
+                                    "private keys) or assigned to a secret-looking name, or a high-entropy "
+                                    "string that reads like one."},
+        "defaultConfiguration": {"level": "error"},
+    },
+    {
+        "id": "XRAY005",
+        "name": "FormatArgumentMismatch",
+        "shortDescription": {"text": "printf-style call passes more or fewer arguments than its format reads"},
+        "fullDescription": {"text": "A call of the fmt printf family, or a log or testing ...f function, whose "
+                                    "constant format string reads a different number of arguments than the "
+                                    "call passes, or whose format is malformed."},
+        "defaultConfiguration": {"level": "warning"},
+    },
+    {
+        "id": "XRAY006",
+        "name": "CustomRule",
+        "shortDescription": {"text": "Project rule broken: a forbidden call, import or type assertion, or a "
+                                     "required one missing"},
+        "fullDescription": {"text": "A rule declared in the project's .xray configuration, or a built-in "
+                                    "example rule: the result names the rule and where it is broken."},
+        "defaultConfiguration": {"level": "warning"},
+    },
+]
+_RULE_INDEX = {rule["id"]: i for i, rule in enumerate(RULES)}
+
+# Unused-symbol confidence -> result level
+_UNUSED_LEVELS = {"high": "warning", "medium": "note", "low": "note"}
+# Secret confidence -> result level
+_SECRET_LEVELS = {"high": "error", "medium": "warning", "low": "note"}
+
+
+def _location(root: Path, path: str, line: Optional[int], column: Optional[int] = None,
+              end_line: Optional[int] = None, message: Optional[str] = None) -> Dict[str, Any]:
+    uri = Path(os.path.relpath(path, root)).as_posix()
+    physical: Dict[str, Any] = {"artifactLocation": {"uri": uri, "uriBaseId": "%SRCROOT%"}}
+    if line:
+        region: Dict[str, Any] = {"startLine": line}
+        if column:
+            region["startColumn"] = column
+        if end_line and end_line >= line:
+            region["endLine"] = end_line
+        physical["region"] = region
+    location: Dict[str, Any] = {"physicalLocation": physical}
+    if message:
+        location["message"] = {"text": message}
+    return location
+
+
+def _name_column(path: str, line: int, name: str) -> Optional[int]:
+    """1-based column of a declared name on its line ("Recv.Name" -> Name)."""
+    text = linecache.getline(path, line)
+    short = name.rsplit(".", 1)[-1]
+    pos = text.find(short, text.find(")") + 1 if text.lstrip().startswith("func (") else 0)
+    return pos + 1 if pos >= 0 else None
+
+
+def _result(rule_id: str, level: str, message: str, locations: List[Dict[str, Any]],
+            identity: str, related: Optional[List[Dict[str, Any]]] = None,
+            properties: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+    result: Dict[str, Any] = {
+        "ruleId": rule_id,
+        "ruleIndex": _RULE_INDEX[rule_id],
+        "level": level,
+        "message": {"text": message},
+        "locations": locations,
+        "partialFingerprints": {
+            "xrayFinding/v1": hashlib.sha256(f"{rule_id}:{identity}".encode()).hexdigest()[:32],
+        },
+    }
+    if related:
+        for i, location in enumerate(related):
+            location["id"] = i
+        result["relatedLocations"] = related
+    if properties:
+        result["properties"] = properties
+    return result
+
+
+def unused_results(root: Path, unused: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """Results of a find_unused report."""
+    results = []
+    for file_entry in unused["files"]:
+        path = file_entry["path"]
+        for symbol in file_entry["unused"]:
+            line = symbol["start_line"]
+            results.append(_result(
+                "XRAY001",
+                _UNUSED_LEVELS.get(symbol["confidence"], "note"),
+                f"{symbol['type'].capitalize()} '{symbol['name']}' in package {file_entry['package']} is unused: "
+                f"{symbol['reason']}.",
+                [_location(root, path, line, _name_column(path, line, symbol["name"]), symbol.get("end_line"))],
+                identity=f"{Path(os.path.relpath(path, root)).as_posix()}:{symbol['type']}:{symbol['name']}",
+                properties={"confidence": symbol["confidence"]},
+            ))
+    linecache.clearcache()
+    return results
+
+
+def cycle_results(root: Path, graph: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """Results of a dependency_graph: one per cycle, located at the imports that close it."""
+    results = []
+    for cycle in graph["cycles"]:
+        members = set(cycle)
+        sites = []
+        for edge in graph["edges"]:
+            if edge["from"] in members and edge["to"] in members:
+                for site in edge.get("import_sites", []):
+                    sites.append(_location(root, site["path"], site["line"], site.get("column"),
+                                           message=f"{edge['from']} imports {edge['to']}"))
+        if not sites:
+            continue
+        results.append(_result(
+            "XRAY002",
+            "error" if graph.get("depth") is None else "warning",
+            f"Import cycle between {len(cycle)} packages: {' -> '.join(cycle)} -> {cycle[0]}.",
+            sites[:1],
+            identity=" ".join(cycle),
+            related=sites[1:],
+            properties={"packages": cycle, "depth": graph.get("depth")},
+        ))
+    return results
+
+
+# find_cycles kind -> result level; Go allows cycles through external test packages
+_CYCLE_LEVELS = {"import": "error", "test": "warning", "soft": "note"}
+
+
+def find_cycles_results(root: Path, report: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """Results of a find_cycles report, located at the imports of the suggested break."""
+    results = []
+    for cycle in report["cycles"]:
+        breaking = {(edge["from"], edge["to"]) for edge in cycle["suggested_break"]}
+        sites = []
+        for edge in sorted(cycle["edges"], key=lambda e: (e["from"], e["to"]) not in breaking):
+            for site in edge["import_sites"]:
+                sites.append(_location(root, site["path"], site["line"], site.get("column"),
+                                       message=f"{edge['from']} imports {edge['to']}"))
+        if not sites:
+            continue
+        what = {"import": "Import cycle", "test": "Import cycle in tests",
+                "soft": "Import cycle through external test packages"}[cycle["kind"]]
+        results.append(_result(
+            "XRAY002",
+            _CYCLE_LEVELS[cycle["kind"]],
+            f"{what} between {len(cycle['packages'])} packages: {' -> '.join(cycle['path'])}; "
+            f"removing {', '.join(e['from'] + ' -> ' + e['to'] for e in cycle['suggested_break'])} breaks it.",
+            sites[:1],
+            identity=f"{cycle['kind']}:{' '.join(cycle['packages'])}",
+            related=sites[1:],
+            properties={"packages": cycle["packages"], "kind": cycle["kind"]},
+        ))
+    return results
+
+
+def concurrent_write_results(root: Path, usages: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """Results of a global_usages report, if the variable is written concurrently."""
+    if not usages.get("concurrent_writes"):
+        return []
+    symbol = usages["symbol"]
+    writes = [a for a in usages["accesses"] if a["access"] == "write" and a["kind"] != "init"]
+    related = [
+        _location(root, a["path"], a["line"], a.get("column"),
+                  message=f"written in {a['function'] or 'package scope'}"
+                          + (" on a new goroutine" if a.get("in_goroutine") else ""))
+        for a in writes
+    ]
+    line = symbol["start_line"]
+    return [_result(
+        "XRAY003",
+        "warning",
+        f"Package-level variable '{symbol['name']}' in package {symbol['package']} is written from "
+        f"several goroutines ({', '.join(usages['written_from'])}).",
+        [_location(root, symbol["path"], line, _name_column(symbol["path"], line, symbol["name"]))],
+        identity=f"{Path(os.path.relpath(symbol['path'], root)).as_posix()}:{symbol['name']}",
+        related=related,
+    )]
+
+
+def secret_results(root: Path, report: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """Results of a scan_secrets report; the secret itself is never in them."""
+    results = []
+    for finding in report["findings"]:
+        where = finding.get("symbol") or finding.get("section")
+        results.append(_result(
+            "XRAY004",
+            _SECRET_LEVELS.get(finding["confidence"], "note"),
+            f"{finding['description']} ({finding['redacted']})" + (f" in {where}" if where else "") + ".",
+            [_location(root, finding["path"], finding["line"], finding.get("column"))],
+            identity=f"{Path(os.path.relpath(finding['path'], root)).as_posix()}:{finding['kind']}:"
+                     f"{finding['fingerprint']}",
+            properties={"confidence": finding["confidence"], "kind": finding["kind"],
+                        **({"testFixture": True} if finding.get("test_fixture") else {})},
+        ))
+    return results
+
+
+def format_results(root: Path, report: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """Results of a format_strings report: one per call whose arguments do not fit its format."""
+    results = []
+    for call in report["calls"]:
+        if call["status"] not in ("missing_args", "extra_args", "bad_format"):
+            continue
+        where = f" in {call['function']}" if call.get("function") else ""
+        results.append(_result(
+            "XRAY005",
+            "error" if call["status"] == "bad_format" else "warning",
+            f"{call['call']}{where}: {call['message']} ({call['format']!r}).",
+            [_location(root, call["path"], call["line"], call.get("column"))],
+            identity=f"{Path(os.path.relpath(call['path'], root)).as_posix()}:{call.get('function')}:"
+                     f"{call['call']}:{call['format']}",
+            properties={"status": call["status"], "expectedArgs": call["expected_args"], "args": call["args"]},
+        ))
+    return results
+
+
+def rule_results(root: Path, report: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """Results of a run_rules report, one per finding, under the rule's own id."""
+    results = []
+    for finding in report["findings"]:
+        results.append(_result(
+            "XRAY006",
+            finding["severity"],
+            f"[{finding['rule']}] {finding['message']}"
+            + (f" ({finding['detail']})." if finding.get("detail") else "."),
+            [_location(root, finding["path"], finding["line"], finding.get("column"))],
+            identity=f"{Path(os.path.relpath(finding['path'], root)).as_posix()}:{finding['rule']}:"
+                     f"{finding.get('function')}:{finding.get('match')}",
+            properties={"rule": finding["rule"], "polarity": finding["polarity"]},
+        ))
+    return results
+
+
+def sarif_log(root: Path, source_root: Path, results: List[Dict[str, Any]],
+              ref: Optional[str] = None, commit: Optional[str] = None) -> Dict[str, Any]:
+    """
+    Wrap results in a SARIF log. Paths were made relative to root (the tree
+    analyzed, possibly a ref snapshot); %SRCROOT% points at the real project.
+    """
+    run: Dict[str, Any] = {
+        "tool": {
+            "driver": {
+                "name": "xray",
+                "informationUri": INFORMATION_URI,
+                "version": __version__,
+                "rules": RULES,
+            },
+        },
+        "originalUriBaseIds": {"%SRCROOT%": {"uri": source_root.resolve().as_uri() + "/"}},
+        "results": results,
+    }
+    if ref:
+        run["properties"] = {"ref": ref, "commit": commit}
+    return {"$schema": SCHEMA, "version": "2.1.0", "runs": [run]}
@@ -0,0 +1,524 @@
+"""The versioned shape of tool results, as JSON Schema, and the check that keeps it frozen.
+
+Every dict a tool returns carries "schemaVersion", MAJOR.MINOR:
+
+    MAJOR   bumped when a field is removed or renamed, or its type changes -
+            what breaks a client reading the old shape
+    MINOR   bumped when a tool, a parameter or a result field is added, or a
+            field is deprecated
+
+Field names are frozen per major version. The schemas are derived from the
+tools themselves: the input schema from each tool's signature and the
+descriptions of its INPUTS, the output schema from the EXAMPLE OUTPUT of its
+docstring - the documented contract, read leniently (`...` elisions
+dropped), every field optional and more allowed. The golden file
+(schemas/v<MAJOR>.json, written by `git-project-xray-mcp schema --write`)
+freezes them: `schema --check` fails when the shapes moved and the version
+did not, or when a field went away without a major bump or a deprecation.
+
+A field is retired by listing it in DEPRECATED_FIELDS a minor version
+before it goes: the schema marks it "deprecated" and names what replaces
+it, and the check lets it disappear once the version reaches "removed_in".
+A renamed field is still returned under its old name until then, a copy
+of the new one (see with_deprecated), and documented only under the new.
+Renames are listed for every tool returning the field, with a "**." path:
+wherever it is in the result, as the old name is copied back. The version
+itself, renamed from "schema_version" on every result, is VERSION_RENAME.
+"""
+
+import inspect
+import json
+import re
+import typing
+from pathlib import Path
+from typing import Any, Callable, Dict, List, Optional, Tuple
+
+SCHEMA_VERSION = "1.2"
+
+# The tools whose results go through XRayIndexer.present: any location in them
+# can be in a transcoded file, and any symbol or call-graph node in them carries
+# its file's build constraints
+PRESENTING_TOOLS: Tuple[str, ...] = (
+    "analyze_buffer", "api_diff", "api_surface", "api_usage", "audit_context", "audit_errors", "audit_resources",
+    "blame_symbol", "check_mocks", "compare_refs", "compare_snapshots", "concurrency_map", "config_usage", "coupling",
+    "coupling_metrics", "coverage_by_symbol", "cross_language_links", "dependencies", "dependency_graph",
+    "deprecated_usage", "diagnostics", "diff_impact", "diff_symbols", "explore_repo", "export_chunks",
+    "export_references", "export_scip", "export_symbols", "export_tags", "extract_routes", "field_usages",
+    "file_dependencies", "find_callees", "find_callers", "find_constructions", "find_cycles", "find_duplicates",
+    "find_failure_points", "find_implementations", "find_literals", "find_log_calls", "find_paths", "find_stale_docs",
+    "find_symbol", "find_tests_for", "find_unused", "format_strings", "generate_report", "get_symbol_source",
+    "global_usages", "hotspots", "interface_usage", "list_containers", "list_debt", "list_grpc_services", "list_mocks",
+    "list_queries", "list_symbols", "list_tasks", "locate_change", "metrics", "ownership", "plan_package_move",
+    "project_overview", "reflection_usages", "rename_preview", "run_rules", "scan_secrets", "search_by_signature",
+    "search_symbols", "service_map", "sql_schema", "stale_queries", "symbol_history", "table_usages", "template_usage",
+    "three_way_impact", "trace_variable", "type_hierarchy", "type_outline", "what_breaks",
+)
+
+# (old name, new name, deprecated in, removed in, the tools returning the field)
+RENAMES: List[Tuple[str, str, str, str, Tuple[str, ...]]] = [
+    # 1.2: the field names the requests gave, camelCase like "range"
+    ("parse_error", "parseError", "1.2", "1.3", ("compare_refs", "diff_symbols", "file_dependencies")),
+    ("parse_errors", "parseErrors", "1.2", "1.3", ("analyze_buffer", "diagnostics", "diff_impact", "file_dependencies",
+                                                   "list_symbols", "template_usage")),
+    ("tag_parse_error", "parseError", "1.2", "1.3", ("list_symbols",)),
+    ("test_kind", "testKind", "1.2", "1.3", ("find_symbol", "find_tests_for", "list_symbols", "search_symbols")),
+    ("type_params", "typeParams", "1.2", "1.3", ("find_symbol", "list_symbols")),
+    ("build_constraints", "buildConstraints", "1.2", "1.3", PRESENTING_TOOLS),
+    ("renamed_from", "renamedFrom", "1.2", "1.3", ("symbol_history",)),
+    ("transcoded_from", "transcodedFrom", "1.2", "1.3", PRESENTING_TOOLS + ("index_summary",)),
+    ("truncated_history", "truncatedHistory", "1.2", "1.3", ("blame_symbol", "coupling", "find_stale_docs", "hotspots",
+                                                             "list_debt", "locate_change", "ownership", "scan_secrets",
+                                                             "symbol_history", "three_way_impact")),
+    ("via_interface", "viaInterface", "1.2", "1.3", ("find_callers",)),
+    ("deprecated_fields", "deprecatedFields", "1.2", "1.3", ("get_schema",)),
+    ("schema_version", "schemaVersion", "1.2", "1.3", ("get_schema",)),
+]
+
+
+def _deprecated_fields() -> Dict[str, List[Dict[str, str]]]:
+    fields: Dict[str, List[Dict[str, str]]] = {}
+    for old, new, deprecated_in, removed_in, tools in RENAMES:
+        for tool in tools:
+            fields.setdefault(tool, []).append({"field": f"**.{old}", "deprecated_in": deprecated_in,
+                                                "removed_in": removed_in, "replaced_by": f"**.{new}"})
+    return dict(sorted(fields.items()))
+
+
+# tool -> fields on their way out: {"field": dotted path ("symbols[].doc"),
+# "deprecated_in": "1.1", "removed_in": "1.2", "replaced_by": "symbols[].docs"};
+# a "**." path is the field on any object of the result
+DEPRECATED_FIELDS: Dict[str, List[Dict[str, str]]] = _deprecated_fields()
+
+# Every tool's: the version a result is stamped with (see xray.mcp_server._versioned)
+VERSION_RENAME: Dict[str, str] = {"field": "schema_version", "deprecated_in": "1.2", "removed_in": "1.3",
+                                  "replaced_by": "schemaVersion"}
+
+GOLDEN_DIR = Path(__file__).resolve().parent.parent / "schemas"
+
+ERROR_SCHEMA: Dict[str, Any] = {
+    "type": "object",
+    "properties": {
+        "error": {
+            "type": "object",
+            "properties": {
+                "code": {"type": "string"},
+                "message": {"type": "string"},
+                "path": {"type": "string"},
+                "ref": {"type": "string"},
+            },
+            "required": ["code", "message"],
+        },
+        "schemaVersion": {"type": "string"},
+        "schema_version": {"type": "string", "deprecated": True,
+                           "description": "Deprecated in 1.2, removed in 1.3: use schemaVersion"},
+    },
+    "required": ["error"],
+}
+
+# What a JSON value (or an elision) starts with, after a comma or colon
+_VALUE_START = set('"{[-0123456789tfn.\n')
+_JSON_TYPES = {str: "string", int: "integer", float: "number", bool: "boolean"}
+_SECTION = re.compile(r"^[A-Z][A-Z ]+(?: \d+)?(?: -.*)?:?$")
+# "- name: ...", and "- a, b: ..." or "- a / b: ..." for several
+_INPUT = re.compile(r"^- (\w+(?:(?:, | / )\w+)*): ?(.*)$")
+
+
+def parse_version(version: str) -> Tuple[int, int]:
+    major, _, minor = version.partition(".")
+    return int(major), int(minor or 0)
+
+
+def _leaf(field: str) -> str:
+    return field.rpartition(".")[2].rstrip("[]")
+
+
+def renamed_fields(tool: str) -> Dict[str, str]:
+    """The fields a tool's result has under new names: new name -> old."""
+    return {_leaf(e["replaced_by"]): _leaf(e["field"]) for e in DEPRECATED_FIELDS.get(tool, [])
+            if e.get("replaced_by") and _leaf(e["replaced_by"]) != _leaf(e["field"])}
+
+
+def with_deprecated(tool: str, value: Any) -> Any:
+    """
+    A tool's result with every field it renamed also under its old name, at
+    any depth - a copy; the result itself when the tool renamed none.
+    """
+    renamed = renamed_fields(tool)
+
+    def copy(value: Any) -> Any:
+        if isinstance(value, list):
+            return [copy(item) for item in value]
+        if not isinstance(value, dict):
+            return value
+        result = {key: copy(item) for key, item in value.items()}
+        for new, old in renamed.items():
+            if new in result and old not in result:
+                result[old] = result[new]
+        return result
+
+    return copy(value) if renamed else value
+
+
+def golden_path(version: str = SCHEMA_VERSION) -> Path:
+    return GOLDEN_DIR / f"v{parse_version(version)[0]}.json"
+
+
+def _type_schema(hint: Any) -> Dict[str, Any]:
+    """The JSON Schema of a parameter's type hint; Optional adds null."""
+    origin = typing.get_origin(hint)
+    if origin is typing.Union:
+        members = [a for a in typing.get_args(hint) if a is not type(None)]
+        schemas = [_type_schema(a) for a in members]
+        nullable = len(members) < len(typing.get_args(hint))
+        if len(schemas) == 1:
+            schema = dict(schemas[0])
+        else:
+            schema = {"anyOf": schemas}
+        if nullable:
+            if "type" in schema:
+                schema["type"] = [schema["type"], "null"] if isinstance(schema["type"], str) \
+                    else schema["type"] + ["null"]
+            else:
+                schema = {"anyOf": schema.get("anyOf", [schema]) + [{"type": "null"}]}
+        return schema
+    if origin in (list, List):
+        args = typing.get_args(hint)
+        return {"type": "array", "items": _type_schema(args[0]) if args else {}}
+    if origin in (dict, Dict):
+        return {"type": "object"}
+    if hint in _JSON_TYPES:
+        return {"type": _JSON_TYPES[hint]}
+    return {}
+
+
+def _indent(line: str) -> int:
+    return len(line) - len(line.lstrip())
+
+
+def _section(doc: str, name: str) -> List[str]:
+    """The lines of a docstring section ("INPUTS:") up to the next section heading."""
+    lines = doc.splitlines()
+    for i, line in enumerate(lines):
+        if line.strip().rstrip(":") == name:
+            body = []
+            for rest in lines[i + 1:]:
+                if _SECTION.match(rest.strip()) and _indent(rest) <= _indent(line):
+                    break
+                body.append(rest)
+            return body
+    return []
+
+
+def input_descriptions(doc: str) -> Dict[str, str]:
+    """Parameter -> its description in the INPUTS section, continuation lines joined."""
+    descriptions: Dict[str, str] = {}
+    current, indent = None, 0
+    for line in _section(doc, "INPUTS") or _section(doc, "INPUT"):
+        match = _INPUT.match(line.strip())
+        if match and (current is None or _indent(line) <= indent):
+            current, indent = re.split(r", | / ", match.group(1)), _indent(line)
+            for name in current:
+                descriptions[name] = match.group(2).strip()
+        elif current and line.strip() and _indent(line) > indent:
+            for name in current:
+                descriptions[name] += " " + line.strip()
+        else:
+            current = None
+    return descriptions
+
+
+def input_schema(func: Callable[..., Any]) -> Dict[str, Any]:
+    """The JSON Schema of a tool's arguments, from its signature and INPUTS."""
+    try:
+        hints = typing.get_type_hints(func)
+    except Exception:
+        hints = {}
+    descriptions = input_descriptions(inspect.cleandoc(func.__doc__ or ""))
+    properties: Dict[str, Any] = {}
+    required = []
+    for name, parameter in inspect.signature(func).parameters.items():
+        if name == "ctx":
+            continue
+        schema = _type_schema(hints.get(name, parameter.annotation))
+        if parameter.default is inspect.Parameter.empty:
+            required.append(name)
+        elif parameter.default is not None:
+            schema["default"] = parameter.default
+        if descriptions.get(name):
+            schema["description"] = descriptions[name]
+        properties[name] = schema
+    schema = {"type": "object", "properties": properties, "additionalProperties": False}
+    if required:
+        schema["required"] = required
+    return schema
+
+
+def _without_ellipses(text: str) -> str:
+    """
+    An example made JSON: `...` elisions outside strings dropped with their
+    commas, quotes inside strings ("errors.New("x")") escaped.
+    """
+    out: List[str] = []
+    i, in_string = 0, False
+    while i < len(text):
+        char = text[i]
+        if in_string:
+            if char == "\\":
+                out.append(text[i:i + 2])
+                i += 2
+                continue
+            if char == '"':
+                rest = text[i + 1:].lstrip(" ")
+                after = rest[1:].lstrip(" ")[:1]
+                # A quote closing the string is followed by what may follow a value, and that by a value
+                if not rest or rest[0] in "}]\n" or rest[0] in ",:" and (not after or after in _VALUE_START):
+                    in_string = False
+                else:
+                    out.append('\\"')
+                    i += 1
+                    continue
+            elif char == "\n":
+                out.append("\\n")
+                i += 1
+                continue
+            out.append(char)
+        elif text.startswith("...", i):
+            i += 3
+            continue
+        else:
+            if char == '"':
+                in_string = True
+            out.append(char)
+        i += 1
+    cleaned = "".join(out)
+    previous = None
+    while previous != cleaned:
+        previous = cleaned
+        cleaned = re.sub(r",(\s*[}\]])", r"\1", cleaned)
+        cleaned = re.sub(r"([\[{]\s*),", r"\1", cleaned)
+        cleaned = re.sub(r",(\s*),", r",\1", cleaned)
+    return cleaned
+
+
+def example_output(doc: str) -> Optional[Any]:
+    """The first JSON value after a docstring's EXAMPLE OUTPUT heading, None if there is none that reads."""
+    index = doc.find("EXAMPLE OUTPUT")
+    if index < 0:
+        return None
+    text = doc[index:].split("\n", 1)[-1]
+    starts = [p for p in (text.find("{"), text.find("[")) if p >= 0]
+    if not starts:
+        return None
+    decoder = json.JSONDecoder(strict=False)
+    for candidate in (text[min(starts):], _without_ellipses(text[min(starts):])):
+        try:
+            return decoder.raw_decode(candidate)[0]
+        except ValueError:
+            continue
+    return None
+
+
+def _merge(a: Dict[str, Any], b: Dict[str, Any]) -> Dict[str, Any]:
+    """One schema covering two inferred ones: properties joined, integer and number made number."""
+    if not a:
+        return b
+    if not b or a == b:
+        return a
+    if a.get("type") == b.get("type") == "object":
+        properties = dict(a.get("properties", {}))
+        for key, schema in b.get("properties", {}).items():
+            properties[key] = _merge(properties[key], schema) if key in properties else schema
+        return {"type": "object", "properties": properties}
+    if a.get("type") == b.get("type") == "array":
+        return {"type": "array", "items": _merge(a.get("items", {}), b.get("items", {}))}
+    if {a.get("type"), b.get("type")} == {"integer", "number"}:
+        return {"type": "number"}
+    types = sorted({t for s in (a, b) for t in ([s["type"]] if isinstance(s.get("type"), str) else s.get("type", []))})
+    return {"type": types} if types else {}
+
+
+def infer_schema(value: Any) -> Dict[str, Any]:
+    """The JSON Schema an example value has; null says nothing about a field's type."""
+    if isinstance(value, dict):
+        return {"type": "object", "properties": {k: infer_schema(v) for k, v in value.items()}}
+    if isinstance(value, list):
+        items: Dict[str, Any] = {}
+        for item in value:
+            items = _merge(items, infer_schema(item))
+        return {"type": "array", "items": items}
+    if isinstance(value, bool):
+        return {"type": "boolean"}
+    if value is None:
+        return {}
+    return {"type": _JSON_TYPES.get(type(value), "string")}
+
+
+def _field_schema(schema: Dict[str, Any], field: str) -> Optional[Dict[str, Any]]:
+    """The schema of a dotted field path ("symbols[].doc") inside an output schema."""
+    for part in field.split("."):
+        if schema is None:
+            return None
+        name, brackets = part.rstrip("[]"), part.count("[]")
+        schema = schema.get("properties", {}).get(name)
+        for _ in range(brackets):
+            schema = schema.get("items") if schema else None
+    return schema
+
+
+def output_schema(name: str, func: Callable[..., Any]) -> Dict[str, Any]:
+    """The JSON Schema of a tool's result, from its EXAMPLE OUTPUT; deprecated fields marked."""
+    example = example_output(inspect.cleandoc(func.__doc__ or ""))
+    if example is not None:
+        schema = infer_schema(example)
+    else:
+        # explore_repo's tree is text; its errors are objects
+        hint = inspect.signature(func).return_annotation
+        schema = {**_type_schema(hint), "description": "No documented example; only the common fields are fixed"}
+    for sub in [schema, *schema.get("anyOf", [])]:
+        if sub.get("type") == "object":
+            sub.setdefault("properties", {})["schemaVersion"] = {"type": "string"}
+            sub["properties"]["schema_version"] = {"type": "string"}
+            _mark(sub["properties"]["schema_version"], VERSION_RENAME)
+    for entry in DEPRECATED_FIELDS.get(name, []):
+        if entry["field"].startswith("**."):
+            _mark_renamed(schema, entry)
+            continue
+        field = _field_schema(schema, entry["field"])
+        if field is None and entry.get("replaced_by"):
+            # Documented under its new name only: the old one has the same shape
+            parent_path, _, leaf = entry["field"].rpartition(".")
+            parent = _field_schema(schema, parent_path) if parent_path else schema
+            replacement = _field_schema(schema, entry["replaced_by"])
+            if parent is not None and replacement is not None:
+                field = json.loads(json.dumps(replacement))
+                parent.setdefault("properties", {})[leaf.rstrip("[]")] = field
+        if field is not None:
+            _mark(field, entry)
+    return schema
+
+
+def _mark(field: Dict[str, Any], entry: Dict[str, str]):
+    field["deprecated"] = True
+    if entry.get("replaced_by"):
+        field["description"] = f"Deprecated in {entry['deprecated_in']}, removed in " \
+                               f"{entry['removed_in']}: use {entry['replaced_by']}"
+
+
+def _mark_renamed(schema: Dict[str, Any], entry: Dict[str, str]):
+    """A field renamed wherever it is ("**." paths): the old name beside every documented new one."""
+    old, new = _leaf(entry["field"]), _leaf(entry["replaced_by"])
+    properties = schema.get("properties", {})
+    if new in properties and old not in properties:
+        properties[old] = json.loads(json.dumps(properties[new]))
+        _mark(properties[old], entry)
+    for sub in [*properties.values(), schema.get("items"), *schema.get("anyOf", [])]:
+        if isinstance(sub, dict) and sub is not properties.get(old):
+            _mark_renamed(sub, entry)
+
+
+def deprecations(name: str) -> List[Dict[str, str]]:
+    """A tool's fields on their way out: its DEPRECATED_FIELDS and the version's rename."""
+    return [VERSION_RENAME, *DEPRECATED_FIELDS.get(name, [])]
+
+
+def tool_schema(name: str, func: Callable[..., Any]) -> Dict[str, Any]:
+    """A tool's input and output schemas with its summary line and deprecated fields."""
+    doc = inspect.cleandoc(func.__doc__ or "")
+    return {
+        "name": name,
+        "description": doc.strip().splitlines()[0].strip() if doc.strip() else "",
+        "schemaVersion": SCHEMA_VERSION,
+        "input_schema": input_schema(func),
+        "output_schema": output_schema(name, func),
+        "deprecatedFields": deprecations(name),
+    }
+
+
+def shape(schema: Dict[str, Any], prefix: str = "") -> Dict[str, str]:
+    """A schema flattened to dotted field paths and their types, as the golden file keeps it."""
+    fields: Dict[str, str] = {}
+    kind = schema.get("type")
+    if kind == "object":
+        for key, sub in schema.get("properties", {}).items():
+            path = f"{prefix}.{key}" if prefix else key
+            fields[path] = _type_name(sub)
+            fields.update(shape(sub, path))
+    elif kind == "array" and schema.get("items"):
+        fields.update(shape(schema["items"], prefix + "[]"))
+    return fields
+
+
+def _type_name(schema: Dict[str, Any]) -> str:
+    kind = schema.get("type")
+    if isinstance(kind, list):
+        return "|".join(kind)
+    if kind is None and "anyOf" in schema:
+        return "|".join(_type_name(s) for s in schema["anyOf"])
+    return kind or "any"
+
+
+def golden(schemas: List[Dict[str, Any]]) -> Dict[str, Any]:
+    """The golden file's content: the version and every tool's input and output shape."""
+    return {
+        "schemaVersion": SCHEMA_VERSION,
+        "tools": {s["name"]: {"input": shape(s["input_schema"]), "output": shape(s["output_schema"])}
+                  for s in schemas},
+    }
+
+
+def _compatible(old: str, new: str) -> bool:
+    """Whether a field's type may move from old to new without breaking a reader: widened or made known."""
+    if old == "any" or old == new:
+        return True
+    return set(old.split("|")) <= set(new.split("|")) or {old, new} == {"integer", "number"} and new == "number"
+
+
+def compare(frozen: Dict[str, Any], current: Dict[str, Any]) -> Dict[str, Any]:
+    """
+    The shape changes between the golden file and the tools as they are,
+    and the problems they make at the current version:
+    {"added", "removed", "retyped", "problems", "ok"}.
+    """
+    added, removed, retyped = [], [], []
+    old_tools, new_tools = frozen.get("tools", {}), current["tools"]
+    for tool in sorted(set(old_tools) | set(new_tools)):
+        if tool not in new_tools:
+            removed.append({"tool": tool})
+            continue
+        if tool not in old_tools:
+            added.append({"tool": tool})
+            continue
+        for side in ("input", "output"):
+            old, new = old_tools[tool].get(side, {}), new_tools[tool].get(side, {})
+            for field in sorted(set(old) | set(new)):
+                if field not in new:
+                    removed.append({"tool": tool, side: field})
+                elif field not in old:
+                    added.append({"tool": tool, side: field})
+                elif old[field] != new[field]:
+                    change = {"tool": tool, side: field, "was": old[field], "now": new[field]}
+                    (added if _compatible(old[field], new[field]) else retyped).append(change)
+
+    frozen_at = frozen.get("schemaVersion", frozen.get("schema_version"))
+    version, frozen_version = parse_version(SCHEMA_VERSION), parse_version(frozen_at or "0.0")
+    problems = []
+    if (added or removed or retyped) and version <= frozen_version:
+        problems.append(f"tool shapes changed but schemaVersion is still {SCHEMA_VERSION}: "
+                        f"bump the minor version (additions) or the major one (removals, type changes)")
+    for tool in sorted(set(old_tools) | set(new_tools)):
+        for e in deprecations(tool):
+            if parse_version(e["removed_in"]) <= parse_version(e["deprecated_in"]):
+                problems.append(f"{tool}: '{e['field']}' must stay deprecated for a minor version before it is removed")
+    if version[0] == frozen_version[0]:
+        retired = {(tool, e["field"]) for tool in set(old_tools) | set(new_tools) for e in deprecations(tool)
+                   if parse_version(e["removed_in"]) <= version}
+        for change in removed + retyped:
+            field = change.get("output") or change.get("input")
+            if field is None:
+                problems.append(f"tool {change['tool']} removed without a major version bump")
+            elif (change["tool"], field) not in retired and (change["tool"], f"**.{_leaf(field)}") not in retired:
+                problems.append(f"{change['tool']}: {'input' if 'input' in change else 'output'} field "
+                                f"'{field}' {'removed' if 'now' not in change else 'changed type'} without "
+                                f"a major version bump or a deprecation in DEPRECATED_FIELDS")
+    return {"schemaVersion": SCHEMA_VERSION, "goldenVersion": frozen_at,
+            "added": added, "removed": removed, "retyped": retyped, "problems": problems, "ok": not problems}
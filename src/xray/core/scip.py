@@ -0,0 +1,311 @@
+"""SCIP indexes of the Go symbol index, for Sourcegraph code intelligence.
+
+The index is written as scip.proto's Index message, protobuf-encoded by
+hand (the schema is small and stable, and this keeps protobuf out of our
+dependencies). Symbols follow the conventions scip-go uses, so references
+resolve across repositories indexed by either tool:
+
+    scip-go gomod <module> <version> `<import path>`/Type#Method().
+
+Documents hold a definition occurrence for every declaration, references
+for every call and function value the call graph resolves, and import
+occurrences for import specs. Positions are 0-based lines and code point
+offsets (the tokenizer's columns), declared as UTF32 code units.
+"""
+
+import os
+from typing import Any, Callable, Dict, List, Optional, Tuple
+
+from xray import __version__
+from xray.core.go_analysis import GoCallGraph, GoProject
+from xray.core.go_deps import is_std
+
+SCHEME = "scip-go"
+MANAGER = "gomod"
+# How scip-go names the standard library's package
+STD_PACKAGE = "github.com/golang/go/src"
+
+# scip.proto enum values
+_TEXT_ENCODING_UTF8 = 1
+_POSITION_UTF32 = 3
+ROLE_DEFINITION = 1
+ROLE_IMPORT = 2
+_KINDS = {
+    "function": 17, "method": 26, "interface_method": 67, "struct": 49, "interface": 21,
+    "type": 54, "alias": 55, "field": 15, "constant": 8, "variable": 61,
+}
+
+
+# ----------------------------------------------------------------------
+# Protobuf wire format
+# ----------------------------------------------------------------------
+
+def _varint(value: int) -> bytes:
+    if value < 0:
+        value += 1 << 64
+    out = bytearray()
+    while True:
+        byte = value & 0x7F
+        value >>= 7
+        if value:
+            out.append(byte | 0x80)
+        else:
+            out.append(byte)
+            return bytes(out)
+
+
+def _int(field: int, value: int) -> bytes:
+    """A varint field; proto3 leaves out zero values."""
+    return _varint(field << 3) + _varint(value) if value else b""
+
+
+def _bytes(field: int, value: bytes) -> bytes:
+    return _varint(field << 3 | 2) + _varint(len(value)) + value
+
+
+def _str(field: int, value: Optional[str]) -> bytes:
+    return _bytes(field, value.encode("utf-8")) if value else b""
+
+
+def _packed(field: int, values: List[int]) -> bytes:
+    return _bytes(field, b"".join(_varint(v) for v in values)) if values else b""
+
+
+def _symbol_information(info: Dict[str, Any]) -> bytes:
+    out = _str(1, info["symbol"])
+    for doc in info.get("documentation", []):
+        out += _str(3, doc)
+    for rel in info.get("relationships", []):
+        out += _bytes(4, _str(1, rel["symbol"]) + _int(2, rel.get("is_reference", False))
+                      + _int(3, rel.get("is_implementation", False))
+                      + _int(4, rel.get("is_type_definition", False)))
+    out += _int(5, info.get("kind", 0))
+    out += _str(6, info.get("display_name"))
+    out += _str(8, info.get("enclosing_symbol"))
+    return out
+
+
+def _document(doc: Dict[str, Any]) -> bytes:
+    out = _str(1, doc["relative_path"])
+    for occ in doc["occurrences"]:
+        out += _bytes(2, _packed(1, occ["range"]) + _str(2, occ["symbol"]) + _int(3, occ["symbol_roles"]))
+    for info in doc["symbols"]:
+        out += _bytes(3, _symbol_information(info))
+    out += _str(4, "go")
+    out += _int(6, _POSITION_UTF32)
+    return out
+
+
+def encode_scip(index: Dict[str, Any]) -> bytes:
+    """Serialize an index built by scip_index as a scip.Index message."""
+    meta = index["metadata"]
+    tool = _str(1, "xray") + _str(2, __version__)
+    out = _bytes(1, _bytes(2, tool) + _str(3, meta["project_root"]) + _int(4, _TEXT_ENCODING_UTF8))
+    for doc in index["documents"]:
+        out += _bytes(2, _document(doc))
+    for info in index["external_symbols"]:
+        out += _bytes(3, _symbol_information(info))
+    return out
+
+
+# ----------------------------------------------------------------------
+# Symbols
+# ----------------------------------------------------------------------
+
+def _is_simple(name: str) -> bool:
+    return bool(name) and all(c.isascii() and (c.isalnum() or c in "_+-$") for c in name)
+
+
+def _descriptor(name: str) -> str:
+    return name if _is_simple(name) else "`" + name.replace("`", "``") + "`"
+
+
+def _space_escape(value: Optional[str]) -> str:
+    return value.replace(" ", "  ") if value else "."
+
+
+class _Symbols:
+    """SCIP symbol strings for project and external Go declarations."""
+
+    def __init__(self, project: GoProject, module: Optional[str], version: str,
+                 go_version: Optional[str], requires: List[Dict[str, Any]]):
+        self.project = project
+        self.module = module or os.path.basename(project.root or "") or "."
+        self.version = version
+        self.std_version = f"go{go_version}" if go_version else "."
+        self.requires = sorted(requires, key=lambda r: len(r["path"]), reverse=True)
+
+    def import_path(self, pkg_dir: str) -> str:
+        rel = os.path.relpath(pkg_dir, self.project.root).replace(os.sep, "/") if self.project.root else "."
+        return self.module if rel == "." else f"{self.module}/{rel}"
+
+    def package(self, import_path: str, internal: bool) -> str:
+        """The symbol of a package (a namespace descriptor)."""
+        if internal:
+            name, version = self.module, self.version
+        elif is_std(import_path):
+            name, version = STD_PACKAGE, self.std_version
+        else:
+            name, version = import_path, "."
+            for required in self.requires:
+                if import_path == required["path"] or import_path.startswith(required["path"] + "/"):
+                    name, version = required["path"], required["version"]
+                    break
+        return f"{SCHEME} {MANAGER} {_space_escape(name)} {_space_escape(version)} {_descriptor(import_path)}/"
+
+    def declaration(self, pkg_dir: str, symbol: Dict[str, Any]) -> Optional[str]:
+        prefix = self.package(self.import_path(pkg_dir), True)
+        name = _descriptor(symbol["name"])
+        kind = symbol["type"]
+        if kind == "function":
+            return f"{prefix}{name}()."
+        if kind == "method":
+            owner = symbol["receiver"]["type"] if symbol.get("receiver") else symbol.get("container")
+            return f"{prefix}{_descriptor(owner)}#{name}()." if owner else None
+        if kind in ("struct", "interface", "type"):
+            return f"{prefix}{name}#"
+        if kind == "field":
+            return f"{prefix}{_descriptor(symbol['container'])}#{name}."
+        if kind in ("constant", "variable"):
+            return f"{prefix}{name}."
+        return None
+
+    def function(self, key: Tuple[str, str]) -> str:
+        """A project function or method node of the call graph."""
+        prefix = self.package(self.import_path(key[0]), True)
+        if "." in key[1]:
+            owner, name = key[1].split(".", 1)
+            return f"{prefix}{_descriptor(owner)}#{_descriptor(name)}()."
+        return f"{prefix}{_descriptor(key[1])}()."
+
+    def external(self, qualified: str, imports: List[str]) -> Optional[Tuple[str, str]]:
+        """The symbol and display name of an external callee ("net/http.Get", "io.Writer.Write")."""
+        for import_path in sorted(imports, key=len, reverse=True):
+            if qualified.startswith(import_path + "."):
+                members = qualified[len(import_path) + 1:].split(".")
+                prefix = self.package(import_path, False)
+                if len(members) == 1:
+                    return f"{prefix}{_descriptor(members[0])}().", members[0]
+                return f"{prefix}{_descriptor(members[0])}#{_descriptor(members[-1])}().", members[-1]
+        return None
+
+
+# ----------------------------------------------------------------------
+# Index
+# ----------------------------------------------------------------------
+
+def _range(line: int, column: int, length: int) -> List[int]:
+    return [line - 1, column - 1, column - 1 + length]
+
+
+def _implementations(project: GoProject, symbols: _Symbols) -> Dict[str, List[Dict[str, Any]]]:
+    """Symbol -> is_implementation relationships of types and methods satisfying project interfaces."""
+    relationships: Dict[str, List[Dict[str, Any]]] = {}
+    for iface_key, iface in sorted(project.types.items()):
+        if iface["type"] != "interface" or iface.get("alias"):
+            continue
+        iface_symbol = f"{symbols.package(symbols.import_path(iface_key[0]), True)}{_descriptor(iface_key[1])}#"
+        # Methods of embedded interfaces are declared, and have their symbol, there
+        specs, _ = project.interface_method_set(*iface_key)
+        for match in project.implementations_of(iface)["implementations"]:
+            type_dir = os.path.dirname(match["path"])
+            type_symbol = f"{symbols.package(symbols.import_path(type_dir), True)}{_descriptor(match['name'])}#"
+            relationships.setdefault(type_symbol, []).append({"symbol": iface_symbol, "is_implementation": True})
+            for method in match["matched"]:
+                spec = specs[method]
+                if method in match.get("promoted", {}):
+                    continue
+                implemented = symbols.function((os.path.dirname(spec["path"]), f"{spec['container']}.{method}"))
+                related = relationships.setdefault(symbols.function((type_dir, f"{match['name']}.{method}")), [])
+                if all(rel["symbol"] != implemented for rel in related):
+                    related.append({"symbol": implemented, "is_implementation": True})
+    return relationships
+
+
+def scip_index(project: GoProject, graph: GoCallGraph, relpath: Callable[[str], str], project_root: str,
+                module: Optional[str] = None, version: Optional[str] = None,
+                go_version: Optional[str] = None, requires: Optional[List[Dict[str, Any]]] = None) -> Dict[str, Any]:
+    """
+    Collect the documents and external symbols of a SCIP index.
+
+    Args:
+        project: The parsed project
+        graph: Its call graph, for reference occurrences
+        relpath: Maps an indexed path to the document's path relative to the project root
+        project_root: URI of the project root
+        module: Module path from go.mod, the package name of project symbols
+        version: Version of project symbols (a commit, say); "." when unknown
+        go_version: The go directive of go.mod, the version of standard library symbols
+        requires: go.mod requirements, which give external symbols their module and version
+    """
+    symbols = _Symbols(project, module, version or ".", go_version, requires or [])
+    implements = _implementations(project, symbols)
+    external: Dict[str, Dict[str, Any]] = {}
+    edges_by_path: Dict[str, List[Dict[str, Any]]] = {}
+    for edge in graph.edges:
+        edges_by_path.setdefault(edge["path"], []).append(edge)
+
+    documents = []
+    for path in sorted(project.files):
+        parsed = project.files[path]
+        pkg_dir = os.path.dirname(path)
+        package_symbol = symbols.package(symbols.import_path(pkg_dir), True)
+        occurrences = []
+        infos = []
+
+        for imp in parsed.get("imports", []):
+            if imp.get("column") is None:
+                continue
+            internal = project.import_dir(imp["path"], path) is not None
+            occurrences.append({"range": _range(imp["line"], imp["column"], len(imp["path"]) + 2),
+                                "symbol": symbols.package(imp["path"], internal), "symbol_roles": ROLE_IMPORT})
+
+        for symbol in parsed["symbols"]:
+            scip_symbol = symbols.declaration(pkg_dir, symbol)
+            if scip_symbol is None or symbol.get("column") is None:
+                continue
+            occurrences.append({"range": _range(symbol["start_line"], symbol["column"], len(symbol["name"])),
+                                "symbol": scip_symbol, "symbol_roles": ROLE_DEFINITION})
+            kind = symbol["type"]
+            if kind == "method" and symbol.get("container"):
+                kind = "interface_method"
+            elif kind == "type" and symbol.get("alias"):
+                kind = "alias"
+            owner = symbol.get("container") or (symbol.get("receiver") or {}).get("type")
+            documentation = [f"```go\n{symbol.get('signature') or symbol['name']}\n```"]
+            if symbol.get("doc"):
+                documentation.append(symbol["doc"])
+            info = {
+                "symbol": scip_symbol,
+                "documentation": documentation,
+                "kind": _KINDS[kind],
+                "display_name": symbol["name"],
+                "enclosing_symbol": f"{package_symbol}{_descriptor(owner)}#" if owner else package_symbol,
+            }
+            if scip_symbol in implements:
+                info["relationships"] = implements[scip_symbol]
+            infos.append(info)
+
+        import_paths = [imp["path"] for imp in parsed.get("imports", [])]
+        for edge in edges_by_path.get(path, []):
+            if edge["external"]:
+                resolved = symbols.external(edge["callee"][1], import_paths)
+                if resolved is None:
+                    continue
+                scip_symbol, name = resolved
+                external.setdefault(scip_symbol, {"symbol": scip_symbol, "kind": _KINDS["function"],
+                                                  "display_name": name})
+            else:
+                scip_symbol = symbols.function(edge["callee"])
+                name = edge["callee"][1].rsplit(".", 1)[-1]
+            occurrences.append({"range": _range(edge["line"], edge["column"], len(name)),
+                                "symbol": scip_symbol, "symbol_roles": 0})
+
+        occurrences.sort(key=lambda o: (o["range"], o["symbol"]))
+        documents.append({"relative_path": relpath(path), "occurrences": occurrences, "symbols": infos})
+
+    return {
+        "metadata": {"project_root": project_root},
+        "documents": documents,
+        "external_symbols": [external[s] for s in sorted(external)],
+    }
@@ -0,0 +1,178 @@
+"""Named snapshots of a project's symbol table, and the diff between two.
+
+A snapshot is the summary of an index at one moment, committed or not:
+every declaration (language, scope and qualified name, as symbol IDs
+have them - see xray.core.symbol_ids - with its kind, signature and
+location), the number of call and function-value sites the Go call graph
+has for each function and method, and per package (a Go package's import
+path, a directory per language elsewhere) its files, lines and
+declarations. No source is kept, so a snapshot of a large project stays
+small.
+
+Snapshots live under the cache directory, next to the persisted indexes
+but apart from them - clear_cache leaves them alone - one file per
+label, in the same checksummed format (see xray.core.cache).
+"""
+
+import hashlib
+import os
+import re
+import tempfile
+import time
+from pathlib import Path
+from typing import Any, Dict, List, Optional, Tuple
+
+from xray.core.cache import cache_root, decode, encode
+from xray.core.errors import FileNotFound, XRayError
+
+_NAME = re.compile(r"^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$")
+_SUFFIX = ".snap"
+
+# Per symbol: kind, language, signature, path relative to the root, line, Go call/reference sites (None elsewhere)
+Record = Tuple[str, str, Optional[str], str, int, Optional[int]]
+
+
+def symbol_key(language: str, scope: str, qualified: str) -> str:
+    """A declaration's identity across snapshots: its symbol ID without the signature hash ("" names its package)."""
+    return f"{language}:{scope}:{qualified}"
+
+
+class SnapshotStore:
+    """The snapshots of one project."""
+
+    def __init__(self, project_path: Path):
+        self.directory = cache_root() / "snapshots" / hashlib.sha256(str(project_path).encode()).hexdigest()[:16]
+
+    def _path(self, name: str) -> Path:
+        if not isinstance(name, str) or not _NAME.match(name):
+            raise XRayError(f"Invalid snapshot name '{name}': use up to 64 letters, digits, '.', '_' or '-'")
+        return self.directory / (name + _SUFFIX)
+
+    def save(self, name: str, snapshot: Dict[str, Any]) -> int:
+        """Write a snapshot under a name, replacing one of the same name; returns its size in bytes."""
+        path = self._path(name)
+        self.directory.mkdir(parents=True, exist_ok=True)
+        fd, tmp = tempfile.mkstemp(dir=self.directory, prefix=".snapshot-")
+        with os.fdopen(fd, "wb") as f:
+            f.write(encode(snapshot))
+        os.replace(tmp, path)
+        return path.stat().st_size
+
+    def load(self, name: str) -> Dict[str, Any]:
+        path = self._path(name)
+        try:
+            blob = path.read_bytes()
+        except OSError:
+            known = ", ".join(s["name"] for s in self.list()) or "none"
+            raise FileNotFound(f"No snapshot named '{name}' (snapshots: {known})")
+        try:
+            data = decode(blob)
+        except Exception as e:
+            raise XRayError(f"Snapshot '{name}' is unreadable ({e}); delete it and take it again")
+        return data
+
+    def list(self) -> List[Dict[str, Any]]:
+        """Every snapshot, oldest first, with what it was taken of."""
+        found = []
+        for path in self.directory.glob("*" + _SUFFIX) if self.directory.is_dir() else ():
+            try:
+                data = decode(path.read_bytes())
+            except Exception:
+                continue
+            found.append({"name": path.name[:-len(_SUFFIX)], **_meta(data), "bytes": path.stat().st_size})
+        return sorted(found, key=lambda s: (s["created_at"], s["name"]))
+
+    def delete(self, name: str) -> bool:
+        try:
+            self._path(name).unlink()
+            return True
+        except FileNotFoundError:
+            return False
+
+
+def _meta(snapshot: Dict[str, Any]) -> Dict[str, Any]:
+    return {
+        "created_at": snapshot["created_at"],
+        "commit": snapshot.get("commit"),
+        "ref": snapshot.get("ref"),
+        "symbols": len(snapshot["symbols"]),
+        "packages": len(snapshot["packages"]),
+    }
+
+
+def new_snapshot(symbols: Dict[str, Record], packages: Dict[str, Dict[str, int]],
+                 commit: Optional[str], ref: Optional[str]) -> Dict[str, Any]:
+    return {"created_at": time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime()), "commit": commit, "ref": ref,
+            "symbols": symbols, "packages": packages}
+
+
+def _entry(key: str, record: Record) -> Dict[str, Any]:
+    language, scope, qualified = key.split(":", 2)
+    entry = {"name": qualified, "kind": record[0], "language": language, "package": scope,
+             "path": record[3], "line": record[4]}
+    if record[2]:
+        entry["signature"] = record[2]
+    return entry
+
+
+def compare(old: Dict[str, Any], new: Dict[str, Any], old_name: str, new_name: str) -> Dict[str, Any]:
+    """
+    The symbol-table changes from one snapshot to another.
+
+    Returns:
+        {"from", "to", "added", "removed", "signature_changed", "moved",
+        "references", "packages", "counts"}: declarations only in one of
+        them, ones whose signature or kind changed or that moved to another
+        file, Go functions whose call/reference sites changed in number,
+        and packages whose files, lines or declarations did, each with the
+        two values and the delta
+    """
+    before, after = old["symbols"], new["symbols"]
+    added = [_entry(key, after[key]) for key in sorted(after.keys() - before.keys())]
+    removed = [_entry(key, before[key]) for key in sorted(before.keys() - after.keys())]
+    changed, moved, references = [], [], []
+    for key in sorted(before.keys() & after.keys()):
+        was, now = before[key], after[key]
+        if (was[0], was[2]) != (now[0], now[2]):
+            entry = _entry(key, now)
+            entry["old_signature"] = was[2]
+            if was[0] != now[0]:
+                entry["old_kind"] = was[0]
+            changed.append(entry)
+        if was[3] != now[3]:
+            entry = _entry(key, now)
+            entry["old_path"] = was[3]
+            moved.append(entry)
+        if (was[5] or 0) != (now[5] or 0):
+            entry = _entry(key, now)
+            entry.update({"old_references": was[5] or 0, "references": now[5] or 0,
+                          "delta": (now[5] or 0) - (was[5] or 0)})
+            references.append(entry)
+    references.sort(key=lambda e: (-abs(e["delta"]), e["package"], e["name"]))
+
+    packages = []
+    for key in sorted(old["packages"].keys() | new["packages"].keys()):
+        was, now = old["packages"].get(key), new["packages"].get(key)
+        if was == now:
+            continue
+        language, scope, _ = key.split(":", 2)
+        entry: Dict[str, Any] = {"package": scope, "language": language,
+                                 "status": "added" if was is None else "removed" if now is None else "changed"}
+        for field in ("files", "lines", "symbols"):
+            a, b = (was or {}).get(field, 0), (now or {}).get(field, 0)
+            entry[field] = {"old": a, "new": b, "delta": b - a}
+        packages.append(entry)
+    packages.sort(key=lambda e: (-abs(e["lines"]["delta"]), e["package"]))
+
+    return {
+        "from": {"name": old_name, **_meta(old)},
+        "to": {"name": new_name, **_meta(new)},
+        "added": added,
+        "removed": removed,
+        "signature_changed": changed,
+        "moved": moved,
+        "references": references,
+        "packages": packages,
+        "counts": {"added": len(added), "removed": len(removed), "signature_changed": len(changed),
+                   "moved": len(moved), "references": len(references), "packages": len(packages)},
+    }
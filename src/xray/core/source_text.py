@@ -0,0 +1,75 @@
+"""Reading source files that are not UTF-8.
+
+Source is expected in UTF-8, and read as it is when it decodes. Otherwise
+a small set of common encodings is tried before giving up on the file:
+
+    utf-16le, utf-16be  a UTF-16 byte order mark starts the file
+    latin-1             anything else that is not valid UTF-8 (ISO-8859-1;
+                        every byte sequence decodes, so this never fails)
+
+A transcoded file is indexed from its decoded text: symbol names, lines
+and columns are those of the text, with columns counted in UTF-8 bytes of
+it like every other file's (see xray.core.ranges). Its parse result, and
+every tool result location in it, notes "transcodedFrom": the encoding it
+was read in.
+"""
+
+import codecs
+import hashlib
+from typing import List, Optional, Tuple
+
+UTF16_BOMS = {codecs.BOM_UTF16_LE: "utf-16le", codecs.BOM_UTF16_BE: "utf-16be"}
+
+
+def _decode(data: bytes) -> Tuple[str, Optional[str]]:
+    for bom, encoding in UTF16_BOMS.items():
+        if data.startswith(bom):
+            try:
+                return data[len(bom):].decode(encoding), encoding
+            except UnicodeDecodeError:
+                break
+    try:
+        return data.decode("utf-8"), None
+    except UnicodeDecodeError:
+        return data.decode("latin-1"), "latin-1"
+
+
+def decode_source(data: bytes) -> Tuple[str, Optional[str]]:
+    """
+    The text of a source file's bytes, with line endings translated as
+    text-mode reads do, and the encoding it was transcoded from (None for UTF-8).
+    """
+    text, encoding = _decode(data)
+    if "\r" in text:
+        text = text.replace("\r\n", "\n").replace("\r", "\n")
+    return text, encoding
+
+
+def read_source(path: str) -> Tuple[str, Optional[str]]:
+    """Read a source file as text (see decode_source); OSError if it cannot be read."""
+    with open(path, "rb") as f:
+        return decode_source(f.read())
+
+
+def read_text(path: str) -> str:
+    """The text of a source file, whatever its encoding."""
+    return read_source(path)[0]
+
+
+def source_lines(text: str) -> List[str]:
+    """
+    The lines of source text numbered as the parsers number them: split at
+    line feeds alone, a CRLF's carriage return dropped. str.splitlines also
+    breaks at form feeds, NEL and U+2028, which Go and most languages read
+    as spaces or as characters of a comment or string.
+    """
+    lines = text.split("\n")
+    if lines[-1] == "":
+        lines.pop()
+    return [line[:-1] if line.endswith("\r") else line for line in lines]
+
+
+def content_hash(text: str, encoding: Optional[str] = None) -> str:
+    """The hash index entries keep of a file's text; a transcoded file's covers its encoding too."""
+    data = text.encode("utf-8")
+    return hashlib.sha1(data if encoding is None else encoding.encode("ascii") + b"\0" + data).hexdigest()
@@ -0,0 +1,576 @@
+"""Cross-file view of a project's SQL: the schema its migrations build, and the Go queries using it.
+
+Migration files are ordered the way their tools apply them, by the version
+their name starts with: golang-migrate (`000001_create_users.up.sql`, with
+`.down.sql` twins), goose and dbmate (`20240105120000_add_email.sql`) and
+Flyway (`V2_1__add_email.sql`; `U` undo files, `R__` repeatable ones after
+every versioned one). Other .sql files (a `schema.sql` dump) come first.
+The current schema is the fold of every up statement in that order, so a
+column a later migration drops is gone from it but remembered, with the
+statement that dropped it.
+
+Go queries (see xray.core.go_queries) are linked to tables and columns by
+name: the tables after FROM, JOIN, UPDATE, INTO and DELETE FROM (aliases
+and CTE names told apart), qualified `alias.column` references, INSERT
+column lists, and unqualified names that are columns of a table the query
+uses. Names compare case-insensitively.
+"""
+
+import os
+import re
+from typing import Any, Dict, Iterator, List, Optional, Tuple
+
+from xray.core.sql_parser import tokenize
+
+# Flyway V/U files, then numbered (golang-migrate, goose, dbmate) ones
+_FLYWAY = re.compile(r"^([VU])(\d+(?:[._]\d+)*)__")
+_REPEATABLE = re.compile(r"^R__")
+_NUMBERED = re.compile(r"^(\d+)(?=[_.-])")
+
+# Words of a query that are never table, alias or column names
+QUERY_KEYWORDS = {
+    "ALL", "AND", "ANY", "AS", "ASC", "BETWEEN", "BY", "CASE", "CAST", "CONFLICT", "CROSS", "CURRENT_DATE",
+    "CURRENT_TIME", "CURRENT_TIMESTAMP", "DEFAULT", "DELETE", "DESC", "DISTINCT", "DO", "DUPLICATE", "ELSE",
+    "END", "EXCEPT", "EXISTS", "FALSE", "FETCH", "FIRST", "FOR", "FROM", "FULL", "GROUP", "HAVING", "IGNORE",
+    "ILIKE", "IN", "INNER", "INSERT", "INTERSECT", "INTERVAL", "INTO", "IS", "JOIN", "KEY", "LAST", "LATERAL",
+    "LEFT", "LIKE", "LIMIT", "LOCKED", "NATURAL", "NEXT", "NOT", "NOTHING", "NOWAIT", "NULL", "NULLS",
+    "OFFSET", "ON", "ONLY", "OR", "ORDER", "OUTER", "OVER", "PARTITION", "RECURSIVE", "REPLACE", "RETURNING",
+    "RIGHT", "ROW", "ROWS", "SELECT", "SET", "SHARE", "SKIP", "THEN", "TRUE", "UNION", "UPDATE", "USING",
+    "VALUES", "WHEN", "WHERE", "WINDOW", "WITH", "UPSERT", "MERGE", "MATCHED", "TRUNCATE", "TABLE",
+}
+# What may follow a table name in place of an alias
+_AFTER_TABLE = {"WHERE", "JOIN", "ON", "SET", "LEFT", "RIGHT", "INNER", "OUTER", "FULL", "CROSS", "NATURAL",
+                "GROUP", "ORDER", "LIMIT", "OFFSET", "USING", "VALUES", "RETURNING", "UNION", "EXCEPT",
+                "INTERSECT", "HAVING", "WINDOW", "FOR", "SELECT", "DEFAULT", "OUTPUT", "LATERAL", "STRAIGHT_JOIN"}
+
+
+def migration_version(path: str) -> Tuple[int, Tuple[int, ...], bool]:
+    """
+    (rank, version, down) of a .sql file by its name: rank 0 for files
+    that are no migration, 1 for versioned ones, 2 for Flyway repeatables.
+    """
+    name = os.path.basename(path)
+    down = name.lower().endswith(".down.sql")
+    flyway = _FLYWAY.match(name)
+    if flyway:
+        return 1, tuple(int(part) for part in re.split(r"[._]", flyway.group(2))), flyway.group(1) == "U"
+    if _REPEATABLE.match(name):
+        return 2, (), False
+    numbered = _NUMBERED.match(name)
+    if numbered:
+        return 1, (int(numbered.group(1)),), down
+    return 0, (), down
+
+
+def query_references(text: str) -> Dict[str, Any]:
+    """
+    The tables and column names a query refers to: {"operation", "tables"
+    [{"name", "alias"?}], "columns" [{"name", "table"?}], "star"}. A
+    column's "table" is the table its qualifier (alias or name) stands for;
+    unqualified ones have none. INSERT column lists and UPDATE SET targets
+    name the statement's table.
+    """
+    tokens = tokenize(text)
+    words = [t.upper for t in tokens]
+    ctes = set()
+    for i, token in enumerate(tokens):
+        # WITH name [(columns)] AS (...), name AS (...)
+        if words[i] == "AS" and i + 1 < len(tokens) and tokens[i + 1].value == "(":
+            j = i - 1
+            if j >= 0 and tokens[j].value == ")":
+                while j >= 0 and tokens[j].value != "(":
+                    j -= 1
+                j -= 1
+            if j >= 0 and tokens[j].kind in ("word", "ident") and (j == 0 or words[j - 1] in ("WITH", "RECURSIVE")
+                                                               or tokens[j - 1].value == ","):
+                ctes.add(tokens[j].value.lower())
+    tables: List[Dict[str, Any]] = []
+    aliases: Dict[str, str] = {}
+    columns: List[Dict[str, Any]] = []
+    skip = set()
+    main_table = None
+
+    def table_at(i: int, source: bool) -> int:
+        """Read `name [AS] [alias]` at i (a FROM or JOIN source if source); return the index after it."""
+        nonlocal main_table
+        if i >= len(tokens) or tokens[i].kind not in ("word", "ident") or words[i] in QUERY_KEYWORDS:
+            return i
+        start = i
+        while i + 2 < len(tokens) and tokens[i + 1].value == "." and tokens[i + 2].kind in ("word", "ident"):
+            i += 2
+        name = tokens[i].value
+        skip.update(range(start, i + 1))
+        i += 1
+        if source and i < len(tokens) and tokens[i].value == "(":
+            # A function in FROM (generate_series(...)), not a table: skip it and its alias
+            depth = 0
+            while i < len(tokens):
+                depth += (tokens[i].value == "(") - (tokens[i].value == ")")
+                i += 1
+                if depth == 0:
+                    break
+            if i < len(tokens) and words[i] == "AS":
+                i += 1
+            if i < len(tokens) and tokens[i].kind in ("word", "ident") and words[i] not in _AFTER_TABLE:
+                aliases[tokens[i].value.lower()] = ""
+                skip.add(i)
+                i += 1
+            return i
+        entry: Dict[str, Any] = {"name": name}
+        if i < len(tokens) and words[i] == "AS":
+            skip.add(i)
+            i += 1
+        if i < len(tokens) and tokens[i].kind in ("word", "ident") and words[i] not in _AFTER_TABLE and \
+                words[i] not in QUERY_KEYWORDS:
+            entry["alias"] = tokens[i].value
+            skip.add(i)
+            i += 1
+        if name.lower() not in ctes:
+            tables.append(entry)
+            aliases[name.lower()] = name
+            if "alias" in entry:
+                aliases[entry["alias"].lower()] = name
+            if main_table is None:
+                main_table = name
+        elif "alias" in entry:
+            aliases[entry["alias"].lower()] = ""
+        return i
+
+    operation = next((w for w in words if w), "")
+    if operation == "WITH":
+        # The statement the CTEs are for
+        depth = 0
+        for token, word in zip(tokens, words):
+            depth += (token.value == "(") - (token.value == ")")
+            if depth == 0 and word in ("SELECT", "INSERT", "UPDATE", "DELETE", "MERGE"):
+                operation = word
+                break
+    i = 0
+    while i < len(tokens):
+        word = words[i]
+        if word in ("FROM", "JOIN", "UPDATE", "INTO", "TABLE") or (word == "USING" and operation == "DELETE"):
+            i = table_at(i + 1, word in ("FROM", "JOIN", "USING"))
+            # FROM a, b
+            while word == "FROM" and i < len(tokens) and tokens[i].value == ",":
+                i = table_at(i + 1, True)
+            continue
+        i += 1
+
+    # INSERT INTO t (a, b) and UPDATE t SET a = ..., b = ...
+    for i, token in enumerate(tokens):
+        if words[i] == "INTO" and i + 1 < len(tokens):
+            j = i + 1
+            while j < len(tokens) and j in skip:
+                j += 1
+            if j < len(tokens) and tokens[j].value == "(" and main_table:
+                j += 1
+                while j < len(tokens) and tokens[j].value != ")":
+                    if tokens[j].kind in ("word", "ident"):
+                        columns.append({"name": tokens[j].value, "table": main_table})
+                        skip.add(j)
+                    j += 1
+        if words[i] == "SET" and operation == "UPDATE" and main_table:
+            j = i + 1
+            depth = 0
+            while j < len(tokens) and not (depth == 0 and words[j] in ("WHERE", "FROM", "RETURNING")):
+                if tokens[j].value == "(":
+                    depth += 1
+                elif tokens[j].value == ")":
+                    depth -= 1
+                elif depth == 0 and tokens[j].kind in ("word", "ident") and j + 1 < len(tokens) and \
+                        tokens[j + 1].value == "=" and (tokens[j - 1].value in (",",) or j == i + 1):
+                    columns.append({"name": tokens[j].value, "table": main_table})
+                    skip.add(j)
+                j += 1
+
+    star = False
+    for i, token in enumerate(tokens):
+        if i in skip:
+            continue
+        if token.value == "*" and token.kind == "punct" and i > 0 and \
+                (words[i - 1] in ("SELECT", "DISTINCT") or tokens[i - 1].value in (",", ".")):
+            star = True
+            continue
+        if token.kind not in ("word", "ident") or (token.kind == "word" and words[i] in QUERY_KEYWORDS):
+            continue
+        previous = tokens[i - 1] if i else None
+        following = tokens[i + 1] if i + 1 < len(tokens) else None
+        if previous is not None and previous.kind == "punct" and previous.value == "::":
+            continue
+        if previous is not None and (words[i - 1] == "AS" or previous.value == ")"):
+            # An output or subquery alias
+            continue
+        if following is not None and following.value == "(":
+            continue
+        if following is not None and following.value == "." and i + 2 < len(tokens):
+            qualifier = token.value.lower()
+            target = tokens[i + 2]
+            skip.add(i + 2)
+            if target.kind in ("word", "ident"):
+                table = aliases.get(qualifier)
+                if qualifier == "excluded":
+                    table = main_table
+                if table:
+                    columns.append({"name": target.value, "table": table})
+            elif target.value == "*":
+                star = True
+            continue
+        if previous is not None and previous.value == ".":
+            continue
+        if token.value.lower() in aliases or token.value.lower() in ctes:
+            continue
+        columns.append({"name": token.value})
+    seen = set()
+    unique = []
+    for column in columns:
+        key = (column["name"].lower(), (column.get("table") or "").lower())
+        if key not in seen:
+            seen.add(key)
+            unique.append(column)
+    return {"operation": operation, "tables": tables, "columns": unique, "star": star}
+
+
+class SqlProject:
+    """The parsed .sql files of a project folded, in migration order, into the current schema."""
+
+    def __init__(self, files: Dict[str, Dict[str, Any]], root: str):
+        self.files = files
+        self.root = root
+        self.order = sorted(files, key=self._order_key)
+        self._schema: Optional[Dict[str, Any]] = None
+
+    def _order_key(self, path: str):
+        rank, version, down = migration_version(path)
+        return rank, version, down, path
+
+    def symbols(self) -> Iterator[Tuple[str, Dict[str, Any]]]:
+        """(path, symbol) for every table, column and index, files in sorted order."""
+        for path in sorted(self.files):
+            for symbol in self.files[path]["symbols"]:
+                yield path, symbol
+
+    def dotted_name(self, path: str) -> str:
+        """The file name a table or column is declared in."""
+        return os.path.basename(path)
+
+    def migrations(self) -> List[Dict[str, Any]]:
+        """The .sql files that change the schema, in the order they are applied."""
+        result = []
+        for path in self.order:
+            statements = self.files[path].get("statements", [])
+            if not statements:
+                continue
+            rank, version, down = migration_version(path)
+            entry: Dict[str, Any] = {"path": path, "kind": ("schema", "migration", "repeatable")[rank]}
+            if version:
+                entry["version"] = ".".join(str(part) for part in version)
+            if down:
+                entry["down"] = True
+            entry["statements"] = sum(1 for s in statements if not s.get("down"))
+            result.append(entry)
+        return result
+
+    def schema(self) -> Dict[str, Any]:
+        """
+        The folded schema: {"tables", "dropped_tables", "warnings"}. tables
+        maps a lower-cased name to {"name", "defined_at", "columns",
+        "indexes", "dropped_columns", "history"}; columns and dropped
+        columns map lower-cased names to their definitions.
+        """
+        if self._schema is None:
+            self._schema = _Fold(self).run()
+        return self._schema
+
+    def table(self, name: str) -> Optional[Dict[str, Any]]:
+        return self.schema()["tables"].get(name.lower())
+
+    def describe(self, table: Dict[str, Any], history: bool = False) -> Dict[str, Any]:
+        """A folded table as listed: columns, indexes and dropped columns as lists, in declaration order."""
+        entry = {k: table[k] for k in ("name", "schema", "defined_at") if k in table}
+        entry["columns"] = list(table["columns"].values())
+        entry["indexes"] = list(table["indexes"].values())
+        if table["dropped_columns"]:
+            entry["dropped_columns"] = list(table["dropped_columns"].values())
+        if history:
+            entry["history"] = table["history"]
+        return entry
+
+    def unparsed(self) -> List[Dict[str, Any]]:
+        """The schema statements no file's parse could read, with their files."""
+        return [{"path": path, **error} for path in self.order
+                for error in self.files[path].get("parseErrors", [])]
+
+    # Queries
+
+    def _links(self, query: Dict[str, Any]) -> Dict[str, Any]:
+        """A Go query's references resolved against the schema: {"tables": {lower: name}, "columns": {...}}."""
+        refs = query_references(query["query"])
+        schema = self.schema()
+        tables: Dict[str, str] = {}
+        for entry in refs["tables"]:
+            tables[entry["name"].lower()] = entry["name"]
+        columns: Dict[str, List[Dict[str, Any]]] = {}
+        for column in refs["columns"]:
+            if column.get("table"):
+                owners = [column["table"].lower()]
+            else:
+                # Unqualified: a column of whichever table of the query has one by that name
+                owners = [t for t in tables if t in schema["tables"] and
+                          (column["name"].lower() in schema["tables"][t]["columns"] or
+                           column["name"].lower() in schema["tables"][t]["dropped_columns"])]
+            for owner in owners:
+                columns.setdefault(owner, []).append({**column, "qualified": bool(column.get("table"))})
+        return {"operation": refs["operation"], "tables": tables, "columns": columns, "star": refs["star"]}
+
+    def usages(self, table: str, column: Optional[str], queries: List[Dict[str, Any]]) -> Dict[str, Any]:
+        """
+        The queries using a table (or one of its columns): each with the
+        columns of the table it names, and the Go functions they are in.
+        """
+        key = table.lower()
+        known = self.table(table)
+        matches = []
+        for query in queries:
+            links = self._links(query)
+            if key not in links["tables"]:
+                continue
+            names = sorted({c["name"] for c in links["columns"].get(key, [])}, key=str.lower)
+            if column is not None and column.lower() not in {n.lower() for n in names} and not links["star"]:
+                continue
+            entry = {k: query[k] for k in ("query", "dynamic", "function", "path", "line", "column")}
+            entry["operation"] = links["operation"]
+            entry["columns"] = names
+            if links["star"]:
+                entry["star"] = True
+            matches.append(entry)
+        functions: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        for entry in matches:
+            function = functions.setdefault((entry["path"], entry["function"]), {
+                "function": entry["function"], "path": entry["path"], "operations": [], "queries": 0})
+            function["queries"] += 1
+            if entry["operation"] and entry["operation"] not in function["operations"]:
+                function["operations"].append(entry["operation"])
+        result: Dict[str, Any] = {"table": known["name"] if known else table}
+        if column is not None:
+            result["column"] = column
+        if known:
+            result["defined_at"] = known["defined_at"]
+        else:
+            result["in_schema"] = False
+        result["queries"] = matches
+        result["functions"] = list(functions.values())
+        result["total_count"] = len(matches)
+        return result
+
+    def stale(self, queries: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """
+        Queries out of step with the folded schema: using a dropped (or
+        renamed) table or column, or a qualified column the table never had.
+        """
+        schema = self.schema()
+        issues = []
+        for query in queries:
+            links = self._links(query)
+            where = {k: query[k] for k in ("query", "function", "path", "line", "column")}
+            for key, name in links["tables"].items():
+                table = schema["tables"].get(key)
+                if table is None:
+                    dropped = schema["dropped_tables"].get(key)
+                    if dropped:
+                        issues.append({"kind": "renamed_table" if dropped.get("renamed_to") else "dropped_table",
+                                       "table": name, **_dropped_fields(dropped), **where})
+                    continue
+                for column in links["columns"].get(key, []):
+                    lower = column["name"].lower()
+                    if lower in table["columns"]:
+                        continue
+                    dropped = table["dropped_columns"].get(lower)
+                    if dropped:
+                        issues.append({"kind": "renamed_column" if dropped.get("renamed_to") else "dropped_column",
+                                       "table": table["name"], "column_name": column["name"],
+                                       **_dropped_fields(dropped), **where})
+                    elif column["qualified"]:
+                        issues.append({"kind": "unknown_column", "table": table["name"],
+                                       "column_name": column["name"], **where})
+        return issues
+
+
+def _dropped_fields(dropped: Dict[str, Any]) -> Dict[str, Any]:
+    return {k: dropped[k] for k in ("defined_at", "dropped_at", "renamed_to") if k in dropped}
+
+
+class _Fold:
+    """Applies the up statements of a SqlProject's files in migration order."""
+
+    def __init__(self, project: SqlProject):
+        self.project = project
+        self.tables: Dict[str, Dict[str, Any]] = {}
+        self.dropped_tables: Dict[str, Dict[str, Any]] = {}
+        self.warnings: List[Dict[str, Any]] = []
+        self.path = ""
+
+    def run(self) -> Dict[str, Any]:
+        for path in self.project.order:
+            if migration_version(path)[2]:
+                continue
+            self.path = path
+            for statement in self.project.files[path].get("statements", []):
+                if statement.get("down"):
+                    continue
+                getattr(self, statement["op"])(statement)
+        return {"tables": self.tables, "dropped_tables": self.dropped_tables, "warnings": self.warnings}
+
+    def at(self, line: int) -> Dict[str, Any]:
+        return {"path": self.path, "line": line}
+
+    def warn(self, statement: Dict[str, Any], message: str):
+        self.warnings.append({**self.at(statement["line"]), "message": message})
+
+    def history(self, table: Dict[str, Any], statement: Dict[str, Any], change: str):
+        table["history"].append({**self.at(statement["line"]), "change": change})
+
+    def target(self, statement: Dict[str, Any], name: str) -> Optional[Dict[str, Any]]:
+        table = self.tables.get(name.lower())
+        if table is None:
+            self.warn(statement, f"{statement['op']} on unknown table {name}")
+        return table
+
+    def column_record(self, column: Dict[str, Any], line: int) -> Dict[str, Any]:
+        record = {k: v for k, v in column.items() if k != "line"}
+        record["defined_at"] = self.at(line)
+        return record
+
+    def create_table(self, statement: Dict[str, Any]):
+        key = statement["table"].lower()
+        if key in self.tables and statement.get("if_not_exists"):
+            return
+        table = {
+            "name": statement["table"],
+            "defined_at": self.at(statement["line"]),
+            "columns": {c["name"].lower(): self.column_record(c, c["line"]) for c in statement["columns"]},
+            "indexes": {i["name"].lower(): {**i, "defined_at": self.at(statement["line"])}
+                        for i in statement.get("indexes", [])},
+            "dropped_columns": {},
+            "history": [],
+        }
+        if statement.get("schema"):
+            table["schema"] = statement["schema"]
+        self.tables[key] = table
+        self.dropped_tables.pop(key, None)
+        self.history(table, statement, "create table")
+
+    def drop_table(self, statement: Dict[str, Any]):
+        for name in statement["tables"]:
+            table = self.tables.pop(name.lower(), None)
+            if table is not None:
+                self.dropped_tables[name.lower()] = {"name": table["name"], "defined_at": table["defined_at"],
+                                                     "dropped_at": self.at(statement["line"])}
+
+    def rename_table(self, statement: Dict[str, Any], table: Optional[Dict[str, Any]] = None):
+        table = table or self.target(statement, statement["table"])
+        if table is None:
+            return
+        old = table["name"]
+        self.tables.pop(old.lower())
+        table["name"] = statement["to"]
+        self.tables[statement["to"].lower()] = table
+        self.dropped_tables[old.lower()] = {"name": old, "defined_at": table["defined_at"],
+                                            "dropped_at": self.at(statement["line"]), "renamed_to": statement["to"]}
+        self.history(table, statement, f"rename table {old} to {statement['to']}")
+
+    def create_index(self, statement: Dict[str, Any]):
+        table = self.target(statement, statement["table"])
+        if table is None:
+            return
+        index = {k: statement[k] for k in ("name", "columns", "unique") if k in statement}
+        table["indexes"][statement["name"].lower()] = {**index, "defined_at": self.at(statement["line"])}
+        self.history(table, statement, f"create index {statement['name']}")
+
+    def drop_index(self, statement: Dict[str, Any]):
+        key = statement["name"].lower()
+        for table in self.tables.values():
+            if key in table["indexes"]:
+                del table["indexes"][key]
+                self.history(table, statement, f"drop index {statement['name']}")
+                return
+
+    def alter_table(self, statement: Dict[str, Any]):
+        table = self.target(statement, statement["table"])
+        if table is None:
+            return
+        line = statement["line"]
+        for action in statement["actions"]:
+            kind = action["action"]
+            if kind in ("add_column", "add_columns"):
+                for column in action.get("columns") or [action["column"]]:
+                    table["columns"][column["name"].lower()] = self.column_record(column, column["line"])
+                    table["dropped_columns"].pop(column["name"].lower(), None)
+                    self.history(table, statement, f"add column {column['name']}")
+            elif kind == "drop_column":
+                self.drop_column(table, action["column"], line)
+                self.history(table, statement, f"drop column {action['column']}")
+            elif kind == "rename_column":
+                column = table["columns"].get(action["from"].lower())
+                if column is None:
+                    self.warn(statement, f"rename of unknown column {table['name']}.{action['from']}")
+                    continue
+                self.drop_column(table, action["from"], line, action["to"])
+                table["columns"][action["to"].lower()] = {**column, "name": action["to"]}
+                self.history(table, statement, f"rename column {action['from']} to {action['to']}")
+            elif kind in ("modify_column", "change_column"):
+                column = action["column"]
+                old = action.get("from", column["name"])
+                previous = table["columns"].get(old.lower())
+                if old.lower() != column["name"].lower():
+                    self.drop_column(table, old, line, column["name"])
+                record = self.column_record(column, column["line"])
+                if previous:
+                    record["defined_at"] = previous["defined_at"]
+                    record["changed_at"] = self.at(line)
+                table["columns"][column["name"].lower()] = record
+                self.history(table, statement, f"{kind.split('_')[0]} column {column['name']}")
+            elif kind == "alter_column":
+                column = table["columns"].get(action["column"].lower())
+                if column is None:
+                    self.warn(statement, f"alter of unknown column {table['name']}.{action['column']}")
+                    continue
+                for field in ("column_type", "nullable", "default"):
+                    if field in action:
+                        if action[field] is None:
+                            column.pop(field, None)
+                        else:
+                            column[field] = action[field]
+                column["changed_at"] = self.at(line)
+                self.history(table, statement, f"alter column {action['column']}")
+            elif kind == "rename_table":
+                self.rename_table({**statement, "to": action["to"]}, table)
+            elif kind == "add_index":
+                index = {k: action[k] for k in ("name", "columns", "unique") if k in action}
+                table["indexes"][action["name"].lower()] = {**index, "defined_at": self.at(line)}
+                self.history(table, statement, f"add index {action['name']}")
+            elif kind == "drop_index":
+                table["indexes"].pop(action["name"].lower(), None)
+                self.history(table, statement, f"drop index {action['name']}")
+            elif kind == "rename_index":
+                index = table["indexes"].pop(action["from"].lower(), None)
+                if index is not None:
+                    table["indexes"][action["to"].lower()] = {**index, "name": action["to"]}
+            elif kind == "add_key":
+                for name in action["columns"]:
+                    column = table["columns"].get(name.lower())
+                    if column is None:
+                        continue
+                    if action["kind"] == "primary_key":
+                        column["primary_key"] = True
+                        column["nullable"] = False
+                    elif action["kind"] == "foreign_key" and len(action["columns"]) == 1:
+                        column["references"] = action["references"]
+
+    def drop_column(self, table: Dict[str, Any], name: str, line: int, renamed_to: Optional[str] = None):
+        column = table["columns"].pop(name.lower(), None)
+        dropped = {"name": column["name"] if column else name, "dropped_at": self.at(line)}
+        if column:
+            dropped["defined_at"] = column["defined_at"]
+        if renamed_to:
+            dropped["renamed_to"] = renamed_to
+        table["dropped_columns"][name.lower()] = dropped
@@ -0,0 +1,747 @@
+"""SQL parser for XRAY - statement splitter and schema (DDL) reader.
+
+Reads .sql schema and migration files the way migration tools apply them.
+Statements end at semicolons outside strings, comments, quoted identifiers
+and Postgres dollar quotes; a MySQL `DELIMITER //` line changes the
+terminator, and goose / sql-migrate `StatementBegin` ... `StatementEnd`
+blocks are one statement. The Down sections of goose (`-- +goose Down`),
+sql-migrate (`-- +migrate Down`) and dbmate (`-- migrate:down`) files are
+marked "down" so the schema fold (see xray.core.sql_analysis) skips them.
+
+Of the statements, CREATE TABLE, ALTER TABLE (ADD/DROP/RENAME/ALTER/MODIFY/
+CHANGE COLUMN, RENAME TO, ADD/DROP INDEX and keys), CREATE INDEX, DROP
+TABLE, DROP INDEX and RENAME TABLE are read, in Postgres and MySQL syntax;
+everything else (INSERT, CREATE FUNCTION, GRANT, ...) is skipped. A schema
+statement that cannot be read is kept as a parse error, never fatal.
+Unquoted names are kept as written; they compare case-insensitively.
+"""
+
+import bisect
+import re
+from typing import Any, Dict, List, Optional, Tuple
+
+# Bump whenever the shape of extracted records changes so cached results are discarded
+SQL_PARSER_VERSION = 1
+
+# Longest signature or statement text quoted from a file
+MAX_SIGNATURE = 120
+
+# Where a column definition's type stops and its constraints begin
+COLUMN_CONSTRAINTS = {
+    "NOT", "NULL", "DEFAULT", "PRIMARY", "REFERENCES", "UNIQUE", "CHECK", "CONSTRAINT", "COLLATE",
+    "GENERATED", "AUTO_INCREMENT", "AUTOINCREMENT", "COMMENT", "ON", "IDENTITY", "FIRST", "AFTER",
+    "VISIBLE", "INVISIBLE", "AS", "KEY", "STORED", "VIRTUAL",
+}
+# Table-level definitions of a CREATE TABLE, and what ALTER TABLE ADD may add besides a column
+TABLE_CONSTRAINTS = {"CONSTRAINT", "PRIMARY", "UNIQUE", "FOREIGN", "CHECK", "EXCLUDE", "INDEX", "KEY",
+                     "FULLTEXT", "SPATIAL", "LIKE", "PERIOD"}
+
+_SCAN = re.compile(
+    r"--[^\n]*|/\*.*?(?:\*/|\Z)|'(?:[^'\\]|\\.|'')*(?:'|\Z)|\"(?:[^\"]|\"\")*(?:\"|\Z)|`[^`]*(?:`|\Z)"
+    r"|\$([A-Za-z_]\w*|)\$.*?(?:\$\1\$|\Z)|^[ \t]*#[^\n]*|;", re.S | re.M)
+# After a DELIMITER line: no dollar quotes (`DELIMITER $$` is common) and no semicolon terminator
+_SCAN_DELIMITED = re.compile(
+    r"--[^\n]*|/\*.*?(?:\*/|\Z)|'(?:[^'\\]|\\.|'')*(?:'|\Z)|\"(?:[^\"]|\"\")*(?:\"|\Z)|`[^`]*(?:`|\Z)"
+    r"|^[ \t]*#[^\n]*", re.S | re.M)
+_DELIMITER_LINE = re.compile(r"^[ \t]*DELIMITER[ \t]+(\S+)[ \t]*$", re.M | re.I)
+# Migration tool annotations: goose and sql-migrate sections and blocks, dbmate sections
+_DIRECTIVE = re.compile(r"^--\s*(?:\+(?:goose|migrate)\s+(Up|Down|StatementBegin|StatementEnd)\b"
+                        r"|migrate:(up|down)\b)", re.I)
+_TOKEN = re.compile(
+    r"(?P<space>\s+)|(?P<comment>--[^\n]*|/\*.*?(?:\*/|\Z))"
+    r"|(?P<string>[EeNnBbXx]?'(?:[^'\\]|\\.|'')*(?:'|\Z)|\$(?P<tag>[A-Za-z_]\w*|)\$.*?(?:\$(?P=tag)\$|\Z))"
+    r"|(?P<ident>\"(?:[^\"]|\"\")*(?:\"|\Z)|`[^`]*(?:`|\Z)|\[[^\]\n]*\])"
+    r"|(?P<placeholder>\{[^{}]*\})|(?P<param>\$\d+|\?|(?<![:\w]):[A-Za-z_]\w*)"
+    r"|(?P<number>\d+(?:\.\d*)?(?:[eE][+-]?\d+)?|\.\d+)|(?P<word>[A-Za-z_][\w$]*)"
+    r"|(?P<punct>::|<>|<=|>=|!=|\|\||->>?|[^\s\w])", re.S)
+
+
+class Token:
+    """A single SQL token with its offsets in the file."""
+
+    __slots__ = ("kind", "value", "start", "end")
+
+    def __init__(self, kind: str, value: str, start: int, end: int):
+        self.kind = kind
+        self.value = value
+        self.start = start
+        self.end = end
+
+    @property
+    def upper(self) -> str:
+        """The value in upper case for an unquoted word (a keyword candidate), else ""."""
+        return self.value.upper() if self.kind == "word" else ""
+
+    def __repr__(self):
+        return f"Token({self.kind}, {self.value!r}, {self.start})"
+
+
+def tokenize(src: str, start: int = 0, end: Optional[int] = None) -> List[Token]:
+    """
+    Split SQL text (src[start:end]) into tokens, comments and whitespace
+    dropped. Quoted identifiers ("x", `x`, [x]) come back unquoted as
+    "ident" tokens; `{expr}` placeholders of rendered Go queries (see
+    xray.core.go_queries) as "placeholder" tokens.
+    """
+    end = len(src) if end is None else end
+    tokens = []
+    pos = start
+    while pos < end:
+        match = _TOKEN.match(src, pos, end)
+        pos = match.end()
+        kind = match.lastgroup
+        if kind == "tag":
+            kind = "string"
+        if kind in ("space", "comment"):
+            continue
+        value = match.group(0)
+        if value == "#" and not src[src.rfind("\n", 0, match.start()) + 1:match.start()].strip():
+            # A MySQL comment, when it starts the line
+            newline = src.find("\n", pos, end)
+            pos = end if newline < 0 else newline
+            continue
+        if kind == "ident":
+            value = value[1:-1].replace(value[0] * 2, value[0]) if value[0] != "[" else value[1:-1]
+        tokens.append(Token(kind, value, match.start(), match.end()))
+    return tokens
+
+
+def unquote(literal: str) -> str:
+    """The text of a SQL string literal ('it''s' -> it's), dollar-quoted ones included."""
+    if literal.startswith("$"):
+        tag_end = literal.index("$", 1) + 1
+        return literal[tag_end:len(literal) - tag_end]
+    if literal[:1] in "EeNnBbXx" and literal[1:2] == "'":
+        literal = literal[1:]
+    return literal[1:-1].replace("''", "'")
+
+
+def _clip(text: str) -> str:
+    text = " ".join(text.split())
+    return text if len(text) <= MAX_SIGNATURE else text[:MAX_SIGNATURE - 3] + "..."
+
+
+def split_statements(src: str) -> List[Tuple[int, int, bool]]:
+    """(start, end, down) offsets of every statement of a file, terminators left out."""
+    statements: List[Tuple[int, int, bool]] = []
+    down = False
+    block = False
+    pos = 0
+
+    def emit(start: int, stop: int):
+        if src[start:stop].strip():
+            statements.append((start, stop, down))
+
+    segments = []
+    delimiter, offset = ";", 0
+    for match in _DELIMITER_LINE.finditer(src):
+        segments.append((offset, match.start(), delimiter))
+        delimiter, offset = match.group(1), match.end()
+    segments.append((offset, len(src), delimiter))
+
+    for seg_start, seg_end, delimiter in segments:
+        pos = max(pos, seg_start)
+        scanner = _SCAN if delimiter == ";" else _SCAN_DELIMITED
+        last = seg_start
+        for match in scanner.finditer(src, seg_start, seg_end):
+            if delimiter != ";" and not block:
+                # Terminators sit in the code between strings and comments
+                gap = src.find(delimiter, last, match.start())
+                while gap >= 0:
+                    emit(pos, gap)
+                    pos = gap + len(delimiter)
+                    gap = src.find(delimiter, pos, match.start())
+            last = match.end()
+            text = match.group(0)
+            if text == ";":
+                if not block:
+                    emit(pos, match.start())
+                    pos = match.end()
+                continue
+            if not text.startswith("--"):
+                continue
+            directive = _DIRECTIVE.match(text)
+            if not directive:
+                continue
+            word = (directive.group(1) or directive.group(2)).lower()
+            emit(pos, match.start())
+            pos = match.end()
+            if word in ("up", "down"):
+                down = word == "down"
+            else:
+                block = word == "statementbegin"
+        if delimiter != ";" and not block:
+            gap = src.find(delimiter, last, seg_end)
+            while gap >= 0:
+                emit(pos, gap)
+                pos = gap + len(delimiter)
+                gap = src.find(delimiter, pos, seg_end)
+        if seg_end < len(src):
+            # A DELIMITER line ends the statement before it
+            emit(pos, seg_end)
+            pos = seg_end
+    emit(pos, len(src))
+    return statements
+
+
+class ParseFailure(Exception):
+    """A schema statement this parser cannot read."""
+
+
+class SqlFileParser:
+    """Reads the schema statements of one .sql file into symbols and fold operations."""
+
+    def __init__(self, content: str):
+        self.src = content
+        self.line_starts = [0] + [m.end() for m in re.finditer(r"\n", content)]
+        self.symbols: List[Dict[str, Any]] = []
+        self.statements: List[Dict[str, Any]] = []
+        self.errors: List[Dict[str, Any]] = []
+        self.tokens: List[Token] = []
+        self.pos = 0
+        self.down = False
+
+    # Positions
+
+    def line_of(self, offset: int) -> int:
+        return bisect.bisect_right(self.line_starts, offset)
+
+    def column_of(self, offset: int) -> int:
+        return offset - self.line_starts[self.line_of(offset) - 1] + 1
+
+    def text(self, first: Token, last: Token) -> str:
+        return " ".join(self.src[first.start:last.end].split())
+
+    # Token cursor
+
+    def peek(self, offset: int = 0) -> Optional[Token]:
+        index = self.pos + offset
+        return self.tokens[index] if index < len(self.tokens) else None
+
+    def at(self, *words: str) -> bool:
+        """Whether the next tokens are these keywords."""
+        for offset, word in enumerate(words):
+            token = self.peek(offset)
+            if token is None or token.upper != word:
+                return False
+        return True
+
+    def accept(self, *words: str) -> bool:
+        if self.at(*words):
+            self.pos += len(words)
+            return True
+        return False
+
+    def expect(self, word: str):
+        if not self.accept(word):
+            token = self.peek()
+            raise ParseFailure(f"expected {word}, found {token.value if token else 'end of statement'}")
+
+    def name(self) -> Tuple[Optional[str], str, Token]:
+        """(schema, name, last token) of a possibly schema-qualified name."""
+        parts = []
+        token = self.peek()
+        while token is not None and token.kind in ("word", "ident"):
+            parts.append(token.value)
+            self.pos += 1
+            last = token
+            if not (self.peek() is not None and self.peek().value == "." and self.peek(1) is not None):
+                break
+            self.pos += 1
+            token = self.peek()
+        if not parts:
+            token = self.peek()
+            raise ParseFailure(f"expected a name, found {token.value if token else 'end of statement'}")
+        return (".".join(parts[:-1]) or None), parts[-1], last
+
+    def skip_group(self):
+        """Skip a parenthesized group starting at the cursor."""
+        depth = 0
+        while self.peek() is not None:
+            value = self.peek().value if self.peek().kind == "punct" else ""
+            self.pos += 1
+            if value == "(":
+                depth += 1
+            elif value == ")":
+                depth -= 1
+                if depth <= 0:
+                    return
+
+    def group_items(self) -> List[List[Token]]:
+        """The comma-separated items of the parenthesized group at the cursor, consumed."""
+        if self.peek() is None or self.peek().value != "(":
+            raise ParseFailure("expected '('")
+        self.pos += 1
+        items: List[List[Token]] = [[]]
+        depth = 0
+        while True:
+            token = self.peek()
+            if token is None:
+                raise ParseFailure("unclosed '('")
+            self.pos += 1
+            if token.kind == "punct" and token.value == "(":
+                depth += 1
+            elif token.kind == "punct" and token.value == ")":
+                if depth == 0:
+                    if len(items) > 1 and not all(items):
+                        raise ParseFailure("empty item in a list")
+                    return [item for item in items if item]
+                depth -= 1
+            elif token.kind == "punct" and token.value == "," and depth == 0:
+                items.append([])
+                continue
+            items[-1].append(token)
+
+    def split_items(self, tokens: List[Token]) -> List[List[Token]]:
+        """Top-level comma-separated parts of a token run."""
+        items: List[List[Token]] = [[]]
+        depth = 0
+        for token in tokens:
+            if token.kind == "punct" and token.value == "(":
+                depth += 1
+            elif token.kind == "punct" and token.value == ")":
+                depth -= 1
+            elif token.kind == "punct" and token.value == "," and depth == 0:
+                items.append([])
+                continue
+            items[-1].append(token)
+        return [item for item in items if item]
+
+    # Records
+
+    def symbol(self, name: str, kind: str, name_token: Token, last: Token, signature: str,
+               container: Optional[str] = None, **extra) -> Dict[str, Any]:
+        record = {
+            "name": name,
+            "type": kind,
+            "signature": _clip(signature),
+            "start_line": self.line_of(name_token.start),
+            "column": self.column_of(name_token.start),
+            "end_line": self.line_of(last.end - 1),
+            "language": "sql",
+        }
+        if container:
+            record["container"] = container
+        record.update({k: v for k, v in extra.items() if v is not None})
+        if self.down:
+            record["down"] = True
+        self.symbols.append(record)
+        return record
+
+    def statement(self, op: str, first: Token, **fields) -> Dict[str, Any]:
+        record = {"op": op, "line": self.line_of(first.start),
+                  **{k: v for k, v in fields.items() if v is not None}}
+        if self.down:
+            record["down"] = True
+        self.statements.append(record)
+        return record
+
+    # Parsing
+
+    def parse(self) -> Dict[str, Any]:
+        for start, end, down in split_statements(self.src):
+            self.tokens = tokenize(self.src, start, end)
+            self.pos = 0
+            self.down = down
+            if not self.tokens:
+                continue
+            symbols, statements = len(self.symbols), len(self.statements)
+            try:
+                self.schema_statement()
+            except (ParseFailure, IndexError) as e:
+                del self.symbols[symbols:]
+                del self.statements[statements:]
+                first = self.tokens[0]
+                self.errors.append({
+                    "kind": "unparsed_statement",
+                    "line": self.line_of(first.start),
+                    "column": self.column_of(first.start),
+                    "message": str(e) or "statement not understood",
+                    "statement": _clip(self.text(first, self.tokens[-1])),
+                })
+        result: Dict[str, Any] = {"symbols": self.symbols, "statements": self.statements}
+        if self.errors:
+            result["parseErrors"] = self.errors
+        return result
+
+    def schema_statement(self):
+        first = self.peek()
+        if self.accept("CREATE"):
+            self.accept("OR", "REPLACE")
+            while self.accept("GLOBAL") or self.accept("LOCAL") or self.accept("TEMP") or \
+                    self.accept("TEMPORARY") or self.accept("UNLOGGED"):
+                pass
+            if self.accept("TABLE"):
+                self.create_table(first)
+            elif self.at("INDEX") or self.at("UNIQUE") or self.at("FULLTEXT") or self.at("SPATIAL"):
+                self.create_index(first)
+        elif self.accept("ALTER", "TABLE"):
+            self.alter_table(first)
+        elif self.accept("DROP", "TABLE"):
+            self.accept("IF", "EXISTS")
+            tables = []
+            while self.peek() is not None and self.peek().kind in ("word", "ident"):
+                if self.peek().upper in ("CASCADE", "RESTRICT"):
+                    break
+                tables.append(self.name()[1])
+                if not (self.peek() is not None and self.peek().value == ","):
+                    break
+                self.pos += 1
+            if not tables:
+                raise ParseFailure("expected a table name")
+            self.statement("drop_table", first, tables=tables)
+        elif self.accept("DROP", "INDEX"):
+            self.accept("CONCURRENTLY")
+            self.accept("IF", "EXISTS")
+            _, name, _ = self.name()
+            table = self.name()[1] if self.accept("ON") else None
+            self.statement("drop_index", first, name=name, table=table)
+        elif self.accept("RENAME", "TABLE"):
+            while True:
+                _, old, _ = self.name()
+                self.expect("TO")
+                _, new, _ = self.name()
+                self.statement("rename_table", first, table=old, to=new)
+                if not (self.peek() is not None and self.peek().value == ","):
+                    break
+                self.pos += 1
+
+    def create_table(self, first: Token):
+        if_not_exists = self.accept("IF", "NOT", "EXISTS") or None
+        schema, table, last = self.name()
+        columns: List[Dict[str, Any]] = []
+        indexes: List[Dict[str, Any]] = []
+        keys: List[Dict[str, Any]] = []
+        record = self.symbol(table, "table", last, self.tokens[-1], self.text(first, last), schema=schema)
+        if self.peek() is not None and self.peek().value == "(":
+            for item in self.group_items():
+                if _is_constraint(item):
+                    self.table_constraint(item, table, keys, indexes)
+                else:
+                    columns.append(self.column(item, table))
+        elif self.at("AS") or self.at("LIKE"):
+            record["as_select"] = True
+        for key in keys:
+            self.apply_key(key, columns)
+        self.statement("create_table", first, table=table, schema=schema, if_not_exists=if_not_exists,
+                       columns=columns, indexes=indexes or None)
+
+    def column(self, item: List[Token], table: str) -> Dict[str, Any]:
+        """One column definition (`email TEXT NOT NULL UNIQUE`), recorded as a column symbol."""
+        if item[0].kind not in ("word", "ident"):
+            raise ParseFailure(f"expected a column name, found {item[0].value}")
+        name_token = item[0]
+        i = 1
+        depth = 0
+        while i < len(item):
+            token = item[i]
+            if token.kind == "punct" and token.value == "(":
+                depth += 1
+            elif token.kind == "punct" and token.value == ")":
+                depth -= 1
+            elif depth == 0 and token.upper in COLUMN_CONSTRAINTS:
+                break
+            i += 1
+        if i == 1:
+            raise ParseFailure(f"column {name_token.value} has no type")
+        column: Dict[str, Any] = {"name": name_token.value, "column_type": self.text(item[1], item[i - 1]),
+                                  "line": self.line_of(name_token.start)}
+        self.column_constraints(item[i:], column)
+        self.symbol(column["name"], "column", name_token, item[-1], self.text(item[0], item[-1]), table,
+                    **{k: v for k, v in column.items() if k not in ("name", "line")})
+        return column
+
+    def column_constraints(self, tokens: List[Token], column: Dict[str, Any]):
+        i = 0
+        while i < len(tokens):
+            word = tokens[i].upper
+            following = tokens[i + 1].upper if i + 1 < len(tokens) else ""
+            if word == "NOT" and following == "NULL":
+                column["nullable"] = False
+                i += 2
+            elif word == "NULL":
+                column["nullable"] = True
+                i += 1
+            elif word == "PRIMARY" and following == "KEY":
+                column["primary_key"] = True
+                column["nullable"] = False
+                i += 2
+            elif word == "UNIQUE":
+                column["unique"] = True
+                i += 2 if following == "KEY" else 1
+            elif word == "DEFAULT" and i + 1 < len(tokens):
+                start = i + 1
+                i = self.constraint_end(tokens, start + 1)
+                column["default"] = self.text(tokens[start], tokens[i - 1])
+            elif word == "REFERENCES":
+                i += 1
+                column["references"], i = self.references(tokens, i)
+            elif word in ("CHECK", "AS", "GENERATED"):
+                i = self.constraint_end(tokens, i + 1)
+            elif word == "CONSTRAINT":
+                i += 2
+            else:
+                i += 1
+
+    def constraint_end(self, tokens: List[Token], i: int) -> int:
+        """The index of the next column constraint keyword at depth 0 from i."""
+        depth = 0
+        while i < len(tokens):
+            token = tokens[i]
+            if token.kind == "punct" and token.value == "(":
+                depth += 1
+            elif token.kind == "punct" and token.value == ")":
+                depth -= 1
+            elif depth == 0 and token.upper in COLUMN_CONSTRAINTS and token.upper not in ("AS", "KEY"):
+                break
+            i += 1
+        return i
+
+    def references(self, tokens: List[Token], i: int) -> Tuple[Dict[str, Any], int]:
+        """{"table", "columns"} of a REFERENCES clause starting at i (after the keyword)."""
+        parts = []
+        while i < len(tokens) and tokens[i].kind in ("word", "ident"):
+            parts.append(tokens[i].value)
+            i += 1
+            if i < len(tokens) and tokens[i].value == "." and tokens[i].kind == "punct":
+                i += 1
+                continue
+            break
+        if not parts:
+            raise ParseFailure("REFERENCES without a table")
+        reference: Dict[str, Any] = {"table": parts[-1]}
+        if i < len(tokens) and tokens[i].value == "(":
+            columns = []
+            i += 1
+            while i < len(tokens) and tokens[i].value != ")":
+                if tokens[i].kind in ("word", "ident"):
+                    columns.append(tokens[i].value)
+                i += 1
+            i += 1
+            reference["columns"] = columns
+        return reference, i
+
+    def key_columns(self, tokens: List[Token], i: int) -> Tuple[List[str], int]:
+        """The columns of a `(a, b DESC, lower(c))` list at i: names, or expression texts."""
+        while i < len(tokens) and tokens[i].value != "(":
+            i += 1
+        if i >= len(tokens):
+            raise ParseFailure("expected a column list")
+        depth, start, names = 0, i + 1, []
+        j = i
+        while j < len(tokens):
+            token = tokens[j]
+            if token.kind == "punct" and token.value == "(":
+                depth += 1
+            elif token.kind == "punct" and token.value in (")", ",") and depth == 1:
+                part = tokens[start:j]
+                if part:
+                    # A name, maybe with a MySQL prefix length (name(10)); else an expression (lower(email))
+                    expression = part[0].kind not in ("word", "ident") or \
+                        (len(part) > 1 and part[1].value == "(" and not (len(part) > 2 and part[2].kind == "number"))
+                    names.append(self.text(part[0], part[-1]) if expression else part[0].value)
+                start = j + 1
+                if token.value == ")":
+                    return names, j + 1
+            if token.kind == "punct" and token.value == ")":
+                depth -= 1
+            j += 1
+        raise ParseFailure("unclosed column list")
+
+    def table_constraint(self, item: List[Token], table: str, keys: List[Dict[str, Any]],
+                         indexes: List[Dict[str, Any]]):
+        """A table-level key, index or check of a CREATE TABLE or ALTER TABLE ADD."""
+        i = 2 if item[0].upper == "CONSTRAINT" else 0
+        word = item[i].upper if i < len(item) else ""
+        if word == "PRIMARY":
+            columns, _ = self.key_columns(item, i)
+            keys.append({"kind": "primary_key", "columns": columns})
+        elif word == "FOREIGN":
+            columns, j = self.key_columns(item, i)
+            while j < len(item) and item[j].upper != "REFERENCES":
+                j += 1
+            reference, _ = self.references(item, j + 1)
+            keys.append({"kind": "foreign_key", "columns": columns, "references": reference})
+        elif word in ("UNIQUE", "INDEX", "KEY", "FULLTEXT", "SPATIAL"):
+            j = i + 1
+            while j < len(item) and item[j].upper in ("INDEX", "KEY"):
+                j += 1
+            name_token = item[1] if i else None
+            if name_token is None and j < len(item) and item[j].kind in ("word", "ident") and item[j].upper != "USING":
+                name_token = item[j]
+            columns, _ = self.key_columns(item, j)
+            name = name_token.value if name_token else f"{table}({', '.join(columns)})"
+            unique = word == "UNIQUE" or None
+            if unique:
+                keys.append({"kind": "unique", "columns": columns})
+            indexes.append({"name": name, "columns": columns, **({"unique": True} if unique else {})})
+            self.symbol(name, "index", name_token or item[0], item[-1], self.text(item[0], item[-1]), table,
+                        columns=columns, unique=unique)
+
+    @staticmethod
+    def apply_key(key: Dict[str, Any], columns: List[Dict[str, Any]]):
+        by_name = {c["name"].lower(): c for c in columns}
+        for name in key["columns"]:
+            column = by_name.get(name.lower())
+            if column is None:
+                continue
+            if key["kind"] == "primary_key":
+                column["primary_key"] = True
+                column["nullable"] = False
+            elif key["kind"] == "foreign_key" and len(key["columns"]) == 1:
+                column["references"] = key["references"]
+            elif key["kind"] == "unique" and len(key["columns"]) == 1:
+                column["unique"] = True
+
+    def create_index(self, first: Token):
+        unique = self.accept("UNIQUE") or None
+        if not self.accept("FULLTEXT"):
+            self.accept("SPATIAL")
+        self.expect("INDEX")
+        self.accept("CONCURRENTLY")
+        self.accept("IF", "NOT", "EXISTS")
+        name = name_token = None
+        if not self.at("ON"):
+            name_token = self.peek()
+            name = self.name()[1]
+        if self.accept("USING"):
+            self.pos += 1
+        self.expect("ON")
+        self.accept("ONLY")
+        table_token = self.peek()
+        _, table, _ = self.name()
+        columns, _ = self.key_columns(self.tokens, self.pos)
+        display = name or f"{table}({', '.join(columns)})"
+        self.symbol(display, "index", name_token or table_token, self.tokens[-1],
+                    self.text(first, self.tokens[-1]), table, columns=columns, unique=unique)
+        self.statement("create_index", first, name=display, table=table, columns=columns, unique=unique)
+
+    def alter_table(self, first: Token):
+        self.accept("IF", "EXISTS")
+        self.accept("ONLY")
+        _, table, _ = self.name()
+        actions = []
+        for item in self.split_items(self.tokens[self.pos:]):
+            action = self.alter_action(item, table)
+            if action is not None:
+                actions.append(action)
+        self.statement("alter_table", first, table=table, actions=actions)
+
+    def alter_action(self, item: List[Token], table: str) -> Optional[Dict[str, Any]]:
+        word = item[0].upper
+        rest = item[1:]
+        skip = lambda tokens, *words: tokens[1:] if tokens and tokens[0].upper in words else tokens
+        if word == "ADD":
+            rest = skip(rest, "COLUMN")
+            if len(rest) > 3 and rest[0].upper == "IF" and rest[1].upper == "NOT" and rest[2].upper == "EXISTS":
+                rest = rest[3:]
+            if not rest:
+                raise ParseFailure("ADD without a definition")
+            if item[1].upper != "COLUMN" and _is_constraint(rest):
+                keys: List[Dict[str, Any]] = []
+                indexes: List[Dict[str, Any]] = []
+                self.table_constraint(rest, table, keys, indexes)
+                if indexes:
+                    return {"action": "add_index", **indexes[0]}
+                return {"action": "add_key", **keys[0]} if keys else None
+            if rest[0].value == "(":
+                # MySQL: ADD (a INT, b INT)
+                inner = rest[1:-1] if rest[-1].value == ")" else rest[1:]
+                return {"action": "add_columns",
+                        "columns": [self.column(part, table) for part in self.split_items(inner)]}
+            return {"action": "add_column", "column": self.column(rest, table)}
+        if word == "DROP":
+            if rest and rest[0].upper in ("CONSTRAINT", "PRIMARY", "FOREIGN", "CHECK"):
+                return {"action": "drop_constraint"}
+            if rest and rest[0].upper in ("INDEX", "KEY"):
+                return {"action": "drop_index", "name": rest[1].value}
+            rest = skip(rest, "COLUMN")
+            if len(rest) > 2 and rest[0].upper == "IF" and rest[1].upper == "EXISTS":
+                rest = rest[2:]
+            if not rest or rest[0].kind not in ("word", "ident"):
+                raise ParseFailure("DROP without a column")
+            return {"action": "drop_column", "column": rest[0].value}
+        if word == "RENAME":
+            if rest and rest[0].upper in ("TO", "AS"):
+                return {"action": "rename_table", "to": rest[-1].value}
+            if rest and rest[0].upper in ("INDEX", "KEY"):
+                return {"action": "rename_index", "from": rest[1].value, "to": rest[-1].value}
+            rest = skip(rest, "COLUMN")
+            if len(rest) >= 3 and rest[1].upper == "TO":
+                return {"action": "rename_column", "from": rest[0].value, "to": rest[2].value}
+            raise ParseFailure("RENAME not understood")
+        if word in ("MODIFY", "CHANGE"):
+            rest = skip(rest, "COLUMN")
+            if word == "CHANGE":
+                if len(rest) < 3:
+                    raise ParseFailure("CHANGE without a new definition")
+                old = rest[0].value
+                column = self.column(rest[1:], table)
+                return {"action": "change_column", "from": old, "column": column}
+            return {"action": "modify_column", "column": self.column(rest, table)}
+        if word == "ALTER":
+            rest = skip(rest, "COLUMN")
+            if not rest:
+                raise ParseFailure("ALTER without a column")
+            name = rest[0].value
+            change = rest[1:]
+            action: Dict[str, Any] = {"action": "alter_column", "column": name}
+            words = [t.upper for t in change]
+            if words[:1] == ["TYPE"] or words[:3] == ["SET", "DATA", "TYPE"]:
+                start = 1 if words[:1] == ["TYPE"] else 3
+                end = next((k for k in range(start, len(change)) if change[k].upper in ("USING", "COLLATE")),
+                           len(change))
+                if end > start:
+                    action["column_type"] = self.text(change[start], change[end - 1])
+            elif words[:3] == ["SET", "NOT", "NULL"]:
+                action["nullable"] = False
+            elif words[:3] == ["DROP", "NOT", "NULL"]:
+                action["nullable"] = True
+            elif words[:2] == ["SET", "DEFAULT"] and len(change) > 2:
+                action["default"] = self.text(change[2], change[-1])
+            elif words[:2] == ["DROP", "DEFAULT"]:
+                action["default"] = None
+            return action
+        return None
+
+
+
+def _is_constraint(item: List[Token]) -> bool:
+    """
+    Whether a CREATE TABLE item (or what ALTER TABLE ADD adds) is a key,
+    index or check rather than a column: `KEY idx (a)` is one, a column
+    named key (`key VARCHAR(10)`) is not.
+    """
+    word = item[0].upper
+    if word not in TABLE_CONSTRAINTS or len(item) < 2:
+        return False
+    following = item[1]
+    if word == "CONSTRAINT" or following.value == "(":
+        return True
+    if word in ("PRIMARY", "FOREIGN"):
+        return following.upper == "KEY"
+    if word in ("UNIQUE", "FULLTEXT", "SPATIAL") and following.upper in ("KEY", "INDEX"):
+        return True
+    if word == "LIKE":
+        return True
+    if word == "EXCLUDE":
+        return following.upper == "USING"
+    if word == "PERIOD":
+        return following.upper == "FOR"
+    # INDEX name (a, b): a column list, where a type would take a number (VARCHAR(10))
+    return len(item) > 3 and item[2].value == "(" and item[3].kind in ("word", "ident")
+
+
+def parse_sql_source(content: str) -> Dict[str, Any]:
+    """
+    Parse the schema statements of a .sql file.
+
+    Returns:
+        {"symbols", "statements"} plus "parseErrors" for schema statements
+        that could not be read. Symbols are "table", "column" and "index"
+        records, columns and indexes naming their table in "container";
+        statements are the file's schema changes in order - "create_table",
+        "alter_table" (with its "actions"), "create_index", "drop_table",
+        "drop_index" and "rename_table" - for the migration fold.
+    """
+    return SqlFileParser(content).parse()
@@ -0,0 +1,182 @@
+"""Stable symbol IDs - a declaration's identity, independent of where it sits in its file.
+
+A file and line stop naming a symbol as soon as an edit above it shifts
+lines. A symbol ID is built from what the declaration is instead:
+
+    go:example.com/app/store:UserService.GetUser:3fa2c1d0
+    typescript:web/src/lib/store.ts:UserStore.add:9b0e4417
+
+- the language;
+- the scope: a Go package's import path (module path and directory, or the
+  directory relative to the project root outside every module), the file
+  relative to the project root for the other languages;
+- the qualified name: "Type.Method", "Class.member", "Parent.name" for a
+  nested declaration, "Type.Field" for a struct field;
+- a hash of its kind and signature (whitespace collapsed).
+
+So an ID survives re-indexing, edits elsewhere in the file and, for Go,
+moving the declaration to another file of its package; it changes when
+the declaration's name, receiver or signature does. Every symbol a tool
+returns carries its ID under "symbol_id", and every tool taking a symbol
+accepts one instead of a name. An ID no declaration has any more is
+answered with the closest declaration there is (SymbolChanged): the same
+name in the same scope with another signature, else the same name
+elsewhere.
+
+A name, unlike an ID, may stand for several declarations - a New() in
+every package, GetUser on two receivers. It can be qualified by the
+package: the import path or directory ("internal/api.Config",
+"example.com/app/internal/api.Server.Start"), its last element when no type
+of that name declares the rest ("api.Config"), or a file without its
+extension outside Go ("web/src/config.Cfg"); and a Go method may be written
+as a method expression, "(*UserService).GetUser". A name still matching
+declarations in more than one scope, or on more than one receiver, is not
+resolved to any of them: the tool fails with AmbiguousSymbol and lists the
+candidates to retry with.
+"""
+
+import difflib
+import hashlib
+import os
+import posixpath
+import re
+from typing import Any, Callable, Dict, List, NamedTuple, Optional, Tuple
+
+from xray.core.languages import NATIVE_LANGUAGES
+
+# The languages the index parses, so every ID it hands out parses back
+_ID = re.compile(rf"^({'|'.join(sorted(NATIVE_LANGUAGES))}):([^:\s]+):(\S.*):([0-9a-f]{{8}})$")
+_SPACE = re.compile(r"\s+")
+# pkg.(*T).M, (*T).M, (T).M
+_METHOD_EXPRESSION = re.compile(r"^(?:(.+)\.)?\(\*?([^()]+)\)\.([^()]+)$")
+_PATH_KEYS = ("path", "file")
+# Line fields naming where a declaration starts, most specific first
+_LINE_KEYS = ("declaration_line", "start_line", "line")
+
+
+class SymbolId(NamedTuple):
+    language: str
+    scope: str
+    name: str
+    hash: str
+
+
+def signature_hash(kind: str, signature: Optional[str]) -> str:
+    """The part of an ID that changes with a declaration's kind or signature."""
+    text = f"{kind}\0{_SPACE.sub(' ', signature or '').strip()}"
+    return hashlib.sha1(text.encode("utf-8")).hexdigest()[:8]
+
+
+def symbol_id(language: str, scope: str, name: str, kind: str, signature: Optional[str]) -> str:
+    """The ID of a declaration (see the module docstring)."""
+    return f"{language}:{scope}:{name}:{signature_hash(kind, signature)}"
+
+
+def parse_symbol_id(value: Any) -> Optional[SymbolId]:
+    """The parts of a symbol ID; None for anything else, such as a plain name."""
+    match = _ID.match(value) if isinstance(value, str) else None
+    return SymbolId(*match.groups()) if match else None
+
+
+def split_qualifier(symbol: str) -> Tuple[Optional[str], str]:
+    """
+    The package qualifier of a symbol name (None without one) and the name
+    it qualifies, a Go method expression turned into "Type.Method". A
+    qualifier only the declarations can tell from a type ("api.Config") is
+    left in the name.
+    """
+    match = _METHOD_EXPRESSION.match(symbol)
+    if match:
+        return match.group(1), f"{match.group(2)}.{match.group(3)}"
+    slash = symbol.rfind("/")
+    if slash < 0:
+        return None, symbol
+    dot = symbol.find(".", slash)
+    if dot < 0:
+        return None, symbol
+    return symbol[:dot], symbol[dot + 1:]
+
+
+def in_package(package: str, scope: str, relpath: str) -> bool:
+    """
+    Whether a declaration - its ID scope and its file relative to the root -
+    is in the package a qualifier names, by import path, directory or file.
+    """
+    package = package.strip("/")
+    return any(place == package or place.endswith("/" + package)
+               for place in (scope, posixpath.dirname(relpath), posixpath.splitext(relpath)[0]))
+
+
+def closest_match(wanted: SymbolId, candidates: List[Dict[str, Any]]) -> Optional[Dict[str, Any]]:
+    """
+    The declaration an ID most likely stands for now, among candidates
+    ({"id", "qualified", "scope", ...}): the same name in the same scope,
+    the same name anywhere, the same last name part (another receiver or
+    parent), then the most similar name in the same scope.
+    """
+    same_scope = [c for c in candidates if c["scope"] == wanted.scope]
+    bare = wanted.name.rsplit(".", 1)[-1]
+    for pool, same in ((same_scope, lambda c: c["qualified"] == wanted.name),
+                       (candidates, lambda c: c["qualified"] == wanted.name),
+                       (same_scope, lambda c: c["name"] == bare),
+                       (candidates, lambda c: c["name"] == bare)):
+        named = [c for c in pool if same(c)]
+        if named:
+            return named[0]
+    similar = difflib.get_close_matches(wanted.name, [c["qualified"] for c in same_scope], n=1, cutoff=0.6)
+    return next((c for c in same_scope if c["qualified"] == similar[0]), None) if similar else None
+
+
+def add_symbol_ids(result: Any, root: str, declarations: Callable[[str], List[Dict[str, Any]]]) -> Any:
+    """
+    Add, in place, a "symbol_id" to every symbol in a result: each dict with
+    a name, a declaration line (declaration_line, start_line, or a line
+    next to a kind, type or signature) and a path of its own or of the
+    nearest enclosing dict, matching a declaration of that file.
+    declarations(path) lists a file's declarations as {"id", "name",
+    "qualified", "line", "column", "range"}; the symbol's name may be bare
+    or qualified. A dict that is the declaration itself - without a column,
+    or at the declared name's - also takes its range, as its file was
+    indexed, so each tool gives it the one range; one elsewhere on the line
+    (a literal, a call in the declaration) keeps its own.
+    """
+    files: Dict[str, Dict[int, List[Dict[str, Any]]]] = {}
+
+    def declared(path: str) -> Dict[int, List[Dict[str, Any]]]:
+        if path not in files:
+            by_line: Dict[int, List[Dict[str, Any]]] = {}
+            for decl in declarations(path):
+                by_line.setdefault(decl["line"], []).append(decl)
+            files[path] = by_line
+        return files[path]
+
+    def walk(value: Any, path: Optional[str]):
+        if isinstance(value, list):
+            for item in value:
+                walk(item, path)
+            return
+        if not isinstance(value, dict):
+            return
+        own = next((value[k] for k in _PATH_KEYS if isinstance(value.get(k), str)), None)
+        if own is not None:
+            path = own if os.path.isabs(own) else os.path.join(root, own)
+        name = value.get("name")
+        line_key = next((k for k in _LINE_KEYS if isinstance(value.get(k), int)), None)
+        if line_key == "line" and not any(k in value for k in ("kind", "type", "signature")):
+            # A bare line is a call site or reference, not a declaration
+            line_key = None
+        if path and isinstance(name, str) and line_key and "symbol_id" not in value:
+            names = {name, value.get("qualified_name")}
+            match = next((d for d in declared(path).get(value[line_key], [])
+                          if d["name"] in names or d["qualified"] in names), None)
+            if match:
+                value["symbol_id"] = match["id"]
+                column = value.get("column")
+                if match.get("range") and (not isinstance(column, int) or column == match.get("column")):
+                    value["range"] = match["range"]
+        for item in value.values():
+            if isinstance(item, (dict, list)):
+                walk(item, path)
+
+    walk(result, None)
+    return result
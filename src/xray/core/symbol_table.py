@@ -0,0 +1,94 @@
+"""CSV and JSON Lines exports of the symbol table and the Go references.
+
+One row per declaration, every language the index parses, nested
+declarations too:
+
+    symbol_id, name, kind, language, package, receiver, signature, file,
+    start_line, end_line, exported, doc, and the metrics of
+    xray.core.go_metrics (complexity, loc, ...) for Go functions with a body
+
+"package" is a Go package's import path, the directory of the file
+otherwise; "receiver" the type a Go method, field or interface method
+belongs to, or the class of a member; "doc" the first sentence of the doc
+comment. One row per call of, or reference to, a Go function or method:
+
+    from_symbol_id, from_symbol, to_symbol_id, to_symbol, kind, external,
+    file, line, column
+
+An external callee ("net/http.Get") has no symbol ID. Rows are written to
+the file as they are produced, never gathered first, so a table of any
+size costs no more memory than the index it comes from. In CSV, values
+missing from a row are empty cells and flags are "true" or "false"; a
+JSON line has every column, null where it is missing.
+"""
+
+import csv
+import json
+from typing import Any, Dict, Iterable, TextIO
+
+from xray.core.go_metrics import METRICS
+from xray.core.report import summary_line
+
+EXPORT_FORMATS = ("csv", "jsonl")
+
+SYMBOL_COLUMNS = ("symbol_id", "name", "kind", "language", "package", "receiver", "signature", "file",
+                  "start_line", "end_line", "exported", "doc") + METRICS
+REFERENCE_COLUMNS = ("from_symbol_id", "from_symbol", "to_symbol_id", "to_symbol", "kind", "external",
+                     "file", "line", "column")
+
+
+def symbol_row(declaration: Dict[str, Any], symbol: Dict[str, Any], file: str, package: str) -> Dict[str, Any]:
+    """
+    The row of a declaration (as XRayIndexer._declarations lists it) and
+    the parsed symbol it was made from; file and package as exported.
+    """
+    receiver = (symbol.get("receiver") or {}).get("type") or symbol.get("container")
+    exported = symbol.get("exported")
+    if exported is None:
+        exported = symbol["name"][:1].isupper()
+    row = {
+        "symbol_id": declaration["id"],
+        "name": symbol["name"],
+        "kind": symbol["type"],
+        "language": declaration["language"],
+        "package": package,
+        "receiver": receiver,
+        "signature": symbol.get("signature"),
+        "file": file,
+        "start_line": symbol["start_line"],
+        "end_line": symbol.get("end_line"),
+        "exported": bool(exported),
+        "doc": summary_line(symbol["doc"]) if symbol.get("doc") else None,
+    }
+    for metric in METRICS:
+        row[metric] = symbol.get(metric)
+    return row
+
+
+def _cell(value: Any) -> Any:
+    if value is None:
+        return ""
+    if isinstance(value, bool):
+        return "true" if value else "false"
+    return value
+
+
+def write_table(out: TextIO, columns: Iterable[str], rows: Iterable[Dict[str, Any]], format: str = "csv") -> int:
+    """
+    Write rows to an open text file, one at a time, as CSV with a header
+    line or as JSON Lines. Returns how many rows were written.
+    """
+    if format not in EXPORT_FORMATS:
+        raise ValueError(f"format must be one of {', '.join(EXPORT_FORMATS)}")
+    columns = list(columns)
+    writer = csv.writer(out, lineterminator="\n") if format == "csv" else None
+    if writer is not None:
+        writer.writerow(columns)
+    count = 0
+    for row in rows:
+        if writer is not None:
+            writer.writerow([_cell(row.get(column)) for column in columns])
+        else:
+            out.write(json.dumps({column: row.get(column) for column in columns}, ensure_ascii=False) + "\n")
+        count += 1
+    return count
@@ -0,0 +1,116 @@
+"""Universal Ctags-compatible tags files from the Go symbol index.
+
+Output follows the extended tags format (see tags(5) of Universal Ctags):
+
+    {name}<TAB>{file}<TAB>/^{line}$/;"<TAB>{kind}<TAB>line:{n}<TAB>...
+
+Addresses are ex search patterns rather than line numbers, so tags stay
+usable after small edits, and file names are taken verbatim - spaces and
+all - since only tabs separate fields. Lines are sorted bytewise, which is
+what vim's binary search expects of a file declaring _TAG_FILE_SORTED 1.
+"""
+
+import linecache
+import os
+import re
+from typing import Any, Callable, Dict, List, Optional, Tuple
+
+from xray import __version__
+from xray.core.go_analysis import GoProject
+
+# Go kind letters and names as Universal Ctags spells them
+KINDS = {
+    "function": ("f", "func", "functions"),
+    "method": ("f", "func", "functions"),
+    "type": ("t", "type", "types"),
+    "struct": ("s", "struct", "structs"),
+    "interface": ("i", "interface", "interfaces"),
+    "field": ("m", "member", "struct members"),
+    "interface_method": ("n", "methodSpec", "interface method specification"),
+    "constant": ("c", "const", "constants"),
+    "variable": ("v", "var", "variables"),
+}
+
+
+def _pattern(text: str) -> str:
+    """An ex search address matching the whole line."""
+    text = text.rstrip("\r\n").replace("\\", "\\\\").replace("/", "\\/")
+    return f"/^{text}$/"
+
+
+def _signature(symbol: Dict[str, Any]) -> Optional[str]:
+    """The parameter list onwards ("(id int) string"), for funcs and methods."""
+    signature = symbol.get("signature") or ""
+    name = symbol["name"].rsplit(".", 1)[-1]
+    if signature.startswith("func ("):
+        # Skip the receiver, which may mention the method's name
+        signature = signature[signature.find(")") + 1:]
+    match = re.search(rf"\b{re.escape(name)}(?=[(\[])", signature)
+    return signature[match.end():] if match else None
+
+
+def _entries(project: GoProject, path: str, tag_file: str) -> List[Tuple[str, str, str, str, List[str]]]:
+    parsed = project.files[path]
+    package = parsed.get("package", "")
+    pkg_dir = os.path.dirname(path)
+    entries = []
+    for symbol in parsed["symbols"]:
+        kind = symbol["type"]
+        fields = [f"line:{symbol['start_line']}"]
+        if kind == "method" and symbol.get("container"):
+            kind = "interface_method"
+            fields.append(f"interface:{symbol['container']}")
+        elif kind == "method" and symbol.get("receiver"):
+            receiver = symbol["receiver"]
+            owner = project.types.get((pkg_dir, receiver["type"]))
+            scope = owner["type"] if owner and owner["type"] in ("struct", "interface") else "type"
+            fields.append(f"{scope}:{receiver['type']}")
+            fields.append(f"receiver:{'*' if receiver.get('pointer') else ''}{receiver['type']}")
+        elif kind == "field" and symbol.get("container"):
+            fields.append(f"struct:{symbol['container']}")
+        if kind not in KINDS:
+            continue
+        fields.append(f"package:{package}")
+        if kind in ("function", "method", "interface_method"):
+            signature = _signature(symbol)
+            if signature:
+                fields.append(f"signature:{signature.replace(chr(9), ' ')}")
+        line = linecache.getline(path, symbol["start_line"])
+        address = _pattern(line) if line else str(symbol["start_line"])
+        entries.append((symbol["name"], tag_file, address, KINDS[kind][0], fields))
+    return entries
+
+
+def build_tags(project: GoProject, tags_dir: str,
+               source_path: Optional[Callable[[str], str]] = None) -> Tuple[List[str], int]:
+    """
+    Return the lines of a tags file for every indexed Go file, with paths
+    relative to tags_dir, and the number of files covered. source_path maps
+    an analyzed path (in a ref snapshot, say) to the one the tags point at.
+    """
+    lines = []
+    for path in sorted(project.files):
+        shown = source_path(path) if source_path else path
+        tag_file = os.path.relpath(shown, tags_dir).replace(os.sep, "/")
+        for name, file_name, address, kind, fields in _entries(project, path, tag_file):
+            lines.append("\t".join([name, file_name, f'{address};"', kind, *fields]))
+    linecache.clearcache()
+    # Bytewise order, as `LC_ALL=C sort` would produce
+    lines.sort(key=lambda line: line.encode("utf-8"))
+
+    header = [
+        "!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;\" to lines/",
+        "!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/",
+        "!_TAG_FILE_ENCODING\tutf-8\t//",
+        "!_TAG_PROGRAM_AUTHOR\tXRAY\t//",
+        "!_TAG_PROGRAM_NAME\txray\t//",
+        "!_TAG_PROGRAM_URL\thttps://github.com/Jamie-BitFlight/git-project-xray-mcp\t//",
+        f"!_TAG_PROGRAM_VERSION\t{__version__}\t//",
+    ]
+    seen = set()
+    for letter, name, description in KINDS.values():
+        if letter not in seen:
+            seen.add(letter)
+            header.append(f"!_TAG_KIND_DESCRIPTION!Go\t{letter},{name}\t/{description}/")
+    header.sort(key=lambda line: line.encode("utf-8"))
+    return header + lines, len(project.files)
@@ -0,0 +1,450 @@
+"""The build entry points of a project: Makefile targets and shell scripts, and what they run.
+
+A Makefile's targets see the targets and variables of the files it
+includes; a recipe's `$(VAR)` references are expanded from them (and
+`$(MAKE)`, `$(CURDIR)`) before its commands are read. Every command is
+split into simple commands, and each names one of:
+
+    go build ./cmd/api      "go": the packages it builds, tests or runs,
+                            resolved to the project's Go packages (which of
+                            them are main packages) or left as patterns
+    $(MAKE) -C docs html    "target": a target of this or another Makefile
+    ./scripts/release.sh    "script": one of the project's shell scripts
+    bash scripts/ci.sh      (also through sh, source and .)
+    notify "done"           "function": a function of the script, or of a
+                            script it sources
+
+Anything else is listed by program name under "tools" (shell builtins and
+coreutils left out). Recipe lines run in their Makefile's directory, each
+in its own shell; a script's commands run from the project root, with `cd`
+followed while its argument is a plain path.
+"""
+
+import os
+import re
+import shlex
+from typing import Any, Dict, List, Optional, Set, Tuple
+
+from xray.core.go_analysis import GoProject
+from xray.core.task_parser import is_makefile
+
+# Programs run through the files they are given
+SHELLS = {"bash", "sh", "zsh", "dash", "ksh", "source", "."}
+# Words before the program of a command
+COMMAND_PREFIXES = {"env", "sudo", "exec", "time", "command", "nohup", "nice", "xargs"}
+# Shell words that introduce the command after them
+SHELL_KEYWORDS = {"if", "then", "else", "elif", "do", "while", "until", "!", "{", "[[", "time"}
+# Not worth listing as tools
+IGNORED_PROGRAMS = {
+    "echo", "printf", "cd", "pushd", "popd", "set", "unset", "export", "local", "readonly", "declare", "typeset",
+    "shift", "exit", "return", "true", "false", "test", "[", "read", "eval", "trap", "wait", "sleep", "cat", "cp",
+    "mv", "rm", "mkdir", "rmdir", "ln", "ls", "touch", "chmod", "chown", "grep", "egrep", "sed", "awk", "cut", "tr",
+    "sort", "uniq", "head", "tail", "wc", "find", "tee", "basename", "dirname", "pwd", "date", "which", "type",
+    "hash", ":", "let", "fi", "done", "esac", "case", "for", "in", "}", "]]", "break", "continue", "shopt", "usage",
+}
+# go subcommands taking packages, and their flags that take a separate value
+GO_PACKAGE_COMMANDS = {"build", "install", "run", "test", "vet", "generate", "list", "fmt"}
+GO_VALUE_FLAGS = {
+    "-o", "-ldflags", "-gcflags", "-asmflags", "-tags", "-run", "-bench", "-count", "-timeout", "-p", "-coverprofile",
+    "-covermode", "-coverpkg", "-exec", "-mod", "-modfile", "-pkgdir", "-toolexec", "-parallel", "-cpu",
+    "-benchtime", "-memprofile", "-cpuprofile", "-outputdir", "-C", "-skip", "-fuzz", "-fuzztime", "-overlay",
+    "-pgo", "-buildmode", "-compiler", "-installsuffix", "-shuffle", "-vet", "-f",
+}
+MAKE_VALUE_FLAGS = {"-C", "-f", "-j", "-l", "-o", "-W", "-I", "--directory", "--file", "--makefile"}
+
+_MAKE_REFERENCE = re.compile(r"\$[({]([A-Za-z0-9_.\-]+)[)}]")
+_ASSIGNMENT_WORD = re.compile(r"^[A-Za-z_][A-Za-z0-9_]*=")
+_SEPARATORS = {";", "&&", "||", "|", "&", "(", ")", "|&", ";;", "{", "}"}
+
+
+def simple_commands(text: str) -> List[List[str]]:
+    """The words of each simple command of a command line (split at ;, &&, ||, | and parentheses)."""
+    lexer = shlex.shlex(text, posix=True, punctuation_chars=True)
+    lexer.whitespace_split = True
+    lexer.commenters = "#"
+    try:
+        tokens = list(lexer)
+    except ValueError:
+        tokens = text.split()
+    commands: List[List[str]] = [[]]
+    redirect = False
+    for token in tokens:
+        if redirect:
+            redirect = False
+            continue
+        if token in _SEPARATORS or (token and all(c in ";&|()" for c in token)):
+            commands.append([])
+        elif token and all(c in "<>&" for c in token) or re.match(r"^\d+>", token):
+            # A redirection takes the next word, unless it came with it (2>&1)
+            redirect = not token.endswith("&1") and not token.endswith("&2")
+        else:
+            commands[-1].append(token)
+    return [c for c in commands if c]
+
+
+def go_command(words: List[str], line: int, directory: str, go: GoProject, root: str) -> Dict[str, Any]:
+    """What a `go ...` command runs: its subcommand, -o output and package arguments resolved by go_package."""
+    subcommand = words[1]
+    run: Dict[str, Any] = {"kind": "go", "command": f"go {subcommand}", "line": line}
+    if subcommand not in GO_PACKAGE_COMMANDS:
+        return run
+    arguments = []
+    i = 2
+    while i < len(words):
+        word = words[i]
+        if word == "-args" or word == "--":
+            break
+        if word in GO_VALUE_FLAGS and i + 1 < len(words):
+            if word == "-o":
+                run["output"] = words[i + 1]
+            elif word == "-C":
+                directory = os.path.normpath(os.path.join(directory, words[i + 1]))
+            i += 2
+            continue
+        if not word.startswith("-"):
+            arguments.append(word)
+            if subcommand == "run":
+                # The rest are the program's arguments
+                break
+        i += 1
+    if not arguments and subcommand != "generate":
+        arguments = ["."]
+    run["packages"] = [go_package(argument, directory, go, root) for argument in arguments]
+    return run
+
+
+def go_package(argument: str, directory: str, go: GoProject, root: str) -> Dict[str, Any]:
+    """A go command's package argument, run in directory, resolved to a package of the project where it is one."""
+    version = None
+    if "@" in argument:
+        argument, version = argument.split("@", 1)
+    if argument.endswith("/...") or argument == "...":
+        base = argument[:-4] if argument.endswith("/...") else "."
+        entry: Dict[str, Any] = {"pattern": argument}
+        if base.startswith(".") or base == "":
+            entry["dir"] = os.path.relpath(os.path.normpath(os.path.join(directory, base or ".")), root)
+        return entry
+    if argument.endswith(".go"):
+        argument = os.path.dirname(argument) or "."
+    if argument.startswith(".") or os.path.isabs(argument):
+        pkg_dir = os.path.normpath(os.path.join(directory, argument))
+    else:
+        pkg_dir = go.import_dir(argument)
+    if pkg_dir is None or pkg_dir not in go.packages:
+        entry = {"import_path": argument, "resolved": False}
+        if version:
+            entry["version"] = version
+        return entry
+    package = go.packages[pkg_dir]
+    entry = {"dir": os.path.relpath(pkg_dir, root), "package": package["name"]}
+    import_path = go.import_path(pkg_dir)
+    if import_path:
+        entry["import_path"] = import_path
+    if package["name"] == "main":
+        entry["main"] = True
+    entry["path"] = package["files"][0]
+    return entry
+
+
+class TaskProject:
+    """The Makefiles and shell scripts of a project, linked to each other and to its Go packages."""
+
+    def __init__(self, files: Dict[str, Dict[str, Any]], root: str):
+        self.files = files
+        self.root = root
+        self.makefiles = sorted(p for p in files if is_makefile(p))
+        self.scripts = sorted(p for p in files if not is_makefile(p))
+        # Makefile -> the files whose targets and variables it sees: itself, then its includes
+        self._namespaces: Dict[str, List[str]] = {p: self._included(p, [p]) for p in self.makefiles}
+        included = {p for files in self._namespaces.values() for p in files[1:]}
+        self._roots = [p for p in self.makefiles if p not in included]
+
+    def symbols(self):
+        """(path, symbol) for every target, variable and shell function, files in sorted order."""
+        for path in sorted(self.files):
+            for symbol in self.files[path]["symbols"]:
+                yield path, symbol
+
+    def dotted_name(self, path: str) -> str:
+        """The file a target or function is declared in."""
+        return os.path.basename(path)
+
+    def _included(self, path: str, seen: List[str]) -> List[str]:
+        directory = os.path.dirname(path)
+        variables = self._own_variables(path)
+        for include in self.files[path].get("includes", []):
+            for name in self._expand(include["path"], variables, directory).split():
+                target = os.path.normpath(os.path.join(directory, name))
+                if target in self.files and target not in seen:
+                    seen.append(target)
+                    self._included(target, seen)
+        return seen
+
+    def _own_variables(self, path: str) -> Dict[str, str]:
+        return {s["name"]: s["value"] for s in self.files[path]["symbols"] if s["type"] == "variable"}
+
+    def _variables(self, makefile: str) -> Dict[str, str]:
+        variables: Dict[str, str] = {}
+        for path in self._namespaces.get(makefile, [makefile]):
+            for name, value in self._own_variables(path).items():
+                variables.setdefault(name, value)
+        return variables
+
+    def _targets(self, makefile: str) -> Dict[str, Tuple[str, Dict[str, Any]]]:
+        """Target name -> (path, symbol), across a Makefile and its includes."""
+        targets: Dict[str, Tuple[str, Dict[str, Any]]] = {}
+        for path in self._namespaces.get(makefile, [makefile]):
+            for symbol in self.files[path]["symbols"]:
+                if symbol["type"] == "target":
+                    targets.setdefault(symbol["name"], (path, symbol))
+        return targets
+
+    def _owner(self, path: str) -> str:
+        """The Makefile whose namespace a Makefile's targets are looked up in: the one including it, if any."""
+        for root in self._roots:
+            if path in self._namespaces[root]:
+                return root
+        return path
+
+    @staticmethod
+    def _expand(text: str, variables: Dict[str, str], directory: str, depth: int = 0) -> str:
+        """A Makefile text with its $(VAR) references to known variables (and $(MAKE), $(CURDIR)) expanded."""
+        def replace(match):
+            name = match.group(1)
+            if name == "MAKE":
+                return "make"
+            if name == "CURDIR":
+                return directory
+            if name in variables and depth < 8:
+                return TaskProject._expand(variables[name], variables, directory, depth + 1)
+            return "$" + name
+        return _MAKE_REFERENCE.sub(replace, text).replace("$$", "$")
+
+    # Tasks
+
+    def tasks(self, go: GoProject, scope: Optional[str] = None, task: Optional[str] = None) -> List[Dict[str, Any]]:
+        """
+        Every target, script and shell function with what it runs; with
+        task, the ones named so (a target, function, or script file name
+        or path) and everything they run, directly or not.
+        """
+        entries: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        for path in self.makefiles:
+            owner = self._owner(path)
+            variables = self._variables(owner)
+            targets = self._targets(owner)
+            directory = os.path.dirname(owner)
+            for symbol in self.files[path]["symbols"]:
+                if symbol["type"] != "target":
+                    continue
+                entry = {"name": symbol["name"], "kind": "make_target", "path": path, "line": symbol["start_line"],
+                         "end_line": symbol["end_line"]}
+                for key in ("doc", "phony", "default", "pattern"):
+                    # The first target of an included file is not the including Makefile's default
+                    if symbol.get(key) and not (key == "default" and owner != path):
+                        entry[key] = symbol[key]
+                entry["prerequisites"] = symbol["prerequisites"]
+                entry["commands"] = symbol["commands"]
+                runs: List[Dict[str, Any]] = []
+                tools: Set[str] = set()
+                for command in symbol["commands"]:
+                    text = self._expand(command["text"], variables, directory)
+                    # Each recipe line runs in a shell of its own, from the Makefile's directory
+                    self._read(text, command["line"], path, [directory], go, runs, tools, targets=targets)
+                entry["runs"] = runs
+                entry["tools"] = sorted(tools)
+                entry["_prerequisites"] = [targets[p] for p in symbol["prerequisites"] if p in targets]
+                entries[("make_target", path, symbol["name"])] = entry
+        for path in self.scripts:
+            parsed = self.files[path]
+            functions = self._functions(path)
+            cwd = [self.root]
+            runs, tools = [], set()
+            for command in parsed.get("commands", []):
+                self._read(command["text"], command["line"], path, cwd, go, runs, tools, functions=functions)
+            entry = {"name": os.path.basename(path), "kind": "script", "path": path, "line": 1}
+            if parsed.get("shebang"):
+                entry["shebang"] = parsed["shebang"]
+            own = [s["name"] for s in parsed["symbols"] if s["type"] == "function"]
+            if own:
+                entry["functions"] = own
+            entry["runs"] = runs
+            entry["tools"] = sorted(tools)
+            entries[("script", path, "")] = entry
+            for symbol in parsed["symbols"]:
+                if symbol["type"] != "function":
+                    continue
+                runs, tools = [], set()
+                cwd = [self.root]
+                for command in symbol["commands"]:
+                    self._read(command["text"], command["line"], path, cwd, go, runs, tools, functions=functions)
+                entries[("shell_function", path, symbol["name"])] = {
+                    "name": symbol["name"], "kind": "shell_function", "path": path, "line": symbol["start_line"],
+                    "end_line": symbol["end_line"], "commands": symbol["commands"], "runs": runs,
+                    "tools": sorted(tools)}
+        # Edges: prerequisites, and runs naming a target, script or function
+        for key, entry in entries.items():
+            edges = [("make_target", p, s["name"], "prerequisite") for p, s in entry.pop("_prerequisites", [])]
+            for run in entry["runs"]:
+                if run["kind"] == "target" and run.get("path"):
+                    edges.append(("make_target", run["path"], run["name"], "command"))
+                elif run["kind"] == "script" and run.get("path"):
+                    edges.append(("script", run["path"], "", "command"))
+                elif run["kind"] == "function" and run.get("path"):
+                    edges.append(("shell_function", run["path"], run["name"], "command"))
+            entry["_next"] = []
+            for kind, path, name, via in edges:
+                callee = entries.get((kind, path, name))
+                if callee is None or callee is entry:
+                    continue
+                entry["_next"].append(callee)
+                caller = {"name": entry["name"], "kind": entry["kind"], "path": entry["path"], "via": via}
+                if caller not in callee.setdefault("run_by", []):
+                    callee["run_by"].append(caller)
+        ordered = [entries[k] for k in sorted(entries, key=lambda k: (k[1], self._line(entries[k]), k[2]))]
+        if task is not None:
+            ordered = self._closure(ordered, task)
+        elif scope is not None:
+            ordered = [e for e in ordered if e["path"] == scope or e["path"].startswith(scope.rstrip(os.sep) + os.sep)]
+        for entry in entries.values():
+            entry.pop("_next", None)
+        return ordered
+
+    @staticmethod
+    def _line(entry: Dict[str, Any]) -> int:
+        return entry["line"] if entry["kind"] != "script" else 0
+
+    def _closure(self, ordered: List[Dict[str, Any]], task: str) -> List[Dict[str, Any]]:
+        wanted = os.path.normpath(task)
+        start = [e for e in ordered if e["name"] == task or
+                 (e["kind"] == "script" and (os.path.relpath(e["path"], self.root) == wanted or e["path"] == wanted))]
+        result: List[Dict[str, Any]] = []
+        seen: Set[int] = set()
+        queue = list(start)
+        while queue:
+            entry = queue.pop(0)
+            if id(entry) in seen:
+                continue
+            seen.add(id(entry))
+            result.append(entry)
+            queue.extend(entry["_next"])
+        return result
+
+    def _functions(self, path: str) -> Dict[str, Tuple[str, Dict[str, Any]]]:
+        """Function name -> (path, symbol): a script's own, then those of the scripts it sources."""
+        functions: Dict[str, Tuple[str, Dict[str, Any]]] = {}
+        pending, seen = [path], set()
+        while pending:
+            current = pending.pop(0)
+            if current in seen:
+                continue
+            seen.add(current)
+            parsed = self.files[current]
+            for symbol in parsed["symbols"]:
+                if symbol["type"] == "function":
+                    functions.setdefault(symbol["name"], (current, symbol))
+            commands = parsed.get("commands", []) + [c for s in parsed["symbols"] for c in s.get("commands", [])]
+            for command in commands:
+                for words in simple_commands(command["text"]):
+                    if words[0] in ("source", ".") and len(words) > 1:
+                        script = self._script(words[1], self.root, current)
+                        if script:
+                            pending.append(script)
+        return functions
+
+    def _script(self, word: str, cwd: str, current: str) -> Optional[str]:
+        """The project shell script a command word names, tried from cwd, the root and the current file."""
+        for base in (cwd, self.root, os.path.dirname(current)):
+            if "$" in word:
+                break
+            candidate = os.path.normpath(os.path.join(base, word))
+            if candidate in self.files and not is_makefile(candidate):
+                return candidate
+        if "/" in word or word.endswith((".sh", ".bash")):
+            # $(dirname "$0")/lib.sh and the like: a script of that name beside this one, or the only one
+            name = os.path.basename(word)
+            beside = os.path.join(os.path.dirname(current), name)
+            if beside in self.files:
+                return beside
+            matches = [p for p in self.scripts if os.path.basename(p) == name]
+            if len(matches) == 1:
+                return matches[0]
+        return None
+
+    def _read(self, text: str, line: int, path: str, cwd: List[str], go: GoProject, runs: List[Dict[str, Any]],
+              tools: Set[str], targets: Optional[Dict[str, Tuple[str, Dict[str, Any]]]] = None,
+              functions: Optional[Dict[str, Tuple[str, Dict[str, Any]]]] = None):
+        """
+        Add what one command line runs to runs and tools. cwd holds the
+        directory it runs in, which `cd` moves for the commands after it.
+        """
+        directory = cwd[0]
+        for words in simple_commands(text):
+            while words and (_ASSIGNMENT_WORD.match(words[0]) or words[0] in SHELL_KEYWORDS or
+                             words[0] in COMMAND_PREFIXES):
+                words = words[1:]
+            if not words or words[0] in ("for", "case", "select", "function"):
+                continue
+            program = os.path.basename(words[0])
+            if program in ("cd", "pushd") and len(words) > 1 and "$" not in words[1]:
+                directory = cwd[0] = os.path.normpath(os.path.join(directory, words[1]))
+                continue
+            if program == "go" and len(words) > 1:
+                runs.append(go_command(words, line, directory, go, self.root))
+            elif program in ("make", "gmake"):
+                runs.extend(self._make_runs(words, line, path, directory, targets))
+            elif program in SHELLS and len(words) > 1:
+                argument = next((w for w in words[1:] if not w.startswith("-")), None)
+                if argument is not None:
+                    runs.append(self._script_run(argument, line, directory, path))
+            elif functions is not None and words[0] in functions:
+                defined, _ = functions[words[0]]
+                runs.append({"kind": "function", "name": words[0], "path": defined, "line": line})
+            elif self._script(words[0], directory, path) and ("/" in words[0] or words[0].endswith((".sh", ".bash"))):
+                runs.append(self._script_run(words[0], line, directory, path))
+            elif program not in IGNORED_PROGRAMS and not program.startswith("$") and \
+                    re.match(r"^[\w.+-]+$", program):
+                tools.add(program)
+
+    def _script_run(self, word: str, line: int, directory: str, current: str) -> Dict[str, Any]:
+        script = self._script(word, directory, current)
+        if script:
+            return {"kind": "script", "path": script, "line": line}
+        return {"kind": "script", "argument": word, "line": line}
+
+    def _make_runs(self, words: List[str], line: int, path: str, directory: str,
+                   targets: Optional[Dict[str, Tuple[str, Dict[str, Any]]]]) -> List[Dict[str, Any]]:
+        makefile, names = None, []
+        i = 1
+        while i < len(words):
+            word = words[i]
+            if word in MAKE_VALUE_FLAGS and i + 1 < len(words):
+                value = words[i + 1]
+                if word in ("-C", "--directory"):
+                    directory = os.path.normpath(os.path.join(directory, value))
+                elif word in ("-f", "--file", "--makefile"):
+                    makefile = os.path.normpath(os.path.join(directory, value))
+                i += 2
+                continue
+            if not word.startswith("-") and "=" not in word:
+                names.append(word)
+            i += 1
+        if makefile is None:
+            makefile = next((os.path.join(directory, n) for n in ("GNUmakefile", "makefile", "Makefile")
+                             if os.path.join(directory, n) in self.files), None)
+        if makefile is not None and makefile in self.files:
+            known = self._targets(self._owner(makefile))
+        else:
+            known = targets if targets is not None and makefile is None else {}
+        if not names:
+            default = next((s["name"] for _, s in known.values() if s.get("default")), None)
+            names = [default] if default else []
+        runs = []
+        for name in names:
+            run: Dict[str, Any] = {"kind": "target", "name": name, "line": line}
+            if name in known:
+                run["path"] = known[name][0]
+            elif makefile is not None:
+                run["makefile"] = makefile
+            runs.append(run)
+        return runs
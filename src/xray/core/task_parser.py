@@ -0,0 +1,392 @@
+"""Makefile and shell script parser for XRAY - the build entry points of a project.
+
+Makefiles (Makefile, GNUmakefile, *.mk) give their rules' targets with
+prerequisites and recipe commands, the `.PHONY` set, the default goal,
+variable assignments and include directives. A target's doc is the comment
+block above its rule or a trailing `## text` (the self-documenting `make
+help` idiom). Conditionals are read through, both branches alike, and
+`define` blocks are kept as variables.
+
+Shell scripts (*.sh, *.bash) give their functions, `name() {` and
+`function name {` forms, with the commands of their bodies; commands
+outside any function are the script's own. Quotes, comments, `${...}`
+expansions and here-documents are masked before braces are counted, so a
+`}` inside a string does not end a function.
+
+Commands are kept as written, one per logical line (backslash
+continuations joined, recipe prefixes `@`, `-` and `+` dropped); what they
+run is worked out across files by xray.core.task_analysis.
+"""
+
+import os
+import re
+from typing import Any, Dict, List, Optional, Tuple
+
+# Bump whenever the shape of extracted records changes so cached results are discarded
+TASK_PARSER_VERSION = 1
+
+# Longest signature text quoted from a declaration
+MAX_SIGNATURE = 120
+
+# File names (lower-cased) that are Makefiles whatever their extension
+MAKEFILE_NAMES = ("makefile", "gnumakefile")
+
+_ASSIGNMENT = re.compile(r"^(?:(?:export|override|private)\s+)*([A-Za-z0-9_.\-]+)\s*(::=|:::=|:=|\?=|\+=|!=|=)\s*(.*)$")
+_DIRECTIVE = re.compile(r"^(-?include|sinclude|ifeq|ifneq|ifdef|ifndef|else|endif|define|endef|export|unexport|"
+                        r"override|vpath|undefine)\b\s*(.*)$")
+_RULE = re.compile(r"^([^:=#]+?)\s*(::?)(?!=)\s*(.*)$")
+_SHELL_FUNCTION = re.compile(r"^\s*(?:function\s+([A-Za-z_][\w:.\-]*)\s*(?:\(\s*\))?|([A-Za-z_][\w:.\-]*)\s*\(\s*\))"
+                             r"\s*(\{|\()?\s*")
+_HEREDOC = re.compile(r"<<(-?)\s*(?:'([^']*)'|\"([^\"]*)\"|\\?([A-Za-z_][\w]*))")
+
+
+def is_makefile(path: str) -> bool:
+    """Whether a file is a Makefile: named like one, or a .mk include."""
+    name = os.path.basename(path).lower()
+    return name in MAKEFILE_NAMES or name.endswith(".mk")
+
+
+def _clip(text: str) -> str:
+    text = " ".join(text.split())
+    return text if len(text) <= MAX_SIGNATURE else text[:MAX_SIGNATURE - 3] + "..."
+
+
+def _strip_comment(text: str) -> Tuple[str, Optional[str]]:
+    """A Makefile line without its `#` comment (unless escaped), and the comment text."""
+    i = 0
+    while True:
+        i = text.find("#", i)
+        if i < 0:
+            return text, None
+        if i and text[i - 1] == "\\":
+            i += 1
+            continue
+        return text[:i], text[i + 1:]
+
+
+def _logical_lines(src: str) -> List[Tuple[int, int, str]]:
+    """(first line, last line, text) of every line, backslash continuations joined."""
+    lines = src.split("\n")
+    result = []
+    i = 0
+    while i < len(lines):
+        start = i
+        text = lines[i].rstrip("\r")
+        while text.endswith("\\") and i + 1 < len(lines):
+            i += 1
+            text = text[:-1] + " " + lines[i].rstrip("\r").lstrip()
+        result.append((start + 1, i + 1, text))
+        i += 1
+    return result
+
+
+# Makefiles
+
+def parse_makefile(content: str) -> Dict[str, Any]:
+    """
+    Parse a Makefile.
+
+    Returns:
+        {"symbols", "includes"} plus "default_goal" when a target is the
+        first one make would build. Symbols are "target" records (with
+        "prerequisites", "commands" [{"line", "text"}], and "phony", "doc",
+        "pattern", "default" when they apply) and "variable" records with
+        their "value".
+    """
+    symbols: List[Dict[str, Any]] = []
+    targets: Dict[str, Dict[str, Any]] = {}
+    variables: Dict[str, Dict[str, Any]] = {}
+    includes: List[Dict[str, Any]] = []
+    phony: List[str] = []
+    docs: List[str] = []
+    current: List[Dict[str, Any]] = []
+    default_goal: Optional[str] = None
+    defining: Optional[Dict[str, Any]] = None
+
+    def add_command(line: int, text: str):
+        text = text.strip()
+        while text[:1] in ("@", "-", "+"):
+            text = text[1:].lstrip()
+        if not text or text.startswith("#"):
+            return
+        for target in current:
+            target["commands"].append({"line": line, "text": text})
+            target["end_line"] = max(target["end_line"], line)
+
+    for first, last, raw in _logical_lines(content):
+        if defining is not None:
+            if raw.strip() == "endef":
+                defining["end_line"] = last
+                defining = None
+            else:
+                defining["value"] = (defining["value"] + "\n" + raw).lstrip("\n")
+            continue
+        if raw.startswith("\t") and current:
+            add_command(first, raw[1:])
+            continue
+        text, comment = _strip_comment(raw)
+        stripped = text.strip()
+        if not stripped:
+            if comment is not None and not raw.startswith("\t"):
+                docs.append(comment.lstrip("#").strip())
+            elif not raw.strip():
+                docs = []
+            continue
+        directive = _DIRECTIVE.match(stripped)
+        if directive and not _ASSIGNMENT.match(stripped):
+            keyword, rest = directive.groups()
+            if keyword in ("include", "-include", "sinclude"):
+                for name in rest.split():
+                    includes.append({"path": name, "line": first, "optional": keyword != "include"})
+            elif keyword == "define":
+                name = rest.split()[0] if rest.split() else ""
+                defining = {"name": name, "type": "variable", "signature": _clip(f"define {name}"),
+                            "start_line": first, "end_line": last, "column": raw.find("define") + 1,
+                            "language": "make", "value": ""}
+                symbols.append(defining)
+                variables[name] = defining
+            elif keyword in ("ifeq", "ifneq", "ifdef", "ifndef", "else", "endif"):
+                # A conditional inside a recipe keeps the rule open
+                continue
+            current = []
+            docs = []
+            continue
+        assignment = _ASSIGNMENT.match(stripped)
+        if assignment:
+            name, operator, value = assignment.groups()
+            current = []
+            docs = []
+            if name == ".DEFAULT_GOAL":
+                default_goal = value.strip() or default_goal
+                continue
+            existing = variables.get(name)
+            if existing is not None and operator == "+=":
+                existing["value"] = (existing["value"] + " " + value.strip()).strip()
+                continue
+            if existing is not None and operator == "?=":
+                continue
+            record = {"name": name, "type": "variable", "signature": _clip(stripped), "start_line": first,
+                      "end_line": last, "column": raw.find(name) + 1, "language": "make", "value": value.strip()}
+            if existing is not None:
+                existing.update(record)
+            else:
+                symbols.append(record)
+                variables[name] = record
+            continue
+        rule = _RULE.match(stripped)
+        if not rule:
+            current = []
+            docs = []
+            continue
+        names, _, rest = rule.groups()
+        recipe = None
+        if ";" in rest:
+            rest, recipe = rest.split(";", 1)
+        if _ASSIGNMENT.match(rest.strip()):
+            # A target-specific variable: `build: GOFLAGS = -trimpath`
+            current = []
+            docs = []
+            continue
+        doc = comment.lstrip("#").strip() if comment is not None and comment.startswith("#") else None
+        if doc is None and docs:
+            doc = " ".join(d for d in docs if d)
+        prerequisites = [p for p in rest.split() if p != "|"]
+        current = []
+        for name in names.split():
+            if name == ".PHONY":
+                phony.extend(prerequisites)
+                continue
+            if name.startswith(".") and name[1:2].isupper():
+                # .SUFFIXES, .DELETE_ON_ERROR and the other special targets
+                continue
+            target = targets.get(name)
+            if target is None:
+                target = {"name": name, "type": "target", "signature": _clip(stripped), "start_line": first,
+                          "end_line": last, "column": raw.find(name) + 1, "language": "make",
+                          "prerequisites": [], "commands": []}
+                if "%" in name:
+                    target["pattern"] = True
+                symbols.append(target)
+                targets[name] = target
+                if default_goal is None and "%" not in name and not name.startswith("."):
+                    default_goal = name
+            target["prerequisites"].extend(p for p in prerequisites if p not in target["prerequisites"])
+            if doc and not target.get("doc"):
+                target["doc"] = doc
+            current.append(target)
+        docs = []
+        if recipe is not None:
+            add_command(first, recipe)
+    for name in phony:
+        if name in targets:
+            targets[name]["phony"] = True
+    result: Dict[str, Any] = {"symbols": symbols, "includes": includes}
+    if default_goal is not None and default_goal in targets:
+        targets[default_goal]["default"] = True
+        result["default_goal"] = default_goal
+    return result
+
+
+# Shell scripts
+
+def _mask_shell(src: str) -> str:
+    """
+    src with the contents of quotes, here-document bodies and comments
+    blanked out and `${...}` expansions filled in, offsets and line breaks
+    kept.
+    """
+    out = list(src)
+    n = len(src)
+    i = 0
+    pending: List[Tuple[str, bool]] = []
+
+    def blank(start: int, end: int):
+        for k in range(start, min(end, n)):
+            if out[k] != "\n":
+                out[k] = " "
+
+    while i < n:
+        c = src[i]
+        if c == "\n":
+            i += 1
+            # Here-document bodies start on the line after their operator
+            for delimiter, strip_tabs in pending:
+                while i < n:
+                    end = src.find("\n", i)
+                    end = n if end < 0 else end
+                    line = src[i:end]
+                    blank(i, end)
+                    i = end + 1
+                    if (line.lstrip("\t") if strip_tabs else line).rstrip("\r") == delimiter:
+                        break
+            pending = []
+            continue
+        if c == "\\":
+            blank(i, i + 2)
+            i += 2
+        elif c == "'":
+            end = src.find("'", i + 1)
+            end = n if end < 0 else end
+            blank(i + 1, end)
+            i = end + 1
+        elif c == '"':
+            j = i + 1
+            while j < n and src[j] != '"':
+                j += 2 if src[j] == "\\" else 1
+            blank(i + 1, j)
+            i = j + 1
+        elif c == "$" and src.startswith("${", i):
+            depth, j = 0, i + 1
+            while j < n:
+                if src[j] == "{":
+                    depth += 1
+                elif src[j] == "}":
+                    depth -= 1
+                    if depth == 0:
+                        break
+                j += 1
+            # Kept non-blank, so a trailing expansion is not cut off the command
+            for k in range(i + 1, min(j + 1, n)):
+                if out[k] != "\n":
+                    out[k] = "x"
+            i = j + 1
+        elif c == "#" and (i == 0 or src[i - 1] in " \t\n;&|()"):
+            end = src.find("\n", i)
+            end = n if end < 0 else end
+            blank(i, end)
+            i = end
+        elif src.startswith("<<", i) and not src.startswith("<<<", i):
+            match = _HEREDOC.match(src, i)
+            if match:
+                pending.append((match.group(2) or match.group(3) or match.group(4), match.group(1) == "-"))
+                i = match.end()
+            else:
+                i += 2
+        else:
+            i += 1
+    return "".join(out)
+
+
+def _braces(masked: str) -> int:
+    """The change in `{ ... }` group depth over a masked line."""
+    opened = len(re.findall(r"(?:^|(?<=[\s;&|()]))\{(?=\s|$)", masked))
+    closed = len(re.findall(r"(?:^|(?<=[\s;&|]))\}(?=[\s;&|)]|$)", masked))
+    return opened - closed
+
+
+def parse_shell_source(content: str) -> Dict[str, Any]:
+    """
+    Parse a shell script.
+
+    Returns:
+        {"symbols", "commands"} plus "shebang" (the interpreter, e.g.
+        "bash"). Symbols are "function" records with the "commands" of
+        their bodies; "commands" are the script's own, outside functions.
+        Commands are {"line", "text"}.
+    """
+    content = content.replace("\r\n", "\n")
+    original = content.split("\n")
+    masked_lines = _mask_shell(content).split("\n")
+    symbols: List[Dict[str, Any]] = []
+    commands: List[Dict[str, Any]] = []
+    result: Dict[str, Any] = {"symbols": symbols, "commands": commands}
+    if original[0].startswith("#!"):
+        words = original[0][2:].split()
+        if words:
+            interpreter = words[0].rsplit("/", 1)[-1]
+            if interpreter == "env" and len(words) > 1:
+                interpreter = words[1]
+            result["shebang"] = interpreter
+    # Open functions: (symbol, group depth outside their body; None for a subshell body)
+    stack: List[Tuple[Dict[str, Any], Optional[int]]] = []
+    depth = 0
+    i = 0
+    while i < len(original):
+        first = i + 1
+        text, masked = original[i], masked_lines[i]
+        while text.endswith("\\") and i + 1 < len(original):
+            i += 1
+            indent = len(original[i]) - len(original[i].lstrip())
+            text = text[:-1] + " " + original[i][indent:]
+            masked = masked[:-1] + " " + masked_lines[i][indent:]
+        i += 1
+        if not masked.strip():
+            continue
+        body = stack[-1][0]["commands"] if stack else commands
+        header = _SHELL_FUNCTION.match(masked)
+        if header:
+            name = header.group(1) or header.group(2)
+            symbol = {"name": name, "type": "function", "signature": _clip(text[:header.end()]),
+                      "start_line": first, "end_line": i, "column": text.find(name) + 1, "language": "shell",
+                      "commands": []}
+            if stack:
+                symbol["container"] = stack[-1][0]["name"]
+            symbols.append(symbol)
+            if header.group(3) == "(":
+                stack.append((symbol, None))
+                continue
+            stack.append((symbol, depth))
+            if header.group(3) != "{":
+                # The body's brace is on a line of its own
+                continue
+            depth += 1
+            text, masked = text[header.end():], masked[header.end():]
+            body = symbol["commands"]
+        change = _braces(masked)
+        _add_command(body, first, text, masked)
+        depth += change
+        if stack and stack[-1][1] is None and masked.strip().startswith(")"):
+            stack.pop()[0]["end_line"] = i
+        while stack and stack[-1][1] is not None and depth <= stack[-1][1] and \
+                (change < 0 or header):
+            stack.pop()[0]["end_line"] = i
+    return result
+
+
+def _add_command(commands: List[Dict[str, Any]], line: int, text: str, masked: str):
+    """Record a command line with its comment and group braces trimmed."""
+    code = text[:len(masked.rstrip())].strip()
+    code = re.sub(r"^[{(]\s*", "", code)
+    code = re.sub(r"\s*;?\s*[})]$", "", code).strip()
+    if code and code not in ("{", "}", "(", ")"):
+        commands.append({"line": line, "text": code})
@@ -0,0 +1,161 @@
+"""Cross-file view of the TypeScript and JavaScript modules of a project.
+
+Import specifiers are resolved the way bundlers and tsc's "bundler"
+resolution do for source trees: relative paths with or without an
+extension, directory index files, a `.js` specifier naming the `.ts`
+source it compiles from, and the baseUrl and paths aliases of the root
+tsconfig.json (or jsconfig.json). Anything else is a package - a Node
+builtin or an npm dependency named by its first one or two segments.
+"""
+
+import json
+import os
+import re
+from typing import Any, Dict, Iterator, List, Optional, Tuple
+
+# Tried in this order after the specifier as written
+EXTENSIONS = (".ts", ".tsx", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs")
+# Compiled extension -> the source extensions it may come from
+_SOURCE_OF = {".js": (".ts", ".tsx"), ".jsx": (".tsx",), ".mjs": (".mts",), ".cjs": (".cts",)}
+
+NODE_BUILTINS = {
+    "assert", "async_hooks", "buffer", "child_process", "cluster", "console", "constants", "crypto",
+    "dgram", "diagnostics_channel", "dns", "domain", "events", "fs", "http", "http2", "https",
+    "inspector", "module", "net", "os", "path", "perf_hooks", "process", "punycode", "querystring",
+    "readline", "repl", "stream", "string_decoder", "sys", "timers", "tls", "trace_events", "tty",
+    "url", "util", "v8", "vm", "wasi", "worker_threads", "zlib",
+}
+
+
+def is_relative(specifier: str) -> bool:
+    return specifier.startswith(("./", "../", "/")) or specifier in (".", "..")
+
+
+def is_builtin(specifier: str) -> bool:
+    """Node core modules, with or without the node: scheme ("fs", "node:fs/promises")."""
+    if specifier.startswith("node:"):
+        return True
+    return specifier.split("/", 1)[0] in NODE_BUILTINS
+
+
+def package_name(specifier: str) -> str:
+    """The npm package a bare specifier belongs to ("@scope/pkg/sub" -> "@scope/pkg")."""
+    if specifier.startswith("node:"):
+        return specifier[len("node:"):].split("/", 1)[0]
+    parts = specifier.split("/")
+    return "/".join(parts[:2]) if specifier.startswith("@") and len(parts) > 1 else parts[0]
+
+
+def _strip_jsonc(text: str) -> str:
+    """tsconfig files allow comments and trailing commas; plain JSON does not."""
+    out = []
+    i, n = 0, len(text)
+    while i < n:
+        ch = text[i]
+        if ch == '"':
+            j = i + 1
+            while j < n and text[j] != '"':
+                j += 2 if text[j] == "\\" else 1
+            out.append(text[i:j + 1])
+            i = j + 1
+        elif text.startswith("//", i):
+            end = text.find("\n", i)
+            i = n if end == -1 else end
+        elif text.startswith("/*", i):
+            end = text.find("*/", i + 2)
+            i = n if end == -1 else end + 2
+        else:
+            out.append(ch)
+            i += 1
+    return re.sub(r",(\s*[}\]])", r"\1", "".join(out))
+
+
+def load_tsconfig(root: str) -> Dict[str, Any]:
+    """
+    The module resolution settings of the project's root tsconfig.json or
+    jsconfig.json, following relative `extends`.
+
+    Returns:
+        {"base_url": absolute dir or None, "paths": {pattern: [absolute targets]}}
+    """
+    config: Dict[str, Any] = {"base_url": None, "paths": {}}
+    path = next((os.path.join(root, name) for name in ("tsconfig.json", "jsconfig.json")
+                 if os.path.isfile(os.path.join(root, name))), None)
+    chain = []
+    while path and len(chain) < 8 and path not in chain:
+        chain.append(path)
+        try:
+            with open(path, "r", encoding="utf-8") as f:
+                data = json.loads(_strip_jsonc(f.read()))
+        except (OSError, ValueError):
+            break
+        options = data.get("compilerOptions") or {}
+        config_dir = os.path.dirname(path)
+        if config["base_url"] is None and isinstance(options.get("baseUrl"), str):
+            config["base_url"] = os.path.normpath(os.path.join(config_dir, options["baseUrl"]))
+        if not config["paths"] and isinstance(options.get("paths"), dict):
+            # Targets resolve against baseUrl, or the declaring file's directory without one
+            base = config["base_url"] or config_dir
+            config["paths"] = {pattern: [os.path.normpath(os.path.join(base, t)) for t in targets
+                                         if isinstance(t, str)]
+                               for pattern, targets in options["paths"].items() if isinstance(targets, list)}
+        parent = data.get("extends")
+        path = None
+        if isinstance(parent, str) and is_relative(parent):
+            path = os.path.normpath(os.path.join(config_dir, parent))
+            if not path.endswith(".json"):
+                path += ".json"
+    return config
+
+
+class TsProject:
+    """The parsed TypeScript/JavaScript files of a project and the imports between them."""
+
+    def __init__(self, files: Dict[str, Dict[str, Any]], root: str, config: Optional[Dict[str, Any]] = None):
+        self.files = files
+        self.root = root
+        self.config = config or {"base_url": None, "paths": {}}
+        self._resolved: Dict[Tuple[str, str], Optional[str]] = {}
+
+    def symbols(self) -> Iterator[Tuple[str, Dict[str, Any]]]:
+        """(path, symbol) for every declaration, files in sorted order."""
+        for path in sorted(self.files):
+            for symbol in self.files[path]["symbols"]:
+                yield path, symbol
+
+    def _lookup(self, base: str) -> Optional[str]:
+        stem, ext = os.path.splitext(base)
+        candidates = [base] + [stem + source for source in _SOURCE_OF.get(ext, ())]
+        candidates += [base + e for e in EXTENSIONS] + [os.path.join(base, "index" + e) for e in EXTENSIONS]
+        return next((c for c in candidates if c in self.files), None)
+
+    def resolve(self, importer: str, specifier: str) -> Optional[str]:
+        """The indexed file an import in importer refers to, or None (a package, or not indexed)."""
+        key = (os.path.dirname(importer), specifier)
+        if key in self._resolved:
+            return self._resolved[key]
+        found = None
+        if is_relative(specifier):
+            base = specifier if specifier.startswith("/") else os.path.join(key[0], specifier)
+            found = self._lookup(os.path.normpath(base))
+        else:
+            for pattern, targets in self.config["paths"].items():
+                prefix, star, suffix = pattern.partition("*")
+                if star and specifier.startswith(prefix) and specifier.endswith(suffix) \
+                        and len(specifier) >= len(prefix) + len(suffix):
+                    middle = specifier[len(prefix):len(specifier) - len(suffix)]
+                elif not star and specifier == pattern:
+                    middle = ""
+                else:
+                    continue
+                found = next((f for f in (self._lookup(t.replace("*", middle)) for t in targets) if f), None)
+                if found:
+                    break
+            if found is None and self.config["base_url"]:
+                found = self._lookup(os.path.normpath(os.path.join(self.config["base_url"], specifier)))
+        self._resolved[key] = found
+        return found
+
+    def imports_of(self, path: str) -> List[Dict[str, Any]]:
+        """The imports of a file, each with the indexed file it resolves to ("resolved", or None)."""
+        return [{**imp, "resolved": self.resolve(path, imp["source"])} for imp in self.files[path]["imports"]]
@@ -0,0 +1,1184 @@
+"""TypeScript and JavaScript source parser for XRAY - tokenizer and declaration scanner.
+
+Like the Go parser this is a small native lexer plus a parser for the
+declarations that matter to the index: imports and exports, classes and
+their members, interfaces, type aliases, enums, functions and the consts
+bound to arrow functions. Expressions and function bodies are only skipped
+over as balanced token ranges, with automatic semicolon insertion
+approximated from line breaks, so invalid or unusual code degrades to
+missed symbols rather than a failed file.
+"""
+
+import bisect
+import re
+from typing import Any, Dict, List, Optional, Set, Tuple
+
+# Bump whenever the shape of extracted records changes so cached results are discarded
+TS_PARSER_VERSION = 2
+
+# Longest punctuators first so the lexer can match greedily
+PUNCTUATORS = [
+    ">>>=", "...", "===", "!==", "**=", "<<=", ">>=", ">>>", "&&=", "||=", "??=",
+    "=>", "==", "!=", "<=", ">=", "&&", "||", "??", "?.", "++", "--", "+=", "-=", "*=",
+    "/=", "%=", "&=", "|=", "^=", "**", "<<", ">>",
+    "{", "}", "(", ")", "[", "]", ";", ",", "<", ">", "+", "-", "*", "/", "%",
+    "&", "|", "^", "!", "~", "?", ":", "=", ".", "@",
+]
+
+# Words after which a slash or angle bracket starts an expression (regex, JSX)
+_EXPRESSION_KEYWORDS = {
+    "return", "typeof", "instanceof", "in", "of", "new", "delete", "void", "throw",
+    "case", "do", "else", "yield", "await", "default", "extends",
+}
+
+# A line break does not end a statement after these, nor before the next set
+_CONTINUES_AFTER = {
+    "=", "+", "-", "*", "/", "%", "**", "&&", "||", "??", "?", ":", ",", ".", "?.", "=>",
+    "|", "&", "^", "==", "===", "!=", "!==", "<=", ">=", "<<", ">>", ">>>", "!", "~",
+    "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "**=", "<<=", ">>=", ">>>=", "&&=", "||=", "??=",
+    "extends", "keyof", "typeof", "new", "in", "instanceof", "of", "as", "satisfies", "await",
+    "implements", "readonly", "unique", "infer", "is", "asserts",
+}
+_CONTINUES_BEFORE = {
+    ".", "?.", "=>", "&&", "||", "??", "?", ":", "|", "&", "^", "+", "-", "*", "/", "%", "**",
+    "==", "===", "!=", "!==", "<=", ">=", "=", ",", ")", "]",
+    "extends", "instanceof", "in", "as", "satisfies", "implements",
+}
+
+# Class member modifiers, kept in signatures
+_MODIFIERS = {
+    "public", "private", "protected", "static", "readonly", "abstract", "async",
+    "override", "declare", "accessor", "get", "set",
+}
+
+# Comment directives that are not documentation
+_DIRECTIVE = re.compile(r"^(//|/\*)\s*(eslint|@ts-|tslint|prettier-ignore|istanbul|jshint|global |#region|#endregion)")
+
+# Longest signature text quoted from an initializer or type alias body
+MAX_SIGNATURE = 120
+
+_TS_EXTENSIONS = (".ts", ".tsx", ".mts", ".cts")
+
+
+def source_language(path: str) -> str:
+    """"typescript" for .ts/.tsx files, "javascript" for the others."""
+    return "typescript" if path.lower().endswith(_TS_EXTENSIONS) else "javascript"
+
+
+def allows_jsx(path: str) -> bool:
+    """
+    Whether JSX may appear in the file. Plain .ts cannot hold it (`<T>x` is a
+    type assertion there), but React code routinely puts it in .js files.
+    """
+    return not path.lower().endswith((".ts", ".mts", ".cts"))
+
+
+class Token:
+    """A single TypeScript/JavaScript token with its source position."""
+
+    __slots__ = ("kind", "value", "line", "col", "end_line", "start", "end", "nl_before")
+
+    def __init__(self, kind, value, start, end):
+        self.kind = kind
+        self.value = value
+        self.start = start
+        self.end = end
+        self.line = self.col = self.end_line = 0
+        self.nl_before = False
+
+    def __repr__(self):
+        return f"Token({self.kind}, {self.value!r}, {self.line}:{self.col})"
+
+
+class _Lexer:
+    """
+    Split source into tokens. Template literals, regular expressions and
+    JSX elements each become one opaque token; the code embedded in them
+    (`${...}`, `{...}`) is lexed only to find where they end.
+    """
+
+    def __init__(self, src: str, jsx: bool):
+        self.src = src
+        self.n = len(src)
+        self.jsx = jsx
+        self.i = 0
+        self.tokens: List[Token] = []
+        self.comments: List[Token] = []
+
+    def run(self) -> Tuple[List[Token], List[Token]]:
+        if self.src.startswith("#!"):
+            end = self.src.find("\n")
+            self.i = self.n if end == -1 else end
+        self._scan(self.tokens, nested=False)
+        return self.tokens, self.comments
+
+    def _expression_expected(self, out: List[Token]) -> bool:
+        """Whether the previous token leaves the lexer at the start of an expression."""
+        if not out:
+            return True
+        prev = out[-1]
+        if prev.kind == "ident":
+            return prev.value in _EXPRESSION_KEYWORDS
+        if prev.kind == "punct":
+            return prev.value not in (")", "]", "++", "--")
+        return False
+
+    def _scan(self, out: List[Token], nested: bool):
+        """Lex until EOF or, when nested, past the `}` closing the embedded code."""
+        src, n = self.src, self.n
+        depth = 0
+        while self.i < n:
+            i = self.i
+            ch = src[i]
+            if ch in " \t\r\n\v\f\ufeff\u00a0\u2028\u2029":
+                self.i += 1
+                continue
+            if src.startswith("//", i):
+                end = src.find("\n", i)
+                end = n if end == -1 else end
+                self.comments.append(Token("comment", src[i:end], i, end))
+                self.i = end
+                continue
+            if src.startswith("/*", i):
+                end = src.find("*/", i + 2)
+                end = n if end == -1 else end + 2
+                self.comments.append(Token("comment", src[i:end], i, end))
+                self.i = end
+                continue
+            if ch.isalpha() or ch in "_$#" or ord(ch) > 127:
+                j = i + 1
+                while j < n and (src[j].isalnum() or src[j] in "_$" or ord(src[j]) > 127):
+                    j += 1
+                out.append(Token("ident", src[i:j], i, j))
+                self.i = j
+                continue
+            if ch.isdigit() or (ch == "." and i + 1 < n and src[i + 1].isdigit()):
+                j = i + 1
+                while j < n and (src[j].isalnum() or src[j] in "_." or
+                                 (src[j] in "+-" and src[j - 1] in "eE" and not src.startswith(("0x", "0X"), i))):
+                    j += 1
+                out.append(Token("number", src[i:j], i, j))
+                self.i = j
+                continue
+            if ch in "\"'":
+                j = i + 1
+                while j < n and src[j] != ch and src[j] != "\n":
+                    j += 2 if src[j] == "\\" else 1
+                self.i = min(j + 1, n)
+                out.append(Token("string", src[i:self.i], i, self.i))
+                continue
+            if ch == "`":
+                self._template()
+                out.append(Token("template", src[i:self.i], i, self.i))
+                continue
+            if ch == "/" and self._expression_expected(out) and self._regex():
+                out.append(Token("regex", src[i:self.i], i, self.i))
+                continue
+            if ch == "<" and self.jsx and self._expression_expected(out) and self._jsx_start():
+                self._jsx_element()
+                out.append(Token("jsx", src[i:self.i], i, self.i))
+                continue
+            if ch == "{":
+                depth += 1
+            elif ch == "}":
+                if nested and depth == 0:
+                    self.i += 1
+                    return
+                depth -= 1
+            for punct in PUNCTUATORS:
+                if src.startswith(punct, i):
+                    out.append(Token("punct", punct, i, i + len(punct)))
+                    self.i = i + len(punct)
+                    break
+            else:
+                # Unknown character - skip it rather than abort the whole file
+                self.i += 1
+
+    def _template(self):
+        src, n = self.src, self.n
+        self.i += 1
+        while self.i < n:
+            ch = src[self.i]
+            if ch == "\\":
+                self.i += 2
+            elif ch == "`":
+                self.i += 1
+                return
+            elif src.startswith("${", self.i):
+                self.i += 2
+                self._scan([], nested=True)
+            else:
+                self.i += 1
+
+    def _regex(self) -> bool:
+        """Consume a regular expression literal; False (nothing consumed) if the line ends first."""
+        src, n = self.src, self.n
+        j = self.i + 1
+        in_class = False
+        while j < n and src[j] != "\n":
+            ch = src[j]
+            if ch == "\\":
+                j += 2
+                continue
+            if ch == "[":
+                in_class = True
+            elif ch == "]":
+                in_class = False
+            elif ch == "/" and not in_class:
+                j += 1
+                while j < n and (src[j].isalnum() or src[j] == "_"):
+                    j += 1
+                self.i = j
+                return True
+            j += 1
+        return False
+
+    def _jsx_start(self) -> bool:
+        """A `<` opens an element when a tag name or `>` follows - but not `<T,>` or `<T extends U>`."""
+        match = re.compile(r"<\s*(?:>|([A-Za-z_$][\w$.:-]*)(\s*,|\s+extends\s)?)").match(self.src, self.i)
+        return bool(match) and not match.group(2)
+
+    def _jsx_element(self):
+        src, n = self.src, self.n
+        self.i += 1
+        while self.i < n and src[self.i].isspace():
+            self.i += 1
+        if self.i < n and src[self.i] == ">":
+            self.i += 1
+            self._jsx_children()
+            return
+        while self.i < n and (src[self.i].isalnum() or src[self.i] in "_$.:-"):
+            self.i += 1
+        if self.i < n and src[self.i] == "<":
+            # Type arguments of a generic component, <Select<Option> ...>
+            depth = 0
+            while self.i < n:
+                depth += {"<": 1, ">": -1}.get(src[self.i], 0)
+                self.i += 1
+                if depth == 0:
+                    break
+        while self.i < n:
+            ch = src[self.i]
+            if src.startswith("/>", self.i):
+                self.i += 2
+                return
+            if ch == ">":
+                self.i += 1
+                self._jsx_children()
+                return
+            if ch == "{":
+                self.i += 1
+                self._scan([], nested=True)
+            elif ch in "\"'":
+                end = src.find(ch, self.i + 1)
+                self.i = n if end == -1 else end + 1
+            else:
+                self.i += 1
+
+    def _jsx_children(self):
+        src, n = self.src, self.n
+        while self.i < n:
+            ch = src[self.i]
+            if ch == "<":
+                j = self.i + 1
+                while j < n and src[j].isspace():
+                    j += 1
+                if j < n and src[j] == "/":
+                    end = src.find(">", j)
+                    self.i = n if end == -1 else end + 1
+                    return
+                self._jsx_element()
+            elif ch == "{":
+                self.i += 1
+                self._scan([], nested=True)
+            else:
+                self.i += 1
+
+
+def tokenize(src: str, jsx: bool = False) -> Tuple[List[Token], List[Token]]:
+    """
+    Split TypeScript/JavaScript source into tokens.
+
+    Returns:
+        (tokens, comments) - every token records whether a line break
+        precedes it, which stands in for semicolon insertion.
+    """
+    tokens, comments = _Lexer(src, jsx).run()
+    line_starts = [0] + [m.end() for m in re.finditer("\n", src)]
+
+    def locate(offset: int) -> Tuple[int, int]:
+        line = bisect.bisect_right(line_starts, offset)
+        return line, offset - line_starts[line - 1] + 1
+
+    prev_end = 0
+    for tok in tokens:
+        tok.line, tok.col = locate(tok.start)
+        tok.end_line = locate(max(tok.end - 1, tok.start))[0]
+        tok.nl_before = "\n" in src[prev_end:tok.start]
+        prev_end = tok.end
+    for comment in comments:
+        comment.line, comment.col = locate(comment.start)
+        comment.end_line = locate(max(comment.end - 1, comment.start))[0]
+    return tokens, comments
+
+
+def unquote(literal: str) -> str:
+    """Return the contents of a string literal without its quotes (escapes kept)."""
+    if len(literal) >= 2 and literal[0] == literal[-1] and literal[0] in "\"'`":
+        return literal[1:-1]
+    return literal
+
+
+def _clip(text: str) -> str:
+    return text if len(text) <= MAX_SIGNATURE else text[:MAX_SIGNATURE - 3].rstrip() + "..."
+
+
+class TsFileParser:
+    """Parse a TypeScript/JavaScript file into import, export, and symbol records."""
+
+    def __init__(self, content: str, language: str = "typescript", jsx: bool = False):
+        self.src = content
+        self.language = language
+        self.tokens, self.comments = tokenize(content, jsx)
+        self.imports: List[Dict[str, Any]] = []
+        self.exports: List[Dict[str, Any]] = []
+        self.symbols: List[Dict[str, Any]] = []
+        # Local names exported by `export { a as b }`, `export default a` and CommonJS
+        self._exported_locals: Dict[str, bool] = {}
+        # Token indexes of require() calls already recorded by `import x = require()`
+        self._consumed: Set[int] = set()
+        self._first_code_col: Optional[Dict[int, int]] = None
+
+    # ------------------------------------------------------------------
+    # Token helpers
+    # ------------------------------------------------------------------
+
+    def _v(self, idx: int) -> Optional[str]:
+        return self.tokens[idx].value if 0 <= idx < len(self.tokens) else None
+
+    def _kind(self, idx: int) -> Optional[str]:
+        return self.tokens[idx].kind if 0 <= idx < len(self.tokens) else None
+
+    def _is_name(self, idx: int) -> bool:
+        """An identifier token (keywords included - most are contextual in TypeScript)."""
+        return self._kind(idx) == "ident"
+
+    def _same_line(self, idx: int) -> bool:
+        return 0 <= idx < len(self.tokens) and not self.tokens[idx].nl_before
+
+    def _text(self, start: int, end: int) -> str:
+        """Render tokens[start:end] as single-line source text."""
+        parts = []
+        prev = None
+        for idx in range(start, min(end, len(self.tokens))):
+            tok = self.tokens[idx]
+            if prev is not None and self.src[prev.end:tok.start]:
+                parts.append(" ")
+            parts.append(" ".join(tok.value.split()) if tok.kind in ("template", "jsx") else tok.value)
+            prev = tok
+        return "".join(parts)
+
+    def _match(self, idx: int) -> int:
+        """Index of the bracket closing the one at idx (the last token if unbalanced)."""
+        pairs = {"(": ")", "[": "]", "{": "}"}
+        depth = 0
+        for j in range(idx, len(self.tokens)):
+            tok = self.tokens[j]
+            if tok.kind != "punct":
+                continue
+            if tok.value in pairs:
+                depth += 1
+            elif tok.value in (")", "]", "}"):
+                depth -= 1
+                if depth == 0:
+                    return j
+        return len(self.tokens) - 1
+
+    def _match_angle(self, idx: int) -> int:
+        """Index just past the `>` closing type parameters or arguments opened at idx."""
+        depth = 0
+        j = idx
+        while j < len(self.tokens):
+            tok = self.tokens[j]
+            if tok.kind == "punct":
+                if tok.value == "<":
+                    depth += 1
+                elif tok.value in (">", ">>", ">>>"):
+                    depth -= len(tok.value)
+                    if depth <= 0:
+                        return j + 1
+                elif tok.value in ("(", "[", "{"):
+                    j = self._match(j)
+                elif tok.value in (";", ")", "]", "}"):
+                    return j
+            j += 1
+        return j
+
+    def _type_arguments(self, idx: int) -> bool:
+        """Whether the `<` at idx, stuck to a name, opens the type arguments of a call."""
+        prev = self.tokens[idx - 1]
+        if self.language != "typescript" or prev.kind != "ident" or prev.end != self.tokens[idx].start:
+            return False
+        return self._v(self._match_angle(idx)) in ("(", "`")
+
+    def _breaks(self, idx: int) -> bool:
+        """Whether a line break before token idx ends the statement (semicolon insertion)."""
+        tok = self.tokens[idx]
+        if not tok.nl_before or idx == 0:
+            return False
+        prev = self.tokens[idx - 1]
+        if prev.kind in ("punct", "ident") and prev.value in _CONTINUES_AFTER:
+            return False
+        return not (tok.kind in ("punct", "ident") and tok.value in _CONTINUES_BEFORE)
+
+    def _end(self, idx: int, upper: Optional[int] = None, stops: Tuple[str, ...] = (), after: bool = False) -> int:
+        """
+        Index of the token ending the statement starting at idx: a `;`, an
+        enclosing closer, the first token after a statement-ending line
+        break, or a depth-0 token in stops ("," for list elements; "=",
+        "{" and "=>" end a type annotation, which switches on counting of
+        angle brackets). The terminator itself is not consumed. With after,
+        idx follows a parsed construct, so a line break before it counts.
+        """
+        upper = len(self.tokens) if upper is None else upper
+        in_type = any(stop in stops for stop in ("=", "{", "=>"))
+        depth = 0
+        angle = 0
+        j = idx
+        while j < upper:
+            tok = self.tokens[j]
+            if (j > idx or after) and depth == 0 and self._breaks(j):
+                return j
+            if tok.kind == "ident" and tok.value in ("as", "satisfies") and depth == 0:
+                # What follows is a type, where angle brackets pair up
+                in_type = True
+            if tok.kind == "punct":
+                value = tok.value
+                if value == "<" and not in_type and (j == idx or self._type_arguments(j)):
+                    # Type parameters of a generic arrow function, <T,>(x: T) => x,
+                    # or type arguments of a call, new Map<string, number>()
+                    j = self._match_angle(j)
+                    continue
+                if value in ("(", "[", "{"):
+                    if value == "{" and "{" in stops and depth == 0 and angle == 0 and j > idx and \
+                            self._v(j - 1) not in (":", "|", "&", ",", "=>", "<", "(", "keyof", "typeof"):
+                        return j
+                    depth += 1
+                elif value in (")", "]", "}"):
+                    if depth == 0:
+                        return j
+                    depth -= 1
+                elif depth == 0:
+                    if value == ";" or (value in stops and angle == 0):
+                        return j
+                    if in_type:
+                        # Inside a type, angle brackets delimit type arguments
+                        if value == "<":
+                            angle += 1
+                        elif value in (">", ">>", ">>>"):
+                            angle = max(0, angle - len(value))
+            j += 1
+        return upper
+
+    def _finish(self, idx: int, end: int) -> int:
+        """Skip what is left of a statement whose interesting part ends before idx."""
+        return self._skip_semi(self._end(idx, end, after=True))
+
+    def _skip_semi(self, idx: int) -> int:
+        return idx + 1 if self._v(idx) == ";" else idx
+
+    # ------------------------------------------------------------------
+    # Documentation
+    # ------------------------------------------------------------------
+
+    def _leading_comments(self, line: int) -> List[Token]:
+        """The contiguous comment block ending directly above `line`, as in the Go parser."""
+        if self._first_code_col is None:
+            self._first_code_col = {}
+            for tok in self.tokens:
+                self._first_code_col.setdefault(tok.line, tok.col)
+        block: List[Token] = []
+        expected = line - 1
+        for comment in reversed(self.comments):
+            if comment.end_line > expected:
+                continue
+            if comment.end_line < expected:
+                break
+            if self._first_code_col.get(comment.line, comment.col + 1) < comment.col:
+                break
+            block.insert(0, comment)
+            expected = comment.line - 1
+        return block
+
+    def _doc_for(self, line: int) -> str:
+        """The JSDoc or line comments directly above a declaration, markers stripped."""
+        block = [c for c in self._leading_comments(line) if not _DIRECTIVE.match(c.value)]
+        # A JSDoc block wins over line comments stacked above it
+        jsdoc = [c for c in block if c.value.startswith("/**")]
+        if jsdoc:
+            block = jsdoc[-1:]
+        lines = []
+        for comment in block:
+            text = comment.value
+            if text.startswith("//"):
+                body = text[2:]
+                lines.append(body[1:] if body.startswith(" ") else body)
+            else:
+                body_lines = text[2:-2].lstrip("*").strip("\n").split("\n")
+                lines.extend(re.sub(r"^\s*\* ?", "", l) if l.lstrip().startswith("*") else l.strip()
+                             for l in body_lines)
+        return "\n".join(lines).strip()
+
+    def _doc_extras(self, doc: str) -> Dict[str, Any]:
+        """The `deprecated` flag of a JSDoc @deprecated tag."""
+        match = re.search(r"^@deprecated\b(.*)$", doc, re.MULTILINE)
+        if not match:
+            return {}
+        return {"deprecated": True, "deprecation": match.group(1).strip()}
+
+    # ------------------------------------------------------------------
+    # Statements
+    # ------------------------------------------------------------------
+
+    def parse(self) -> Dict[str, Any]:
+        """Parse the whole file and return imports, exports, and symbols."""
+        self._statements(0, len(self.tokens), None)
+        self._calls()
+
+        for symbol in self.symbols:
+            declared_default = symbol.pop("declared_default", False)
+            listed = symbol.pop("listed", False)
+            if "container" in symbol:
+                continue
+            if not symbol["exported"] and symbol["name"] in self._exported_locals:
+                # Exported further down, by an export list or assignment already recorded
+                symbol["exported"] = True
+                if self._exported_locals[symbol["name"]]:
+                    symbol["default"] = True
+            elif symbol["exported"] and not listed:
+                self.exports.append({"name": "default" if declared_default else symbol["name"],
+                                     "local": symbol["name"], "line": symbol["start_line"], "kind": symbol["type"]})
+        # Members are visible wherever their class or interface is, private ones aside
+        owners = {(f"{s['container']}." if "container" in s else "") + s["name"]: s
+                  for s in self.symbols if s["type"] in ("class", "interface")}
+        for symbol in self.symbols:
+            owner = owners.get(symbol.get("container"))
+            if owner is not None:
+                symbol["exported"] = owner["exported"] and symbol.get("access") != "private"
+        self.exports.sort(key=lambda e: e["line"])
+        self.imports.sort(key=lambda imp: (imp["line"], imp["column"]))
+        return {"imports": self.imports, "exports": self.exports, "symbols": self.symbols}
+
+    def _statements(self, start: int, end: int, container: Optional[str]):
+        """Parse the statements in tokens[start:end] - a file or a namespace body."""
+        pos = start
+        while pos < end:
+            pos = max(self._statement(pos, end, container), pos + 1)
+
+    def _skip_decorators(self, idx: int) -> int:
+        while self._v(idx) == "@":
+            idx += 1
+            while self._is_name(idx) and self._v(idx + 1) == ".":
+                idx += 2
+            idx += 1
+            if self._v(idx) == "<":
+                idx = self._match_angle(idx)
+            if self._v(idx) == "(" and self._same_line(idx):
+                idx = self._match(idx) + 1
+        return idx
+
+    def _statement(self, pos: int, end: int, container: Optional[str]) -> int:
+        start = pos
+        pos = self._skip_decorators(pos)
+        flags = {"exported": False, "default": False, "declare": False}
+        value = self._v(pos)
+
+        if value == "export":
+            nxt = self._v(pos + 1)
+            if nxt in ("{", "*") or (nxt == "type" and self._v(pos + 2) in ("{", "*")):
+                return self._export_clause(pos, end)
+            if nxt == "=":
+                # TypeScript's `export = value`, the CommonJS module.exports
+                return self._export_default(pos + 2, end)
+            if nxt in ("import", "as"):
+                return self._skip_semi(self._end(pos + 1, end))
+            flags["exported"] = True
+            pos += 1
+            if self._v(pos) == "default":
+                flags["default"] = True
+                pos += 1
+            pos = self._skip_decorators(pos)
+            value = self._v(pos)
+        elif value == "import" and self._v(pos + 1) not in ("(", "."):
+            return self._import(pos, end)
+
+        while value in ("declare", "abstract") and self._is_name(pos + 1) and self._same_line(pos + 1):
+            flags["declare"] |= value == "declare"
+            pos += 1
+            value = self._v(pos)
+
+        if value == "function" or (value == "async" and self._v(pos + 1) == "function" and self._same_line(pos + 1)):
+            return self._function(start, pos, end, flags, container)
+        if value == "class":
+            return self._class(start, pos, end, flags, container)
+        if value == "interface" and self._is_name(pos + 1):
+            return self._interface(start, pos, end, flags, container)
+        if value == "type" and self._is_name(pos + 1) and self._v(pos + 2) in ("=", "<"):
+            return self._type_alias(start, pos, end, flags, container)
+        if value == "enum" or (value == "const" and self._v(pos + 1) == "enum"):
+            return self._enum(start, pos, end, flags, container)
+        if value in ("const", "let", "var") and (self._is_name(pos + 1) or self._v(pos + 1) in ("{", "[")):
+            return self._variables(start, pos, end, flags, container)
+        if value in ("namespace", "module") and self._is_name(pos + 1) and self._same_line(pos + 1):
+            return self._namespace(start, pos, end, flags, container)
+        if flags["default"]:
+            return self._export_default(pos, end)
+        if container is None and value in ("module", "exports") and self._v(pos + 1) == ".":
+            return self._commonjs(pos, end)
+        if value == "{":
+            return self._match(pos) + 1
+        return self._skip_semi(self._end(pos, end))
+
+    def _symbol(self, name_tok: Optional[Token], kind: str, start: int, last: int, signature: str,
+                flags: Dict[str, bool], container: Optional[str], **extra) -> Dict[str, Any]:
+        """Record a symbol spanning tokens[start..last]; anonymous default exports are named "default"."""
+        first = self.tokens[start]
+        doc = self._doc_for(first.line)
+        symbol = {
+            "name": name_tok.value if name_tok else "default",
+            "type": kind,
+            "signature": signature,
+            "start_line": first.line,
+            "column": (name_tok or first).col,
+            "end_line": self.tokens[min(max(last, start), len(self.tokens) - 1)].end_line,
+            "doc": doc,
+            **self._doc_extras(doc),
+            "exported": flags["exported"],
+            "language": self.language,
+        }
+        if flags["default"]:
+            symbol["default"] = True
+            symbol["declared_default"] = True
+        if flags.get("declare"):
+            symbol["ambient"] = True
+        if container:
+            symbol["container"] = container
+        symbol.update(extra)
+        self.symbols.append(symbol)
+        return symbol
+
+    def _params_and_return(self, idx: int, end: int, arrow: bool = False) -> int:
+        """From type parameters or `(`, skip the parameters and return type; index of what follows."""
+        if self._v(idx) == "<":
+            idx = self._match_angle(idx)
+        if self._v(idx) == "(":
+            idx = self._match(idx) + 1
+        if self._v(idx) == ":":
+            return self._end(idx + 1, end, (",", "=", "=>") if arrow else (",", "=", "{"))
+        return idx
+
+    def _function(self, start: int, pos: int, end: int, flags: Dict[str, bool], container: Optional[str]) -> int:
+        sig_start = pos
+        is_async = self._v(pos) == "async"
+        pos += 2 if is_async else 1
+        generator = self._v(pos) == "*"
+        if generator:
+            pos += 1
+        name_tok = self.tokens[pos] if self._is_name(pos) and self._v(pos) != "(" else None
+        if name_tok:
+            pos += 1
+        body = self._params_and_return(pos, end)
+        signature = self._text(sig_start, body)
+        if self._v(body) == "{":
+            last = self._match(body)
+            nxt = last + 1
+        else:
+            nxt = self._end(body, end, after=True)
+            last = nxt - 1
+            nxt = self._skip_semi(nxt)
+            if not flags["declare"]:
+                # An overload signature; the implementation follows
+                return nxt
+        extra = {"async": True} if is_async else {}
+        if generator:
+            extra["generator"] = True
+        if name_tok or flags["default"]:
+            self._symbol(name_tok, "function", start, last, signature, flags, container, **extra)
+        return nxt
+
+    def _heritage(self, idx: int, stop: str) -> Tuple[List[str], int]:
+        """Comma-separated type references up to the word stop or a `{`."""
+        items = []
+        while idx < len(self.tokens) and self._v(idx) not in ("{", stop):
+            item_end = idx
+            while item_end < len(self.tokens) and self._v(item_end) not in ("{", ",", stop):
+                if self._v(item_end) == "<":
+                    item_end = self._match_angle(item_end)
+                elif self._v(item_end) in ("(", "["):
+                    item_end = self._match(item_end) + 1
+                else:
+                    item_end += 1
+            items.append(self._text(idx, item_end))
+            idx = item_end + 1 if self._v(item_end) == "," else item_end
+        return items, idx
+
+    def _class(self, start: int, pos: int, end: int, flags: Dict[str, bool], container: Optional[str]) -> int:
+        sig_start = pos
+        while self._v(sig_start - 1) in ("abstract", "declare") and sig_start - 1 >= start:
+            sig_start -= 1
+        extra: Dict[str, Any] = {}
+        if any(self._v(j) == "abstract" for j in range(sig_start, pos)):
+            extra["abstract"] = True
+        pos += 1
+        name_tok = None
+        if self._is_name(pos) and self._v(pos) not in ("extends", "implements"):
+            name_tok = self.tokens[pos]
+            pos += 1
+        if self._v(pos) == "<":
+            pos = self._match_angle(pos)
+        if self._v(pos) == "extends":
+            extra["extends"], pos = self._heritage(pos + 1, "implements")
+        if self._v(pos) == "implements":
+            extra["implements"], pos = self._heritage(pos + 1, "{")
+        if self._v(pos) != "{":
+            return self._finish(pos, end)
+        last = self._match(pos)
+        symbol = self._symbol(name_tok, "class", start, last, self._text(sig_start, pos), flags, container, **extra)
+        self._members(pos + 1, last, f"{container}.{symbol['name']}" if container else symbol["name"], interface=False)
+        return last + 1
+
+    def _interface(self, start: int, pos: int, end: int, flags: Dict[str, bool], container: Optional[str]) -> int:
+        name_tok = self.tokens[pos + 1]
+        body = pos + 2
+        if self._v(body) == "<":
+            body = self._match_angle(body)
+        extra: Dict[str, Any] = {}
+        if self._v(body) == "extends":
+            extra["extends"], body = self._heritage(body + 1, "{")
+        if self._v(body) != "{":
+            return self._finish(body, end)
+        last = self._match(body)
+        self._symbol(name_tok, "interface", start, last, self._text(pos, body), flags, container, **extra)
+        self._members(body + 1, last, f"{container}.{name_tok.value}" if container else name_tok.value, interface=True)
+        return last + 1
+
+    def _members(self, pos: int, end: int, owner: str, interface: bool):
+        """Record the methods and properties of a class or interface body (exported flags set by parse())."""
+        # Interface members may be separated by commas as well
+        stops = (",",) if interface else ()
+        while pos < end:
+            if self._v(pos) in (";", ","):
+                pos += 1
+                continue
+            start = pos
+            pos = self._skip_decorators(pos)
+            sig_start = pos
+            if self._v(pos) == "static" and self._v(pos + 1) == "{":
+                pos = self._match(pos + 1) + 1
+                continue
+            modifiers = []
+            while self._v(pos) in _MODIFIERS and self._same_line(pos + 1) and \
+                    (self._kind(pos + 1) in ("ident", "string", "number") or self._v(pos + 1) in ("[", "*")):
+                modifiers.append(self._v(pos))
+                pos += 1
+            if self._v(pos) == "*":
+                pos += 1
+            if self._kind(pos) in ("ident", "string", "number"):
+                name_tok = self._member_name(pos, pos)
+                pos += 1
+            elif self._v(pos) == "[" and not (self._is_name(pos + 1) and self._v(pos + 2) in (":", "in")):
+                close = self._match(pos)
+                name_tok = self._member_name(pos, close)
+                pos = close + 1
+            else:
+                # Index, call and construct signatures
+                pos = self._skip_semi(max(self._end(pos, end, stops), pos + 1))
+                continue
+            if self._v(pos) in ("?", "!"):
+                pos += 1
+
+            access = next((m for m in modifiers if m in ("private", "protected")), None)
+            if name_tok.value.startswith("#"):
+                access = "private"
+            member_flags = {"exported": False, "default": False}
+            extra: Dict[str, Any] = {}
+            if "static" in modifiers:
+                extra["static"] = True
+            if access:
+                extra["access"] = access
+
+            if self._v(pos) in ("(", "<"):
+                body = self._params_and_return(pos, end)
+                signature = self._text(sig_start, body)
+                if self._v(body) == "{" and not interface:
+                    last = self._match(body)
+                    nxt = last + 1
+                else:
+                    nxt = self._end(body, end, stops, after=True)
+                    last = nxt - 1
+                    nxt = self._skip_semi(nxt)
+                    if not interface and "abstract" not in modifiers and "declare" not in modifiers:
+                        # An overload signature; the implementation follows
+                        pos = nxt
+                        continue
+                if "async" in modifiers:
+                    extra["async"] = True
+                if "get" in modifiers or "set" in modifiers:
+                    extra["accessor"] = "get" if "get" in modifiers else "set"
+                self._symbol(name_tok, "method", start, last, signature, member_flags, owner, **extra)
+                pos = nxt
+                continue
+
+            type_end = pos
+            if self._v(pos) == ":":
+                type_end = self._end(pos + 1, end, stops + ("=",))
+            signature = self._text(sig_start, type_end)
+            kind = "property"
+            if self._v(type_end) == "=":
+                nxt = self._end(type_end + 1, end)
+                if self._is_function(type_end + 1, nxt):
+                    kind = "method"
+                    extra["arrow"] = True
+                    signature += f" = {self._function_head(type_end + 1, nxt)}"
+            else:
+                nxt = self._end(type_end, end, stops, after=True)
+            self._symbol(name_tok, kind, start, nxt - 1, signature, member_flags, owner, **extra)
+            pos = self._skip_semi(max(nxt, pos))
+
+    def _member_name(self, first: int, last: int) -> Token:
+        """The name of a member: identifiers as they are, strings unquoted, `[computed]` as written."""
+        tok = self.tokens[first]
+        if first == last and tok.kind != "string":
+            return tok
+        value = unquote(tok.value) if first == last else self._text(first, last + 1)
+        name = Token("ident", value, tok.start, self.tokens[last].end)
+        name.line, name.col, name.end_line = tok.line, tok.col, self.tokens[last].end_line
+        return name
+
+    def _type_alias(self, start: int, pos: int, end: int, flags: Dict[str, bool], container: Optional[str]) -> int:
+        name_tok = self.tokens[pos + 1]
+        stmt_end = self._end(pos + 2, end)
+        self._symbol(name_tok, "type", start, stmt_end - 1, _clip(self._text(pos, stmt_end)), flags, container)
+        return self._skip_semi(stmt_end)
+
+    def _enum(self, start: int, pos: int, end: int, flags: Dict[str, bool], container: Optional[str]) -> int:
+        sig_start = pos
+        if self._v(pos) == "const":
+            pos += 1
+        name_tok = self.tokens[pos + 1] if self._is_name(pos + 1) else None
+        body = pos + 2
+        if name_tok is None or self._v(body) != "{":
+            return self._finish(body, end)
+        last = self._match(body)
+        members = []
+        idx = body + 1
+        while idx < last:
+            item_end = self._end(idx, last, (",",))
+            if self._kind(idx) in ("ident", "string"):
+                members.append(unquote(self._v(idx)))
+            idx = max(item_end, idx) + 1
+        extra: Dict[str, Any] = {"members": members}
+        if sig_start != pos:
+            extra["const"] = True
+        self._symbol(name_tok, "enum", start, last, self._text(sig_start, body), flags, container, **extra)
+        return last + 1
+
+    def _is_function(self, start: int, end: int) -> bool:
+        """Whether the expression tokens[start:end] is an arrow function or function expression."""
+        idx = start
+        if self._v(idx) == "async" and self._v(idx + 1) != "=>" and idx + 1 < end:
+            idx += 1
+        if self._v(idx) == "function":
+            return True
+        if self._is_name(idx):
+            return self._v(idx + 1) == "=>"
+        if self._v(idx) in ("(", "<"):
+            return self._v(self._params_and_return(idx, end, arrow=True)) == "=>"
+        return False
+
+    def _function_head(self, start: int, end: int) -> str:
+        """An arrow function up to its `=>`, or a function expression up to its body."""
+        idx = start + 1 if self._v(start) == "async" else start
+        if self._v(idx) == "function":
+            idx += 1
+            if self._v(idx) == "*":
+                idx += 1
+            if self._is_name(idx) and self._v(idx) != "(":
+                idx += 1
+            return self._text(start, self._params_and_return(idx, end))
+        if self._is_name(idx):
+            return self._text(start, idx + 2)
+        return self._text(start, self._params_and_return(idx, end, arrow=True) + 1)
+
+    def _variables(self, start: int, pos: int, end: int, flags: Dict[str, bool], container: Optional[str]) -> int:
+        keyword = self._v(pos)
+        idx = pos + 1
+        while idx < end:
+            if self._is_name(idx):
+                names = [self.tokens[idx]]
+                single = True
+                idx += 1
+            elif self._v(idx) in ("{", "["):
+                close = self._match(idx)
+                names = self._binding_names(idx + 1, close)
+                single = False
+                idx = close + 1
+            else:
+                break
+            if self._v(idx) == "!":
+                idx += 1
+            type_text = None
+            if self._v(idx) == ":":
+                type_end = self._end(idx + 1, end, (",", "="))
+                type_text = self._text(idx + 1, type_end)
+                idx = type_end
+            init = None
+            if self._v(idx) == "=":
+                init = (idx + 1, self._end(idx + 1, end, (",",)))
+                idx = init[1]
+            last = (init[1] if init else idx) - 1
+            for name_tok in names:
+                if single:
+                    self._variable(name_tok, keyword, type_text, init, start, last, flags, container)
+                else:
+                    kind = "constant" if keyword == "const" else "variable"
+                    self._symbol(name_tok, kind, start, last, f"{keyword} {name_tok.value}", flags, container,
+                                 destructured=True)
+            if self._v(idx) != ",":
+                break
+            idx += 1
+        return self._finish(idx, end)
+
+    def _variable(self, name_tok: Token, keyword: str, type_text: Optional[str], init: Optional[Tuple[int, int]],
+                  start: int, last: int, flags: Dict[str, bool], container: Optional[str]):
+        if init and self._is_function(*init):
+            signature = f"{keyword} {name_tok.value}{': ' + type_text if type_text else ''} = " \
+                        f"{self._function_head(*init)}"
+            head = init[0] + 1 if self._v(init[0]) == "async" else init[0]
+            extra: Dict[str, Any] = {} if self._v(head) == "function" else {"arrow": True}
+            if head != init[0]:
+                extra["async"] = True
+            self._symbol(name_tok, "function", start, last, signature, flags, container, **extra)
+            return
+        signature = f"{keyword} {name_tok.value}"
+        if type_text:
+            signature += f": {type_text}"
+        elif init:
+            signature += f" = {_clip(self._text(*init))}"
+        kind = "constant" if keyword == "const" else "variable"
+        self._symbol(name_tok, kind, start, last, signature, flags, container)
+
+    def _binding_names(self, start: int, end: int) -> List[Token]:
+        """Names bound by an object or array destructuring pattern in tokens[start:end]."""
+        names = []
+        idx = start
+        while idx < end:
+            item_end = self._end(idx, end, (",",))
+            element = idx + 1 if self._v(idx) == "..." else idx
+            # `key: target` binds the target; `name = default` binds name
+            if self._v(element + 1) == ":" and element + 1 < item_end:
+                element += 2
+            if self._v(element) in ("{", "["):
+                names.extend(self._binding_names(element + 1, self._match(element)))
+            elif self._is_name(element):
+                names.append(self.tokens[element])
+            idx = max(item_end, idx) + 1
+        return names
+
+    def _namespace(self, start: int, pos: int, end: int, flags: Dict[str, bool], container: Optional[str]) -> int:
+        name_start = pos + 1
+        body = name_start
+        while self._is_name(body) and self._v(body + 1) == ".":
+            body += 2
+        body += 1
+        if self._v(body) != "{":
+            return self._finish(body, end)
+        last = self._match(body)
+        name_tok = self._member_name(name_start, body - 1)
+        self._symbol(name_tok, "namespace", start, last, self._text(pos, body), flags, container)
+        self._statements(body + 1, last, name_tok.value)
+        return last + 1
+
+    # ------------------------------------------------------------------
+    # Imports and exports
+    # ------------------------------------------------------------------
+
+    def _specifiers(self, start: int, end: int) -> List[Dict[str, Any]]:
+        """`a`, `a as b` and `type a` entries of an import or export clause."""
+        names = []
+        idx = start
+        while idx < end:
+            item_end = self._end(idx, end, (",",))
+            words = [self.tokens[j] for j in range(idx, item_end)]
+            type_only = len(words) > 1 and words[0].value == "type" and words[1].value != "as"
+            if type_only:
+                words = words[1:]
+            if words:
+                imported = unquote(words[0].value)
+                local = unquote(words[2].value) if len(words) >= 3 and words[1].value == "as" else imported
+                spec = {"imported": imported, "local": local}
+                if type_only:
+                    spec["type_only"] = True
+                names.append(spec)
+            idx = max(item_end, idx) + 1
+        return names
+
+    def _import(self, pos: int, end: int) -> int:
+        tok = self.tokens[pos]
+        idx = pos + 1
+        record: Dict[str, Any] = {"source": None, "kind": "import", "default": None, "namespace": None,
+                                  "names": [], "type_only": False, "line": tok.line, "column": tok.col}
+        # `import type X from`, but not a default import named "type"
+        if self._v(idx) == "type" and (self._v(idx + 1) in ("{", "*") or
+                                       (self._is_name(idx + 1) and self._v(idx + 1) != "from") or
+                                       (self._v(idx + 1) == "from" and self._v(idx + 2) in ("from", "="))):
+            record["type_only"] = True
+            idx += 1
+        if self._kind(idx) == "string":
+            # A side-effect import
+            record["source"] = unquote(self._v(idx))
+            self.imports.append(record)
+            return self._finish(idx + 1, end)
+        if self._is_name(idx):
+            record["default"] = self._v(idx)
+            idx += 1
+            if self._v(idx) == "=":
+                # import x = require("y"), or an alias of a namespace member
+                if self._v(idx + 1) == "require" and self._kind(idx + 3) == "string":
+                    record.update(kind="require", source=unquote(self._v(idx + 3)))
+                    self._consumed.add(idx + 1)
+                    self.imports.append(record)
+                return self._skip_semi(self._end(idx + 1, end))
+            if self._v(idx) == ",":
+                idx += 1
+        if self._v(idx) == "*" and self._v(idx + 1) == "as":
+            record["namespace"] = self._v(idx + 2)
+            idx += 3
+        if self._v(idx) == "{":
+            close = self._match(idx)
+            record["names"] = self._specifiers(idx + 1, close)
+            idx = close + 1
+        if self._v(idx) == "from" and self._kind(idx + 1) == "string":
+            record["source"] = unquote(self._v(idx + 1))
+            self.imports.append(record)
+            idx += 2
+        return self._finish(idx, end)
+
+    def _export_clause(self, pos: int, end: int) -> int:
+        """`export { a, b as c } [from "x"]` and `export * [as ns] from "x"`."""
+        tok = self.tokens[pos]
+        idx = pos + 1
+        type_only = self._v(idx) == "type"
+        if type_only:
+            idx += 1
+        names: List[Dict[str, Any]] = []
+        star = None
+        if self._v(idx) == "*":
+            star = "*"
+            idx += 1
+            if self._v(idx) == "as":
+                star = unquote(self._v(idx + 1))
+                idx += 2
+        else:
+            close = self._match(idx)
+            names = self._specifiers(idx + 1, close)
+            idx = close + 1
+        source = None
+        if self._v(idx) == "from" and self._kind(idx + 1) == "string":
+            source = unquote(self._v(idx + 1))
+            idx += 2
+            self.imports.append({"source": source, "kind": "export", "default": None,
+                                 "namespace": star if star != "*" else None, "names": names,
+                                 "type_only": type_only, "line": tok.line, "column": tok.col})
+        if star:
+            self.exports.append({"name": star, "local": None, "source": source, "line": tok.line})
+        for spec in names:
+            entry = {"name": spec["local"], "local": spec["imported"], "line": tok.line}
+            if source:
+                entry["source"] = source
+            else:
+                self._exported_locals.setdefault(spec["imported"], spec["local"] == "default")
+            if type_only or spec.get("type_only"):
+                entry["type_only"] = True
+            self.exports.append(entry)
+        return self._finish(idx, end)
+
+    def _export_default(self, pos: int, end: int) -> int:
+        """`export default <expression>` (or `export =`): an identifier exports that local."""
+        stmt_end = self._end(pos, end)
+        local = self._v(pos) if stmt_end == pos + 1 and self._is_name(pos) else None
+        if local:
+            self._exported_locals[local] = True
+        self.exports.append({"name": "default", "local": local, "line": self.tokens[pos - 1].line})
+        return self._skip_semi(stmt_end)
+
+    def _commonjs(self, pos: int, end: int) -> int:
+        """`module.exports = ...`, `module.exports.x = ...` and `exports.x = ...`."""
+        stmt_end = self._end(pos, end)
+        idx = pos + 2
+        if self._v(pos) == "module":
+            if self._v(idx) != "exports":
+                return self._skip_semi(stmt_end)
+            idx += 1
+            if self._v(idx) == "=":
+                value = idx + 1
+                if self._v(value) == "{":
+                    self._commonjs_object(value + 1, self._match(value))
+                else:
+                    local = self._v(value) if stmt_end == value + 1 and self._is_name(value) else None
+                    if local:
+                        self._exported_locals.setdefault(local, True)
+                    self.exports.append({"name": "default", "local": local, "line": self.tokens[pos].line,
+                                         "commonjs": True})
+                return self._skip_semi(stmt_end)
+            idx += 1
+        if not (self._is_name(idx) and self._v(idx + 1) == "=" and self._v(idx - 1) == "."):
+            return self._skip_semi(stmt_end)
+        name_tok = self.tokens[idx]
+        value = idx + 2
+        local = self._v(value) if stmt_end == value + 1 and self._is_name(value) else None
+        self.exports.append({"name": name_tok.value, "local": local, "line": name_tok.line, "commonjs": True})
+        if local:
+            self._exported_locals.setdefault(local, False)
+        elif self._is_function(value, stmt_end):
+            # The assignment is the function's only declaration
+            signature = f"{self._text(pos, value)} {self._function_head(value, stmt_end)}"
+            self._symbol(name_tok, "function", pos, stmt_end - 1, signature,
+                         {"exported": True, "default": False}, None, listed=True)
+        return self._skip_semi(stmt_end)
+
+    def _commonjs_object(self, start: int, end: int):
+        """`module.exports = { a, b: c, d() {} }` exports a, b and d."""
+        idx = start
+        while idx < end:
+            item_end = self._end(idx, end, (",",))
+            if self._kind(idx) in ("ident", "string"):
+                name = unquote(self._v(idx))
+                local = None
+                if idx + 1 == item_end:
+                    local = name
+                elif self._v(idx + 1) == ":" and idx + 3 == item_end and self._is_name(idx + 2):
+                    local = self._v(idx + 2)
+                self.exports.append({"name": name, "local": local, "line": self.tokens[idx].line, "commonjs": True})
+                if local:
+                    self._exported_locals.setdefault(local, False)
+            idx = max(item_end, idx) + 1
+
+    def _calls(self):
+        """`require("x")` and `import("x")` calls anywhere in the file."""
+        for idx, tok in enumerate(self.tokens):
+            if tok.kind != "ident" or tok.value not in ("require", "import") or idx in self._consumed:
+                continue
+            if self._v(idx + 1) != "(" or self._kind(idx + 2) != "string" or self._v(idx + 3) not in (")", ","):
+                continue
+            if self._v(idx - 1) in (".", "?.", "function"):
+                continue
+            self.imports.append({"source": unquote(self._v(idx + 2)),
+                                 "kind": "require" if tok.value == "require" else "dynamic",
+                                 "default": None, "namespace": None, "names": [], "type_only": False,
+                                 "line": tok.line, "column": tok.col})
+
+
+def parse_ts_source(content: str, language: str = "typescript", jsx: bool = False) -> Dict[str, Any]:
+    """Parse TypeScript or JavaScript source text and return imports, exports, and symbols."""
+    return TsFileParser(content, language, jsx).parse()
@@ -0,0 +1,209 @@
+"""Watch a project tree and report debounced batches of changes.
+
+Uses watchdog (inotify, FSEvents, ReadDirectoryChangesW) when it is
+installed and falls back to polling file stamps otherwise. Bursts - an
+editor's save storm, a git checkout rewriting hundreds of files - are
+coalesced: a batch is delivered once events stop for DEBOUNCE seconds, or
+MAX_DELAY after its first event at the latest. A moved git HEAD marks the
+batch as a checkout so the consumer can refresh more broadly.
+"""
+
+import os
+import sys
+import threading
+import time
+from pathlib import Path
+from typing import Callable, Dict, Optional, Set, Tuple
+
+try:
+    from watchdog.events import FileSystemEventHandler
+    from watchdog.observers import Observer
+except ImportError:
+    Observer = None
+
+# Seconds without events before a batch is delivered
+DEBOUNCE = 0.5
+# Longest a batch is held back by a continuous stream of events
+MAX_DELAY = 5.0
+# Seconds between stamp scans (polling backend) and HEAD checks
+POLL_INTERVAL = 2.0
+
+
+def _git_dir(root: str) -> Optional[Path]:
+    """The .git directory of the repository containing root, following worktree links."""
+    current = Path(root).resolve()
+    for directory in [current, *current.parents]:
+        dot_git = directory / ".git"
+        if dot_git.is_dir():
+            return dot_git
+        if dot_git.is_file():
+            try:
+                text = dot_git.read_text(encoding="utf-8").strip()
+            except OSError:
+                return None
+            if text.startswith("gitdir:"):
+                return (directory / text[len("gitdir:"):].strip()).resolve()
+            return None
+    return None
+
+
+def git_head(root: str) -> Optional[str]:
+    """
+    What HEAD points at, read from the files rather than by running git:
+    the symbolic ref and the commit it resolves to. None outside a repo.
+    """
+    git_dir = _git_dir(root)
+    if git_dir is None:
+        return None
+    try:
+        head = (git_dir / "HEAD").read_text(encoding="utf-8").strip()
+    except OSError:
+        return None
+    if not head.startswith("ref:"):
+        return head
+    ref = head[len("ref:"):].strip()
+    # Linked worktrees keep branch refs in the main repository
+    common = git_dir
+    try:
+        common = (git_dir / (git_dir / "commondir").read_text(encoding="utf-8").strip()).resolve()
+    except OSError:
+        pass
+    try:
+        return f"{ref} {(common / ref).read_text(encoding='utf-8').strip()}"
+    except OSError:
+        pass
+    try:
+        for line in (common / "packed-refs").read_text(encoding="utf-8").splitlines():
+            if line.endswith(" " + ref):
+                return f"{ref} {line.split(' ', 1)[0]}"
+    except OSError:
+        pass
+    return ref
+
+
+class ProjectWatcher:
+    """
+    Watches one project root on background threads.
+
+    on_batch(paths, head_moved) is called from the watcher's own thread
+    with the absolute paths that changed since the previous batch. Only
+    paths accepted by relevant() are collected; the polling backend diffs
+    the {path: stamp} maps that snapshot() returns.
+    """
+
+    def __init__(
+        self,
+        root: str,
+        on_batch: Callable[[Set[str], bool], None],
+        relevant: Callable[[str], bool],
+        snapshot: Callable[[], Dict[str, Tuple]],
+        debounce: float = DEBOUNCE,
+        max_delay: float = MAX_DELAY,
+        poll_interval: float = POLL_INTERVAL,
+    ):
+        self.root = root
+        self.on_batch = on_batch
+        self.relevant = relevant
+        self.snapshot = snapshot
+        self.debounce = debounce
+        self.max_delay = max_delay
+        self.poll_interval = poll_interval
+        self.backend = "watchdog" if Observer is not None else "polling"
+        self.batches = 0
+        self._pending: Set[str] = set()
+        self._first_at: Optional[float] = None
+        self._last_at = 0.0
+        self._head = git_head(root)
+        self._head_moved = False
+        self._wake = threading.Condition()
+        self._stopped = threading.Event()
+        self._observer = None
+        self._threads = []
+
+    def start(self):
+        if self.backend == "watchdog":
+            watcher = self
+
+            class Handler(FileSystemEventHandler):
+                def on_any_event(self, event):
+                    for path in (getattr(event, "src_path", None), getattr(event, "dest_path", None)):
+                        if path:
+                            watcher._add(os.fsdecode(path))
+
+            self._observer = Observer()
+            self._observer.schedule(Handler(), self.root, recursive=True)
+            self._observer.start()
+        else:
+            self._threads.append(threading.Thread(target=self._poll, name=f"xray-poll {self.root}", daemon=True))
+        self._threads.append(threading.Thread(target=self._dispatch, name=f"xray-watch {self.root}", daemon=True))
+        for thread in self._threads:
+            thread.start()
+
+    def stop(self):
+        self._stopped.set()
+        with self._wake:
+            self._wake.notify_all()
+        if self._observer is not None:
+            self._observer.stop()
+            self._observer.join()
+        for thread in self._threads:
+            thread.join()
+
+    def _add(self, path: str):
+        if not self.relevant(path):
+            return
+        with self._wake:
+            now = time.monotonic()
+            self._pending.add(path)
+            if self._first_at is None:
+                self._first_at = now
+            self._last_at = now
+            self._wake.notify_all()
+
+    def _poll(self):
+        """Polling backend: diff stamp snapshots of the tree."""
+        stamps = self.snapshot()
+        while not self._stopped.wait(self.poll_interval):
+            try:
+                current = self.snapshot()
+            except Exception as e:
+                print(f"xray: watching {self.root}: {e}", file=sys.stderr)
+                continue
+            for path in set(stamps) | set(current):
+                if stamps.get(path) != current.get(path):
+                    self._add(path)
+            stamps = current
+
+    def _check_head(self):
+        head = git_head(self.root)
+        if head != self._head:
+            self._head = head
+            with self._wake:
+                self._head_moved = True
+                now = time.monotonic()
+                if self._first_at is None:
+                    self._first_at = now
+                self._last_at = now
+
+    def _dispatch(self):
+        next_head_check = time.monotonic() + self.poll_interval
+        while not self._stopped.is_set():
+            now = time.monotonic()
+            if now >= next_head_check:
+                self._check_head()
+                next_head_check = now + self.poll_interval
+            with self._wake:
+                if self._first_at is None:
+                    self._wake.wait(max(0.0, next_head_check - now))
+                    continue
+                due = min(self._last_at + self.debounce, self._first_at + self.max_delay)
+                if now < due:
+                    self._wake.wait(due - now)
+                    continue
+                paths, head_moved = self._pending, self._head_moved
+                self._pending, self._head_moved, self._first_at = set(), False, None
+            self.batches += 1
+            try:
+                self.on_batch(paths, head_moved)
+            except Exception as e:
+                print(f"xray: re-indexing {self.root} after changes: {e}", file=sys.stderr)
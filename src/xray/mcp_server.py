@@ -20,8 +20,8 @@ tree = explore_repo("/Users/john/myproject", focus_dirs=["src"], include_symbols
 # Now shows function signatures and docstrings in src/
 
 # Step 2: Find the specific function you need
-symbols = find_symbol("/Users/john/myproject", "validate user")
-# Returns list of matching symbols with exact locations
+symbols = find_symbol("/Users/john/myproject", "validate user")["symbols"]
+# Returns a page of matching symbols with exact locations
 
 # Step 3: See what would be affected if you change it
 impact = what_breaks(symbols[0])  # Pass the ENTIRE symbol object!
@@ -40,12 +40,44 @@ TIPS:
 - what_breaks does text search - review results to see which are actual code references
 """
 
+import argparse
+import asyncio
+import functools
+import inspect
+import json
 import os
-from typing import Dict, List, Any, Optional, Union
+import signal
+import sys
+import threading
+import time
+import typing
+import weakref
+from typing import Any, Callable, Dict, List, Optional, Set, Tuple, Union
 
-from fastmcp import FastMCP
+from fastmcp import Context, FastMCP
+from mcp import types
 
-from xray.core.indexer import XRayIndexer
+from xray.core.allowlist import AllowList
+from xray.core.blob_cache import DEFAULT_ENTRIES as HISTORY_CACHE_ENTRIES, DEFAULT_MB as HISTORY_CACHE_MB, BlobCache, \
+    cache_file
+from xray.core.cli import (EXIT_ERROR, EXIT_FINDINGS, EXIT_OK, ProgressPrinter, all_pages, findings, parse_command,
+                           parse_schema_command, render, tool_arguments)
+from xray.core.errors import BUDGET_EXCEEDED, DeadlineExceeded, FileNotFound, ProjectNotIndexed, XRayError, describe_error
+from xray.core.git_history import GitRepo
+from xray.core.http_transport import http_app, parse_listen, parse_origin, serve
+from xray.core.ignore import PathGlobs
+from xray.core.indexer import LANGUAGE_MAP, XRayIndexer
+from xray.core.languages import parse_mapping
+from xray.core.memory import MEGABYTE
+from xray.core.metrics import DEFAULT_SLOW_CALL_MS, ServerMetrics, mark_failed, process_memory, prometheus_text
+from xray.core.paging import DEFAULT_LIMIT, PageStore, estimate_tokens
+from xray.core.parse_pool import IndexingCancelled
+from xray.core.paths import canonical_case
+from xray.core.project_config import ProjectConfig, load_config
+from xray.core.resources import ProjectRegistry, outline_filters, parse_uri, resource_stamp, resource_uri
+from xray.core.schema import (ERROR_SCHEMA, SCHEMA_VERSION, compare, golden, golden_path, renamed_fields, tool_schema,
+                              with_deprecated)
+from xray.core.watcher import ProjectWatcher
 
 # Initialize FastMCP server
 mcp = FastMCP("XRAY Code Intelligence")
@@ -53,35 +85,373 @@ mcp = FastMCP("XRAY Code Intelligence")
 # Cache for indexer instances per repository path
 _indexer_cache: Dict[str, XRayIndexer] = {}
 
+# Full results of listing tools, kept so later pages don't re-run the query
+_pages = PageStore()
+
+# Stable project names for xray:// resource URIs, kept across restarts
+_projects = ProjectRegistry()
+
+# Configuration files of project roots, for include_generated left out: root -> config
+_configs: Dict[str, ProjectConfig] = {}
+
+# Projects added with add_project, per client session: session -> {name -> root}.
+# Over stdio there is one session; over HTTP every client has its own.
+_session_projects: "weakref.WeakKeyDictionary" = weakref.WeakKeyDictionary()
+# Projects added outside any client request
+_default_projects: Dict[str, str] = {}
+# Default include/exclude globs set with add_project, per client session: session -> {root -> globs}
+_session_globs: "weakref.WeakKeyDictionary" = weakref.WeakKeyDictionary()
+_default_globs: Dict[str, Dict[str, List[str]]] = {}
+
+# Watch mode (--watch / XRAY_WATCH): re-index projects as they change on disk
+_watch_enabled = False
+# project root -> watcher, started with the project's first working-tree indexer
+_watchers: Dict[str, ProjectWatcher] = {}
+# Client sessions seen so far, told when the resource list changes
+_sessions: "weakref.WeakSet" = weakref.WeakSet()
+
+# Directories tools may touch (--allow-dir / XRAY_ALLOW_DIRS); empty allows any
+_allowlist = AllowList()
+
+# Index git submodules as nested sub-projects (--submodules / XRAY_SUBMODULES)
+_include_submodules = True
+
+# Fetch blobs a partial clone left out when history tools need them (--fetch-missing / XRAY_FETCH_MISSING)
+_fetch_missing = False
+
+# File names and extensions -> languages (--language-map / XRAY_LANGUAGE_MAP); a project's
+# "extensions" in .xray.yaml win over it
+_language_map: Dict[str, str] = {}
+
+# Parses of old file versions, shared by the history tools of every project (see core/blob_cache.py);
+# bounded by --history-cache-entries / XRAY_HISTORY_CACHE_ENTRIES and --history-cache-mb / XRAY_HISTORY_CACHE_MB
+_blob_cache = BlobCache(path=cache_file())
+
+# Quick index (--quick-index / XRAY_QUICK_INDEX): answer from declaration skeletons at first,
+# parsing packages in full on a background thread; add_project sets it per project
+_quick_index = False
+_quick_projects: Set[str] = set()
+# Seconds a background deep-indexing thread leaves the project lock free between two steps
+DEEP_INDEX_PAUSE = 0.05
+# Set at shutdown: background deep indexing stops after its current step
+_deep_indexing_stop = threading.Event()
+
+# Calls and latency of every tool (server_stats, /metrics); calls slower than
+# --slow-call-ms / XRAY_SLOW_CALL_MS are logged to stderr
+_metrics = ServerMetrics()
+
 
 def normalize_path(path: str) -> str:
-    """Normalize a path to absolute form."""
+    """Normalize a path to absolute form, refusing one outside the allowed directories."""
     path = os.path.expanduser(path)
     path = os.path.abspath(path)
-    path = str(Path(path).resolve())
+    # Checked before existence, so paths outside do not reveal what is there
+    path = _allowlist.check(path)
     if not os.path.exists(path):
-        raise ValueError(f"Path '{path}' does not exist")
+        raise FileNotFound(f"Path '{path}' does not exist", path)
     if not os.path.isdir(path):
-        raise ValueError(f"Path '{path}' is not a directory")
-    return path
+        raise XRayError(f"Path '{path}' is not a directory", path)
+    # One indexer per project, whatever casing a case-insensitive filesystem was given
+    return str(canonical_case(path))
+
+
+def _current_session():
+    """The client session of the request being handled, None outside one."""
+    try:
+        return mcp._mcp_server.request_context.session
+    except (LookupError, AttributeError):
+        return None
+
+
+def _added() -> Dict[str, str]:
+    """The projects added in the current client session: name -> root."""
+    session = _current_session()
+    if session is None:
+        return _default_projects
+    return _session_projects.setdefault(session, {})
+
+
+def _project_globs() -> Dict[str, Dict[str, List[str]]]:
+    """The default globs of the current client session's projects: root -> {"include", "exclude"}."""
+    session = _current_session()
+    if session is None:
+        return _default_globs
+    return _session_globs.setdefault(session, {})
+
+
+def _globs(indexer: XRayIndexer, include: Optional[List[str]],
+           exclude: Optional[List[str]]) -> Tuple[Optional[List[str]], Optional[List[str]]]:
+    """A call's include and exclude globs, each left out falling back to its project's default."""
+    defaults = _project_globs().get(str(indexer.source_root), {})
+    return (defaults.get("include") if include is None else include,
+            defaults.get("exclude") if exclude is None else exclude)
+
+
+def _added_anywhere(root: str) -> bool:
+    """Whether any client session still has a project root added."""
+    return any(root in projects.values()
+               for projects in [_default_projects, *list(_session_projects.values())])
+
+
+def resolve_root(path: Optional[str], project: Optional[str] = None) -> str:
+    """
+    The project root a tool call is about: path if given, else the root of
+    the named project - or, with neither, of the only project added.
+    """
+    if path:
+        return normalize_path(path)
+    if project:
+        root = _added().get(project) or _projects.root_for(project)
+        if root is None:
+            raise ProjectNotIndexed(f"Unknown project '{project}' - add it with add_project or pass root_path")
+        return normalize_path(root)
+    added = _added()
+    if len(added) == 1:
+        return next(iter(added.values()))
+    if not added:
+        raise ProjectNotIndexed("No root_path given and no project added - pass root_path or call add_project first")
+    raise ProjectNotIndexed(f"{len(added)} projects are added ({', '.join(sorted(added))}) - pass project to pick one")
+
+
+def get_indexer(path: Optional[str], ref: Optional[str] = None, include_generated: Optional[bool] = None,
+                project: Optional[str] = None) -> XRayIndexer:
+    """
+    Get or create indexer instance for the given path (or added project), optionally at a git ref.
+    include_generated left out (None) takes the project's .xray.yaml setting.
+    """
+    path = resolve_root(path, project)
+    if include_generated is None:
+        _configs[path] = load_config(Path(path), sorted(set(LANGUAGE_MAP.values())), _configs.get(path))
+        # An invalid file fails the call once the indexer reads it
+        include_generated = bool(_configs[path].get("include_generated", False))
+    key = path
+    if ref:
+        # Key by commit so a moved branch gets a fresh snapshot
+        key = f"{path}@{GitRepo(path).resolve(ref)}"
+    if include_generated:
+        key += "+generated"
+    if key not in _indexer_cache:
+        quick = _quick_index or path in _quick_projects
+        _indexer_cache[key] = XRayIndexer(path, ref, include_generated, _allowlist, _include_submodules, _fetch_missing,
+                                          quick, _blob_cache, _language_map)
+        if not ref:
+            _projects.name_for(path)
+            if _watch_enabled and not include_generated:
+                _start_watcher(_indexer_cache[key])
+        if quick:
+            _start_deep_indexing(_indexer_cache[key])
+    return _indexer_cache[key]
+
+
+def _start_deep_indexing(indexer: XRayIndexer):
+    """
+    Build a quick index's shallow index, then parse its packages in full,
+    one deepen step at a time under the project lock, so tool calls get
+    their turn in between. Stops once the index is deep, the project is
+    removed or the server shuts down.
+    """
+    def run():
+        while not _deep_indexing_stop.is_set() and any(i is indexer for i in _indexer_cache.values()):
+            try:
+                with _index_lock(indexer), indexer.tracking():
+                    if not indexer.deepen():
+                        return
+            except Exception as e:
+                print(f"xray: deep indexing of {indexer.source_root} stopped: {e}", file=sys.stderr, flush=True)
+                return
+            _deep_indexing_stop.wait(DEEP_INDEX_PAUSE)
+
+    threading.Thread(target=run, name=f"xray-deep-index {indexer.source_root}", daemon=True).start()
+
+
+# Upper bound on a tool's timeout_ms: half an hour
+MAX_TIMEOUT_MS = 30 * 60 * 1000
+
+# Tool calls on a project share its indexers; run them one at a time, as when they
+# blocked the event loop. Each project has its own lock, so projects index side by side.
+# The lock is exclusive rather than shared by readers: queries fill lazy caches, and
+# parsing and graph walks hold the GIL anyway. Results are presented under it too, so
+# a call never reads an entry the watcher is replacing.
+_index_locks: Dict[str, threading.Lock] = {}
+_index_locks_guard = threading.Lock()
+
+
+def _index_lock(indexer: XRayIndexer) -> threading.Lock:
+    """The lock of the project an indexer (of the working tree or of a ref) belongs to."""
+    with _index_locks_guard:
+        return _index_locks.setdefault(str(indexer.source_root), threading.Lock())
+
+
+def _progress_sender(ctx: Context, loop: asyncio.AbstractEventLoop) -> Callable[[Dict[str, Any]], None]:
+    """
+    Turn the indexer's progress events into MCP progress notifications.
+
+    Notifications are only sent when the client asked for them with a
+    progress token. Each phase (scanning, parsing, call graph...) counts
+    from zero, so phases are laid end to end to keep progress increasing.
+    """
+    state = {"phase": None, "base": 0, "last": 0}
+
+    def send(event: Dict[str, Any]):
+        if event["phase"] != state["phase"]:
+            state["base"] += state["last"]
+            state["phase"] = event["phase"]
+        state["last"] = event["total"] or event["done"]
+        total = event["total"]
+        message = f"{event['phase']} {event['done']}" + (f"/{total}" if total is not None else "")
+        if event["current"]:
+            message += f": {event['current']}"
+        progress = state["base"] + event["done"]
+        total = state["base"] + total if total is not None else None
+        try:
+            report = ctx.report_progress(progress, total, message)
+        except TypeError:
+            # FastMCP releases before progress messages
+            report = ctx.report_progress(progress, total)
+        asyncio.run_coroutine_threadsafe(report, loop)
+
+    return send
+
+
+def _with_warnings(result: Any, warnings: List[Dict[str, Any]], field: Optional[str] = None) -> Any:
+    """
+    Attach the indexer's warnings (files it could not index) to a result,
+    a list one becoming {field: result}. SARIF logs stay as they are.
+    """
+    if not warnings:
+        return result
+    if isinstance(result, list) and field:
+        result = {field: result}
+    if isinstance(result, dict) and "$schema" not in result:
+        result = {**result, "warnings": [*result.get("warnings", []), *warnings]}
+    return result
+
+
+def _versioned(result: Any) -> Any:
+    """
+    A tool's dict result with the "schemaVersion" its shape follows, and
+    until 1.3 its old "schema_version" too; SARIF logs stay as they are.
+    """
+    if isinstance(result, dict) and "$schema" not in result:
+        return {**result, "schemaVersion": SCHEMA_VERSION, "schema_version": SCHEMA_VERSION}
+    return result
+
+
+def _tool(func: Callable[..., Any]) -> Any:
+    """
+    Register a tool with the server. A tool that renamed result fields (see
+    xray.core.schema.DEPRECATED_FIELDS) returns them under their old names
+    too, however it is called - over MCP, in a batch, from the command line;
+    the others' results are left as they are.
+    """
+    name = func.__name__
+    if not renamed_fields(name):
+        return mcp.tool(func)
+
+    @functools.wraps(func)
+    async def call(*args, **kwargs):
+        result = await func(*args, **kwargs)
+        return with_deprecated(name, result) if isinstance(result, dict) and "$schema" not in result else result
+
+    return mcp.tool(call)
+
 
+def _failed(result: Any) -> bool:
+    """Whether a tool's result is an _error one."""
+    return isinstance(result, dict) and set(result) - {"schemaVersion", "schema_version"} == {"error"}
 
-def get_indexer(path: str) -> XRayIndexer:
-    """Get or create indexer instance for the given path."""
-    path = normalize_path(path)
-    if path not in _indexer_cache:
-        _indexer_cache[path] = XRayIndexer(path)
-    return _indexer_cache[path]
 
+def _error(action: str, e: Exception) -> Dict[str, Any]:
+    """A tool's failure as {"error": {"code", "message", "path", "ref"}} (see xray.core.errors)."""
+    error = describe_error(e, action)
+    mark_failed(error["code"])
+    return _versioned({"error": error})
 
-@mcp.tool
-def explore_repo(
-    root_path: str, 
+
+async def _run(indexer: XRayIndexer, func: Callable[..., Any], *args,
+               ctx: Optional[Context] = None, listing: Optional[str] = None,
+               timeout_ms: Optional[int] = None, present: bool = False, **kwargs) -> Any:
+    """
+    Run an indexer call on a worker thread so the event loop stays free.
+
+    With a ctx, long phases stream throttled progress notifications. If the
+    client cancels the request, or timeout_ms (at most MAX_TIMEOUT_MS) runs
+    out, the indexer stops at its next checkpoint - killing a git or rg
+    command in progress - and discards the partial work, so the index is as
+    it was before the call. Files the index is missing are listed under the
+    result's "warnings" - a list result is first wrapped as {listing: result}.
+    With present, the result goes through indexer.present (ranges, symbol
+    IDs) before the lock is released; SARIF callers pass False.
+    """
+    progress = _progress_sender(ctx, asyncio.get_running_loop()) if ctx is not None else None
+    if ctx is not None:
+        _remember_session(getattr(ctx, "session", None))
+    cancelled = threading.Event()
+    running = threading.Event()
+
+    def call():
+        with _index_lock(indexer), indexer.tracking(progress):
+            # Cancelled while waiting for the lock
+            if cancelled.is_set():
+                raise IndexingCancelled("request was cancelled")
+            running.set()
+            try:
+                result = _with_warnings(func(*args, **kwargs), indexer.warnings(), listing)
+                return _versioned(indexer.present(result) if present else result)
+            finally:
+                running.clear()
+
+    def stop():
+        cancelled.set()
+        # Only the call still running is ours; one queued behind the lock just gives up
+        if running.is_set():
+            indexer.cancel()
+
+    timeout = min(max(timeout_ms, 1), MAX_TIMEOUT_MS) / 1000 if timeout_ms is not None else None
+    try:
+        return await asyncio.wait_for(asyncio.to_thread(call), timeout)
+    except asyncio.TimeoutError:
+        stop()
+        raise DeadlineExceeded(f"Timed out after {round(timeout * 1000)} ms; the partial work was discarded")
+    except asyncio.CancelledError:
+        stop()
+        raise
+
+
+async def _paged(indexer: XRayIndexer, field: str, limit: int, cursor: Optional[str],
+                 max_tokens: Optional[int], func: Callable[..., Any], *args,
+                 ctx: Optional[Context] = None, timeout_ms: Optional[int] = None) -> Dict[str, Any]:
+    """
+    Serve one page of a listing tool's result[field].
+
+    Without a cursor the query runs and its full result is stored; a cursor
+    returns a later page of that stored result, so paging through thousands
+    of entries runs the query once. Cursors are bound to the call's
+    arguments and cannot be replayed against a different query.
+    """
+    key = json.dumps([func.__name__, str(indexer.source_root), indexer.ref_commit,
+                      indexer.include_generated, args], default=str, sort_keys=True)
+    if cursor:
+        return _versioned(_pages.next_page(key, cursor, field, limit, max_tokens))
+    result = await _run(indexer, func, *args, ctx=ctx, listing=field, timeout_ms=timeout_ms, present=True)
+    if isinstance(result, list):
+        result = {field: result}
+    return _versioned(_pages.first_page(key, result, field, limit, max_tokens))
+
+
+@_tool
+async def explore_repo(
+    root_path: Optional[str] = None,
     max_depth: Optional[Union[int, str]] = None,
     include_symbols: Union[bool, str] = False,
     focus_dirs: Optional[List[str]] = None,
-    max_symbols_per_file: Union[int, str] = 5
-) -> str:
+    max_symbols_per_file: Union[int, str] = 5,
+    ref: Optional[str] = None,
+    include_generated: Optional[bool] = None,
+    timeout_ms: Optional[int] = None,
+    project: Optional[str] = None,
+    ctx: Optional[Context] = None
+) -> Union[str, Dict[str, Any]]:
     """
     🗺️ STEP 1: Map the codebase structure - start simple, then zoom in!
     
@@ -93,10 +463,14 @@ def explore_repo(
     INPUTS:
     - root_path: The ABSOLUTE path to the project (e.g., "/Users/john/myproject")
                  NOT relative paths like "./myproject" or "~/myproject"
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
     - max_depth: How deep to traverse directories (None = unlimited, accepts int or string)
     - include_symbols: Show function/class signatures with docs (False = dirs only, accepts bool or string)
     - focus_dirs: List of top-level directories to focus on (e.g., ["src", "lib"])
     - max_symbols_per_file: Max symbols to show per file when include_symbols=True (accepts int or string)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
     
     EXAMPLE 1 - Initial exploration (directory only):
     explore_repo("/Users/john/project")
@@ -142,146 +516,6485 @@ def explore_repo(
         if isinstance(include_symbols, str):
             include_symbols = include_symbols.lower() in ('true', '1', 'yes')
             
-        indexer = get_indexer(root_path)
-        tree = indexer.explore_repo(
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(
+            indexer,
+            indexer.explore_repo,
             max_depth=max_depth,
             include_symbols=include_symbols,
             focus_dirs=focus_dirs,
-            max_symbols_per_file=max_symbols_per_file
+            max_symbols_per_file=max_symbols_per_file,
+            ctx=ctx,
+            timeout_ms=timeout_ms,
+            present=True
         )
-        return tree
     except Exception as e:
-        return f"Error exploring repository: {str(e)}"
+        return _error("Error exploring repository", e)
 
 
-@mcp.tool
-def find_symbol(root_path: str, query: str) -> List[Dict[str, Any]]:
+@_tool
+async def project_overview(root_path: Optional[str] = None, max_items: int = 20, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
     """
-    🔍 STEP 2: Find specific functions, classes, or methods in the codebase.
-    
-    USE THIS AFTER explore_repo() when you need to locate a specific piece of code.
-    Uses fuzzy matching - you don't need the exact name!
-    
+    🧭 One-call orientation: languages, packages, dependencies, entry points and the biggest code.
+
+    USE THIS FIRST on an unfamiliar Go project. It is built from the index
+    (no extra tree walk) and cached until indexed content changes, so it is
+    cheap to call again. Each package carries a "description": its package
+    comment (doc.go first; different comments of several files joined, each
+    followed by its file), else the first paragraph of its README.md, cut to
+    description_length (.xray.yaml, default 200) characters; "" with neither.
+
     INPUTS:
-    - root_path: Same ABSOLUTE path used in explore_repo
-    - query: What you're looking for (fuzzy search works!)
-             Examples: "auth", "user service", "validate", "parseJSON"
-    
-    EXAMPLE INPUTS:
-    find_symbol("/Users/john/awesome-project", "authenticate")
-    find_symbol("/Users/john/awesome-project", "user model")  # Fuzzy matches "UserModel"
-    
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - max_items: Maximum entries per list - packages, routes, largest files/functions (default 20)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
     EXAMPLE OUTPUT:
-    [
-        {
-            "name": "authenticate_user",
-            "type": "function",
-            "path": "/Users/john/awesome-project/src/auth.py",
-            "start_line": 45,
-            "end_line": 67
+    {
+        "files": 212, "lines": 48210,
+        "languages": {"go": {"files": 198, "lines": 45102}, "python": {"files": 14, "lines": 3108}},
+        "package_count": 31,
+        "packages": [{"dir": "internal/store", "name": "store", "import_path": "github.com/acme/api/internal/store",
+                      "files": 12, "symbols": 140, "exported": 61,
+                      "description": "Package store persists users in Postgres.", "description_from": ["doc.go"]}],
+        "module": {"path": "github.com/acme/api", "go": "1.22"},
+        "modules": [{"path": "github.com/acme/api", "dir": ".", "go": "1.22", "kind": "main", "go_mod": "go.mod"}],
+        "dependencies": [{"path": "github.com/go-chi/chi/v5", "version": "v5.0.12", "indirect": false}],
+        "replacements": [],
+        "entry_points": {
+            "main_packages": [{"dir": "cmd/api", "path": ".../cmd/api/main.go", "line": 12}],
+            "route_count": 24,
+            "routes": [{"method": "GET", "route": "/users/{id}", "handler": "getUser", "path": ".../routes.go", "line": 30}]
         },
-        {
-            "name": "AuthService",
-            "type": "class", 
-            "path": "/Users/john/awesome-project/src/services.py",
-            "start_line": 12,
-            "end_line": 89
-        }
-    ]
-    
-    RETURNS:
-    List of symbol objects (dictionaries). Save these objects - you'll pass them to what_breaks()!
-    Empty list if no matches found.
-    
-    WHAT TO DO NEXT:
-    Pick a symbol from the results and pass THE ENTIRE SYMBOL OBJECT to what_breaks() 
-    to see where it's used in the codebase.
+        "largest_files": [{"path": ".../internal/store/users.go", "language": "go", "lines": 1210}],
+        "largest_functions": [{"name": "Server.routes", "path": ".../server.go", "start_line": 40, "lines": 180}],
+        "git": {"available": true, "root": "/Users/john/monorepo", "commit": "9fceb02d...", "branch": "main",
+                "detached": false, "shallow": false, "subdirectory": "services/api"},
+        "index": {"indexed_at": "2024-05-01T09:30:12Z", "head": "9fceb02d...", "ref": null,
+                  "include_generated": false, "skipped": [{"reason": "default: vendor", "count": 812}],
+                  "cache_file": "..."}
+    }
+
+    "modules" lists every go.mod: the root's is "main"; with a go.work at
+    the root, the modules it uses are "workspace" and the tree's other
+    go.mod files "separate" (without one, every go.mod but the root's is).
+    Packages get the "import_path" of their module plus their directory;
+    "workspace" describes the go.work. Local replace directives are shown
+    on their module and followed when resolving imports.
+
+    "git" tells whether history tools can work here. "subdirectory" is
+    where the project sits in a larger repository. Outside any repository
+    (an exported tarball, a build sandbox) it reads
+    {"available": false, "reason": "..."}: indexing, search, references
+    and call graphs work all the same, and the history tools (blame_symbol,
+    symbol_history, hotspots, coupling, ownership, diff_symbols,
+    compare_refs, api_diff, diff_impact, ref) fail with the code GIT_UNAVAILABLE.
     """
     try:
-        indexer = get_indexer(root_path)
-        results = indexer.find_symbol(query)
-        return results
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.project_overview, max_items, ctx=ctx, timeout_ms=timeout_ms, present=True)
     except Exception as e:
-        return [{"error": f"Error finding symbol: {str(e)}"}]
+        return _error("Error building project overview", e)
 
 
-@mcp.tool  
-def what_breaks(exact_symbol: Dict[str, Any]) -> Dict[str, Any]:
+@_tool
+async def index_summary(root_path: Optional[str] = None, include_generated: Optional[bool] = None, max_paths: int = 20, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
     """
-    💥 STEP 3: See what code might break if you change this symbol.
-    
-    USE THIS AFTER find_symbol() to understand the impact of changing a function/class.
-    Shows you every place in the codebase where this symbol name appears.
-    
-    INPUT:
-    - exact_symbol: Pass THE ENTIRE SYMBOL OBJECT from find_symbol(), not just the name!
-                   Must be a dictionary with AT LEAST 'name' and 'path' keys.
-    
-    EXAMPLE INPUT:
-    # First, get a symbol from find_symbol():
-    symbols = find_symbol("/Users/john/project", "authenticate")
-    symbol = symbols[0]  # Pick the first result
-    
-    # Then pass THE WHOLE SYMBOL OBJECT:
-    what_breaks(symbol)
-    # or directly:
-    what_breaks({
-        "name": "authenticate_user",
-        "type": "function",
-        "path": "/Users/john/project/src/auth.py",
-        "start_line": 45,
-        "end_line": 67
-    })
-    
+    🧾 See which files XRAY indexes - and why the others are skipped.
+
+    USE THIS when a file you expected is missing from results. Files are
+    skipped by the default exclusions (node_modules, vendor, testdata, build
+    output...), by .gitignore rules (nested .gitignore files, .git/info/exclude
+    and the global excludes file), because they are generated Go code, or
+    because they are binary (a NUL byte in their first 8000 bytes) despite a
+    source file name. Source files larger than partial_file_size (.xray.yaml,
+    default 2MB) are indexed for their declarations only, listed under
+    "partial"; tool results mark locations in them "partial": true, as calls,
+    references and other facts from inside their bodies are missing. Files
+    that are not valid UTF-8 are read as UTF-16 (with a byte order mark) or
+    Latin-1 and listed under "transcoded"; locations in them carry
+    "transcodedFrom".
+    Symlinks are not followed: one leading to a file or directory inside the
+    project is listed under "aliases" and its target indexed once, under its
+    own path (a path through the link reaches the same symbols); one leading
+    outside the project, nowhere or in a loop is skipped.
+    Git submodules are indexed as nested sub-projects unless the server runs
+    with --no-submodules; uninitialized ones (empty directories) are listed
+    under "submodules" with initialized: false and skipped, not an error.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - include_generated: Index generated Go files instead of skipping them (default: .xray.yaml, else false)
+    - max_paths: Maximum example paths listed per skip reason (default 20)
+
     EXAMPLE OUTPUT:
     {
-        "references": [
-            {
-                "file": "/Users/john/project/src/api.py",
-                "line": 23,
-                "text": "    user = authenticate_user(username, password)"
-            },
-            {
-                "file": "/Users/john/project/tests/test_auth.py", 
-                "line": 45,
-                "text": "def test_authenticate_user():"
-            }
+        "root": "/Users/john/project",
+        "files_indexed": 412,
+        "by_language": {"go": 398, "python": 14},
+        "include_generated": false,
+        "include_submodules": true,
+        "skipped": [
+            {"reason": "default: vendor", "count": 1, "paths": ["vendor/"]},
+            {"reason": "gitignore: .gitignore:3: /bin", "count": 1, "paths": ["bin/"]},
+            {"reason": "generated", "count": 2, "paths": ["api/service.pb.go", "api/service_grpc.pb.go"]},
+            {"reason": "binary", "count": 1, "paths": ["assets/logo.go"]},
+            {"reason": "symlink: outside project", "count": 1, "paths": ["docs/"]},
+            {"reason": "submodule: not initialized", "count": 1, "paths": ["third_party/proto/"]}
         ],
-        "total_count": 2,
-        "note": "Found 2 potential references based on a text search for the name 'authenticate_user'. This may include comments, strings, or other unrelated symbols."
+        "partial": {"partial_file_size": 2000000, "count": 1, "paths": ["internal/bindata/bindata.go"]},
+        "transcoded": {"count": 1, "paths": [{"path": "legacy/menu.go", "transcodedFrom": "latin-1"}]},
+        "aliases": {"count": 1, "paths": [{"path": "pkg/compat", "target": "internal/compat"}]},
+        "submodules": [
+            {"name": "libs/shared", "path": "libs/shared", "url": "git@github.com:acme/shared.git",
+             "initialized": true, "files_indexed": 57},
+            {"name": "third_party/proto", "path": "third_party/proto", "url": "https://github.com/acme/proto.git",
+             "initialized": false, "files_indexed": 0}
+        ]
     }
-    
-    ⚠️ IMPORTANT: This does a text search for the name, so it might find:
-    - Actual function calls (what you want!)
-    - Comments mentioning the function
-    - Other functions/variables with the same name
-    - Strings containing the name
-    
-    Review each reference to determine if it's actually affected.
+
+    Symbols from a submodule carry "repository": its path; blame_symbol,
+    symbol_history, hotspots, coupling and ownership read its own history.
     """
     try:
-        # Extract root path from the symbol's path
-        symbol_path = Path(exact_symbol['path'])
-        root_path = str(symbol_path.parent)
-        
-        # Find a suitable root (go up until we find a git repo or reach root)
-        while root_path != '/':
-            if (Path(root_path) / '.git').exists():
-                break
-            parent = Path(root_path).parent
-            if parent == Path(root_path):
-                break
-            root_path = str(parent)
-        
-        indexer = get_indexer(root_path)
-        return indexer.what_breaks(exact_symbol)
+        indexer = get_indexer(root_path, include_generated=include_generated, project=project)
+        return await _run(indexer, indexer.index_summary, max_paths, ctx=ctx, timeout_ms=timeout_ms)
     except Exception as e:
-        return {"error": f"Error finding references: {str(e)}"}
+        return _error("Error summarizing index", e)
 
 
-def main():
-    """Main entry point for the XRAY MCP server."""
-    mcp.run()
+@_tool
+async def show_config(root_path: Optional[str] = None, path: Optional[str] = None, include_generated: Optional[bool] = None, include_tests: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    ⚙️ Show the project's .xray.yaml settings and the configuration in effect.
+
+    USE THIS when results leave out files you expected, or to check a
+    configuration file after editing it. A .xray.yaml (or .xray.yml,
+    .xray.json) at the project root sets, for everyone analyzing the
+    repository: exclude (globs), include_generated, include_tests,
+    max_file_size ("512KB", "2MB" or bytes), partial_file_size (above which
+    files are indexed for their declarations only), languages ({rust: false}),
+    generated_headers (regular expressions matched against leading comment
+    lines), description_length (characters of package descriptions) and
+    max_memory_mb (see index_stats). A parameter passed to a tool wins over the file, the file over the
+    built-in default. A file with an unknown key or a malformed value makes
+    the other tools fail with INVALID_CONFIG naming it; this one lists the
+    errors instead.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: Optional file or directory to explain: whether it is indexed, and the rule leaving it out
+    - include_generated, include_tests: Values a tool call would pass, to see them win over the file
+
+    EXAMPLE OUTPUT:
+    {
+        "config_file": "/Users/john/project/.xray.yaml",
+        "valid": true,
+        "errors": [],
+        "file": {"exclude": ["docs/**"], "max_file_size": 524288, "languages": {"rust": false}},
+        "effective": {"exclude": ["docs/**"], "include_generated": false, "include_tests": true,
+                      "max_file_size": 524288, "partial_file_size": 2000000, "languages": {"go": true, "python": true, "rust": false, ...},
+                      "generated_headers": [], "description_length": 200, "max_memory_mb": null},
+        "sources": {"exclude": "file", "include_generated": "default", "include_tests": "default",
+                    "max_file_size": "file", "partial_file_size": "default", "languages": "file", "generated_headers": "default",
+                    "description_length": "default", "max_memory_mb": "default"},
+        "path": {"path": "docs/gen/api.go", "indexed": false, "reason": "config: exclude docs/**",
+                 "excluded_at": "docs"}
+    }
+
+    An invalid file reports, e.g., "errors": ["unknown key 'exlude' - did you mean 'exclude'?"].
+    """
+    try:
+        indexer = get_indexer(root_path, include_generated=include_generated, project=project)
+        overrides = {"include_generated": include_generated, "include_tests": include_tests}
+        return await _run(indexer, indexer.show_config, path, overrides, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error showing configuration", e)
+
+
+@_tool
+async def reindex(root_path: Optional[str] = None, force: bool = False, concurrency: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🔄 Bring the Go index up to date - normally automatic, this forces or reports it.
+
+    Every Go-aware tool refreshes the index incrementally before answering:
+    files are re-parsed only when their mtime/size and content hash change,
+    and the call graph is patched for the packages affected. Large batches
+    are parsed by a pool of worker processes, stopped promptly if the
+    request is cancelled. Use force=True as an escape hatch to drop all
+    cached parse results and rebuild.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - force: Discard the cache and rebuild from scratch (default false)
+    - concurrency: Parser worker processes for this and later runs (default:
+                   XRAY_CONCURRENCY, else one per CPU)
+
+    EXAMPLE OUTPUT:
+    {
+        "forced": false,
+        "files_indexed": 4012,
+        "added": 0,
+        "modified": ["/Users/john/project/api/users.go"],
+        "removed": [],
+        "duration_ms": 212.4,
+        "concurrency": 8
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, project=project)
+        return await _run(indexer, indexer.reindex, force, concurrency, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error reindexing", e)
+
+
+@_tool
+async def cache_status(root_path: Optional[str] = None, project: Optional[str] = None) -> Dict[str, Any]:
+    """
+    💾 Show the persisted index: where it lives, its size, and cache hits vs misses.
+
+    The index is saved under the user cache directory (XRAY_CACHE_DIR
+    overrides it), keyed by project path and HEAD commit, and reloaded on
+    server start. Files are re-validated by mtime/size and content hash, so
+    hits are files served without re-parsing and misses are files parsed.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+
+    EXAMPLE OUTPUT:
+    {
+        "cache_file": "/Users/john/.cache/xray/projects/3f2a9c01d4e5b6a7/9fceb02.../index.bin",
+        "exists": true,
+        "size_bytes": 618342,
+        "commit": "9fceb02d0ae598e95dc970b74767f19372d61af8",
+        "loaded_from": "/Users/john/.cache/xray/projects/3f2a9c01d4e5b6a7/9fceb02.../index.bin",
+        "load_error": null,
+        "files_in_index": 4012,
+        "persisted_files": 4012,
+        "hits": 4009,
+        "misses": 3
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, project=project)
+        return await _run(indexer, indexer.cache_status)
+    except Exception as e:
+        return _error("Error reading cache status", e)
+
+
+@_tool
+async def index_stats(root_path: Optional[str] = None, max_packages: int = 20, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📊 Count the symbols and references in the index and estimate the memory it takes.
+
+    USE THIS to see which packages make a large project's index big, or to
+    pick a max_memory_mb for .xray.yaml. Parse results keep no syntax trees,
+    only the declarations and facts the tools read, with repeated strings
+    (package paths, type names) shared. With max_memory_mb set, the Go
+    packages holding the most function-body facts are made lean until the
+    index fits: symbol lookups are unaffected, while call-graph and
+    body-level queries re-parse their files when they read them. Lean
+    packages are marked "lean", with the bytes their bodies would take.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - max_packages: Largest packages listed, by approximate bytes (default 20)
+
+    EXAMPLE OUTPUT:
+    {
+        "files": 4012,
+        "symbols": 61877,
+        "references": 402113,
+        "approximate_bytes": 389120512,
+        "by_language": {"go": {"files": 3998, "symbols": 61540, "references": 401980, "bytes": 388901232}, ...},
+        "memory": {"max_memory_mb": 256, "within_budget": true, "lean_packages": 3, "on_demand_bytes": 121634816},
+        "call_graph": {"nodes": 24410, "edges": 188201, "approximate_bytes": 96104448},
+        "history_cache": {"entries": 1840, "approximate_bytes": 41943040, "max_entries": 20000,
+                          "max_bytes": 268435456, "hits": 5210, "misses": 96, "hit_rate": 0.982, "evictions": 0,
+                          "cache_file": "/Users/john/.cache/xray/history/blobs.bin"},
+        "package_count": 412,
+        "packages": [
+            {"package": "github.com/acme/shop/internal/gen/sqlc", "language": "go", "files": 61, "symbols": 2210,
+             "references": 40112, "bytes": 3407872, "lean": true, "on_demand_bytes": 52428800},
+            {"package": "github.com/acme/shop/internal/orders", "language": "go", "files": 40, "symbols": 902,
+             "references": 12030, "bytes": 18874368}
+        ]
+    }
+
+    Sizes err high: a string shared by several files counts in each. The
+    call graph is only reported once a tool has built it. "history_cache"
+    holds the parses of old file versions that symbol_history, hotspots,
+    diff_symbols and the other history tools share, by git blob SHA, across
+    projects and restarts; it is bounded by --history-cache-entries and
+    --history-cache-mb.
+    """
+    try:
+        indexer = get_indexer(root_path, project=project)
+        return await _run(indexer, indexer.index_stats, max_packages, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error computing index statistics", e)
+
+
+@_tool
+async def diagnostics(root_path: Optional[str] = None, repair: bool = False, max_paths: int = 20, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🩺 Check the index itself: what it is missing, what is stale, what no longer exists.
+
+    USE THIS when results look wrong - a symbol that should be found is not,
+    a caller is listed in a file that was deleted. The index is inspected
+    as it is, before the usual catch-up with the tree, against the files on
+    disk: every entry's content hash is compared with its file.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - repair: Fix what can be fixed and report what was done (default false: only look)
+    - max_paths: Example paths listed per category (default 20)
+
+    EXAMPLE OUTPUT:
+    {
+        "not_indexed": {
+            "new": {"count": 1, "paths": ["internal/api/routes.go"]},
+            "failed": {"count": 1, "paths": [{"path": "gen/huge.go", "reason": "Not indexed: ..."}]},
+            "excluded": [{"reason": "default: node_modules", "count": 1, "paths": ["web/node_modules/"]}]
+        },
+        "deleted": {"count": 1, "paths": ["internal/old/old.go"]},
+        "stale": {"count": 2, "paths": ["internal/store/user.go", "cmd/main.go"], "touched": 5},
+        "parseErrors": {"files": 1, "errors": 2,
+                         "by_language": {"go": {"files": 1, "errors": 2, "paths": ["internal/wip/draft.go"]}}},
+        "dangling_edges": {"checked": true, "count": 1,
+                           "edges": [{"caller": "Handle", "callee": "GetUser", "path": "api/api.go", "line": 12}]},
+        "cache": {"path": "/Users/john/.cache/xray/projects/3f2a.../9fceb02.../index.bin", "exists": true,
+                  "size_bytes": 618342, "valid": true, "error": null, "files": 4012, "load_error": null,
+                  "others": [], "unsaved_changes": false},
+        "issues": 6,
+        "healthy": false,
+        "repaired": {"added": 1, "reparsed": 2, "removed": 1, "dropped_edges": 1, "cache_saved": true,
+                     "not_repairable": {"failed": 1}}
+    }
+
+    "new" files appeared since the index last walked the tree; "failed"
+    could not be read or parsed (they are retried once fixed); "excluded"
+    are left out by a rule (see show_config) and "touched" files have a new
+    mtime but the same content - neither counts as an issue, nor do parse
+    errors, which are the sources' (list_symbols on a file lists them).
+    Repairing re-parses stale and new files, drops deleted entries and
+    dangling edges, deletes corrupt cache files and saves the index;
+    "repaired" is only present with repair=true.
+    """
+    try:
+        indexer = get_indexer(root_path, project=project)
+        return await _run(indexer, indexer.diagnostics, repair, max_paths, ctx=ctx, timeout_ms=timeout_ms,
+                          present=True)
+    except Exception as e:
+        return _error("Error running index diagnostics", e)
+
+
+@_tool
+async def clear_cache(root_path: Optional[str] = None, project: Optional[str] = None) -> Dict[str, Any]:
+    """
+    🗑️ Wipe the persisted index of a project (all commits); the next call rebuilds it.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+
+    EXAMPLE OUTPUT:
+    {"cleared": "/Users/john/.cache/xray/projects/3f2a9c01d4e5b6a7", "bytes_freed": 1236684}
+    """
+    try:
+        indexer = get_indexer(root_path, project=project)
+        return await _run(indexer, indexer.clear_cache)
+    except Exception as e:
+        return _error("Error clearing cache", e)
+
+
+def _server_stats() -> Dict[str, Any]:
+    """
+    The server's usage: tools, every loaded index, the page store and memory.
+    An index another call is working on is reported as busy rather than waited for.
+    """
+    projects = []
+    for indexer in list(_indexer_cache.values()):
+        lock = _index_lock(indexer)
+        if not lock.acquire(blocking=False):
+            projects.append({"root": str(indexer.source_root), "ref": indexer.ref_commit, "busy": True})
+            continue
+        try:
+            projects.append(indexer.usage_stats())
+        finally:
+            lock.release()
+    projects.sort(key=lambda p: (p["root"], p["ref"] or ""))
+    tools = _metrics.tools()
+    return {
+        "uptime_s": round(time.time() - _metrics.started, 1),
+        "calls": sum(t["calls"] for t in tools),
+        "errors": sum(t["errors"] for t in tools),
+        "slow_calls": sum(t["slow_calls"] for t in tools),
+        "slow_call_ms": _metrics.slow_call_ms,
+        "tools": tools,
+        "projects": projects,
+        "pages": _pages.stats(),
+        "memory": process_memory(),
+        "watchers": len(_watchers),
+    }
+
+
+@_tool
+async def server_stats() -> Dict[str, Any]:
+    """
+    📈 How the server is doing: calls and latency per tool, index sizes, refresh times, cache hit rates, memory.
+
+    USE THIS to find the slow tools of a shared server, to see whether the
+    persisted index is being reused (cache hit rate) and how long catching
+    up with the tree takes, or to watch the server's memory. Counts start
+    with the server; latencies are percentiles of each tool's last 1024
+    calls. Nothing is indexed or measured for the call: an index another
+    call is working on is listed as busy, and approximate_bytes is null
+    until index_stats has measured that index. With --listen and
+    --metrics, the same numbers are served at /metrics for Prometheus.
+
+    EXAMPLE OUTPUT:
+    {
+        "uptime_s": 86412.3,
+        "calls": 1894,
+        "errors": 12,
+        "slow_calls": 3,
+        "slow_call_ms": 5000,
+        "tools": [
+            {"tool": "find_symbol", "calls": 802, "errors": 2, "error_codes": {"SYMBOL_NOT_FOUND": 2},
+             "slow_calls": 0, "mean_ms": 14.2, "p50_ms": 9.8, "p90_ms": 31.0, "p99_ms": 120.4, "max_ms": 410.7,
+             "last_ms": 8.1, "total_ms": 11388.4}
+        ],
+        "projects": [
+            {"root": "/Users/john/myproject", "ref": null, "files": 4012, "symbols": 61877,
+             "approximate_bytes": null, "cache_bytes": 618342, "call_graph": {"nodes": 24410, "edges": 188201},
+             "cache": {"hits": 3990, "misses": 22, "hit_rate": 0.995},
+             "refresh": {"count": 640, "mean_ms": 41.5, "p50_ms": 35.2, "p90_ms": 60.1, "p99_ms": 2210.0,
+                         "max_ms": 18250.3, "last_ms": 33.9, "total_ms": 26560.0},
+             "indexed_at": "2024-05-02T09:14:55Z"}
+        ],
+        "pages": {"stored_results": 4, "hits": 51, "misses": 1, "hit_rate": 0.981},
+        "memory": {"rss_bytes": 512753664, "peak_rss_bytes": 790102016},
+        "watchers": 1
+    }
+
+    "refresh" times every catch-up of the index with the tree - the first
+    full build, and the quick checks before later calls. Cache hits are
+    files whose persisted parse result was reused, misses files parsed.
+    Calls slower than slow_call_ms are logged to stderr with their
+    arguments, secrets redacted and long values cut.
+    """
+    try:
+        return _versioned(_server_stats())
+    except Exception as e:
+        return _error("Error reading server statistics", e)
+
+
+def _project_list() -> List[Dict[str, Any]]:
+    """The added projects, by name, and whether each has an index loaded or a watcher running."""
+    loaded = {str(indexer.source_root) for indexer in _indexer_cache.values()}
+    globs = _project_globs()
+    return [{"project": name, "root_path": root, "indexed": root in loaded, "watching": root in _watchers,
+             **globs.get(root, {})}
+            for name, root in sorted(_added().items())]
+
+
+@_tool
+async def add_project(path: str, include: Optional[List[str]] = None,
+                      exclude: Optional[List[str]] = None, quick_index: Optional[bool] = None) -> Dict[str, Any]:
+    """
+    📂 Add a project to this session, so tools can name it instead of repeating its path.
+
+    USE THIS when working on several repositories from one server. Every
+    tool taking root_path also takes project, the name returned here; with
+    a single project added, both can be left out. Each project has its own
+    index and on-disk cache, and is indexed on the first call that needs it.
+    Each client session has its own projects - over HTTP (--listen), clients
+    sharing the server do not see each other's.
+
+    INPUTS:
+    - path: The ABSOLUTE path to the project root
+    - include: Default include globs of the project - the tools taking include
+      (list_symbols, search_symbols, what_breaks, find_callers, hotspots,
+      dependency_graph) use them when a call leaves include out
+    - exclude: Default exclude globs, likewise, e.g. ["vendor", "**/*_mock.go"]
+    - quick_index: Index the project quickly first (default: --quick-index / XRAY_QUICK_INDEX, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "project": "api",
+        "root_path": "/Users/john/api",
+        "added": true,
+        "projects": [
+            {"project": "api", "root_path": "/Users/john/api", "indexed": false, "watching": false},
+            {"project": "web", "root_path": "/Users/john/web", "indexed": true, "watching": false}
+        ]
+    }
+
+    The name is the directory name, suffixed on a collision ("api-2"). It is
+    the name xray:// resource URIs use and stays the same across sessions;
+    "added" is false if the project was already added.
+
+    Globs use doublestar syntax against paths relative to the project root:
+    * stays within a directory, ** spans any number of them, {a,b} picks
+    alternatives, and a pattern matching a directory covers all below it.
+    Adding the project again with include or exclude replaces that default
+    ([] clears it); a call passing its own include or exclude (even [])
+    overrides the default for that call. Entries of "projects" carry the
+    defaults set.
+
+    With quick_index, meant for huge repositories, the first index holds
+    every file's declarations only - names, signatures, line ranges, the
+    package structure - read from its skeleton (as for files over
+    partial_file_size), and tools answer from it at once. A background
+    thread then parses the packages in full, a batch at a time between
+    calls, and results gain calls and references as packages complete.
+    Until all have, dict results carry "index_depth": "shallow" and
+    deep_index_percent, locations in files not parsed in full yet are
+    marked "partial": true, and a package a call names by path is parsed
+    in full first. project_overview reports the progress under
+    index.deep_index. It takes effect when the project is first indexed in
+    this server.
+    """
+    try:
+        root = normalize_path(path)
+        name = _projects.name_for(root)
+        projects = _added()
+        added = name not in projects
+        projects[name] = root
+        if quick_index is not None:
+            (_quick_projects.add if quick_index else _quick_projects.discard)(root)
+        if include is not None or exclude is not None:
+            defaults = _project_globs().setdefault(root, {})
+            for field, patterns in (("include", include), ("exclude", exclude)):
+                if patterns is not None:
+                    # Rejects an invalid pattern now rather than on every later call
+                    PathGlobs(root, **{field: patterns})
+                    defaults[field] = [p for p in patterns if p]
+                if not defaults.get(field):
+                    defaults.pop(field, None)
+        return _versioned({"project": name, "root_path": root, "added": added, "projects": _project_list()})
+    except Exception as e:
+        return _error("Error adding project", e)
+
+
+@_tool
+async def remove_project(project: str) -> Dict[str, Any]:
+    """
+    ➖ Remove a project from this session and unload its index.
+
+    INPUTS:
+    - project: The name add_project returned, or the project's root path
+
+    EXAMPLE OUTPUT:
+    {"removed": "web", "root_path": "/Users/john/web", "indexes_unloaded": 2, "projects": [...]}
+
+    The on-disk cache is kept (clear_cache wipes it), so adding the project
+    again is quick. Its watcher, with --watch, stops. While another client
+    session has the project added its indexes stay loaded (indexes_unloaded: 0).
+    """
+    try:
+        projects = _added()
+        name = project
+        if name not in projects:
+            root = str(Path(os.path.expanduser(project)).resolve())
+            name = next((n for n, r in projects.items() if r == root), None)
+        if name is None:
+            raise ProjectNotIndexed(f"Project '{project}' is not added - see list_projects")
+        root = projects.pop(name)
+        _project_globs().pop(root, None)
+        keys = []
+        # Another session working on the project keeps its indexes
+        if not _added_anywhere(root):
+            # Indexers of the working tree and of every ref of the project
+            keys = [key for key, indexer in _indexer_cache.items() if str(indexer.source_root) == root]
+            for key in keys:
+                del _indexer_cache[key]
+            watcher = _watchers.pop(root, None)
+            if watcher is not None:
+                await asyncio.to_thread(watcher.stop)
+        return _versioned({"removed": name, "root_path": root, "indexes_unloaded": len(keys),
+                           "projects": _project_list()})
+    except Exception as e:
+        return _error("Error removing project", e)
+
+
+@_tool
+async def list_projects() -> Dict[str, Any]:
+    """
+    📋 List the projects added with add_project.
+
+    EXAMPLE OUTPUT:
+    {
+        "projects": [
+            {"project": "api", "root_path": "/Users/john/api", "indexed": true, "watching": false},
+            {"project": "web", "root_path": "/Users/john/web", "indexed": false, "watching": false}
+        ],
+        "default": null
+    }
+
+    "default" is the project tools use when given neither root_path nor
+    project - set only while exactly one project is added. "indexed" means
+    its index is loaded in this session.
+    """
+    try:
+        added = _added()
+        return _versioned({"projects": _project_list(), "default": next(iter(added)) if len(added) == 1 else None})
+    except Exception as e:
+        return _error("Error listing projects", e)
+
+
+@_tool
+async def allowed_directories() -> Dict[str, Any]:
+    """
+    🔒 List the directories this server may read - check before passing a path.
+
+    USE THIS when a call fails with PATH_NOT_ALLOWED, or before adding a
+    project, to see which roots are open to you.
+
+    EXAMPLE OUTPUT:
+    {"restricted": true, "allowed_dirs": ["/Users/john/api", "/Users/john/web"]}
+
+    With "restricted" false (no --allow-dir at startup) any path is allowed.
+    Paths are checked after resolving symlinks and "..": a link inside an
+    allowed directory that points outside it is refused, and a symlinked
+    source file pointing outside is left out of the index.
+    """
+    try:
+        return _versioned(_allowlist.describe())
+    except Exception as e:
+        return _error("Error listing allowed directories", e)
+
+
+@_tool
+async def get_schema(tool: Optional[str] = None) -> Dict[str, Any]:
+    """
+    📐 The JSON Schema of each tool's input and output, at the schemaVersion results carry.
+
+    USE THIS to validate responses or generate client types. Every object a
+    tool returns has "schemaVersion" (MAJOR.MINOR): within a major version
+    field names are frozen - fields are only added, and one on its way out
+    is marked "deprecated" for a minor version, listed under
+    deprecatedFields with what replaces it, before it goes. A major bump
+    is what may remove or retype fields. Output schemas are read from the
+    tools' documented example outputs: they name the fields a result may
+    have, all optional, and allow more.
+
+    INPUTS:
+    - tool: One tool's name (default: every tool)
+
+    EXAMPLE OUTPUT:
+    {
+        "tools": [
+            {
+                "name": "list_projects",
+                "description": "📋 List the projects added with add_project.",
+                "schemaVersion": "1.2",
+                "input_schema": {"type": "object", "properties": {}, "additionalProperties": false},
+                "output_schema": {"type": "object", "properties": {"projects": {"type": "array", "items": {...}},
+                                  "default": {}, "schemaVersion": {"type": "string"}}},
+                "deprecatedFields": [{"field": "schema_version", "deprecated_in": "1.2", "removed_in": "1.3",
+                                      "replaced_by": "schemaVersion"}]
+            }
+        ],
+        "error_schema": {"type": "object", "properties": {"error": {...}, "schemaVersion": {...}}, "required": ["error"]},
+        "schemaVersion": "1.2"
+    }
+
+    Errors are {"error": {...}} of any tool, as error_schema describes.
+    """
+    try:
+        names = _tool_names()
+        if tool is not None and tool not in names:
+            raise XRayError(f"No tool '{tool}' - call get_schema without a tool to list them all")
+        schemas = [tool_schema(name, _tool_function(name)) for name in ([tool] if tool else names)]
+        return _versioned({"tools": schemas, "error_schema": ERROR_SCHEMA})
+    except Exception as e:
+        return _error("Error describing tool schemas", e)
+
+
+@_tool
+async def find_symbol(root_path: Optional[str] = None, *, query: str, include_tests: Optional[bool] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = 10, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🔍 STEP 2: Find specific functions, classes, or methods in the codebase.
+    
+    USE THIS AFTER explore_repo() when you need to locate a specific piece of code.
+    Uses fuzzy matching - you don't need the exact name!
+    
+    INPUTS:
+    - root_path: Same ABSOLUTE path used in explore_repo
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - query: What you're looking for (fuzzy search works!)
+             Examples: "auth", "user service", "validate", "parseJSON"
+    - include_tests: Also match symbols in test files (_test.go, test_*.py, *.test.ts, tests/) (default: .xray.yaml, else true; false for production code only)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 10)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+    
+    EXAMPLE INPUTS:
+    find_symbol("/Users/john/awesome-project", "authenticate")
+    find_symbol("/Users/john/awesome-project", "user model")  # Fuzzy matches "UserModel"
+    
+    EXAMPLE OUTPUT:
+    {
+        "symbols": [
+            {
+                "name": "authenticate_user",
+                "type": "function",
+                "language": "python",
+                "path": "/Users/john/awesome-project/src/auth.py",
+                "start_line": 45,
+                "end_line": 67,
+                "symbol_id": "python:src/auth.py:authenticate_user:1c9e04b2"
+            },
+            {
+                "name": "AuthService",
+                "type": "class",
+                "language": "typescript",
+                "path": "/Users/john/awesome-project/web/auth.ts",
+                "start_line": 12,
+                "end_line": 89,
+                "symbol_id": "typescript:web/auth.ts:AuthService:5d27a9e0"
+            }
+        ],
+        "total_count": 214,
+        "next_cursor": "OWMxZTZhMjRmMGQzYjg1NzoxMA"
+    }
+    
+    RETURNS:
+    Symbol objects (dictionaries), best match first. Save these objects - you'll pass them to what_breaks()!
+    Empty list if no matches found. total_count counts every ranked match; pass
+    next_cursor back to get the following page. Every name of a grouped or
+    multi-name Go declaration (var a, b int; const ( ... ); type ( ... )) is
+    a symbol of its own: constants and variables carry their own "value" and
+    "const_type"/"var_type", declarations of one parenthesized block share a
+    "group"; "value_index" tells which result of var a, b = f() a name takes.
+    
+    Every symbol a tool returns carries a "symbol_id" built from what it is -
+    package or file, receiver or class, name and signature - not where it
+    sits, so it stays valid while edits shift lines around it. Every tool
+    taking a symbol accepts it instead of a name; once the declaration's
+    signature or name changes the ID fails with SYMBOL_CHANGED and the
+    error's "closest_match" (its current name, symbol_id and location).
+    A name may be qualified by its package ("internal/api.Config",
+    "api.Config") and a Go method written "(*UserService).GetUser"; a name
+    still meaning declarations in several packages, or methods on several
+    receivers, fails with AMBIGUOUS_SYMBOL and "candidates" (symbol_id,
+    package, kind, signature, path) to retry with.
+    
+    WHAT TO DO NEXT:
+    Pick a symbol from the results and pass THE ENTIRE SYMBOL OBJECT to what_breaks() 
+    to see where it's used in the codebase.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, True)
+        return await _paged(indexer, "symbols", limit, cursor, max_tokens, indexer.find_symbol, query, None, include_tests, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error finding symbol", e)
+
+
+@_tool
+async def locate_change(root_path: Optional[str] = None, description: Optional[str] = None, keywords: Optional[List[str]] = None, limit: int = 20, weights: Optional[Dict[str, float]] = None, since: Optional[str] = "6 months ago", max_commits: Optional[int] = 500, include_tests: Optional[bool] = None, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📍 Where would this change go? Files and symbols ranked for a described change, with the evidence.
+
+    USE THIS at the start of a task described in words ("user email
+    validation") - a better starting set than grep. Four signals are
+    scored from 0 to 1 and summed with weights:
+    - name: the terms against the words of symbol names (and file names)
+    - text: how often the terms occur in identifiers and comments (tf-idf,
+      so a term found everywhere counts for little)
+    - churn: how often git changed the file in the window
+    - graph: one call (1) or two (0.5) away, in the Go call graph, from the
+      best name and text matches
+    It is deliberately heuristic: read the evidence to judge each candidate.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - description: The change in words; stop words ("fix", "the", "add") are dropped
+    - keywords: Identifiers or words that must be matched too, e.g. ["ValidateEmail", "smtp"]
+    - limit: Symbols and files returned (default 20)
+    - weights: Signal weights, e.g. {"churn": 0} (default name 0.4, text 0.3, churn 0.1, graph 0.2)
+    - since: History window of the churn signal (default "6 months ago"; any git date, or null for all)
+    - max_commits: Most recent commits the churn signal reads (default 500)
+    - include_tests: Also rank test files (default: .xray.yaml, else false)
+    - include: Only files matching one of these globs
+    - exclude: No files matching one of these globs
+
+    EXAMPLE OUTPUT:
+    {
+        "terms": ["user", "email", "valid"],
+        "weights": {"name": 0.4, "text": 0.3, "churn": 0.1, "graph": 0.2},
+        "symbols": [
+            {"name": "ValidateEmail", "type": "method", "language": "go",
+             "path": "/Users/john/shop/internal/users/validate.go",
+             "start_line": 14, "end_line": 31, "receiver": {"name": "u", "type": "*User"},
+             "symbol_id": "go:example.com/shop/internal/users:User.ValidateEmail:2b7c9e10",
+             "score": 0.812,
+             "evidence": {"name": {"score": 1.0, "matched": {"user": "user", "email": "email", "valid": "validate"}},
+                          "text": {"score": 0.71, "counts": {"email": 9, "user": 3, "valid": 4}},
+                          "churn": {"score": 0.62, "commits": 7}}},
+            {"name": "Register", "type": "function", "language": "go", "path": "/Users/john/shop/internal/api/users.go",
+             "start_line": 40, "end_line": 77, "score": 0.318,
+             "evidence": {"text": {"score": 0.42, "counts": {"email": 2, "user": 2}},
+                          "graph": {"score": 1.0, "near": "User.ValidateEmail", "hops": 1}}}
+        ],
+        "total_count": 57,
+        "files": [
+            {"path": "/Users/john/shop/internal/users/validate.go", "score": 0.851,
+             "evidence": {"name": {"score": 1.0, "symbols": ["User.ValidateEmail"], "file_name": {"valid": "validate"}},
+                          "text": {"score": 1.0, "counts": {"user": 5, "email": 14, "valid": 6}},
+                          "churn": {"score": 0.62, "commits": 7}},
+             "owners": {"recent_authors": [{"author": "Ana Lima", "commits": 5}], "codeowners": ["@acme/identity"]}}
+        ],
+        "file_count": 23,
+        "window": {"since": "6 months ago", "max_commits": 500},
+        "truncatedHistory": false
+    }
+
+    Terms are stemmed ("validation" and "validate" both become "valid").
+    Churn alone never makes a candidate. Without git history the churn
+    signal is left out, and without Go code the graph signal;
+    "unavailable" says why. Files list the authors of their recent
+    commits and their CODEOWNERS owners, so you know whom to ask.
+    """
+    try:
+        indexer = get_indexer(root_path, project=project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        return await _run(indexer, indexer.locate_change, description, keywords, limit, weights, since, max_commits,
+                          include_tests, *_globs(indexer, include, exclude), ctx=ctx, timeout_ms=timeout_ms,
+                          present=True)
+    except Exception as e:
+        return _error("Error locating change", e)
+
+
+@_tool
+async def search_symbols(
+    root_path: Optional[str] = None,
+    *,
+    query: str,
+    mode: str = "substring",
+    case_sensitive: bool = False,
+    kinds: Optional[List[str]] = None,
+    package: Optional[str] = None,
+    exported_only: bool = False,
+    language: Optional[str] = None,
+    include_tests: Optional[bool] = None,
+    ref: Optional[str] = None,
+    include_generated: Optional[bool] = None,
+    limit: int = DEFAULT_LIMIT,
+    cursor: Optional[str] = None,
+    max_tokens: Optional[int] = None,
+    timeout_ms: Optional[int] = None,
+    project: Optional[str] = None,
+    all_projects: bool = False,
+    include: Optional[List[str]] = None,
+    exclude: Optional[List[str]] = None,
+    ctx: Optional[Context] = None
+) -> Dict[str, Any]:
+    """
+    🔎 Search Go, TypeScript, JavaScript, Python, Rust, Java, .proto, .sql, Makefile and shell declarations by name - substring, regex, or CamelCase-aware fuzzy.
+
+    USE THIS when you only half-remember an identifier: fuzzy mode matches
+    "usvc" to UserService and "gbid" to GetByID. Filters combine with the
+    query, e.g. exported methods under internal/store whose name contains "get".
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - query: The text to match against symbol names
+    - mode: "substring" (default), "regex" (matched against "Name" or "Type.Name") or "fuzzy"
+    - case_sensitive: Match case exactly in substring and regex modes (default false: names
+                      are compared case-folded, so "STRASSE" finds "straße", "größe" finds "Größe")
+    - kinds: Only these kinds - any of "func", "method", "type", "interface", "struct", "class", "enum",
+      "record", "annotation", "const", "var", "field", "namespace", "module", "trait", "macro",
+      "message", "service", "rpc", "table", "column", "index", "target" ("type" covers classes, enums, records,
+      annotation types, traits and messages too, "const" enum values)
+    - package: Only packages or modules at or under this directory, relative to root_path (e.g. "internal/store")
+    - exported_only: Only exported names - capitalized in Go, exported from the module in TS/JS,
+      public (no leading underscore) or listed in `__all__` in Python, `pub` in Rust, public or
+      protected in Java (default false)
+    - language: Only "go", "typescript", "javascript", "python", "rust", "java", "proto", "sql", "make" or "shell" symbols (default all)
+    - include_tests: Also search test files (_test.go, test_*.py, *.test.ts, tests/) (default: .xray.yaml, else true; false for production code only)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+    - all_projects: Search every project added with add_project instead of one; each result
+      names its "project" (default false; ref does not apply)
+    - include: Only files matching one of these globs, e.g. ["internal/**", "cmd/{api,worker}/**"]
+      (doublestar syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: Leave out files matching one of these globs, e.g. ["**/*_test.go", "vendor"]
+      (default: the project's, see add_project)
+
+    EXAMPLE OUTPUT:
+    {
+        "symbols": [
+            {"name": "UserService", "qualified_name": "UserService", "kind": "struct", "language": "go",
+             "container": {"package": "service", "package_dir": "internal/service", "receiver": null},
+             "path": "/Users/john/project/internal/service/user.go", "line": 14,
+             "signature": "type UserService struct", "score": 67},
+            {"name": "UserStore", "qualified_name": "UserStore", "kind": "class", "language": "typescript",
+             "container": {"package_dir": "web/src", "module": "store.ts", "receiver": null},
+             "path": "/Users/john/project/web/src/store.ts", "line": 8,
+             "signature": "export class UserStore", "score": 60}
+        ],
+        "total_count": 3,
+        "next_cursor": "..."
+    }
+
+    Scores run 0-100 (100 = exact name); results are ranked by score, then name.
+    "receiver" is the method's receiver type, or the struct/interface owning a field or method spec;
+    for TS/JS and Python it is the class (interface, namespace) a member is declared in.
+    Python results name their dotted module in container.module ("app.core"), Rust results
+    their module path with the crate name in front ("geo_kit::shapes::circle"); the receiver of a
+    Rust method is the type or trait of its impl or trait block. .proto results name their
+    proto package ("acme.users.v1"). Go test functions carry their "testKind".
+    Nested Go declarations - a function literal given a name inside a function,
+    the types and constants of function bodies, anonymous structs ("struct@41:10") -
+    are found too; they carry "parent", e.g. "Server.Start", and their
+    qualified_name starts with it.
+    Across all projects, results of every project are ranked together and the
+    output lists the "projects" searched.
+    """
+    try:
+        if all_projects:
+            if ref:
+                raise ValueError("ref names a commit of one project; leave it out with all_projects")
+            return await _search_all_projects(
+                (query, mode, case_sensitive, kinds, package, exported_only, language), include_tests,
+                include, exclude, include_generated, limit, cursor, max_tokens, timeout_ms)
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, True)
+        return await _paged(indexer, "symbols", limit, cursor, max_tokens, indexer.search_symbols,
+                            query, mode, case_sensitive, kinds, package, exported_only, language, include_tests,
+                            *_globs(indexer, include, exclude), ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error searching symbols", e)
+
+
+async def _search_all_projects(args: tuple, include_tests: Optional[bool], include: Optional[List[str]],
+                               exclude: Optional[List[str]], include_generated: Optional[bool], limit: int,
+                               cursor: Optional[str], max_tokens: Optional[int],
+                               timeout_ms: Optional[int]) -> Dict[str, Any]:
+    """
+    search_symbols over every added project at once, each with its own default globs and
+    .xray.yaml settings, paged like _paged.
+    """
+    added = sorted(_added().items())
+    if not added:
+        raise ProjectNotIndexed("No project added - call add_project first")
+    indexers = [(name, get_indexer(root, include_generated=include_generated)) for name, root in added]
+    tests = [indexer.setting("include_tests", include_tests, True) for _, indexer in indexers]
+    globs = [_globs(indexer, include, exclude) for _, indexer in indexers]
+    key = json.dumps(["search_symbols", added, include_generated, args, tests, globs], default=str, sort_keys=True)
+    if cursor:
+        return _versioned(_pages.next_page(key, cursor, "symbols", limit, max_tokens))
+    # No progress: the phases of projects indexing side by side would interleave
+    found = await asyncio.gather(*(_run(indexer, indexer.search_symbols, *args, project_tests, *project_globs,
+                                        listing="symbols", timeout_ms=timeout_ms, present=True)
+                                   for (_, indexer), project_tests, project_globs in zip(indexers, tests, globs)))
+    result: Dict[str, Any] = {"symbols": [], "projects": [name for name, _ in added]}
+    for (name, indexer), results in zip(indexers, found):
+        if isinstance(results, list):
+            results = {"symbols": results}
+        result["symbols"].extend({**symbol, "project": name} for symbol in results["symbols"])
+        if results.get("warnings"):
+            result.setdefault("warnings", []).extend({**w, "project": name} for w in results["warnings"])
+    result["symbols"].sort(key=lambda r: (-r["score"], r["name"], r["project"], r["path"], r["line"]))
+    return _versioned(_pages.first_page(key, result, "symbols", limit, max_tokens))
+
+
+@_tool
+async def search_by_signature(root_path: Optional[str] = None, params: Optional[List[str]] = None, returns: Optional[List[str]] = None, receiver: Optional[str] = None, kind: Optional[str] = None, variadic: Optional[bool] = None, min_params: Optional[int] = None, max_params: Optional[int] = None, exact_params: bool = False, exact_returns: bool = False, include_tests: Optional[bool] = None, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🧬 Find Go functions and methods by the shape of their signature - what they take, return and are called on.
+
+    USE THIS when you know the shape but not the name: every method taking
+    a context.Context and returning an error, the functions returning
+    *sql.DB, the variadic option setters. Types are matched understanding
+    packages, pointers and slices:
+    - "User": User of any package, behind any pointer or slice (User, *User, []*User, ...User)
+    - "*User", "[]User", "...User": exactly that
+    - "store.User", "example.com/app/store.User": User of that package, import aliases resolved
+    - "_" or "*": any type; "*_" any pointer, "sql._" any type of package sql
+    - anything else ("map[string]any", "func()") matches its text
+    Patterns match parameters (results) in any order, each a different one.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - params: Type patterns the parameters must include, e.g. ["context.Context", "*User"]
+    - returns: Type patterns the results must include, e.g. ["error"]
+    - receiver: Type pattern of the method receiver, e.g. "UserService" (implies kind "method")
+    - kind: "func" (no receiver) or "method" (interface method specs too); default both
+    - variadic: Only variadic (true) or non-variadic (false) ones
+    - min_params: At least this many parameters
+    - max_params: At most this many parameters
+    - exact_params: No parameters besides the params given (default false)
+    - exact_returns: No results besides the returns given (default false)
+    - include_tests: Also functions of _test.go files (default: .xray.yaml, else true)
+    - include: Only files matching one of these globs, e.g. ["internal/**"]
+      (doublestar syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: Leave out files matching one of these globs, e.g. ["vendor/**"] (default: the project's, see add_project)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT (params ["context.Context"], returns ["error"], kind "method"):
+    {
+        "matches": [
+            {"symbol": "UserService.GetUser", "kind": "method",
+             "signature": "func (s *UserService) GetUser(ctx context.Context, id string) (*User, error)",
+             "package": "github.com/john/project/svc", "path": ".../svc/methods.go", "line": 5,
+             "exported": true, "variadic": false},
+            {"symbol": "Getter.GetUser", "kind": "method", "interface": "Getter", ...}
+        ],
+        "total_count": 2
+    }
+
+    Matches come by package, file and line. An interface method spec names
+    its "interface"; a function of a _test.go file is "test": true.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, True)
+        return await _paged(indexer, "matches", limit, cursor, max_tokens, indexer.search_by_signature, params,
+                            returns, receiver, kind, variadic, min_params, max_params, exact_params, exact_returns,
+                            include_tests, *_globs(indexer, include, exclude), ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error searching signatures", e)
+
+
+@_tool
+async def list_symbols(root_path: Optional[str] = None, *, path: str, include_tests: Optional[bool] = None, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, include_nested: bool = False, kinds: Optional[List[str]] = None, exported_only: bool = False, top_level_only: bool = False, language: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📋 List every symbol declared in a Go, TypeScript, JavaScript, Python, Rust, Java, .proto, .sql, Makefile or shell file or directory.
+
+    USE THIS when you already know which file or package you care about and want
+    its full shape: functions, methods, types, and struct fields with complete signatures.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: A .go/.ts/.tsx/.js/.mjs/.py/.rs/.java/.proto/.sql/.sh/.mk file, a Makefile or a directory (absolute, or relative to root_path)
+    - include_tests: For a directory, also list its test files (default: .xray.yaml, else true; false for production code only)
+    - include: For a directory, only its files matching one of these globs, e.g. ["internal/**", "cmd/{api,worker}/**"]
+      (doublestar syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: For a directory, leave out its files matching one of these globs, e.g. ["**/*_test.go", "vendor"]
+      (default: the project's, see add_project)
+    - include_nested: Also list Go declarations below the top level - function literals
+      assigned to a name inside a function, the types and constants of function bodies,
+      anonymous structs - and Java local and anonymous classes (default false)
+    - kinds: Only these kinds, as search_symbols takes them - e.g. ["func", "method", "type"]
+      ("type" covers structs, interfaces, classes and the like; default all)
+    - exported_only: Only exported names, as search_symbols decides them (default false)
+    - top_level_only: Leave out declarations inside another one: struct fields, interface
+      method specs, class members and nested declarations; Go methods stay (default false)
+    - language: For a file, parse it as this language whatever its extension or
+      the project's "extensions" mapping - e.g. "go" for a .gotmpl template (default: the file's own)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "symbols": [
+            {
+                "name": "Map",
+                "type": "function",
+                "signature": "func Map[T any, U any](in []T, f func(T) U) []U",
+                "typeParams": [
+                    {"name": "T", "constraint": "any"},
+                    {"name": "U", "constraint": "any"}
+                ],
+                "path": "/Users/john/project/util/slices.go",
+                "start_line": 12,
+                "end_line": 18,
+                "doc": "Map applies f to every element of in."
+            }
+        ],
+        "total_count": 1
+    }
+
+    Struct fields are listed as "field" symbols with the owning struct in
+    `container` and their tags parsed into a map:
+    {"name": "Email", "type": "field", "container": "User", "field_type": "string",
+     "tag": "json:\"email,omitempty\"",
+     "tags": {"json": {"name": "email", "options": ["omitempty"]}}}
+    A "-" tag value shows up as {"skip": true}; malformed tags keep the raw
+    `tag` string and set `parseError`.
+
+    Methods also carry a `receiver` object, e.g.
+    {"name": "s", "type": "UserService", "pointer": true}. Pointer-receiver
+    methods can mutate the receiver and are only in the method set of *T.
+
+    A file that does not parse cleanly - a half-finished edit, merge conflict
+    markers - still lists the declarations around the broken region. Its
+    problems are listed in `parseErrors`, and the symbols they fall inside
+    are marked `"approximate": true`:
+    {"path": ".../user.go", "kind": "merge_conflict", "line": 40, "column": 1, "end_line": 52,
+     "message": "unresolved merge conflict (HEAD vs feature)"}
+    {"path": ".../user.go", "kind": "syntax", "line": 61, "column": 21, "message": "unclosed '{'"}
+
+    Files with build constraints - a `//go:build` line or a _GOOS/_GOARCH
+    name - pass them on to their symbols as `buildConstraints`, in
+    //go:build syntax ("linux && amd64", "(darwin || freebsd) && !cgo").
+    A declaration repeated per platform lists the others as `variants`:
+    {"name": "Open", "buildConstraints": "unix",
+     "variants": [{"path": ".../open_windows.go", "line": 9, "buildConstraints": "windows"}]}
+
+    In _test.go files, functions `go test` runs carry a `testKind`: "test",
+    "benchmark", "fuzz", "example" or "main" (TestMain). Examples name what
+    they document in `example_of` ("UserService.GetUser"), and table-driven
+    tests describe their case table:
+    {"name": "TestGetUser", "testKind": "test",
+     "table": {"variable": "tests", "line": 14, "cases": 3,
+               "case_names": ["found", "missing", "negative id"],
+               "loop_variable": "tt", "loop_line": 25, "subtests": true}}
+
+    With include_nested, nested declarations follow each file's top-level ones,
+    marked "nested": true with their innermost enclosing declaration in "parent".
+    Java member classes are top-level symbols with the outer class in `container`;
+    local classes and anonymous ones (named "Circle$1", with their "supertype")
+    are nested. An anonymous struct is named after where its struct keyword stands, its
+    fields carry that name in `container`:
+    {"name": "handle", "type": "function", "function_literal": true, "nested": true,
+     "parent": "Server.Start", "signature": "func handle(e event) error", ...}
+    {"name": "struct@41:10", "type": "struct", "anonymous": true, "nested": true,
+     "parent": "Server.Start", "signature": "struct{Verbose bool}", ...}
+    search_symbols, find_symbol and get_symbol_source ("Server.Start.handle") find
+    them without the flag. A package-level var assigned a function literal is
+    listed as a variable marked "function_literal": true.
+
+    Java declarations carry their `visibility`, other `modifiers` and their
+    `annotations`, arguments parsed ("value" for a lone one); types list
+    `extends`, `implements` and `permits`, records their components as fields:
+    {"name": "getUser", "type": "method", "container": "UserController",
+     "visibility": "public", "annotations": [{"name": "GetMapping", "arguments": {"value": "/{id}"}}],
+     "parameters": [{"name": "id", "type": "long"}], "returns": "User", ...}
+
+    RETURNS:
+    A page of symbol objects. Generic declarations carry `typeParams` with the
+    name and constraint of each parameter; methods on generic types list the
+    receiver's parameters with the constraints from the type declaration.
+    The kinds, exported_only and top_level_only filters apply before paging:
+    limit and total_count count the symbols that pass them.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, True)
+        return await _paged(indexer, "symbols", limit, cursor, max_tokens, indexer.list_symbols, path, include_tests,
+                            *_globs(indexer, include, exclude), include_nested, kinds, exported_only, top_level_only,
+                            language, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error listing symbols", e)
+
+
+@_tool
+async def find_implementations(root_path: Optional[str] = None, *, name: str, path: Optional[str] = None, format: str = "json", depth: int = 1, include_mocks: bool = False, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🧩 Find what implements an interface - or which interfaces a type satisfies.
+
+    Method sets are compared the way the Go compiler does: a method with a
+    pointer receiver only counts for *T, so a type whose methods use pointer
+    receivers is reported as `"satisfied_by": "*UserService"`.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - name: An interface name (e.g. "Service") or a concrete type name (e.g. "UserService"), or its symbol_id
+    - path: Optional file or package directory to pick one of several same-named types
+    - format: "json" (default) or "mermaid" for a classDiagram of the same result
+    - depth: With mermaid, levels of embedded types drawn around each type (default 1)
+    - include_mocks: Also list gomock, mockery/testify, moq and hand-written mocks, generated mock files included (default false)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT (interface given):
+    {
+        "interface": {"name": "Service", "methods": ["CreateUser", "DeleteUser", "GetUser"], ...},
+        "implementations": [
+            {"name": "PostgresService", "satisfied_by": "*PostgresService",
+             "pointer_receiver_methods": ["CreateUser", "DeleteUser", "GetUser"], ...}
+        ],
+        "partial": [
+            {"name": "UserService", "matched": ["GetUser"],
+             "missing": ["CreateUser", "DeleteUser"], ...}
+        ],
+        "total_count": 1
+    }
+
+    Given a concrete type the result has "type", "interfaces", and "partial"
+    instead. Methods with the right name but a different signature are listed
+    under "mismatched" with the expected and actual signatures.
+
+    Mocks are left out and counted in "mocks_excluded"; with include_mocks
+    they are listed with "mock": true, their "framework" and the interface
+    they were generated for in "mocks" (see list_mocks).
+
+    With format="mermaid", "diagram" holds a classDiagram: implementers
+    point at the interface with ..|>, partial matches with a dashed ..>,
+    and embedded types hang off their embedder (*-- for structs, <|-- for
+    interfaces).
+
+    Rust traits are matched by their `impl Trait for Type` blocks rather than
+    by method sets: each implementation names the impl block, the trait
+    methods it leaves to their defaults, and whether it is a blanket impl
+    (`impl<T: Display> Trait for T`). `#[derive(...)]` counts as an impl of
+    the derived trait, and a Rust type lists the traits it implements.
+
+    Java interfaces are matched by `implements` and `extends` clauses,
+    inherited ones included: each implementation names the supertype it
+    comes "via" and is marked "abstract" for an abstract class, and
+    "extended_by" lists the subinterfaces. A Java class lists the
+    interfaces it implements and its "superclasses"; ones from outside
+    the project (java.io.Serializable) carry the package of their import.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.find_implementations, name, path, format, depth, include_mocks, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error finding implementations", e)
+
+
+@_tool
+async def list_mocks(root_path: Optional[str] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🎭 List which Go interfaces have mocks and which don't.
+
+    USE THIS before writing tests, to reuse an existing mock or see what
+    mockgen/mockery still has to generate. A mock is a gomock struct (a
+    *gomock.Controller field; its MockRecorder goes with it), a struct
+    embedding testify's mock.Mock (mockery), a moq struct of <Method>Func
+    fields, or a type named Mock.../...Mock/Fake.../Stub... in a mock-looking
+    file (*_mock.go, mock_*.go, a mocks/ directory, a generator header).
+    Each is linked to its interface by the generator's doc comment
+    ("linked_by": "doc"), its name without the affix ("name") or, failing
+    both, the interface with the most methods it implements ("methods").
+    Generated mock files are read even when the index skips generated code.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: Optional file or directory to limit the interfaces to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "mocked": [
+            {"name": "Store", "package": "store", "path": ".../store/store.go", "start_line": 3, "methods": 1,
+             "mocks": [{"name": "MockStore", "package": "mocks", "path": ".../mocks/mock_store.go",
+                        "start_line": 14, "framework": "gomock", "linked_by": "doc"}]}
+        ],
+        "unmocked": [
+            {"name": "Lister", "package": "store", "path": ".../store/store.go", "start_line": 12, "methods": 1}
+        ],
+        "total_count": 2,
+        "mock_count": 1
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.list_mocks, path, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error listing mocks", e)
+
+
+@_tool
+async def check_mocks(root_path: Optional[str] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🥸 Find Go mocks that need regenerating: interfaces that grew, shrank or changed since their mock was generated.
+
+    USE THIS after changing an interface, or in CI, to catch mocks that still
+    compile but no longer match - before a test fails in a confusing way.
+    Every mock list_mocks links to a project interface has its methods
+    compared with the interface's method set (embedded interfaces followed):
+    - "stale_mock": the interface methods the mock is "missing", the ones it
+      has "extra" (not for handwritten fakes, and not generator helpers such
+      as EXPECT or moq's <Method>Calls), and "mismatched" signatures, each
+      with its line
+    - "missing_mock_file": a //go:generate directive running mockgen, mockery
+      or moq (directly, via `go run` or `go tool`) writes a file that is not there
+    - "missing_mock": the file is there but lacks the mock of one of the
+      directive's interfaces - an interface added to a -source file, say
+    A mock found in a directive's output is checked against that
+    directive's interface, and "generated_by" gives the directive to re-run.
+    Generated mock files are read even when the index skips generated code.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: Optional file or directory to limit the interfaces, mocks and directives to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "findings": [
+            {"kind": "stale_mock",
+             "interface": {"name": "Store", "package": "store", "path": ".../store/store.go", "start_line": 8},
+             "mock": {"name": "MockStore", "package": "mocks", "path": ".../mocks/mock_store.go", "start_line": 14,
+                      "framework": "gomock", "linked_by": "go:generate"},
+             "mock_file": ".../mocks/mock_store.go",
+             "missing": [{"method": "Delete", "signature": "Delete(ctx context.Context, id int) error", "line": 11}],
+             "mismatched": [{"method": "Get", "expected": "Get(ctx context.Context, id int) (*User, error)",
+                             "actual": "func (m *MockStore) Get(arg0 int) (*store.User, error)",
+                             "interface_line": 9, "line": 40}],
+             "delta": 2,
+             "generated_by": {"path": ".../store/store.go", "line": 3,
+                              "command": "mockgen -source=store.go -destination=../mocks/mock_store.go -package=mocks"}},
+            {"kind": "missing_mock_file", "generator": "mockery", "expected_path": ".../billing/mocks/Invoicer.go",
+             "interfaces": ["Invoicer"],
+             "generated_by": {"path": ".../billing/invoice.go", "line": 5, "command": "mockery --name=Invoicer"}}
+        ],
+        "total_count": 2,
+        "counts": {"stale_mock": 1, "missing_mock_file": 1},
+        "checked_mocks": 6,
+        "message": "1 stale mock, 1 missing mock file",
+        "next_cursor": null
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "findings", limit, cursor, max_tokens, indexer.check_mocks, path,
+                            ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error checking mocks", e)
+
+
+@_tool
+async def interface_usage(root_path: Optional[str] = None, *, name: str, path: Optional[str] = None, include_tests: Optional[bool] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    ✂️ Find the methods of a Go interface that no consumer calls through it.
+
+    USE THIS before shrinking or splitting a fat interface. A call counts
+    only when its receiver's static type is the interface - a parameter,
+    field or local declared as Service, or as an interface embedding it -
+    not a concrete implementer. Method values (`f := svc.DeleteUser`) count
+    as references.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - name: Interface name (e.g. "Service"), or its symbol_id
+    - path: Optional file or package directory to pick one of several same-named interfaces
+    - include_tests: Also count calls made in _test.go files (default: .xray.yaml, else false)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "interface": {"name": "Service", "import_path": "example.com/app", "method_count": 3, ...},
+        "methods": [
+            {"name": "DeleteUser", "signature": "DeleteUser(id int) error", "calls": 0, "references": 0,
+             "concrete_calls": 0, "unresolved": 0, "consumer_packages": [], "call_sites": []},
+            {"name": "GetUser", "calls": 2, "references": 0, "concrete_calls": 1, "unresolved": 0,
+             "consumer_packages": ["example.com/app/api", "example.com/app/cli"],
+             "call_sites": [{"path": "/Users/john/project/api/users.go", "line": 31, "column": 18,
+                             "function": "Handler.Get", "package": "example.com/app/api"}, ...]},
+            ...
+        ],
+        "unused": ["DeleteUser"],
+        "consumers": [{"package": "example.com/app/api", "methods": ["CreateUser", "GetUser"], "call_count": 3}, ...],
+        "consumer_groups": [
+            {"methods": ["CreateUser", "GetUser"], "packages": ["example.com/app/api"]},
+            {"methods": ["GetUser"], "packages": ["example.com/app/cli"]}
+        ],
+        "total_count": 3,
+        "message": "1 of 3 methods of Service are never called through it: DeleteUser - candidates to drop or split off"
+    }
+
+    "unused" methods are candidates to drop, or to move to an interface of
+    their own; "consumer_groups" is the split along consumer lines, one
+    smaller interface per group. "concrete_calls" counts calls on the
+    implementing types themselves, which do not need the interface. A call
+    through an interface embedding this one is marked with "via"; methods an
+    embedded interface declares carry "declared_in". "unresolved" counts
+    selectors with the method's name whose receiver could not be typed -
+    look at those before deleting a method.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        return await _run(indexer, indexer.interface_usage, name, path, include_tests, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error measuring interface usage", e)
+
+
+@_tool
+async def trace_variable(root_path: Optional[str] = None, *, symbol: str, variable: str, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🕳️ Trace where a variable in one Go function gets its values, and which can be nil.
+
+    USE THIS on a nil pointer panic: the stack names the line that
+    dereferenced the variable, this lists every assignment that reaches it -
+    short declarations, var, plain and multi-assignments, range and type
+    switch bindings, parameters - with whether each right-hand side can be
+    nil, and the dereferences and `&v` pointer passes that follow.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - symbol: Function or method name (e.g. "GetUser" or "UserService.GetUser"), or its symbol_id
+    - variable: The variable's name (e.g. "user"); parameters, receivers and named results count
+    - path: Optional file or package directory to pick one of several same-named functions
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "function": {"name": "UserService.GetUser", "path": "/Users/john/project/service.go", "start_line": 47, ...},
+        "variable": "user",
+        "declarations": [
+            {"declaration": 1, "line": 48, "kind": "short_decl", "scope": {"start_line": 48, "end_line": 50},
+             "type": "*User", "can_be_nil": false},
+            {"declaration": 2, "line": 52, "kind": "short_decl", "scope": {"start_line": 52, "end_line": 60},
+             "type": "*User", "can_be_nil": false}
+        ],
+        "assignments": [
+            {"line": 48, "column": 8, "kind": "short_decl", "declaration": 1,
+             "text": "if user, ok := s.cache[id]; ok {", "can_be_nil": false,
+             "nil_reason": "a comma-ok map lookup: the zero value only when ok is false", "type": "*User"},
+            {"line": 52, "column": 5, "kind": "short_decl", "declaration": 2, "text": "user := &User{}",
+             "can_be_nil": false, "nil_reason": "the address of a composite literal", "type": "*User"}
+        ],
+        "dereferences": [
+            {"line": 53, "column": 72, "declaration": 2, "kind": "field", "text": "user.ID",
+             "after_line": 52, "after_can_be_nil": false}, ...
+        ],
+        "pointer_passes": [{"line": 53, "column": 72, "declaration": 2, "text": "&user.ID", "call": "Scan"}, ...],
+        "nil_checks": [],
+        "total_count": 2
+    }
+
+    can_be_nil is true for the nil literal, a map lookup without ok of
+    nilable values, the pointer of a (pointer, error) result, a nilable
+    zero value or parameter; false for &T{}, new, make, literals and
+    comma-ok forms; null when the tracer cannot tell (an external call, a
+    value whose type it cannot find). A := in an inner block declares a
+    new variable: each entry names its declaration, and a shadowing one is
+    marked "shadows". A dereference is "nil_checked" inside `if v != nil {`
+    or after an `if v == nil {` block that returns. Intraprocedural only:
+    nothing follows the value into callees or back to callers.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.trace_variable, symbol, variable, path, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error tracing variable", e)
+
+
+@_tool
+async def type_hierarchy(root_path: Optional[str] = None, *, symbol: str, path: Optional[str] = None, depth: int = 2, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🌳 Show how a Go type relates to others - embeds, aliases and underlying types.
+
+    USE THIS to see what a struct is built from and what builds on it, what
+    an interface is composed of and who implements it, or which types share
+    the underlying type of a defined type like `UserID`.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - symbol: A type name ("Service", "UserService", "UserID"), or its symbol_id; an alias is followed to its target
+    - path: Optional file or package directory to pick one of several same-named types
+    - depth: Levels of embeds followed in each direction (default 2)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT (struct given):
+    {
+        "root": "example.com/app.Handler",
+        "kind": "struct",
+        "depth": 2,
+        "nodes": [
+            {"id": "example.com/app.Handler", "name": "Handler", "kind": "struct",
+             "path": "/Users/john/project/handler.go", "start_line": 12, ...},
+            {"id": "example.com/app.Base", "name": "Base", "kind": "struct", ...},
+            {"id": "sync.Mutex", "name": "sync.Mutex", "kind": "external"},
+            {"id": "example.com/app.AdminHandler", "name": "AdminHandler", "kind": "struct", ...}
+        ],
+        "edges": [
+            {"from": "example.com/app.Handler", "to": "example.com/app.Base", "relation": "embeds"},
+            {"from": "example.com/app.Handler", "to": "sync.Mutex", "relation": "embeds"},
+            {"from": "example.com/app.AdminHandler", "to": "example.com/app.Handler", "relation": "embeds",
+             "pointer": true}
+        ]
+    }
+
+    Relations: "embeds" (embedder to embedded, both directions walked),
+    "implements" (implementer to interface, for an interface; partial
+    implementers as "partially_implements" with the "missing" methods),
+    "alias_of" (`type A = B`), and for a defined type "defined_on" (the
+    type it is declared on, `type AdminID UserID`) and "same_underlying"
+    (another type on the same underlying type; "underlying" is "int" for
+    UserID). Node ids are import path plus name; pass a node's name and
+    path to other tools to follow up. Embeds looping back (interfaces
+    embedding each other) are marked "cycle": true and not followed.
+
+    A Java class or interface (when no Go type has the name) gets "extends"
+    and "implements" edges from each subtype to its supertype, walked depth
+    levels up and down; node ids are the qualified name ("com.example.Circle"),
+    and types from outside the project are "external" nodes.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.type_hierarchy, symbol, path, depth, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error building type hierarchy", e)
+
+
+@_tool
+async def type_outline(root_path: Optional[str] = None, *, symbol: str, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🗂️ Get the full shape of a Go type in one call - declaration, fields, every method of its package, interfaces and constructors.
+
+    USE THIS when asked about a type, instead of list_symbols on each file
+    of its package: Go methods may be declared in any file, tests included.
+    Methods come exported first, then by name; one declared in a _test.go
+    file is "test": true (it exists in test builds only). Constructors are
+    the functions of any package whose first result is T or *T -
+    NewUserService, but also Default or Open - with "conventional" telling
+    whether the name starts with New/new.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - symbol: A type name ("UserService", "Service"), or its symbol_id; an alias is followed to its target
+    - path: Optional file or package directory to pick one of several same-named types
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT (struct given):
+    {
+        "type": {"name": "UserService", "kind": "struct", "package": "svc",
+                 "import_path": "github.com/john/project/svc", "signature": "type UserService struct",
+                 "path": ".../svc/types.go", "start_line": 14, "end_line": 19, "doc": "UserService serves users."},
+        "fields": [
+            {"name": "db", "type": "map[string]*User", "exported": false, "line": 16, ...},
+            {"name": "Base", "type": "*Base", "embedded": true, "pointer": true, ...}
+        ],
+        "methods": [
+            {"name": "GetUser", "signature": "func (s *UserService) GetUser(ctx context.Context, id string) (*User, error)",
+             "exported": true, "pointer_receiver": true, "path": ".../svc/methods.go", "line": 5},
+            {"name": "seed", "exported": false, "pointer_receiver": true, "path": ".../svc/svc_test.go",
+             "line": 3, "test": true, ...}
+        ],
+        "promoted": [{"name": "Close", "via": "Base", ...}],
+        "interfaces": [{"name": "Getter", "package": "svc", "satisfied_by": "*UserService", ...}],
+        "constructors": [
+            {"name": "NewUserService", "signature": "func NewUserService() *UserService", "package": "svc",
+             "returns": "*UserService", "conventional": true, ...},
+            {"name": "Default", "returns": "*UserService", "returns_error": true, "conventional": false, ...}
+        ],
+        "counts": {"methods": 2, "exported_methods": 1, "test_methods": 1, "constructors": 2, "fields": 2}
+    }
+
+    An interface has its method specs as "methods", its "embeds" and the
+    types implementing it as "implementations" instead of "interfaces" and
+    "promoted"; only structs have "fields".
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.type_outline, symbol, path, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error building type outline", e)
+
+
+@_tool
+async def find_callers(root_path: Optional[str] = None, *, symbol: str, path: Optional[str] = None, depth: int = 1, format: str = "json", include_tests: Optional[bool] = None, build_context: Optional[Dict[str, Any]] = None, interface_resolution: str = "strict", include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, snippet_lines: Optional[int] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📞 Find who calls a Go function or method - the static call graph, inbound.
+
+    Calls are resolved through receivers, parameters, local variables, struct
+    fields and imports, so `service.GetUser(1)` is attributed to
+    UserService.GetUser. Functions passed as values (e.g. a handler given to
+    http.HandleFunc) are reported with kind "reference".
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - symbol: A function name ("userHandler") or "Type.Method" ("UserService.GetUser"), or its symbol_id;
+      qualify a name declared in several packages: "internal/api.New", "(*UserService).GetUser"
+    - path: Optional file or package directory to pick one of several same-named symbols
+    - depth: How many levels to follow (default 1 = direct callers only)
+    - format: "json" (default) or "mermaid" for a flowchart of the same graph
+    - include_tests: Also list callers in test files (default: .xray.yaml, else true; false for production code only)
+    - build_context: Optional {"goos": "windows", "goarch": "amd64", "tags": ["integration"]} -
+      resolve only through the files that build compiles (default: every file; unset fields
+      default to linux/amd64 without tags)
+    - interface_resolution: "strict" (default) for calls to this very method, or "expanded" to add
+      calls through the interfaces it implements / on the implementations of an interface method
+    - include: Only callers in files matching one of these globs, e.g. ["internal/**"] (doublestar
+      syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: Leave out callers in files matching one of these globs, e.g. ["**/*_test.go"]
+      (default: the project's, see add_project); a caller left out is not walked through either
+    - snippet_lines: Inline each call site's line plus this many lines of context either side
+      (0-20; default: no snippets), so no follow-up read is needed
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "symbol": {"name": "UserService.GetUser", "path": "/Users/john/project/main.go", "line": 42, ...},
+        "callers": [
+            {"name": "userHandler", "kind": "call", "depth": 1,
+             "call_site": {"path": "/Users/john/project/main.go", "line": 105, "column": 22}, ...},
+            {"name": "main", "kind": "reference", "depth": 2, "via": "userHandler", ...}
+        ],
+        "total_count": 2,
+        "depth": 2
+    }
+
+    Kinds are "call", "go", "defer" and "reference".
+
+    With snippet_lines=1 each caller also carries the code around its call site:
+    "snippet": {"first_line": 104, "text": "\tid := r.URL.Query().Get(\"id\")\n\tuser, err := svc.GetUser(id)\n...",
+                "match_line": 105, "match_start": 22, "match_end": 29}
+    match_start/match_end are the UTF-8 columns (end exclusive) of the call on
+    match_line. Lines over 200 characters are cut around the match and
+    marked "trimmed": true; with max_tokens, snippets are dropped before callers.
+
+    A call on a variable of interface type (`var svc Service; svc.GetUser(1)`)
+    resolves to the interface method Service.GetUser, not to any one
+    implementation. With interface_resolution="expanded", callers of
+    UserService.GetUser also list those calls, marked
+    {"viaInterface": true, "interface": "Service"}, and callers of
+    Service.GetUser also list direct calls on its implementations, marked
+    {"implementation": "*UserService"}. "linked" names the methods joined in.
+    Links come from the implementation map: a GetUser on a type that does
+    not implement Service is left out. Applies to the JSON format.
+
+    A function declared once per platform (open_unix.go and open_windows.go
+    under different build constraints) is one symbol: the first file's
+    declaration, with the others under "variants" and each one's
+    "buildConstraints". Without a build_context calls to it resolve to that
+    symbol from every file; with one, only the chosen build's files count.
+
+    With format="mermaid" the graph comes back as one flowchart to paste
+    into a PR description, arrows pointing from caller to callee:
+    {
+        "format": "mermaid",
+        "symbol": {"name": "UserService.GetUser", ...},
+        "diagram": "flowchart LR\n    n0[\"users.UserService.GetUser\"]\n    n1[\"main.userHandler\"]\n    n1 --> n0\n...",
+        "nodes": 3,
+        "edges": 2,
+        "depth": 2
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, True)
+        if format == "mermaid":
+            # One diagram of the whole graph; paging does not apply
+            return await _run(indexer, indexer.find_callers, symbol, path, depth, format, include_tests,
+                              build_context, interface_resolution, *_globs(indexer, include, exclude),
+                              ctx=ctx, timeout_ms=timeout_ms, present=True)
+        return await _paged(indexer, "callers", limit, cursor, max_tokens, indexer.find_callers, symbol, path, depth, format,
+                            include_tests, build_context, interface_resolution, *_globs(indexer, include, exclude),
+                            snippet_lines, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error finding callers", e)
+
+
+@_tool
+async def find_callees(root_path: Optional[str] = None, *, symbol: str, path: Optional[str] = None, depth: int = 1, format: str = "json", include_tests: Optional[bool] = None, build_context: Optional[Dict[str, Any]] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📤 Find what a Go function or method calls - the static call graph, outbound.
+
+    Project functions are returned with their location; calls into other
+    modules are returned as external callees with the import path spelled
+    out (e.g. "encoding/json.NewEncoder").
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - symbol: A function name ("userHandler") or "Type.Method" ("UserService.GetUser"), or its symbol_id
+    - path: Optional file or package directory to pick one of several same-named symbols
+    - depth: How many levels to follow (default 1 = direct callees only)
+    - format: "json" (default) or "mermaid" for a flowchart of the same graph
+    - include_tests: Also list test helpers among the callees (default: .xray.yaml, else true; false for production code only)
+    - build_context: Optional {"goos": "windows", "goarch": "amd64", "tags": ["integration"]} -
+      resolve only through the files that build compiles (default: every file; unset fields
+      default to linux/amd64 without tags)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "symbol": {"name": "userHandler", ...},
+        "callees": [
+            {"name": "NewUserService", "kind": "call", "depth": 1, "call_site": {...}, ...},
+            {"name": "UserService.GetUser", "kind": "call", "depth": 1, ...},
+            {"name": "NewEncoder", "qualified_name": "encoding/json.NewEncoder",
+             "external": true, "kind": "call", "depth": 1, ...}
+        ],
+        "total_count": 3,
+        "depth": 1
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, True)
+        if format == "mermaid":
+            # One diagram of the whole graph; paging does not apply
+            return await _run(indexer, indexer.find_callees, symbol, path, depth, format, include_tests,
+                              build_context, ctx=ctx, timeout_ms=timeout_ms, present=True)
+        return await _paged(indexer, "callees", limit, cursor, max_tokens, indexer.find_callees, symbol, path, depth, format,
+                            include_tests, build_context, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error finding callees", e)
+
+
+@_tool
+async def find_paths(root_path: Optional[str] = None, *, to_symbol: str, from_symbol: Optional[str] = None, max_depth: int = 8, max_paths: int = 20, interface_resolution: str = "strict", reverse: bool = False, include_tests: Optional[bool] = None, from_path: Optional[str] = None, to_path: Optional[str] = None, build_context: Optional[Dict[str, Any]] = None, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🛤️ Find the call paths from one Go function to another - "can userHandler reach db.QueryRow, and how?"
+
+    USE THIS instead of chaining find_callees by hand. Searches the static
+    call graph for paths of at most max_depth calls, shortest first, each an
+    ordered list of calls with their call sites. No function appears twice
+    on a path, so recursive cycles cannot loop it. With reverse, the paths
+    start at the entry points instead - main and init functions and the
+    registered HTTP handlers - to tell whether code is reachable in production.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - to_symbol: Where the paths end: a function name, "Type.Method" or symbol_id, or a function outside
+      the project by its qualified name or a tail of it ("database/sql.DB.QueryRow", "sql.DB.QueryRow",
+      "db.QueryRow")
+    - from_symbol: Where the paths start (required unless reverse)
+    - max_depth: Most calls on one path (default 8)
+    - max_paths: Most paths to return (default 20)
+    - interface_resolution: "strict" (default), where a call through an interface ends at the interface
+      method, or "expanded" to step on from an interface method to each implementation ("dispatch" calls)
+    - reverse: Search from every entry point instead of from_symbol (default false)
+    - include_tests: Also follow calls made in test files (default: .xray.yaml, else true)
+    - from_path / to_path: Optional file or package directory to pick one of several same-named symbols
+    - build_context: Optional {"goos": "windows", "goarch": "amd64", "tags": ["integration"]} -
+      follow only the calls of the files that build compiles
+    - include: Only follow calls made in files matching one of these globs, e.g. ["internal/**"]
+      (doublestar syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: Do not follow calls made in files matching one of these globs (default: the project's, see add_project)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "from": {"name": "Server.userHandler", "package": "api", "path": ".../api/api.go", "line": 14},
+        "to": {"name": "DB.QueryRow", "qualified_name": "database/sql.DB.QueryRow", "external": true},
+        "paths": [
+            {"length": 3, "symbols": ["Server.userHandler", "Store.Get", "SQLStore.Get", "DB.QueryRow"],
+             "calls": [
+                {"caller": "Server.userHandler", "callee": "Store.Get", "kind": "call",
+                 "call_site": {"path": ".../api/api.go", "line": 16, "column": 7}},
+                {"caller": "Store.Get", "callee": "SQLStore.Get", "kind": "dispatch", "implementation": "*SQLStore",
+                 "call_site": {"path": ".../store/store.go", "line": 13, "column": null}},
+                {"caller": "SQLStore.Get", "callee": "DB.QueryRow", "kind": "call", "call_sites": 2,
+                 "call_site": {"path": ".../store/store.go", "line": 14, "column": 14}}
+             ]}
+        ],
+        "total_count": 1,
+        "reachable": true,
+        "max_depth": 8,
+        "interface_resolution": "expanded"
+    }
+
+    Kinds are "call", "go", "defer", "reference" (a function passed as a
+    value, such as a handler given to http.HandleFunc) and "dispatch" (an
+    interface method to an implementation, located at the implementation).
+    call_sites counts the calls between the same two functions; the first
+    is shown. "reachable" says whether any path exists within max_depth
+    even when none is listed; "truncated" that max_paths (or the search
+    budget) cut the list short. A target named outside the project matches
+    every external function with that name tail, listed under "to.also".
+
+    With reverse, each path carries its "entry_point" ({"kind": "main" |
+    "init" | "http_handler", "route": "GET /users"}), and "entry_points"
+    lists every entry point that reaches the target with its distance in
+    calls - an empty list means unreachable from production code.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, True)
+        return await _paged(indexer, "paths", limit, cursor, max_tokens, indexer.find_paths, from_symbol, to_symbol,
+                            max_depth, max_paths, interface_resolution, reverse, include_tests, from_path, to_path,
+                            build_context, *_globs(indexer, include, exclude), ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error finding call paths", e)
+
+
+@_tool
+async def find_tests_for(root_path: Optional[str] = None, *, symbol: str, path: Optional[str] = None, depth: int = 4, build_context: Optional[Dict[str, Any]] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🧪 Find the Go tests, benchmarks, fuzz targets and examples that exercise a function, method or type.
+
+    USE THIS before changing code to know which tests to run, or to spot
+    code nothing tests. Tests are matched two ways: by `go test` naming
+    (TestGetUser, TestUserService_GetUser, BenchmarkGetUser_cached, and
+    ExampleUserService_GetUser documenting the method) within the package,
+    and through the call graph - test functions that reach the symbol
+    through up to `depth` levels of calls, with the chain of calls between.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - symbol: A function name ("NewUserService"), "Type.Method" ("UserService.GetUser"),
+      or a type name ("UserService") for the tests of any of its methods, or its symbol_id
+    - path: Optional file or package directory to pick one of several same-named symbols
+    - depth: Levels of callers to walk from the symbol up to a test (default 4)
+    - build_context: Optional {"goos", "goarch", "tags"} - only the tests that build compiles
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "symbol": {"name": "UserService.GetUser", "package": "service", "path": ".../service/user.go", "line": 42, ...},
+        "tests": [
+            {"name": "TestGetUser", "testKind": "test", "path": ".../service/user_test.go", "line": 12,
+             "matched_by": ["name", "calls"], "depth": 1, "call_chain": ["TestGetUser", "UserService.GetUser"],
+             "call_site": {"path": ".../service/user_test.go", "line": 31, "column": 18},
+             "table": {"variable": "tests", "cases": 3, "subtests": true}},
+            {"name": "TestHandler", "testKind": "test", "path": ".../api/handler_test.go", "line": 20,
+             "matched_by": ["calls"], "depth": 2,
+             "call_chain": ["TestHandler", "userHandler", "UserService.GetUser"], ...},
+            {"name": "ExampleUserService_GetUser", "testKind": "example", "matched_by": ["example"], ...}
+        ],
+        "total_count": 3,
+        "counts": {"test": 2, "example": 1},
+        "depth": 4
+    }
+
+    Tests are ordered tests, benchmarks, fuzz targets, examples, then TestMain.
+    An empty "tests" list means nothing found exercises the symbol.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "tests", limit, cursor, max_tokens, indexer.find_tests_for, symbol, path, depth,
+                            build_context, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error finding tests", e)
+
+
+@_tool
+async def extract_routes(root_path: Optional[str] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🛣️ List the HTTP endpoints a Go service registers or Spring controllers map.
+
+    Recognises net/http Handle/HandleFunc (including Go 1.22 "GET /path"
+    patterns), gorilla/mux HandleFunc(...).Methods(...), chi Get/Post/.../Method
+    and gin/echo GET/POST/... registrations. Handlers passed as functions or
+    method values (`s.handleUser`) are resolved to their symbols.
+
+    Each route carries its middleware stack, outermost first: wrappers of
+    the router given to http.ListenAndServe ("server"), Use(...) on the
+    router and its parent groups ("use"), Group/With arguments ("group",
+    "with"), gin/echo per-route middleware ("argument"), and wrappers around
+    the handler at registration - `logRequests(h)` ("wrapper"),
+    `chain(a, b)(h)` or alice's `New(a, b).Then(h)` ("chain"). The handler is
+    the function inside all of them.
+
+    Java classes annotated @RestController or @Controller contribute a route
+    per @RequestMapping/@GetMapping/@PostMapping/... method, the class-level
+    @RequestMapping path prefixed ("framework": "spring"); the handler is
+    "Class.method", and "consumes"/"produces" are passed through.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: Optional file or directory to limit the scan to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "routes": [
+            {
+                "method": null,
+                "route": "/user",
+                "handler": {"name": "userHandler", "path": "/Users/john/project/main.go", "line": 102, ...},
+                "framework": "net/http",
+                "middleware": [
+                    {"name": "recoverPanics", "path": "/Users/john/project/middleware.go", "line": 14, ...,
+                     "resolved": true, "via": "server", "applied_at": 140},
+                    {"name": "logRequests", "path": "/Users/john/project/middleware.go", "line": 30, ...,
+                     "resolved": true, "via": "wrapper", "applied_at": 127},
+                    {"expression": "s.metrics.Wrap", "resolved": false, "via": "wrapper", "applied_at": 127}
+                ],
+                "registered_at": {"path": "/Users/john/project/main.go", "line": 127, "column": 10, "function": "main"}
+            }
+        ],
+        "total_count": 1
+    }
+
+    Routes built from non-constant strings are returned with the expression
+    text as "route" and "dynamic": true. "method" is null when any method is accepted.
+    Middleware that cannot be resolved to a function stays in the stack with
+    "resolved": false; external ones carry their qualified name and "external": true.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "routes", limit, cursor, max_tokens, indexer.extract_routes, path, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error extracting routes", e)
+
+
+@_tool
+async def cross_language_links(root_path: Optional[str] = None, kinds: Optional[List[str]] = None, path: Optional[str] = None, min_confidence: float = 0.0, include_tests: Optional[bool] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🌉 Link Go code to the other languages of the repo: files it embeds, scripts it runs, frontend calls it serves.
+
+    USE THIS when a change crosses a language boundary imports do not show:
+    - embed: a `//go:embed` directive and each file its patterns match, with
+      the symbols indexed in that file (confidence 1: the compiler decides)
+    - exec: exec.Command/CommandContext running one of the repo's shell
+      scripts - directly, or through bash/sh - with the script's functions
+    - http: a fetch/axios/client.get(...) call of the TypeScript/JavaScript
+      code whose method and path fit a route the Go code registers; path
+      parameters match any segment (`${id}` fits `{id}` and `:id`)
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - kinds: Optional subset of ["embed", "exec", "http"] (default: all)
+    - path: Optional file or directory the links start from (the Go or TypeScript side)
+    - min_confidence: Leave out links less certain than this, 0-1 (default 0: all)
+    - include_tests: Also link from test files (default: .xray.yaml, else false)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "links": [
+            {"kind": "embed", "confidence": 1.0,
+             "from": {"language": "go", "path": "/Users/john/project/web/web.go", "line": 9, "symbol": "assets"},
+             "to": {"path": "/Users/john/project/web/static/app.js", "language": "javascript", "symbols": ["init"]},
+             "evidence": {"directive": "//go:embed static", "pattern": "static"}},
+            {"kind": "exec", "confidence": 0.9,
+             "from": {"language": "go", "path": "/Users/john/project/ops/deploy.go", "line": 21, "column": 9,
+                      "function": "Deploy"},
+             "to": {"path": "/Users/john/project/scripts/deploy.sh", "language": "shell", "symbols": ["push"]},
+             "evidence": {"call": "exec.Command", "command": ["bash", "scripts/deploy.sh"],
+                          "program": "scripts/deploy.sh", "matched": "root_relative"}},
+            {"kind": "http", "confidence": 0.95,
+             "from": {"language": "typescript", "path": "/Users/john/project/ui/api.ts", "line": 12, "column": 16,
+                      "function": "loadUser"},
+             "to": {"language": "go", "path": "/Users/john/project/api/users.go", "line": 30,
+                    "symbol": "getUser", "route": "/users/{id}", "method": "GET"},
+             "evidence": {"call": "axios.get", "url": "`/users/${id}`", "request_path": "/users/{}",
+                          "method": "GET", "route": "/users/{id}", "matched": "parameter", "method_match": "exact"}}
+        ],
+        "total_count": 3,
+        "counts": {"embed": 1, "exec": 1, "http": 1}
+    }
+
+    Confidence says how much to trust a string match: a script found by
+    path from the repo root scores 0.9, from the calling file 0.8, by path
+    ending 0.7 and by file name alone 0.6 (0.4 with several candidates). An
+    http link loses confidence when the request's method is unknown, the
+    route takes any method, a literal only matches a route parameter, a
+    wildcard or a path prefix had to absorb segments ("matched": "suffix",
+    as for `${API_BASE}/users` against "/api/users"), or the call is a
+    get/post on something not known to be an HTTP client. Each call keeps
+    only its best matches. "unmatched_embeds" lists embed patterns that
+    match no file on disk, as before a frontend build.
+
+    dependency_graph takes cross_language to draw these links as edges.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        return await _paged(indexer, "links", limit, cursor, max_tokens, indexer.cross_language_links, kinds, path, min_confidence, include_tests, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error linking across languages", e)
+
+
+@_tool
+async def list_queries(root_path: Optional[str] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🗄️ List the inline SQL a Go project sends to the database.
+
+    Finds query strings passed to database/sql (Query, QueryRow, Exec, Prepare
+    and the Context variants) and sqlx (Get, Select, NamedExec, ...). Queries
+    built by concatenation or fmt.Sprintf are returned with `{expr}`
+    placeholders for the dynamic parts and "dynamic": true - the first place to
+    look when auditing for SQL injection.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: Optional file or directory to limit the scan to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "queries": [
+            {
+                "query": "SELECT * FROM users WHERE id = ?",
+                "dynamic": false,
+                "method": "QueryRow",
+                "api": "database/sql.DB.QueryRow",
+                "confidence": "high",
+                "function": "UserService.GetUser",
+                "path": "/Users/john/project/main.go",
+                "line": 53,
+                "column": 14
+            }
+        ],
+        "total_count": 1,
+        "dynamic_count": 0
+    }
+
+    "confidence" is "medium" when the receiver's type could not be resolved
+    and the call was kept because its string looks like SQL.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "queries", limit, cursor, max_tokens, indexer.list_queries, path, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error listing queries", e)
+
+
+@_tool
+async def sql_schema(root_path: Optional[str] = None, table: Optional[str] = None, ref: Optional[str] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🧱 Show the database schema a project's .sql migrations add up to.
+
+    USE THIS to see the tables and columns the code runs against now: every
+    CREATE TABLE, ALTER TABLE (ADD/DROP/RENAME/MODIFY COLUMN), CREATE INDEX,
+    DROP and RENAME of the .sql files, folded in the order the migrations are
+    applied - golang-migrate (000001_x.up.sql), goose and dbmate
+    (20240105120000_x.sql), Flyway (V2__x.sql) - after plain schema files.
+    Down migrations (.down.sql, goose "-- +goose Down" sections, Flyway U
+    files) are left out. Postgres and MySQL syntax both parse; statements
+    that do not are listed in "unparsed" instead of failing the file.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - table: Optional table to return alone, with the history of its changes
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "tables": [
+            {
+                "name": "users",
+                "defined_at": {"path": ".../db/migrations/000001_create_users.up.sql", "line": 1},
+                "columns": [
+                    {"name": "id", "column_type": "BIGSERIAL", "primary_key": true, "nullable": false,
+                     "defined_at": {"path": ".../000001_create_users.up.sql", "line": 2}},
+                    {"name": "email", "column_type": "CITEXT", "nullable": false, "unique": true,
+                     "defined_at": {...}, "changed_at": {"path": ".../000003_email_citext.up.sql", "line": 1}}
+                ],
+                "indexes": [{"name": "users_email_idx", "columns": ["email"], "unique": true, "defined_at": {...}}],
+                "dropped_columns": [
+                    {"name": "legacy_flag", "defined_at": {...},
+                     "dropped_at": {"path": ".../000004_drop_legacy.up.sql", "line": 1}}
+                ]
+            }
+        ],
+        "total_count": 1,
+        "migrations": [
+            {"path": ".../000001_create_users.up.sql", "kind": "migration", "version": "1", "statements": 2},
+            {"path": ".../000001_create_users.down.sql", "kind": "migration", "version": "1", "down": true,
+             "statements": 1}
+        ],
+        "dropped_tables": [],
+        "unparsed": [{"path": ".../000005_proc.up.sql", "kind": "unparsed_statement", "line": 3, "column": 1,
+                      "message": "expected a name, found end of statement", "statement": "ALTER TABLE"}]
+    }
+
+    A renamed column or table is listed as dropped with "renamed_to".
+    "warnings" names statements on tables no earlier file creates.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, project=project)
+        return await _paged(indexer, "tables", limit, cursor, max_tokens, indexer.sql_schema, table, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error reading SQL schema", e)
+
+
+@_tool
+async def table_usages(table: str, root_path: Optional[str] = None, column: Optional[str] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🔗 Find the Go functions whose SQL reads or writes a table or column.
+
+    USE THIS before changing a table: it answers "which functions touch the
+    users table" from the queries list_queries finds. A query uses a table
+    it names after FROM, JOIN, UPDATE, INTO or DELETE FROM (CTE names are
+    not tables), and a column it names qualified (u.email, by alias or table
+    name), in an INSERT column list or SET, or unqualified when the column is
+    one of the table's in sql_schema. With column, queries selecting * are
+    kept too, marked "star".
+
+    INPUTS:
+    - table: Table name (case-insensitive)
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - column: Optional column of the table
+    - path: Optional file or directory to limit the Go scan to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "table": "users",
+        "defined_at": {"path": ".../db/migrations/000001_create_users.up.sql", "line": 1},
+        "queries": [
+            {
+                "query": "UPDATE users SET email = $1 WHERE id = $2",
+                "dynamic": false,
+                "function": "UserStore.SetEmail",
+                "path": "/Users/john/project/store/users.go",
+                "line": 42,
+                "column": 17,
+                "operation": "UPDATE",
+                "columns": ["email", "id"]
+            }
+        ],
+        "functions": [
+            {"function": "UserStore.SetEmail", "path": ".../store/users.go", "operations": ["UPDATE"], "queries": 1}
+        ],
+        "total_count": 1
+    }
+
+    "in_schema": false marks a table no .sql file of the project declares.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "queries", limit, cursor, max_tokens, indexer.table_usages, table, column, path, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error finding table usages", e)
+
+
+@_tool
+async def stale_queries(root_path: Optional[str] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🧟 Find Go queries still using tables or columns the migrations dropped or renamed.
+
+    USE THIS after a migration drops or renames a column, or before merging
+    one: every query list_queries finds is checked against the schema
+    sql_schema folds. A dropped or renamed table or column is reported with
+    the migration that removed it; a qualified column (u.nickname) the table
+    never had is reported as "unknown_column".
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: Optional file or directory to limit the Go scan to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "issues": [
+            {
+                "kind": "dropped_column",
+                "table": "users",
+                "column_name": "legacy_flag",
+                "defined_at": {"path": ".../000001_create_users.up.sql", "line": 6},
+                "dropped_at": {"path": ".../000003_drop_legacy.up.sql", "line": 1},
+                "query": "SELECT id, email, legacy_flag FROM users WHERE id = $1",
+                "function": "UserStore.Get",
+                "path": "/Users/john/project/store/users.go",
+                "line": 11,
+                "column": 14
+            }
+        ],
+        "total_count": 1,
+        "queries_checked": 12
+    }
+
+    Kinds: dropped_column, renamed_column, dropped_table, renamed_table (with
+    "renamed_to") and unknown_column. Queries with `{expr}` placeholders are
+    checked on their static parts only.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "issues", limit, cursor, max_tokens, indexer.stale_queries, path, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error checking queries against the schema", e)
+
+
+@_tool
+async def list_tasks(root_path: Optional[str] = None, path: Optional[str] = None, task: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🛠️ List the build entry points: Makefile targets and shell scripts, and what they run.
+
+    USE THIS to find out how a project is built, tested and released, or what
+    "make release" actually does: every target with its prerequisites and
+    recipe, every script and shell function, and what their commands run -
+    other targets ($(MAKE) -C docs html), scripts (./scripts/release.sh,
+    bash x.sh, source lib.sh), functions, and `go build/test/run/install`
+    resolved to the project's Go packages, main packages marked.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: Optional file or directory to limit the listing to
+    - task: Optional target, shell function or script (name, or path for a script): list it and
+      everything it runs, directly or not
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "tasks": [
+            {
+                "name": "build",
+                "kind": "make_target",
+                "path": "/Users/john/project/Makefile",
+                "line": 13,
+                "end_line": 15,
+                "doc": "Compile the server binary",
+                "phony": true,
+                "prerequisites": ["generate"],
+                "commands": [{"line": 15, "text": "$(GO) build -o $(BIN_DIR)/server ./cmd/server"}],
+                "runs": [
+                    {"kind": "go", "command": "go build", "line": 15, "output": "bin/server",
+                     "packages": [{"dir": "cmd/server", "package": "main", "import_path": "example.com/app/cmd/server",
+                                   "main": true, "path": "/Users/john/project/cmd/server/main.go"}]}
+                ],
+                "tools": [],
+                "run_by": [{"name": "all", "kind": "make_target", "path": "/Users/john/project/Makefile",
+                            "via": "prerequisite"}]
+            },
+            {
+                "name": "release.sh",
+                "kind": "script",
+                "path": "/Users/john/project/scripts/release.sh",
+                "line": 1,
+                "shebang": "bash",
+                "functions": ["build_all", "publish"],
+                "runs": [{"kind": "function", "name": "publish", "path": ".../scripts/release.sh", "line": 28}],
+                "tools": ["gh"],
+                "run_by": [{"name": "release", "kind": "make_target", "path": ".../Makefile", "via": "command"}]
+            }
+        ],
+        "total_count": 2,
+        "makefiles": 1,
+        "scripts": 1
+    }
+
+    Kinds: make_target, script, shell_function. Recipe `$(VAR)` references are
+    expanded from the Makefile and its includes; "default" marks the first
+    target of a Makefile, "pattern" a %-rule. Programs not resolved to a
+    target, script or Go package are listed under "tools".
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "tasks", limit, cursor, max_tokens, indexer.list_tasks, path, task, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error listing tasks", e)
+
+
+@_tool
+async def list_containers(root_path: Optional[str] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🐳 List the Dockerfiles and compose services, and the Go binary each container runs.
+
+    USE THIS to find out what is deployed and how: every Dockerfile with its
+    build stages, base images and tags, COPY/ADD sources, exposed ports,
+    ENTRYPOINT and CMD, and every compose service with its build context,
+    ports and environment. The program a container runs is traced back
+    through COPY --from to the `go build` that wrote it, naming the main
+    package, and the ports it exposes are checked against the addresses
+    the binary listens on.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: Optional Dockerfile, compose file or directory to limit the listing to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "containers": [
+            {
+                "kind": "dockerfile",
+                "name": "Dockerfile",
+                "path": "/Users/john/project/Dockerfile",
+                "line": 1,
+                "context": ".",
+                "stages": [
+                    {"name": "build", "index": 0, "line": 3, "base": {"image": "golang", "tag": "1.22-alpine"},
+                     "workdir": "/src", "env": {}, "expose": [],
+                     "copies": [{"line": 8, "sources": ["."], "dest": "/src"}],
+                     "go": [{"kind": "go", "command": "go build", "line": 9, "output": "/out/api",
+                             "packages": [{"dir": "cmd/api", "package": "main", "main": true, ...}],
+                             "binary": "/out/api"}]},
+                    {"name": "runtime", "index": 1, "line": 11,
+                     "base": {"image": "gcr.io/distroless/static", "tag": "nonroot", "digest": "sha256:..."},
+                     "workdir": "/", "env": {}, "expose": [{"port": 8080, "protocol": "tcp", "line": 13}],
+                     "copies": [{"line": 12, "sources": ["/out/api"], "dest": "/usr/local/bin", "from": "build"}],
+                     "go": [], "entrypoint": {"line": 15, "command": ["api"]}}
+                ],
+                "expose": [{"port": 8080, "protocol": "tcp", "line": 13}],
+                "runs": {
+                    "program": "api",
+                    "line": 15,
+                    "package": {"dir": "cmd/api", "package": "main", "import_path": "example.com/app/cmd/api",
+                                "main": true, "path": "/Users/john/project/cmd/api/main.go"},
+                    "built_at": {"line": 9, "stage": "build", "command": "go build",
+                                 "path": "/Users/john/project/Dockerfile"},
+                    "listens": [{"address": ":8080", "port": 8080, "call": "net/http.ListenAndServe",
+                                 "function": "Run", "path": ".../internal/server/server.go", "line": 15}]
+                },
+                "built_by": ["api"]
+            },
+            {
+                "kind": "compose_service",
+                "name": "api",
+                "path": "/Users/john/project/docker-compose.yml",
+                "line": 2,
+                "build": {"context": ".", "dockerfile": "Dockerfile", "target": "runtime"},
+                "ports": [{"port": 8080, "protocol": "tcp", "published": "80", "line": 6}],
+                "environment": [{"key": "DATABASE_URL", "line": 9}],
+                "runs": {...}
+            }
+        ],
+        "total_count": 2,
+        "port_mismatches": [
+            {"issue": "listening_not_exposed", "port": 9100, "container": "api", "kind": "compose_service",
+             "path": "/Users/john/project/docker-compose.yml",
+             "listener": {"address": ":9100", "call": "net/http.ListenAndServe", "function": "Run",
+                          "path": ".../internal/server/server.go", "line": 11}}
+        ]
+    }
+
+    A program is followed through COPY --from to the stage that built it;
+    "image" or "copied_from" say where it came from when that is no go build
+    of the Dockerfile. Ports are checked only for containers that declare
+    some, and exposed ports only when every listener's address is a
+    constant; a listener whose address comes from a flag or the environment
+    is listed with "dynamic". Environment values are left out, as compose
+    files can hold secrets.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "containers", limit, cursor, max_tokens, indexer.list_containers, path, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error listing containers", e)
+
+
+@_tool
+async def find_log_calls(root_path: Optional[str] = None, text: Optional[str] = None, level: Optional[str] = None, include_tests: Optional[bool] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📜 List a Go project's logging calls, or find the call site behind a production log line.
+
+    USE THIS with text when you have a log message and need the code that
+    wrote it: templates are matched with %s/%d verbs and runtime parts
+    standing for any value, so "user 42 not found: bob" finds
+    `log.Printf("user %d not found: %s", ...)`, and timestamps or slog/logrus
+    key=value pairs around the message don't get in the way. Covers log,
+    log/slog, logrus, zap (Logger and SugaredLogger) and the project's own
+    loggers: methods like `Logger.Log` on a type named like a logger, and
+    short functions passing their parameters on to a logging call, whose
+    callers get the inner template with their arguments filled in.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - text: Optional log line or fragment; only calls that could have written it, best match first
+    - level: Only calls at this level: trace, debug, info, warn, error, dpanic, panic or fatal
+    - include_tests: Also list calls in _test.go files (default: .xray.yaml, else false); their entries get "in_test"
+    - path: Optional file or directory to limit the listing to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "log_calls": [
+            {"path": "/Users/john/project/main.go", "line": 34, "column": 6, "message": "user %d not found: %s",
+             "dynamic": false, "level": null, "style": "printf", "format_args": ["id", "name"],
+             "symbol": "Server.Handle", "symbol_type": "method", "library": "log", "api": "log.Printf",
+             "confidence": "high", "match": "whole", "matched_chars": 17},
+            {"path": "/Users/john/project/main.go", "line": 36, "column": 7, "message": "cache miss", "dynamic": false,
+             "level": "info", "style": "kv", "fields": [{"key": "key", "value": "name"}],
+             "symbol": "Server.Handle", "symbol_type": "method", "library": "slog", "api": "log/slog.Info",
+             "confidence": "high", "match": "part", "matched_chars": 5}
+        ],
+        "total_count": 2,
+        "counts": {"library": {"log": 1, "slog": 1}, "level": {"none": 1, "info": 1}},
+        "wrappers": [{"name": "main.Logger.Log", "path": "/Users/john/project/main.go", "line": 119, "wraps": null}],
+        "query": "user 42 not found: bob"
+    }
+
+    "style" is how the message is built: "printf" (with "format_args"),
+    "print"/"println" (arguments joined), "kv" (key-value pairs or slog
+    attributes) or "fields" (zap fields); "message" shows runtime parts as
+    `{expr}`. "match" is "whole" (the text holds the whole message), "part"
+    (the text lies within it) or "overlap"; "matched_fields" lists the keys
+    of the call's fields the text has as key=value. "confidence" is
+    "medium" for a chained call (`logrus.WithField(...).Error`) recognized
+    by its package rather than its type.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        return await _paged(indexer, "log_calls", limit, cursor, max_tokens, indexer.find_log_calls, text, level,
+                            include_tests, path, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error finding log calls", e)
+
+
+@_tool
+async def format_strings(root_path: Optional[str] = None, include_tests: Optional[bool] = None, path: Optional[str] = None, symbol: Optional[str] = None, mismatched_only: bool = False, format: str = "json", ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🧮 Check a Go project's printf-style calls: format verbs against the arguments passed, and the format strings each function uses.
+
+    USE THIS to find `fmt.Sprintf("%s: %d", name)` before it prints
+    %!d(MISSING) in production, or to list which messages a function can
+    produce. Covers fmt's Printf, Sprintf, Fprintf, Errorf, Appendf and
+    Scanf family, log's Printf/Fatalf/Panicf and testing's Errorf/Fatalf/
+    Logf/Skipf. Formats held in constants or locals are followed; verbs
+    are counted the way fmt reads them (`*` width and precision take an
+    argument, %% none, %[2]d jumps to the second).
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - include_tests: Also check calls in _test.go files (default: .xray.yaml, else false); their entries get "in_test"
+    - path: Optional file or directory to limit the check to
+    - symbol: Only calls made in this function or method ("Server.Index")
+    - mismatched_only: Only calls whose arguments do not fit their format (default false)
+    - format: "json" (default) or "sarif" for a SARIF 2.1.0 log of the mismatches (not paged)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "calls": [
+            {"call": "log.Printf", "path": "/Users/john/project/web/server.go", "line": 37, "column": 7,
+             "function": "Server.Index", "format": "render %s: %v", "verbs": ["%s", "%v"],
+             "expected_args": 2, "args": 1, "status": "missing_args",
+             "message": "format reads 2 arguments but the call passes 1"},
+            {"call": "fmt.Sprintf", "path": "/Users/john/project/web/server.go", "line": 40, "column": 13,
+             "function": "Server.Index", "format": "%s <%s>", "format_expr": "rowFormat", "verbs": ["%s", "%s"],
+             "expected_args": 2, "args": 2, "status": "ok"}
+        ],
+        "total_count": 2,
+        "mismatch_count": 1,
+        "counts": {"ok": 1, "missing_args": 1, "extra_args": 0, "bad_format": 0, "dynamic": 0, "spread": 0},
+        "symbols": [{"function": "Server.Index", "path": "/Users/john/project/web/server.go",
+                     "formats": ["render %s: %v", "%s <%s>"]}]
+    }
+
+    "status" is "ok", "missing_args", "extra_args" (not reported when an
+    explicit index reorders the arguments), "bad_format" (a `%` without
+    a verb, a bad index), or "dynamic" / "spread" when the format is built
+    at runtime or the arguments come as `args...` and the call cannot be
+    checked. "format_expr" is the constant or local the format was read
+    from; package-level calls have "function": null. With format="sarif"
+    each mismatch is an XRAY005 result.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        if format == "sarif":
+            return await _run(indexer, indexer.format_strings, include_tests, path, symbol, mismatched_only, format,
+                              ctx=ctx, timeout_ms=timeout_ms, present=format != "sarif")
+        return await _paged(indexer, "calls", limit, cursor, max_tokens, indexer.format_strings, include_tests, path,
+                            symbol, mismatched_only, format, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error checking format strings", e)
+
+
+@_tool
+async def run_rules(root_path: Optional[str] = None, rules: Optional[List[Dict[str, Any]]] = None, only: Optional[List[str]] = None, builtin: bool = False, include_tests: Optional[bool] = None, path: Optional[str] = None, format: str = "json", ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    👮 Check a Go project against its house rules: calls, imports and type assertions it forbids or requires, declared in .xray.yaml.
+
+    USE THIS for rules too specific to hard-code: "nobody calls time.Now
+    outside the clock package", "every handler calls auth.Check", "no
+    imports of github.com/pkg/errors". Each rule names a match - a call
+    (glob on "time.Now", "auth.Check", "example.com/app/db.DB.Query"), an
+    import path or an asserted type - optional scoping (packages, function
+    names, exported_only, signature) and a polarity: "forbidden" reports
+    every match in scope, "required" every function in scope without one
+    (transitive: true accepts a call made by a function it calls). Without
+    rules in the configuration, built-in examples run: no-unsafe,
+    no-exit-outside-main, no-print-outside-main and
+    test-helpers-call-helper.
+
+    .xray.yaml:
+        rules:
+          - {id: clock-only, call: time.Now, exclude_packages: ["*/clock"], message: "use clock.Now"}
+          - {id: handlers-auth, call: auth.Check, polarity: required, signature: "*http.ResponseWriter*"}
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - rules: Optional rule mappings to run instead of the configured ones, same fields as .xray.yaml
+    - only: Optional rule ids to run, e.g. ["clock-only"]
+    - builtin: Also run the built-in example rules next to the configured ones (default false)
+    - include_tests: Also check _test.go files (default: .xray.yaml, else false); their findings get "in_test"
+    - path: Optional file or directory to limit the check to
+    - format: "json" (default) or "sarif" for a SARIF 2.1.0 log of the findings (not paged)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "rules": [
+            {"id": "clock-only", "polarity": "forbidden", "severity": "warning", "source": "config",
+             "call": ["time.Now"], "finding_count": 1},
+            {"id": "handlers-auth", "polarity": "required", "severity": "warning", "source": "config",
+             "call": ["auth.Check"], "checked": 4, "finding_count": 1}
+        ],
+        "findings": [
+            {"rule": "clock-only", "severity": "warning", "polarity": "forbidden", "message": "use clock.Now",
+             "detail": "Server.Index calls time.Now", "path": "/Users/john/project/web/server.go", "line": 31,
+             "column": 9, "function": "Server.Index", "package": "example.com/app/web", "match": "time.Now"},
+            {"rule": "handlers-auth", "severity": "warning", "polarity": "required",
+             "message": "HandleExport does not call auth.Check", "path": "/Users/john/project/web/export.go",
+             "line": 12, "function": "HandleExport", "package": "example.com/app/web"}
+        ],
+        "total_count": 2,
+        "message": "2 findings from 2 of 2 rules: clock-only, handlers-auth"
+    }
+
+    "detail" says what broke a rule declaring its own "message". Package
+    globs match a package's import path, its directory relative to the
+    root or its name ("main"). A forbidden call also matches a
+    function value (`now := time.Now`) and a package-level call. Required
+    import rules report every package none of whose files import a match.
+    Invalid rules in the configuration are reported as a config error.
+    With format="sarif" each finding is an XRAY006 result whose message
+    starts with the rule id.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        if format == "sarif":
+            return await _run(indexer, indexer.run_rules, rules, only, builtin, include_tests, path, format,
+                              ctx=ctx, timeout_ms=timeout_ms, present=False)
+        return await _paged(indexer, "findings", limit, cursor, max_tokens, indexer.run_rules, rules, only, builtin,
+                            include_tests, path, format, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error running rules", e)
+
+
+@_tool
+async def template_usage(root_path: Optional[str] = None, member: Optional[str] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🧩 List the project's Go templates (text/template, html/template) with the fields and functions they use, linked to the structs they render.
+
+    USE THIS before renaming or removing a struct field - "which templates
+    use User.Email" - since the compiler never sees `{{.Email}}`, or to
+    find the template a handler renders. Reads template files (.tmpl,
+    .gotmpl, .gohtml, ... and the .html files ParseFiles/ParseGlob/ParseFS
+    name) and template strings parsed in Go code
+    (`template.Must(template.New("page").Parse(...))`), with each
+    {{define}} and {{block}} as a template of its own. The data passed at
+    each Execute/ExecuteTemplate call gives the template's dot a type;
+    {{template "row" .Item}} hands the type of .Item on.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - member: Optional "Type.Field" or "Type.Method" ("User.Email", "store.User.Email"), or a type
+      name for any of its members: only templates using it
+    - path: Optional file or directory to limit the listing to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "templates": [
+            {"name": "row", "kind": "define", "path": "/Users/john/project/web/templates/row.gohtml", "line": 1,
+             "engine": "html/template", "invokes": [],
+             "functions": [{"name": "len", "kind": "builtin", "line": 1}],
+             "fields": [{"ref": ".Name", "line": 1, "path": "Name"}, {"ref": ".Email", "line": 1, "path": "Email"},
+                        {"ref": ".Phone", "line": 1, "path": "Phone"}],
+             "executed_with": [{"type": "store.User", "path": "/Users/john/project/web/templates/index.gohtml",
+                                "line": 4, "template": "index.gohtml"}],
+             "uses": [{"member": "Contact.Email", "kind": "field", "path": "/Users/john/project/store/user.go",
+                       "line": 3, "package": "store", "lines": [1], "read_as": ["User.Email"]}],
+             "missing": [{"ref": ".Phone", "type": "store.User", "missing": "Phone", "line": 1}]}
+        ],
+        "total_count": 1,
+        "missing_count": 1,
+        "execute_sites": [
+            {"path": "/Users/john/project/web/server.go", "line": 36, "column": 19, "function": "Server.Index",
+             "method": "ExecuteTemplate", "argument": "p", "template": "index.gohtml", "data_type": "web.Page"}
+        ],
+        "parseErrors": [],
+        "query": "User.Email"
+    }
+
+    "kind" is "file" or "string" for a body, "define" or "block";
+    "engine" is the template package that parses it (null for a file no
+    Go code parses). Field paths are relative to the template's dot, `[]`
+    marking a range element. "uses" has the members the paths resolve to,
+    under the type declaring them ("read_as" when promoted from an
+    embedded struct); "missing" the paths a type it is executed with does
+    not have - executing the template fails there. A function's "kind" is
+    "builtin", "registered" (through Funcs) or "unknown". An Execute call
+    on a template value that cannot be followed has "set_unknown": true.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "templates", limit, cursor, max_tokens, indexer.template_usage, member, path,
+                            ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error listing templates", e)
+
+
+@_tool
+async def init_analysis(root_path: Optional[str] = None, include_tests: Optional[bool] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🚦 What a Go program runs before main: init functions, initialized package variables and blank imports.
+
+    USE THIS when startup misbehaves or before moving code into init-time
+    state. Packages are listed in the order Go initializes them, each with
+    its init functions and the variables whose initializer calls something,
+    with the side effects of that code - "io" (files, network, databases),
+    "env" (environment variables) and "panic" (panic, Must* helpers,
+    log.Fatal, os.Exit) - found directly or through the project functions it
+    calls ("via"). Blank imports (`import _ "..."`) show the init code they
+    trigger, or what a well-known library registers (database drivers,
+    pprof handlers, image decoders).
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - include_tests: Also count _test.go files, whose init code only runs in tests (default: .xray.yaml, else false)
+    - path: Optional directory to limit the packages and blank imports listed to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "packages": [
+            {
+                "package": "github.com/john/project/config",
+                "dir": "config",
+                "order": 1,
+                "init_functions": [
+                    {"path": "/Users/john/project/config/config.go", "line": 11, "calls": ["load"],
+                     "effects": [{"kind": "io", "call": "os.ReadFile", "path": "/Users/john/project/config/config.go",
+                                  "line": 14, "via": ["config.load"]}]}
+                ],
+                "variables": [
+                    {"name": "Port", "path": "/Users/john/project/config/config.go", "line": 8, "calls": ["os.Getenv"],
+                     "effects": [{"kind": "env", "call": "os.Getenv", "path": "/Users/john/project/config/config.go",
+                                  "line": 8}]}
+                ],
+                "imports": [],
+                "flagged": {"io": 1, "env": 1, "panic": 0}
+            }
+        ],
+        "order": ["github.com/john/project/config", "github.com/john/project/registry", "github.com/john/project"],
+        "blank_imports": [
+            {"path": "/Users/john/project/main.go", "line": 10, "import": "github.com/lib/pq",
+             "importer": "github.com/john/project", "in_project": false,
+             "effect": "registers the \"postgres\" database/sql driver"}
+        ],
+        "counts": {"init_functions": 1, "variables": 1, "io": 1, "env": 1, "panic": 0}
+    }
+
+    "order" is the initialization order of every package: a package runs
+    after all it imports, ties going to the lowest import path. A project
+    package imported blank lists its "init_functions", "variables" and the
+    other project packages with init code it "pulls_in".
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        return await _run(indexer, indexer.init_analysis, include_tests, path, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error analyzing initialization", e)
+
+
+@_tool
+async def config_usage(root_path: Optional[str] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🎛️ What knobs does this service have? Every env var, flag and viper key it reads.
+
+    USE THIS to document a service's configuration or to check a deployment
+    sets everything. Finds os.Getenv/os.LookupEnv, flag and pflag
+    definitions (flag.Int, fs.StringVar, cmd.Flags().StringP, ...) and
+    viper.Get*/IsSet, plus project helpers like getEnv(key, fallback) that
+    wrap an env read. Each key comes with its default when one is visible
+    (flag default, helper fallback, viper.SetDefault), the functions reading
+    it, and whether its value decides an if or switch ("control_flow").
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: Optional file or directory to limit the scan to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Keys per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    With .env.example (.sample, .template, .dist) or docker compose files in
+    the tree, "declarations" lists the env vars read in code but declared in
+    none of them, and the declared ones no code reads (viper keys count
+    through their BindEnv names). Declared values are never returned.
+
+    EXAMPLE OUTPUT:
+    {
+        "keys": [
+            {"key": "DEBUG", "source": "env", "dynamic": false, "default": null, "control_flow": true,
+             "reads": [{"api": "os.Getenv", "function": "main", "path": "/Users/john/project/main.go",
+                        "line": 33, "column": 8, "control_flow": true}]},
+            {"key": "port", "source": "flag", "dynamic": false, "default": "8080", "control_flow": false,
+             "reads": [{"api": "flag.Int", "function": null, "package_var": "port", "default": "8080", ...}]},
+            {"key": "prefix + name", "source": "env", "dynamic": true, "default": null, ...}
+        ],
+        "total_count": 3,
+        "dynamic_count": 1,
+        "declarations": {
+            "files": [{"path": "/Users/john/project/.env.example", "kind": "env_file", "keys": 4}],
+            "missing_from_files": [{"key": "DEBUG", "source": "env",
+                                    "read_at": [{"path": "/Users/john/project/main.go", "line": 33}]}],
+            "not_read_in_code": [{"path": "/Users/john/project/.env.example", "key": "LEGACY_MODE", "line": 7}]
+        }
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "keys", limit, cursor, max_tokens, indexer.config_usage, path,
+                            ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error finding configuration keys", e)
+
+
+@_tool
+async def list_grpc_services(root_path: Optional[str] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📡 List the gRPC services of the .proto files - rpc by rpc, with the Go types serving them.
+
+    USE THIS to go from an API definition to its Go server: every rpc lists
+    the methods implementing it. Generated code is located through the
+    `// source:` header of the .pb.go files (or the go_package option), and
+    implementations are the types satisfying the generated FooServer
+    interface. Generated files are read even without include_generated.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: Optional .proto file or directory to limit the listing to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "services": [
+            {
+                "name": "UserService",
+                "full_name": "acme.users.v1.UserService",
+                "path": "/Users/john/project/proto/users/v1/users.proto",
+                "line": 12,
+                "go_package": {"import_path": "example.com/acme/gen/users/v1", "name": "usersv1", ...},
+                "generated": {"server": {"name": "UserServiceServer", "path": ".../users_grpc.pb.go", ...},
+                              "client": {...}, "unimplemented": {...}},
+                "methods": [
+                    {"name": "GetUser", "go_name": "GetUser", "request": "acme.users.v1.GetUserRequest",
+                     "response": "acme.users.v1.User", "client_streaming": false, "server_streaming": false,
+                     "implementations": [{"type": "userServer", "path": "/Users/john/project/server/users.go", "line": 21}]}
+                ],
+                "implementations": [
+                    {"name": "userServer", "satisfied_by": "*userServer", "methods": {"GetUser": {...}},
+                     "unimplemented": ["DeleteUser"], ...}
+                ],
+                "matched_by": "server_interface"
+            }
+        ],
+        "total_count": 1,
+        "method_count": 2
+    }
+
+    "unimplemented" lists rpcs a type only inherits from the embedded
+    UnimplementedFooServer. Without generated code in the tree,
+    "matched_by" is "method_names": types that embed UnimplementedFooServer
+    or have a method for every rpc.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "services", limit, cursor, max_tokens, indexer.list_grpc_services, path, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error listing gRPC services", e)
+
+
+@_tool
+async def find_unused(root_path: Optional[str] = None, include_exported: bool = False, format: str = "json", ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🧹 Find dead Go code - package-level symbols nothing references.
+
+    Covers functions, methods, types, constants and variables. main in
+    package main, init, Test/Benchmark/Example/Fuzz functions, methods
+    required by an interface that is itself used, and well-known interface
+    methods (String, Error, ServeHTTP, MarshalJSON, ...) are never reported.
+    An unexported name counts as mentioned only within its own package.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - include_exported: Also report exported symbols of library packages
+      (off by default, since other modules may use them)
+    - format: "json" (default) or "sarif" for a SARIF 2.1.0 log to upload to code scanning
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "files": [
+            {
+                "path": "/Users/john/project/main.go",
+                "package": "main",
+                "unused": [
+                    {"name": "ProcessData", "type": "function", "start_line": 77,
+                     "confidence": "high", "reason": "never mentioned outside its declaration", ...}
+                ]
+            }
+        ],
+        "total_count": 1,
+        "include_exported": false
+    }
+
+    Confidence: "high" = the name appears nowhere else; "medium" = only tests
+    use it; "low" = the name appears but no call resolves to this symbol.
+    With format="sarif" each becomes an XRAY001 result, level "warning" for
+    high confidence and "note" otherwise.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.find_unused, include_exported, format, ctx=ctx, timeout_ms=timeout_ms, present=format != "sarif")
+    except Exception as e:
+        return _error("Error finding unused symbols", e)
+
+
+@_tool
+async def global_usages(root_path: Optional[str] = None, *, symbol: str, path: Optional[str] = None, format: str = "json", ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🌐 Track where a Go package-level variable is read and written.
+
+    Writes include plain assignment, map/slice element assignment
+    (`userCache[id] = user`), field assignment, ++/-- and taking the address
+    (`&config`). Accesses from other files of the same package and, for
+    exported globals, from importing packages are included.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - symbol: The variable name (e.g. "userCache"), or its symbol_id
+    - path: Optional file or package directory to pick one of several same-named variables
+    - format: "json" (default) or "sarif" for a SARIF 2.1.0 log of concurrent writes
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "symbol": {"name": "logger", "path": "/Users/john/project/main.go", "start_line": 37, ...},
+        "accesses": [
+            {"path": ".../main.go", "line": 89, "column": 5, "function": "processUser",
+             "access": "read", "kind": "call"},
+            {"path": ".../main.go", "line": 124, "column": 5, "function": "main",
+             "access": "write", "kind": "assign"}
+        ],
+        "reads": 1,
+        "writes": 1,
+        "written_from": ["main"],
+        "read_from": ["processUser"]
+    }
+
+    "concurrent_writes": true is added when the variable is written from more
+    than one context and at least one of them runs on its own goroutine.
+    With format="sarif" that is one XRAY003 result at the declaration, the
+    writes as related locations; a variable without it yields no results.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.global_usages, symbol, path, format, ctx=ctx, timeout_ms=timeout_ms, present=format != "sarif")
+    except Exception as e:
+        return _error("Error tracking global usages", e)
+
+
+@_tool
+async def field_usages(root_path: Optional[str] = None, *, symbol: str, path: Optional[str] = None, snippet_lines: Optional[int] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🏷️ Find every use of a Go struct field - before deleting or changing it.
+
+    USE THIS to learn whether a field is actually used. Selectors are typed
+    through the call graph, so `u.Email` counts only when u is the field's
+    struct (or embeds it). Each access is a "read" or a "write", with how:
+    - read: a selector reading it, or "encoded" - a value holding the field
+      passed to json/xml/yaml/... Marshal or Encode, under its tag's "key"
+    - write: assign, compound_assign, increment, index_assign, field_assign,
+      address_of ("scan_destination" inside rows.Scan(&u.Email)),
+      struct_literal (keyed, or positional with its "position"), and
+      "decoded" - Unmarshal/Decode, and sqlx-style row scans by db tag
+    - unknown: "reflection" - the name as a string given to FieldByName, or
+      in a function that calls into reflect
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - symbol: "Type.Field" (e.g. "User.Email"), or its symbol_id
+    - path: Optional file or package directory to pick one of several same-named types
+    - snippet_lines: Inline each access's line plus this many lines of context either side
+      (0-20; default: no snippets), as find_callers does
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "symbol": {"name": "Email", "qualified_name": "User.Email", "path": ".../main.go", "start_line": 15,
+                   "tags": {"json": {"name": "email", "options": []}}, ...},
+        "accesses": [
+            {"path": ".../main.go", "line": 53, "column": 99, "function": "UserService.GetUser",
+             "access": "write", "kind": "scan_destination", "call": "Scan"},
+            {"path": ".../main.go", "line": 111, "column": 24, "function": "userHandler",
+             "access": "read", "kind": "encoded", "call": "encoding/json.NewEncoder.Encode",
+             "format": "json", "key": "email", "via": "User"}
+        ],
+        "reads": 1,
+        "writes": 1,
+        "written_from": ["UserService.GetUser"],
+        "read_from": ["userHandler"],
+        "unresolved": []
+    }
+
+    A field written but never read gets "written_only": true and a message:
+    its writes are probably dead. One with no accesses at all gets "unused".
+    "unresolved" lists selectors with the field's name whose receiver could
+    not be typed - check those before deleting.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.field_usages, symbol, path, snippet_lines, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error finding field usages", e)
+
+
+@_tool
+async def audit_context(root_path: Optional[str] = None, include_unexported: bool = False, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🧭 Audit context.Context propagation: find where Go code drops a context it should pass on.
+
+    USE THIS to enforce "every exported function doing I/O takes a
+    context.Context first". Two lists:
+    - "missing_context": exported functions and methods without a context
+      parameter that call something accepting one - database/sql methods
+      with a *Context variant (QueryRow -> QueryRowContext), http.Get and
+      http.NewRequest, net.Dial, exec.Command, project functions taking a
+      context first, and any call handed a context as its first argument
+      (gRPC clients)
+    - "background_calls": context.Background()/context.TODO() outside main()
+      and tests. "context_available" means the function had a context of
+      its own and made a fresh one anyway
+
+    Both carry "context_available_in": call chains from the nearest callers
+    that do have a context (a context.Context parameter, or r.Context() of
+    an *http.Request) down to the function where it was dropped.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - include_unexported: Also check unexported functions for a missing context (default false)
+    - path: Optional file or directory to limit the audit to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "missing_context": [
+            {
+                "function": "UserService.GetUser",
+                "package": "main",
+                "path": "/Users/john/project/service.go",
+                "line": 47,
+                "signature": "func (s *UserService) GetUser(id int) (*User, error)",
+                "calls": [
+                    {"line": 53, "column": 14, "call": "database/sql.DB.QueryRow", "use_instead": "QueryRowContext"}
+                ],
+                "context_available_in": [
+                    {"context": "r.Context()", "chain": [
+                        {"function": "userHandler", "path": ".../handlers.go", "line": 105,
+                         "calls": "UserService.GetUser", "context": "r.Context()"}
+                    ]}
+                ]
+            }
+        ],
+        "background_calls": [
+            {"function": "Sync", "package": "store", "path": ".../sync.go", "line": 12, "column": 20,
+             "call": "context.Background", "passed_to": "s.client.Fetch", "context_available": "ctx"}
+        ],
+        "counts": {"missing_context": 1, "background_calls": 1, "dropped": 1}
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.audit_context, include_unexported, path, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error auditing context propagation", e)
+
+
+@_tool
+async def audit_errors(root_path: Optional[str] = None, include_tests: Optional[bool] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🩹 Audit Go error handling: dropped errors, errors returned without context, and the project's error values.
+
+    USE THIS to plan an error-handling cleanup. Findings, grouped by package
+    (packages with the most severe first) and sorted high, medium, low:
+    - "dropped_error": a call whose last result is an error, made as a
+      statement (`s.db.Exec(...)`, "how": "statement", high), with the error
+      assigned to `_` ("blank", medium) or deferred/run as a goroutine
+      ("defer"/"go", low). Callees come from the project's declarations and
+      the standard library; methods of other modules named Close, Write,
+      Flush, Scan, ... are "confidence": "likely" and one severity lower
+    - "unwrapped_return": `return err` or `return f()` handing back a
+      callee's error unchanged. "chain" lists the functions passing it on
+      down to "origin", the call it came from: a library error is high where
+      it enters the project and medium in every frame above; an error made
+      in the project is low once it crosses 3 frames unchanged
+
+    "error_definitions" lists each package's sentinel errors (`var ErrX =
+    errors.New(...)`) and types with an Error() string method, with where
+    they are "created" (returned, wrapped, composite literals) and "checked"
+    (errors.Is, errors.As, ==, type assertions and switches).
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - include_tests: Also scan _test.go files (default: .xray.yaml, else false); their entries get "in_test"
+    - path: Optional file or directory to limit the audit to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "packages": [
+            {
+                "package": "main",
+                "directory": "/Users/john/project",
+                "findings": [
+                    {"kind": "unwrapped_return", "severity": "high", "function": "UserService.GetUser",
+                     "path": ".../main.go", "line": 55, "column": 21, "expression": "err",
+                     "from": "s.db.QueryRow().Scan", "frames": 1, "chain": ["UserService.GetUser"],
+                     "origin": {"call": "s.db.QueryRow().Scan", "path": ".../main.go", "line": 53},
+                     "external": true},
+                    {"kind": "dropped_error", "severity": "high", "function": "main", "path": ".../main.go",
+                     "line": 128, "column": 10, "call": "net/http.ListenAndServe", "how": "statement"}
+                ],
+                "error_definitions": [
+                    {"kind": "sentinel", "name": "ErrNotFound", "path": ".../store.go", "start_line": 10,
+                     "value": "errors.New(\"not found\")",
+                     "created": [{"function": "Store.Get", "path": ".../store.go", "line": 30}],
+                     "checked": [{"function": "run", "path": ".../main.go", "line": 20, "how": "errors.Is"}]}
+                ],
+                "counts": {"high": 2, "medium": 0, "low": 0}
+            }
+        ],
+        "total_count": 2,
+        "counts": {"high": 2, "medium": 0, "low": 0, "dropped_error": 1, "unwrapped_return": 1},
+        "include_tests": false
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        return await _run(indexer, indexer.audit_errors, include_tests, path, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error auditing error handling", e)
+
+
+@_tool
+async def audit_resources(root_path: Optional[str] = None, include_tests: Optional[bool] = None, path: Optional[str] = None, list_defers: bool = False, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🚰 Audit Go resource handling: files, rows, transactions, responses, cancel functions and locks not released on every path, and defers in loops.
+
+    USE THIS when hunting a file-descriptor, connection or goroutine leak,
+    or a lock held too long. Resources come from the standard library calls
+    the call graph resolves (os.Open/Create, sql Query/Prepare/Begin, net.Dial
+    and Listen, http.Get and http.Client.Do, context.WithCancel/Timeout/
+    Deadline, time.NewTicker) and from x.Lock()/x.RLock(). Findings, high
+    first:
+    - "discarded": the result holding the resource is not kept (`ctx, _ :=
+      context.WithTimeout(...)`, `db.Query(q)` as a statement)
+    - "never_released": nothing releases it, deferred or not (medium for a lock)
+    - "released_on_some_paths": released without a defer, but
+      "unreleased_exits" lists the returns (or the end) reached without the
+      release; the `if err != nil` right after the acquisition is exempt
+    - "defer_in_loop": the defer runs when the function returns, not per
+      iteration (high for a deferred Unlock of a lock taken in the loop)
+
+    A resource that is returned, stored, sent, appended or used by a
+    goroutine or a closure is left to whoever has it, and a release in a
+    defer or func literal covers every path. Each finding names the
+    acquisition ("acquired", "call") and the "release" it did not find; this
+    is a heuristic, so a `//xray:ignore` comment on the line (or the line
+    above) suppresses one, counted in "suppressed_count". "defers" counts
+    every defer statement by what it defers: close, unlock, cancel,
+    rollback, stop, done, recover or other.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - include_tests: Also scan _test.go files (default: .xray.yaml, else false)
+    - path: Optional file or directory to limit the audit to
+    - list_defers: Also list every defer statement in "defer_statements"
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 10)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "findings": [
+            {"kind": "never_released", "severity": "high", "resource": "rows", "function": "Store.Count",
+             "path": ".../store.go", "line": 34, "column": 20, "acquired": "s.db.QueryContext(ctx, q)",
+             "call": "database/sql.DB.QueryContext", "variable": "rows", "release": "rows.Close()",
+             "reason": "no rows.Close() follows, deferred or not, and rows does not leave the function"},
+            {"kind": "released_on_some_paths", "severity": "medium", "resource": "lock",
+             "function": "Store.Count", "path": ".../store.go", "line": 32, "column": 7,
+             "acquired": "s.mu.Lock()", "call": "sync.Mutex.Lock", "variable": "s.mu",
+             "release": "s.mu.Unlock()", "released_at": [42], "unreleased_exits": [{"line": 36, "exit": "return"}],
+             "reason": "s.mu.Unlock() is called but not deferred, and the return at line 36 comes without it"}
+        ],
+        "total_count": 2,
+        "counts": {"high": 1, "medium": 1, "low": 0, "discarded": 0, "never_released": 1,
+                   "released_on_some_paths": 1, "defer_in_loop": 0},
+        "defers": {"total": 12, "by_kind": {"close": 7, "unlock": 3, "cancel": 2, "rollback": 0, "stop": 0,
+                                            "done": 0, "recover": 0, "other": 0}, "in_loops": 0},
+        "suppressed_count": 0,
+        "include_tests": false,
+        "next_cursor": null
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        return await _paged(indexer, "findings", limit, cursor, max_tokens, indexer.audit_resources, include_tests,
+                            path, list_defers, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error auditing resources", e)
+
+
+@_tool
+async def concurrency_map(root_path: Optional[str] = None, function: Optional[str] = None, channel: Optional[str] = None, path: Optional[str] = None, depth: int = 10, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🧵 Map where Go code starts goroutines and how its channels are used.
+
+    USE THIS when chasing a deadlock, a leaked goroutine or a data race:
+    - function: every goroutine launched from that function, directly or
+      through anything it calls, with the call path ("via") to each
+    - channel: every place one channel variable is used - sends, receives,
+      select cases, range loops and close calls - including inside the
+      functions it is passed to as an argument
+    - neither: every launch site and channel declaration in the project
+
+    Launch sites are `go` statements and `x.Go(func() {...})` calls
+    (errgroup, sync.WaitGroup). Channels are struct fields, package
+    variables, locals and parameters, with direction, element type and
+    buffer size where they are made.
+
+    ⚠️ A goroutine closure that reads an enclosing loop variable lists it
+    in "captures_loop_vars". Before Go 1.22 every iteration shares that
+    variable, so "loop_var_bug" is true unless go.mod says go 1.22 or
+    later ("loop_semantics" tells which applies). Passing the variable as
+    an argument or re-declaring it (`v := v`) in the loop is not flagged.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - function: Optional function or method ("Start" or "Pool.Start"), or its symbol_id
+    - channel: Optional channel variable: "jobs", "Pool.jobs" (field of Pool)
+               or "Fan.out" (local of function Fan); not with function
+    - path: Optional file or package directory to disambiguate a name, or to
+            scope the overview
+    - depth: How many calls deep to follow from function (default 10)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT (channel="Pool.jobs"):
+    {
+        "go_version": "1.21",
+        "loop_semantics": "shared",
+        "channel": {"name": "Pool.jobs", "kind": "field", "package": "worker", "type": "Pool"},
+        "declarations": [
+            {"kind": "field", "path": ".../pool.go", "line": 15, "direction": "both", "element_type": "Job"},
+            {"kind": "make", "path": ".../pool.go", "line": 22, "function": "NewPool", "buffer": "n", ...}
+        ],
+        "uses": [
+            {"op": "close", "path": ".../pool.go", "line": 32, "column": 3, "function": "Pool.Start",
+             "in_goroutine": true, "goroutine_line": 30},
+            {"op": "range", "path": ".../pool.go", "line": 37, "column": 19, "function": "Pool.worker"},
+            {"op": "send", "path": ".../pool.go", "line": 48, "column": 2, "function": "Pool.Submit"}
+        ],
+        "passed_to": [],
+        "senders": ["Pool.Submit"],
+        "receivers": ["Pool.worker"],
+        "closers": ["Pool.Start"],
+        "counts": {"send": 1, "receive": 0, "range": 1, "close": 1},
+        "loop_captures": 0
+    }
+
+    With function, "goroutines" lists launch sites with "kind" ("literal" or
+    "call", the latter with the "launched" function), "depth", "via" and
+    "on_goroutine" when the path to them already crossed a `go`. Uses inside
+    a select carry "select_line"; uses reached through a parameter, "via".
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.concurrency_map, function, channel, path, depth, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error mapping concurrency", e)
+
+
+@_tool
+async def find_failure_points(root_path: Optional[str] = None, include_tests: Optional[bool] = None, path: Optional[str] = None, kinds: Optional[List[str]] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    💥 Answer "where can this service die?" - every panic, exit and unchecked type assertion in Go code.
+
+    USE THIS for reliability reviews. Kinds:
+    - "panic": panic(...) with its argument, and log.Panic*
+    - "fatal": log.Fatal* and the Fatal methods of loggers (log.Logger,
+      logrus, zap); t.Fatal in tests only fails the test and is skipped
+    - "exit": os.Exit, syscall.Exit, runtime.Goexit
+    - "recover": recover() calls, with the deferred literal they sit in
+      ("defer_line") or the functions that defer them ("deferred_by");
+      "effective": false when neither, since recover() then does nothing
+    - "type_assertion": `x.(T)` without the comma-ok form, which panics when
+      x holds another type (`v, ok := x.(T)` and type switches are fine)
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - include_tests: Also scan _test.go files (default: .xray.yaml, else false); their entries get "in_test"
+    - path: Optional file or directory to limit the search to
+    - kinds: Optional list of kinds to report (default all)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "packages": [
+            {
+                "package": "main",
+                "directory": "/Users/john/project",
+                "failure_points": [
+                    {"kind": "type_assertion", "function": "ProcessData", "path": ".../main.go",
+                     "line": 81, "column": 10, "expression": "data.(map[string]any)",
+                     "asserted_type": "map[string]any"},
+                    {"kind": "fatal", "function": "main", "path": ".../main.go", "line": 30,
+                     "column": 7, "call": "log.Fatalf", "argument": "\"listen: %v\", err"}
+                ],
+                "counts": {"panic": 0, "fatal": 1, "exit": 0, "recover": 0, "type_assertion": 1}
+            }
+        ],
+        "total_count": 2,
+        "counts": {"panic": 0, "fatal": 1, "exit": 0, "recover": 0, "type_assertion": 1},
+        "include_tests": false
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        return await _run(indexer, indexer.find_failure_points, include_tests, path, kinds, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error finding failure points", e)
+
+
+@_tool
+async def reflection_usages(root_path: Optional[str] = None, include_tests: Optional[bool] = None, path: Optional[str] = None, kinds: Optional[List[str]] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🪞 Show where Go code leaves static typing - reflection, type assertions and type switches, marshaling of interface{} values.
+
+    USE THIS before changing a type that code may only reach at run time: a
+    rename or a new field breaks nothing the compiler sees there. Kinds:
+    - "reflect": reflect.TypeOf, ValueOf and New, with the argument's type ("value_type")
+    - "type_assertion": every `x.(T)`; "checked" is true for `v, ok := x.(T)`
+    - "type_switch": every `switch v := x.(type)`, with the types of each case
+    - "marshal": encoding/json, xml, yaml, gob and other encoders and
+      decoders given an interface{}/any value (or a map or slice of one)
+
+    Every entry lists the concrete types it mentions ("types"), linked to
+    their declaration when the project has one ("path", "start_line") or
+    else carrying their "import" path; basic types and nil are left out. The case
+    types of type switches also count as references: what_breaks on
+    User tags `case *User:` lines "usage": "type_switch_case".
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - include_tests: Also scan _test.go files (default: .xray.yaml, else false); their entries get "in_test"
+    - path: Optional file or directory to limit the search to
+    - kinds: Optional list of kinds to report (default all)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "packages": [
+            {
+                "package": "main",
+                "directory": "/Users/john/project",
+                "usages": [
+                    {"kind": "type_switch", "function": "ProcessData", "path": ".../main.go",
+                     "line": 74, "column": 14, "expression": "data", "binding": "v",
+                     "cases": [{"line": 75, "types": ["*User"]}, {"line": 77, "types": ["string"]}],
+                     "types": [{"name": "User", "path": ".../main.go", "start_line": 12,
+                                "symbol_id": "go:example.com/app:User:5d0c9e21"}]},
+                    {"kind": "marshal", "function": "writeJSON", "path": ".../main.go", "line": 96,
+                     "column": 2, "call": "encoding/json.NewEncoder.Encode", "format": "json",
+                     "direction": "encode", "argument": "v", "value_type": "interface{}", "types": []}
+                ],
+                "counts": {"reflect": 0, "type_assertion": 0, "type_switch": 1, "marshal": 1}
+            }
+        ],
+        "total_count": 2,
+        "counts": {"reflect": 0, "type_assertion": 0, "type_switch": 1, "marshal": 1},
+        "include_tests": false
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        return await _run(indexer, indexer.reflection_usages, include_tests, path, kinds, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error finding reflection usages", e)
+
+
+@_tool
+async def find_constructions(root_path: Optional[str] = None, *, symbol: str, missing_field: Optional[str] = None, include_tests: Optional[bool] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🏗️ Find every place a Go struct value is constructed - before adding a field that must always be set.
+
+    USE THIS before introducing a required field, or to audit how a type
+    is built. Lists composite literals (`User{...}` and `&User{...}`,
+    qualified, generic, or with the type elided in `[]User{{...}}`), each
+    "keyed", "positional" or "empty" with the fields it sets, plus
+    `new(User)` and `var u User` zero values.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - symbol: Struct type name, e.g. "User" or "store.User"
+    - missing_field: Only constructions that leave this field unset: keyed and empty literals without
+      it, positional literals too short to reach it, and every new() and zero value
+    - include_tests: Also search _test.go files (default: .xray.yaml, else true); their entries get "in_test"
+    - path: Optional file or package directory to disambiguate the type
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "type": {"name": "User", "package": "main", "path": "/Users/john/project/main.go", "start_line": 12,
+                 "fields": ["ID", "Name", "Email"]},
+        "constructions": [
+            {"path": "/Users/john/project/main.go", "line": 52, "column": 13, "kind": "composite",
+             "address": true, "style": "empty", "fields": [], "symbol": "UserService.GetUser", "symbol_type": "method"},
+            {"path": "/Users/john/project/store/seed.go", "line": 8, "column": 6, "kind": "zero_value",
+             "names": ["guest"], "fields": [], "symbol": "guest", "symbol_type": "variable"}
+        ],
+        "total_count": 2,
+        "counts": {"composite": 1, "new": 0, "zero_value": 1}
+    }
+
+    A literal whose type is elided inside a slice or map literal has
+    "elided": true and is placed at its brace. A missing_field that is no
+    field of the type is an error listing the fields it has.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, True)
+        return await _paged(indexer, "constructions", limit, cursor, max_tokens, indexer.find_constructions, symbol,
+                            missing_field, include_tests, path, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error finding constructions", e)
+
+
+@_tool
+async def find_literals(root_path: Optional[str] = None, *, value: str, regex: bool = False, kind: Optional[str] = None, include_tests: Optional[bool] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🔢 Find where a magic number, string or boolean appears in Go code - never in comments.
+
+    USE THIS instead of grep for a value: literals are matched as tokens, so
+    comments don't count, and numbers by value ("100" also finds 0x64 and
+    1e2). Each hit names its enclosing symbol and its "role": "function
+    argument" (with "call"), "struct literal field" (with "field"), "map
+    entry", "composite element", "index", "const value"/"var value" (with
+    "name"), "comparison operand", "case value", "return value",
+    "assignment", "struct tag", "import path" or "expression".
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - value: A number ("100", "0x64", "-1" for a negated one), "true"/"false", or a string's value without quotes
+    - regex: Match string literals by regular expression instead of exactly (default false)
+    - kind: "string", "number" or "bool" to override the guess from value ("100" as a string)
+    - include_tests: Also search _test.go files (default: .xray.yaml, else false); their entries get "in_test"
+    - path: Optional file or directory to limit the search to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "literals": [
+            {"path": "/Users/john/project/main.go", "line": 30, "column": 16, "text": "100", "kind": "int",
+             "role": "const value", "name": "MaxUsers", "symbol": "MaxUsers", "symbol_type": "constant"},
+            {"path": "/Users/john/project/jobs.go", "line": 25, "column": 39, "text": "100", "kind": "int",
+             "role": "const value", "name": "backoff", "symbol": "RunJobs", "symbol_type": "function"}
+        ],
+        "total_count": 2,
+        "query": {"value": "100", "kind": "number", "regex": false}
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        return await _paged(indexer, "literals", limit, cursor, max_tokens, indexer.find_literals, value, regex,
+                            kind, include_tests, path, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error finding literals", e)
+
+
+@_tool
+async def metrics(root_path: Optional[str] = None, sort_by: str = "complexity", min_complexity: Optional[int] = None, min_loc: Optional[int] = None, min_nesting: Optional[int] = None, min_params: Optional[int] = None, min_callees: Optional[int] = None, include_tests: Optional[bool] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📏 List the biggest and most complex Go functions - the worst offenders first.
+
+    USE THIS to find refactoring candidates or to enforce limits ("nothing
+    above complexity 15"). Every function and method with a body is
+    measured while it is parsed:
+    - loc: lines of the declaration holding code (no blank or comment-only lines)
+    - statements: statements in the body
+    - complexity: cyclomatic estimate, 1 + if, for, case, && and ||
+    - max_nesting: deepest if/else/for/switch/select nesting
+    - param_count / result_count: parameters and results, one per name
+    - distinct_callees: different calls made, builtins left out
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - sort_by: "complexity" (default), "loc", "statements", "max_nesting", "param_count", "result_count" or "distinct_callees"
+    - min_complexity, min_loc, min_nesting, min_params, min_callees: Only functions at or above
+      every threshold given (complexity > 15 is min_complexity=16)
+    - include_tests: Also list functions of _test.go files (default: .xray.yaml, else false)
+    - path: Optional file or directory to limit the listing to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "functions": [
+            {"name": "UserService.GetUser", "type": "method", "package": "main", "path": ".../main.go",
+             "start_line": 47, "end_line": 60, "complexity": 3, "loc": 12, "statements": 9,
+             "max_nesting": 1, "param_count": 1, "result_count": 2, "distinct_callees": 2}
+        ],
+        "total_count": 1,
+        "measured": 9,
+        "sort_by": "complexity",
+        "thresholds": {"complexity": 3},
+        "max": {"complexity": 3, "loc": 12, "statements": 9, "max_nesting": 2, "param_count": 2,
+                "result_count": 2, "distinct_callees": 6}
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        return await _paged(indexer, "functions", limit, cursor, max_tokens, indexer.function_metrics, sort_by,
+                            min_complexity, min_loc, min_nesting, min_params, min_callees, include_tests, path,
+                            ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error computing function metrics", e)
+
+
+@_tool
+async def find_duplicates(root_path: Optional[str] = None, min_tokens: int = 50, min_similarity: float = 90, include_tests: Optional[bool] = None, path: Optional[str] = None, cross_package_only: bool = False, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    👯 Find copy-pasted Go functions: bodies identical up to names and literals, or nearly so, grouped, the copies in different services first.
+
+    USE THIS to find code duplicated between packages and services -
+    a helper copied into every service, a handler pasted and tweaked -
+    before deduplicating or when a fix must go into every copy. Bodies are
+    compared by their shape: tokens with identifiers and literals
+    replaced by their kind, so renamed variables or other constants do not
+    hide a copy. "identical" bodies have the same shape; "similar" ones
+    share at least min_similarity percent of their tokens, in order.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - min_tokens: Leave out bodies of fewer tokens (default 50), so small getters and constructors do not flood the result
+    - min_similarity: Percent of tokens in common that makes two bodies near copies (default 90; 100 for identical only)
+    - include_tests: Also compare functions of _test.go files (default: .xray.yaml, else false)
+    - path: Optional file or directory to limit the search to
+    - cross_package_only: Only groups with copies in more than one package (default false)
+    - include: Only files matching one of these globs, e.g. ["services/**"]
+    - exclude: Not files matching one of these globs, e.g. ["**/*_gen.go"]
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "groups": [
+            {"kind": "similar", "similarity": 91.0, "tokens": 90, "copy_count": 2, "duplicated_tokens": 77,
+             "packages": ["example.com/svc/internal/api", "example.com/svc/internal/worker"],
+             "services": ["api", "worker"], "cross_package": true, "cross_service": true,
+             "copies": [
+                {"function": "ParseHeader", "package": "example.com/svc/internal/api",
+                 "path": "/Users/john/svc/internal/api/api.go", "line": 10, "end_line": 21, "tokens": 90,
+                 "similarity": 100.0, "services": ["api"]},
+                {"function": "splitField", "package": "example.com/svc/internal/worker",
+                 "path": "/Users/john/svc/internal/worker/worker.go", "line": 23, "end_line": 34, "tokens": 77,
+                 "similarity": 91.0, "services": ["worker"]}
+             ]}
+        ],
+        "total_count": 1,
+        "function_count": 212,
+        "duplicated_tokens": 77,
+        "min_tokens": 50,
+        "min_similarity": 90,
+        "message": "1 of 1 groups of duplicated functions span services"
+    }
+
+    Each copy's "similarity" is to the group's first copy; the group's is
+    the lowest. "services" are the binaries of service_map building the
+    copy's package; "cross_service" groups have copies no single service
+    builds all of, and come first, then cross-package ones, then the most
+    "duplicated_tokens" (tokens beyond one copy of the largest). Copies of
+    a function in files for other build constraints are not reported.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        return await _paged(indexer, "groups", limit, cursor, max_tokens, indexer.find_duplicates, min_tokens,
+                            min_similarity, include_tests, path, cross_package_only, include, exclude,
+                            ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error finding duplicated functions", e)
+
+
+@_tool
+async def coverage_by_symbol(root_path: Optional[str] = None, *, profile_path: str, threshold: Optional[float] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🧪 Test coverage per Go function from a coverage profile - the least covered first.
+
+    USE THIS with the profile `go test -coverprofile=cover.out ./...` wrote
+    to see which functions tests miss. Each function gets the covered and
+    total statements of the profile's blocks inside it and a percentage, as
+    `go tool cover -func` counts them; "packages" rolls them up and
+    "uncovered_exported" lists the exported functions no test runs at all.
+
+    A profile from another commit still maps: each function is placed on
+    the blocks by its statements' positions, functions keeping their order,
+    and entries of changed files are "approximate" with the "line_offset" from
+    the current lines to the profile's. Functions added since are listed in
+    "not_in_profile"; profile files the project lacks in "unmatched_files".
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - profile_path: The coverage profile, absolute or relative to the project root
+    - threshold: Only list functions covered below this percentage (80 lists everything under 80%)
+    - path: Optional file or directory to limit the listing to
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "functions": [
+            {"name": "Unused", "type": "function", "package": "calc", "path": ".../calc/calc.go",
+             "start_line": 26, "end_line": 29, "covered": 0, "statements": 2, "percent": 0.0},
+            {"name": "Div", "type": "function", "package": "calc", "path": ".../calc/calc.go",
+             "start_line": 14, "end_line": 19, "covered": 2, "statements": 3, "percent": 66.7,
+             "approximate": true, "line_offset": -4}
+        ],
+        "total_count": 2,
+        "mode": "set",
+        "packages": [{"package": "calc", "directory": ".../calc", "covered": 5, "statements": 9,
+                      "functions": 5, "percent": 55.6}],
+        "totals": {"covered": 5, "statements": 9, "percent": 55.6, "functions": 5},
+        "uncovered_exported": [{"name": "Unused", "package": "calc", "path": ".../calc/calc.go",
+                                "start_line": 26, "statements": 2}],
+        "approximate_count": 4,
+        "threshold": 80.0
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "functions", limit, cursor, max_tokens, indexer.coverage_by_symbol,
+                            profile_path, threshold, path, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error mapping coverage", e)
+
+
+@_tool
+async def blame_symbol(root_path: Optional[str] = None, *, symbol: str, path: Optional[str] = None, ref: Optional[str] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🕵️ Who last touched a function or type, and when - blame for one symbol.
+
+    The symbol's line range is looked up in the working tree at call time and
+    blamed with rename/copy detection, so code that moved between files is
+    traced to the commit that actually wrote it.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project (inside a git repository)
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - symbol: A name ("userHandler") or "Type.Method" ("UserService.GetUser"), or its symbol_id
+    - path: Optional file or directory to pick one of several same-named symbols
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+
+    EXAMPLE OUTPUT:
+    {
+        "symbol": {"name": "UserService.GetUser", "path": "/Users/john/project/main.go",
+                   "start_line": 47, "end_line": 61, ...},
+        "hunks": [
+            {"start_line": 47, "end_line": 55, "commit": "3f2a9c1...", "author": "Jane Doe",
+             "author_email": "jane@example.com", "date": "2024-03-02T10:15:00Z",
+             "summary": "Add user cache"},
+            {"start_line": 56, "end_line": 61, "commit": "9be0d44...", ...}
+        ],
+        "last_modified": {"commit": "9be0d44...", "author": "John Roe", "date": "2024-05-11T08:00:00Z", ...},
+        "primary_author": {"author": "Jane Doe", "lines": 9, "share": 0.6},
+        "authors": [{"author": "Jane Doe", "lines": 9}, {"author": "John Roe", "lines": 6}],
+        "truncatedHistory": false
+    }
+
+    Hunks of lines that came from another file carry "original_path"; lines
+    not committed yet are marked "uncommitted": true. In a shallow clone,
+    lines older than its history are blamed on the oldest commit fetched;
+    their hunks and the result carry "truncatedHistory": true.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, project=project)
+        return await _run(indexer, indexer.blame_symbol, symbol, path, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error blaming symbol", e)
+
+
+@_tool
+async def symbol_history(root_path: Optional[str] = None, *, symbol: str, path: Optional[str] = None, max_commits: Optional[int] = 50, ref: Optional[str] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📜 How a function or type got the way it is - every commit that changed it.
+
+    USE THIS instead of reading git log -p of a whole file. The file's
+    history is walked newest first, following file renames, and every
+    version is parsed; a commit is listed only when the symbol's signature
+    or body changed, with a line diff stat of the declaration. Where the
+    symbol was renamed (same kind and receiver, similar body) the walk
+    continues under the old name.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project (inside a git repository)
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - symbol: A name ("userHandler") or "Type.Method" ("UserService.GetUser"), or its symbol_id
+    - path: Optional file or directory to pick one of several same-named symbols
+    - max_commits: Only walk the N most recent commits of the file (default 50)
+    - ref: Optional git tag, branch or SHA to walk back from instead of HEAD
+
+    change is "body_changed", "signature_changed", "renamed" (with
+    renamedFrom and whether the signature changed too) or "added" for the
+    commit that introduced it. introduced_in is null when max_commits ran
+    out first (truncated: true). Code moved in from another file shows up
+    as added. Parsed versions are cached, so asking again is fast.
+
+    EXAMPLE OUTPUT:
+    {
+        "symbol": {"name": "UserService.GetUser", "path": "/Users/john/project/service.go",
+                   "start_line": 47, "end_line": 61, "signature": "func (s *UserService) GetUser(...) ..."},
+        "history": [
+            {"commit": "9be0d44...", "author": "John Roe", "author_email": "john@example.com",
+             "date": "2024-05-11T08:00:00Z", "summary": "Pass context through", "path": "service.go",
+             "change": "signature_changed", "old_signature": "func (s *UserService) GetUser(id int) ...",
+             "new_signature": "func (s *UserService) GetUser(ctx context.Context, id int) ...",
+             "diff": {"added": 3, "removed": 2}},
+            {"commit": "3f2a9c1...", "path": "users.go", "change": "renamed", "renamedFrom": "UserService.FetchUser",
+             "signature_changed": false, ...},
+            {"commit": "1c0ffee...", "change": "added", "new_signature": "...", "diff": {"added": 12, "removed": 0}, ...}
+        ],
+        "file_renames": [{"commit": "5d1e2f3...", "from": "users.go", "to": "service.go"}],
+        "commits_scanned": 23,
+        "introduced_in": "1c0ffee...",
+        "truncated": false,
+        "original_name": "UserService.FetchUser",
+        "total_count": 3,
+        "truncatedHistory": false
+    }
+
+    In a shallow clone the walk stops before the oldest commit fetched,
+    whose diff would make every symbol look added: introduced_in is then
+    null and "truncatedHistory": true. In a partial clone, versions whose
+    blobs are missing are skipped with a warning (see --fetch-missing).
+    """
+    try:
+        indexer = get_indexer(root_path, ref, project=project)
+        return await _run(indexer, indexer.symbol_history, symbol, path, max_commits,
+                          ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error tracing symbol history", e)
+
+
+@_tool
+async def analyze_buffer(root_path: Optional[str] = None, *, path: str, content: str, language: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    ✍️ Check an edit before (or right after) writing it: what the new file content breaks elsewhere.
+
+    USE THIS in an edit loop: pass the content you are about to write (or
+    just wrote, before the index caught up) and get the declarations it
+    adds, removes or re-signatures compared with the indexed file, and the
+    references from the rest of the project those leave dangling - Go calls
+    and function references from other files, TypeScript/JavaScript and
+    Python imports of the name. Nothing is written and the index is left as
+    it is, so the same file can be tried again and again; only the buffer
+    is parsed, each call.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: The file edited, absolute or relative to root_path; a new file is fine
+    - content: The whole content of the file as edited
+    - language: Parse the content as this language whatever the file's extension, e.g. "go" (default: the file's own)
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "path": "/Users/john/project/store/store.go",
+        "language": "go",
+        "indexed": true,
+        "changes": [
+            {"name": "Store.Get", "kind": "method", "change": "signature_changed",
+             "symbol_id": "go:example.com/shop/store:Store.Get:3fa2c1d0",
+             "old_signature": "func (s *Store) Get(id int) (*User, error)", "old_line": 42,
+             "new_symbol_id": "go:example.com/shop/store:Store.Get:81d0e2aa",
+             "new_signature": "func (s *Store) Get(ctx context.Context, id int) (*User, error)", "new_line": 42},
+            {"name": "Store.Delete", "kind": "method", "change": "removed", "symbol_id": "go:example.com/shop/store:Store.Delete:0c9e7b14",
+             "old_signature": "func (s *Store) Delete(id int) error", "old_line": 60}
+        ],
+        "dangling_references": [
+            {"symbol": "Store.Get", "change": "signature_changed", "from": "Handler.ServeUser", "kind": "call",
+             "path": "/Users/john/project/api/users.go", "line": 31, "column": 17}
+        ],
+        "counts": {"signature_changed": 1, "removed": 1, "dangling_references": 1}
+    }
+
+    A buffer that does not parse comes back with "parseErrors" (Go, SQL).
+    """
+    try:
+        indexer = get_indexer(root_path, None, include_generated, project)
+        return await _run(indexer, indexer.analyze_buffer, path, content, language, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error analyzing buffer", e)
+
+
+@_tool
+async def diff_symbols(root_path: Optional[str] = None, *, base: str, head: str = "HEAD", timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🔀 Compare two git refs symbol by symbol instead of line by line.
+
+    Every changed Go file is parsed on both sides, straight from git objects
+    (nothing is checked out), and each function, method, type, constant and
+    variable is classified as added, removed, modified (body changed) or
+    signature_changed. A removed/added pair with identical bodies is reported
+    as "renamed" with "confidence": "low".
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project (inside a git repository)
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - base: The base ref, e.g. "main", "v1.2.0" or a SHA
+    - head: The head ref (default "HEAD")
+
+    EXAMPLE OUTPUT:
+    {
+        "base": {"ref": "main", "sha": "4c1d..."},
+        "head": {"ref": "HEAD", "sha": "a07e..."},
+        "files": [
+            {
+                "path": "main.go",
+                "status": "modified",
+                "symbols": [
+                    {"name": "UserService.GetUser", "type": "method", "change": "signature_changed",
+                     "receiver": {"name": "s", "type": "UserService", "pointer": true},
+                     "old_signature": "func (s *UserService) GetUser(id int) (*User, error)",
+                     "new_signature": "func (s *UserService) GetUser(ctx context.Context, id int) (*User, error)",
+                     "old_lines": [47, 61], "new_lines": [47, 62],
+                     "new_range": {"startLine": 47, "startColumn": 1, "endLine": 62, "endColumn": 2}}
+                ]
+            }
+        ],
+        "summary": {"signature_changed": 1}
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, project=project)
+        return await _run(indexer, indexer.diff_symbols, base, head, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error diffing symbols", e)
+
+
+@_tool
+async def compare_refs(root_path: Optional[str] = None, *, base: str, head: Optional[str] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🔍 Review a branch like a pull request - and find the callers it forgot to update.
+
+    USE THIS before reviewing or opening a PR. The merge base of base and
+    head is computed here, so branch names are enough and commits that
+    landed on base since the fork are left out. Returns the changed files,
+    the Go symbols added, removed or modified in each (as diff_symbols
+    does), and for every modified, removed or renamed exported symbol the
+    dependents the branch did NOT touch - likely missed updates. New and
+    bumped go.mod requirements are listed too.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project (inside a git repository)
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - base: The branch the changes go into, e.g. "main"
+    - head: The branch under review (default: the working tree of the checked-out branch)
+
+    Dependents are callers from the head's call graph for functions and
+    methods, and whole-word references otherwise - removed and renamed
+    symbols are looked up by their old name, so what is left is what breaks.
+    A dependent counts as updated when its line, or the symbol around it,
+    was changed in the branch.
+
+    EXAMPLE OUTPUT:
+    {
+        "base": {"ref": "main", "sha": "4c1d..."},
+        "head": {"ref": "feature/ctx", "sha": "a07e..."},
+        "merge_base": "91b2...",
+        "files": [{"path": "service.go", "status": "modified", "symbols": [...]},
+                  {"path": "go.mod", "status": "modified"}],
+        "summary": {"signature_changed": 1, "modified": 2},
+        "changed_exported_symbols": [
+            {"name": "UserService.GetUser", "change": "signature_changed", "path": "service.go",
+             "dependents": 5,
+             "unchanged_dependents": [{"name": "adminHandler", "path": "/Users/john/project/admin.go",
+                                       "line": 88, "kind": "call", "in_test": false}]}
+        ],
+        "unchanged_dependents_count": 1,
+        "new_dependencies": [{"go_mod": "go.mod", "path": "github.com/google/uuid", "version": "v1.6.0",
+                              "indirect": false}],
+        "updated_dependencies": [{"go_mod": "go.mod", "path": "golang.org/x/sync", "version": "v0.7.0",
+                                  "indirect": false, "old_version": "v0.6.0"}]
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, head, project=project)
+        return await _run(indexer, indexer.compare_refs, base, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error comparing refs", e)
+
+
+@_tool
+async def three_way_impact(root_path: Optional[str] = None, *, base: str, head: Optional[str] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🔱 Before merging a long-lived branch: what did it and the base BOTH change since the fork?
+
+    USE THIS when a branch has been open a while. compare_refs shows what
+    the branch changed; this also reads what landed on base meanwhile and
+    pairs the two sides' Go symbol changes - the semantic conflicts git
+    merges without a word:
+    - both_changed: a symbol modified, re-signatured, removed or renamed on both sides
+    - new_reference: one side changed a symbol that the other side's new or
+      changed code starts to use, written against the old version (base changed
+      what GetUser returns, the branch added a caller of GetUser)
+    - both_added: a name both sides declared in the same package
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project (inside a git repository)
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - base: The branch the head goes into, at its current tip, e.g. "main"
+    - head: The branch under review (default: HEAD)
+
+    Each overlap gives both sides' change and the commits of that side that
+    made it. References are matched by name (`.GetUser` from other
+    packages importing it), so a new_reference is a candidate to look at,
+    not proof. A removed symbol lists the commits of its file.
+
+    EXAMPLE OUTPUT:
+    {
+        "base": {"ref": "main", "sha": "15a6..."},
+        "head": {"ref": "HEAD", "sha": "6f42..."},
+        "merge_base": "aebb...",
+        "changed_symbols": {"head": 3, "base": 3},
+        "overlaps": [
+            {"kind": "new_reference", "symbol": "GetUser", "package_dir": "store", "type": "function",
+             "changed_on": "base",
+             "base": {"change": "modified", "path": "store/store.go",
+                      "commits": [{"commit": "15a6...", "author": "carol", "date": "2026-03-02T10:14:09Z",
+                                   "summary": "GetUser returns an empty name for deleted users"}]},
+             "head": {"referenced_by": [{"name": "NewHandler", "change": "added", "path": "api/api.go", "line": 9}],
+                      "commits": [{"commit": "6f42...", "author": "bob", "date": "...", "summary": "..."}]}}
+        ],
+        "total_count": 1,
+        "counts": {"both_changed": 0, "new_reference": 1, "both_added": 0},
+        "truncatedHistory": false
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, project=project)
+        return await _run(indexer, indexer.three_way_impact, base, head, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error computing the three-way impact", e)
+
+
+@_tool
+async def snapshot_index(root_path: Optional[str] = None, *, name: str, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📸 Save the project's symbol table under a name - uncommitted changes included - to diff later states against.
+
+    USE THIS before a large refactor, then compare_snapshots afterwards to
+    check nothing unexpected changed. Unlike compare_refs, it works for
+    states that were never committed. A snapshot keeps every declaration
+    (kind, signature, location), the call and function-value sites of each
+    Go function and method, and per package its files, lines and
+    declarations - no source - under the cache directory, so it survives
+    restarts and clear_cache. A snapshot of the same name is replaced.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - name: The label, up to 64 letters, digits, ".", "_" or "-", e.g. "before-split"
+    - ref: Optional git tag, branch or SHA to snapshot instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {"name": "before-split", "created_at": "2026-10-14T09:16:18Z", "commit": "9fceb02...", "ref": null,
+     "symbols": 4180, "packages": 62, "bytes": 91542}
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.snapshot_index, name, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error taking snapshot", e)
+
+
+@_tool
+async def compare_snapshots(root_path: Optional[str] = None, *, a: str, b: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🔬 Diff two snapshots of the symbol table, or one against the tree as it is now.
+
+    USE THIS after a refactor to verify what changed: symbols added and
+    removed, signature (or kind) changes, declarations moved to another
+    file, Go functions whose call-site count went up or down (most first),
+    and packages that grew or shrank in files, lines or declarations.
+    Symbols are matched by language, package and qualified name, so a
+    rename shows as one removal and one addition.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - a: The earlier snapshot's name
+    - b: The later snapshot's name (default: the working tree now, indexed for the diff but not saved)
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "from": {"name": "before-split", "created_at": "2026-10-14T09:16:18Z", "commit": "9fceb02...", "symbols": 4180, ...},
+        "to": {"name": "(current)", "created_at": "2026-10-14T10:02:55Z", "commit": "9fceb02...", "symbols": 4183, ...},
+        "added": [{"name": "NewStore", "kind": "function", "language": "go", "package": "example.com/app/store",
+                   "path": "/Users/john/project/store/store.go", "line": 14, "signature": "func NewStore() *Store"}],
+        "removed": [],
+        "signature_changed": [{"name": "Service.GetUser", "kind": "method", ..., "signature": "GetUser(id int64) (*User, error)",
+                               "old_signature": "GetUser(id int) (*User, error)"}],
+        "moved": [{"name": "User", ..., "path": ".../model/user.go", "old_path": ".../store/user.go"}],
+        "references": [{"name": "UserService.GetUser", ..., "old_references": 6, "references": 2, "delta": -4}],
+        "packages": [{"package": "example.com/app/store", "language": "go", "status": "changed",
+                      "files": {"old": 4, "new": 5, "delta": 1}, "lines": {"old": 410, "new": 468, "delta": 58},
+                      "symbols": {"old": 31, "new": 34, "delta": 3}}],
+        "counts": {"added": 1, "removed": 0, "signature_changed": 1, "moved": 1, "references": 1, "packages": 1}
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, None, include_generated, project)
+        return await _run(indexer, indexer.compare_snapshots, a, b, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error comparing snapshots", e)
+
+
+@_tool
+async def list_snapshots(root_path: Optional[str] = None, project: Optional[str] = None) -> Dict[str, Any]:
+    """
+    🗂️ List the snapshots saved for a project with snapshot_index, oldest first.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+
+    EXAMPLE OUTPUT:
+    {
+        "snapshots": [
+            {"name": "before-split", "created_at": "2026-10-14T09:16:18Z", "commit": "9fceb02...", "ref": null,
+             "symbols": 4180, "packages": 62, "bytes": 91542}
+        ],
+        "total_count": 1
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, project=project)
+        return await _run(indexer, indexer.list_snapshots)
+    except Exception as e:
+        return _error("Error listing snapshots", e)
+
+
+@_tool
+async def delete_snapshot(root_path: Optional[str] = None, *, name: str, project: Optional[str] = None) -> Dict[str, Any]:
+    """
+    🗑️ Delete a snapshot saved with snapshot_index.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - name: The snapshot's name
+
+    EXAMPLE OUTPUT:
+    {"deleted": "before-split"}
+    """
+    try:
+        indexer = get_indexer(root_path, project=project)
+        return await _run(indexer, indexer.delete_snapshot, name)
+    except Exception as e:
+        return _error("Error deleting snapshot", e)
+
+
+@_tool
+async def api_surface(root_path: Optional[str] = None, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📘 The exported API of every Go package - what importers can use, as go doc shows it.
+
+    USE THIS to review a library's public surface or to document it. Lists,
+    per package and sorted by name, the exported functions, constants,
+    variables and types, the exported methods and fields of exported types,
+    and the methods and embedded interfaces of exported interfaces. main
+    packages and _test.go files are skipped; internal packages are flagged.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: Optional package directory to limit the listing to (subdirectories included)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Packages per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    Signatures are whitespace-normalized and methods show their receiver
+    without its name: "func (*Client) Do(req *Request) (*Response, error)".
+
+    EXAMPLE OUTPUT:
+    {
+        "packages": [
+            {"directory": "client", "import_path": "github.com/acme/api/client", "package": "client",
+             "internal": false,
+             "declarations": [
+                {"name": "Client", "kind": "type", "signature": "type Client struct",
+                 "path": "/Users/john/project/client/client.go", "start_line": 12},
+                {"name": "Client.Do", "kind": "method",
+                 "signature": "func (*Client) Do(req *Request) (*Response, error)",
+                 "path": "/Users/john/project/client/client.go", "start_line": 30, "pointer_receiver": true},
+                {"name": "Client.Timeout", "kind": "field", "signature": "Timeout time.Duration",
+                 "path": "/Users/john/project/client/client.go", "start_line": 14},
+                {"name": "DefaultTimeout", "kind": "const", "signature": "const DefaultTimeout = 30 * time.Second",
+                 "path": "/Users/john/project/client/client.go", "start_line": 8}
+             ]}
+        ],
+        "total_count": 1,
+        "declarations": 4
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "packages", limit, cursor, max_tokens, indexer.api_surface, path,
+                            ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error listing API surface", e)
+
+
+@_tool
+async def api_usage(root_path: Optional[str] = None, *, package: str, include_tests: Optional[bool] = None, many: int = 3, sort_by: str = "references", format: str = "json", output: Optional[str] = None, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🌡️ Which exported symbols of a Go package other packages use, and who uses each - a heatmap of its API.
+
+    USE THIS before reshaping a library package of a monorepo: every
+    exported function, type, constant, variable and method with the
+    references each consuming package makes to it, and its usage:
+    - wide: used by at least `many` packages - change it carefully
+    - several / single: used by fewer; a single-consumer symbol is a
+      candidate to move into that package
+    - test_only: used only by tests of other packages (or by the package's
+      own external foo_test package) - unexporting it breaks them
+    - internal / none: used only inside the package, or nowhere - an
+      unexport candidate unless keep_exported says why not (a method called
+      through an interface, an interface method, a type whose methods others call)
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - package: The package's directory (absolute or relative to root_path) or its import path
+    - include_tests: Count the references of _test.go files (default true, whatever .xray.yaml says, so an
+      unexport candidate is never one a test still needs)
+    - many: Consuming packages that make a symbol "wide" (default 3)
+    - sort_by: "references" (default) or "packages" (most used first), or "name"
+    - format: "json" (default), or "csv" / "jsonl" to write one row per symbol and consuming package
+      (symbol, kind, usage, consumer, references, test_only, package_count, internal_references,
+      unexport_candidate, path, start_line) to output
+    - output: Optional file to write, relative to root_path (default "api_usage.csv" or "api_usage.jsonl")
+    - include: Only count consuming files matching one of these globs, e.g. ["services/**"]
+      (doublestar syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: Leave out consuming files matching one of these globs (default: the project's, see add_project)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Symbols per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "package": "github.com/john/project/lib/money",
+        "directory": "lib/money",
+        "symbols": [
+            {"name": "Parse", "kind": "func", "signature": "func Parse(s string) (Amount, error)",
+             "path": "/Users/john/project/lib/money/parse.go", "start_line": 14,
+             "references": 37, "package_count": 5, "internal_references": 2, "usage": "wide",
+             "consumers": [{"package": "github.com/john/project/billing", "references": 21}, "..."]},
+            {"name": "Round", "kind": "func", "signature": "func Round(a Amount) Amount",
+             "path": "/Users/john/project/lib/money/round.go", "start_line": 3,
+             "references": 0, "package_count": 0, "internal_references": 0, "usage": "none",
+             "consumers": [], "unexport_candidate": true}
+        ],
+        "total_count": 24,
+        "consumers": [{"package": "github.com/john/project/billing", "symbols": 9, "references": 58}],
+        "summary": {
+            "wide": ["Parse", "Amount"],
+            "single_consumer": [{"name": "FormatEUR", "package": "github.com/john/project/invoice",
+                                 "internal_references": 0}],
+            "unexport_candidates": ["Round"],
+            "keep_exported": [{"name": "Amount.String", "reason": "satisfies an interface, through which it is called"}]
+        },
+        "many": 3,
+        "message": "1 of 24 exported symbols have no references from other packages of the project and could be unexported"
+    }
+
+    Every file counts whatever its build constraints; a consumer whose
+    references are all in constrained files lists them under
+    "buildConstraints", and so does a symbol declared in one. Fields are
+    left out (see field_usages). References from outside the project -
+    other modules importing the package - cannot be seen: check before
+    unexporting the API of a published module. With csv or jsonl the
+    result is the path written, the columns, row_count and the summary.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = True if include_tests is None else include_tests
+        args = (package, include_tests, many, sort_by, format, output, *_globs(indexer, include, exclude))
+        if format != "json":
+            return await _run(indexer, indexer.api_usage, *args, ctx=ctx, timeout_ms=timeout_ms, present=True)
+        return await _paged(indexer, "symbols", limit, cursor, max_tokens, indexer.api_usage, *args,
+                            ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error measuring API usage", e)
+
+
+@_tool
+async def api_diff(root_path: Optional[str] = None, *, base: str, head: Optional[str] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    ⚖️ Did the exported Go API change incompatibly between two versions? Does this need a major bump?
+
+    USE THIS before tagging a release of a library. Compares the api_surface
+    of base with that of head, package by package, and classifies every
+    difference:
+    - addition: a new declaration or package
+    - removal: a declaration or package that is gone
+    - incompatible: other parameter or result types, another field type or
+      type definition, a constant with another value, a value receiver made
+      a pointer receiver, a method added to an interface other packages can
+      implement (one without unexported methods)
+    - compatible: a pointer receiver made a value receiver
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project (inside a git repository)
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - base: The earlier version: a tag, branch or SHA, e.g. "v1.4.0"
+    - head: The later version (default: the working tree)
+    - limit: Changes per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    Removals and incompatible changes carry the exact old (and new)
+    signature. Renaming a parameter is not a change. "compatible" is false
+    as soon as anything was removed or changed incompatibly.
+
+    EXAMPLE OUTPUT:
+    {
+        "base": {"ref": "v1.4.0", "sha": "4c1d..."},
+        "head": {"ref": "working tree", "sha": "a07e..."},
+        "changes": [
+            {"package": "client", "name": "Client.Do", "kind": "method", "change": "incompatible",
+             "old_signature": "func (*Client) Do(req *Request) (*Response, error)",
+             "new_signature": "func (*Client) Do(ctx context.Context, req *Request) (*Response, error)",
+             "path": "/Users/john/project/client/client.go", "start_line": 30, "reason": "signature changed"},
+            {"package": "client", "name": "Client.Retries", "kind": "field", "change": "removal",
+             "old_signature": "Retries int"},
+            {"package": "client", "name": "WithLogger", "kind": "func", "change": "addition",
+             "new_signature": "func WithLogger(l *slog.Logger) Option",
+             "path": "/Users/john/project/client/options.go", "start_line": 41}
+        ],
+        "total_count": 3,
+        "summary": {"addition": 1, "removal": 1, "incompatible": 1, "compatible": 0},
+        "compatible": false
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, head, project=project)
+        return await _paged(indexer, "changes", limit, cursor, max_tokens, indexer.api_diff, base,
+                            ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error diffing API", e)
+
+
+@_tool
+async def diff_impact(root_path: Optional[str] = None, scope: str = "all", timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🎯 Pre-commit blast radius: which symbols your uncommitted changes touch, and who depends on them.
+
+    Maps the hunks of the current diff onto Go symbols, then lists the
+    callers (for functions and methods) or reference sites (for everything
+    else) of each one. New untracked files are parsed too; deleted symbols
+    are listed with the code that still references them.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project (inside a git repository)
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - scope: "staged" (index vs HEAD), "unstaged" (working tree vs index, plus
+             untracked files) or "all" (working tree vs HEAD, plus untracked files)
+
+    EXAMPLE OUTPUT:
+    {
+        "scope": "all",
+        "changed_symbols": [
+            {"name": "UserService.GetUser", "type": "method", "change": "signature_changed",
+             "old_signature": "func (s *UserService) GetUser(id int) (*User, error)",
+             "new_signature": "func (s *UserService) GetUser(id int64) (*User, error)",
+             "old_lines": [47, 60], "new_lines": [47, 62], "path": "/Users/john/project/main.go",
+             "dependents": [
+                 {"name": "userHandler", "path": "/Users/john/project/main.go", "line": 105,
+                  "kind": "call", "in_test": false}
+             ],
+             "dependent_count": 1, "test_dependents": 0}
+        ],
+        "summary": {"signature_changed": 1},
+        "untracked_files": []
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, project=project)
+        return await _run(indexer, indexer.diff_impact, scope, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error computing diff impact", e)
+
+
+@_tool
+async def hotspots(
+    root_path: Optional[str] = None,
+    since: Optional[str] = None,
+    max_commits: Optional[int] = 500,
+    include_merges: bool = False,
+    sort_by: str = "score",
+    include: Optional[List[str]] = None,
+    exclude: Optional[List[str]] = None,
+    limit: int = 50,
+    cursor: Optional[str] = None,
+    max_tokens: Optional[int] = None,
+    timeout_ms: Optional[int] = None,
+    project: Optional[str] = None,
+    ctx: Optional[Context] = None
+) -> Dict[str, Any]:
+    """
+    🔥 Find the code that changes most and is hardest to change - churn × complexity.
+
+    Walks git history over the chosen window, maps every commit's hunks onto
+    the Go symbols they touched (in that commit's version of the file), and
+    joins the counts with each symbol's current size and cyclomatic estimate.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project (inside a git repository)
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - since: Only commits newer than this, e.g. "6 months ago" or "2024-01-01"
+    - max_commits: Only the N most recent commits (default 500)
+    - include_merges: Count merge commits too (default false)
+    - sort_by: "score" (churn × complexity), "churn", "complexity", "authors" or "lines"
+    - include: Only files matching one of these globs, e.g. ["internal/**", "cmd/{api,worker}/**"]
+      (doublestar syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: Leave out files matching one of these globs, e.g. ["**/*_test.go", "vendor"]
+      (default: the project's, see add_project)
+    - limit: Entries per page (default 50)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "symbols": [
+            {"name": "UserService.GetUser", "type": "method", "path": "main.go", "start_line": 47,
+             "churn": 14, "authors": 3, "lines": 15, "statements": 9, "complexity": 4, "score": 56}
+        ],
+        "files": [{"path": "main.go", "commits": 20, "authors": 3, "lines_added": 310, "lines_deleted": 122}],
+        "total_count": 37,
+        "sort_by": "score",
+        "truncatedHistory": false,
+        "next_cursor": "ZDRmMGMxYjJhOTpkNTA"
+    }
+
+    In a shallow clone "truncatedHistory": true means the walk reached the
+    oldest commit fetched, so older changes are missing from the counts.
+
+    The globs narrow the history walk itself: git only lists the commits
+    touching the selected files, so max_commits counts those. The result
+    then carries "path_filter": {"include": [...], "exclude": [...]}.
+    """
+    try:
+        indexer = get_indexer(root_path, project=project)
+        return await _paged(indexer, "symbols", limit, cursor, max_tokens,
+                            indexer.hotspots, since, max_commits, include_merges, sort_by,
+                            *_globs(indexer, include, exclude), ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error computing hotspots", e)
+
+
+@_tool
+async def coupling(
+    root_path: Optional[str] = None,
+    max_commits: Optional[int] = 500,
+    min_support: int = 3,
+    min_confidence: float = 0.5,
+    max_files_per_commit: int = 30,
+    exclude: Optional[List[str]] = None,
+    limit: int = 50,
+    cursor: Optional[str] = None,
+    max_tokens: Optional[int] = None,
+    timeout_ms: Optional[int] = None,
+    project: Optional[str] = None,
+    ctx: Optional[Context] = None
+) -> Dict[str, Any]:
+    """
+    🧲 Find files that always change together - and flag the ones with no import between them.
+
+    Mines recent commits for file pairs that co-change. Support is how many
+    commits touched both files; confidence A→B is the share of A's commits
+    that also touched B. Vendored and generated files are ignored, and
+    commits touching too many files (mass reformats) are skipped.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project (inside a git repository)
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - max_commits: Only the N most recent commits (default 500)
+    - min_support: Minimum commits that changed both files (default 3)
+    - min_confidence: Minimum confidence in either direction, 0-1 (default 0.5)
+    - max_files_per_commit: Skip commits touching more files than this (default 30)
+    - exclude: Extra glob patterns to ignore, e.g. ["docs/*", "*.md"]
+    - limit: Entries per page (default 50)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    static_dependency is "same_package", "a_imports_b", "b_imports_a",
+    "mutual_imports", "none" (hidden coupling), or null when either file is
+    not Go source.
+
+    EXAMPLE OUTPUT:
+    {
+        "pairs": [
+            {"files": ["api/handlers.go", "store/users.go"], "support": 9, "confidence": 0.82,
+             "confidence_a_to_b": 0.82, "confidence_b_to_a": 0.6,
+             "static_dependency": "none", "hidden_coupling": true}
+        ],
+        "total_count": 14,
+        "commits_analyzed": 480,
+        "commits_skipped": 20,
+        "truncatedHistory": false
+    }
+
+    In a shallow clone "truncatedHistory": true means the walk reached the
+    oldest commit fetched, so older changes are missing from the counts.
+    """
+    try:
+        indexer = get_indexer(root_path, project=project)
+        return await _paged(indexer, "pairs", limit, cursor, max_tokens, indexer.coupling,
+                            max_commits, min_support, min_confidence, max_files_per_commit, exclude, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error computing coupling", e)
+
+
+@_tool
+async def ownership(
+    root_path: Optional[str] = None,
+    path: Optional[str] = None,
+    since: Optional[str] = None,
+    max_commits: Optional[int] = 500,
+    include_symbols: bool = False,
+    top: int = 5,
+    check_codeowners: bool = False,
+    limit: int = 50,
+    cursor: Optional[str] = None,
+    max_tokens: Optional[int] = None,
+    timeout_ms: Optional[int] = None,
+    project: Optional[str] = None,
+    ctx: Optional[Context] = None
+) -> Dict[str, Any]:
+    """
+    👥 Find who owns each directory - and where a single person holds the knowledge.
+
+    USE THIS to pick reviewers, or to find code only one person understands.
+    Every source file is blamed: an author's lines are the lines of theirs
+    that survive today. Commits count the commits touching the directory in
+    the window. bus_factor is the fewest authors owning 80% of the surviving
+    lines; directories come riskiest (1) first. Authors go through the
+    repository's .mailmap, so one person's several addresses count once.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project (inside a git repository)
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: Only this file or directory (and below)
+    - since: Only count commits newer than this, any git date (e.g. "6 months ago")
+    - max_commits: Only count the N most recent commits (default 500)
+    - include_symbols: Also give the authors of every top-level symbol (default false)
+    - top: Authors listed per directory and symbol (default 5)
+    - check_codeowners: Flag directories whose dominant author no CODEOWNERS rule lists (default false)
+    - limit: Entries per page (default 50)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    Directories are not recursive: each lists the files directly in it.
+    CODEOWNERS owners match an author by email, or as @handle by the
+    email's local part or the name without spaces; an @org/team owner
+    cannot be checked, leaving dominant_author_listed null. A mismatch's
+    reason is "no_rule" (no rule covers the files) or "not_an_owner".
+    Untracked files are listed in skipped_files.
+
+    EXAMPLE OUTPUT:
+    {
+        "directories": [
+            {"directory": "internal/billing", "files": 6, "lines": 1840, "bus_factor": 1,
+             "authors": [{"author": "Ann Lee", "email": "ann@example.com", "lines": 1620, "share": 0.88,
+                          "commits": 41}, ...],
+             "commits": 57,
+             "codeowners": {"rules": ["4: /internal/ @platform-team"], "owners": ["@platform-team"],
+                            "dominant_author_listed": null}}
+        ],
+        "total_count": 23,
+        "project": {"lines": 42310, "bus_factor": 4, "authors": [...]},
+        "bus_factor_share": 0.8,
+        "codeowners": {"path": ".github/CODEOWNERS", "rules": 12,
+                       "mismatches": [{"directory": "cmd/tool", "dominant_author": "Bob Ray",
+                                       "email": "bob@example.com", "share": 0.74,
+                                       "owners": ["@ann"], "reason": "not_an_owner"}]},
+        "window": {"since": null, "max_commits": 500},
+        "truncatedHistory": false
+    }
+
+    In a shallow clone "truncatedHistory": true means the walk reached the
+    oldest commit fetched, so older changes are missing from the counts.
+    """
+    try:
+        indexer = get_indexer(root_path, project=project)
+        return await _paged(indexer, "directories", limit, cursor, max_tokens, indexer.ownership,
+                            path, since, max_commits, include_symbols, top, check_codeowners, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error computing ownership", e)
+
+
+@_tool
+async def list_debt(root_path: Optional[str] = None, markers: Optional[List[str]] = None, min_age_days: Optional[int] = None, author: Optional[str] = None, blame: bool = True, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🧾 List TODO, FIXME, HACK, XXX and NOTE comments in every language - with their age from git blame and where debt piles up.
+
+    USE THIS to plan cleanup, to find the oldest TODOs, or to see one
+    person's open notes. Markers come from comments only - a "TODO" inside
+    a string literal is not one - and count in upper case, as a word:
+    `// TODO(alice): retry` gives "author": "alice", `# FIXME(#412)` gives
+    "issue": "#412". Each marker names its enclosing symbol, and git blame
+    tells when and by whom its line was written.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - markers: Only these, e.g. ["TODO", "FIXME"] (default: all five)
+    - min_age_days: Only markers whose line is at least this many days old
+    - author: Only markers whose TODO(name) or blamed author (name or email) contains this
+    - blame: Blame each marker's line for its age (default true; false is faster, with no ages)
+    - include: Only files matching one of these globs, e.g. ["internal/**", "web/src/**"]
+      (doublestar syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: Leave out files matching one of these globs, e.g. ["vendor/**"] (default: the project's, see add_project)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "markers": [
+            {"marker": "TODO", "text": "handle errors", "author": "bob", "path": ".../svc/svc.go",
+             "line": 7, "column": 5, "language": "go", "package": "github.com/john/project/svc",
+             "symbol": "Run", "age_days": 412,
+             "blame": {"commit": "cbb157e9...", "author": "Alice", "author_email": "alice@example.com",
+                       "date": "2025-08-28T10:12:00Z"}}
+        ],
+        "total_count": 1,
+        "counts": {"TODO": 1, "FIXME": 0, "HACK": 0, "XXX": 0, "NOTE": 0},
+        "by_package": [
+            {"package": "github.com/john/project/svc", "language": "go", "count": 1,
+             "by_marker": {"TODO": 1}, "oldest_days": 412}
+        ],
+        "truncatedHistory": false
+    }
+
+    "package" is the Go import path, and the directory for other languages.
+    A marker on a line not committed yet is "uncommitted": true with age 0;
+    outside git, or in an untracked file, "age_days" is null.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "markers", limit, cursor, max_tokens, indexer.list_debt, markers, min_age_days,
+                            author, blame, *_globs(indexer, include, exclude), ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error listing debt markers", e)
+
+
+@_tool
+async def find_stale_docs(root_path: Optional[str] = None, blame: bool = True, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📜 Find doc comments that drifted from their code - a copy-pasted name, parameters that no longer exist - and exported declarations with no doc.
+
+    USE THIS before trusting the docs of unfamiliar code, or to tidy them
+    up. Heuristic, every finding explains itself:
+    - name_mismatch: a Go doc opening with another identifier than the
+      declaration's, "// GetUser returns ..." above func FetchUser
+    - unknown_parameter: a doc naming a parameter the signature lacks -
+      `@param` (Java, TS/JS), Sphinx fields or `Args:` (Python), `# Arguments`
+      (Rust), in Go a `backquoted`, [linked] or camelCase word that is no
+      parameter, result, receiver or declaration of the package
+    Git blame compares when the code and its comment last changed: code
+    rewritten after its comment raises the finding's confidence one step.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - blame: Compare the code's and the comment's last change (default true; false is faster)
+    - include: Only files matching one of these globs, e.g. ["internal/**", "web/src/**"]
+      (doublestar syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: Leave out files matching one of these globs, e.g. ["vendor/**"] (default: the project's, see add_project)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "findings": [
+            {"kind": "name_mismatch", "word": "GetUser",
+             "message": "doc comment starts with 'GetUser', but the function is named FetchUser",
+             "confidence": "high", "symbol": "FetchUser", "symbol_kind": "function",
+             "path": ".../svc/svc.go", "line": 4, "language": "go", "package": "github.com/john/project/svc",
+             "doc_changed": "2024-01-01T00:00:00Z", "code_changed": "2025-03-02T09:14:00Z",
+             "code_changed_after_doc": true}
+        ],
+        "total_count": 1,
+        "counts": {"name_mismatch": 1, "unknown_parameter": 0},
+        "by_package": [
+            {"package": "github.com/john/project/svc", "language": "go", "count": 1,
+             "by_kind": {"name_mismatch": 1}, "exported": 4, "documented": 3,
+             "undocumented": ["Exported"], "coverage": 0.75}
+        ],
+        "undocumented_count": 1,
+        "truncatedHistory": false
+    }
+
+    by_package doubles as the completeness report: per package, how many
+    exported functions, methods and types (Go: methods of exported types)
+    have a doc, and which have none. Test files are left out.
+    "package" is the Go import path, and the directory for other languages.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "findings", limit, cursor, max_tokens, indexer.find_stale_docs, blame,
+                            *_globs(indexer, include, exclude), ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error finding stale docs", e)
+
+
+@_tool
+async def deprecated_usage(root_path: Optional[str] = None, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🪦 List every Go declaration marked `// Deprecated:` with the references still using it, by calling package, and the replacement its deprecation names.
+
+    USE THIS to plan a migration off old APIs, or to find the deprecated
+    code that can go. References are resolved like rename_preview's:
+    `pkg.Name` uses, the package's own unqualified uses, selectors through
+    the declaring type. Those from _test.go files are counted apart:
+    - deletable: nothing in the project references it any more
+    - test_only: only tests still use it
+    - in_use: non-test code does
+    A replacement named in the text ("Use FetchUser instead", "replaced by
+    [Client.Do]", "in favor of store.Open") is looked up in the project.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - include: Only declarations in files matching one of these globs, e.g. ["internal/**"]
+      (doublestar syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: Leave out declarations in files matching one of these globs (default: the project's, see add_project)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "deprecated": [
+            {"symbol": "GetUser", "kind": "function", "package": "store",
+             "import_path": "github.com/john/project/store", "path": ".../store/store.go", "line": 13,
+             "exported": true, "deprecation": "Use FetchUser instead.",
+             "replacement": {"name": "FetchUser", "exists": true, "symbol": "FetchUser", "kind": "function",
+                             "package": "store", "path": ".../store/store.go", "line": 16},
+             "status": "in_use", "references": 2, "test_references": 1,
+             "by_package": [
+                 {"package": "api", "import_path": "github.com/john/project/api", "path": ".../api",
+                  "references": 2, "test_references": 0,
+                  "sites": [{"path": ".../api/api.go", "line": 6, "column": 13,
+                             "kind": "qualified_reference", "function": "Handle"}]},
+                 {"package": "store", "import_path": "github.com/john/project/store", "path": ".../store",
+                  "references": 0, "test_references": 1,
+                  "sites": [{"path": ".../store/store_test.go", "line": 5, "column": 9,
+                             "kind": "reference", "function": "TestGetUser", "test": true}]}
+             ]}
+        ],
+        "total_count": 1,
+        "counts": {"in_use": 1, "test_only": 0, "deletable": 0, "deprecated": 1, "references": 2,
+                   "test_references": 1, "with_replacement": 1, "replacement_not_found": 0}
+    }
+
+    Deletable declarations come first, then the most used. A replacement
+    not declared in the project has "exists": false; one from a package
+    outside it (errors.Is) "exists": null and its import path as "external".
+    "unresolved" lists selectors of the name whose receiver type is unknown,
+    possibly more uses. An exported declaration may still be used by other
+    modules importing its package, which this project cannot see.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "deprecated", limit, cursor, max_tokens, indexer.deprecated_usage,
+                            *_globs(indexer, include, exclude), ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error listing deprecated usage", e)
+
+
+@_tool
+async def scan_secrets(root_path: Optional[str] = None, blame: bool = True, format: str = "json", include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🔑 Scan the project for hard-coded credentials: cloud keys, API tokens, private keys, passwords in strings and config files.
+
+    USE THIS before publishing a repository or an audit, or when a key may
+    have leaked. Runs only when called - nothing is indexed - and never
+    returns a secret: only its first characters and length, the line with
+    it redacted, and a fingerprint telling equal secrets apart.
+    - Known formats, on any line: AWS access keys, GitHub, GitLab, Slack,
+      Stripe, Google and npm tokens, -----BEGIN ... PRIVATE KEY----- headers
+    - In string literals and config values (.env, YAML, JSON, TOML, INI,
+      Terraform): Bearer tokens, JWTs, passwords in URLs, strings assigned
+      to password/secret/token/api_key names, high-entropy strings
+    Placeholders ("changeme", "${API_KEY}", "<token>") are skipped.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - blame: Find the commit that introduced each secret (default true; false is faster)
+    - format: "json" (default) or "sarif" for a SARIF 2.1.0 log to upload to code scanning (not paged)
+    - include: Only files matching one of these globs, e.g. ["internal/**", "deploy/**"]
+      (doublestar syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: Leave out files matching one of these globs, e.g. ["docs/**"] (default: the project's, see add_project)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "findings": [
+            {"kind": "aws_access_key_id", "description": "AWS access key ID", "confidence": "high",
+             "column": 15, "redacted": "AKIA******** (20 chars)", "length": 20,
+             "fingerprint": "4f1c0a9e2b7d3c58", "preview": "awsKey := \"AKIA******** (20 chars)\"",
+             "line": 12, "path": ".../internal/store/s3.go", "language": "go", "symbol": "newClient",
+             "introduced": {"commit": "9fceb02...", "author": "John", "author_email": "john@example.com",
+                            "date": "2024-05-02T10:00:00Z"}},
+            {"kind": "generic_secret", "description": "Secret assigned to 'password'", "confidence": "medium",
+             "column": 15, "redacted": "s3cr******** (12 chars)", "length": 12,
+             "fingerprint": "a03e51c7d9b2f648", "preview": "password: s3cr******** (12 chars)",
+             "line": 8, "path": ".../deploy/values.yaml", "section": "database.password",
+             "uncommitted": true},
+            {"kind": "private_key", "description": "Private key", "confidence": "medium", "test_fixture": true,
+             "path": ".../testdata/server.key", "line": 1, "...": "..."}
+        ],
+        "total_count": 3,
+        "counts": {"aws_access_key_id": 1, "generic_secret": 1, "private_key": 1},
+        "by_confidence": {"high": 1, "medium": 2, "low": 0},
+        "test_fixture_count": 1,
+        "files_scanned": 418,
+        "truncatedHistory": false
+    }
+
+    Findings in tests or under testdata/ and fixtures/ are kept, one
+    confidence step lower ("test_fixture": true), and listed last.
+    "uncommitted" means the line is not in any commit yet. With
+    format="sarif" each finding is an XRAY004 result, level "error" for
+    high confidence, "warning" for medium, "note" for low.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        if format == "sarif":
+            return await _run(indexer, indexer.scan_secrets, blame, format,
+                              *_globs(indexer, include, exclude), ctx=ctx, timeout_ms=timeout_ms,
+                              present=format != "sarif")
+        return await _paged(indexer, "findings", limit, cursor, max_tokens, indexer.scan_secrets, blame, format,
+                            *_globs(indexer, include, exclude), ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error scanning for secrets", e)
+
+
+@_tool
+async def get_symbol_source(root_path: Optional[str] = None, *, symbol: str, path: Optional[str] = None, context_lines: int = 0, include_type: bool = False, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📄 Get the exact source of a Go, TypeScript, JavaScript, Python, Rust, Java or .proto declaration - doc comment included.
+
+    USE THIS instead of reading a whole file once list_symbols or find_symbol
+    told you what you want. The text is cut from the same file version the
+    index was parsed from (refreshed if the file changed), and content_hash
+    identifies that version.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - symbol: "Name" or "Type.Method", e.g. "UserService.GetUser" (TS/JS and Python: "Class.member", Rust: "Type.method", .proto: "Service.Rpc";
+      a nested Go declaration: "Parent.name", e.g. "Server.Start.handle"), or its symbol_id
+    - path: Optional file or package directory to disambiguate the name
+    - context_lines: Lines of surrounding code to add above and below (default 0)
+    - include_type: For methods, also return the receiver type's declaration
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "name": "UserService.GetUser",
+        "type": "method",
+        "language": "go",
+        "path": "/Users/john/project/main.go",
+        "signature": "func (s *UserService) GetUser(id int) (*User, error)",
+        "start_line": 45,
+        "end_line": 52,
+        "declaration_line": 46,
+        "source": "// GetUser loads a user by id.\nfunc (s *UserService) GetUser(id int) (*User, error) {\n...\n}",
+        "content_hash": "205f847044abc1605c4f208efb7400a8ce60668a",
+        "receiver_type": {"name": "UserService", "type": "struct", "start_line": 30, "end_line": 34, "source": "...", ...}
+    }
+
+    start_line includes the doc comment; declaration_line is where the
+    declaration itself begins. With context_lines, "context_before" and
+    "context_after" hold the surrounding text separately from "source".
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.get_symbol_source, symbol, path, context_lines, include_type, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error getting symbol source", e)
+
+
+@_tool
+async def dependencies(root_path: Optional[str] = None, ecosystem: Optional[str] = None, direct_only: bool = False, licenses: bool = True, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🪪 List third-party dependencies from go.mod/go.sum, package.json and requirements.txt - versions, licenses, and the unused ones.
+
+    USE THIS for a license review, before upgrading, or to trim go.mod.
+    Go requirements are direct or "// indirect", with their replace
+    directive and whether go.sum pins their content; a pseudo-version
+    (v0.0.0-20220722155255-886fb9371eb4) is decoded into its commit, the
+    commit's time and the tag it follows. Licenses are detected from what is
+    on disk - vendor/, the module cache, node_modules, a .venv - by the
+    wording of LICENSE/COPYING files or the package metadata; nothing is
+    downloaded. A direct dependency no indexed file imports is "removable":
+    check with `go mod tidy` (or your package manager) before deleting it.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - ecosystem: Only "go", "npm" or "python" dependencies (default all)
+    - direct_only: Leave out indirect Go requirements (default false)
+    - licenses: Look for licenses on disk (default true; false is faster)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "dependencies": [
+            {"ecosystem": "go", "manifest": "go.mod", "name": "golang.org/x/sync",
+             "version": "v0.0.0-20220722155255-886fb9371eb4", "direct": true,
+             "pseudo_version": {"commit": "886fb9371eb4", "time": "2022-07-22T15:52:55Z", "base": null},
+             "checksum": true, "imported_by": 4, "license": "BSD-3-Clause", "license_files": ["LICENSE"],
+             "license_source": "module_cache"},
+            {"ecosystem": "go", "manifest": "go.mod", "name": "github.com/unused/thing", "version": "v1.0.0",
+             "direct": true, "checksum": true, "imported_by": 0, "removable": true},
+            {"ecosystem": "npm", "manifest": "web/package.json", "name": "lodash", "version": "^4.17.21",
+             "direct": true, "kind": "runtime", "imported": true, "installed_version": "4.17.21",
+             "license": "MIT", "license_source": "node_modules"}
+        ],
+        "total_count": 3,
+        "manifests": [{"ecosystem": "go", "path": "go.mod", "module": "example.com/app", "go_sum": true},
+                      {"ecosystem": "npm", "path": "web/package.json"}],
+        "counts": {"go": 2, "npm": 1, "python": 0},
+        "direct_count": 3,
+        "removable": [{"ecosystem": "go", "manifest": "go.mod", "name": "github.com/unused/thing"}],
+        "licenses": {"BSD-3-Clause": 1, "MIT": 1, "not found": 1}
+    }
+
+    "license" is an SPDX identifier where the text is recognized,
+    "unknown" for license files matching none, and missing ("not found" in
+    the tally) when the dependency is not on disk. "replaced_by" names a
+    replace directive's target. npm "kind" is runtime, dev, peer or
+    optional; only runtime dependencies can be removable. A Python
+    requirement is only judged removable when it is installed in a .venv,
+    venv or env, whose metadata names the modules it provides.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "dependencies", limit, cursor, max_tokens, indexer.dependencies, ecosystem,
+                            direct_only, licenses, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error listing dependencies", e)
+
+
+@_tool
+async def dependency_graph(root_path: Optional[str] = None, depth: Optional[int] = None, include_std: bool = False, include_external: bool = False, format: str = "json", include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, cross_language: bool = False, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🕸️ Map which packages of a Go module import which - as JSON or GraphViz DOT.
+
+    Edges point from importer to imported package and are weighted by the
+    number of files of the importer that import the target. Go forbids
+    import cycles between packages, but collapsing by directory can reveal
+    cycles between areas of the code; edges inside a cycle are marked.
+
+    TypeScript/JavaScript and Python files join the graph by directory. Their
+    imports of project files become internal edges, Node builtins and the
+    Python standard library count as std, npm and PyPI packages as external.
+    Internal nodes list their "languages", their "description" (as in
+    project_overview), and directories that are Python packages (have an
+    __init__.py) their dotted "python_packages". Rust files
+    join the same way: `mod` declarations and `use` paths into the crate (or
+    another crate of the workspace) are internal edges, std/core/alloc is std
+    and other crates are external.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - depth: Collapse packages to this many directory levels below the module root
+             (1 = top-level directories); edges between merged packages add up
+    - include_std: Also show standard library packages (default false)
+    - include_external: Also show packages of other modules, grouped by go.mod
+                        requirement when collapsing (default false)
+    - format: "json" (default), "dot" for GraphViz source of the same graph, or
+              "sarif" for its import cycles as a SARIF 2.1.0 log (rule XRAY002)
+    - include: Only files matching one of these globs, e.g. ["internal/**", "cmd/{api,worker}/**"]
+      (doublestar syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: Leave out files matching one of these globs, e.g. ["**/*_test.go", "vendor"]
+      (default: the project's, see add_project)
+    - cross_language: Also draw the embed, exec and http links of cross_language_links (default false)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "module": "github.com/john/project",
+        "depth": null,
+        "nodes": [
+            {"id": "github.com/john/project/api", "kind": "internal", "files": 4, "languages": ["go"],
+             "description": "Package api serves the HTTP API.", "description_from": ["doc.go"]},
+            {"id": "github.com/john/project/store", "kind": "internal", "files": 2, "languages": ["go"],
+             "description": ""}
+        ],
+        "edges": [
+            {"from": "github.com/john/project/api", "to": "github.com/john/project/store", "weight": 3}
+        ],
+        "cycles": [],
+        "node_count": 2,
+        "edge_count": 1
+    }
+
+    Edges inside a cycle also carry "cycle": true and "import_sites", the
+    import statements (path, line, column) behind them.
+
+    With cross_language, links between languages join the graph as edges
+    with a "kind" - "embed", "exec" or "http" - weighted by their number of
+    links, from the directory of the Go or TypeScript side to that of the
+    file embedded, the script run or the route's handler. They never make
+    a cycle.
+
+    With format="dot", "dot" holds a digraph ready for `dot -Tsvg`: import
+    paths are quoted, other modules are dashed ellipses and cycle edges
+    are red with class="cycle".
+
+    Packages are named by their import path in the module whose go.mod is
+    nearest them, and imports resolve into go.work modules and local
+    replace targets; with several modules, "modules" lists their paths.
+
+    With include or exclude, files left out neither import nor are imported:
+    a package with none of its files selected drops out of the graph, and
+    "path_filter" echoes the globs.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.dependency_graph, depth, include_std, include_external, format,
+                          *_globs(indexer, include, exclude), cross_language, ctx=ctx, timeout_ms=timeout_ms, present=format != "sarif")
+    except Exception as e:
+        return _error("Error building dependency graph", e)
+
+
+@_tool
+async def find_cycles(root_path: Optional[str] = None, include_tests: Optional[bool] = None, format: str = "json", ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🔁 Find every import cycle between the Go packages of a module, and the cheapest way to break it.
+
+    USE THIS when the build fails with "import cycle not allowed", when
+    `go test` does, or before moving code between packages. Each cycle is
+    a strongly connected component of the package graph, reported with the
+    full paths around it (a → b → c → a), the files and import lines behind
+    every edge, and "suggested_break": the smallest set of edges whose
+    removal breaks the cycle, preferring edges made of fewer files.
+
+    Test files are followed too, and cycles that only exist through them
+    are reported on their own:
+    - "import": a real cycle; the package does not build
+    - "test": closed by an in-package _test.go file; `go test` fails
+    - "soft": closed by an external `package x_test` file; Go permits
+      this ("permitted": true), but it is worth knowing about
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - include_tests: Also follow the imports of _test.go files (default: .xray.yaml, else true)
+    - format: "json" (default), or "sarif" for a SARIF 2.1.0 log (rule XRAY002;
+              test cycles are warnings, soft ones notes)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "module": "github.com/john/project",
+        "cycles": [
+            {
+                "kind": "import",
+                "permitted": false,
+                "packages": ["github.com/john/project/api", "github.com/john/project/store"],
+                "path": ["github.com/john/project/api", "github.com/john/project/store", "github.com/john/project/api"],
+                "paths": [["github.com/john/project/api", "github.com/john/project/store", "github.com/john/project/api"]],
+                "edges": [
+                    {"from": "github.com/john/project/store", "to": "github.com/john/project/api", "kind": "import",
+                     "import_sites": [{"path": "/path/store/hooks.go", "line": 5, "column": 2, "kind": "import"}]},
+                    ...
+                ],
+                "suggested_break": [
+                    {"from": "github.com/john/project/store", "to": "github.com/john/project/api", "files": 1,
+                     "import_sites": [...]}
+                ]
+            }
+        ],
+        "total_count": 1,
+        "counts": {"import": 1, "test": 0, "soft": 0}
+    }
+
+    A test or soft cycle lists only the paths that need a test import, and
+    its suggested break only test edges of that kind. Large components
+    list at most 25 paths ("paths_truncated": true).
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, True)
+        return await _run(indexer, indexer.find_cycles, include_tests, format, ctx=ctx, timeout_ms=timeout_ms, present=format != "sarif")
+    except Exception as e:
+        return _error("Error finding import cycles", e)
+
+
+@_tool
+async def service_map(root_path: Optional[str] = None, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🗺️ Split a Go monorepo into its binaries: which packages each main package builds in, which are shared, which none use.
+
+    USE THIS to find service boundaries before extracting a service, to see
+    what a change to a package redeploys, or to spot packages no binary
+    reaches. Every `package main` with a `func main` is a service; its
+    packages are everything its non-test files import, transitively,
+    inside the project.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - include: Only files matching one of these globs, e.g. ["cmd/**", "internal/**"]
+      (doublestar syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: Leave out files matching one of these globs, e.g. ["tools/**", "hack/**"] so
+      tooling binaries are no services (default: the project's, see add_project)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "module": "github.com/john/project",
+        "services": [
+            {"name": "api", "dir": "cmd/api", "package": "github.com/john/project/cmd/api",
+             "main": {"path": "/path/cmd/api/main.go", "line": 12},
+             "packages": ["github.com/john/project/internal/auth", "github.com/john/project/internal/store"],
+             "package_count": 2, "exclusive_count": 1,
+             "containers": [{"kind": "compose_service", "name": "api", "path": "/path/docker-compose.yml", "line": 2}]}
+        ],
+        "shared": [
+            {"package": "github.com/john/project/internal/store", "dir": "internal/store",
+             "services": ["cmd/api", "cmd/worker"], "service_count": 2}
+        ],
+        "unreachable": [
+            {"package": "github.com/john/project/pkg/legacy", "dir": "pkg/legacy", "kind": "dead",
+             "exported": 0, "imported_by": []}
+        ],
+        "service_count": 2,
+        "package_count": 7,
+        "port_mismatches": [
+            {"issue": "exposed_not_listening", "port": 9090, "container": "api", "kind": "compose_service",
+             "path": "/path/docker-compose.yml", "declared_at": {"path": "/path/Dockerfile", "line": 14},
+             "listening_on": [8080]}
+        ]
+    }
+
+    "exclusive_count" is how many of a service's packages no other service
+    uses. An unreachable package is "test_only" when only test files import
+    it, "library" when it exports something other modules could use, and
+    "dead" otherwise; "imported_by" names the unreachable packages that
+    still import it. With include or exclude, files left out neither
+    import nor are imported, and "path_filter" echoes the globs. When the
+    project has Dockerfiles or compose files, "containers" names the ones
+    running each service and "port_mismatches" the ports they expose that
+    the binary does not listen on, or the other way round (see list_containers).
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.service_map, *_globs(indexer, include, exclude), ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error mapping services", e)
+
+
+@_tool
+async def coupling_metrics(root_path: Optional[str] = None, sort_by: str = "afferent", include_tests: Optional[bool] = None, max_edges: int = 20, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    ⚖️ Coupling metrics per Go package: who imports it, what it imports, its instability, and the heaviest edges.
+
+    USE THIS for architecture reviews, next to find_cycles: which packages
+    everything leans on (high afferent coupling - change them carefully),
+    which lean on everything (high instability), and which imports carry
+    the most of another package's API. Only imports between project
+    packages count:
+    - afferent (Ca): project packages importing this one
+    - efferent (Ce): project packages this one imports
+    - instability: Ce / (Ca + Ce), 0 is maximally stable, 1 maximally unstable
+      (null for a package neither importing nor imported)
+    - symbols_used / symbols_provided: distinct identifiers it selects from
+      other project packages (`store.Open`) / of its own that others select
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - sort_by: "afferent" (default), "efferent", "instability", "files", "symbols_used",
+      "symbols_provided" (highest first) or "package" (A-Z)
+    - include_tests: Also count the imports and references of _test.go files (default: .xray.yaml, else false)
+    - max_edges: How many of the heaviest edges to list (default 20)
+    - include: Only files matching one of these globs, e.g. ["internal/**"]
+      (doublestar syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: Leave out files matching one of these globs, e.g. ["tools/**"] (default: the project's, see add_project)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Packages per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "module": "github.com/john/project",
+        "packages": [
+            {"package": "github.com/john/project/internal/store", "dir": "internal/store",
+             "afferent": 4, "efferent": 1, "instability": 0.2, "files": 6,
+             "symbols_used": 2, "symbols_provided": 17}
+        ],
+        "heaviest_edges": [
+            {"from": "github.com/john/project/internal/api", "to": "github.com/john/project/internal/store",
+             "symbol_count": 9, "references": 41, "files": 3, "symbols": ["ErrNotFound", "Open", "User", "..."]}
+        ],
+        "total_count": 7,
+        "edge_count": 11,
+        "sort_by": "afferent"
+    }
+
+    An edge's weight is the distinct identifiers selected through the
+    import (`pkg.Name`); blank and dot imports make edges with no symbols.
+    Ties go by package name. With include or exclude, files left out
+    neither import nor are imported, and "path_filter" echoes the globs.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        include_tests = indexer.setting("include_tests", include_tests, False)
+        return await _paged(indexer, "packages", limit, cursor, max_tokens, indexer.coupling_metrics, sort_by,
+                            include_tests, max_edges, *_globs(indexer, include, exclude),
+                            ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error computing coupling metrics", e)
+
+
+@_tool
+async def export_tags(root_path: Optional[str] = None, output: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🏷️ Write a Universal Ctags tags file of the project's Go symbols, for vim, Emacs and friends.
+
+    Built from the existing index, so only changed files are parsed. Kinds:
+    f function/method, t type, s struct, i interface, m struct field,
+    n interface method, c constant, v variable. Every tag has line: and
+    package: fields; methods are scoped to their receiver (struct:Store)
+    and carry receiver:*Store, fields their struct. Addresses are /^...$/
+    search patterns and lines are sorted, so vim's binary search works.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - output: Optional file to write, relative to root_path (default "tags");
+      paths inside it are relative to its directory
+    - ref: Optional git tag, branch or SHA to tag instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "path": "/Users/john/project/tags",
+        "tag_count": 1843,
+        "file_count": 112
+    }
+
+    A written line (tab-separated) looks like:
+    Get <TAB> store/store.go <TAB> /^func (s *Store) Get(id int) (*User, error) {$/;" <TAB> f <TAB> line:42 <TAB> struct:Store
+        <TAB> receiver:*Store <TAB> package:store <TAB> signature:(id int) (*User, error)
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.export_tags, output, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error exporting tags", e)
+
+
+@_tool
+async def export_scip(root_path: Optional[str] = None, output: Optional[str] = None, version: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🛰️ Write a SCIP index of the Go code, for uploading to Sourcegraph (`src code-intel upload`).
+
+    Built from the existing index and call graph. Each file becomes a SCIP
+    document with definition occurrences for every declaration, reference
+    occurrences for resolved calls and function values, and import
+    occurrences. Symbols use scip-go's grammar with the go.mod module as
+    package, e.g. scip-go gomod example.com/shop <version> `example.com/shop/store`/Store#Get().
+    so cross-repository navigation works against scip-go indexes. Types and
+    methods satisfying project interfaces carry implementation relationships.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - output: Optional file to write, relative to root_path (default "index.scip")
+    - version: Optional version of the module's symbols (a release tag, say);
+      defaults to the commit analyzed, or "." outside git
+    - ref: Optional git tag, branch or SHA to index instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "path": "/Users/john/project/index.scip",
+        "module": "example.com/shop",
+        "document_count": 112,
+        "occurrence_count": 5310,
+        "symbol_count": 1843,
+        "external_symbol_count": 96
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.export_scip, output, version, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error exporting SCIP index", e)
+
+
+@_tool
+async def export_symbols(root_path: Optional[str] = None, output: Optional[str] = None, format: str = "csv", ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📤 Write the whole symbol table to a CSV or JSON Lines file, for spreadsheets, notebooks and databases.
+
+    One row per declaration of every indexed language, nested ones too:
+    symbol_id, name, kind, language, package (a Go import path, else the
+    file's directory), receiver (a method's type, a field's struct, a
+    member's class), signature, file, start_line, end_line, exported, doc
+    (first sentence), then complexity, loc, statements, max_nesting,
+    param_count, result_count and distinct_callees for Go functions with a
+    body. Rows are streamed to the file as they are produced, so the export
+    of a large index never sits in memory; only the counts come back.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - output: Optional file to write, relative to root_path (default "symbols.csv" or "symbols.jsonl")
+    - format: "csv" (default; a header line, empty cells where a value is missing) or "jsonl" (one object per line)
+    - ref: Optional git tag, branch or SHA to export instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "path": "/Users/john/project/symbols.csv",
+        "format": "csv",
+        "columns": ["symbol_id", "name", "kind", "language", "package", "receiver", "signature", "file", "..."],
+        "symbol_count": 104233,
+        "file_count": 2874
+    }
+
+    A written CSV line looks like:
+    go:example.com/shop/store:Store.Get:3fa2c1d0,Get,method,go,example.com/shop/store,Store,"func (s *Store) Get(id int) (*User, error)",store/store.go,42,58,true,Get returns the user with an id.,4,14,9,2,1,2,3
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.export_symbols, output, format, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error exporting symbols", e)
+
+
+@_tool
+async def export_references(root_path: Optional[str] = None, output: Optional[str] = None, format: str = "csv", ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🔗 Write every Go call and function reference to a CSV or JSON Lines file - the edges to export_symbols' nodes.
+
+    One row per call site or function value taken, from the call graph:
+    from_symbol_id, from_symbol, to_symbol_id, to_symbol (qualified by the
+    package's import path), kind (call, go, defer, reference), external,
+    file, line, column. An external callee such as net/http.Get has no
+    symbol_id. The IDs are those of export_symbols, so the two files join.
+    Rows are streamed to the file; only the counts come back.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - output: Optional file to write, relative to root_path (default "references.csv" or "references.jsonl")
+    - format: "csv" (default) or "jsonl"
+    - ref: Optional git tag, branch or SHA to export instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "path": "/Users/john/project/references.csv",
+        "format": "csv",
+        "columns": ["from_symbol_id", "from_symbol", "to_symbol_id", "to_symbol", "kind", "external", "file", "line", "column"],
+        "reference_count": 48210
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.export_references, output, format, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error exporting references", e)
+
+
+@_tool
+async def export_chunks(root_path: Optional[str] = None, output: Optional[str] = None, path: Optional[str] = None, target_tokens: int = 512, max_chunk_tokens: int = 1024, overlap_tokens: int = 64, tokenizer: str = "chars", ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🍱 Write the source cut into embedding chunks - whole declarations, never half a function - as JSON Lines for RAG.
+
+    USE THIS to feed a vector store instead of chunking by lines. Chunks
+    follow the symbol index: each top-level declaration with its doc
+    comment and decorators, small neighbours of the same file joined up to
+    target_tokens, one over max_chunk_tokens split where its brackets (or
+    Python's indentation) are shallowest, each part repeating the last
+    overlap_tokens of the one before. The code between declarations
+    (package clauses, imports) is chunked too, so every line is in a chunk.
+    Each line of the file is one chunk: chunk_id, file, language, package
+    (a Go import path, else the file's directory), start_line, end_line,
+    symbol_ids and symbols (the declarations starting in it, a split
+    declaration's own first in every part), part and parts of a split one,
+    tokens, file_hash and text. Pass a hit's symbol ID to get_symbol_source
+    for the current source. Chunks are streamed to the file; only the
+    counts come back. An unchanged file gives the same chunks, IDs
+    included, on every run.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - output: Optional file to write, relative to root_path (default "chunks.jsonl")
+    - path: Optional file or directory to chunk, relative to root_path (default: the whole project)
+    - target_tokens: Size small neighbouring declarations are joined up to (default 512)
+    - max_chunk_tokens: Size over which a declaration is split (default 1024)
+    - overlap_tokens: How much of a split part's end the next part repeats (default 64)
+    - tokenizer: How tokens are counted: "chars" (default; about four characters a token), "words"
+      (identifiers, numbers and punctuation) or "tiktoken" (cl100k_base, when the package is installed)
+    - ref: Optional git tag, branch or SHA to chunk instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "path": "/Users/john/project/chunks.jsonl",
+        "format": "jsonl",
+        "columns": ["chunk_id", "file", "language", "package", "start_line", "end_line", "symbol_ids", "..."],
+        "chunk_count": 5120,
+        "file_count": 874,
+        "token_count": 1630211,
+        "split_symbol_count": 96,
+        "tokenizer": "chars"
+    }
+
+    A written line looks like:
+    {"chunk_id": "a1155387f4d2839f", "file": "store/store.go", "language": "go", "package": "example.com/shop/store", "start_line": 40, "end_line": 58, "symbol_ids": ["go:example.com/shop/store:Store.Get:3fa2c1d0"], "symbols": ["Store.Get"], "part": null, "parts": null, "tokens": 161, "file_hash": "9c1e...", "text": "// Get returns the user with an id.\nfunc (s *Store) Get(id int) (*User, error) {..."}
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.export_chunks, output, path, target_tokens, max_chunk_tokens,
+                          overlap_tokens, tokenizer, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error exporting chunks", e)
+
+
+@_tool
+async def generate_report(root_path: Optional[str] = None, packages: bool = True, types: bool = True, entry_points: bool = True, dependencies: bool = True, hotspots: bool = True, max_items: int = 20, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📝 Write up the project's architecture as one Markdown document, ready to commit as ARCHITECTURE.md.
+
+    Sections, each switched on or off by its flag: the package tree with the
+    first sentence of every package comment, exported interfaces and the
+    types with most methods (methods listed), main packages and HTTP routes,
+    external modules with the packages importing them, and the functions
+    ranked highest by git churn × complexity. Declarations link to
+    `path#Lstart-Lend` relative to root_path, so the links work when the
+    file is viewed on GitHub. Output is sorted throughout and carries no
+    timestamps: the same tree always gives the same document.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - packages / types / entry_points / dependencies / hotspots: Include that section (all default true)
+    - max_items: Maximum entries per list (default 20)
+    - ref: Optional git tag, branch or SHA to report on instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "markdown": "# github.com/acme/api architecture\n\n## Packages\n\n- [`internal/store/`](internal/store) (package `store`, 12 files) - Package store persists users.\n...",
+        "sections": ["packages", "types", "entry_points", "dependencies", "hotspots"],
+        "line_count": 214
+    }
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.generate_report, packages, types, entry_points,
+                          dependencies, hotspots, max_items, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error generating report", e)
+
+
+@_tool
+async def file_dependencies(root_path: Optional[str] = None, *, path: str, language: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📦 Show which packages a Go, Python or Rust file really depends on.
+
+    Import aliases are resolved, so `db "database/sql"` is reported as a
+    dependency on database/sql (not a phantom "db" package), together with the
+    identifiers the file uses from it.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - path: A Go, Python or Rust file (absolute, or relative to root_path)
+    - language: "go" to read a file of another extension (a template, a script) as Go (default: the file's own)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+
+    EXAMPLE OUTPUT:
+    {
+        "path": "/Users/john/project/main.go",
+        "package": "main",
+        "dependencies": [
+            {
+                "path": "database/sql",
+                "names": ["db"],
+                "kinds": ["alias"],
+                "lines": [8],
+                "used": ["DB"],
+                "side_effect_only": false
+            },
+            {
+                "path": "github.com/lib/pq",
+                "names": [],
+                "kinds": ["blank"],
+                "lines": [9],
+                "used": [],
+                "side_effect_only": true
+            }
+        ],
+        "total_count": 2
+    }
+
+    NOTES:
+    - kinds: "default", "alias", "blank" (imported for side effects), "dot"
+    - A path imported twice under different names appears once with every name
+    - Dot imports set `merged_into_file_scope` and list the exported names the
+      file uses that are not declared in its own package
+    - Selectors on fields (`s.db.QueryRow`) and on locals that shadow an
+      import name are not counted as package references
+    - Python files report their dotted "module" instead of "package". `import a as b`
+      and `from x import y as z` are attributed to a and x (or the submodule x.y);
+      kinds are "default", "alias", "from" and "star", each dependency carries the
+      file it "resolved" to, its "origin" (internal, stdlib, external) and whether
+      only `if TYPE_CHECKING:` blocks import it
+    - Rust files report their module path ("crate::shapes::circle") as "module". Each
+      `use` item, `mod` declaration and fully qualified path (`std::mem::swap`) counts;
+      kinds are "use", "alias", "glob", "extern_crate", "mod" and "path", and
+      dependencies carry "resolved", "crate" and "origin" (internal, std, external)
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _run(indexer, indexer.file_dependencies, path, language, ctx=ctx, timeout_ms=timeout_ms, present=True)
+    except Exception as e:
+        return _error("Error resolving dependencies", e)
+
+
+@_tool
+async def what_breaks(exact_symbol: Dict[str, Any], include_aliases: bool = False, include_tests: Optional[bool] = None, build_context: Optional[Dict[str, Any]] = None, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, snippet_lines: Optional[int] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    💥 STEP 3: See what code might break if you change this symbol.
+    
+    USE THIS AFTER find_symbol() to understand the impact of changing a function/class.
+    Shows you every place in the codebase where this symbol name appears.
+    
+    INPUT:
+    - exact_symbol: Pass THE ENTIRE SYMBOL OBJECT from find_symbol(), not just the name!
+                   Must be a dictionary with AT LEAST 'name' and 'path' keys,
+                   or a 'symbol_id' (looked up as the declaration is now).
+    - include_aliases: For Go types, also search usages of aliases that resolve to
+                   this type (`type Account = User`); those references carry "via_alias"
+                   (.proto definitions always add their generated Go names, as "via_go")
+    - include_tests: Also list references in test files (default: .xray.yaml, else true; false for production code only)
+    - build_context: Optional {"goos", "goarch", "tags"} - leave out references in Go files
+                   that build does not compile (e.g. the _windows.go variants for linux)
+    - include: Only references in files matching one of these globs, e.g. ["internal/**"] (doublestar
+                   syntax, relative to the project root; default: the project's, see add_project)
+    - exclude: Leave out references in files matching one of these globs, e.g. ["**/*_test.go"]
+                   (default: the project's, see add_project)
+    - snippet_lines: Inline each reference's line plus this many lines of context either side
+                   (0-20; default: no snippets), the matched name marked by its columns
+                   (see find_callers); max_tokens drops snippets before references
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false)
+    - limit: Entries per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+    - project: Name of the added project the symbol belongs to (default: the added project
+                   holding its path, else the git repository around it)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    
+    EXAMPLE INPUT:
+    # First, get a symbol from find_symbol():
+    symbols = find_symbol("/Users/john/project", "authenticate")["symbols"]
+    symbol = symbols[0]  # Pick the first result
+    
+    # Then pass THE WHOLE SYMBOL OBJECT:
+    what_breaks(symbol)
+    # or directly:
+    what_breaks({
+        "name": "authenticate_user",
+        "type": "function",
+        "path": "/Users/john/project/src/auth.py",
+        "start_line": 45,
+        "end_line": 67
+    })
+    
+    EXAMPLE OUTPUT:
+    {
+        "references": [
+            {
+                "file": "/Users/john/project/src/api.py",
+                "line": 23,
+                "text": "    user = authenticate_user(username, password)",
+                "language": "python"
+            },
+            {
+                "file": "/Users/john/project/tests/test_auth.py", 
+                "line": 45,
+                "text": "def test_authenticate_user():",
+                "language": "python"
+            }
+        ],
+        "total_count": 2,
+        "note": "Found 2 potential references based on a text search for the name 'authenticate_user'. This may include comments, strings, or other unrelated symbols."
+    }
+    
+    ⚠️ IMPORTANT: This does a text search for the name, so it might find:
+    - Actual function calls (what you want!)
+    - Comments mentioning the function
+    - Other functions/variables with the same name
+    - Strings containing the name
+    
+    Review each reference to determine if it's actually affected.
+
+    For a .proto message, field, enum, service or rpc the result also has "go":
+    the generated Go declaration it maps to. Services and rpcs list the Go
+    methods implementing them under "implementations" (see list_grpc_services).
+    """
+    try:
+        # Extract root path from the symbol's path
+        symbol_path = Path(exact_symbol['path'])
+        root_path = str(symbol_path.parent)
+        # The deepest added project holding the symbol
+        added = sorted((root for root in _added().values() if symbol_path.is_relative_to(root)), key=len)
+        if project:
+            root_path = resolve_root(None, project)
+        elif added:
+            root_path = added[-1]
+        else:
+            # Find a suitable root (go up until we find a git repo or reach root)
+            while root_path != '/':
+                if (Path(root_path) / '.git').exists():
+                    break
+                parent = Path(root_path).parent
+                if parent == Path(root_path):
+                    break
+                root_path = str(parent)
+        
+        indexer = get_indexer(root_path, ref, include_generated)
+        include_tests = indexer.setting("include_tests", include_tests, True)
+        return await _paged(indexer, "references", limit, cursor, max_tokens,
+                            indexer.what_breaks, exact_symbol, include_aliases, include_tests,
+                            build_context, *_globs(indexer, include, exclude), snippet_lines,
+                            ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error finding references", e)
+
+
+@_tool
+async def rename_preview(root_path: Optional[str] = None, *, symbol: str, new_name: str, path: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    ✏️ Plan a rename before doing it: every edit, every collision, every risk.
+
+    USE THIS before renaming a function, type, method or field. Nothing is
+    changed - the result is the complete edit plan, one entry per location
+    with its range and replacement text, ready for a client to apply.
+
+    For Go, references are resolved rather than grepped: unqualified uses in
+    the declaring package, `pkg.Name` through imports, selectors whose
+    receiver type is the declaring type (promoted fields and methods
+    included), composite literal keys, method expressions, the per-platform
+    variants of the declaration and the doc comment that starts with the name.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - symbol: "Name", "Type.Method" or "Type.Field", or its symbol_id
+    - new_name: The name to rename it to
+    - path: Optional file or package directory to disambiguate the name
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false);
+                         generated files are searched for references either way
+    - limit: Edits per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "symbol": {"name": "GetUser", "qualified_name": "GetUser", "type": "function", "package": "store",
+                   "path": ".../store/user.go", "line": 13, "exported": true},
+        "new_name": "LoadUser",
+        "edits": [
+            {"path": ".../api/api.go", "line": 6, "column": 13, "kind": "qualified_reference",
+             "old_text": "GetUser", "new_text": "LoadUser",
+             "range": {"startLine": 6, "startColumn": 13, "endLine": 6, "endColumn": 20}},
+            {"path": ".../store/user.go", "line": 12, "column": 4, "kind": "doc_comment", ...},
+            {"path": ".../store/user.go", "line": 13, "column": 6, "kind": "definition", ...}
+        ],
+        "collisions": [],
+        "risks": [
+            {"kind": "other_packages", "message": "1 other package(s) use GetUser; they are edited too",
+             "packages": ["example.com/app/api"], "references": 1},
+            {"kind": "string_literal", "context": "sql", "path": ".../store/user.go", "line": 25,
+             "text": "\"SELECT GetUser ...\""}
+        ],
+        "unresolved": [],
+        "counts": {"edits": 3, "files": 2, "collisions": 0, "risks": 2, "unresolved": 0}
+    }
+
+    Edit kinds: definition, doc_comment, reference, qualified_reference,
+    selector, composite_key, method_expression; edits in generated files
+    carry "generated": true.
+
+    "collisions" are declarations the new name would clash with: the
+    package already declares it, the type already has such a field or
+    method, a file using the name imports a package under it, or a
+    function's local of that name would capture a reference.
+
+    "risks" are what a rename does not edit but may break: string literals
+    naming the old name ("context": struct_tag, reflection, sql or string),
+    uses from other packages of an exported name, a change of visibility,
+    generated files, interfaces a method satisfies (or, for an interface
+    method, the implementations to rename with it) and untagged fields of
+    serialized structs. "unresolved" lists selectors with the old name whose
+    receiver type could not be worked out - review them by hand.
+
+    Symbols of other languages get a plan from a whole-word text search
+    (kind "text_match"), with a "note" saying so.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "edits", limit, cursor, max_tokens, indexer.rename_preview, symbol, new_name,
+                            path, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error previewing rename", e)
+
+
+@_tool
+async def plan_package_move(root_path: Optional[str] = None, *, old_path: str, new_path: str, package_name: Optional[str] = None, ref: Optional[str] = None, include_generated: Optional[bool] = None, limit: int = DEFAULT_LIMIT, cursor: Optional[str] = None, max_tokens: Optional[int] = None, timeout_ms: Optional[int] = None, project: Optional[str] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    🚚 Plan moving a Go package to another directory before doing it.
+
+    USE THIS before `git mv internal/store pkg/storage`: the result is every
+    edit the move takes, like rename_preview's - nothing is changed. The
+    package moves with the packages below it in the same module.
+
+    INPUTS:
+    - root_path: The ABSOLUTE path to the project
+    - project: Name of a project added with add_project, instead of root_path (default: the only added project)
+    - timeout_ms: Give up after this many milliseconds (at most 1800000), discarding the partial work
+    - old_path: The package directory (relative to the root) or its import path
+    - new_path: The directory to move it to, or the import path it should have
+    - package_name: Optional new package name (default: the new directory's name when the old name
+                    was the old directory's, else unchanged)
+    - ref: Optional git tag, branch or SHA to analyze instead of the working tree
+    - include_generated: Also index files marked "// Code generated ... DO NOT EDIT." (default: .xray.yaml, else false);
+                         generated files are searched for imports either way
+    - limit: Edits per page (default 100)
+    - cursor: The next_cursor of the previous page, with otherwise identical arguments
+    - max_tokens: Optional size budget; snippet fields are trimmed first, then entries
+
+    EXAMPLE OUTPUT:
+    {
+        "from": {"path": "internal/store", "import_path": "example.com/app/internal/store", "package": "store",
+                 "module": "example.com/app"},
+        "to": {"path": "pkg/storage", "import_path": "example.com/app/pkg/storage", "package": "storage",
+               "module": "example.com/app"},
+        "crosses_modules": false,
+        "packages": [{"from": "internal/store", "to": "pkg/storage", "old_import_path": "example.com/app/internal/store",
+                      "new_import_path": "example.com/app/pkg/storage", "package": "store", "new_package": "storage"}],
+        "file_moves": [{"from": ".../internal/store/user.go", "to": ".../pkg/storage/user.go"}],
+        "edits": [
+            {"path": ".../api/api.go", "line": 4, "column": 2, "kind": "import_path",
+             "old_text": "\"example.com/app/internal/store\"", "new_text": "\"example.com/app/pkg/storage\""},
+            {"path": ".../api/api.go", "line": 9, "column": 9, "kind": "qualifier", "old_text": "store",
+             "new_text": "storage"},
+            {"path": ".../internal/store/user.go", "line": 1, "column": 9, "kind": "package_clause", ...}
+        ],
+        "review": [
+            {"kind": "comment", "context": "go_generate", "path": ".../api/gen.go", "line": 3, "column": 1,
+             "text": "//go:generate mockgen -destination mock.go example.com/app/internal/store Store"},
+            {"kind": "build_file", "context": "directory", "path": ".../Makefile", "line": 12, "column": 14,
+             "text": "go test ./internal/store/..."}
+        ],
+        "collisions": [],
+        "affected_files": [{"path": ".../api/api.go", "edits": 2, "kinds": ["import_path", "qualifier"]}, ...],
+        "counts": {"packages": 1, "file_moves": 1, "edits": 3, "files": 2, "importers": 1, "review": 2,
+                   "collisions": 0}
+    }
+
+    Edit kinds: import_path, package_clause (the `_test` package too),
+    package_doc, qualifier (`store.Open`, in files importing the package
+    without an alias), go_mod and go_work (each with its "directive"). A
+    move into another module adds require lines - and replace lines where
+    no go.work covers both modules - as edits with an empty "old_text" at
+    the end of the go.mod. Moving a module root moves its go.mod: the
+    module path changes only if it ended with the directory, and `use`
+    and local `replace` directives pointing at it are rewritten.
+
+    "review" is what no edit settles: string literals and comments
+    (go:generate) naming the old import path, build files (Makefiles,
+    scripts, Dockerfiles, CI and tool configs) naming it or the old
+    directory, and qualifiers a local variable of the same name may shadow.
+    "collisions": the new name already bound in an importing file (an
+    import, a declaration of its package, a local), or an internal package
+    an importer could no longer import.
+    """
+    try:
+        indexer = get_indexer(root_path, ref, include_generated, project)
+        return await _paged(indexer, "edits", limit, cursor, max_tokens, indexer.plan_package_move, old_path,
+                            new_path, package_name, ctx=ctx, timeout_ms=timeout_ms)
+    except Exception as e:
+        return _error("Error planning package move", e)
+
+
+# Tools a batch cannot run: they change server state or write files, and
+# their order against the other calls would be undefined
+_UNBATCHED = {"batch", "add_project", "remove_project", "reindex", "clear_cache", "export_tags", "export_scip",
+               "snapshot_index", "delete_snapshot"}
+MAX_BATCH_CALLS = 50
+
+# Calls of one batch in flight at once (--batch-parallelism / XRAY_BATCH_PARALLELISM)
+_batch_parallelism = 8
+
+
+def _tool_function(name: str) -> Optional[Callable[..., Any]]:
+    """
+    The function of the tool registered as name, or None: the one registry
+    batch and the command line (see xray.core.cli) both call tools through.
+    """
+    if not isinstance(name, str) or name.startswith("_"):
+        return None
+    tool = globals().get(name)
+    func = getattr(tool, "fn", tool)
+    # Tools are this module's public coroutines; imported ones (serve) are not. Python 3.12.1
+    # calls modules coroutine functions too, hence the isfunction check first.
+    if not inspect.isfunction(func) or not asyncio.iscoroutinefunction(func) or func.__module__ != __name__:
+        return None
+    return func
+
+
+def _tool_names() -> List[str]:
+    return sorted(name for name in list(globals()) if _tool_function(name) is not None)
+
+
+async def _batch_call(call: Any, share: Optional[int]) -> Dict[str, Any]:
+    """One call of a batch, as {"tool", "result"} or {"tool", "error"}."""
+    name = call.get("tool") if isinstance(call, dict) else None
+    try:
+        if not isinstance(name, str) or not isinstance(call.get("arguments", {}), dict):
+            raise XRayError('Each call is {"tool": name, "arguments": {...}}')
+        func = _tool_function(name)
+        if name in _UNBATCHED or func is None:
+            raise XRayError(f"'{name}' is no tool a batch can run")
+        arguments = dict(call.get("arguments", {}))
+        arguments.pop("ctx", None)
+        if share is not None and "max_tokens" in inspect.signature(func).parameters:
+            arguments.setdefault("max_tokens", share)
+        with _metrics.measure(name, arguments):
+            result = await func(**arguments)
+    except TypeError as e:
+        return {"tool": name, "error": describe_error(XRayError(str(e)), "Error in batch call")}
+    except Exception as e:
+        return {"tool": name, "error": describe_error(e, "Error in batch call")}
+    if _failed(result):
+        return {"tool": name, "error": result["error"]}
+    return {"tool": name, "result": result}
+
+
+@_tool
+async def batch(calls: List[Dict[str, Any]], parallelism: Optional[int] = None, max_tokens: Optional[int] = None, ctx: Optional[Context] = None) -> Dict[str, Any]:
+    """
+    📚 Run several tool calls in one request - results come back in order, one failure doesn't fail the rest.
+
+    USE THIS instead of many round trips, e.g. find_symbol for 20
+    identifiers of one file, or get_symbol_source for every hit of a
+    search. Each call is {"tool": name, "arguments": {...}} with the
+    arguments the tool takes on its own, paging included: a call with a
+    cursor returns the next page of its own earlier result.
+
+    Calls run concurrently, at most `parallelism` at a time. Calls on the
+    same project share its index and take turns on it (the first one
+    brings the index up to date); calls on different projects, and cursor
+    pages served from memory, overlap. add_project, remove_project,
+    reindex, clear_cache, export_tags, export_scip, snapshot_index and
+    delete_snapshot cannot be batched.
+
+    INPUTS:
+    - calls: Up to 50 tool calls, e.g. [{"tool": "find_symbol", "arguments": {"query": "GetUser"}}]
+    - parallelism: Calls in flight at once (default and most: the server's --batch-parallelism, 8)
+    - max_tokens: Optional size budget for the whole response: listing calls without a
+      max_tokens of their own get an equal share of it, and a result that no longer
+      fits is replaced by a BUDGET_EXCEEDED error - run that call on its own
+
+    EXAMPLE OUTPUT:
+    {
+        "results": [
+            {"tool": "find_symbol", "result": {"symbols": [...], "total_count": 1}},
+            {"tool": "get_symbol_source", "error": {"code": "SYMBOL_NOT_FOUND", "message": "..."}}
+        ],
+        "total_count": 2,
+        "error_count": 1
+    }
+    """
+    try:
+        if not isinstance(calls, list) or not calls:
+            raise XRayError("calls must be a non-empty list")
+        if len(calls) > MAX_BATCH_CALLS:
+            raise XRayError(f"A batch takes at most {MAX_BATCH_CALLS} calls, got {len(calls)}")
+        if parallelism is not None and parallelism < 1:
+            raise XRayError("parallelism must be at least 1")
+        if max_tokens is not None and max_tokens < 1:
+            raise XRayError("max_tokens must be at least 1")
+        _remember_session(getattr(ctx, "session", None))
+        gate = asyncio.Semaphore(min(parallelism or _batch_parallelism, _batch_parallelism))
+        share = max(1, max_tokens // len(calls)) if max_tokens is not None else None
+
+        async def limited(call: Any) -> Dict[str, Any]:
+            async with gate:
+                return await _batch_call(call, share)
+
+        results = await asyncio.gather(*(limited(call) for call in calls))
+        if max_tokens is not None:
+            budget = max_tokens
+            for k, item in enumerate(results):
+                size = estimate_tokens(item)
+                if size > budget:
+                    results[k] = item = {"tool": item["tool"], "error": {
+                        "code": BUDGET_EXCEEDED,
+                        "message": f"The result (about {size} tokens) does not fit the {budget} tokens left of the batch's "
+                                   f"max_tokens; run the call on its own, or with a smaller limit",
+                    }}
+                    size = estimate_tokens(item)
+                budget -= size
+        return _versioned({"results": results, "total_count": len(results),
+                           "error_count": sum(1 for item in results if "error" in item)})
+    except Exception as e:
+        return _error("Error running batch", e)
+
+
+# Resources: xray://<project>/<path> for every indexed file and Go package
+# of the projects the tools have seen. Handled directly on the MCP server
+# so resources/list can page through thousands of files and clients can
+# subscribe to single files or packages.
+
+RESOURCE_PAGE_SIZE = 200
+# Seconds between checks of subscribed resources for changes
+WATCH_INTERVAL = 2.0
+
+# uri -> {"sessions": subscribed sessions, "stamp": last seen fingerprint}
+_subscriptions: Dict[str, Dict[str, Any]] = {}
+_poller: Optional[asyncio.Task] = None
+
+
+def _resource_indexer(project: str) -> XRayIndexer:
+    root = _projects.root_for(project)
+    if root is None:
+        raise ProjectNotIndexed(f"Unknown project '{project}' - run a tool on it first")
+    return get_indexer(root)
+
+
+def _resource_stamp(uri: str) -> Optional[tuple]:
+    project, relpath = parse_uri(uri)
+    root = _projects.root_for(project)
+    return resource_stamp(root, relpath) if root else None
+
+
+async def _list_resources(req: types.ListResourcesRequest) -> types.ServerResult:
+    _remember_session(mcp._mcp_server.request_context.session)
+    resources = []
+    for project, root in _projects.projects():
+        if not _allowlist.allows(root):
+            continue
+        indexer = get_indexer(root)
+        for entry in await _run(indexer, indexer.resource_entries):
+            if entry["kind"] == "package":
+                label = f"{project}/{entry['path']}".rstrip("/")
+                resources.append(types.Resource(
+                    uri=resource_uri(project, entry["path"]),
+                    name=f"{label} (package {entry['package']})",
+                    description=f"Symbol outline of package {entry['package']}, {entry['files']} file(s)",
+                    mimeType="application/json",
+                ))
+            else:
+                resources.append(types.Resource(
+                    uri=resource_uri(project, entry["path"]),
+                    name=f"{project}/{entry['path']}",
+                    description=f"{entry['language']} source",
+                    mimeType="text/plain",
+                ))
+    cursor = req.params.cursor if req.params else None
+    try:
+        offset = int(cursor) if cursor else 0
+    except ValueError:
+        raise ValueError(f"Invalid cursor '{cursor}'")
+    page = resources[offset:offset + RESOURCE_PAGE_SIZE]
+    end = offset + len(page)
+    return types.ServerResult(types.ListResourcesResult(
+        resources=page, nextCursor=str(end) if end < len(resources) else None))
+
+
+async def _list_resource_templates(req: types.ListResourceTemplatesRequest) -> types.ServerResult:
+    return types.ServerResult(types.ListResourceTemplatesResult(resourceTemplates=[
+        types.ResourceTemplate(
+            uriTemplate="xray://{project}/{path}{?kinds,exported_only,top_level_only}",
+            name="Indexed file or Go package",
+            description="A source file's content, or the symbol outline of the Go package in a directory, "
+                        "filtered as list_symbols filters it (?kinds=func,method&exported_only=true&top_level_only=true)",
+        ),
+    ]))
+
+
+async def _read_resource(req: types.ReadResourceRequest) -> types.ServerResult:
+    uri = str(req.params.uri)
+    project, relpath = parse_uri(uri)
+    indexer = _resource_indexer(project)
+    mime, text = await _run(indexer, indexer.read_resource, relpath, **outline_filters(uri))
+    return types.ServerResult(types.ReadResourceResult(
+        contents=[types.TextResourceContents(uri=uri, mimeType=mime, text=text)]))
+
+
+async def _check_subscriptions():
+    """Tell subscribers of every subscribed resource whose fingerprint moved."""
+    for uri, sub in list(_subscriptions.items()):
+        stamp = _resource_stamp(uri)
+        if stamp == sub["stamp"]:
+            continue
+        sub["stamp"] = stamp
+        for session in list(sub["sessions"]):
+            try:
+                await session.send_resource_updated(uri)
+            except Exception:
+                # Session is gone
+                sub["sessions"].discard(session)
+        if not sub["sessions"]:
+            _subscriptions.pop(uri, None)
+
+
+async def _poll_subscriptions():
+    """Check subscribed resources for changes while any are subscribed."""
+    global _poller
+    try:
+        while _subscriptions:
+            await asyncio.sleep(WATCH_INTERVAL)
+            await _check_subscriptions()
+    finally:
+        _poller = None
+
+
+async def _announce(changes: Dict[str, List[str]]):
+    """After a watcher re-index: list changes to every client, updates to subscribers."""
+    if changes["added"] or changes["removed"]:
+        for session in list(_sessions):
+            try:
+                await session.send_resource_list_changed()
+            except Exception:
+                _sessions.discard(session)
+    await _check_subscriptions()
+
+
+def _start_watcher(indexer: XRayIndexer):
+    """Watch a project's working tree, re-indexing and notifying clients on changes."""
+    root = str(indexer.root_path)
+    if root in _watchers:
+        return
+    try:
+        loop = asyncio.get_running_loop()
+    except RuntimeError:
+        return
+
+    def on_batch(paths, head_moved):
+        with _index_lock(indexer), indexer.tracking():
+            changes = indexer.refresh(head_moved)
+        asyncio.run_coroutine_threadsafe(_announce(changes), loop)
+
+    def stamps():
+        # The walk resets the indexer's submodule and symlink lists: not while a call reads them
+        with _index_lock(indexer):
+            return indexer.source_stamps()
+
+    watcher = ProjectWatcher(root, on_batch, indexer.watch_relevant, stamps)
+    watcher.start()
+    _watchers[root] = watcher
+
+
+def _remember_session(session):
+    if session is not None:
+        _sessions.add(session)
+
+
+async def _subscribe(req: types.SubscribeRequest) -> types.ServerResult:
+    global _poller
+    uri = str(req.params.uri)
+    project, _ = parse_uri(uri)
+    root = _projects.root_for(project)
+    if root is None:
+        raise ProjectNotIndexed(f"Unknown project '{project}' - run a tool on it first")
+    _allowlist.check(root)
+    session = mcp._mcp_server.request_context.session
+    _remember_session(session)
+    sub = _subscriptions.setdefault(uri, {"sessions": set(), "stamp": _resource_stamp(uri)})
+    sub["sessions"].add(session)
+    if _poller is None and not _watch_enabled:
+        # Watch mode checks subscriptions after every re-index instead
+        _poller = asyncio.get_running_loop().create_task(_poll_subscriptions())
+    return types.ServerResult(types.EmptyResult())
+
+
+async def _unsubscribe(req: types.UnsubscribeRequest) -> types.ServerResult:
+    uri = str(req.params.uri)
+    sub = _subscriptions.get(uri)
+    if sub is not None:
+        sub["sessions"].discard(mcp._mcp_server.request_context.session)
+        if not sub["sessions"]:
+            del _subscriptions[uri]
+    return types.ServerResult(types.EmptyResult())
+
+
+def _register_resource_handlers(server):
+    """Install the resource handlers on the low-level MCP server."""
+    server.request_handlers[types.ListResourcesRequest] = _list_resources
+    server.request_handlers[types.ListResourceTemplatesRequest] = _list_resource_templates
+    server.request_handlers[types.ReadResourceRequest] = _read_resource
+    server.request_handlers[types.SubscribeRequest] = _subscribe
+    server.request_handlers[types.UnsubscribeRequest] = _unsubscribe
+
+    # The SDK advertises resources without subscribe support; we have it,
+    # and list change notifications in watch mode
+    get_capabilities = server.get_capabilities
+
+    def capabilities(*args, **kwargs):
+        result = get_capabilities(*args, **kwargs)
+        if result.resources is not None:
+            result.resources.subscribe = True
+            result.resources.listChanged = _watch_enabled
+        return result
+
+    server.get_capabilities = capabilities
+
+
+_register_resource_handlers(mcp._mcp_server)
+
+
+def _register_call_metrics(server):
+    """Time every tool call the low-level MCP server handles (see xray.core.metrics)."""
+    call_tool = server.request_handlers.get(types.CallToolRequest)
+    if call_tool is None:
+        return
+
+    async def handler(req: types.CallToolRequest) -> types.ServerResult:
+        name = req.params.name
+        # Unknown names are the SDK's to reject; counting them would let clients grow the metrics
+        if _tool_function(name) is None:
+            return await call_tool(req)
+        with _metrics.measure(name, req.params.arguments):
+            return await call_tool(req)
+
+    server.request_handlers[types.CallToolRequest] = handler
+
+
+_register_call_metrics(mcp._mcp_server)
+
+
+def _flush_indexes():
+    """Stop the watchers and write every changed index to disk, once the calls in flight are done."""
+    _deep_indexing_stop.set()
+    for watcher in list(_watchers.values()):
+        watcher.stop()
+    _watchers.clear()
+    for indexer in list(_indexer_cache.values()):
+        with _index_lock(indexer):
+            indexer.flush()
+
+
+async def _shutdown():
+    await asyncio.to_thread(_flush_indexes)
+
+
+def _index_options() -> argparse.ArgumentParser:
+    """The options of how projects are indexed, shared by the server and the command line."""
+    parser = argparse.ArgumentParser(add_help=False)
+    parser.add_argument(
+        "--allow-dir", metavar="DIR", action="append",
+        default=[d for d in os.environ.get("XRAY_ALLOW_DIRS", "").split(os.pathsep) if d],
+        help=f"only touch paths inside DIR, after resolving symlinks; repeat for several, added to "
+             f"XRAY_ALLOW_DIRS ({os.pathsep}-separated) (default: anywhere)",
+    )
+    parser.add_argument(
+        "--submodules", action=argparse.BooleanOptionalAction,
+        default=os.environ.get("XRAY_SUBMODULES", "").lower() not in ("0", "false", "no", "off"),
+        help="index the work trees of git submodules, with their own history for blame and hotspots "
+             "(default: XRAY_SUBMODULES, else on)",
+    )
+    parser.add_argument(
+        "--fetch-missing", action=argparse.BooleanOptionalAction,
+        default=os.environ.get("XRAY_FETCH_MISSING", "").lower() in ("1", "true", "yes", "on"),
+        help="in a partial clone, let git fetch the blobs history tools need from the remote instead of "
+             "skipping them with a warning (default: XRAY_FETCH_MISSING, else off)",
+    )
+    parser.add_argument(
+        "--history-cache-entries", metavar="N", type=int,
+        default=int(os.environ.get("XRAY_HISTORY_CACHE_ENTRIES") or HISTORY_CACHE_ENTRIES),
+        help=f"keep at most N parsed old file versions for history tools, 0 for none "
+             f"(default: XRAY_HISTORY_CACHE_ENTRIES, else {HISTORY_CACHE_ENTRIES})",
+    )
+    parser.add_argument(
+        "--history-cache-mb", metavar="MB", type=int,
+        default=int(os.environ.get("XRAY_HISTORY_CACHE_MB") or HISTORY_CACHE_MB),
+        help=f"keep at most about MB megabytes of parsed old file versions "
+             f"(default: XRAY_HISTORY_CACHE_MB, else {HISTORY_CACHE_MB})",
+    )
+    parser.add_argument(
+        "--language-map", metavar="EXT=LANG", action="append",
+        default=[os.environ["XRAY_LANGUAGE_MAP"]] if os.environ.get("XRAY_LANGUAGE_MAP") else [],
+        help="read files with extension EXT (.gotmpl) or named EXT (Tiltfile) as language LANG (go, starlark); "
+             "repeat or comma-separate for several, added to XRAY_LANGUAGE_MAP; a project's \"extensions\" "
+             "in .xray.yaml win (default: the built-in extensions)",
+    )
+    return parser
+
+
+def _apply_index_options(parser: argparse.ArgumentParser, args: argparse.Namespace):
+    global _allowlist, _include_submodules, _fetch_missing, _blob_cache, _language_map
+    _include_submodules = args.submodules
+    _fetch_missing = args.fetch_missing
+    if args.history_cache_entries < 0 or args.history_cache_mb < 0:
+        parser.error("--history-cache-entries and --history-cache-mb must be 0 or more")
+    _blob_cache = BlobCache(args.history_cache_entries, args.history_cache_mb * MEGABYTE, cache_file())
+    for directory in args.allow_dir:
+        if not os.path.isdir(os.path.expanduser(directory)):
+            parser.error(f"--allow-dir {directory}: not a directory")
+    _allowlist = AllowList(args.allow_dir)
+    _language_map = {}
+    for value in args.language_map:
+        mapping, problem = parse_mapping(value)
+        if problem:
+            parser.error(f"--language-map {problem}")
+        _language_map.update(mapping)
+
+
+def _schema_command(argv: List[str]) -> int:
+    """`schema`: print the tools' JSON Schemas, or check or write the golden file (see xray.core.schema)."""
+    command = parse_schema_command(argv)
+    unknown = [name for name in command.tools if _tool_function(name) is None]
+    if unknown:
+        print(f"xray: no tool '{unknown[0]}' - 'tools' lists them", file=sys.stderr)
+        return EXIT_ERROR
+    schemas = [tool_schema(name, _tool_function(name)) for name in command.tools or _tool_names()]
+    path = golden_path()
+    if command.write:
+        path.parent.mkdir(parents=True, exist_ok=True)
+        path.write_text(json.dumps(golden(schemas), indent=1, sort_keys=True, ensure_ascii=False) + "\n",
+                        encoding="utf-8")
+        print(f"xray: wrote {path}", file=sys.stderr)
+        return EXIT_OK
+    if command.check:
+        try:
+            frozen = json.loads(path.read_text(encoding="utf-8"))
+        except (OSError, ValueError) as e:
+            print(f"xray: cannot read the golden file {path}: {e} - 'schema --write' creates it", file=sys.stderr)
+            return EXIT_ERROR
+        result = compare(frozen, golden(schemas))
+        sys.stdout.write(render("schema", result, "json"))
+        for problem in result["problems"]:
+            print(f"xray: {problem}", file=sys.stderr)
+        return EXIT_OK if result["ok"] else EXIT_FINDINGS
+    result = with_deprecated("get_schema", _versioned({"tools": schemas, "error_schema": ERROR_SCHEMA}))
+    sys.stdout.write(render("schema", result, "json"))
+    return EXIT_OK
+
+
+def _command(argv: List[str]) -> int:
+    """Run one tool from the command line (see xray.core.cli) and return the exit status."""
+    if argv[0] == "schema":
+        return _schema_command(argv[1:])
+    options = _index_options()
+    command = parse_command(argv, [options])
+    _apply_index_options(options, command)
+    if command.tool == "tools":
+        for name in _tool_names():
+            doc = inspect.cleandoc(_tool_function(name).__doc__ or "")
+            print(f"{name}\t{doc.splitlines()[0] if doc else ''}")
+        return EXIT_OK
+    func = _tool_function(command.tool)
+    if func is None:
+        print(f"xray: no tool '{command.tool}' - 'tools' lists them", file=sys.stderr)
+        return EXIT_ERROR
+    if command.describe:
+        print(inspect.cleandoc(func.__doc__ or ""))
+        return EXIT_OK
+    parameters = list(inspect.signature(func).parameters)
+    try:
+        arguments = tool_arguments(command.tool, parameters, command, command.project, typing.get_type_hints(func))
+    except ValueError as e:
+        print(f"xray: {e}", file=sys.stderr)
+        return EXIT_ERROR
+    if "ctx" in parameters and not command.quiet:
+        arguments["ctx"] = ProgressPrinter()
+    try:
+        result = asyncio.run(all_pages(func, arguments))
+    except TypeError as e:
+        # A required argument left out
+        print(f"xray: {command.tool}: {e}", file=sys.stderr)
+        return EXIT_ERROR
+    finally:
+        _flush_indexes()
+    if _failed(result):
+        error = result["error"]
+        print(f"xray: {error.get('message')} ({error.get('code')})", file=sys.stderr)
+        return EXIT_ERROR
+    sys.stdout.write(render(command.tool, result, command.format))
+    return EXIT_FINDINGS if findings(command.tool, result) else EXIT_OK
+
+
+def main():
+    """Main entry point for the XRAY MCP server, or with a tool name first, for one tool call."""
+    global _watch_enabled, _batch_parallelism, _quick_index
+    if len(sys.argv) > 1 and not sys.argv[1].startswith("-"):
+        sys.exit(_command(sys.argv[1:]))
+    options = _index_options()
+    parser = argparse.ArgumentParser(
+        description="XRAY MCP server - or, with a tool name first, one tool call ('git-project-xray-mcp tools' lists them)",
+        parents=[options])
+    parser.add_argument(
+        "--watch", action=argparse.BooleanOptionalAction,
+        default=os.environ.get("XRAY_WATCH", "").lower() in ("1", "true", "yes", "on"),
+        help="re-index projects as files change on disk and notify clients (default: XRAY_WATCH, else off)",
+    )
+    parser.add_argument(
+        "--quick-index", action=argparse.BooleanOptionalAction,
+        default=os.environ.get("XRAY_QUICK_INDEX", "").lower() in ("1", "true", "yes", "on"),
+        help="answer from a shallow index of declarations at first, parsing packages in full in the background "
+             "(default: XRAY_QUICK_INDEX, else off)",
+    )
+    parser.add_argument(
+        "--listen", metavar="[HOST:]PORT", default=os.environ.get("XRAY_LISTEN") or None,
+        help="serve MCP over streamable HTTP at http://HOST:PORT/mcp instead of stdio "
+             "(default: XRAY_LISTEN, else stdio; HOST defaults to 127.0.0.1)",
+    )
+    parser.add_argument(
+        "--auth-token", metavar="TOKEN", default=os.environ.get("XRAY_AUTH_TOKEN") or None,
+        help="with --listen, require 'Authorization: Bearer TOKEN' on every request (default: XRAY_AUTH_TOKEN)",
+    )
+    parser.add_argument(
+        "--allow-origin", metavar="ORIGIN", action="append",
+        default=[o for o in os.environ.get("XRAY_ALLOW_ORIGINS", "").split(",") if o.strip()],
+        help="with --listen, answer browser requests from this Origin (scheme://host[:port], or * for any); "
+             "repeat for several, added to XRAY_ALLOW_ORIGINS (comma-separated) (default: loopback origins only)",
+    )
+    parser.add_argument(
+        "--metrics", action=argparse.BooleanOptionalAction,
+        default=os.environ.get("XRAY_METRICS", "").lower() in ("1", "true", "yes", "on"),
+        help="with --listen, serve Prometheus metrics at http://HOST:PORT/metrics (default: XRAY_METRICS, else off)",
+    )
+    parser.add_argument(
+        "--slow-call-ms", metavar="MS", type=int,
+        default=int(os.environ.get("XRAY_SLOW_CALL_MS") or DEFAULT_SLOW_CALL_MS),
+        help=f"log tool calls slower than MS milliseconds to stderr, 0 for none "
+             f"(default: XRAY_SLOW_CALL_MS, else {DEFAULT_SLOW_CALL_MS})",
+    )
+    parser.add_argument(
+        "--batch-parallelism", metavar="N", type=int,
+        default=int(os.environ.get("XRAY_BATCH_PARALLELISM") or _batch_parallelism),
+        help=f"run at most N calls of a batch at once (default: XRAY_BATCH_PARALLELISM, else {_batch_parallelism})",
+    )
+    args = parser.parse_args()
+    if args.batch_parallelism < 1:
+        parser.error("--batch-parallelism must be at least 1")
+    _batch_parallelism = args.batch_parallelism
+    if args.slow_call_ms < 0:
+        parser.error("--slow-call-ms must be 0 or more")
+    _metrics.slow_call_ms = args.slow_call_ms
+    _watch_enabled = args.watch
+    _quick_index = args.quick_index
+    _apply_index_options(parser, args)
+    if args.auth_token and not args.listen:
+        parser.error("--auth-token only applies with --listen")
+    if args.metrics and not args.listen:
+        parser.error("--metrics only applies with --listen; use the server_stats tool over stdio")
+    if args.allow_origin and not args.listen:
+        parser.error("--allow-origin only applies with --listen")
+    for origin in args.allow_origin:
+        try:
+            parse_origin(origin)
+        except ValueError as e:
+            parser.error(str(e))
+    if args.listen:
+        try:
+            host, port = parse_listen(args.listen)
+        except ValueError as e:
+            parser.error(str(e))
+        metrics = (lambda: prometheus_text(_server_stats())) if args.metrics else None
+        asyncio.run(serve(http_app(mcp, args.auth_token, metrics, args.allow_origin), host, port, _shutdown))
+        return
+    # SIGTERM ends a stdio session like Ctrl-C: calls in flight finish, then the indexes are flushed
+    signal.signal(signal.SIGTERM, lambda signum, frame: sys.exit(0))
+    try:
+        mcp.run()
+    finally:
+        _flush_indexes()
 
 
 if __name__ == "__main__":
@@ -0,0 +1,12 @@
+// Go CRLF test file: every line ends in \r\n, as checked out on Windows
+package main
+
+// Greeting ends at column 2 of line 7, as it would with \n endings
+func Greeting(name string) string {
+    return "hello, " + name
+}
+
+/* Farewell has a block doc comment
+   spanning CRLF lines */
+var Farewell = `bye
+now`
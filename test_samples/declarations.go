@@ -0,0 +1,37 @@
+// Go grouped and multi-name declaration test file: 18 declared names, 20 symbols with Point's fields
+package main
+
+import "os"
+
+// Grouped variables, each with its own type
+var (
+    sessionCount int
+    sessionOwner *User
+)
+
+// One type shared by three names
+var width, height, depth int
+
+// One value each
+var label, ratio = "box", 0.5
+
+// Names taking the results of one call
+var workDir, workDirErr = os.Getwd()
+
+// Multi-name constant
+const Low, High = 1, 10
+
+// Enum-style block: the second spec repeats the first with the next iota
+const (
+    KB, KiB = 1 << (10 * (iota + 1)), 1000 * (iota + 1)
+    MB, MiB
+)
+
+// Type group
+type (
+    Point struct {
+        X, Y int
+    }
+    Names []string
+    Count = int
+)
@@ -0,0 +1,47 @@
+// Go generics test file
+package main
+
+// Map applies f to every element of in
+func Map[T any, U any](in []T, f func(T) U) []U {
+    out := make([]U, 0, len(in))
+    for _, v := range in {
+        out = append(out, f(v))
+    }
+    return out
+}
+
+// Number is a constraint with a type union
+type Number interface {
+    ~int | ~int64 | ~float64
+}
+
+// Sum adds up a slice of numbers
+func Sum[S ~[]E, E Number](s S) E {
+    var total E
+    for _, v := range s {
+        total += v
+    }
+    return total
+}
+
+// Cache is a generic key/value store
+type Cache[K comparable, V any] struct {
+    items map[K]V
+}
+
+// Get looks up a key
+func (c *Cache[K, V]) Get(k K) (V, bool) {
+    v, ok := c.items[k]
+    return v, ok
+}
+
+// Set stores a value
+func (c *Cache[K, V]) Set(k K, v V) {
+    c.items[k] = v
+}
+
+// Pair is a generic defined type
+type Pair[A, B any] struct {
+    First  A
+    Second B
+}
@@ -0,0 +1,33 @@
+// Go import forms test file
+package main
+
+import (
+    "fmt"
+    f "fmt"
+    db "database/sql"
+    _ "github.com/lib/pq"
+    . "strings"
+)
+
+// Store wraps a database handle
+type Store struct {
+    db *db.DB
+}
+
+// Describe uses the aliased, duplicate, and dot imports
+func (s *Store) Describe(name string) string {
+    row := s.db.QueryRow("SELECT 1")
+    _ = row
+    f.Println(ToUpper(name))
+    return fmt.Sprintf("store for %s", TrimSpace(name))
+}
+
+// shadowed takes a parameter named like an import
+func shadowed(fmt Formatter) {
+    fmt.Format()
+}
+
+// Formatter is declared locally so it is not a dot-import candidate
+type Formatter interface {
+    Format()
+}
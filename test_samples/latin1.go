@@ -0,0 +1,12 @@
+// Go Latin-1 (ISO-8859-1) encoded test file: indexed as "transcoded_from": "latin-1"
+package main
+
+// Caf returns a French word
+func Caf() string {
+    return "crme brle"
+}
+
+// Men is a type with a Latin-1 name
+type Men struct {
+    Preis int
+}
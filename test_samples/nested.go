@@ -0,0 +1,36 @@
+// Go nested declaration test file: function literals, local types and constants, anonymous structs
+package main
+
+import "fmt"
+
+// serverConfig is a package-level anonymous struct: struct@7:20, parent serverConfig
+var serverConfig = struct {
+    Host string
+    Port int
+}{Host: "localhost", Port: 8080}
+
+// onShutdown is a variable marked "function_literal": true
+var onShutdown = func() {
+    fmt.Println("shutting down")
+}
+
+// RunJobs declares everything below it as nested symbols with parent RunJobs
+func RunJobs(names []string) int {
+    const maxAttempts = 3
+    type job struct {
+        name     string
+        attempts int
+    }
+    run := func(j *job) bool {
+        const backoff = maxAttempts * 100
+        j.attempts++
+        return j.attempts < backoff
+    }
+    done := 0
+    for _, name := range names {
+        if run(&job{name: name}) {
+            done++
+        }
+    }
+    return done
+}
@@ -0,0 +1,30 @@
+// Go Unicode identifier test file
+package main
+
+import "fmt"
+
+// Größe is a size with Unicode field names
+type Größe struct {
+    Länge  int
+    Breite int
+}
+
+// Fläche multiplies both sides; its name is 7 characters but 8 UTF-8 bytes
+func (g Größe) Fläche() int {
+    return g.Länge * g.Breite
+}
+
+// BerechneGröße calls a method whose name is not ASCII
+func BerechneGröße(g Größe) int {
+    return g.Fläche() * 2
+}
+
+// straße is found by searching for "STRASSE" (case folding turns ß into ss)
+func straße() string {
+    return "straße"
+}
+
+// Ω and 数量 are identifiers too: any Unicode letter may start one
+func Ω(数量 int) {
+    fmt.Println(BerechneGröße(Größe{Länge: 数量, Breite: 2}), straße())
+}
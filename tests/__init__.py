@@ -1 +1,9 @@
-"""Tests for XRAY."""
\ No newline at end of file
+"""Tests for XRAY."""
+
+import sys
+from pathlib import Path
+
+# Run from a checkout without installing the package
+SRC = Path(__file__).resolve().parent.parent / "src"
+if str(SRC) not in sys.path:
+    sys.path.insert(0, str(SRC))
@@ -0,0 +1,27 @@
+// Package a holds one of each finding the SARIF export reports.
+package a
+
+import (
+	"fmt"
+
+	"example.com/sarif/b"
+)
+
+// hits is written from two goroutines without a lock.
+var hits int
+
+// Run starts the workers.
+func Run() {
+	go func() { hits++ }()
+	go func() { hits = 0 }()
+	fmt.Printf("%d of %d\n", hits)
+	b.Count()
+}
+
+// helper is never called.
+func helper() int {
+	return 1
+}
+
+// dbPassword is a hardcoded credential.
+const dbPassword = "Zq8v-Lm3r-Tx91-Kp4w"
@@ -0,0 +1,9 @@
+// Package b imports a back.
+package b
+
+import "example.com/sarif/a"
+
+// Count runs a.
+func Count() {
+	a.Run()
+}
@@ -0,0 +1,141 @@
+"""Name-based lookups: same-name declarations in several packages, and same-name methods on several receivers."""
+
+import shutil
+import tempfile
+import unittest
+from pathlib import Path
+
+from xray.core.errors import AmbiguousSymbol, SymbolNotFound
+from xray.core.indexer import XRayIndexer
+
+FILES = {
+    "go.mod": "module example.com/app\n\ngo 1.21\n",
+    "api/config.go": (
+        "package api\n\n"
+        "// Config holds the API settings.\n"
+        "type Config struct {\n\tAddr string\n}\n\n"
+        "// New returns the default API settings.\n"
+        "func New() *Config {\n\treturn &Config{Addr: \":8080\"}\n}\n"
+    ),
+    "internal/db/config.go": (
+        "package db\n\n"
+        "// Config holds the database settings.\n"
+        "type Config struct {\n\tDSN string\n}\n\n"
+        "// New returns the database settings for a DSN.\n"
+        "func New(dsn string) *Config {\n\treturn &Config{DSN: dsn}\n}\n"
+    ),
+    "svc/svc.go": (
+        "package svc\n\n"
+        "type User struct{ ID int }\n\n"
+        "type UserService struct{}\n\n"
+        "func (s *UserService) GetUser(id int) (*User, error) {\n\treturn &User{ID: id}, nil\n}\n\n"
+        "type AdminService struct{}\n\n"
+        "func (a AdminService) GetUser(id int) *User {\n\treturn &User{ID: -id}\n}\n"
+    ),
+    "cmd/app/main.go": (
+        "package main\n\n"
+        "import (\n\t\"example.com/app/api\"\n\t\"example.com/app/internal/db\"\n\t\"example.com/app/svc\"\n)\n\n"
+        "func serve(c *api.Config) {}\n\n"
+        "func open(c *db.Config) {}\n\n"
+        "func main() {\n"
+        "\tserve(api.New())\n"
+        "\topen(db.New(\"postgres://\"))\n"
+        "\tusers := &svc.UserService{}\n"
+        "\tusers.GetUser(1)\n"
+        "\tadmins := svc.AdminService{}\n"
+        "\tadmins.GetUser(2)\n"
+        "\tadmins.GetUser(3)\n"
+        "}\n"
+    ),
+}
+
+
+class AmbiguityTest(unittest.TestCase):
+
+    @classmethod
+    def setUpClass(cls):
+        cls.root = Path(tempfile.mkdtemp())
+        for path, content in FILES.items():
+            (cls.root / path).parent.mkdir(parents=True, exist_ok=True)
+            (cls.root / path).write_text(content, encoding="utf-8")
+        cls.indexer = XRayIndexer(str(cls.root))
+        cls.indexer.reindex(force=True)
+
+    @classmethod
+    def tearDownClass(cls):
+        shutil.rmtree(cls.root, ignore_errors=True)
+
+    def candidates(self, call, *args):
+        with self.assertRaises(AmbiguousSymbol) as raised:
+            call(*args)
+        self.assertIn("retry with one's symbol_id", str(raised.exception))
+        return raised.exception.candidates
+
+    def callers(self, symbol):
+        return [c["call_site"]["line"] for c in self.indexer.find_callers(symbol)["callers"]]
+
+    # Same name, different packages
+
+    def test_type_in_two_packages(self):
+        found = self.candidates(self.indexer.get_symbol_source, "Config")
+        self.assertEqual([(c["package"], c["kind"], c["signature"]) for c in found], [
+            ("example.com/app/api", "struct", "type Config struct"),
+            ("example.com/app/internal/db", "struct", "type Config struct"),
+        ])
+
+    def test_function_in_two_packages(self):
+        found = self.candidates(self.indexer.find_callers, "New")
+        self.assertEqual([(c["package"], c["signature"]) for c in found], [
+            ("example.com/app/api", "func New() *Config"),
+            ("example.com/app/internal/db", "func New(dsn string) *Config"),
+        ])
+
+    def test_package_qualifiers(self):
+        cases = [
+            ("package name", "api.Config", "api/config.go"),
+            ("directory", "internal/db.Config", "internal/db/config.go"),
+            ("import path", "example.com/app/internal/db.Config", "internal/db/config.go"),
+        ]
+        for label, symbol, path in cases:
+            with self.subTest(label):
+                self.assertEqual(self.indexer.get_symbol_source(symbol)["path"], str(self.root / path))
+
+    def test_qualified_callers_are_the_package_s_own(self):
+        self.assertEqual(self.callers("api.New"), [14])
+        self.assertEqual(self.callers("db.New"), [15])
+
+    def test_path_picks_one(self):
+        result = self.indexer.get_symbol_source("Config", "internal/db")
+        self.assertEqual(result["path"], str(self.root / "internal" / "db" / "config.go"))
+
+    def test_retry_with_a_candidate_s_symbol_id(self):
+        for candidate in self.candidates(self.indexer.get_symbol_source, "Config"):
+            with self.subTest(candidate["package"]):
+                self.assertEqual(self.indexer.get_symbol_source(candidate["symbol_id"])["path"], candidate["path"])
+
+    # Same method name, different receivers
+
+    def test_method_on_two_receivers(self):
+        found = self.candidates(self.indexer.find_callers, "GetUser")
+        self.assertEqual([(c["name"], c["kind"], c["signature"]) for c in found], [
+            ("UserService.GetUser", "method", "func (s *UserService) GetUser(id int) (*User, error)"),
+            ("AdminService.GetUser", "method", "func (a AdminService) GetUser(id int) *User"),
+        ])
+
+    def test_receiver_qualifiers(self):
+        cases = [
+            ("pointer method expression", "(*UserService).GetUser", [17]),
+            ("type and method", "UserService.GetUser", [17]),
+            ("value receiver", "AdminService.GetUser", [19, 20]),
+            ("package, type and method", "svc.AdminService.GetUser", [19, 20]),
+        ]
+        for label, symbol, lines in cases:
+            with self.subTest(label):
+                self.assertEqual(self.callers(symbol), lines)
+
+    def test_unknown_receiver(self):
+        self.assertRaises(SymbolNotFound, self.indexer.find_callers, "GuestService.GetUser")
+
+
+if __name__ == "__main__":
+    unittest.main()
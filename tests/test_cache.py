@@ -0,0 +1,79 @@
+"""The persisted index: other commits' indexes pruned to the most recent ones within the size cap."""
+
+import os
+import shutil
+import tempfile
+import time
+import unittest
+from pathlib import Path
+from unittest import mock
+
+from xray.core.cache import KEEP_INDEXES, IndexCache
+
+
+class PruneTest(unittest.TestCase):
+
+    def setUp(self):
+        self.home = Path(tempfile.mkdtemp())
+        patcher = mock.patch.dict(os.environ, {"XRAY_CACHE_DIR": str(self.home)})
+        patcher.start()
+        self.addCleanup(patcher.stop)
+        self.addCleanup(shutil.rmtree, self.home, ignore_errors=True)
+        self.project = self.home / "repo"
+
+    def save(self, key, data=None, age=0):
+        """An index saved for commit key, dated age seconds back."""
+        cache = IndexCache(self.project, key)
+        self.assertTrue(cache.save(data if data is not None else {"key": key}))
+        past = time.time() - age
+        os.utime(cache.path, (past, past))
+        return cache
+
+    def commits(self):
+        return sorted(d.name for d in IndexCache(self.project, "any").project_dir.iterdir() if d.is_dir())
+
+    def test_keeps_the_most_recent(self):
+        for i in range(KEEP_INDEXES + 3):
+            self.save(f"c{i}", age=1000 - i)
+        # The last saved is this commit's; the four before it are the most recent others
+        self.assertEqual(self.commits(), [f"c{i}" for i in range(3, KEEP_INDEXES + 3)])
+
+    def test_count_from_the_environment(self):
+        with mock.patch.dict(os.environ, {"XRAY_CACHE_KEEP": "2"}):
+            for i in range(4):
+                self.save(f"c{i}", age=1000 - i)
+        self.assertEqual(self.commits(), ["c2", "c3"])
+
+    def test_size_cap(self):
+        big = {"blob": os.urandom(400 * 1024)}
+        with mock.patch.dict(os.environ, {"XRAY_CACHE_MAX_MB": "1"}):
+            self.save("old", big, age=300)
+            self.save("newer", big, age=200)
+            current = self.save("current", big)
+            # The newest other fits beside this commit's, the oldest does not
+            self.assertEqual(self.commits(), ["current", "newer"])
+            # This commit's is kept even when it alone is over the cap
+            self.save("huge", {"blob": os.urandom(1200 * 1024)})
+        self.assertEqual(self.commits(), ["huge"])
+        self.assertFalse(current.path.exists())
+
+    def test_the_previous_commit_still_seeds_the_next(self):
+        with mock.patch.dict(os.environ, {"XRAY_CACHE_KEEP": "2"}):
+            self.save("c0", age=100)
+            self.save("c1", {"seed": True}, age=50)
+            cache = IndexCache(self.project, "c2")
+            self.assertEqual(cache.load(), {"seed": True})
+            cache.save({"seed": False})
+        self.assertEqual(self.commits(), ["c1", "c2"])
+
+    def test_prune_frees(self):
+        for i in range(3):
+            self.save(f"c{i}", age=100 - i)
+        with mock.patch.dict(os.environ, {"XRAY_CACHE_KEEP": "1"}):
+            freed = IndexCache(self.project, "c2").prune()
+        self.assertGreater(freed, 0)
+        self.assertEqual(self.commits(), ["c2"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,112 @@
+"""Cancellation: a call stopped mid-index keeps the index as it was, and the next call runs at once."""
+
+import asyncio
+import importlib.util
+import os
+import shutil
+import tempfile
+import threading
+import time
+import unittest
+from pathlib import Path
+from unittest import mock
+
+HAS_FASTMCP = importlib.util.find_spec("fastmcp") is not None
+
+FILE_COUNT = 200
+# Seconds the next call may take, waiting for the stopped one included
+PROMPTLY = 5.0
+
+
+def write_files(root, name):
+    for i in range(FILE_COUNT):
+        path = root / f"p{i:03d}" / "f.go"
+        path.parent.mkdir(parents=True, exist_ok=True)
+        path.write_text(f"package p{i:03d}\n\nfunc {name}{i}() int {{ return {i} }}\n", encoding="utf-8")
+        # Past the first version's mtime whatever the clock's resolution
+        os.utime(path, (time.time() + 10, time.time() + 10))
+
+
+@unittest.skipUnless(HAS_FASTMCP, "the MCP server needs fastmcp")
+class CancelMidIndexTest(unittest.TestCase):
+
+    def setUp(self):
+        from xray import mcp_server
+        from xray.core import parse_pool
+        self.server = mcp_server
+        self.root = Path(tempfile.mkdtemp()).resolve()
+        (self.root / "go.mod").write_text("module example.com/many\n", encoding="utf-8")
+        write_files(self.root, "Old")
+        self.indexer = mcp_server.get_indexer(str(self.root))
+        self.call("reindex", force=True)
+        self.before = self.hashes()
+        # Every file changes, so the reindex stopped below has all of them to parse
+        write_files(self.root, "New")
+
+        # Parsing slowed down, and a signal once it is well under way
+        self.started = threading.Event()
+        parse = parse_pool.parse_source
+        parsed = []
+
+        def slow(path, *args, **kwargs):
+            parsed.append(path)
+            if len(parsed) == 20:
+                self.started.set()
+            time.sleep(0.01)
+            return parse(path, *args, **kwargs)
+
+        patcher = mock.patch.object(parse_pool, "parse_source", slow)
+        patcher.start()
+        self.addCleanup(patcher.stop)
+        self.parsed = parsed
+
+    def tearDown(self):
+        self.server._indexer_cache.pop(str(self.root), None)
+        shutil.rmtree(self.root, ignore_errors=True)
+
+    def call(self, tool, **arguments):
+        return asyncio.run(self.server._tool_function(tool)(root_path=str(self.root), **arguments))
+
+    def hashes(self):
+        index = self.indexer._file_index(self.root / "p000" / "f.go")
+        return {path: entry["hash"] for path, entry in index.items()}
+
+    def assert_next_call_is_prompt_and_current(self):
+        start = time.monotonic()
+        found = self.call("find_symbol", query="New7")
+        self.assertLess(time.monotonic() - start, PROMPTLY)
+        self.assertNotIn("error", found)
+        self.assertEqual(found["symbols"][0]["name"], "New7")
+
+    def test_cancelled_by_the_client(self):
+        async def scenario():
+            task = asyncio.create_task(self.server._tool_function("reindex")(root_path=str(self.root), concurrency=1))
+            self.assertTrue(await asyncio.to_thread(self.started.wait, 30))
+            task.cancel()
+            with self.assertRaises(asyncio.CancelledError):
+                await task
+
+        asyncio.run(scenario())
+        # Stopped at a checkpoint soon after, and nothing it parsed was merged
+        self.assertLess(len(self.parsed), FILE_COUNT)
+        self.assertEqual(self.hashes(), self.before)
+        self.assert_next_call_is_prompt_and_current()
+
+    def test_timed_out(self):
+        result = self.call("reindex", concurrency=1, timeout_ms=300)
+        self.assertEqual(result["error"]["code"], "TIMEOUT")
+        self.assertLess(len(self.parsed), FILE_COUNT)
+        self.assertEqual(self.hashes(), self.before)
+        self.assert_next_call_is_prompt_and_current()
+
+    def test_cancel_only_stops_the_call_in_progress(self):
+        self.indexer.cancel()
+        # Set before any call is tracked: the next call starts afresh
+        result = self.call("reindex", concurrency=1)
+        self.assertNotIn("error", result)
+        self.assertEqual(len(self.parsed), FILE_COUNT)
+        self.assertNotEqual(self.hashes(), self.before)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,64 @@
+"""The command line: --arg values read as the tool's parameters take them."""
+
+import contextlib
+import importlib.util
+import io
+import json
+import shutil
+import tempfile
+import unittest
+from pathlib import Path
+from typing import List, Optional
+
+from xray.core.cli import parse_arg
+
+HAS_FASTMCP = importlib.util.find_spec("fastmcp") is not None
+SAMPLES = Path(__file__).resolve().parent.parent / "test_samples"
+
+
+class ParseArgTest(unittest.TestCase):
+
+    def test_json_for_the_types_it_fits(self):
+        self.assertEqual(parse_arg("limit=50", int), ("limit", 50))
+        self.assertEqual(parse_arg("include_tests=true", Optional[bool]), ("include_tests", True))
+        self.assertEqual(parse_arg('params=["string"]', Optional[List[str]]), ("params", ["string"]))
+
+    def test_a_numeric_looking_string(self):
+        self.assertEqual(parse_arg("value=100", str), ("value", "100"))
+        self.assertEqual(parse_arg("kind=true", Optional[str]), ("kind", "true"))
+        self.assertEqual(parse_arg("query=GetUser", str), ("query", "GetUser"))
+
+    def test_unannotated_is_json(self):
+        self.assertEqual(parse_arg("value=100"), ("value", 100))
+
+    def test_not_key_value(self):
+        with self.assertRaises(ValueError):
+            parse_arg("value")
+
+
+@unittest.skipUnless(HAS_FASTMCP, "the MCP server needs fastmcp")
+class CommandTest(unittest.TestCase):
+
+    def setUp(self):
+        self.root = Path(tempfile.mkdtemp())
+        shutil.copy(SAMPLES / "test.go", self.root / "test.go")
+
+    def tearDown(self):
+        from xray import mcp_server
+        mcp_server._indexer_cache.pop(str(self.root), None)
+        shutil.rmtree(self.root, ignore_errors=True)
+
+    def test_find_literals_of_a_number(self):
+        from xray import mcp_server
+        out = io.StringIO()
+        with contextlib.redirect_stdout(out):
+            status = mcp_server._command(["find_literals", "--project", str(self.root),
+                                          "--arg", "value=100", "--quiet"])
+        self.assertEqual(status, 0)
+        result = json.loads(out.getvalue())
+        self.assertNotIn("error", result)
+        self.assertEqual(result["literals"][0]["line"], 30)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,68 @@
+"""Non-ASCII source: test_samples/unicode.go, latin1.go and utf16.go through the index and the tools' ranges."""
+
+import shutil
+import tempfile
+import unittest
+from pathlib import Path
+
+from xray.core.indexer import XRayIndexer
+
+SAMPLES = Path(__file__).resolve().parent.parent / "test_samples"
+FILES = ("unicode.go", "latin1.go", "utf16.go")
+
+
+def span(start_line, start_column, end_line, end_column):
+    return {"startLine": start_line, "startColumn": start_column, "endLine": end_line, "endColumn": end_column}
+
+
+class EncodingsTest(unittest.TestCase):
+
+    @classmethod
+    def setUpClass(cls):
+        cls.root = Path(tempfile.mkdtemp())
+        for name in FILES:
+            shutil.copy(SAMPLES / name, cls.root / name)
+        cls.indexer = XRayIndexer(str(cls.root))
+        cls.indexer.reindex(force=True)
+
+    @classmethod
+    def tearDownClass(cls):
+        shutil.rmtree(cls.root, ignore_errors=True)
+
+    def listed(self, path):
+        return self.indexer.present(self.indexer.list_symbols(path))["symbols"]
+
+    def test_transcoded_from_the_encoding(self):
+        self.assertEqual([(s["name"], s.get("transcodedFrom")) for s in self.listed("latin1.go")],
+                         [("Café", "latin-1"), ("Menü", "latin-1"), ("Preis", "latin-1")])
+        self.assertEqual([(s["name"], s.get("transcodedFrom")) for s in self.listed("utf16.go")],
+                         [("Zwölf", "utf-16le")])
+        self.assertTrue(all("transcodedFrom" not in s for s in self.listed("unicode.go")))
+        self.assertEqual(self.indexer.index_summary()["transcoded"],
+                         {"count": 2, "paths": [{"path": "latin1.go", "transcodedFrom": "latin-1"},
+                                                {"path": "utf16.go", "transcodedFrom": "utf-16le"}]})
+
+    def test_byte_columns(self):
+        # straße() after "Größe" twice, "Länge" and "数量" on the line: 61 characters in, 70 bytes
+        site = self.indexer.present(self.indexer.find_callers("straße"))["callers"][0]["call_site"]
+        self.assertEqual((site["line"], site["column"], site["range"]), (29, 61, span(29, 70, 29, 77)))
+        # The field "Länge  int": 14 characters after the indent, 15 bytes
+        fields = {s["name"]: s for s in self.listed("unicode.go")}
+        self.assertEqual(fields["Länge"]["range"], span(8, 5, 8, 16))
+
+    def test_byte_columns_of_transcoded_text(self):
+        # "crème brûlée" is 14 characters, and 17 bytes of UTF-8 whatever the file was read in
+        literal = self.indexer.present(self.indexer.find_literals("crème brûlée"))["literals"][0]
+        self.assertEqual((literal["line"], literal["column"], literal["range"], literal["transcodedFrom"]),
+                         (6, 12, span(6, 12, 6, 29), "latin-1"))
+
+    def test_case_folded_search(self):
+        found = self.indexer.present(self.indexer.search_symbols("STRASSE"))
+        self.assertEqual([s["qualified_name"] for s in found], ["straße"])
+        found = self.indexer.present(self.indexer.search_symbols("GRÖSSE"))
+        self.assertEqual([s["qualified_name"] for s in found], ["Größe", "BerechneGröße"])
+        self.assertEqual([s["name"] for s in self.indexer.search_symbols("zwölf")], ["Zwölf"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,231 @@
+"""Structured errors: every code a tool call fails with, and warnings for files left out of a working index."""
+
+import asyncio
+import importlib.util
+import os
+import shutil
+import subprocess
+import tempfile
+import time
+import unittest
+from pathlib import Path
+from unittest import mock
+
+from xray.core.errors import ERROR_CODES, IndexingCancelled, describe_error, error_code
+from xray.core.go_parser import GoSyntaxError
+
+HAS_FASTMCP = importlib.util.find_spec("fastmcp") is not None
+
+FILES = {
+    "go.mod": "module example.com/app\n",
+    "store/store.go": "package store\n\nfunc Get(id int) int { return id }\n",
+    "cache/cache.go": "package cache\n\nfunc Get(key string) string { return key }\n",
+    "api/api.go": "package api\n\nimport \"example.com/app/store\"\n\nfunc Handle() int { return store.Get(1) }\n",
+}
+
+
+def write(root, files):
+    for path, content in files.items():
+        (root / path).parent.mkdir(parents=True, exist_ok=True)
+        (root / path).write_text(content, encoding="utf-8")
+
+
+def git(root, *args):
+    subprocess.run(["git", "-c", "user.name=t", "-c", "user.email=t@example.com", *args], cwd=root, check=True,
+                   capture_output=True)
+
+
+class ErrorCodeTest(unittest.TestCase):
+    """Exceptions of no tool's own are classified by type."""
+
+    def test_classification(self):
+        cases = [
+            (GoSyntaxError("expected ')'"), "PARSE_ERROR"),
+            (IndexingCancelled("stopped"), "CANCELLED"),
+            (UnicodeDecodeError("utf-8", b"\xff", 0, 1, "invalid"), "PARSE_ERROR"),
+            (FileNotFoundError(2, "No such file", "x.go"), "FILE_NOT_FOUND"),
+            (ValueError("bad"), "INVALID_ARGUMENT"),
+            (RuntimeError("bug"), "INTERNAL_ERROR"),
+        ]
+        for exc, code in cases:
+            with self.subTest(type(exc).__name__):
+                self.assertEqual(error_code(exc), code)
+                self.assertIn(code, ERROR_CODES)
+
+    def test_described_with_the_action_and_path(self):
+        info = describe_error(FileNotFoundError(2, "No such file", "x.go"), "Error reading")
+        self.assertEqual(info, {"code": "FILE_NOT_FOUND", "message": "Error reading: [Errno 2] No such file: 'x.go'",
+                                "path": "x.go"})
+
+
+@unittest.skipUnless(HAS_FASTMCP, "the MCP server needs fastmcp")
+class ToolErrorTest(unittest.TestCase):
+    """Each code as a tool call returns it: {"error": {"code", "message", "path"...}}."""
+
+    @classmethod
+    def setUpClass(cls):
+        from xray import mcp_server
+        cls.server = mcp_server
+        cls.tmp = Path(tempfile.mkdtemp()).resolve()
+        # A git repository, and the same files without one
+        cls.repo = cls.tmp / "repo"
+        write(cls.repo, FILES)
+        git(cls.repo, "init", "-q")
+        git(cls.repo, "add", "-A")
+        git(cls.repo, "commit", "-qm", "initial")
+        cls.plain = cls.tmp / "plain"
+        write(cls.plain, FILES)
+
+    @classmethod
+    def tearDownClass(cls):
+        shutil.rmtree(cls.tmp, ignore_errors=True)
+
+    def call(self, tool, **arguments):
+        return asyncio.run(self.server._tool_function(tool)(**arguments))
+
+    def error(self, tool, **arguments):
+        result = self.call(tool, **arguments)
+        self.assertIn("error", result, result)
+        self.assertTrue(result["error"]["message"])
+        return result["error"]
+
+    def project(self, name, files):
+        root = self.tmp / name
+        write(root, files)
+        return str(root)
+
+    def test_invalid_argument(self):
+        self.assertEqual(self.error("find_callers", root_path=str(self.repo), symbol="Handle", format="xml")["code"],
+                         "INVALID_ARGUMENT")
+
+    def test_project_not_indexed(self):
+        self.assertEqual(self.error("find_symbol", project="no-such-project", query="Get")["code"], "PROJECT_NOT_INDEXED")
+
+    def test_file_not_found(self):
+        error = self.error("list_symbols", root_path=str(self.repo), path="store/missing.go")
+        self.assertEqual(error["code"], "FILE_NOT_FOUND")
+        self.assertTrue(error["path"].endswith("missing.go"))
+
+    def test_path_not_allowed(self):
+        root = self.project("outside", FILES)
+        with mock.patch.object(self.server, "_allowlist", self.server.AllowList([str(self.repo)])):
+            error = self.error("list_symbols", root_path=root, path="store/store.go")
+        self.assertEqual(error["code"], "PATH_NOT_ALLOWED")
+        self.assertEqual(error["path"], root)
+
+    def test_symbol_not_found(self):
+        self.assertEqual(self.error("find_callers", root_path=str(self.repo), symbol="NoSuchThing")["code"],
+                         "SYMBOL_NOT_FOUND")
+
+    def test_ambiguous_symbol(self):
+        error = self.error("get_symbol_source", root_path=str(self.repo), symbol="Get")
+        self.assertEqual(error["code"], "AMBIGUOUS_SYMBOL")
+        self.assertEqual(sorted(c["package"] for c in error["candidates"]),
+                         ["example.com/app/cache", "example.com/app/store"])
+
+    def test_symbol_changed(self):
+        root = self.project("changed", FILES)
+        found = self.call("find_symbol", root_path=root, query="Handle")["symbols"][0]
+        path = Path(root) / "api" / "api.go"
+        path.write_text(path.read_text(encoding="utf-8").replace("Handle()", "Handle(n int)"), encoding="utf-8")
+        os.utime(path, (time.time() + 5, time.time() + 5))
+        error = self.error("get_symbol_source", root_path=root, symbol=found["symbol_id"])
+        self.assertEqual(error["code"], "SYMBOL_CHANGED")
+        self.assertEqual(error["closest_match"]["name"], "Handle")
+
+    def test_git_error(self):
+        error = self.error("compare_refs", root_path=str(self.repo), base="no-such-ref")
+        self.assertEqual(error["code"], "GIT_ERROR")
+        self.assertEqual(error["ref"], "no-such-ref")
+
+    def test_unsupported_language(self):
+        self.assertEqual(self.error("file_dependencies", root_path=str(self.repo), path="api/api.go",
+                                    language="cobol")["code"], "UNSUPPORTED_LANGUAGE")
+
+    def test_invalid_config(self):
+        root = self.project("misconfigured", {**FILES, ".xray.yaml": "no_such_setting: 1\n"})
+        error = self.error("find_symbol", root_path=root, query="Get")
+        self.assertEqual(error["code"], "INVALID_CONFIG")
+        self.assertTrue(error["path"].endswith(".xray.yaml"))
+
+    def test_cancelled(self):
+        indexer = self.server.get_indexer(str(self.repo))
+
+        def cancelled_midway(*args, **kwargs):
+            indexer.cancel()
+            indexer._report("searching", 1)
+
+        with mock.patch.object(indexer, "find_symbol", cancelled_midway):
+            self.assertEqual(self.error("find_symbol", root_path=str(self.repo), query="Get")["code"], "CANCELLED")
+        # The next call is not cancelled too
+        self.assertNotIn("error", self.call("find_symbol", root_path=str(self.repo), query="Handle"))
+
+    def test_timeout(self):
+        indexer = self.server.get_indexer(str(self.repo))
+
+        def slow(*args, **kwargs):
+            time.sleep(0.5)
+
+        with mock.patch.object(indexer, "find_symbol", slow):
+            error = self.error("find_symbol", root_path=str(self.repo), query="Get", timeout_ms=50)
+        self.assertEqual(error["code"], "TIMEOUT")
+
+    def test_budget_exceeded(self):
+        result = self.call("batch", calls=[{"tool": "list_symbols", "arguments": {"root_path": str(self.repo),
+                                                                                  "path": "store/store.go"}}],
+                           max_tokens=1)
+        self.assertEqual(result["results"][0]["error"]["code"], "BUDGET_EXCEEDED")
+        self.assertEqual(result["error_count"], 1)
+
+    def test_internal_error(self):
+        indexer = self.server.get_indexer(str(self.repo))
+        with mock.patch.object(indexer, "find_symbol", side_effect=RuntimeError("boom")):
+            self.assertEqual(self.error("find_symbol", root_path=str(self.repo), query="Get")["code"], "INTERNAL_ERROR")
+
+    # No .git directory: history tools say so, everything else works
+
+    def test_no_git_directory(self):
+        self.assertFalse((self.plain / ".git").exists())
+        for tool, arguments in [("blame_symbol", {"symbol": "Handle"}), ("symbol_history", {"symbol": "Handle"}),
+                                ("compare_refs", {"base": "HEAD"})]:
+            with self.subTest(tool):
+                self.assertEqual(self.error(tool, root_path=str(self.plain), **arguments)["code"], "GIT_UNAVAILABLE")
+
+    def test_no_git_directory_still_indexes(self):
+        found = self.call("find_symbol", root_path=str(self.plain), query="Handle")
+        self.assertEqual(found["symbols"][0]["name"], "Handle")
+        overview = self.call("project_overview", root_path=str(self.plain))
+        self.assertFalse(overview["git"]["available"])
+
+    # Partial success
+
+    def with_a_failing_file(self, name, tool, **arguments):
+        """A tool's result on a project one file of which fails to parse."""
+        root = self.project(name, {**FILES, "bad/bad.go": "package bad\n\nfunc Bad() {}\n"})
+        from xray.core import indexer, parse_pool
+        parse = parse_pool.parse_source
+
+        def failing(path, *args, **kwargs):
+            if path.endswith("bad.go"):
+                raise GoSyntaxError("expected ';', found 'EOF'")
+            return parse(path, *args, **kwargs)
+
+        # Whether the tool brings the whole index up to date or parses the files it reads itself
+        with mock.patch.object(parse_pool, "parse_source", failing), mock.patch.object(indexer, "parse_source", failing):
+            result = self.call(tool, root_path=root, **arguments)
+        self.assertEqual(result["warnings"], [{"code": "PARSE_ERROR", "message": "Not indexed: expected ';', found 'EOF'",
+                                               "path": str(Path(root) / "bad" / "bad.go")}])
+        return root, result
+
+    def test_files_left_out_are_warnings(self):
+        root, result = self.with_a_failing_file("partial", "find_symbol", query="Get")
+        self.assertEqual(sorted(s["path"] for s in result["symbols"] if s["name"] == "Get"),
+                         [str(Path(root) / "cache" / "cache.go"), str(Path(root) / "store" / "store.go")])
+
+    def test_files_left_out_of_the_index_are_warnings(self):
+        _, result = self.with_a_failing_file("partial-index", "find_callers", symbol="store.Get")
+        self.assertEqual([c["name"] for c in result["callers"]], ["Handle"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,192 @@
+"""The Go parser: declarations, their receivers, and what it keeps of a file that does not parse."""
+
+import os
+import unittest
+from pathlib import Path
+
+from xray.core.go_analysis import GoProject
+from xray.core.go_parser import parse_go_source
+
+SAMPLES = Path(__file__).resolve().parent.parent / "test_samples"
+
+UNCLOSED = """package p
+
+import "fmt"
+
+func Broken( {
+	fmt.Println("x")
+}
+
+type T struct { A int
+
+func After() int {
+	return 1
+}
+"""
+
+
+def symbols(parsed):
+    return {s["name"]: s for s in parsed["symbols"]}
+
+
+class RecoveryTest(unittest.TestCase):
+
+    def test_unclosed_brackets_are_reported_where_they_open(self):
+        errors = [(e["message"], e["line"], e["column"]) for e in parse_go_source(UNCLOSED)["parseErrors"]]
+        self.assertEqual(errors, [("unclosed '('", 5, 12), ("unclosed '{'", 9, 15)])
+
+    def test_unclosed_declarations_are_kept_partial(self):
+        found = symbols(parse_go_source(UNCLOSED))
+        self.assertEqual((found["Broken"]["type"], found["Broken"]["start_line"], found["Broken"]["end_line"]),
+                         ("function", 5, 7))
+        self.assertEqual((found["T"]["type"], found["T"]["start_line"]), ("struct", 9))
+        self.assertEqual(found["A"]["container"], "T")
+        for name in ("Broken", "T", "A"):
+            with self.subTest(name=name):
+                self.assertTrue(found[name].get("partial"))
+                self.assertTrue(found[name].get("approximate"))
+
+    def test_the_declaration_after_is_healthy(self):
+        after = symbols(parse_go_source(UNCLOSED))["After"]
+        self.assertEqual((after["signature"], after["start_line"], after["end_line"]), ("func After() int", 11, 13))
+        self.assertNotIn("approximate", after)
+        self.assertNotIn("partial", after)
+
+    def test_unclosed_group(self):
+        parsed = parse_go_source("package p\n\nconst (\n\tA = 1\n\tB = 2\n\nvar V int\n")
+        self.assertEqual([(e["message"], e["line"], e["column"]) for e in parsed["parseErrors"]],
+                         [("unclosed '('", 3, 7)])
+        found = symbols(parsed)
+        self.assertTrue(found["A"]["partial"] and found["B"]["partial"])
+        self.assertNotIn("approximate", found["V"])
+
+    def test_unclosed_method_without_a_body_keeps_its_receiver(self):
+        parsed = parse_go_source("package p\n\nfunc (s *S) Get(id int, {\n\nfunc Next() {}\n")
+        found = symbols(parsed)
+        self.assertEqual((found["Get"]["type"], found["Get"]["receiver"]["type"]), ("method", "S"))
+        self.assertTrue(found["Get"]["partial"])
+        self.assertNotIn("approximate", found["Next"])
+
+    def test_unclosed_value(self):
+        parsed = parse_go_source("package p\n\nvar x = [\n\nfunc F() {}\n")
+        self.assertEqual([(e["message"], e["line"], e["column"]) for e in parsed["parseErrors"]],
+                         [("unclosed '['", 3, 9)])
+        found = symbols(parsed)
+        self.assertTrue(found["x"]["partial"])
+        self.assertNotIn("approximate", found["F"])
+
+    def test_error_inside_a_declaration_stays_where_it_is(self):
+        parsed = parse_go_source("package p\n\ntype = int\n\nfunc F() {}\n")
+        self.assertEqual([(e["message"], e["line"], e["column"]) for e in parsed["parseErrors"]],
+                         [("expected identifier, found '='", 3, 6)])
+        self.assertIn("F", symbols(parsed))
+
+
+class GroupedDeclarationTest(unittest.TestCase):
+    """Every name of a grouped or multi-name declaration in test_samples/declarations.go is a symbol."""
+
+    @classmethod
+    def setUpClass(cls):
+        cls.parsed = parse_go_source((SAMPLES / "declarations.go").read_text(encoding="utf-8"))
+        cls.found = symbols(cls.parsed)
+
+    def test_count(self):
+        self.assertEqual(len(self.parsed["symbols"]), 20)
+        self.assertEqual(len([s for s in self.parsed["symbols"] if s["type"] != "field"]), 18)
+
+    def test_each_name_at_its_own_position(self):
+        positions = [(s["name"], s["start_line"], s["column"]) for s in self.parsed["symbols"]]
+        self.assertEqual(positions, [
+            ("sessionCount", 8, 5), ("sessionOwner", 9, 5),
+            ("width", 13, 5), ("height", 13, 12), ("depth", 13, 20),
+            ("label", 16, 5), ("ratio", 16, 12),
+            ("workDir", 19, 5), ("workDirErr", 19, 14),
+            ("Low", 22, 7), ("High", 22, 12),
+            ("KB", 26, 5), ("KiB", 26, 9), ("MB", 27, 5), ("MiB", 27, 9),
+            ("Point", 32, 5), ("X", 33, 9), ("Y", 33, 12), ("Names", 35, 5), ("Count", 36, 5),
+        ])
+
+    def test_shared_type(self):
+        for name in ("width", "height", "depth"):
+            with self.subTest(name):
+                self.assertEqual((self.found[name]["var_type"], self.found[name]["signature"]), ("int", f"var {name} int"))
+        self.assertEqual([self.found[n]["var_type"] for n in ("sessionCount", "sessionOwner")], ["int", "*User"])
+
+    def test_own_values(self):
+        self.assertEqual([self.found[n]["value"] for n in ("label", "ratio", "Low", "High")], ['"box"', "0.5", 1, 10])
+        # One call's results, taken by position
+        self.assertEqual([(self.found[n]["value"], self.found[n]["value_index"]) for n in ("workDir", "workDirErr")],
+                         [("os.Getwd()", 0), ("os.Getwd()", 1)])
+
+    def test_repeated_spec_takes_the_next_iota(self):
+        self.assertEqual([(self.found[n]["value"], self.found[n]["iota"]) for n in ("KB", "KiB", "MB", "MiB")],
+                         [(1024, 0), (1000, 0), (1048576, 1), (2000, 1)])
+        self.assertEqual(self.found["MiB"]["expression"], "1000 * (iota + 1)")
+
+    def test_groups(self):
+        groups = {s["name"]: s.get("group") for s in self.parsed["symbols"] if s["type"] != "field"}
+        self.assertEqual({name for name, group in groups.items() if group == "var@7"}, {"sessionCount", "sessionOwner"})
+        self.assertEqual({name for name, group in groups.items() if group == "const@25"}, {"KB", "KiB", "MB", "MiB"})
+        self.assertEqual({name for name, group in groups.items() if group == "type@31"}, {"Point", "Names", "Count"})
+        self.assertIsNone(groups["width"])
+
+    def test_type_group(self):
+        self.assertEqual([(self.found[n]["type"], self.found[n]["alias"]) for n in ("Point", "Names", "Count")],
+                         [("struct", False), ("type", False), ("type", True)])
+        self.assertEqual((self.found["X"]["container"], self.found["Y"]["container"]), ("Point", "Point"))
+
+
+class ReceiverTest(unittest.TestCase):
+
+    @classmethod
+    def setUpClass(cls):
+        cls.path = str(SAMPLES / "test.go")
+        cls.parsed = parse_go_source((SAMPLES / "test.go").read_text(encoding="utf-8"))
+
+    def method(self, receiver, name):
+        return next(s for s in self.parsed["symbols"]
+                    if s["type"] == "method" and s.get("receiver", {}).get("type") == receiver and s["name"] == name)
+
+    def test_pointer_receiver(self):
+        self.assertEqual(self.method("UserService", "GetUser")["receiver"],
+                         {"name": "s", "type": "UserService", "pointer": True})
+
+    def test_value_receiver(self):
+        self.assertEqual(self.method("UserService", "String")["receiver"],
+                         {"name": "s", "type": "UserService", "pointer": False})
+
+    def test_interface_methods_have_no_receiver(self):
+        specs = [s for s in self.parsed["symbols"] if s.get("container") == "Service"]
+        self.assertEqual(sorted(s["name"] for s in specs), ["CreateUser", "DeleteUser", "GetUser"])
+        self.assertFalse(any("receiver" in s for s in specs))
+
+    def test_pointer_receivers_need_an_addressable_value(self):
+        ifaces = parse_go_source(
+            "package main\n\n"
+            "type Stringer interface{ String() string }\n\n"
+            "type Getter interface {\n\tGetUser(id int) (*User, error)\n\tString() string\n}\n")
+        directory = str(SAMPLES)
+        project = GoProject([(self.path, self.parsed), (os.path.join(directory, "ifaces.go"), ifaces)], directory)
+        service = (directory, "UserService")
+        self.assertEqual(project.match_interface((directory, "Stringer"), service)["satisfied_by"], "UserService")
+        getter = project.match_interface((directory, "Getter"), service)
+        self.assertEqual(getter["satisfied_by"], "*UserService")
+        self.assertEqual(getter["pointer_receiver_methods"], ["GetUser"])
+
+
+class StructTagTest(unittest.TestCase):
+
+    def test_parsed(self):
+        fields = symbols(parse_go_source('package p\n\ntype T struct {\n\tA int `json:"a,omitempty" db:"-"`\n}\n'))
+        self.assertEqual(fields["A"]["tags"], {"json": {"name": "a", "options": ["omitempty"]}, "db": {"name": "", "options": [], "skip": True}})
+        self.assertNotIn("parseError", fields["A"])
+
+    def test_malformed_keeps_the_raw_tag(self):
+        fields = symbols(parse_go_source('package p\n\ntype T struct {\n\tA int `json:"a`\n\tB int `db:x`\n}\n'))
+        for name, tag in (("A", 'json:"a'), ("B", "db:x")):
+            with self.subTest(name):
+                self.assertEqual((fields[name]["tag"], fields[name]["parseError"]), (tag, True))
+
+
+if __name__ == "__main__":
+    unittest.main()
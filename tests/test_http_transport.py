@@ -0,0 +1,87 @@
+"""The HTTP transport's guards: Origin checks against DNS rebinding, and the bearer token."""
+
+import asyncio
+import unittest
+
+from xray.core.http_transport import BearerAuth, OriginCheck, parse_origin
+
+
+class App:
+    """An ASGI app answering 200, counting the requests that reach it."""
+
+    def __init__(self):
+        self.calls = 0
+
+    async def __call__(self, scope, receive, send):
+        self.calls += 1
+        await send({"type": "http.response.start", "status": 200, "headers": []})
+        await send({"type": "http.response.body", "body": b"ok"})
+
+
+def request(app, headers=(), kind="http"):
+    """The status an ASGI app answers a POST /mcp with these headers, None if it sent nothing."""
+    sent = []
+
+    async def receive():
+        return {"type": "http.request", "body": b""}
+
+    async def send(message):
+        sent.append(message)
+
+    scope = {"type": kind, "method": "POST", "path": "/mcp",
+             "headers": [(name.encode(), value.encode()) for name, value in headers]}
+    asyncio.run(app(scope, receive, send))
+    return next((m["status"] for m in sent if m["type"] == "http.response.start"), None)
+
+
+class OriginCheckTest(unittest.TestCase):
+
+    def test_no_origin_is_not_a_browser(self):
+        self.assertEqual(request(OriginCheck(App())), 200)
+
+    def test_loopback_origins_by_default(self):
+        check = OriginCheck(App())
+        for origin in ("http://localhost:3000", "http://127.0.0.1", "https://[::1]:8443", "HTTP://LOCALHOST/"):
+            with self.subTest(origin):
+                self.assertEqual(request(check, [("Origin", origin)]), 200)
+
+    def test_other_origins_are_turned_away(self):
+        app = App()
+        check = OriginCheck(app)
+        # A rebound name resolves to 127.0.0.1 but is not a loopback origin
+        for origin in ("http://rebind.example.com:8765", "http://localhost.example.com", "http://127.0.0.1@evil.com",
+                       "null", "file://", "ws://localhost"):
+            with self.subTest(origin):
+                self.assertEqual(request(check, [("Origin", origin)]), 403)
+        self.assertEqual(app.calls, 0)
+
+    def test_configured_origins_replace_loopback(self):
+        check = OriginCheck(App(), ["https://app.example.com/"])
+        self.assertEqual(request(check, [("Origin", "https://app.example.com")]), 200)
+        self.assertEqual(request(check, [("Origin", "https://app.example.com:444")]), 403)
+        self.assertEqual(request(check, [("Origin", "http://localhost:3000")]), 403)
+
+    def test_any_origin(self):
+        self.assertEqual(request(OriginCheck(App(), ["*"]), [("Origin", "https://anywhere.example")]), 200)
+
+    def test_lifespan_passes(self):
+        app = App()
+        request(OriginCheck(app), kind="lifespan")
+        self.assertEqual(app.calls, 1)
+
+    def test_before_the_token(self):
+        app = OriginCheck(BearerAuth(App(), "secret"))
+        self.assertEqual(request(app, [("Origin", "http://evil.example")]), 403)
+        self.assertEqual(request(app, [("Origin", "http://localhost")]), 401)
+        self.assertEqual(request(app, [("Origin", "http://localhost"), ("Authorization", "Bearer secret")]), 200)
+
+    def test_parse_origin(self):
+        self.assertEqual(parse_origin(" https://App.Example.com:8443/ "), "https://app.example.com:8443")
+        self.assertEqual(parse_origin("*"), "*")
+        for value in ("app.example.com", "ftp://app.example.com", "https://app.example.com/path", "https://"):
+            with self.subTest(value), self.assertRaises(ValueError):
+                parse_origin(value)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,72 @@
+"""Import resolution: aliased, dot, blank and duplicate-path imports in one file (test_samples/imports.go)."""
+
+import unittest
+from pathlib import Path
+
+from xray.core.go_parser import parse_go_source
+from xray.core.indexer import XRayIndexer
+
+SAMPLES = Path(__file__).resolve().parent.parent / "test_samples"
+
+
+class ImportTableTest(unittest.TestCase):
+
+    @classmethod
+    def setUpClass(cls):
+        cls.parsed = parse_go_source((SAMPLES / "imports.go").read_text(encoding="utf-8"))
+
+    def test_every_form(self):
+        imports = [(i["path"], i["name"], i["kind"]) for i in self.parsed["imports"]]
+        self.assertEqual(imports, [
+            ("fmt", "fmt", "default"),
+            ("fmt", "f", "alias"),
+            ("database/sql", "db", "alias"),
+            ("github.com/lib/pq", None, "blank"),
+            ("strings", None, "dot"),
+        ])
+
+    def test_qualified_references_resolve_to_the_import_path(self):
+        refs = [(r["qualifier"], r["name"], r["package"]) for r in self.parsed["qualified_refs"]]
+        self.assertEqual(refs, [("db", "DB", "database/sql"), ("f", "Println", "fmt"), ("fmt", "Sprintf", "fmt")])
+
+    def test_parameter_shadowing_an_import_is_not_the_package(self):
+        self.assertNotIn("Format", [r["name"] for r in self.parsed["qualified_refs"]])
+
+    def test_dot_import_names(self):
+        self.assertEqual(self.parsed["unqualified_exported"], ["ToUpper", "TrimSpace"])
+
+
+class FileDependenciesTest(unittest.TestCase):
+
+    @classmethod
+    def setUpClass(cls):
+        result = XRayIndexer(str(SAMPLES)).file_dependencies("imports.go")
+        cls.result = result
+        cls.deps = {d["path"]: d for d in result["dependencies"]}
+
+    def test_no_phantom_alias_packages(self):
+        self.assertEqual(list(self.deps), ["fmt", "database/sql", "github.com/lib/pq", "strings"])
+        self.assertEqual(self.result["total_count"], 4)
+
+    def test_aliased(self):
+        self.assertEqual((self.deps["database/sql"]["names"], self.deps["database/sql"]["used"]), (["db"], ["DB"]))
+
+    def test_duplicate_path_reported_once(self):
+        fmt = self.deps["fmt"]
+        self.assertEqual((fmt["names"], fmt["kinds"], fmt["lines"]), (["fmt", "f"], ["default", "alias"], [5, 6]))
+        self.assertEqual(fmt["used"], ["Println", "Sprintf"])
+
+    def test_blank_is_side_effect_only(self):
+        pq = self.deps["github.com/lib/pq"]
+        self.assertTrue(pq["side_effect_only"])
+        self.assertEqual((pq["names"], pq["used"]), ([], []))
+
+    def test_dot_merges_into_the_file_scope(self):
+        strings = self.deps["strings"]
+        self.assertTrue(strings["merged_into_file_scope"])
+        self.assertFalse(strings["side_effect_only"])
+        self.assertEqual(strings["used"], ["ToUpper", "TrimSpace"])
+
+
+if __name__ == "__main__":
+    unittest.main()
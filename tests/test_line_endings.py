@@ -0,0 +1,132 @@
+"""Line endings: CRLF and mixed files give the lines and columns of their LF version."""
+
+import shutil
+import tempfile
+import unittest
+from pathlib import Path
+
+from xray.core.go_parser import parse_go_source
+from xray.core.indexer import XRayIndexer
+from xray.core.ranges import add_declaration_ranges
+from xray.core.source_text import decode_source, source_lines
+
+LF = (
+    "package main\n"
+    "\n"
+    "// Greeting greets.\n"
+    "func Greeting(name string) string {\n"
+    "\treturn \"hello, \" + name\n"
+    "}\n"
+    "\n"
+    "var Farewell = `bye\n"
+    "now`\n"
+    "\n"
+    "func After() string { return Greeting(\"wörld\") }\n"
+)
+
+
+def crlf(text):
+    return text.replace("\n", "\r\n")
+
+
+def mixed(text):
+    """Every other line ending CRLF."""
+    lines = text.split("\n")
+    return "".join(line + ("\r\n" if i % 2 else "\n") for i, line in enumerate(lines[:-1]))
+
+
+# Characters str.splitlines breaks at and Go does not, in a comment and a string
+SEPARATORS = crlf(LF.replace("greets.", "greets,\x0c in\x85 two  words.")
+                  .replace('"hello, "', '"hello,\x0c "'))
+
+VARIANTS = {"lf": LF, "crlf": crlf(LF), "mixed": mixed(LF), "separators": SEPARATORS}
+
+
+def span(start_line, start_column, end_line, end_column):
+    return {"startLine": start_line, "startColumn": start_column, "endLine": end_line, "endColumn": end_column}
+
+
+EXPECTED = [
+    # name, line, column, end line, declaration range
+    ("Greeting", 4, 6, 6, span(4, 1, 6, 2)),
+    ("Farewell", 8, 5, 9, span(8, 1, 9, 5)),
+    ("After", 11, 6, 11, span(11, 1, 11, 50)),
+]
+
+
+class SourceLinesTest(unittest.TestCase):
+
+    def test_split(self):
+        cases = [
+            ("empty", "", []),
+            ("no final newline", "a\nb", ["a", "b"]),
+            ("final newline", "a\nb\n", ["a", "b"]),
+            ("crlf", "a\r\nb\r\n", ["a", "b"]),
+            ("mixed", "a\r\nb\nc\r\n", ["a", "b", "c"]),
+            ("blank lines", "a\r\n\r\n\nb", ["a", "", "", "b"]),
+            ("separators stay in their line", "a\x0cb\x85c d\n", ["a\x0cb\x85c d"]),
+        ]
+        for label, text, lines in cases:
+            with self.subTest(label):
+                self.assertEqual(source_lines(text), lines)
+
+    def test_read_from_disk(self):
+        for label, text in VARIANTS.items():
+            with self.subTest(label):
+                decoded, _ = decode_source(text.encode("utf-8"))
+                self.assertEqual(len(source_lines(decoded)), 11)
+                self.assertNotIn("\r", decoded)
+
+
+class ParsedPositionsTest(unittest.TestCase):
+
+    def test_positions(self):
+        for label, text in VARIANTS.items():
+            with self.subTest(label):
+                parsed = add_declaration_ranges(parse_go_source(text), text)
+                self.assertEqual([(s["name"], s["start_line"], s["column"], s["end_line"], s["range"])
+                                  for s in parsed["symbols"]], EXPECTED)
+
+
+class ToolPositionsTest(unittest.TestCase):
+    """The files on disk, through the index and the tools' ranges."""
+
+    @classmethod
+    def setUpClass(cls):
+        cls.root = Path(tempfile.mkdtemp())
+        (cls.root / "go.mod").write_bytes(b"module example.com/endings\r\n\r\ngo 1.21\r\n")
+        for label, text in VARIANTS.items():
+            (cls.root / label).mkdir()
+            (cls.root / label / "main.go").write_bytes(text.encode("utf-8"))
+        cls.indexer = XRayIndexer(str(cls.root))
+        cls.indexer.reindex(force=True)
+
+    @classmethod
+    def tearDownClass(cls):
+        shutil.rmtree(cls.root, ignore_errors=True)
+
+    def test_listed(self):
+        for label in VARIANTS:
+            with self.subTest(label):
+                listed = self.indexer.present(self.indexer.list_symbols(f"{label}/main.go"))["symbols"]
+                self.assertEqual([(s["name"], s["start_line"], s["end_line"], s["range"]) for s in listed],
+                                 [(name, line, end, declared) for name, line, _, end, declared in EXPECTED])
+
+    def test_call_site(self):
+        for label in VARIANTS:
+            with self.subTest(label):
+                result = self.indexer.present(self.indexer.find_callers("Greeting", f"{label}/main.go"))
+                site = result["callers"][0]["call_site"]
+                # Greeting( after "func After() string { return "
+                self.assertEqual((site["line"], site["range"]), (11, span(11, 30, 11, 38)))
+
+    def test_source_text(self):
+        for label in VARIANTS:
+            with self.subTest(label):
+                source = self.indexer.get_symbol_source("Farewell", f"{label}/main.go")
+                self.assertEqual((source["start_line"], source["end_line"]), (8, 9))
+                self.assertNotIn("\r", source["source"])
+
+
+if __name__ == "__main__":
+    unittest.main()
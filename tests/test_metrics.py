@@ -0,0 +1,81 @@
+"""Function metrics of test_samples/test.go, pinned, and their ranking with thresholds."""
+
+import os
+import unittest
+from pathlib import Path
+
+from xray.core.go_analysis import GoProject
+from xray.core.go_metrics import METRICS, rank_functions
+from xray.core.go_parser import parse_go_source
+
+SAMPLES = Path(__file__).resolve().parent.parent / "test_samples"
+
+# name: complexity, loc, statements, max_nesting, param_count, result_count, distinct_callees
+EXPECTED = {
+    # Two ifs; the blank lines are not code; QueryRow and Scan
+    "UserService.GetUser": (3, 12, 9, 1, 1, 2, 2),
+    # len is a builtin
+    "UserService.String": (1, 3, 1, 0, 0, 1, 1),
+    # So is make
+    "NewUserService": (1, 6, 1, 0, 1, 1, 0),
+    # Two cases, default not counted; the switch nests
+    "ProcessData": (3, 10, 4, 1, 1, 1, 3),
+    # The comment-only line is not code
+    "processUser": (1, 4, 2, 0, 1, 1, 1),
+    # An if in a for
+    "processUsers": (3, 8, 5, 2, 1, 1, 1),
+    # NewUserService, GetUser, http.Error, err.Error, json.NewEncoder, Encode
+    "userHandler": (2, 9, 6, 1, 2, 0, 6),
+    "Logger.Log": (1, 3, 1, 0, 1, 0, 1),
+    "main": (1, 6, 4, 0, 0, 0, 2),
+}
+
+
+class MetricsTest(unittest.TestCase):
+
+    @classmethod
+    def setUpClass(cls):
+        path = str(SAMPLES / "test.go")
+        parsed = parse_go_source((SAMPLES / "test.go").read_text(encoding="utf-8"))
+        cls.symbols = parsed["symbols"]
+        cls.project = GoProject([(path, parsed)], str(SAMPLES))
+
+    def test_symbol_records(self):
+        measured = {}
+        for symbol in self.symbols:
+            if "complexity" in symbol:
+                receiver = (symbol.get("receiver") or {}).get("type")
+                measured[f"{receiver}.{symbol['name']}" if receiver else symbol["name"]] = \
+                    tuple(symbol[metric] for metric in METRICS)
+        self.assertEqual(measured, EXPECTED)
+
+    def test_declarations_without_a_body_are_not_measured(self):
+        for symbol in self.symbols:
+            if symbol["type"] not in ("function", "method") or symbol.get("container") == "Service":
+                with self.subTest(symbol["name"]):
+                    self.assertNotIn("complexity", symbol)
+
+    def test_ranked_worst_first(self):
+        result = rank_functions(self.project)
+        self.assertEqual([r["name"] for r in result["functions"]][:3], ["UserService.GetUser", "ProcessData", "processUsers"])
+        self.assertEqual((result["total_count"], result["measured"]), (9, 9))
+        self.assertEqual(result["max"], dict(zip(METRICS, (3, 12, 9, 2, 2, 2, 6))))
+
+    def test_thresholds(self):
+        result = rank_functions(self.project, sort_by="distinct_callees", minimums={"complexity": 2, "loc": 9})
+        self.assertEqual([(r["name"], r["distinct_callees"]) for r in result["functions"]],
+                         [("userHandler", 6), ("ProcessData", 3), ("UserService.GetUser", 2)])
+        self.assertEqual(result["measured"], 9)
+
+    def test_path_filter(self):
+        self.assertEqual(rank_functions(self.project, path=os.path.join(str(SAMPLES), "other.go"))["total_count"], 0)
+
+    def test_unknown_metric(self):
+        with self.assertRaises(ValueError):
+            rank_functions(self.project, sort_by="halstead")
+        with self.assertRaises(ValueError):
+            rank_functions(self.project, minimums={"halstead": 1})
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,110 @@
+"""The parse pool: the same results in parallel as serially, whatever fails, and a benchmark of its speedup.
+
+The benchmark parses test_samples replicated COPIES times, serially and
+with a worker per CPU. It always checks the results agree; set
+XRAY_BENCHMARK=1 to time both and require the pool to be faster.
+"""
+
+import os
+import shutil
+import tempfile
+import threading
+import time
+import unittest
+from concurrent.futures import Future
+from concurrent.futures.process import BrokenProcessPool
+from pathlib import Path
+from unittest import mock
+
+from xray.core import parse_pool
+from xray.core.errors import IndexingCancelled
+
+SAMPLES = Path(__file__).resolve().parent.parent / "test_samples"
+COPIES = int(os.environ.get("XRAY_BENCHMARK_COPIES", "12"))
+BENCHMARK = os.environ.get("XRAY_BENCHMARK") == "1"
+
+
+def replicate(root, copies):
+    """test_samples copied into copies directories, as parse jobs with no known hash."""
+    jobs = []
+    for i in range(copies):
+        directory = root / f"copy{i:02d}"
+        shutil.copytree(SAMPLES, directory)
+        jobs += [(str(path), None) for path in sorted(directory.iterdir())]
+    return jobs
+
+
+class BrokenPool:
+    """An executor whose workers have all died: every batch fails with BrokenProcessPool."""
+
+    def __init__(self, *args, **kwargs):
+        self.submitted = 0
+
+    def submit(self, *args, **kwargs):
+        self.submitted += 1
+        future = Future()
+        future.set_exception(BrokenProcessPool("a worker was killed"))
+        return future
+
+    def shutdown(self, *args, **kwargs):
+        pass
+
+
+class ParsePoolTest(unittest.TestCase):
+
+    @classmethod
+    def setUpClass(cls):
+        cls.root = Path(tempfile.mkdtemp())
+        cls.jobs = replicate(cls.root, COPIES)
+        cls.serial = parse_pool.parse_files(cls.jobs, 1)
+
+    @classmethod
+    def tearDownClass(cls):
+        shutil.rmtree(cls.root, ignore_errors=True)
+
+    def test_enough_files_for_the_pool(self):
+        self.assertGreaterEqual(len(self.jobs), parse_pool.MIN_PARALLEL_FILES)
+        self.assertEqual(set(self.serial), {path for path, _ in self.jobs})
+
+    def test_parallel_matches_serial(self):
+        self.assertEqual(parse_pool.parse_files(self.jobs, 4), self.serial)
+
+    def test_failing_files_are_reported_alone(self):
+        missing = str(self.root / "missing.go")
+        results = parse_pool.parse_files(self.jobs + [(missing, None)], 4)
+        self.assertEqual(results[missing]["code"], "FILE_NOT_FOUND")
+        self.assertEqual({path: r for path, r in results.items() if path != missing}, self.serial)
+
+    def test_a_dead_worker_falls_back_to_parsing_here(self):
+        with mock.patch.object(parse_pool, "ProcessPoolExecutor", BrokenPool):
+            progress = []
+            results = parse_pool.parse_files(self.jobs, 4, progress=lambda done, total, path: progress.append(done))
+        self.assertEqual(results, self.serial)
+        self.assertEqual(progress, list(range(1, len(self.jobs) + 1)))
+
+    def test_cancelled(self):
+        cancel = threading.Event()
+        cancel.set()
+        with self.assertRaises(IndexingCancelled):
+            parse_pool.parse_files(self.jobs, 4, cancel=cancel)
+        with mock.patch.object(parse_pool, "ProcessPoolExecutor", BrokenPool), self.assertRaises(IndexingCancelled):
+            parse_pool.parse_files(self.jobs, 4, cancel=cancel)
+
+    @unittest.skipUnless(BENCHMARK, "set XRAY_BENCHMARK=1 to time the pool")
+    def test_benchmark(self):
+        workers = parse_pool.default_concurrency()
+        if workers < 2:
+            self.skipTest("one CPU: nothing to parallelize over")
+        start = time.perf_counter()
+        parse_pool.parse_files(self.jobs, 1)
+        serial = time.perf_counter() - start
+        start = time.perf_counter()
+        parse_pool.parse_files(self.jobs, workers)
+        parallel = time.perf_counter() - start
+        print(f"\n{len(self.jobs)} files: {serial:.2f}s serially, {parallel:.2f}s on {workers} workers "
+              f"({serial / parallel:.1f}x)")
+        self.assertLess(parallel, serial)
+
+
+if __name__ == "__main__":
+    unittest.main()
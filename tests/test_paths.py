@@ -0,0 +1,85 @@
+"""Path parameters: the forms a tool accepts for a file in the project, and the ones leading out of it."""
+
+import os
+import shutil
+import tempfile
+import unittest
+from pathlib import Path
+
+from xray.core.errors import FILE_NOT_FOUND, INVALID_ARGUMENT, XRayError, describe_error
+from xray.core.indexer import XRayIndexer
+
+FILES = {
+    "go.mod": "module example.com/app\n",
+    "store/store.go": "package store\n\nfunc Get() int { return 1 }\n",
+    "api/api.go": "package api\n\nfunc Handle() {}\n",
+}
+
+
+class ResolvePathTest(unittest.TestCase):
+
+    @classmethod
+    def setUpClass(cls):
+        cls.tmp = Path(tempfile.mkdtemp()).resolve()
+        cls.root = cls.tmp / "project"
+        for path, content in FILES.items():
+            (cls.root / path).parent.mkdir(parents=True, exist_ok=True)
+            (cls.root / path).write_text(content, encoding="utf-8")
+        (cls.tmp / "outside").mkdir()
+        (cls.tmp / "outside" / "secret.go").write_text("package outside\n", encoding="utf-8")
+        # A link to the project from outside, and one out of the project from inside
+        os.symlink(cls.root, cls.tmp / "linked")
+        os.symlink(cls.tmp / "outside", cls.root / "escape")
+        cls.indexer = XRayIndexer(str(cls.root))
+        cls.indexer.reindex(force=True)
+
+    @classmethod
+    def tearDownClass(cls):
+        shutil.rmtree(cls.tmp, ignore_errors=True)
+
+    def test_paths_in_the_project(self):
+        store = self.root / "store" / "store.go"
+        cases = [
+            ("relative", "store/store.go", store),
+            ("dot relative", "./store/store.go", store),
+            ("absolute", str(store), store),
+            ("directory", "store", self.root / "store"),
+            ("trailing slash", "store/", self.root / "store"),
+            ("root", ".", self.root),
+            (".. back into the project", "api/../store/store.go", store),
+            ("through a symlinked parent", str(self.tmp / "linked" / "store" / "store.go"), store),
+            ("windows separators", "store\\store.go", store),
+        ]
+        for label, path, expected in cases:
+            with self.subTest(label, path=path):
+                self.assertEqual(self.indexer._resolve_path(path), expected)
+
+    def test_paths_out_of_the_project(self):
+        cases = [
+            ("parent", "../"),
+            ("parent's file", "../outside/secret.go"),
+            ("windows parent", "..\\outside"),
+            ("filesystem root", "/"),
+            ("absolute elsewhere", str(self.tmp / "outside" / "secret.go")),
+            ("symlink out of the project", "escape/secret.go"),
+        ]
+        for label, path in cases:
+            with self.subTest(label, path=path):
+                with self.assertRaises(XRayError) as raised:
+                    self.indexer._resolve_path(path)
+                self.assertEqual(describe_error(raised.exception)["code"], INVALID_ARGUMENT)
+
+    def test_scope_that_does_not_exist(self):
+        with self.assertRaises(XRayError) as raised:
+            self.indexer._scope("store/missing.go")
+        self.assertEqual(describe_error(raised.exception)["code"], FILE_NOT_FOUND)
+
+    def test_list_symbols_takes_every_form(self):
+        for path in ("store/store.go", str(self.root / "store" / "store.go"), "store\\store.go"):
+            with self.subTest(path=path):
+                names = [s["name"] for s in self.indexer.list_symbols(path)["symbols"]]
+                self.assertIn("Get", names)
+
+
+if __name__ == "__main__":
+    unittest.main()
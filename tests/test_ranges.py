@@ -0,0 +1,124 @@
+"""Source ranges: UTF-8 byte columns, and one range per declaration whichever tool reports it."""
+
+import shutil
+import tempfile
+import unittest
+from pathlib import Path
+
+from xray.core.go_parser import parse_go_source
+from xray.core.indexer import XRayIndexer
+from xray.core.ranges import add_declaration_ranges, source_range
+
+
+def span(start_line, start_column, end_line, end_column):
+    return {"startLine": start_line, "startColumn": start_column, "endLine": end_line, "endColumn": end_column}
+
+
+class SourceRangeTest(unittest.TestCase):
+
+    def test_columns(self):
+        cases = [
+            # label, line text, character column, expected byte columns of the token there
+            ("ascii", "x := Foo(1)", 6, (6, 9)),
+            ("tab indented", "\tx := Foo(1)", 7, (7, 10)),
+            ("two tabs", "\t\treturn Bar()", 10, (10, 13)),
+            ("after a two-byte character", 's := "é"; Foo()', 11, (12, 15)),
+            ("after a three-byte character", 's := "€"; Foo()', 11, (13, 16)),
+            ("after a four-byte character", 's := "😀"; Foo()', 11, (14, 17)),
+            ("multibyte identifier", "\tGrüße(name)", 2, (2, 9)),
+            ("string literal", '\tfmt.Println("héllo")', 14, (14, 22)),
+        ]
+        for label, text, column, (start, end) in cases:
+            with self.subTest(label):
+                self.assertEqual(source_range([text], 1, column), span(1, start, 1, end))
+
+    def test_name_without_a_column(self):
+        cases = [
+            ("tab indented", "\tresult := Fetch(id)", "Fetch", (12, 17)),
+            ("qualified", "\treturn store.Fetch(id)", "Store.Fetch", (15, 20)),
+            ("after multibyte text", '\tlog("Grüße"); Fetch(id)', "Fetch", (18, 23)),
+            ("whole words only", "\tFetchAll(); Fetch()", "Fetch", (14, 19)),
+        ]
+        for label, text, name, (start, end) in cases:
+            with self.subTest(label):
+                self.assertEqual(source_range([text], 1, name=name), span(1, start, 1, end))
+
+    def test_bare_line(self):
+        self.assertEqual(source_range(["\t\tgo work()  "], 1), span(1, 3, 1, 12))
+        self.assertEqual(source_range(["\tnothing(here)"], 1, name="Fetch"), span(1, 2, 1, 15))
+
+    def test_declaration(self):
+        lines = ["// Grüße greets.", "func Grüße(name string) string {", '\treturn "héllo " + name', "}"]
+        self.assertEqual(source_range(lines, 2, 6, 4, declaration=True), span(2, 1, 4, 2))
+        self.assertEqual(source_range(lines, 3, 2, 3), span(3, 2, 3, 25))
+
+    def test_declaration_ranges_at_parse_time(self):
+        content = 'package p\n\nfunc Grüße(name string) string {\n\treturn "héllo " + name\n}\n'
+        parsed = add_declaration_ranges(parse_go_source(content), content)
+        self.assertEqual(parsed["symbols"][0]["range"], span(3, 1, 5, 2))
+
+
+class DeclarationRangeTest(unittest.TestCase):
+    """A declaration listed, found as a caller and previewed for a rename has one range."""
+
+    @classmethod
+    def setUpClass(cls):
+        cls.root = Path(tempfile.mkdtemp())
+        (cls.root / "go.mod").write_text("module example.com/app\n", encoding="utf-8")
+        (cls.root / "main.go").write_text(
+            "package main\n"
+            "\n"
+            "// Grüße greets.\n"
+            "func Grüße(name string) string {\n"
+            "\tgreeting := \"héllo \" + name\n"
+            "\treturn greeting\n"
+            "}\n"
+            "\n"
+            "func main() {\n"
+            "\tprintln(Grüße(\"wörld\"))\n"
+            "}\n",
+            encoding="utf-8",
+        )
+        cls.indexer = XRayIndexer(str(cls.root))
+        cls.indexer.reindex(force=True)
+
+    @classmethod
+    def tearDownClass(cls):
+        shutil.rmtree(cls.root, ignore_errors=True)
+
+    def listed(self, name):
+        symbols = self.indexer.present(self.indexer.list_symbols("main.go"))["symbols"]
+        return next(s for s in symbols if s["name"] == name)["range"]
+
+    def test_listed(self):
+        self.assertEqual(self.listed("Grüße"), span(4, 1, 7, 2))
+        self.assertEqual(self.listed("main"), span(9, 1, 11, 2))
+
+    def test_callers_take_the_declaration_range(self):
+        result = self.indexer.present(self.indexer.find_callers("Grüße"))
+        self.assertEqual(result["symbol"]["range"], self.listed("Grüße"))
+        caller = result["callers"][0]
+        self.assertEqual(caller["range"], self.listed("main"))
+        # The call itself is the callee's name, past the tab and before the multibyte argument
+        self.assertEqual(caller["call_site"]["range"], span(10, 10, 10, 17))
+
+    def test_rename_preview_takes_the_declaration_range(self):
+        result = self.indexer.present(self.indexer.rename_preview("Grüße", "Greet"))
+        declared = [v for v in _dicts(result) if v.get("symbol_id") and v.get("name") == "Grüße"]
+        self.assertTrue(declared)
+        for value in declared:
+            self.assertEqual(value["range"], self.listed("Grüße"))
+
+
+def _dicts(value):
+    if isinstance(value, dict):
+        yield value
+        for item in value.values():
+            yield from _dicts(item)
+    elif isinstance(value, list):
+        for item in value:
+            yield from _dicts(item)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,148 @@
+"""House rules against test_samples/test.go: what forbidden and required rules find, and what they leave alone."""
+
+import json
+import shutil
+import tempfile
+import unittest
+from pathlib import Path
+
+from xray.core.go_analysis import GoCallGraph, GoProject
+from xray.core.go_parser import parse_go_source
+from xray.core.go_rules import BUILTIN_RULES, RuleEngine, parse_rules
+from xray.core.indexer import XRayIndexer
+
+SAMPLES = Path(__file__).resolve().parent.parent / "test_samples"
+TEST_GO = (SAMPLES / "test.go").read_text(encoding="utf-8")
+
+
+def engine(source=TEST_GO, directory=str(SAMPLES)):
+    path = str(Path(directory) / "test.go")
+    return RuleEngine(GoCallGraph(GoProject([(path, parse_go_source(source))], directory)))
+
+
+def run(*declared, source=TEST_GO):
+    rules, errors = parse_rules(list(declared))
+    assert not errors, errors
+    return engine(source).run(rules)
+
+
+def found(result):
+    return [(f["rule"], f["line"], f.get("column"), f.get("function")) for f in result["findings"]]
+
+
+class ForbiddenTest(unittest.TestCase):
+
+    def test_call(self):
+        result = run({"id": "no-printf", "call": "fmt.Printf"})
+        self.assertEqual(found(result), [("no-printf", 120, 9, "Logger.Log")])
+        self.assertEqual((result["findings"][0]["match"], result["findings"][0]["message"]),
+                         ("fmt.Printf", "Logger.Log calls fmt.Printf"))
+
+    def test_call_of_a_project_method(self):
+        # logger.Log through the package variable, only in the functions scoped
+        result = run({"id": "no-log", "call": "main.Logger.Log", "functions": ["process*"]})
+        self.assertEqual(found(result), [("no-log", 89, 12, "processUser")])
+
+    def test_import(self):
+        result = run({"id": "no-json", "import": "encoding/json", "message": "use the codec package"})
+        self.assertEqual(found(result), [("no-json", 7, 5, None)])
+        self.assertEqual((result["findings"][0]["message"], result["findings"][0]["detail"]),
+                         ("use the codec package", "imports encoding/json"))
+
+    def test_type_switch_is_not_an_assertion(self):
+        self.assertEqual(found(run({"id": "no-user-assert", "type_assertion": "*User"})), [])
+
+    def test_out_of_scope(self):
+        self.assertEqual(found(run({"id": "no-printf", "call": "fmt.Printf", "exclude_packages": ["main"]})), [])
+        self.assertEqual(found(run({"id": "no-printf", "call": "fmt.Printf", "functions": ["process*"]})), [])
+
+
+class RequiredTest(unittest.TestCase):
+
+    def test_present(self):
+        result = run({"id": "handlers-error", "call": "net/http.Error", "polarity": "required",
+                      "signature": "*http.ResponseWriter*"})
+        self.assertEqual(found(result), [])
+        self.assertEqual(result["rules"][0]["checked"], 1)
+
+    def test_missing(self):
+        result = run({"id": "handlers-auth", "call": "auth.Check", "polarity": "required",
+                      "signature": "*http.ResponseWriter*"})
+        self.assertEqual(found(result), [("handlers-auth", 103, None, "userHandler")])
+        self.assertEqual(result["findings"][0]["message"], "userHandler does not call auth.Check")
+
+    def test_within_the_function_or_through_its_callees(self):
+        rule = {"id": "process-logs", "call": "main.Logger.Log", "polarity": "required", "functions": ["process*"]}
+        # processUsers logs only through processUser
+        direct = run(rule)
+        self.assertEqual(found(direct), [("process-logs", 93, None, "processUsers")])
+        self.assertEqual(direct["rules"][0]["checked"], 2)
+        self.assertEqual(found(run(dict(rule, transitive=True))), [])
+
+    def test_exported_only(self):
+        result = run({"id": "exported-fmt", "call": "fmt.*", "polarity": "required", "exported_only": True})
+        self.assertEqual(found(result), [("exported-fmt", 47, None, "UserService.GetUser"),
+                                         ("exported-fmt", 68, None, "NewUserService")])
+        self.assertEqual(result["rules"][0]["checked"], 5)
+
+    def test_import(self):
+        missing = run({"id": "main-log", "import": "log", "polarity": "required", "packages": "main"})
+        self.assertEqual(found(missing), [("main-log", 1, None, None)])
+        present = run({"id": "main-http", "import": "net/http", "polarity": "required"})
+        self.assertEqual((found(present), present["rules"][0]["checked"]), ([], 1))
+
+
+class BuiltinRulesTest(unittest.TestCase):
+
+    def test_main_may_print(self):
+        result = engine().run(BUILTIN_RULES)
+        self.assertEqual(found(result), [])
+        self.assertEqual([r["id"] for r in result["rules"]],
+                         ["no-unsafe", "no-exit-outside-main", "no-print-outside-main", "test-helpers-call-helper"])
+
+    def test_a_library_may_not(self):
+        library = TEST_GO.replace("package main", "package service", 1)
+        self.assertEqual(found(engine(library).run(BUILTIN_RULES)),
+                         [("no-print-outside-main", 120, 9, "Logger.Log")])
+
+
+class ConfiguredRulesTest(unittest.TestCase):
+    """Rules from the project's configuration, through the indexer."""
+
+    def setUp(self):
+        self.root = Path(tempfile.mkdtemp())
+        shutil.copy(SAMPLES / "test.go", self.root / "test.go")
+        (self.root / ".xray.json").write_text(json.dumps({"rules": [
+            {"id": "no-printf", "call": "fmt.Printf", "severity": "error"},
+            {"id": "handlers-auth", "call": "auth.Check", "polarity": "required", "functions": ["*Handler"]},
+        ]}), encoding="utf-8")
+        self.indexer = XRayIndexer(str(self.root))
+        self.indexer.reindex(force=True)
+
+    def tearDown(self):
+        shutil.rmtree(self.root, ignore_errors=True)
+
+    def test_configured(self):
+        result = self.indexer.run_rules()
+        self.assertEqual([(f["rule"], f["severity"], f["line"]) for f in result["findings"]],
+                         [("handlers-auth", "warning", 103), ("no-printf", "error", 120)])
+        self.assertEqual([(r["id"], r["source"]) for r in result["rules"]],
+                         [("no-printf", "config"), ("handlers-auth", "config")])
+        self.assertEqual(result["message"], "2 findings from 2 of 2 rules: no-printf, handlers-auth")
+
+    def test_only_and_builtin(self):
+        result = self.indexer.run_rules(only=["handlers-auth", "no-unsafe"], builtin=True)
+        self.assertEqual([(r["id"], r["source"]) for r in result["rules"]],
+                         [("handlers-auth", "config"), ("no-unsafe", "builtin")])
+        with self.assertRaises(ValueError):
+            self.indexer.run_rules(only=["no-such-rule"])
+
+    def test_rules_given_replace_the_configured(self):
+        result = self.indexer.run_rules(rules=[{"id": "no-json", "import": "encoding/json"}])
+        self.assertEqual([(f["rule"], f["line"]) for f in result["findings"]], [("no-json", 7)])
+        with self.assertRaises(ValueError):
+            self.indexer.run_rules(rules=[{"id": "both", "call": "a.B", "import": "c"}])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,93 @@
+"""SARIF export against the golden log of the fixture project (tests/fixtures/sarif).
+
+The fixture holds one of each finding. After a deliberate change to the
+output, regenerate the golden file with
+
+    XRAY_UPDATE_GOLDEN=1 python -m unittest tests.test_sarif
+"""
+
+import json
+import os
+import shutil
+import tempfile
+import unittest
+from pathlib import Path
+
+from xray.core.indexer import XRayIndexer
+from xray.core.sarif import RULES, SCHEMA
+
+FIXTURES = Path(__file__).resolve().parent / "fixtures"
+GOLDEN = FIXTURES / "findings.sarif"
+
+# The finding tools, each giving one run of the golden log
+TOOLS = [
+    ("find_unused", lambda indexer: indexer.find_unused(format="sarif")),
+    ("find_cycles", lambda indexer: indexer.find_cycles(format="sarif")),
+    ("global_usages", lambda indexer: indexer.global_usages("hits", format="sarif")),
+    ("scan_secrets", lambda indexer: indexer.scan_secrets(blame=False, format="sarif")),
+    ("format_strings", lambda indexer: indexer.format_strings(format="sarif")),
+    ("run_rules", lambda indexer: indexer.run_rules(format="sarif")),
+]
+
+
+def _normalized(log):
+    """A log without what changes between checkouts and releases: the root's URI and the version."""
+    log = json.loads(json.dumps(log))
+    for run in log["runs"]:
+        run["tool"]["driver"]["version"] = "VERSION"
+        run["originalUriBaseIds"]["%SRCROOT%"]["uri"] = "file:///SRCROOT/"
+    return log
+
+
+class GoldenSarifTest(unittest.TestCase):
+
+    @classmethod
+    def setUpClass(cls):
+        # A copy, so the index cache and the git repository around the checkout stay out of it
+        cls.tmp = Path(tempfile.mkdtemp())
+        cls.root = cls.tmp / "sarif"
+        shutil.copytree(FIXTURES / "sarif", cls.root)
+        indexer = XRayIndexer(str(cls.root))
+        indexer.reindex(force=True)
+        cls.logs = {name: _normalized(run(indexer)) for name, run in TOOLS}
+        cls.combined = {"$schema": SCHEMA, "version": "2.1.0",
+                        "runs": [dict(cls.logs[name]["runs"][0], automationDetails={"id": f"xray/{name}/"})
+                                 for name, _ in TOOLS]}
+        if os.environ.get("XRAY_UPDATE_GOLDEN"):
+            GOLDEN.write_text(json.dumps(cls.combined, indent=2, sort_keys=True) + "\n", encoding="utf-8")
+
+    @classmethod
+    def tearDownClass(cls):
+        shutil.rmtree(cls.tmp, ignore_errors=True)
+
+    def test_matches_the_golden_log(self):
+        golden = json.loads(GOLDEN.read_text(encoding="utf-8"))
+        self.assertEqual(len(golden["runs"]), len(TOOLS))
+        for expected, actual in zip(golden["runs"], self.combined["runs"]):
+            with self.subTest(run=expected["automationDetails"]["id"]):
+                self.assertEqual(actual, expected)
+
+    def test_each_log_is_sarif_2_1_0(self):
+        for name, log in self.logs.items():
+            with self.subTest(name):
+                self.assertEqual((log["$schema"], log["version"], len(log["runs"])), (SCHEMA, "2.1.0", 1))
+                self.assertEqual(log["runs"][0]["tool"]["driver"]["rules"], RULES)
+
+    def test_every_rule_is_exercised(self):
+        fired = {r["ruleId"] for log in self.logs.values() for r in log["runs"][0]["results"]}
+        self.assertEqual(fired, {rule["id"] for rule in RULES})
+
+    def test_results_point_at_their_rule_with_a_region(self):
+        for name, log in self.logs.items():
+            for result in log["runs"][0]["results"]:
+                with self.subTest(name, rule=result["ruleId"]):
+                    self.assertEqual(RULES[result["ruleIndex"]]["id"], result["ruleId"])
+                    location = result["locations"][0]["physicalLocation"]
+                    self.assertEqual(location["artifactLocation"]["uriBaseId"], "%SRCROOT%")
+                    self.assertFalse(os.path.isabs(location["artifactLocation"]["uri"]))
+                    self.assertGreater(location["region"]["startLine"], 0)
+                    self.assertIn("xrayFinding/v1", result["partialFingerprints"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,197 @@
+"""The tools' result shapes against the golden file (src/xray/schemas), the gate for schemaVersion bumps."""
+
+import asyncio
+import importlib.util
+import inspect
+import json
+import shutil
+import tempfile
+import unittest
+from pathlib import Path
+
+from xray.core.schema import DEPRECATED_FIELDS, PRESENTING_TOOLS, RENAMES, SCHEMA_VERSION, compare, golden, golden_path, parse_version, \
+    renamed_fields, with_deprecated
+
+HAS_FASTMCP = importlib.util.find_spec("fastmcp") is not None
+
+
+@unittest.skipUnless(HAS_FASTMCP, "the MCP server needs fastmcp")
+class GoldenSchemaTest(unittest.TestCase):
+
+    @classmethod
+    def setUpClass(cls):
+        from xray import mcp_server
+        from xray.core.schema import tool_schema
+        cls.server = mcp_server
+        cls.names = mcp_server._tool_names()
+        cls.current = golden([tool_schema(name, mcp_server._tool_function(name)) for name in cls.names])
+        cls.frozen = json.loads(golden_path().read_text(encoding="utf-8"))
+
+    def test_registry_lists_the_tools(self):
+        self.assertIn("get_schema", self.names)
+        self.assertIn("find_symbol", self.names)
+        self.assertNotIn("inspect", self.names)
+
+    def test_golden_file_is_at_the_current_version(self):
+        self.assertEqual(self.frozen["schemaVersion"], SCHEMA_VERSION,
+                         "SCHEMA_VERSION was bumped: run 'git-project-xray-mcp schema --write'")
+
+    def test_every_tool_matches_the_golden_file(self):
+        result = compare(self.frozen, self.current)
+        self.assertEqual(result["problems"], [])
+        drift = result["added"] + result["removed"] + result["retyped"]
+        self.assertEqual(drift, [], "tool shapes changed: bump SCHEMA_VERSION in xray/core/schema.py, "
+                                    "then run 'git-project-xray-mcp schema --write'")
+
+    def test_get_schema_describes_one_tool(self):
+        result = asyncio.run(self.server._tool_function("get_schema")("list_projects"))
+        self.assertEqual(result["schemaVersion"], SCHEMA_VERSION)
+        self.assertEqual([t["name"] for t in result["tools"]], ["list_projects"])
+        self.assertIn("projects", result["tools"][0]["output_schema"]["properties"])
+
+    def test_version_under_both_names(self):
+        result = asyncio.run(self.server._tool_function("get_schema")("list_projects"))
+        self.assertEqual((result["schemaVersion"], result["schema_version"]), (SCHEMA_VERSION, SCHEMA_VERSION))
+        described = result["tools"][0]
+        self.assertEqual(described["schema_version"], described["schemaVersion"])
+        self.assertEqual(described["deprecated_fields"], described["deprecatedFields"])
+        self.assertEqual(described["deprecatedFields"][0]["replaced_by"], "schemaVersion")
+        version = described["output_schema"]["properties"]["schema_version"]
+        self.assertTrue(version["deprecated"])
+        self.assertNotIn("deprecated", described["output_schema"]["properties"]["schemaVersion"])
+
+    def test_unknown_tool_is_an_error_with_the_version(self):
+        result = asyncio.run(self.server._tool_function("get_schema")("no_such_tool"))
+        self.assertEqual(result["error"]["code"], "INVALID_ARGUMENT")
+        self.assertEqual((result["schemaVersion"], result["schema_version"]), (SCHEMA_VERSION, SCHEMA_VERSION))
+
+
+class RenamedFieldTest(unittest.TestCase):
+    """1.2's camelCase renames, still returned and documented under the old names until 1.3."""
+
+    def test_old_names_are_copies_at_any_depth(self):
+        result = with_deprecated("list_symbols", {
+            "symbols": [{"name": "Map", "typeParams": [{"name": "T", "constraint": "any"}]}, {"name": "M"}],
+            "files": {"a.go": {"typeParams": []}}})
+        self.assertEqual(result["symbols"][0]["type_params"], [{"name": "T", "constraint": "any"}])
+        self.assertEqual(result["files"]["a.go"]["type_params"], [])
+        self.assertNotIn("type_params", result["symbols"][1])
+
+    def test_only_the_renames_of_the_tool(self):
+        value = {"symbols": [{"name": "Map", "typeParams": []}], "parseErrors": []}
+        self.assertEqual(with_deprecated("find_tests_for", value), value)
+        self.assertIs(with_deprecated("list_projects", value), value)
+
+    def test_every_rename_is_listed_per_tool(self):
+        for old, new, _, _, tools in RENAMES:
+            for tool in tools:
+                with self.subTest(tool=tool, field=old):
+                    self.assertEqual(renamed_fields(tool).get(new), old)
+
+    @unittest.skipUnless(HAS_FASTMCP, "the MCP server needs fastmcp")
+    def test_presenting_tools_are_listed(self):
+        from xray import mcp_server
+        presenting = [name for name in mcp_server._tool_names()
+                      if any(call in inspect.getsource(mcp_server._tool_function(name))
+                             for call in ("present=", "_paged(", ".present("))]
+        self.assertEqual(sorted(presenting), sorted(PRESENTING_TOOLS))
+
+    def test_deprecations_run_a_minor_version(self):
+        for tool, entries in DEPRECATED_FIELDS.items():
+            for entry in entries:
+                with self.subTest(tool=tool, field=entry["field"]):
+                    self.assertLessEqual(parse_version(entry["deprecated_in"]), parse_version(SCHEMA_VERSION))
+                    self.assertLess(parse_version(SCHEMA_VERSION), parse_version(entry["removed_in"]))
+
+    @unittest.skipUnless(HAS_FASTMCP, "the MCP server needs fastmcp")
+    def test_schema_marks_the_old_name(self):
+        from xray import mcp_server
+        from xray.core.schema import tool_schema
+        schema = tool_schema("list_symbols", mcp_server._tool_function("list_symbols"))
+        symbol = schema["output_schema"]["properties"]["symbols"]["items"]["properties"]
+        self.assertTrue(symbol["type_params"]["deprecated"])
+        self.assertIn("use **.typeParams", symbol["type_params"]["description"])
+        self.assertNotIn("deprecated", symbol["typeParams"])
+        self.assertEqual([e["removed_in"] for e in schema["deprecatedFields"]], ["1.3"] * len(schema["deprecatedFields"]))
+
+    @unittest.skipUnless(HAS_FASTMCP, "the MCP server needs fastmcp")
+    def test_tools_return_both_names(self):
+        from xray import mcp_server
+        root = tempfile.mkdtemp()
+        self.addCleanup(shutil.rmtree, root, ignore_errors=True)
+        Path(root, "m.go").write_text("package m\n\nfunc Map[T any](xs []T) []T { return xs }\n", encoding="utf-8")
+        self.addCleanup(mcp_server._indexer_cache.pop, str(Path(root).resolve()), None)
+        result = asyncio.run(mcp_server._tool_function("list_symbols")(root_path=root, path="m.go"))
+        symbol = result["symbols"][0]
+        self.assertEqual(symbol["typeParams"], [{"name": "T", "constraint": "any"}])
+        self.assertEqual(symbol["type_params"], symbol["typeParams"])
+
+    @unittest.skipUnless(HAS_FASTMCP, "the MCP server needs fastmcp")
+    def test_file_fields_under_both_names(self):
+        from xray import mcp_server
+        root = tempfile.mkdtemp()
+        self.addCleanup(shutil.rmtree, root, ignore_errors=True)
+        Path(root, "m_linux.go").write_bytes(b"package m\n\n// Caf\xe9\nfunc Open() {}\n")
+        self.addCleanup(mcp_server._indexer_cache.pop, str(Path(root).resolve()), None)
+        symbol = asyncio.run(mcp_server._tool_function("list_symbols")(root_path=root, path="m_linux.go"))["symbols"][0]
+        self.assertEqual((symbol["buildConstraints"], symbol["transcodedFrom"]), ("linux", "latin-1"))
+        self.assertEqual((symbol["build_constraints"], symbol["transcoded_from"]), ("linux", "latin-1"))
+
+    @unittest.skipUnless(HAS_FASTMCP, "the MCP server needs fastmcp")
+    def test_parse_problems_under_both_names(self):
+        from xray import mcp_server
+        root = tempfile.mkdtemp()
+        self.addCleanup(shutil.rmtree, root, ignore_errors=True)
+        Path(root, "m.go").write_text("package m\n\nfunc Broken( {\n", encoding="utf-8")
+        Path(root, "t.go").write_text('package m\n\ntype T struct {\n\tA int `json:"a`\n}\n', encoding="utf-8")
+        Path(root, "m.py").write_text("import os\ndef broken(:\n", encoding="utf-8")
+        self.addCleanup(mcp_server._indexer_cache.pop, str(Path(root).resolve()), None)
+        listed = asyncio.run(mcp_server._tool_function("list_symbols")(root_path=root, path="m.go"))
+        self.assertTrue(listed["parseErrors"])
+        self.assertEqual(listed["parse_errors"], listed["parseErrors"])
+        field = asyncio.run(mcp_server._tool_function("list_symbols")(root_path=root, path="t.go"))["symbols"][1]
+        self.assertEqual((field["name"], field["parseError"], field["tag_parse_error"]), ("A", True, True))
+        imports = asyncio.run(mcp_server._tool_function("file_dependencies")(root_path=root, path="m.py"))
+        self.assertEqual(imports["parseError"]["line"], 2)
+        self.assertEqual(imports["parse_error"], imports["parseError"])
+
+
+class CompareTest(unittest.TestCase):
+    """compare() on hand-made shapes, without the server."""
+
+    def frozen(self, output):
+        return {"schemaVersion": SCHEMA_VERSION, "tools": {"t": {"input": {}, "output": output}}}
+
+    def current(self, output):
+        return {"schemaVersion": SCHEMA_VERSION, "tools": {"t": {"input": {}, "output": output}}}
+
+    def test_unchanged_shape_is_ok(self):
+        self.assertTrue(compare(self.frozen({"a": "string"}), self.current({"a": "string"}))["ok"])
+
+    def test_added_field_needs_a_version_bump(self):
+        result = compare(self.frozen({"a": "string"}), self.current({"a": "string", "b": "integer"}))
+        self.assertEqual(result["added"], [{"tool": "t", "output": "b"}])
+        self.assertFalse(result["ok"])
+
+    def test_removed_field_needs_a_major_bump(self):
+        major, minor = parse_version(SCHEMA_VERSION)
+        frozen = {"schemaVersion": f"{major}.{max(0, minor - 1)}" if minor else SCHEMA_VERSION,
+                  "tools": {"t": {"input": {}, "output": {"a": "string", "b": "integer"}}}}
+        result = compare(frozen, self.current({"a": "string"}))
+        self.assertEqual(result["removed"], [{"tool": "t", "output": "b"}])
+        self.assertTrue(any("'b' removed" in p for p in result["problems"]))
+
+    def test_golden_file_of_the_old_name(self):
+        frozen = {"schema_version": SCHEMA_VERSION, "tools": {"t": {"input": {}, "output": {"a": "string"}}}}
+        result = compare(frozen, self.current({"a": "string", "b": "integer"}))
+        self.assertEqual(result["goldenVersion"], SCHEMA_VERSION)
+        self.assertFalse(result["ok"])
+
+    def test_retyped_field_is_a_problem(self):
+        result = compare(self.frozen({"a": "string"}), self.current({"a": "integer"}))
+        self.assertEqual(len(result["retyped"]), 1)
+        self.assertFalse(result["ok"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,187 @@
+"""SCIP export: the index written to disk decodes back to the symbols and occurrences it was built from."""
+
+import shutil
+import tempfile
+import unittest
+from pathlib import Path
+from unittest import mock
+
+from xray import __version__
+from xray.core import indexer as indexer_module
+from xray.core.indexer import XRayIndexer
+
+SAMPLES = Path(__file__).resolve().parent.parent / "test_samples"
+
+
+# A protobuf wire format reader of its own, so the test does not share the encoder's mistakes
+
+def _varint(data, pos):
+    value = shift = 0
+    while True:
+        byte = data[pos]
+        pos += 1
+        value |= (byte & 0x7F) << shift
+        shift += 7
+        if not byte & 0x80:
+            return value, pos
+
+
+def _fields(data):
+    """The (field number, value) pairs of a message: ints for varints, bytes for length-delimited fields."""
+    pos, out = 0, []
+    while pos < len(data):
+        key, pos = _varint(data, pos)
+        field, wire_type = key >> 3, key & 7
+        if wire_type == 0:
+            value, pos = _varint(data, pos)
+        elif wire_type == 2:
+            length, pos = _varint(data, pos)
+            value, pos = data[pos:pos + length], pos + length
+        else:
+            raise AssertionError(f"unexpected wire type {wire_type} for field {field}")
+        out.append((field, value))
+    return out
+
+
+def _packed(data):
+    pos, values = 0, []
+    while pos < len(data):
+        value, pos = _varint(data, pos)
+        values.append(value)
+    return values
+
+
+def _symbol_information(data):
+    info = {"documentation": [], "relationships": [], "kind": 0}
+    for field, value in _fields(data):
+        if field == 1:
+            info["symbol"] = value.decode()
+        elif field == 3:
+            info["documentation"].append(value.decode())
+        elif field == 4:
+            rel = {"symbol": None, "is_reference": False, "is_implementation": False, "is_type_definition": False}
+            names = {1: "symbol", 2: "is_reference", 3: "is_implementation", 4: "is_type_definition"}
+            for f, v in _fields(value):
+                rel[names[f]] = v.decode() if f == 1 else bool(v)
+            info["relationships"].append(rel)
+        elif field == 5:
+            info["kind"] = value
+        elif field == 6:
+            info["display_name"] = value.decode()
+        elif field == 8:
+            info["enclosing_symbol"] = value.decode()
+    return info
+
+
+def decode(data):
+    index = {"documents": [], "external_symbols": []}
+    for field, value in _fields(data):
+        if field == 1:
+            meta = dict(_fields(value))
+            tool = dict(_fields(meta[2]))
+            index["metadata"] = {"project_root": meta[3].decode(), "tool": (tool[1].decode(), tool[2].decode()),
+                                 "text_document_encoding": meta.get(4, 0)}
+        elif field == 2:
+            doc = {"occurrences": [], "symbols": []}
+            for f, v in _fields(value):
+                if f == 1:
+                    doc["relative_path"] = v.decode()
+                elif f == 2:
+                    occurrence = {"symbol_roles": 0}
+                    for of, ov in _fields(v):
+                        occurrence[{1: "range", 2: "symbol", 3: "symbol_roles"}[of]] = (
+                            _packed(ov) if of == 1 else ov.decode() if of == 2 else ov)
+                    doc["occurrences"].append(occurrence)
+                elif f == 3:
+                    doc["symbols"].append(_symbol_information(v))
+                elif f == 4:
+                    doc["language"] = v.decode()
+                elif f == 6:
+                    doc["position_encoding"] = v
+            index["documents"].append(doc)
+        elif field == 3:
+            index["external_symbols"].append(_symbol_information(value))
+    return index
+
+
+def _with_defaults(info):
+    """A symbol as it decodes: proto3 has no absent repeated fields or false booleans."""
+    out = dict(info, documentation=info.get("documentation", []), kind=info.get("kind", 0))
+    out["relationships"] = [{"symbol": r["symbol"], "is_reference": bool(r.get("is_reference")),
+                             "is_implementation": bool(r.get("is_implementation")),
+                             "is_type_definition": bool(r.get("is_type_definition"))}
+                            for r in info.get("relationships", [])]
+    return out
+
+
+class RoundTripTest(unittest.TestCase):
+
+    @classmethod
+    def setUpClass(cls):
+        cls.root = Path(tempfile.mkdtemp()) / "samples"
+        shutil.copytree(SAMPLES, cls.root)
+        # An interface UserService satisfies, for is_implementation relationships
+        (cls.root / "stringer.go").write_text("package main\n\ntype Stringer interface{ String() string }\n",
+                                              encoding="utf-8")
+        indexer = XRayIndexer(str(cls.root))
+        indexer.reindex(force=True)
+        built = []
+        encode = indexer_module.encode_scip
+
+        def capture(index):
+            built.append(index)
+            return encode(index)
+
+        with mock.patch.object(indexer_module, "encode_scip", capture):
+            cls.result = indexer.export_scip(version="v1.0.0")
+        cls.built = built[0]
+        cls.decoded = decode(Path(cls.result["path"]).read_bytes())
+
+    @classmethod
+    def tearDownClass(cls):
+        shutil.rmtree(cls.root.parent, ignore_errors=True)
+
+    def test_the_samples_give_an_index_worth_checking(self):
+        self.assertGreater(self.result["document_count"], 1)
+        self.assertGreater(self.result["occurrence_count"], self.result["symbol_count"])
+        self.assertTrue(any(info.get("relationships") for doc in self.built["documents"] for info in doc["symbols"]))
+
+    def test_metadata(self):
+        self.assertEqual(self.decoded["metadata"], {"project_root": self.root.as_uri(), "tool": ("xray", __version__),
+                                                    "text_document_encoding": 1})
+
+    def test_documents(self):
+        self.assertEqual([d["relative_path"] for d in self.decoded["documents"]],
+                         [d["relative_path"] for d in self.built["documents"]])
+        for doc in self.decoded["documents"]:
+            self.assertEqual((doc["language"], doc["position_encoding"]), ("go", 3))
+
+    def test_occurrences(self):
+        for built, decoded in zip(self.built["documents"], self.decoded["documents"]):
+            with self.subTest(path=built["relative_path"]):
+                self.assertEqual(decoded["occurrences"], built["occurrences"])
+
+    def test_symbols(self):
+        for built, decoded in zip(self.built["documents"], self.decoded["documents"]):
+            with self.subTest(path=built["relative_path"]):
+                self.assertEqual(decoded["symbols"], [_with_defaults(info) for info in built["symbols"]])
+
+    def test_external_symbols(self):
+        self.assertTrue(self.built["external_symbols"])
+        self.assertEqual(self.decoded["external_symbols"], [_with_defaults(i) for i in self.built["external_symbols"]])
+
+    def test_implementation_relationship(self):
+        doc = next(d for d in self.decoded["documents"] if d["relative_path"] == "test.go")
+        method = next(s for s in doc["symbols"] if s["symbol"].endswith(" samples/UserService#String()."))
+        self.assertEqual((method["kind"], method["display_name"]), (26, "String"))
+        self.assertEqual(method["relationships"], [{"symbol": "scip-go gomod samples v1.0.0 samples/Stringer#String().",
+                                                    "is_reference": False, "is_implementation": True,
+                                                    "is_type_definition": False}])
+
+    def test_counts(self):
+        self.assertEqual(self.result["occurrence_count"], sum(len(d["occurrences"]) for d in self.decoded["documents"]))
+        self.assertEqual(self.result["symbol_count"], sum(len(d["symbols"]) for d in self.decoded["documents"]))
+
+
+if __name__ == "__main__":
+    unittest.main()
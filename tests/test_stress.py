@@ -0,0 +1,160 @@
+"""Queries hammering a project while it is re-indexed: every result whole, from one version of each file.
+
+Python has no race detector; the test instead runs long enough, with
+enough threads, to hit the interleavings a race would show up in - an
+error from a dict changing size mid-iteration, or a file's symbols from
+two versions of it in one result.
+"""
+
+import asyncio
+import importlib.util
+import os
+import re
+import shutil
+import tempfile
+import threading
+import time
+import unittest
+from pathlib import Path
+
+HAS_FASTMCP = importlib.util.find_spec("fastmcp") is not None
+
+PACKAGES = 12
+READERS = 6
+# Seconds of hammering
+DURATION = float(os.environ.get("XRAY_STRESS_SECONDS", "4"))
+
+
+def source(i, generation):
+    """A file whose every declaration and call names its generation, so a result mixing two is plain."""
+    return (f"package p{i}\n\n"
+            f"const Generation{generation} = {generation}\n\n"
+            f"func Make{generation}() int {{ return Generation{generation} }}\n\n"
+            f"func Use() int {{ return Make{generation}() + Make{generation}() }}\n")
+
+
+def write(path, content):
+    # Replaced, never truncated: a file is only ever seen whole
+    tmp = path.with_suffix(".tmp")
+    tmp.write_text(content, encoding="utf-8")
+    os.replace(tmp, path)
+
+
+@unittest.skipUnless(HAS_FASTMCP, "the MCP server needs fastmcp")
+class QueriesWhileReindexingTest(unittest.TestCase):
+
+    @classmethod
+    def setUpClass(cls):
+        from xray import mcp_server
+        cls.server = mcp_server
+        cls.root = Path(tempfile.mkdtemp()).resolve()
+        (cls.root / "go.mod").write_text("module example.com/stress\n", encoding="utf-8")
+        for i in range(PACKAGES):
+            (cls.root / f"p{i}").mkdir()
+            write(cls.root / f"p{i}" / "f.go", source(i, 0))
+        cls.indexer = mcp_server.get_indexer(str(cls.root))
+
+    @classmethod
+    def tearDownClass(cls):
+        cls.server._indexer_cache.pop(str(cls.root), None)
+        shutil.rmtree(cls.root, ignore_errors=True)
+
+    def call(self, tool, **arguments):
+        return asyncio.run(self.server._tool_function(tool)(root_path=str(self.root), **arguments))
+
+    def test_hammer(self):
+        stop = threading.Event()
+        failures = []
+        counts = {"queries": 0, "generations": 0}
+        lock = threading.Lock()
+
+        def fail(message):
+            with lock:
+                failures.append(message)
+            stop.set()
+
+        def writer():
+            # Rewrite the files and re-index, alternately as the watcher does and through the tool
+            generation = 0
+            try:
+                while not stop.is_set():
+                    generation += 1
+                    for i in range(PACKAGES):
+                        write(self.root / f"p{i}" / "f.go", source(i, generation))
+                    if generation % 2:
+                        with self.server._index_lock(self.indexer), self.indexer.tracking():
+                            self.indexer.refresh()
+                    else:
+                        result = self.call("reindex")
+                        if "error" in result:
+                            fail(f"reindex: {result['error']}")
+                    counts["generations"] = generation
+            except Exception as e:
+                fail(f"writer: {type(e).__name__}: {e}")
+
+        def generations(text):
+            return set(re.findall(r"(?:Generation|Make)(\d+)", text))
+
+        def reader(k):
+            queries = [
+                lambda i: ("list_symbols", {"path": f"p{i}/f.go"}),
+                lambda i: ("find_symbol", {"query": "Make", "limit": 100}),
+                lambda i: ("find_callers", {"symbol": f"p{i}.Use", "depth": 1}),
+                lambda i: ("find_callees", {"symbol": f"p{i}.Use"}),
+                lambda i: ("get_symbol_source", {"symbol": f"p{i}.Use"}),
+            ]
+            n = 0
+            try:
+                while not stop.is_set():
+                    n += 1
+                    tool, arguments = queries[(k + n) % len(queries)]((k * 7 + n) % PACKAGES)
+                    result = self.call(tool, **arguments)
+                    if "error" in result:
+                        fail(f"{tool} {arguments}: {result['error']}")
+                        return
+                    self.check(tool, result, generations, fail)
+                    with lock:
+                        counts["queries"] += 1
+            except Exception as e:
+                fail(f"reader {k}: {type(e).__name__}: {e}")
+
+        threads = [threading.Thread(target=writer)] + [threading.Thread(target=reader, args=(k,))
+                                                       for k in range(READERS)]
+        for thread in threads:
+            thread.start()
+        deadline = time.monotonic() + DURATION
+        while time.monotonic() < deadline and not stop.is_set():
+            time.sleep(0.05)
+        stop.set()
+        for thread in threads:
+            thread.join(60)
+        self.assertEqual(failures, [])
+        self.assertGreater(counts["generations"], 2)
+        self.assertGreater(counts["queries"], 20)
+
+    def check(self, tool, result, generations, fail):
+        """One version of each file per result, and the symbols it lists all of one version."""
+        by_file = {}
+        for symbol in result.get("symbols", []):
+            found = generations(symbol["name"])
+            if found:
+                by_file.setdefault(symbol["path"], set()).update(found)
+        mixed = {path: g for path, g in by_file.items() if len(g) > 1}
+        if mixed:
+            fail(f"{tool}: symbols of several versions of one file: {mixed}")
+        if tool == "list_symbols":
+            names = sorted(s["name"] for s in result["symbols"])
+            if len(names) != 3 or len(generations(" ".join(names))) != 1:
+                fail(f"list_symbols: not one whole version: {names}")
+        if tool == "find_callees":
+            found = generations(" ".join(c.get("name", "") for c in result.get("callees", [])))
+            if len(found) > 1:
+                fail(f"find_callees: callees of several versions: {found}")
+        if tool == "get_symbol_source":
+            found = generations(result.get("source", ""))
+            if len(found) != 1:
+                fail(f"get_symbol_source: not one version: {result.get('source')}")
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,168 @@
+"""Symbol IDs: parsed back for every indexed language, resolved by the tools taking a symbol."""
+
+import shutil
+import tempfile
+import unittest
+from pathlib import Path
+from typing import Dict
+
+from xray.core.indexer import XRayIndexer
+from xray.core.languages import NATIVE_LANGUAGES
+from xray.core.symbol_ids import parse_symbol_id, symbol_id
+
+
+class ParseSymbolIdTest(unittest.TestCase):
+
+    def test_every_indexed_language_parses(self):
+        for language in NATIVE_LANGUAGES:
+            with self.subTest(language=language):
+                parsed = parse_symbol_id(symbol_id(language, "src/a", "T.m", "method", "m()"))
+                self.assertIsNotNone(parsed)
+                self.assertEqual((parsed.language, parsed.scope, parsed.name), (language, "src/a", "T.m"))
+
+    def test_java_id(self):
+        parsed = parse_symbol_id("java:src/com/x/Foo.java:Foo.bar:24c45ce2")
+        self.assertEqual(tuple(parsed), ("java", "src/com/x/Foo.java", "Foo.bar", "24c45ce2"))
+
+    def test_not_an_id(self):
+        for value in ("Foo.bar", "cobol:src/a.cbl:PARA:24c45ce2", "go:pkg:Name:xyz", "go::Name:24c45ce2"):
+            with self.subTest(value=value):
+                self.assertIsNone(parse_symbol_id(value))
+
+
+class RoundTrip:
+    """Mixed into a TestCase: a project written from FILES (path -> content), indexed once for the class."""
+
+    FILES: Dict[str, str] = {}
+
+    @classmethod
+    def setUpClass(cls):
+        cls.root = Path(tempfile.mkdtemp())
+        for path, content in cls.FILES.items():
+            (cls.root / path).parent.mkdir(parents=True, exist_ok=True)
+            (cls.root / path).write_text(content, encoding="utf-8")
+        cls.indexer = XRayIndexer(str(cls.root))
+        cls.indexer.reindex(force=True)
+
+    @classmethod
+    def tearDownClass(cls):
+        shutil.rmtree(cls.root, ignore_errors=True)
+
+    def assert_round_trip(self, query: str, expected_id: str, qualified: str, path: str, line: int):
+        """The ID a search hands out for query names the declaration it came from."""
+        found = [s for s in self.indexer.present(self.indexer.search_symbols(query))
+                 if s["qualified_name"] == qualified]
+        self.assertEqual(len(found), 1, found)
+        self.assertEqual(found[0]["symbol_id"], expected_id)
+        source = self.indexer.get_symbol_source(found[0]["symbol_id"])
+        self.assertEqual((source["name"], source["path"], source["start_line"]),
+                         (qualified, str(self.root / path), line))
+
+
+class JavaRoundTripTest(RoundTrip, unittest.TestCase):
+
+    FILES = {
+        "src/com/x/Foo.java": (
+            "package com.x;\n"
+            "\n"
+            "public class Foo {\n"
+            "    public int bar(String s) { return 1; }\n"
+            "\n"
+            "    static class Inner {\n"
+            "        void run() {}\n"
+            "    }\n"
+            "}\n"
+        ),
+    }
+
+    def test_class(self):
+        self.assert_round_trip("Foo", symbol_id("java", "src/com/x/Foo.java", "Foo", "class", "public class Foo"),
+                               "Foo", "src/com/x/Foo.java", 3)
+
+    def test_method(self):
+        expected = symbol_id("java", "src/com/x/Foo.java", "Foo.bar", "method", "public int bar(String s)")
+        self.assert_round_trip("bar", expected, "Foo.bar", "src/com/x/Foo.java", 4)
+
+    def test_inner_class_method(self):
+        expected = symbol_id("java", "src/com/x/Foo.java", "Foo.Inner.run", "method", "void run()")
+        self.assert_round_trip("run", expected, "Foo.Inner.run", "src/com/x/Foo.java", 7)
+
+
+class SqlRoundTripTest(RoundTrip, unittest.TestCase):
+
+    FILES = {
+        "db/migrations/001_users.sql": (
+            "CREATE TABLE users (\n"
+            "  id INT PRIMARY KEY,\n"
+            "  email TEXT\n"
+            ");\n"
+            "\n"
+            "CREATE INDEX users_email ON users (email);\n"
+        ),
+        "db/migrations/002_name.sql": "ALTER TABLE users ADD COLUMN name TEXT;\n",
+    }
+
+    def test_table(self):
+        expected = symbol_id("sql", "db/migrations/001_users.sql", "users", "table", "CREATE TABLE users")
+        self.assert_round_trip("users", expected, "users", "db/migrations/001_users.sql", 1)
+
+    def test_column(self):
+        expected = symbol_id("sql", "db/migrations/001_users.sql", "users.email", "column", "email TEXT")
+        self.assert_round_trip("email", expected, "users.email", "db/migrations/001_users.sql", 3)
+
+    def test_column_added_by_a_later_migration(self):
+        expected = symbol_id("sql", "db/migrations/002_name.sql", "users.name", "column", "name TEXT")
+        self.assert_round_trip("name", expected, "users.name", "db/migrations/002_name.sql", 1)
+
+
+class TaskRoundTripTest(RoundTrip, unittest.TestCase):
+
+    FILES = {
+        "Makefile": (
+            ".PHONY: build gen\n"
+            "\n"
+            "build: gen\n"
+            "\tgo build ./...\n"
+            "\n"
+            "gen:\n"
+            "\t./scripts/gen.sh\n"
+        ),
+        "scripts/gen.sh": (
+            "#!/bin/sh\n"
+            "\n"
+            "generate() {\n"
+            "  echo generated\n"
+            "}\n"
+            "\n"
+            "generate\n"
+        ),
+    }
+
+    def test_make_target(self):
+        self.assert_round_trip("build", symbol_id("make", "Makefile", "build", "target", "build: gen"),
+                               "build", "Makefile", 3)
+
+    def test_shell_function(self):
+        expected = symbol_id("shell", "scripts/gen.sh", "generate", "function", "generate() {")
+        self.assert_round_trip("generate", expected, "generate", "scripts/gen.sh", 3)
+
+
+class DeclarationRangeTest(RoundTrip, unittest.TestCase):
+    """Locations on a declaration's line take its range only when they are the declaration."""
+
+    FILES = {"test.go": (Path(__file__).resolve().parent.parent / "test_samples" / "test.go").read_text(encoding="utf-8")}
+
+    def test_a_literal_keeps_its_own_range(self):
+        literal = self.indexer.present(self.indexer.find_literals("100"))["literals"][0]
+        # MaxUsers = 100: the literal, not the MaxUsers declaration at columns 5-19
+        self.assertEqual((literal["name"], literal["line"], literal["column"]), ("MaxUsers", 30, 16))
+        self.assertEqual(literal["range"], {"startLine": 30, "startColumn": 16, "endLine": 30, "endColumn": 19})
+        self.assertTrue(literal["symbol_id"].startswith("go:.:MaxUsers:"))
+
+    def test_the_declaration_takes_it(self):
+        listed = {s["name"]: s for s in self.indexer.present(self.indexer.list_symbols("test.go"))["symbols"]}
+        self.assertEqual(listed["MaxUsers"]["range"], {"startLine": 30, "startColumn": 5, "endLine": 30, "endColumn": 19})
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,63 @@
+"""Dead code: which entry points are exempt, and where an unexported name's mentions count."""
+
+import shutil
+import tempfile
+import unittest
+from pathlib import Path
+
+from xray.core.indexer import XRayIndexer
+
+FILES = {
+    "go.mod": "module example.com/dead\n",
+    # main and init of package main: the program's entry points
+    "cmd/app/main.go": "package main\n\nimport \"example.com/dead/a\"\n\n"
+                       "func init() {}\n\nfunc main() { a.Run() }\n",
+    # A main outside package main is an ordinary function
+    "a/a.go": "package a\n\nfunc init() {}\n\nfunc main() {}\n\n"
+              "func helper() int { return 1 }\n\nfunc Run() {}\n",
+    # Another package's helper, used there: a mention of the name, not of a.helper
+    "b/b.go": "package b\n\nfunc helper() int { return 2 }\n\nfunc Answer() int { return helper() }\n",
+    # Exported and mentioned from another package: used
+    "c/c.go": "package c\n\nimport \"example.com/dead/a\"\n\nfunc Start() { a.Run() }\n\nconst limit = 3\n",
+    # The same unexported name used in a second file of its package: used
+    "c/d.go": "package c\n\nvar doubled = limit * 2\n",
+}
+
+
+class UnusedTest(unittest.TestCase):
+
+    @classmethod
+    def setUpClass(cls):
+        cls.root = Path(tempfile.mkdtemp())
+        for name, content in FILES.items():
+            (cls.root / name).parent.mkdir(parents=True, exist_ok=True)
+            (cls.root / name).write_text(content, encoding="utf-8")
+        cls.indexer = XRayIndexer(str(cls.root))
+        cls.indexer.reindex(force=True)
+        result = cls.indexer.find_unused()
+        cls.unused = {(Path(f["path"]).relative_to(cls.root).as_posix(), u["name"]): u["confidence"]
+                      for f in result["files"] for u in f["unused"]}
+
+    @classmethod
+    def tearDownClass(cls):
+        shutil.rmtree(cls.root, ignore_errors=True)
+
+    def test_entry_points_of_package_main(self):
+        self.assertNotIn(("cmd/app/main.go", "main"), self.unused)
+        self.assertNotIn(("cmd/app/main.go", "init"), self.unused)
+
+    def test_main_elsewhere_is_not_an_entry_point(self):
+        self.assertEqual(self.unused.get(("a/a.go", "main")), "high")
+        self.assertNotIn(("a/a.go", "init"), self.unused)
+
+    def test_unexported_mentions_count_in_their_own_package(self):
+        self.assertEqual(self.unused.get(("a/a.go", "helper")), "high")
+        self.assertNotIn(("b/b.go", "helper"), self.unused)
+        self.assertNotIn(("c/c.go", "limit"), self.unused)
+
+    def test_everything_else_is_used(self):
+        self.assertEqual(sorted(self.unused), [("a/a.go", "helper"), ("a/a.go", "main"), ("c/d.go", "doubled")])
+
+
+if __name__ == "__main__":
+    unittest.main()